@@ -7,18 +7,24 @@ module github.com/trustbloc/hub-router/cmd/hub-router
 go 1.15
 
 require (
+	github.com/aws/aws-sdk-go v1.36.29
 	github.com/cenkalti/backoff/v4 v4.1.0
-	github.com/gorilla/mux v1.7.4
 	github.com/hyperledger/aries-framework-go v0.1.7-0.20210526123422-eec182deab9a
+	github.com/hyperledger/aries-framework-go-ext/component/storage/couchdb v0.0.0-20210429200350-4099d2551ddd
 	github.com/hyperledger/aries-framework-go-ext/component/storage/mysql v0.0.0-20210429200350-4099d2551ddd
 	github.com/hyperledger/aries-framework-go/component/storageutil v0.0.0-20210520055214-ae429bb89bf7
 	github.com/hyperledger/aries-framework-go/spi v0.0.0-20210520055214-ae429bb89bf7
 	github.com/phayes/freeport v0.0.0-20180830031419-95f893ade6f2
 	github.com/rs/cors v1.7.0
 	github.com/spf13/cobra v1.0.0
-	github.com/stretchr/testify v1.7.0
+	github.com/spf13/pflag v1.0.3
+	github.com/stretchr/testify v1.7.1
 	github.com/trustbloc/edge-core v0.1.7-0.20210527163745-994ae929f957
 	github.com/trustbloc/hub-router v0.0.0-00010101000000-000000000000
+	github.com/xeipuuv/gojsonschema v1.2.0
 )
 
 replace github.com/trustbloc/hub-router => ../..
+
+// bou.ke/monkey, required by the tests of the CouchDB driver, is only available from its GitHub repository
+replace bou.ke/monkey => github.com/bouk/monkey v1.0.1
@@ -23,6 +23,7 @@ func main() {
 	}
 
 	cmd.AddCommand(startcmd.GetStartCmd(&startcmd.HTTPServer{}))
+	cmd.AddCommand(startcmd.GetConfigCmd())
 
 	if err := cmd.Execute(); err != nil {
 		log.Fatalf("failed to run hub-router: %s", err.Error())
@@ -0,0 +1,101 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/trustbloc/hub-router/pkg/ldcontext"
+)
+
+// Air-gapped mode config.
+const (
+	airGappedFlagName  = "air-gapped"
+	airGappedFlagUsage = "Air-gapped mode (true/false) : the router makes no outbound call but to the destinations" +
+		" configured explicitly, and fails to start if the config requires one, eg: the telemetry or the remote" +
+		" JSON-LD contexts. Defaults to false." +
+		" Alternatively, this can be set with the following environment variable: " + airGappedEnvKey
+	airGappedEnvKey = "HUB_ROUTER_AIR_GAPPED"
+)
+
+// egressCheck is a setting reaching a destination the operator didn't configure, rejected in air-gapped mode.
+type egressCheck struct {
+	flagName string
+	reason   string
+	set      bool
+}
+
+func createAirGapFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(airGappedFlagName, "", "", airGappedFlagUsage)
+}
+
+// getAirGapParams validates the config for the air-gapped mode, if enabled, and denies the remote loading of the
+// JSON-LD contexts unless a policy is set.
+func getAirGapParams(cmd *cobra.Command, params *hubRouterParameters) error {
+	airGapped, err := getBool(cmd, airGappedFlagName, airGappedEnvKey)
+	if err != nil || !airGapped {
+		return err
+	}
+
+	for _, check := range egressChecks(params) {
+		if check.set {
+			return fmt.Errorf("%s can't be set in air-gapped mode : %s", check.flagName, check.reason)
+		}
+	}
+
+	if params.ldContext == nil {
+		params.ldContext = &ldcontext.Config{}
+	}
+
+	if params.ldContext.Policy == "" {
+		params.ldContext.Policy = ldcontext.PolicyDeny
+	}
+
+	return nil
+}
+
+func egressChecks(params *hubRouterParameters) []egressCheck {
+	alerts := params.alerts
+	if alerts == nil {
+		alerts = &alertParameters{}
+	}
+
+	sinks := &eventSinkParameters{}
+	if params.webhookParams != nil && params.webhookParams.sinks != nil {
+		sinks = params.webhookParams.sinks
+	}
+
+	return []egressCheck{
+		{
+			flagName: telemetryURLFlagName,
+			reason:   "the telemetry is disabled",
+			set:      params.telemetryParams != nil && params.telemetryParams.url != "",
+		},
+		{
+			flagName: ldContextPolicyFlagName,
+			reason:   "the remote JSON-LD contexts can't be fetched, use " + ldContextBundleFlagName,
+			set:      params.ldContext != nil && params.ldContext.Policy == ldcontext.PolicyAllow,
+		},
+		{
+			flagName: alertPagerDutyKeyFlagName,
+			reason:   alertPagerDutyURLFlagName + " is required",
+			set:      alerts.pagerDutyKey != "" && alerts.pagerDutyURL == "",
+		},
+		{
+			flagName: alertOpsgenieKeyFlagName,
+			reason:   alertOpsgenieURLFlagName + " is required",
+			set:      alerts.opsgenieKey != "" && alerts.opsgenieURL == "",
+		},
+		{
+			flagName: pubSubTopicFlagName,
+			reason:   pubSubEndpointFlagName + " is required",
+			set:      sinks.pubSubTopic != "" && sinks.pubSubEndpoint == "",
+		},
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/ldcontext"
+)
+
+func TestGetAirGapParams(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createAirGapFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		params := &hubRouterParameters{telemetryParams: &telemetryParameters{url: "https://example.com"}}
+
+		require.NoError(t, getAirGapParams(newCmd(), params))
+		require.Nil(t, params.ldContext)
+	})
+
+	t.Run("remote contexts denied", func(t *testing.T) {
+		params := &hubRouterParameters{}
+
+		require.NoError(t, getAirGapParams(newCmd("--"+airGappedFlagName, "true"), params))
+		require.Equal(t, ldcontext.PolicyDeny, params.ldContext.Policy)
+
+		params = &hubRouterParameters{
+			ldContext: &ldcontext.Config{Policy: ldcontext.PolicyCacheOnly},
+			alerts:    &alertParameters{pagerDutyKey: "key", pagerDutyURL: "https://pagerduty.internal"},
+			webhookParams: &webhookParameters{
+				sinks: &eventSinkParameters{pubSubTopic: "topic", pubSubEndpoint: "https://pubsub.internal"},
+			},
+		}
+
+		require.NoError(t, getAirGapParams(newCmd("--"+airGappedFlagName, "true"), params))
+		require.Equal(t, ldcontext.PolicyCacheOnly, params.ldContext.Policy)
+	})
+
+	t.Run("egress rejected", func(t *testing.T) {
+		for flag, params := range map[string]*hubRouterParameters{
+			telemetryURLFlagName:      {telemetryParams: &telemetryParameters{url: "https://example.com"}},
+			ldContextPolicyFlagName:   {ldContext: &ldcontext.Config{Policy: ldcontext.PolicyAllow}},
+			alertPagerDutyKeyFlagName: {alerts: &alertParameters{pagerDutyKey: "key"}},
+			alertOpsgenieKeyFlagName:  {alerts: &alertParameters{opsgenieKey: "key"}},
+			pubSubTopicFlagName: {
+				webhookParams: &webhookParameters{sinks: &eventSinkParameters{pubSubTopic: "topic"}},
+			},
+		} {
+			err := getAirGapParams(newCmd("--"+airGappedFlagName, "true"), params)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), flag+" can't be set in air-gapped mode")
+		}
+	})
+
+	t.Run("invalid param", func(t *testing.T) {
+		err := getAirGapParams(newCmd("--"+airGappedFlagName, "maybe"), &hubRouterParameters{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid "+airGappedFlagName)
+	})
+}
+
+func TestAirGappedEgress(t *testing.T) {
+	var requests int32
+
+	external := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		w.Header().Set("Content-Type", "application/ld+json")
+		_, err := w.Write([]byte(`{"@context":{"name":"https://schema.org/name"}}`))
+		require.NoError(t, err)
+	}))
+	defer external.Close()
+
+	args := func(extra ...string) []string {
+		return append([]string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + airGappedFlagName, "true",
+		}, extra...)
+	}
+
+	t.Run("start", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+		startCmd.SetArgs(args())
+
+		require.NoError(t, startCmd.Execute())
+	})
+
+	t.Run("telemetry rejected", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+		startCmd.SetArgs(args("--"+telemetryURLFlagName, external.URL, "--"+telemetryIntervalFlagName, "1ms"))
+
+		err := startCmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), telemetryURLFlagName+" can't be set in air-gapped mode")
+	})
+
+	t.Run("remote contexts not fetched", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+		require.NoError(t, startCmd.ParseFlags(args()))
+
+		params, err := getHubRouterParameters(startCmd)
+		require.NoError(t, err)
+
+		loader, err := newContextLoader(mem.NewProvider(), params, nil)
+		require.NoError(t, err)
+
+		_, err = loader.LoadDocument(external.URL + "/contexts/v1")
+		require.True(t, errors.Is(err, ldcontext.ErrContextNotFound))
+
+		_, err = loader.LoadDocument("https://www.w3.org/2018/credentials/v1")
+		require.NoError(t, err)
+	})
+
+	require.Zero(t, atomic.LoadInt32(&requests))
+}
@@ -0,0 +1,112 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	hubrouter "github.com/trustbloc/hub-router/pkg/server"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+// REST API keys config.
+const (
+	operatorAPIKeyFlagName  = "operator-api-key"
+	operatorAPIKeyFlagUsage = "API key of the platform operator, required in the Authorization header" +
+		" (Bearer <key>) of the REST API requests. The REST API is open if no API key is set." +
+		" Alternatively, this can be set with the following environment variable: " + operatorAPIKeyEnvKey
+	operatorAPIKeyEnvKey = "HUB_ROUTER_OPERATOR_API_KEY"
+
+	tenantAPIKeyFlagName  = "tenant-api-key"
+	tenantAPIKeyFlagUsage = "API key of a tenant, in the tenant=key format. The tenant admins access the invitation," +
+		" wallets and stats endpoints, scoped to the wallets of their tenant. This flag can be repeated." +
+		" Alternatively, this can be set with the following environment variable (in CSV format): " +
+		tenantAPIKeyEnvKey
+	tenantAPIKeyEnvKey = "HUB_ROUTER_TENANT_API_KEY"
+)
+
+func createAPIKeyFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(operatorAPIKeyFlagName, "", "", operatorAPIKeyFlagUsage)
+	startCmd.Flags().StringArrayP(tenantAPIKeyFlagName, "", []string{}, tenantAPIKeyFlagUsage)
+}
+
+// getAPIKeys returns the REST API keys, disabled if no key is set.
+func getAPIKeys(cmd *cobra.Command) (*tenant.Keys, error) {
+	operatorKey, err := cmdutils.GetUserSetVarFromString(cmd, operatorAPIKeyFlagName, operatorAPIKeyEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantKeys, err := cmdutils.GetUserSetVarFromArrayString(cmd, tenantAPIKeyFlagName, tenantAPIKeyEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if operatorKey == "" && len(tenantKeys) > 0 {
+		return nil, fmt.Errorf("%s is required with %s", operatorAPIKeyFlagName, tenantAPIKeyFlagName)
+	}
+
+	keys := make(map[string]string, len(tenantKeys))
+
+	for _, val := range tenantKeys {
+		parts := strings.SplitN(val, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid %s : expected tenant=key format", tenantAPIKeyFlagName)
+		}
+
+		keys[parts[0]] = parts[1]
+	}
+
+	return tenant.NewKeys(operatorKey, keys), nil
+}
+
+// getTenantParams sets the REST API keys, the invitation tokens, the terms and the branding of the invitations.
+func getTenantParams(cmd *cobra.Command, params *hubRouterParameters) error {
+	var err error
+
+	params.apiKeys, err = getAPIKeys(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.invitationTokens, err = getInvitationTokenConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.terms, err = getTerms(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.branding, err = getBranding(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.label = cmdutils.GetUserSetOptionalVarFromString(cmd, routerLabelFlagName, routerLabelEnvKey)
+
+	params.userAgentTenants, err = getUserAgentTenants(cmd)
+
+	return err
+}
+
+func tenancyConfig(params *hubRouterParameters, transports *agentTransports,
+	tlsConfig *tls.Config) hubrouter.TenancyConfig {
+	return hubrouter.TenancyConfig{
+		APIKeys:          params.apiKeys,
+		Residency:        transports.residency,
+		Metering:         params.meteringParams.enabled,
+		MeteringSink:     newMeteringSink(params.meteringParams, params.cloudEvents, tlsConfig),
+		UserAgentTenants: params.userAgentTenants,
+	}
+}
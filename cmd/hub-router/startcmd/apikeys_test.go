@@ -0,0 +1,75 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAPIKeys(t *testing.T) {
+	t.Run("with api keys", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + operatorAPIKeyFlagName, "operator-key",
+			"--" + tenantAPIKeyFlagName, "tenant-1=key-1",
+			"--" + tenantAPIKeyFlagName, "tenant-2=key-2",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid api keys", func(t *testing.T) {
+		for val, errMsg := range map[string]string{
+			"tenant-1":  "invalid " + tenantAPIKeyFlagName,
+			"=key-1":    "invalid " + tenantAPIKeyFlagName,
+			"tenant-1=": "invalid " + tenantAPIKeyFlagName,
+		} {
+			startCmd := GetStartCmd(&mockServer{})
+
+			args := []string{
+				"--" + hostURLFlagName, "localhost:8080",
+				"--" + didCommHTTPHostFlagName, randomURL(t),
+				"--" + didCommWSHostFlagName, randomURL(t),
+				"--" + datasourcePersistentFlagName, "mem://tests",
+				"--" + datasourceTransientFlagName, "mem://tests",
+				"--" + operatorAPIKeyFlagName, "operator-key",
+				"--" + tenantAPIKeyFlagName, val,
+			}
+			startCmd.SetArgs(args)
+
+			err := startCmd.Execute()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), errMsg)
+		}
+
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + tenantAPIKeyFlagName, "tenant-1=key-1",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), operatorAPIKeyFlagName+" is required")
+	})
+}
@@ -0,0 +1,204 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/archive"
+	"github.com/trustbloc/hub-router/pkg/backup"
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/eventsink/aws"
+	hubrouter "github.com/trustbloc/hub-router/pkg/server"
+)
+
+// Dead-letter retention, archive and backup encryption config.
+const (
+	deadLetterRetentionFlagName  = "deadletter-retention"
+	deadLetterRetentionFlagUsage = "Period the dead-letter entries are kept for after their last update, eg: 720h." +
+		" The entries are archived before being deleted if the archive is configured. Kept forever if not set." +
+		" Alternatively, this can be set with the following environment variable: " + deadLetterRetentionEnvKey
+	deadLetterRetentionEnvKey = "HUB_ROUTER_DEADLETTER_RETENTION"
+
+	archiveS3BucketURLFlagName  = "archive-s3-bucket-url"
+	archiveS3BucketURLFlagUsage = "URL of the S3 (or S3-compatible) bucket the dead-letter entries are archived to," +
+		" once dead-lettered and when they expire, eg: https://{bucket}.s3.{region}.amazonaws.com or" +
+		" https://minio.example.com/{bucket}. The requests are signed with the aws-region and aws-access-key-id" +
+		" flags, or the default credential chain of the AWS SDK." +
+		" Alternatively, this can be set with the following environment variable: " + archiveS3BucketURLEnvKey
+	archiveS3BucketURLEnvKey = "HUB_ROUTER_ARCHIVE_S3_BUCKET_URL"
+
+	archivePrefixFlagName  = "archive-prefix"
+	archivePrefixFlagUsage = "Key prefix of the archived objects. Defaults to " + defaultArchivePrefix + "." +
+		" Alternatively, this can be set with the following environment variable: " + archivePrefixEnvKey
+	archivePrefixEnvKey  = "HUB_ROUTER_ARCHIVE_PREFIX"
+	defaultArchivePrefix = "hub-router/"
+
+	archivePayloadsFlagName  = "archive-payloads"
+	archivePayloadsFlagUsage = "Set to true to archive the dead-lettered messages, server-side encrypted, in" +
+		" addition to their metadata. Defaults to false." +
+		" Alternatively, this can be set with the following environment variable: " + archivePayloadsEnvKey
+	archivePayloadsEnvKey = "HUB_ROUTER_ARCHIVE_PAYLOADS"
+
+	archiveKMSKeyIDFlagName  = "archive-kms-key-id"
+	archiveKMSKeyIDFlagUsage = "AWS KMS key the archived payloads are encrypted with (SSE-KMS). The S3 managed keys" +
+		" (SSE-S3) are used if not set." +
+		" Alternatively, this can be set with the following environment variable: " + archiveKMSKeyIDEnvKey
+	archiveKMSKeyIDEnvKey = "HUB_ROUTER_ARCHIVE_KMS_KEY_ID"
+
+	archiveExpirationDaysFlagName  = "archive-expiration-days"
+	archiveExpirationDaysFlagUsage = "Number of days the archived objects are kept for : sets a lifecycle rule on the" +
+		" archive prefix, replacing the lifecycle configuration of the bucket. The lifecycle configuration is left" +
+		" unchanged if not set." +
+		" Alternatively, this can be set with the following environment variable: " + archiveExpirationDaysEnvKey
+	archiveExpirationDaysEnvKey = "HUB_ROUTER_ARCHIVE_EXPIRATION_DAYS"
+
+	backupAgeRecipientsFlagName  = "backup-age-recipients"
+	backupAgeRecipientsFlagUsage = "age public keys (age1...) of the operators the exports (audit, stats and" +
+		" metering) and the archived objects are encrypted to, before leaving the router. Each recipient can" +
+		" decrypt them with its private key (eg: age -d -i key.txt). Not encrypted if not set." +
+		" Alternatively, this can be set with the following environment variable: " + backupAgeRecipientsEnvKey
+	backupAgeRecipientsEnvKey = "HUB_ROUTER_BACKUP_AGE_RECIPIENTS"
+)
+
+type archiveParameters struct {
+	deadLetterRetention time.Duration
+	bucketURL           string
+	config              *archive.Config
+}
+
+func createArchiveFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(deadLetterRetentionFlagName, "", "", deadLetterRetentionFlagUsage)
+	startCmd.Flags().StringP(archiveS3BucketURLFlagName, "", "", archiveS3BucketURLFlagUsage)
+	startCmd.Flags().StringP(archivePrefixFlagName, "", "", archivePrefixFlagUsage)
+	startCmd.Flags().StringP(archivePayloadsFlagName, "", "", archivePayloadsFlagUsage)
+	startCmd.Flags().StringP(archiveKMSKeyIDFlagName, "", "", archiveKMSKeyIDFlagUsage)
+	startCmd.Flags().StringP(archiveExpirationDaysFlagName, "", "", archiveExpirationDaysFlagUsage)
+	startCmd.Flags().StringArrayP(backupAgeRecipientsFlagName, "", []string{}, backupAgeRecipientsFlagUsage)
+}
+
+func getArchiveParams(cmd *cobra.Command) (*archiveParameters, error) {
+	params := &archiveParameters{
+		bucketURL: cmdutils.GetUserSetOptionalVarFromString(cmd, archiveS3BucketURLFlagName, archiveS3BucketURLEnvKey),
+		config: &archive.Config{
+			Prefix:   cmdutils.GetUserSetOptionalVarFromString(cmd, archivePrefixFlagName, archivePrefixEnvKey),
+			KMSKeyID: cmdutils.GetUserSetOptionalVarFromString(cmd, archiveKMSKeyIDFlagName, archiveKMSKeyIDEnvKey),
+		},
+	}
+
+	if params.config.Prefix == "" {
+		params.config.Prefix = defaultArchivePrefix
+	}
+
+	var err error
+
+	if retention := cmdutils.GetUserSetOptionalVarFromString(cmd, deadLetterRetentionFlagName,
+		deadLetterRetentionEnvKey); retention != "" {
+		params.deadLetterRetention, err = time.ParseDuration(retention)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s : %w", deadLetterRetentionFlagName, err)
+		}
+	}
+
+	params.config.Payloads, err = getBool(cmd, archivePayloadsFlagName, archivePayloadsEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if days := cmdutils.GetUserSetOptionalVarFromString(cmd, archiveExpirationDaysFlagName,
+		archiveExpirationDaysEnvKey); days != "" {
+		params.config.ExpirationDays, err = strconv.Atoi(days)
+		if err != nil || params.config.ExpirationDays <= 0 {
+			return nil, fmt.Errorf("invalid %s : %s", archiveExpirationDaysFlagName, days)
+		}
+	}
+
+	params.config.Encrypter, err = getBackupEncrypter(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+// getBackupEncrypter returns the encrypter of the exports and archived objects, nil if no recipient is configured.
+func getBackupEncrypter(cmd *cobra.Command) (*backup.Encrypter, error) {
+	recipients, err := cmdutils.GetUserSetVarFromArrayString(cmd, backupAgeRecipientsFlagName,
+		backupAgeRecipientsEnvKey, true)
+	if err != nil || len(recipients) == 0 {
+		return nil, err
+	}
+
+	encrypter, err := backup.NewEncrypter(recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s : %w", backupAgeRecipientsFlagName, err)
+	}
+
+	return encrypter, nil
+}
+
+// setDeadLetterConfig sets the dead-letter retention and archive, and the backup encryption of the server config.
+func setDeadLetterConfig(config *hubrouter.Config, params *hubRouterParameters, tlsConfig *tls.Config) error {
+	archiver, err := newDeadLetterArchiver(params.archiveParams, params.webhookParams.sinks, tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	config.DeadLetters.Retention = deadLetterRetention(params.archiveParams)
+	config.DeadLetters.Archiver = archiver
+	config.Reporting.BackupEncrypter = backupEncrypter(params.archiveParams)
+
+	return nil
+}
+
+// newDeadLetterArchiver returns the archiver of the dead-letter entries, nil if no bucket is configured.
+func newDeadLetterArchiver(params *archiveParameters, awsParams *eventSinkParameters,
+	tlsConfig *tls.Config) (deadletter.Archiver, error) {
+	if params == nil || params.bucketURL == "" {
+		return nil, nil
+	}
+
+	if awsParams == nil {
+		awsParams = &eventSinkParameters{}
+	}
+
+	bucket, err := aws.NewS3(params.bucketURL,
+		awsConfig(awsParams, &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}))
+	if err != nil {
+		return nil, err
+	}
+
+	archiver, err := archive.New(bucket, params.config)
+	if err != nil {
+		return nil, err
+	}
+
+	return archiver, nil
+}
+
+func backupEncrypter(params *archiveParameters) *backup.Encrypter {
+	if params == nil {
+		return nil
+	}
+
+	return params.config.Encrypter
+}
+
+func deadLetterRetention(params *archiveParameters) time.Duration {
+	if params == nil {
+		return 0
+	}
+
+	return params.deadLetterRetention
+}
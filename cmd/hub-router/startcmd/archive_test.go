@@ -0,0 +1,131 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/archive"
+)
+
+func TestArchiveParams(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := GetStartCmd(&mockServer{})
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		params, err := getArchiveParams(newCmd())
+		require.NoError(t, err)
+		require.Zero(t, params.deadLetterRetention)
+		require.Empty(t, params.bucketURL)
+		require.Equal(t, &archive.Config{Prefix: defaultArchivePrefix}, params.config)
+		require.Zero(t, deadLetterRetention(params))
+		require.Zero(t, deadLetterRetention(nil))
+	})
+
+	t.Run("archive", func(t *testing.T) {
+		params, err := getArchiveParams(newCmd(
+			"--"+deadLetterRetentionFlagName, "720h",
+			"--"+archiveS3BucketURLFlagName, "https://archive.s3.eu-west-1.amazonaws.com",
+			"--"+archivePrefixFlagName, "router-1/",
+			"--"+archivePayloadsFlagName, "true",
+			"--"+archiveKMSKeyIDFlagName, "alias/archive",
+			"--"+archiveExpirationDaysFlagName, "90",
+		))
+		require.NoError(t, err)
+		require.Equal(t, 720*time.Hour, deadLetterRetention(params))
+		require.Equal(t, "https://archive.s3.eu-west-1.amazonaws.com", params.bucketURL)
+		require.Equal(t, &archive.Config{
+			Prefix: "router-1/", Payloads: true, KMSKeyID: "alias/archive", ExpirationDays: 90,
+		}, params.config)
+	})
+
+	t.Run("backup encryption", func(t *testing.T) {
+		params, err := getArchiveParams(newCmd(
+			"--"+backupAgeRecipientsFlagName, "age1xmwwc06ly3ee5rytxm9mflaz2u56jjj36s0mypdrwsvlul66mv4q47ryef",
+		))
+		require.NoError(t, err)
+		require.NotNil(t, params.config.Encrypter)
+		require.Equal(t, params.config.Encrypter, backupEncrypter(params))
+		require.Nil(t, backupEncrypter(nil))
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for flag, value := range map[string]string{
+			deadLetterRetentionFlagName:   "month",
+			archivePayloadsFlagName:       "maybe",
+			archiveExpirationDaysFlagName: "0",
+			backupAgeRecipientsFlagName:   "age1invalid",
+		} {
+			_, err := getArchiveParams(newCmd("--"+flag, value))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+	})
+}
+
+func TestNewDeadLetterArchiver(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	t.Run("no archive", func(t *testing.T) {
+		archiver, err := newDeadLetterArchiver(nil, nil, tlsConfig)
+		require.NoError(t, err)
+		require.Nil(t, archiver)
+
+		archiver, err = newDeadLetterArchiver(&archiveParameters{}, nil, tlsConfig)
+		require.NoError(t, err)
+		require.Nil(t, archiver)
+	})
+
+	t.Run("archive with lifecycle", func(t *testing.T) {
+		lifecycle := make(chan url.Values, 1)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lifecycle <- r.URL.Query()
+		}))
+		defer srv.Close()
+
+		archiver, err := newDeadLetterArchiver(&archiveParameters{
+			bucketURL: srv.URL + "/archive",
+			config:    &archive.Config{Prefix: defaultArchivePrefix, ExpirationDays: 30},
+		}, &eventSinkParameters{awsAccessKeyID: "AKID", awsSecretAccessKey: "secret"}, tlsConfig)
+		require.NoError(t, err)
+		require.IsType(t, &archive.Archiver{}, archiver)
+		require.Contains(t, <-lifecycle, "lifecycle")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := newDeadLetterArchiver(&archiveParameters{bucketURL: "archive", config: &archive.Config{}}, nil,
+			tlsConfig)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid s3 bucket url")
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer srv.Close()
+
+		archiver, err := newDeadLetterArchiver(&archiveParameters{
+			bucketURL: srv.URL + "/archive",
+			config:    &archive.Config{ExpirationDays: 30},
+		}, &eventSinkParameters{awsAccessKeyID: "AKID", awsSecretAccessKey: "secret"}, tlsConfig)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set archive lifecycle")
+		require.Nil(t, archiver)
+	})
+}
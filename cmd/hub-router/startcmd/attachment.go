@@ -0,0 +1,78 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/attachment"
+)
+
+// Attachment config.
+const (
+	attachmentBaseURLFlagName  = "attachment-base-url"
+	attachmentBaseURLFlagUsage = "External URL of the REST API the wallets fetch the attachments from, eg:" +
+		" https://router.example.com. Enables the attachment API : the adapters upload the large attachments to the" +
+		" router, and forward their expiring URL instead of the blob. Disabled if not set." +
+		" Alternatively, this can be set with the following environment variable: " + attachmentBaseURLEnvKey
+	attachmentBaseURLEnvKey = "HUB_ROUTER_ATTACHMENT_BASE_URL"
+
+	attachmentTTLFlagName  = "attachment-ttl"
+	attachmentTTLFlagUsage = "Maximum time the attachments are kept for, eg: 72h. Defaults to 24h." +
+		" Alternatively, this can be set with the following environment variable: " + attachmentTTLEnvKey
+	attachmentTTLEnvKey = "HUB_ROUTER_ATTACHMENT_TTL"
+
+	attachmentMaxSizeFlagName  = "attachment-max-size"
+	attachmentMaxSizeFlagUsage = "Maximum size of an attachment, in bytes. Defaults to 104857600 (100 MiB)." +
+		" Alternatively, this can be set with the following environment variable: " + attachmentMaxSizeEnvKey
+	attachmentMaxSizeEnvKey = "HUB_ROUTER_ATTACHMENT_MAX_SIZE"
+)
+
+func createAttachmentFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(attachmentBaseURLFlagName, "", "", attachmentBaseURLFlagUsage)
+	startCmd.Flags().StringP(attachmentTTLFlagName, "", "", attachmentTTLFlagUsage)
+	startCmd.Flags().StringP(attachmentMaxSizeFlagName, "", "", attachmentMaxSizeFlagUsage)
+}
+
+// getAttachmentConfig returns the attachment config, nil if the base URL isn't set.
+func getAttachmentConfig(cmd *cobra.Command) (*attachment.Config, error) {
+	config := &attachment.Config{
+		BaseURL: cmdutils.GetUserSetOptionalVarFromString(cmd, attachmentBaseURLFlagName, attachmentBaseURLEnvKey),
+	}
+
+	if config.BaseURL == "" {
+		return nil, nil
+	}
+
+	u, err := url.Parse(config.BaseURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("invalid %s : %s", attachmentBaseURLFlagName, config.BaseURL)
+	}
+
+	if ttl := cmdutils.GetUserSetOptionalVarFromString(cmd, attachmentTTLFlagName, attachmentTTLEnvKey); ttl != "" {
+		config.TTL, err = time.ParseDuration(ttl)
+		if err != nil || config.TTL <= 0 {
+			return nil, fmt.Errorf("invalid %s : %s", attachmentTTLFlagName, ttl)
+		}
+	}
+
+	if size := cmdutils.GetUserSetOptionalVarFromString(cmd, attachmentMaxSizeFlagName,
+		attachmentMaxSizeEnvKey); size != "" {
+		config.MaxSize, err = strconv.ParseInt(size, 10, 64)
+		if err != nil || config.MaxSize <= 0 {
+			return nil, fmt.Errorf("invalid %s : %s", attachmentMaxSizeFlagName, size)
+		}
+	}
+
+	return config, nil
+}
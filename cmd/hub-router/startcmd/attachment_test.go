@@ -0,0 +1,62 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/attachment"
+)
+
+func TestGetAttachmentConfig(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createAttachmentFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		config, err := getAttachmentConfig(newCmd("--"+attachmentTTLFlagName, "1h"))
+		require.NoError(t, err)
+		require.Nil(t, config)
+	})
+
+	t.Run("attachments", func(t *testing.T) {
+		config, err := getAttachmentConfig(newCmd(
+			"--"+attachmentBaseURLFlagName, "https://router.example.com",
+			"--"+attachmentTTLFlagName, "72h",
+			"--"+attachmentMaxSizeFlagName, "1048576",
+		))
+		require.NoError(t, err)
+		require.Equal(t, &attachment.Config{
+			BaseURL: "https://router.example.com", TTL: 72 * time.Hour, MaxSize: 1048576,
+		}, config)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for flag, value := range map[string]string{
+			attachmentBaseURLFlagName: "router.example.com",
+			attachmentTTLFlagName:     "-1h",
+			attachmentMaxSizeFlagName: "100MB",
+		} {
+			args := []string{"--" + flag, value}
+			if flag != attachmentBaseURLFlagName {
+				args = append(args, "--"+attachmentBaseURLFlagName, "https://router.example.com")
+			}
+
+			_, err := getAttachmentConfig(newCmd(args...))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/trustbloc/hub-router/pkg/backpressure"
+)
+
+// Backpressure config.
+const (
+	queueRecipientCapFlagName  = "queue-recipient-cap"
+	queueRecipientCapFlagUsage = "Number of messages queued for a single wallet from which the forwards addressed to" +
+		" it are rejected. The senders get a problem report with a retry hint, from 90% of the cap. Disabled if not set." +
+		" Alternatively, this can be set with the following environment variable: " + queueRecipientCapEnvKey
+	queueRecipientCapEnvKey = "HUB_ROUTER_QUEUE_RECIPIENT_CAP"
+
+	queueRetryAfterFlagName  = "queue-retry-after"
+	queueRetryAfterFlagUsage = "Time the senders are advised to pause for when the queue of a wallet is near its cap," +
+		" eg: 30s. Defaults to 1m. Alternatively, this can be set with the following environment variable: " +
+		queueRetryAfterEnvKey
+	queueRetryAfterEnvKey = "HUB_ROUTER_QUEUE_RETRY_AFTER"
+
+	transientRetryAfterFlagName  = "transient-retry-after"
+	transientRetryAfterFlagUsage = "Time the peers are advised to wait before retrying a message that failed on a" +
+		" transient router error (eg: a storage timeout or a KMS hiccup), eg: 10s. Defaults to 5s." +
+		" Alternatively, this can be set with the following environment variable: " + transientRetryAfterEnvKey
+	transientRetryAfterEnvKey = "HUB_ROUTER_TRANSIENT_RETRY_AFTER"
+)
+
+func createBackpressureFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(queueRecipientCapFlagName, "", "", queueRecipientCapFlagUsage)
+	startCmd.Flags().StringP(queueRetryAfterFlagName, "", "", queueRetryAfterFlagUsage)
+	startCmd.Flags().StringP(transientRetryAfterFlagName, "", "", transientRetryAfterFlagUsage)
+}
+
+// getBackpressureConfig returns the backpressure config, nil if the recipient cap isn't set.
+func getBackpressureConfig(cmd *cobra.Command) (*backpressure.Config, error) {
+	recipientCap, err := getWatermark(cmd, queueRecipientCapFlagName, queueRecipientCapEnvKey)
+	if err != nil || recipientCap == 0 {
+		return nil, err
+	}
+
+	if recipientCap < 0 {
+		return nil, fmt.Errorf("invalid %s : %d", queueRecipientCapFlagName, recipientCap)
+	}
+
+	retryAfter, err := getThreshold(cmd, queueRetryAfterFlagName, queueRetryAfterEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if retryAfter < 0 {
+		return nil, fmt.Errorf("invalid %s : %s", queueRetryAfterFlagName, retryAfter)
+	}
+
+	return &backpressure.Config{RecipientCap: recipientCap, RetryAfter: retryAfter}, nil
+}
+
+// getTransientRetryAfter returns the time the peers are advised to wait on transient errors, zero if not set.
+func getTransientRetryAfter(cmd *cobra.Command) (time.Duration, error) {
+	retryAfter, err := getThreshold(cmd, transientRetryAfterFlagName, transientRetryAfterEnvKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if retryAfter < 0 {
+		return 0, fmt.Errorf("invalid %s : %s", transientRetryAfterFlagName, retryAfter)
+	}
+
+	return retryAfter, nil
+}
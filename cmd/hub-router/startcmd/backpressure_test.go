@@ -0,0 +1,88 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/backpressure"
+)
+
+func TestGetBackpressureConfig(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createBackpressureFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		config, err := getBackpressureConfig(newCmd("--"+queueRetryAfterFlagName, "30s"))
+		require.NoError(t, err)
+		require.Nil(t, config)
+	})
+
+	t.Run("backpressure", func(t *testing.T) {
+		config, err := getBackpressureConfig(newCmd(
+			"--"+queueRecipientCapFlagName, "500",
+			"--"+queueRetryAfterFlagName, "30s",
+		))
+		require.NoError(t, err)
+		require.Equal(t, &backpressure.Config{RecipientCap: 500, RetryAfter: 30 * time.Second}, config)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for flag, value := range map[string]string{
+			queueRecipientCapFlagName: "-1",
+			queueRetryAfterFlagName:   "-1s",
+		} {
+			args := []string{"--" + flag, value}
+			if flag != queueRecipientCapFlagName {
+				args = append(args, "--"+queueRecipientCapFlagName, "500")
+			}
+
+			_, err := getBackpressureConfig(newCmd(args...))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+	})
+}
+
+func TestGetTransientRetryAfter(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createBackpressureFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("default", func(t *testing.T) {
+		retryAfter, err := getTransientRetryAfter(newCmd())
+		require.NoError(t, err)
+		require.Zero(t, retryAfter)
+	})
+
+	t.Run("retry after", func(t *testing.T) {
+		retryAfter, err := getTransientRetryAfter(newCmd("--"+transientRetryAfterFlagName, "10s"))
+		require.NoError(t, err)
+		require.Equal(t, 10*time.Second, retryAfter)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for _, value := range []string{"-1s", "soon"} {
+			_, err := getTransientRetryAfter(newCmd("--"+transientRetryAfterFlagName, value))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+transientRetryAfterFlagName)
+		}
+	})
+}
@@ -0,0 +1,58 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+// Tenant branding config.
+const (
+	tenantBrandingFileFlagName  = "tenant-branding-file"
+	tenantBrandingFileFlagUsage = "JSON file of the invitation branding of the tenants, keyed by tenant ID, eg:" +
+		` {"acme":{"label":"Acme","goal":"Connect your Acme wallet","imageUrl":"https://acme.example.com/logo.png"}}.` +
+		" The label, goal and imageUrl are embedded in the invitations issued under the API key of the tenant." +
+		" Alternatively, this can be set with the following environment variable: " + tenantBrandingFileEnvKey
+	tenantBrandingFileEnvKey = "HUB_ROUTER_TENANT_BRANDING_FILE"
+
+	routerLabelFlagName  = "router-label"
+	routerLabelFlagUsage = "Label of the invitations issued without tenant branding, unless set with the label query" +
+		" param of the invitation request. Defaults to hub-router." +
+		" Alternatively, this can be set with the following environment variable: " + routerLabelEnvKey
+	routerLabelEnvKey = "HUB_ROUTER_LABEL"
+)
+
+func createBrandingFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(tenantBrandingFileFlagName, "", "", tenantBrandingFileFlagUsage)
+	startCmd.Flags().StringP(routerLabelFlagName, "", "", routerLabelFlagUsage)
+}
+
+// getBranding returns the invitation branding of the tenants, nil if not configured.
+func getBranding(cmd *cobra.Command) (map[string]*tenant.Branding, error) {
+	file := cmdutils.GetUserSetOptionalVarFromString(cmd, tenantBrandingFileFlagName, tenantBrandingFileEnvKey)
+	if file == "" {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(file) // nolint:gosec // file path is set by the operator
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s : %w", tenantBrandingFileFlagName, err)
+	}
+
+	branding, err := tenant.ParseBranding(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s : %w", tenantBrandingFileFlagName, err)
+	}
+
+	return branding, nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+func TestGetBranding(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createBrandingFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	writeFile := func(data string) string {
+		file := filepath.Join(t.TempDir(), "branding.json")
+		require.NoError(t, ioutil.WriteFile(file, []byte(data), 0o600))
+
+		return file
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		branding, err := getBranding(newCmd())
+		require.NoError(t, err)
+		require.Nil(t, branding)
+	})
+
+	t.Run("branding", func(t *testing.T) {
+		branding, err := getBranding(newCmd(
+			"--"+tenantBrandingFileFlagName, writeFile(`{"acme":{"label":"Acme","goal":"Connect your Acme wallet"}}`),
+		))
+		require.NoError(t, err)
+		require.Equal(t, map[string]*tenant.Branding{
+			"acme": {Label: "Acme", Goal: "Connect your Acme wallet"},
+		}, branding)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for _, file := range []string{
+			filepath.Join(t.TempDir(), "missing.json"),
+			writeFile(`{"acme":{}}`),
+		} {
+			_, err := getBranding(newCmd("--"+tenantBrandingFileFlagName, file))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+tenantBrandingFileFlagName)
+		}
+	})
+}
+
+func TestGetRouterLabel(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	params := &hubRouterParameters{}
+	require.NoError(t, getTenantParams(newCmd(), params))
+	require.Empty(t, params.label)
+
+	require.NoError(t, getTenantParams(newCmd("--"+routerLabelFlagName, "Example Router"), params))
+	require.Equal(t, "Example Router", params.label)
+}
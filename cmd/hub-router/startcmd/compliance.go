@@ -0,0 +1,44 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/compliance"
+)
+
+// Protocol compliance config.
+const (
+	complianceModeFlagName  = "compliance-mode"
+	complianceModeFlagUsage = "Validation of the inbound DIDComm messages against the schemas of the Aries RFCs: " +
+		compliance.ModeOff + ", " + compliance.ModeReport + " (the non-compliant peers are reported by the" +
+		" compliance report API) or " + compliance.ModeStrict + " (their non-compliant messages are also rejected)," +
+		" eg: during interop plugfests. Defaults to " + compliance.ModeOff + "." +
+		" Alternatively, this can be set with the following environment variable: " + complianceModeEnvKey
+	complianceModeEnvKey = "HUB_ROUTER_COMPLIANCE_MODE"
+)
+
+func createComplianceFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(complianceModeFlagName, "", "", complianceModeFlagUsage)
+}
+
+func getComplianceMode(cmd *cobra.Command) (string, error) {
+	mode, err := cmdutils.GetUserSetVarFromString(cmd, complianceModeFlagName, complianceModeEnvKey, true)
+	if err != nil || mode == "" {
+		return compliance.ModeOff, err
+	}
+
+	if !compliance.ValidMode(mode) {
+		return "", fmt.Errorf("invalid %s : unsupported mode %s", complianceModeFlagName, mode)
+	}
+
+	return mode, nil
+}
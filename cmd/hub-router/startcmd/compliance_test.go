@@ -0,0 +1,43 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/compliance"
+)
+
+func TestGetComplianceMode(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("default", func(t *testing.T) {
+		mode, err := getComplianceMode(newCmd())
+		require.NoError(t, err)
+		require.Equal(t, compliance.ModeOff, mode)
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		params := &didCommParameters{}
+		require.NoError(t, getDIDCommOptions(newCmd("--"+complianceModeFlagName, compliance.ModeStrict), params))
+		require.Equal(t, compliance.ModeStrict, params.complianceMode)
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		_, err := getComplianceMode(newCmd("--"+complianceModeFlagName, "flag"))
+		require.EqualError(t, err, "invalid "+complianceModeFlagName+" : unsupported mode flag")
+	})
+}
@@ -0,0 +1,287 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	configFileFlagName  = "config-file"
+	configFileFlagUsage = "JSON config file to validate, keyed by the start flag names. The repeatable flags are set" +
+		" with arrays. The environment variables are validated with the file." +
+		" Alternatively, this can be set with the following environment variable: " + configFileEnvKey
+	configFileEnvKey = "HUB_ROUTER_CONFIG_FILE"
+)
+
+const (
+	envKeyPrefix = "HUB_ROUTER_"
+	envKeyMarker = "environment variable: "
+	boolMarker   = "[true] [false]"
+	schemaID     = "https://trustbloc.github.io/hub-router/config-schema.json"
+)
+
+// GetConfigCmd returns the Cobra config command, which validates the start configuration before deployment.
+func GetConfigCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Validate hub-router configuration",
+		Long:  "Validate the hub-router start configuration, or print its JSON schema",
+	}
+
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Validate a config file and the environment variables",
+		Long: "Validate a config file and the HUB_ROUTER_ environment variables against the configuration schema," +
+			" reporting the unknown keys, the invalid values and the invalid combinations",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			file, err := cmdutils.GetUserSetVarFromString(cmd, configFileFlagName, configFileEnvKey, true)
+			if err != nil {
+				return err
+			}
+
+			return validateConfig(cmd, file)
+		},
+	}
+
+	validateCmd.Flags().StringP(configFileFlagName, "", "", configFileFlagUsage)
+
+	schemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the configuration JSON schema",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			schemaBytes, err := json.MarshalIndent(configSchema(), "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshal config schema : %w", err)
+			}
+
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), string(schemaBytes))
+
+			return err
+		},
+	}
+
+	configCmd.AddCommand(validateCmd, schemaCmd)
+
+	return configCmd
+}
+
+// configSchema returns the JSON schema of the config file, generated from the start flags.
+func configSchema() map[string]interface{} {
+	properties := make(map[string]interface{})
+
+	startFlags().VisitAll(func(f *pflag.Flag) {
+		property := map[string]interface{}{"description": f.Usage}
+
+		switch {
+		case f.Value.Type() == "stringArray":
+			property["type"] = "array"
+			property["items"] = map[string]interface{}{"type": "string"}
+		case strings.Contains(f.Usage, boolMarker):
+			property["type"] = "string"
+			property["enum"] = []string{"true", "false"}
+		default:
+			property["type"] = "string"
+		}
+
+		properties[f.Name] = property
+	})
+
+	return map[string]interface{}{
+		"$schema":              "http://json-schema.org/draft-07/schema#",
+		"$id":                  schemaID,
+		"title":                "hub-router configuration",
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// validateConfig prints the problems of the config file and of the environment variables, and returns an error if
+// any. The parameters are not checked while the config file doesn't match the schema.
+func validateConfig(cmd *cobra.Command, file string) error {
+	startCmd := createStartCmd(nil)
+	createFlags(startCmd)
+
+	problems, err := configFileProblems(startCmd, file)
+	if err != nil {
+		return err
+	}
+
+	// the parameters are only checked once the config file is applied
+	if len(problems) == 0 {
+		problems = paramProblems(startCmd)
+	}
+
+	problems = append(problems, envProblems(startCmd.Flags())...)
+
+	out := cmd.OutOrStdout()
+
+	if len(problems) == 0 {
+		_, err = fmt.Fprintln(out, "configuration is valid")
+
+		return err
+	}
+
+	for _, p := range problems {
+		fmt.Fprintf(out, "- %s\n", p) // nolint:errcheck // the problems are also counted in the error
+	}
+
+	return fmt.Errorf("invalid configuration : %d problem(s)", len(problems))
+}
+
+// paramProblems parses the start parameters, as the start command does, and checks their combinations.
+func paramProblems(startCmd *cobra.Command) []string {
+	params, err := getHubRouterParameters(startCmd)
+	if err != nil {
+		return []string{err.Error()}
+	}
+
+	return combinationProblems(params)
+}
+
+// configFileProblems validates the config file against the schema, and sets its values on the start command flags.
+func configFileProblems(startCmd *cobra.Command, file string) ([]string, error) {
+	if file == "" {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadFile(file) // nolint:gosec // file path is set by the operator
+	if err != nil {
+		return nil, fmt.Errorf("read config file : %w", err)
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewGoLoader(configSchema()), gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse config file : %w", err)
+	}
+
+	if !result.Valid() {
+		var problems []string
+
+		for _, e := range result.Errors() {
+			problems = append(problems, e.String())
+		}
+
+		return problems, nil
+	}
+
+	config := make(map[string]interface{})
+
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, fmt.Errorf("parse config file : %w", err)
+	}
+
+	var problems []string
+
+	for name, val := range config {
+		for _, v := range configValues(val) {
+			err = startCmd.Flags().Set(name, v)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s : %s", name, err))
+			}
+		}
+	}
+
+	return problems, nil
+}
+
+func configValues(val interface{}) []string {
+	values, ok := val.([]interface{})
+	if !ok {
+		return []string{fmt.Sprint(val)}
+	}
+
+	result := make([]string, 0, len(values))
+
+	for _, v := range values {
+		result = append(result, fmt.Sprint(v))
+	}
+
+	return result
+}
+
+// envProblems reports the HUB_ROUTER_ environment variables that are not start flags, typically misspelled.
+func envProblems(flags *pflag.FlagSet) []string {
+	known := map[string]bool{configFileEnvKey: true}
+
+	flags.VisitAll(func(f *pflag.Flag) {
+		known[flagEnvKey(f)] = true
+	})
+
+	var problems []string
+
+	for _, env := range os.Environ() {
+		key := strings.SplitN(env, "=", 2)[0] // nolint:gomnd // key=value
+
+		if strings.HasPrefix(key, envKeyPrefix) && !known[key] {
+			problems = append(problems, fmt.Sprintf("unknown environment variable %s", key))
+		}
+	}
+
+	sort.Strings(problems)
+
+	return problems
+}
+
+// combinationProblems reports the settings that are valid on their own but not together.
+func combinationProblems(params *hubRouterParameters) []string {
+	var problems []string
+
+	if (params.tlsParams.serveCertPath == "") != (params.tlsParams.serveKeyPath == "") {
+		problems = append(problems, fmt.Sprintf("%s and %s must be set together",
+			tlsServeCertPathFlagName, tlsServeKeyPathFlagName))
+	}
+
+	didComm := params.didCommParameters
+	if didComm.httpHostInternal == didComm.wsHostInternal || params.hostURL == didComm.httpHostInternal ||
+		params.hostURL == didComm.wsHostInternal {
+		problems = append(problems, fmt.Sprintf("%s, %s and %s must be different addresses",
+			hostURLFlagName, didCommHTTPHostFlagName, didCommWSHostFlagName))
+	}
+
+	slow := params.slowConsumerConfig
+	if (slow.Degrade || slow.Window > 0) && !slow.Enabled() {
+		problems = append(problems, fmt.Sprintf("%s and %s require %s or %s", slowConsumerDegradeFlagName,
+			slowConsumerWindowFlagName, slowConsumerPickupThresholdFlagName, slowConsumerWriteThresholdFlagName))
+	}
+
+	if params.queueConfig.LoadShedding && params.queueConfig.GlobalWatermark == 0 {
+		problems = append(problems, fmt.Sprintf("%s requires %s", queueLoadSheddingFlagName, queueGlobalWatermarkFlagName))
+	}
+
+	return problems
+}
+
+// startFlags returns the flags of the start command.
+func startFlags() *pflag.FlagSet {
+	startCmd := createStartCmd(nil)
+	createFlags(startCmd)
+
+	return startCmd.Flags()
+}
+
+// flagEnvKey returns the environment variable of the flag, named at the end of its usage.
+func flagEnvKey(f *pflag.Flag) string {
+	i := strings.LastIndex(f.Usage, envKeyMarker)
+	if i < 0 {
+		return ""
+	}
+
+	return strings.TrimSpace(f.Usage[i+len(envKeyMarker):])
+}
@@ -0,0 +1,159 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSchema(t *testing.T) {
+	t.Run("published schema is up to date", func(t *testing.T) {
+		out := &bytes.Buffer{}
+
+		cmd := GetConfigCmd()
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"schema"})
+		require.NoError(t, cmd.Execute())
+
+		published, err := ioutil.ReadFile("../../../docs/config-schema.json")
+		require.NoError(t, err)
+		require.JSONEq(t, string(published), out.String(), "run 'hub-router config schema' to update the schema")
+	})
+
+	t.Run("flags", func(t *testing.T) {
+		schema := configSchema()
+
+		properties, ok := schema["properties"].(map[string]interface{})
+		require.True(t, ok)
+
+		property, ok := properties[webhookURLFlagName].(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, "array", property["type"])
+
+		property, ok = properties[meteringFlagName].(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, []string{"true", "false"}, property["enum"])
+	})
+
+	t.Run("every flag names its environment variable", func(t *testing.T) {
+		flags := startFlags()
+
+		for _, name := range []string{hostURLFlagName, datasourcePersistentFlagName, logLevelFlagName} {
+			require.NotEmpty(t, flagEnvKey(flags.Lookup(name)), name)
+		}
+
+		require.Equal(t, datasourcePersistentEnvKey, flagEnvKey(flags.Lookup(datasourcePersistentFlagName)))
+	})
+}
+
+func TestConfigValidate(t *testing.T) {
+	validate := func(t *testing.T, config map[string]interface{}) (string, error) {
+		t.Helper()
+
+		file := filepath.Join(t.TempDir(), "config.json")
+
+		configBytes, err := json.Marshal(config)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(file, configBytes, 0o600))
+
+		out := &bytes.Buffer{}
+
+		cmd := GetConfigCmd()
+		cmd.SetOut(out)
+		cmd.SetArgs([]string{"validate", "--" + configFileFlagName, file})
+
+		err = cmd.Execute()
+
+		return out.String(), err
+	}
+
+	validConfig := func() map[string]interface{} {
+		return map[string]interface{}{
+			hostURLFlagName:              "localhost:8080",
+			didCommHTTPHostFlagName:      "localhost:8081",
+			didCommWSHostFlagName:        "localhost:8082",
+			datasourcePersistentFlagName: "mem://tests",
+			datasourceTransientFlagName:  "mem://tests",
+			webhookURLFlagName:           []string{"http://localhost:9000"},
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		out, err := validate(t, validConfig())
+		require.NoError(t, err)
+		require.Contains(t, out, "configuration is valid")
+	})
+
+	t.Run("unknown key and invalid value", func(t *testing.T) {
+		config := validConfig()
+		config["metring"] = "true"
+		config[keyPinningFlagName] = "yes"
+
+		out, err := validate(t, config)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid configuration : 2 problem(s)")
+		require.Contains(t, out, "Additional property metring is not allowed")
+		require.Contains(t, out, keyPinningFlagName)
+	})
+
+	t.Run("invalid combinations", func(t *testing.T) {
+		config := validConfig()
+		config[didCommWSHostFlagName] = "localhost:8081"
+		config[tlsServeCertPathFlagName] = "cert.pem"
+		config[slowConsumerDegradeFlagName] = "true"
+		config[queueLoadSheddingFlagName] = "true"
+
+		out, err := validate(t, config)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "4 problem(s)")
+		require.Contains(t, out, "tls-serve-cert and tls-serve-key must be set together")
+		require.Contains(t, out, "must be different addresses")
+		require.Contains(t, out, "slow-consumer-degrade and slow-consumer-window require")
+		require.Contains(t, out, "queue-load-shedding requires queue-global-watermark")
+	})
+
+	t.Run("parameter error", func(t *testing.T) {
+		config := validConfig()
+		config[tenantAPIKeyFlagName] = []string{"tenant-1=key"}
+
+		out, err := validate(t, config)
+		require.Error(t, err)
+		require.Contains(t, out, "operator-api-key is required with tenant-api-key")
+	})
+
+	t.Run("unknown environment variable", func(t *testing.T) {
+		require.NoError(t, os.Setenv("HUB_ROUTER_METERING_KAFKA_TOPC", "topic"))
+
+		defer func() {
+			require.NoError(t, os.Unsetenv("HUB_ROUTER_METERING_KAFKA_TOPC"))
+		}()
+
+		out, err := validate(t, validConfig())
+		require.Error(t, err)
+		require.Contains(t, out, "unknown environment variable HUB_ROUTER_METERING_KAFKA_TOPC")
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		cmd := GetConfigCmd()
+		cmd.SetArgs([]string{"validate", "--" + configFileFlagName, filepath.Join(t.TempDir(), "missing.json")})
+		require.Contains(t, cmd.Execute().Error(), "read config file")
+
+		file := filepath.Join(t.TempDir(), "config.json")
+		require.NoError(t, ioutil.WriteFile(file, []byte("{"), 0o600))
+
+		cmd = GetConfigCmd()
+		cmd.SetArgs([]string{"validate", "--" + configFileFlagName, file})
+		require.Contains(t, cmd.Execute().Error(), "parse config file")
+	})
+}
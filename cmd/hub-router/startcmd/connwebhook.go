@@ -0,0 +1,57 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"net/http"
+
+	"github.com/spf13/cobra"
+
+	"github.com/trustbloc/hub-router/pkg/restapi/operation"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+// Connection webhook config.
+const (
+	connectionWebhooksFlagName  = "connection-webhooks"
+	connectionWebhooksFlagUsage = "Enables the webhooks registered for a connection (PUT /connections/{id}/webhook)," +
+		" eg: by the adapter that created it, receiving the notifications about the connection only. They are posted" +
+		" without the webhook client certificate and OAuth2 credentials. Possible values [true] [false]." +
+		" Defaults to false." +
+		" Alternatively, this can be set with the following environment variable: " + connectionWebhooksEnvKey
+	connectionWebhooksEnvKey = "HUB_ROUTER_CONNECTION_WEBHOOKS"
+)
+
+func createConnectionWebhookFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(connectionWebhooksFlagName, "", "", connectionWebhooksFlagUsage)
+}
+
+func getConnectionWebhookParams(cmd *cobra.Command, params *webhookParameters) error {
+	var err error
+
+	params.connectionWebhooks, err = getBool(cmd, connectionWebhooksFlagName, connectionWebhooksEnvKey)
+
+	return err
+}
+
+// connectionWebhookOption returns the option of the notifier posting to the connection webhooks, registered in the
+// persistent storage, nil if not enabled.
+func connectionWebhookOption(params *webhookParameters, s *operation.Storage, tlsConfig *tls.Config) (webhook.Option,
+	error) {
+	if !params.connectionWebhooks {
+		return nil, nil
+	}
+
+	webhooks, err := webhook.NewConnectionWebhooks(s.Persistent, s.Locks)
+	if err != nil {
+		return nil, err
+	}
+
+	return webhook.WithConnectionWebhooks(webhooks,
+		&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}), nil
+}
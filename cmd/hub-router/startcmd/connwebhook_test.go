@@ -0,0 +1,68 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/restapi/operation"
+)
+
+func TestConnectionWebhookParams(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createConnectionWebhookFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("default", func(t *testing.T) {
+		params := &webhookParameters{}
+		require.NoError(t, getConnectionWebhookParams(newCmd(), params))
+		require.False(t, params.connectionWebhooks)
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		params := &webhookParameters{}
+		require.NoError(t, getConnectionWebhookParams(newCmd("--"+connectionWebhooksFlagName, "true"), params))
+		require.True(t, params.connectionWebhooks)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		err := getConnectionWebhookParams(newCmd("--"+connectionWebhooksFlagName, "maybe"), &webhookParameters{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid connection-webhooks")
+	})
+}
+
+func TestNewWebhookConnectionWebhooks(t *testing.T) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	t.Run("without webhook URLs", func(t *testing.T) {
+		n, err := newWebhook(&webhookParameters{connectionWebhooks: true}, nil, tlsConfig,
+			&operation.Storage{Persistent: mem.NewProvider(), Locks: lock.NewLocal()})
+		require.NoError(t, err)
+		require.NotNil(t, n.ConnectionWebhooks())
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		_, err := newWebhook(&webhookParameters{connectionWebhooks: true}, nil, tlsConfig, &operation.Storage{
+			Persistent: &mockstore.MockStoreProvider{ErrOpenStoreHandle: errors.New("open error")},
+		})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open connection webhook store")
+	})
+}
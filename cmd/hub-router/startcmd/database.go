@@ -0,0 +1,193 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+// Database config.
+const (
+	databaseTypeFlagName  = "database-type"
+	databaseTypeFlagUsage = "Type of the database storing the mediation registrations, the connections and the queued" +
+		" messages of the router, used for both the persistent and the transient datasources unless " +
+		datasourcePersistentFlagName + " or " + datasourceTransientFlagName + " is set. Supported types are" +
+		" [mem, mysql, couchdb], mongodb isn't supported yet." +
+		" Alternatively, this can be set with the following environment variable: " + databaseTypeEnvKey
+	databaseTypeEnvKey = "HUB_ROUTER_DATABASE_TYPE"
+
+	databaseURLFlagName  = "database-url"
+	databaseURLFlagUsage = "Driver-specific name of the database set with " + databaseTypeFlagName + ", with" +
+		" credentials if required, eg: root:secret@tcp(localhost:3306)/?interpolateParams=true for mysql, or" +
+		" admin:secret@localhost:5984 for couchdb." +
+		" Alternatively, this can be set with the following environment variable: " + databaseURLEnvKey
+	databaseURLEnvKey = "HUB_ROUTER_DATABASE_URL"
+
+	databasePrefixFlagName  = "database-prefix"
+	databasePrefixFlagUsage = "Prefix of the databases and tables created by the router, eg: to share a database" +
+		" server between several routers. Defaults to " + storagePrefix + "." +
+		" Alternatively, this can be set with the following environment variable: " + databasePrefixEnvKey
+	databasePrefixEnvKey = "HUB_ROUTER_DATABASE_PREFIX"
+
+	// mongoDBDriver is recognized only to be rejected with the reason : the MongoDB provider of aries-framework-go-ext
+	// built against the storage interface of the Aries framework of the router is a stub, without the tags, queries,
+	// batches nor store configurations the framework and the router rely on. The first complete provider requires the
+	// storage interface of Aries v0.1.7, whose DIDComm v1 destinations only take did:key recipient keys : MongoDB is
+	// supported once the router is upgraded to it.
+	mongoDBDriver = "mongodb"
+)
+
+var errMongoDBUnsupported = errors.New("unsupported storage driver mongodb : the MongoDB provider of the Aries" +
+	" framework of the router is incomplete, use mysql or couchdb")
+
+type databaseParams struct {
+	driver string
+	url    string
+	prefix string
+}
+
+func createDatabaseFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(databaseTypeFlagName, "", "", databaseTypeFlagUsage)
+	startCmd.Flags().StringP(databaseURLFlagName, "", "", databaseURLFlagUsage)
+	startCmd.Flags().StringP(databasePrefixFlagName, "", "", databasePrefixFlagUsage)
+}
+
+// getDatabaseParams returns the database config, nil if no database type is set.
+func getDatabaseParams(cmd *cobra.Command) (*databaseParams, error) {
+	params := &databaseParams{}
+
+	for _, v := range []struct {
+		value            *string
+		flagName, envKey string
+	}{
+		{&params.driver, databaseTypeFlagName, databaseTypeEnvKey},
+		{&params.url, databaseURLFlagName, databaseURLEnvKey},
+		{&params.prefix, databasePrefixFlagName, databasePrefixEnvKey},
+	} {
+		val, err := cmdutils.GetUserSetVarFromString(cmd, v.flagName, v.envKey, true)
+		if err != nil {
+			return nil, err
+		}
+
+		*v.value = val
+	}
+
+	if params.driver == "" {
+		if params.url != "" {
+			return nil, fmt.Errorf("invalid %s : %s not set", databaseURLFlagName, databaseTypeFlagName)
+		}
+
+		if params.prefix == "" {
+			return nil, nil
+		}
+
+		return params, nil
+	}
+
+	if _, ok := supportedStorageProviders[params.driver]; !ok {
+		return nil, fmt.Errorf("invalid %s : %w", databaseTypeFlagName, unsupportedDriver(params.driver))
+	}
+
+	if params.url == "" && params.driver != memDriver {
+		return nil, fmt.Errorf("invalid %s : %s not set", databaseTypeFlagName, databaseURLFlagName)
+	}
+
+	return params, nil
+}
+
+// unsupportedDriver returns the error of a storage driver that isn't supported, with the reason for MongoDB.
+func unsupportedDriver(driver string) error {
+	if driver == mongoDBDriver {
+		return errMongoDBUnsupported
+	}
+
+	return fmt.Errorf("unsupported storage driver %s", driver)
+}
+
+// dbURL returns the datasource URL of the database, in the <driver>:[//]<driver-specific-dsn> format, empty if no
+// database type is set.
+func (p *databaseParams) dbURL() string {
+	if p == nil || p.driver == "" {
+		return ""
+	}
+
+	return p.driver + "://" + p.url
+}
+
+// dbPrefix returns the prefix of the databases created by the router.
+func (p *datasourceParams) dbPrefix() string {
+	if p.database == nil || p.database.prefix == "" {
+		return storagePrefix
+	}
+
+	return p.database.prefix
+}
+
+// initLocks returns the locks serializing the writes of the records shared by the nodes : the named locks of the
+// persistent database if MySQL, the lock documents of the persistent database if CouchDB, the locks of the process
+// otherwise. With a DSN file, the locks are reconnected when the credentials in the file change, like the stores.
+func initLocks(params *datasourceParams) (lock.Locker, error) {
+	driver, dsn, err := getDBParams(params.persistentURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if driver != mysqlDriver && driver != couchDBDriver {
+		return lock.NewLocal(), nil
+	}
+
+	if params.persistentFile == "" {
+		return openLocks(driver, dsn, params.dbPrefix())
+	}
+
+	locks, err := lock.NewRotating(func(url string) (lock.Locker, error) {
+		d, rotatedDSN, parseErr := getDBParams(url)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+
+		if d != driver {
+			return nil, fmt.Errorf("lock driver can't change from %s to %s", driver, d)
+		}
+
+		return openLocks(driver, rotatedDSN, params.dbPrefix())
+	}, params.persistentURL)
+	if err != nil {
+		return nil, err
+	}
+
+	params.watch(params.persistentFile, params.persistentURL, locks)
+
+	return locks, nil
+}
+
+// openLocks connects the locks of the MySQL or CouchDB database.
+func openLocks(driver, dsn, prefix string) (lock.Locker, error) {
+	if driver == couchDBDriver {
+		locks, err := lock.NewCouchDB(dsn, prefix+"_", lock.DefaultTimeout, lock.DefaultLease)
+		if err != nil {
+			return nil, fmt.Errorf("open lock database : %w", err)
+		}
+
+		return locks, nil
+	}
+
+	// the mysql driver is registered by the mysql storage provider
+	db, err := sql.Open(mysqlDriver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open lock database : %w", err)
+	}
+
+	return lock.NewSQL(db, prefix, lock.DefaultTimeout), nil
+}
@@ -0,0 +1,264 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/keyusage"
+	hubrouter "github.com/trustbloc/hub-router/pkg/server"
+	"github.com/trustbloc/hub-router/pkg/upstream"
+)
+
+// Mediation config.
+const (
+	autoGrantMediationFlagName  = "auto-grant-mediation"
+	autoGrantMediationFlagUsage = "Grant mediation to the connections created through create-conn-req," +
+		" registering the recipient keys of the wallet DID doc with the router." +
+		" Possible values [true] [false]. Defaults to false if not set." +
+		" Alternatively, this can be set with the following environment variable: " + autoGrantMediationEnvKey
+	autoGrantMediationEnvKey = "HUB_ROUTER_AUTO_GRANT_MEDIATION"
+
+	multiHopForwardFlagName  = "multi-hop-forward"
+	multiHopForwardFlagUsage = "Relay the nested forward messages addressed to another mediator DID (the router being" +
+		" one hop of a chain of mediators) to the endpoint of that DID." +
+		" Possible values [true] [false]. Defaults to false if not set." +
+		" Alternatively, this can be set with the following environment variable: " + multiHopForwardEnvKey
+	multiHopForwardEnvKey = "HUB_ROUTER_MULTI_HOP_FORWARD"
+
+	handoverGracePeriodFlagName  = "handover-grace-period"
+	handoverGracePeriodFlagUsage = "Let the wallets moving to another mediator request a handover, with a DIDComm" +
+		" message or the REST API : for the given period, the messages queued for the wallet and those still forwarded" +
+		" to it are forwarded to the endpoint of the new mediator. Disabled if not set. Format: Go duration (eg: 72h)." +
+		" Alternatively, this can be set with the following environment variable: " + handoverGracePeriodEnvKey
+	handoverGracePeriodEnvKey = "HUB_ROUTER_HANDOVER_GRACE_PERIOD"
+
+	grantTransferFlagName  = "grant-transfer"
+	grantTransferFlagUsage = "Let the wallets recovered on a new device transfer the mediation of their previous DID" +
+		" (the recipient keys routed and the messages queued) to the connection of the new device, with a DIDComm" +
+		" message proving the control of a recipient key of the previous DID." +
+		" Possible values [true] [false]. Defaults to false if not set." +
+		" Alternatively, this can be set with the following environment variable: " + grantTransferEnvKey
+	grantTransferEnvKey = "HUB_ROUTER_GRANT_TRANSFER"
+
+	recoveryTokenTTLFlagName  = "recovery-token-ttl"
+	recoveryTokenTTLFlagUsage = "Lifetime of the recovery tokens issued to the wallet backends, proving the grant" +
+		" transfers. Defaults to 720h if not set. Format: Go duration (eg: 720h)." +
+		" Alternatively, this can be set with the following environment variable: " + recoveryTokenTTLEnvKey
+	recoveryTokenTTLEnvKey = "HUB_ROUTER_RECOVERY_TOKEN_TTL"
+
+	recoveryTokenSecretFlagName  = "recovery-token-secret"
+	recoveryTokenSecretFlagUsage = "Secret signing the recovery tokens, of at least 32 bytes. If not set, a secret is" +
+		" generated and stored in the persistent datasource, shared by the router instances." +
+		" Alternatively, this can be set with the following environment variable: " + recoveryTokenSecretEnvKey
+	recoveryTokenSecretEnvKey = "HUB_ROUTER_RECOVERY_TOKEN_SECRET"
+
+	// minRecoveryTokenSecretSize is the size in bytes of the shortest recovery token secret, the size of the HS256 hash.
+	minRecoveryTokenSecretSize = 32
+)
+
+// Security config.
+const (
+	keyPinningFlagName  = "key-pinning"
+	keyPinningFlagUsage = "Pin the sender key of the inbound envelopes per connection on first use, and reject the" +
+		" envelopes sent with another key (unless the message carries a DID rotation signed with the pinned key)." +
+		" Possible values [true] [false]. Defaults to false if not set." +
+		" Alternatively, this can be set with the following environment variable: " + keyPinningEnvKey
+	keyPinningEnvKey = "HUB_ROUTER_KEY_PINNING"
+
+	keyReusePolicyFlagName  = "key-reuse-policy"
+	keyReusePolicyFlagUsage = "Policy applied when a router key (router DID or routing key) is reused across" +
+		" connections. Possible values [audit] [reject]: audit raises a security event and reports the key in" +
+		" GET /audit/key-reuse, reject also rejects the connection or mediation request. Defaults to audit if not set." +
+		" Alternatively, this can be set with the following environment variable: " + keyReusePolicyEnvKey
+	keyReusePolicyEnvKey = "HUB_ROUTER_KEY_REUSE_POLICY"
+)
+
+type didCommParameters struct {
+	httpHostInternal    string
+	httpHostExternal    string
+	wsHostInternal      string
+	wsHostExternal      string
+	autoGrantMediation  bool
+	multiHopForward     bool
+	keyPinning          bool
+	handoverGracePeriod time.Duration
+	grantTransfer       bool
+	recoveryTokenTTL    time.Duration
+	recoveryTokenSecret string
+	keyReusePolicy      string
+	complianceMode      string
+	upstream            *upstream.Config
+	advertisedEndpoint  string
+	advertisedEndpoints []string
+}
+
+func createDIDCommFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(didCommHTTPHostFlagName, "", "", didCommHTTPHostFlagUsage)
+	startCmd.Flags().StringP(didCommHTTPHostExternalFlagName, "", "", didCommHTTPHostExternalFlagUsage)
+	startCmd.Flags().StringP(didCommWSHostFlagName, "", "", didCommWSHostFlagUsage)
+	startCmd.Flags().StringP(didCommWSHostExternalFlagName, "", "", didCommWSHostExternalFlagUsage)
+	startCmd.Flags().StringP(autoGrantMediationFlagName, "", "", autoGrantMediationFlagUsage)
+	startCmd.Flags().StringP(multiHopForwardFlagName, "", "", multiHopForwardFlagUsage)
+	startCmd.Flags().StringP(handoverGracePeriodFlagName, "", "", handoverGracePeriodFlagUsage)
+	startCmd.Flags().StringP(grantTransferFlagName, "", "", grantTransferFlagUsage)
+	startCmd.Flags().StringP(recoveryTokenTTLFlagName, "", "", recoveryTokenTTLFlagUsage)
+	startCmd.Flags().StringP(recoveryTokenSecretFlagName, "", "", recoveryTokenSecretFlagUsage)
+
+	startCmd.Flags().StringP(keyPinningFlagName, "", "", keyPinningFlagUsage)
+	startCmd.Flags().StringP(keyReusePolicyFlagName, "", "", keyReusePolicyFlagUsage)
+}
+
+func getDIDCommParams(cmd *cobra.Command) (*didCommParameters, error) {
+	httpHostInternal, err := cmdutils.GetUserSetVarFromString(cmd, didCommHTTPHostFlagName,
+		didCommHTTPHostEnvKey, false)
+	if err != nil {
+		return nil, err
+	}
+
+	httpHostExternal, err := cmdutils.GetUserSetVarFromString(cmd, didCommHTTPHostExternalFlagName,
+		didCommHTTPHostExternalEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	wsHostInternal, err := cmdutils.GetUserSetVarFromString(cmd, didCommWSHostFlagName,
+		didCommWSHostEnvKey, false)
+	if err != nil {
+		return nil, err
+	}
+
+	wsHostExternal, err := cmdutils.GetUserSetVarFromString(cmd, didCommWSHostExternalFlagName,
+		didCommWSHostExternalEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &didCommParameters{
+		httpHostInternal: httpHostInternal,
+		httpHostExternal: httpHostExternal,
+		wsHostInternal:   wsHostInternal,
+		wsHostExternal:   wsHostExternal,
+	}
+
+	err = getDIDCommOptions(cmd, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+// getDIDCommOptions sets the mediation, forwarding and key options of the DIDComm parameters.
+func getDIDCommOptions(cmd *cobra.Command, params *didCommParameters) error {
+	var err error
+
+	params.autoGrantMediation, err = getBool(cmd, autoGrantMediationFlagName, autoGrantMediationEnvKey)
+	if err != nil {
+		return err
+	}
+
+	params.multiHopForward, err = getBool(cmd, multiHopForwardFlagName, multiHopForwardEnvKey)
+	if err != nil {
+		return err
+	}
+
+	params.handoverGracePeriod, err = getThreshold(cmd, handoverGracePeriodFlagName, handoverGracePeriodEnvKey)
+	if err != nil {
+		return err
+	}
+
+	params.grantTransfer, err = getBool(cmd, grantTransferFlagName, grantTransferEnvKey)
+	if err != nil {
+		return err
+	}
+
+	err = getRecoveryTokenOptions(cmd, params)
+	if err != nil {
+		return err
+	}
+
+	params.keyPinning, err = getBool(cmd, keyPinningFlagName, keyPinningEnvKey)
+	if err != nil {
+		return err
+	}
+
+	params.keyReusePolicy, err = getKeyReusePolicy(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.complianceMode, err = getComplianceMode(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.upstream, err = getUpstreamConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.advertisedEndpoint, err = getAdvertisedEndpoint(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.advertisedEndpoints, err = getAdvertisedEndpoints(cmd, params)
+
+	return err
+}
+
+// getRecoveryTokenOptions sets the lifetime and the secret of the recovery tokens of the grant transfers.
+func getRecoveryTokenOptions(cmd *cobra.Command, params *didCommParameters) error {
+	var err error
+
+	params.recoveryTokenTTL, err = getThreshold(cmd, recoveryTokenTTLFlagName, recoveryTokenTTLEnvKey)
+	if err != nil {
+		return err
+	}
+
+	params.recoveryTokenSecret = cmdutils.GetUserSetOptionalVarFromString(cmd, recoveryTokenSecretFlagName,
+		recoveryTokenSecretEnvKey)
+
+	if params.recoveryTokenSecret != "" && len(params.recoveryTokenSecret) < minRecoveryTokenSecretSize {
+		return fmt.Errorf("invalid %s : at least %d bytes expected", recoveryTokenSecretFlagName,
+			minRecoveryTokenSecretSize)
+	}
+
+	return nil
+}
+
+func getKeyReusePolicy(cmd *cobra.Command) (string, error) {
+	policy, err := cmdutils.GetUserSetVarFromString(cmd, keyReusePolicyFlagName, keyReusePolicyEnvKey, true)
+	if err != nil || policy == "" {
+		return keyusage.PolicyAudit, err
+	}
+
+	if !keyusage.ValidPolicy(policy) {
+		return "", fmt.Errorf("invalid %s : unsupported policy %s", keyReusePolicyFlagName, policy)
+	}
+
+	return policy, nil
+}
+
+func mediationConfig(params *hubRouterParameters) hubrouter.MediationConfig {
+	return hubrouter.MediationConfig{
+		AutoGrant:           params.didCommParameters.autoGrantMediation,
+		PresenceTimeout:     presenceTimeout(params.webhookParams),
+		KeyReusePolicy:      params.didCommParameters.keyReusePolicy,
+		GrantTransfer:       params.didCommParameters.grantTransfer,
+		RecoveryTokenTTL:    params.didCommParameters.recoveryTokenTTL,
+		RecoveryTokenSecret: []byte(params.didCommParameters.recoveryTokenSecret),
+		AdvertisedEndpoint:  params.didCommParameters.advertisedEndpoint,
+		Endpoints:           params.didCommParameters.advertisedEndpoints,
+		Upstream:            params.didCommParameters.upstream,
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+)
+
+// Advertised endpoint config.
+const (
+	advertisedEndpointFlagName  = "advertised-endpoint"
+	advertisedEndpointFlagUsage = "Externally reachable URL of the router, eg: https://router.example.com, advertised in" +
+		" the router DID docs, the create-conn-resp messages and the mediation grants, instead of the endpoint of the" +
+		" inbound transports : in the cloud or behind NAT, where the router binds to a private address." +
+		" Alternatively, this can be set with the following environment variable: " + advertisedEndpointEnvKey
+	advertisedEndpointEnvKey = "HUB_ROUTER_ADVERTISED_ENDPOINT"
+
+	advertisedTransportFlagName  = "advertised-transport"
+	advertisedTransportFlagUsage = "Transport whose external endpoint is advertised in the invitations and the router" +
+		" DID docs : http (" + didCommHTTPHostExternalFlagName + ") or ws (" + didCommWSHostExternalFlagName + ")." +
+		" Repeat the flag in order of priority, eg: ws first for the wallets honoring the first service only. The" +
+		" first endpoint is advertised in the mediation grants. Defaults to the default endpoint of the agent." +
+		" Alternatively, this can be set with the following environment variable (comma separated): " +
+		advertisedTransportEnvKey
+	advertisedTransportEnvKey = "HUB_ROUTER_ADVERTISED_TRANSPORTS"
+)
+
+// Advertised transports.
+const (
+	transportHTTP = "http"
+	transportWS   = "ws"
+)
+
+func createEndpointFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(advertisedEndpointFlagName, "", "", advertisedEndpointFlagUsage)
+	startCmd.Flags().StringArrayP(advertisedTransportFlagName, "", []string{}, advertisedTransportFlagUsage)
+}
+
+// getAdvertisedEndpoint returns the advertised endpoint, empty if not configured.
+func getAdvertisedEndpoint(cmd *cobra.Command) (string, error) {
+	value := cmdutils.GetUserSetOptionalVarFromString(cmd, advertisedEndpointFlagName, advertisedEndpointEnvKey)
+	if value == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(value)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid %s : %s", advertisedEndpointFlagName, value)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "ws", "wss":
+		return value, nil
+	default:
+		return "", fmt.Errorf("invalid %s : unsupported scheme %s", advertisedEndpointFlagName, u.Scheme)
+	}
+}
+
+// getAdvertisedEndpoints returns the external endpoints of the advertised transports in order of priority, nil if not
+// configured.
+func getAdvertisedEndpoints(cmd *cobra.Command, params *didCommParameters) ([]string, error) {
+	transports, err := cmdutils.GetUserSetVarFromArrayString(cmd, advertisedTransportFlagName,
+		advertisedTransportEnvKey, true)
+	if err != nil || len(transports) == 0 {
+		return nil, err
+	}
+
+	if params.advertisedEndpoint != "" {
+		return nil, fmt.Errorf("%s and %s are exclusive", advertisedEndpointFlagName, advertisedTransportFlagName)
+	}
+
+	external := map[string]string{transportHTTP: params.httpHostExternal, transportWS: params.wsHostExternal}
+	flags := map[string]string{transportHTTP: didCommHTTPHostExternalFlagName, transportWS: didCommWSHostExternalFlagName}
+
+	var endpoints []string
+
+	for i, t := range transports {
+		flag, ok := flags[t]
+		if !ok {
+			return nil, fmt.Errorf("invalid %s : unsupported transport %s", advertisedTransportFlagName, t)
+		}
+
+		for _, previous := range transports[:i] {
+			if previous == t {
+				return nil, fmt.Errorf("invalid %s : duplicate transport %s", advertisedTransportFlagName, t)
+			}
+		}
+
+		if external[t] == "" {
+			return nil, fmt.Errorf("%s %s requires %s", advertisedTransportFlagName, t, flag)
+		}
+
+		endpoints = append(endpoints, external[t])
+	}
+
+	return endpoints, nil
+}
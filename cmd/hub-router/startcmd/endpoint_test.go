@@ -0,0 +1,108 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAdvertisedEndpoint(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createEndpointFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("default", func(t *testing.T) {
+		endpoint, err := getAdvertisedEndpoint(newCmd())
+		require.NoError(t, err)
+		require.Empty(t, endpoint)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		for _, value := range []string{"https://router.example.com", "wss://router.example.com:8443/ws"} {
+			endpoint, err := getAdvertisedEndpoint(newCmd("--"+advertisedEndpointFlagName, value))
+			require.NoError(t, err)
+			require.Equal(t, value, endpoint)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, value := range []string{"router.example.com", "https://", "%zz"} {
+			_, err := getAdvertisedEndpoint(newCmd("--"+advertisedEndpointFlagName, value))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid advertised-endpoint : "+value)
+		}
+
+		_, err := getAdvertisedEndpoint(newCmd("--"+advertisedEndpointFlagName, "ftp://router.example.com"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unsupported scheme ftp")
+	})
+}
+
+func TestGetAdvertisedEndpoints(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createEndpointFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	params := func() *didCommParameters {
+		return &didCommParameters{
+			httpHostExternal: "https://router.example.com", wsHostExternal: "wss://router.example.com/ws",
+		}
+	}
+
+	t.Run("default", func(t *testing.T) {
+		endpoints, err := getAdvertisedEndpoints(newCmd(), params())
+		require.NoError(t, err)
+		require.Nil(t, endpoints)
+	})
+
+	t.Run("in order of priority", func(t *testing.T) {
+		endpoints, err := getAdvertisedEndpoints(newCmd("--"+advertisedTransportFlagName, "ws",
+			"--"+advertisedTransportFlagName, "http"), params())
+		require.NoError(t, err)
+		require.Equal(t, []string{"wss://router.example.com/ws", "https://router.example.com"}, endpoints)
+
+		endpoints, err = getAdvertisedEndpoints(newCmd("--"+advertisedTransportFlagName, "http"), params())
+		require.NoError(t, err)
+		require.Equal(t, []string{"https://router.example.com"}, endpoints)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := getAdvertisedEndpoints(newCmd("--"+advertisedTransportFlagName, "smtp"), params())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid advertised-transport : unsupported transport smtp")
+
+		_, err = getAdvertisedEndpoints(newCmd("--"+advertisedTransportFlagName, "ws",
+			"--"+advertisedTransportFlagName, "ws"), params())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid advertised-transport : duplicate transport ws")
+
+		p := params()
+		p.wsHostExternal = ""
+
+		_, err = getAdvertisedEndpoints(newCmd("--"+advertisedTransportFlagName, "ws"), p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "advertised-transport ws requires didcomm-ws-host-external")
+
+		p = params()
+		p.advertisedEndpoint = "https://lb.example.com"
+
+		_, err = getAdvertisedEndpoints(newCmd("--"+advertisedTransportFlagName, "ws"), p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "advertised-endpoint and advertised-transport are exclusive")
+	})
+}
@@ -0,0 +1,248 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/eventsink/aws"
+	"github.com/trustbloc/hub-router/pkg/eventsink/pubsub"
+	"github.com/trustbloc/hub-router/pkg/eventsink/servicebus"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+// AWS event sinks config.
+const (
+	sqsQueueURLFlagName  = "event-sqs-queue-url"
+	sqsQueueURLFlagUsage = "URL of the AWS SQS queue the event notifications are sent to, in addition to the" +
+		" webhooks. The notifications of a connection are ordered with a FIFO queue." +
+		" Alternatively, this can be set with the following environment variable: " + sqsQueueURLEnvKey
+	sqsQueueURLEnvKey = "HUB_ROUTER_EVENT_SQS_QUEUE_URL"
+
+	snsTopicARNFlagName  = "event-sns-topic-arn"
+	snsTopicARNFlagUsage = "ARN of the AWS SNS topic the event notifications are published to, in addition to the" +
+		" webhooks. The notifications of a connection are ordered with a FIFO topic." +
+		" Alternatively, this can be set with the following environment variable: " + snsTopicARNEnvKey
+	snsTopicARNEnvKey = "HUB_ROUTER_EVENT_SNS_TOPIC_ARN"
+
+	awsRegionFlagName  = "aws-region"
+	awsRegionFlagUsage = "AWS region of the SQS queue and SNS topic. Defaults to the region of the queue URL and" +
+		" topic ARN." +
+		" Alternatively, this can be set with the following environment variable: " + awsRegionEnvKey
+	awsRegionEnvKey = "HUB_ROUTER_AWS_REGION"
+
+	awsAccessKeyIDFlagName  = "aws-access-key-id"
+	awsAccessKeyIDFlagUsage = "AWS access key ID. The default credential chain of the AWS SDK (environment, web" +
+		" identity, ECS task role or EC2 instance profile) is used if not set." +
+		" Alternatively, this can be set with the following environment variable: " + awsAccessKeyIDEnvKey
+	awsAccessKeyIDEnvKey = "HUB_ROUTER_AWS_ACCESS_KEY_ID"
+
+	awsSecretAccessKeyFlagName  = "aws-secret-access-key"
+	awsSecretAccessKeyFlagUsage = "AWS secret access key, required with the access key ID." +
+		" Alternatively, this can be set with the following environment variable: " + awsSecretAccessKeyEnvKey
+	awsSecretAccessKeyEnvKey = "HUB_ROUTER_AWS_SECRET_ACCESS_KEY"
+)
+
+// GCP event sink config.
+const (
+	pubSubTopicFlagName  = "event-pubsub-topic"
+	pubSubTopicFlagUsage = "Google Cloud Pub/Sub topic (projects/{project}/topics/{topic}) the event notifications" +
+		" are published to, in addition to the webhooks. The notifications of a connection are published with the" +
+		" connection ID as ordering key." +
+		" Alternatively, this can be set with the following environment variable: " + pubSubTopicEnvKey
+	pubSubTopicEnvKey = "HUB_ROUTER_EVENT_PUBSUB_TOPIC"
+
+	pubSubEndpointFlagName  = "pubsub-endpoint"
+	pubSubEndpointFlagUsage = "Pub/Sub API endpoint, eg: a regional endpoint (https://{region}-pubsub.googleapis.com)" +
+		" to keep the ordering guarantees when the router runs in several regions. Defaults to the global endpoint." +
+		" Alternatively, this can be set with the following environment variable: " + pubSubEndpointEnvKey
+	pubSubEndpointEnvKey = "HUB_ROUTER_PUBSUB_ENDPOINT"
+
+	gcpCredentialsFileFlagName  = "gcp-credentials-file"
+	gcpCredentialsFileFlagUsage = "Path to the GCP service account key file. The Application Default Credentials" +
+		" (GOOGLE_APPLICATION_CREDENTIALS or the metadata server) are used if not set." +
+		" Alternatively, this can be set with the following environment variable: " + gcpCredentialsFileEnvKey
+	gcpCredentialsFileEnvKey = "HUB_ROUTER_GCP_CREDENTIALS_FILE"
+)
+
+// Azure event sink config.
+const (
+	serviceBusURLFlagName  = "event-servicebus-url"
+	serviceBusURLFlagUsage = "URL of the Azure Service Bus queue or topic" +
+		" (https://{namespace}.servicebus.windows.net/{entity}) the event notifications are sent to, in addition to" +
+		" the webhooks. The notifications of a connection are sent in the session of the connection ID." +
+		" Defaults to the EntityPath of the connection string." +
+		" Alternatively, this can be set with the following environment variable: " + serviceBusURLEnvKey
+	serviceBusURLEnvKey = "HUB_ROUTER_EVENT_SERVICEBUS_URL"
+
+	serviceBusConnStrFlagName  = "servicebus-connection-string"
+	serviceBusConnStrFlagUsage = "Azure Service Bus connection string, with the shared access key the messages are" +
+		" sent with. The Azure identity the router runs with (environment service principal, workload identity," +
+		" App Service, container app or VM managed identity) is used if not set." +
+		" Alternatively, this can be set with the following environment variable: " + serviceBusConnStrEnvKey
+	serviceBusConnStrEnvKey = "HUB_ROUTER_SERVICEBUS_CONNECTION_STRING"
+)
+
+type eventSinkParameters struct {
+	sqsQueueURL        string
+	snsTopicARN        string
+	awsRegion          string
+	awsAccessKeyID     string
+	awsSecretAccessKey string
+	pubSubTopic        string
+	pubSubEndpoint     string
+	gcpCredentialsFile string
+	serviceBusURL      string
+	serviceBusConnStr  string
+}
+
+func (p *eventSinkParameters) enabled() bool {
+	return p != nil && (p.sqsQueueURL != "" || p.snsTopicARN != "" || p.pubSubTopic != "" ||
+		p.serviceBusURL != "" || p.serviceBusConnStr != "")
+}
+
+func createEventSinkFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(sqsQueueURLFlagName, "", "", sqsQueueURLFlagUsage)
+	startCmd.Flags().StringP(snsTopicARNFlagName, "", "", snsTopicARNFlagUsage)
+	startCmd.Flags().StringP(awsRegionFlagName, "", "", awsRegionFlagUsage)
+	startCmd.Flags().StringP(awsAccessKeyIDFlagName, "", "", awsAccessKeyIDFlagUsage)
+	startCmd.Flags().StringP(awsSecretAccessKeyFlagName, "", "", awsSecretAccessKeyFlagUsage)
+	startCmd.Flags().StringP(pubSubTopicFlagName, "", "", pubSubTopicFlagUsage)
+	startCmd.Flags().StringP(pubSubEndpointFlagName, "", "", pubSubEndpointFlagUsage)
+	startCmd.Flags().StringP(gcpCredentialsFileFlagName, "", "", gcpCredentialsFileFlagUsage)
+	startCmd.Flags().StringP(serviceBusURLFlagName, "", "", serviceBusURLFlagUsage)
+	startCmd.Flags().StringP(serviceBusConnStrFlagName, "", "", serviceBusConnStrFlagUsage)
+}
+
+func getEventSinkParams(cmd *cobra.Command) (*eventSinkParameters, error) {
+	params := &eventSinkParameters{
+		sqsQueueURL:    cmdutils.GetUserSetOptionalVarFromString(cmd, sqsQueueURLFlagName, sqsQueueURLEnvKey),
+		snsTopicARN:    cmdutils.GetUserSetOptionalVarFromString(cmd, snsTopicARNFlagName, snsTopicARNEnvKey),
+		awsRegion:      cmdutils.GetUserSetOptionalVarFromString(cmd, awsRegionFlagName, awsRegionEnvKey),
+		awsAccessKeyID: cmdutils.GetUserSetOptionalVarFromString(cmd, awsAccessKeyIDFlagName, awsAccessKeyIDEnvKey),
+		awsSecretAccessKey: cmdutils.GetUserSetOptionalVarFromString(cmd, awsSecretAccessKeyFlagName,
+			awsSecretAccessKeyEnvKey),
+		pubSubTopic:    cmdutils.GetUserSetOptionalVarFromString(cmd, pubSubTopicFlagName, pubSubTopicEnvKey),
+		pubSubEndpoint: cmdutils.GetUserSetOptionalVarFromString(cmd, pubSubEndpointFlagName, pubSubEndpointEnvKey),
+		gcpCredentialsFile: cmdutils.GetUserSetOptionalVarFromString(cmd, gcpCredentialsFileFlagName,
+			gcpCredentialsFileEnvKey),
+		serviceBusURL: cmdutils.GetUserSetOptionalVarFromString(cmd, serviceBusURLFlagName, serviceBusURLEnvKey),
+		serviceBusConnStr: cmdutils.GetUserSetOptionalVarFromString(cmd, serviceBusConnStrFlagName,
+			serviceBusConnStrEnvKey),
+	}
+
+	if (params.awsAccessKeyID == "") != (params.awsSecretAccessKey == "") {
+		return nil, fmt.Errorf("%s and %s must be set together", awsAccessKeyIDFlagName, awsSecretAccessKeyFlagName)
+	}
+
+	return params, nil
+}
+
+// newEventSinks returns the sinks the event notifications are published to, in addition to the webhooks.
+func newEventSinks(params *eventSinkParameters, tlsConfig *tls.Config) ([]webhook.Sink, error) {
+	if !params.enabled() {
+		return nil, nil
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+
+	sinks, err := newAWSSinks(params, client)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.pubSubTopic != "" {
+		publisher, err := newPubSubPublisher(params, client)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, publisher)
+	}
+
+	if params.serviceBusURL != "" || params.serviceBusConnStr != "" {
+		sender, err := newServiceBusSender(params, client, tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, sender)
+	}
+
+	return sinks, nil
+}
+
+// awsConfig returns the AWS config of the region and access key flags, also used by the archive.
+func awsConfig(params *eventSinkParameters, client *http.Client) *aws.Config {
+	config := &aws.Config{Region: params.awsRegion, Client: client}
+
+	if params.awsAccessKeyID != "" {
+		config.Credentials = credentials.NewStaticCredentials(params.awsAccessKeyID, params.awsSecretAccessKey, "")
+	}
+
+	return config
+}
+
+func newAWSSinks(params *eventSinkParameters, client *http.Client) ([]webhook.Sink, error) {
+	config := awsConfig(params, client)
+
+	var sinks []webhook.Sink
+
+	if params.sqsQueueURL != "" {
+		sqs, err := aws.NewSQS(params.sqsQueueURL, config)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, sqs)
+	}
+
+	if params.snsTopicARN != "" {
+		sns, err := aws.NewSNS(params.snsTopicARN, config)
+		if err != nil {
+			return nil, err
+		}
+
+		sinks = append(sinks, sns)
+	}
+
+	return sinks, nil
+}
+
+func newPubSubPublisher(params *eventSinkParameters, client *http.Client) (*pubsub.Publisher, error) {
+	creds := pubsub.NewDefaultTokenSource(client)
+
+	if params.gcpCredentialsFile != "" {
+		var err error
+
+		creds, err = pubsub.NewServiceAccountTokenSource(params.gcpCredentialsFile, client)
+		if err != nil {
+			return nil, fmt.Errorf("pubsub credentials : %w", err)
+		}
+	}
+
+	return pubsub.New(params.pubSubTopic, &pubsub.Config{
+		Endpoint:    params.pubSubEndpoint,
+		Credentials: creds,
+		Client:      client,
+	})
+}
+
+func newServiceBusSender(params *eventSinkParameters, client *http.Client,
+	tlsConfig *tls.Config) (*servicebus.Sender, error) {
+	return servicebus.New(params.serviceBusURL, &servicebus.Config{
+		ConnectionString: params.serviceBusConnStr,
+		Client:           client,
+		TLSConfig:        tlsConfig,
+	})
+}
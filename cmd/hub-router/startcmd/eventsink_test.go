@@ -0,0 +1,139 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/eventsink/aws"
+	"github.com/trustbloc/hub-router/pkg/eventsink/pubsub"
+	"github.com/trustbloc/hub-router/pkg/eventsink/servicebus"
+)
+
+func TestEventSinks(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := GetStartCmd(&mockServer{})
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	t.Run("no sink", func(t *testing.T) {
+		params, err := getEventSinkParams(newCmd())
+		require.NoError(t, err)
+		require.False(t, params.enabled())
+
+		sinks, err := newEventSinks(params, tlsConfig)
+		require.NoError(t, err)
+		require.Empty(t, sinks)
+	})
+
+	t.Run("sqs and sns", func(t *testing.T) {
+		params, err := getEventSinkParams(newCmd(
+			"--"+sqsQueueURLFlagName, "https://sqs.eu-west-1.amazonaws.com/123/events.fifo",
+			"--"+snsTopicARNFlagName, "arn:aws:sns:eu-west-1:123:events",
+			"--"+awsAccessKeyIDFlagName, "AKID",
+			"--"+awsSecretAccessKeyFlagName, "secret",
+		))
+		require.NoError(t, err)
+		require.True(t, params.enabled())
+
+		sinks, err := newEventSinks(params, tlsConfig)
+		require.NoError(t, err)
+		require.Len(t, sinks, 2)
+		require.IsType(t, &aws.SQS{}, sinks[0])
+		require.IsType(t, &aws.SNS{}, sinks[1])
+
+		n, err := newWebhook(&webhookParameters{sinks: params}, nil, tlsConfig, nil)
+		require.NoError(t, err)
+		require.NotNil(t, n)
+	})
+
+	t.Run("pubsub", func(t *testing.T) {
+		params, err := getEventSinkParams(newCmd(
+			"--"+pubSubTopicFlagName, "projects/router/topics/events",
+			"--"+pubSubEndpointFlagName, "https://europe-west1-pubsub.googleapis.com",
+		))
+		require.NoError(t, err)
+		require.True(t, params.enabled())
+
+		sinks, err := newEventSinks(params, tlsConfig)
+		require.NoError(t, err)
+		require.Len(t, sinks, 1)
+		require.IsType(t, &pubsub.Publisher{}, sinks[0])
+	})
+
+	t.Run("service bus", func(t *testing.T) {
+		params, err := getEventSinkParams(newCmd(
+			"--"+serviceBusConnStrFlagName, "Endpoint=sb://router.servicebus.windows.net/;"+
+				"SharedAccessKeyName=send;SharedAccessKey=secret;EntityPath=events",
+		))
+		require.NoError(t, err)
+		require.True(t, params.enabled())
+
+		sinks, err := newEventSinks(params, tlsConfig)
+		require.NoError(t, err)
+		require.Len(t, sinks, 1)
+		require.IsType(t, &servicebus.Sender{}, sinks[0])
+
+		sinks, err = newEventSinks(&eventSinkParameters{
+			serviceBusURL: "https://router.servicebus.windows.net/events",
+		}, tlsConfig)
+		require.NoError(t, err)
+		require.Len(t, sinks, 1)
+	})
+
+	t.Run("access key without secret", func(t *testing.T) {
+		_, err := getEventSinkParams(newCmd("--"+awsAccessKeyIDFlagName, "AKID"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "aws-access-key-id and aws-secret-access-key must be set together")
+	})
+
+	t.Run("invalid sinks", func(t *testing.T) {
+		_, err := newEventSinks(&eventSinkParameters{sqsQueueURL: "http://localhost/123/events"}, tlsConfig)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no region in sqs queue url")
+
+		_, err = newEventSinks(&eventSinkParameters{snsTopicARN: "invalid"}, tlsConfig)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid sns topic arn")
+
+		_, err = newWebhook(&webhookParameters{sinks: &eventSinkParameters{snsTopicARN: "invalid"}}, nil, tlsConfig,
+			nil)
+		require.Error(t, err)
+
+		_, err = newEventSinks(&eventSinkParameters{pubSubTopic: "events"}, tlsConfig)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid pubsub topic")
+
+		_, err = newEventSinks(&eventSinkParameters{
+			pubSubTopic: "projects/router/topics/events", gcpCredentialsFile: "/invalid/key.json",
+		}, tlsConfig)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "pubsub credentials : read service account key")
+
+		_, err = newEventSinks(&eventSinkParameters{serviceBusConnStr: "invalid"}, tlsConfig)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid service bus connection string")
+
+		_, err = newEventSinks(&eventSinkParameters{
+			serviceBusConnStr: "Endpoint=sb://router.servicebus.windows.net/;SharedAccessKeyName=send;SharedAccessKey=k",
+		}, tlsConfig)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "service bus entity url is required")
+
+		_, err = newEventSinks(&eventSinkParameters{serviceBusURL: "router"}, tlsConfig)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid service bus entity url")
+	})
+}
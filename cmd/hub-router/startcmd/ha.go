@@ -0,0 +1,118 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/ha"
+)
+
+// Active/standby config.
+const (
+	haModeFlagName  = "ha-mode"
+	haModeFlagUsage = "Mode of the node in an active/standby deployment, active or standby. The active node holds" +
+		" the fencing token in the persistent storage shared by the nodes, the standby nodes refuse the DIDComm" +
+		" messages and the REST API updates. A node started active waits for the lease of another active node to" +
+		" expire. Not an active/standby deployment if not set." +
+		" Alternatively, this can be set with the following environment variable: " + haModeEnvKey
+	haModeEnvKey = "HUB_ROUTER_HA_MODE"
+
+	haNodeIDFlagName  = "ha-node-id"
+	haNodeIDFlagUsage = "ID of the node in the fencing token. Defaults to the host name." +
+		" Alternatively, this can be set with the following environment variable: " + haNodeIDEnvKey
+	haNodeIDEnvKey = "HUB_ROUTER_HA_NODE_ID"
+
+	haRenewIntervalFlagName  = "ha-renew-interval"
+	haRenewIntervalFlagUsage = "Interval the active node renews the fencing token at, and the standby nodes read it" +
+		" at, eg: 5s. Defaults to 10s." +
+		" Alternatively, this can be set with the following environment variable: " + haRenewIntervalEnvKey
+	haRenewIntervalEnvKey = "HUB_ROUTER_HA_RENEW_INTERVAL"
+
+	haAutoPromoteFlagName  = "ha-auto-promote"
+	haAutoPromoteFlagUsage = "Promote the standby node to active when the fencing token isn't renewed by the active" +
+		" node within the lease timeout. Possible values [true] [false]. Defaults to false." +
+		" Alternatively, this can be set with the following environment variable: " + haAutoPromoteEnvKey
+	haAutoPromoteEnvKey = "HUB_ROUTER_HA_AUTO_PROMOTE"
+
+	haLeaseTimeoutFlagName  = "ha-lease-timeout"
+	haLeaseTimeoutFlagUsage = "Time after which the fencing token not renewed expires, eg: 30s. Defaults to three" +
+		" renew intervals. Alternatively, this can be set with the following environment variable: " +
+		haLeaseTimeoutEnvKey
+	haLeaseTimeoutEnvKey = "HUB_ROUTER_HA_LEASE_TIMEOUT"
+)
+
+func createHAFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(haModeFlagName, "", "", haModeFlagUsage)
+	startCmd.Flags().StringP(haNodeIDFlagName, "", "", haNodeIDFlagUsage)
+	startCmd.Flags().StringP(haRenewIntervalFlagName, "", "", haRenewIntervalFlagUsage)
+	startCmd.Flags().StringP(haAutoPromoteFlagName, "", "", haAutoPromoteFlagUsage)
+	startCmd.Flags().StringP(haLeaseTimeoutFlagName, "", "", haLeaseTimeoutFlagUsage)
+}
+
+// getHAConfig returns the active/standby config, nil if the mode isn't set.
+func getHAConfig(cmd *cobra.Command) (*ha.Config, error) {
+	mode, err := cmdutils.GetUserSetVarFromString(cmd, haModeFlagName, haModeEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == "" {
+		return nil, nil
+	}
+
+	if mode != ha.ModeActive && mode != ha.ModeStandby {
+		return nil, fmt.Errorf("invalid %s : %s, expected %s or %s", haModeFlagName, mode, ha.ModeActive, ha.ModeStandby)
+	}
+
+	nodeID, err := cmdutils.GetUserSetVarFromString(cmd, haNodeIDFlagName, haNodeIDEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if nodeID == "" {
+		nodeID, err = os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s : host name : %w", haNodeIDFlagName, err)
+		}
+	}
+
+	config := &ha.Config{Mode: mode, NodeID: nodeID}
+
+	return config, getHALease(cmd, config)
+}
+
+// getHALease sets the renew interval, the lease timeout and the auto promotion of the active/standby config.
+func getHALease(cmd *cobra.Command, config *ha.Config) error {
+	var err error
+
+	config.RenewInterval, err = getThreshold(cmd, haRenewIntervalFlagName, haRenewIntervalEnvKey)
+	if err != nil {
+		return err
+	}
+
+	if config.RenewInterval < 0 {
+		return fmt.Errorf("invalid %s : must be positive", haRenewIntervalFlagName)
+	}
+
+	config.LeaseTimeout, err = getThreshold(cmd, haLeaseTimeoutFlagName, haLeaseTimeoutEnvKey)
+	if err != nil {
+		return err
+	}
+
+	if config.LeaseTimeout < 0 {
+		return fmt.Errorf("invalid %s : must be positive", haLeaseTimeoutFlagName)
+	}
+
+	config.AutoPromote, err = getBool(cmd, haAutoPromoteFlagName, haAutoPromoteEnvKey)
+
+	return err
+}
@@ -0,0 +1,72 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/ha"
+)
+
+func TestGetHAConfig(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createHAFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		config, err := getHAConfig(newCmd())
+		require.NoError(t, err)
+		require.Nil(t, config)
+	})
+
+	t.Run("active", func(t *testing.T) {
+		config, err := getHAConfig(newCmd(
+			"--"+haModeFlagName, ha.ModeActive,
+			"--"+haNodeIDFlagName, "node-1",
+			"--"+haRenewIntervalFlagName, "5s",
+			"--"+haLeaseTimeoutFlagName, "20s",
+			"--"+haAutoPromoteFlagName, "true",
+		))
+		require.NoError(t, err)
+		require.Equal(t, &ha.Config{
+			Mode: ha.ModeActive, NodeID: "node-1", RenewInterval: 5 * time.Second, LeaseTimeout: 20 * time.Second,
+			AutoPromote: true,
+		}, config)
+	})
+
+	t.Run("standby defaults", func(t *testing.T) {
+		config, err := getHAConfig(newCmd("--"+haModeFlagName, ha.ModeStandby))
+		require.NoError(t, err)
+
+		hostname, err := os.Hostname()
+		require.NoError(t, err)
+		require.Equal(t, &ha.Config{Mode: ha.ModeStandby, NodeID: hostname}, config)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for _, args := range [][]string{
+			{"--" + haModeFlagName, "primary"},
+			{"--" + haModeFlagName, ha.ModeActive, "--" + haRenewIntervalFlagName, "soon"},
+			{"--" + haModeFlagName, ha.ModeActive, "--" + haRenewIntervalFlagName, "-5s"},
+			{"--" + haModeFlagName, ha.ModeActive, "--" + haLeaseTimeoutFlagName, "-5s"},
+			{"--" + haModeFlagName, ha.ModeStandby, "--" + haAutoPromoteFlagName, "maybe"},
+		} {
+			_, err := getHAConfig(newCmd(args...))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+args[len(args)-2][2:])
+		}
+	})
+}
@@ -0,0 +1,42 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+)
+
+// History config.
+const (
+	historyLimitFlagName  = "history-limit"
+	historyLimitFlagUsage = "Number of changes kept in the history of each connection and of its mediation" +
+		" (GET /connections/{id}/history), the oldest changes are dropped. Defaults to 100." +
+		" Alternatively, this can be set with the following environment variable: " + historyLimitEnvKey
+	historyLimitEnvKey = "HUB_ROUTER_HISTORY_LIMIT"
+)
+
+func createHistoryFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(historyLimitFlagName, "", "", historyLimitFlagUsage)
+}
+
+func getHistoryLimit(cmd *cobra.Command) (int, error) {
+	value := cmdutils.GetUserSetOptionalVarFromString(cmd, historyLimitFlagName, historyLimitEnvKey)
+	if value == "" {
+		return 0, nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		return 0, fmt.Errorf("invalid %s : %s", historyLimitFlagName, value)
+	}
+
+	return limit, nil
+}
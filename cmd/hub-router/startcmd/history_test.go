@@ -0,0 +1,44 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetHistoryLimit(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createHistoryFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("default", func(t *testing.T) {
+		limit, err := getHistoryLimit(newCmd())
+		require.NoError(t, err)
+		require.Zero(t, limit)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		limit, err := getHistoryLimit(newCmd("--"+historyLimitFlagName, "20"))
+		require.NoError(t, err)
+		require.Equal(t, 20, limit)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, value := range []string{"many", "0", "-1"} {
+			_, err := getHistoryLimit(newCmd("--"+historyLimitFlagName, value))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid history-limit")
+		}
+	})
+}
@@ -0,0 +1,41 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Idempotency config.
+const (
+	idempotencyKeyTTLFlagName  = "idempotency-key-ttl"
+	idempotencyKeyTTLFlagUsage = "Time the results of the admin requests (POST and DELETE) with an Idempotency-Key" +
+		" header are recorded for in the transient storage, so that the requests retried with the same key aren't" +
+		" executed again, eg: 1h. Defaults to 24h." +
+		" Alternatively, this can be set with the following environment variable: " + idempotencyKeyTTLEnvKey
+	idempotencyKeyTTLEnvKey = "HUB_ROUTER_IDEMPOTENCY_KEY_TTL"
+)
+
+func createIdempotencyFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(idempotencyKeyTTLFlagName, "", "", idempotencyKeyTTLFlagUsage)
+}
+
+func getIdempotencyKeyTTL(cmd *cobra.Command) (time.Duration, error) {
+	ttl, err := getThreshold(cmd, idempotencyKeyTTLFlagName, idempotencyKeyTTLEnvKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if ttl < 0 {
+		return 0, fmt.Errorf("invalid %s : must be positive", idempotencyKeyTTLFlagName)
+	}
+
+	return ttl, nil
+}
@@ -0,0 +1,47 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetIdempotencyKeyTTL(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createIdempotencyFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("default", func(t *testing.T) {
+		ttl, err := getIdempotencyKeyTTL(newCmd())
+		require.NoError(t, err)
+		require.Zero(t, ttl)
+	})
+
+	t.Run("set", func(t *testing.T) {
+		ttl, err := getIdempotencyKeyTTL(newCmd("--"+idempotencyKeyTTLFlagName, "1h"))
+		require.NoError(t, err)
+		require.Equal(t, time.Hour, ttl)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := getIdempotencyKeyTTL(newCmd("--"+idempotencyKeyTTLFlagName, "soon"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid idempotency-key-ttl")
+
+		_, err = getIdempotencyKeyTTL(newCmd("--"+idempotencyKeyTTLFlagName, "-1h"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "must be positive")
+	})
+}
@@ -0,0 +1,59 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/incident"
+)
+
+// Incident timeline config.
+const (
+	incidentRetentionFlagName  = "incident-retention"
+	incidentRetentionFlagUsage = "Period the resolved incidents of the timeline (GET /incidents) are kept for, eg:" +
+		" 720h. Defaults to 2160h (90 days)." +
+		" Alternatively, this can be set with the following environment variable: " + incidentRetentionEnvKey
+	incidentRetentionEnvKey = "HUB_ROUTER_INCIDENT_RETENTION"
+
+	incidentErrorBurstFlagName  = "incident-error-burst-threshold"
+	incidentErrorBurstFlagUsage = "Number of internal errors within a minute opening an error burst incident." +
+		" Defaults to 10." +
+		" Alternatively, this can be set with the following environment variable: " + incidentErrorBurstEnvKey
+	incidentErrorBurstEnvKey = "HUB_ROUTER_INCIDENT_ERROR_BURST_THRESHOLD"
+)
+
+func createIncidentFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(incidentRetentionFlagName, "", "", incidentRetentionFlagUsage)
+	startCmd.Flags().StringP(incidentErrorBurstFlagName, "", "", incidentErrorBurstFlagUsage)
+}
+
+// getIncidentConfig returns the config of the incident timeline, the defaults apply to the flags not set.
+func getIncidentConfig(cmd *cobra.Command) (*incident.Config, error) {
+	retention, err := getThreshold(cmd, incidentRetentionFlagName, incidentRetentionEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &incident.Config{Retention: retention}
+
+	threshold := cmdutils.GetUserSetOptionalVarFromString(cmd, incidentErrorBurstFlagName, incidentErrorBurstEnvKey)
+	if threshold == "" {
+		return config, nil
+	}
+
+	config.BurstThreshold, err = strconv.Atoi(threshold)
+	if err != nil || config.BurstThreshold <= 0 {
+		return nil, fmt.Errorf("invalid %s : %s", incidentErrorBurstFlagName, threshold)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,57 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/incident"
+)
+
+func TestGetIncidentConfig(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createIncidentFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		config, err := getIncidentConfig(newCmd())
+		require.NoError(t, err)
+		require.Equal(t, &incident.Config{}, config)
+	})
+
+	t.Run("retention and error burst threshold", func(t *testing.T) {
+		config, err := getIncidentConfig(newCmd(
+			"--"+incidentRetentionFlagName, "720h",
+			"--"+incidentErrorBurstFlagName, "50",
+		))
+		require.NoError(t, err)
+		require.Equal(t, &incident.Config{Retention: 720 * time.Hour, BurstThreshold: 50}, config)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for _, tc := range []struct {
+			args []string
+			err  string
+		}{
+			{[]string{"--" + incidentRetentionFlagName, "90 days"}, "invalid " + incidentRetentionFlagName},
+			{[]string{"--" + incidentErrorBurstFlagName, "many"}, "invalid " + incidentErrorBurstFlagName},
+			{[]string{"--" + incidentErrorBurstFlagName, "0"}, "invalid " + incidentErrorBurstFlagName},
+		} {
+			_, err := getIncidentConfig(newCmd(tc.args...))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		}
+	})
+}
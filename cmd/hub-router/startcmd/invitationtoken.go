@@ -0,0 +1,106 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/poptoken"
+)
+
+// Invitation token config.
+const (
+	invitationTokenSecretFlagName  = "invitation-token-secret"
+	invitationTokenSecretFlagUsage = "Secret verifying the HS256 tokens required to create the invitations : the" +
+		" wallet backend issues the tokens to its authenticated app users, and the connections are bound to the" +
+		" subject of the tokens. Mutually exclusive with the public key." +
+		" Alternatively, this can be set with the following environment variable: " + invitationTokenSecretEnvKey
+	invitationTokenSecretEnvKey = "HUB_ROUTER_INVITATION_TOKEN_SECRET"
+
+	invitationTokenPublicKeyFlagName  = "invitation-token-public-key"
+	invitationTokenPublicKeyFlagUsage = "PEM file of the public key (RSA, P-256 or Ed25519) verifying the RS256, ES256" +
+		" or EdDSA tokens required to create the invitations. Mutually exclusive with the secret." +
+		" Alternatively, this can be set with the following environment variable: " + invitationTokenPublicKeyEnvKey
+	invitationTokenPublicKeyEnvKey = "HUB_ROUTER_INVITATION_TOKEN_PUBLIC_KEY"
+
+	invitationTokenIssuerFlagName  = "invitation-token-issuer"
+	invitationTokenIssuerFlagUsage = "Issuer (iss claim) of the invitation tokens, not checked if not set." +
+		" Alternatively, this can be set with the following environment variable: " + invitationTokenIssuerEnvKey
+	invitationTokenIssuerEnvKey = "HUB_ROUTER_INVITATION_TOKEN_ISSUER"
+
+	invitationTokenAudienceFlagName  = "invitation-token-audience"
+	invitationTokenAudienceFlagUsage = "Audience (aud claim) of the invitation tokens, not checked if not set." +
+		" Alternatively, this can be set with the following environment variable: " + invitationTokenAudienceEnvKey
+	invitationTokenAudienceEnvKey = "HUB_ROUTER_INVITATION_TOKEN_AUDIENCE"
+
+	invitationTokenMaxTTLFlagName  = "invitation-token-max-ttl"
+	invitationTokenMaxTTLFlagUsage = "Maximum lifetime of the invitation tokens, from their verification to their" +
+		" expiry. Defaults to 5m." +
+		" Alternatively, this can be set with the following environment variable: " + invitationTokenMaxTTLEnvKey
+	invitationTokenMaxTTLEnvKey = "HUB_ROUTER_INVITATION_TOKEN_MAX_TTL"
+)
+
+func createInvitationTokenFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(invitationTokenSecretFlagName, "", "", invitationTokenSecretFlagUsage)
+	startCmd.Flags().StringP(invitationTokenPublicKeyFlagName, "", "", invitationTokenPublicKeyFlagUsage)
+	startCmd.Flags().StringP(invitationTokenIssuerFlagName, "", "", invitationTokenIssuerFlagUsage)
+	startCmd.Flags().StringP(invitationTokenAudienceFlagName, "", "", invitationTokenAudienceFlagUsage)
+	startCmd.Flags().StringP(invitationTokenMaxTTLFlagName, "", "", invitationTokenMaxTTLFlagUsage)
+}
+
+// getInvitationTokenConfig returns the invitation token config, nil if the invitations don't require a token.
+func getInvitationTokenConfig(cmd *cobra.Command) (*poptoken.Config, error) {
+	secret := cmdutils.GetUserSetOptionalVarFromString(cmd, invitationTokenSecretFlagName, invitationTokenSecretEnvKey)
+	keyFile := cmdutils.GetUserSetOptionalVarFromString(cmd, invitationTokenPublicKeyFlagName,
+		invitationTokenPublicKeyEnvKey)
+
+	if secret == "" && keyFile == "" {
+		return nil, nil
+	}
+
+	if secret != "" && keyFile != "" {
+		return nil, fmt.Errorf("%s and %s are mutually exclusive", invitationTokenSecretFlagName,
+			invitationTokenPublicKeyFlagName)
+	}
+
+	config := &poptoken.Config{
+		Secret: []byte(secret),
+		Issuer: cmdutils.GetUserSetOptionalVarFromString(cmd, invitationTokenIssuerFlagName,
+			invitationTokenIssuerEnvKey),
+		Audience: cmdutils.GetUserSetOptionalVarFromString(cmd, invitationTokenAudienceFlagName,
+			invitationTokenAudienceEnvKey),
+	}
+
+	if keyFile != "" {
+		pemBytes, err := ioutil.ReadFile(keyFile) // nolint:gosec // file path is set by the operator
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s : %w", invitationTokenPublicKeyFlagName, err)
+		}
+
+		config.PublicKey, err = poptoken.ParsePublicKey(pemBytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s : %w", invitationTokenPublicKeyFlagName, err)
+		}
+	}
+
+	var err error
+
+	config.MaxTTL, err = getThreshold(cmd, invitationTokenMaxTTLFlagName, invitationTokenMaxTTLEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.MaxTTL < 0 {
+		return nil, fmt.Errorf("invalid %s : %s", invitationTokenMaxTTLFlagName, config.MaxTTL)
+	}
+
+	return config, nil
+}
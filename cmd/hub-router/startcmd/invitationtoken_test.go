@@ -0,0 +1,96 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/poptoken"
+)
+
+func TestGetInvitationTokenConfig(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createInvitationTokenFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	keyFile := filepath.Join(t.TempDir(), "key.pem")
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600))
+
+	invalidKeyFile := filepath.Join(t.TempDir(), "invalid.pem")
+	require.NoError(t, ioutil.WriteFile(invalidKeyFile, []byte("invalid"), 0600))
+
+	t.Run("disabled", func(t *testing.T) {
+		config, err := getInvitationTokenConfig(newCmd())
+		require.NoError(t, err)
+		require.False(t, config.Enabled())
+	})
+
+	t.Run("secret", func(t *testing.T) {
+		config, err := getInvitationTokenConfig(newCmd(
+			"--"+invitationTokenSecretFlagName, "secret",
+			"--"+invitationTokenIssuerFlagName, "wallet-backend",
+			"--"+invitationTokenAudienceFlagName, "hub-router",
+			"--"+invitationTokenMaxTTLFlagName, "1m",
+		))
+		require.NoError(t, err)
+		require.Equal(t, &poptoken.Config{
+			Secret: []byte("secret"), Issuer: "wallet-backend", Audience: "hub-router", MaxTTL: time.Minute,
+		}, config)
+	})
+
+	t.Run("public key", func(t *testing.T) {
+		config, err := getInvitationTokenConfig(newCmd("--"+invitationTokenPublicKeyFlagName, keyFile))
+		require.NoError(t, err)
+		require.Equal(t, pub, config.PublicKey)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for _, tc := range []struct {
+			args []string
+			err  string
+		}{
+			{
+				[]string{"--" + invitationTokenSecretFlagName, "secret", "--" + invitationTokenPublicKeyFlagName, keyFile},
+				"mutually exclusive",
+			},
+			{[]string{"--" + invitationTokenPublicKeyFlagName, "missing.pem"}, "no such file"},
+			{[]string{"--" + invitationTokenPublicKeyFlagName, invalidKeyFile}, "invalid " + invitationTokenPublicKeyFlagName},
+			{
+				[]string{"--" + invitationTokenSecretFlagName, "secret", "--" + invitationTokenMaxTTLFlagName, "5"},
+				"invalid " + invitationTokenMaxTTLFlagName,
+			},
+			{
+				[]string{"--" + invitationTokenSecretFlagName, "secret", "--" + invitationTokenMaxTTLFlagName, "-1m"},
+				"invalid " + invitationTokenMaxTTLFlagName,
+			},
+		} {
+			_, err := getInvitationTokenConfig(newCmd(tc.args...))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		}
+	})
+}
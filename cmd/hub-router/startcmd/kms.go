@@ -0,0 +1,57 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/trustbloc/hub-router/pkg/kmscache"
+)
+
+// KMS cache config.
+const (
+	kmsCacheSizeFlagName  = "kms-cache-size"
+	kmsCacheSizeFlagUsage = "Number of KMS key handles and public keys cached, so that the forwards packed for the" +
+		" same router keys don't each cost a KMS round trip. Disabled if not set." +
+		" Alternatively, this can be set with the following environment variable: " + kmsCacheSizeEnvKey
+	kmsCacheSizeEnvKey = "HUB_ROUTER_KMS_CACHE_SIZE"
+
+	kmsCacheTTLFlagName  = "kms-cache-ttl"
+	kmsCacheTTLFlagUsage = "Time a KMS key handle is cached for, eg: 10m. Defaults to 5m." +
+		" Alternatively, this can be set with the following environment variable: " + kmsCacheTTLEnvKey
+	kmsCacheTTLEnvKey = "HUB_ROUTER_KMS_CACHE_TTL"
+)
+
+func createKMSFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(kmsCacheSizeFlagName, "", "", kmsCacheSizeFlagUsage)
+	startCmd.Flags().StringP(kmsCacheTTLFlagName, "", "", kmsCacheTTLFlagUsage)
+}
+
+// getKMSCacheConfig returns the KMS cache config, the KMS round trips are counted even if the cache is disabled.
+func getKMSCacheConfig(cmd *cobra.Command) (*kmscache.Config, error) {
+	size, err := getWatermark(cmd, kmsCacheSizeFlagName, kmsCacheSizeEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if size < 0 {
+		return nil, fmt.Errorf("invalid %s : %d", kmsCacheSizeFlagName, size)
+	}
+
+	ttl, err := getThreshold(cmd, kmsCacheTTLFlagName, kmsCacheTTLEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl < 0 {
+		return nil, fmt.Errorf("invalid %s : %s", kmsCacheTTLFlagName, ttl)
+	}
+
+	return &kmscache.Config{Size: size, TTL: ttl}, nil
+}
@@ -0,0 +1,53 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/kmscache"
+)
+
+func TestGetKMSCacheConfig(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createKMSFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		config, err := getKMSCacheConfig(newCmd())
+		require.NoError(t, err)
+		require.False(t, config.Enabled())
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		config, err := getKMSCacheConfig(newCmd(
+			"--"+kmsCacheSizeFlagName, "1000",
+			"--"+kmsCacheTTLFlagName, "10m",
+		))
+		require.NoError(t, err)
+		require.Equal(t, &kmscache.Config{Size: 1000, TTL: 10 * time.Minute}, config)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for flag, value := range map[string]string{
+			kmsCacheSizeFlagName: "-1",
+			kmsCacheTTLFlagName:  "-1m",
+		} {
+			_, err := getKMSCacheConfig(newCmd("--"+flag, value))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+	})
+}
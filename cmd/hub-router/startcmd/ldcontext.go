@@ -0,0 +1,85 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/ldcontext"
+)
+
+// JSON-LD context config.
+const (
+	ldContextPolicyFlagName  = "jsonld-context-policy"
+	ldContextPolicyFlagUsage = "Loading of the JSON-LD contexts neither embedded in the router nor bundled :" +
+		" allow (fetched from their URL and cached), cache-only (loaded from the cache only) or deny." +
+		" Defaults to cache-only." +
+		" Alternatively, this can be set with the following environment variable: " + ldContextPolicyEnvKey
+	ldContextPolicyEnvKey = "HUB_ROUTER_JSONLD_CONTEXT_POLICY"
+
+	ldContextBundleFlagName  = "jsonld-context-bundle"
+	ldContextBundleFlagUsage = "Path of the offline bundle of JSON-LD contexts : a JSON array of the contexts, each" +
+		" with its url, documentURL and content, loaded in addition to the embedded contexts." +
+		" Alternatively, this can be set with the following environment variable: " + ldContextBundleEnvKey
+	ldContextBundleEnvKey = "HUB_ROUTER_JSONLD_CONTEXT_BUNDLE"
+)
+
+func createLDContextFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(ldContextPolicyFlagName, "", "", ldContextPolicyFlagUsage)
+	startCmd.Flags().StringP(ldContextBundleFlagName, "", "", ldContextBundleFlagUsage)
+}
+
+// getLDContextConfig returns the JSON-LD context loading config, with the offline bundle read.
+func getLDContextConfig(cmd *cobra.Command) (*ldcontext.Config, error) {
+	policy := cmdutils.GetUserSetOptionalVarFromString(cmd, ldContextPolicyFlagName, ldContextPolicyEnvKey)
+	if policy != "" && !ldcontext.ValidPolicy(policy) {
+		return nil, fmt.Errorf("invalid %s : %s", ldContextPolicyFlagName, policy)
+	}
+
+	cfg := &ldcontext.Config{Policy: policy}
+
+	bundle := cmdutils.GetUserSetOptionalVarFromString(cmd, ldContextBundleFlagName, ldContextBundleEnvKey)
+	if bundle == "" {
+		return cfg, nil
+	}
+
+	var err error
+
+	cfg.Bundle, err = ldcontext.ReadBundle(bundle)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s : %w", ldContextBundleFlagName, err)
+	}
+
+	return cfg, nil
+}
+
+// newContextLoader returns the JSON-LD context loader of the agent, fetching the remote contexts through the
+// outbound proxy if the policy allows it.
+func newContextLoader(store storage.Provider, params *hubRouterParameters,
+	tlsConfig *tls.Config) (*ldcontext.Loader, error) {
+	cfg := ldcontext.Config{}
+	if params.ldContext != nil {
+		cfg = *params.ldContext
+	}
+
+	cfg.Client = &http.Client{Transport: &http.Transport{
+		TLSClientConfig: tlsConfig, Proxy: params.proxyConfig.Proxy,
+	}}
+
+	loader, err := ldcontext.New(store, &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("init JSON-LD context loader: %w", err)
+	}
+
+	return loader, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/ldcontext"
+	"github.com/trustbloc/hub-router/pkg/proxy"
+)
+
+func TestGetLDContextConfig(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createLDContextFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		config, err := getLDContextConfig(newCmd())
+		require.NoError(t, err)
+		require.Equal(t, &ldcontext.Config{}, config)
+	})
+
+	t.Run("policy and bundle", func(t *testing.T) {
+		bundle := filepath.Join(t.TempDir(), "contexts.json")
+		require.NoError(t, ioutil.WriteFile(bundle,
+			[]byte(`[{"url":"https://example.com/v1","content":{"@context":{"id":"@id"}}}]`), 0o600))
+
+		config, err := getLDContextConfig(newCmd(
+			"--"+ldContextPolicyFlagName, ldcontext.PolicyDeny,
+			"--"+ldContextBundleFlagName, bundle,
+		))
+		require.NoError(t, err)
+		require.Equal(t, ldcontext.PolicyDeny, config.Policy)
+		require.Len(t, config.Bundle, 1)
+		require.Equal(t, "https://example.com/v1", config.Bundle[0].DocumentURL)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for flag, value := range map[string]string{
+			ldContextPolicyFlagName: "sometimes",
+			ldContextBundleFlagName: filepath.Join(t.TempDir(), "missing.json"),
+		} {
+			_, err := getLDContextConfig(newCmd("--"+flag, value))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+	})
+}
+
+func TestNewContextLoader(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		loader, err := newContextLoader(mem.NewProvider(), &hubRouterParameters{proxyConfig: &proxy.Config{}}, nil)
+		require.NoError(t, err)
+
+		_, err = loader.LoadDocument("https://www.w3.org/ns/did/v1")
+		require.NoError(t, err)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		p := mockstore.NewMockStoreProvider()
+		p.ErrOpenStoreHandle = errors.New("open error")
+
+		_, err := newContextLoader(p, &hubRouterParameters{
+			proxyConfig: &proxy.Config{}, ldContext: &ldcontext.Config{Policy: ldcontext.PolicyAllow},
+		}, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "init JSON-LD context loader")
+	})
+}
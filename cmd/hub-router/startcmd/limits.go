@@ -0,0 +1,155 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/limits"
+)
+
+// Runtime limits config.
+const (
+	maxProcsFlagName  = "max-procs"
+	maxProcsFlagUsage = "Number of CPUs executing the router simultaneously (GOMAXPROCS), or auto for the CPU quota" +
+		" of the container. Defaults to the number of CPUs of the host, or the GOMAXPROCS environment variable." +
+		" Alternatively, this can be set with the following environment variable: " + maxProcsEnvKey
+	maxProcsEnvKey = "HUB_ROUTER_MAX_PROCS"
+
+	memoryLimitFlagName  = "memory-limit"
+	memoryLimitFlagUsage = "Soft limit of the heap, eg: 512MiB or 2GB, or auto for 90% of the memory limit of the" +
+		" container : the garbage collections get more frequent as the heap grows towards it. Unlimited if not set." +
+		" Alternatively, this can be set with the following environment variable: " + memoryLimitEnvKey
+	memoryLimitEnvKey = "HUB_ROUTER_MEMORY_LIMIT"
+
+	maxHandshakesFlagName  = "max-handshakes"
+	maxHandshakesFlagUsage = "Number of DID exchanges in progress from which the new ones are queued, or rejected if" +
+		" they aren't queued. Unlimited if not set." +
+		" Alternatively, this can be set with the following environment variable: " + maxHandshakesEnvKey
+	maxHandshakesEnvKey = "HUB_ROUTER_MAX_HANDSHAKES"
+
+	maxHandshakesPerSourceFlagName  = "max-handshakes-per-source"
+	maxHandshakesPerSourceFlagUsage = "Number of DID exchanges in progress responding to the same invitation from which" +
+		" the new ones are queued, or rejected if they aren't queued. Unlimited if not set." +
+		" Alternatively, this can be set with the following environment variable: " + maxHandshakesPerSourceEnvKey
+	maxHandshakesPerSourceEnvKey = "HUB_ROUTER_MAX_HANDSHAKES_PER_SOURCE"
+
+	handshakeQueueFlagName  = "handshake-queue"
+	handshakeQueueFlagUsage = "Number of DID exchanges over the handshake limits waiting for a slot, from which the" +
+		" new ones are rejected. Not queued if not set." +
+		" Alternatively, this can be set with the following environment variable: " + handshakeQueueEnvKey
+	handshakeQueueEnvKey = "HUB_ROUTER_HANDSHAKE_QUEUE"
+
+	handshakeQueueTimeoutFlagName  = "handshake-queue-timeout"
+	handshakeQueueTimeoutFlagUsage = "Time a queued DID exchange waits for a slot before being rejected. Defaults to" +
+		" 10s. Alternatively, this can be set with the following environment variable: " + handshakeQueueTimeoutEnvKey
+	handshakeQueueTimeoutEnvKey = "HUB_ROUTER_HANDSHAKE_QUEUE_TIMEOUT"
+
+	handshakeTimeoutFlagName  = "handshake-timeout"
+	handshakeTimeoutFlagUsage = "Time after which a DID exchange not completed no longer counts towards the handshake" +
+		" limits. Defaults to 2m." +
+		" Alternatively, this can be set with the following environment variable: " + handshakeTimeoutEnvKey
+	handshakeTimeoutEnvKey = "HUB_ROUTER_HANDSHAKE_TIMEOUT"
+
+	maxPickupsFlagName  = "max-pickups"
+	maxPickupsFlagUsage = "Number of message pickup requests handled concurrently from which the new ones are" +
+		" rejected. Unlimited if not set. Alternatively, this can be set with the following environment variable: " +
+		maxPickupsEnvKey
+	maxPickupsEnvKey = "HUB_ROUTER_MAX_PICKUPS"
+)
+
+func createLimitsFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(maxProcsFlagName, "", "", maxProcsFlagUsage)
+	startCmd.Flags().StringP(memoryLimitFlagName, "", "", memoryLimitFlagUsage)
+	startCmd.Flags().StringP(maxHandshakesFlagName, "", "", maxHandshakesFlagUsage)
+	startCmd.Flags().StringP(maxHandshakesPerSourceFlagName, "", "", maxHandshakesPerSourceFlagUsage)
+	startCmd.Flags().StringP(handshakeQueueFlagName, "", "", handshakeQueueFlagUsage)
+	startCmd.Flags().StringP(handshakeQueueTimeoutFlagName, "", "", handshakeQueueTimeoutFlagUsage)
+	startCmd.Flags().StringP(handshakeTimeoutFlagName, "", "", handshakeTimeoutFlagUsage)
+	startCmd.Flags().StringP(maxPickupsFlagName, "", "", maxPickupsFlagUsage)
+}
+
+// getLimitsConfig returns the runtime limits, nil if the router isn't limited.
+func getLimitsConfig(cmd *cobra.Command) (*limits.Config, error) {
+	config := &limits.Config{}
+
+	var err error
+
+	if v := cmdutils.GetUserSetOptionalVarFromString(cmd, maxProcsFlagName, maxProcsEnvKey); v != "" {
+		config.MaxProcs, err = limits.ParseMaxProcs(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s : %w", maxProcsFlagName, err)
+		}
+	}
+
+	if v := cmdutils.GetUserSetOptionalVarFromString(cmd, memoryLimitFlagName, memoryLimitEnvKey); v != "" {
+		config.MemoryLimit, err = limits.ParseMemoryLimit(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s : %w", memoryLimitFlagName, err)
+		}
+	}
+
+	err = getHandshakeLimits(cmd, config)
+	if err != nil {
+		return nil, err
+	}
+
+	config.MaxPickups, err = getLimit(cmd, maxPickupsFlagName, maxPickupsEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if *config == (limits.Config{}) {
+		return nil, nil
+	}
+
+	return config, nil
+}
+
+func getHandshakeLimits(cmd *cobra.Command, config *limits.Config) error {
+	var err error
+
+	config.MaxHandshakes, err = getLimit(cmd, maxHandshakesFlagName, maxHandshakesEnvKey)
+	if err != nil {
+		return err
+	}
+
+	config.MaxHandshakesPerSource, err = getLimit(cmd, maxHandshakesPerSourceFlagName, maxHandshakesPerSourceEnvKey)
+	if err != nil {
+		return err
+	}
+
+	config.HandshakeQueue, err = getLimit(cmd, handshakeQueueFlagName, handshakeQueueEnvKey)
+	if err != nil {
+		return err
+	}
+
+	config.HandshakeQueueTimeout, err = getThreshold(cmd, handshakeQueueTimeoutFlagName, handshakeQueueTimeoutEnvKey)
+	if err != nil {
+		return err
+	}
+
+	config.HandshakeTimeout, err = getThreshold(cmd, handshakeTimeoutFlagName, handshakeTimeoutEnvKey)
+
+	return err
+}
+
+func getLimit(cmd *cobra.Command, flagName, envKey string) (int, error) {
+	limit, err := getWatermark(cmd, flagName, envKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if limit < 0 {
+		return 0, fmt.Errorf("invalid %s : %d", flagName, limit)
+	}
+
+	return limit, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/limits"
+)
+
+func TestGetLimitsConfig(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createLimitsFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		config, err := getLimitsConfig(newCmd())
+		require.NoError(t, err)
+		require.Nil(t, config)
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		config, err := getLimitsConfig(newCmd(
+			"--"+maxProcsFlagName, "2",
+			"--"+memoryLimitFlagName, "512MiB",
+			"--"+maxHandshakesFlagName, "100",
+			"--"+maxPickupsFlagName, "200",
+		))
+		require.NoError(t, err)
+		require.Equal(t, &limits.Config{
+			MaxProcs: 2, MemoryLimit: 512 << 20, MaxHandshakes: 100, MaxPickups: 200,
+		}, config)
+	})
+
+	t.Run("handshake limits", func(t *testing.T) {
+		config, err := getLimitsConfig(newCmd(
+			"--"+maxHandshakesPerSourceFlagName, "10",
+			"--"+handshakeQueueFlagName, "500",
+			"--"+handshakeQueueTimeoutFlagName, "5s",
+			"--"+handshakeTimeoutFlagName, "1m",
+		))
+		require.NoError(t, err)
+		require.Equal(t, &limits.Config{
+			MaxHandshakesPerSource: 10, HandshakeQueue: 500, HandshakeQueueTimeout: 5 * time.Second,
+			HandshakeTimeout: time.Minute,
+		}, config)
+	})
+
+	t.Run("auto", func(t *testing.T) {
+		_, err := getLimitsConfig(newCmd(
+			"--"+maxProcsFlagName, "auto",
+			"--"+memoryLimitFlagName, "auto",
+		))
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for flag, value := range map[string]string{
+			maxProcsFlagName:               "-1",
+			memoryLimitFlagName:            "lots",
+			maxHandshakesFlagName:          "-1",
+			maxHandshakesPerSourceFlagName: "-1",
+			handshakeQueueFlagName:         "many",
+			handshakeQueueTimeoutFlagName:  "5",
+			handshakeTimeoutFlagName:       "1",
+			maxPickupsFlagName:             "many",
+		} {
+			_, err := getLimitsConfig(newCmd("--"+flag, value))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+	})
+}
@@ -0,0 +1,80 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+
+	arieslog "github.com/hyperledger/aries-framework-go/pkg/common/log"
+	"github.com/spf13/cobra"
+	"github.com/trustbloc/edge-core/pkg/log"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+)
+
+// "Other" bucket.
+const (
+	logLevelFlagName  = "log-level"
+	logLevelFlagUsage = "Sets the logging level." +
+		" Possible values are [DEBUG, INFO, WARNING, ERROR, CRITICAL] (default is INFO)." +
+		" Alternatively, this can be set with the following environment variable: " + logLevelEnvKey
+	logLevelEnvKey = "HUB_ROUTER_LOGLEVEL"
+)
+
+func createLogLevelFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(logLevelFlagName, "", "INFO", logLevelFlagUsage)
+}
+
+func initLogLevel(cmd *cobra.Command) error {
+	logLevel, err := cmdutils.GetUserSetVarFromString(cmd, logLevelFlagName, logLevelEnvKey, true)
+	if err != nil {
+		return err
+	}
+
+	if logLevel == "" {
+		logLevel = "INFO"
+	}
+
+	err = setLogLevel(logLevel)
+	if err != nil {
+		return err
+	}
+
+	logger.Infof("logger level set to %s", logLevel)
+
+	return nil
+}
+
+func setLogLevel(logLevel string) error {
+	err := setEdgeCoreLogLevel(logLevel)
+	if err != nil {
+		return err
+	}
+
+	return setAriesFrameworkLogLevel(logLevel)
+}
+
+func setEdgeCoreLogLevel(logLevel string) error {
+	level, err := log.ParseLevel(logLevel)
+	if err != nil {
+		return fmt.Errorf("failed to parse log level '%s' : %w", logLevel, err)
+	}
+
+	log.SetLevel("", level)
+
+	return nil
+}
+
+func setAriesFrameworkLogLevel(logLevel string) error {
+	level, err := arieslog.ParseLevel(logLevel)
+	if err != nil {
+		return fmt.Errorf("failed to parse log level '%s' : %w", logLevel, err)
+	}
+
+	arieslog.SetLevel("", level)
+
+	return nil
+}
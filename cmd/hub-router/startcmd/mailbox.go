@@ -0,0 +1,74 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/trustbloc/hub-router/pkg/mailbox"
+)
+
+// Mailbox limits config.
+const (
+	queueMaxDepthFlagName  = "queue-max-depth"
+	queueMaxDepthFlagUsage = "Number of messages queued for a single wallet from which the new messages are rejected" +
+		" by the queue store, whichever transport they came through. Unlimited if not set." +
+		" Alternatively, this can be set with the following environment variable: " + queueMaxDepthEnvKey
+	queueMaxDepthEnvKey = "HUB_ROUTER_QUEUE_MAX_DEPTH"
+
+	queueMaxMessageSizeFlagName  = "queue-max-message-size"
+	queueMaxMessageSizeFlagUsage = "Size in bytes of the largest message queued for a wallet, the larger messages" +
+		" being rejected. Unlimited if not set. Alternatively, this can be set with the following environment" +
+		" variable: " + queueMaxMessageSizeEnvKey
+	queueMaxMessageSizeEnvKey = "HUB_ROUTER_QUEUE_MAX_MESSAGE_SIZE"
+
+	queueMessageTTLFlagName  = "queue-message-ttl"
+	queueMessageTTLFlagUsage = "Time a message stays queued for a wallet, if not picked up, before it is dropped," +
+		" eg: 168h. The messages never expire if not set." +
+		" Alternatively, this can be set with the following environment variable: " + queueMessageTTLEnvKey
+	queueMessageTTLEnvKey = "HUB_ROUTER_QUEUE_MESSAGE_TTL"
+)
+
+func createMailboxFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(queueMaxDepthFlagName, "", "", queueMaxDepthFlagUsage)
+	startCmd.Flags().StringP(queueMaxMessageSizeFlagName, "", "", queueMaxMessageSizeFlagUsage)
+	startCmd.Flags().StringP(queueMessageTTLFlagName, "", "", queueMessageTTLFlagUsage)
+}
+
+// getMailboxConfig returns the limits of the pickup mailboxes, unlimited if not set.
+func getMailboxConfig(cmd *cobra.Command) (*mailbox.Config, error) {
+	maxDepth, err := getWatermark(cmd, queueMaxDepthFlagName, queueMaxDepthEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxDepth < 0 {
+		return nil, fmt.Errorf("invalid %s : %d", queueMaxDepthFlagName, maxDepth)
+	}
+
+	maxMessageSize, err := getWatermark(cmd, queueMaxMessageSizeFlagName, queueMaxMessageSizeEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxMessageSize < 0 {
+		return nil, fmt.Errorf("invalid %s : %d", queueMaxMessageSizeFlagName, maxMessageSize)
+	}
+
+	ttl, err := getThreshold(cmd, queueMessageTTLFlagName, queueMessageTTLEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl < 0 {
+		return nil, fmt.Errorf("invalid %s : %s", queueMessageTTLFlagName, ttl)
+	}
+
+	return &mailbox.Config{MaxDepth: maxDepth, MaxMessageSize: maxMessageSize, TTL: ttl}, nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/mailbox"
+)
+
+func TestGetMailboxConfig(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createMailboxFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("unlimited", func(t *testing.T) {
+		config, err := getMailboxConfig(newCmd())
+		require.NoError(t, err)
+		require.False(t, config.Enabled())
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		config, err := getMailboxConfig(newCmd(
+			"--"+queueMaxDepthFlagName, "1000",
+			"--"+queueMaxMessageSizeFlagName, "65536",
+			"--"+queueMessageTTLFlagName, "168h",
+		))
+		require.NoError(t, err)
+		require.Equal(t, &mailbox.Config{MaxDepth: 1000, MaxMessageSize: 65536, TTL: 168 * time.Hour}, config)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for flag, value := range map[string]string{
+			queueMaxDepthFlagName:       "-1",
+			queueMaxMessageSizeFlagName: "-1",
+			queueMessageTTLFlagName:     "-1s",
+		} {
+			_, err := getMailboxConfig(newCmd("--"+flag, value))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+
+		for _, flag := range []string{queueMaxDepthFlagName, queueMaxMessageSizeFlagName, queueMessageTTLFlagName} {
+			_, err := getMailboxConfig(newCmd("--"+flag, "many"))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/cloudevents"
+	"github.com/trustbloc/hub-router/pkg/metering"
+)
+
+// Metering config.
+const (
+	meteringFlagName  = "metering"
+	meteringFlagUsage = "Close the hourly metering periods, recording the usage of each tenant in immutable records" +
+		" (GET /metering/records). Possible values [true] [false]. Defaults to false if not set." +
+		" Alternatively, this can be set with the following environment variable: " + meteringEnvKey
+	meteringEnvKey = "HUB_ROUTER_METERING"
+
+	meteringKafkaURLFlagName  = "metering-kafka-url"
+	meteringKafkaURLFlagUsage = "URL of the Kafka REST proxy the metering records are published to, once their period" +
+		" is closed. Requires the metering to be enabled." +
+		" Alternatively, this can be set with the following environment variable: " + meteringKafkaURLEnvKey
+	meteringKafkaURLEnvKey = "HUB_ROUTER_METERING_KAFKA_URL"
+
+	meteringKafkaTopicFlagName  = "metering-kafka-topic"
+	meteringKafkaTopicFlagUsage = "Kafka topic the metering records are published to. Defaults to " +
+		defaultMeteringKafkaTopic + " if not set." +
+		" Alternatively, this can be set with the following environment variable: " + meteringKafkaTopicEnvKey
+	meteringKafkaTopicEnvKey  = "HUB_ROUTER_METERING_KAFKA_TOPIC"
+	defaultMeteringKafkaTopic = "hub-router-metering"
+)
+
+// CloudEvents config.
+const (
+	cloudEventsModeFlagName  = "cloudevents-mode"
+	cloudEventsModeFlagUsage = "Send the webhook notifications and publish the metering records to Kafka as" +
+		" CloudEvents 1.0 events, in the structured or binary content mode. The Kafka binary mode requires the REST" +
+		" proxy v3 API (" + meteringKafkaURLFlagName + " set to the v3 cluster URL, eg:" +
+		" http://kafka-rest:8082/v3/clusters/{cluster_id})." +
+		" Possible values [structured] [binary]. Disabled if not set." +
+		" Alternatively, this can be set with the following environment variable: " + cloudEventsModeEnvKey
+	cloudEventsModeEnvKey = "HUB_ROUTER_CLOUDEVENTS_MODE"
+
+	cloudEventsSourceFlagName  = "cloudevents-source"
+	cloudEventsSourceFlagUsage = "Source of the CloudEvents events. Defaults to " + defaultCloudEventsSource +
+		" if not set." +
+		" Alternatively, this can be set with the following environment variable: " + cloudEventsSourceEnvKey
+	cloudEventsSourceEnvKey  = "HUB_ROUTER_CLOUDEVENTS_SOURCE"
+	defaultCloudEventsSource = "/hub-router"
+
+	kafkaV3URLPath = "/v3/clusters/"
+)
+
+type meteringParameters struct {
+	enabled    bool
+	kafkaURL   string
+	kafkaTopic string
+}
+
+type cloudEventsParameters struct {
+	mode   string
+	source string
+}
+
+func createMeteringFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(meteringFlagName, "", "", meteringFlagUsage)
+	startCmd.Flags().StringP(meteringKafkaURLFlagName, "", "", meteringKafkaURLFlagUsage)
+	startCmd.Flags().StringP(meteringKafkaTopicFlagName, "", "", meteringKafkaTopicFlagUsage)
+}
+
+func getMeteringParams(cmd *cobra.Command) (*meteringParameters, error) {
+	enabled, err := getBool(cmd, meteringFlagName, meteringEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	kafkaURL, err := cmdutils.GetUserSetVarFromString(cmd, meteringKafkaURLFlagName, meteringKafkaURLEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if kafkaURL != "" && !enabled {
+		return nil, fmt.Errorf("%s requires %s", meteringKafkaURLFlagName, meteringFlagName)
+	}
+
+	kafkaTopic, err := cmdutils.GetUserSetVarFromString(cmd, meteringKafkaTopicFlagName, meteringKafkaTopicEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if kafkaTopic == "" {
+		kafkaTopic = defaultMeteringKafkaTopic
+	}
+
+	return &meteringParameters{enabled: enabled, kafkaURL: kafkaURL, kafkaTopic: kafkaTopic}, nil
+}
+
+func getCloudEventsParams(cmd *cobra.Command, metering *meteringParameters) (*cloudEventsParameters, error) {
+	params := &cloudEventsParameters{
+		mode:   cmdutils.GetUserSetOptionalVarFromString(cmd, cloudEventsModeFlagName, cloudEventsModeEnvKey),
+		source: cmdutils.GetUserSetOptionalVarFromString(cmd, cloudEventsSourceFlagName, cloudEventsSourceEnvKey),
+	}
+
+	if params.mode != "" && !cloudevents.ValidMode(params.mode) {
+		return nil, fmt.Errorf("invalid %s : %s", cloudEventsModeFlagName, params.mode)
+	}
+
+	if params.mode == cloudevents.ModeBinary && metering.kafkaURL != "" &&
+		!strings.Contains(metering.kafkaURL, kafkaV3URLPath) {
+		return nil, fmt.Errorf("%s %s requires the Kafka REST proxy v3 cluster URL in %s", cloudEventsModeFlagName,
+			cloudevents.ModeBinary, meteringKafkaURLFlagName)
+	}
+
+	if params.source == "" {
+		params.source = defaultCloudEventsSource
+	}
+
+	return params, nil
+}
+
+func newMeteringSink(params *meteringParameters, ce *cloudEventsParameters, tlsConfig *tls.Config) metering.Sink {
+	if params.kafkaURL == "" {
+		return nil
+	}
+
+	var opts []metering.KafkaOption
+
+	if ce != nil && ce.mode != "" {
+		opts = append(opts, metering.WithKafkaCloudEvents(ce.mode, ce.source))
+	}
+
+	return metering.NewKafkaSink(params.kafkaURL, params.kafkaTopic,
+		&http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}, opts...)
+}
@@ -0,0 +1,98 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMeteringParams(t *testing.T) {
+	t.Run("with metering", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + meteringFlagName, "true",
+			"--" + meteringKafkaURLFlagName, "http://localhost:8082",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid metering", func(t *testing.T) {
+		for flag, errMsg := range map[string]string{
+			meteringFlagName:         "invalid " + meteringFlagName,
+			meteringKafkaURLFlagName: meteringKafkaURLFlagName + " requires " + meteringFlagName,
+		} {
+			startCmd := GetStartCmd(&mockServer{})
+
+			args := []string{
+				"--" + hostURLFlagName, "localhost:8080",
+				"--" + didCommHTTPHostFlagName, randomURL(t),
+				"--" + didCommWSHostFlagName, randomURL(t),
+				"--" + datasourcePersistentFlagName, "mem://tests",
+				"--" + datasourceTransientFlagName, "mem://tests",
+				"--" + flag, "invalid",
+			}
+			startCmd.SetArgs(args)
+
+			err := startCmd.Execute()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), errMsg)
+		}
+	})
+}
+
+func TestCloudEventsParams(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := GetStartCmd(&mockServer{})
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		params, err := getCloudEventsParams(newCmd(), &meteringParameters{})
+		require.NoError(t, err)
+		require.Empty(t, params.mode)
+		require.Equal(t, defaultCloudEventsSource, params.source)
+		require.Nil(t, newMeteringSink(&meteringParameters{}, params, nil))
+	})
+
+	t.Run("binary mode", func(t *testing.T) {
+		metering := &meteringParameters{kafkaURL: "http://kafka-rest:8082/v3/clusters/cluster-1", kafkaTopic: "topic"}
+
+		params, err := getCloudEventsParams(newCmd("--"+cloudEventsModeFlagName, "binary",
+			"--"+cloudEventsSourceFlagName, "urn:router-1"), metering)
+		require.NoError(t, err)
+		require.Equal(t, "binary", params.mode)
+		require.Equal(t, "urn:router-1", params.source)
+		require.NotNil(t, newMeteringSink(metering, params, nil))
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		_, err := getCloudEventsParams(newCmd("--"+cloudEventsModeFlagName, "batch"), &meteringParameters{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid cloudevents-mode : batch")
+	})
+
+	t.Run("binary mode requires the kafka v3 API", func(t *testing.T) {
+		_, err := getCloudEventsParams(newCmd("--"+cloudEventsModeFlagName, "binary"),
+			&meteringParameters{kafkaURL: "http://kafka-rest:8082"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "requires the Kafka REST proxy v3 cluster URL")
+	})
+}
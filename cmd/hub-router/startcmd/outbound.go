@@ -0,0 +1,74 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/trustbloc/hub-router/pkg/connpool"
+)
+
+// Outbound connection pool config.
+const (
+	outboundMaxIdlePerHostFlagName  = "outbound-max-idle-per-host"
+	outboundMaxIdlePerHostFlagUsage = "Number of idle outbound connections kept open per DIDComm destination" +
+		" (eg: an adapter), reused by the next deliveries. Defaults to 16." +
+		" Alternatively, this can be set with the following environment variable: " + outboundMaxIdlePerHostEnvKey
+	outboundMaxIdlePerHostEnvKey = "HUB_ROUTER_OUTBOUND_MAX_IDLE_PER_HOST"
+
+	outboundMaxIdleFlagName  = "outbound-max-idle"
+	outboundMaxIdleFlagUsage = "Number of idle outbound connections kept open across the DIDComm destinations." +
+		" Defaults to 256. Alternatively, this can be set with the following environment variable: " +
+		outboundMaxIdleEnvKey
+	outboundMaxIdleEnvKey = "HUB_ROUTER_OUTBOUND_MAX_IDLE"
+
+	outboundIdleTimeoutFlagName  = "outbound-idle-timeout"
+	outboundIdleTimeoutFlagUsage = "Time an idle outbound connection is kept open for, eg: 5m. Defaults to 90s." +
+		" Alternatively, this can be set with the following environment variable: " + outboundIdleTimeoutEnvKey
+	outboundIdleTimeoutEnvKey = "HUB_ROUTER_OUTBOUND_IDLE_TIMEOUT"
+)
+
+func createOutboundPoolFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(outboundMaxIdlePerHostFlagName, "", "", outboundMaxIdlePerHostFlagUsage)
+	startCmd.Flags().StringP(outboundMaxIdleFlagName, "", "", outboundMaxIdleFlagUsage)
+	startCmd.Flags().StringP(outboundIdleTimeoutFlagName, "", "", outboundIdleTimeoutFlagUsage)
+}
+
+// getOutboundPoolConfig returns the config of the outbound connection pool, the defaults are applied to the unset
+// values.
+func getOutboundPoolConfig(cmd *cobra.Command) (*connpool.Config, error) {
+	maxIdlePerHost, err := getWatermark(cmd, outboundMaxIdlePerHostFlagName, outboundMaxIdlePerHostEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxIdlePerHost < 0 {
+		return nil, fmt.Errorf("invalid %s : %d", outboundMaxIdlePerHostFlagName, maxIdlePerHost)
+	}
+
+	maxIdle, err := getWatermark(cmd, outboundMaxIdleFlagName, outboundMaxIdleEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxIdle < 0 {
+		return nil, fmt.Errorf("invalid %s : %d", outboundMaxIdleFlagName, maxIdle)
+	}
+
+	idleTimeout, err := getThreshold(cmd, outboundIdleTimeoutFlagName, outboundIdleTimeoutEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if idleTimeout < 0 {
+		return nil, fmt.Errorf("invalid %s : %s", outboundIdleTimeoutFlagName, idleTimeout)
+	}
+
+	return &connpool.Config{MaxIdlePerHost: maxIdlePerHost, MaxIdle: maxIdle, IdleTimeout: idleTimeout}, nil
+}
@@ -0,0 +1,55 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/connpool"
+)
+
+func TestGetOutboundPoolConfig(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createOutboundPoolFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		config, err := getOutboundPoolConfig(newCmd())
+		require.NoError(t, err)
+		require.Equal(t, &connpool.Config{}, config)
+	})
+
+	t.Run("pool", func(t *testing.T) {
+		config, err := getOutboundPoolConfig(newCmd(
+			"--"+outboundMaxIdlePerHostFlagName, "32",
+			"--"+outboundMaxIdleFlagName, "512",
+			"--"+outboundIdleTimeoutFlagName, "5m",
+		))
+		require.NoError(t, err)
+		require.Equal(t, &connpool.Config{MaxIdlePerHost: 32, MaxIdle: 512, IdleTimeout: 5 * time.Minute}, config)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for flag, value := range map[string]string{
+			outboundMaxIdlePerHostFlagName: "-1",
+			outboundMaxIdleFlagName:        "many",
+			outboundIdleTimeoutFlagName:    "-1s",
+		} {
+			_, err := getOutboundPoolConfig(newCmd("--"+flag, value))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+	})
+}
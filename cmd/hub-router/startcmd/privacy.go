@@ -0,0 +1,113 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/privacy"
+	"github.com/trustbloc/hub-router/pkg/proxy"
+)
+
+// Privacy mode config.
+const (
+	privacyPadSizeFlagName  = "privacy-pad-size"
+	privacyPadSizeFlagUsage = "Smallest padding bucket, in bytes: the outbound envelopes are padded to the next power" +
+		" of two multiple of this size, eg: 1024. Disabled if not set." +
+		" Alternatively, this can be set with the following environment variable: " + privacyPadSizeEnvKey
+	privacyPadSizeEnvKey = "HUB_ROUTER_PRIVACY_PAD_SIZE"
+
+	privacyBatchWindowFlagName  = "privacy-batch-window"
+	privacyBatchWindowFlagUsage = "Maximum time an outbound message is held to be released in a randomized batch," +
+		" eg: 2s. Disabled if not set." +
+		" Alternatively, this can be set with the following environment variable: " + privacyBatchWindowEnvKey
+	privacyBatchWindowEnvKey = "HUB_ROUTER_PRIVACY_BATCH_WINDOW"
+)
+
+// Outbound proxy config.
+const (
+	outboundProxyFlagName  = "outbound-proxy"
+	outboundProxyFlagUsage = "SOCKS5 proxy used for the outbound DIDComm deliveries matching no proxy rule," +
+		" eg: socks5://127.0.0.1:9050. Direct connections if not set." +
+		" Alternatively, this can be set with the following environment variable: " + outboundProxyEnvKey
+	outboundProxyEnvKey = "HUB_ROUTER_OUTBOUND_PROXY"
+
+	outboundProxyRuleFlagName  = "outbound-proxy-rule"
+	outboundProxyRuleFlagUsage = "SOCKS5 proxy used for the outbound DIDComm deliveries to the matching hosts, in the" +
+		" host=proxy-url format, eg: *.onion=socks5://127.0.0.1:9050. This flag can be repeated." +
+		" Alternatively, this can be set with the following environment variable (in CSV format): " +
+		outboundProxyRuleEnvKey
+	outboundProxyRuleEnvKey = "HUB_ROUTER_OUTBOUND_PROXY_RULE"
+)
+
+func createPrivacyFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(privacyPadSizeFlagName, "", "", privacyPadSizeFlagUsage)
+	startCmd.Flags().StringP(privacyBatchWindowFlagName, "", "", privacyBatchWindowFlagUsage)
+	startCmd.Flags().StringP(outboundProxyFlagName, "", "", outboundProxyFlagUsage)
+	startCmd.Flags().StringArrayP(outboundProxyRuleFlagName, "", []string{}, outboundProxyRuleFlagUsage)
+
+	createOutboundPoolFlags(startCmd)
+}
+
+// getPrivacyParams sets the privacy mode, outbound proxy and connection pool parameters.
+func getPrivacyParams(cmd *cobra.Command, params *hubRouterParameters) error {
+	padSize, err := getWatermark(cmd, privacyPadSizeFlagName, privacyPadSizeEnvKey)
+	if err != nil {
+		return err
+	}
+
+	batchWindow, err := getThreshold(cmd, privacyBatchWindowFlagName, privacyBatchWindowEnvKey)
+	if err != nil {
+		return err
+	}
+
+	params.privacyConfig = &privacy.Config{PadSize: padSize, BatchWindow: batchWindow}
+
+	params.outboundPool, err = getOutboundPoolConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.proxyConfig, err = getProxyConfig(cmd)
+
+	return err
+}
+
+func getProxyConfig(cmd *cobra.Command) (*proxy.Config, error) {
+	config := &proxy.Config{}
+
+	defaultProxy, err := cmdutils.GetUserSetVarFromString(cmd, outboundProxyFlagName, outboundProxyEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if defaultProxy != "" {
+		config.Default, err = proxy.ParseURL(defaultProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s : %w", outboundProxyFlagName, err)
+		}
+	}
+
+	rules, err := cmdutils.GetUserSetVarFromArrayString(cmd, outboundProxyRuleFlagName, outboundProxyRuleEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range rules {
+		rule, err := proxy.ParseRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s : %w", outboundProxyRuleFlagName, err)
+		}
+
+		config.Rules = append(config.Rules, rule)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,117 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPrivacyParams(t *testing.T) {
+	t.Run("with privacy mode", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + privacyPadSizeFlagName, "1024",
+			"--" + privacyBatchWindowFlagName, "2s",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid privacy mode", func(t *testing.T) {
+		for flag, val := range map[string]string{privacyPadSizeFlagName: "1kb", privacyBatchWindowFlagName: "2"} {
+			startCmd := GetStartCmd(&mockServer{})
+
+			args := []string{
+				"--" + hostURLFlagName, "localhost:8080",
+				"--" + didCommHTTPHostFlagName, randomURL(t),
+				"--" + didCommWSHostFlagName, randomURL(t),
+				"--" + datasourcePersistentFlagName, "mem://tests",
+				"--" + datasourceTransientFlagName, "mem://tests",
+				"--" + flag, val,
+			}
+			startCmd.SetArgs(args)
+
+			err := startCmd.Execute()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+	})
+
+	t.Run("with outbound proxy", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + outboundProxyRuleFlagName, "*.onion=socks5://127.0.0.1:9050",
+			"--" + outboundMaxIdlePerHostFlagName, "32",
+			"--" + outboundIdleTimeoutFlagName, "5m",
+			"--" + kmsCacheSizeFlagName, "1000",
+			"--" + maxPickupsFlagName, "100",
+			"--" + handshakeQueueFlagName, "100",
+			"--" + invitationTokenSecretFlagName, "secret",
+			"--" + termsURLFlagName, "https://example.com/terms",
+			"--" + termsVersionFlagName, "2021-06",
+			"--" + tenantStorageIsolationFlagName, "true",
+			"--" + storageRegionFlagName, "eu=mem://eu",
+			"--" + tenantRegionFlagName, "acme=eu",
+			"--" + haModeFlagName, "active",
+			"--" + haNodeIDFlagName, "node-1",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid outbound proxy", func(t *testing.T) {
+		for flag, val := range map[string]string{
+			outboundProxyFlagName:            "http://127.0.0.1:8080",
+			outboundProxyRuleFlagName:        "socks5://127.0.0.1:9050",
+			outboundMaxIdleFlagName:          "-1",
+			kmsCacheTTLFlagName:              "5",
+			memoryLimitFlagName:              "lots",
+			invitationTokenPublicKeyFlagName: "missing.pem",
+			termsURLFlagName:                 "terms",
+			tenantBrandingFileFlagName:       "missing.json",
+			tenantStorageIsolationFlagName:   "maybe",
+			tenantStorageURLFlagName:         "acme",
+			storageRegionFlagName:            "eu",
+			tenantRegionFlagName:             "acme=eu",
+			haModeFlagName:                   "primary",
+		} {
+			startCmd := GetStartCmd(&mockServer{})
+
+			args := []string{
+				"--" + hostURLFlagName, "localhost:8080",
+				"--" + didCommHTTPHostFlagName, randomURL(t),
+				"--" + didCommWSHostFlagName, randomURL(t),
+				"--" + datasourcePersistentFlagName, "mem://tests",
+				"--" + datasourceTransientFlagName, "mem://tests",
+				"--" + flag, val,
+			}
+			startCmd.SetArgs(args)
+
+			err := startCmd.Execute()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+	})
+}
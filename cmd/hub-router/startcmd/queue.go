@@ -0,0 +1,300 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/chunking"
+	"github.com/trustbloc/hub-router/pkg/compression"
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/dedup"
+	"github.com/trustbloc/hub-router/pkg/mailbox"
+	"github.com/trustbloc/hub-router/pkg/ordering"
+	"github.com/trustbloc/hub-router/pkg/pickup"
+	"github.com/trustbloc/hub-router/pkg/queue"
+	"github.com/trustbloc/hub-router/pkg/restapi/operation"
+	hubrouter "github.com/trustbloc/hub-router/pkg/server"
+)
+
+// Queue config.
+const (
+	queueRecipientWatermarkFlagName  = "queue-recipient-watermark"
+	queueRecipientWatermarkFlagUsage = "Number of messages queued for a single wallet above which an alert is raised" +
+		" (logs and webhooks). Disabled if not set." +
+		" Alternatively, this can be set with the following environment variable: " + queueRecipientWatermarkEnvKey
+	queueRecipientWatermarkEnvKey = "HUB_ROUTER_QUEUE_RECIPIENT_WATERMARK"
+
+	queueGlobalWatermarkFlagName  = "queue-global-watermark"
+	queueGlobalWatermarkFlagUsage = "Total number of queued messages above which an alert is raised" +
+		" (logs and webhooks). Disabled if not set." +
+		" Alternatively, this can be set with the following environment variable: " + queueGlobalWatermarkEnvKey
+	queueGlobalWatermarkEnvKey = "HUB_ROUTER_QUEUE_GLOBAL_WATERMARK"
+
+	queueLoadSheddingFlagName  = "queue-load-shedding"
+	queueLoadSheddingFlagUsage = "Reject new connections while the total number of queued messages is above" +
+		" the global watermark. Possible values [true] [false]. Defaults to false if not set." +
+		" Alternatively, this can be set with the following environment variable: " + queueLoadSheddingEnvKey
+	queueLoadSheddingEnvKey = "HUB_ROUTER_QUEUE_LOAD_SHEDDING"
+
+	queueCompressionFlagName  = "queue-compression"
+	queueCompressionFlagUsage = "Algorithm the queued messages are compressed with before being persisted." +
+		" Possible values [none] [gzip] [zstd]. Defaults to none if not set; the messages queued with another" +
+		" algorithm are still delivered. Alternatively, this can be set with the following environment variable: " +
+		queueCompressionEnvKey
+	queueCompressionEnvKey = "HUB_ROUTER_QUEUE_COMPRESSION"
+
+	queueChunkSizeFlagName  = "queue-chunk-size"
+	queueChunkSizeFlagUsage = "Size in bytes above which the queued messages of a wallet are split into chunks," +
+		" persisted separately and reassembled on pickup, eg: to fit the size limits of the database." +
+		" Disabled if not set. Alternatively, this can be set with the following environment variable: " +
+		queueChunkSizeEnvKey
+	queueChunkSizeEnvKey = "HUB_ROUTER_QUEUE_CHUNK_SIZE"
+
+	queueDedupFlagName  = "queue-dedup"
+	queueDedupFlagUsage = "Store the byte-identical messages queued for the same wallet once, eg: forwarded again by" +
+		" the adapters retrying during an outage. Possible values [true] [false]. Defaults to false if not set." +
+		" Alternatively, this can be set with the following environment variable: " + queueDedupEnvKey
+	queueDedupEnvKey = "HUB_ROUTER_QUEUE_DEDUP"
+
+	queueOrderingFlagName  = "queue-ordering"
+	queueOrderingFlagUsage = "Persist a sequence number with each message queued for a wallet, and deliver the" +
+		" messages of the wallet in sequence on pickup, eg: for the credential protocols breaking when their messages" +
+		" arrive out of order. Possible values [true] [false]. Defaults to false if not set." +
+		" Alternatively, this can be set with the following environment variable: " + queueOrderingEnvKey
+	queueOrderingEnvKey = "HUB_ROUTER_QUEUE_ORDERING"
+
+	deliverySuppressionWindowFlagName  = "delivery-suppression-window"
+	deliverySuppressionWindowFlagUsage = "Time the IDs of the messages forwarded to each wallet are tracked for in the" +
+		" transient storage, to suppress the duplicates forwarded again within the window, eg: by the senders retrying" +
+		" or after a failover. Disabled if not set. Format: Go duration (eg: 10m)." +
+		" Alternatively, this can be set with the following environment variable: " + deliverySuppressionWindowEnvKey
+	deliverySuppressionWindowEnvKey = "HUB_ROUTER_DELIVERY_SUPPRESSION_WINDOW"
+)
+
+func createQueueFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(queueRecipientWatermarkFlagName, "", "", queueRecipientWatermarkFlagUsage)
+	startCmd.Flags().StringP(queueGlobalWatermarkFlagName, "", "", queueGlobalWatermarkFlagUsage)
+	startCmd.Flags().StringP(queueLoadSheddingFlagName, "", "", queueLoadSheddingFlagUsage)
+	startCmd.Flags().StringP(queueCompressionFlagName, "", "", queueCompressionFlagUsage)
+	startCmd.Flags().StringP(queueChunkSizeFlagName, "", "", queueChunkSizeFlagUsage)
+	startCmd.Flags().StringP(queueDedupFlagName, "", "", queueDedupFlagUsage)
+	startCmd.Flags().StringP(queueOrderingFlagName, "", "", queueOrderingFlagUsage)
+	startCmd.Flags().StringP(deliverySuppressionWindowFlagName, "", "", deliverySuppressionWindowFlagUsage)
+
+	createBackpressureFlags(startCmd)
+	createMailboxFlags(startCmd)
+}
+
+// getQueueStoreParams sets the compression, chunking, deduplication, sequencing and limits of the pickup mailboxes.
+func getQueueStoreParams(cmd *cobra.Command, params *hubRouterParameters) error {
+	var err error
+
+	params.queueCodec, err = getQueueCodec(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.queueChunkSize, err = getWatermark(cmd, queueChunkSizeFlagName, queueChunkSizeEnvKey)
+	if err != nil {
+		return err
+	}
+
+	params.queueDedup, err = getBool(cmd, queueDedupFlagName, queueDedupEnvKey)
+	if err != nil {
+		return err
+	}
+
+	params.queueOrdering, err = getBool(cmd, queueOrderingFlagName, queueOrderingEnvKey)
+	if err != nil {
+		return err
+	}
+
+	params.queueLimits, err = getMailboxConfig(cmd)
+
+	return err
+}
+
+func getQueueConfig(cmd *cobra.Command) (*queue.Config, error) {
+	recipientWatermark, err := getWatermark(cmd, queueRecipientWatermarkFlagName, queueRecipientWatermarkEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	globalWatermark, err := getWatermark(cmd, queueGlobalWatermarkFlagName, queueGlobalWatermarkEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &queue.Config{RecipientWatermark: recipientWatermark, GlobalWatermark: globalWatermark}
+
+	loadShedding, err := cmdutils.GetUserSetVarFromString(cmd, queueLoadSheddingFlagName, queueLoadSheddingEnvKey, true)
+	if err != nil || loadShedding == "" {
+		return config, err
+	}
+
+	config.LoadShedding, err = strconv.ParseBool(loadShedding)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s : %w", queueLoadSheddingFlagName, err)
+	}
+
+	return config, nil
+}
+
+func getQueueCodec(cmd *cobra.Command) (compression.Codec, error) {
+	algorithm := cmdutils.GetUserSetOptionalVarFromString(cmd, queueCompressionFlagName, queueCompressionEnvKey)
+
+	codec, err := compression.New(algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s : %w", queueCompressionFlagName, err)
+	}
+
+	return codec, nil
+}
+
+func queueConfig(params *hubRouterParameters, queues *queueProviders) hubrouter.QueueConfig {
+	return hubrouter.QueueConfig{
+		Watermarks:    params.queueConfig,
+		SlowConsumers: params.slowConsumerConfig,
+		Compression:   queues.compression,
+		Dedup:         queues.dedup,
+		Ordering:      queues.ordering,
+		Mailboxes:     queues.mailboxes,
+		Pickup:        queues.pickup,
+	}
+}
+
+// queueProviders wrap the storage provider of the pickup mailboxes, the limits being the outermost.
+type queueProviders struct {
+	pickup      *pickup.Queue
+	legacy      storage.Store
+	ordering    *ordering.Sequencer
+	mailboxes   *mailbox.Provider
+	dedup       *dedup.Provider
+	compression *compression.Provider
+}
+
+// newQueueStore returns the pickup queue, sequencing the messages of each wallet, and the providers bounding,
+// deduplicating, compressing and chunking its mailboxes in the persistent storage of the router. They are always read
+// through the deduplication, compression and chunking, so that the messages queued while they were enabled are
+// delivered after they are disabled; the mailboxes are bounded, then deduplicated, then compressed, then chunked, then
+// routed to the region of their wallet, whose pin is kept in the Aries storage. The mailboxes of the Aries message
+// pickup, queued in the Aries storage before the upgrade, are read through the same wrappers to be migrated.
+func newQueueStore(pins storage.Provider, routerStorage *operation.Storage, params *hubRouterParameters,
+	transports *agentTransports) (*queueProviders, error) {
+	locks := routerStorage.Locks
+
+	store, err := initResidency(pins, routerStorage.Persistent, params.datasourceParams, locks, transports)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &queueProviders{}
+
+	q.compression, q.dedup, err = newMailboxWrappers(store, params)
+	if err != nil {
+		return nil, err
+	}
+
+	q.mailboxes = mailbox.NewProvider(q.dedup, params.queueLimits, messagepickup.Namespace)
+	q.ordering = ordering.New(params.queueOrdering)
+
+	// the dead letters are only written here, they are listed, replayed and swept by the operation
+	deadLetters, err := deadletter.New(routerStorage.Persistent)
+	if err != nil {
+		return nil, fmt.Errorf("init pickup dead letters: %w", err)
+	}
+
+	q.pickup, err = pickup.NewQueue(q.mailboxes, locks, pickup.WithSequencer(q.ordering),
+		pickup.WithDeadLetters(deadLetters))
+	if err != nil {
+		return nil, fmt.Errorf("init pickup queue: %w", err)
+	}
+
+	legacy := pins
+
+	if transports.residency != nil {
+		legacy = transports.residency.NewProvider(pins, messagepickup.Namespace)
+	}
+
+	_, legacyDedup, err := newMailboxWrappers(legacy, params)
+	if err != nil {
+		return nil, err
+	}
+
+	q.legacy, err = legacyDedup.OpenStore(messagepickup.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("open legacy mailbox store: %w", err)
+	}
+
+	return q, nil
+}
+
+// newMailboxWrappers returns the providers deduplicating, compressing and chunking the mailboxes in the given storage.
+func newMailboxWrappers(store storage.Provider, params *hubRouterParameters) (*compression.Provider, *dedup.Provider,
+	error) {
+	c, err := compression.NewProvider(chunking.NewProvider(store, params.queueChunkSize, messagepickup.Namespace),
+		params.queueCodec, messagepickup.Namespace)
+	if err != nil {
+		return nil, nil, fmt.Errorf("init queue compression: %w", err)
+	}
+
+	return c, dedup.NewProvider(c, params.queueDedup, messagepickup.Namespace), nil
+}
+
+// migrateMailboxes moves the messages queued by the Aries message pickup before the upgrade to the pickup queue, for
+// the wallets connected to the router. It runs once, the failures are logged and the wallets that failed are migrated
+// on the next start.
+func migrateMailboxes(framework *aries.Aries, queues *queueProviders) {
+	ctx, err := framework.Context()
+	if err != nil {
+		logger.Errorf("failed to migrate the mailboxes : %s", err)
+
+		return
+	}
+
+	lookup, err := connection.NewLookup(ctx)
+	if err != nil {
+		logger.Errorf("failed to migrate the mailboxes : %s", err)
+
+		return
+	}
+
+	records, err := lookup.QueryConnectionRecords()
+	if err != nil {
+		logger.Errorf("failed to migrate the mailboxes : %s", err)
+
+		return
+	}
+
+	dids := make([]string, 0, len(records))
+	seen := make(map[string]bool, len(records))
+
+	for _, record := range records {
+		if record.TheirDID != "" && !seen[record.TheirDID] {
+			seen[record.TheirDID] = true
+			dids = append(dids, record.TheirDID)
+		}
+	}
+
+	migrated, err := queues.pickup.Migrate(queues.legacy, dids)
+	if err != nil {
+		logger.Errorf("failed to migrate the mailboxes : %s", err)
+	}
+
+	if migrated > 0 {
+		logger.Infof("mailboxes of the aries message pickup migrated : messages=%d", migrated)
+	}
+}
@@ -0,0 +1,161 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/compression"
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/mailbox"
+	"github.com/trustbloc/hub-router/pkg/pickup"
+	"github.com/trustbloc/hub-router/pkg/restapi/operation"
+)
+
+func TestGetQueueConfig(t *testing.T) {
+	t.Run("with queue watermarks", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + queueRecipientWatermarkFlagName, "100",
+			"--" + queueGlobalWatermarkFlagName, "10000",
+			"--" + queueLoadSheddingFlagName, "true",
+			"--" + queueCompressionFlagName, "zstd",
+			"--" + queueChunkSizeFlagName, "65536",
+			"--" + queueDedupFlagName, "true",
+			"--" + queueOrderingFlagName, "true",
+			"--" + deliverySuppressionWindowFlagName, "10m",
+			"--" + queueRecipientCapFlagName, "500",
+			"--" + queueRetryAfterFlagName, "30s",
+			"--" + transientRetryAfterFlagName, "10s",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid queue config", func(t *testing.T) {
+		for flag, val := range map[string]string{
+			queueRecipientWatermarkFlagName:   "invalid",
+			queueGlobalWatermarkFlagName:      "invalid",
+			queueLoadSheddingFlagName:         "invalid",
+			queueCompressionFlagName:          "lz4",
+			queueChunkSizeFlagName:            "64KB",
+			queueDedupFlagName:                "invalid",
+			queueOrderingFlagName:             "invalid",
+			deliverySuppressionWindowFlagName: "soon",
+			queueRecipientCapFlagName:         "invalid",
+			transientRetryAfterFlagName:       "soon",
+		} {
+			startCmd := GetStartCmd(&mockServer{})
+
+			args := []string{
+				"--" + hostURLFlagName, "localhost:8080",
+				"--" + didCommHTTPHostFlagName, randomURL(t),
+				"--" + didCommWSHostFlagName, randomURL(t),
+				"--" + datasourcePersistentFlagName, "mem://tests",
+				"--" + datasourceTransientFlagName, "mem://tests",
+				"--" + flag, val,
+			}
+			startCmd.SetArgs(args)
+
+			err := startCmd.Execute()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+	})
+}
+
+func TestNewQueueStore(t *testing.T) {
+	t.Run("providers", func(t *testing.T) {
+		codec, err := compression.New(compression.Gzip)
+		require.NoError(t, err)
+
+		routerStorage := &operation.Storage{Persistent: mem.NewProvider(), Locks: lock.NewLocal()}
+
+		queues, err := newQueueStore(mem.NewProvider(), routerStorage, &hubRouterParameters{
+			datasourceParams: &datasourceParams{}, queueCodec: codec,
+			queueDedup: true, queueOrdering: true, queueLimits: &mailbox.Config{MaxDepth: 100},
+		}, &agentTransports{})
+		require.NoError(t, err)
+
+		require.NotNil(t, queues.pickup)
+		require.True(t, queues.ordering.Enabled())
+		require.Equal(t, queues.dedup, queues.mailboxes.Provider)
+		require.Equal(t, queues.compression, queues.dedup.Provider)
+	})
+
+	t.Run("pickup queue error", func(t *testing.T) {
+		routerStorage := &operation.Storage{
+			Persistent: &mockstore.MockStoreProvider{FailNamespace: pickup.IndexNamespace},
+			Locks:      lock.NewLocal(),
+		}
+
+		_, err := newQueueStore(mem.NewProvider(), routerStorage, &hubRouterParameters{
+			datasourceParams: &datasourceParams{},
+		}, &agentTransports{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "init pickup queue")
+	})
+
+	t.Run("legacy mailbox store error", func(t *testing.T) {
+		routerStorage := &operation.Storage{Persistent: mem.NewProvider(), Locks: lock.NewLocal()}
+
+		_, err := newQueueStore(&mockstore.MockStoreProvider{FailNamespace: messagepickup.Namespace}, routerStorage,
+			&hubRouterParameters{datasourceParams: &datasourceParams{}}, &agentTransports{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open legacy mailbox store")
+	})
+
+	t.Run("mailboxes of the aries message pickup migrated", func(t *testing.T) {
+		pins := mem.NewProvider()
+
+		legacy, err := pins.OpenStore(messagepickup.Namespace)
+		require.NoError(t, err)
+		require.NoError(t, legacy.Put("did:1", []byte(`{"DID":"did:1","messages":[{"id":"a","msg":{}}]}`)))
+
+		framework, err := aries.New(aries.WithStoreProvider(pins))
+		require.NoError(t, err)
+
+		defer func() { require.NoError(t, framework.Close()) }()
+
+		ctx, err := framework.Context()
+		require.NoError(t, err)
+
+		recorder, err := connection.NewRecorder(ctx)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-1", State: "completed", TheirDID: "did:1",
+		}))
+
+		queues, err := newQueueStore(pins, &operation.Storage{Persistent: mem.NewProvider(), Locks: lock.NewLocal()},
+			&hubRouterParameters{datasourceParams: &datasourceParams{}}, &agentTransports{})
+		require.NoError(t, err)
+
+		migrateMailboxes(framework, queues)
+
+		depth, err := queues.pickup.Depth("did:1")
+		require.NoError(t, err)
+		require.Equal(t, 1, depth)
+
+		_, err = legacy.Get("did:1")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,108 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+// Event redaction config.
+const (
+	webhookDIDRedactionFlagName  = "webhook-did-redaction"
+	webhookDIDRedactionFlagUsage = "Redaction of the DIDs of the event notifications : raw, hashed or omitted." +
+		" Applies to the webhooks, and to the event sinks unless " + eventSinkDIDRedactionFlagName + " is set." +
+		" Defaults to raw." +
+		" Alternatively, this can be set with the following environment variable: " + webhookDIDRedactionEnvKey
+	webhookDIDRedactionEnvKey = "HUB_ROUTER_WEBHOOK_DID_REDACTION"
+
+	webhookKeyRedactionFlagName  = "webhook-key-redaction"
+	webhookKeyRedactionFlagUsage = "Redaction of the keys of the event notifications : raw, hashed or omitted." +
+		" Applies to the webhooks, and to the event sinks unless " + eventSinkKeyRedactionFlagName + " is set." +
+		" Defaults to raw." +
+		" Alternatively, this can be set with the following environment variable: " + webhookKeyRedactionEnvKey
+	webhookKeyRedactionEnvKey = "HUB_ROUTER_WEBHOOK_KEY_REDACTION"
+
+	eventSinkDIDRedactionFlagName  = "event-sink-did-redaction"
+	eventSinkDIDRedactionFlagUsage = "Redaction of the DIDs of the event notifications published to the event sinks" +
+		" (SQS, SNS, Pub/Sub, Service Bus) : raw, hashed or omitted. Defaults to " + webhookDIDRedactionFlagName + "." +
+		" Alternatively, this can be set with the following environment variable: " + eventSinkDIDRedactionEnvKey
+	eventSinkDIDRedactionEnvKey = "HUB_ROUTER_EVENT_SINK_DID_REDACTION"
+
+	eventSinkKeyRedactionFlagName  = "event-sink-key-redaction"
+	eventSinkKeyRedactionFlagUsage = "Redaction of the keys of the event notifications published to the event sinks" +
+		" (SQS, SNS, Pub/Sub, Service Bus) : raw, hashed or omitted. Defaults to " + webhookKeyRedactionFlagName + "." +
+		" Alternatively, this can be set with the following environment variable: " + eventSinkKeyRedactionEnvKey
+	eventSinkKeyRedactionEnvKey = "HUB_ROUTER_EVENT_SINK_KEY_REDACTION"
+
+	redactionSaltFlagName  = "event-redaction-salt"
+	redactionSaltFlagUsage = "Secret the hashed DIDs and keys of the event notifications are keyed with" +
+		" (HMAC-SHA256), so that the consumers can't correlate them with the identifiers they know. Share the same" +
+		" salt across the router instances to hash the identifiers consistently." +
+		" Alternatively, this can be set with the following environment variable: " + redactionSaltEnvKey
+	redactionSaltEnvKey = "HUB_ROUTER_EVENT_REDACTION_SALT"
+)
+
+func createRedactionFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(webhookDIDRedactionFlagName, "", "", webhookDIDRedactionFlagUsage)
+	startCmd.Flags().StringP(webhookKeyRedactionFlagName, "", "", webhookKeyRedactionFlagUsage)
+	startCmd.Flags().StringP(eventSinkDIDRedactionFlagName, "", "", eventSinkDIDRedactionFlagUsage)
+	startCmd.Flags().StringP(eventSinkKeyRedactionFlagName, "", "", eventSinkKeyRedactionFlagUsage)
+	startCmd.Flags().StringP(redactionSaltFlagName, "", "", redactionSaltFlagUsage)
+}
+
+// getRedactionParams sets the redaction of the notifications posted to the webhooks, and of those published to the
+// event sinks if it differs.
+func getRedactionParams(cmd *cobra.Command, params *webhookParameters) error {
+	levels := map[string]string{}
+
+	for flagName, envKey := range map[string]string{
+		webhookDIDRedactionFlagName:   webhookDIDRedactionEnvKey,
+		webhookKeyRedactionFlagName:   webhookKeyRedactionEnvKey,
+		eventSinkDIDRedactionFlagName: eventSinkDIDRedactionEnvKey,
+		eventSinkKeyRedactionFlagName: eventSinkKeyRedactionEnvKey,
+	} {
+		level := cmdutils.GetUserSetOptionalVarFromString(cmd, flagName, envKey)
+		if level != "" && !webhook.ValidRedaction(level) {
+			return fmt.Errorf("invalid %s : %s", flagName, level)
+		}
+
+		levels[flagName] = level
+	}
+
+	salt := cmdutils.GetUserSetOptionalVarFromString(cmd, redactionSaltFlagName, redactionSaltEnvKey)
+
+	params.redaction = &webhook.Redaction{
+		DIDs: levels[webhookDIDRedactionFlagName],
+		Keys: levels[webhookKeyRedactionFlagName],
+		Salt: salt,
+	}
+
+	if levels[eventSinkDIDRedactionFlagName] == "" && levels[eventSinkKeyRedactionFlagName] == "" {
+		return nil
+	}
+
+	params.sinkRedaction = &webhook.Redaction{
+		DIDs: levels[eventSinkDIDRedactionFlagName],
+		Keys: levels[eventSinkKeyRedactionFlagName],
+		Salt: salt,
+	}
+
+	if params.sinkRedaction.DIDs == "" {
+		params.sinkRedaction.DIDs = params.redaction.DIDs
+	}
+
+	if params.sinkRedaction.Keys == "" {
+		params.sinkRedaction.Keys = params.redaction.Keys
+	}
+
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestGetRedactionParams(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createRedactionFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("raw by default", func(t *testing.T) {
+		params := &webhookParameters{}
+		require.NoError(t, getRedactionParams(newCmd(), params))
+		require.Equal(t, &webhook.Redaction{}, params.redaction)
+		require.Nil(t, params.sinkRedaction)
+	})
+
+	t.Run("webhook redaction", func(t *testing.T) {
+		params := &webhookParameters{}
+		require.NoError(t, getRedactionParams(newCmd(
+			"--"+webhookDIDRedactionFlagName, webhook.RedactionHashed,
+			"--"+webhookKeyRedactionFlagName, webhook.RedactionOmitted,
+			"--"+redactionSaltFlagName, "salt",
+		), params))
+		require.Equal(t, &webhook.Redaction{
+			DIDs: webhook.RedactionHashed, Keys: webhook.RedactionOmitted, Salt: "salt",
+		}, params.redaction)
+		require.Nil(t, params.sinkRedaction)
+	})
+
+	t.Run("event sink redaction", func(t *testing.T) {
+		params := &webhookParameters{}
+		require.NoError(t, getRedactionParams(newCmd(
+			"--"+webhookKeyRedactionFlagName, webhook.RedactionHashed,
+			"--"+eventSinkDIDRedactionFlagName, webhook.RedactionOmitted,
+		), params))
+		require.Equal(t, &webhook.Redaction{Keys: webhook.RedactionHashed}, params.redaction)
+		require.Equal(t, &webhook.Redaction{
+			DIDs: webhook.RedactionOmitted, Keys: webhook.RedactionHashed,
+		}, params.sinkRedaction)
+
+		params = &webhookParameters{}
+		require.NoError(t, getRedactionParams(newCmd(
+			"--"+webhookDIDRedactionFlagName, webhook.RedactionHashed,
+			"--"+eventSinkKeyRedactionFlagName, webhook.RedactionOmitted,
+		), params))
+		require.Equal(t, &webhook.Redaction{
+			DIDs: webhook.RedactionHashed, Keys: webhook.RedactionOmitted,
+		}, params.sinkRedaction)
+	})
+
+	t.Run("invalid redaction", func(t *testing.T) {
+		for _, flagName := range []string{
+			webhookDIDRedactionFlagName, webhookKeyRedactionFlagName, eventSinkDIDRedactionFlagName,
+			eventSinkKeyRedactionFlagName,
+		} {
+			err := getRedactionParams(newCmd("--"+flagName, "masked"), &webhookParameters{})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flagName)
+		}
+	})
+}
@@ -0,0 +1,111 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/anomaly"
+)
+
+// Stats config.
+const (
+	statsRetentionFlagName  = "stats-retention"
+	statsRetentionFlagUsage = "Period the hourly stats rollups (GET /stats/history) are kept for, eg: 168h." +
+		" Defaults to 720h (30 days)." +
+		" Alternatively, this can be set with the following environment variable: " + statsRetentionEnvKey
+	statsRetentionEnvKey = "HUB_ROUTER_STATS_RETENTION"
+)
+
+// Anomaly detection config.
+const (
+	anomalyDetectionFlagName  = "anomaly-detection"
+	anomalyDetectionFlagUsage = "Analyze the routed traffic hourly and report the unusual routing patterns" +
+		" (GET /reports/anomalies) : volume spikes, new sender keys and bursts of failures. Defaults to false." +
+		" Alternatively, this can be set with the following environment variable: " + anomalyDetectionEnvKey
+	anomalyDetectionEnvKey = "HUB_ROUTER_ANOMALY_DETECTION"
+
+	anomalyBaselineFlagName  = "anomaly-baseline"
+	anomalyBaselineFlagUsage = "Period before the analyzed hour its traffic is compared to, eg: 48h." +
+		" Defaults to 24h." +
+		" Alternatively, this can be set with the following environment variable: " + anomalyBaselineEnvKey
+	anomalyBaselineEnvKey = "HUB_ROUTER_ANOMALY_BASELINE"
+)
+
+func createReportingFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(statsRetentionFlagName, "", "", statsRetentionFlagUsage)
+	startCmd.Flags().StringP(anomalyDetectionFlagName, "", "", anomalyDetectionFlagUsage)
+	startCmd.Flags().StringP(anomalyBaselineFlagName, "", "", anomalyBaselineFlagUsage)
+}
+
+// getReportingParams sets the config of the anomaly detection, of the digests, of the alerts, of the incident
+// timeline, the exit code of the permanent failures and the history limit.
+func getReportingParams(cmd *cobra.Command, params *hubRouterParameters) error {
+	var err error
+
+	params.anomalies, err = getAnomalyConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.digests, err = getDigestParams(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.alerts, err = getAlertParams(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.incidents, err = getIncidentConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.failureExitCode, err = getFailureExitCode(cmd)
+	if err != nil {
+		return err
+	}
+
+	params.historyLimit, err = getHistoryLimit(cmd)
+
+	return err
+}
+
+func getStatsRetention(cmd *cobra.Command) (time.Duration, error) {
+	retention, err := cmdutils.GetUserSetVarFromString(cmd, statsRetentionFlagName, statsRetentionEnvKey, true)
+	if err != nil || retention == "" {
+		return 0, err
+	}
+
+	statsRetention, err := time.ParseDuration(retention)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse stats retention %s: %w", retention, err)
+	}
+
+	return statsRetention, nil
+}
+
+// getAnomalyConfig returns the config of the anomaly detection, nil if not enabled.
+func getAnomalyConfig(cmd *cobra.Command) (*anomaly.Config, error) {
+	enabled, err := getBool(cmd, anomalyDetectionFlagName, anomalyDetectionEnvKey)
+	if err != nil || !enabled {
+		return nil, err
+	}
+
+	baseline, err := getThreshold(cmd, anomalyBaselineFlagName, anomalyBaselineEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &anomaly.Config{Baseline: baseline}, nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/credrotation"
+)
+
+// getDSN returns the datasource name read from the file set with the file flag if any, else the one set with the
+// flag, and the file.
+func getDSN(cmd *cobra.Command, flagName, envKey, fileFlagName, fileEnvKey string,
+	optional bool) (string, string, error) {
+	file, err := cmdutils.GetUserSetVarFromString(cmd, fileFlagName, fileEnvKey, true)
+	if err != nil {
+		return "", "", err
+	}
+
+	if file == "" {
+		dsn, dsnErr := cmdutils.GetUserSetVarFromString(cmd, flagName, envKey, optional)
+
+		return dsn, "", dsnErr
+	}
+
+	dsn, err := credrotation.ReadDSN(file)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid %s : %w", fileFlagName, err)
+	}
+
+	return dsn, file, nil
+}
+
+// newRotatingStore connects the storage provider through a credrotation provider, rotated by the watcher of the DSN
+// file. The storage driver can't change with the rotation.
+func newRotatingStore(dbURL, dsnFile, prefix string, params *datasourceParams) (storage.Provider, error) {
+	driver, _, err := getDBParams(dbURL)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := credrotation.New(func(url string) (storage.Provider, error) {
+		d, dsn, parseErr := getDBParams(url)
+		if parseErr != nil {
+			return nil, parseErr
+		}
+
+		if d != driver {
+			return nil, fmt.Errorf("storage driver can't change from %s to %s", driver, d)
+		}
+
+		return supportedStorageProviders[driver](dsn, prefix)
+	}, dbURL, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	params.watch(dsnFile, dbURL, p)
+
+	return p, nil
+}
+
+// watch rotates the credentials of the rotator when the DSN file changes, with the watcher shared by the connections
+// of the file.
+func (p *datasourceParams) watch(dsnFile, dbURL string, r credrotation.Rotator) {
+	w, ok := p.watchers[dsnFile]
+	if !ok {
+		w = credrotation.NewWatcher(dsnFile, dbURL)
+		w.Start(p.reloadInterval)
+
+		p.watchers[dsnFile] = w
+	}
+
+	w.Add(r)
+}
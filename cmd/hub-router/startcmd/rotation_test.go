@@ -0,0 +1,97 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/credrotation"
+)
+
+func TestCredentialRotation(t *testing.T) {
+	writeDSN := func(t *testing.T, dsn string) string {
+		t.Helper()
+
+		file := filepath.Join(t.TempDir(), "dsn")
+		require.NoError(t, ioutil.WriteFile(file, []byte(dsn), 0o600))
+
+		return file
+	}
+
+	t.Run("dsn files", func(t *testing.T) {
+		persistentFile := writeDSN(t, "mem://persistent")
+
+		startCmd := GetStartCmd(&mockServer{})
+		require.NoError(t, startCmd.ParseFlags([]string{
+			"--" + datasourcePersistentFileFlagName, persistentFile,
+			"--" + datasourceTransientFlagName, "mem://transient",
+			"--" + datasourceReloadIntervalFlagName, "1m",
+		}))
+
+		params, err := getDatasourceParams(startCmd)
+		require.NoError(t, err)
+		require.Equal(t, "mem://persistent", params.persistentURL)
+		require.Equal(t, persistentFile, params.persistentFile)
+		require.Equal(t, "mem://transient", params.transientURL)
+		require.Empty(t, params.transientFile)
+
+		persistent, _, err := initStores(params, "_test", "_testps")
+		require.NoError(t, err)
+
+		_, ok := persistent.(*credrotation.Provider)
+		require.False(t, ok, "the mem storage is not rotated")
+	})
+
+	t.Run("invalid dsn file", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+		require.NoError(t, startCmd.ParseFlags([]string{
+			"--" + datasourcePersistentFileFlagName, filepath.Join(t.TempDir(), "missing"),
+		}))
+
+		_, err := getDatasourceParams(startCmd)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid dsn-p-file")
+	})
+
+	t.Run("rotating store", func(t *testing.T) {
+		file := writeDSN(t, "mem://test")
+		params := &datasourceParams{}
+
+		params.watchers = make(map[string]*credrotation.Watcher)
+
+		store, err := newRotatingStore("mem://test", file, "hr-store", params)
+		require.NoError(t, err)
+
+		p, ok := store.(*credrotation.Provider)
+		require.True(t, ok)
+
+		_, err = newRotatingStore("mem://test", file, "hr-store-ps", params)
+		require.NoError(t, err)
+		require.Len(t, params.watchers, 1)
+
+		err = p.Rotate("mysql://test")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "storage driver can't change from mem to mysql")
+
+		err = p.Rotate("invalid")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid dbURL")
+
+		require.NoError(t, p.Rotate("mem://rotated"))
+
+		for _, w := range params.watchers {
+			w.Stop()
+		}
+
+		_, err = newRotatingStore("invalid", file, "hr-store", params)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,82 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/slowconsumer"
+)
+
+// Slow consumer config.
+const (
+	slowConsumerPickupThresholdFlagName  = "slow-consumer-pickup-threshold"
+	slowConsumerPickupThresholdFlagUsage = "Time the messages queued for a wallet can wait to be picked up before" +
+		" the delivery is considered slow, eg: 5m. Measured at the queue check interval (30s). Disabled if not set." +
+		" Alternatively, this can be set with the following environment variable: " + slowConsumerPickupThresholdEnvKey
+	slowConsumerPickupThresholdEnvKey = "HUB_ROUTER_SLOW_CONSUMER_PICKUP_THRESHOLD"
+
+	slowConsumerWriteThresholdFlagName  = "slow-consumer-write-threshold"
+	slowConsumerWriteThresholdFlagUsage = "WebSocket write latency above which the delivery to a wallet is" +
+		" considered slow, eg: 500ms. Disabled if not set." +
+		" Alternatively, this can be set with the following environment variable: " + slowConsumerWriteThresholdEnvKey
+	slowConsumerWriteThresholdEnvKey = "HUB_ROUTER_SLOW_CONSUMER_WRITE_THRESHOLD"
+
+	slowConsumerWindowFlagName  = "slow-consumer-window"
+	slowConsumerWindowFlagUsage = "Number of consecutive slow deliveries after which a wallet is flagged as slow" +
+		" consumer. Defaults to 5 if not set." +
+		" Alternatively, this can be set with the following environment variable: " + slowConsumerWindowEnvKey
+	slowConsumerWindowEnvKey = "HUB_ROUTER_SLOW_CONSUMER_WINDOW"
+
+	slowConsumerDegradeFlagName  = "slow-consumer-degrade"
+	slowConsumerDegradeFlagUsage = "Serialize the WebSocket writes to the slow consumers so that they do not hold up" +
+		" the deliveries to the other wallets. Possible values [true] [false]. Defaults to false if not set." +
+		" Alternatively, this can be set with the following environment variable: " + slowConsumerDegradeEnvKey
+	slowConsumerDegradeEnvKey = "HUB_ROUTER_SLOW_CONSUMER_DEGRADE"
+)
+
+func createSlowConsumerFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(slowConsumerPickupThresholdFlagName, "", "", slowConsumerPickupThresholdFlagUsage)
+	startCmd.Flags().StringP(slowConsumerWriteThresholdFlagName, "", "", slowConsumerWriteThresholdFlagUsage)
+	startCmd.Flags().StringP(slowConsumerWindowFlagName, "", "", slowConsumerWindowFlagUsage)
+	startCmd.Flags().StringP(slowConsumerDegradeFlagName, "", "", slowConsumerDegradeFlagUsage)
+}
+
+func getSlowConsumerConfig(cmd *cobra.Command) (*slowconsumer.Config, error) {
+	pickupThreshold, err := getThreshold(cmd, slowConsumerPickupThresholdFlagName, slowConsumerPickupThresholdEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	writeThreshold, err := getThreshold(cmd, slowConsumerWriteThresholdFlagName, slowConsumerWriteThresholdEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	window, err := getWatermark(cmd, slowConsumerWindowFlagName, slowConsumerWindowEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &slowconsumer.Config{PickupThreshold: pickupThreshold, WriteThreshold: writeThreshold, Window: window}
+
+	degrade, err := cmdutils.GetUserSetVarFromString(cmd, slowConsumerDegradeFlagName, slowConsumerDegradeEnvKey, true)
+	if err != nil || degrade == "" {
+		return config, err
+	}
+
+	config.Degrade, err = strconv.ParseBool(degrade)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s : %w", slowConsumerDegradeFlagName, err)
+	}
+
+	return config, nil
+}
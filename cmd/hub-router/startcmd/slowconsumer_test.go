@@ -0,0 +1,60 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetSlowConsumerConfig(t *testing.T) {
+	t.Run("with slow consumer detection", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + slowConsumerPickupThresholdFlagName, "5m",
+			"--" + slowConsumerWriteThresholdFlagName, "500ms",
+			"--" + slowConsumerWindowFlagName, "3",
+			"--" + slowConsumerDegradeFlagName, "true",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid slow consumer config", func(t *testing.T) {
+		for flag, val := range map[string]string{
+			slowConsumerPickupThresholdFlagName: "invalid",
+			slowConsumerWriteThresholdFlagName:  "invalid",
+			slowConsumerWindowFlagName:          "invalid",
+			slowConsumerDegradeFlagName:         "invalid",
+		} {
+			startCmd := GetStartCmd(&mockServer{})
+
+			args := []string{
+				"--" + hostURLFlagName, "localhost:8080",
+				"--" + didCommHTTPHostFlagName, randomURL(t),
+				"--" + didCommWSHostFlagName, randomURL(t),
+				"--" + datasourcePersistentFlagName, "mem://tests",
+				"--" + datasourceTransientFlagName, "mem://tests",
+				"--" + flag, val,
+			}
+			startCmd.SetArgs(args)
+
+			err := startCmd.Execute()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+flag)
+		}
+	})
+}
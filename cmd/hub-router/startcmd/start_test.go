@@ -94,72 +94,6 @@ func TestGetStartCmd(t *testing.T) {
 		require.Equal(t, "HUB_ROUTER_HOST_URL value is empty", err.Error())
 	})
 
-	t.Run("missing persistent dsn arg", func(t *testing.T) {
-		startCmd := GetStartCmd(&mockServer{})
-
-		args := []string{
-			"--" + hostURLFlagName, "localhost:8080",
-			"--" + didCommHTTPHostFlagName, randomURL(t),
-			"--" + datasourceTransientFlagName, "mem://tests",
-		}
-		startCmd.SetArgs(args)
-
-		err := startCmd.Execute()
-		require.Error(t, err)
-		require.Equal(t,
-			"Neither dsn-p (command line flag) nor HUB_ROUTER_DSN_PERSISTENT (environment variable) have been set.", err.Error())
-	})
-
-	t.Run("missing transient dsn arg", func(t *testing.T) {
-		startCmd := GetStartCmd(&mockServer{})
-
-		args := []string{
-			"--" + hostURLFlagName, "localhost:8080",
-			"--" + didCommHTTPHostFlagName, randomURL(t),
-			"--" + datasourcePersistentFlagName, "mem://tests",
-		}
-		startCmd.SetArgs(args)
-
-		err := startCmd.Execute()
-		require.Error(t, err)
-		require.Equal(t,
-			"Neither dsn-t (command line flag) nor HUB_ROUTER_DSN_TRANSIENT (environment variable) have been set.", err.Error())
-	})
-
-	t.Run("unsupported datasource driver", func(t *testing.T) {
-		startCmd := GetStartCmd(&mockServer{})
-
-		args := []string{
-			"--" + hostURLFlagName, "localhost:8080",
-			"--" + didCommHTTPHostFlagName, randomURL(t),
-			"--" + didCommWSHostFlagName, randomURL(t),
-			"--" + datasourcePersistentFlagName, "UNSUPPORTED://",
-			"--" + datasourceTransientFlagName, "mem://tests",
-		}
-		startCmd.SetArgs(args)
-
-		err := startCmd.Execute()
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "unsupported storage driver")
-	})
-
-	t.Run("malformed dsn", func(t *testing.T) {
-		startCmd := GetStartCmd(&mockServer{})
-
-		args := []string{
-			"--" + hostURLFlagName, "localhost:8080",
-			"--" + didCommHTTPHostFlagName, randomURL(t),
-			"--" + didCommWSHostFlagName, randomURL(t),
-			"--" + datasourcePersistentFlagName, "malformed",
-			"--" + datasourceTransientFlagName, "mem://tests",
-		}
-		startCmd.SetArgs(args)
-
-		err := startCmd.Execute()
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "invalid dbURL malformed")
-	})
-
 	t.Run("invalid system cert flag", func(t *testing.T) {
 		startCmd := GetStartCmd(&mockServer{})
 
@@ -177,24 +111,6 @@ func TestGetStartCmd(t *testing.T) {
 		require.Contains(t, err.Error(), "invalid syntax")
 	})
 
-	t.Run("invalid dsn timeout", func(t *testing.T) {
-		startCmd := GetStartCmd(&mockServer{})
-
-		args := []string{
-			"--" + hostURLFlagName, "localhost:8080",
-			"--" + didCommHTTPHostFlagName, randomURL(t),
-			"--" + didCommWSHostFlagName, randomURL(t),
-			"--" + datasourcePersistentFlagName, "mem://tests",
-			"--" + datasourceTransientFlagName, "mem://tests",
-			"--" + datasourceTimeoutFlagName, "invalid",
-		}
-		startCmd.SetArgs(args)
-
-		err := startCmd.Execute()
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "failed to parse dsn timeout")
-	})
-
 	t.Run("missing didcomm inbound host", func(t *testing.T) {
 		startCmd := GetStartCmd(&mockServer{})
 
@@ -212,20 +128,6 @@ func TestGetStartCmd(t *testing.T) {
 				"(environment variable) have been set.",
 			err.Error())
 	})
-
-	t.Run("test adapter mode - store errors", func(t *testing.T) {
-		parameters := &hubRouterParameters{
-			datasourceParams: &datasourceParams{},
-		}
-
-		err := addHandlers(parameters, nil, nil, nil)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "init persistent storage: invalid dbURL")
-
-		_, err = initStore("invaldidb://test", "", 10)
-		require.Error(t, err)
-		require.Contains(t, err.Error(), "unsupported storage driver: invaldidb")
-	})
 }
 
 func TestStartHubRouter(t *testing.T) {
@@ -276,29 +178,6 @@ func TestStartHubRouter(t *testing.T) {
 	})
 }
 
-func TestSupportedDatabases(t *testing.T) {
-	tests := []struct {
-		dbURL          string
-		isErr          bool
-		expectedErrMsg string
-	}{
-		{dbURL: "mem://test", isErr: false},
-		{dbURL: "mysql://test", isErr: true, expectedErrMsg: "store init - connect to storage at test"},
-		{dbURL: "random", isErr: true, expectedErrMsg: "invalid dbURL random"},
-	}
-
-	for _, test := range tests {
-		_, err := initStore(test.dbURL, "hr-store", 1)
-
-		if !test.isErr {
-			require.NoError(t, err)
-		} else {
-			require.Error(t, err)
-			require.Contains(t, err.Error(), test.expectedErrMsg)
-		}
-	}
-}
-
 func checkFlagPropertiesCorrect(t *testing.T, cmd *cobra.Command, flagName, flagShorthand, flagUsage string) {
 	t.Helper()
 
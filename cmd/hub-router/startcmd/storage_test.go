@@ -0,0 +1,200 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDatasourceParams(t *testing.T) {
+	t.Run("missing persistent dsn arg", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + datasourceTransientFlagName, "mem://tests",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.Error(t, err)
+		require.Equal(t,
+			"Neither dsn-p (command line flag) nor HUB_ROUTER_DSN_PERSISTENT (environment variable) have been set.", err.Error())
+	})
+
+	t.Run("missing transient dsn arg", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.Error(t, err)
+		require.Equal(t,
+			"Neither dsn-t (command line flag) nor HUB_ROUTER_DSN_TRANSIENT (environment variable) have been set.", err.Error())
+	})
+
+	t.Run("unsupported datasource driver", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "UNSUPPORTED://",
+			"--" + datasourceTransientFlagName, "mem://tests",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unsupported storage driver")
+	})
+
+	t.Run("malformed dsn", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "malformed",
+			"--" + datasourceTransientFlagName, "mem://tests",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid dbURL malformed")
+	})
+
+	t.Run("invalid dsn timeout", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + datasourceTimeoutFlagName, "invalid",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to parse dsn timeout")
+	})
+
+	t.Run("test adapter mode - store errors", func(t *testing.T) {
+		parameters := &hubRouterParameters{
+			datasourceParams: &datasourceParams{},
+		}
+
+		_, err := initRouterStorage(parameters.datasourceParams)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "init persistent storage: invalid dbURL")
+
+		_, err = initStore("invaldidb://test", "", "", &datasourceParams{timeout: 10})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unsupported storage driver: invaldidb")
+
+		_, err = initStore("mongodb://mongodb:27017", "", "", &datasourceParams{timeout: 10})
+		require.ErrorIs(t, err, errMongoDBUnsupported)
+	})
+}
+
+func TestSupportedDatabases(t *testing.T) {
+	tests := []struct {
+		dbURL          string
+		isErr          bool
+		expectedErrMsg string
+	}{
+		{dbURL: "mem://test", isErr: false},
+		{dbURL: "mysql://test", isErr: true, expectedErrMsg: "store init - connect to storage at test"},
+		{dbURL: "random", isErr: true, expectedErrMsg: "invalid dbURL random"},
+	}
+
+	for _, test := range tests {
+		_, err := initStore(test.dbURL, "", "hr-store", &datasourceParams{timeout: 1})
+
+		if !test.isErr {
+			require.NoError(t, err)
+		} else {
+			require.Error(t, err)
+			require.Contains(t, err.Error(), test.expectedErrMsg)
+		}
+	}
+}
+
+func TestWaitFor(t *testing.T) {
+	t.Run("retries until the dependency is available", func(t *testing.T) {
+		attempts := 0
+
+		err := waitFor("test", time.Minute, func() error {
+			attempts++
+			if attempts < 2 {
+				return errors.New("not available")
+			}
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, attempts)
+	})
+
+	t.Run("gives up after the window", func(t *testing.T) {
+		err := waitFor("test", time.Millisecond, func() error {
+			return errors.New("not available")
+		})
+		require.EqualError(t, err, "not available")
+	})
+
+	t.Run("no window", func(t *testing.T) {
+		attempts := 0
+
+		err := waitFor("test", 0, func() error {
+			attempts++
+
+			return errors.New("not available")
+		})
+		require.Error(t, err)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("storage", func(t *testing.T) {
+		_, err := initStore("mysql://test", "", "hr-store", &datasourceParams{waitFor: time.Millisecond})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "store init - connect to storage at test")
+	})
+
+	t.Run("invalid wait-for", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		startCmd.SetArgs([]string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + waitForFlagName, "2",
+		})
+
+		err := startCmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid wait-for")
+	})
+}
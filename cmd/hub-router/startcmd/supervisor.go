@@ -0,0 +1,78 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/supervisor"
+)
+
+// Failure exit config.
+const (
+	failureExitCodeFlagName  = "failure-exit-code"
+	failureExitCodeFlagUsage = "Exit code of the router once one of its listeners (DIDComm HTTP and WebSocket, REST" +
+		" API) or of its DIDComm listener goroutines failed permanently, eg: 3, so that the orchestration restarts it." +
+		" If not set, the router keeps running with its readiness (GET /readiness) failing." +
+		" Alternatively, this can be set with the following environment variable: " + failureExitCodeEnvKey
+	failureExitCodeEnvKey = "HUB_ROUTER_FAILURE_EXIT_CODE"
+
+	// maxExitCode is the highest exit code not reserved by the shells.
+	maxExitCode          = 125
+	listenerCheckTimeout = 5 * time.Second
+)
+
+func createSupervisorFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(failureExitCodeFlagName, "", "", failureExitCodeFlagUsage)
+}
+
+// getFailureExitCode returns the exit code of the permanent failures, zero if the router doesn't exit.
+func getFailureExitCode(cmd *cobra.Command) (int, error) {
+	value := cmdutils.GetUserSetOptionalVarFromString(cmd, failureExitCodeFlagName, failureExitCodeEnvKey)
+	if value == "" {
+		return 0, nil
+	}
+
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 1 || code > maxExitCode {
+		return 0, fmt.Errorf("invalid %s : %s", failureExitCodeFlagName, value)
+	}
+
+	return code, nil
+}
+
+// watchListeners checks that the DIDComm listeners, started by the Aries framework, keep accepting the connections.
+func watchListeners(s *supervisor.Supervisor, params *didCommParameters) {
+	for component, host := range map[string]string{
+		"didcomm-http": params.httpHostInternal,
+		"didcomm-ws":   params.wsHostInternal,
+	} {
+		s.Watch(component, supervisor.DialCheck(dialAddress(host), listenerCheckTimeout),
+			supervisor.DefaultCheckInterval, supervisor.DefaultCheckThreshold)
+	}
+}
+
+// dialAddress returns the address to dial the listener at : the listeners on all the interfaces are dialed on the
+// loopback interface, as the unspecified address can't be dialed on every platform.
+func dialAddress(host string) string {
+	h, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return host
+	}
+
+	if ip := net.ParseIP(h); h == "" || ip != nil && ip.IsUnspecified() {
+		h = "localhost"
+	}
+
+	return net.JoinHostPort(h, port)
+}
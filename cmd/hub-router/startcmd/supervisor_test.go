@@ -0,0 +1,98 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/supervisor"
+)
+
+type failingServer struct{}
+
+func (s *failingServer) ListenAndServe(string, http.Handler) error {
+	return errors.New("address already in use")
+}
+
+func (s *failingServer) ListenAndServeTLS(string, string, string, http.Handler) error {
+	return errors.New("address already in use")
+}
+
+func TestGetFailureExitCode(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createSupervisorFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("not set", func(t *testing.T) {
+		code, err := getFailureExitCode(newCmd())
+		require.NoError(t, err)
+		require.Zero(t, code)
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		code, err := getFailureExitCode(newCmd("--"+failureExitCodeFlagName, "3"))
+		require.NoError(t, err)
+		require.Equal(t, 3, code)
+	})
+
+	t.Run("invalid param", func(t *testing.T) {
+		for _, value := range []string{"0", "126", "restart"} {
+			_, err := getFailureExitCode(newCmd("--"+failureExitCodeFlagName, value))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid "+failureExitCodeFlagName)
+		}
+	})
+}
+
+func TestWatchListeners(t *testing.T) {
+	t.Run("dial address", func(t *testing.T) {
+		for host, expected := range map[string]string{
+			":8090":          "localhost:8090",
+			"0.0.0.0:8090":   "localhost:8090",
+			"[::]:8090":      "localhost:8090",
+			"10.0.0.1:8090":  "10.0.0.1:8090",
+			"router:8090":    "router:8090",
+			"missing-port":   "missing-port",
+			"localhost:8090": "localhost:8090",
+		} {
+			require.Equal(t, expected, dialAddress(host), host)
+		}
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		s := supervisor.New(0)
+
+		watchListeners(s, &didCommParameters{httpHostInternal: ":8090", wsHostInternal: ":8091"})
+		s.Stop()
+
+		require.True(t, s.Ready())
+	})
+
+	t.Run("REST API listener failed", func(t *testing.T) {
+		startCmd := GetStartCmd(&failingServer{})
+		startCmd.SetArgs([]string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+		})
+
+		err := startCmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "address already in use")
+	})
+}
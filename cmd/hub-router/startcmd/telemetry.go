@@ -0,0 +1,95 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/restapi/operation"
+	"github.com/trustbloc/hub-router/pkg/telemetry"
+)
+
+// Telemetry config.
+const (
+	telemetryURLFlagName  = "telemetry-url"
+	telemetryURLFlagUsage = "Opt-in anonymous usage telemetry endpoint." +
+		" If set, aggregate non-identifying counters (version, protocol usage, storage backend) are posted" +
+		" to this URL periodically. Telemetry is disabled if not set." +
+		" Alternatively, this can be set with the following environment variable: " + telemetryURLEnvKey
+	telemetryURLEnvKey = "HUB_ROUTER_TELEMETRY_URL"
+
+	telemetryIntervalFlagName  = "telemetry-interval"
+	telemetryIntervalFlagUsage = "Interval between usage telemetry reports, eg: 12h. Defaults to 24h." +
+		" Alternatively, this can be set with the following environment variable: " + telemetryIntervalEnvKey
+	telemetryIntervalEnvKey  = "HUB_ROUTER_TELEMETRY_INTERVAL"
+	telemetryIntervalDefault = 24 * time.Hour
+)
+
+type telemetryParameters struct {
+	url      string
+	interval time.Duration
+}
+
+func createTelemetryFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(telemetryURLFlagName, "", "", telemetryURLFlagUsage)
+	startCmd.Flags().StringP(telemetryIntervalFlagName, "", "", telemetryIntervalFlagUsage)
+}
+
+func getTelemetryParams(cmd *cobra.Command) (*telemetryParameters, error) {
+	url, err := cmdutils.GetUserSetVarFromString(cmd, telemetryURLFlagName, telemetryURLEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	interval, err := cmdutils.GetUserSetVarFromString(cmd, telemetryIntervalFlagName, telemetryIntervalEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &telemetryParameters{
+		url:      url,
+		interval: telemetryIntervalDefault,
+	}
+
+	if interval != "" {
+		params.interval, err = time.ParseDuration(interval)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse telemetry interval %s: %w", interval, err)
+		}
+	}
+
+	return params, nil
+}
+
+func startTelemetry(params *hubRouterParameters, o *operation.Operation, tlsConfig *tls.Config) {
+	if params.telemetryParams == nil || params.telemetryParams.url == "" {
+		return
+	}
+
+	var backends []string
+
+	for _, dbURL := range []string{params.datasourceParams.persistentURL, params.datasourceParams.transientURL} {
+		if driver, _, err := getDBParams(dbURL); err == nil {
+			backends = append(backends, driver)
+		}
+	}
+
+	telemetry.New(&telemetry.Config{
+		Endpoint:        params.telemetryParams.url,
+		Interval:        params.telemetryParams.interval,
+		Version:         version,
+		StorageBackends: backends,
+		Usage:           o.ProtocolUsage,
+		HTTPClient:      &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}},
+	}).Start()
+}
@@ -0,0 +1,75 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/terms"
+)
+
+// Mediator terms config.
+const (
+	termsURLFlagName  = "terms-url"
+	termsURLFlagUsage = "URL of the terms of service of the mediator : attached to the invitations and sent with the" +
+		" mediation grants, so that the wallets display and record their acceptance. Requires the terms version." +
+		" Alternatively, this can be set with the following environment variable: " + termsURLEnvKey
+	termsURLEnvKey = "HUB_ROUTER_TERMS_URL"
+
+	termsVersionFlagName  = "terms-version"
+	termsVersionFlagUsage = "Version of the terms of service, eg: 2021-06. The version presented to each connection" +
+		" is recorded. Alternatively, this can be set with the following environment variable: " + termsVersionEnvKey
+	termsVersionEnvKey = "HUB_ROUTER_TERMS_VERSION"
+
+	privacyURLFlagName  = "privacy-url"
+	privacyURLFlagUsage = "URL of the privacy policy of the mediator, referenced with the terms of service." +
+		" Alternatively, this can be set with the following environment variable: " + privacyURLEnvKey
+	privacyURLEnvKey = "HUB_ROUTER_PRIVACY_URL"
+)
+
+func createTermsFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(termsURLFlagName, "", "", termsURLFlagUsage)
+	startCmd.Flags().StringP(termsVersionFlagName, "", "", termsVersionFlagUsage)
+	startCmd.Flags().StringP(privacyURLFlagName, "", "", privacyURLFlagUsage)
+}
+
+// getTerms returns the terms of the mediator, nil if not configured.
+func getTerms(cmd *cobra.Command) (*terms.Terms, error) {
+	t := &terms.Terms{
+		URL:        cmdutils.GetUserSetOptionalVarFromString(cmd, termsURLFlagName, termsURLEnvKey),
+		PrivacyURL: cmdutils.GetUserSetOptionalVarFromString(cmd, privacyURLFlagName, privacyURLEnvKey),
+		Version:    cmdutils.GetUserSetOptionalVarFromString(cmd, termsVersionFlagName, termsVersionEnvKey),
+	}
+
+	if t.URL == "" {
+		if t.PrivacyURL != "" || t.Version != "" {
+			return nil, fmt.Errorf("%s and %s require %s", privacyURLFlagName, termsVersionFlagName, termsURLFlagName)
+		}
+
+		return nil, nil
+	}
+
+	for flag, u := range map[string]string{termsURLFlagName: t.URL, privacyURLFlagName: t.PrivacyURL} {
+		if u == "" {
+			continue
+		}
+
+		if parsed, err := url.ParseRequestURI(u); err != nil || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid %s : %s", flag, u)
+		}
+	}
+
+	if t.Version == "" {
+		return nil, fmt.Errorf("%s requires %s", termsURLFlagName, termsVersionFlagName)
+	}
+
+	return t, nil
+}
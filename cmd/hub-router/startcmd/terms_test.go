@@ -0,0 +1,69 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/terms"
+)
+
+func TestGetTerms(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createTermsFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		config, err := getTerms(newCmd())
+		require.NoError(t, err)
+		require.False(t, config.Enabled())
+	})
+
+	t.Run("terms", func(t *testing.T) {
+		config, err := getTerms(newCmd(
+			"--"+termsURLFlagName, "https://example.com/terms",
+			"--"+termsVersionFlagName, "2021-06",
+			"--"+privacyURLFlagName, "https://example.com/privacy",
+		))
+		require.NoError(t, err)
+		require.Equal(t, &terms.Terms{
+			URL: "https://example.com/terms", PrivacyURL: "https://example.com/privacy", Version: "2021-06",
+		}, config)
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for _, tc := range []struct {
+			args []string
+			err  string
+		}{
+			{[]string{"--" + termsVersionFlagName, "2021-06"}, "require " + termsURLFlagName},
+			{[]string{"--" + termsURLFlagName, "https://example.com/terms"}, "requires " + termsVersionFlagName},
+			{
+				[]string{"--" + termsURLFlagName, "terms", "--" + termsVersionFlagName, "1"},
+				"invalid " + termsURLFlagName,
+			},
+			{
+				[]string{
+					"--" + termsURLFlagName, "https://example.com/terms", "--" + termsVersionFlagName, "1",
+					"--" + privacyURLFlagName, "/privacy",
+				},
+				"invalid " + privacyURLFlagName,
+			},
+		} {
+			_, err := getTerms(newCmd(tc.args...))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		}
+	})
+}
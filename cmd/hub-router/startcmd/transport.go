@@ -0,0 +1,134 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	arieshttp "github.com/hyperledger/aries-framework-go/pkg/didcomm/transport/http"
+	ariesws "github.com/hyperledger/aries-framework-go/pkg/didcomm/transport/ws"
+
+	"github.com/trustbloc/hub-router/pkg/connpool"
+	"github.com/trustbloc/hub-router/pkg/inbound"
+	"github.com/trustbloc/hub-router/pkg/limits"
+	"github.com/trustbloc/hub-router/pkg/privacy"
+	"github.com/trustbloc/hub-router/pkg/proxy"
+	"github.com/trustbloc/hub-router/pkg/residency"
+	hubrouter "github.com/trustbloc/hub-router/pkg/server"
+	"github.com/trustbloc/hub-router/pkg/slowconsumer"
+)
+
+// agentTransports are the Aries agent transports hooked to the hub-router operation: the WebSocket outbound
+// transport is timed to detect the slow consumers, the HTTP one pools the connections per destination, and the
+// inbound transports hand their envelopes through the chain of stages verifying the pinned keys, relaying the nested
+// forwards, rejecting the forwards addressed to a full queue and limiting the pickups.
+type agentTransports struct {
+	wsOutbound *slowconsumer.Outbound
+	httpPool   *connpool.Transport
+	inbound    []*inbound.Middleware
+	pickups    *limits.Pickups
+	residency  *residency.Router
+}
+
+func createTransports(parameters *hubRouterParameters, tlsConfig *tls.Config) (*agentTransports, error) {
+	inboundHTTP, err := arieshttp.NewInbound(
+		parameters.didCommParameters.httpHostInternal,
+		parameters.didCommParameters.httpHostExternal,
+		parameters.tlsParams.serveCertPath,
+		parameters.tlsParams.serveKeyPath,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("aries-framework - create http inbound transport : %w", err)
+	}
+
+	inboundWS, err := ariesws.NewInbound(
+		parameters.didCommParameters.wsHostInternal,
+		parameters.didCommParameters.wsHostExternal,
+		parameters.tlsParams.serveCertPath,
+		parameters.tlsParams.serveKeyPath,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("aries-framework - create ws inbound transport : %w", err)
+	}
+
+	// the WebSocket outbound transport dials through the outbound proxy, without changing the default HTTP client
+	wsOutbound := proxy.NewWSOutbound(ariesws.NewOutbound(), &http.Transport{
+		TLSClientConfig: tlsConfig, Proxy: parameters.proxyConfig.Proxy,
+	})
+
+	// the envelopes are admitted, then verified, before the sockets held open by the wallets are registered and the
+	// nested forwards relayed : a standby node relays nothing, and the relayed forwards aren't queued by the router
+	t := &agentTransports{
+		wsOutbound: slowconsumer.NewOutbound(wsOutbound, slowconsumer.DefaultSlowLanes),
+		inbound: []*inbound.Middleware{
+			inbound.NewMiddleware(inboundHTTP,
+				inbound.Admit, inbound.Verify, inbound.Relay, inbound.Gate, inbound.Limit),
+			inbound.NewMiddleware(inboundWS,
+				inbound.Admit, inbound.Verify, inbound.ObserveSocket, inbound.Relay, inbound.Gate, inbound.Limit),
+		},
+	}
+
+	if parameters.limits != nil {
+		t.pickups = limits.NewPickups(parameters.limits.MaxPickups)
+	}
+
+	if t.pickups != nil {
+		for _, m := range t.inbound {
+			m.Handle(inbound.Limit, t.pickups.HandleEnvelope)
+		}
+	}
+
+	return t, nil
+}
+
+func (t *agentTransports) inboundTransports() []transport.InboundTransport {
+	transports := make([]transport.InboundTransport, len(t.inbound))
+
+	for i, m := range t.inbound {
+		transports[i] = m
+	}
+
+	return transports
+}
+
+// newOutboundTransports returns the HTTP and WebSocket outbound transports of the agent, through the outbound proxy
+// and the connection pool, and padded and batched if the privacy is enabled.
+func newOutboundTransports(parameters *hubRouterParameters, tlsConfig *tls.Config,
+	transports *agentTransports) ([]transport.OutboundTransport, error) {
+	transports.httpPool = connpool.NewTransport(&http.Transport{
+		TLSClientConfig: tlsConfig, Proxy: parameters.proxyConfig.Proxy,
+	}, parameters.outboundPool)
+
+	outboundHTTP, err := arieshttp.NewOutbound(arieshttp.WithOutboundHTTPClient(&http.Client{
+		Transport: transports.httpPool,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("aries-framework - create outbound tranpsort opts : %w", err)
+	}
+
+	outbound := []transport.OutboundTransport{outboundHTTP, transports.wsOutbound}
+
+	if parameters.privacyConfig.Enabled() {
+		for i, ot := range outbound {
+			outbound[i] = privacy.NewOutbound(ot, parameters.privacyConfig)
+		}
+	}
+
+	return outbound, nil
+}
+
+func transportConfig(transports *agentTransports, packager transport.Packager) hubrouter.TransportConfig {
+	return hubrouter.TransportConfig{
+		WSOutbound:   transports.wsOutbound,
+		OutboundPool: transports.httpPool,
+		Inbound:      transports.inbound,
+		Packager:     packager,
+	}
+}
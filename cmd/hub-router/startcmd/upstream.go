@@ -0,0 +1,110 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/upstream"
+)
+
+// Upstream mediator config.
+const (
+	upstreamInvitationFlagName  = "upstream-mediator-invitation"
+	upstreamInvitationFlagUsage = "Out-of-band invitation of an upstream mediator the router requests mediation from," +
+		" when it runs behind NAT without a public endpoint : the JSON invitation, or its URL with the base64url" +
+		" encoded invitation in the oob query parameter. The router advertises the endpoint and the routing keys of" +
+		" the upstream mediator in its DID docs and mediation grants, and receives its messages on the return route." +
+		" Alternatively, this can be set with the following environment variable: " + upstreamInvitationEnvKey
+	upstreamInvitationEnvKey = "HUB_ROUTER_UPSTREAM_MEDIATOR_INVITATION"
+
+	upstreamLabelFlagName  = "upstream-mediator-label"
+	upstreamLabelFlagUsage = "Label of the router in the connection with the upstream mediator. Defaults to hub-router." +
+		" Alternatively, this can be set with the following environment variable: " + upstreamLabelEnvKey
+	upstreamLabelEnvKey = "HUB_ROUTER_UPSTREAM_MEDIATOR_LABEL"
+
+	upstreamTimeoutFlagName  = "upstream-mediator-timeout"
+	upstreamTimeoutFlagUsage = "Timeout of the connection with the upstream mediator and of its mediation grant, eg: 1m." +
+		" Defaults to 30s. Alternatively, this can be set with the following environment variable: " +
+		upstreamTimeoutEnvKey
+	upstreamTimeoutEnvKey = "HUB_ROUTER_UPSTREAM_MEDIATOR_TIMEOUT"
+)
+
+const oobQueryParam = "oob"
+
+func createUpstreamFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringP(upstreamInvitationFlagName, "", "", upstreamInvitationFlagUsage)
+	startCmd.Flags().StringP(upstreamLabelFlagName, "", "", upstreamLabelFlagUsage)
+	startCmd.Flags().StringP(upstreamTimeoutFlagName, "", "", upstreamTimeoutFlagUsage)
+}
+
+// getUpstreamConfig returns the config of the upstream mediator, nil if not configured.
+func getUpstreamConfig(cmd *cobra.Command) (*upstream.Config, error) {
+	value := cmdutils.GetUserSetOptionalVarFromString(cmd, upstreamInvitationFlagName, upstreamInvitationEnvKey)
+	if value == "" {
+		return nil, nil
+	}
+
+	invitation, err := parseInvitation(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s : %w", upstreamInvitationFlagName, err)
+	}
+
+	timeout, err := getThreshold(cmd, upstreamTimeoutFlagName, upstreamTimeoutEnvKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &upstream.Config{
+		Invitation: invitation,
+		Label:      cmdutils.GetUserSetOptionalVarFromString(cmd, upstreamLabelFlagName, upstreamLabelEnvKey),
+		Timeout:    timeout,
+	}, nil
+}
+
+// parseInvitation parses the JSON invitation, or the invitation URL.
+func parseInvitation(value string) (*outofband.Invitation, error) {
+	invitationBytes := []byte(value)
+
+	if !strings.HasPrefix(strings.TrimSpace(value), "{") {
+		u, err := url.Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("parse invitation url : %w", err)
+		}
+
+		encoded := u.Query().Get(oobQueryParam)
+		if encoded == "" {
+			return nil, fmt.Errorf("no %s query parameter in the invitation url", oobQueryParam)
+		}
+
+		invitationBytes, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+		if err != nil {
+			return nil, fmt.Errorf("decode invitation : %w", err)
+		}
+	}
+
+	invitation := &outofband.Invitation{}
+
+	if err := json.Unmarshal(invitationBytes, invitation); err != nil {
+		return nil, fmt.Errorf("parse invitation : %w", err)
+	}
+
+	if len(invitation.Services) == 0 {
+		return nil, errors.New("no service in the invitation")
+	}
+
+	return invitation, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+const upstreamInvitation = `{"@id":"inv-1","@type":"https://didcomm.org/out-of-band/1.0/invitation",` +
+	`"label":"mediator","services":["did:example:mediator"]}`
+
+func TestGetUpstreamConfig(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createUpstreamFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("not configured", func(t *testing.T) {
+		config, err := getUpstreamConfig(newCmd())
+		require.NoError(t, err)
+		require.Nil(t, config)
+	})
+
+	t.Run("json invitation", func(t *testing.T) {
+		config, err := getUpstreamConfig(newCmd("--"+upstreamInvitationFlagName, upstreamInvitation,
+			"--"+upstreamLabelFlagName, "edge-router", "--"+upstreamTimeoutFlagName, "1m"))
+		require.NoError(t, err)
+		require.Equal(t, "inv-1", config.Invitation.ID)
+		require.Equal(t, []interface{}{"did:example:mediator"}, config.Invitation.Services)
+		require.Equal(t, "edge-router", config.Label)
+		require.Equal(t, time.Minute, config.Timeout)
+	})
+
+	t.Run("invitation url", func(t *testing.T) {
+		for _, encoding := range []*base64.Encoding{base64.URLEncoding, base64.RawURLEncoding} {
+			config, err := getUpstreamConfig(newCmd("--"+upstreamInvitationFlagName,
+				"https://mediator.example.com/invite?oob="+encoding.EncodeToString([]byte(upstreamInvitation))))
+			require.NoError(t, err)
+			require.Equal(t, "inv-1", config.Invitation.ID)
+			require.Empty(t, config.Label)
+			require.Zero(t, config.Timeout)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for value, msg := range map[string]string{
+			"https://mediator.example.com/invite":          "no oob query parameter",
+			"https://mediator.example.com/%zz?oob=e30":     "parse invitation url",
+			"https://mediator.example.com/invite?oob=$$$$": "decode invitation",
+			"{":                             "parse invitation",
+			`{"@id":"inv-1","services":[]}`: "no service in the invitation",
+		} {
+			_, err := getUpstreamConfig(newCmd("--"+upstreamInvitationFlagName, value))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid upstream-mediator-invitation")
+			require.Contains(t, err.Error(), msg)
+		}
+
+		_, err := getUpstreamConfig(newCmd("--"+upstreamInvitationFlagName, upstreamInvitation,
+			"--"+upstreamTimeoutFlagName, "soon"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid upstream-mediator-timeout")
+	})
+}
@@ -0,0 +1,54 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/useragent"
+)
+
+// User agent capture config.
+const (
+	userAgentTenantsFlagName  = "user-agent-capture-tenant"
+	userAgentTenantsFlagUsage = "ID of a tenant opted in the capture of the user agent and app version its wallets" +
+		" report at registration, returned with the wallets to debug the interop issues of specific wallet versions." +
+		" Use " + useragent.AllTenants + " to opt all the tenants in, and the wallets registered without tenant." +
+		" Nothing is captured unless set. This flag can be repeated, allowing for multiple tenants." +
+		" Alternatively, this can be set with the following environment variable (in CSV format): " +
+		userAgentTenantsEnvKey
+	userAgentTenantsEnvKey = "HUB_ROUTER_USER_AGENT_CAPTURE_TENANTS"
+)
+
+func createUserAgentFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringArrayP(userAgentTenantsFlagName, "", []string{}, userAgentTenantsFlagUsage)
+}
+
+// getUserAgentTenants returns the tenants opted in the capture of the user agents, nil if none.
+func getUserAgentTenants(cmd *cobra.Command) ([]string, error) {
+	tenants, err := cmdutils.GetUserSetVarFromArrayString(cmd, userAgentTenantsFlagName, userAgentTenantsEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+
+	for _, t := range tenants {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			return nil, fmt.Errorf("invalid %s : empty tenant ID", userAgentTenantsFlagName)
+		}
+
+		ids = append(ids, t)
+	}
+
+	return ids, nil
+}
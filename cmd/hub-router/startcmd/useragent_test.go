@@ -0,0 +1,42 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetUserAgentTenants(t *testing.T) {
+	newCmd := func(args ...string) *cobra.Command {
+		startCmd := &cobra.Command{}
+		createFlags(startCmd)
+		require.NoError(t, startCmd.ParseFlags(args))
+
+		return startCmd
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		tenants, err := getUserAgentTenants(newCmd())
+		require.NoError(t, err)
+		require.Empty(t, tenants)
+	})
+
+	t.Run("tenants", func(t *testing.T) {
+		params := &hubRouterParameters{}
+		require.NoError(t, getTenantParams(newCmd(
+			"--"+userAgentTenantsFlagName, "acme", "--"+userAgentTenantsFlagName, " globex"), params))
+		require.Equal(t, []string{"acme", "globex"}, params.userAgentTenants)
+	})
+
+	t.Run("invalid tenant", func(t *testing.T) {
+		_, err := getUserAgentTenants(newCmd("--"+userAgentTenantsFlagName, " "))
+		require.EqualError(t, err, "invalid "+userAgentTenantsFlagName+" : empty tenant ID")
+	})
+}
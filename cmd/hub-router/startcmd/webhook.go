@@ -0,0 +1,258 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+	cmdutils "github.com/trustbloc/edge-core/pkg/utils/cmd"
+
+	"github.com/trustbloc/hub-router/pkg/restapi/operation"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+// Webhook config.
+const (
+	webhookURLFlagName  = "webhook-url"
+	webhookURLFlagUsage = "URL to send event notifications (eg: wallet presence changes) to." +
+		" This flag can be repeated, allowing for multiple webhooks." +
+		" Alternatively, this can be set with the following environment variable (in CSV format): " + webhookURLEnvKey
+	webhookURLEnvKey = "HUB_ROUTER_WEBHOOK_URL"
+
+	webhookTLSCertFlagName  = "webhook-tls-cert"
+	webhookTLSCertFlagUsage = "Client certificate path, for the webhooks requiring mutual TLS." +
+		" Alternatively, this can be set with the following environment variable: " + webhookTLSCertEnvKey
+	webhookTLSCertEnvKey = "HUB_ROUTER_WEBHOOK_TLS_CERT"
+
+	webhookTLSKeyFlagName  = "webhook-tls-key"
+	webhookTLSKeyFlagUsage = "Client certificate private key path, for the webhooks requiring mutual TLS." +
+		" Alternatively, this can be set with the following environment variable: " + webhookTLSKeyEnvKey
+	webhookTLSKeyEnvKey = "HUB_ROUTER_WEBHOOK_TLS_KEY"
+
+	webhookTokenURLFlagName  = "webhook-oauth2-token-url"
+	webhookTokenURLFlagUsage = "OAuth2 token endpoint URL : the webhook notifications are sent with an access token" +
+		" acquired with the client credentials grant. Disabled if not set." +
+		" Alternatively, this can be set with the following environment variable: " + webhookTokenURLEnvKey
+	webhookTokenURLEnvKey = "HUB_ROUTER_WEBHOOK_OAUTH2_TOKEN_URL"
+
+	webhookClientIDFlagName  = "webhook-oauth2-client-id"
+	webhookClientIDFlagUsage = "OAuth2 client ID, required with the token URL." +
+		" Alternatively, this can be set with the following environment variable: " + webhookClientIDEnvKey
+	webhookClientIDEnvKey = "HUB_ROUTER_WEBHOOK_OAUTH2_CLIENT_ID"
+
+	webhookClientSecretFlagName  = "webhook-oauth2-client-secret"
+	webhookClientSecretFlagUsage = "OAuth2 client secret." +
+		" Alternatively, this can be set with the following environment variable: " + webhookClientSecretEnvKey
+	webhookClientSecretEnvKey = "HUB_ROUTER_WEBHOOK_OAUTH2_CLIENT_SECRET"
+
+	webhookScopesFlagName  = "webhook-oauth2-scopes"
+	webhookScopesFlagUsage = "OAuth2 scopes requested with the access token." +
+		" This flag can be repeated, allowing for multiple scopes." +
+		" Alternatively, this can be set with the following environment variable (in CSV format): " +
+		webhookScopesEnvKey
+	webhookScopesEnvKey = "HUB_ROUTER_WEBHOOK_OAUTH2_SCOPES"
+
+	webhookSchemaVersionFlagName  = "webhook-schema-version"
+	webhookSchemaVersionFlagUsage = "Schema version of the webhook messages : set the previous version to keep the" +
+		" consumers not supporting the current one working. Possible values [1] [2]. Defaults to 2 if not set." +
+		" Alternatively, this can be set with the following environment variable: " + webhookSchemaVersionEnvKey
+	webhookSchemaVersionEnvKey = "HUB_ROUTER_WEBHOOK_SCHEMA_VERSION"
+)
+
+// Wallet presence config.
+const (
+	presenceTimeoutFlagName  = "presence-timeout"
+	presenceTimeoutFlagUsage = "Inactivity period after which a mediated wallet is considered offline, eg: 10m." +
+		" Defaults to 5m." +
+		" Alternatively, this can be set with the following environment variable: " + presenceTimeoutEnvKey
+	presenceTimeoutEnvKey = "HUB_ROUTER_PRESENCE_TIMEOUT"
+)
+
+type webhookParameters struct {
+	urls               []string
+	presenceTimeout    time.Duration
+	tlsCertPath        string
+	tlsKeyPath         string
+	oauth2             *webhook.ClientCredentials
+	schemaVersion      string
+	sinks              *eventSinkParameters
+	redaction          *webhook.Redaction
+	sinkRedaction      *webhook.Redaction
+	connectionWebhooks bool
+}
+
+func createWebhookFlags(startCmd *cobra.Command) {
+	startCmd.Flags().StringArrayP(webhookURLFlagName, "", []string{}, webhookURLFlagUsage)
+	startCmd.Flags().StringP(presenceTimeoutFlagName, "", "", presenceTimeoutFlagUsage)
+	startCmd.Flags().StringP(webhookTLSCertFlagName, "", "", webhookTLSCertFlagUsage)
+	startCmd.Flags().StringP(webhookTLSKeyFlagName, "", "", webhookTLSKeyFlagUsage)
+	startCmd.Flags().StringP(webhookTokenURLFlagName, "", "", webhookTokenURLFlagUsage)
+	startCmd.Flags().StringP(webhookClientIDFlagName, "", "", webhookClientIDFlagUsage)
+	startCmd.Flags().StringP(webhookClientSecretFlagName, "", "", webhookClientSecretFlagUsage)
+	startCmd.Flags().StringArrayP(webhookScopesFlagName, "", []string{}, webhookScopesFlagUsage)
+	startCmd.Flags().StringP(webhookSchemaVersionFlagName, "", "", webhookSchemaVersionFlagUsage)
+
+	// the cloudevents format also applies to the metering records
+	startCmd.Flags().StringP(cloudEventsModeFlagName, "", "", cloudEventsModeFlagUsage)
+	startCmd.Flags().StringP(cloudEventsSourceFlagName, "", "", cloudEventsSourceFlagUsage)
+
+	createEventSinkFlags(startCmd)
+	createRedactionFlags(startCmd)
+	createConnectionWebhookFlags(startCmd)
+}
+
+func getWebhookParams(cmd *cobra.Command) (*webhookParameters, error) {
+	urls, err := cmdutils.GetUserSetVarFromArrayString(cmd, webhookURLFlagName, webhookURLEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := cmdutils.GetUserSetVarFromString(cmd, presenceTimeoutFlagName, presenceTimeoutEnvKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &webhookParameters{
+		urls:          urls,
+		schemaVersion: webhook.CurrentSchemaVersion,
+	}
+
+	if timeout != "" {
+		params.presenceTimeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse presence timeout %s: %w", timeout, err)
+		}
+	}
+
+	version := cmdutils.GetUserSetOptionalVarFromString(cmd, webhookSchemaVersionFlagName, webhookSchemaVersionEnvKey)
+	if version != "" {
+		if _, err = webhook.Schema(version); err != nil {
+			return nil, fmt.Errorf("invalid %s : %w", webhookSchemaVersionFlagName, err)
+		}
+
+		params.schemaVersion = version
+	}
+
+	err = getWebhookAuthParams(cmd, params)
+	if err != nil {
+		return nil, err
+	}
+
+	params.sinks, err = getEventSinkParams(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	err = getRedactionParams(cmd, params)
+	if err != nil {
+		return nil, err
+	}
+
+	err = getConnectionWebhookParams(cmd, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return params, nil
+}
+
+func getWebhookAuthParams(cmd *cobra.Command, params *webhookParameters) error {
+	params.tlsCertPath = cmdutils.GetUserSetOptionalVarFromString(cmd, webhookTLSCertFlagName, webhookTLSCertEnvKey)
+	params.tlsKeyPath = cmdutils.GetUserSetOptionalVarFromString(cmd, webhookTLSKeyFlagName, webhookTLSKeyEnvKey)
+
+	if (params.tlsCertPath == "") != (params.tlsKeyPath == "") {
+		return fmt.Errorf("%s and %s must be set together", webhookTLSCertFlagName, webhookTLSKeyFlagName)
+	}
+
+	tokenURL := cmdutils.GetUserSetOptionalVarFromString(cmd, webhookTokenURLFlagName, webhookTokenURLEnvKey)
+	if tokenURL == "" {
+		return nil
+	}
+
+	clientID := cmdutils.GetUserSetOptionalVarFromString(cmd, webhookClientIDFlagName, webhookClientIDEnvKey)
+	if clientID == "" {
+		return fmt.Errorf("%s requires %s", webhookTokenURLFlagName, webhookClientIDFlagName)
+	}
+
+	scopes, err := cmdutils.GetUserSetVarFromArrayString(cmd, webhookScopesFlagName, webhookScopesEnvKey, true)
+	if err != nil {
+		return err
+	}
+
+	params.oauth2 = &webhook.ClientCredentials{
+		TokenURL:     tokenURL,
+		ClientID:     clientID,
+		ClientSecret: cmdutils.GetUserSetOptionalVarFromString(cmd, webhookClientSecretFlagName, webhookClientSecretEnvKey),
+		Scopes:       scopes,
+	}
+
+	return nil
+}
+
+func newWebhook(params *webhookParameters, ce *cloudEventsParameters, tlsConfig *tls.Config,
+	s *operation.Storage) (*webhook.Notifier, error) {
+	if params == nil || (len(params.urls) == 0 && !params.sinks.enabled() && !params.connectionWebhooks) {
+		return nil, nil
+	}
+
+	sinks, err := newEventSinks(params.sinks, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	connWebhooks, err := connectionWebhookOption(params, s, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.tlsCertPath != "" {
+		cert, certErr := tls.LoadX509KeyPair(params.tlsCertPath, params.tlsKeyPath)
+		if certErr != nil {
+			return nil, fmt.Errorf("load webhook client certificate : %w", certErr)
+		}
+
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	var transport http.RoundTripper = &http.Transport{TLSClientConfig: tlsConfig}
+
+	if params.oauth2 != nil {
+		transport = webhook.NewOAuth2Transport(params.oauth2, transport)
+	}
+
+	opts := []webhook.Option{
+		webhook.WithSchemaVersion(params.schemaVersion), webhook.WithSinks(sinks...),
+		webhook.WithRedaction(params.redaction),
+	}
+
+	if params.sinkRedaction != nil {
+		opts = append(opts, webhook.WithSinkRedaction(params.sinkRedaction))
+	}
+
+	if connWebhooks != nil {
+		opts = append(opts, connWebhooks)
+	}
+
+	if ce != nil && ce.mode != "" {
+		opts = append(opts, webhook.WithCloudEvents(ce.mode, ce.source))
+	}
+
+	return webhook.New(params.urls, &http.Client{Transport: transport}, opts...), nil
+}
+
+func presenceTimeout(params *webhookParameters) time.Duration {
+	if params == nil {
+		return 0
+	}
+
+	return params.presenceTimeout
+}
@@ -0,0 +1,138 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package startcmd
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestGetWebhookParams(t *testing.T) {
+	t.Run("with webhooks", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + webhookURLFlagName, "https://webhook1.example.com",
+			"--" + webhookURLFlagName, "https://webhook2.example.com",
+			"--" + presenceTimeoutFlagName, "10m",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid presence timeout", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + presenceTimeoutFlagName, "invalid",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "failed to parse presence timeout")
+	})
+
+	t.Run("with webhook authentication", func(t *testing.T) {
+		startCmd := GetStartCmd(&mockServer{})
+
+		args := []string{
+			"--" + hostURLFlagName, "localhost:8080",
+			"--" + didCommHTTPHostFlagName, randomURL(t),
+			"--" + didCommWSHostFlagName, randomURL(t),
+			"--" + datasourcePersistentFlagName, "mem://tests",
+			"--" + datasourceTransientFlagName, "mem://tests",
+			"--" + webhookURLFlagName, "https://webhook1.example.com",
+			"--" + webhookTokenURLFlagName, "https://auth.example.com/token",
+			"--" + webhookClientIDFlagName, "hub-router",
+			"--" + webhookClientSecretFlagName, "secret",
+			"--" + webhookScopesFlagName, "events",
+			"--" + webhookSchemaVersionFlagName, "1",
+		}
+		startCmd.SetArgs(args)
+
+		err := startCmd.Execute()
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid webhook config", func(t *testing.T) {
+		for _, tc := range []struct {
+			args []string
+			err  string
+		}{
+			{
+				args: []string{"--" + webhookTLSCertFlagName, "cert.pem"},
+				err:  "webhook-tls-cert and webhook-tls-key must be set together",
+			},
+			{
+				args: []string{"--" + webhookSchemaVersionFlagName, "0"},
+				err:  "invalid webhook-schema-version : unknown schema version : 0",
+			},
+			{
+				args: []string{"--" + webhookTokenURLFlagName, "https://auth.example.com/token"},
+				err:  "webhook-oauth2-token-url requires webhook-oauth2-client-id",
+			},
+		} {
+			startCmd := GetStartCmd(&mockServer{})
+
+			startCmd.SetArgs(append([]string{
+				"--" + hostURLFlagName, "localhost:8080",
+				"--" + didCommHTTPHostFlagName, randomURL(t),
+				"--" + didCommWSHostFlagName, randomURL(t),
+				"--" + datasourcePersistentFlagName, "mem://tests",
+				"--" + datasourceTransientFlagName, "mem://tests",
+			}, tc.args...))
+
+			err := startCmd.Execute()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		}
+	})
+}
+
+func TestNewWebhook(t *testing.T) {
+	t.Run("no webhook", func(t *testing.T) {
+		n, err := newWebhook(&webhookParameters{}, nil, &tls.Config{MinVersion: tls.VersionTLS12}, nil)
+		require.NoError(t, err)
+		require.Nil(t, n)
+	})
+
+	t.Run("with oauth2", func(t *testing.T) {
+		n, err := newWebhook(&webhookParameters{
+			urls:   []string{"https://webhook.example.com"},
+			oauth2: &webhook.ClientCredentials{TokenURL: "https://auth.example.com/token", ClientID: "hub-router"},
+		}, &cloudEventsParameters{mode: "binary", source: "/hub-router"}, &tls.Config{MinVersion: tls.VersionTLS12}, nil)
+		require.NoError(t, err)
+		require.NotNil(t, n)
+	})
+
+	t.Run("invalid client certificate", func(t *testing.T) {
+		_, err := newWebhook(&webhookParameters{
+			urls:        []string{"https://webhook.example.com"},
+			tlsCertPath: "invalid-cert.pem",
+			tlsKeyPath:  "invalid-key.pem",
+		}, nil, &tls.Config{MinVersion: tls.VersionTLS12}, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "load webhook client certificate")
+	})
+}
@@ -7,16 +7,26 @@ module github.com/trustbloc/hub-router
 go 1.16
 
 require (
+	filippo.io/age v1.0.0
+	github.com/Azure/azure-sdk-for-go/sdk/azcore v1.6.0
+	github.com/Azure/azure-sdk-for-go/sdk/azidentity v1.3.0
+	github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus v1.4.0
+	github.com/aws/aws-sdk-go v1.36.29
+	github.com/btcsuite/btcutil v1.0.1
 	github.com/cenkalti/backoff/v4 v4.1.0 // indirect
-	github.com/google/uuid v1.2.0
+	github.com/google/uuid v1.3.0
+	github.com/gorilla/mux v1.7.4
 	github.com/hyperledger/aries-framework-go v0.1.7-0.20210526123422-eec182deab9a
 	github.com/hyperledger/aries-framework-go/component/storageutil v0.0.0-20210520055214-ae429bb89bf7
 	github.com/hyperledger/aries-framework-go/spi v0.0.0-20210520055214-ae429bb89bf7
 	github.com/hyperledger/aries-framework-go/test/component v0.0.0-20210422144621-1355c6f90b44 // indirect
+	github.com/klauspost/compress v1.10.3
 	github.com/niemeyer/pretty v0.0.0-20200227124842-a10e7caefd8e // indirect
-	github.com/stretchr/testify v1.7.0
+	github.com/piprate/json-gold v0.4.0
+	github.com/stretchr/testify v1.7.1
 	github.com/trustbloc/edge-core v0.1.7-0.20210527163745-994ae929f957
-	golang.org/x/sys v0.0.0-20210420072515-93ed5bcd2bfe // indirect
+	github.com/xeipuuv/gojsonschema v1.2.0
+	golang.org/x/oauth2 v0.0.0-20200902213428-5d25da1a8d43
 	gopkg.in/check.v1 v1.0.0-20200227125254-8fa46927fb4f // indirect
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+	nhooyr.io/websocket v1.8.7
 )
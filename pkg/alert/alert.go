@@ -0,0 +1,82 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package alert
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Alert kinds.
+const (
+	// QueueWatermark is raised when the queue depth of a wallet, or of the router, crosses its high-watermark.
+	QueueWatermark = "queue-watermark"
+	// StorageFailure is raised when the storage of the router fails to be written or read.
+	StorageFailure = "storage-failure"
+	// SLOBreach is raised when the delivery SLO of a digest period is not met.
+	SLOBreach = "slo-breach"
+)
+
+// Alert is a critical condition of the router, raised and then resolved (except the SLO breaches, over periods).
+type Alert struct {
+	Kind string `json:"kind"`
+	// Subject the alert is raised for : the connection, the storage or the digest schedule. An alert is raised once
+	// per kind and subject until resolved.
+	Subject  string    `json:"subject"`
+	Resolved bool      `json:"resolved"`
+	Time     time.Time `json:"time"`
+	Summary  string    `json:"summary"`
+	// Detail of the condition : the queue watermark alert, the failing storage or the digest delivery.
+	Detail interface{} `json:"detail,omitempty"`
+}
+
+// DedupKey returns the key identifying the alert of its kind and subject, raised and then resolved, eg: to open and
+// resolve the same incident.
+func (a *Alert) DedupKey() string {
+	return "hub-router/" + a.Kind + "/" + a.Subject
+}
+
+// Channel sends the alerts, eg: emails them.
+type Channel interface {
+	Send(a *Alert) error
+}
+
+// Alerter sends the alerts to the channels.
+type Alerter struct {
+	channels []Channel
+}
+
+// New returns a new Alerter sending to the channels, nil if there are none.
+func New(channels ...Channel) *Alerter {
+	if len(channels) == 0 {
+		return nil
+	}
+
+	return &Alerter{channels: channels}
+}
+
+// Send sends the alert to all the channels.
+func (a *Alerter) Send(alert *Alert) error {
+	if a == nil {
+		return nil
+	}
+
+	var errs []string
+
+	for _, c := range a.channels {
+		if err := c.Send(alert); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("send %s alert : %s", alert.Kind, strings.Join(errs, "; "))
+	}
+
+	return nil
+}
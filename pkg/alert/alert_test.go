@@ -0,0 +1,47 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package alert
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockChannel struct {
+	alerts []*Alert
+	err    error
+}
+
+func (m *mockChannel) Send(a *Alert) error {
+	m.alerts = append(m.alerts, a)
+
+	return m.err
+}
+
+func TestAlerter(t *testing.T) {
+	t.Run("no channel", func(t *testing.T) {
+		a := New()
+		require.Nil(t, a)
+		require.NoError(t, a.Send(&Alert{Kind: SLOBreach}))
+	})
+
+	t.Run("sent to all the channels", func(t *testing.T) {
+		failing := &mockChannel{err: errors.New("channel down")}
+		working := &mockChannel{}
+
+		err := New(failing, working).Send(&Alert{Kind: QueueWatermark})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "send queue-watermark alert : channel down")
+		require.Len(t, failing.alerts, 1)
+		require.Len(t, working.alerts, 1)
+
+		require.NoError(t, New(working).Send(&Alert{Kind: QueueWatermark}))
+		require.Len(t, working.alerts, 2)
+	})
+}
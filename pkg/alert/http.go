@@ -0,0 +1,62 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const httpTimeout = 10 * time.Second
+
+// HTTPClient posts the alerts to the incident management services.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// post posts the JSON request to the URL, with the headers.
+func post(client HTTPClient, url string, headers map[string]string, request interface{}) error {
+	reqBytes, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("marshal alert request : %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), httpTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBytes))
+	if err != nil {
+		return fmt.Errorf("create alert request : %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post alert %s : %w", url, err)
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			logger.Warnf("failed to close alert response body : %s", errClose)
+		}
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("post alert %s : unexpected status %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultOpsgenieURL is the URL of the Opsgenie Alert API, eg: https://api.eu.opsgenie.com for the EU instance.
+const DefaultOpsgenieURL = "https://api.opsgenie.com"
+
+const (
+	// opsgenieMessageLimit is the maximum length of the Opsgenie alert messages.
+	opsgenieMessageLimit = 130
+	opsgeniePriority     = "P1"
+)
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Details     map[string]string `json:"details"`
+	Tags        []string          `json:"tags"`
+	Source      string            `json:"source"`
+	Priority    string            `json:"priority"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note"`
+}
+
+// OpsgenieChannel creates an Opsgenie alert for each alert raised, and closes it once the alert is resolved, with the
+// Alert API. The Opsgenie alerts are deduplicated by alert kind and subject (their alias).
+type OpsgenieChannel struct {
+	url    string
+	apiKey string
+	source string
+	client HTTPClient
+}
+
+// NewOpsgenieChannel returns a new OpsgenieChannel creating the alerts with the API key of an API integration, as the
+// source (eg: the router host name). The URL defaults to DefaultOpsgenieURL if empty.
+func NewOpsgenieChannel(apiURL, apiKey, source string, client HTTPClient) *OpsgenieChannel {
+	if apiURL == "" {
+		apiURL = DefaultOpsgenieURL
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &OpsgenieChannel{url: strings.TrimSuffix(apiURL, "/"), apiKey: apiKey, source: source, client: client}
+}
+
+// Send creates or closes the Opsgenie alert of the alert.
+func (c *OpsgenieChannel) Send(a *Alert) error {
+	headers := map[string]string{"Authorization": "GenieKey " + c.apiKey}
+
+	if a.Resolved {
+		return post(c.client, c.url+"/v2/alerts/"+url.PathEscape(a.DedupKey())+"/close?identifierType=alias",
+			headers, &opsgenieClose{Source: c.source, Note: a.Summary})
+	}
+
+	message := a.Summary
+	if runes := []rune(message); len(runes) > opsgenieMessageLimit {
+		message = string(runes[:opsgenieMessageLimit-len("...")]) + "..."
+	}
+
+	req := &opsgenieAlert{
+		Message:     message,
+		Alias:       a.DedupKey(),
+		Description: a.Summary,
+		Details:     map[string]string{"kind": a.Kind, "subject": a.Subject},
+		Tags:        []string{"hub-router", a.Kind},
+		Source:      c.source,
+		Priority:    opsgeniePriority,
+	}
+
+	if a.Detail != nil {
+		detail, err := json.MarshalIndent(a.Detail, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		req.Description += "\n\n" + string(detail)
+	}
+
+	return post(c.client, c.url+"/v2/alerts", headers, req)
+}
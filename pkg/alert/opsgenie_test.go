@@ -0,0 +1,97 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package alert
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpsgenieChannel(t *testing.T) {
+	a := &Alert{
+		Kind:    StorageFailure,
+		Subject: "router",
+		Time:    time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC),
+		Summary: "router storage failing : write : connection refused",
+		Detail:  &StorageDetail{Storage: "router", Error: "write : connection refused"},
+	}
+
+	type request struct {
+		path string
+		auth string
+		body map[string]interface{}
+	}
+
+	t.Run("alert created and closed", func(t *testing.T) {
+		var requests []*request
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := map[string]interface{}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+			requests = append(requests, &request{path: r.URL.String(), auth: r.Header.Get("Authorization"), body: body})
+
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer server.Close()
+
+		c := NewOpsgenieChannel(server.URL+"/", "api-key", "router-1", nil)
+
+		require.NoError(t, c.Send(a))
+		require.NoError(t, c.Send(&Alert{
+			Kind: StorageFailure, Subject: "router", Resolved: true, Summary: "router storage recovered",
+		}))
+
+		require.Len(t, requests, 2)
+		require.Equal(t, "/v2/alerts", requests[0].path)
+		require.Equal(t, "GenieKey api-key", requests[0].auth)
+		require.Equal(t, map[string]interface{}{
+			"message": a.Summary,
+			"alias":   "hub-router/storage-failure/router",
+			"description": a.Summary + "\n\n{\n  \"storage\": \"router\",\n" +
+				"  \"error\": \"write : connection refused\"\n}",
+			"details":  map[string]interface{}{"kind": "storage-failure", "subject": "router"},
+			"tags":     []interface{}{"hub-router", "storage-failure"},
+			"source":   "router-1",
+			"priority": "P1",
+		}, requests[0].body)
+
+		require.Equal(t, "/v2/alerts/hub-router%2Fstorage-failure%2Frouter/close?identifierType=alias", requests[1].path)
+		require.Equal(t, map[string]interface{}{"source": "router-1", "note": "router storage recovered"},
+			requests[1].body)
+	})
+
+	t.Run("long summary truncated", func(t *testing.T) {
+		var message string
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := &opsgenieAlert{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(body))
+
+			message = body.Message
+		}))
+		defer server.Close()
+
+		require.NoError(t, NewOpsgenieChannel(server.URL, "api-key", "router-1", nil).Send(&Alert{
+			Kind: SLOBreach, Summary: strings.Repeat("é", 200),
+		}))
+		require.Equal(t, strings.Repeat("é", 127)+"...", message)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		require.Equal(t, DefaultOpsgenieURL, NewOpsgenieChannel("", "api-key", "router-1", nil).url)
+
+		err := NewOpsgenieChannel("", "api-key", "router-1", nil).Send(&Alert{Detail: func() {}})
+		require.Error(t, err)
+	})
+}
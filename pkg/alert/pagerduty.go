@@ -0,0 +1,81 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package alert
+
+import (
+	"net/http"
+	"time"
+)
+
+// DefaultPagerDutyURL is the URL of the PagerDuty Events API v2.
+const DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// PagerDuty event actions.
+const (
+	pagerDutyTrigger = "trigger"
+	pagerDutyResolve = "resolve"
+)
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string      `json:"summary"`
+	Source        string      `json:"source"`
+	Severity      string      `json:"severity"`
+	Timestamp     string      `json:"timestamp"`
+	Component     string      `json:"component"`
+	Class         string      `json:"class"`
+	CustomDetails interface{} `json:"custom_details,omitempty"`
+}
+
+// PagerDutyChannel triggers a PagerDuty incident for each alert raised, and resolves it once the alert is, with the
+// Events API v2. The incidents are deduplicated by alert kind and subject.
+type PagerDutyChannel struct {
+	url        string
+	routingKey string
+	source     string
+	client     HTTPClient
+}
+
+// NewPagerDutyChannel returns a new PagerDutyChannel sending the events to the integration of the routing key, as
+// the source (eg: the router host name). The URL defaults to DefaultPagerDutyURL if empty.
+func NewPagerDutyChannel(url, routingKey, source string, client HTTPClient) *PagerDutyChannel {
+	if url == "" {
+		url = DefaultPagerDutyURL
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &PagerDutyChannel{url: url, routingKey: routingKey, source: source, client: client}
+}
+
+// Send triggers or resolves the incident of the alert.
+func (c *PagerDutyChannel) Send(a *Alert) error {
+	event := &pagerDutyEvent{RoutingKey: c.routingKey, EventAction: pagerDutyResolve, DedupKey: a.DedupKey()}
+
+	if !a.Resolved {
+		event.EventAction = pagerDutyTrigger
+		event.Payload = &pagerDutyPayload{
+			Summary:       a.Summary,
+			Source:        c.source,
+			Severity:      "critical",
+			Timestamp:     a.Time.UTC().Format(time.RFC3339),
+			Component:     "hub-router",
+			Class:         a.Kind,
+			CustomDetails: a.Detail,
+		}
+	}
+
+	return post(c.client, c.url, nil, event)
+}
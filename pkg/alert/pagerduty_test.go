@@ -0,0 +1,105 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type mockHTTPClient struct {
+	err error
+}
+
+func (m *mockHTTPClient) Do(*http.Request) (*http.Response, error) {
+	return nil, m.err
+}
+
+func TestPagerDutyChannel(t *testing.T) {
+	a := &Alert{
+		Kind:    QueueWatermark,
+		Subject: "conn-1",
+		Time:    time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC),
+		Summary: "queue of connection conn-1 depth 120 above watermark 100",
+		Detail:  map[string]int{"depth": 120},
+	}
+
+	t.Run("incident triggered and resolved", func(t *testing.T) {
+		var events []map[string]interface{}
+
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			event := map[string]interface{}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+			require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+
+			events = append(events, event)
+
+			w.WriteHeader(http.StatusAccepted)
+		}))
+		defer server.Close()
+
+		c := NewPagerDutyChannel(server.URL, "routing-key", "router-1", nil)
+
+		require.NoError(t, c.Send(a))
+		require.NoError(t, c.Send(&Alert{Kind: QueueWatermark, Subject: "conn-1", Resolved: true}))
+
+		require.Len(t, events, 2)
+		require.Equal(t, map[string]interface{}{
+			"routing_key":  "routing-key",
+			"event_action": "trigger",
+			"dedup_key":    "hub-router/queue-watermark/conn-1",
+			"payload": map[string]interface{}{
+				"summary":        a.Summary,
+				"source":         "router-1",
+				"severity":       "critical",
+				"timestamp":      "2021-06-01T10:30:00Z",
+				"component":      "hub-router",
+				"class":          "queue-watermark",
+				"custom_details": map[string]interface{}{"depth": float64(120)},
+			},
+		}, events[0])
+		require.Equal(t, map[string]interface{}{
+			"routing_key":  "routing-key",
+			"event_action": "resolve",
+			"dedup_key":    "hub-router/queue-watermark/conn-1",
+		}, events[1])
+	})
+
+	t.Run("default URL", func(t *testing.T) {
+		require.Equal(t, DefaultPagerDutyURL, NewPagerDutyChannel("", "routing-key", "router-1", nil).url)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		err := NewPagerDutyChannel(server.URL, "routing-key", "router-1", nil).Send(a)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unexpected status 400")
+
+		err = NewPagerDutyChannel(server.URL, "routing-key", "router-1",
+			&mockHTTPClient{err: errors.New("connection refused")}).Send(a)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "connection refused")
+
+		err = NewPagerDutyChannel(server.URL, "routing-key", "router-1", nil).Send(&Alert{Detail: func() {}})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "marshal alert request")
+
+		err = NewPagerDutyChannel("http://[::1]:namedport", "routing-key", "router-1", nil).Send(a)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create alert request")
+	})
+}
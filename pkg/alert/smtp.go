@@ -0,0 +1,123 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"text/template"
+
+	"github.com/trustbloc/hub-router/pkg/mail"
+)
+
+// Default templates of the alert emails.
+const (
+	DefaultSubjectTemplate = `[hub-router] {{if .Resolved}}RESOLVED{{else}}ALERT{{end}} {{.Kind}} : {{.Summary}}`
+	DefaultBodyTemplate    = `{{.Summary}}
+
+Kind : {{.Kind}}
+Status : {{if .Resolved}}resolved{{else}}raised{{end}}
+Time : {{.Time.Format "2006-01-02T15:04:05Z07:00"}}
+{{- with .Detail}}
+
+Detail :
+{{json .}}
+{{- end}}
+`
+)
+
+// Templates of the alert emails : text/template templates executed with the Alert. The detail of the alert can be
+// rendered with the json function, eg: {{json .Detail}}. The default templates are used if empty.
+type Templates struct {
+	Subject string
+	Body    string
+}
+
+// SMTPChannel emails the alerts as plain text.
+type SMTPChannel struct {
+	sender  *mail.Sender
+	subject *template.Template
+	body    *template.Template
+}
+
+// NewSMTPChannel returns a new SMTPChannel emailing the alerts with the sender, rendered with the templates.
+func NewSMTPChannel(sender *mail.Sender, templates *Templates) (*SMTPChannel, error) {
+	if templates == nil {
+		templates = &Templates{}
+	}
+
+	subject, body := templates.Subject, templates.Body
+
+	if subject == "" {
+		subject = DefaultSubjectTemplate
+	}
+
+	if body == "" {
+		body = DefaultBodyTemplate
+	}
+
+	c := &SMTPChannel{sender: sender}
+
+	var err error
+
+	c.subject, err = parse("subject", subject)
+	if err != nil {
+		return nil, err
+	}
+
+	c.body, err = parse("body", body)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Send emails the alert.
+func (c *SMTPChannel) Send(a *Alert) error {
+	subject, err := execute(c.subject, a)
+	if err != nil {
+		return err
+	}
+
+	body, err := execute(c.body, a)
+	if err != nil {
+		return err
+	}
+
+	if err = c.sender.Send(subject, a.Time, body); err != nil {
+		return fmt.Errorf("send alert email : %w", err)
+	}
+
+	return nil
+}
+
+func parse(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(template.FuncMap{"json": toJSON}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parse alert email %s template : %w", name, err)
+	}
+
+	return t, nil
+}
+
+func execute(t *template.Template, a *Alert) (string, error) {
+	buf := &bytes.Buffer{}
+
+	if err := t.Execute(buf, a); err != nil {
+		return "", fmt.Errorf("execute alert email %s template : %w", t.Name(), err)
+	}
+
+	return buf.String(), nil
+}
+
+func toJSON(v interface{}) (string, error) {
+	b, err := json.MarshalIndent(v, "", "  ")
+
+	return string(b), err
+}
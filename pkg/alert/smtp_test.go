@@ -0,0 +1,103 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package alert
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	mocksmtp "github.com/trustbloc/hub-router/pkg/internal/mock/smtp"
+	"github.com/trustbloc/hub-router/pkg/mail"
+)
+
+func TestSMTPChannel(t *testing.T) {
+	server, err := mocksmtp.NewServer(mocksmtp.Config{})
+	require.NoError(t, err)
+
+	defer server.Close() // nolint:errcheck // test
+
+	sender, err := mail.New("smtp://"+server.Addr, nil, "router@example.com", []string{"ops@example.com"})
+	require.NoError(t, err)
+
+	a := &Alert{
+		Kind:    StorageFailure,
+		Time:    time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC),
+		Summary: "router storage failing : write : connection refused",
+		Detail:  &StorageDetail{Storage: "router", Error: "write : connection refused"},
+	}
+
+	t.Run("default templates", func(t *testing.T) {
+		c, err := NewSMTPChannel(sender, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, c.Send(a))
+
+		messages := server.Messages()
+		require.Len(t, messages, 1)
+		require.Contains(t, messages[0].Data,
+			"Subject: [hub-router] ALERT storage-failure : router storage failing : write : connection refused\n")
+		require.Contains(t, messages[0].Data, "\n\nrouter storage failing : write : connection refused\n\n"+
+			"Kind : storage-failure\n"+
+			"Status : raised\n"+
+			"Time : 2021-06-01T10:30:00Z\n\n"+
+			"Detail :\n"+
+			"{\n  \"storage\": \"router\",\n  \"error\": \"write : connection refused\"\n}\n")
+	})
+
+	t.Run("custom templates", func(t *testing.T) {
+		c, err := NewSMTPChannel(sender, &Templates{
+			Subject: `{{if .Resolved}}OK{{else}}PAGE{{end}} {{.Detail.Storage}}`,
+			Body:    `{{.Kind}} since {{.Time.Format "15:04"}}`,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, c.Send(&Alert{Kind: StorageFailure, Resolved: true, Time: a.Time, Detail: a.Detail}))
+
+		messages := server.Messages()
+		require.Len(t, messages, 2)
+		require.Contains(t, messages[1].Data, "Subject: OK router\n")
+		require.Contains(t, messages[1].Data, "\n\nstorage-failure since 10:30")
+	})
+
+	t.Run("template errors", func(t *testing.T) {
+		_, err := NewSMTPChannel(sender, &Templates{Subject: "{{.Kind"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "parse alert email subject template")
+
+		_, err = NewSMTPChannel(sender, &Templates{Body: "{{end}}"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "parse alert email body template")
+
+		c, err := NewSMTPChannel(sender, &Templates{Subject: "{{.Missing}}"})
+		require.NoError(t, err)
+
+		err = c.Send(a)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "execute alert email subject template")
+
+		c, err = NewSMTPChannel(sender, &Templates{Body: "{{json .Detail.Storage.Name}}"})
+		require.NoError(t, err)
+
+		err = c.Send(a)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "execute alert email body template")
+	})
+
+	t.Run("send error", func(t *testing.T) {
+		unavailable, err := mail.New("smtp://localhost:0", nil, "router@example.com", []string{"ops@example.com"})
+		require.NoError(t, err)
+
+		c, err := NewSMTPChannel(unavailable, nil)
+		require.NoError(t, err)
+
+		err = c.Send(a)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "send alert email")
+	})
+}
@@ -0,0 +1,141 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package alert
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "alert"
+	probeKey  = "storage-probe"
+)
+
+var logger = log.New("hub-router/alert")
+
+// StorageDetail is the detail of the storage failure alerts.
+type StorageDetail struct {
+	Storage string `json:"storage"`
+	Error   string `json:"error,omitempty"`
+}
+
+// StorageMonitor probes the storage periodically : a storage failure alert is raised when a probe fails, and
+// resolved when the probes succeed again.
+type StorageMonitor struct {
+	stores   map[string]storage.Store
+	onAlert  func(*Alert)
+	mutex    sync.Mutex
+	failing  map[string]bool
+	now      func() time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewStorageMonitor returns a new StorageMonitor probing the storage providers, by name.
+func NewStorageMonitor(providers map[string]storage.Provider, onAlert func(*Alert)) (*StorageMonitor, error) {
+	m := &StorageMonitor{
+		stores:  make(map[string]storage.Store, len(providers)),
+		onAlert: onAlert,
+		failing: make(map[string]bool),
+		now:     time.Now,
+		stop:    make(chan struct{}),
+	}
+
+	for name, p := range providers {
+		store, err := p.OpenStore(storeName)
+		if err != nil {
+			return nil, fmt.Errorf("open %s alert store : %w", name, err)
+		}
+
+		m.stores[name] = store
+	}
+
+	return m, nil
+}
+
+// Check probes each storage, raising or resolving its storage failure alert on change.
+func (m *StorageMonitor) Check() {
+	names := make([]string, 0, len(m.stores))
+	for name := range m.stores {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		err := m.probe(m.stores[name])
+
+		m.mutex.Lock()
+		changed := m.failing[name] != (err != nil)
+		m.failing[name] = err != nil
+		m.mutex.Unlock()
+
+		if !changed {
+			continue
+		}
+
+		detail := &StorageDetail{Storage: name}
+		a := &Alert{Kind: StorageFailure, Subject: name, Time: m.now().UTC(), Detail: detail}
+
+		if err != nil {
+			logger.Errorf("%s storage failing : %s", name, err)
+
+			a.Summary = fmt.Sprintf("%s storage failing : %s", name, err)
+			detail.Error = err.Error()
+		} else {
+			logger.Infof("%s storage recovered", name)
+
+			a.Resolved = true
+			a.Summary = fmt.Sprintf("%s storage recovered", name)
+		}
+
+		m.onAlert(a)
+	}
+}
+
+// probe writes the probe record to the store and reads it back.
+func (m *StorageMonitor) probe(store storage.Store) error {
+	value := []byte(m.now().UTC().Format(time.RFC3339Nano))
+
+	if err := store.Put(probeKey, value); err != nil {
+		return fmt.Errorf("write : %w", err)
+	}
+
+	if _, err := store.Get(probeKey); err != nil {
+		return fmt.Errorf("read : %w", err)
+	}
+
+	return nil
+}
+
+// Start probes the storage at the interval until stopped.
+func (m *StorageMonitor) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				m.Check()
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the probes.
+func (m *StorageMonitor) Stop() {
+	m.stopOnce.Do(func() { close(m.stop) })
+}
@@ -0,0 +1,104 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package alert
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestStorageMonitor(t *testing.T) {
+	t.Run("failure raised and resolved", func(t *testing.T) {
+		store := &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry)}
+
+		var alerts []*Alert
+
+		m, err := NewStorageMonitor(map[string]storage.Provider{
+			"router": mockstore.NewCustomMockStoreProvider(store),
+			"aries":  mem.NewProvider(),
+		}, func(a *Alert) { alerts = append(alerts, a) })
+		require.NoError(t, err)
+
+		now := time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC)
+		m.now = func() time.Time { return now }
+
+		m.Check()
+		require.Empty(t, alerts)
+
+		store.ErrPut = errors.New("connection refused")
+
+		m.Check()
+		m.Check()
+		require.Len(t, alerts, 1)
+		require.Equal(t, &Alert{
+			Kind:    StorageFailure,
+			Subject: "router",
+			Time:    now,
+			Summary: "router storage failing : write : connection refused",
+			Detail:  &StorageDetail{Storage: "router", Error: "write : connection refused"},
+		}, alerts[0])
+
+		store.ErrPut = nil
+		store.ErrGet = errors.New("timeout")
+
+		m.Check()
+		require.Len(t, alerts, 1)
+
+		store.ErrGet = nil
+
+		m.Check()
+		require.Len(t, alerts, 2)
+		require.True(t, alerts[1].Resolved)
+		require.Equal(t, "router storage recovered", alerts[1].Summary)
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := NewStorageMonitor(map[string]storage.Provider{"router": p}, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open router alert store")
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		store := &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry), ErrPut: errors.New("put error")}
+
+		var (
+			mutex  sync.Mutex
+			alerts []*Alert
+		)
+
+		m, err := NewStorageMonitor(map[string]storage.Provider{"router": mockstore.NewCustomMockStoreProvider(store)},
+			func(a *Alert) {
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				alerts = append(alerts, a)
+			})
+		require.NoError(t, err)
+
+		m.Start(time.Millisecond)
+		defer m.Stop()
+
+		require.Eventually(t, func() bool {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			return len(alerts) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,217 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package anomaly detects the unusual routing patterns : the sudden spikes of the forwards routed to a wallet, the new
+// sender keys of the connections and the bursts of failures. The routed traffic is analyzed hourly by a scheduled job,
+// against the activity of the previous hours, and the anomalies are reported per hour.
+package anomaly
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Anomaly kinds.
+const (
+	// KindVolumeSpike is a sudden spike of the forwards routed to a wallet.
+	KindVolumeSpike = "volume-spike"
+	// KindNewSenderKey is an envelope sent with a new key to a recipient key that received envelopes from other keys.
+	KindNewSenderKey = "new-sender-key"
+	// KindFailureBurst is a burst of failures of the messages of a connection.
+	KindFailureBurst = "failure-burst"
+)
+
+// Defaults of the analysis.
+const (
+	DefaultSpikeFactor = 5
+	DefaultMinVolume   = 100
+	DefaultMinFailures = 10
+	DefaultBaseline    = 24 * time.Hour
+	DefaultRetention   = 30 * 24 * time.Hour
+)
+
+// Config of the analysis, the defaults apply to the zero values.
+type Config struct {
+	// SpikeFactor is the factor of the hourly average of the baseline the forwards or failures of an hour must exceed
+	// to be reported.
+	SpikeFactor float64
+	// MinVolume is the minimum number of forwards routed to a wallet in an hour to report a spike.
+	MinVolume int
+	// MinFailures is the minimum number of failures of a connection in an hour to report a burst.
+	MinFailures int
+	// Baseline is the period before the analyzed hour its activity is compared to.
+	Baseline time.Duration
+	// Retention is the period the reports are kept for.
+	Retention time.Duration
+}
+
+func (c *Config) withDefaults() *Config {
+	cfg := Config{}
+
+	if c != nil {
+		cfg = *c
+	}
+
+	if cfg.SpikeFactor <= 0 {
+		cfg.SpikeFactor = DefaultSpikeFactor
+	}
+
+	if cfg.MinVolume <= 0 {
+		cfg.MinVolume = DefaultMinVolume
+	}
+
+	if cfg.MinFailures <= 0 {
+		cfg.MinFailures = DefaultMinFailures
+	}
+
+	if cfg.Baseline < time.Hour {
+		cfg.Baseline = DefaultBaseline
+	}
+
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultRetention
+	}
+
+	return &cfg
+}
+
+// Anomaly is an unusual routing pattern of an hour.
+type Anomaly struct {
+	Kind string `json:"kind"`
+	// ConnectionID is the connection of the anomaly, if known.
+	ConnectionID string `json:"connectionID,omitempty"`
+	// WalletDID is the wallet the forwards of a volume spike are routed to.
+	WalletDID string `json:"walletDID,omitempty"`
+	// RecipientKey and SenderKey are the keys of a new sender key, base58 encoded.
+	RecipientKey string `json:"recipientKey,omitempty"`
+	SenderKey    string `json:"senderKey,omitempty"`
+	// Count is the number of forwards or failures of the hour.
+	Count int `json:"count,omitempty"`
+	// Baseline is the hourly average of the forwards or failures over the baseline period.
+	Baseline float64 `json:"baseline"`
+	// Time is the time a new sender key was seen at, the hour of the other anomalies.
+	Time   time.Time `json:"time"`
+	Detail string    `json:"detail"`
+}
+
+// Report is the analysis of the traffic routed in an hour.
+type Report struct {
+	Period      time.Time  `json:"period"`
+	GeneratedAt time.Time  `json:"generatedAt"`
+	Forwards    int        `json:"forwards"`
+	Failures    int        `json:"failures"`
+	Anomalies   []*Anomaly `json:"anomalies"`
+}
+
+// senderKey is a new sender key seen in an hour.
+type senderKey struct {
+	RecipientKey string    `json:"recipientKey"`
+	SenderKey    string    `json:"senderKey"`
+	Time         time.Time `json:"time"`
+}
+
+// activity is the traffic routed in an hour : the forwards per wallet DID, the failures per connection and the new
+// sender keys.
+type activity struct {
+	Period     time.Time      `json:"period"`
+	Forwards   map[string]int `json:"forwards"`
+	Failures   map[string]int `json:"failures"`
+	SenderKeys []*senderKey   `json:"senderKeys,omitempty"`
+}
+
+func newActivity(period time.Time) *activity {
+	return &activity{Period: period, Forwards: map[string]int{}, Failures: map[string]int{}}
+}
+
+// merge adds the activity recorded by another router instance.
+func (a *activity) merge(other *activity) {
+	for walletDID, n := range other.Forwards {
+		a.Forwards[walletDID] += n
+	}
+
+	for connID, n := range other.Failures {
+		a.Failures[connID] += n
+	}
+
+	a.SenderKeys = append(a.SenderKeys, other.SenderKeys...)
+}
+
+// analyze returns the report of the activity of the hour, compared to the activity of the baseline hours.
+func analyze(a *activity, baseline []*activity, cfg *Config) *Report {
+	hours := float64(cfg.Baseline / time.Hour)
+	forwards, failures := totals(baseline)
+
+	r := &Report{Period: a.Period, Anomalies: []*Anomaly{}}
+
+	for walletDID, n := range a.Forwards {
+		r.Forwards += n
+
+		avg := float64(forwards[walletDID]) / hours
+		if n >= cfg.MinVolume && float64(n) > cfg.SpikeFactor*avg {
+			r.Anomalies = append(r.Anomalies, &Anomaly{
+				Kind: KindVolumeSpike, WalletDID: walletDID, Count: n, Baseline: avg, Time: a.Period,
+				Detail: fmt.Sprintf("%d forwards routed to the wallet, %.1f per hour on average", n, avg),
+			})
+		}
+	}
+
+	for connID, n := range a.Failures {
+		r.Failures += n
+
+		avg := float64(failures[connID]) / hours
+		if n >= cfg.MinFailures && float64(n) > cfg.SpikeFactor*avg {
+			r.Anomalies = append(r.Anomalies, &Anomaly{
+				Kind: KindFailureBurst, ConnectionID: connID, Count: n, Baseline: avg, Time: a.Period,
+				Detail: fmt.Sprintf("%d failures, %.1f per hour on average", n, avg),
+			})
+		}
+	}
+
+	for _, k := range a.SenderKeys {
+		r.Anomalies = append(r.Anomalies, &Anomaly{
+			Kind: KindNewSenderKey, RecipientKey: k.RecipientKey, SenderKey: k.SenderKey, Time: k.Time,
+			Detail: "envelope sent with a new key",
+		})
+	}
+
+	sort.Slice(r.Anomalies, func(i, j int) bool {
+		return r.Anomalies[i].less(r.Anomalies[j])
+	})
+
+	return r
+}
+
+// totals returns the forwards per wallet DID and the failures per connection of the activities.
+func totals(activities []*activity) (map[string]int, map[string]int) {
+	forwards, failures := map[string]int{}, map[string]int{}
+
+	for _, a := range activities {
+		for walletDID, n := range a.Forwards {
+			forwards[walletDID] += n
+		}
+
+		for connID, n := range a.Failures {
+			failures[connID] += n
+		}
+	}
+
+	return forwards, failures
+}
+
+// less orders the anomalies by count, the largest first, then by kind and subject.
+func (a *Anomaly) less(other *Anomaly) bool {
+	if a.Count != other.Count {
+		return a.Count > other.Count
+	}
+
+	if a.Kind != other.Kind {
+		return a.Kind < other.Kind
+	}
+
+	return a.WalletDID+a.ConnectionID+a.RecipientKey+a.SenderKey <
+		other.WalletDID+other.ConnectionID+other.RecipientKey+other.SenderKey
+}
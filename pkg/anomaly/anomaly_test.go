@@ -0,0 +1,91 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cfg := (*Config)(nil).withDefaults()
+		require.Equal(t, &Config{
+			SpikeFactor: DefaultSpikeFactor,
+			MinVolume:   DefaultMinVolume,
+			MinFailures: DefaultMinFailures,
+			Baseline:    DefaultBaseline,
+			Retention:   DefaultRetention,
+		}, cfg)
+
+		cfg = (&Config{Baseline: time.Minute}).withDefaults()
+		require.Equal(t, DefaultBaseline, cfg.Baseline)
+	})
+
+	t.Run("configured", func(t *testing.T) {
+		c := &Config{SpikeFactor: 2, MinVolume: 1, MinFailures: 1, Baseline: time.Hour, Retention: time.Hour}
+		require.Equal(t, c, c.withDefaults())
+	})
+}
+
+func TestAnalyze(t *testing.T) {
+	period := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	cfg := &Config{SpikeFactor: 3, MinVolume: 10, MinFailures: 5, Baseline: 2 * time.Hour}
+
+	baseline := []*activity{newActivity(period.Add(-2 * time.Hour)), newActivity(period.Add(-time.Hour))}
+	baseline[0].Forwards["did:steady"] = 20
+	baseline[1].Forwards["did:steady"] = 20
+	baseline[1].Forwards["did:spike"] = 2
+	baseline[0].Failures["conn-steady"] = 10
+
+	a := newActivity(period)
+	a.Forwards["did:steady"] = 25
+	a.Forwards["did:spike"] = 40
+	a.Forwards["did:quiet"] = 5
+	a.Failures["conn-steady"] = 6
+	a.Failures["conn-burst"] = 12
+	a.SenderKeys = []*senderKey{{RecipientKey: "recipient", SenderKey: "sender", Time: period}}
+
+	r := analyze(a, baseline, cfg)
+	require.Equal(t, period, r.Period)
+	require.Equal(t, 70, r.Forwards)
+	require.Equal(t, 18, r.Failures)
+	require.Len(t, r.Anomalies, 3)
+
+	require.Equal(t, KindVolumeSpike, r.Anomalies[0].Kind)
+	require.Equal(t, "did:spike", r.Anomalies[0].WalletDID)
+	require.Equal(t, 40, r.Anomalies[0].Count)
+	require.Equal(t, 1.0, r.Anomalies[0].Baseline)
+
+	require.Equal(t, KindFailureBurst, r.Anomalies[1].Kind)
+	require.Equal(t, "conn-burst", r.Anomalies[1].ConnectionID)
+	require.Equal(t, 12, r.Anomalies[1].Count)
+	require.Zero(t, r.Anomalies[1].Baseline)
+
+	require.Equal(t, KindNewSenderKey, r.Anomalies[2].Kind)
+	require.Equal(t, "recipient", r.Anomalies[2].RecipientKey)
+	require.Equal(t, "sender", r.Anomalies[2].SenderKey)
+}
+
+func TestActivityMerge(t *testing.T) {
+	a := newActivity(time.Time{})
+	a.Forwards["did:a"] = 1
+	a.Failures["conn-a"] = 1
+
+	other := newActivity(time.Time{})
+	other.Forwards["did:a"] = 2
+	other.Forwards["did:b"] = 3
+	other.Failures["conn-a"] = 4
+	other.SenderKeys = []*senderKey{{RecipientKey: "recipient", SenderKey: "sender"}}
+
+	a.merge(other)
+	require.Equal(t, map[string]int{"did:a": 3, "did:b": 3}, a.Forwards)
+	require.Equal(t, map[string]int{"conn-a": 5}, a.Failures)
+	require.Len(t, a.SenderKeys, 1)
+}
@@ -0,0 +1,419 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package anomaly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "anomaly"
+
+	// tag used to query the activities; the value is the period in unix seconds.
+	activityTag = "activity"
+
+	// tag used to query the reports; the value is the period in unix seconds.
+	reportTag = "report"
+
+	sendersKeyPrefix = "senders-"
+)
+
+var logger = log.New("hub-router/anomaly")
+
+// ConnectionResolver returns the ID of the connection with the wallet DID, empty if not found.
+type ConnectionResolver func(walletDID string) string
+
+// Detector records the traffic routed by the router, and analyzes it hourly to report the anomalies. The activity of
+// the current hour is recorded in memory, and merged in the storage with the activity of the other router instances
+// once the hour is over.
+type Detector struct {
+	store      storage.Store
+	cfg        *Config
+	connection ConnectionResolver
+	mutex      sync.Mutex
+	activities map[time.Time]*activity
+	senders    map[string]map[string]bool
+	now        func() time.Time
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// New returns a new Detector analyzing the traffic with the config, the defaults apply if nil. The connection
+// resolver, if not nil, resolves the connections of the wallets of the volume spikes.
+func New(p storage.Provider, cfg *Config, connection ConnectionResolver) (*Detector, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open anomaly store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{activityTag, reportTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set anomaly store config : %w", err)
+	}
+
+	return &Detector{
+		store:      store,
+		cfg:        cfg.withDefaults(),
+		connection: connection,
+		activities: map[time.Time]*activity{},
+		senders:    map[string]map[string]bool{},
+		now:        time.Now,
+		stop:       make(chan struct{}),
+	}, nil
+}
+
+// Forward records a forward routed to the wallet DID.
+func (d *Detector) Forward(walletDID string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.current().Forwards[walletDID]++
+}
+
+// Failure records a failure of a message of the connection.
+func (d *Detector) Failure(connID string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.current().Failures[connID]++
+}
+
+// SenderKey records an envelope sent with the sender key to the recipient key, both base58 encoded. The first sender
+// key of a recipient key is trusted, the following new ones are reported.
+func (d *Detector) SenderKey(recipient, sender string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	known, ok := d.senders[recipient]
+	if !ok {
+		keys, err := d.knownSenders(recipient)
+		if err != nil {
+			return err
+		}
+
+		known = map[string]bool{}
+
+		for _, k := range keys {
+			known[k] = true
+		}
+
+		d.senders[recipient] = known
+	}
+
+	if known[sender] {
+		return nil
+	}
+
+	if len(known) > 0 {
+		a := d.current()
+		a.SenderKeys = append(a.SenderKeys, &senderKey{RecipientKey: recipient, SenderKey: sender, Time: d.now().UTC()})
+	}
+
+	known[sender] = true
+
+	return d.saveSenders(recipient, known)
+}
+
+// Analyze saves the activity recorded in the hours over, and reports their anomalies. The expired activities and
+// reports are pruned.
+func (d *Detector) Analyze() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := d.now().UTC()
+	hour := now.Truncate(time.Hour)
+
+	for period, a := range d.activities {
+		if !period.Before(hour) {
+			continue
+		}
+
+		if err := d.report(a); err != nil {
+			return err
+		}
+
+		delete(d.activities, period)
+	}
+
+	return d.prune(now)
+}
+
+// Reports returns the reports of the hours within [from, to), ordered by period. A zero value disables the bound.
+func (d *Detector) Reports(from, to time.Time) ([]*Report, error) {
+	reports := []*Report{}
+
+	err := d.query(reportTag, from, to, func(val []byte) error {
+		r := &Report{}
+		if err := json.Unmarshal(val, r); err != nil {
+			return fmt.Errorf("unmarshal anomaly report : %w", err)
+		}
+
+		reports = append(reports, r)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Period.Before(reports[j].Period)
+	})
+
+	return reports, nil
+}
+
+// Start analyzes the routed traffic periodically until Stop is called.
+func (d *Detector) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := d.Analyze(); err != nil {
+					logger.Warnf("anomaly analysis : %s", err)
+				}
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic analysis.
+func (d *Detector) Stop() {
+	d.stopOnce.Do(func() {
+		close(d.stop)
+	})
+}
+
+// current returns the activity of the current hour.
+func (d *Detector) current() *activity {
+	period := d.now().UTC().Truncate(time.Hour)
+
+	a, ok := d.activities[period]
+	if !ok {
+		a = newActivity(period)
+		d.activities[period] = a
+	}
+
+	return a
+}
+
+// report merges the activity with the one saved by the other router instances, and saves the report of its hour.
+func (d *Detector) report(a *activity) error {
+	saved, err := d.saved(a.Period, a.Period.Add(time.Hour))
+	if err != nil {
+		return err
+	}
+
+	for _, s := range saved {
+		a.merge(s)
+	}
+
+	if err = d.put(activityTag, a.Period, a); err != nil {
+		return err
+	}
+
+	baseline, err := d.saved(a.Period.Add(-d.cfg.Baseline), a.Period)
+	if err != nil {
+		return err
+	}
+
+	r := analyze(a, baseline, d.cfg)
+	r.GeneratedAt = d.now().UTC()
+
+	for _, anomaly := range r.Anomalies {
+		if anomaly.WalletDID != "" && d.connection != nil {
+			anomaly.ConnectionID = d.connection(anomaly.WalletDID)
+		}
+	}
+
+	return d.put(reportTag, a.Period, r)
+}
+
+// saved returns the activities saved for the hours within [from, to).
+func (d *Detector) saved(from, to time.Time) ([]*activity, error) {
+	var activities []*activity
+
+	err := d.query(activityTag, from, to, func(val []byte) error {
+		a := newActivity(time.Time{})
+		if err := json.Unmarshal(val, a); err != nil {
+			return fmt.Errorf("unmarshal anomaly activity : %w", err)
+		}
+
+		activities = append(activities, a)
+
+		return nil
+	})
+
+	return activities, err
+}
+
+// prune deletes the activities older than the baseline of the previous hour, and the reports older than the
+// retention period.
+func (d *Detector) prune(now time.Time) error {
+	expired := map[string]time.Time{
+		activityTag: now.Truncate(time.Hour).Add(-d.cfg.Baseline - time.Hour),
+		reportTag:   now.Add(-d.cfg.Retention),
+	}
+
+	for tag, before := range expired {
+		var periods []time.Time
+
+		err := d.query(tag, time.Time{}, before, func(val []byte) error {
+			p := struct {
+				Period time.Time `json:"period"`
+			}{}
+
+			if err := json.Unmarshal(val, &p); err != nil {
+				return fmt.Errorf("unmarshal anomaly %s : %w", tag, err)
+			}
+
+			periods = append(periods, p.Period)
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, period := range periods {
+			if err = d.store.Delete(key(tag, period)); err != nil {
+				return fmt.Errorf("delete anomaly %s : %w", tag, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Detector) put(tag string, period time.Time, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal anomaly %s : %w", tag, err)
+	}
+
+	err = d.store.Put(key(tag, period), b, storage.Tag{Name: tag, Value: strconv.FormatInt(period.Unix(), 10)})
+	if err != nil {
+		return fmt.Errorf("save anomaly %s : %w", tag, err)
+	}
+
+	return nil
+}
+
+// query calls read with the values of the tag whose period is within [from, to). A zero value disables the bound.
+func (d *Detector) query(tag string, from, to time.Time, read func([]byte) error) error {
+	iter, err := d.store.Query(tag)
+	if err != nil {
+		return fmt.Errorf("query anomaly %s : %w", tag, err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("iterate anomaly %s : %w", tag, err)
+		}
+
+		if !ok {
+			return nil
+		}
+
+		tags, err := iter.Tags()
+		if err != nil {
+			return fmt.Errorf("read anomaly %s tags : %w", tag, err)
+		}
+
+		if !within(tags, from, to) {
+			continue
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return fmt.Errorf("read anomaly %s : %w", tag, err)
+		}
+
+		if err = read(val); err != nil {
+			return err
+		}
+	}
+}
+
+func (d *Detector) knownSenders(recipient string) ([]string, error) {
+	b, err := d.store.Get(sendersKeyPrefix + recipient)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get sender keys : %w", err)
+	}
+
+	var keys []string
+
+	if err = json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("unmarshal sender keys : %w", err)
+	}
+
+	return keys, nil
+}
+
+func (d *Detector) saveSenders(recipient string, known map[string]bool) error {
+	keys := make([]string, 0, len(known))
+
+	for k := range known {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	b, err := json.Marshal(keys)
+	if err != nil {
+		return fmt.Errorf("marshal sender keys : %w", err)
+	}
+
+	if err = d.store.Put(sendersKeyPrefix+recipient, b); err != nil {
+		return fmt.Errorf("save sender keys : %w", err)
+	}
+
+	return nil
+}
+
+// within returns true if the period of the tags is within [from, to).
+func within(tags []storage.Tag, from, to time.Time) bool {
+	for _, t := range tags {
+		sec, err := strconv.ParseInt(t.Value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		period := time.Unix(sec, 0)
+
+		return (from.IsZero() || !period.Before(from.Truncate(time.Hour))) && (to.IsZero() || period.Before(to))
+	}
+
+	return false
+}
+
+// key returns the key of the activity or report of the period.
+func key(tag string, period time.Time) string {
+	return tag + "-" + period.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,216 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package anomaly
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		d, err := New(mem.NewProvider(), nil, nil)
+		require.NoError(t, err)
+		require.Equal(t, DefaultBaseline, d.cfg.Baseline)
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, nil, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open anomaly store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p, nil, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set anomaly store config")
+	})
+}
+
+func TestDetector(t *testing.T) {
+	cfg := &Config{SpikeFactor: 2, MinVolume: 3, MinFailures: 2, Baseline: 2 * time.Hour, Retention: 3 * time.Hour}
+	start := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("reports the anomalies of the hours over", func(t *testing.T) {
+		now := start.Add(30 * time.Minute)
+
+		d, err := New(mem.NewProvider(), cfg, func(walletDID string) string {
+			return "conn-" + walletDID
+		})
+		require.NoError(t, err)
+
+		d.now = func() time.Time { return now }
+
+		d.Forward("did:a")
+		d.Failure("conn-b")
+		require.NoError(t, d.SenderKey("recipient", "sender-1"))
+		require.NoError(t, d.SenderKey("recipient", "sender-1"))
+
+		// the current hour is not analyzed
+		require.NoError(t, d.Analyze())
+
+		reports, err := d.Reports(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Empty(t, reports)
+
+		now = now.Add(time.Hour)
+
+		for i := 0; i < 5; i++ {
+			d.Forward("did:a")
+			d.Failure("conn-b")
+		}
+
+		require.NoError(t, d.SenderKey("recipient", "sender-2"))
+
+		now = now.Add(time.Hour)
+		require.NoError(t, d.Analyze())
+
+		reports, err = d.Reports(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, reports, 2)
+		require.Equal(t, start, reports[0].Period)
+		require.Empty(t, reports[0].Anomalies)
+		require.Equal(t, now, reports[1].GeneratedAt)
+		require.Equal(t, 5, reports[1].Forwards)
+		require.Len(t, reports[1].Anomalies, 3)
+		require.Equal(t, KindFailureBurst, reports[1].Anomalies[0].Kind)
+		require.Equal(t, KindVolumeSpike, reports[1].Anomalies[1].Kind)
+		require.Equal(t, "conn-did:a", reports[1].Anomalies[1].ConnectionID)
+		require.Equal(t, KindNewSenderKey, reports[1].Anomalies[2].Kind)
+		require.Equal(t, "sender-2", reports[1].Anomalies[2].SenderKey)
+
+		reports, err = d.Reports(start.Add(time.Hour), time.Time{})
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+
+		// the activities beyond the baseline and the reports beyond the retention are pruned
+		now = now.Add(90 * time.Minute)
+		require.NoError(t, d.Analyze())
+
+		reports, err = d.Reports(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+
+		activities, err := d.saved(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, activities, 1)
+	})
+
+	t.Run("merges the activity of the router instances", func(t *testing.T) {
+		p := mem.NewProvider()
+		now := start
+
+		var detectors []*Detector
+
+		for i := 0; i < 2; i++ {
+			d, err := New(p, cfg, nil)
+			require.NoError(t, err)
+
+			d.now = func() time.Time { return now }
+			d.Forward("did:a")
+			d.Forward("did:a")
+
+			detectors = append(detectors, d)
+		}
+
+		now = now.Add(time.Hour)
+
+		for _, d := range detectors {
+			require.NoError(t, d.Analyze())
+		}
+
+		reports, err := detectors[0].Reports(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, reports, 1)
+		require.Equal(t, 4, reports[0].Forwards)
+		require.Len(t, reports[0].Anomalies, 1)
+	})
+
+	t.Run("known sender keys are shared", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		d, err := New(p, cfg, nil)
+		require.NoError(t, err)
+		require.NoError(t, d.SenderKey("recipient", "sender-1"))
+
+		d, err = New(p, cfg, nil)
+		require.NoError(t, err)
+		require.NoError(t, d.SenderKey("recipient", "sender-1"))
+		require.Empty(t, d.current().SenderKeys)
+		require.NoError(t, d.SenderKey("recipient", "sender-2"))
+		require.Len(t, d.current().SenderKeys, 1)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		d, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}), cfg, nil)
+		require.NoError(t, err)
+
+		err = d.SenderKey("recipient", "sender")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save sender keys")
+
+		_, err = d.Reports(time.Time{}, time.Time{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query anomaly report")
+
+		require.Error(t, d.Analyze())
+
+		d.now = func() time.Time { return start }
+		d.Forward("did:a")
+		d.now = func() time.Time { return start.Add(time.Hour) }
+
+		err = d.Analyze()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query anomaly activity")
+
+		d, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), cfg, nil)
+		require.NoError(t, err)
+
+		err = d.SenderKey("recipient", "sender")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get sender keys")
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		d, err := New(mem.NewProvider(), cfg, nil)
+		require.NoError(t, err)
+
+		d.now = func() time.Time { return start }
+		d.Forward("did:a")
+		d.now = time.Now
+
+		d.Start(time.Millisecond)
+		defer d.Stop()
+
+		require.Eventually(t, func() bool {
+			d.mutex.Lock()
+			defer d.mutex.Unlock()
+
+			return len(d.activities) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,149 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package archive
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/backup"
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/eventsink/aws"
+)
+
+const (
+	jsonContentType = "application/json"
+	// payloadsPrefix is the key prefix of the payload objects, the metadata records are prefixed with the reason.
+	payloadsPrefix = "payloads"
+	// lifecycleRuleID is the ID of the lifecycle rule expiring the archived objects.
+	lifecycleRuleID = "hub-router-archive-expiration"
+)
+
+var logger = log.New("hub-router/archive")
+
+// Bucket stores the archived objects, eg: an S3 bucket.
+type Bucket interface {
+	Put(key string, body []byte, opts *aws.PutOptions) error
+	PutLifecycle(rules ...*aws.LifecycleRule) error
+}
+
+// Config of the archive.
+type Config struct {
+	// Prefix of the object keys, eg: hub-router/.
+	Prefix string
+	// Payloads archives the dead-lettered messages, server-side encrypted, in addition to their metadata.
+	Payloads bool
+	// KMSKeyID encrypts the payloads with the KMS key (SSE-KMS), with the S3 managed keys (SSE-S3) if empty.
+	KMSKeyID string
+	// ExpirationDays sets a lifecycle rule on the bucket, deleting the archived objects after the given number of
+	// days. The lifecycle configuration of the bucket is left unchanged if zero.
+	ExpirationDays int
+	// Encrypter encrypts the archived objects with the public keys of the operators, before they are uploaded. The
+	// objects are suffixed with .age.
+	Encrypter *backup.Encrypter
+}
+
+// Record is the archived metadata of a dead-letter entry : the entry without its message, and the key of the payload
+// object if the payloads are archived.
+type Record struct {
+	Reason  string            `json:"reason"`
+	Time    time.Time         `json:"time"`
+	Entry   *deadletter.Entry `json:"entry"`
+	Payload string            `json:"payload,omitempty"`
+}
+
+// Archiver archives the dead-letter entries to a bucket, under {prefix}{reason}/{yyyy}/{mm}/{dd}/{id}.json for the
+// metadata and {prefix}payloads/{id}.json for the payloads (with the .age suffix if encrypted).
+type Archiver struct {
+	bucket Bucket
+	config Config
+}
+
+// New returns a new Archiver, setting the lifecycle rule of the bucket if an expiration is configured.
+func New(bucket Bucket, config *Config) (*Archiver, error) {
+	a := &Archiver{bucket: bucket, config: *config}
+
+	if config.ExpirationDays > 0 {
+		err := bucket.PutLifecycle(&aws.LifecycleRule{
+			ID: lifecycleRuleID, Prefix: config.Prefix, Days: config.ExpirationDays,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("set archive lifecycle : %w", err)
+		}
+	}
+
+	return a, nil
+}
+
+// Archive archives the metadata of the entry, and its payload if enabled.
+func (a *Archiver) Archive(e *deadletter.Entry, reason string) error {
+	now := time.Now().UTC()
+
+	metadata := *e
+	metadata.Message = nil
+
+	record := &Record{Reason: reason, Time: now, Entry: &metadata}
+
+	if a.config.Payloads && len(e.Message) > 0 {
+		record.Payload = a.key(path.Join(payloadsPrefix, e.ID+".json"))
+
+		opts := &aws.PutOptions{Encryption: aws.SSES3}
+		if a.config.KMSKeyID != "" {
+			opts.Encryption, opts.KMSKeyID = aws.SSEKMS, a.config.KMSKeyID
+		}
+
+		err := a.put(record.Payload, e.Message, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	recordBytes, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("marshal archive record : %w", err)
+	}
+
+	key := a.key(path.Join(reason, now.Format("2006/01/02"), e.ID+".json"))
+
+	err = a.put(key, recordBytes, &aws.PutOptions{})
+	if err != nil {
+		return err
+	}
+
+	logger.Debugf("dead-letter entry %s archived : key=%s", e.ID, key)
+
+	return nil
+}
+
+// key returns the object key with the prefix, and the suffix of the encrypted objects.
+func (a *Archiver) key(name string) string {
+	if a.config.Encrypter != nil {
+		name += backup.Extension
+	}
+
+	return a.config.Prefix + name
+}
+
+// put uploads the JSON object, encrypted if configured.
+func (a *Archiver) put(key string, data []byte, opts *aws.PutOptions) error {
+	opts.ContentType = jsonContentType
+
+	if a.config.Encrypter != nil {
+		encrypted, err := a.config.Encrypter.Encrypt(data)
+		if err != nil {
+			return fmt.Errorf("encrypt archive object : %w", err)
+		}
+
+		data, opts.ContentType = encrypted, backup.ContentType
+	}
+
+	return a.bucket.Put(key, data, opts)
+}
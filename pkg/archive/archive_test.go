@@ -0,0 +1,150 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package archive
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/backup"
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/eventsink/aws"
+)
+
+func TestArchiver(t *testing.T) {
+	entry := &deadletter.Entry{
+		ID: "entry-1", MsgID: "msg-1", MsgType: "type", Status: deadletter.StatusDeadLettered, Error: "failed",
+		Message: []byte(`{"@id":"msg-1"}`),
+	}
+
+	t.Run("metadata", func(t *testing.T) {
+		bucket := newMockBucket()
+
+		a, err := New(bucket, &Config{Prefix: "hub-router/"})
+		require.NoError(t, err)
+		require.Empty(t, bucket.rules)
+
+		require.NoError(t, a.Archive(entry, deadletter.ReasonDeadLettered))
+		require.Len(t, bucket.objects, 1)
+
+		key := "hub-router/dead-lettered/" + time.Now().UTC().Format("2006/01/02") + "/entry-1.json"
+		require.Contains(t, bucket.objects, key)
+		require.Equal(t, &aws.PutOptions{ContentType: jsonContentType}, bucket.opts[key])
+
+		record := &Record{}
+		require.NoError(t, json.Unmarshal(bucket.objects[key], record))
+		require.Equal(t, deadletter.ReasonDeadLettered, record.Reason)
+		require.Equal(t, "msg-1", record.Entry.MsgID)
+		require.Equal(t, "failed", record.Entry.Error)
+		require.Empty(t, record.Payload)
+		require.NotContains(t, string(bucket.objects[key]), "@id")
+		require.NotEmpty(t, entry.Message)
+	})
+
+	t.Run("payloads", func(t *testing.T) {
+		bucket := newMockBucket()
+
+		a, err := New(bucket, &Config{Prefix: "archive/", Payloads: true, ExpirationDays: 90})
+		require.NoError(t, err)
+		require.Equal(t, []*aws.LifecycleRule{{ID: lifecycleRuleID, Prefix: "archive/", Days: 90}}, bucket.rules)
+
+		require.NoError(t, a.Archive(entry, deadletter.ReasonExpired))
+		require.Len(t, bucket.objects, 2)
+		require.Equal(t, `{"@id":"msg-1"}`, string(bucket.objects["archive/payloads/entry-1.json"]))
+		require.Equal(t, aws.SSES3, bucket.opts["archive/payloads/entry-1.json"].Encryption)
+
+		key := "archive/expired/" + time.Now().UTC().Format("2006/01/02") + "/entry-1.json"
+
+		record := &Record{}
+		require.NoError(t, json.Unmarshal(bucket.objects[key], record))
+		require.Equal(t, deadletter.ReasonExpired, record.Reason)
+		require.Equal(t, "archive/payloads/entry-1.json", record.Payload)
+
+		a, err = New(bucket, &Config{Payloads: true, KMSKeyID: "alias/archive"})
+		require.NoError(t, err)
+		require.NoError(t, a.Archive(entry, deadletter.ReasonDeadLettered))
+		require.Equal(t, &aws.PutOptions{ContentType: jsonContentType, Encryption: aws.SSEKMS, KMSKeyID: "alias/archive"},
+			bucket.opts["payloads/entry-1.json"])
+	})
+
+	t.Run("encrypted", func(t *testing.T) {
+		bucket := newMockBucket()
+
+		encrypter, err := backup.NewEncrypter("age1xmwwc06ly3ee5rytxm9mflaz2u56jjj36s0mypdrwsvlul66mv4q47ryef")
+		require.NoError(t, err)
+
+		a, err := New(bucket, &Config{Payloads: true, Encrypter: encrypter})
+		require.NoError(t, err)
+		require.NoError(t, a.Archive(entry, deadletter.ReasonDeadLettered))
+
+		key := "dead-lettered/" + time.Now().UTC().Format("2006/01/02") + "/entry-1.json.age"
+
+		for _, k := range []string{key, "payloads/entry-1.json.age"} {
+			require.Contains(t, bucket.objects, k)
+			require.Equal(t, backup.ContentType, bucket.opts[k].ContentType)
+			require.True(t, bytes.HasPrefix(bucket.objects[k], []byte("age-encryption.org/v1\n")))
+			require.NotContains(t, string(bucket.objects[k]), "msg-1")
+		}
+
+		require.Equal(t, aws.SSES3, bucket.opts["payloads/entry-1.json.age"].Encryption)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		bucket := newMockBucket()
+		bucket.err = errors.New("bucket error")
+
+		_, err := New(bucket, &Config{ExpirationDays: 1})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set archive lifecycle : bucket error")
+
+		for _, config := range []*Config{{}, {Payloads: true}} {
+			a, err := New(bucket, config)
+			require.NoError(t, err)
+
+			err = a.Archive(entry, deadletter.ReasonDeadLettered)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "bucket error")
+		}
+	})
+}
+
+type mockBucket struct {
+	objects map[string][]byte
+	opts    map[string]*aws.PutOptions
+	rules   []*aws.LifecycleRule
+	err     error
+}
+
+func newMockBucket() *mockBucket {
+	return &mockBucket{objects: make(map[string][]byte), opts: make(map[string]*aws.PutOptions)}
+}
+
+func (m *mockBucket) Put(key string, body []byte, opts *aws.PutOptions) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.objects[key] = body
+	m.opts[key] = opts
+
+	return nil
+}
+
+func (m *mockBucket) PutLifecycle(rules ...*aws.LifecycleRule) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.rules = rules
+
+	return nil
+}
@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package aries
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/hyperledger/aries-framework-go/pkg/client/didexchange"
@@ -14,6 +15,8 @@ import (
 	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
 	ariescrypto "github.com/hyperledger/aries-framework-go/pkg/crypto"
 	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/dispatcher"
+	mediatorsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
 	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
 	vdrapi "github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
 	"github.com/hyperledger/aries-framework-go/pkg/kms"
@@ -32,16 +35,19 @@ type Ctx interface {
 	Crypto() ariescrypto.Crypto
 	KeyType() kms.KeyType
 	KeyAgreementType() kms.KeyType
+	OutboundDispatcher() dispatcher.Outbound
 }
 
 // OutOfBand client.
 type OutOfBand interface {
 	CreateInvitation(services []interface{}, opts ...outofband.MessageOption) (*outofband.Invitation, error)
+	AcceptInvitation(i *outofband.Invitation, myLabel string, opts ...outofband.MessageOption) (string, error)
 }
 
 // DIDExchange client.
 type DIDExchange interface {
 	CreateConnection(myDID string, theirDID *did.Doc, options ...didexchange.ConnectionOption) (string, error)
+	CreateImplicitInvitation(inviterLabel, inviterDID string, args ...didexchange.Opt) (string, error)
 	RegisterActionEvent(chan<- service.DIDCommAction) error
 	GetConnection(connectionID string) (*didexchange.Connection, error)
 }
@@ -51,6 +57,11 @@ type Mediator interface {
 	RegisterActionEvent(chan<- service.DIDCommAction) error
 }
 
+// RouteService is the route coordination (mediator) protocol service.
+type RouteService interface {
+	HandleInbound(msg service.DIDCommMsg, ctx service.DIDCommContext) (string, error)
+}
+
 // CreateOutofbandClient util function to create oob client.
 func CreateOutofbandClient(ariesCtx outofband.Provider) (*outofband.Client, error) {
 	oobClient, err := outofband.New(ariesCtx)
@@ -96,3 +107,18 @@ func CreateMediatorClient(ctx Ctx, actionCh chan service.DIDCommAction) (Mediato
 
 	return mediatorClient, nil
 }
+
+// GetRouteService util function to lookup the route coordination service.
+func GetRouteService(ctx Ctx) (RouteService, error) {
+	svc, err := ctx.Service(mediatorsvc.Coordination)
+	if err != nil {
+		return nil, fmt.Errorf("lookup route service : %w", err)
+	}
+
+	routeSvc, ok := svc.(RouteService)
+	if !ok {
+		return nil, errors.New("cast service to route service failed")
+	}
+
+	return routeSvc, nil
+}
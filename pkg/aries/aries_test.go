@@ -35,6 +35,26 @@ func TestCreateOutofbandClient(t *testing.T) {
 	})
 }
 
+func TestGetRouteService(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		svc, err := GetRouteService(getAriesCtx())
+		require.NoError(t, err)
+		require.NotNil(t, svc)
+	})
+
+	t.Run("lookup error", func(t *testing.T) {
+		_, err := GetRouteService(&mockprovider.Provider{ServiceErr: errors.New("lookup error")})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "lookup route service")
+	})
+
+	t.Run("cast error", func(t *testing.T) {
+		_, err := GetRouteService(&mockprovider.Provider{ServiceValue: "invalid"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "cast service to route service failed")
+	})
+}
+
 func TestCreateDIDExchangeClient(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		c, err := CreateDIDExchangeClient(getAriesCtx(), nil, nil)
@@ -6,7 +6,11 @@ SPDX-License-Identifier: Apache-2.0
 
 package aries
 
-import "github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+)
 
 // MsgService msg service implementation.
 type MsgService struct {
@@ -34,10 +38,29 @@ func (m *MsgService) Accept(msgType string, _ []string) bool {
 	return m.msgType == msgType
 }
 
+// InboundMsg is an inbound didcomm msg with the DIDs of the connection it was received on.
+type InboundMsg struct {
+	service.DIDCommMsg
+	MyDID    string
+	TheirDID string
+}
+
+// MarshalJSON marshals the didcomm msg only.
+func (m *InboundMsg) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.DIDCommMsg)
+}
+
 // HandleInbound handles inbound didcomm msg.
-func (m *MsgService) HandleInbound(msg service.DIDCommMsg, _ service.DIDCommContext) (string, error) {
+func (m *MsgService) HandleInbound(msg service.DIDCommMsg, ctx service.DIDCommContext) (string, error) {
+	inbound := &InboundMsg{DIDCommMsg: msg}
+
+	if ctx != nil {
+		inbound.MyDID = ctx.MyDID()
+		inbound.TheirDID = ctx.TheirDID()
+	}
+
 	go func() {
-		m.msgCh <- msg
+		m.msgCh <- inbound
 	}()
 
 	return "", nil
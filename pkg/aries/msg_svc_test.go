@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package aries
 
 import (
+	"encoding/json"
 	"testing"
 	"time"
 
@@ -45,3 +46,32 @@ func TestNewMsgSvc(t *testing.T) {
 		require.Fail(t, "tests are not validated due to timeout")
 	}
 }
+
+func TestInboundMsg(t *testing.T) {
+	msgType := "http://example.com/message/test"
+	msgCh := make(chan service.DIDCommMsg)
+
+	msgSvc := NewMsgSvc("msg-123", msgType, msgCh)
+
+	msg := service.NewDIDCommMsgMap(struct {
+		Type string `json:"@type,omitempty"`
+	}{Type: msgType})
+
+	_, err := msgSvc.HandleInbound(msg, service.NewDIDCommContext("did:peer:router", "did:peer:wallet", nil))
+	require.NoError(t, err)
+
+	select {
+	case received := <-msgCh:
+		inbound, ok := received.(*InboundMsg)
+		require.True(t, ok)
+		require.Equal(t, "did:peer:router", inbound.MyDID)
+		require.Equal(t, "did:peer:wallet", inbound.TheirDID)
+		require.Equal(t, msgType, inbound.Type())
+
+		msgBytes, err := json.Marshal(inbound)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"@type":"`+msgType+`"}`, string(msgBytes))
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "tests are not validated due to timeout")
+	}
+}
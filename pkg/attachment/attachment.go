@@ -0,0 +1,331 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package attachment
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/chunking"
+)
+
+const (
+	storeName   = "attachment"
+	metadataTag = "attachment"
+	blobPrefix  = "blob_"
+	tokenSize   = 32
+	// chunkSize splits the blobs into records that fit the size limits of the databases.
+	chunkSize = 512 * 1024
+)
+
+// Defaults of the Config.
+const (
+	DefaultTTL     = 24 * time.Hour
+	DefaultMaxSize = 100 << 20
+)
+
+var (
+	// ErrNotFound is returned when the attachment doesn't exist, expired, or the token doesn't match.
+	ErrNotFound = errors.New("attachment not found")
+	// ErrTooLarge is returned when the attachment exceeds the maximum size.
+	ErrTooLarge = errors.New("attachment too large")
+)
+
+var logger = log.New("hub-router/attachment")
+
+// Config of the attachment store.
+type Config struct {
+	// BaseURL is the external URL of the router REST API, the attachments are fetched from.
+	BaseURL string
+	// TTL is the maximum time the attachments are kept for, DefaultTTL if zero.
+	TTL time.Duration
+	// MaxSize is the maximum size of an attachment in bytes, DefaultMaxSize if zero.
+	MaxSize int64
+}
+
+// Enabled returns true if the base URL of the attachments is configured.
+func (c *Config) Enabled() bool {
+	return c != nil && c.BaseURL != ""
+}
+
+// Attachment is a blob uploaded by an adapter, fetched by the wallet from its URL instead of being forwarded in the
+// DIDComm envelope.
+type Attachment struct {
+	ID          string `json:"id"`
+	ContentType string `json:"contentType"`
+	Size        int64  `json:"size"`
+	// Digest is the base64 SHA-256 of the blob, eg: to set the attachment data sha256 of the DIDComm message.
+	Digest  string    `json:"digest"`
+	Tenant  string    `json:"tenant,omitempty"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+	// URL fetches the blob without API key until the attachment expires : it is a capability, shared only with the
+	// wallet.
+	URL string `json:"url"`
+}
+
+type record struct {
+	*Attachment
+	Token string `json:"token"`
+}
+
+// Store persists the attachments until they expire.
+type Store struct {
+	store    storage.Store
+	config   Config
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns a new attachment Store backed by the given storage provider.
+func New(p storage.Provider, config *Config) (*Store, error) {
+	store, err := chunking.NewProvider(p, chunkSize, storeName).OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open attachment store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{metadataTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set attachment store config : %w", err)
+	}
+
+	s := &Store{store: store, config: *config, stop: make(chan struct{})}
+
+	if s.config.TTL <= 0 {
+		s.config.TTL = DefaultTTL
+	}
+
+	if s.config.MaxSize <= 0 {
+		s.config.MaxSize = DefaultMaxSize
+	}
+
+	return s, nil
+}
+
+// Put stores the blob read from r for the tenant, for the given ttl; the configured TTL if zero or greater.
+func (s *Store) Put(tenantID, contentType string, r io.Reader, ttl time.Duration) (*Attachment, error) {
+	blob, err := ioutil.ReadAll(io.LimitReader(r, s.config.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("read attachment : %w", err)
+	}
+
+	if int64(len(blob)) > s.config.MaxSize {
+		return nil, fmt.Errorf("%w : maximum size is %d bytes", ErrTooLarge, s.config.MaxSize)
+	}
+
+	if ttl <= 0 || ttl > s.config.TTL {
+		ttl = s.config.TTL
+	}
+
+	token := make([]byte, tokenSize)
+
+	_, err = rand.Read(token)
+	if err != nil {
+		return nil, fmt.Errorf("generate attachment token : %w", err)
+	}
+
+	digest := sha256.Sum256(blob)
+	now := time.Now().UTC()
+
+	rec := &record{
+		Attachment: &Attachment{
+			ID: uuid.New().String(), ContentType: contentType, Size: int64(len(blob)),
+			Digest: base64.StdEncoding.EncodeToString(digest[:]), Tenant: tenantID, Created: now, Expires: now.Add(ttl),
+		},
+		Token: hex.EncodeToString(token),
+	}
+
+	err = s.store.Put(blobPrefix+rec.ID, blob)
+	if err != nil {
+		return nil, fmt.Errorf("store attachment : %w", err)
+	}
+
+	recBytes, err := json.Marshal(rec)
+	if err != nil {
+		return nil, fmt.Errorf("marshal attachment : %w", err)
+	}
+
+	err = s.store.Put(rec.ID, recBytes, storage.Tag{Name: metadataTag})
+	if err != nil {
+		return nil, fmt.Errorf("store attachment : %w", err)
+	}
+
+	return s.withURL(rec), nil
+}
+
+// Get returns the attachment and its blob, if the token is the token of its URL.
+func (s *Store) Get(id, token string) (*Attachment, []byte, error) {
+	rec, err := s.get(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(rec.Token)) != 1 {
+		return nil, nil, ErrNotFound
+	}
+
+	blob, err := s.store.Get(blobPrefix + id)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("get attachment %s : %w", id, err)
+	}
+
+	return rec.Attachment, blob, nil
+}
+
+// Delete deletes the attachment of the tenant before it expires; the operator (empty tenant) deletes any attachment.
+func (s *Store) Delete(id, tenantID string) error {
+	rec, err := s.get(id)
+	if err != nil {
+		return err
+	}
+
+	if tenantID != "" && rec.Tenant != tenantID {
+		return ErrNotFound
+	}
+
+	return s.delete(id)
+}
+
+func (s *Store) get(id string) (*record, error) {
+	if strings.HasPrefix(id, blobPrefix) {
+		return nil, ErrNotFound
+	}
+
+	recBytes, err := s.store.Get(id)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get attachment %s : %w", id, err)
+	}
+
+	rec := &record{}
+
+	err = json.Unmarshal(recBytes, rec)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal attachment %s : %w", id, err)
+	}
+
+	// the attachment expired, but isn't swept yet
+	if rec.Attachment == nil || !time.Now().Before(rec.Expires) {
+		return nil, ErrNotFound
+	}
+
+	return rec, nil
+}
+
+func (s *Store) delete(id string) error {
+	err := s.store.Batch([]storage.Operation{{Key: id}, {Key: blobPrefix + id}})
+	if err != nil {
+		return fmt.Errorf("delete attachment %s : %w", id, err)
+	}
+
+	return nil
+}
+
+func (s *Store) withURL(rec *record) *Attachment {
+	a := *rec.Attachment
+	a.URL = strings.TrimSuffix(s.config.BaseURL, "/") + "/attachments/" + url.PathEscape(a.ID) + "/content?" +
+		url.Values{"token": {rec.Token}}.Encode()
+
+	return &a
+}
+
+// Sweep deletes the expired attachments.
+func (s *Store) Sweep(now time.Time) error {
+	it, err := s.store.Query(metadataTag)
+	if err != nil {
+		return fmt.Errorf("query attachments : %w", err)
+	}
+
+	defer storage.Close(it, logger)
+
+	var expired []string
+
+	more, err := it.Next()
+
+	for ; more && err == nil; more, err = it.Next() {
+		var key string
+
+		var value []byte
+
+		key, err = it.Key()
+		if err == nil {
+			value, err = it.Value()
+		}
+
+		if err != nil {
+			break
+		}
+
+		rec := &record{}
+		if json.Unmarshal(value, rec) != nil || rec.Attachment == nil || !now.Before(rec.Expires) {
+			expired = append(expired, key)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("read attachments : %w", err)
+	}
+
+	for _, id := range expired {
+		err = s.delete(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Start sweeps the expired attachments at the given interval, until Stop is called.
+func (s *Store) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := s.Sweep(now.UTC()); err != nil {
+					logger.Warnf("attachment sweep : %s", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic sweep.
+func (s *Store) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
@@ -0,0 +1,184 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package attachment
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	blob := bytes.Repeat([]byte{0xff, 0xd8, 0xff, 0xe0}, chunkSize/2)
+
+	t.Run("put and get", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), &Config{BaseURL: "https://router.example.com/"})
+		require.NoError(t, err)
+
+		a, err := s.Put("tenant-1", "image/jpeg", bytes.NewReader(blob), 0)
+		require.NoError(t, err)
+		require.Equal(t, "image/jpeg", a.ContentType)
+		require.Equal(t, int64(len(blob)), a.Size)
+		require.Equal(t, "tenant-1", a.Tenant)
+		require.Equal(t, DefaultTTL, a.Expires.Sub(a.Created))
+
+		digest := sha256.Sum256(blob)
+		require.Equal(t, base64.StdEncoding.EncodeToString(digest[:]), a.Digest)
+
+		u, err := url.Parse(a.URL)
+		require.NoError(t, err)
+		require.Equal(t, "router.example.com", u.Host)
+		require.Equal(t, "/attachments/"+a.ID+"/content", u.Path)
+
+		token := u.Query().Get("token")
+		require.Len(t, token, 2*tokenSize)
+
+		got, data, err := s.Get(a.ID, token)
+		require.NoError(t, err)
+		require.Equal(t, blob, data)
+		require.Equal(t, a.ID, got.ID)
+		require.Empty(t, got.URL)
+
+		for _, tc := range []struct{ id, token string }{
+			{id: a.ID, token: "invalid"},
+			{id: a.ID},
+			{id: "unknown", token: token},
+			{id: blobPrefix + a.ID, token: token},
+		} {
+			_, _, err = s.Get(tc.id, tc.token)
+			require.ErrorIs(t, err, ErrNotFound)
+		}
+	})
+
+	t.Run("ttl and size limits", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), &Config{BaseURL: "https://router.example.com", TTL: time.Hour, MaxSize: 10})
+		require.NoError(t, err)
+
+		a, err := s.Put("", "text/plain", strings.NewReader("attachment"), time.Minute)
+		require.NoError(t, err)
+		require.Equal(t, time.Minute, a.Expires.Sub(a.Created))
+
+		a, err = s.Put("", "text/plain", strings.NewReader("attachment"), 2*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, time.Hour, a.Expires.Sub(a.Created))
+
+		_, err = s.Put("", "text/plain", strings.NewReader("attachments"), 0)
+		require.ErrorIs(t, err, ErrTooLarge)
+		require.Contains(t, err.Error(), "maximum size is 10 bytes")
+
+		_, err = s.Put("", "text/plain", &failingReader{}, 0)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "read attachment")
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), &Config{BaseURL: "https://router.example.com"})
+		require.NoError(t, err)
+
+		a, err := s.Put("tenant-1", "image/jpeg", bytes.NewReader(blob), 0)
+		require.NoError(t, err)
+
+		require.ErrorIs(t, s.Delete(a.ID, "tenant-2"), ErrNotFound)
+		require.NoError(t, s.Delete(a.ID, "tenant-1"))
+		require.ErrorIs(t, s.Delete(a.ID, ""), ErrNotFound)
+
+		a, err = s.Put("tenant-1", "image/jpeg", bytes.NewReader(blob), 0)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Delete(a.ID, ""))
+
+		_, err = s.store.Get(blobPrefix + a.ID)
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+	})
+
+	t.Run("sweep", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), &Config{BaseURL: "https://router.example.com", TTL: time.Hour})
+		require.NoError(t, err)
+
+		expiring, err := s.Put("", "text/plain", strings.NewReader("expiring"), time.Minute)
+		require.NoError(t, err)
+
+		kept, err := s.Put("", "text/plain", strings.NewReader("kept"), 0)
+		require.NoError(t, err)
+
+		require.NoError(t, s.store.Put("corrupted", []byte("{"), storage.Tag{Name: metadataTag}))
+
+		require.NoError(t, s.Sweep(time.Now().Add(30*time.Minute)))
+
+		_, err = s.get(expiring.ID)
+		require.ErrorIs(t, err, ErrNotFound)
+
+		_, err = s.store.Get(blobPrefix + expiring.ID)
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+
+		_, err = s.store.Get("corrupted")
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+
+		_, err = s.get(kept.ID)
+		require.NoError(t, err)
+
+		s.Start(time.Millisecond)
+		s.Stop()
+		s.Stop()
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := New(&mockProvider{Provider: mem.NewProvider(), openErr: errors.New("open failed")}, &Config{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open attachment store")
+
+		_, err = New(&mockProvider{Provider: mem.NewProvider(), configErr: errors.New("config failed")}, &Config{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set attachment store config")
+
+		s, err := New(mem.NewProvider(), &Config{BaseURL: "https://router.example.com"})
+		require.NoError(t, err)
+
+		require.NoError(t, s.store.Put("corrupted", []byte("{")))
+
+		_, _, err = s.Get("corrupted", "")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal attachment corrupted")
+	})
+}
+
+type failingReader struct{}
+
+func (r *failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
+
+type mockProvider struct {
+	storage.Provider
+	openErr   error
+	configErr error
+}
+
+func (p *mockProvider) OpenStore(name string) (storage.Store, error) {
+	if p.openErr != nil {
+		return nil, p.openErr
+	}
+
+	return p.Provider.OpenStore(name)
+}
+
+func (p *mockProvider) SetStoreConfig(name string, config storage.StoreConfiguration) error {
+	if p.configErr != nil {
+		return p.configErr
+	}
+
+	return p.Provider.SetStoreConfig(name, config)
+}
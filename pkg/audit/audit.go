@@ -0,0 +1,357 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "audit"
+
+	// tag used to query all the audit entries; the value is the entry time in unix seconds.
+	timeTag = "time"
+
+	// tag used to query the audit entries of a tenant; the value is the tenant ID.
+	tenantTag = "tenant"
+
+	// tag used to query the audit entries of a day; the value is the UTC date of the entry.
+	dayTag = "day"
+
+	// tag used to query the audit entries of a tenant for a day; the value is the tenant ID and the UTC date.
+	tenantDayTag = "tenant-day"
+
+	// dayIndexKey marks the entries recorded before the day tags as tagged.
+	dayIndexKey = "day-index"
+
+	dayFormat = "2006-01-02"
+	day       = 24 * time.Hour
+
+	// maxIndexedDays is the longest time range read a day at a time : a longer range is read at once.
+	maxIndexedDays = 366
+)
+
+// Audit entry types.
+const (
+	InvitationCreated = "invitation-created"
+	ConnectionCreated = "connection-created"
+	DIDExchangeAction = "didexchange-action"
+	MediationAction   = "mediation-action"
+	MediationPending  = "mediation-pending"
+	ActionRejected    = "action-rejected"
+	MessageFailed     = "message-failed"
+	KeyMismatch       = "key-mismatch"
+	KeyReuse          = "key-reuse"
+	PolicyUpdated     = "policy-updated"
+	TermsAccepted     = "terms-accepted"
+	TenantUpdated     = "tenant-updated"
+	WalletPinned      = "wallet-pinned"
+	RoleChanged       = "role-changed"
+	WalletHandover    = "wallet-handover"
+	HandoverCancelled = "handover-cancelled"
+	WebhookChanged    = "webhook-changed"
+	GrantTransfer     = "grant-transfer"
+	RecoveryToken     = "recovery-token"
+)
+
+var logger = log.New("hub-router/audit")
+
+// Entry is a single audit record.
+type Entry struct {
+	ID           string    `json:"id"`
+	Time         time.Time `json:"time"`
+	Type         string    `json:"type"`
+	ConnectionID string    `json:"connectionID,omitempty"`
+	ThreadID     string    `json:"threadID,omitempty"`
+	MsgType      string    `json:"msgType,omitempty"`
+	Detail       string    `json:"detail,omitempty"`
+	Tenant       string    `json:"tenant,omitempty"`
+	Actor        string    `json:"actor,omitempty"`
+}
+
+// Log persists and queries audit entries.
+type Log struct {
+	store storage.Store
+}
+
+// New returns a new audit Log backed by the given storage provider.
+func New(p storage.Provider) (*Log, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open audit store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{
+		TagNames: []string{timeTag, tenantTag, dayTag, tenantDayTag},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("set audit store config : %w", err)
+	}
+
+	l := &Log{store: store}
+
+	// the entries not tagged yet are still read by the unbounded queries, the index is completed at the next start
+	if err = l.indexDays(); err != nil {
+		logger.Warnf("failed to tag the audit entries with their day : %s", err)
+	}
+
+	return l, nil
+}
+
+// Record persists the entry, setting the ID and Time if they are empty.
+func (l *Log) Record(e *Entry) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	return l.put(e)
+}
+
+func (l *Log) put(e *Entry) error {
+	entryBytes, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal audit entry : %w", err)
+	}
+
+	date := e.Time.UTC().Format(dayFormat)
+
+	tags := []storage.Tag{
+		{Name: timeTag, Value: strconv.FormatInt(e.Time.Unix(), 10)},
+		{Name: dayTag, Value: date},
+	}
+
+	if e.Tenant != "" {
+		tags = append(tags,
+			storage.Tag{Name: tenantTag, Value: e.Tenant}, storage.Tag{Name: tenantDayTag, Value: e.Tenant + "/" + date})
+	}
+
+	err = l.store.Put(e.ID, entryBytes, tags...)
+	if err != nil {
+		return fmt.Errorf("save audit entry : %w", err)
+	}
+
+	return nil
+}
+
+// Query returns the entries recorded within [from, to), ordered by time. A zero value disables the bound.
+func (l *Log) Query(from, to time.Time) ([]*Entry, error) {
+	return l.QueryTenant("", from, to)
+}
+
+// QueryTenant returns the entries of the tenant recorded within [from, to), ordered by time. An empty tenant ID
+// returns all the entries.
+func (l *Log) QueryTenant(tenantID string, from, to time.Time) ([]*Entry, error) {
+	var entries []*Entry
+
+	err := l.Each(tenantID, from, to, func(e *Entry) error {
+		entries = append(entries, e)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// Each calls fn with the entries of the tenant recorded within [from, to), in time order, stopping at the first
+// error. An empty tenant ID reads all the entries. A bounded range is read a day at a time, through the day tags, so
+// that the entries out of the range aren't read and only the entries of a day are held at once.
+func (l *Log) Each(tenantID string, from, to time.Time, fn func(*Entry) error) error {
+	if from.IsZero() || to.IsZero() || to.Sub(from) > maxIndexedDays*day {
+		expression := timeTag
+		if tenantID != "" {
+			expression = tenantTag + ":" + tenantID
+		}
+
+		return l.each(expression, from, to, fn)
+	}
+
+	for d := from.UTC().Truncate(day); d.Before(to); d = d.Add(day) {
+		expression := dayTag + ":" + d.Format(dayFormat)
+		if tenantID != "" {
+			expression = tenantDayTag + ":" + tenantID + "/" + d.Format(dayFormat)
+		}
+
+		if err := l.each(expression, from, to, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// each calls fn with the entries matching the expression within [from, to), in time order.
+func (l *Log) each(expression string, from, to time.Time, fn func(*Entry) error) error {
+	entries, err := l.query(expression, from, to)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err = fn(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// indexDays tags the entries recorded before the day tags with their day, once : the marker is saved once they are
+// all tagged.
+func (l *Log) indexDays() error {
+	_, err := l.store.Get(dayIndexKey)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, storage.ErrDataNotFound) {
+		return fmt.Errorf("get audit day index : %w", err)
+	}
+
+	iter, err := l.store.Query(timeTag)
+	if err != nil {
+		return fmt.Errorf("query audit entries : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var untagged []*Entry
+
+	for {
+		e, ok, err := nextUntagged(iter, dayTag)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			break
+		}
+
+		if e != nil {
+			untagged = append(untagged, e)
+		}
+	}
+
+	for _, e := range untagged {
+		if err = l.put(e); err != nil {
+			return err
+		}
+	}
+
+	if err = l.store.Put(dayIndexKey, []byte(time.Now().UTC().Format(time.RFC3339))); err != nil {
+		return fmt.Errorf("save audit day index : %w", err)
+	}
+
+	return nil
+}
+
+// nextUntagged returns the next entry of the iterator, nil if it has the tag, or false once all the entries are read.
+func nextUntagged(iter storage.Iterator, tag string) (*Entry, bool, error) {
+	ok, err := iter.Next()
+	if err != nil {
+		return nil, false, fmt.Errorf("iterate audit entries : %w", err)
+	}
+
+	if !ok {
+		return nil, false, nil
+	}
+
+	tags, err := iter.Tags()
+	if err != nil {
+		return nil, false, fmt.Errorf("read audit entry tags : %w", err)
+	}
+
+	for _, t := range tags {
+		if t.Name == tag {
+			return nil, true, nil
+		}
+	}
+
+	val, err := iter.Value()
+	if err != nil {
+		return nil, false, fmt.Errorf("read audit entry : %w", err)
+	}
+
+	e := &Entry{}
+
+	if err = json.Unmarshal(val, e); err != nil {
+		return nil, false, fmt.Errorf("unmarshal audit entry : %w", err)
+	}
+
+	return e, true, nil
+}
+
+func (l *Log) query(expression string, from, to time.Time) ([]*Entry, error) {
+	iter, err := l.store.Query(expression)
+	if err != nil {
+		return nil, fmt.Errorf("query audit entries : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var entries []*Entry
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate audit entries : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read audit entry : %w", err)
+		}
+
+		e := &Entry{}
+
+		err = json.Unmarshal(val, e)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal audit entry : %w", err)
+		}
+
+		if inRange(e.Time, from, to) {
+			entries = append(entries, e)
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return entries, nil
+}
+
+func inRange(t, from, to time.Time) bool {
+	if !from.IsZero() && t.Before(from) {
+		return false
+	}
+
+	if !to.IsZero() && !t.Before(to) {
+		return false
+	}
+
+	return true
+}
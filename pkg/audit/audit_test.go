@@ -0,0 +1,224 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package audit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		l, err := New(mem.NewProvider())
+		require.NoError(t, err)
+		require.NotNil(t, l)
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		l, err := New(p)
+		require.Nil(t, l)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open audit store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		l, err := New(p)
+		require.Nil(t, l)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set audit store config")
+	})
+}
+
+func TestLog(t *testing.T) {
+	t.Run("record and query", func(t *testing.T) {
+		l, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		now := time.Now().UTC()
+
+		require.NoError(t, l.Record(&Entry{Type: InvitationCreated, Time: now.Add(-2 * time.Hour)}))
+		require.NoError(t, l.Record(&Entry{Type: ConnectionCreated, ConnectionID: "conn-1", Time: now}))
+		require.NoError(t, l.Record(&Entry{Type: MediationAction}))
+
+		entries, err := l.Query(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		require.Equal(t, InvitationCreated, entries[0].Type)
+		require.NotEmpty(t, entries[0].ID)
+
+		entries, err = l.Query(now.Add(-time.Hour), time.Time{})
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		entries, err = l.Query(time.Time{}, now.Add(-time.Hour))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, InvitationCreated, entries[0].Type)
+	})
+
+	t.Run("query tenant", func(t *testing.T) {
+		l, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		require.NoError(t, l.Record(&Entry{Type: InvitationCreated, Tenant: "tenant-1"}))
+		require.NoError(t, l.Record(&Entry{Type: ConnectionCreated, ConnectionID: "conn-1", Tenant: "tenant-2"}))
+		require.NoError(t, l.Record(&Entry{Type: MediationAction}))
+
+		entries, err := l.QueryTenant("tenant-1", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, InvitationCreated, entries[0].Type)
+
+		entries, err = l.QueryTenant("tenant-3", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Empty(t, entries)
+
+		entries, err = l.QueryTenant("", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+	})
+
+	t.Run("record error", func(t *testing.T) {
+		l := &Log{store: &mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+		}}
+
+		err := l.Record(&Entry{Type: InvitationCreated})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save audit entry")
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		l := &Log{store: &mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrQuery: errors.New("query error"),
+		}}
+
+		_, err := l.Query(time.Time{}, time.Time{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query audit entries")
+	})
+
+	t.Run("invalid entry", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		l, err := New(p)
+		require.NoError(t, err)
+
+		require.NoError(t, l.store.Put("id", []byte("invalid"), storage.Tag{Name: timeTag, Value: "0"}))
+
+		_, err = l.Query(time.Time{}, time.Time{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal audit entry")
+	})
+	t.Run("bounded range read a day at a time", func(t *testing.T) {
+		l, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		start := time.Date(2021, 5, 1, 22, 0, 0, 0, time.UTC)
+
+		for i := 0; i < 4; i++ {
+			hours := time.Duration(i) * time.Hour
+
+			require.NoError(t, l.Record(&Entry{Type: ConnectionCreated, Time: start.Add(2 * hours)}))
+			require.NoError(t, l.Record(&Entry{Type: KeyReuse, Tenant: "tenant-1", Time: start.Add(hours)}))
+		}
+
+		entries, err := l.Query(start.Add(time.Hour), start.Add(5*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, entries, 5)
+
+		for i := 1; i < len(entries); i++ {
+			require.False(t, entries[i].Time.Before(entries[i-1].Time))
+		}
+
+		entries, err = l.QueryTenant("tenant-1", start.Add(time.Hour), start.Add(3*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, start.Add(time.Hour), entries[0].Time)
+
+		// the iteration stops at the first error
+		count := 0
+		stop := errors.New("stop")
+
+		err = l.Each("", start, start.Add(day), func(*Entry) error {
+			count++
+
+			return stop
+		})
+		require.ErrorIs(t, err, stop)
+		require.Equal(t, 1, count)
+	})
+
+	t.Run("entries recorded before the day tags", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		l, err := New(p)
+		require.NoError(t, err)
+
+		entry := []byte(`{"id":"1","time":"2021-05-01T10:00:00Z","type":"key-reuse","tenant":"tenant-1"}`)
+
+		require.NoError(t, l.store.Delete(dayIndexKey))
+		require.NoError(t, l.store.Put("1", entry, storage.Tag{Name: timeTag, Value: "1619863200"},
+			storage.Tag{Name: tenantTag, Value: "tenant-1"}))
+		require.NoError(t, l.Record(&Entry{Type: KeyReuse, Time: time.Date(2021, 5, 1, 11, 0, 0, 0, time.UTC)}))
+
+		from, to := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2021, 5, 2, 0, 0, 0, 0, time.UTC)
+
+		entries, err := l.Query(from, to)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+
+		l, err = New(p)
+		require.NoError(t, err)
+
+		entries, err = l.Query(from, to)
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		entries, err = l.QueryTenant("tenant-1", from, to)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, "1", entries[0].ID)
+	})
+
+	t.Run("day tags error", func(t *testing.T) {
+		l := &Log{store: &mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}}
+		require.Contains(t, l.indexDays().Error(), "get audit day index")
+
+		l = &Log{store: &mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrQuery: errors.New("query error"),
+		}}
+		require.Contains(t, l.indexDays().Error(), "query audit entries")
+
+		// the log is still created
+		_, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}))
+		require.NoError(t, err)
+	})
+}
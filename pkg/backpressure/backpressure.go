@@ -0,0 +1,206 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package backpressure
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/preparse"
+	"github.com/trustbloc/hub-router/pkg/routes"
+)
+
+// Signal states.
+const (
+	// StateNearCap is signaled when the queue of the recipient is near its cap : the forward is still queued.
+	StateNearCap = "near-cap"
+	// StateFull is signaled when the queue of the recipient reached its cap : the forward is rejected.
+	StateFull = "full"
+)
+
+const (
+	// DefaultRetryAfter is the retry hint of the signals, if not configured.
+	DefaultRetryAfter = time.Minute
+	// nearCapPercent is the percentage of the cap from which the queue is near its cap.
+	nearCapPercent = 90
+)
+
+var (
+	// ErrQueueFull is returned for the forwards addressed to a recipient whose queue reached its cap.
+	ErrQueueFull = errors.New("recipient queue full")
+	// ErrSuppressed is returned by the gates for the envelopes acknowledged without being handled, eg: duplicates.
+	ErrSuppressed = errors.New("envelope suppressed")
+)
+
+var logger = log.New("hub-router/backpressure")
+
+// Config of the backpressure.
+type Config struct {
+	// RecipientCap is the number of messages queued for a single wallet from which the forwards are rejected.
+	RecipientCap int
+	// RetryAfter is the time the senders are advised to pause for, DefaultRetryAfter if zero.
+	RetryAfter time.Duration
+}
+
+// Enabled returns true if the recipient cap is configured.
+func (c *Config) Enabled() bool {
+	return c != nil && c.RecipientCap > 0
+}
+
+// Signal is raised when a forward is addressed to a wallet whose queue is near its cap, or full. The signals of a
+// recipient and sender are raised at most once per retry period.
+type Signal struct {
+	Time  time.Time `json:"time"`
+	State string    `json:"state"`
+	// ConnectionID of the recipient, if known.
+	ConnectionID string `json:"connectionID,omitempty"`
+	MsgID        string `json:"msgID,omitempty"`
+	Depth        int    `json:"depth"`
+	Cap          int    `json:"cap"`
+	// Rejected is true if the forward isn't queued.
+	Rejected bool `json:"rejected"`
+	// RetryAfter is the number of seconds the sender is advised to pause for.
+	RetryAfter int `json:"retryAfter"`
+	// RecipientDID is the DID of the wallet the forward is addressed to.
+	RecipientDID string `json:"-"`
+	// SenderKey is the base58 key of the sender, empty if anonymous.
+	SenderKey string `json:"-"`
+	// RouterKey is the base58 key of the router the forward was packed for.
+	RouterKey string `json:"-"`
+}
+
+// Gate rejects the forward messages addressed to a wallet whose queue (pickup mailbox) reached its cap, instead of
+// queueing them, and signals the senders to pause from the time the queue is near its cap.
+type Gate struct {
+	routes    *routes.Store
+	mailboxes Mailboxes
+	config    Config
+	onSignal  func(*Signal)
+	mutex     sync.Mutex
+	signaled  map[string]time.Time
+}
+
+// Mailboxes are the pickup mailboxes of the wallets, eg: the pickup queue.
+type Mailboxes interface {
+	// Depth returns the number of messages queued for the wallet.
+	Depth(theirDID string) (int, error)
+}
+
+// New returns a new Gate reading the routes from the given Aries storage provider, and the depths of the given pickup
+// mailboxes.
+func New(p storage.Provider, mailboxes Mailboxes, config *Config, onSignal func(*Signal)) (*Gate, error) {
+	r, err := routes.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if onSignal == nil {
+		onSignal = func(*Signal) {}
+	}
+
+	g := &Gate{
+		routes: r, mailboxes: mailboxes, config: *config, onSignal: onSignal, signaled: make(map[string]time.Time),
+	}
+
+	if g.config.RetryAfter <= 0 {
+		g.config.RetryAfter = DefaultRetryAfter
+	}
+
+	return g, nil
+}
+
+// AdmitEnvelope returns an error wrapping ErrQueueFull if the envelope, with the header forward, is a forward
+// addressed to a wallet whose queue reached its cap. The other envelopes are admitted, as well as the forwards if the
+// queue depth can't be read.
+func (g *Gate) AdmitEnvelope(envelope *transport.Envelope, forward *preparse.Header) error {
+	if !forward.IsForward() {
+		return nil
+	}
+
+	theirDID, err := g.routes.DID(string(forward.To))
+	if err != nil {
+		return nil // nolint:nilerr // not routed by the router : handled by the Aries mediator
+	}
+
+	depth, err := g.depth(string(theirDID))
+	if err != nil {
+		logger.Warnf("backpressure : failed to read the queue depth : %s", err)
+
+		return nil
+	}
+
+	if depth < g.config.RecipientCap*nearCapPercent/100 {
+		return nil
+	}
+
+	signal := &Signal{
+		Time: time.Now().UTC(), State: StateNearCap, MsgID: string(forward.ID), Depth: depth, Cap: g.config.RecipientCap,
+		RetryAfter: int(g.config.RetryAfter / time.Second), RecipientDID: string(theirDID),
+		RouterKey: base58.Encode(envelope.ToKey),
+	}
+
+	if len(envelope.FromKey) > 0 {
+		signal.SenderKey = base58.Encode(envelope.FromKey)
+	}
+
+	if depth >= g.config.RecipientCap {
+		signal.State = StateFull
+		signal.Rejected = true
+	}
+
+	g.signal(signal)
+
+	if signal.Rejected {
+		return fmt.Errorf("%w : msgID=%s depth=%d cap=%d", ErrQueueFull, signal.MsgID, depth, g.config.RecipientCap)
+	}
+
+	return nil
+}
+
+// signal raises the signal, unless it was raised for the recipient and sender within the retry period.
+func (g *Gate) signal(s *Signal) {
+	key := s.State + ":" + s.RecipientDID + ":" + s.SenderKey
+
+	g.mutex.Lock()
+
+	for k, t := range g.signaled {
+		if s.Time.Sub(t) >= g.config.RetryAfter {
+			delete(g.signaled, k)
+		}
+	}
+
+	_, signaled := g.signaled[key]
+	if !signaled {
+		g.signaled[key] = s.Time
+	}
+
+	g.mutex.Unlock()
+
+	if signaled {
+		return
+	}
+
+	logger.Warnf("backpressure : state=%s msgID=%s depth=%d cap=%d", s.State, s.MsgID, s.Depth, s.Cap)
+
+	g.onSignal(s)
+}
+
+func (g *Gate) depth(theirDID string) (int, error) {
+	depth, err := g.mailboxes.Depth(theirDID)
+	if err != nil {
+		return 0, fmt.Errorf("get mailbox : %w", err)
+	}
+
+	return depth, nil
+}
@@ -0,0 +1,169 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package backpressure
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/preparse"
+	"github.com/trustbloc/hub-router/pkg/routes"
+)
+
+const walletDID = "did:example:wallet"
+
+// forward returns a forward envelope, with the header of its message.
+func forward(id, to string) (*transport.Envelope, *preparse.Header) {
+	envelope := &transport.Envelope{
+		Message: []byte(fmt.Sprintf(`{"@id":"%s","@type":"https://didcomm.org/routing/1.0/forward","to":"%s",`+
+			`"msg":{"protected":"e30"}}`, id, to)),
+		FromKey: []byte("sender-key"),
+		ToKey:   []byte("router-key"),
+	}
+
+	return envelope, &preparse.Header{
+		ID: []byte(id), Type: []byte("https://didcomm.org/routing/1.0/forward"), To: []byte(to),
+	}
+}
+
+type mockMailboxes struct {
+	depth int
+	err   error
+}
+
+func (m *mockMailboxes) Depth(theirDID string) (int, error) {
+	if theirDID != walletDID {
+		return 0, nil
+	}
+
+	return m.depth, m.err
+}
+
+func TestGate(t *testing.T) {
+	p := mem.NewProvider()
+
+	mediated, err := routes.Open(p)
+	require.NoError(t, err)
+	require.NoError(t, mediated.Route("key1", walletDID))
+
+	var signals []*Signal
+
+	mailboxes := &mockMailboxes{}
+
+	g, err := New(p, mailboxes, &Config{RecipientCap: 10}, func(s *Signal) {
+		signals = append(signals, s)
+	})
+	require.NoError(t, err)
+
+	t.Run("admits the other envelopes", func(t *testing.T) {
+		mailboxes.depth = 100
+
+		require.NoError(t, g.AdmitEnvelope(&transport.Envelope{}, &preparse.Header{Type: []byte("https://didcomm.org/a")}))
+		require.NoError(t, g.AdmitEnvelope(&transport.Envelope{Message: []byte("invalid")}, &preparse.Header{}))
+		// not routed by the router
+		require.NoError(t, g.AdmitEnvelope(forward("1", "key2")))
+		require.Empty(t, signals)
+	})
+
+	t.Run("below the cap", func(t *testing.T) {
+		for _, depth := range []int{0, 8} {
+			mailboxes.depth = depth
+
+			require.NoError(t, g.AdmitEnvelope(forward("1", "key1")))
+		}
+
+		require.Empty(t, signals)
+	})
+
+	t.Run("near the cap", func(t *testing.T) {
+		mailboxes.depth = 9
+
+		require.NoError(t, g.AdmitEnvelope(forward("2", "key1")))
+		require.NoError(t, g.AdmitEnvelope(forward("3", "key1")))
+
+		// signaled once per retry period
+		require.Len(t, signals, 1)
+		require.Equal(t, StateNearCap, signals[0].State)
+		require.False(t, signals[0].Rejected)
+		require.Equal(t, "2", signals[0].MsgID)
+		require.Equal(t, 9, signals[0].Depth)
+		require.Equal(t, 10, signals[0].Cap)
+		require.Equal(t, 60, signals[0].RetryAfter)
+		require.Equal(t, walletDID, signals[0].RecipientDID)
+		require.Equal(t, base58.Encode([]byte("sender-key")), signals[0].SenderKey)
+		require.Equal(t, base58.Encode([]byte("router-key")), signals[0].RouterKey)
+	})
+
+	t.Run("full", func(t *testing.T) {
+		mailboxes.depth = 10
+
+		err = g.AdmitEnvelope(forward("4", "key1"))
+		require.ErrorIs(t, err, ErrQueueFull)
+		require.Contains(t, err.Error(), "msgID=4 depth=10 cap=10")
+
+		err = g.AdmitEnvelope(forward("5", "key1"))
+		require.ErrorIs(t, err, ErrQueueFull)
+
+		require.Len(t, signals, 2)
+		require.Equal(t, StateFull, signals[1].State)
+		require.True(t, signals[1].Rejected)
+	})
+
+	t.Run("signaled again after the retry period", func(t *testing.T) {
+		g.mutex.Lock()
+		for k := range g.signaled {
+			g.signaled[k] = time.Now().Add(-time.Hour)
+		}
+		g.mutex.Unlock()
+
+		require.Error(t, g.AdmitEnvelope(forward("6", "key1")))
+		require.Len(t, signals, 3)
+	})
+
+	t.Run("depth not read", func(t *testing.T) {
+		mailboxes.err = errors.New("get error")
+
+		require.NoError(t, g.AdmitEnvelope(forward("7", "key1")))
+	})
+}
+
+func TestNew(t *testing.T) {
+	g, err := New(mem.NewProvider(), &mockMailboxes{}, &Config{RecipientCap: 10, RetryAfter: 5 * time.Second}, nil)
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Second, g.config.RetryAfter)
+	require.True(t, g.config.Enabled())
+
+	var c *Config
+	require.False(t, c.Enabled())
+
+	_, err = New(&mockstore.MockStoreProvider{
+		FailNamespace: mediator.Coordination,
+	}, &mockMailboxes{}, &Config{RecipientCap: 10}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "open route store")
+
+	t.Run("store read error", func(t *testing.T) {
+		p := mockstore.NewMockStoreProvider()
+
+		g, err := New(p, &mockMailboxes{}, &Config{RecipientCap: 10}, nil)
+		require.NoError(t, err)
+		require.NoError(t, g.routes.Route("key1", walletDID))
+
+		p.Store.ErrGet = errors.New("get error")
+
+		require.NoError(t, g.AdmitEnvelope(forward("1", "key1")))
+	})
+}
@@ -0,0 +1,82 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package backup
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"filippo.io/age"
+)
+
+// Encrypted backups.
+const (
+	// Extension of the encrypted files.
+	Extension = ".age"
+	// ContentType of the encrypted files.
+	ContentType = "application/octet-stream"
+)
+
+// Encrypter encrypts the backups (exports and archives) with the age format (https://age-encryption.org/v1) to the
+// X25519 public keys of the operators : the backups can only be decrypted with the operators' private keys, eg: with
+// age -d -i key.txt.
+type Encrypter struct {
+	recipients []age.Recipient
+}
+
+// NewEncrypter returns a new Encrypter to the given age recipients (age1...).
+func NewEncrypter(recipients ...string) (*Encrypter, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no age recipient")
+	}
+
+	e := &Encrypter{}
+
+	for _, r := range recipients {
+		recipient, err := age.ParseX25519Recipient(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient %s : %w", r, err)
+		}
+
+		e.recipients = append(e.recipients, recipient)
+	}
+
+	return e, nil
+}
+
+// Encrypt encrypts the data.
+func (e *Encrypter) Encrypt(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+
+	w, err := e.Writer(&out)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = w.Write(data); err != nil {
+		return nil, fmt.Errorf("age encrypt : %w", err)
+	}
+
+	if err = w.Close(); err != nil {
+		return nil, fmt.Errorf("age encrypt : %w", err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// Writer returns the writer encrypting the data written to out, eg: an export streamed to the client. The encryption
+// is only complete once the writer is closed.
+func (e *Encrypter) Writer(out io.Writer) (io.WriteCloser, error) {
+	w, err := age.Encrypt(out, e.recipients...)
+	if err != nil {
+		return nil, fmt.Errorf("age encrypt : %w", err)
+	}
+
+	return w, nil
+}
@@ -0,0 +1,99 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/require"
+)
+
+// chunkSize is the size of the chunks of the age payload.
+const chunkSize = 64 * 1024
+
+func TestEncrypter(t *testing.T) {
+	identity1, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	identity2, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	other, err := age.GenerateX25519Identity()
+	require.NoError(t, err)
+
+	e, err := NewEncrypter(identity1.Recipient().String(), identity2.Recipient().String())
+	require.NoError(t, err)
+
+	for _, size := range []int{0, 10, chunkSize, chunkSize + 1, 3 * chunkSize} {
+		data := make([]byte, size)
+		_, err = rand.Read(data)
+		require.NoError(t, err)
+
+		encrypted, err := e.Encrypt(data)
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(encrypted, []byte("age-encryption.org/v1\n-> X25519 ")))
+
+		// decrypted by the reference implementation of age, as age -d -i key.txt does
+		for _, identity := range []age.Identity{identity1, identity2} {
+			r, err := age.Decrypt(bytes.NewReader(encrypted), identity)
+			require.NoError(t, err, "size %d", size)
+
+			decrypted, err := io.ReadAll(r)
+			require.NoError(t, err, "size %d", size)
+			require.Equal(t, data, decrypted)
+		}
+
+		_, err = age.Decrypt(bytes.NewReader(encrypted), other)
+		require.Error(t, err)
+	}
+
+	t.Run("streamed", func(t *testing.T) {
+		var out bytes.Buffer
+
+		w, err := e.Writer(&out)
+		require.NoError(t, err)
+
+		for _, part := range []string{"id,time\n", "1,2021-05-01T00:00:00Z\n"} {
+			_, err = w.Write([]byte(part))
+			require.NoError(t, err)
+		}
+
+		require.NoError(t, w.Close())
+
+		r, err := age.Decrypt(&out, identity1)
+		require.NoError(t, err)
+
+		decrypted, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, "id,time\n1,2021-05-01T00:00:00Z\n", string(decrypted))
+	})
+}
+
+func TestNewEncrypter(t *testing.T) {
+	_, err := NewEncrypter()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no age recipient")
+
+	// the X25519 recipient of the age specification test vectors
+	_, err = NewEncrypter("age1xmwwc06ly3ee5rytxm9mflaz2u56jjj36s0mypdrwsvlul66mv4q47ryef")
+	require.NoError(t, err)
+
+	for _, recipient := range []string{
+		"age1xmwwc06ly3ee5rytxm9mflaz2u56jjj36s0mypdrwsvlul66mv4q47ryeg", // invalid checksum
+		"Age1xmwwc06ly3ee5rytxm9mflaz2u56jjj36s0mypdrwsvlul66mv4q47ryef", // mixed case
+		"age1qqqq",
+		"AGE-SECRET-KEY-1GQ9778VQXMMJVE8SK7J6VT8UJ4HDQAJUVSFCWCM02D8GEWQ72PVQ2Y5J33", // an identity
+	} {
+		_, err = NewEncrypter(recipient)
+		require.Error(t, err, recipient)
+		require.Contains(t, err.Error(), "invalid age recipient", recipient)
+	}
+}
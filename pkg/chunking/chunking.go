@@ -0,0 +1,289 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chunking
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	idSize = 8
+	// manifestPrefix starts the value stored in place of a chunked value; JSON values never start with a NUL byte.
+	manifestPrefix = "\x00hub-router-chunks\x00"
+)
+
+var logger = log.New("hub-router/chunking")
+
+// manifest lists the chunks of a value, stored under the key of the value.
+type manifest struct {
+	ID     string `json:"id"`
+	Chunks int    `json:"chunks"`
+	Size   int    `json:"size"`
+}
+
+func (m *manifest) chunkKey(key string, i int) string {
+	return fmt.Sprintf("%s#chunk-%s-%d", key, m.ID, i)
+}
+
+func (m *manifest) chunkKeys(key string) []string {
+	keys := make([]string, m.Chunks)
+
+	for i := range keys {
+		keys[i] = m.chunkKey(key, i)
+	}
+
+	return keys
+}
+
+// Provider is a storage provider splitting the values of the given stores (eg: the pickup mailboxes holding the
+// large forwarded messages) that exceed the chunk size into parts, persisted separately, so that they fit the size
+// limits of the database. The values are reassembled when they are read. The chunks of a value are written before
+// the manifest replacing it, so that a failed write leaves the previous value readable.
+type Provider struct {
+	storage.Provider
+	size   int
+	stores map[string]bool
+}
+
+// NewProvider returns a new Provider splitting the values of the given stores larger than size bytes; a zero size
+// stores the new values whole, while still reading back the chunked ones.
+func NewProvider(p storage.Provider, size int, stores ...string) *Provider {
+	cp := &Provider{Provider: p, size: size, stores: make(map[string]bool, len(stores))}
+
+	for _, name := range stores {
+		cp.stores[name] = true
+	}
+
+	return cp
+}
+
+// OpenStore opens the store, chunked if it is one of the chunked stores.
+func (p *Provider) OpenStore(name string) (storage.Store, error) {
+	s, err := p.Provider.OpenStore(name)
+	if err != nil || !p.stores[name] {
+		return s, err
+	}
+
+	return &store{Store: s, size: p.size}, nil
+}
+
+// store splits the values written to the underlying store.
+type store struct {
+	storage.Store
+	size int
+}
+
+func (s *store) Put(key string, value []byte, tags ...storage.Tag) error {
+	return s.Batch([]storage.Operation{{Key: key, Value: value, Tags: tags}})
+}
+
+func (s *store) Get(key string) ([]byte, error) {
+	value, err := s.Store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.reassemble(key, value)
+}
+
+func (s *store) GetBulk(keys ...string) ([][]byte, error) {
+	values, err := s.Store.GetBulk(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+
+		values[i], err = s.reassemble(keys[i], value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+func (s *store) Query(expression string, options ...storage.QueryOption) (storage.Iterator, error) {
+	it, err := s.Store.Query(expression, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &iterator{Iterator: it, store: s}, nil
+}
+
+func (s *store) Delete(key string) error {
+	return s.Batch([]storage.Operation{{Key: key}})
+}
+
+// Batch writes the chunks of the values with the operations, then deletes the chunks they replaced.
+func (s *store) Batch(operations []storage.Operation) error {
+	var ops, stale []storage.Operation
+
+	for _, op := range operations {
+		prev, err := s.manifest(op.Key)
+		if err != nil {
+			return err
+		}
+
+		if prev != nil {
+			for _, chunkKey := range prev.chunkKeys(op.Key) {
+				stale = append(stale, storage.Operation{Key: chunkKey})
+			}
+		}
+
+		chunks, err := s.split(op)
+		if err != nil {
+			return err
+		}
+
+		ops = append(ops, chunks...)
+	}
+
+	err := s.Store.Batch(ops)
+	if err != nil {
+		return err
+	}
+
+	if len(stale) > 0 {
+		// the replaced chunks are no longer referenced : a failure leaves orphans, not a corrupted value
+		if errDelete := s.Store.Batch(stale); errDelete != nil {
+			logger.Warnf("failed to delete the replaced chunks : %s", errDelete)
+		}
+	}
+
+	return nil
+}
+
+// split returns the operations writing the chunks of the value, then its manifest; the operation as is if the value
+// doesn't exceed the chunk size.
+func (s *store) split(op storage.Operation) ([]storage.Operation, error) {
+	if s.size <= 0 || op.Value == nil || len(op.Value) <= s.size {
+		return []storage.Operation{op}, nil
+	}
+
+	id := make([]byte, idSize)
+
+	_, err := rand.Read(id)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %s : %w", op.Key, err)
+	}
+
+	m := &manifest{ID: hex.EncodeToString(id), Chunks: (len(op.Value) + s.size - 1) / s.size, Size: len(op.Value)}
+
+	ops := make([]storage.Operation, 0, m.Chunks+1)
+
+	for i := 0; i < m.Chunks; i++ {
+		end := (i + 1) * s.size
+		if end > len(op.Value) {
+			end = len(op.Value)
+		}
+
+		ops = append(ops, storage.Operation{Key: m.chunkKey(op.Key, i), Value: op.Value[i*s.size : end]})
+	}
+
+	mBytes, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("chunk %s : %w", op.Key, err)
+	}
+
+	return append(ops, storage.Operation{
+		Key: op.Key, Value: append([]byte(manifestPrefix), mBytes...), Tags: op.Tags,
+	}), nil
+}
+
+// manifest returns the manifest currently stored under the key, nil if the value isn't chunked.
+func (s *store) manifest(key string) (*manifest, error) {
+	value, err := s.Store.Get(key)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return parseManifest(key, value)
+}
+
+func parseManifest(key string, value []byte) (*manifest, error) {
+	if !bytes.HasPrefix(value, []byte(manifestPrefix)) {
+		return nil, nil
+	}
+
+	m := &manifest{}
+
+	err := json.Unmarshal(value[len(manifestPrefix):], m)
+	if err != nil {
+		return nil, fmt.Errorf("read chunks manifest of %s : %w", key, err)
+	}
+
+	return m, nil
+}
+
+func (s *store) reassemble(key string, value []byte) ([]byte, error) {
+	m, err := parseManifest(key, value)
+	if err != nil {
+		return nil, err
+	}
+
+	if m == nil {
+		return value, nil
+	}
+
+	chunks, err := s.Store.GetBulk(m.chunkKeys(key)...)
+	if err != nil {
+		return nil, fmt.Errorf("read chunks of %s : %w", key, err)
+	}
+
+	reassembled := make([]byte, 0, m.Size)
+
+	for i, chunk := range chunks {
+		if chunk == nil {
+			return nil, fmt.Errorf("read chunks of %s : missing chunk %d", key, i)
+		}
+
+		reassembled = append(reassembled, chunk...)
+	}
+
+	if len(reassembled) != m.Size {
+		return nil, fmt.Errorf("read chunks of %s : size %d, expected %d", key, len(reassembled), m.Size)
+	}
+
+	return reassembled, nil
+}
+
+// iterator reassembles the values of the query results.
+type iterator struct {
+	storage.Iterator
+	store *store
+}
+
+func (it *iterator) Value() ([]byte, error) {
+	value, err := it.Iterator.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := it.Iterator.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	return it.store.reassemble(key, value)
+}
@@ -0,0 +1,243 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package chunking
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	inbox := []byte(`{"message_count":1,"messages":["` + strings.Repeat("a", 1000) + `"]}`)
+
+	t.Run("chunked store", func(t *testing.T) {
+		mp := mem.NewProvider()
+
+		s, err := NewProvider(mp, 100, "mailbox").OpenStore("mailbox")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put("did:example:1", inbox, storage.Tag{Name: "inbox"}))
+		require.NoError(t, s.Put("did:example:2", []byte(`{}`), storage.Tag{Name: "inbox"}))
+
+		raw, err := mp.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		stored, err := raw.Get("did:example:1")
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(stored, []byte(manifestPrefix)))
+
+		m, err := parseManifest("did:example:1", stored)
+		require.NoError(t, err)
+		require.Equal(t, 11, m.Chunks)
+		require.Equal(t, len(inbox), m.Size)
+
+		chunks, err := raw.GetBulk(m.chunkKeys("did:example:1")...)
+		require.NoError(t, err)
+
+		for _, chunk := range chunks {
+			require.LessOrEqual(t, len(chunk), 100)
+		}
+
+		// not exceeding the chunk size : stored as is
+		stored, err = raw.Get("did:example:2")
+		require.NoError(t, err)
+		require.Equal(t, []byte(`{}`), stored)
+
+		value, err := s.Get("did:example:1")
+		require.NoError(t, err)
+		require.Equal(t, inbox, value)
+
+		values, err := s.GetBulk("did:example:1", "did:example:2", "did:example:3")
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{inbox, []byte(`{}`), nil}, values)
+
+		it, err := s.Query("inbox")
+		require.NoError(t, err)
+
+		count := 0
+
+		for {
+			more, errNext := it.Next()
+			require.NoError(t, errNext)
+
+			if !more {
+				break
+			}
+
+			value, err = it.Value()
+			require.NoError(t, err)
+			require.True(t, bytes.HasPrefix(value, []byte("{")))
+
+			count++
+		}
+
+		require.NoError(t, it.Close())
+		require.Equal(t, 2, count)
+	})
+
+	t.Run("replaced and deleted values remove their chunks", func(t *testing.T) {
+		mp := mem.NewProvider()
+
+		s, err := NewProvider(mp, 100, "mailbox").OpenStore("mailbox")
+		require.NoError(t, err)
+
+		raw, err := mp.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put("did:example:1", inbox))
+
+		stored, err := raw.Get("did:example:1")
+		require.NoError(t, err)
+
+		first, err := parseManifest("did:example:1", stored)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put("did:example:1", append(inbox, ' ')))
+
+		value, err := s.Get("did:example:1")
+		require.NoError(t, err)
+		require.Equal(t, append(inbox, ' '), value)
+
+		_, err = raw.Get(first.chunkKey("did:example:1", 0))
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+
+		stored, err = raw.Get("did:example:1")
+		require.NoError(t, err)
+
+		second, err := parseManifest("did:example:1", stored)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Delete("did:example:1"))
+
+		_, err = s.Get("did:example:1")
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+
+		_, err = raw.Get(second.chunkKey("did:example:1", 0))
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+	})
+
+	t.Run("reads the chunked values once disabled", func(t *testing.T) {
+		mp := mem.NewProvider()
+
+		s, err := NewProvider(mp, 100, "mailbox").OpenStore("mailbox")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put("did:example:1", inbox))
+
+		s, err = NewProvider(mp, 0, "mailbox").OpenStore("mailbox")
+		require.NoError(t, err)
+
+		value, err := s.Get("did:example:1")
+		require.NoError(t, err)
+		require.Equal(t, inbox, value)
+
+		require.NoError(t, s.Put("did:example:1", inbox))
+
+		raw, err := mp.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		stored, err := raw.Get("did:example:1")
+		require.NoError(t, err)
+		require.Equal(t, inbox, stored)
+	})
+
+	t.Run("other stores are not chunked", func(t *testing.T) {
+		mp := mem.NewProvider()
+
+		s, err := NewProvider(mp, 100, "mailbox").OpenStore("didexchange")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put("key", inbox))
+
+		raw, err := mp.OpenStore("didexchange")
+		require.NoError(t, err)
+
+		stored, err := raw.Get("key")
+		require.NoError(t, err)
+		require.Equal(t, inbox, stored)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mp := mem.NewProvider()
+
+		s, err := NewProvider(mp, 100, "mailbox").OpenStore("mailbox")
+		require.NoError(t, err)
+
+		raw, err := mp.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		require.NoError(t, raw.Put("did:example:1", []byte(manifestPrefix+"{")))
+
+		_, err = s.Get("did:example:1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "read chunks manifest of did:example:1")
+
+		_, err = s.GetBulk("did:example:1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "read chunks manifest of did:example:1")
+
+		err = s.Put("did:example:1", inbox)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "read chunks manifest of did:example:1")
+
+		require.NoError(t, raw.Put("did:example:2", []byte(manifestPrefix+`{"id":"1","chunks":2,"size":200}`)))
+
+		_, err = s.Get("did:example:2")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "missing chunk 0")
+
+		require.NoError(t, raw.Put("did:example:2#chunk-1-0", []byte("a")))
+		require.NoError(t, raw.Put("did:example:2#chunk-1-1", []byte("b")))
+
+		_, err = s.Get("did:example:2")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "size 2, expected 200")
+
+		_, err = s.Get("did:example:3")
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+
+		_, err = s.GetBulk()
+		require.Error(t, err)
+
+		_, err = s.Query("")
+		require.Error(t, err)
+
+		fs, err := NewProvider(&failingProvider{Provider: mp}, 100, "mailbox").OpenStore("mailbox")
+		require.NoError(t, err)
+
+		err = fs.Put("did:example:4", inbox)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get failed")
+	})
+}
+
+type failingProvider struct {
+	storage.Provider
+}
+
+func (p *failingProvider) OpenStore(name string) (storage.Store, error) {
+	s, err := p.Provider.OpenStore(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &failingStore{Store: s}, nil
+}
+
+type failingStore struct {
+	storage.Store
+}
+
+func (s *failingStore) Get(string) ([]byte, error) {
+	return nil, errors.New("get failed")
+}
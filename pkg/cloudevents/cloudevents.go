@@ -0,0 +1,89 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SpecVersion is the CloudEvents specification version of the events.
+const SpecVersion = "1.0"
+
+// ContentType of the events in structured mode.
+const ContentType = "application/cloudevents+json"
+
+// Content modes of the events.
+const (
+	// ModeStructured sends the event (attributes and data) as the message body.
+	ModeStructured = "structured"
+	// ModeBinary sends the data as the message body, and the attributes as the message headers.
+	ModeBinary = "binary"
+)
+
+// Header prefixes of the event attributes in binary mode.
+const (
+	HTTPHeaderPrefix  = "ce-"
+	KafkaHeaderPrefix = "ce_"
+)
+
+const (
+	dataContentType = "application/json"
+	typePrefix      = "org.trustbloc.hub-router."
+)
+
+// Event is a CloudEvents 1.0 event with JSON data.
+type Event struct {
+	SpecVersion     string          `json:"specversion"`
+	ID              string          `json:"id"`
+	Source          string          `json:"source"`
+	Type            string          `json:"type"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// New returns a new event with the data marshalled to JSON.
+func New(id, source, eventType string, t time.Time, data interface{}) (*Event, error) {
+	dataBytes, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("marshal event data : %w", err)
+	}
+
+	return &Event{
+		SpecVersion:     SpecVersion,
+		ID:              id,
+		Source:          source,
+		Type:            eventType,
+		Time:            t.UTC(),
+		DataContentType: dataContentType,
+		Data:            dataBytes,
+	}, nil
+}
+
+// Type returns the event type of the hub-router events with the given name, eg: the webhook topic.
+func Type(name string) string {
+	return typePrefix + name
+}
+
+// ValidMode returns true if the content mode is supported.
+func ValidMode(mode string) bool {
+	return mode == ModeStructured || mode == ModeBinary
+}
+
+// Headers returns the attributes of the event as binary mode headers, with the given prefix. The data content type is
+// not included : it is the content type of the message.
+func (e *Event) Headers(prefix string) map[string]string {
+	return map[string]string{
+		prefix + "specversion": e.SpecVersion,
+		prefix + "id":          e.ID,
+		prefix + "source":      e.Source,
+		prefix + "type":        e.Type,
+		prefix + "time":        e.Time.Format(time.RFC3339Nano),
+	}
+}
@@ -0,0 +1,63 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package cloudevents
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvent(t *testing.T) {
+	t.Run("structured", func(t *testing.T) {
+		now := time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC)
+
+		e, err := New("event-1", "/hub-router", Type("presence"), now, map[string]string{"status": "online"})
+		require.NoError(t, err)
+
+		eventBytes, err := json.Marshal(e)
+		require.NoError(t, err)
+		require.JSONEq(t, `{
+			"specversion":"1.0",
+			"id":"event-1",
+			"source":"/hub-router",
+			"type":"org.trustbloc.hub-router.presence",
+			"time":"2021-06-01T10:30:00Z",
+			"datacontenttype":"application/json",
+			"data":{"status":"online"}
+		}`, string(eventBytes))
+	})
+
+	t.Run("binary", func(t *testing.T) {
+		now := time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC)
+
+		e, err := New("event-1", "/hub-router", Type("queue"), now, nil)
+		require.NoError(t, err)
+
+		require.Equal(t, map[string]string{
+			"ce_specversion": "1.0",
+			"ce_id":          "event-1",
+			"ce_source":      "/hub-router",
+			"ce_type":        "org.trustbloc.hub-router.queue",
+			"ce_time":        "2021-06-01T10:30:00Z",
+		}, e.Headers(KafkaHeaderPrefix))
+	})
+
+	t.Run("marshal error", func(t *testing.T) {
+		_, err := New("event-1", "/hub-router", Type("queue"), time.Now(), make(chan int))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "marshal event data")
+	})
+
+	t.Run("modes", func(t *testing.T) {
+		require.True(t, ValidMode(ModeStructured))
+		require.True(t, ValidMode(ModeBinary))
+		require.False(t, ValidMode("batch"))
+	})
+}
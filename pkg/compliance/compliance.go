@@ -0,0 +1,173 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package compliance
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Compliance modes.
+const (
+	// ModeOff doesn't validate the inbound messages.
+	ModeOff = "off"
+	// ModeReport validates the inbound messages and reports the non-compliant peers, without rejecting them.
+	ModeReport = "report"
+	// ModeStrict also rejects the non-compliant messages.
+	ModeStrict = "strict"
+)
+
+const (
+	didcommPrefix = "https://didcomm.org/"
+	// legacyPrefix is the message type prefix replaced by didcommPrefix (Aries RFC 0348), still accepted.
+	legacyPrefix = "did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/"
+)
+
+// ErrNonCompliant is returned for the messages rejected in strict mode.
+var ErrNonCompliant = errors.New("message not compliant with the Aries RFCs")
+
+//go:embed schema/*.json
+var schemaFS embed.FS
+
+// typePattern is the message type URI of Aries RFC 0003 : doc-uri/protocol-name/version/message-type-name.
+var typePattern = regexp.MustCompile( // nolint:gochecknoglobals // read-only pattern
+	`^(https://didcomm\.org/|did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/)[a-z0-9._-]+/[0-9]+\.[0-9]+/[A-Za-z0-9._-]+$`)
+
+// messageSchemas are the schema files of the message types validated, the other types only get the generic checks.
+// nolint:gochecknoglobals // read-only lookup table
+var messageSchemas = map[string]string{
+	"didexchange/1.0/request":                  "didexchange-request.json",
+	"didexchange/1.0/response":                 "didexchange-response.json",
+	"didexchange/1.0/complete":                 "didexchange-complete.json",
+	"coordinate-mediation/1.0/mediate-request": "mediate-request.json",
+	"coordinate-mediation/1.0/keylist-update":  "keylist-update.json",
+	"coordinate-mediation/1.0/keylist-query":   "keylist-query.json",
+	"routing/1.0/forward":                      "forward.json",
+	"messagepickup/1.0/batch-pickup":           "batch-pickup.json",
+	"trust_ping/1.0/ping":                      "trust-ping.json",
+	"notification/1.0/ack":                     "ack.json",
+	"report-problem/1.0/problem-report":        "problem-report.json",
+}
+
+// ValidMode returns true if the compliance mode is supported.
+func ValidMode(mode string) bool {
+	return mode == ModeOff || mode == ModeReport || mode == ModeStrict
+}
+
+// Result of the validation of a message.
+type Result struct {
+	MsgType string `json:"msgType,omitempty"`
+	MsgID   string `json:"msgID,omitempty"`
+	// RFC is the Aries RFC the message was validated against, empty if only the generic checks applied.
+	RFC    string   `json:"rfc,omitempty"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Compliant returns true if the message passed the validation.
+func (r *Result) Compliant() bool {
+	return len(r.Errors) == 0
+}
+
+type messageSchema struct {
+	schema *gojsonschema.Schema
+	rfc    string
+}
+
+// Validator validates the plaintext DIDComm messages against the schemas of the Aries RFCs.
+type Validator struct {
+	schemas map[string]*messageSchema
+}
+
+// NewValidator returns a new Validator, loading the schemas of the message types.
+func NewValidator() (*Validator, error) {
+	v := &Validator{schemas: make(map[string]*messageSchema, len(messageSchemas))}
+
+	for msgType, file := range messageSchemas {
+		schemaBytes, err := schemaFS.ReadFile("schema/" + file)
+		if err != nil {
+			return nil, fmt.Errorf("read schema %s : %w", file, err)
+		}
+
+		doc := struct {
+			Description string `json:"description"`
+		}{}
+
+		err = json.Unmarshal(schemaBytes, &doc)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal schema %s : %w", file, err)
+		}
+
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schemaBytes))
+		if err != nil {
+			return nil, fmt.Errorf("load schema %s : %w", file, err)
+		}
+
+		v.schemas[msgType] = &messageSchema{schema: schema, rfc: doc.Description}
+	}
+
+	return v, nil
+}
+
+// Validate validates the message : its type must be a message type URI, and the messages of the supported protocols
+// must be valid against the schema of their RFC. The other messages must have an ID.
+func (v *Validator) Validate(msg []byte) *Result {
+	r := &Result{}
+	fields := map[string]interface{}{}
+
+	if err := json.Unmarshal(msg, &fields); err != nil {
+		r.Errors = append(r.Errors, fmt.Sprintf("not a JSON object : %s", err))
+
+		return r
+	}
+
+	r.MsgType, r.MsgID = stringField(fields, "@type"), stringField(fields, "@id")
+
+	if !typePattern.MatchString(r.MsgType) {
+		r.Errors = append(r.Errors, fmt.Sprintf("@type : invalid message type URI '%s'", r.MsgType))
+
+		return r
+	}
+
+	s, ok := v.schemas[strings.TrimPrefix(strings.TrimPrefix(r.MsgType, didcommPrefix), legacyPrefix)]
+	if !ok {
+		if r.MsgID == "" {
+			r.Errors = append(r.Errors, "@id : message ID is required")
+		}
+
+		return r
+	}
+
+	r.RFC = s.rfc
+
+	result, err := s.schema.Validate(gojsonschema.NewBytesLoader(msg))
+	if err != nil {
+		r.Errors = append(r.Errors, fmt.Sprintf("validate message : %s", err))
+
+		return r
+	}
+
+	for _, e := range result.Errors() {
+		r.Errors = append(r.Errors, e.String())
+	}
+
+	return r
+}
+
+// stringField returns the value of the field, empty if it isn't a string.
+func stringField(fields map[string]interface{}, name string) string {
+	if s, ok := fields[name].(string); ok {
+		return s
+	}
+
+	return ""
+}
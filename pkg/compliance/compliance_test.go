@@ -0,0 +1,86 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package compliance
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidMode(t *testing.T) {
+	require.True(t, ValidMode(ModeOff))
+	require.True(t, ValidMode(ModeReport))
+	require.True(t, ValidMode(ModeStrict))
+	require.False(t, ValidMode("flag"))
+}
+
+func TestValidator(t *testing.T) {
+	v, err := NewValidator()
+	require.NoError(t, err)
+	require.Len(t, v.schemas, len(messageSchemas))
+
+	t.Run("compliant", func(t *testing.T) {
+		for _, msg := range []string{
+			`{"@id":"1","@type":"https://didcomm.org/routing/1.0/forward","to":"key-1","msg":{"protected":"x"}}`,
+			`{"@id":"2","@type":"https://didcomm.org/didexchange/1.0/request","label":"Bob","did":"did:peer:1",` +
+				`"did_doc~attach":{"data":{"base64":"e30"}},"~thread":{"thid":"2","pthid":"inv-1"}}`,
+			`{"@id":"3","@type":"https://didcomm.org/coordinate-mediation/1.0/keylist-update",` +
+				`"updates":[{"recipient_key":"key-1","action":"add"}]}`,
+			`{"@id":"4","@type":"did:sov:BzCbsNYhMrjHiqZDTUASHg;spec/trust_ping/1.0/ping","response_requested":true}`,
+			`{"@id":"5","@type":"https://didcomm.org/basicmessage/1.0/message","content":"hi"}`,
+		} {
+			r := v.Validate([]byte(msg))
+			require.True(t, r.Compliant(), msg, r.Errors)
+		}
+
+		r := v.Validate([]byte(`{"@id":"1","@type":"https://didcomm.org/messagepickup/1.0/batch-pickup","batch_size":5}`))
+		require.Equal(t, "1", r.MsgID)
+		require.Equal(t, "https://didcomm.org/messagepickup/1.0/batch-pickup", r.MsgType)
+		require.Equal(t, "Aries RFC 0212: Pickup Protocol 1.0", r.RFC)
+	})
+
+	t.Run("non-compliant", func(t *testing.T) {
+		r := v.Validate([]byte(`{"@id":"1","@type":"https://didcomm.org/routing/1.0/forward","msg":{}}`))
+		require.False(t, r.Compliant())
+		require.Equal(t, "Aries RFC 0094: Cross-Domain Messaging", r.RFC)
+		require.Equal(t, []string{"(root): to is required"}, r.Errors)
+
+		r = v.Validate([]byte(`{"@id":"2","@type":"https://didcomm.org/didexchange/1.0/request","label":"Bob",` +
+			`"connection":{"DID":"did:peer:1"},"~thread":{"pthid":"inv-1"}}`))
+		require.Equal(t, []string{"(root): did is required"}, r.Errors)
+
+		r = v.Validate([]byte(`{"@id":"3","@type":"https://didcomm.org/coordinate-mediation/1.0/keylist-update",` +
+			`"updates":[{"recipient_key":"key-1","action":"replace"}]}`))
+		require.Len(t, r.Errors, 1)
+		require.Contains(t, r.Errors[0], "updates.0.action")
+
+		r = v.Validate([]byte(`{"@type":"https://didcomm.org/basicmessage/1.0/message"}`))
+		require.Equal(t, []string{"@id : message ID is required"}, r.Errors)
+		require.Empty(t, r.RFC)
+	})
+
+	t.Run("invalid type", func(t *testing.T) {
+		for _, msg := range []string{
+			`{"@id":"1"}`,
+			`{"@id":"1","@type":"forward"}`,
+			`{"@id":"1","@type":"https://example.com/routing/1.0/forward"}`,
+			`{"@id":"1","@type":"https://didcomm.org/routing/forward"}`,
+			`{"@id":"1","@type":42}`,
+		} {
+			r := v.Validate([]byte(msg))
+			require.Len(t, r.Errors, 1, msg)
+			require.Contains(t, r.Errors[0], "@type : invalid message type URI", msg)
+		}
+	})
+
+	t.Run("not a JSON object", func(t *testing.T) {
+		r := v.Validate([]byte(`[]`))
+		require.Len(t, r.Errors, 1)
+		require.Contains(t, r.Errors[0], "not a JSON object")
+	})
+}
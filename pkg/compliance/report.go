@@ -0,0 +1,253 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package compliance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "compliance"
+	reportTag = "report"
+	// maxViolations is the number of violations kept per peer, the most recent.
+	maxViolations = 20
+)
+
+// AnonymousPeer is the peer of the messages received without a sender key.
+const AnonymousPeer = "anonymous"
+
+// ErrNotFound is returned when no message of the peer was validated.
+var ErrNotFound = errors.New("compliance report not found")
+
+var logger = log.New("hub-router/compliance")
+
+// Violation is a non-compliant message received from a peer.
+type Violation struct {
+	Time time.Time `json:"time"`
+	*Result
+	// Rejected is true if the message was rejected, in strict mode.
+	Rejected bool `json:"rejected,omitempty"`
+}
+
+// MsgTypeStats counts the messages of a type validated for a peer.
+type MsgTypeStats struct {
+	Checked      int `json:"checked"`
+	NonCompliant int `json:"nonCompliant,omitempty"`
+}
+
+// Report is the compliance report of a peer, identified by its sender key.
+type Report struct {
+	Peer         string                   `json:"peer"`
+	ConnectionID string                   `json:"connectionID,omitempty"`
+	Compliant    bool                     `json:"compliant"`
+	Checked      int                      `json:"checked"`
+	NonCompliant int                      `json:"nonCompliant"`
+	Rejected     int                      `json:"rejected,omitempty"`
+	MsgTypes     map[string]*MsgTypeStats `json:"msgTypes,omitempty"`
+	// Violations are the most recent violations, most recent first.
+	Violations []*Violation `json:"violations,omitempty"`
+	FirstSeen  time.Time    `json:"firstSeen"`
+	LastSeen   time.Time    `json:"lastSeen"`
+}
+
+// Reports records the validation results of the messages received from each peer.
+type Reports struct {
+	store storage.Store
+	mutex sync.Mutex
+}
+
+// NewReports returns a new compliance Reports store.
+func NewReports(p storage.Provider) (*Reports, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open compliance store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{reportTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set compliance store config : %w", err)
+	}
+
+	return &Reports{store: store}, nil
+}
+
+// Record records the validation result of a message received from the peer, on the connection if known.
+func (r *Reports) Record(peer, connID string, result *Result, rejected bool) (*Report, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now().UTC()
+
+	report, err := r.get(peer)
+	if errors.Is(err, ErrNotFound) {
+		report, err = &Report{Peer: peer, Compliant: true, FirstSeen: now}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if connID != "" {
+		report.ConnectionID = connID
+	}
+
+	report.Checked++
+	report.LastSeen = now
+
+	stats := report.msgTypeStats(result.MsgType)
+	stats.Checked++
+
+	if !result.Compliant() {
+		stats.NonCompliant++
+		report.NonCompliant++
+		report.Compliant = false
+
+		if rejected {
+			report.Rejected++
+		}
+
+		report.Violations = append([]*Violation{{Time: now, Result: result, Rejected: rejected}}, report.Violations...)
+		if len(report.Violations) > maxViolations {
+			report.Violations = report.Violations[:maxViolations]
+		}
+	}
+
+	return report, r.put(report)
+}
+
+// msgTypeStats returns the stats of the message type, or of the messages without a type.
+func (r *Report) msgTypeStats(msgType string) *MsgTypeStats {
+	if msgType == "" {
+		msgType = "none"
+	}
+
+	if r.MsgTypes == nil {
+		r.MsgTypes = make(map[string]*MsgTypeStats)
+	}
+
+	stats, ok := r.MsgTypes[msgType]
+	if !ok {
+		stats = &MsgTypeStats{}
+		r.MsgTypes[msgType] = stats
+	}
+
+	return stats
+}
+
+// Get returns the compliance report of the peer.
+func (r *Reports) Get(peer string) (*Report, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.get(peer)
+}
+
+// Delete deletes the compliance report of the peer, eg: to validate its messages again after a fix.
+func (r *Reports) Delete(peer string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, err := r.get(peer); err != nil {
+		return err
+	}
+
+	if err := r.store.Delete(peer); err != nil {
+		return fmt.Errorf("delete compliance report : %w", err)
+	}
+
+	return nil
+}
+
+// List returns the compliance reports, the peers with the most non-compliant messages first, then the most recently
+// seen.
+func (r *Reports) List() ([]*Report, error) {
+	iter, err := r.store.Query(reportTag)
+	if err != nil {
+		return nil, fmt.Errorf("query compliance reports : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	reports := []*Report{}
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate compliance reports : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read compliance report : %w", err)
+		}
+
+		report := &Report{}
+
+		err = json.Unmarshal(val, report)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal compliance report : %w", err)
+		}
+
+		reports = append(reports, report)
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].NonCompliant != reports[j].NonCompliant {
+			return reports[i].NonCompliant > reports[j].NonCompliant
+		}
+
+		return reports[i].LastSeen.After(reports[j].LastSeen)
+	})
+
+	return reports, nil
+}
+
+func (r *Reports) get(peer string) (*Report, error) {
+	val, err := r.store.Get(peer)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get compliance report : %w", err)
+	}
+
+	report := &Report{}
+
+	err = json.Unmarshal(val, report)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal compliance report : %w", err)
+	}
+
+	return report, nil
+}
+
+func (r *Reports) put(report *Report) error {
+	val, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("marshal compliance report : %w", err)
+	}
+
+	err = r.store.Put(report.Peer, val, storage.Tag{Name: reportTag})
+	if err != nil {
+		return fmt.Errorf("save compliance report : %w", err)
+	}
+
+	return nil
+}
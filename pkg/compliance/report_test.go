@@ -0,0 +1,186 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package compliance
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNewReports(t *testing.T) {
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := NewReports(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open compliance store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := NewReports(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set compliance store config")
+	})
+}
+
+func TestReports(t *testing.T) {
+	compliant := &Result{MsgType: "https://didcomm.org/trust_ping/1.0/ping", MsgID: "1"}
+	nonCompliant := &Result{MsgType: "https://didcomm.org/routing/1.0/forward", Errors: []string{"to is required"}}
+
+	t.Run("record, list and delete", func(t *testing.T) {
+		r, err := NewReports(mem.NewProvider())
+		require.NoError(t, err)
+
+		reports, err := r.List()
+		require.NoError(t, err)
+		require.Empty(t, reports)
+
+		report, err := r.Record("key-1", "", compliant, false)
+		require.NoError(t, err)
+		require.True(t, report.Compliant)
+		require.Equal(t, 1, report.Checked)
+
+		_, err = r.Record("key-2", "", compliant, false)
+		require.NoError(t, err)
+
+		_, err = r.Record("key-1", "conn-1", nonCompliant, true)
+		require.NoError(t, err)
+
+		report, err = r.Get("key-1")
+		require.NoError(t, err)
+		require.False(t, report.Compliant)
+		require.Equal(t, "conn-1", report.ConnectionID)
+		require.Equal(t, 2, report.Checked)
+		require.Equal(t, 1, report.NonCompliant)
+		require.Equal(t, 1, report.Rejected)
+		require.Equal(t, &MsgTypeStats{Checked: 1, NonCompliant: 1}, report.MsgTypes[nonCompliant.MsgType])
+		require.Equal(t, &MsgTypeStats{Checked: 1}, report.MsgTypes[compliant.MsgType])
+		require.Len(t, report.Violations, 1)
+		require.True(t, report.Violations[0].Rejected)
+		require.Equal(t, nonCompliant.Errors, report.Violations[0].Errors)
+		require.False(t, report.FirstSeen.After(report.LastSeen))
+
+		reports, err = r.List()
+		require.NoError(t, err)
+		require.Len(t, reports, 2)
+		require.Equal(t, "key-1", reports[0].Peer)
+
+		require.NoError(t, r.Delete("key-1"))
+		require.ErrorIs(t, r.Delete("key-1"), ErrNotFound)
+
+		_, err = r.Get("key-1")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("recent violations", func(t *testing.T) {
+		r, err := NewReports(mem.NewProvider())
+		require.NoError(t, err)
+
+		for i := 0; i < maxViolations+5; i++ {
+			_, err = r.Record(AnonymousPeer, "", &Result{MsgID: fmt.Sprint(i), Errors: []string{"invalid"}}, false)
+			require.NoError(t, err)
+		}
+
+		report, err := r.Get(AnonymousPeer)
+		require.NoError(t, err)
+		require.Equal(t, maxViolations+5, report.NonCompliant)
+		require.Len(t, report.Violations, maxViolations)
+		require.Equal(t, fmt.Sprint(maxViolations+4), report.Violations[0].MsgID)
+		require.Equal(t, maxViolations+5, report.MsgTypes["none"].NonCompliant)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		r, err := NewReports(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}))
+		require.NoError(t, err)
+
+		_, err = r.Record("key-1", "", compliant, false)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save compliance report")
+
+		_, err = r.List()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query compliance reports")
+
+		r, err = NewReports(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}))
+		require.NoError(t, err)
+
+		_, err = r.Record("key-1", "", compliant, false)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get compliance report")
+
+		p := mem.NewProvider()
+
+		r, err = NewReports(p)
+		require.NoError(t, err)
+
+		_, err = r.Record("key-1", "", compliant, false)
+		require.NoError(t, err)
+
+		store, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+		require.NoError(t, store.Put("key-1", []byte("{"), storage.Tag{Name: reportTag}))
+
+		_, err = r.Get("key-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal compliance report")
+
+		_, err = r.List()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal compliance report")
+	})
+
+	t.Run("delete error", func(t *testing.T) {
+		store := &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry)}
+
+		r, err := NewReports(mockstore.NewCustomMockStoreProvider(store))
+		require.NoError(t, err)
+
+		_, err = r.Record("key-1", "", compliant, false)
+		require.NoError(t, err)
+
+		store.ErrDelete = errors.New("delete error")
+
+		err = r.Delete("key-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "delete compliance report")
+	})
+
+	t.Run("iterator errors", func(t *testing.T) {
+		for _, store := range []*mockstore.MockStore{
+			{Store: make(map[string]mockstore.DBEntry), ErrNext: errors.New("next error")},
+			{Store: make(map[string]mockstore.DBEntry), ErrValue: errors.New("value error")},
+		} {
+			r, err := NewReports(mockstore.NewCustomMockStoreProvider(store))
+			require.NoError(t, err)
+
+			_, err = r.Record("key-1", "", compliant, false)
+			require.NoError(t, err)
+
+			_, err = r.List()
+			require.Error(t, err)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression algorithms.
+const (
+	None = "none"
+	Gzip = "gzip"
+	Zstd = "zstd"
+)
+
+// maxDecompressedSize guards against a corrupted record expanding without bound.
+const maxDecompressedSize = 256 << 20
+
+// ErrUnknownAlgorithm is returned for an unsupported compression algorithm.
+var ErrUnknownAlgorithm = errors.New("unknown compression algorithm")
+
+// Codec compresses the stored values. The compressed data starts with the magic number of the codec, so that the
+// values compressed with any codec, or not compressed, are read back transparently.
+type Codec interface {
+	Name() string
+	Magic() []byte
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte) ([]byte, error)
+}
+
+// New returns the codec of the given algorithm, nil for None or an empty algorithm.
+func New(algorithm string) (Codec, error) {
+	switch algorithm {
+	case "", None:
+		return nil, nil
+	case Gzip:
+		return &gzipCodec{}, nil
+	case Zstd:
+		return newZstdCodec()
+	default:
+		return nil, fmt.Errorf("%w : %s", ErrUnknownAlgorithm, algorithm)
+	}
+}
+
+// Algorithms returns the supported compression algorithms.
+func Algorithms() []string {
+	return []string{None, Gzip, Zstd}
+}
+
+type gzipCodec struct{}
+
+func (c *gzipCodec) Name() string {
+	return Gzip
+}
+
+func (c *gzipCodec) Magic() []byte {
+	return []byte{0x1f, 0x8b}
+}
+
+func (c *gzipCodec) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := gzip.NewWriter(&buf)
+
+	_, err := w.Write(data)
+	if err != nil {
+		return nil, fmt.Errorf("gzip compress : %w", err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		return nil, fmt.Errorf("gzip compress : %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (c *gzipCodec) Decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("gzip decompress : %w", err)
+	}
+
+	decompressed, err := ioutil.ReadAll(io.LimitReader(r, maxDecompressedSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("gzip decompress : %w", err)
+	}
+
+	if len(decompressed) > maxDecompressedSize {
+		return nil, errors.New("gzip decompress : decompressed size exceeds the limit")
+	}
+
+	return decompressed, nil
+}
+
+type zstdCodec struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func newZstdCodec() (*zstdCodec, error) {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, fmt.Errorf("create zstd encoder : %w", err)
+	}
+
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxDecompressedSize))
+	if err != nil {
+		return nil, fmt.Errorf("create zstd decoder : %w", err)
+	}
+
+	return &zstdCodec{encoder: encoder, decoder: decoder}, nil
+}
+
+func (c *zstdCodec) Name() string {
+	return Zstd
+}
+
+func (c *zstdCodec) Magic() []byte {
+	return []byte{0x28, 0xb5, 0x2f, 0xfd}
+}
+
+func (c *zstdCodec) Compress(data []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCodec) Decompress(data []byte) ([]byte, error) {
+	decompressed, err := c.decoder.DecodeAll(data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("zstd decompress : %w", err)
+	}
+
+	return decompressed, nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package compression
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"@type":"https://didcomm.org/routing/1.0/forward","msg":"payload"}`), 100)
+
+	for _, algorithm := range []string{Gzip, Zstd} {
+		algorithm := algorithm
+
+		t.Run(algorithm, func(t *testing.T) {
+			c, err := New(algorithm)
+			require.NoError(t, err)
+			require.Equal(t, algorithm, c.Name())
+
+			compressed, err := c.Compress(data)
+			require.NoError(t, err)
+			require.Less(t, len(compressed), len(data))
+			require.True(t, bytes.HasPrefix(compressed, c.Magic()))
+
+			decompressed, err := c.Decompress(compressed)
+			require.NoError(t, err)
+			require.Equal(t, data, decompressed)
+
+			_, err = c.Decompress(append(c.Magic(), "corrupted"...))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), algorithm+" decompress")
+		})
+	}
+
+	t.Run("none", func(t *testing.T) {
+		for _, algorithm := range []string{"", None} {
+			c, err := New(algorithm)
+			require.NoError(t, err)
+			require.Nil(t, c)
+		}
+	})
+
+	t.Run("unknown algorithm", func(t *testing.T) {
+		_, err := New("lz4")
+		require.ErrorIs(t, err, ErrUnknownAlgorithm)
+	})
+
+	require.Equal(t, []string{None, Gzip, Zstd}, Algorithms())
+}
@@ -0,0 +1,234 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package compression
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// Stats of the values written to the compressed stores.
+type Stats struct {
+	Algorithm string `json:"algorithm"`
+	// Writes is the number of values written.
+	Writes uint64 `json:"writes"`
+	// Compressed is the number of values stored compressed; the values that don't shrink are stored as is.
+	Compressed        uint64  `json:"compressed"`
+	UncompressedBytes uint64  `json:"uncompressedBytes"`
+	StoredBytes       uint64  `json:"storedBytes"`
+	Ratio             float64 `json:"ratio"`
+}
+
+// Provider is a storage provider compressing the values of the given stores (eg: the pickup mailboxes) before they
+// are persisted, and decompressing them when they are read. The values stored before the compression was enabled, or
+// with another algorithm, are read back as is. The values of the compressed stores must not start with the magic
+// number of a codec, which JSON values never do.
+type Provider struct {
+	storage.Provider
+	codec        Codec
+	codecs       []Codec
+	stores       map[string]bool
+	writes       uint64
+	compressed   uint64
+	uncompressed uint64
+	stored       uint64
+}
+
+// NewProvider returns a new Provider compressing the values of the given stores with the codec; a nil codec stores
+// the new values uncompressed, while still reading back the compressed ones.
+func NewProvider(p storage.Provider, codec Codec, stores ...string) (*Provider, error) {
+	cp := &Provider{Provider: p, codec: codec, stores: make(map[string]bool, len(stores))}
+
+	for _, name := range stores {
+		cp.stores[name] = true
+	}
+
+	for _, algorithm := range []string{Gzip, Zstd} {
+		if codec != nil && codec.Name() == algorithm {
+			continue
+		}
+
+		c, err := New(algorithm)
+		if err != nil {
+			return nil, err
+		}
+
+		cp.codecs = append(cp.codecs, c)
+	}
+
+	if codec != nil {
+		cp.codecs = append(cp.codecs, codec)
+	}
+
+	return cp, nil
+}
+
+// OpenStore opens the store, compressed if it is one of the compressed stores.
+func (p *Provider) OpenStore(name string) (storage.Store, error) {
+	s, err := p.Provider.OpenStore(name)
+	if err != nil || !p.stores[name] {
+		return s, err
+	}
+
+	return &store{Store: s, provider: p}, nil
+}
+
+// Stats returns the compression stats since the router started.
+func (p *Provider) Stats() *Stats {
+	stats := &Stats{
+		Algorithm:         None,
+		Writes:            atomic.LoadUint64(&p.writes),
+		Compressed:        atomic.LoadUint64(&p.compressed),
+		UncompressedBytes: atomic.LoadUint64(&p.uncompressed),
+		StoredBytes:       atomic.LoadUint64(&p.stored),
+	}
+
+	if p.codec != nil {
+		stats.Algorithm = p.codec.Name()
+	}
+
+	if stats.StoredBytes > 0 {
+		stats.Ratio = float64(stats.UncompressedBytes) / float64(stats.StoredBytes)
+	}
+
+	return stats
+}
+
+func (p *Provider) compress(value []byte) ([]byte, error) {
+	stored := value
+
+	if p.codec != nil {
+		compressed, err := p.codec.Compress(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(compressed) < len(value) {
+			stored = compressed
+
+			atomic.AddUint64(&p.compressed, 1)
+		}
+	}
+
+	atomic.AddUint64(&p.writes, 1)
+	atomic.AddUint64(&p.uncompressed, uint64(len(value)))
+	atomic.AddUint64(&p.stored, uint64(len(stored)))
+
+	return stored, nil
+}
+
+func (p *Provider) decompress(value []byte) ([]byte, error) {
+	for _, c := range p.codecs {
+		if bytes.HasPrefix(value, c.Magic()) {
+			return c.Decompress(value)
+		}
+	}
+
+	return value, nil
+}
+
+// store compresses the values written to the underlying store.
+type store struct {
+	storage.Store
+	provider *Provider
+}
+
+func (s *store) Put(key string, value []byte, tags ...storage.Tag) error {
+	compressed, err := s.provider.compress(value)
+	if err != nil {
+		return fmt.Errorf("compress %s : %w", key, err)
+	}
+
+	return s.Store.Put(key, compressed, tags...)
+}
+
+func (s *store) Get(key string) ([]byte, error) {
+	value, err := s.Store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.decompress(key, value)
+}
+
+func (s *store) GetBulk(keys ...string) ([][]byte, error) {
+	values, err := s.Store.GetBulk(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+
+		values[i], err = s.decompress(keys[i], value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+func (s *store) Query(expression string, options ...storage.QueryOption) (storage.Iterator, error) {
+	it, err := s.Store.Query(expression, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &iterator{Iterator: it, provider: s.provider}, nil
+}
+
+func (s *store) Batch(operations []storage.Operation) error {
+	ops := make([]storage.Operation, len(operations))
+
+	for i, op := range operations {
+		ops[i] = op
+
+		// a nil value deletes the key
+		if op.Value == nil {
+			continue
+		}
+
+		value, err := s.provider.compress(op.Value)
+		if err != nil {
+			return fmt.Errorf("compress %s : %w", op.Key, err)
+		}
+
+		ops[i].Value = value
+	}
+
+	return s.Store.Batch(ops)
+}
+
+func (s *store) decompress(key string, value []byte) ([]byte, error) {
+	decompressed, err := s.provider.decompress(value)
+	if err != nil {
+		return nil, fmt.Errorf("decompress %s : %w", key, err)
+	}
+
+	return decompressed, nil
+}
+
+// iterator decompresses the values of the query results.
+type iterator struct {
+	storage.Iterator
+	provider *Provider
+}
+
+func (it *iterator) Value() ([]byte, error) {
+	value, err := it.Iterator.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	return it.provider.decompress(value)
+}
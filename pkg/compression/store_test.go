@@ -0,0 +1,235 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvider(t *testing.T) {
+	inbox := bytes.Repeat([]byte(`{"message_count":1,"messages":["eyJwcm90ZWN0ZWQiOiJleUpsYm1NaU9pSllRekl3In0"]}`), 20)
+
+	t.Run("compressed store", func(t *testing.T) {
+		mp := mem.NewProvider()
+
+		c, err := New(Zstd)
+		require.NoError(t, err)
+
+		p, err := NewProvider(mp, c, "mailbox")
+		require.NoError(t, err)
+
+		s, err := p.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put("did:example:1", inbox, storage.Tag{Name: "inbox"}))
+		require.NoError(t, s.Put("did:example:2", []byte(`{}`), storage.Tag{Name: "inbox"}))
+
+		raw, err := mp.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		stored, err := raw.Get("did:example:1")
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(stored, c.Magic()))
+		require.Less(t, len(stored), len(inbox))
+
+		// too small to shrink : stored as is
+		stored, err = raw.Get("did:example:2")
+		require.NoError(t, err)
+		require.Equal(t, []byte(`{}`), stored)
+
+		value, err := s.Get("did:example:1")
+		require.NoError(t, err)
+		require.Equal(t, inbox, value)
+
+		values, err := s.GetBulk("did:example:1", "did:example:2", "did:example:3")
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{inbox, []byte(`{}`), nil}, values)
+
+		it, err := s.Query("inbox")
+		require.NoError(t, err)
+
+		for {
+			more, errNext := it.Next()
+			require.NoError(t, errNext)
+
+			if !more {
+				break
+			}
+
+			value, err = it.Value()
+			require.NoError(t, err)
+			require.True(t, bytes.HasPrefix(value, []byte("{")))
+		}
+
+		require.NoError(t, it.Close())
+
+		stats := p.Stats()
+		require.Equal(t, Zstd, stats.Algorithm)
+		require.Equal(t, uint64(2), stats.Writes)
+		require.Equal(t, uint64(1), stats.Compressed)
+		require.Equal(t, uint64(len(inbox)+2), stats.UncompressedBytes)
+		require.Greater(t, stats.Ratio, 1.0)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		c, err := New(Gzip)
+		require.NoError(t, err)
+
+		p, err := NewProvider(mem.NewProvider(), c, "mailbox")
+		require.NoError(t, err)
+
+		s, err := p.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put("did:example:2", inbox))
+		require.NoError(t, s.Batch([]storage.Operation{
+			{Key: "did:example:1", Value: inbox},
+			{Key: "did:example:2"},
+		}))
+
+		value, err := s.Get("did:example:1")
+		require.NoError(t, err)
+		require.Equal(t, inbox, value)
+
+		_, err = s.Get("did:example:2")
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+	})
+
+	t.Run("reads values stored with another algorithm or uncompressed", func(t *testing.T) {
+		mp := mem.NewProvider()
+
+		gz, err := New(Gzip)
+		require.NoError(t, err)
+
+		p, err := NewProvider(mp, gz, "mailbox")
+		require.NoError(t, err)
+
+		s, err := p.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put("did:example:1", inbox))
+
+		raw, err := mp.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		require.NoError(t, raw.Put("did:example:2", inbox))
+
+		for _, algorithm := range []string{Zstd, None} {
+			c, errNew := New(algorithm)
+			require.NoError(t, errNew)
+
+			p, err = NewProvider(mp, c, "mailbox")
+			require.NoError(t, err)
+
+			s, err = p.OpenStore("mailbox")
+			require.NoError(t, err)
+
+			for _, key := range []string{"did:example:1", "did:example:2"} {
+				value, errGet := s.Get(key)
+				require.NoError(t, errGet)
+				require.Equal(t, inbox, value)
+			}
+		}
+
+		require.NoError(t, s.Put("did:example:3", inbox))
+
+		value, err := raw.Get("did:example:3")
+		require.NoError(t, err)
+		require.Equal(t, inbox, value)
+
+		stats := p.Stats()
+		require.Equal(t, None, stats.Algorithm)
+		require.Equal(t, 1.0, stats.Ratio)
+	})
+
+	t.Run("other stores are not compressed", func(t *testing.T) {
+		mp := mem.NewProvider()
+
+		c, err := New(Gzip)
+		require.NoError(t, err)
+
+		p, err := NewProvider(mp, c, "mailbox")
+		require.NoError(t, err)
+
+		s, err := p.OpenStore("didexchange")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put("key", inbox))
+
+		raw, err := mp.OpenStore("didexchange")
+		require.NoError(t, err)
+
+		value, err := raw.Get("key")
+		require.NoError(t, err)
+		require.Equal(t, inbox, value)
+		require.Zero(t, p.Stats().Ratio)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mp := mem.NewProvider()
+
+		p, err := NewProvider(mp, &failingCodec{}, "mailbox")
+		require.NoError(t, err)
+
+		s, err := p.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		err = s.Put("did:example:1", inbox)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "compress did:example:1")
+
+		err = s.Batch([]storage.Operation{{Key: "did:example:1", Value: inbox}})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "compress did:example:1")
+
+		raw, err := mp.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		require.NoError(t, raw.Put("did:example:1", []byte("fail")))
+
+		_, err = s.Get("did:example:1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "decompress did:example:1")
+
+		_, err = s.GetBulk("did:example:1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "decompress did:example:1")
+
+		_, err = s.Get("did:example:2")
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+
+		_, err = s.GetBulk()
+		require.Error(t, err)
+
+		_, err = s.Query("")
+		require.Error(t, err)
+	})
+}
+
+type failingCodec struct{}
+
+func (c *failingCodec) Name() string {
+	return "failing"
+}
+
+func (c *failingCodec) Magic() []byte {
+	return []byte("fail")
+}
+
+func (c *failingCodec) Compress([]byte) ([]byte, error) {
+	return nil, errors.New("compress failed")
+}
+
+func (c *failingCodec) Decompress([]byte) ([]byte, error) {
+	return nil, errors.New("decompress failed")
+}
@@ -0,0 +1,206 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connpool
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Pool defaults.
+const (
+	DefaultMaxIdlePerHost = 16
+	DefaultMaxIdle        = 256
+	DefaultIdleTimeout    = 90 * time.Second
+
+	// maxDestinations is the number of destinations tracked, the requests to the others are counted under
+	// otherDestinations.
+	maxDestinations   = 1024
+	otherDestinations = "*"
+	// topDestinations is the number of busiest destinations returned by the stats.
+	topDestinations = 20
+)
+
+// Config of the outbound connection pool.
+type Config struct {
+	// MaxIdlePerHost is the number of idle connections kept open per destination, DefaultMaxIdlePerHost if zero.
+	MaxIdlePerHost int
+	// MaxIdle is the number of idle connections kept open across the destinations, DefaultMaxIdle if zero.
+	MaxIdle int
+	// IdleTimeout is the time an idle connection is kept open for, DefaultIdleTimeout if zero.
+	IdleTimeout time.Duration
+}
+
+// DestinationStats are the pool counters of a destination host.
+type DestinationStats struct {
+	Host     string `json:"host,omitempty"`
+	Requests uint64 `json:"requests"`
+	// HTTP2Requests is the number of requests served over HTTP/2.
+	HTTP2Requests uint64 `json:"http2Requests"`
+	// NewConns is the number of connections opened, ReusedConns the number of requests sent on a pooled one.
+	NewConns      uint64 `json:"newConns"`
+	ReusedConns   uint64 `json:"reusedConns"`
+	TLSHandshakes uint64 `json:"tlsHandshakes"`
+	Errors        uint64 `json:"errors"`
+}
+
+// Stats of the outbound connection pool since the router started.
+type Stats struct {
+	MaxIdlePerHost int `json:"maxIdlePerHost"`
+	MaxIdle        int `json:"maxIdle"`
+	// IdleTimeout is in seconds.
+	IdleTimeout int `json:"idleTimeout"`
+	// DestinationStats are the counters across the destinations.
+	DestinationStats
+	// Destinations are the busiest destinations.
+	Destinations []*DestinationStats `json:"destinations"`
+}
+
+// Transport pools and reuses the outbound connections per destination, negotiating HTTP/2 when the destination
+// supports it so that the requests to the same adapter are multiplexed over a single TLS connection.
+type Transport struct {
+	base         *http.Transport
+	mutex        sync.Mutex
+	destinations map[string]*DestinationStats
+}
+
+// NewTransport returns a new Transport pooling the connections of the given base transport, configured with the
+// pool settings.
+func NewTransport(base *http.Transport, config *Config) *Transport {
+	if config == nil {
+		config = &Config{}
+	}
+
+	base.ForceAttemptHTTP2 = true
+	base.MaxIdleConnsPerHost = withDefault(config.MaxIdlePerHost, DefaultMaxIdlePerHost)
+	base.MaxIdleConns = withDefault(config.MaxIdle, DefaultMaxIdle)
+	base.IdleConnTimeout = config.IdleTimeout
+
+	if base.IdleConnTimeout <= 0 {
+		base.IdleConnTimeout = DefaultIdleTimeout
+	}
+
+	if base.TLSClientConfig == nil {
+		base.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	return &Transport{base: base, destinations: make(map[string]*DestinationStats)}
+}
+
+// RoundTrip sends the request over a pooled connection, counting the connections opened and reused.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Host
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.count(host, func(s *DestinationStats) {
+				if info.Reused {
+					s.ReusedConns++
+				} else {
+					s.NewConns++
+				}
+			})
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				t.count(host, func(s *DestinationStats) { s.TLSHandshakes++ })
+			}
+		},
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+
+	t.count(host, func(s *DestinationStats) {
+		s.Requests++
+
+		switch {
+		case err != nil:
+			s.Errors++
+		case resp.ProtoMajor == 2: // nolint:gomnd // HTTP/2
+			s.HTTP2Requests++
+		}
+	})
+
+	return resp, err
+}
+
+// CloseIdleConnections closes the idle pooled connections.
+func (t *Transport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+// Stats returns the pool stats, with the busiest destinations first.
+func (t *Transport) Stats() *Stats {
+	stats := &Stats{
+		MaxIdlePerHost: t.base.MaxIdleConnsPerHost,
+		MaxIdle:        t.base.MaxIdleConns,
+		IdleTimeout:    int(t.base.IdleConnTimeout / time.Second),
+		Destinations:   []*DestinationStats{},
+	}
+
+	t.mutex.Lock()
+
+	for _, d := range t.destinations {
+		s := *d
+		stats.Destinations = append(stats.Destinations, &s)
+
+		stats.Requests += s.Requests
+		stats.HTTP2Requests += s.HTTP2Requests
+		stats.NewConns += s.NewConns
+		stats.ReusedConns += s.ReusedConns
+		stats.TLSHandshakes += s.TLSHandshakes
+		stats.Errors += s.Errors
+	}
+
+	t.mutex.Unlock()
+
+	sort.Slice(stats.Destinations, func(i, j int) bool {
+		if stats.Destinations[i].Requests != stats.Destinations[j].Requests {
+			return stats.Destinations[i].Requests > stats.Destinations[j].Requests
+		}
+
+		return stats.Destinations[i].Host < stats.Destinations[j].Host
+	})
+
+	if len(stats.Destinations) > topDestinations {
+		stats.Destinations = stats.Destinations[:topDestinations]
+	}
+
+	return stats
+}
+
+func (t *Transport) count(host string, update func(*DestinationStats)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	s, ok := t.destinations[host]
+	if !ok {
+		if len(t.destinations) >= maxDestinations {
+			host = otherDestinations
+		}
+
+		s, ok = t.destinations[host]
+		if !ok {
+			s = &DestinationStats{Host: host}
+			t.destinations[host] = s
+		}
+	}
+
+	update(s)
+}
+
+func withDefault(value, defaultValue int) int {
+	if value <= 0 {
+		return defaultValue
+	}
+
+	return value
+}
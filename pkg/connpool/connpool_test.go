@@ -0,0 +1,107 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package connpool
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func get(t *testing.T, client *http.Client, url string) {
+	t.Helper()
+
+	resp, err := client.Get(url) // nolint:noctx // test request
+	require.NoError(t, err)
+
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+}
+
+func TestTransport(t *testing.T) {
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+
+	defer srv.Close()
+
+	t.Run("reuses the HTTP/2 connections", func(t *testing.T) {
+		tlsConfig := srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+		tlsConfig.NextProtos = nil
+
+		pool := NewTransport(&http.Transport{TLSClientConfig: tlsConfig}, &Config{
+			MaxIdlePerHost: 4, MaxIdle: 32, IdleTimeout: time.Minute,
+		})
+		defer pool.CloseIdleConnections()
+
+		client := &http.Client{Transport: pool}
+
+		for i := 0; i < 3; i++ {
+			get(t, client, srv.URL)
+		}
+
+		stats := pool.Stats()
+		require.Equal(t, 4, stats.MaxIdlePerHost)
+		require.Equal(t, 32, stats.MaxIdle)
+		require.Equal(t, 60, stats.IdleTimeout)
+		require.Equal(t, uint64(3), stats.Requests)
+		require.Equal(t, uint64(3), stats.HTTP2Requests)
+		require.Equal(t, uint64(1), stats.NewConns)
+		require.Equal(t, uint64(2), stats.ReusedConns)
+		require.Equal(t, uint64(1), stats.TLSHandshakes)
+		require.Len(t, stats.Destinations, 1)
+		require.Equal(t, srv.Listener.Addr().String(), stats.Destinations[0].Host)
+	})
+
+	t.Run("counts the errors", func(t *testing.T) {
+		pool := NewTransport(&http.Transport{}, nil)
+
+		resp, err := (&http.Client{Transport: pool}).Get(srv.URL) // nolint:noctx // test request
+		require.Error(t, err)
+		require.Nil(t, resp)
+
+		stats := pool.Stats()
+		require.Equal(t, DefaultMaxIdlePerHost, stats.MaxIdlePerHost)
+		require.Equal(t, DefaultMaxIdle, stats.MaxIdle)
+		require.Equal(t, int(DefaultIdleTimeout/time.Second), stats.IdleTimeout)
+		require.Equal(t, uint64(1), stats.Errors)
+		require.Equal(t, uint64(0), stats.TLSHandshakes)
+		require.Equal(t, uint64(tls.VersionTLS12), uint64(pool.base.TLSClientConfig.MinVersion))
+	})
+}
+
+func TestStats(t *testing.T) {
+	pool := NewTransport(&http.Transport{}, nil)
+
+	for i := 0; i < maxDestinations+10; i++ {
+		for j := 0; j <= i%3; j++ {
+			pool.count(fmt.Sprintf("host-%d:443", i), func(s *DestinationStats) { s.Requests++ })
+		}
+	}
+
+	pool.count("host-0:443", func(s *DestinationStats) { s.Requests += 10 })
+
+	stats := pool.Stats()
+	require.Len(t, stats.Destinations, topDestinations)
+	require.Equal(t, otherDestinations, stats.Destinations[0].Host)
+	require.Equal(t, "host-0:443", stats.Destinations[1].Host)
+	require.Equal(t, uint64(11), stats.Destinations[1].Requests)
+
+	for i := 2; i < topDestinations; i++ {
+		require.Equal(t, uint64(3), stats.Destinations[i].Requests)
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package correlation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "correlation"
+
+	// ThreadIDTag tags records by DIDComm thread ID.
+	ThreadIDTag = "threadID"
+	// ParentThreadIDTag tags records by DIDComm parent thread ID, eg: the invitation ID for didexchange.
+	ParentThreadIDTag = "parentThreadID"
+	// ConnectionIDTag tags records by connection ID.
+	ConnectionIDTag = "connectionID"
+	// CorrelationIDTag tags records by the originating REST/webhook correlation ID.
+	CorrelationIDTag = "correlationID"
+)
+
+var logger = log.New("hub-router/correlation")
+
+// Record links a DIDComm thread to a connection and to the REST/webhook request that originated it.
+type Record struct {
+	ID             string    `json:"id"`
+	Time           time.Time `json:"time"`
+	ThreadID       string    `json:"threadID,omitempty"`
+	ParentThreadID string    `json:"parentThreadID,omitempty"`
+	ConnectionID   string    `json:"connectionID,omitempty"`
+	CorrelationID  string    `json:"correlationID,omitempty"`
+	MsgID          string    `json:"msgID,omitempty"`
+	MsgType        string    `json:"msgType,omitempty"`
+}
+
+// Store persists and queries correlation records.
+type Store struct {
+	store storage.Store
+}
+
+// New returns a new correlation Store backed by the given storage provider.
+func New(p storage.Provider) (*Store, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open correlation store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{
+		TagNames: []string{ThreadIDTag, ParentThreadIDTag, ConnectionIDTag, CorrelationIDTag},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("set correlation store config : %w", err)
+	}
+
+	return &Store{store: store}, nil
+}
+
+// Save persists the record, setting the ID and Time if they are empty.
+func (s *Store) Save(r *Record) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+
+	if r.Time.IsZero() {
+		r.Time = time.Now().UTC()
+	}
+
+	recordBytes, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal correlation record : %w", err)
+	}
+
+	var tags []storage.Tag
+
+	for name, val := range map[string]string{
+		ThreadIDTag:       r.ThreadID,
+		ParentThreadIDTag: r.ParentThreadID,
+		ConnectionIDTag:   r.ConnectionID,
+		CorrelationIDTag:  r.CorrelationID,
+	} {
+		if val != "" {
+			tags = append(tags, storage.Tag{Name: name, Value: val})
+		}
+	}
+
+	err = s.store.Put(r.ID, recordBytes, tags...)
+	if err != nil {
+		return fmt.Errorf("save correlation record : %w", err)
+	}
+
+	return nil
+}
+
+// Thread returns the records of the thread and of its child threads ordered by time.
+func (s *Store) Thread(threadID string) ([]*Record, error) {
+	records, err := s.Find(ThreadIDTag, threadID)
+	if err != nil {
+		return nil, err
+	}
+
+	children, err := s.Find(ParentThreadIDTag, threadID)
+	if err != nil {
+		return nil, err
+	}
+
+	records = append(records, children...)
+
+	sortByTime(records)
+
+	return records, nil
+}
+
+// Find returns the records with the given tag value ordered by time.
+func (s *Store) Find(tagName, value string) ([]*Record, error) {
+	if value == "" {
+		return nil, errors.New("correlation query value is mandatory")
+	}
+
+	iter, err := s.store.Query(fmt.Sprintf("%s:%s", tagName, value))
+	if err != nil {
+		return nil, fmt.Errorf("query correlation records : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var records []*Record
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate correlation records : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read correlation record : %w", err)
+		}
+
+		r := &Record{}
+
+		err = json.Unmarshal(val, r)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal correlation record : %w", err)
+		}
+
+		records = append(records, r)
+	}
+
+	sortByTime(records)
+
+	return records, nil
+}
+
+func sortByTime(records []*Record) {
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.Before(records[j].Time)
+	})
+}
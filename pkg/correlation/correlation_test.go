@@ -0,0 +1,127 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package correlation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+		require.NotNil(t, s)
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open correlation store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set correlation store config")
+	})
+}
+
+func TestStore(t *testing.T) {
+	t.Run("save and find", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		now := time.Now()
+
+		require.NoError(t, s.Save(&Record{ThreadID: "inv-1", CorrelationID: "corr-1", Time: now}))
+		require.NoError(t, s.Save(&Record{
+			ThreadID: "thid-1", ParentThreadID: "inv-1", ConnectionID: "conn-1", Time: now.Add(time.Second),
+		}))
+		require.NoError(t, s.Save(&Record{ThreadID: "thid-2", ConnectionID: "conn-1", Time: now.Add(2 * time.Second)}))
+
+		records, err := s.Find(CorrelationIDTag, "corr-1")
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		require.Equal(t, "inv-1", records[0].ThreadID)
+		require.NotEmpty(t, records[0].ID)
+
+		records, err = s.Find(ConnectionIDTag, "conn-1")
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		require.Equal(t, "thid-1", records[0].ThreadID)
+
+		records, err = s.Thread("inv-1")
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		require.Equal(t, "corr-1", records[0].CorrelationID)
+		require.Equal(t, "conn-1", records[1].ConnectionID)
+	})
+
+	t.Run("missing query value", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		_, err = s.Find(ThreadIDTag, "")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "correlation query value is mandatory")
+
+		_, err = s.Thread("")
+		require.Error(t, err)
+	})
+
+	t.Run("save error", func(t *testing.T) {
+		s := &Store{store: &mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+		}}
+
+		err := s.Save(&Record{ThreadID: "thid"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save correlation record")
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		s := &Store{store: &mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrQuery: errors.New("query error"),
+		}}
+
+		_, err := s.Thread("thid")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query correlation records")
+	})
+
+	t.Run("invalid record", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		require.NoError(t, s.store.Put("id", []byte("invalid"), storageTag(ThreadIDTag, "thid")))
+
+		_, err = s.Find(ThreadIDTag, "thid")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal correlation record")
+	})
+}
+
+func storageTag(name, value string) storage.Tag {
+	return storage.Tag{Name: name, Value: value}
+}
@@ -0,0 +1,226 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package credrotation
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+// DefaultDrain is the time the previous connections are kept open after a rotation.
+const DefaultDrain = 30 * time.Second
+
+var logger = log.New("hub-router/credrotation")
+
+// OpenFunc connects a storage provider with the given DSN, which carries the database credentials.
+type OpenFunc func(dsn string) (storage.Provider, error)
+
+// Provider is a storage provider connected through an underlying provider that can be replaced at runtime, with new
+// database credentials, without closing the stores opened by the router. The previous underlying provider is closed
+// once the drain period elapsed, so that the operations started on its connections (eg: a forward being queued, or
+// an iterator being read) complete.
+type Provider struct {
+	open    OpenFunc
+	drain   time.Duration
+	mutex   sync.RWMutex
+	current storage.Provider
+	dsn     string
+	stores  map[string]*store
+	rotate  sync.Mutex
+}
+
+// New returns a new Provider, connected with the given DSN. A zero drain period defaults to DefaultDrain.
+func New(open OpenFunc, dsn string, drain time.Duration) (*Provider, error) {
+	current, err := open(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if drain <= 0 {
+		drain = DefaultDrain
+	}
+
+	return &Provider{open: open, drain: drain, current: current, dsn: dsn, stores: make(map[string]*store)}, nil
+}
+
+// Rotate connects a new underlying provider with the given DSN, reopens the stores on it, then switches the stores
+// to it. The current provider is kept if the new DSN fails, so that a wrong secret doesn't take the router down.
+func (p *Provider) Rotate(dsn string) error {
+	p.rotate.Lock()
+	defer p.rotate.Unlock()
+
+	if dsn == p.dsn {
+		return nil
+	}
+
+	next, err := p.open(dsn)
+	if err != nil {
+		return fmt.Errorf("connect with rotated credentials : %w", err)
+	}
+
+	p.mutex.Lock()
+
+	// the stores are reopened under the lock, so that no store is opened on the previous provider meanwhile
+	nextStores := make(map[string]storage.Store, len(p.stores))
+
+	for name := range p.stores {
+		nextStores[name], err = next.OpenStore(name)
+		if err != nil {
+			p.mutex.Unlock()
+
+			closeProvider(next)
+
+			return fmt.Errorf("reopen store %s with rotated credentials : %w", name, err)
+		}
+	}
+
+	prev := p.current
+	p.current = next
+	p.dsn = dsn
+
+	for name, s := range p.stores {
+		s.current = nextStores[name]
+	}
+
+	p.mutex.Unlock()
+
+	logger.Infof("storage credentials rotated, previous connections closed in %s", p.drain)
+
+	time.AfterFunc(p.drain, func() {
+		closeProvider(prev)
+	})
+
+	return nil
+}
+
+// OpenStore opens the store on the current underlying provider.
+func (p *Provider) OpenStore(name string) (storage.Store, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if s, ok := p.stores[name]; ok {
+		return s, nil
+	}
+
+	current, err := p.current.OpenStore(name)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &store{provider: p, name: name, current: current}
+	p.stores[name] = s
+
+	return s, nil
+}
+
+// SetStoreConfig sets the store configuration on the current underlying provider.
+func (p *Provider) SetStoreConfig(name string, config storage.StoreConfiguration) error {
+	return p.provider().SetStoreConfig(name, config)
+}
+
+// GetStoreConfig returns the store configuration from the current underlying provider.
+func (p *Provider) GetStoreConfig(name string) (storage.StoreConfiguration, error) {
+	return p.provider().GetStoreConfig(name)
+}
+
+// GetOpenStores returns the opened stores.
+func (p *Provider) GetOpenStores() []storage.Store {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	stores := make([]storage.Store, 0, len(p.stores))
+
+	for _, s := range p.stores {
+		stores = append(stores, s)
+	}
+
+	return stores
+}
+
+// Close closes the current underlying provider.
+func (p *Provider) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.stores = make(map[string]*store)
+
+	return p.current.Close()
+}
+
+func (p *Provider) provider() storage.Provider {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	return p.current
+}
+
+func closeProvider(p storage.Provider) {
+	err := p.Close()
+	if err != nil && !errors.Is(err, storage.ErrStoreNotFound) {
+		logger.Warnf("failed to close storage provider : %s", err)
+	}
+}
+
+// store delegates to the store opened on the current underlying provider.
+type store struct {
+	provider *Provider
+	name     string
+	current  storage.Store
+}
+
+func (s *store) get() storage.Store {
+	s.provider.mutex.RLock()
+	defer s.provider.mutex.RUnlock()
+
+	return s.current
+}
+
+func (s *store) Put(key string, value []byte, tags ...storage.Tag) error {
+	return s.get().Put(key, value, tags...)
+}
+
+func (s *store) Get(key string) ([]byte, error) {
+	return s.get().Get(key)
+}
+
+func (s *store) GetTags(key string) ([]storage.Tag, error) {
+	return s.get().GetTags(key)
+}
+
+func (s *store) GetBulk(keys ...string) ([][]byte, error) {
+	return s.get().GetBulk(keys...)
+}
+
+func (s *store) Query(expression string, options ...storage.QueryOption) (storage.Iterator, error) {
+	return s.get().Query(expression, options...)
+}
+
+func (s *store) Delete(key string) error {
+	return s.get().Delete(key)
+}
+
+func (s *store) Batch(operations []storage.Operation) error {
+	return s.get().Batch(operations)
+}
+
+func (s *store) Flush() error {
+	return s.get().Flush()
+}
+
+func (s *store) Close() error {
+	s.provider.mutex.Lock()
+	delete(s.provider.stores, s.name)
+	current := s.current
+	s.provider.mutex.Unlock()
+
+	return current.Close()
+}
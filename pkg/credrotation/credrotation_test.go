@@ -0,0 +1,149 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package credrotation
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// database is shared by the providers connected with the valid credentials.
+type database struct {
+	storage.Provider
+	mutex  sync.Mutex
+	closed map[string]bool
+	failOn string
+}
+
+type connection struct {
+	storage.Provider
+	db  *database
+	dsn string
+}
+
+func (c *connection) OpenStore(name string) (storage.Store, error) {
+	if c.dsn == c.db.failOn {
+		return nil, errors.New("open store error")
+	}
+
+	return c.Provider.OpenStore(name)
+}
+
+func (c *connection) Close() error {
+	c.db.mutex.Lock()
+	defer c.db.mutex.Unlock()
+
+	c.db.closed[c.dsn] = true
+
+	return nil
+}
+
+func newDatabase() *database {
+	return &database{Provider: mem.NewProvider(), closed: make(map[string]bool)}
+}
+
+func (db *database) open(dsn string) (storage.Provider, error) {
+	if dsn == "invalid" {
+		return nil, errors.New("access denied")
+	}
+
+	return &connection{Provider: db.Provider, db: db, dsn: dsn}, nil
+}
+
+func (db *database) isClosed(dsn string) bool {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	return db.closed[dsn]
+}
+
+func TestProvider(t *testing.T) {
+	t.Run("rotate", func(t *testing.T) {
+		db := newDatabase()
+
+		p, err := New(db.open, "user-1", time.Millisecond)
+		require.NoError(t, err)
+
+		s, err := p.OpenStore("forwards")
+		require.NoError(t, err)
+		require.NoError(t, p.SetStoreConfig("forwards", storage.StoreConfiguration{TagNames: []string{"tag"}}))
+		require.NoError(t, s.Put("msg-1", []byte("data"), storage.Tag{Name: "tag"}))
+
+		require.NoError(t, p.Rotate("user-1"))
+		require.NoError(t, p.Rotate("user-2"))
+
+		value, err := s.Get("msg-1")
+		require.NoError(t, err)
+		require.Equal(t, []byte("data"), value)
+
+		config, err := p.GetStoreConfig("forwards")
+		require.NoError(t, err)
+		require.Equal(t, []string{"tag"}, config.TagNames)
+
+		require.NoError(t, s.Put("msg-2", []byte("data")))
+		require.NoError(t, s.Batch([]storage.Operation{{Key: "msg-3", Value: []byte("data")}}))
+		require.NoError(t, s.Flush())
+
+		values, err := s.GetBulk("msg-1", "msg-2", "msg-3")
+		require.NoError(t, err)
+		require.Len(t, values, 3)
+
+		tags, err := s.GetTags("msg-1")
+		require.NoError(t, err)
+		require.Len(t, tags, 1)
+
+		iter, err := s.Query("tag")
+		require.NoError(t, err)
+		require.NoError(t, iter.Close())
+
+		require.NoError(t, s.Delete("msg-2"))
+		require.Len(t, p.GetOpenStores(), 1)
+
+		require.Eventually(t, func() bool { return db.isClosed("user-1") }, time.Second, time.Millisecond)
+		require.False(t, db.isClosed("user-2"))
+
+		require.NoError(t, s.Close())
+		require.Empty(t, p.GetOpenStores())
+		require.NoError(t, p.Close())
+		require.True(t, db.isClosed("user-2"))
+	})
+
+	t.Run("failed rotation keeps the current credentials", func(t *testing.T) {
+		db := newDatabase()
+		db.failOn = "user-3"
+
+		p, err := New(db.open, "user-1", 0)
+		require.NoError(t, err)
+		require.Equal(t, DefaultDrain, p.drain)
+
+		s, err := p.OpenStore("forwards")
+		require.NoError(t, err)
+
+		err = p.Rotate("invalid")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "access denied")
+
+		err = p.Rotate("user-3")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "reopen store forwards")
+		require.True(t, db.isClosed("user-3"))
+
+		require.NoError(t, s.Put("msg-1", []byte("data")))
+		require.Equal(t, "user-1", p.dsn)
+	})
+
+	t.Run("connect error", func(t *testing.T) {
+		_, err := New(newDatabase().open, "invalid", 0)
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,122 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package credrotation
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultReloadInterval is the default interval between two reads of the secret file.
+const DefaultReloadInterval = 30 * time.Second
+
+// ReadDSN reads the DSN from the secret file, ignoring the surrounding whitespace.
+func ReadDSN(file string) (string, error) {
+	dsnBytes, err := ioutil.ReadFile(file) // nolint:gosec // file path is set by the operator
+	if err != nil {
+		return "", fmt.Errorf("read dsn file : %w", err)
+	}
+
+	dsn := strings.TrimSpace(string(dsnBytes))
+	if dsn == "" {
+		return "", errors.New("dsn file is empty")
+	}
+
+	return dsn, nil
+}
+
+// Rotator is connected with the DSN of a secret file, and reconnects with the new DSN when the file changes, eg: a
+// Provider, or the locks of the database.
+type Rotator interface {
+	Rotate(dsn string) error
+}
+
+// Watcher reloads the DSN from a secret file (eg: a mounted Kubernetes secret), and rotates the credentials of the
+// rotators connected with it when it changes.
+type Watcher struct {
+	file     string
+	mutex    sync.Mutex
+	dsn      string
+	rotators []Rotator
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWatcher returns a new Watcher of the secret file, holding the given DSN.
+func NewWatcher(file, dsn string) *Watcher {
+	return &Watcher{file: file, dsn: dsn, done: make(chan struct{})}
+}
+
+// Add adds a rotator to rotate when the DSN changes.
+func (w *Watcher) Add(r Rotator) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.rotators = append(w.rotators, r)
+}
+
+// Reload reads the secret file and rotates the rotators if the DSN changed. The DSN is read again at the next reload
+// if a rotator failed to rotate.
+func (w *Watcher) Reload() error {
+	dsn, err := ReadDSN(w.file)
+	if err != nil {
+		return err
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if dsn == w.dsn {
+		return nil
+	}
+
+	for _, r := range w.rotators {
+		err = r.Rotate(dsn)
+		if err != nil {
+			return err
+		}
+	}
+
+	w.dsn = dsn
+
+	return nil
+}
+
+// Start reloads the secret file periodically, until Stop is called.
+func (w *Watcher) Start(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				err := w.Reload()
+				if err != nil {
+					logger.Errorf("failed to rotate storage credentials from %s : %s", w.file, err)
+				}
+			case <-w.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic reload.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+	})
+}
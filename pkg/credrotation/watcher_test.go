@@ -0,0 +1,74 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package credrotation
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatcher(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "dsn")
+
+	writeDSN := func(dsn string) {
+		require.NoError(t, ioutil.WriteFile(file, []byte(dsn+"\n"), 0o600))
+	}
+
+	t.Run("reload", func(t *testing.T) {
+		writeDSN("user-1")
+
+		dsn, err := ReadDSN(file)
+		require.NoError(t, err)
+		require.Equal(t, "user-1", dsn)
+
+		db := newDatabase()
+
+		p, err := New(db.open, dsn, time.Millisecond)
+		require.NoError(t, err)
+
+		w := NewWatcher(file, dsn)
+		w.Add(p)
+
+		require.NoError(t, w.Reload())
+		require.Equal(t, "user-1", p.dsn)
+
+		writeDSN("invalid")
+		require.Error(t, w.Reload())
+		require.Equal(t, "user-1", w.dsn)
+
+		writeDSN("user-2")
+
+		w.Start(time.Millisecond)
+		defer w.Stop()
+
+		require.Eventually(t, func() bool {
+			w.mutex.Lock()
+			defer w.mutex.Unlock()
+
+			return w.dsn == "user-2"
+		}, time.Second, time.Millisecond)
+
+		w.Stop()
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		_, err := ReadDSN(filepath.Join(t.TempDir(), "missing"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "read dsn file")
+
+		writeDSN(" ")
+
+		_, err = ReadDSN(file)
+		require.EqualError(t, err, "dsn file is empty")
+
+		require.Error(t, NewWatcher(file, "user-1").Reload())
+	})
+}
@@ -0,0 +1,335 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "deadletter"
+	statusTag = "status"
+)
+
+// Dead-letter statuses.
+const (
+	// StatusDeadLettered messages failed processing and are waiting for an operator.
+	StatusDeadLettered = "dead-lettered"
+	// StatusResolved messages were processed successfully on replay.
+	StatusResolved = "resolved"
+)
+
+// Archive reasons.
+const (
+	// ReasonDeadLettered entries are archived once dead-lettered.
+	ReasonDeadLettered = "dead-lettered"
+	// ReasonExpired entries are archived before being deleted at the end of the retention period.
+	ReasonExpired = "expired"
+)
+
+// ErrNotFound is returned when the dead-letter entry doesn't exist.
+var ErrNotFound = errors.New("dead-letter entry not found")
+
+var logger = log.New("hub-router/deadletter")
+
+// Decision is a processing step applied to the message and its outcome.
+type Decision struct {
+	Time    time.Time `json:"time"`
+	Step    string    `json:"step"`
+	Outcome string    `json:"outcome"`
+}
+
+// Entry is a message that failed processing, with the decisions applied to it.
+type Entry struct {
+	ID       string    `json:"id"`
+	MsgID    string    `json:"msgID"`
+	MsgType  string    `json:"msgType"`
+	ThreadID string    `json:"threadID,omitempty"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	// Problem is the problem code of the error.
+	Problem   string          `json:"problem,omitempty"`
+	Replays   int             `json:"replays"`
+	Decisions []*Decision     `json:"decisions"`
+	Message   json.RawMessage `json:"message"`
+	// Archived is the time the entry was archived, if an Archiver is configured.
+	Archived *time.Time `json:"archived,omitempty"`
+	// NodeID and Token identify the active node that processed the message, and the fencing token it held, in an
+	// active/standby deployment.
+	NodeID string `json:"nodeID,omitempty"`
+	Token  uint64 `json:"token,omitempty"`
+}
+
+// AddDecision appends a decision to the entry.
+func (e *Entry) AddDecision(step, outcome string) {
+	e.Decisions = append(e.Decisions, &Decision{Time: time.Now().UTC(), Step: step, Outcome: outcome})
+}
+
+// Archiver archives the entries, eg: to an object storage for a forensic review after an incident.
+type Archiver interface {
+	Archive(e *Entry, reason string) error
+}
+
+// Option configures the Store.
+type Option func(s *Store)
+
+// WithRetention deletes the entries not updated for the given period. The entries are kept forever by default.
+func WithRetention(retention time.Duration) Option {
+	return func(s *Store) {
+		s.retention = retention
+	}
+}
+
+// WithArchiver archives the entries once dead-lettered, and before they are deleted at the end of the retention
+// period.
+func WithArchiver(archiver Archiver) Option {
+	return func(s *Store) {
+		s.archiver = archiver
+	}
+}
+
+// Store persists dead-lettered messages.
+type Store struct {
+	store     storage.Store
+	retention time.Duration
+	archiver  Archiver
+	// mutex serializes the updates of the entries, by the API and the sweep.
+	mutex    sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns a new dead-letter Store backed by the given storage provider.
+func New(p storage.Provider, opts ...Option) (*Store, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open dead-letter store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{statusTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set dead-letter store config : %w", err)
+	}
+
+	s := &Store{store: store, stop: make(chan struct{})}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s, nil
+}
+
+// Put creates or updates the entry, setting the ID and timestamps.
+func (s *Store) Put(e *Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now().UTC()
+
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+		e.Created = now
+	}
+
+	if e.Status == "" {
+		e.Status = StatusDeadLettered
+	}
+
+	e.Updated = now
+
+	return s.save(e)
+}
+
+func (s *Store) save(e *Entry) error {
+	entryBytes, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("marshal dead-letter entry : %w", err)
+	}
+
+	err = s.store.Put(e.ID, entryBytes, storage.Tag{Name: statusTag, Value: e.Status})
+	if err != nil {
+		return fmt.Errorf("save dead-letter entry : %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the entry with the given ID.
+func (s *Store) Get(id string) (*Entry, error) {
+	entryBytes, err := s.store.Get(id)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get dead-letter entry : %w", err)
+	}
+
+	e := &Entry{}
+
+	err = json.Unmarshal(entryBytes, e)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal dead-letter entry : %w", err)
+	}
+
+	return e, nil
+}
+
+// List returns the entries with the given status (all entries if empty), oldest first.
+func (s *Store) List(status string) ([]*Entry, error) {
+	expression := statusTag
+	if status != "" {
+		expression = fmt.Sprintf("%s:%s", statusTag, status)
+	}
+
+	iter, err := s.store.Query(expression)
+	if err != nil {
+		return nil, fmt.Errorf("query dead-letter entries : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var entries []*Entry
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate dead-letter entries : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read dead-letter entry : %w", err)
+		}
+
+		e := &Entry{}
+
+		err = json.Unmarshal(val, e)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal dead-letter entry : %w", err)
+		}
+
+		entries = append(entries, e)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Created.Before(entries[j].Created)
+	})
+
+	return entries, nil
+}
+
+// Enabled returns true if the entries are archived or deleted at the end of the retention period, by the sweep.
+func (s *Store) Enabled() bool {
+	return s.retention > 0 || s.archiver != nil
+}
+
+// Sweep archives the entries not archived yet, and archives then deletes the entries not updated within the retention
+// period. An entry is only deleted once archived.
+func (s *Store) Sweep(now time.Time) error {
+	entries, err := s.List("")
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		switch {
+		case s.retention > 0 && e.Updated.Before(now.Add(-s.retention)):
+			err = s.expire(e)
+		case s.archiver != nil && e.Archived == nil:
+			err = s.archive(e.ID, now)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) expire(e *Entry) error {
+	if s.archiver != nil {
+		err := s.archiver.Archive(e, ReasonExpired)
+		if err != nil {
+			return fmt.Errorf("archive expired dead-letter entry %s : %w", e.ID, err)
+		}
+	}
+
+	err := s.store.Delete(e.ID)
+	if err != nil {
+		return fmt.Errorf("delete dead-letter entry : %w", err)
+	}
+
+	logger.Debugf("dead-letter entry %s expired", e.ID)
+
+	return nil
+}
+
+// archive archives the entry, then records the archive time : the entry is reloaded so that an update made meanwhile
+// (eg: replay) isn't overwritten.
+func (s *Store) archive(id string, now time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+
+	err = s.archiver.Archive(e, ReasonDeadLettered)
+	if err != nil {
+		return fmt.Errorf("archive dead-letter entry %s : %w", e.ID, err)
+	}
+
+	e.Archived = &now
+
+	return s.save(e)
+}
+
+// Start sweeps the entries periodically until Stop is called.
+func (s *Store) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := s.Sweep(now.UTC()); err != nil {
+					logger.Warnf("dead-letter sweep : %s", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic sweep.
+func (s *Store) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
@@ -0,0 +1,254 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package deadletter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+		require.NotNil(t, s)
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open dead-letter store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set dead-letter store config")
+	})
+}
+
+func TestStore(t *testing.T) {
+	t.Run("put, get and list", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		e := &Entry{MsgID: "msg-1", MsgType: "type", Message: []byte(`{"@id":"msg-1"}`)}
+		e.AddDecision("route", "create-connection")
+
+		require.NoError(t, s.Put(e))
+		require.NotEmpty(t, e.ID)
+		require.Equal(t, StatusDeadLettered, e.Status)
+		require.False(t, e.Created.IsZero())
+
+		require.NoError(t, s.Put(&Entry{MsgID: "msg-2", Status: StatusResolved}))
+
+		result, err := s.Get(e.ID)
+		require.NoError(t, err)
+		require.Equal(t, "msg-1", result.MsgID)
+		require.Len(t, result.Decisions, 1)
+		require.Equal(t, "create-connection", result.Decisions[0].Outcome)
+
+		entries, err := s.List("")
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+
+		entries, err = s.List(StatusDeadLettered)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, e.ID, entries[0].ID)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		_, err = s.Get("invalid")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		s := &Store{store: &mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrGet:   errors.New("get error"),
+			ErrQuery: errors.New("query error"),
+		}}
+
+		err := s.Put(&Entry{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save dead-letter entry")
+
+		_, err = s.Get("id")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get dead-letter entry")
+
+		_, err = s.List("")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query dead-letter entries")
+	})
+
+	t.Run("invalid entry", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		require.NoError(t, s.store.Put("id", []byte("invalid"), storage.Tag{Name: statusTag, Value: StatusResolved}))
+
+		_, err = s.Get("id")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal dead-letter entry")
+
+		_, err = s.List("")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal dead-letter entry")
+	})
+}
+
+func TestSweep(t *testing.T) {
+	t.Run("archive and expire", func(t *testing.T) {
+		archiver := &mockArchiver{}
+
+		s, err := New(mem.NewProvider(), WithRetention(time.Hour), WithArchiver(archiver))
+		require.NoError(t, err)
+		require.True(t, s.Enabled())
+
+		e := &Entry{MsgID: "msg-1"}
+		require.NoError(t, s.Put(e))
+
+		now := time.Now().UTC()
+
+		require.NoError(t, s.Sweep(now))
+		require.Equal(t, []string{"msg-1:" + ReasonDeadLettered}, archiver.archived)
+
+		e, err = s.Get(e.ID)
+		require.NoError(t, err)
+		require.NotNil(t, e.Archived)
+
+		// archived once
+		require.NoError(t, s.Sweep(now))
+		require.Len(t, archiver.archived, 1)
+
+		require.NoError(t, s.Sweep(now.Add(2*time.Hour)))
+		require.Equal(t, "msg-1:"+ReasonExpired, archiver.archived[1])
+
+		_, err = s.Get(e.ID)
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("expire without archiver", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), WithRetention(time.Hour))
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put(&Entry{MsgID: "msg-1"}))
+		require.NoError(t, s.Sweep(time.Now().UTC()))
+
+		entries, err := s.List("")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Nil(t, entries[0].Archived)
+
+		require.NoError(t, s.Sweep(time.Now().UTC().Add(2*time.Hour)))
+
+		entries, err = s.List("")
+		require.NoError(t, err)
+		require.Empty(t, entries)
+
+		s, err = New(mem.NewProvider())
+		require.NoError(t, err)
+		require.False(t, s.Enabled())
+	})
+
+	t.Run("archive error", func(t *testing.T) {
+		archiver := &mockArchiver{err: errors.New("bucket error")}
+
+		s, err := New(mem.NewProvider(), WithRetention(time.Hour), WithArchiver(archiver))
+		require.NoError(t, err)
+
+		e := &Entry{MsgID: "msg-1"}
+		require.NoError(t, s.Put(e))
+
+		err = s.Sweep(time.Now().UTC())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "archive dead-letter entry")
+		require.Contains(t, err.Error(), "bucket error")
+
+		// not deleted unless archived
+		err = s.Sweep(time.Now().UTC().Add(2 * time.Hour))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "archive expired dead-letter entry")
+
+		_, err = s.Get(e.ID)
+		require.NoError(t, err)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		s := &Store{store: &mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrQuery: errors.New("query error"),
+		}}
+
+		err := s.Sweep(time.Now())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query dead-letter entries")
+
+		store := &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry), ErrDelete: errors.New("delete error")}
+		s = &Store{store: store, retention: time.Hour}
+
+		require.NoError(t, s.Put(&Entry{}))
+
+		err = s.Sweep(time.Now().Add(2 * time.Hour))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "delete dead-letter entry")
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		archiver := &mockArchiver{}
+
+		s, err := New(mem.NewProvider(), WithArchiver(archiver))
+		require.NoError(t, err)
+		require.NoError(t, s.Put(&Entry{MsgID: "msg-1"}))
+
+		s.Start(time.Millisecond)
+		defer s.Stop()
+
+		require.Eventually(t, func() bool {
+			entries, listErr := s.List("")
+
+			return listErr == nil && entries[0].Archived != nil
+		}, time.Second, time.Millisecond)
+
+		s.Stop()
+	})
+}
+
+type mockArchiver struct {
+	archived []string
+	err      error
+}
+
+func (m *mockArchiver) Archive(e *Entry, reason string) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.archived = append(m.archived, e.MsgID+":"+reason)
+
+	return nil
+}
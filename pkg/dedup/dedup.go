@@ -0,0 +1,368 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dedup
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+const (
+	// prefix starts the value stored in place of a deduplicated mailbox; JSON values never start with a NUL byte.
+	prefix = "\x00hub-router-dedup\x00"
+
+	messagesField = "messages"
+	msgField      = "msg"
+	refField      = "msg_ref"
+
+	// minSize is the size from which a payload is shared : the reference of a smaller payload costs more than it saves.
+	minSize = 256
+)
+
+// Stats of the mailboxes written to the deduplicated stores.
+type Stats struct {
+	// Writes is the number of mailboxes written.
+	Writes uint64 `json:"writes"`
+	// Deduplicated is the number of mailboxes stored with shared payloads.
+	Deduplicated uint64 `json:"deduplicated"`
+	// Duplicates is the number of queued messages referencing the payload of another message, counted at each write of
+	// their mailbox.
+	Duplicates uint64 `json:"duplicates"`
+	// SavedBytes is the number of payload bytes not written thanks to the deduplication.
+	SavedBytes uint64 `json:"savedBytes"`
+}
+
+// payload is a message payload shared by several queued messages, stored once with the number of its references.
+type payload struct {
+	Refs int             `json:"refs"`
+	Msg  json.RawMessage `json:"msg"`
+}
+
+// mailbox is the stored form of a deduplicated mailbox : the messages reference the shared payloads by their hash.
+type mailbox struct {
+	Inbox    json.RawMessage     `json:"inbox"`
+	Payloads map[string]*payload `json:"payloads"`
+}
+
+// Provider is a storage provider detecting the byte-identical payloads queued for the same wallet (eg: forwarded
+// again by an adapter retrying during an outage) in the mailboxes of the given stores, and storing each of them once
+// with its reference count. A shared payload is dropped with its last reference, once the messages are picked up. The
+// messages are restored when the mailbox is read, so that the wallet still receives each of them. The mailboxes
+// without duplicates are stored as is.
+type Provider struct {
+	storage.Provider
+	enabled      bool
+	stores       map[string]bool
+	writes       uint64
+	deduplicated uint64
+	duplicates   uint64
+	saved        uint64
+}
+
+// NewProvider returns a new Provider deduplicating the mailboxes of the given stores, if enabled; when disabled, the
+// new mailboxes are stored as is, while the deduplicated ones are still read back.
+func NewProvider(p storage.Provider, enabled bool, stores ...string) *Provider {
+	dp := &Provider{Provider: p, enabled: enabled, stores: make(map[string]bool, len(stores))}
+
+	for _, name := range stores {
+		dp.stores[name] = true
+	}
+
+	return dp
+}
+
+// OpenStore opens the store, deduplicated if it is one of the deduplicated stores.
+func (p *Provider) OpenStore(name string) (storage.Store, error) {
+	s, err := p.Provider.OpenStore(name)
+	if err != nil || !p.stores[name] {
+		return s, err
+	}
+
+	return &store{Store: s, provider: p}, nil
+}
+
+// Enabled returns true if the new mailboxes are deduplicated.
+func (p *Provider) Enabled() bool {
+	return p.enabled
+}
+
+// Stats returns the deduplication stats since the router started.
+func (p *Provider) Stats() *Stats {
+	return &Stats{
+		Writes:       atomic.LoadUint64(&p.writes),
+		Deduplicated: atomic.LoadUint64(&p.deduplicated),
+		Duplicates:   atomic.LoadUint64(&p.duplicates),
+		SavedBytes:   atomic.LoadUint64(&p.saved),
+	}
+}
+
+// deduplicate returns the mailbox with the payloads shared by several messages stored once, the value as is if it
+// isn't a mailbox or has no duplicates.
+func (p *Provider) deduplicate(value []byte) ([]byte, error) {
+	if !p.enabled {
+		return value, nil
+	}
+
+	atomic.AddUint64(&p.writes, 1)
+
+	inbox := map[string]json.RawMessage{}
+	if json.Unmarshal(value, &inbox) != nil {
+		return value, nil
+	}
+
+	var messages []map[string]json.RawMessage
+	if json.Unmarshal(inbox[messagesField], &messages) != nil {
+		return value, nil
+	}
+
+	payloads := map[string]*payload{}
+	refs := make([]string, len(messages))
+
+	for i, msg := range messages {
+		if len(msg[msgField]) < minSize {
+			continue
+		}
+
+		hash := sha256.Sum256(msg[msgField])
+		refs[i] = hex.EncodeToString(hash[:])
+
+		if payloads[refs[i]] == nil {
+			payloads[refs[i]] = &payload{Msg: msg[msgField]}
+		}
+
+		payloads[refs[i]].Refs++
+	}
+
+	duplicates, saved := share(messages, refs, payloads)
+	if duplicates == 0 {
+		return value, nil
+	}
+
+	return p.marshal(inbox, messages, payloads, duplicates, saved)
+}
+
+// share replaces the payloads referenced by several messages with their hash, and drops the others from the shared
+// payloads. It returns the number of duplicate messages, and the bytes saved.
+func share(messages []map[string]json.RawMessage, refs []string, payloads map[string]*payload) (int, int) {
+	var duplicates, saved int
+
+	for ref, pl := range payloads {
+		if pl.Refs < 2 { // nolint:gomnd // a payload is shared by at least two messages
+			delete(payloads, ref)
+
+			continue
+		}
+
+		duplicates += pl.Refs - 1
+		saved += (pl.Refs - 1) * len(pl.Msg)
+	}
+
+	for i, msg := range messages {
+		if refs[i] != "" && payloads[refs[i]] != nil {
+			delete(msg, msgField)
+
+			msg[refField] = json.RawMessage(`"` + refs[i] + `"`)
+		}
+	}
+
+	return duplicates, saved
+}
+
+func (p *Provider) marshal(inbox map[string]json.RawMessage, messages []map[string]json.RawMessage,
+	payloads map[string]*payload, duplicates, saved int) ([]byte, error) {
+	var err error
+
+	inbox[messagesField], err = json.Marshal(messages)
+	if err != nil {
+		return nil, fmt.Errorf("marshal messages : %w", err)
+	}
+
+	mb := &mailbox{Payloads: payloads}
+
+	mb.Inbox, err = json.Marshal(inbox)
+	if err != nil {
+		return nil, fmt.Errorf("marshal inbox : %w", err)
+	}
+
+	mbBytes, err := json.Marshal(mb)
+	if err != nil {
+		return nil, fmt.Errorf("marshal mailbox : %w", err)
+	}
+
+	atomic.AddUint64(&p.deduplicated, 1)
+	atomic.AddUint64(&p.duplicates, uint64(duplicates))
+	atomic.AddUint64(&p.saved, uint64(saved))
+
+	return append([]byte(prefix), mbBytes...), nil
+}
+
+// restore returns the mailbox with the shared payloads set back in the messages referencing them.
+func restore(value []byte) ([]byte, error) {
+	if !bytes.HasPrefix(value, []byte(prefix)) {
+		return value, nil
+	}
+
+	mb := &mailbox{}
+
+	err := json.Unmarshal(value[len(prefix):], mb)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal mailbox : %w", err)
+	}
+
+	inbox := map[string]json.RawMessage{}
+
+	err = json.Unmarshal(mb.Inbox, &inbox)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal inbox : %w", err)
+	}
+
+	var messages []map[string]json.RawMessage
+
+	err = json.Unmarshal(inbox[messagesField], &messages)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal messages : %w", err)
+	}
+
+	for _, msg := range messages {
+		if len(msg[refField]) == 0 {
+			continue
+		}
+
+		var ref string
+
+		err = json.Unmarshal(msg[refField], &ref)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal payload reference : %w", err)
+		}
+
+		pl, ok := mb.Payloads[ref]
+		if !ok {
+			return nil, fmt.Errorf("missing payload %s", ref)
+		}
+
+		delete(msg, refField)
+
+		msg[msgField] = pl.Msg
+	}
+
+	inbox[messagesField], err = json.Marshal(messages)
+	if err != nil {
+		return nil, fmt.Errorf("marshal messages : %w", err)
+	}
+
+	return json.Marshal(inbox)
+}
+
+// store deduplicates the mailboxes written to the underlying store.
+type store struct {
+	storage.Store
+	provider *Provider
+}
+
+func (s *store) Put(key string, value []byte, tags ...storage.Tag) error {
+	deduplicated, err := s.provider.deduplicate(value)
+	if err != nil {
+		return fmt.Errorf("deduplicate %s : %w", key, err)
+	}
+
+	return s.Store.Put(key, deduplicated, tags...)
+}
+
+func (s *store) Get(key string) ([]byte, error) {
+	value, err := s.Store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.restore(key, value)
+}
+
+func (s *store) GetBulk(keys ...string) ([][]byte, error) {
+	values, err := s.Store.GetBulk(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+
+		values[i], err = s.restore(keys[i], value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+func (s *store) Query(expression string, options ...storage.QueryOption) (storage.Iterator, error) {
+	it, err := s.Store.Query(expression, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &iterator{Iterator: it, store: s}, nil
+}
+
+func (s *store) Batch(operations []storage.Operation) error {
+	ops := make([]storage.Operation, len(operations))
+
+	for i, op := range operations {
+		ops[i] = op
+
+		// a nil value deletes the key
+		if op.Value == nil {
+			continue
+		}
+
+		value, err := s.provider.deduplicate(op.Value)
+		if err != nil {
+			return fmt.Errorf("deduplicate %s : %w", op.Key, err)
+		}
+
+		ops[i].Value = value
+	}
+
+	return s.Store.Batch(ops)
+}
+
+func (s *store) restore(key string, value []byte) ([]byte, error) {
+	restored, err := restore(value)
+	if err != nil {
+		return nil, fmt.Errorf("restore %s : %w", key, err)
+	}
+
+	return restored, nil
+}
+
+// iterator restores the mailboxes of the query results.
+type iterator struct {
+	storage.Iterator
+	store *store
+}
+
+func (it *iterator) Value() ([]byte, error) {
+	value, err := it.Iterator.Value()
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := it.Iterator.Key()
+	if err != nil {
+		return nil, err
+	}
+
+	return it.store.restore(key, value)
+}
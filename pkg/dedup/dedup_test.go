@@ -0,0 +1,254 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package dedup
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	envelope      = `{"message":"` + strings.Repeat("ZXlKd2NtOTBaV04wWldRaU9pSmxl", 20) + `"}`
+	otherEnvelope = `{"message":"` + strings.Repeat("VXBzWW0xTmFVOXBTbGxSZWtsM0lu", 20) + `"}`
+)
+
+func inbox(envelopes ...string) []byte {
+	messages := make([]map[string]interface{}, len(envelopes))
+
+	for i, e := range envelopes {
+		messages[i] = map[string]interface{}{
+			"id": string(rune('a' + i)), "added_time": "2021-06-01T10:30:00Z", "msg": json.RawMessage(e),
+		}
+	}
+
+	raw, err := json.Marshal(messages)
+	if err != nil {
+		panic(err)
+	}
+
+	value, err := json.Marshal(map[string]interface{}{
+		"DID": "did:example:1", "message_count": len(envelopes), "messages": json.RawMessage(raw),
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return value
+}
+
+func TestProvider(t *testing.T) {
+	t.Run("deduplicated store", func(t *testing.T) {
+		mp := mem.NewProvider()
+		p := NewProvider(mp, true, "mailbox")
+		require.True(t, p.Enabled())
+
+		s, err := p.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		duplicated := inbox(envelope, otherEnvelope, envelope, envelope)
+		unique := inbox(envelope, otherEnvelope)
+
+		require.NoError(t, s.Put("did:example:1", duplicated, storage.Tag{Name: "inbox"}))
+		require.NoError(t, s.Put("did:example:2", unique, storage.Tag{Name: "inbox"}))
+
+		raw, err := mp.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		stored, err := raw.Get("did:example:1")
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(stored, []byte(prefix)))
+		require.Less(t, len(stored), len(duplicated))
+		require.Equal(t, 1, bytes.Count(stored, []byte(envelope)))
+		require.Contains(t, string(stored), `"refs":3`)
+
+		// no duplicates : stored as is
+		stored, err = raw.Get("did:example:2")
+		require.NoError(t, err)
+		require.Equal(t, unique, stored)
+
+		value, err := s.Get("did:example:1")
+		require.NoError(t, err)
+		require.JSONEq(t, string(duplicated), string(value))
+
+		values, err := s.GetBulk("did:example:1", "did:example:2", "did:example:3")
+		require.NoError(t, err)
+		require.Len(t, values, 3)
+		require.JSONEq(t, string(duplicated), string(values[0]))
+		require.Equal(t, unique, values[1])
+		require.Nil(t, values[2])
+
+		it, err := s.Query("inbox")
+		require.NoError(t, err)
+
+		for {
+			more, errNext := it.Next()
+			require.NoError(t, errNext)
+
+			if !more {
+				break
+			}
+
+			value, err = it.Value()
+			require.NoError(t, err)
+			require.False(t, bytes.HasPrefix(value, []byte(prefix)))
+		}
+
+		require.NoError(t, it.Close())
+
+		// the shared payload is dropped once its duplicates are picked up
+		require.NoError(t, s.Put("did:example:1", inbox(envelope)))
+
+		stored, err = raw.Get("did:example:1")
+		require.NoError(t, err)
+		require.Equal(t, inbox(envelope), stored)
+
+		stats := p.Stats()
+		require.Equal(t, uint64(3), stats.Writes)
+		require.Equal(t, uint64(1), stats.Deduplicated)
+		require.Equal(t, uint64(2), stats.Duplicates)
+		require.Equal(t, uint64(2*len(envelope)), stats.SavedBytes)
+	})
+
+	t.Run("batch", func(t *testing.T) {
+		p := NewProvider(mem.NewProvider(), true, "mailbox")
+
+		s, err := p.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		duplicated := inbox(envelope, envelope)
+
+		require.NoError(t, s.Put("did:example:2", duplicated))
+		require.NoError(t, s.Batch([]storage.Operation{
+			{Key: "did:example:1", Value: duplicated},
+			{Key: "did:example:2"},
+		}))
+
+		value, err := s.Get("did:example:1")
+		require.NoError(t, err)
+		require.JSONEq(t, string(duplicated), string(value))
+
+		_, err = s.Get("did:example:2")
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mp := mem.NewProvider()
+
+		s, err := NewProvider(mp, true, "mailbox").OpenStore("mailbox")
+		require.NoError(t, err)
+
+		duplicated := inbox(envelope, envelope)
+
+		require.NoError(t, s.Put("did:example:1", duplicated))
+
+		p := NewProvider(mp, false, "mailbox")
+		require.False(t, p.Enabled())
+
+		s, err = p.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		// the mailboxes deduplicated while enabled are still read back
+		value, err := s.Get("did:example:1")
+		require.NoError(t, err)
+		require.JSONEq(t, string(duplicated), string(value))
+
+		require.NoError(t, s.Put("did:example:2", duplicated))
+
+		raw, err := mp.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		stored, err := raw.Get("did:example:2")
+		require.NoError(t, err)
+		require.Equal(t, duplicated, stored)
+		require.Zero(t, p.Stats().Writes)
+	})
+
+	t.Run("values that aren't mailboxes are stored as is", func(t *testing.T) {
+		mp := mem.NewProvider()
+		p := NewProvider(mp, true, "mailbox")
+
+		s, err := p.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		others, err := p.OpenStore("didexchange")
+		require.NoError(t, err)
+
+		for _, value := range [][]byte{[]byte("not json"), []byte(`{"messages":"invalid"}`)} {
+			require.NoError(t, s.Put("key", value))
+
+			stored, errGet := s.Get("key")
+			require.NoError(t, errGet)
+			require.Equal(t, value, stored)
+		}
+
+		// too small to be shared
+		small := inbox(`{"message":"ZXlK"}`, `{"message":"ZXlK"}`)
+		require.NoError(t, s.Put("small", small))
+
+		raw, err := mp.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		stored, err := raw.Get("small")
+		require.NoError(t, err)
+		require.Equal(t, small, stored)
+
+		require.NoError(t, others.Put("key", inbox(envelope, envelope)))
+
+		rawOthers, err := mp.OpenStore("didexchange")
+		require.NoError(t, err)
+
+		stored, err = rawOthers.Get("key")
+		require.NoError(t, err)
+		require.Equal(t, inbox(envelope, envelope), stored)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		mp := mem.NewProvider()
+
+		s, err := NewProvider(mp, true, "mailbox").OpenStore("mailbox")
+		require.NoError(t, err)
+
+		raw, err := mp.OpenStore("mailbox")
+		require.NoError(t, err)
+
+		for _, tc := range []struct {
+			value string
+			err   string
+		}{
+			{value: prefix + "{", err: "unmarshal mailbox"},
+			{value: prefix + `{"inbox":"{"}`, err: "unmarshal inbox"},
+			{value: prefix + `{"inbox":{"messages":{}}}`, err: "unmarshal messages"},
+			{value: prefix + `{"inbox":{"messages":[{"msg_ref":1}]}}`, err: "unmarshal payload reference"},
+			{value: prefix + `{"inbox":{"messages":[{"msg_ref":"abc"}]}}`, err: "missing payload abc"},
+		} {
+			require.NoError(t, raw.Put("did:example:1", []byte(tc.value)))
+
+			_, err = s.Get("did:example:1")
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "restore did:example:1 : "+tc.err)
+
+			_, err = s.GetBulk("did:example:1")
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		}
+
+		_, err = s.Get("did:example:2")
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+
+		_, err = s.GetBulk()
+		require.Error(t, err)
+
+		_, err = s.Query("")
+		require.Error(t, err)
+	})
+}
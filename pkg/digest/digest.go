@@ -0,0 +1,170 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package digest delivers the daily or weekly digests of the router activity to the operators, through the webhooks
+// or by email : the connections added, the mediation grants revoked, the delivery objective and the top senders.
+package digest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedules of the digests.
+const (
+	// Daily digests cover the previous day, in UTC.
+	Daily = "daily"
+	// Weekly digests cover the previous week, from Monday to Sunday in UTC.
+	Weekly = "weekly"
+)
+
+const (
+	// DefaultObjective is the ratio of the messages handled successfully the delivery is expected to meet.
+	DefaultObjective = 0.999
+	// DefaultTopSenders is the number of top senders of the digests.
+	DefaultTopSenders = 10
+
+	// Grace is the delay after the end of a period before its digest is delivered, for the activity of the other
+	// router instances to be saved.
+	Grace = 15 * time.Minute
+
+	day  = 24 * time.Hour
+	week = 7 * day
+)
+
+// Config of the digests.
+type Config struct {
+	// Schedules of the digests, Daily or Weekly.
+	Schedules []string
+	// Objective is the ratio of the messages handled successfully, DefaultObjective if zero.
+	Objective float64
+	// TopSenders is the number of top senders of the digests, DefaultTopSenders if zero.
+	TopSenders int
+}
+
+// Enabled returns true if a digest is scheduled.
+func (c *Config) Enabled() bool {
+	return c != nil && len(c.Schedules) > 0
+}
+
+func (c *Config) objective() float64 {
+	if c.Objective <= 0 {
+		return DefaultObjective
+	}
+
+	return c.Objective
+}
+
+func (c *Config) topSenders() int {
+	if c.TopSenders <= 0 {
+		return DefaultTopSenders
+	}
+
+	return c.TopSenders
+}
+
+// Delivery summarizes the messages handled in the period, against the delivery objective.
+type Delivery struct {
+	Forwards    int     `json:"forwards"`
+	Failures    int     `json:"failures"`
+	SuccessRate float64 `json:"successRate"`
+	Objective   float64 `json:"objective"`
+	Met         bool    `json:"met"`
+}
+
+// NewDelivery returns the delivery of the forwards and failures, against the objective.
+func NewDelivery(forwards, failures int, objective float64) *Delivery {
+	rate := 1.0
+
+	if forwards+failures > 0 {
+		rate = float64(forwards) / float64(forwards+failures)
+	}
+
+	return &Delivery{
+		Forwards: forwards, Failures: failures, SuccessRate: rate, Objective: objective, Met: rate >= objective,
+	}
+}
+
+// Sender is a sender of forwards, identified by the key of its envelopes (base58 encoded).
+type Sender struct {
+	Key string `json:"key"`
+	// ConnectionID is the connection of the sender with the router, if any.
+	ConnectionID string `json:"connectionID,omitempty"`
+	Forwards     int    `json:"forwards"`
+}
+
+// Digest summarizes the router activity over a period.
+type Digest struct {
+	Schedule         string    `json:"schedule"`
+	From             time.Time `json:"from"`
+	To               time.Time `json:"to"`
+	GeneratedAt      time.Time `json:"generatedAt"`
+	ConnectionsAdded int       `json:"connectionsAdded"`
+	// GrantsRevoked is the number of mediation grants ended : the wallets handed over to another mediator, and the
+	// grants transferred to a new device.
+	GrantsRevoked int       `json:"grantsRevoked"`
+	Delivery      *Delivery `json:"delivery"`
+	TopSenders    []*Sender `json:"topSenders"`
+}
+
+// Text returns the digest as plain text.
+func (d *Digest) Text() string {
+	b := &strings.Builder{}
+
+	fmt.Fprintf(b, "Hub router %s digest : %s - %s (UTC)\n\n", d.Schedule, d.From.Format(time.RFC3339),
+		d.To.Format(time.RFC3339))
+	fmt.Fprintf(b, "Connections added : %d\n", d.ConnectionsAdded)
+	fmt.Fprintf(b, "Mediation grants revoked : %d\n", d.GrantsRevoked)
+
+	if d.Delivery != nil {
+		met := "met"
+		if !d.Delivery.Met {
+			met = "not met"
+		}
+
+		fmt.Fprintf(b, "Delivery : %d forwards, %d failures, success rate %.2f%% (objective %.2f%%, %s)\n",
+			d.Delivery.Forwards, d.Delivery.Failures, 100*d.Delivery.SuccessRate, 100*d.Delivery.Objective, met)
+	}
+
+	if len(d.TopSenders) == 0 {
+		return b.String()
+	}
+
+	b.WriteString("\nTop senders :\n")
+
+	for i, s := range d.TopSenders {
+		fmt.Fprintf(b, "%3d. %s", i+1, s.Key)
+
+		if s.ConnectionID != "" {
+			fmt.Fprintf(b, " (connection %s)", s.ConnectionID)
+		}
+
+		fmt.Fprintf(b, " : %d forwards\n", s.Forwards)
+	}
+
+	return b.String()
+}
+
+// Period returns the last period of the schedule ended at least Grace before now : the previous day, or the previous
+// week from Monday, in UTC.
+func Period(schedule string, now time.Time) (time.Time, time.Time, error) {
+	var length time.Duration
+
+	switch schedule {
+	case Daily:
+		length = day
+	case Weekly:
+		// the zero time is a Monday
+		length = week
+	default:
+		return time.Time{}, time.Time{}, fmt.Errorf("unsupported digest schedule : %s", schedule)
+	}
+
+	to := now.UTC().Add(-Grace).Truncate(length)
+
+	return to.Add(-length), to, nil
+}
@@ -0,0 +1,97 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package digest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeriod(t *testing.T) {
+	// Wednesday
+	now := time.Date(2021, 6, 2, 10, 30, 0, 0, time.UTC)
+
+	t.Run("daily", func(t *testing.T) {
+		from, to, err := Period(Daily, now)
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), from)
+		require.Equal(t, time.Date(2021, 6, 2, 0, 0, 0, 0, time.UTC), to)
+
+		// the period ended less than the grace delay ago
+		from, _, err = Period(Daily, time.Date(2021, 6, 2, 0, 10, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2021, 5, 31, 0, 0, 0, 0, time.UTC), from)
+	})
+
+	t.Run("weekly", func(t *testing.T) {
+		from, to, err := Period(Weekly, now)
+		require.NoError(t, err)
+		require.Equal(t, time.Date(2021, 5, 24, 0, 0, 0, 0, time.UTC), from)
+		require.Equal(t, time.Monday, from.Weekday())
+		require.Equal(t, time.Date(2021, 5, 31, 0, 0, 0, 0, time.UTC), to)
+	})
+
+	t.Run("unsupported schedule", func(t *testing.T) {
+		_, _, err := Period("hourly", now)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unsupported digest schedule")
+	})
+}
+
+func TestNewDelivery(t *testing.T) {
+	d := NewDelivery(999, 1, DefaultObjective)
+	require.Equal(t, 0.999, d.SuccessRate)
+	require.True(t, d.Met)
+
+	d = NewDelivery(99, 1, DefaultObjective)
+	require.False(t, d.Met)
+
+	d = NewDelivery(0, 0, DefaultObjective)
+	require.Equal(t, 1.0, d.SuccessRate)
+	require.True(t, d.Met)
+}
+
+func TestDigestText(t *testing.T) {
+	d := &Digest{
+		Schedule:         Daily,
+		From:             time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:               time.Date(2021, 6, 2, 0, 0, 0, 0, time.UTC),
+		ConnectionsAdded: 12,
+		GrantsRevoked:    1,
+		Delivery:         NewDelivery(99, 1, DefaultObjective),
+		TopSenders: []*Sender{
+			{Key: "sender-1", ConnectionID: "conn-1", Forwards: 80},
+			{Key: "sender-2", Forwards: 19},
+		},
+	}
+
+	require.Equal(t, "Hub router daily digest : 2021-06-01T00:00:00Z - 2021-06-02T00:00:00Z (UTC)\n\n"+
+		"Connections added : 12\n"+
+		"Mediation grants revoked : 1\n"+
+		"Delivery : 99 forwards, 1 failures, success rate 99.00% (objective 99.90%, not met)\n\n"+
+		"Top senders :\n"+
+		"  1. sender-1 (connection conn-1) : 80 forwards\n"+
+		"  2. sender-2 : 19 forwards\n", d.Text())
+
+	d.Delivery = NewDelivery(0, 0, DefaultObjective)
+	d.TopSenders = nil
+	require.Contains(t, d.Text(), "(objective 99.90%, met)\n")
+	require.NotContains(t, d.Text(), "Top senders")
+}
+
+func TestConfig(t *testing.T) {
+	require.False(t, (*Config)(nil).Enabled())
+	require.False(t, (&Config{}).Enabled())
+	require.True(t, (&Config{Schedules: []string{Daily}}).Enabled())
+
+	require.Equal(t, float64(DefaultObjective), (&Config{}).objective())
+	require.Equal(t, DefaultTopSenders, (&Config{}).topSenders())
+	require.Equal(t, 0.99, (&Config{Objective: 0.99}).objective())
+	require.Equal(t, 3, (&Config{TopSenders: 3}).topSenders())
+}
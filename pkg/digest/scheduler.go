@@ -0,0 +1,358 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package digest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "digest"
+
+	// tag used to query the forward tallies; the value is the tally period in unix seconds.
+	tallyTag = "tally"
+
+	tallyKeyPrefix     = "tally-"
+	deliveredKeyPrefix = "delivered-"
+
+	// tallyRetention is the period the forward tallies are kept for, the longest digest period.
+	tallyRetention = week + day
+)
+
+var logger = log.New("hub-router/digest")
+
+// Builder returns the digest of the router activity within [from, to) : the schedule, period and generation time
+// are set by the Scheduler.
+type Builder func(from, to time.Time) (*Digest, error)
+
+// Sink delivers the digests.
+type Sink interface {
+	Deliver(d *Digest) error
+}
+
+// tally is the number of forwards received in an hour, in total and per sender key.
+type tally struct {
+	Period   time.Time      `json:"period"`
+	Forwards int            `json:"forwards"`
+	Senders  map[string]int `json:"senders"`
+}
+
+// Scheduler tallies the forwards received by the router, and delivers the digests of the ended periods to the sinks.
+// The tallies of the current hour are kept in memory, and merged in the storage with the tallies of the other router
+// instances once the hour is over. Each digest is delivered once, by the first instance delivering it.
+type Scheduler struct {
+	store    storage.Store
+	cfg      *Config
+	build    Builder
+	sinks    []Sink
+	mutex    sync.Mutex
+	tallies  map[time.Time]*tally
+	now      func() time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns a new Scheduler delivering the digests of the config, built by the builder, to the sinks.
+func New(p storage.Provider, cfg *Config, build Builder, sinks ...Sink) (*Scheduler, error) {
+	for _, schedule := range cfg.Schedules {
+		if _, _, err := Period(schedule, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open digest store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{tallyTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set digest store config : %w", err)
+	}
+
+	return &Scheduler{
+		store:   store,
+		cfg:     cfg,
+		build:   build,
+		sinks:   sinks,
+		tallies: map[time.Time]*tally{},
+		now:     time.Now,
+		stop:    make(chan struct{}),
+	}, nil
+}
+
+// Objective returns the delivery objective of the digests.
+func (s *Scheduler) Objective() float64 {
+	return s.cfg.objective()
+}
+
+// Forward tallies a forward received from the sender key, base58 encoded, empty if anonymous.
+func (s *Scheduler) Forward(senderKey string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	period := s.now().UTC().Truncate(time.Hour)
+
+	t, ok := s.tallies[period]
+	if !ok {
+		t = &tally{Period: period, Senders: map[string]int{}}
+		s.tallies[period] = t
+	}
+
+	t.Forwards++
+
+	if senderKey != "" {
+		t.Senders[senderKey]++
+	}
+}
+
+// Forwards returns the number of forwards saved for the hours within [from, to), and the top senders.
+func (s *Scheduler) Forwards(from, to time.Time) (int, []*Sender, error) {
+	tallies, err := s.saved(from, to)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	total, senders := 0, map[string]int{}
+
+	for _, t := range tallies {
+		total += t.Forwards
+
+		for key, n := range t.Senders {
+			senders[key] += n
+		}
+	}
+
+	top := make([]*Sender, 0, len(senders))
+
+	for key, n := range senders {
+		top = append(top, &Sender{Key: key, Forwards: n})
+	}
+
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Forwards != top[j].Forwards {
+			return top[i].Forwards > top[j].Forwards
+		}
+
+		return top[i].Key < top[j].Key
+	})
+
+	if len(top) > s.cfg.topSenders() {
+		top = top[:s.cfg.topSenders()]
+	}
+
+	return total, top, nil
+}
+
+// Run saves the tallies of the hours over, and delivers the digests of the ended periods not delivered yet. The
+// expired tallies are pruned.
+func (s *Scheduler) Run() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now().UTC()
+
+	err := s.flush(now)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+
+	for _, schedule := range s.cfg.Schedules {
+		if err = s.deliver(schedule, now); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+
+	return s.prune(now)
+}
+
+// Start delivers the digests periodically until Stop is called.
+func (s *Scheduler) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.Run(); err != nil {
+					logger.Warnf("digest : %s", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic delivery.
+func (s *Scheduler) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+// flush merges the tallies of the hours over with the ones saved by the other router instances.
+func (s *Scheduler) flush(now time.Time) error {
+	hour := now.Truncate(time.Hour)
+
+	for period, t := range s.tallies {
+		if !period.Before(hour) {
+			continue
+		}
+
+		saved, err := s.saved(period, period.Add(time.Hour))
+		if err != nil {
+			return err
+		}
+
+		for _, other := range saved {
+			t.Forwards += other.Forwards
+
+			for key, n := range other.Senders {
+				t.Senders[key] += n
+			}
+		}
+
+		if err = s.put(t); err != nil {
+			return err
+		}
+
+		delete(s.tallies, period)
+	}
+
+	return nil
+}
+
+// deliver delivers the digest of the last ended period of the schedule, unless already delivered. The delivery is
+// retried on the next run if a sink fails.
+func (s *Scheduler) deliver(schedule string, now time.Time) error {
+	from, to, err := Period(schedule, now)
+	if err != nil {
+		return err
+	}
+
+	key := deliveredKeyPrefix + schedule + "-" + from.Format(time.RFC3339)
+
+	_, err = s.store.Get(key)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, storage.ErrDataNotFound) {
+		return fmt.Errorf("get digest delivery : %w", err)
+	}
+
+	d, err := s.build(from, to)
+	if err != nil {
+		return fmt.Errorf("build %s digest : %w", schedule, err)
+	}
+
+	d.Schedule, d.From, d.To, d.GeneratedAt = schedule, from, to, now
+
+	for _, sink := range s.sinks {
+		if err = sink.Deliver(d); err != nil {
+			return fmt.Errorf("deliver %s digest : %w", schedule, err)
+		}
+	}
+
+	if err = s.store.Put(key, []byte(now.Format(time.RFC3339))); err != nil {
+		return fmt.Errorf("save digest delivery : %w", err)
+	}
+
+	logger.Infof("digest delivered : schedule=%s from=%s", schedule, from.Format(time.RFC3339))
+
+	return nil
+}
+
+// prune deletes the tallies older than the longest digest period.
+func (s *Scheduler) prune(now time.Time) error {
+	expired, err := s.saved(time.Time{}, now.Add(-tallyRetention))
+	if err != nil {
+		return err
+	}
+
+	for _, t := range expired {
+		if err = s.store.Delete(tallyKey(t.Period)); err != nil {
+			return fmt.Errorf("delete digest tally : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// saved returns the tallies saved for the hours within [from, to). A zero value disables the bound.
+func (s *Scheduler) saved(from, to time.Time) ([]*tally, error) {
+	iter, err := s.store.Query(tallyTag)
+	if err != nil {
+		return nil, fmt.Errorf("query digest tallies : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var tallies []*tally
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate digest tallies : %w", err)
+		}
+
+		if !ok {
+			return tallies, nil
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read digest tally : %w", err)
+		}
+
+		t := &tally{}
+		if err = json.Unmarshal(val, t); err != nil {
+			return nil, fmt.Errorf("unmarshal digest tally : %w", err)
+		}
+
+		if (from.IsZero() || !t.Period.Before(from)) && (to.IsZero() || t.Period.Before(to)) {
+			tallies = append(tallies, t)
+		}
+	}
+}
+
+func (s *Scheduler) put(t *tally) error {
+	b, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("marshal digest tally : %w", err)
+	}
+
+	err = s.store.Put(tallyKey(t.Period), b, storage.Tag{Name: tallyTag, Value: strconv.FormatInt(t.Period.Unix(), 10)})
+	if err != nil {
+		return fmt.Errorf("save digest tally : %w", err)
+	}
+
+	return nil
+}
+
+func tallyKey(period time.Time) string {
+	return tallyKeyPrefix + period.Format(time.RFC3339)
+}
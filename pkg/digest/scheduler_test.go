@@ -0,0 +1,236 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package digest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+type mockSink struct {
+	digests []*Digest
+	err     error
+}
+
+func (m *mockSink) Deliver(d *Digest) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	m.digests = append(m.digests, d)
+
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	build := func(from, to time.Time) (*Digest, error) { return &Digest{}, nil }
+
+	t.Run("success", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), &Config{Schedules: []string{Daily, Weekly}}, build)
+		require.NoError(t, err)
+		require.Equal(t, float64(DefaultObjective), s.Objective())
+	})
+
+	t.Run("unsupported schedule", func(t *testing.T) {
+		_, err := New(mem.NewProvider(), &Config{Schedules: []string{"monthly"}}, build)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unsupported digest schedule : monthly")
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, &Config{}, build)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open digest store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p, &Config{}, build)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set digest store config")
+	})
+}
+
+func TestScheduler(t *testing.T) {
+	// Tuesday
+	start := time.Date(2021, 6, 1, 22, 30, 0, 0, time.UTC)
+
+	t.Run("delivers the digests of the ended periods once", func(t *testing.T) {
+		p := mem.NewProvider()
+		sink := &mockSink{}
+		now := start
+
+		build := func(from, to time.Time) (*Digest, error) {
+			return &Digest{ConnectionsAdded: 3}, nil
+		}
+
+		var schedulers []*Scheduler
+
+		for i := 0; i < 2; i++ {
+			s, err := New(p, &Config{Schedules: []string{Daily}, TopSenders: 2}, build, sink)
+			require.NoError(t, err)
+
+			s.now = func() time.Time { return now }
+			schedulers = append(schedulers, s)
+		}
+
+		schedulers[0].Forward("sender-1")
+		schedulers[0].Forward("sender-2")
+		schedulers[1].Forward("sender-1")
+		schedulers[1].Forward("")
+
+		now = start.Add(time.Hour)
+		schedulers[0].Forward("sender-3")
+
+		// the digest of the previous day is delivered
+		require.NoError(t, schedulers[0].Run())
+		require.Len(t, sink.digests, 1)
+		require.Equal(t, Daily, sink.digests[0].Schedule)
+		require.Equal(t, time.Date(2021, 5, 31, 0, 0, 0, 0, time.UTC), sink.digests[0].From)
+		require.Equal(t, now, sink.digests[0].GeneratedAt)
+		require.Equal(t, 3, sink.digests[0].ConnectionsAdded)
+
+		require.NoError(t, schedulers[1].Run())
+		require.Len(t, sink.digests, 1)
+
+		total, top, err := schedulers[0].Forwards(start.Truncate(day), start.Truncate(day).Add(day))
+		require.NoError(t, err)
+		require.Equal(t, 4, total)
+		require.Equal(t, []*Sender{{Key: "sender-1", Forwards: 2}, {Key: "sender-2", Forwards: 1}}, top)
+
+		// the day is over
+		now = start.Add(2 * time.Hour)
+		require.NoError(t, schedulers[1].Run())
+		require.Len(t, sink.digests, 2)
+		require.Equal(t, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC), sink.digests[1].From)
+
+		require.NoError(t, schedulers[0].Run())
+		require.Len(t, sink.digests, 2)
+
+		total, _, err = schedulers[0].Forwards(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, 5, total)
+
+		// the expired tallies are pruned
+		now = now.Add(tallyRetention + time.Hour)
+		require.NoError(t, schedulers[0].Run())
+
+		total, _, err = schedulers[0].Forwards(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Zero(t, total)
+	})
+
+	t.Run("delivery retried", func(t *testing.T) {
+		sink := &mockSink{err: errors.New("sink error")}
+		fail := true
+
+		s, err := New(mem.NewProvider(), &Config{Schedules: []string{Weekly}}, func(from, to time.Time) (*Digest, error) {
+			if fail {
+				return nil, errors.New("build error")
+			}
+
+			return &Digest{}, nil
+		}, sink)
+		require.NoError(t, err)
+
+		s.now = func() time.Time { return start }
+
+		err = s.Run()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "build weekly digest")
+
+		fail = false
+
+		err = s.Run()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "deliver weekly digest")
+
+		sink.err = nil
+
+		require.NoError(t, s.Run())
+		require.Len(t, sink.digests, 1)
+		require.Equal(t, time.Date(2021, 5, 24, 0, 0, 0, 0, time.UTC), sink.digests[0].From)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		build := func(from, to time.Time) (*Digest, error) { return &Digest{}, nil }
+
+		s, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrQuery: errors.New("query error"),
+		}), &Config{Schedules: []string{Daily}}, build)
+		require.NoError(t, err)
+
+		_, _, err = s.Forwards(time.Time{}, time.Time{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query digest tallies")
+
+		s.now = func() time.Time { return start }
+		s.Forward("sender-1")
+		s.now = func() time.Time { return start.Add(time.Hour) }
+
+		require.Error(t, s.Run())
+
+		s, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), &Config{Schedules: []string{Daily}}, build)
+		require.NoError(t, err)
+
+		err = s.Run()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get digest delivery")
+
+		s, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+		}), &Config{Schedules: []string{Daily}}, build)
+		require.NoError(t, err)
+
+		err = s.Run()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save digest delivery")
+
+		s.now = func() time.Time { return start }
+		s.Forward("sender-1")
+		s.now = func() time.Time { return start.Add(time.Hour) }
+
+		err = s.Run()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save digest tally")
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		sink := &mockSink{}
+
+		s, err := New(mem.NewProvider(), &Config{Schedules: []string{Daily}},
+			func(from, to time.Time) (*Digest, error) { return &Digest{}, nil }, sink)
+		require.NoError(t, err)
+
+		s.Start(time.Millisecond)
+		defer s.Stop()
+
+		require.Eventually(t, func() bool {
+			s.mutex.Lock()
+			defer s.mutex.Unlock()
+
+			return len(sink.digests) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,57 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package digest
+
+import (
+	"fmt"
+
+	"github.com/trustbloc/hub-router/pkg/mail"
+)
+
+// Topic of the digests posted to the webhooks.
+const Topic = "digest"
+
+// Notifier posts the notifications to the webhooks.
+type Notifier interface {
+	Notify(topic string, msg interface{}) error
+}
+
+// WebhookSink posts the digests to the webhooks, with the digest topic.
+type WebhookSink struct {
+	notifier Notifier
+}
+
+// NewWebhookSink returns a new WebhookSink posting the digests with the notifier.
+func NewWebhookSink(notifier Notifier) *WebhookSink {
+	return &WebhookSink{notifier: notifier}
+}
+
+// Deliver posts the digest to the webhooks.
+func (w *WebhookSink) Deliver(d *Digest) error {
+	return w.notifier.Notify(Topic, d)
+}
+
+// SMTPSink emails the digests as plain text.
+type SMTPSink struct {
+	sender *mail.Sender
+}
+
+// NewSMTPSink returns a new SMTPSink emailing the digests with the sender.
+func NewSMTPSink(sender *mail.Sender) *SMTPSink {
+	return &SMTPSink{sender: sender}
+}
+
+// Deliver emails the digest.
+func (s *SMTPSink) Deliver(d *Digest) error {
+	subject := fmt.Sprintf("Hub router %s digest - %s", d.Schedule, d.From.Format("2006-01-02"))
+
+	if err := s.sender.Send(subject, d.GeneratedAt, d.Text()); err != nil {
+		return fmt.Errorf("send digest email : %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package digest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	mocksmtp "github.com/trustbloc/hub-router/pkg/internal/mock/smtp"
+	"github.com/trustbloc/hub-router/pkg/mail"
+)
+
+type mockNotifier struct {
+	topic string
+	msg   interface{}
+	err   error
+}
+
+func (m *mockNotifier) Notify(topic string, msg interface{}) error {
+	m.topic, m.msg = topic, msg
+
+	return m.err
+}
+
+func TestWebhookSink(t *testing.T) {
+	notifier := &mockNotifier{}
+	d := &Digest{Schedule: Weekly}
+
+	require.NoError(t, NewWebhookSink(notifier).Deliver(d))
+	require.Equal(t, Topic, notifier.topic)
+	require.Equal(t, d, notifier.msg)
+
+	notifier.err = errors.New("notify error")
+	require.Error(t, NewWebhookSink(notifier).Deliver(d))
+}
+
+func TestSMTPSink(t *testing.T) {
+	d := &Digest{
+		Schedule:    Daily,
+		From:        time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+		To:          time.Date(2021, 6, 2, 0, 0, 0, 0, time.UTC),
+		GeneratedAt: time.Date(2021, 6, 2, 0, 15, 0, 0, time.UTC),
+		Delivery:    NewDelivery(10, 0, DefaultObjective),
+	}
+
+	server, err := mocksmtp.NewServer(mocksmtp.Config{})
+	require.NoError(t, err)
+
+	defer server.Close() // nolint:errcheck // test
+
+	t.Run("success", func(t *testing.T) {
+		sender, err := mail.New("smtp://"+server.Addr, nil, "router@example.com", []string{"ops@example.com"})
+		require.NoError(t, err)
+
+		require.NoError(t, NewSMTPSink(sender).Deliver(d))
+
+		messages := server.Messages()
+		require.Len(t, messages, 1)
+		require.Contains(t, messages[0].Data, "Subject: Hub router daily digest - 2021-06-01\n")
+		require.Contains(t, messages[0].Data, "Date: Wed, 02 Jun 2021 00:15:00 +0000\n")
+		require.Contains(t, messages[0].Data, "\n\nHub router daily digest")
+		require.Contains(t, messages[0].Data, "Delivery : 10 forwards, 0 failures")
+	})
+
+	t.Run("send error", func(t *testing.T) {
+		sender, err := mail.New("smtp://localhost:0", nil, "router@example.com", []string{"ops@example.com"})
+		require.NoError(t, err)
+
+		err = NewSMTPSink(sender).Deliver(d)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "send digest email")
+	})
+}
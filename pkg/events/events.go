@@ -0,0 +1,264 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+var logger = log.New("hub-router/events")
+
+// Topic of an event.
+type Topic string
+
+// Event topics.
+const (
+	TopicConnection Topic = "connection"
+	TopicMediation  Topic = "mediation"
+	TopicForward    Topic = "forward"
+	TopicPresence   Topic = "presence"
+	TopicSecurity   Topic = "security"
+	TopicTenant     Topic = "tenant"
+	TopicHA         Topic = "ha"
+)
+
+// Connection states.
+const (
+	ConnectionCreated   = "created"
+	ConnectionCompleted = "completed"
+)
+
+// Event published by hub-router. Use a type switch to get the typed event.
+type Event interface {
+	Topic() Topic
+}
+
+// ConnectionEvent is published when a connection is created or completed.
+type ConnectionEvent struct {
+	Time         time.Time
+	State        string
+	ConnectionID string
+	ThreadID     string
+	MyDID        string
+	TheirDID     string
+	// Label of the wallet or agent, if it sent one.
+	Label string
+}
+
+// Topic of the event.
+func (e *ConnectionEvent) Topic() Topic {
+	return TopicConnection
+}
+
+// MediationEvent is published when a wallet requests mediation.
+type MediationEvent struct {
+	Time         time.Time
+	ConnectionID string
+	ThreadID     string
+	MsgType      string
+}
+
+// Topic of the event.
+func (e *MediationEvent) Topic() Topic {
+	return TopicMediation
+}
+
+// ForwardEvent is published when a forward message is routed to a wallet.
+type ForwardEvent struct {
+	Time         time.Time
+	ConnectionID string
+	RecipientKey string
+	MsgID        string
+}
+
+// Topic of the event.
+func (e *ForwardEvent) Topic() Topic {
+	return TopicForward
+}
+
+// PresenceEvent is published when a wallet goes online or offline.
+type PresenceEvent struct {
+	Time         time.Time
+	ConnectionID string
+	Status       string
+	Source       string
+}
+
+// Topic of the event.
+func (e *PresenceEvent) Topic() Topic {
+	return TopicPresence
+}
+
+// Security event kinds.
+const (
+	SecurityKeyMismatch = "key-mismatch"
+	SecurityKeyReuse    = "key-reuse"
+)
+
+// SecurityEvent is published when an inbound message is rejected for security reasons, eg: an envelope sent with
+// another key than the one pinned for the connection, or when a router key is reused across connections.
+type SecurityEvent struct {
+	Time         time.Time `json:"time"`
+	Kind         string    `json:"kind"`
+	RecipientKey string    `json:"recipientKey,omitempty"`
+	ConnectionID string    `json:"connectionID,omitempty"`
+	Detail       string    `json:"detail"`
+}
+
+// Topic of the event.
+func (e *SecurityEvent) Topic() Topic {
+	return TopicSecurity
+}
+
+// TenantDeleted is the state of the tenant events published when a tenant is deleted.
+const TenantDeleted = "deleted"
+
+// TenantEvent is published when a tenant is created, changes state (active or suspended), or is deleted.
+type TenantEvent struct {
+	Time     time.Time `json:"time"`
+	TenantID string    `json:"tenantID"`
+	State    string    `json:"state"`
+	// Previous is the state of the tenant before the change, empty if created.
+	Previous string `json:"previous,omitempty"`
+}
+
+// Topic of the event.
+func (e *TenantEvent) Topic() Topic {
+	return TopicTenant
+}
+
+// RoleEvent is published when the node of an active/standby deployment is promoted to active, or fenced.
+type RoleEvent struct {
+	Time   time.Time `json:"time"`
+	NodeID string    `json:"nodeID"`
+	Role   string    `json:"role"`
+	// Previous is the role of the node before the change.
+	Previous string `json:"previous"`
+	// Token is the fencing token held by the node, zero if standby.
+	Token uint64 `json:"token,omitempty"`
+}
+
+// Topic of the event.
+func (e *RoleEvent) Topic() Topic {
+	return TopicHA
+}
+
+// Subscription to the bus events.
+type Subscription struct {
+	// C receives the events; it is closed on Unsubscribe.
+	C <-chan Event
+
+	ch     chan Event
+	topics map[Topic]bool
+	fn     func(Event)
+	bus    *Bus
+}
+
+// Unsubscribe stops the delivery of events to the subscription.
+func (s *Subscription) Unsubscribe() {
+	s.bus.remove(s)
+}
+
+func (s *Subscription) accepts(t Topic) bool {
+	return len(s.topics) == 0 || s.topics[t]
+}
+
+// Bus dispatches the hub-router events to in-process subscribers, eg: Go services embedding hub-router.
+type Bus struct {
+	mutex sync.RWMutex
+	subs  map[*Subscription]struct{}
+}
+
+// NewBus returns a new event Bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a channel subscription to the given topics (all topics if none). Events are dropped if the
+// channel buffer is full, so that slow subscribers don't stall message processing.
+func (b *Bus) Subscribe(bufferSize int, topics ...Topic) *Subscription {
+	ch := make(chan Event, bufferSize)
+
+	s := &Subscription{C: ch, ch: ch, topics: topicSet(topics), bus: b}
+
+	b.add(s)
+
+	return s
+}
+
+// Register calls fn for each event of the given topics (all topics if none). fn is called synchronously by the
+// publisher and must not block.
+func (b *Bus) Register(fn func(Event), topics ...Topic) *Subscription {
+	s := &Subscription{topics: topicSet(topics), fn: fn, bus: b}
+
+	b.add(s)
+
+	return s
+}
+
+// Publish dispatches the event to the subscribers.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	for s := range b.subs {
+		if !s.accepts(e.Topic()) {
+			continue
+		}
+
+		if s.fn != nil {
+			s.fn(e)
+
+			continue
+		}
+
+		select {
+		case s.ch <- e:
+		default:
+			logger.Warnf("subscriber buffer full, dropping %s event", e.Topic())
+		}
+	}
+}
+
+func (b *Bus) add(s *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.subs[s] = struct{}{}
+}
+
+func (b *Bus) remove(s *Subscription) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if _, ok := b.subs[s]; !ok {
+		return
+	}
+
+	delete(b.subs, s)
+
+	if s.ch != nil {
+		close(s.ch)
+	}
+}
+
+func topicSet(topics []Topic) map[Topic]bool {
+	set := make(map[Topic]bool, len(topics))
+
+	for _, t := range topics {
+		set[t] = true
+	}
+
+	return set
+}
@@ -0,0 +1,92 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus(t *testing.T) {
+	t.Run("subscribe", func(t *testing.T) {
+		b := NewBus()
+
+		all := b.Subscribe(10)
+		conns := b.Subscribe(10, TopicConnection)
+
+		b.Publish(&ConnectionEvent{State: ConnectionCreated, ConnectionID: "conn-1"})
+		b.Publish(&MediationEvent{ConnectionID: "conn-1"})
+		b.Publish(&ForwardEvent{RecipientKey: "key-1"})
+		b.Publish(&PresenceEvent{ConnectionID: "conn-1", Status: "online"})
+		b.Publish(&SecurityEvent{Kind: SecurityKeyMismatch})
+		b.Publish(&TenantEvent{TenantID: "tenant-1", State: TenantDeleted})
+		b.Publish(&RoleEvent{NodeID: "node-1", Role: "active", Previous: "standby", Token: 1})
+
+		require.Len(t, all.C, 7)
+		require.Len(t, conns.C, 1)
+
+		e, ok := (<-conns.C).(*ConnectionEvent)
+		require.True(t, ok)
+		require.Equal(t, "conn-1", e.ConnectionID)
+
+		var topics []Topic
+		for i := 0; i < 7; i++ {
+			topics = append(topics, (<-all.C).Topic())
+		}
+
+		require.Equal(t, []Topic{
+			TopicConnection, TopicMediation, TopicForward, TopicPresence, TopicSecurity, TopicTenant, TopicHA,
+		}, topics)
+
+		conns.Unsubscribe()
+		conns.Unsubscribe()
+
+		_, ok = <-conns.C
+		require.False(t, ok)
+
+		b.Publish(&ConnectionEvent{})
+		require.Len(t, all.C, 1)
+	})
+
+	t.Run("register", func(t *testing.T) {
+		b := NewBus()
+
+		var received []Event
+
+		s := b.Register(func(e Event) {
+			received = append(received, e)
+		}, TopicMediation)
+
+		b.Publish(&ConnectionEvent{})
+		b.Publish(&MediationEvent{})
+		require.Len(t, received, 1)
+
+		s.Unsubscribe()
+
+		b.Publish(&MediationEvent{})
+		require.Len(t, received, 1)
+	})
+
+	t.Run("full buffer", func(t *testing.T) {
+		b := NewBus()
+
+		s := b.Subscribe(1)
+
+		b.Publish(&ConnectionEvent{})
+		b.Publish(&ConnectionEvent{})
+		require.Len(t, s.C, 1)
+	})
+
+	t.Run("nil bus", func(t *testing.T) {
+		var b *Bus
+
+		require.NotPanics(t, func() {
+			b.Publish(&ConnectionEvent{})
+		})
+	})
+}
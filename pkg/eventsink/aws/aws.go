@@ -0,0 +1,86 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	fifoSuffix     = ".fifo"
+	// topicAttribute is the message attribute with the notification topic.
+	topicAttribute = "topic"
+	stringType     = "String"
+)
+
+// Config of the AWS sinks.
+type Config struct {
+	// Region of the queue, topic or bucket. Defaults to the region of the queue URL, topic ARN or bucket URL.
+	Region string
+	// Endpoint overrides the SNS endpoint, eg: a VPC endpoint. Defaults to https://sns.{region}.amazonaws.com/.
+	Endpoint string
+	// Credentials sign the requests. Defaults to the credential chain of the AWS SDK : the environment, the web
+	// identity (eg: EKS service accounts), the container role (eg: ECS tasks) and the EC2 instance profile.
+	Credentials *credentials.Credentials
+	Client      *http.Client
+}
+
+// newSession returns the AWS SDK session of the region and endpoint, with the credentials and client of the config.
+func newSession(region, endpoint string, config *Config) (*session.Session, error) {
+	c := aws.NewConfig().WithRegion(region)
+
+	if endpoint != "" {
+		c = c.WithEndpoint(endpoint)
+	}
+
+	if config.Credentials != nil {
+		c = c.WithCredentials(config.Credentials)
+	}
+
+	if config.Client != nil {
+		c = c.WithHTTPClient(config.Client)
+	}
+
+	s, err := session.NewSession(c)
+	if err != nil {
+		return nil, fmt.Errorf("create aws session : %w", err)
+	}
+
+	return s, nil
+}
+
+// attributes returns the message attributes of the notification : the topic, and the notification headers (the content
+// type, and the event attributes in CloudEvents binary mode).
+func attributes(n *webhook.Notification) map[string]string {
+	attrs := map[string]string{topicAttribute: n.Topic}
+
+	for k, v := range n.Headers {
+		attrs[k] = v
+	}
+
+	return attrs
+}
+
+// fifo returns the message group (the connection, so that the notifications of a connection are ordered) and the
+// deduplication ID of the FIFO queues and topics.
+func fifo(n *webhook.Notification) (*string, *string) {
+	group := n.ConnectionID
+	if group == "" {
+		group = n.Topic
+	}
+
+	return aws.String(group), aws.String(n.ID)
+}
@@ -0,0 +1,162 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package aws
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// defaultS3Region is the region of the S3-compatible services without regions (eg: MinIO).
+const defaultS3Region = "us-east-1"
+
+// Server-side encryption of the S3 objects.
+const (
+	// SSES3 encrypts the objects with the S3 managed keys.
+	SSES3 = s3.ServerSideEncryptionAes256
+	// SSEKMS encrypts the objects with a KMS key.
+	SSEKMS = s3.ServerSideEncryptionAwsKms
+)
+
+// S3 stores objects in an S3 (or S3-compatible, eg: MinIO) bucket.
+type S3 struct {
+	client  *s3.S3
+	bucket  string
+	timeout time.Duration
+}
+
+// PutOptions of an S3 object.
+type PutOptions struct {
+	ContentType string
+	// Encryption is the server-side encryption of the object (SSES3 or SSEKMS), none if empty.
+	Encryption string
+	// KMSKeyID is the KMS key of the SSEKMS encryption, the default S3 key if empty.
+	KMSKeyID string
+}
+
+// LifecycleRule expires the objects with the given key prefix after the given number of days.
+type LifecycleRule struct {
+	ID     string
+	Prefix string
+	Days   int
+}
+
+// NewS3 returns a new S3 client of the bucket, with a virtual-hosted (https://{bucket}.s3.{region}.amazonaws.com) or
+// path-style (eg: https://minio.example.com/{bucket}) URL.
+func NewS3(bucketURL string, config *Config) (*S3, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 bucket url %s", bucketURL)
+	}
+
+	region := config.Region
+	if region == "" {
+		region = s3Region(u.Hostname())
+	}
+
+	// path-style : the endpoint is the host, virtual-hosted : the host without the bucket
+	bucket, host, pathStyle := strings.Trim(u.Path, "/"), u.Host, true
+	if bucket == "" {
+		pathStyle = false
+
+		parts := strings.SplitN(host, ".", 2) // nolint:gomnd // {bucket}.{endpoint}
+		if len(parts) != 2 {                  // nolint:gomnd // {bucket}.{endpoint}
+			return nil, fmt.Errorf("no bucket in s3 bucket url %s", bucketURL)
+		}
+
+		bucket, host = parts[0], parts[1]
+	}
+
+	s, err := newSession(region, u.Scheme+"://"+host, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3{
+		client:  s3.New(s, aws.NewConfig().WithS3ForcePathStyle(pathStyle)),
+		bucket:  bucket,
+		timeout: defaultTimeout,
+	}, nil
+}
+
+// s3Region returns the region of the S3 endpoint ({bucket}.s3.{region}.amazonaws.com or s3.{region}.amazonaws.com),
+// the default region otherwise.
+func s3Region(host string) string {
+	parts := strings.Split(host, ".")
+
+	for i := 0; i+2 < len(parts); i++ {
+		if parts[i] == "s3" && parts[i+2] == "amazonaws" {
+			return parts[i+1]
+		}
+	}
+
+	return defaultS3Region
+}
+
+// Put stores the object with the given key.
+func (s *S3) Put(key string, body []byte, opts *PutOptions) error {
+	input := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(strings.TrimPrefix(key, "/")),
+		Body:   bytes.NewReader(body),
+	}
+
+	if opts.ContentType != "" {
+		input.ContentType = aws.String(opts.ContentType)
+	}
+
+	if opts.Encryption != "" {
+		input.ServerSideEncryption = aws.String(opts.Encryption)
+	}
+
+	if opts.Encryption == SSEKMS && opts.KMSKeyID != "" {
+		input.SSEKMSKeyId = aws.String(opts.KMSKeyID)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	_, err := s.client.PutObjectWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("s3 put %s : %w", key, err)
+	}
+
+	return nil
+}
+
+// PutLifecycle replaces the lifecycle configuration of the bucket with the given rules.
+func (s *S3) PutLifecycle(rules ...*LifecycleRule) error {
+	config := &s3.BucketLifecycleConfiguration{}
+
+	for _, r := range rules {
+		config.Rules = append(config.Rules, &s3.LifecycleRule{
+			ID:         aws.String(r.ID),
+			Filter:     &s3.LifecycleRuleFilter{Prefix: aws.String(r.Prefix)},
+			Status:     aws.String(s3.ExpirationStatusEnabled),
+			Expiration: &s3.LifecycleExpiration{Days: aws.Int64(int64(r.Days))},
+		})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	_, err := s.client.PutBucketLifecycleConfigurationWithContext(ctx, &s3.PutBucketLifecycleConfigurationInput{
+		Bucket: aws.String(s.bucket), LifecycleConfiguration: config,
+	})
+	if err != nil {
+		return fmt.Errorf("s3 put lifecycle : %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,216 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package aws
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/stretchr/testify/require"
+)
+
+// testCredentials returns the static credentials the requests of the tests are signed with.
+func testCredentials() *credentials.Credentials {
+	return credentials.NewStaticCredentials("AKID", "secret", "")
+}
+
+// requireSigned checks the signature of the request as AWS does : over the path as sent, eg: with the reserved
+// characters of the S3 keys escaped.
+func requireSigned(t *testing.T, r *http.Request, body []byte, service, region string) {
+	t.Helper()
+
+	auth := r.Header.Get("Authorization")
+	require.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/"), auth)
+	require.Contains(t, auth, "/"+region+"/"+service+"/aws4_request")
+
+	signTime, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(r.Method, "http://"+r.Host+r.RequestURI, bytes.NewReader(body))
+	require.NoError(t, err)
+
+	signed := auth[strings.Index(auth, "SignedHeaders=")+len("SignedHeaders=") : strings.Index(auth, ", Signature=")]
+
+	for _, h := range strings.Split(signed, ";") {
+		switch h {
+		case "host":
+		case "content-length":
+			req.Header.Set(h, strconv.FormatInt(r.ContentLength, 10))
+		default:
+			req.Header[http.CanonicalHeaderKey(h)] = r.Header.Values(h)
+		}
+	}
+
+	signer := v4.NewSigner(testCredentials(), func(s *v4.Signer) {
+		s.DisableURIPathEscaping = service == "s3"
+	})
+
+	_, err = signer.Sign(req, bytes.NewReader(body), service, region, signTime)
+	require.NoError(t, err)
+	require.Equal(t, req.Header.Get("Authorization"), auth)
+}
+
+func TestS3(t *testing.T) {
+	t.Run("new", func(t *testing.T) {
+		for bucketURL, region := range map[string]string{
+			"https://archive.s3.eu-west-1.amazonaws.com":           "eu-west-1",
+			"https://s3.ca-central-1.amazonaws.com/archive":        "ca-central-1",
+			"https://archive.s3.dualstack.us-west-2.amazonaws.com": defaultS3Region,
+			"http://minio:9000/archive":                            defaultS3Region,
+		} {
+			b, err := NewS3(bucketURL, &Config{})
+			require.NoError(t, err)
+			require.Equal(t, "archive", b.bucket, bucketURL)
+			require.Equal(t, region, *b.client.Config.Region, bucketURL)
+		}
+
+		b, err := NewS3("http://minio:9000/archive/", &Config{Region: "eu-central-1"})
+		require.NoError(t, err)
+		require.Equal(t, "eu-central-1", *b.client.Config.Region)
+		require.Equal(t, "http://minio:9000", b.client.Endpoint)
+		require.True(t, *b.client.Config.S3ForcePathStyle)
+
+		b, err = NewS3("https://archive.s3.eu-west-1.amazonaws.com/", &Config{})
+		require.NoError(t, err)
+		require.Equal(t, "https://s3.eu-west-1.amazonaws.com", b.client.Endpoint)
+		require.False(t, *b.client.Config.S3ForcePathStyle)
+
+		_, err = NewS3("archive", &Config{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid s3 bucket url")
+
+		_, err = NewS3("http://minio", &Config{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no bucket in s3 bucket url")
+	})
+
+	t.Run("put", func(t *testing.T) {
+		reqs := make(chan *http.Request, 3)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			require.Equal(t, `{"id":"1"}`, string(body))
+
+			requireSigned(t, r, body, "s3", defaultS3Region)
+
+			reqs <- r
+		}))
+		defer srv.Close()
+
+		b, err := NewS3(srv.URL+"/archive", &Config{Credentials: testCredentials()})
+		require.NoError(t, err)
+
+		require.NoError(t, b.Put("/deadletter/1.json", []byte(`{"id":"1"}`), &PutOptions{
+			ContentType: "application/json",
+		}))
+
+		req := <-reqs
+		require.Equal(t, http.MethodPut, req.Method)
+		require.Equal(t, "/archive/deadletter/1.json", req.URL.Path)
+		require.Equal(t, "application/json", req.Header.Get("Content-Type"))
+		require.Empty(t, req.Header.Get("X-Amz-Server-Side-Encryption"))
+
+		require.NoError(t, b.Put("payloads/1.json", []byte(`{"id":"1"}`), &PutOptions{
+			Encryption: SSEKMS, KMSKeyID: "alias/archive",
+		}))
+
+		req = <-reqs
+		require.Equal(t, SSEKMS, req.Header.Get("X-Amz-Server-Side-Encryption"))
+		require.Equal(t, "alias/archive", req.Header.Get("X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id"))
+
+		// the reserved characters of the key are escaped in the path the request is signed with
+		require.NoError(t, b.Put("payloads/2021-06-01 10:30/a+b=c.json", []byte(`{"id":"1"}`), &PutOptions{}))
+
+		req = <-reqs
+		require.Equal(t, "/archive/payloads/2021-06-01%2010%3A30/a%2Bb%3Dc.json", req.URL.EscapedPath())
+		require.Equal(t, "/archive/payloads/2021-06-01 10:30/a+b=c.json", req.URL.Path)
+	})
+
+	t.Run("put lifecycle", func(t *testing.T) {
+		type lifecycleConfiguration struct {
+			Rules []struct {
+				ID         string `xml:"ID"`
+				Prefix     string `xml:"Filter>Prefix"`
+				Status     string `xml:"Status"`
+				Expiration int    `xml:"Expiration>Days"`
+			} `xml:"Rule"`
+		}
+
+		configs := make(chan *lifecycleConfiguration, 1)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/archive", r.URL.Path)
+			require.Equal(t, "lifecycle=", r.URL.RawQuery)
+			require.NotEmpty(t, r.Header.Get("Content-MD5"))
+
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			requireSigned(t, r, body, "s3", defaultS3Region)
+
+			config := &lifecycleConfiguration{}
+			require.NoError(t, xml.Unmarshal(body, config))
+
+			configs <- config
+		}))
+		defer srv.Close()
+
+		b, err := NewS3(srv.URL+"/archive", &Config{Credentials: testCredentials()})
+		require.NoError(t, err)
+		require.NoError(t, b.PutLifecycle(&LifecycleRule{ID: "expire", Prefix: "hub-router/", Days: 90}))
+
+		config := <-configs
+		require.Len(t, config.Rules, 1)
+		require.Equal(t, "expire", config.Rules[0].ID)
+		require.Equal(t, "hub-router/", config.Rules[0].Prefix)
+		require.Equal(t, "Enabled", config.Rules[0].Status)
+		require.Equal(t, 90, config.Rules[0].Expiration)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("<Error><Code>AccessDenied</Code></Error>")) // nolint:errcheck // test server
+		}))
+		defer srv.Close()
+
+		b, err := NewS3(srv.URL+"/archive", &Config{Credentials: testCredentials()})
+		require.NoError(t, err)
+
+		err = b.Put("1.json", nil, &PutOptions{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "s3 put 1.json")
+		require.Contains(t, err.Error(), "AccessDenied")
+
+		err = b.PutLifecycle(&LifecycleRule{ID: "expire", Days: 1})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "s3 put lifecycle")
+		require.Contains(t, err.Error(), "AccessDenied")
+
+		b, err = NewS3(srv.URL+"/archive", &Config{
+			Credentials: credentials.NewCredentials(&credentials.ErrorProvider{
+				Err: errors.New("no credentials"), ProviderName: "test",
+			}),
+		})
+		require.NoError(t, err)
+
+		err = b.Put("1.json", nil, &PutOptions{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no credentials")
+	})
+}
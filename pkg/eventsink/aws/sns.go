@@ -0,0 +1,82 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+// SNS publishes the notifications to an SNS topic.
+type SNS struct {
+	client   *sns.SNS
+	topicARN string
+	timeout  time.Duration
+}
+
+// NewSNS returns a new SNS sink publishing the notifications to the topic. The notifications of a connection are
+// ordered with a FIFO topic (.fifo).
+func NewSNS(topicARN string, config *Config) (*SNS, error) {
+	// arn:aws:sns:{region}:{account}:{topic}
+	parts := strings.Split(topicARN, ":")
+	if len(parts) != 6 || parts[2] != "sns" { // nolint:gomnd // arn fields
+		return nil, fmt.Errorf("invalid sns topic arn %s", topicARN)
+	}
+
+	region := config.Region
+	if region == "" {
+		region = parts[3]
+	}
+
+	if region == "" {
+		return nil, errors.New("no region in sns topic arn " + topicARN)
+	}
+
+	s, err := newSession(region, config.Endpoint, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SNS{client: sns.New(s), topicARN: topicARN, timeout: defaultTimeout}, nil
+}
+
+// Publish publishes the notification to the topic.
+func (t *SNS) Publish(n *webhook.Notification) error {
+	input := &sns.PublishInput{
+		TopicArn:          aws.String(t.topicARN),
+		Message:           aws.String(string(n.Body)),
+		MessageAttributes: map[string]*sns.MessageAttributeValue{},
+	}
+
+	for name, value := range attributes(n) {
+		input.MessageAttributes[name] = &sns.MessageAttributeValue{
+			DataType: aws.String(stringType), StringValue: aws.String(value),
+		}
+	}
+
+	if strings.HasSuffix(t.topicARN, fifoSuffix) {
+		input.MessageGroupId, input.MessageDeduplicationId = fifo(n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+
+	_, err := t.client.PublishWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("sns Publish : %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package aws
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestSNS(t *testing.T) {
+	creds := testCredentials()
+	notification := &webhook.Notification{
+		ID: "msg-1", Topic: "queue", Body: []byte(`{"id":"msg-1"}`),
+		Headers: map[string]string{"ce-id": "msg-1", "Content-Type": "application/json"},
+	}
+
+	t.Run("new", func(t *testing.T) {
+		s, err := NewSNS("arn:aws:sns:eu-west-1:123:events", &Config{Credentials: creds})
+		require.NoError(t, err)
+		require.Equal(t, "eu-west-1", *s.client.Config.Region)
+		require.Equal(t, "https://sns.eu-west-1.amazonaws.com", s.client.Endpoint)
+
+		_, err = NewSNS("arn:aws:sqs:eu-west-1:123:events", &Config{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid sns topic arn")
+
+		_, err = NewSNS("arn:aws:sns::123:events", &Config{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no region in sns topic arn")
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		forms := make(chan url.Values, 2)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			requireSigned(t, r, body, "sns", "eu-west-1")
+
+			form, err := url.ParseQuery(string(body))
+			require.NoError(t, err)
+
+			forms <- form
+		}))
+		defer srv.Close()
+
+		s, err := NewSNS("arn:aws:sns:eu-west-1:123:events.fifo", &Config{Endpoint: srv.URL, Credentials: creds})
+		require.NoError(t, err)
+		require.NoError(t, s.Publish(notification))
+
+		form := <-forms
+		require.Equal(t, "Publish", form.Get("Action"))
+		require.Equal(t, "arn:aws:sns:eu-west-1:123:events.fifo", form.Get("TopicArn"))
+		require.Equal(t, `{"id":"msg-1"}`, form.Get("Message"))
+		require.Equal(t, "Content-Type", form.Get("MessageAttributes.entry.1.Name"))
+		require.Equal(t, "ce-id", form.Get("MessageAttributes.entry.2.Name"))
+		require.Equal(t, "topic", form.Get("MessageAttributes.entry.3.Name"))
+		require.Equal(t, "queue", form.Get("MessageAttributes.entry.3.Value.StringValue"))
+		require.Equal(t, "queue", form.Get("MessageGroupId"))
+		require.Equal(t, "msg-1", form.Get("MessageDeduplicationId"))
+	})
+
+	t.Run("publish error", func(t *testing.T) {
+		s, err := NewSNS("arn:aws:sns:eu-west-1:123:events", &Config{Endpoint: "http://127.0.0.1:1", Credentials: creds})
+		require.NoError(t, err)
+
+		err = s.Publish(notification)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "sns Publish")
+	})
+}
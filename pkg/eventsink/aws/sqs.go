@@ -0,0 +1,83 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+// SQS publishes the notifications to an SQS queue.
+type SQS struct {
+	client   *sqs.SQS
+	queueURL string
+	timeout  time.Duration
+}
+
+// NewSQS returns a new SQS sink sending the notifications to the queue, through the endpoint of the queue URL. The
+// notifications of a connection are ordered with a FIFO queue (.fifo).
+func NewSQS(queueURL string, config *Config) (*SQS, error) {
+	u, err := url.Parse(queueURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid sqs queue url %s", queueURL)
+	}
+
+	region := config.Region
+	if region == "" {
+		// https://sqs.{region}.amazonaws.com/{account}/{queue}
+		parts := strings.Split(u.Host, ".")
+		if len(parts) < 3 || parts[0] != "sqs" { // nolint:gomnd // sqs.{region}.amazonaws.com
+			return nil, fmt.Errorf("no region in sqs queue url %s", queueURL)
+		}
+
+		region = parts[1]
+	}
+
+	s, err := newSession(region, u.Scheme+"://"+u.Host, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SQS{client: sqs.New(s), queueURL: queueURL, timeout: defaultTimeout}, nil
+}
+
+// Publish sends the notification to the queue.
+func (q *SQS) Publish(n *webhook.Notification) error {
+	input := &sqs.SendMessageInput{
+		QueueUrl:          aws.String(q.queueURL),
+		MessageBody:       aws.String(string(n.Body)),
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{},
+	}
+
+	for name, value := range attributes(n) {
+		input.MessageAttributes[name] = &sqs.MessageAttributeValue{
+			DataType: aws.String(stringType), StringValue: aws.String(value),
+		}
+	}
+
+	if strings.HasSuffix(q.queueURL, fifoSuffix) {
+		input.MessageGroupId, input.MessageDeduplicationId = fifo(n)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+	defer cancel()
+
+	_, err := q.client.SendMessageWithContext(ctx, input)
+	if err != nil {
+		return fmt.Errorf("sqs SendMessage : %w", err)
+	}
+
+	return nil
+}
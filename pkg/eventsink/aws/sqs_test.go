@@ -0,0 +1,109 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package aws
+
+import (
+	"crypto/md5" // nolint:gosec // SQS message checksum
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestSQS(t *testing.T) {
+	creds := testCredentials()
+	notification := &webhook.Notification{
+		ID: "msg-1", Topic: "presence", ConnectionID: "conn-1", Body: []byte(`{"id":"msg-1"}`),
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+
+	t.Run("new", func(t *testing.T) {
+		q, err := NewSQS("https://sqs.eu-west-1.amazonaws.com/123/events", &Config{})
+		require.NoError(t, err)
+		require.Equal(t, "eu-west-1", *q.client.Config.Region)
+		require.Equal(t, "https://sqs.eu-west-1.amazonaws.com", q.client.Endpoint)
+
+		q, err = NewSQS("http://localhost:4566/123/events", &Config{Region: "us-east-1", Credentials: creds})
+		require.NoError(t, err)
+		require.Equal(t, "us-east-1", *q.client.Config.Region)
+		require.Equal(t, "http://localhost:4566", q.client.Endpoint)
+
+		_, err = NewSQS("http://localhost:4566/123/events", &Config{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no region in sqs queue url")
+
+		_, err = NewSQS("invalid", &Config{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid sqs queue url")
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		forms := make(chan url.Values, 2)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			requireSigned(t, r, body, "sqs", "us-east-1")
+
+			form, err := url.ParseQuery(string(body))
+			require.NoError(t, err)
+
+			// the SDK checks the MD5 of the message body returned by SQS
+			sum := md5.Sum([]byte(form.Get("MessageBody"))) // nolint:gosec // SQS message checksum
+			fmt.Fprintf(w, "<SendMessageResponse><SendMessageResult><MD5OfMessageBody>%x</MD5OfMessageBody>"+
+				"</SendMessageResult></SendMessageResponse>", sum)
+
+			forms <- form
+		}))
+		defer srv.Close()
+
+		q, err := NewSQS(srv.URL+"/123/events", &Config{Region: "us-east-1", Credentials: creds})
+		require.NoError(t, err)
+		require.NoError(t, q.Publish(notification))
+
+		form := <-forms
+		require.Equal(t, "SendMessage", form.Get("Action"))
+		require.Equal(t, `{"id":"msg-1"}`, form.Get("MessageBody"))
+		require.Equal(t, srv.URL+"/123/events", form.Get("QueueUrl"))
+		require.Equal(t, "Content-Type", form.Get("MessageAttribute.1.Name"))
+		require.Equal(t, "application/json", form.Get("MessageAttribute.1.Value.StringValue"))
+		require.Equal(t, "topic", form.Get("MessageAttribute.2.Name"))
+		require.Equal(t, "String", form.Get("MessageAttribute.2.Value.DataType"))
+		require.Equal(t, "presence", form.Get("MessageAttribute.2.Value.StringValue"))
+		require.Empty(t, form.Get("MessageGroupId"))
+
+		q, err = NewSQS(srv.URL+"/123/events.fifo", &Config{Region: "us-east-1", Credentials: creds})
+		require.NoError(t, err)
+		require.NoError(t, q.Publish(notification))
+
+		form = <-forms
+		require.Equal(t, "conn-1", form.Get("MessageGroupId"))
+		require.Equal(t, "msg-1", form.Get("MessageDeduplicationId"))
+	})
+
+	t.Run("publish error", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		}))
+		defer srv.Close()
+
+		q, err := NewSQS(srv.URL+"/123/events", &Config{Region: "us-east-1", Credentials: creds})
+		require.NoError(t, err)
+
+		err = q.Publish(notification)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "sqs SendMessage")
+		require.Contains(t, err.Error(), "403")
+	})
+}
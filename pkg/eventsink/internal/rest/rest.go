@@ -0,0 +1,54 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+// maxResponseSize limits the response bodies read from the cloud APIs.
+const maxResponseSize = 64 * 1024
+
+var logger = log.New("hub-router/eventsink")
+
+// HTTPClient sends the requests of the event sinks.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Do sends the request and returns the response body, or an error with the response body if the response status is
+// not 2xx.
+func Do(client HTTPClient, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s %s : %w", req.Method, req.URL.Host, err)
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			logger.Warnf("failed to close response body : %s", errClose)
+		}
+	}()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("read %s response : %w", req.URL.Host, err)
+	}
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("%s %s : unexpected status %d : %s", req.Method, req.URL.Host, resp.StatusCode,
+			strings.TrimSpace(string(body)))
+	}
+
+	return body, nil
+}
@@ -0,0 +1,80 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package rest
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type clientFunc func(req *http.Request) (*http.Response, error)
+
+func (f clientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type errorReader struct{}
+
+func (r *errorReader) Read([]byte) (int, error) {
+	return 0, errors.New("read error")
+}
+
+func TestDo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/denied" {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte("access denied\n")) // nolint:errcheck // test server
+
+			return
+		}
+
+		_, _ = w.Write([]byte(strings.Repeat("a", 2*maxResponseSize))) // nolint:errcheck // test server
+	}))
+	defer srv.Close()
+
+	t.Run("response body read up to the max size", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+
+		body, err := Do(http.DefaultClient, req)
+		require.NoError(t, err)
+		require.Len(t, body, maxResponseSize)
+	})
+
+	t.Run("unexpected status", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/denied", nil)
+		require.NoError(t, err)
+
+		_, err = Do(http.DefaultClient, req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unexpected status 403 : access denied")
+	})
+
+	t.Run("request errors", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		require.NoError(t, err)
+
+		_, err = Do(clientFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("connection refused")
+		}), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "connection refused")
+
+		_, err = Do(clientFunc(func(*http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(&errorReader{})}, nil
+		}), req)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "read 127.0.0.1")
+		require.Contains(t, err.Error(), "read error")
+	})
+}
@@ -0,0 +1,66 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const pubsubScope = "https://www.googleapis.com/auth/pubsub"
+
+// NewServiceAccountTokenSource returns the TokenSource of the service account with the key file, for the Pub/Sub
+// scope. The token requests are sent with the client, http.DefaultClient if nil.
+func NewServiceAccountTokenSource(keyFile string, client *http.Client) (oauth2.TokenSource, error) {
+	keyBytes, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("read service account key : %w", err)
+	}
+
+	config, err := google.JWTConfigFromJSON(keyBytes, pubsubScope)
+	if err != nil {
+		return nil, fmt.Errorf("decode service account key : %w", err)
+	}
+
+	return config.TokenSource(clientContext(client)), nil
+}
+
+// NewDefaultTokenSource returns the TokenSource of the Application Default Credentials (GOOGLE_APPLICATION_CREDENTIALS,
+// the gcloud credentials, else the metadata server on GCE and GKE), for the Pub/Sub scope. The credentials are found
+// at the first token, so that the router starts before the metadata server is reachable.
+func NewDefaultTokenSource(client *http.Client) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &defaultTokenSource{ctx: clientContext(client)})
+}
+
+type defaultTokenSource struct {
+	ctx context.Context
+}
+
+func (s *defaultTokenSource) Token() (*oauth2.Token, error) {
+	creds, err := google.FindDefaultCredentials(s.ctx, pubsubScope)
+	if err != nil {
+		return nil, fmt.Errorf("find default credentials : %w", err)
+	}
+
+	return creds.TokenSource.Token()
+}
+
+// clientContext returns the context of the token requests, sent with the client if not nil.
+func clientContext(client *http.Client) context.Context {
+	ctx := context.Background()
+
+	if client != nil {
+		ctx = context.WithValue(ctx, oauth2.HTTPClient, client)
+	}
+
+	return ctx
+}
@@ -0,0 +1,224 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pubsub
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceAccountTokenSource(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	t.Run("token", func(t *testing.T) {
+		var calls int32
+
+		srv := tokenServer(t, privateKey, &calls)
+		defer srv.Close()
+
+		ts, err := NewServiceAccountTokenSource(writeKey(t, privateKey, srv.URL), srv.Client())
+		require.NoError(t, err)
+
+		token, err := ts.Token()
+		require.NoError(t, err)
+		require.Equal(t, "sa-token", token.AccessToken)
+
+		_, err = ts.Token()
+		require.NoError(t, err)
+		require.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	})
+
+	t.Run("token errors", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/invalid" {
+				fmt.Fprint(w, "{")
+
+				return
+			}
+
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+		}))
+		defer srv.Close()
+
+		for _, path := range []string{"/invalid", "/denied"} {
+			ts, err := NewServiceAccountTokenSource(writeKey(t, privateKey, srv.URL+path), nil)
+			require.NoError(t, err)
+
+			_, err = ts.Token()
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "cannot fetch token")
+		}
+
+		ts, err := NewServiceAccountTokenSource(keyFile(t, keyJSON("invalid", srv.URL)), nil)
+		require.NoError(t, err)
+
+		_, err = ts.Token()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "private key")
+	})
+
+	t.Run("invalid key file", func(t *testing.T) {
+		_, err := NewServiceAccountTokenSource("invalid-file", nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "read service account key")
+
+		for _, content := range []string{"{", `{"type":"authorized_user"}`} {
+			_, err = NewServiceAccountTokenSource(keyFile(t, content), nil)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "decode service account key")
+		}
+	})
+}
+
+func TestDefaultTokenSource(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+
+		switch r.URL.Path {
+		case "/computeMetadata/v1/project/project-id":
+			fmt.Fprint(w, "project")
+		case "/computeMetadata/v1/instance/service-accounts/default/token":
+			fmt.Fprint(w, `{"access_token":"metadata-token","expires_in":3600,"token_type":"Bearer"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer metadata.Close()
+
+	var calls int32
+
+	srv := tokenServer(t, privateKey, &calls)
+	defer srv.Close()
+
+	// no gcloud credentials
+	setenv(t, "HOME", t.TempDir())
+
+	t.Run("service account key file", func(t *testing.T) {
+		setenv(t, "GOOGLE_APPLICATION_CREDENTIALS", writeKey(t, privateKey, srv.URL))
+
+		token, err := NewDefaultTokenSource(srv.Client()).Token()
+		require.NoError(t, err)
+		require.Equal(t, "sa-token", token.AccessToken)
+	})
+
+	t.Run("invalid key file", func(t *testing.T) {
+		setenv(t, "GOOGLE_APPLICATION_CREDENTIALS", "invalid-file")
+
+		_, err := NewDefaultTokenSource(nil).Token()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "find default credentials")
+	})
+
+	t.Run("metadata server", func(t *testing.T) {
+		setenv(t, "GOOGLE_APPLICATION_CREDENTIALS", "")
+		setenv(t, "GCE_METADATA_HOST", strings.TrimPrefix(metadata.URL, "http://"))
+
+		token, err := NewDefaultTokenSource(nil).Token()
+		require.NoError(t, err)
+		require.Equal(t, "metadata-token", token.AccessToken)
+	})
+}
+
+// tokenServer returns a token endpoint verifying the assertions of the service account with the private key.
+func tokenServer(t *testing.T, privateKey *rsa.PrivateKey, calls *int32) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "urn:ietf:params:oauth:grant-type:jwt-bearer", r.Form.Get("grant_type"))
+
+		parts := strings.Split(r.Form.Get("assertion"), ".")
+		require.Len(t, parts, 3)
+
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		require.NoError(t, err)
+
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		require.NoError(t, rsa.VerifyPKCS1v15(&privateKey.PublicKey, crypto.SHA256, digest[:], signature))
+
+		claimsBytes, err := base64.RawURLEncoding.DecodeString(parts[1])
+		require.NoError(t, err)
+
+		claims := make(map[string]interface{})
+		require.NoError(t, json.Unmarshal(claimsBytes, &claims))
+		require.Equal(t, "router@project.iam.gserviceaccount.com", claims["iss"])
+		require.Equal(t, pubsubScope, claims["scope"])
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"sa-token","expires_in":3600,"token_type":"Bearer"}`)
+	}))
+}
+
+func writeKey(t *testing.T, privateKey *rsa.PrivateKey, tokenURI string) string {
+	t.Helper()
+
+	keyBytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+
+	return keyFile(t, keyJSON(string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyBytes})), tokenURI))
+}
+
+func keyFile(t *testing.T, content string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "key.json")
+	require.NoError(t, ioutil.WriteFile(file, []byte(content), 0600))
+
+	return file
+}
+
+func keyJSON(privateKey, tokenURI string) string {
+	keyBytes, _ := json.Marshal(map[string]string{ // nolint:errcheck // test key
+		"type":           "service_account",
+		"client_email":   "router@project.iam.gserviceaccount.com",
+		"private_key_id": "key-1",
+		"private_key":    privateKey,
+		"token_uri":      tokenURI,
+	})
+
+	return string(keyBytes)
+}
+
+// setenv sets the environment variable until the end of the test.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+
+	previous, ok := os.LookupEnv(key)
+	require.NoError(t, os.Setenv(key, value))
+
+	t.Cleanup(func() {
+		if ok {
+			require.NoError(t, os.Setenv(key, previous))
+		} else {
+			require.NoError(t, os.Unsetenv(key))
+		}
+	})
+}
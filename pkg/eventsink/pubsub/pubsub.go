@@ -0,0 +1,155 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+
+	"github.com/trustbloc/hub-router/pkg/eventsink/internal/rest"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+const (
+	defaultEndpoint = "https://pubsub.googleapis.com"
+	defaultTimeout  = 10 * time.Second
+	// orderingLocks is the number of locks serializing the messages with the same ordering key.
+	orderingLocks = 64
+	// topicAttribute is the message attribute with the notification topic.
+	topicAttribute = "topic"
+)
+
+var topicPattern = regexp.MustCompile(`^projects/[^/]+/topics/[^/]+$`)
+
+// HTTPClient sends the Pub/Sub requests.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Config of the Pub/Sub sink.
+type Config struct {
+	// Endpoint of the Pub/Sub API, eg: a regional endpoint (https://{region}-pubsub.googleapis.com). Defaults to the
+	// global endpoint.
+	Endpoint string
+	// Credentials of the requests. Defaults to the Application Default Credentials.
+	Credentials oauth2.TokenSource
+	Client      HTTPClient
+}
+
+type publishRequest struct {
+	Messages []*message `json:"messages"`
+}
+
+type message struct {
+	Data        []byte            `json:"data"`
+	Attributes  map[string]string `json:"attributes"`
+	OrderingKey string            `json:"orderingKey,omitempty"`
+}
+
+// Publisher publishes the notifications to a Pub/Sub topic, with the connection ID as ordering key : the consumers
+// get the notifications of each connection in order, with a subscription with message ordering enabled.
+type Publisher struct {
+	url     string
+	creds   oauth2.TokenSource
+	client  HTTPClient
+	timeout time.Duration
+	// locks serialize the publishing of the messages with the same ordering key (hashed to a lock).
+	locks [orderingLocks]sync.Mutex
+}
+
+// New returns a new Publisher publishing to the topic (projects/{project}/topics/{topic}).
+func New(topic string, config *Config) (*Publisher, error) {
+	if !topicPattern.MatchString(topic) {
+		return nil, fmt.Errorf("invalid pubsub topic %s : expected projects/{project}/topics/{topic}", topic)
+	}
+
+	p := &Publisher{creds: config.Credentials, client: config.Client, timeout: defaultTimeout}
+
+	if p.client == nil {
+		p.client = http.DefaultClient
+	}
+
+	if p.creds == nil {
+		p.creds = NewDefaultTokenSource(nil)
+	}
+
+	endpoint := config.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	p.url = strings.TrimSuffix(endpoint, "/") + "/v1/" + topic + ":publish"
+
+	return p, nil
+}
+
+// Publish publishes the notification to the topic. The message attributes are the notification topic and headers.
+func (p *Publisher) Publish(n *webhook.Notification) error {
+	msg := &message{
+		Data:        n.Body,
+		Attributes:  map[string]string{topicAttribute: n.Topic},
+		OrderingKey: n.ConnectionID,
+	}
+
+	for k, v := range n.Headers {
+		msg.Attributes[k] = v
+	}
+
+	if msg.OrderingKey != "" {
+		h := fnv.New32a()
+		h.Write([]byte(msg.OrderingKey)) // nolint:errcheck,gosec // hash writes never fail
+
+		lock := &p.locks[h.Sum32()%orderingLocks]
+
+		lock.Lock()
+		defer lock.Unlock()
+	}
+
+	err := p.publish(msg)
+	if err != nil {
+		return fmt.Errorf("pubsub publish : %w", err)
+	}
+
+	return nil
+}
+
+func (p *Publisher) publish(msg *message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	token, err := p.creds.Token()
+	if err != nil {
+		return fmt.Errorf("get access token : %w", err)
+	}
+
+	reqBytes, err := json.Marshal(&publishRequest{Messages: []*message{msg}})
+	if err != nil {
+		return fmt.Errorf("marshal publish request : %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(reqBytes))
+	if err != nil {
+		return fmt.Errorf("create publish request : %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	token.SetAuthHeader(req)
+
+	_, err = rest.Do(p.client, req)
+
+	return err
+}
@@ -0,0 +1,162 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestPublisher(t *testing.T) {
+	notification := &webhook.Notification{
+		ID: "msg-1", Topic: "presence", ConnectionID: "conn-1", Body: []byte(`{"id":"msg-1"}`),
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+
+	t.Run("new", func(t *testing.T) {
+		p, err := New("projects/router/topics/events", &Config{Credentials: &mockTokenSource{}})
+		require.NoError(t, err)
+		require.Equal(t, "https://pubsub.googleapis.com/v1/projects/router/topics/events:publish", p.url)
+
+		p, err = New("projects/router/topics/events", &Config{})
+		require.NoError(t, err)
+		require.NotNil(t, p.creds)
+
+		_, err = New("events", &Config{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid pubsub topic events")
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		reqs := make(chan *publishRequest, 1)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/v1/projects/router/topics/events:publish", r.URL.Path)
+			require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+			req := &publishRequest{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(req))
+
+			reqs <- req
+		}))
+		defer srv.Close()
+
+		p, err := New("projects/router/topics/events", &Config{
+			Endpoint: srv.URL + "/", Credentials: &mockTokenSource{token: "token"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, p.Publish(notification))
+
+		req := <-reqs
+		require.Len(t, req.Messages, 1)
+		require.Equal(t, `{"id":"msg-1"}`, string(req.Messages[0].Data))
+		require.Equal(t, "conn-1", req.Messages[0].OrderingKey)
+		require.Equal(t, map[string]string{"topic": "presence", "Content-Type": "application/json"},
+			req.Messages[0].Attributes)
+	})
+
+	t.Run("ordered publish", func(t *testing.T) {
+		var (
+			mutex    sync.Mutex
+			inFlight = make(map[string]bool)
+		)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			req := &publishRequest{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(req))
+
+			key := req.Messages[0].OrderingKey
+
+			mutex.Lock()
+			require.False(t, inFlight[key], "concurrent publish of ordering key %s", key)
+			inFlight[key] = true
+			mutex.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mutex.Lock()
+			inFlight[key] = false
+			mutex.Unlock()
+		}))
+		defer srv.Close()
+
+		p, err := New("projects/router/topics/events", &Config{Endpoint: srv.URL, Credentials: &mockTokenSource{}})
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				n := *notification
+				n.ConnectionID = []string{"conn-1", "conn-2"}[i%2]
+
+				require.NoError(t, p.Publish(&n))
+			}(i)
+		}
+
+		wg.Wait()
+	})
+
+	t.Run("publish errors", func(t *testing.T) {
+		p, err := New("projects/router/topics/events", &Config{
+			Credentials: &mockTokenSource{err: errors.New("token error")},
+		})
+		require.NoError(t, err)
+
+		err = p.Publish(notification)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "pubsub publish : get access token : token error")
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error":{"message":"Resource not found"}}`)) // nolint:errcheck // test server
+		}))
+		defer srv.Close()
+
+		p, err = New("projects/router/topics/events", &Config{Endpoint: srv.URL, Credentials: &mockTokenSource{}})
+		require.NoError(t, err)
+
+		err = p.Publish(notification)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unexpected status 404")
+		require.Contains(t, err.Error(), "Resource not found")
+
+		p, err = New("projects/router/topics/events", &Config{Endpoint: ":invalid", Credentials: &mockTokenSource{}})
+		require.NoError(t, err)
+
+		err = p.Publish(notification)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create publish request")
+	})
+}
+
+type mockTokenSource struct {
+	token string
+	err   error
+}
+
+func (m *mockTokenSource) Token() (*oauth2.Token, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &oauth2.Token{AccessToken: m.token}, nil
+}
@@ -0,0 +1,43 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package servicebus
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+)
+
+// NewDefaultCredential returns the Azure AD credential of the identity the router runs with : the service principal
+// of the environment (AZURE_TENANT_ID, AZURE_CLIENT_ID and AZURE_CLIENT_SECRET), the workload identity
+// (AZURE_FEDERATED_TOKEN_FILE, eg: AKS), else the managed identity of the App Service, container app or VM (the
+// user-assigned identity AZURE_CLIENT_ID if set). The token requests are sent with the client, if not nil.
+func NewDefaultCredential(client HTTPClient) (azcore.TokenCredential, error) {
+	options := &azidentity.DefaultAzureCredentialOptions{}
+	options.Transport = client
+
+	cred, err := azidentity.NewDefaultAzureCredential(options)
+	if err != nil {
+		return nil, fmt.Errorf("azure default credential : %w", err)
+	}
+
+	return cred, nil
+}
+
+// entityPath returns the EntityPath of the connection string, empty if not set.
+func entityPath(connStr string) string {
+	for _, part := range strings.Split(connStr, ";") {
+		kv := strings.SplitN(part, "=", 2) // nolint:gomnd // key=value
+		if len(kv) == 2 && strings.EqualFold(strings.TrimSpace(kv[0]), "EntityPath") {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,76 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package servicebus
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultCredential(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "secret", r.Header.Get("X-IDENTITY-HEADER"))
+		require.Equal(t, "https://servicebus.azure.net", r.URL.Query().Get("resource"))
+
+		fmt.Fprint(w, `{"access_token":"identity-token","expires_on":"4102444800","resource":"x","token_type":"Bearer"}`)
+	}))
+	defer srv.Close()
+
+	for _, key := range []string{
+		"AZURE_CLIENT_ID", "AZURE_TENANT_ID", "AZURE_CLIENT_SECRET", "AZURE_FEDERATED_TOKEN_FILE", "MSI_ENDPOINT",
+	} {
+		setenv(t, key, "")
+	}
+
+	// the App Service and container app identity
+	setenv(t, "IDENTITY_ENDPOINT", srv.URL)
+	setenv(t, "IDENTITY_HEADER", "secret")
+
+	cred, err := NewDefaultCredential(srv.Client())
+	require.NoError(t, err)
+
+	token, err := cred.GetToken(context.Background(), policy.TokenRequestOptions{
+		Scopes: []string{"https://servicebus.azure.net/.default"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "identity-token", token.Token)
+}
+
+func TestEntityPath(t *testing.T) {
+	require.Equal(t, "events", entityPath("Endpoint=sb://router.servicebus.windows.net/;SharedAccessKeyName=send;"+
+		"SharedAccessKey=secret;EntityPath=events"))
+	require.Equal(t, "events", entityPath("entitypath = events"))
+	require.Empty(t, entityPath("Endpoint=sb://router.servicebus.windows.net/;SharedAccessKey=secret"))
+}
+
+// setenv sets the environment variable until the end of the test, unset if empty.
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+
+	previous, ok := os.LookupEnv(key)
+
+	if value == "" {
+		require.NoError(t, os.Unsetenv(key))
+	} else {
+		require.NoError(t, os.Setenv(key, value))
+	}
+
+	t.Cleanup(func() {
+		if ok {
+			require.NoError(t, os.Setenv(key, previous))
+		} else {
+			require.NoError(t, os.Unsetenv(key))
+		}
+	})
+}
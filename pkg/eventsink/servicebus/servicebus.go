@@ -0,0 +1,171 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package servicebus
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+const (
+	defaultTimeout = 10 * time.Second
+	// sessionLocks is the number of locks serializing the messages of the same session.
+	sessionLocks = 64
+	// topicProperty is the message property with the notification topic.
+	topicProperty = "topic"
+)
+
+// HTTPClient sends the token requests of the default credential.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Config of the Service Bus sink.
+type Config struct {
+	// ConnectionString with the shared access key of the namespace or entity
+	// (Endpoint=sb://{namespace}.servicebus.windows.net/;SharedAccessKeyName=...;SharedAccessKey=...[;EntityPath=...]).
+	ConnectionString string
+	// Credential of the messages sent without connection string. Defaults to the Azure default credential.
+	Credential azcore.TokenCredential
+	Client     HTTPClient
+	// TLSConfig of the AMQP connection.
+	TLSConfig *tls.Config
+}
+
+// messageSender sends the messages to the queue or topic : the azservicebus sender.
+type messageSender interface {
+	SendMessage(ctx context.Context, message *azservicebus.Message, options *azservicebus.SendMessageOptions) error
+}
+
+// Sender sends the notifications to a Service Bus queue or topic, in the session of their connection : the consumers
+// of a session-enabled queue or subscription get the notifications of each connection in order.
+type Sender struct {
+	entity  string
+	sender  messageSender
+	timeout time.Duration
+	// locks serialize the sending of the messages of the same session (hashed to a lock).
+	locks [sessionLocks]sync.Mutex
+}
+
+// New returns a new Sender sending to the queue or topic (https://{namespace}.servicebus.windows.net/{entity}), or to
+// the EntityPath of the connection string if the entity URL is empty.
+func New(entityURL string, config *Config) (*Sender, error) {
+	namespace, entity, err := parseEntityURL(entityURL)
+	if err != nil {
+		return nil, err
+	}
+
+	options := &azservicebus.ClientOptions{TLSConfig: config.TLSConfig}
+
+	var client *azservicebus.Client
+
+	switch {
+	case config.ConnectionString != "":
+		client, err = azservicebus.NewClientFromConnectionString(config.ConnectionString, options)
+		if err != nil {
+			return nil, fmt.Errorf("invalid service bus connection string : %w", err)
+		}
+
+		if entity == "" {
+			entity = entityPath(config.ConnectionString)
+		}
+	case namespace != "":
+		cred := config.Credential
+		if cred == nil {
+			if cred, err = NewDefaultCredential(config.Client); err != nil {
+				return nil, err
+			}
+		}
+
+		client, err = azservicebus.NewClient(namespace, cred, options)
+		if err != nil {
+			return nil, fmt.Errorf("service bus client : %w", err)
+		}
+	}
+
+	if entity == "" {
+		return nil, errors.New("service bus entity url is required without EntityPath in the connection string")
+	}
+
+	sender, err := client.NewSender(entity, nil)
+	if err != nil {
+		return nil, fmt.Errorf("service bus sender : %w", err)
+	}
+
+	return &Sender{entity: entity, sender: sender, timeout: defaultTimeout}, nil
+}
+
+// parseEntityURL returns the namespace and entity of the entity URL, empty if the URL is empty.
+func parseEntityURL(entityURL string) (string, string, error) {
+	if entityURL == "" {
+		return "", "", nil
+	}
+
+	u, err := url.Parse(entityURL)
+	if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return "", "", fmt.Errorf("invalid service bus entity url %s", entityURL)
+	}
+
+	return u.Host, strings.Trim(u.Path, "/"), nil
+}
+
+// Publish sends the notification to the queue or topic. The session is the connection ID (the topic for the
+// notifications without connection), and the application properties are the notification topic and headers.
+func (s *Sender) Publish(n *webhook.Notification) error {
+	msg := &azservicebus.Message{
+		Body:                  n.Body,
+		MessageID:             &n.ID,
+		SessionID:             &n.ConnectionID,
+		ApplicationProperties: map[string]interface{}{topicProperty: n.Topic},
+	}
+
+	if n.ConnectionID == "" {
+		msg.SessionID = &n.Topic
+	}
+
+	for k, v := range n.Headers {
+		if strings.EqualFold(k, "Content-Type") {
+			contentType := v
+			msg.ContentType = &contentType
+
+			continue
+		}
+
+		msg.ApplicationProperties[k] = v
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(*msg.SessionID)) // nolint:errcheck,gosec // hash writes never fail
+
+	lock := &s.locks[h.Sum32()%sessionLocks]
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	err := s.sender.SendMessage(ctx, msg, nil)
+	if err != nil {
+		return fmt.Errorf("service bus send : %w", err)
+	}
+
+	return nil
+}
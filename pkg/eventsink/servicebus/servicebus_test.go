@@ -0,0 +1,154 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package servicebus
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/messaging/azservicebus"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestSender(t *testing.T) {
+	notification := &webhook.Notification{
+		ID: "msg-1", Topic: "presence", ConnectionID: "conn-1", Body: []byte(`{"id":"msg-1"}`),
+		Headers: map[string]string{"Content-Type": "application/json", "ce-type": "org.trustbloc.hub-router.presence"},
+	}
+
+	connStr := "Endpoint=sb://router.servicebus.windows.net/;SharedAccessKeyName=send;SharedAccessKey=secret"
+
+	t.Run("new", func(t *testing.T) {
+		s, err := New("https://router.servicebus.windows.net/events/", &Config{Credential: &mockCredential{}})
+		require.NoError(t, err)
+		require.Equal(t, "events", s.entity)
+
+		s, err = New("", &Config{ConnectionString: connStr + ";EntityPath=events"})
+		require.NoError(t, err)
+		require.Equal(t, "events", s.entity)
+
+		s, err = New("https://router.servicebus.windows.net/other", &Config{ConnectionString: connStr + ";EntityPath=events"})
+		require.NoError(t, err)
+		require.Equal(t, "other", s.entity)
+
+		for _, entityURL := range []string{"router", "https://router.servicebus.windows.net/", ":invalid"} {
+			_, err = New(entityURL, &Config{})
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "invalid service bus entity url")
+		}
+
+		_, err = New("", &Config{ConnectionString: connStr})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "entity url is required without EntityPath")
+
+		_, err = New("", &Config{ConnectionString: "invalid"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid service bus connection string")
+	})
+
+	t.Run("publish", func(t *testing.T) {
+		sender := &mockSender{}
+		s := &Sender{sender: sender, timeout: time.Second}
+
+		require.NoError(t, s.Publish(notification))
+
+		msg := sender.messages[0]
+		require.Equal(t, "msg-1", *msg.MessageID)
+		require.Equal(t, "conn-1", *msg.SessionID)
+		require.Equal(t, "application/json", *msg.ContentType)
+		require.Equal(t, `{"id":"msg-1"}`, string(msg.Body))
+		require.Equal(t, map[string]interface{}{
+			"topic": "presence", "ce-type": "org.trustbloc.hub-router.presence",
+		}, msg.ApplicationProperties)
+
+		n := *notification
+		n.ConnectionID = ""
+
+		require.NoError(t, s.Publish(&n))
+		require.Equal(t, "presence", *sender.messages[1].SessionID)
+	})
+
+	t.Run("ordered publish", func(t *testing.T) {
+		var (
+			mutex    sync.Mutex
+			inFlight = make(map[string]bool)
+		)
+
+		s := &Sender{timeout: time.Second, sender: &mockSender{send: func(msg *azservicebus.Message) error {
+			mutex.Lock()
+			require.False(t, inFlight[*msg.SessionID], "concurrent send to session %s", *msg.SessionID)
+			inFlight[*msg.SessionID] = true
+			mutex.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mutex.Lock()
+			inFlight[*msg.SessionID] = false
+			mutex.Unlock()
+
+			return nil
+		}}}
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				n := *notification
+				n.ConnectionID = []string{"conn-1", "conn-2", ""}[i%3]
+
+				require.NoError(t, s.Publish(&n))
+			}(i)
+		}
+
+		wg.Wait()
+	})
+
+	t.Run("publish error", func(t *testing.T) {
+		s := &Sender{timeout: time.Second, sender: &mockSender{send: func(*azservicebus.Message) error {
+			return errors.New("session id is required")
+		}}}
+
+		err := s.Publish(notification)
+		require.EqualError(t, err, "service bus send : session id is required")
+	})
+}
+
+type mockSender struct {
+	mutex    sync.Mutex
+	messages []*azservicebus.Message
+	send     func(msg *azservicebus.Message) error
+}
+
+func (m *mockSender) SendMessage(_ context.Context, msg *azservicebus.Message,
+	_ *azservicebus.SendMessageOptions) error {
+	if m.send != nil {
+		return m.send(msg)
+	}
+
+	m.mutex.Lock()
+	m.messages = append(m.messages, msg)
+	m.mutex.Unlock()
+
+	return nil
+}
+
+type mockCredential struct{}
+
+func (m *mockCredential) GetToken(context.Context, policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{}, nil
+}
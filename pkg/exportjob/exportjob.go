@@ -0,0 +1,250 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package exportjob runs the asynchronous exports of the admin API, and keeps their files until they expire : the jobs
+// are saved in the (transient) storage shared by the nodes, so that a job started on a node is read from another one.
+package exportjob
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "exportjob"
+	jobTag    = "job"
+
+	// DefaultTTL is the time the jobs and their files are kept for, if not set.
+	DefaultTTL = time.Hour
+
+	// DefaultMaxRunning is the number of jobs run concurrently by a node, if not set.
+	DefaultMaxRunning = 2
+)
+
+// Job status.
+const (
+	StatusPending = "pending"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+var (
+	// ErrNotFound is returned for a job not found, or expired.
+	ErrNotFound = errors.New("export job not found")
+	// ErrBusy is returned when the node already runs as many jobs as allowed.
+	ErrBusy = errors.New("too many export jobs running")
+)
+
+var logger = log.New("hub-router/exportjob")
+
+// Job is an export run in the background.
+type Job struct {
+	ID      string    `json:"jobID"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	Tenant  string    `json:"tenant,omitempty"`
+	Created time.Time `json:"created"`
+	// FileName and Data are the export file, once done.
+	FileName string `json:"fileName,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+}
+
+// ExportFunc returns the export file, and its name.
+type ExportFunc func() ([]byte, string, error)
+
+// Store runs the jobs, at most maxRunning at once, and keeps them for the ttl.
+type Store struct {
+	store    storage.Store
+	ttl      time.Duration
+	running  chan struct{}
+	now      func() time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns a new Store running maxRunning jobs at once, DefaultMaxRunning if zero, and keeping them for the ttl,
+// DefaultTTL if zero.
+func New(p storage.Provider, ttl time.Duration, maxRunning int) (*Store, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open export job store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{jobTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set export job store config : %w", err)
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	if maxRunning <= 0 {
+		maxRunning = DefaultMaxRunning
+	}
+
+	return &Store{
+		store: store, ttl: ttl, running: make(chan struct{}, maxRunning), now: time.Now, stop: make(chan struct{}),
+	}, nil
+}
+
+// Run saves a pending job of the tenant, and runs the export in the background : the job is saved again with the file
+// exported, or the export error. ErrBusy is returned, and the job isn't created, if the node runs as many jobs as
+// allowed.
+func (s *Store) Run(tenantID string, export ExportFunc) (*Job, error) {
+	select {
+	case s.running <- struct{}{}:
+	default:
+		return nil, ErrBusy
+	}
+
+	job := &Job{ID: uuid.New().String(), Status: StatusPending, Tenant: tenantID, Created: s.now().UTC()}
+
+	if err := s.save(job); err != nil {
+		<-s.running
+
+		return nil, err
+	}
+
+	// the job returned isn't updated by the export
+	pending := *job
+
+	go func() {
+		defer func() { <-s.running }()
+
+		data, name, err := export()
+		if err != nil {
+			job.Status, job.Error = StatusFailed, err.Error()
+		} else {
+			job.Status, job.Data, job.FileName = StatusDone, data, name
+		}
+
+		if err = s.save(job); err != nil {
+			logger.Errorf("failed to save export job id=[%s] : %s", job.ID, err)
+		}
+	}()
+
+	return &pending, nil
+}
+
+// Get returns the job, or ErrNotFound if not found or expired.
+func (s *Store) Get(id string) (*Job, error) {
+	jobBytes, err := s.store.Get(id)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get export job : %w", err)
+	}
+
+	job := &Job{}
+
+	if err = json.Unmarshal(jobBytes, job); err != nil {
+		return nil, fmt.Errorf("unmarshal export job : %w", err)
+	}
+
+	if s.now().Sub(job.Created) >= s.ttl {
+		return nil, ErrNotFound
+	}
+
+	return job, nil
+}
+
+func (s *Store) save(job *Job) error {
+	jobBytes, err := json.Marshal(job)
+	if err != nil {
+		return fmt.Errorf("marshal export job : %w", err)
+	}
+
+	if err = s.store.Put(job.ID, jobBytes, storage.Tag{Name: jobTag}); err != nil {
+		return fmt.Errorf("save export job : %w", err)
+	}
+
+	return nil
+}
+
+// Sweep deletes the jobs created before the ttl, with their file.
+func (s *Store) Sweep(now time.Time) error {
+	iter, err := s.store.Query(jobTag)
+	if err != nil {
+		return fmt.Errorf("query export jobs : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var expired []string
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("iterate export jobs : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		k, err := iter.Key()
+		if err != nil {
+			return fmt.Errorf("read export job key : %w", err)
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return fmt.Errorf("read export job : %w", err)
+		}
+
+		job := &Job{}
+
+		// the jobs that can't be read are dropped
+		if err = json.Unmarshal(val, job); err != nil || now.Sub(job.Created) >= s.ttl {
+			expired = append(expired, k)
+		}
+	}
+
+	for _, k := range expired {
+		if err = s.store.Delete(k); err != nil {
+			return fmt.Errorf("delete export job : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Start sweeps the expired jobs periodically until Stop is called.
+func (s *Store) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := s.Sweep(now.UTC()); err != nil {
+					logger.Warnf("export job sweep : %s", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic sweep.
+func (s *Store) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
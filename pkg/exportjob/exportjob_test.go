@@ -0,0 +1,182 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package exportjob
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), 0, 0)
+		require.NoError(t, err)
+		require.Equal(t, DefaultTTL, s.ttl)
+		require.Equal(t, DefaultMaxRunning, cap(s.running))
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, time.Minute, 1)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open export job store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p, time.Minute, 1)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set export job store config")
+	})
+}
+
+func TestStore(t *testing.T) {
+	t.Run("job run", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		s, err := New(p, time.Minute, 1)
+		require.NoError(t, err)
+
+		release := make(chan struct{})
+
+		job, err := s.Run("tenant-1", func() ([]byte, string, error) {
+			<-release
+
+			return []byte("id,time\n"), "audit.csv", nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, StatusPending, job.Status)
+
+		// the jobs are bounded
+		_, err = s.Run("tenant-1", func() ([]byte, string, error) { return nil, "", nil })
+		require.ErrorIs(t, err, ErrBusy)
+
+		// another node sharing the storage
+		other, err := New(p, time.Minute, 1)
+		require.NoError(t, err)
+
+		pending, err := other.Get(job.ID)
+		require.NoError(t, err)
+		require.Equal(t, StatusPending, pending.Status)
+		require.Equal(t, "tenant-1", pending.Tenant)
+
+		close(release)
+
+		require.Eventually(t, func() bool {
+			done, getErr := s.Get(job.ID)
+
+			return getErr == nil && done.Status == StatusDone
+		}, time.Second, 10*time.Millisecond)
+
+		done, err := s.Get(job.ID)
+		require.NoError(t, err)
+		require.Equal(t, "audit.csv", done.FileName)
+		require.Equal(t, []byte("id,time\n"), done.Data)
+
+		// the slot is released once the job is saved
+		require.Eventually(t, func() bool { return len(s.running) == 0 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("job failed", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), time.Minute, 1)
+		require.NoError(t, err)
+
+		job, err := s.Run("", func() ([]byte, string, error) { return nil, "", errors.New("export error") })
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool {
+			failed, getErr := s.Get(job.ID)
+
+			return getErr == nil && failed.Status == StatusFailed && failed.Error == "export error"
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("job expired", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), time.Minute, 1)
+		require.NoError(t, err)
+
+		job, err := s.Run("", func() ([]byte, string, error) { return []byte("data"), "stats.csv", nil })
+		require.NoError(t, err)
+
+		require.Eventually(t, func() bool { return len(s.running) == 0 }, time.Second, 10*time.Millisecond)
+
+		s.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+
+		_, err = s.Get(job.ID)
+		require.ErrorIs(t, err, ErrNotFound)
+
+		_, err = s.Get("unknown")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		// swept with its file
+		require.NoError(t, s.store.Put("invalid", []byte("invalid"), storage.Tag{Name: jobTag}))
+		require.NoError(t, s.Sweep(time.Now()))
+
+		_, err = s.store.Get(job.ID)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Sweep(time.Now().Add(2*time.Minute)))
+
+		for _, k := range []string{job.ID, "invalid"} {
+			_, err = s.store.Get(k)
+			require.ErrorIs(t, err, storage.ErrDataNotFound)
+		}
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), time.Millisecond, 1)
+		require.NoError(t, err)
+
+		require.NoError(t, s.save(&Job{ID: "1", Status: StatusDone}))
+
+		s.Start(time.Millisecond)
+		defer s.Stop()
+
+		require.Eventually(t, func() bool {
+			_, getErr := s.store.Get("1")
+
+			return errors.Is(getErr, storage.ErrDataNotFound)
+		}, time.Second, 5*time.Millisecond)
+
+		s.Stop()
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		s, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrGet:   errors.New("get error"),
+			ErrQuery: errors.New("query error"),
+		}), time.Minute, 1)
+		require.NoError(t, err)
+
+		_, err = s.Run("", func() ([]byte, string, error) { return nil, "", nil })
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save export job")
+		require.Empty(t, s.running)
+
+		_, err = s.Get("1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get export job")
+
+		err = s.Sweep(time.Now())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query export jobs")
+	})
+}
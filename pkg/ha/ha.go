@@ -0,0 +1,541 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package ha fences the DIDComm processing to the active node of an active/standby deployment. The active node holds
+// the fencing token, a lease stored in the storage shared by the nodes : a node taking the token increments it, and
+// the node it was taken from is fenced (demoted to standby) as soon as it sees the new token, so that a single node
+// delivers the messages. The lease is read and written while its lock is held, so that the token is taken and renewed
+// conditionally on the lease stored; a node that can't renew its lease within the lease timeout fences itself.
+//
+// The nodes don't compare their clocks : the lease of another node expires once it wasn't seen renewed (its renewal
+// count incremented) for the lease timeout, measured with the monotonic clock of the node since it last saw it change.
+package ha
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+// Modes the nodes are started in.
+const (
+	// ModeActive nodes take the fencing token when they start, unless another node holds a lease not expired : they
+	// wait for that lease to expire.
+	ModeActive = "active"
+	// ModeStandby nodes wait to be promoted.
+	ModeStandby = "standby"
+)
+
+// Roles of the nodes.
+const (
+	RoleActive  = "active"
+	RoleStandby = "standby"
+)
+
+const (
+	// DefaultRenewInterval is the interval the active node renews its lease at, and the standby nodes read it at.
+	DefaultRenewInterval = 10 * time.Second
+	// leaseTimeoutRenewals is the number of renewals missed by the active node after which its lease expires, if the
+	// lease timeout isn't configured.
+	leaseTimeoutRenewals = 3
+
+	storeName = "ha"
+	leaseKey  = "lease"
+	lockName  = "ha-lease"
+)
+
+// ErrStandby is returned for the DIDComm messages received by a standby node.
+var ErrStandby = errors.New("standby node")
+
+// errLeaseHeld is returned when the token isn't taken, another node holding a lease not expired.
+var errLeaseHeld = errors.New("lease held")
+
+var logger = log.New("hub-router/ha")
+
+// Config of the node.
+type Config struct {
+	// Mode is active or standby.
+	Mode string
+	// NodeID identifies the node in the lease.
+	NodeID string
+	// RenewInterval is the interval the lease is renewed or read at, DefaultRenewInterval if zero.
+	RenewInterval time.Duration
+	// AutoPromote promotes the standby node when the lease of the active node expires.
+	AutoPromote bool
+	// LeaseTimeout is the time after which the lease not renewed expires, three renew intervals if zero.
+	LeaseTimeout time.Duration
+}
+
+// Enabled returns true if the node is part of an active/standby deployment.
+func (c *Config) Enabled() bool {
+	return c != nil && c.Mode != ""
+}
+
+// Lease is the fencing token, held by the active node.
+type Lease struct {
+	NodeID string `json:"nodeID"`
+	// Token is incremented each time the lease is taken.
+	Token uint64 `json:"token"`
+	// Renewals is incremented each time the lease is renewed.
+	Renewals uint64    `json:"renewals"`
+	Acquired time.Time `json:"acquired"`
+	Renewed  time.Time `json:"renewed"`
+}
+
+// Status of the node.
+type Status struct {
+	NodeID string `json:"nodeID"`
+	Role   string `json:"role"`
+	// Token is the fencing token held by the node, zero if standby.
+	Token uint64 `json:"token,omitempty"`
+	// Leader is the lease last read, nil if never taken.
+	Leader *Lease `json:"leader,omitempty"`
+	// Previous is the lease the active node took the token from, nil if not taken from another node.
+	Previous *Lease `json:"previous,omitempty"`
+}
+
+// Node is a node of an active/standby deployment.
+type Node struct {
+	config   Config
+	store    storage.Store
+	locker   lock.Locker
+	onChange func(status *Status, previous string)
+	mutex    sync.Mutex
+	token    uint64
+	// renewed is the time the node last took or renewed its lease.
+	renewed time.Time
+	// takeover is the time the lease of the node the token was taken from expires : the node taking the token admits
+	// the messages from then on, once the other node fenced itself.
+	takeover time.Time
+	previous *Lease
+	leader   *Lease
+	// seen is the lease last seen taken or renewed, at seenAt.
+	seen     *Lease
+	seenAt   time.Time
+	now      func() time.Time
+	after    func(time.Duration) <-chan time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns a new Node, holding the fencing token if started in active mode and no other node holds a lease not
+// expired. The locker serializes the writes of the lease by the nodes sharing the storage. The onChange function is
+// called when the role of the node changes.
+func New(p storage.Provider, locker lock.Locker, config *Config,
+	onChange func(status *Status, previous string)) (*Node, error) {
+	if config.Mode != ModeActive && config.Mode != ModeStandby {
+		return nil, fmt.Errorf("invalid mode %q", config.Mode)
+	}
+
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open ha store : %w", err)
+	}
+
+	if onChange == nil {
+		onChange = func(*Status, string) {}
+	}
+
+	n := &Node{
+		config: *config, store: store, locker: locker, onChange: onChange, now: time.Now, after: time.After,
+		stop: make(chan struct{}),
+	}
+
+	if n.config.RenewInterval <= 0 {
+		n.config.RenewInterval = DefaultRenewInterval
+	}
+
+	if n.config.LeaseTimeout <= 0 {
+		n.config.LeaseTimeout = leaseTimeoutRenewals * n.config.RenewInterval
+	}
+
+	if config.Mode == ModeActive {
+		return n, n.acquire(false)
+	}
+
+	n.leader, err = n.read()
+	n.observe(n.leader)
+
+	return n, err
+}
+
+// Active returns true if the node holds the fencing token, and its lease didn't expire.
+func (n *Node) Active() bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.holding()
+}
+
+// Admit returns an error wrapping ErrStandby if the node doesn't hold the fencing token, or if its lease expired : the
+// standby nodes may have taken the token since.
+func (n *Node) Admit() error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.holding() {
+		return nil
+	}
+
+	if n.token > 0 && n.now().Before(n.takeover) {
+		return fmt.Errorf("%w : taking over from %s", ErrStandby, n.previous.NodeID)
+	}
+
+	if n.token > 0 {
+		return fmt.Errorf("%w : lease not renewed since %s", ErrStandby, n.renewed.Format(time.RFC3339))
+	}
+
+	if n.leader != nil {
+		return fmt.Errorf("%w : active node is %s", ErrStandby, n.leader.NodeID)
+	}
+
+	return ErrStandby
+}
+
+// Status returns the role of the node and the lease last read.
+func (n *Node) Status() *Status {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.status()
+}
+
+func (n *Node) status() *Status {
+	s := &Status{NodeID: n.config.NodeID, Role: RoleStandby}
+
+	if n.holding() {
+		s.Role = RoleActive
+		s.Token = n.token
+	}
+
+	if n.holding() && n.previous != nil {
+		previous := *n.previous
+		s.Previous = &previous
+	}
+
+	if n.leader != nil {
+		leader := *n.leader
+		s.Leader = &leader
+	}
+
+	return s
+}
+
+// Start renews the lease while the node is active, and reads it while standby, until Stop is called.
+func (n *Node) Start() {
+	go func() {
+		ticker := time.NewTicker(n.config.RenewInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := n.Renew(); err != nil {
+					logger.Warnf("failed to renew the lease : %s", err)
+				}
+			case <-n.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops renewing the lease.
+func (n *Node) Stop() {
+	n.stopOnce.Do(func() { close(n.stop) })
+}
+
+// Promote takes the fencing token, the node it was taken from is fenced when it next renews its lease. Promote returns
+// once the lease of that node expired, so that it doesn't deliver the messages anymore. Promoting the active node has
+// no effect.
+func (n *Node) Promote() (*Status, error) {
+	if n.Active() {
+		return n.Status(), nil
+	}
+
+	if err := n.acquire(true); err != nil {
+		return nil, err
+	}
+
+	return n.Status(), nil
+}
+
+// Renew renews the lease if the node holds the fencing token, or fences the node if the token was taken by another
+// node. The standby nodes read the lease, and are promoted if it expired and the auto promotion is enabled or the node
+// was started in active mode.
+func (n *Node) Renew() error {
+	fenced, expired, err := n.renew()
+	if fenced != nil {
+		n.onChange(fenced, RoleActive)
+	}
+
+	if err != nil || !expired {
+		return err
+	}
+
+	logger.Warnf("lease expired : promoting node %s", n.config.NodeID)
+
+	return n.acquire(false)
+}
+
+// renew returns the status of the node if it was fenced, and true if the standby node must be promoted. The active
+// node failing to renew its lease is fenced once the lease timeout elapsed.
+func (n *Node) renew() (*Status, bool, error) {
+	unlock, err := n.locker.Lock(lockName)
+	if err != nil {
+		return n.lapse(), false, fmt.Errorf("lock lease : %w", err)
+	}
+
+	defer unlock()
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	lease, err := n.read()
+	if err != nil {
+		return n.fenceExpired(), false, err
+	}
+
+	n.leader = lease
+	n.observe(lease)
+
+	if n.token == 0 {
+		return nil, (n.config.AutoPromote || n.config.Mode == ModeActive) && n.expired(lease), nil
+	}
+
+	if lease == nil || lease.Token != n.token || lease.NodeID != n.config.NodeID {
+		logger.Warnf("node %s fenced : token %d was taken", n.config.NodeID, n.token)
+
+		return n.fence(), false, nil
+	}
+
+	now := n.now()
+	lease.Renewals++
+	lease.Renewed = now.UTC()
+
+	if err = n.write(lease); err != nil {
+		return n.fenceExpired(), false, err
+	}
+
+	n.renewed = now
+	n.observe(lease)
+
+	return nil, false, nil
+}
+
+// lapse fences the node if its lease expired, it returns its status if fenced.
+func (n *Node) lapse() *Status {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	return n.fenceExpired()
+}
+
+// fenceExpired fences the active node whose lease expired, it returns its status if fenced.
+func (n *Node) fenceExpired() *Status {
+	if n.token == 0 || !n.lapsed() {
+		return nil
+	}
+
+	logger.Warnf("node %s fenced : lease not renewed since %s", n.config.NodeID, n.renewed.Format(time.RFC3339))
+
+	return n.fence()
+}
+
+func (n *Node) fence() *Status {
+	n.token = 0
+
+	return n.status()
+}
+
+// holding returns true if the node holds the fencing token, renewed its lease within the lease timeout, and the lease
+// of the node it took the token from expired.
+func (n *Node) holding() bool {
+	return n.token > 0 && !n.lapsed() && !n.now().Before(n.takeover)
+}
+
+// lapsed returns true if the node didn't renew its lease within the lease timeout.
+func (n *Node) lapsed() bool {
+	return n.now().Sub(n.renewed) > n.config.LeaseTimeout
+}
+
+// observe records the time the node sees the lease taken or renewed, the lease expiring once not seen renewed for the
+// lease timeout. The renewed time of the lease is written with the clock of the node holding it, so it isn't compared.
+func (n *Node) observe(lease *Lease) {
+	if lease == nil {
+		n.seen = nil
+
+		return
+	}
+
+	if n.seen == nil || n.seen.Token != lease.Token || n.seen.Renewals != lease.Renewals {
+		seen := *lease
+		n.seen, n.seenAt = &seen, n.now()
+	}
+}
+
+// expired returns true if the lease observed wasn't seen renewed within the lease timeout, or was never taken. A lease
+// seen for the first time expires after the lease timeout, as it may have been renewed just before.
+func (n *Node) expired(lease *Lease) bool {
+	return lease == nil || n.now().Sub(n.seenAt) > n.config.LeaseTimeout
+}
+
+// acquire takes the fencing token : it is incremented, so that the node it was taken from is fenced. Unless forced,
+// the token isn't taken from another node holding a lease not expired : the node stays standby.
+func (n *Node) acquire(force bool) error {
+	previous, err := n.take(force)
+	if errors.Is(err, errLeaseHeld) {
+		logger.Infof("node %s standby : %s", n.config.NodeID, err)
+
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err = n.awaitTakeover(); err != nil {
+		return err
+	}
+
+	n.onChange(n.Status(), previous)
+
+	return nil
+}
+
+// awaitTakeover waits for the lease of the node the token was taken from to expire : that node fenced itself by then.
+func (n *Node) awaitTakeover() error {
+	n.mutex.Lock()
+	wait := n.takeover.Sub(n.now())
+	n.mutex.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	logger.Infof("node %s waiting %s for the lease of the active node to expire", n.config.NodeID, wait)
+
+	select {
+	case <-n.after(wait):
+	case <-n.stop:
+		return errors.New("node stopped")
+	}
+
+	// the lease is renewed, unless the token was taken by another node in the meantime
+	fenced, _, err := n.renew()
+	if err != nil {
+		return err
+	}
+
+	if fenced != nil {
+		return errors.New("fencing token taken by another node")
+	}
+
+	return nil
+}
+
+// take writes the lease with the incremented token, it returns the previous role of the node, or an error wrapping
+// errLeaseHeld if not forced and another node holds a lease not expired. The lease is read and written while its lock
+// is held, so that two nodes never take the same token.
+func (n *Node) take(force bool) (string, error) {
+	unlock, err := n.locker.Lock(lockName)
+	if err != nil {
+		return "", fmt.Errorf("lock lease : %w", err)
+	}
+
+	defer unlock()
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	current, err := n.read()
+	if err != nil {
+		return "", err
+	}
+
+	n.leader = current
+	n.observe(current)
+
+	held := current != nil && current.NodeID != n.config.NodeID && !n.expired(current)
+	if held && !force {
+		return "", fmt.Errorf("%w : waiting for the lease of node %s to expire", errLeaseHeld, current.NodeID)
+	}
+
+	now := n.now()
+	lease := &Lease{NodeID: n.config.NodeID, Token: 1, Acquired: now.UTC(), Renewed: now.UTC()}
+
+	if current != nil {
+		lease.Token = current.Token + 1
+	}
+
+	if err = n.write(lease); err != nil {
+		return "", err
+	}
+
+	n.previous, n.takeover = nil, time.Time{}
+
+	if current != nil && current.NodeID != n.config.NodeID {
+		n.previous = current
+
+		if held {
+			n.takeover = n.seenAt.Add(n.config.LeaseTimeout)
+		}
+	}
+
+	previous := RoleStandby
+	if n.token > 0 {
+		previous = RoleActive
+	}
+
+	n.leader = lease
+	n.token = lease.Token
+	n.renewed = now
+	n.observe(lease)
+
+	logger.Infof("node %s active : fencing token %d", n.config.NodeID, n.token)
+
+	return previous, nil
+}
+
+func (n *Node) read() (*Lease, error) {
+	leaseBytes, err := n.store.Get(leaseKey)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get lease : %w", err)
+	}
+
+	lease := &Lease{}
+
+	err = json.Unmarshal(leaseBytes, lease)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal lease : %w", err)
+	}
+
+	return lease, nil
+}
+
+func (n *Node) write(lease *Lease) error {
+	leaseBytes, err := json.Marshal(lease)
+	if err != nil {
+		return fmt.Errorf("marshal lease : %w", err)
+	}
+
+	if err = n.store.Put(leaseKey, leaseBytes); err != nil {
+		return fmt.Errorf("save lease : %w", err)
+	}
+
+	return nil
+}
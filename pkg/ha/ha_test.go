@@ -0,0 +1,483 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ha
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+type change struct {
+	status   *Status
+	previous string
+}
+
+type failingLocker struct {
+	err error
+}
+
+func (l *failingLocker) Lock(string) (func(), error) {
+	return nil, l.err
+}
+
+// skipTakeover advances the clock of the node when it waits for the lease it took over to expire.
+func skipTakeover(n *Node) {
+	var skipped int64
+
+	n.now = func() time.Time {
+		return time.Now().Add(time.Duration(atomic.LoadInt64(&skipped)))
+	}
+
+	n.after = func(d time.Duration) <-chan time.Time {
+		atomic.AddInt64(&skipped, int64(d))
+
+		return time.After(0)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	require.False(t, (*Config)(nil).Enabled())
+	require.False(t, (&Config{}).Enabled())
+	require.True(t, (&Config{Mode: ModeStandby}).Enabled())
+}
+
+func TestNode(t *testing.T) {
+	locker := lock.NewLocal()
+
+	t.Run("active node fenced when the token is taken", func(t *testing.T) {
+		p := mem.NewProvider()
+		changes := make(chan change, 10)
+
+		node1, err := New(p, locker, &Config{Mode: ModeActive, NodeID: "node-1"}, func(s *Status, previous string) {
+			changes <- change{status: s, previous: previous}
+		})
+		require.NoError(t, err)
+		require.True(t, node1.Active())
+		require.NoError(t, node1.Admit())
+
+		c := <-changes
+		require.Equal(t, RoleActive, c.status.Role)
+		require.Equal(t, RoleStandby, c.previous)
+		require.Equal(t, uint64(1), c.status.Token)
+
+		status := node1.Status()
+		require.Equal(t, "node-1", status.NodeID)
+		require.Equal(t, "node-1", status.Leader.NodeID)
+
+		renewed := time.Now().Add(time.Minute)
+		node1.now = func() time.Time { return renewed }
+
+		require.NoError(t, node1.Renew())
+		require.True(t, node1.Active())
+		require.Equal(t, renewed.UTC(), node1.Status().Leader.Renewed)
+
+		node2, err := New(p, locker, &Config{Mode: ModeStandby, NodeID: "node-2"}, nil)
+		require.NoError(t, err)
+		skipTakeover(node2)
+
+		_, err = node2.Promote()
+		require.NoError(t, err)
+		require.True(t, node2.Active())
+		require.Equal(t, uint64(2), node2.Status().Token)
+
+		require.NoError(t, node1.Renew())
+		require.False(t, node1.Active())
+
+		c = <-changes
+		require.Equal(t, RoleStandby, c.status.Role)
+		require.Equal(t, RoleActive, c.previous)
+
+		err = node1.Admit()
+		require.True(t, errors.Is(err, ErrStandby))
+		require.Contains(t, err.Error(), "active node is node-2")
+	})
+
+	t.Run("standby node", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		node, err := New(p, locker, &Config{Mode: ModeStandby, NodeID: "node-2"}, nil)
+		require.NoError(t, err)
+		require.False(t, node.Active())
+		require.EqualError(t, node.Admit(), ErrStandby.Error())
+		require.Nil(t, node.Status().Leader)
+		require.Equal(t, RoleStandby, node.Status().Role)
+
+		_, err = New(p, locker, &Config{Mode: ModeActive, NodeID: "node-1"}, nil)
+		require.NoError(t, err)
+
+		node.Start()
+		defer node.Stop()
+
+		require.NoError(t, node.Renew())
+		require.False(t, node.Active())
+		require.Equal(t, "node-1", node.Status().Leader.NodeID)
+	})
+
+	t.Run("standby node promoted", func(t *testing.T) {
+		p := mem.NewProvider()
+		changes := make(chan change, 10)
+
+		node1, err := New(p, locker, &Config{Mode: ModeActive, NodeID: "node-1"}, nil)
+		require.NoError(t, err)
+
+		node2, err := New(p, locker, &Config{Mode: ModeStandby, NodeID: "node-2"}, func(s *Status, previous string) {
+			changes <- change{status: s, previous: previous}
+		})
+		require.NoError(t, err)
+		skipTakeover(node2)
+
+		status, err := node2.Promote()
+		require.NoError(t, err)
+		require.Equal(t, RoleActive, status.Role)
+		require.Equal(t, uint64(2), status.Token)
+		require.Equal(t, "node-1", status.Previous.NodeID)
+		require.Equal(t, uint64(1), status.Previous.Token)
+
+		c := <-changes
+		require.Equal(t, RoleActive, c.status.Role)
+		require.Equal(t, RoleStandby, c.previous)
+
+		status, err = node2.Promote()
+		require.NoError(t, err)
+		require.Equal(t, uint64(2), status.Token)
+		require.Empty(t, changes)
+
+		require.NoError(t, node1.Renew())
+		require.False(t, node1.Active())
+	})
+
+	t.Run("standby node promoted when the lease expires", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		_, err := New(p, locker, &Config{Mode: ModeActive, NodeID: "node-1"}, nil)
+		require.NoError(t, err)
+
+		node2, err := New(p, locker, &Config{Mode: ModeStandby, NodeID: "node-2", AutoPromote: true}, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, node2.Renew())
+		require.False(t, node2.Active())
+
+		expired := time.Now().Add(3*DefaultRenewInterval + time.Second)
+		node2.now = func() time.Time { return expired }
+
+		require.NoError(t, node2.Renew())
+		require.True(t, node2.Active())
+		require.Equal(t, uint64(2), node2.Status().Token)
+
+		node3, err := New(p, locker, &Config{Mode: ModeStandby, NodeID: "node-3"}, nil)
+		require.NoError(t, err)
+
+		node3.now = func() time.Time { return expired }
+
+		require.NoError(t, node3.Renew())
+		require.False(t, node3.Active())
+	})
+
+	t.Run("node fenced when another node wrote the same token", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		node1, err := New(p, locker, &Config{Mode: ModeActive, NodeID: "node-1"}, nil)
+		require.NoError(t, err)
+
+		store, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+		require.NoError(t, store.Put(leaseKey, []byte(`{"nodeID":"node-2","token":1}`)))
+
+		require.NoError(t, node1.Renew())
+		require.False(t, node1.Active())
+	})
+
+	t.Run("active node waits for the lease renewed in the background to expire", func(t *testing.T) {
+		p := mem.NewProvider()
+		activated := make(chan struct{})
+
+		node1, err := New(p, locker, &Config{Mode: ModeActive, NodeID: "node-1", RenewInterval: 10 * time.Millisecond},
+			nil)
+		require.NoError(t, err)
+
+		node1.Start()
+		defer node1.Stop()
+
+		node2, err := New(p, locker, &Config{Mode: ModeActive, NodeID: "node-2", RenewInterval: 10 * time.Millisecond},
+			func(s *Status, _ string) {
+				if s.Role == RoleActive {
+					close(activated)
+				}
+			})
+		require.NoError(t, err)
+		require.False(t, node2.Active())
+		require.Equal(t, "node-1", node2.Status().Leader.NodeID)
+
+		node2.Start()
+		defer node2.Stop()
+
+		select {
+		case <-activated:
+			require.Fail(t, "lease taken while renewed")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		require.True(t, node1.Active())
+
+		node1.Stop()
+
+		select {
+		case <-activated:
+		case <-time.After(time.Second):
+			require.Fail(t, "lease not taken once expired")
+		}
+
+		require.NoError(t, node1.Renew())
+		require.False(t, node1.Active())
+	})
+
+	t.Run("active node restarted takes its own lease", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		_, err := New(p, locker, &Config{Mode: ModeActive, NodeID: "node-1"}, nil)
+		require.NoError(t, err)
+
+		node, err := New(p, locker, &Config{Mode: ModeActive, NodeID: "node-1"}, nil)
+		require.NoError(t, err)
+		require.True(t, node.Active())
+		require.Equal(t, uint64(2), node.Status().Token)
+	})
+
+	t.Run("lease expiry ignores the clock of the node holding it", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		store, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+
+		// node-1 renews its lease with a clock behind
+		require.NoError(t, store.Put(leaseKey, []byte(`{"nodeID":"node-1","token":1,"renewed":"2000-01-01T00:00:00Z"}`)))
+
+		node2, err := New(p, locker, &Config{Mode: ModeStandby, NodeID: "node-2", AutoPromote: true}, nil)
+		require.NoError(t, err)
+
+		node3, err := New(p, locker, &Config{Mode: ModeActive, NodeID: "node-3"}, nil)
+		require.NoError(t, err)
+		require.False(t, node3.Active())
+
+		later := time.Now().Add(2 * DefaultRenewInterval)
+		node2.now = func() time.Time { return later }
+
+		require.NoError(t, store.Put(leaseKey,
+			[]byte(`{"nodeID":"node-1","token":1,"renewals":1,"renewed":"2000-01-01T00:00:00Z"}`)))
+		require.NoError(t, node2.Renew())
+		require.False(t, node2.Active())
+
+		// the lease isn't renewed anymore, node-2 promotes itself once it didn't see it renewed for the lease timeout
+		node2.now = func() time.Time { return later.Add(3 * DefaultRenewInterval) }
+
+		require.NoError(t, node2.Renew())
+		require.False(t, node2.Active())
+
+		node2.now = func() time.Time { return later.Add(3*DefaultRenewInterval + time.Second) }
+
+		require.NoError(t, node2.Renew())
+		require.True(t, node2.Active())
+		require.Equal(t, uint64(2), node2.Status().Token)
+	})
+
+	t.Run("active node fenced when the lease can't be renewed within the lease timeout", func(t *testing.T) {
+		s := &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry)}
+		changes := make(chan change, 10)
+
+		node, err := New(mockstore.NewCustomMockStoreProvider(s), locker, &Config{Mode: ModeActive, NodeID: "node-1"},
+			func(s *Status, previous string) {
+				changes <- change{status: s, previous: previous}
+			})
+		require.NoError(t, err)
+		<-changes
+
+		s.ErrPut = errors.New("put error")
+
+		require.EqualError(t, node.Renew(), "save lease : put error")
+		require.True(t, node.Active())
+		require.Empty(t, changes)
+
+		s.ErrGet = errors.New("get error")
+		expired := time.Now().Add(3*DefaultRenewInterval + time.Second)
+		node.now = func() time.Time { return expired }
+
+		// the lease expired before the node sees it was taken : no message is admitted in between
+		err = node.Admit()
+		require.True(t, errors.Is(err, ErrStandby))
+		require.Contains(t, err.Error(), "lease not renewed since")
+		require.False(t, node.Active())
+		require.Equal(t, RoleStandby, node.Status().Role)
+
+		require.EqualError(t, node.Renew(), "get lease : get error")
+
+		c := <-changes
+		require.Equal(t, RoleStandby, c.status.Role)
+		require.Equal(t, RoleActive, c.previous)
+		require.True(t, errors.Is(node.Admit(), ErrStandby))
+	})
+
+	t.Run("active node fenced when the lease can't be locked within the lease timeout", func(t *testing.T) {
+		failing := &failingLocker{}
+
+		node, err := New(mem.NewProvider(), locker, &Config{Mode: ModeActive, NodeID: "node-1"}, nil)
+		require.NoError(t, err)
+
+		node.locker = failing
+		failing.err = errors.New("lock timeout")
+
+		require.EqualError(t, node.Renew(), "lock lease : lock timeout")
+		require.True(t, node.Active())
+
+		expired := time.Now().Add(3*DefaultRenewInterval + time.Second)
+		node.now = func() time.Time { return expired }
+
+		require.Error(t, node.Renew())
+		require.Zero(t, node.Status().Token)
+
+		_, err = node.Promote()
+		require.EqualError(t, err, "lock lease : lock timeout")
+	})
+
+	t.Run("fencing token taken once by concurrent promotions", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		const nodes = 10
+
+		tokens := make(chan uint64, nodes)
+
+		for i := 0; i < nodes; i++ {
+			node, err := New(p, locker, &Config{Mode: ModeStandby, NodeID: fmt.Sprintf("node-%d", i)}, nil)
+			require.NoError(t, err)
+
+			go func() {
+				if _, takeErr := node.take(true); takeErr != nil {
+					tokens <- 0
+
+					return
+				}
+
+				node.mutex.Lock()
+				defer node.mutex.Unlock()
+
+				tokens <- node.token
+			}()
+		}
+
+		taken := map[uint64]bool{}
+
+		for i := 0; i < nodes; i++ {
+			token := <-tokens
+			require.NotZero(t, token)
+			require.False(t, taken[token], "token %d taken twice", token)
+
+			taken[token] = true
+		}
+
+		require.Len(t, taken, nodes)
+	})
+
+	t.Run("messages refused until the lease taken over expires", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		node1, err := New(p, locker, &Config{Mode: ModeActive, NodeID: "node-1"}, nil)
+		require.NoError(t, err)
+
+		node2, err := New(p, locker, &Config{Mode: ModeStandby, NodeID: "node-2"}, nil)
+		require.NoError(t, err)
+
+		_, err = node2.take(true)
+		require.NoError(t, err)
+
+		// both nodes hold a token until node-1 sees its token was taken, or its lease expires
+		require.NoError(t, node1.Admit())
+
+		err = node2.Admit()
+		require.True(t, errors.Is(err, ErrStandby))
+		require.Contains(t, err.Error(), "taking over from node-1")
+		require.Equal(t, RoleStandby, node2.Status().Role)
+
+		skipTakeover(node2)
+		require.NoError(t, node2.awaitTakeover())
+		require.NoError(t, node2.Admit())
+
+		expired := time.Now().Add(3*DefaultRenewInterval + time.Second)
+		node1.now = func() time.Time { return expired }
+		require.True(t, errors.Is(node1.Admit(), ErrStandby))
+
+		node3, err := New(p, locker, &Config{Mode: ModeStandby, NodeID: "node-3"}, nil)
+		require.NoError(t, err)
+
+		_, err = node3.take(true)
+		require.NoError(t, err)
+
+		node3.after = func(time.Duration) <-chan time.Time { return make(chan time.Time) }
+		node3.Stop()
+		require.EqualError(t, node3.awaitTakeover(), "node stopped")
+	})
+
+	t.Run("token taken while taking over", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		_, err := New(p, locker, &Config{Mode: ModeActive, NodeID: "node-1"}, nil)
+		require.NoError(t, err)
+
+		node2, err := New(p, locker, &Config{Mode: ModeStandby, NodeID: "node-2"}, nil)
+		require.NoError(t, err)
+
+		node3, err := New(p, locker, &Config{Mode: ModeStandby, NodeID: "node-3"}, nil)
+		require.NoError(t, err)
+
+		node2.after = func(time.Duration) <-chan time.Time {
+			_, takeErr := node3.take(true)
+			require.NoError(t, takeErr)
+
+			return time.After(0)
+		}
+
+		_, err = node2.Promote()
+		require.EqualError(t, err, "fencing token taken by another node")
+		require.False(t, node2.Active())
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		_, err := New(mem.NewProvider(), locker, &Config{Mode: "primary"}, nil)
+		require.EqualError(t, err, `invalid mode "primary"`)
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		_, err := New(&mockstorage.MockProvider{OpenStoreErr: errors.New("open error")}, locker,
+			&Config{Mode: ModeActive}, nil)
+		require.EqualError(t, err, "open ha store : open error")
+
+		s := &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry), ErrGet: errors.New("get error")}
+
+		_, err = New(mockstore.NewCustomMockStoreProvider(s), locker, &Config{Mode: ModeActive}, nil)
+		require.EqualError(t, err, "get lease : get error")
+
+		s = &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry), ErrPut: errors.New("put error")}
+
+		_, err = New(mockstore.NewCustomMockStoreProvider(s), locker, &Config{Mode: ModeActive}, nil)
+		require.EqualError(t, err, "save lease : put error")
+
+		s = &mockstore.MockStore{Store: map[string]mockstore.DBEntry{leaseKey: {Value: []byte("{")}}}
+
+		_, err = New(mockstore.NewCustomMockStoreProvider(s), locker, &Config{Mode: ModeStandby}, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal lease")
+	})
+}
@@ -0,0 +1,127 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package history keeps the changes of each connection and mediation record (who changed it, what and when), bounded
+// to the latest changes, so that questions like "who revoked this wallet?" are answered without querying the whole
+// audit trail.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+const storeName = "history"
+
+// DefaultLimit is the default number of changes kept per record.
+const DefaultLimit = 100
+
+// Actors of the changes; the changes requested with a tenant API key are attributed to the tenant (see TenantActor).
+const (
+	// ActorOperator is the operator, with the operator API key or without API keys configured.
+	ActorOperator = "operator"
+	// ActorWallet is the wallet of the connection, with a DIDComm message.
+	ActorWallet = "wallet"
+	// ActorRouter is the router itself, eg: auto-accepting a mediation request.
+	ActorRouter = "router"
+
+	tenantActorPrefix = "tenant:"
+)
+
+// Change of a record.
+type Change struct {
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Type   string    `json:"type"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Log keeps the latest changes of each record.
+type Log struct {
+	store storage.Store
+	limit int
+	mutex sync.Mutex
+}
+
+// TenantActor returns the actor of the changes requested with the API key of the tenant.
+func TenantActor(tenantID string) string {
+	return tenantActorPrefix + tenantID
+}
+
+// New returns a new history Log keeping the given number of changes per record, DefaultLimit if not positive.
+func New(p storage.Provider, limit int) (*Log, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open history store : %w", err)
+	}
+
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	return &Log{store: store, limit: limit}, nil
+}
+
+// Record appends the change to the history of the record, dropping its oldest changes over the limit. The time of the
+// change is set if it is empty, and the actor defaults to the router.
+func (l *Log) Record(recordID string, c *Change) error {
+	if c.Time.IsZero() {
+		c.Time = time.Now().UTC()
+	}
+
+	if c.Actor == "" {
+		c.Actor = ActorRouter
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	changes, err := l.Get(recordID)
+	if err != nil {
+		return err
+	}
+
+	changes = append(changes, c)
+	if len(changes) > l.limit {
+		changes = changes[len(changes)-l.limit:]
+	}
+
+	changesBytes, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("marshal history : %w", err)
+	}
+
+	if err = l.store.Put(recordID, changesBytes); err != nil {
+		return fmt.Errorf("save history : %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the changes of the record, oldest first; empty if the record has no history.
+func (l *Log) Get(recordID string) ([]*Change, error) {
+	changesBytes, err := l.store.Get(recordID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return []*Change{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get history : %w", err)
+	}
+
+	changes := []*Change{}
+
+	if err = json.Unmarshal(changesBytes, &changes); err != nil {
+		return nil, fmt.Errorf("unmarshal history : %w", err)
+	}
+
+	return changes, nil
+}
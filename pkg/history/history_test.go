@@ -0,0 +1,118 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package history
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("default limit", func(t *testing.T) {
+		l, err := New(mem.NewProvider(), 0)
+		require.NoError(t, err)
+		require.Equal(t, DefaultLimit, l.limit)
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, 10)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open history store")
+	})
+}
+
+func TestTenantActor(t *testing.T) {
+	require.Equal(t, "tenant:acme", TenantActor("acme"))
+}
+
+func TestLog(t *testing.T) {
+	t.Run("record and get", func(t *testing.T) {
+		l, err := New(mem.NewProvider(), 10)
+		require.NoError(t, err)
+
+		changes, err := l.Get("conn-1")
+		require.NoError(t, err)
+		require.Empty(t, changes)
+
+		created := time.Now().Add(-time.Hour).UTC()
+
+		require.NoError(t, l.Record("conn-1", &Change{Time: created, Type: "connection-created"}))
+		require.NoError(t, l.Record("conn-1", &Change{Actor: ActorOperator, Type: "wallet-pinned", Detail: "region=eu"}))
+		require.NoError(t, l.Record("conn-2", &Change{Actor: ActorWallet, Type: "terms-accepted"}))
+
+		changes, err = l.Get("conn-1")
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		require.True(t, created.Equal(changes[0].Time))
+		require.Equal(t, ActorRouter, changes[0].Actor)
+		require.Equal(t, ActorOperator, changes[1].Actor)
+		require.Equal(t, "region=eu", changes[1].Detail)
+		require.False(t, changes[1].Time.IsZero())
+
+		changes, err = l.Get("conn-2")
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		require.Equal(t, ActorWallet, changes[0].Actor)
+	})
+
+	t.Run("bounded", func(t *testing.T) {
+		l, err := New(mem.NewProvider(), 3)
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, l.Record("conn-1", &Change{Type: "change", Detail: fmt.Sprintf("change=%d", i)}))
+		}
+
+		changes, err := l.Get("conn-1")
+		require.NoError(t, err)
+		require.Len(t, changes, 3)
+		require.Equal(t, "change=2", changes[0].Detail)
+		require.Equal(t, "change=4", changes[2].Detail)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		l, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+		}), 10)
+		require.NoError(t, err)
+
+		err = l.Record("conn-1", &Change{Type: "change"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save history")
+
+		l, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), 10)
+		require.NoError(t, err)
+
+		err = l.Record("conn-1", &Change{Type: "change"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get history")
+
+		l, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store: map[string]mockstore.DBEntry{"conn-1": {Value: []byte("invalid")}},
+		}), 10)
+		require.NoError(t, err)
+
+		_, err = l.Get("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal history")
+	})
+}
@@ -0,0 +1,236 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package idempotency records the results of the admin requests carrying an idempotency key, so that the requests
+// retried with the same key, eg: by an automation after a timeout, get the recorded result instead of being executed
+// twice.
+package idempotency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "idempotency"
+	resultTag = "result"
+
+	// DefaultTTL is the time the results are kept for if not set.
+	DefaultTTL = 24 * time.Hour
+)
+
+var (
+	// ErrInProgress is returned for a key whose request is still being executed.
+	ErrInProgress = errors.New("request with the idempotency key in progress")
+	// ErrMismatch is returned for a key reused with a different request.
+	ErrMismatch = errors.New("idempotency key reused with a different request")
+)
+
+var logger = log.New("hub-router/idempotency")
+
+// Result of a request.
+type Result struct {
+	// Fingerprint identifies the request : its method, URL and body.
+	Fingerprint string    `json:"fingerprint"`
+	Created     time.Time `json:"created"`
+	// Completed is false while the request is being executed.
+	Completed   bool   `json:"completed"`
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+// Store records the results of the requests by idempotency key, in the given (transient) storage : the storage is
+// shared by the nodes, so that a request retried on another node is not executed again.
+type Store struct {
+	store    storage.Store
+	ttl      time.Duration
+	now      func() time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns a new Store keeping the results for the ttl, DefaultTTL if zero.
+func New(p storage.Provider, ttl time.Duration) (*Store, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open idempotency store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{resultTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set idempotency store config : %w", err)
+	}
+
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &Store{store: store, ttl: ttl, now: time.Now, stop: make(chan struct{})}, nil
+}
+
+// Begin returns the result recorded for the key, or records the request with the fingerprint in progress and returns
+// nil : the caller executes the request, then completes or abandons it. ErrInProgress and ErrMismatch are returned
+// for the requests with the key in progress, or with a different fingerprint.
+func (s *Store) Begin(key, fingerprint string) (*Result, error) {
+	r, err := s.get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if r != nil {
+		switch {
+		case r.Fingerprint != fingerprint:
+			return nil, ErrMismatch
+		case !r.Completed:
+			return nil, ErrInProgress
+		default:
+			return r, nil
+		}
+	}
+
+	return nil, s.put(key, &Result{Fingerprint: fingerprint, Created: s.now().UTC()})
+}
+
+// Complete records the result of the request with the key.
+func (s *Store) Complete(key string, r *Result) error {
+	r.Completed = true
+
+	if r.Created.IsZero() {
+		r.Created = s.now().UTC()
+	}
+
+	return s.put(key, r)
+}
+
+// Abandon deletes the request with the key in progress, eg: failed with a server error, so that it is executed again
+// when retried.
+func (s *Store) Abandon(key string) error {
+	if err := s.store.Delete(key); err != nil {
+		return fmt.Errorf("delete idempotency result : %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) get(key string) (*Result, error) {
+	resultBytes, err := s.store.Get(key)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get idempotency result : %w", err)
+	}
+
+	r := &Result{}
+
+	err = json.Unmarshal(resultBytes, r)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal idempotency result : %w", err)
+	}
+
+	if s.now().Sub(r.Created) >= s.ttl {
+		return nil, nil
+	}
+
+	return r, nil
+}
+
+func (s *Store) put(key string, r *Result) error {
+	resultBytes, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal idempotency result : %w", err)
+	}
+
+	err = s.store.Put(key, resultBytes, storage.Tag{Name: resultTag})
+	if err != nil {
+		return fmt.Errorf("save idempotency result : %w", err)
+	}
+
+	return nil
+}
+
+// Sweep deletes the results recorded before the ttl.
+func (s *Store) Sweep(now time.Time) error {
+	iter, err := s.store.Query(resultTag)
+	if err != nil {
+		return fmt.Errorf("query idempotency results : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var expired []string
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("iterate idempotency results : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		k, err := iter.Key()
+		if err != nil {
+			return fmt.Errorf("read idempotency result key : %w", err)
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return fmt.Errorf("read idempotency result : %w", err)
+		}
+
+		r := &Result{}
+
+		// the results that can't be read are dropped
+		if err = json.Unmarshal(val, r); err != nil || now.Sub(r.Created) >= s.ttl {
+			expired = append(expired, k)
+		}
+	}
+
+	for _, k := range expired {
+		if err = s.store.Delete(k); err != nil {
+			return fmt.Errorf("delete idempotency result : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Start sweeps the expired results periodically until Stop is called.
+func (s *Store) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := s.Sweep(now.UTC()); err != nil {
+					logger.Warnf("idempotency sweep : %s", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic sweep.
+func (s *Store) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
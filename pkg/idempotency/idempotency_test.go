@@ -0,0 +1,186 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package idempotency
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("default ttl", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), 0)
+		require.NoError(t, err)
+		require.Equal(t, DefaultTTL, s.ttl)
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, time.Minute)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open idempotency store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p, time.Minute)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set idempotency store config")
+	})
+}
+
+func TestStore(t *testing.T) {
+	t.Run("result replayed", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		s, err := New(p, time.Minute)
+		require.NoError(t, err)
+
+		r, err := s.Begin("key-1", "fp-1")
+		require.NoError(t, err)
+		require.Nil(t, r)
+
+		_, err = s.Begin("key-1", "fp-1")
+		require.True(t, errors.Is(err, ErrInProgress))
+
+		_, err = s.Begin("key-1", "fp-2")
+		require.True(t, errors.Is(err, ErrMismatch))
+
+		require.NoError(t, s.Complete("key-1", &Result{
+			Fingerprint: "fp-1", Status: http.StatusCreated, ContentType: "application/json", Body: []byte(`{}`),
+		}))
+
+		// another node sharing the storage
+		other, err := New(p, time.Minute)
+		require.NoError(t, err)
+
+		r, err = other.Begin("key-1", "fp-1")
+		require.NoError(t, err)
+		require.True(t, r.Completed)
+		require.Equal(t, http.StatusCreated, r.Status)
+		require.Equal(t, []byte(`{}`), r.Body)
+
+		_, err = other.Begin("key-1", "fp-2")
+		require.True(t, errors.Is(err, ErrMismatch))
+
+		later := time.Now().Add(time.Minute)
+		s.now = func() time.Time { return later }
+
+		r, err = s.Begin("key-1", "fp-2")
+		require.NoError(t, err)
+		require.Nil(t, r)
+	})
+
+	t.Run("abandoned", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), time.Minute)
+		require.NoError(t, err)
+
+		_, err = s.Begin("key-1", "fp-1")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Abandon("key-1"))
+
+		r, err := s.Begin("key-1", "fp-1")
+		require.NoError(t, err)
+		require.Nil(t, r)
+	})
+
+	t.Run("sweep", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		s, err := New(p, time.Minute)
+		require.NoError(t, err)
+
+		_, err = s.Begin("key-1", "fp-1")
+		require.NoError(t, err)
+		require.NoError(t, s.Complete("key-2", &Result{Fingerprint: "fp-2", Status: http.StatusOK}))
+
+		store, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+		require.NoError(t, store.Put("invalid", []byte("{"), storage.Tag{Name: resultTag}))
+
+		require.NoError(t, s.Sweep(time.Now()))
+
+		_, err = store.Get("invalid")
+		require.Error(t, err)
+
+		_, err = store.Get("key-2")
+		require.NoError(t, err)
+
+		require.NoError(t, s.Sweep(time.Now().Add(time.Minute)))
+
+		_, err = store.Get("key-1")
+		require.Error(t, err)
+
+		_, err = store.Get("key-2")
+		require.Error(t, err)
+	})
+
+	t.Run("sweep in the background", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), time.Nanosecond)
+		require.NoError(t, err)
+
+		_, err = s.Begin("key-1", "fp-1")
+		require.NoError(t, err)
+
+		s.Start(time.Millisecond)
+		defer s.Stop()
+
+		require.Eventually(t, func() bool {
+			_, err = s.store.Get("key-1")
+
+			return err != nil
+		}, time.Second, time.Millisecond)
+
+		s.Stop()
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		s, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:     make(map[string]mockstore.DBEntry),
+			ErrGet:    errors.New("get error"),
+			ErrPut:    errors.New("put error"),
+			ErrQuery:  errors.New("query error"),
+			ErrDelete: errors.New("delete error"),
+		}), time.Minute)
+		require.NoError(t, err)
+
+		_, err = s.Begin("key-1", "fp-1")
+		require.EqualError(t, err, "get idempotency result : get error")
+
+		err = s.Complete("key-1", &Result{})
+		require.EqualError(t, err, "save idempotency result : put error")
+
+		err = s.Abandon("key-1")
+		require.EqualError(t, err, "delete idempotency result : delete error")
+
+		err = s.Sweep(time.Now())
+		require.EqualError(t, err, "query idempotency results : query error")
+
+		s, err = New(mem.NewProvider(), time.Minute)
+		require.NoError(t, err)
+
+		require.NoError(t, s.store.Put("key-1", []byte("{")))
+
+		_, err = s.Begin("key-1", "fp-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal idempotency result")
+	})
+}
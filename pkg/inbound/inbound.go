@@ -0,0 +1,144 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package inbound chains the handlers of the unpacked inbound envelopes, before they are handed to the Aries
+// framework : each inbound transport is wrapped in a Middleware handing its envelopes through the stages of its chain,
+// in order. The chains are created with the transports, before the router components handling the stages : the stages
+// are bound to their handler once the components are created, and pass the envelopes on until then. The routing
+// header of the message is parsed once, at the head of the chain, and handed to the stages with the envelope.
+package inbound
+
+import (
+	"sync"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+
+	"github.com/trustbloc/hub-router/pkg/preparse"
+)
+
+// Stage names a step of the inbound chains.
+type Stage string
+
+const (
+	// Admit rejects the envelopes not admitted by the router, eg: those received by a standby node, before any other
+	// stage handles them.
+	Admit Stage = "admit"
+	// Verify rejects the envelopes sent with another key than the one pinned for the connection.
+	Verify Stage = "verify"
+	// ObserveSocket registers the wallets holding their WebSocket open to the router.
+	ObserveSocket Stage = "observe-socket"
+	// Relay relays the nested forward messages addressed to another mediator.
+	Relay Stage = "relay"
+	// Gate hands the envelopes on, or suppresses or rejects them, eg: the forwards addressed to a full queue.
+	Gate Stage = "gate"
+	// Limit caps the pickups handled concurrently.
+	Limit Stage = "limit"
+)
+
+// Handler handles an envelope in a stage : it returns an error to reject the envelope, nil without calling next to
+// consume it, or calls next to pass it on to the next stage. The header is the routing header of the message, empty
+// if the message isn't a JSON object : it mustn't be modified.
+type Handler func(envelope *transport.Envelope, header *preparse.Header, next transport.InboundMessageHandler) error
+
+// Check returns the handler rejecting the envelopes failing the check, and passing the others on.
+func Check(check func(envelope *transport.Envelope, header *preparse.Header) error) Handler {
+	return func(envelope *transport.Envelope, header *preparse.Header, next transport.InboundMessageHandler) error {
+		if err := check(envelope, header); err != nil {
+			return err
+		}
+
+		return next(envelope)
+	}
+}
+
+// Middleware wraps an inbound transport to hand its envelopes through the stages of its chain, before the Aries
+// framework.
+type Middleware struct {
+	transport.InboundTransport
+	stages   []Stage
+	mutex    sync.RWMutex
+	handlers map[Stage]Handler
+}
+
+// NewMiddleware returns a new Middleware wrapping the given transport, with the chain of the given stages in order.
+func NewMiddleware(it transport.InboundTransport, stages ...Stage) *Middleware {
+	return &Middleware{InboundTransport: it, stages: stages, handlers: map[Stage]Handler{}}
+}
+
+// Has returns true if the stage is in the chain of the transport.
+func (m *Middleware) Has(stage Stage) bool {
+	for _, s := range m.stages {
+		if s == stage {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Handle binds the stage to the handler; the transport is created before the handler. A stage not in the chain of the
+// transport is ignored.
+func (m *Middleware) Handle(stage Stage, handler Handler) {
+	if !m.Has(stage) {
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.handlers[stage] = handler
+}
+
+// Start starts the wrapped transport.
+func (m *Middleware) Start(prov transport.Provider) error {
+	return m.InboundTransport.Start(&provider{Provider: prov, middleware: m})
+}
+
+// chain returns the handler of the envelope handing it through the stages bound, with the header of its message, then
+// to the Aries handler.
+func (m *Middleware) chain(handler transport.InboundMessageHandler,
+	header *preparse.Header) transport.InboundMessageHandler {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for i := len(m.stages) - 1; i >= 0; i-- {
+		h, ok := m.handlers[m.stages[i]]
+		if !ok {
+			continue
+		}
+
+		next := handler
+
+		handler = func(envelope *transport.Envelope) error {
+			return h(envelope, header, next)
+		}
+	}
+
+	return handler
+}
+
+type provider struct {
+	transport.Provider
+	middleware *Middleware
+}
+
+func (p *provider) InboundMessageHandler() transport.InboundMessageHandler {
+	handler := p.Provider.InboundMessageHandler()
+
+	return func(envelope *transport.Envelope) error {
+		return p.middleware.chain(handler, parseHeader(envelope.Message))(envelope)
+	}
+}
+
+// parseHeader returns the routing header of the message, empty if the message isn't a JSON object.
+func parseHeader(msg []byte) *preparse.Header {
+	header, err := preparse.Parse(msg)
+	if err != nil {
+		return &preparse.Header{}
+	}
+
+	return header
+}
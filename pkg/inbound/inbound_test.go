@@ -0,0 +1,147 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package inbound
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/preparse"
+)
+
+func TestMiddleware(t *testing.T) {
+	var (
+		handled []*transport.Envelope
+		steps   []string
+	)
+
+	it := &mockInbound{}
+
+	m := NewMiddleware(it, Verify, Relay, Gate)
+	require.NoError(t, m.Start(&mockProvider{handler: func(envelope *transport.Envelope) error {
+		handled = append(handled, envelope)
+
+		return nil
+	}}))
+
+	step := func(name string, err error) Handler {
+		return func(envelope *transport.Envelope, _ *preparse.Header, next transport.InboundMessageHandler) error {
+			steps = append(steps, name)
+
+			if err != nil {
+				return err
+			}
+
+			return next(envelope)
+		}
+	}
+
+	t.Run("stages not bound pass the envelopes on", func(t *testing.T) {
+		require.NoError(t, it.handler(&transport.Envelope{}))
+		require.Len(t, handled, 1)
+	})
+
+	t.Run("stages handled in the order of the chain", func(t *testing.T) {
+		m.Handle(Gate, step("gate", nil))
+		m.Handle(Verify, step("verify", nil))
+		m.Handle(Limit, step("limit", nil))
+
+		require.NoError(t, it.handler(&transport.Envelope{}))
+		require.Len(t, handled, 2)
+		require.Equal(t, []string{"verify", "gate"}, steps)
+		require.True(t, m.Has(Relay))
+		require.False(t, m.Has(Limit))
+	})
+
+	t.Run("envelope consumed", func(t *testing.T) {
+		steps = nil
+
+		m.Handle(Relay, func(*transport.Envelope, *preparse.Header, transport.InboundMessageHandler) error {
+			steps = append(steps, "relay")
+
+			return nil
+		})
+
+		require.NoError(t, it.handler(&transport.Envelope{}))
+		require.Len(t, handled, 2)
+		require.Equal(t, []string{"verify", "relay"}, steps)
+	})
+
+	t.Run("envelope rejected", func(t *testing.T) {
+		steps = nil
+
+		m.Handle(Verify, Check(func(*transport.Envelope, *preparse.Header) error {
+			return errors.New("verify error")
+		}))
+
+		require.EqualError(t, it.handler(&transport.Envelope{}), "verify error")
+		require.Len(t, handled, 2)
+		require.Empty(t, steps)
+
+		m.Handle(Verify, Check(func(*transport.Envelope, *preparse.Header) error {
+			return nil
+		}))
+
+		require.NoError(t, it.handler(&transport.Envelope{}))
+		require.Equal(t, []string{"relay"}, steps)
+	})
+}
+
+func TestMiddlewareHeader(t *testing.T) {
+	var headers []*preparse.Header
+
+	it := &mockInbound{}
+
+	m := NewMiddleware(it, Admit, Gate)
+	require.NoError(t, m.Start(&mockProvider{handler: func(*transport.Envelope) error { return nil }}))
+
+	record := func(envelope *transport.Envelope, header *preparse.Header, next transport.InboundMessageHandler) error {
+		headers = append(headers, header)
+
+		return next(envelope)
+	}
+
+	m.Handle(Admit, record)
+	m.Handle(Gate, record)
+
+	require.NoError(t, it.handler(&transport.Envelope{Message: []byte(`{"@id":"1","@type":"type"}`)}))
+	require.Len(t, headers, 2)
+	require.Same(t, headers[0], headers[1])
+	require.Equal(t, "1", string(headers[0].ID))
+	require.Equal(t, "type", string(headers[0].Type))
+
+	t.Run("empty header of a message that isn't JSON", func(t *testing.T) {
+		headers = nil
+
+		require.NoError(t, it.handler(&transport.Envelope{Message: []byte("invalid")}))
+		require.Len(t, headers, 2)
+		require.Equal(t, &preparse.Header{}, headers[1])
+	})
+}
+
+type mockInbound struct {
+	transport.InboundTransport
+	handler transport.InboundMessageHandler
+}
+
+func (m *mockInbound) Start(prov transport.Provider) error {
+	m.handler = prov.InboundMessageHandler()
+
+	return nil
+}
+
+type mockProvider struct {
+	transport.Provider
+	handler transport.InboundMessageHandler
+}
+
+func (m *mockProvider) InboundMessageHandler() transport.InboundMessageHandler {
+	return m.handler
+}
@@ -0,0 +1,88 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package incident persists the incident timeline of the router : the failed health checks, the critical alerts and
+// the bursts of internal errors, from the time they are raised to the time they are resolved. The timeline is kept
+// for the retention period, so that the post-mortems don't depend on the retention of the external monitoring.
+package incident
+
+import (
+	"time"
+)
+
+// ErrorBurst is the kind of the incidents of the bursts of internal errors.
+const ErrorBurst = "error-burst"
+
+// Defaults of the timeline.
+const (
+	DefaultRetention      = 90 * 24 * time.Hour
+	DefaultBurstThreshold = 10
+	DefaultBurstWindow    = time.Minute
+)
+
+// Config of the timeline, the defaults apply to the zero values.
+type Config struct {
+	// Retention is the period the incidents are kept for, once resolved.
+	Retention time.Duration
+	// BurstThreshold is the number of internal errors within the burst window opening an error burst incident. The
+	// incident is resolved once the errors within the window are back below the threshold.
+	BurstThreshold int
+	// BurstWindow is the sliding window the internal errors are counted over.
+	BurstWindow time.Duration
+}
+
+func (c *Config) withDefaults() *Config {
+	cfg := Config{}
+
+	if c != nil {
+		cfg = *c
+	}
+
+	if cfg.Retention <= 0 {
+		cfg.Retention = DefaultRetention
+	}
+
+	if cfg.BurstThreshold <= 0 {
+		cfg.BurstThreshold = DefaultBurstThreshold
+	}
+
+	if cfg.BurstWindow <= 0 {
+		cfg.BurstWindow = DefaultBurstWindow
+	}
+
+	return &cfg
+}
+
+// Incident is a condition of the router, from the time it is raised to the time it is resolved.
+type Incident struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+	// Subject the incident is raised for, eg: the connection, the storage or the digest schedule.
+	Subject string    `json:"subject,omitempty"`
+	Summary string    `json:"summary"`
+	Start   time.Time `json:"start"`
+	// End is the time the incident is resolved, nil while ongoing.
+	End *time.Time `json:"end,omitempty"`
+	// Errors is the number of internal errors of the error bursts.
+	Errors int      `json:"errors,omitempty"`
+	Events []*Event `json:"events"`
+}
+
+// Event is an entry of the incident timeline.
+type Event struct {
+	Time    time.Time `json:"time"`
+	Summary string    `json:"summary"`
+}
+
+// Ongoing returns true if the incident is not resolved yet.
+func (i *Incident) Ongoing() bool {
+	return i.End == nil
+}
+
+// overlaps returns true if the incident is ongoing at some time within [from, to). A zero value disables the bound.
+func (i *Incident) overlaps(from, to time.Time) bool {
+	return (to.IsZero() || i.Start.Before(to)) && (from.IsZero() || i.End == nil || !i.End.Before(from))
+}
@@ -0,0 +1,323 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package incident
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "incident"
+
+	// tag used to query the incidents; the value is the start of the incident in unix seconds.
+	incidentTag = "incident"
+)
+
+var logger = log.New("hub-router/incident")
+
+// Timeline records the incidents of the router instance. The ongoing incidents are tracked in memory : an incident
+// left ongoing by a stopped router instance is not resolved by the other instances.
+type Timeline struct {
+	store    storage.Store
+	cfg      *Config
+	mutex    sync.Mutex
+	ongoing  map[string]*Incident
+	errors   []time.Time
+	burst    *Incident
+	now      func() time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns a new Timeline with the config, the defaults apply if nil.
+func New(p storage.Provider, cfg *Config) (*Timeline, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open incident store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{incidentTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set incident store config : %w", err)
+	}
+
+	return &Timeline{
+		store:   store,
+		cfg:     cfg.withDefaults(),
+		ongoing: map[string]*Incident{},
+		now:     time.Now,
+		stop:    make(chan struct{}),
+	}, nil
+}
+
+// Raise opens the incident of the kind and subject raised at the time, or adds the event to the timeline of the
+// incident if ongoing.
+func (t *Timeline) Raise(kind, subject, summary string, at time.Time) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	i, ok := t.ongoing[kind+"/"+subject]
+	if !ok {
+		i = newIncident(kind, subject, summary, at)
+		t.ongoing[kind+"/"+subject] = i
+	} else {
+		i.Events = append(i.Events, &Event{Time: at.UTC(), Summary: summary})
+	}
+
+	return t.put(i)
+}
+
+// Resolve resolves the ongoing incident of the kind and subject at the time, if any.
+func (t *Timeline) Resolve(kind, subject, summary string, at time.Time) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	i, ok := t.ongoing[kind+"/"+subject]
+	if !ok {
+		return nil
+	}
+
+	delete(t.ongoing, kind+"/"+subject)
+
+	return t.put(resolve(i, summary, at))
+}
+
+// Record records an incident of the kind and subject over at the time, eg: the delivery SLO breach of a period.
+func (t *Timeline) Record(kind, subject, summary string, at time.Time) error {
+	i := newIncident(kind, subject, summary, at)
+	end := i.Start
+
+	i.End = &end
+
+	return t.put(i)
+}
+
+// Error records an internal error, opening an error burst incident once the errors within the burst window reach the
+// threshold. The errors of the ongoing burst are saved by Check.
+func (t *Timeline) Error() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now().UTC()
+
+	t.errors = append(t.recentErrors(now), now)
+
+	if t.burst != nil {
+		t.burst.Errors++
+
+		return nil
+	}
+
+	if len(t.errors) < t.cfg.BurstThreshold {
+		return nil
+	}
+
+	logger.Warnf("error burst : %d errors within %s", len(t.errors), t.cfg.BurstWindow)
+
+	t.burst = newIncident(ErrorBurst, "",
+		fmt.Sprintf("%d internal errors within %s", len(t.errors), t.cfg.BurstWindow), now)
+	t.burst.Errors = len(t.errors)
+
+	return t.put(t.burst)
+}
+
+// Check resolves the error burst once the errors within the burst window are back below the threshold, saving its
+// errors otherwise, and prunes the incidents resolved before the retention period.
+func (t *Timeline) Check() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now().UTC()
+
+	t.errors = t.recentErrors(now)
+
+	if t.burst != nil {
+		if len(t.errors) < t.cfg.BurstThreshold {
+			logger.Infof("error burst over : %d errors", t.burst.Errors)
+
+			burst := resolve(t.burst, fmt.Sprintf("%d internal errors within %s, back below threshold %d",
+				len(t.errors), t.cfg.BurstWindow, t.cfg.BurstThreshold), now)
+			t.burst = nil
+
+			if err := t.put(burst); err != nil {
+				return err
+			}
+		} else if err := t.put(t.burst); err != nil {
+			return err
+		}
+	}
+
+	return t.prune(now)
+}
+
+// Incidents returns the incidents ongoing at some time within [from, to), ordered by start. A zero value disables
+// the bound.
+func (t *Timeline) Incidents(from, to time.Time) ([]*Incident, error) {
+	incidents := []*Incident{}
+
+	err := t.query(func(i *Incident) {
+		if i.overlaps(from, to) {
+			incidents = append(incidents, i)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(incidents, func(i, j int) bool {
+		return incidents[i].Start.Before(incidents[j].Start)
+	})
+
+	return incidents, nil
+}
+
+// Start checks the error bursts and prunes the incidents periodically until Stop is called.
+func (t *Timeline) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := t.Check(); err != nil {
+					logger.Warnf("incident check : %s", err)
+				}
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic checks.
+func (t *Timeline) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+	})
+}
+
+// recentErrors returns the errors within the burst window.
+func (t *Timeline) recentErrors(now time.Time) []time.Time {
+	since := now.Add(-t.cfg.BurstWindow)
+
+	n := sort.Search(len(t.errors), func(i int) bool {
+		return t.errors[i].After(since)
+	})
+
+	return t.errors[n:]
+}
+
+// prune deletes the incidents resolved before the retention period.
+func (t *Timeline) prune(now time.Time) error {
+	before := now.Add(-t.cfg.Retention)
+
+	var expired []string
+
+	err := t.query(func(i *Incident) {
+		if i.End != nil && i.End.Before(before) {
+			expired = append(expired, i.ID)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range expired {
+		if err = t.store.Delete(key(id)); err != nil {
+			return fmt.Errorf("delete incident : %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (t *Timeline) put(i *Incident) error {
+	b, err := json.Marshal(i)
+	if err != nil {
+		return fmt.Errorf("marshal incident : %w", err)
+	}
+
+	err = t.store.Put(key(i.ID), b, storage.Tag{Name: incidentTag, Value: strconv.FormatInt(i.Start.Unix(), 10)})
+	if err != nil {
+		return fmt.Errorf("save incident : %w", err)
+	}
+
+	return nil
+}
+
+// query calls read with the incidents saved.
+func (t *Timeline) query(read func(*Incident)) error {
+	iter, err := t.store.Query(incidentTag)
+	if err != nil {
+		return fmt.Errorf("query incidents : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("iterate incidents : %w", err)
+		}
+
+		if !ok {
+			return nil
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return fmt.Errorf("read incident : %w", err)
+		}
+
+		i := &Incident{}
+		if err = json.Unmarshal(val, i); err != nil {
+			return fmt.Errorf("unmarshal incident : %w", err)
+		}
+
+		read(i)
+	}
+}
+
+func newIncident(kind, subject, summary string, at time.Time) *Incident {
+	at = at.UTC()
+
+	return &Incident{
+		ID:      uuid.New().String(),
+		Kind:    kind,
+		Subject: subject,
+		Summary: summary,
+		Start:   at,
+		Events:  []*Event{{Time: at, Summary: summary}},
+	}
+}
+
+// resolve returns a copy of the incident resolved at the time.
+func resolve(i *Incident, summary string, at time.Time) *Incident {
+	resolved := *i
+	end := at.UTC()
+
+	resolved.End = &end
+	resolved.Events = append(append([]*Event{}, i.Events...), &Event{Time: end, Summary: summary})
+
+	return &resolved
+}
+
+// key returns the key of the incident.
+func key(id string) string {
+	return "incident-" + id
+}
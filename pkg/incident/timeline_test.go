@@ -0,0 +1,205 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package incident
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		tl, err := New(mem.NewProvider(), nil)
+		require.NoError(t, err)
+		require.Equal(t, DefaultRetention, tl.cfg.Retention)
+		require.Equal(t, DefaultBurstThreshold, tl.cfg.BurstThreshold)
+		require.Equal(t, DefaultBurstWindow, tl.cfg.BurstWindow)
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open incident store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set incident store config")
+	})
+}
+
+func TestTimeline(t *testing.T) {
+	start := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("raises and resolves the incidents", func(t *testing.T) {
+		tl, err := New(mem.NewProvider(), nil)
+		require.NoError(t, err)
+
+		require.NoError(t, tl.Raise("storage-failure", "router", "router storage failing", start))
+		require.NoError(t, tl.Raise("storage-failure", "router", "router storage still failing",
+			start.Add(time.Minute)))
+		require.NoError(t, tl.Raise("queue-watermark", "conn-1", "queue above watermark", start.Add(time.Hour)))
+		require.NoError(t, tl.Resolve("storage-failure", "router", "router storage recovered",
+			start.Add(2*time.Minute)))
+		require.NoError(t, tl.Resolve("storage-failure", "aries", "not raised", start.Add(2*time.Minute)))
+		require.NoError(t, tl.Record("slo-breach", "daily", "SLO not met", start.Add(30*time.Minute)))
+
+		incidents, err := tl.Incidents(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, incidents, 3)
+
+		storage := incidents[0]
+		require.Equal(t, "storage-failure", storage.Kind)
+		require.Equal(t, "router", storage.Subject)
+		require.Equal(t, "router storage failing", storage.Summary)
+		require.Equal(t, start, storage.Start)
+		require.False(t, storage.Ongoing())
+		require.Equal(t, start.Add(2*time.Minute), *storage.End)
+		require.Len(t, storage.Events, 3)
+		require.Equal(t, "router storage recovered", storage.Events[2].Summary)
+
+		slo := incidents[1]
+		require.Equal(t, "slo-breach", slo.Kind)
+		require.Equal(t, slo.Start, *slo.End)
+
+		queue := incidents[2]
+		require.True(t, queue.Ongoing())
+		require.Len(t, queue.Events, 1)
+
+		// the incidents ongoing within the range
+		incidents, err = tl.Incidents(start.Add(10*time.Minute), start.Add(2*time.Hour))
+		require.NoError(t, err)
+		require.Len(t, incidents, 2)
+		require.Equal(t, slo.ID, incidents[0].ID)
+		require.Equal(t, queue.ID, incidents[1].ID)
+
+		incidents, err = tl.Incidents(start, start.Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, incidents, 1)
+		require.Equal(t, storage.ID, incidents[0].ID)
+	})
+
+	t.Run("opens and resolves the error bursts", func(t *testing.T) {
+		now := start
+
+		tl, err := New(mem.NewProvider(), &Config{BurstThreshold: 3, BurstWindow: time.Minute})
+		require.NoError(t, err)
+
+		tl.now = func() time.Time { return now }
+
+		require.NoError(t, tl.Error())
+		require.NoError(t, tl.Error())
+
+		now = now.Add(2 * time.Minute)
+
+		// the errors outside the window don't count
+		require.NoError(t, tl.Error())
+		require.NoError(t, tl.Error())
+		require.NoError(t, tl.Check())
+
+		incidents, err := tl.Incidents(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Empty(t, incidents)
+
+		require.NoError(t, tl.Error())
+		require.NoError(t, tl.Error())
+		require.NoError(t, tl.Check())
+
+		incidents, err = tl.Incidents(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, incidents, 1)
+		require.Equal(t, ErrorBurst, incidents[0].Kind)
+		require.Equal(t, "3 internal errors within 1m0s", incidents[0].Summary)
+		require.Equal(t, 4, incidents[0].Errors)
+		require.True(t, incidents[0].Ongoing())
+
+		now = now.Add(2 * time.Minute)
+
+		require.NoError(t, tl.Check())
+
+		incidents, err = tl.Incidents(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, incidents, 1)
+		require.Equal(t, now, *incidents[0].End)
+		require.Equal(t, 4, incidents[0].Errors)
+		require.Len(t, incidents[0].Events, 2)
+	})
+
+	t.Run("prunes the incidents resolved before the retention period", func(t *testing.T) {
+		now := start
+
+		tl, err := New(mem.NewProvider(), &Config{Retention: time.Hour})
+		require.NoError(t, err)
+
+		tl.now = func() time.Time { return now }
+
+		require.NoError(t, tl.Record("slo-breach", "daily", "SLO not met", start))
+		require.NoError(t, tl.Raise("storage-failure", "router", "router storage failing", start))
+
+		now = now.Add(2 * time.Hour)
+
+		require.NoError(t, tl.Check())
+
+		incidents, err := tl.Incidents(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, incidents, 1)
+		require.Equal(t, "storage-failure", incidents[0].Kind)
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		p := mockstore.NewMockStoreProvider()
+		p.Store.ErrPut = errors.New("put error")
+		p.Store.ErrQuery = errors.New("query error")
+
+		tl, err := New(p, &Config{BurstThreshold: 1})
+		require.NoError(t, err)
+
+		err = tl.Raise("storage-failure", "router", "failing", start)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save incident")
+
+		err = tl.Resolve("storage-failure", "router", "recovered", start)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save incident")
+
+		err = tl.Error()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save incident")
+
+		err = tl.Check()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save incident")
+
+		_, err = tl.Incidents(time.Time{}, time.Time{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query incidents")
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		tl, err := New(mem.NewProvider(), nil)
+		require.NoError(t, err)
+
+		tl.Start(time.Millisecond)
+		time.Sleep(5 * time.Millisecond)
+		tl.Stop()
+		tl.Stop()
+	})
+}
@@ -18,7 +18,11 @@ import (
 type MockClient struct {
 	ActionEventFunc  func(chan<- service.DIDCommAction) error
 	CreateConnErr    error
+	ImplicitInvErr   error
 	GetConnectionErr error
+	MyDID            string
+	TheirDID         string
+	InvitationID     string
 }
 
 // RegisterActionEvent registers the action event channel.
@@ -39,11 +43,22 @@ func (c *MockClient) CreateConnection(_ string, _ *did.Doc, _ ...didexchange.Con
 	return uuid.New().String(), nil
 }
 
+// CreateImplicitInvitation starts the did exchange with the public DID, returns the new connection ID.
+func (c *MockClient) CreateImplicitInvitation(_, _ string, _ ...didexchange.Opt) (string, error) {
+	if c.ImplicitInvErr != nil {
+		return "", c.ImplicitInvErr
+	}
+
+	return uuid.New().String(), nil
+}
+
 // GetConnection fetches connection record based on connID.
 func (c *MockClient) GetConnection(connectionID string) (*didexchange.Connection, error) {
 	if c.GetConnectionErr != nil {
 		return nil, c.GetConnectionErr
 	}
 
-	return &didexchange.Connection{Record: &connection.Record{ConnectionID: connectionID}}, nil
+	return &didexchange.Connection{Record: &connection.Record{
+		ConnectionID: connectionID, MyDID: c.MyDID, TheirDID: c.TheirDID, InvitationID: c.InvitationID,
+	}}, nil
 }
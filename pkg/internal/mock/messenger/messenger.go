@@ -14,6 +14,8 @@ import (
 type MockMessenger struct {
 	ReplyToFunc func(msgID string, msg service.DIDCommMsgMap) error
 	SendFunc    func(msg service.DIDCommMsgMap, myDID, theirDID string) error
+	// SendToDestinationFunc is called by SendToDestination.
+	SendToDestinationFunc func(msg service.DIDCommMsgMap, sender string, destination *service.Destination) error
 }
 
 // ReplyTo reply to a message.
@@ -40,7 +42,12 @@ func (m *MockMessenger) Send(msg service.DIDCommMsgMap, myDID, theirDID string)
 }
 
 // SendToDestination send mesage to destination.
-func (m *MockMessenger) SendToDestination(_ service.DIDCommMsgMap, _ string, _ *service.Destination) error {
+func (m *MockMessenger) SendToDestination(msg service.DIDCommMsgMap, sender string,
+	destination *service.Destination) error {
+	if m.SendToDestinationFunc != nil {
+		return m.SendToDestinationFunc(msg, sender, destination)
+	}
+
 	return nil
 }
 
@@ -11,6 +11,9 @@ import "github.com/hyperledger/aries-framework-go/pkg/client/outofband"
 // MockClient is a mock out-of-band client used in tests.
 type MockClient struct {
 	CreateInvitationErr error
+	InvitationID        string
+	AcceptInvitationErr error
+	ConnectionID        string
 }
 
 // CreateInvitation creates a mock outofband invitation.
@@ -19,5 +22,14 @@ func (c *MockClient) CreateInvitation([]interface{}, ...outofband.MessageOption)
 		return nil, c.CreateInvitationErr
 	}
 
-	return &outofband.Invitation{}, nil
+	return &outofband.Invitation{ID: c.InvitationID}, nil
+}
+
+// AcceptInvitation accepts a mock outofband invitation.
+func (c *MockClient) AcceptInvitation(*outofband.Invitation, string, ...outofband.MessageOption) (string, error) {
+	if c.AcceptInvitationErr != nil {
+		return "", c.AcceptInvitationErr
+	}
+
+	return c.ConnectionID, nil
 }
@@ -0,0 +1,252 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package smtp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is an email received by the mock server.
+type Message struct {
+	From          string
+	To            []string
+	Data          string
+	TLS           bool
+	Authenticated bool
+}
+
+// Config of the mock server.
+type Config struct {
+	// ImplicitTLS serves SMTP over TLS (SMTPS).
+	ImplicitTLS bool
+	// StartTLS advertises the STARTTLS extension.
+	StartTLS bool
+	// Auth advertises the AUTH PLAIN extension, any credentials are accepted.
+	Auth bool
+	// RejectRecipients rejects the recipients of the emails.
+	RejectRecipients bool
+}
+
+// Server is a mock SMTP server on the loopback interface.
+type Server struct {
+	Addr     string
+	config   Config
+	tls      *tls.Config
+	certPool *x509.CertPool
+	listener net.Listener
+	mutex    sync.Mutex
+	messages []*Message
+}
+
+// NewServer starts a new mock SMTP server with the config. The TLS certificate is self-signed, see CertPool.
+func NewServer(config Config) (*Server, error) {
+	s := &Server{config: config}
+
+	if err := s.initTLS(); err != nil {
+		return nil, err
+	}
+
+	var err error
+
+	if config.ImplicitTLS {
+		s.listener, err = tls.Listen("tcp", "127.0.0.1:0", s.tls)
+	} else {
+		s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.Addr = s.listener.Addr().String()
+
+	go s.serve()
+
+	return s, nil
+}
+
+// CertPool returns the pool of the self-signed certificate of the server.
+func (s *Server) CertPool() *x509.CertPool {
+	return s.certPool
+}
+
+// Messages returns the emails received.
+func (s *Server) Messages() []*Message {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]*Message(nil), s.messages...)
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+// nolint:gocyclo,funlen // mock SMTP conversation
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close() // nolint:errcheck // mock
+
+	text := textproto.NewConn(conn)
+	msg := &Message{TLS: s.config.ImplicitTLS}
+
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			if err := text.PrintfLine("%s", line); err != nil {
+				return
+			}
+		}
+	}
+
+	reply("220 mock ESMTP")
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0]) // nolint:gomnd // verb and parameters
+
+		switch verb {
+		case "EHLO", "HELO":
+			lines := []string{"250-mock"}
+
+			if s.config.StartTLS && !msg.TLS {
+				lines = append(lines, "250-STARTTLS")
+			}
+
+			if s.config.Auth {
+				lines = append(lines, "250-AUTH PLAIN")
+			}
+
+			reply(append(lines, "250 8BITMIME")...)
+		case "STARTTLS":
+			reply("220 ready to start TLS")
+
+			tlsConn := tls.Server(conn, s.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+
+			text, msg.TLS = textproto.NewConn(tlsConn), true
+		case "AUTH":
+			msg.Authenticated = true
+
+			reply("235 authenticated")
+		case "MAIL":
+			msg.From = address(line)
+
+			reply("250 ok")
+		case "RCPT":
+			if s.config.RejectRecipients {
+				reply("550 mailbox unavailable")
+
+				continue
+			}
+
+			msg.To = append(msg.To, address(line))
+
+			reply("250 ok")
+		case "DATA":
+			reply("354 end data with <CR><LF>.<CR><LF>")
+
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+
+			msg.Data = string(data)
+
+			s.mutex.Lock()
+			s.messages = append(s.messages, msg)
+			s.mutex.Unlock()
+
+			msg = &Message{TLS: msg.TLS}
+
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+
+			return
+		default:
+			reply("250 ok")
+		}
+	}
+}
+
+// address returns the address of the MAIL FROM:<address> and RCPT TO:<address> commands.
+func address(line string) string {
+	start, end := strings.Index(line, "<"), strings.LastIndex(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+
+	return line[start+1 : end]
+}
+
+func (s *Server) initTLS() error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mock smtp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)}, // nolint:gomnd // loopback
+		DNSNames:     []string{"localhost"},
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return err
+	}
+
+	s.certPool = x509.NewCertPool()
+	s.certPool.AddCert(cert)
+	s.tls = &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert}},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	return nil
+}
@@ -0,0 +1,42 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package storage
+
+import (
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// MockProvider is a mock storage provider backed by the in-memory provider with injectable errors.
+type MockProvider struct {
+	storage.Provider
+	OpenStoreErr      error
+	SetStoreConfigErr error
+}
+
+// NewMockProvider returns a new MockProvider.
+func NewMockProvider() *MockProvider {
+	return &MockProvider{Provider: mem.NewProvider()}
+}
+
+// OpenStore opens the store or returns OpenStoreErr.
+func (p *MockProvider) OpenStore(name string) (storage.Store, error) {
+	if p.OpenStoreErr != nil {
+		return nil, p.OpenStoreErr
+	}
+
+	return p.Provider.OpenStore(name)
+}
+
+// SetStoreConfig sets the store config or returns SetStoreConfigErr.
+func (p *MockProvider) SetStoreConfig(name string, config storage.StoreConfiguration) error {
+	if p.SetStoreConfigErr != nil {
+		return p.SetStoreConfigErr
+	}
+
+	return p.Provider.SetStoreConfig(name, config)
+}
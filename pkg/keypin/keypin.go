@@ -0,0 +1,183 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package keypin
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "keypin"
+	jwsParts  = 3
+)
+
+// ErrKeyMismatch is returned when an envelope is sent with another key than the one pinned for the connection.
+var ErrKeyMismatch = errors.New("sender key does not match the pinned key")
+
+var errNotPinned = errors.New("key not pinned")
+
+var logger = log.New("hub-router/keypin")
+
+// MismatchError details a sender key mismatch; it wraps ErrKeyMismatch.
+type MismatchError struct {
+	RecipientKey string
+	Pinned       string
+	Received     string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf("%s : recipientKey=%s pinned=%s received=%s", ErrKeyMismatch, e.RecipientKey, e.Pinned, e.Received)
+}
+
+// Unwrap returns ErrKeyMismatch.
+func (e *MismatchError) Unwrap() error {
+	return ErrKeyMismatch
+}
+
+// Pin is the sender key pinned for the connection the router uses the recipient key for.
+type Pin struct {
+	RecipientKey string    `json:"recipientKey"`
+	SenderKey    string    `json:"senderKey"`
+	Thumbprint   string    `json:"thumbprint"`
+	PinnedAt     time.Time `json:"pinnedAt"`
+	Rotations    int       `json:"rotations"`
+}
+
+// Store pins the sender keys of the inbound envelopes on first use, per router recipient key. As the router uses
+// a distinct key per connection, the pins are connection-scoped.
+type Store struct {
+	store storage.Store
+	mutex sync.Mutex
+}
+
+// New returns a new key pin Store.
+func New(p storage.Provider) (*Store, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open key pin store : %w", err)
+	}
+
+	return &Store{store: store}, nil
+}
+
+// Thumbprint returns the thumbprint of the key : the base64url encoded SHA-256 hash of its bytes.
+func Thumbprint(key []byte) string {
+	h := sha256.Sum256(key)
+
+	return base64.RawURLEncoding.EncodeToString(h[:])
+}
+
+// Verify checks the sender key of an envelope against the key pinned for the recipient key, pinning it on first use.
+// A new sender key is pinned only if the message carries a DID rotation (from_prior) signed with the pinned key.
+// Anonymous envelopes, without sender key, are not checked.
+func (s *Store) Verify(recipientKey, senderKey, msg []byte) error {
+	if len(recipientKey) == 0 || len(senderKey) == 0 {
+		return nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	pin, err := s.get(Thumbprint(recipientKey))
+	if errors.Is(err, errNotPinned) {
+		pin, err = &Pin{RecipientKey: Thumbprint(recipientKey)}, nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	thumbprint := Thumbprint(senderKey)
+
+	switch {
+	case pin.Thumbprint == thumbprint:
+		return nil
+	case pin.Thumbprint == "":
+		logger.Debugf("sender key pinned : recipientKey=%s thumbprint=%s", pin.RecipientKey, thumbprint)
+	case rotated(msg, base58.Decode(pin.SenderKey)):
+		pin.Rotations++
+
+		logger.Infof("pinned key rotated : recipientKey=%s rotations=%d", pin.RecipientKey, pin.Rotations)
+	default:
+		return &MismatchError{RecipientKey: pin.RecipientKey, Pinned: pin.Thumbprint, Received: thumbprint}
+	}
+
+	pin.SenderKey = base58.Encode(senderKey)
+	pin.Thumbprint = thumbprint
+	pin.PinnedAt = time.Now().UTC()
+
+	return s.save(pin)
+}
+
+func (s *Store) get(recipientKey string) (*Pin, error) {
+	pinBytes, err := s.store.Get(recipientKey)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, errNotPinned
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get key pin : %w", err)
+	}
+
+	pin := &Pin{}
+
+	err = json.Unmarshal(pinBytes, pin)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal key pin : %w", err)
+	}
+
+	return pin, nil
+}
+
+func (s *Store) save(pin *Pin) error {
+	pinBytes, err := json.Marshal(pin)
+	if err != nil {
+		return fmt.Errorf("marshal key pin : %w", err)
+	}
+
+	err = s.store.Put(pin.RecipientKey, pinBytes)
+	if err != nil {
+		return fmt.Errorf("save key pin : %w", err)
+	}
+
+	return nil
+}
+
+// rotated returns true if the message carries a DID rotation (from_prior JWT) signed with the pinned Ed25519 key.
+func rotated(msg, pinnedKey []byte) bool {
+	m := &struct {
+		FromPrior string `json:"from_prior"`
+	}{}
+
+	if err := json.Unmarshal(msg, m); err != nil || m.FromPrior == "" || len(pinnedKey) != ed25519.PublicKeySize {
+		return false
+	}
+
+	parts := strings.Split(m.FromPrior, ".")
+	if len(parts) != jwsParts {
+		return false
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+
+	return ed25519.Verify(pinnedKey, []byte(parts[0]+"."+parts[1]), sig)
+}
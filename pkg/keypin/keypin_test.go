@@ -0,0 +1,138 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package keypin
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	p := mockstorage.NewMockProvider()
+	p.OpenStoreErr = errors.New("open error")
+
+	_, err := New(p)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "open key pin store")
+}
+
+func TestStore(t *testing.T) {
+	t.Run("pin on first use", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		require.NoError(t, s.Verify([]byte("router-key-1"), []byte("sender-key-1"), nil))
+		require.NoError(t, s.Verify([]byte("router-key-1"), []byte("sender-key-1"), nil))
+		require.NoError(t, s.Verify([]byte("router-key-2"), []byte("sender-key-2"), nil))
+
+		err = s.Verify([]byte("router-key-1"), []byte("sender-key-2"), nil)
+		require.ErrorIs(t, err, ErrKeyMismatch)
+
+		mismatch := &MismatchError{}
+		require.True(t, errors.As(err, &mismatch))
+		require.Equal(t, Thumbprint([]byte("router-key-1")), mismatch.RecipientKey)
+		require.Equal(t, Thumbprint([]byte("sender-key-1")), mismatch.Pinned)
+		require.Equal(t, Thumbprint([]byte("sender-key-2")), mismatch.Received)
+		require.Contains(t, err.Error(), "recipientKey="+mismatch.RecipientKey)
+
+		pin, err := s.get(Thumbprint([]byte("router-key-1")))
+		require.NoError(t, err)
+		require.Equal(t, Thumbprint([]byte("sender-key-1")), pin.Thumbprint)
+	})
+
+	t.Run("anonymous envelope", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		require.NoError(t, s.Verify([]byte("router-key"), nil, nil))
+		require.NoError(t, s.Verify(nil, []byte("sender-key"), nil))
+
+		_, err = s.get(Thumbprint([]byte("router-key")))
+		require.ErrorIs(t, err, errNotPinned)
+	})
+
+	t.Run("did rotation", func(t *testing.T) {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		newPub, _, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		require.NoError(t, s.Verify([]byte("router-key"), pub, nil))
+
+		err = s.Verify([]byte("router-key"), newPub, []byte(`{"from_prior":"invalid"}`))
+		require.ErrorIs(t, err, ErrKeyMismatch)
+
+		_, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		err = s.Verify([]byte("router-key"), newPub, fromPrior(otherPriv))
+		require.ErrorIs(t, err, ErrKeyMismatch)
+
+		require.NoError(t, s.Verify([]byte("router-key"), newPub, fromPrior(priv)))
+		require.NoError(t, s.Verify([]byte("router-key"), newPub, nil))
+
+		pin, err := s.get(Thumbprint([]byte("router-key")))
+		require.NoError(t, err)
+		require.Equal(t, 1, pin.Rotations)
+
+		err = s.Verify([]byte("router-key"), pub, nil)
+		require.ErrorIs(t, err, ErrKeyMismatch)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		s, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+		}))
+		require.NoError(t, err)
+
+		err = s.Verify([]byte("router-key"), []byte("sender-key"), nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save key pin")
+
+		s, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}))
+		require.NoError(t, err)
+
+		err = s.Verify([]byte("router-key"), []byte("sender-key"), nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get key pin")
+
+		s, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store: map[string]mockstore.DBEntry{Thumbprint([]byte("router-key")): {Value: []byte("invalid")}},
+		}))
+		require.NoError(t, err)
+
+		err = s.Verify([]byte("router-key"), []byte("sender-key"), nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal key pin")
+	})
+}
+
+func fromPrior(priv ed25519.PrivateKey) []byte {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"EdDSA"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"did:example:new","iss":"did:example:prior"}`))
+	sig := base64.RawURLEncoding.EncodeToString(ed25519.Sign(priv, []byte(header+"."+payload)))
+
+	return []byte(fmt.Sprintf(`{"from_prior":"%s.%s.%s"}`, header, payload, sig))
+}
@@ -0,0 +1,212 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package keyusage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "keyusage"
+	reusedTag = "reused"
+)
+
+// Key roles.
+const (
+	RoleRouterDID  = "router-did"
+	RoleRoutingKey = "routing-key"
+)
+
+// Key reuse policies.
+const (
+	// PolicyAudit records and reports the key reuse.
+	PolicyAudit = "audit"
+	// PolicyReject also rejects the connections and mediation requests reusing a router key.
+	PolicyReject = "reject"
+)
+
+// ErrKeyReused is returned when a router key is used for more than one connection.
+var ErrKeyReused = errors.New("router key reused across connections")
+
+var logger = log.New("hub-router/keyusage")
+
+// Usage of a router key.
+type Usage struct {
+	Key           string    `json:"key"`
+	Role          string    `json:"role"`
+	ConnectionIDs []string  `json:"connectionIDs"`
+	FirstUsed     time.Time `json:"firstUsed"`
+}
+
+// Report verifies that no router key is used for more than one connection.
+type Report struct {
+	Time        time.Time `json:"time"`
+	Keys        int       `json:"keys"`
+	Connections int       `json:"connections"`
+	Reused      []*Usage  `json:"reused"`
+}
+
+// Registry records the keys the router uses per connection (router DIDs and routing keys), to detect their reuse
+// across relationships.
+type Registry struct {
+	store storage.Store
+	mutex sync.Mutex
+}
+
+// New returns a new key usage Registry.
+func New(p storage.Provider) (*Registry, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open key usage store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{reusedTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set key usage store config : %w", err)
+	}
+
+	return &Registry{store: store}, nil
+}
+
+// ValidPolicy returns true if the key reuse policy is supported.
+func ValidPolicy(policy string) bool {
+	return policy == PolicyAudit || policy == PolicyReject
+}
+
+// Record records the use of the public keys for the connection. It returns ErrKeyReused if a key was already used
+// for another connection; the use is recorded anyway, for the report.
+func (r *Registry) Record(connectionID, role string, keys ...[]byte) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var reused []string
+
+	for _, k := range keys {
+		if len(k) == 0 {
+			continue
+		}
+
+		u, err := r.record(connectionID, role, base58.Encode(k))
+		if err != nil {
+			return err
+		}
+
+		if len(u.ConnectionIDs) > 1 {
+			reused = append(reused, fmt.Sprintf("key=%s connections=%s", u.Key, strings.Join(u.ConnectionIDs, ",")))
+		}
+	}
+
+	if len(reused) > 0 {
+		logger.Warnf("router key reused : role=%s %s", role, strings.Join(reused, "; "))
+
+		return fmt.Errorf("%w : role=%s %s", ErrKeyReused, role, strings.Join(reused, "; "))
+	}
+
+	return nil
+}
+
+// Report returns the key usage report.
+func (r *Registry) Report() (*Report, error) {
+	iter, err := r.store.Query(reusedTag)
+	if err != nil {
+		return nil, fmt.Errorf("query key usage : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	report := &Report{Time: time.Now().UTC(), Reused: []*Usage{}}
+	connections := make(map[string]struct{})
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate key usage : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read key usage : %w", err)
+		}
+
+		u := &Usage{}
+
+		err = json.Unmarshal(val, u)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal key usage : %w", err)
+		}
+
+		report.Keys++
+
+		for _, connID := range u.ConnectionIDs {
+			connections[connID] = struct{}{}
+		}
+
+		if len(u.ConnectionIDs) > 1 {
+			report.Reused = append(report.Reused, u)
+		}
+	}
+
+	report.Connections = len(connections)
+
+	sort.Slice(report.Reused, func(i, j int) bool {
+		return report.Reused[i].FirstUsed.Before(report.Reused[j].FirstUsed)
+	})
+
+	return report, nil
+}
+
+func (r *Registry) record(connectionID, role, key string) (*Usage, error) {
+	u := &Usage{Key: key, Role: role, FirstUsed: time.Now().UTC()}
+
+	usageBytes, err := r.store.Get(key)
+
+	switch {
+	case errors.Is(err, storage.ErrDataNotFound):
+	case err != nil:
+		return nil, fmt.Errorf("get key usage : %w", err)
+	default:
+		err = json.Unmarshal(usageBytes, u)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal key usage : %w", err)
+		}
+	}
+
+	for _, connID := range u.ConnectionIDs {
+		if connID == connectionID {
+			return u, nil
+		}
+	}
+
+	u.ConnectionIDs = append(u.ConnectionIDs, connectionID)
+
+	usageBytes, err = json.Marshal(u)
+	if err != nil {
+		return nil, fmt.Errorf("marshal key usage : %w", err)
+	}
+
+	err = r.store.Put(key, usageBytes, storage.Tag{Name: reusedTag, Value: strconv.FormatBool(len(u.ConnectionIDs) > 1)})
+	if err != nil {
+		return nil, fmt.Errorf("save key usage : %w", err)
+	}
+
+	return u, nil
+}
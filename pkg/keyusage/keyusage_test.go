@@ -0,0 +1,117 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package keyusage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open key usage store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set key usage store config")
+	})
+}
+
+func TestValidPolicy(t *testing.T) {
+	require.True(t, ValidPolicy(PolicyAudit))
+	require.True(t, ValidPolicy(PolicyReject))
+	require.False(t, ValidPolicy("invalid"))
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("distinct keys", func(t *testing.T) {
+		r, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		require.NoError(t, r.Record("conn-1", RoleRouterDID, []byte("key-1")))
+		require.NoError(t, r.Record("conn-1", RoleRoutingKey, []byte("key-1"), []byte("key-2")))
+		require.NoError(t, r.Record("conn-2", RoleRouterDID, []byte("key-3")))
+		require.NoError(t, r.Record("conn-3", RoleRouterDID, nil))
+
+		report, err := r.Report()
+		require.NoError(t, err)
+		require.Equal(t, 3, report.Keys)
+		require.Equal(t, 2, report.Connections)
+		require.Empty(t, report.Reused)
+	})
+
+	t.Run("reused key", func(t *testing.T) {
+		r, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		require.NoError(t, r.Record("conn-1", RoleRoutingKey, []byte("key-1")))
+
+		err = r.Record("conn-2", RoleRoutingKey, []byte("key-2"), []byte("key-1"))
+		require.ErrorIs(t, err, ErrKeyReused)
+		require.Contains(t, err.Error(), "connections=conn-1,conn-2")
+
+		report, err := r.Report()
+		require.NoError(t, err)
+		require.Equal(t, 2, report.Keys)
+		require.Len(t, report.Reused, 1)
+		require.Equal(t, base58.Encode([]byte("key-1")), report.Reused[0].Key)
+		require.Equal(t, []string{"conn-1", "conn-2"}, report.Reused[0].ConnectionIDs)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		r, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}))
+		require.NoError(t, err)
+
+		err = r.Record("conn-1", RoleRouterDID, []byte("key-1"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save key usage")
+
+		_, err = r.Report()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query key usage")
+
+		r, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}))
+		require.NoError(t, err)
+
+		err = r.Record("conn-1", RoleRouterDID, []byte("key-1"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get key usage")
+
+		r, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store: map[string]mockstore.DBEntry{base58.Encode([]byte("key-1")): {Value: []byte("invalid")}},
+		}))
+		require.NoError(t, err)
+
+		err = r.Record("conn-1", RoleRouterDID, []byte("key-1"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal key usage")
+	})
+}
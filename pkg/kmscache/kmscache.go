@@ -0,0 +1,338 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kmscache
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/kms"
+	"github.com/hyperledger/aries-framework-go/pkg/kms/localkms"
+)
+
+const (
+	// LocalMasterKeyURI is the master key URI of the Aries local KMS, the one used by the Aries framework by default.
+	LocalMasterKeyURI = "local-lock://default/master/key/"
+	// DefaultTTL is the time the key handles are cached for, if not configured.
+	DefaultTTL = 5 * time.Minute
+
+	handlePrefix = "handle:"
+	pubKeyPrefix = "pubkey:"
+)
+
+// Config of the KMS cache.
+type Config struct {
+	// Size is the number of key handles and public keys cached, the KMS operations are only counted if zero.
+	Size int
+	// TTL is the time an entry is cached for, DefaultTTL if zero.
+	TTL time.Duration
+}
+
+// Enabled returns true if the cache size is configured.
+func (c *Config) Enabled() bool {
+	return c != nil && c.Size > 0
+}
+
+// Stats of the KMS operations since the router started.
+type Stats struct {
+	// Size is the number of entries cached, out of Capacity.
+	Size     int `json:"size"`
+	Capacity int `json:"capacity"`
+	// Lookups is the number of key handle and public key lookups, Hits the number served from the cache, and
+	// Coalesced the number served by a concurrent round trip for the same key.
+	Lookups   uint64 `json:"lookups"`
+	Hits      uint64 `json:"hits"`
+	Coalesced uint64 `json:"coalesced"`
+	// RoundTrips is the number of operations sent to the KMS, and RoundTripTime their average latency in
+	// microseconds.
+	RoundTrips    uint64 `json:"roundTrips"`
+	RoundTripTime int64  `json:"roundTripTime"`
+	Errors        uint64 `json:"errors"`
+}
+
+type entry struct {
+	key     string
+	value   interface{}
+	expires time.Time
+}
+
+// call is a round trip in progress, shared by the concurrent lookups of the same key.
+type call struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// KeyManager caches the key handles unwrapped by the KMS, and their public keys, so that the forwards packed for the
+// same router keys don't each cost a KMS round trip. The concurrent lookups of the same key share one round trip.
+// The keys are never cached as missing : they may be created by another router instance sharing the KMS.
+type KeyManager struct {
+	kms.KeyManager
+	config Config
+
+	mutex    sync.Mutex
+	lru      *list.List
+	entries  map[string]*list.Element
+	inflight map[string]*call
+	stats    Stats
+	rtTotal  time.Duration
+	now      func() time.Time
+}
+
+// New returns a new KeyManager caching the lookups of the given KMS.
+func New(km kms.KeyManager, config *Config) *KeyManager {
+	k := &KeyManager{
+		KeyManager: km,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		inflight:   make(map[string]*call),
+		now:        time.Now,
+	}
+
+	if config != nil {
+		k.config = *config
+	}
+
+	if k.config.TTL <= 0 {
+		k.config.TTL = DefaultTTL
+	}
+
+	return k
+}
+
+// NewCreator returns the Aries KMS creator of the local KMS, cached with the given config.
+func NewCreator(config *Config) kms.Creator {
+	return func(p kms.Provider) (kms.KeyManager, error) {
+		km, err := localkms.New(LocalMasterKeyURI, p)
+		if err != nil {
+			return nil, fmt.Errorf("create local kms : %w", err)
+		}
+
+		return New(km, config), nil
+	}
+}
+
+// Get returns the key handle of the given key ID.
+func (k *KeyManager) Get(keyID string) (interface{}, error) {
+	return k.lookup(handlePrefix+keyID, func() (interface{}, error) {
+		return k.KeyManager.Get(keyID)
+	})
+}
+
+// ExportPubKeyBytes returns the public key of the given key ID.
+func (k *KeyManager) ExportPubKeyBytes(keyID string) ([]byte, error) {
+	pubKey, err := k.lookup(pubKeyPrefix+keyID, func() (interface{}, error) {
+		return k.KeyManager.ExportPubKeyBytes(keyID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pubKeyBytes, _ := pubKey.([]byte) // nolint:errcheck // cached by ExportPubKeyBytes
+
+	return pubKeyBytes, nil
+}
+
+// Create creates a new key.
+func (k *KeyManager) Create(kt kms.KeyType) (string, interface{}, error) {
+	var (
+		keyID string
+		kh    interface{}
+	)
+
+	err := k.roundTrip(func() (err error) {
+		keyID, kh, err = k.KeyManager.Create(kt)
+
+		return
+	})
+
+	return keyID, kh, err
+}
+
+// CreateAndExportPubKeyBytes creates a new key and returns its public key.
+func (k *KeyManager) CreateAndExportPubKeyBytes(kt kms.KeyType) (string, []byte, error) {
+	var (
+		keyID  string
+		pubKey []byte
+	)
+
+	err := k.roundTrip(func() (err error) {
+		keyID, pubKey, err = k.KeyManager.CreateAndExportPubKeyBytes(kt)
+
+		return
+	})
+
+	return keyID, pubKey, err
+}
+
+// Rotate rotates the key, the cached handle and public key of the previous key are dropped.
+func (k *KeyManager) Rotate(kt kms.KeyType, keyID string) (string, interface{}, error) {
+	k.mutex.Lock()
+	k.remove(handlePrefix + keyID)
+	k.remove(pubKeyPrefix + keyID)
+	k.mutex.Unlock()
+
+	var (
+		newKeyID string
+		kh       interface{}
+	)
+
+	err := k.roundTrip(func() (err error) {
+		newKeyID, kh, err = k.KeyManager.Rotate(kt, keyID)
+
+		return
+	})
+
+	return newKeyID, kh, err
+}
+
+// ImportPrivateKey imports the private key.
+func (k *KeyManager) ImportPrivateKey(privKey interface{}, kt kms.KeyType,
+	opts ...kms.PrivateKeyOpts) (string, interface{}, error) {
+	var (
+		keyID string
+		kh    interface{}
+	)
+
+	err := k.roundTrip(func() (err error) {
+		keyID, kh, err = k.KeyManager.ImportPrivateKey(privKey, kt, opts...)
+
+		return
+	})
+
+	return keyID, kh, err
+}
+
+// Stats returns the KMS stats since the router started.
+func (k *KeyManager) Stats() *Stats {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	stats := k.stats
+	stats.Size = k.lru.Len()
+	stats.Capacity = k.config.Size
+
+	if stats.RoundTrips > 0 {
+		stats.RoundTripTime = (k.rtTotal / time.Duration(stats.RoundTrips)).Microseconds()
+	}
+
+	return &stats
+}
+
+// lookup returns the cached value of the key, or gets it from the KMS, once for the concurrent lookups.
+func (k *KeyManager) lookup(key string, get func() (interface{}, error)) (interface{}, error) {
+	k.mutex.Lock()
+	k.stats.Lookups++
+
+	if value, ok := k.cached(key); ok {
+		k.stats.Hits++
+		k.mutex.Unlock()
+
+		return value, nil
+	}
+
+	if c, ok := k.inflight[key]; ok {
+		k.stats.Coalesced++
+		k.mutex.Unlock()
+
+		<-c.done
+
+		return c.value, c.err
+	}
+
+	c := &call{done: make(chan struct{})}
+	k.inflight[key] = c
+	k.mutex.Unlock()
+
+	c.err = k.roundTrip(func() (err error) {
+		c.value, err = get()
+
+		return
+	})
+
+	k.mutex.Lock()
+	delete(k.inflight, key)
+
+	if c.err == nil {
+		k.add(key, c.value)
+	}
+
+	k.mutex.Unlock()
+
+	close(c.done)
+
+	return c.value, c.err
+}
+
+// roundTrip runs a KMS operation, counting its latency.
+func (k *KeyManager) roundTrip(op func() error) error {
+	start := k.now()
+	err := op()
+	elapsed := k.now().Sub(start)
+
+	k.mutex.Lock()
+	k.stats.RoundTrips++
+	k.rtTotal += elapsed
+
+	if err != nil {
+		k.stats.Errors++
+	}
+
+	k.mutex.Unlock()
+
+	return err
+}
+
+// cached returns the cached value of the key, if not expired. It is called with the mutex locked.
+func (k *KeyManager) cached(key string) (interface{}, bool) {
+	e, ok := k.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	if k.now().After(entryOf(e).expires) {
+		k.remove(key)
+
+		return nil, false
+	}
+
+	k.lru.MoveToFront(e)
+
+	return entryOf(e).value, true
+}
+
+// add caches the value, evicting the least recently used entry when the cache is full. It is called with the mutex
+// locked.
+func (k *KeyManager) add(key string, value interface{}) {
+	if !k.config.Enabled() {
+		return
+	}
+
+	k.remove(key)
+
+	if k.lru.Len() >= k.config.Size {
+		k.remove(entryOf(k.lru.Back()).key)
+	}
+
+	k.entries[key] = k.lru.PushFront(&entry{key: key, value: value, expires: k.now().Add(k.config.TTL)})
+}
+
+// remove drops the cached value of the key. It is called with the mutex locked.
+func (k *KeyManager) remove(key string) {
+	if e, ok := k.entries[key]; ok {
+		k.lru.Remove(e)
+		delete(k.entries, key)
+	}
+}
+
+func entryOf(e *list.Element) *entry {
+	v, _ := e.Value.(*entry) // nolint:errcheck // list of entries
+
+	return v
+}
@@ -0,0 +1,189 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package kmscache
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/kms"
+	mockkms "github.com/hyperledger/aries-framework-go/pkg/mock/kms"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/pkg/secretlock"
+	"github.com/hyperledger/aries-framework-go/pkg/secretlock/noop"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+)
+
+type kmsProvider struct {
+	storage storage.Provider
+}
+
+func (p *kmsProvider) StorageProvider() storage.Provider {
+	return p.storage
+}
+
+func (p *kmsProvider) SecretLock() secretlock.Service {
+	return &noop.NoLock{}
+}
+
+// slowKMS counts the Get calls, blocked until released.
+type slowKMS struct {
+	mockkms.KeyManager
+	mutex   sync.Mutex
+	gets    int
+	release chan struct{}
+}
+
+func (k *slowKMS) Get(keyID string) (interface{}, error) {
+	k.mutex.Lock()
+	k.gets++
+	k.mutex.Unlock()
+
+	<-k.release
+
+	return k.KeyManager.Get(keyID)
+}
+
+func TestKeyManager(t *testing.T) {
+	km, err := NewCreator(&Config{Size: 2, TTL: time.Minute})(&kmsProvider{storage: mem.NewProvider()})
+	require.NoError(t, err)
+
+	k, ok := km.(*KeyManager)
+	require.True(t, ok)
+
+	keyID, pubKey, err := k.CreateAndExportPubKeyBytes(kms.ED25519Type)
+	require.NoError(t, err)
+
+	t.Run("caches the key handles and public keys", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			kh, err := k.Get(keyID)
+			require.NoError(t, err)
+			require.NotNil(t, kh)
+
+			exported, err := k.ExportPubKeyBytes(keyID)
+			require.NoError(t, err)
+			require.Equal(t, pubKey, exported)
+		}
+
+		stats := k.Stats()
+		require.Equal(t, uint64(6), stats.Lookups)
+		require.Equal(t, uint64(4), stats.Hits)
+		// key creation, then one get and one export
+		require.Equal(t, uint64(3), stats.RoundTrips)
+		require.Equal(t, 2, stats.Size)
+		require.Equal(t, 2, stats.Capacity)
+	})
+
+	t.Run("missing keys aren't cached", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err = k.Get("missing")
+			require.Error(t, err)
+		}
+
+		stats := k.Stats()
+		require.Equal(t, uint64(5), stats.RoundTrips)
+		require.Equal(t, uint64(2), stats.Errors)
+	})
+
+	t.Run("evicts the least recently used entry", func(t *testing.T) {
+		otherID, _, err := k.Create(kms.ED25519Type)
+		require.NoError(t, err)
+
+		_, err = k.Get(keyID)
+		require.NoError(t, err)
+
+		_, err = k.Get(otherID)
+		require.NoError(t, err)
+
+		require.Contains(t, k.entries, handlePrefix+keyID)
+		require.Contains(t, k.entries, handlePrefix+otherID)
+		require.NotContains(t, k.entries, pubKeyPrefix+keyID)
+	})
+
+	t.Run("expires the entries", func(t *testing.T) {
+		roundTrips := k.Stats().RoundTrips
+
+		k.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+		defer func() { k.now = time.Now }()
+
+		_, err = k.Get(keyID)
+		require.NoError(t, err)
+		require.Equal(t, roundTrips+1, k.Stats().RoundTrips)
+	})
+
+	t.Run("rotation drops the previous key", func(t *testing.T) {
+		newKeyID, _, err := k.Rotate(kms.ED25519Type, keyID)
+		require.NoError(t, err)
+		require.NotEqual(t, keyID, newKeyID)
+		require.NotContains(t, k.entries, handlePrefix+keyID)
+	})
+
+	t.Run("import", func(t *testing.T) {
+		_, _, err = k.ImportPrivateKey(nil, kms.ED25519Type)
+		require.Error(t, err)
+	})
+}
+
+func TestCoalescedLookups(t *testing.T) {
+	slow := &slowKMS{release: make(chan struct{})}
+	k := New(slow, nil)
+	require.Equal(t, DefaultTTL, k.config.TTL)
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := k.Get("key")
+			require.NoError(t, err)
+		}()
+	}
+
+	require.Eventually(t, func() bool {
+		return k.Stats().Coalesced == 4
+	}, time.Second, 10*time.Millisecond)
+
+	close(slow.release)
+	wg.Wait()
+
+	require.Equal(t, 1, slow.gets)
+
+	// not cached without size
+	_, err := k.Get("key")
+	require.NoError(t, err)
+	require.Equal(t, 2, slow.gets)
+	require.Zero(t, k.Stats().Size)
+
+	var c *Config
+	require.False(t, c.Enabled())
+}
+
+func TestErrors(t *testing.T) {
+	k := New(&mockkms.KeyManager{
+		ExportPubKeyBytesErr: errors.New("export error"),
+		CrAndExportPubKeyErr: errors.New("create error"),
+	}, &Config{Size: 10})
+
+	_, err := k.ExportPubKeyBytes("key")
+	require.EqualError(t, err, "export error")
+
+	_, _, err = k.CreateAndExportPubKeyBytes(kms.ED25519Type)
+	require.EqualError(t, err, "create error")
+
+	_, err = NewCreator(nil)(&kmsProvider{
+		storage: &mockstore.MockStoreProvider{ErrOpenStoreHandle: errors.New("open error")},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "create local kms")
+}
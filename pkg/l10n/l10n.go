@@ -0,0 +1,145 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package l10n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is used when the requested locale or message isn't in the catalog.
+const DefaultLocale = "en"
+
+//go:embed catalog/*.json
+var catalogFS embed.FS
+
+// Catalog holds the localized human-readable messages, keyed by locale and message code.
+type Catalog struct {
+	messages map[string]map[string]string
+}
+
+// NewCatalog returns a Catalog loaded with the embedded message files (catalog/<locale>.json).
+func NewCatalog() (*Catalog, error) {
+	files, err := catalogFS.ReadDir("catalog")
+	if err != nil {
+		return nil, fmt.Errorf("read l10n catalog : %w", err)
+	}
+
+	c := &Catalog{messages: make(map[string]map[string]string)}
+
+	for _, f := range files {
+		fileBytes, err := catalogFS.ReadFile(path.Join("catalog", f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read l10n catalog file %s : %w", f.Name(), err)
+		}
+
+		msgs := make(map[string]string)
+
+		err = json.Unmarshal(fileBytes, &msgs)
+		if err != nil {
+			return nil, fmt.Errorf("parse l10n catalog file %s : %w", f.Name(), err)
+		}
+
+		c.messages[strings.TrimSuffix(f.Name(), path.Ext(f.Name()))] = msgs
+	}
+
+	return c, nil
+}
+
+// Text returns the message for the code in the closest available locale along with that locale.
+// A regional locale (eg: fr-CA) falls back to its base language (fr) and then to DefaultLocale.
+// If the code isn't found in any of them, then the code itself is returned.
+func (c *Catalog) Text(locale, code string) (text, resolvedLocale string) {
+	for _, l := range candidates(locale) {
+		if msg, ok := c.messages[l][code]; ok {
+			return msg, l
+		}
+	}
+
+	return code, DefaultLocale
+}
+
+// Negotiate returns the catalog locale closest to the languages of the Accept-Language header, by order of quality,
+// DefaultLocale if none of them is in the catalog.
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	for _, lang := range parseAcceptLanguage(acceptLanguage) {
+		for _, l := range languages(lang) {
+			if _, ok := c.messages[l]; ok {
+				return l
+			}
+		}
+	}
+
+	return DefaultLocale
+}
+
+// parseAcceptLanguage returns the languages of the Accept-Language header by order of quality, without the wildcard
+// and the languages refused with a zero quality.
+func parseAcceptLanguage(acceptLanguage string) []string {
+	type language struct {
+		tag     string
+		quality float64
+	}
+
+	var languages []language
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(part, ";")
+		lang := language{tag: strings.TrimSpace(fields[0]), quality: 1}
+
+		for _, param := range fields[1:] {
+			if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+				q, err := strconv.ParseFloat(strings.TrimPrefix(v, "q="), 64)
+				if err != nil {
+					q = 0
+				}
+
+				lang.quality = q
+			}
+		}
+
+		if lang.tag != "" && lang.tag != "*" && lang.quality > 0 {
+			languages = append(languages, lang)
+		}
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].quality > languages[j].quality })
+
+	tags := make([]string, len(languages))
+
+	for i, lang := range languages {
+		tags[i] = lang.tag
+	}
+
+	return tags
+}
+
+func candidates(locale string) []string {
+	return append(languages(locale), DefaultLocale)
+}
+
+// languages returns the locale and its base language, if regional.
+func languages(locale string) []string {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+
+	var result []string
+
+	if locale != "" {
+		result = append(result, locale)
+
+		if i := strings.Index(locale, "-"); i > 0 {
+			result = append(result, locale[:i])
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,72 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package l10n
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalog(t *testing.T) {
+	c, err := NewCatalog()
+	require.NoError(t, err)
+
+	t.Run("default locale", func(t *testing.T) {
+		text, locale := c.Text("", "invalid-did-doc")
+		require.Equal(t, "The DID document is not valid.", text)
+		require.Equal(t, DefaultLocale, locale)
+	})
+
+	t.Run("exact locale", func(t *testing.T) {
+		text, locale := c.Text("fr", "invalid-did-doc")
+		require.Equal(t, "Le document DID n'est pas valide.", text)
+		require.Equal(t, "fr", locale)
+	})
+
+	t.Run("regional locale falls back to language", func(t *testing.T) {
+		text, locale := c.Text("es_MX", "invalid-did-doc")
+		require.Equal(t, "El documento DID no es válido.", text)
+		require.Equal(t, "es", locale)
+	})
+
+	t.Run("unknown locale falls back to default", func(t *testing.T) {
+		text, locale := c.Text("xx", "internal-error")
+		require.Contains(t, text, "please try again later")
+		require.Equal(t, DefaultLocale, locale)
+	})
+
+	t.Run("unknown code", func(t *testing.T) {
+		text, locale := c.Text("fr", "unknown-code")
+		require.Equal(t, "unknown-code", text)
+		require.Equal(t, DefaultLocale, locale)
+	})
+
+	t.Run("negotiate", func(t *testing.T) {
+		for acceptLanguage, expected := range map[string]string{
+			"":                           DefaultLocale,
+			"*":                          DefaultLocale,
+			"fr":                         "fr",
+			"fr-CA":                      "fr",
+			"de-DE,de;q=0.9,en;q=0.8":    "de",
+			"en;q=0.5, es-MX":            "es",
+			"xx, es;q=0.3, fr;q=0.4":     "fr",
+			"fr;q=0, es;q=bad, de;q=0.1": "de",
+			"ja, zh":                     DefaultLocale,
+		} {
+			require.Equal(t, expected, c.Negotiate(acceptLanguage), acceptLanguage)
+		}
+	})
+
+	t.Run("all locales define the default messages", func(t *testing.T) {
+		for locale, msgs := range c.messages {
+			for code := range c.messages[DefaultLocale] {
+				require.Contains(t, msgs, code, "locale %s is missing %s", locale, code)
+			}
+		}
+	})
+}
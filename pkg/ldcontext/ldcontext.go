@@ -0,0 +1,179 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package ldcontext loads the JSON-LD contexts of the DID documents and credentials processed by the router : the
+// contexts embedded in the router and those of the offline bundle first, then the contexts cached in the storage, and
+// last the remote contexts, fetched from their URL and cached, if the remote loading policy allows it. With the
+// bundle and the cache-only or deny policy, the router functions without reaching the context URLs, eg: in air-gapped
+// environments.
+package ldcontext
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/jsonld"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/piprate/json-gold/ld"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+// Remote loading policies of the contexts neither embedded nor bundled.
+const (
+	// PolicyAllow fetches the contexts from their URL, and caches them.
+	PolicyAllow = "allow"
+	// PolicyCacheOnly loads the contexts cached in the storage, without fetching the others.
+	PolicyCacheOnly = "cache-only"
+	// PolicyDeny loads the embedded and bundled contexts only.
+	PolicyDeny = "deny"
+	// DefaultPolicy is the policy if not set : the router doesn't reach the context URLs unless allowed.
+	DefaultPolicy = PolicyCacheOnly
+)
+
+const storeName = "jsonld-context"
+
+var logger = log.New("hub-router/ldcontext")
+
+// ErrContextNotFound is returned for the contexts neither embedded, bundled nor cached, that the policy doesn't allow
+// to fetch.
+var ErrContextNotFound = errors.New("JSON-LD context not found")
+
+// Config of the context loading.
+type Config struct {
+	// Policy of the remote loading, DefaultPolicy if empty.
+	Policy string
+	// Bundle is the offline bundle : the contexts loaded in addition to the embedded ones, eg: read with ReadBundle.
+	Bundle []jsonld.ContextDocument
+	// Client fetches the remote contexts, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+// Loader is a JSON-LD document loader of the contexts, following the remote loading policy.
+type Loader struct {
+	local  *jsonld.DocumentLoader
+	cache  storage.Store
+	remote ld.DocumentLoader
+	policy string
+}
+
+// ValidPolicy returns true if the remote loading policy is supported.
+func ValidPolicy(policy string) bool {
+	return policy == PolicyAllow || policy == PolicyCacheOnly || policy == PolicyDeny
+}
+
+// New returns a new Loader caching the remote contexts in the storage, with the config.
+func New(p storage.Provider, cfg *Config) (*Loader, error) {
+	if cfg == nil {
+		cfg = &Config{}
+	}
+
+	policy := cfg.Policy
+	if policy == "" {
+		policy = DefaultPolicy
+	}
+
+	if !ValidPolicy(policy) {
+		return nil, fmt.Errorf("invalid JSON-LD context loading policy : %s", policy)
+	}
+
+	// the embedded and bundled contexts are loaded in memory, so that they always take precedence over the cache
+	local, err := jsonld.NewDocumentLoader(mem.NewProvider(), jsonld.WithExtraContexts(cfg.Bundle...))
+	if err != nil {
+		return nil, fmt.Errorf("load JSON-LD contexts : %w", err)
+	}
+
+	cache, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open JSON-LD context store : %w", err)
+	}
+
+	return &Loader{local: local, cache: cache, remote: ld.NewDefaultDocumentLoader(cfg.Client), policy: policy}, nil
+}
+
+// LoadDocument returns the context with the URL : embedded or bundled, else cached, else fetched and cached if the
+// policy allows it.
+func (l *Loader) LoadDocument(u string) (*ld.RemoteDocument, error) {
+	doc, err := l.local.LoadDocument(u)
+	if !errors.Is(err, jsonld.ErrContextNotFound) {
+		return doc, err
+	}
+
+	if l.policy == PolicyDeny {
+		return nil, fmt.Errorf("%w : %s (remote loading denied)", ErrContextNotFound, u)
+	}
+
+	b, err := l.cache.Get(u)
+	if err == nil {
+		doc = &ld.RemoteDocument{}
+		if err = json.Unmarshal(b, doc); err != nil {
+			return nil, fmt.Errorf("unmarshal cached JSON-LD context %s : %w", u, err)
+		}
+
+		return doc, nil
+	}
+
+	if !errors.Is(err, storage.ErrDataNotFound) {
+		return nil, fmt.Errorf("get cached JSON-LD context %s : %w", u, err)
+	}
+
+	if l.policy == PolicyCacheOnly {
+		return nil, fmt.Errorf("%w : %s (not cached)", ErrContextNotFound, u)
+	}
+
+	return l.fetch(u)
+}
+
+// fetch fetches the context from its URL, and caches it.
+func (l *Loader) fetch(u string) (*ld.RemoteDocument, error) {
+	doc, err := l.remote.LoadDocument(u)
+	if err != nil {
+		return nil, fmt.Errorf("fetch JSON-LD context %s : %w", u, err)
+	}
+
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("marshal JSON-LD context %s : %w", u, err)
+	}
+
+	if err = l.cache.Put(u, b); err != nil {
+		return nil, fmt.Errorf("cache JSON-LD context %s : %w", u, err)
+	}
+
+	logger.Infof("JSON-LD context %s fetched and cached", u)
+
+	return doc, nil
+}
+
+// ReadBundle reads the offline bundle file : a JSON array of the contexts, each with its 'url', the 'documentURL' it
+// was loaded from (defaults to the url) and its 'content'.
+func ReadBundle(path string) ([]jsonld.ContextDocument, error) {
+	b, err := ioutil.ReadFile(path) // nolint:gosec // path set by the operator
+	if err != nil {
+		return nil, fmt.Errorf("read JSON-LD context bundle : %w", err)
+	}
+
+	var contexts []jsonld.ContextDocument
+
+	if err = json.Unmarshal(b, &contexts); err != nil {
+		return nil, fmt.Errorf("unmarshal JSON-LD context bundle : %w", err)
+	}
+
+	for i, c := range contexts {
+		if c.URL == "" || len(c.Content) == 0 {
+			return nil, fmt.Errorf("invalid JSON-LD context bundle : context %d requires the url and content", i)
+		}
+
+		if c.DocumentURL == "" {
+			contexts[i].DocumentURL = c.URL
+		}
+	}
+
+	return contexts, nil
+}
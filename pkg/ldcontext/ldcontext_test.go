@@ -0,0 +1,224 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ldcontext
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/jsonld"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	didContext    = "https://www.w3.org/ns/did/v1"
+	bundleContext = "https://example.com/contexts/bundle/v1"
+)
+
+func newContextServer(t *testing.T) (*httptest.Server, *int) {
+	t.Helper()
+
+	fetches := 0
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+
+		w.Header().Set("Content-Type", "application/ld+json")
+		_, err := w.Write([]byte(`{"@context":{"name":"https://schema.org/name"}}`))
+		require.NoError(t, err)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, &fetches
+}
+
+func TestLoader(t *testing.T) {
+	bundle := []jsonld.ContextDocument{{
+		URL: bundleContext, DocumentURL: bundleContext, Content: json.RawMessage(`{"@context":{"id":"@id"}}`),
+	}}
+
+	t.Run("allow", func(t *testing.T) {
+		srv, fetches := newContextServer(t)
+
+		l, err := New(mem.NewProvider(), &Config{Policy: PolicyAllow, Bundle: bundle})
+		require.NoError(t, err)
+
+		doc, err := l.LoadDocument(didContext)
+		require.NoError(t, err)
+		require.Equal(t, didContext, doc.DocumentURL)
+
+		doc, err = l.LoadDocument(bundleContext)
+		require.NoError(t, err)
+		require.Equal(t, bundleContext, doc.DocumentURL)
+
+		doc, err = l.LoadDocument(srv.URL + "/v1")
+		require.NoError(t, err)
+		require.NotNil(t, doc.Document)
+
+		// cached
+		_, err = l.LoadDocument(srv.URL + "/v1")
+		require.NoError(t, err)
+		require.Equal(t, 1, *fetches)
+	})
+
+	t.Run("cache only", func(t *testing.T) {
+		srv, fetches := newContextServer(t)
+		p := mem.NewProvider()
+
+		l, err := New(p, nil)
+		require.NoError(t, err)
+		require.Equal(t, DefaultPolicy, l.policy)
+
+		_, err = l.LoadDocument(srv.URL + "/v1")
+		require.True(t, errors.Is(err, ErrContextNotFound))
+		require.Contains(t, err.Error(), "not cached")
+
+		_, err = l.LoadDocument(didContext)
+		require.NoError(t, err)
+
+		allow, err := New(p, &Config{Policy: PolicyAllow})
+		require.NoError(t, err)
+
+		_, err = allow.LoadDocument(srv.URL + "/v1")
+		require.NoError(t, err)
+
+		_, err = l.LoadDocument(srv.URL + "/v1")
+		require.NoError(t, err)
+		require.Equal(t, 1, *fetches)
+	})
+
+	t.Run("deny", func(t *testing.T) {
+		srv, fetches := newContextServer(t)
+		p := mem.NewProvider()
+
+		allow, err := New(p, &Config{Policy: PolicyAllow})
+		require.NoError(t, err)
+
+		_, err = allow.LoadDocument(srv.URL + "/v1")
+		require.NoError(t, err)
+
+		l, err := New(p, &Config{Policy: PolicyDeny, Bundle: bundle})
+		require.NoError(t, err)
+
+		_, err = l.LoadDocument(srv.URL + "/v1")
+		require.True(t, errors.Is(err, ErrContextNotFound))
+		require.Contains(t, err.Error(), "remote loading denied")
+
+		_, err = l.LoadDocument(bundleContext)
+		require.NoError(t, err)
+		require.Equal(t, 1, *fetches)
+	})
+
+	t.Run("fetch error", func(t *testing.T) {
+		l, err := New(mem.NewProvider(), &Config{Policy: PolicyAllow})
+		require.NoError(t, err)
+
+		_, err = l.LoadDocument("http://localhost:-1/v1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "fetch JSON-LD context")
+	})
+
+	t.Run("cache errors", func(t *testing.T) {
+		srv, _ := newContextServer(t)
+
+		p := mockstore.NewMockStoreProvider()
+		p.Store.ErrPut = errors.New("put error")
+
+		l, err := New(p, &Config{Policy: PolicyAllow})
+		require.NoError(t, err)
+
+		_, err = l.LoadDocument(srv.URL + "/v1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "cache JSON-LD context")
+
+		p = mockstore.NewMockStoreProvider()
+		p.Store.ErrGet = errors.New("get error")
+
+		l, err = New(p, nil)
+		require.NoError(t, err)
+
+		_, err = l.LoadDocument(srv.URL + "/v1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get cached JSON-LD context")
+
+		p = mockstore.NewMockStoreProvider()
+		require.NoError(t, p.Store.Put(srv.URL+"/v1", []byte("{")))
+
+		l, err = New(p, nil)
+		require.NoError(t, err)
+
+		_, err = l.LoadDocument(srv.URL + "/v1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal cached JSON-LD context")
+	})
+
+	t.Run("new errors", func(t *testing.T) {
+		_, err := New(mem.NewProvider(), &Config{Policy: "sometimes"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid JSON-LD context loading policy")
+
+		_, err = New(mem.NewProvider(), &Config{Bundle: []jsonld.ContextDocument{
+			{URL: bundleContext, Content: json.RawMessage(`[`)},
+		}})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "load JSON-LD contexts")
+
+		p := mockstore.NewMockStoreProvider()
+		p.ErrOpenStoreHandle = errors.New("open error")
+
+		_, err = New(p, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open JSON-LD context store")
+	})
+}
+
+func TestReadBundle(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0o600))
+
+		return path
+	}
+
+	t.Run("success", func(t *testing.T) {
+		contexts, err := ReadBundle(write("bundle.json",
+			`[{"url":"`+bundleContext+`","content":{"@context":{"id":"@id"}}}]`))
+		require.NoError(t, err)
+		require.Len(t, contexts, 1)
+		require.Equal(t, bundleContext, contexts[0].DocumentURL)
+
+		l, err := New(mem.NewProvider(), &Config{Policy: PolicyDeny, Bundle: contexts})
+		require.NoError(t, err)
+
+		_, err = l.LoadDocument(bundleContext)
+		require.NoError(t, err)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := ReadBundle(filepath.Join(dir, "missing.json"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "read JSON-LD context bundle")
+
+		_, err = ReadBundle(write("invalid.json", `{}`))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal JSON-LD context bundle")
+
+		_, err = ReadBundle(write("no-content.json", `[{"url":"`+bundleContext+`"}]`))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "context 0 requires the url and content")
+	})
+}
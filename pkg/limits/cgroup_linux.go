@@ -0,0 +1,11 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package limits
+
+// cgroupRoot is the mount point of the cgroup hierarchy, read for the container limits.
+// nolint:gochecknoglobals // overridden by the tests
+var cgroupRoot = "/sys/fs/cgroup"
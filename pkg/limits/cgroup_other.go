@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package limits
+
+// cgroupRoot is empty on the platforms without cgroups, eg: Windows : the limits set to auto are not set.
+// nolint:gochecknoglobals // overridden by the tests
+var cgroupRoot = ""
@@ -0,0 +1,208 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package limits
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHandshakeTimeout is the time after which a handshake not completed no longer counts towards the limits.
+	DefaultHandshakeTimeout = 2 * time.Minute
+	// DefaultHandshakeQueueTimeout is the time a queued handshake waits for a slot before being rejected.
+	DefaultHandshakeQueueTimeout = 10 * time.Second
+)
+
+type handshake struct {
+	source string
+	opened time.Time
+}
+
+// Handshakes limits the number of DID exchanges in progress, from the request to the completion, in total and per
+// source. The handshakes over the limits wait for a slot in a bounded queue, and are rejected if the queue is full or
+// no slot is released within the queue timeout. The handshakes not completed within the timeout are dropped, as they
+// may have been abandoned.
+type Handshakes struct {
+	max          int
+	maxPerSource int
+	queue        int
+	queueTimeout time.Duration
+	timeout      time.Duration
+	mutex        sync.Mutex
+	open         map[string]handshake
+	sources      map[string]int
+	queued       int
+	released     chan struct{}
+	rejected     uint64
+	expired      uint64
+	now          func() time.Time
+}
+
+// NewHandshakes returns a new Handshakes limit, nil if the handshakes aren't limited.
+func NewHandshakes(config *Config) *Handshakes {
+	if config == nil || (config.MaxHandshakes <= 0 && config.MaxHandshakesPerSource <= 0) {
+		return nil
+	}
+
+	h := &Handshakes{
+		max:          config.MaxHandshakes,
+		maxPerSource: config.MaxHandshakesPerSource,
+		queue:        config.HandshakeQueue,
+		queueTimeout: config.HandshakeQueueTimeout,
+		timeout:      config.HandshakeTimeout,
+		open:         make(map[string]handshake),
+		sources:      make(map[string]int),
+		released:     make(chan struct{}),
+		now:          time.Now,
+	}
+
+	if h.queueTimeout <= 0 {
+		h.queueTimeout = DefaultHandshakeQueueTimeout
+	}
+
+	if h.timeout <= 0 {
+		h.timeout = DefaultHandshakeTimeout
+	}
+
+	return h
+}
+
+// Queueing returns true if the handshakes over the limits are queued, ie: Open may block.
+func (h *Handshakes) Queueing() bool {
+	return h != nil && h.queue > 0
+}
+
+// Open counts the handshake of the connection from the source, waiting for a slot if the limits are reached, or
+// returns an error wrapping ErrLimitReached if the queue is full or the queue timeout expires.
+func (h *Handshakes) Open(connectionID, source string) error {
+	h.mutex.Lock()
+
+	if h.admit(connectionID, source) {
+		h.mutex.Unlock()
+
+		return nil
+	}
+
+	if h.queued >= h.queue {
+		err := h.reject(source)
+		h.mutex.Unlock()
+
+		return err
+	}
+
+	h.queued++
+
+	deadline := time.NewTimer(h.queueTimeout)
+	defer deadline.Stop()
+
+	for {
+		released := h.released
+		h.mutex.Unlock()
+
+		select {
+		case <-released:
+		case <-deadline.C:
+			h.mutex.Lock()
+			h.queued--
+			err := h.reject(source)
+			h.mutex.Unlock()
+
+			return err
+		}
+
+		h.mutex.Lock()
+
+		if h.admit(connectionID, source) {
+			h.queued--
+			h.mutex.Unlock()
+
+			return nil
+		}
+	}
+}
+
+// Close releases the handshake of the connection, once completed.
+func (h *Handshakes) Close(connectionID string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.open[connectionID]; ok {
+		h.remove(connectionID)
+		h.release()
+	}
+}
+
+// Stats returns the handshakes in progress and queued.
+func (h *Handshakes) Stats() *Stats {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return &Stats{
+		Max: h.max, MaxPerSource: h.maxPerSource, Open: len(h.open), Queued: h.queued, Rejected: h.rejected,
+		Expired: h.expired,
+	}
+}
+
+// admit counts the handshake if it is within the limits, once the timed out handshakes are dropped.
+func (h *Handshakes) admit(connectionID, source string) bool {
+	now := h.now()
+
+	expired := false
+
+	for id, hs := range h.open {
+		if now.Sub(hs.opened) >= h.timeout {
+			h.remove(id)
+			h.expired++
+
+			expired = true
+		}
+	}
+
+	if expired {
+		h.release()
+	}
+
+	if _, ok := h.open[connectionID]; ok {
+		return true
+	}
+
+	if (h.max > 0 && len(h.open) >= h.max) || (h.maxPerSource > 0 && h.sources[source] >= h.maxPerSource) {
+		return false
+	}
+
+	h.open[connectionID] = handshake{source: source, opened: now}
+	h.sources[source]++
+
+	return true
+}
+
+func (h *Handshakes) remove(connectionID string) {
+	source := h.open[connectionID].source
+
+	delete(h.open, connectionID)
+
+	h.sources[source]--
+	if h.sources[source] <= 0 {
+		delete(h.sources, source)
+	}
+}
+
+// release wakes up the queued handshakes.
+func (h *Handshakes) release() {
+	close(h.released)
+	h.released = make(chan struct{})
+}
+
+func (h *Handshakes) reject(source string) error {
+	h.rejected++
+
+	logger.Warnf("handshake rejected : source=%s open=%d queued=%d", source, len(h.open), h.queued)
+
+	return fmt.Errorf("%w : %d handshakes in progress, %d queued", ErrLimitReached, len(h.open), h.queued)
+}
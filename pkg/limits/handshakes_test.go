@@ -0,0 +1,111 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package limits
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandshakes(t *testing.T) {
+	require.Nil(t, NewHandshakes(nil))
+	require.Nil(t, NewHandshakes(&Config{MaxPickups: 10}))
+	require.False(t, NewHandshakes(nil).Queueing())
+
+	t.Run("global limit", func(t *testing.T) {
+		h := NewHandshakes(&Config{MaxHandshakes: 2})
+		require.Equal(t, DefaultHandshakeTimeout, h.timeout)
+		require.Equal(t, DefaultHandshakeQueueTimeout, h.queueTimeout)
+		require.False(t, h.Queueing())
+
+		require.NoError(t, h.Open("conn-1", "inv-1"))
+		require.NoError(t, h.Open("conn-2", "inv-2"))
+		// already counted
+		require.NoError(t, h.Open("conn-2", "inv-2"))
+
+		err := h.Open("conn-3", "inv-3")
+		require.ErrorIs(t, err, ErrLimitReached)
+		require.Equal(t, &Stats{Max: 2, Open: 2, Rejected: 1}, h.Stats())
+
+		h.Close("conn-1")
+		h.Close("unknown")
+		require.NoError(t, h.Open("conn-3", "inv-3"))
+	})
+
+	t.Run("per source limit", func(t *testing.T) {
+		h := NewHandshakes(&Config{MaxHandshakesPerSource: 1})
+
+		require.NoError(t, h.Open("conn-1", "inv-1"))
+		require.NoError(t, h.Open("conn-2", "inv-2"))
+		require.ErrorIs(t, h.Open("conn-3", "inv-1"), ErrLimitReached)
+
+		h.Close("conn-1")
+		require.NoError(t, h.Open("conn-3", "inv-1"))
+		require.Equal(t, &Stats{MaxPerSource: 1, Open: 2, Rejected: 1}, h.Stats())
+		require.Len(t, h.sources, 2)
+	})
+
+	t.Run("queued until released", func(t *testing.T) {
+		h := NewHandshakes(&Config{MaxHandshakes: 1, HandshakeQueue: 1, HandshakeQueueTimeout: time.Minute})
+		require.True(t, h.Queueing())
+
+		require.NoError(t, h.Open("conn-1", "inv-1"))
+
+		opened := make(chan error)
+
+		go func() {
+			opened <- h.Open("conn-2", "inv-1")
+		}()
+
+		require.Eventually(t, func() bool {
+			return h.Stats().Queued == 1
+		}, time.Second, time.Millisecond)
+
+		// the queue is full
+		require.ErrorIs(t, h.Open("conn-3", "inv-1"), ErrLimitReached)
+
+		h.Close("conn-1")
+
+		select {
+		case err := <-opened:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+
+		require.Equal(t, &Stats{Max: 1, Open: 1, Rejected: 1}, h.Stats())
+	})
+
+	t.Run("queue timeout", func(t *testing.T) {
+		h := NewHandshakes(&Config{
+			MaxHandshakes: 1, MaxHandshakesPerSource: 1, HandshakeQueue: 10, HandshakeQueueTimeout: 10 * time.Millisecond,
+		})
+
+		require.NoError(t, h.Open("conn-1", "inv-1"))
+
+		go h.Close("conn-other")
+
+		err := h.Open("conn-2", "inv-1")
+		require.ErrorIs(t, err, ErrLimitReached)
+		require.Equal(t, &Stats{Max: 1, MaxPerSource: 1, Open: 1, Rejected: 1}, h.Stats())
+	})
+
+	t.Run("abandoned handshakes time out", func(t *testing.T) {
+		h := NewHandshakes(&Config{MaxHandshakes: 2, HandshakeTimeout: time.Minute})
+
+		require.NoError(t, h.Open("conn-1", "inv-1"))
+		require.NoError(t, h.Open("conn-2", "inv-1"))
+
+		h.now = func() time.Time { return time.Now().Add(time.Minute) }
+
+		require.NoError(t, h.Open("conn-3", "inv-1"))
+		require.Equal(t, &Stats{Max: 2, Open: 1, Expired: 2}, h.Stats())
+		require.Equal(t, 1, h.sources["inv-1"])
+	})
+}
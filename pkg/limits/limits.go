@@ -0,0 +1,128 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/preparse"
+)
+
+// ErrLimitReached is returned when a handshake or a pickup is rejected as the router is at its limit.
+var ErrLimitReached = errors.New("router at its limit")
+
+var logger = log.New("hub-router/limits")
+
+// Config of the router limits.
+type Config struct {
+	// MaxProcs is the GOMAXPROCS value, unchanged if zero.
+	MaxProcs int
+	// MemoryLimit is the soft limit of the heap, in bytes, unlimited if zero.
+	MemoryLimit uint64
+	// MaxHandshakes is the number of DID exchanges in progress from which new ones are queued, unlimited if zero.
+	MaxHandshakes int
+	// MaxHandshakesPerSource is the number of DID exchanges in progress from the same source (invitation) from which
+	// new ones are queued, unlimited if zero.
+	MaxHandshakesPerSource int
+	// HandshakeQueue is the number of DID exchanges waiting for a slot from which new ones are rejected, none if zero.
+	HandshakeQueue int
+	// HandshakeQueueTimeout is the time a DID exchange waits for a slot before being rejected,
+	// DefaultHandshakeQueueTimeout if zero.
+	HandshakeQueueTimeout time.Duration
+	// HandshakeTimeout is the time after which a DID exchange not completed no longer counts towards the limits,
+	// DefaultHandshakeTimeout if zero.
+	HandshakeTimeout time.Duration
+	// MaxPickups is the number of pickup requests handled concurrently from which new ones are rejected, unlimited
+	// if zero.
+	MaxPickups int
+}
+
+// Stats of a limit.
+type Stats struct {
+	Max          int    `json:"max"`
+	MaxPerSource int    `json:"maxPerSource,omitempty"`
+	Open         int    `json:"open"`
+	Queued       int    `json:"queued,omitempty"`
+	Rejected     uint64 `json:"rejected"`
+	Expired      uint64 `json:"expired,omitempty"`
+}
+
+// Pickups limits the number of pickup requests (batch pickups and status requests) handled concurrently.
+type Pickups struct {
+	max      int
+	mutex    sync.Mutex
+	open     int
+	rejected uint64
+}
+
+// NewPickups returns a new Pickups limit, nil if max is zero.
+func NewPickups(max int) *Pickups {
+	if max <= 0 {
+		return nil
+	}
+
+	return &Pickups{max: max}
+}
+
+// AcquireEnvelope counts the envelope with the header if it is a pickup request, or returns an error wrapping
+// ErrLimitReached. The release function is called once the envelope is handled.
+func (p *Pickups) AcquireEnvelope(header *preparse.Header) (func(), error) {
+	if !isPickup(string(header.Type)) {
+		return func() {}, nil
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.open >= p.max {
+		p.rejected++
+
+		logger.Warnf("pickup rejected : %d pickups in progress", p.open)
+
+		return nil, fmt.Errorf("%w : %d pickups in progress", ErrLimitReached, p.open)
+	}
+
+	p.open++
+
+	return func() {
+		p.mutex.Lock()
+		p.open--
+		p.mutex.Unlock()
+	}, nil
+}
+
+// HandleEnvelope hands the envelope on to next, unless it is a pickup request over the limit.
+func (p *Pickups) HandleEnvelope(envelope *transport.Envelope, header *preparse.Header,
+	next transport.InboundMessageHandler) error {
+	release, err := p.AcquireEnvelope(header)
+	if err != nil {
+		return err
+	}
+
+	defer release()
+
+	return next(envelope)
+}
+
+// Stats returns the pickups in progress.
+func (p *Pickups) Stats() *Stats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return &Stats{Max: p.max, Open: p.open, Rejected: p.rejected}
+}
+
+func isPickup(msgType string) bool {
+	return msgType == messagepickup.BatchPickupMsgType || msgType == messagepickup.StatusRequestMsgType
+}
@@ -0,0 +1,80 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package limits
+
+import (
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/preparse"
+)
+
+func TestPickups(t *testing.T) {
+	require.Nil(t, NewPickups(0))
+
+	p := NewPickups(1)
+
+	pickup := &preparse.Header{Type: []byte("https://didcomm.org/messagepickup/1.0/batch-pickup")}
+
+	release, err := p.AcquireEnvelope(pickup)
+	require.NoError(t, err)
+
+	_, err = p.AcquireEnvelope(pickup)
+	require.ErrorIs(t, err, ErrLimitReached)
+
+	// the other messages aren't limited
+	for _, header := range []*preparse.Header{{Type: []byte("https://didcomm.org/routing/1.0/forward")}, {}} {
+		other, err := p.AcquireEnvelope(header)
+		require.NoError(t, err)
+
+		other()
+	}
+
+	require.Equal(t, &Stats{Max: 1, Open: 1, Rejected: 1}, p.Stats())
+
+	release()
+
+	release, err = p.AcquireEnvelope(&preparse.Header{
+		Type: []byte("https://didcomm.org/messagepickup/1.0/status-request"),
+	})
+	require.NoError(t, err)
+
+	release()
+	require.Zero(t, p.Stats().Open)
+
+	t.Run("handle envelope", func(t *testing.T) {
+		handled := 0
+
+		err := p.HandleEnvelope(&transport.Envelope{}, pickup, func(*transport.Envelope) error {
+			handled++
+
+			// the pickup is counted while it is handled
+			_, err := p.AcquireEnvelope(pickup)
+			require.ErrorIs(t, err, ErrLimitReached)
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, handled)
+		require.Zero(t, p.Stats().Open)
+
+		release, err := p.AcquireEnvelope(pickup)
+		require.NoError(t, err)
+
+		defer release()
+
+		err = p.HandleEnvelope(&transport.Envelope{}, pickup, func(*transport.Envelope) error {
+			handled++
+
+			return nil
+		})
+		require.ErrorIs(t, err, ErrLimitReached)
+		require.Equal(t, 1, handled)
+	})
+}
@@ -0,0 +1,302 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package limits
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Auto sets the limit from the cgroup (container) limits.
+	Auto = "auto"
+
+	// autoMemoryPercent is the share of the cgroup memory limit used as the heap limit, leaving room for the
+	// non-heap memory.
+	autoMemoryPercent = 90
+	defaultGCPercent  = 100
+	minGCPercent      = 10
+	governorInterval  = 2 * time.Second
+)
+
+// nolint:gochecknoglobals // binary units
+var memoryUnits = map[string]uint64{
+	"":    1,
+	"B":   1,
+	"KiB": 1 << 10,
+	"MiB": 1 << 20,
+	"GiB": 1 << 30,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+}
+
+// errNoCgroupLimit is returned by the cgroup limit readers when no limit is set.
+var errNoCgroupLimit = errors.New("no cgroup limit")
+
+// ParseMaxProcs parses a GOMAXPROCS value : a positive number, or auto for the CPU quota of the cgroup rounded up,
+// zero if the cgroup has no CPU quota.
+func ParseMaxProcs(value string) (int, error) {
+	if value == Auto {
+		cpus, err := cgroupCPUs()
+		if errors.Is(err, errNoCgroupLimit) {
+			return 0, nil
+		}
+
+		return cpus, err
+	}
+
+	procs, err := strconv.Atoi(value)
+	if err != nil || procs < 0 {
+		return 0, fmt.Errorf("invalid max procs %s", value)
+	}
+
+	return procs, nil
+}
+
+// ParseMemoryLimit parses a memory limit : a number of bytes with an optional unit (eg: 512MiB, 2GB), or auto for
+// 90% of the memory limit of the cgroup, zero if the cgroup has no memory limit.
+func ParseMemoryLimit(value string) (uint64, error) {
+	if value == Auto {
+		limit, err := cgroupMemory()
+		if errors.Is(err, errNoCgroupLimit) {
+			return 0, nil
+		}
+
+		return limit / 100 * autoMemoryPercent, err
+	}
+
+	i := strings.IndexFunc(value, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+	if i < 0 {
+		i = len(value)
+	}
+
+	unit, ok := memoryUnits[value[i:]]
+
+	n, err := strconv.ParseFloat(value[:i], 64)
+	if err != nil || !ok || n < 0 {
+		return 0, fmt.Errorf("invalid memory limit %s", value)
+	}
+
+	return uint64(n * float64(unit)), nil
+}
+
+// cgroupCPUs returns the CPU quota of the cgroup (v2, or v1), rounded up.
+func cgroupCPUs() (int, error) {
+	var quota, period float64
+
+	values, err := readCgroup("cpu.max")
+	if err != nil && !errors.Is(err, errNoCgroupLimit) {
+		return 0, err
+	}
+
+	if len(values) == 2 { // nolint:gomnd // cgroup v2 : quota period
+		if values[0] == "max" {
+			return 0, errNoCgroupLimit
+		}
+
+		quota, err = strconv.ParseFloat(values[0], 64)
+		if err == nil {
+			period, err = strconv.ParseFloat(values[1], 64)
+		}
+	} else {
+		quota, period, err = cgroupV1CPUQuota()
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	if quota <= 0 || period <= 0 {
+		return 0, errNoCgroupLimit
+	}
+
+	return int(math.Ceil(quota / period)), nil
+}
+
+func cgroupV1CPUQuota() (float64, float64, error) {
+	quota, err := readCgroupNumber(filepath.Join("cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	period, err := readCgroupNumber(filepath.Join("cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return quota, period, nil
+}
+
+// cgroupMemory returns the memory limit of the cgroup (v2, or v1).
+func cgroupMemory() (uint64, error) {
+	name := "memory.max"
+	if _, err := os.Stat(filepath.Join(cgroupRoot, name)); os.IsNotExist(err) {
+		name = filepath.Join("memory", "memory.limit_in_bytes")
+	}
+
+	limit, err := readCgroupNumber(name)
+	if err != nil {
+		return 0, err
+	}
+
+	// cgroup v1 reports the unlimited memory as a huge number
+	if limit <= 0 || limit >= math.MaxInt64/2 {
+		return 0, errNoCgroupLimit
+	}
+
+	return uint64(limit), nil
+}
+
+func readCgroup(name string) ([]string, error) {
+	// the platforms without cgroups have no container limits
+	if cgroupRoot == "" {
+		return nil, errNoCgroupLimit
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(cgroupRoot, name)) // nolint:gosec // cgroup file
+	if os.IsNotExist(err) {
+		return nil, errNoCgroupLimit
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read cgroup %s : %w", name, err)
+	}
+
+	return strings.Fields(string(b)), nil
+}
+
+func readCgroupNumber(name string) (float64, error) {
+	values, err := readCgroup(name)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(values) != 1 {
+		return 0, fmt.Errorf("invalid cgroup %s", name)
+	}
+
+	if values[0] == "max" {
+		return 0, errNoCgroupLimit
+	}
+
+	n, err := strconv.ParseFloat(values[0], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cgroup %s : %w", name, err)
+	}
+
+	return n, nil
+}
+
+// Governor keeps the heap under a soft limit by lowering the GC target as the heap grows towards it, so that the
+// heap goal never exceeds the limit, and by forcing a collection once it is reached.
+type Governor struct {
+	limit     uint64
+	gcPercent int
+	stop      chan struct{}
+	once      sync.Once
+	setGC     func(int) int
+	freeOS    func()
+}
+
+// Apply sets GOMAXPROCS and starts the memory governor, if configured. The returned governor is nil if there is no
+// memory limit.
+func Apply(config *Config) *Governor {
+	if config == nil {
+		return nil
+	}
+
+	if config.MaxProcs > 0 {
+		previous := runtime.GOMAXPROCS(config.MaxProcs)
+
+		logger.Infof("GOMAXPROCS set to %d (was %d)", config.MaxProcs, previous)
+	}
+
+	if config.MemoryLimit == 0 {
+		return nil
+	}
+
+	g := newGovernor(config.MemoryLimit)
+
+	go g.run(governorInterval)
+
+	logger.Infof("memory limit set to %d bytes", config.MemoryLimit)
+
+	return g
+}
+
+func newGovernor(limit uint64) *Governor {
+	return &Governor{
+		limit:     limit,
+		gcPercent: defaultGCPercent,
+		stop:      make(chan struct{}),
+		setGC:     debug.SetGCPercent,
+		freeOS:    debug.FreeOSMemory,
+	}
+}
+
+// Stop stops the governor.
+func (g *Governor) Stop() {
+	g.once.Do(func() { close(g.stop) })
+}
+
+func (g *Governor) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			var stats runtime.MemStats
+
+			runtime.ReadMemStats(&stats)
+
+			g.check(stats.HeapAlloc)
+		case <-g.stop:
+			g.setGC(defaultGCPercent)
+
+			return
+		}
+	}
+}
+
+// check sets the GC percent so that the next heap goal stays under the limit.
+func (g *Governor) check(heap uint64) {
+	if heap >= g.limit {
+		logger.Warnf("heap above its limit : heap=%d limit=%d", heap, g.limit)
+
+		g.freeOS()
+	}
+
+	percent := defaultGCPercent
+	if heap > 0 && heap < g.limit {
+		percent = int((g.limit - heap) * 100 / heap) // nolint:gomnd // percent
+	}
+
+	if heap >= g.limit || percent < minGCPercent {
+		percent = minGCPercent
+	}
+
+	if percent > defaultGCPercent {
+		percent = defaultGCPercent
+	}
+
+	if percent != g.gcPercent {
+		g.gcPercent = percent
+		g.setGC(percent)
+	}
+}
@@ -0,0 +1,218 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package limits
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func withCgroup(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o700))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0o600))
+	}
+
+	root := cgroupRoot
+	cgroupRoot = dir
+
+	t.Cleanup(func() {
+		cgroupRoot = root
+		require.NoError(t, os.RemoveAll(dir))
+	})
+}
+
+func TestParseMaxProcs(t *testing.T) {
+	procs, err := ParseMaxProcs("4")
+	require.NoError(t, err)
+	require.Equal(t, 4, procs)
+
+	for _, value := range []string{"-1", "many"} {
+		_, err = ParseMaxProcs(value)
+		require.EqualError(t, err, "invalid max procs "+value)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		files    map[string]string
+		expected int
+	}{
+		{name: "cgroup v2", files: map[string]string{"cpu.max": "150000 100000\n"}, expected: 2},
+		{name: "cgroup v2 unlimited", files: map[string]string{"cpu.max": "max 100000\n"}, expected: 0},
+		{name: "cgroup v1", files: map[string]string{
+			"cpu/cpu.cfs_quota_us": "400000\n", "cpu/cpu.cfs_period_us": "100000\n",
+		}, expected: 4},
+		{name: "cgroup v1 unlimited", files: map[string]string{
+			"cpu/cpu.cfs_quota_us": "-1\n", "cpu/cpu.cfs_period_us": "100000\n",
+		}, expected: 0},
+		{name: "no cgroup", expected: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withCgroup(t, tc.files)
+
+			procs, err := ParseMaxProcs(Auto)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, procs)
+		})
+	}
+
+	t.Run("invalid cgroup", func(t *testing.T) {
+		for _, files := range []map[string]string{
+			{"cpu.max": "many 100000"},
+			{"cpu.max": "100000 often"},
+			{"cpu/cpu.cfs_quota_us": "many", "cpu/cpu.cfs_period_us": "100000"},
+			{"cpu/cpu.cfs_quota_us": "100000", "cpu/cpu.cfs_period_us": "1 2"},
+		} {
+			withCgroup(t, files)
+
+			_, err := ParseMaxProcs(Auto)
+			require.Error(t, err)
+		}
+	})
+}
+
+func TestParseMemoryLimit(t *testing.T) {
+	for value, expected := range map[string]uint64{
+		"1048576": 1 << 20,
+		"512MiB":  512 << 20,
+		"1.5GiB":  3 << 29,
+		"2GB":     2e9,
+		"64KiB":   64 << 10,
+	} {
+		limit, err := ParseMemoryLimit(value)
+		require.NoError(t, err)
+		require.Equal(t, expected, limit, value)
+	}
+
+	for _, value := range []string{"512TB", "lots", "-1MiB", ""} {
+		_, err := ParseMemoryLimit(value)
+		require.EqualError(t, err, "invalid memory limit "+value)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		files    map[string]string
+		expected uint64
+	}{
+		{name: "cgroup v2", files: map[string]string{"memory.max": "1073741824\n"}, expected: 1073741824 / 100 * 90},
+		{name: "cgroup v2 unlimited", files: map[string]string{"memory.max": "max\n"}, expected: 0},
+		{name: "cgroup v1", files: map[string]string{"memory/memory.limit_in_bytes": "2000\n"}, expected: 1800},
+		{name: "cgroup v1 unlimited", files: map[string]string{
+			"memory/memory.limit_in_bytes": "9223372036854771712\n",
+		}, expected: 0},
+		{name: "no cgroup", expected: 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			withCgroup(t, tc.files)
+
+			limit, err := ParseMemoryLimit(Auto)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, limit)
+		})
+	}
+
+	t.Run("invalid cgroup", func(t *testing.T) {
+		withCgroup(t, map[string]string{"memory.max": "lots"})
+
+		_, err := ParseMemoryLimit(Auto)
+		require.Error(t, err)
+	})
+}
+
+func TestNoCgroups(t *testing.T) {
+	root := cgroupRoot
+	cgroupRoot = ""
+
+	t.Cleanup(func() { cgroupRoot = root })
+
+	procs, err := ParseMaxProcs(Auto)
+	require.NoError(t, err)
+	require.Zero(t, procs)
+
+	limit, err := ParseMemoryLimit(Auto)
+	require.NoError(t, err)
+	require.Zero(t, limit)
+}
+
+func TestApply(t *testing.T) {
+	require.Nil(t, Apply(nil))
+
+	procs := runtime.GOMAXPROCS(0)
+	defer runtime.GOMAXPROCS(procs)
+
+	require.Nil(t, Apply(&Config{MaxProcs: 1}))
+	require.Equal(t, 1, runtime.GOMAXPROCS(0))
+
+	g := Apply(&Config{MemoryLimit: 1 << 40})
+	require.NotNil(t, g)
+
+	g.Stop()
+	g.Stop()
+}
+
+func TestGovernor(t *testing.T) {
+	var percents []int
+
+	freed := 0
+
+	g := newGovernor(1000)
+	g.setGC = func(percent int) int {
+		percents = append(percents, percent)
+
+		return 0
+	}
+	g.freeOS = func() { freed++ }
+
+	// heap goal under the limit : unchanged
+	g.check(100)
+	g.check(400)
+	// the heap goal is lowered as the heap grows
+	g.check(800)
+	g.check(950)
+	// above the limit
+	g.check(1200)
+	g.check(0)
+
+	require.Equal(t, []int{25, 10, 100}, percents)
+	require.Equal(t, 1, freed)
+
+	t.Run("run", func(t *testing.T) {
+		g := newGovernor(1)
+		done := make(chan struct{})
+
+		var gc []int
+
+		g.setGC = func(percent int) int {
+			gc = append(gc, percent)
+
+			return 0
+		}
+		g.freeOS = func() {}
+
+		go func() {
+			g.run(time.Millisecond)
+			close(done)
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		g.Stop()
+		<-done
+
+		require.Equal(t, []int{minGCPercent, defaultGCPercent}, gc)
+	})
+}
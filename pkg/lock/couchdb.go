@@ -0,0 +1,220 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// DefaultLease is the time after which a CouchDB lock whose holder is lost is broken by the nodes waiting for it.
+	DefaultLease = time.Minute
+
+	// couchDBLocksName is the name of the database of the locks, after the database prefix.
+	couchDBLocksName = "locks"
+	// couchDBRetryInterval is the interval at which a held lock is tried again.
+	couchDBRetryInterval = 50 * time.Millisecond
+)
+
+// CouchDB locks across the nodes sharing a CouchDB server, with a document per lock held : a document is created only
+// once, the other nodes get a conflict until the holder deletes it. A CouchDB lock isn't released with the node
+// holding it, so a lock seen with the same revision for longer than the lease is broken : the lease is measured with
+// the clock of the node waiting for the lock, never compared with the clocks of the other nodes.
+type CouchDB struct {
+	client  *http.Client
+	db      string
+	timeout time.Duration
+	lease   time.Duration
+	// seen are the revisions of the locks held by other nodes, with the time they were first seen held.
+	seen      map[string]*heldLock
+	seenMutex sync.Mutex
+}
+
+type heldLock struct {
+	rev   string
+	since time.Time
+}
+
+// NewCouchDB returns a new CouchDB locker, creating the database of the locks if it doesn't exist. The hostURL is the
+// URL of the CouchDB server, with the credentials if required, eg: admin:secret@localhost:5984; the prefix is added to
+// the name of the database of the locks, so that the routers sharing a server don't share their locks.
+func NewCouchDB(hostURL, prefix string, timeout, lease time.Duration) (*CouchDB, error) {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+
+	if !strings.Contains(hostURL, "://") {
+		hostURL = "http://" + hostURL
+	}
+
+	l := &CouchDB{
+		client:  &http.Client{Timeout: timeout},
+		db:      strings.TrimSuffix(hostURL, "/") + "/" + url.PathEscape(strings.ToLower(prefix+couchDBLocksName)),
+		timeout: timeout,
+		lease:   lease,
+		seen:    map[string]*heldLock{},
+	}
+
+	status, err := l.do(http.MethodPut, l.db, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create lock database : %w", err)
+	}
+
+	// 412 : the database exists
+	if status != http.StatusCreated && status != http.StatusAccepted && status != http.StatusPreconditionFailed {
+		return nil, fmt.Errorf("create lock database : status %d", status)
+	}
+
+	return l, nil
+}
+
+// Lock locks the name, it returns ErrTimeout if the lock is still held by another node once the timeout elapsed.
+func (l *CouchDB) Lock(name string) (func(), error) {
+	doc := l.db + "/" + url.PathEscape(name)
+	holder, err := json.Marshal(map[string]string{"holder": uuid.New().String()})
+
+	if err != nil {
+		return nil, fmt.Errorf("lock %s : %w", name, err)
+	}
+
+	deadline := time.Now().Add(l.timeout)
+
+	for {
+		created := &struct {
+			Rev string `json:"rev"`
+		}{}
+
+		status, err := l.do(http.MethodPut, doc, holder, created)
+		if err != nil {
+			return nil, fmt.Errorf("lock %s : %w", name, err)
+		}
+
+		switch status {
+		case http.StatusCreated, http.StatusAccepted:
+			l.seenMutex.Lock()
+			delete(l.seen, name)
+			l.seenMutex.Unlock()
+
+			return func() {
+				l.release(name, doc, created.Rev)
+			}, nil
+		case http.StatusConflict:
+			l.breakExpired(name, doc)
+		default:
+			return nil, fmt.Errorf("lock %s : status %d", name, status)
+		}
+
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("lock %s : %w", name, ErrTimeout)
+		}
+
+		time.Sleep(couchDBRetryInterval)
+	}
+}
+
+// release deletes the document of the lock held.
+func (l *CouchDB) release(name, doc, rev string) {
+	status, err := l.do(http.MethodDelete, doc+"?rev="+url.QueryEscape(rev), nil, nil)
+	if err == nil && status != http.StatusOK && status != http.StatusAccepted {
+		err = fmt.Errorf("status %d", status)
+	}
+
+	if err != nil {
+		logger.Warnf("failed to release lock %s : %s", name, err)
+	}
+}
+
+// breakExpired deletes the document of the lock held by another node if it was seen with the same revision for
+// longer than the lease : its holder is lost. The revision deleted is the revision seen, so that a lock acquired
+// meanwhile isn't broken.
+func (l *CouchDB) breakExpired(name, doc string) {
+	held := &struct {
+		Rev string `json:"_rev"`
+	}{}
+
+	status, err := l.do(http.MethodGet, doc, nil, held)
+	if err != nil || status != http.StatusOK {
+		return
+	}
+
+	l.seenMutex.Lock()
+
+	seen, ok := l.seen[name]
+	if !ok || seen.rev != held.Rev {
+		seen = &heldLock{rev: held.Rev, since: time.Now()}
+		l.seen[name] = seen
+	}
+
+	expired := time.Since(seen.since) >= l.lease
+	if expired {
+		delete(l.seen, name)
+	}
+
+	l.seenMutex.Unlock()
+
+	if !expired {
+		return
+	}
+
+	logger.Warnf("breaking lock %s, held for longer than %s", name, l.lease)
+
+	l.release(name, doc, held.Rev)
+}
+
+// do sends the request, and decodes the response into result if set and the request succeeded.
+func (l *CouchDB) do(method, target string, body []byte, result interface{}) (int, error) {
+	var reader io.Reader
+
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequest(method, target, reader) // nolint:noctx // bounded by the client timeout
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			logger.Warnf("failed to close response body : %s", closeErr)
+		}
+	}()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+
+	if result != nil && resp.StatusCode < http.StatusMultipleChoices {
+		if err = json.Unmarshal(respBody, result); err != nil {
+			return 0, fmt.Errorf("unmarshal response : %w", err)
+		}
+	}
+
+	return resp.StatusCode, nil
+}
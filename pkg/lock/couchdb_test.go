@@ -0,0 +1,207 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lock
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// couchDB is a fake CouchDB server, a document is created once and deleted with its revision.
+type couchDB struct {
+	mutex sync.Mutex
+	dbs   map[string]bool
+	docs  map[string]string
+	revs  int
+	user  string
+}
+
+func newCouchDB(t *testing.T) (*couchDB, string) {
+	t.Helper()
+
+	c := &couchDB{dbs: map[string]bool{}, docs: map[string]string{}}
+	srv := httptest.NewServer(c)
+
+	t.Cleanup(srv.Close)
+
+	return c, strings.TrimPrefix(srv.URL, "http://")
+}
+
+func (c *couchDB) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if user, _, ok := req.BasicAuth(); ok {
+		c.user = user
+	}
+
+	path := strings.SplitN(strings.TrimPrefix(req.URL.EscapedPath(), "/"), "/", 2)
+
+	if len(path) == 1 {
+		if c.dbs[path[0]] {
+			rw.WriteHeader(http.StatusPreconditionFailed)
+
+			return
+		}
+
+		c.dbs[path[0]] = true
+		rw.WriteHeader(http.StatusCreated)
+
+		return
+	}
+
+	rev, held := c.docs[req.URL.Path]
+
+	switch req.Method {
+	case http.MethodPut:
+		if held {
+			rw.WriteHeader(http.StatusConflict)
+
+			return
+		}
+
+		c.revs++
+		c.docs[req.URL.Path] = fmt.Sprintf("%d-rev", c.revs)
+
+		rw.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(rw, `{"ok":true,"rev":"%s"}`, c.docs[req.URL.Path])
+	case http.MethodGet:
+		if !held {
+			rw.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		fmt.Fprintf(rw, `{"_rev":"%s"}`, rev)
+	case http.MethodDelete:
+		if !held || req.URL.Query().Get("rev") != rev {
+			rw.WriteHeader(http.StatusConflict)
+
+			return
+		}
+
+		delete(c.docs, req.URL.Path)
+	}
+}
+
+func (c *couchDB) held() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.docs)
+}
+
+func TestCouchDB(t *testing.T) {
+	t.Run("lock and release", func(t *testing.T) {
+		srv, hostURL := newCouchDB(t)
+
+		l, err := NewCouchDB("admin:secret@"+hostURL, "hr_", 100*time.Millisecond, 0)
+		require.NoError(t, err)
+		require.Equal(t, DefaultLease, l.lease)
+		require.True(t, srv.dbs["hr_locks"])
+		require.Equal(t, "admin", srv.user)
+
+		unlock, err := l.Lock("pickup-did:peer:1")
+		require.NoError(t, err)
+
+		_, err = l.Lock("pickup-did:peer:1")
+		require.ErrorIs(t, err, ErrTimeout)
+
+		unlockOther, err := l.Lock("pickup-did:peer:2")
+		require.NoError(t, err)
+		unlockOther()
+
+		unlock()
+		require.Zero(t, srv.held())
+
+		unlock, err = l.Lock("pickup-did:peer:1")
+		require.NoError(t, err)
+		unlock()
+
+		// the database exists
+		_, err = NewCouchDB("http://"+hostURL, "hr_", time.Second, time.Minute)
+		require.NoError(t, err)
+	})
+
+	t.Run("lock waits for the holder", func(t *testing.T) {
+		_, hostURL := newCouchDB(t)
+
+		l, err := NewCouchDB(hostURL, "", time.Second, 0)
+		require.NoError(t, err)
+
+		unlock, err := l.Lock("lease")
+		require.NoError(t, err)
+
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			unlock()
+		}()
+
+		unlock, err = l.Lock("lease")
+		require.NoError(t, err)
+		unlock()
+	})
+
+	t.Run("lock of a lost holder broken after the lease", func(t *testing.T) {
+		srv, hostURL := newCouchDB(t)
+
+		lost, err := NewCouchDB(hostURL, "", time.Second, 0)
+		require.NoError(t, err)
+
+		_, err = lost.Lock("lease")
+		require.NoError(t, err)
+
+		l, err := NewCouchDB(hostURL, "", 50*time.Millisecond, 200*time.Millisecond)
+		require.NoError(t, err)
+
+		_, err = l.Lock("lease")
+		require.ErrorIs(t, err, ErrTimeout)
+		require.Equal(t, 1, srv.held())
+
+		// the lease is measured from the first time the revision was seen, across the calls
+		time.Sleep(200 * time.Millisecond)
+
+		unlock, err := l.Lock("lease")
+		require.NoError(t, err)
+		unlock()
+	})
+
+	t.Run("server errors", func(t *testing.T) {
+		_, err := NewCouchDB("localhost:1", "", 100*time.Millisecond, 0)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create lock database")
+
+		srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			if req.URL.Path == "/locks" {
+				rw.WriteHeader(http.StatusCreated)
+
+				return
+			}
+
+			rw.WriteHeader(http.StatusUnauthorized)
+		}))
+		defer srv.Close()
+
+		l, err := NewCouchDB(srv.URL, "", time.Second, 0)
+		require.NoError(t, err)
+
+		_, err = l.Lock("lease")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "status 401")
+
+		_, err = NewCouchDB(srv.URL, "other", time.Second, 0)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "status 401")
+	})
+}
@@ -0,0 +1,167 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package lock serializes the read-modify-writes of the records shared by the nodes of the router : the storage has no
+// compare-and-swap, so a record is read from the storage, checked and written while the lock named after it is held,
+// which makes the write conditional on the stored record.
+package lock
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	// DefaultTimeout is the time Lock waits for a lock held by another node.
+	DefaultTimeout = 10 * time.Second
+
+	// maxNameLength is the length of the longest lock name of MySQL.
+	maxNameLength = 64
+)
+
+// ErrTimeout is returned when the lock is still held by another node once the timeout elapsed.
+var ErrTimeout = errors.New("lock timeout")
+
+var logger = log.New("hub-router/lock")
+
+// Locker acquires named locks.
+type Locker interface {
+	// Lock blocks until the lock is acquired, it returns the function releasing it.
+	Lock(name string) (func(), error)
+}
+
+// Local locks within the process, for the single node deployments and the in-memory storage.
+type Local struct {
+	mutex sync.Mutex
+	locks map[string]*local
+}
+
+type local struct {
+	sync.Mutex
+	holders int
+}
+
+// NewLocal returns a new Local locker.
+func NewLocal() *Local {
+	return &Local{locks: map[string]*local{}}
+}
+
+// Lock locks the name, it never fails.
+func (l *Local) Lock(name string) (func(), error) {
+	l.mutex.Lock()
+
+	lock, ok := l.locks[name]
+	if !ok {
+		lock = &local{}
+		l.locks[name] = lock
+	}
+
+	lock.holders++
+
+	l.mutex.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+
+		// the locks not held or waited for are dropped, so that the map doesn't grow with the names locked
+		if lock.holders--; lock.holders == 0 {
+			delete(l.locks, name)
+		}
+	}, nil
+}
+
+// SQL locks across the nodes sharing a MySQL database, with the GET_LOCK named locks : a lock is held by a connection
+// of the database, and released with it if the node holding it is lost.
+type SQL struct {
+	db      *sql.DB
+	prefix  string
+	timeout time.Duration
+}
+
+// NewSQL returns a new SQL locker. The prefix is added to the lock names, so that the routers sharing a database
+// server don't share their locks.
+func NewSQL(db *sql.DB, prefix string, timeout time.Duration) *SQL {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &SQL{db: db, prefix: prefix, timeout: timeout}
+}
+
+// Lock locks the name, it returns ErrTimeout if the lock is still held by another node once the timeout elapsed.
+func (l *SQL) Lock(name string) (func(), error) {
+	ctx := context.Background()
+
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("lock connection : %w", err)
+	}
+
+	name = l.name(name)
+
+	var acquired sql.NullInt64
+
+	err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, l.timeoutSeconds()).Scan(&acquired)
+	if err != nil || acquired.Int64 != 1 {
+		closeConn(conn)
+
+		if err != nil {
+			return nil, fmt.Errorf("lock %s : %w", name, err)
+		}
+
+		return nil, fmt.Errorf("lock %s : %w", name, ErrTimeout)
+	}
+
+	return func() {
+		if _, releaseErr := conn.ExecContext(ctx, "DO RELEASE_LOCK(?)", name); releaseErr != nil {
+			logger.Warnf("failed to release lock %s : %s", name, releaseErr)
+		}
+
+		closeConn(conn)
+	}, nil
+}
+
+// Close closes the database of the locks, the locks held are released with their connection.
+func (l *SQL) Close() error {
+	return l.db.Close()
+}
+
+// timeoutSeconds returns the timeout of GET_LOCK, rounded up to whole seconds : a sub-second timeout truncated to
+// zero wouldn't wait for the lock at all.
+func (l *SQL) timeoutSeconds() int {
+	return int((l.timeout + time.Second - 1) / time.Second)
+}
+
+// name returns the prefixed lock name, hashed if longer than allowed by MySQL.
+func (l *SQL) name(name string) string {
+	name = l.prefix + name
+	if len(name) <= maxNameLength {
+		return name
+	}
+
+	hash := sha256.Sum256([]byte(name))
+
+	return hex.EncodeToString(hash[:])
+}
+
+func closeConn(conn *sql.Conn) {
+	if err := conn.Close(); err != nil {
+		logger.Warnf("failed to close lock connection : %s", err)
+	}
+}
@@ -0,0 +1,279 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lock
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// server is a fake MySQL server implementing the named locks, the locks held are never waited for. If credentials
+// are set, only the connections opened with them as DSN are accepted.
+type server struct {
+	mutex       sync.Mutex
+	held        map[string]*conn
+	names       []string
+	timeouts    []int64
+	queryErr    error
+	credentials string
+}
+
+type conn struct {
+	server *server
+	dsn    string
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+type rows struct {
+	values []driver.Value
+}
+
+func (s *server) Open(dsn string) (driver.Conn, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.credentials != "" && dsn != s.credentials {
+		return nil, errors.New("access denied")
+	}
+
+	return &conn{server: s, dsn: dsn}, nil
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	c.server.mutex.Lock()
+	defer c.server.mutex.Unlock()
+
+	for name, holder := range c.server.held {
+		if holder == c {
+			delete(c.server.held, name)
+		}
+	}
+
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *stmt) Close() error {
+	return nil
+}
+
+func (s *stmt) NumInput() int {
+	return -1
+}
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	srv := s.conn.server
+
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+
+	if strings.Contains(s.query, "RELEASE_LOCK") && srv.held[args[0].(string)] == s.conn {
+		delete(srv.held, args[0].(string))
+	}
+
+	return driver.RowsAffected(0), nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	srv := s.conn.server
+
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+
+	if srv.queryErr != nil {
+		return nil, srv.queryErr
+	}
+
+	// the connections of revoked credentials are closed by the server
+	if srv.credentials != "" && s.conn.dsn != srv.credentials {
+		return nil, driver.ErrBadConn
+	}
+
+	name := args[0].(string)
+	srv.names = append(srv.names, name)
+	srv.timeouts = append(srv.timeouts, args[1].(int64))
+
+	if holder, ok := srv.held[name]; ok && holder != s.conn {
+		return &rows{values: []driver.Value{int64(0)}}, nil
+	}
+
+	srv.held[name] = s.conn
+
+	return &rows{values: []driver.Value{int64(1)}}, nil
+}
+
+func (r *rows) Columns() []string {
+	return []string{"acquired"}
+}
+
+func (r *rows) Close() error {
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.values == nil {
+		return io.EOF
+	}
+
+	copy(dest, r.values)
+	r.values = nil
+
+	return nil
+}
+
+// nolint:gochecknoglobals // the drivers are registered once per process
+var registerOnce sync.Once
+
+// nolint:gochecknoglobals // fake database registered with the sql package
+var fake = &server{held: map[string]*conn{}}
+
+func openFake(t *testing.T) *sql.DB {
+	t.Helper()
+
+	return openFakeAs(t, "")
+}
+
+// openFakeAs opens the fake database with the given DSN.
+func openFakeAs(t *testing.T, dsn string) *sql.DB {
+	t.Helper()
+
+	registerOnce.Do(func() { sql.Register("fakelock", fake) })
+
+	db, err := sql.Open("fakelock", dsn)
+	require.NoError(t, err)
+
+	t.Cleanup(func() { require.NoError(t, db.Close()) })
+
+	return db
+}
+
+func TestLocal(t *testing.T) {
+	l := NewLocal()
+
+	unlock, err := l.Lock("a")
+	require.NoError(t, err)
+
+	locked := make(chan struct{})
+
+	go func() {
+		unlockB, lockErr := l.Lock("a")
+		require.NoError(t, lockErr)
+
+		close(locked)
+		unlockB()
+	}()
+
+	select {
+	case <-locked:
+		t.Fatal("lock acquired twice")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	unlockOther, err := l.Lock("b")
+	require.NoError(t, err)
+	unlockOther()
+
+	unlock()
+	<-locked
+
+	require.Eventually(t, func() bool {
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+
+		return len(l.locks) == 0
+	}, time.Second, time.Millisecond)
+}
+
+func TestSQL(t *testing.T) {
+	t.Run("lock and release", func(t *testing.T) {
+		db := openFake(t)
+		l := NewSQL(db, "hr_", 0)
+		require.Equal(t, DefaultTimeout, l.timeout)
+
+		unlock, err := l.Lock("lease")
+		require.NoError(t, err)
+
+		_, err = l.Lock("lease")
+		require.ErrorIs(t, err, ErrTimeout)
+
+		unlock()
+
+		unlock, err = l.Lock("lease")
+		require.NoError(t, err)
+		unlock()
+
+		require.Contains(t, fake.names, "hr_lease")
+	})
+
+	t.Run("long name", func(t *testing.T) {
+		l := NewSQL(openFake(t), "hr_", time.Second)
+
+		unlock, err := l.Lock(strings.Repeat("x", 100))
+		require.NoError(t, err)
+		unlock()
+
+		require.Len(t, fake.names[len(fake.names)-1], maxNameLength)
+	})
+
+	t.Run("sub-second timeout", func(t *testing.T) {
+		for timeout, seconds := range map[time.Duration]int64{
+			time.Millisecond: 1, 500 * time.Millisecond: 1, time.Second: 1, 1500 * time.Millisecond: 2,
+		} {
+			unlock, err := NewSQL(openFake(t), "hr_", timeout).Lock("lease")
+			require.NoError(t, err)
+			unlock()
+
+			require.Equal(t, seconds, fake.timeouts[len(fake.timeouts)-1], timeout)
+		}
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		l := NewSQL(openFake(t), "hr_", time.Second)
+
+		fake.mutex.Lock()
+		fake.queryErr = errors.New("connection refused")
+		fake.mutex.Unlock()
+
+		defer func() {
+			fake.mutex.Lock()
+			fake.queryErr = nil
+			fake.mutex.Unlock()
+		}()
+
+		_, err := l.Lock("lease")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "connection refused")
+	})
+
+	t.Run("connection error", func(t *testing.T) {
+		db := openFake(t)
+		require.NoError(t, db.Close())
+
+		_, err := NewSQL(db, "", time.Second).Lock("lease")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "lock connection")
+	})
+}
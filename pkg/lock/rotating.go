@@ -0,0 +1,84 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lock
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// rotationDrain is the time the previous locker is kept open after a rotation, so that the locks held through it are
+// released on their own connection.
+const rotationDrain = 30 * time.Second
+
+// OpenFunc connects a locker with the given DSN, which carries the database credentials.
+type OpenFunc func(dsn string) (Locker, error)
+
+// Rotating locks through an underlying locker that is replaced when the database credentials rotate, eg: by the
+// watcher of a secret file. The locks are named in the same database whatever the credentials, so a lock held through
+// the previous locker still excludes the nodes locking through the new one until it is released.
+type Rotating struct {
+	open    OpenFunc
+	rotate  sync.Mutex
+	mutex   sync.RWMutex
+	current Locker
+	dsn     string
+	drain   time.Duration
+}
+
+// NewRotating returns a new Rotating locker, connected with the given DSN.
+func NewRotating(open OpenFunc, dsn string) (*Rotating, error) {
+	current, err := open(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rotating{open: open, current: current, dsn: dsn, drain: rotationDrain}, nil
+}
+
+// Lock locks the name through the current locker.
+func (r *Rotating) Lock(name string) (func(), error) {
+	r.mutex.RLock()
+	current := r.current
+	r.mutex.RUnlock()
+
+	return current.Lock(name)
+}
+
+// Rotate connects a new underlying locker with the given DSN, and closes the previous one once drained. The current
+// locker is kept if the new DSN fails, so that a wrong secret doesn't stop the locks.
+func (r *Rotating) Rotate(dsn string) error {
+	r.rotate.Lock()
+	defer r.rotate.Unlock()
+
+	if dsn == r.dsn {
+		return nil
+	}
+
+	next, err := r.open(dsn)
+	if err != nil {
+		return fmt.Errorf("connect locks with rotated credentials : %w", err)
+	}
+
+	r.mutex.Lock()
+	previous := r.current
+	r.current = next
+	r.dsn = dsn
+	r.mutex.Unlock()
+
+	if closer, ok := previous.(io.Closer); ok {
+		time.AfterFunc(r.drain, func() {
+			if closeErr := closer.Close(); closeErr != nil {
+				logger.Warnf("failed to close the locks of the previous credentials : %s", closeErr)
+			}
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package lock
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotating(t *testing.T) {
+	setCredentials := func(dsn string) {
+		fake.mutex.Lock()
+		fake.credentials = dsn
+		fake.mutex.Unlock()
+	}
+
+	t.Cleanup(func() { setCredentials("") })
+
+	var lockers []*SQL
+
+	open := func(dsn string) (Locker, error) {
+		if dsn == "invalid" {
+			return nil, errors.New("invalid dsn")
+		}
+
+		l := NewSQL(openFakeAs(t, dsn), "hr_", time.Second)
+		lockers = append(lockers, l)
+
+		return l, nil
+	}
+
+	_, err := NewRotating(open, "invalid")
+	require.EqualError(t, err, "invalid dsn")
+
+	setCredentials("user-1")
+
+	r, err := NewRotating(open, "user-1")
+	require.NoError(t, err)
+
+	r.drain = 0
+
+	unlock, err := r.Lock("rotated")
+	require.NoError(t, err)
+	unlock()
+
+	// the credentials of the locks are revoked once rotated
+	setCredentials("user-2")
+
+	_, err = r.Lock("rotated")
+	require.Error(t, err)
+
+	require.NoError(t, r.Rotate("user-2"))
+	require.NoError(t, r.Rotate("user-2"))
+	require.Len(t, lockers, 2)
+
+	unlock, err = r.Lock("rotated")
+	require.NoError(t, err)
+	unlock()
+
+	// the previous locker is closed once drained
+	require.Eventually(t, func() bool {
+		pingErr := lockers[0].db.Ping()
+
+		return pingErr != nil && pingErr.Error() == "sql: database is closed"
+	}, time.Second, time.Millisecond)
+
+	// a wrong secret keeps the current locker
+	err = r.Rotate("invalid")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "connect locks with rotated credentials")
+
+	unlock, err = r.Lock("rotated")
+	require.NoError(t, err)
+	unlock()
+}
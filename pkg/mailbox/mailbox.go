@@ -0,0 +1,577 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package mailbox bounds the pickup mailboxes, the durable queues of the forward messages routed to each wallet, kept
+// in the persistent storage of the router until they are picked up : the depth of a queue and the size of its
+// messages are capped, and the messages expire once their TTL has elapsed.
+//
+// The mailboxes are written by the pickup queue while the lock of their wallet is held, and purged or expired under
+// the same lock, taken through the queue : a mailbox is never written back with the messages removed meanwhile.
+package mailbox
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	messagesField = "messages"
+	countField    = "message_count"
+	sizeField     = "total_size"
+	idField       = "id"
+	addedField    = "added_time"
+	msgField      = "msg"
+)
+
+var (
+	// ErrQueueFull is returned when a message is queued for a wallet whose queue reached its max depth.
+	ErrQueueFull = errors.New("recipient queue full")
+	// ErrMessageTooLarge is returned when a message larger than the max message size is queued.
+	ErrMessageTooLarge = errors.New("queued message too large")
+)
+
+var logger = log.New("hub-router/mailbox")
+
+// RecipientSource returns the DIDs of the wallets the router queues messages for.
+type RecipientSource func() ([]string, error)
+
+// Locker locks the mailboxes of the wallets, eg: the pickup queue, which writes the mailboxes of a wallet while its
+// lock is held.
+type Locker interface {
+	// LockMailboxes locks the mailboxes of the wallet, it returns their keys and the function unlocking them.
+	LockMailboxes(theirDID string) ([]string, func(), error)
+}
+
+// Config of the mailbox limits; a zero limit is unlimited.
+type Config struct {
+	// MaxDepth is the number of messages queued for a wallet from which the new messages are rejected.
+	MaxDepth int
+	// MaxMessageSize is the size in bytes of the largest message queued.
+	MaxMessageSize int
+	// TTL is the time a message stays queued, if not picked up, before it expires.
+	TTL time.Duration
+}
+
+// Enabled returns true if a limit is configured.
+func (c *Config) Enabled() bool {
+	return c != nil && (c.MaxDepth > 0 || c.MaxMessageSize > 0 || c.TTL > 0)
+}
+
+// Stats of the bounded mailboxes.
+type Stats struct {
+	// Full is the number of messages rejected, their queue being full.
+	Full uint64 `json:"full"`
+	// Oversized is the number of messages rejected for their size.
+	Oversized uint64 `json:"oversized"`
+	// Expired is the number of messages dropped once expired.
+	Expired uint64 `json:"expired"`
+	// Purged is the number of messages purged by the operator.
+	Purged uint64 `json:"purged"`
+}
+
+// Message is a queued message, without its payload.
+type Message struct {
+	ID        string     `json:"id"`
+	AddedTime time.Time  `json:"addedTime"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// Size is the size in bytes of the message payload.
+	Size int `json:"size"`
+}
+
+// Queue is the mailbox of a wallet, its messages in delivery order.
+type Queue struct {
+	DID      string     `json:"did"`
+	Depth    int        `json:"depth"`
+	Size     int        `json:"size"`
+	Messages []*Message `json:"messages"`
+}
+
+// Provider is a storage provider enforcing the limits on the mailboxes of the given store : the new messages are
+// rejected with ErrQueueFull or ErrMessageTooLarge, and the expired messages are dropped when their mailbox is read
+// or written, and by the periodic sweep. The limits are enforced on the messages queued, not on the mailboxes stored
+// before they were configured : a mailbox over its max depth still delivers all its messages.
+type Provider struct {
+	storage.Provider
+	config    *Config
+	name      string
+	full      uint64
+	oversized uint64
+	expired   uint64
+	purged    uint64
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewProvider returns a new Provider bounding the mailboxes of the given store, unlimited if config is nil.
+func NewProvider(p storage.Provider, config *Config, name string) *Provider {
+	if config == nil {
+		config = &Config{}
+	}
+
+	return &Provider{Provider: p, config: config, name: name, stop: make(chan struct{})}
+}
+
+// OpenStore opens the store, bounded if it is the mailbox store.
+func (p *Provider) OpenStore(name string) (storage.Store, error) {
+	s, err := p.Provider.OpenStore(name)
+	if err != nil || name != p.name {
+		return s, err
+	}
+
+	return &store{Store: s, provider: p}, nil
+}
+
+// Config returns the mailbox limits.
+func (p *Provider) Config() *Config {
+	return p.config
+}
+
+// Stats returns the messages rejected, expired and purged since the router started.
+func (p *Provider) Stats() *Stats {
+	return &Stats{
+		Full:      atomic.LoadUint64(&p.full),
+		Oversized: atomic.LoadUint64(&p.oversized),
+		Expired:   atomic.LoadUint64(&p.expired),
+		Purged:    atomic.LoadUint64(&p.purged),
+	}
+}
+
+// Inspect returns the messages queued in the mailbox, without the expired messages; the queue is empty if the mailbox
+// isn't found.
+func (p *Provider) Inspect(key string) (*Queue, error) {
+	mb, err := p.mailbox(key)
+	if err != nil {
+		return nil, err
+	}
+
+	mb.expire(p.config.TTL, time.Now())
+
+	q := &Queue{DID: key, Depth: len(mb.messages), Messages: make([]*Message, len(mb.messages))}
+
+	for i, msg := range mb.messages {
+		q.Messages[i] = &Message{ID: messageID(msg), Size: len(msg[msgField])}
+		q.Size += q.Messages[i].Size
+
+		added, ok := addedTime(msg)
+		if !ok {
+			continue
+		}
+
+		q.Messages[i].AddedTime = added.UTC()
+
+		if p.config.TTL > 0 {
+			expiresAt := added.Add(p.config.TTL).UTC()
+			q.Messages[i].ExpiresAt = &expiresAt
+		}
+	}
+
+	return q, nil
+}
+
+// Purge drops the messages queued for the wallet, eg: once the wallet is lost, and returns their number. The mailboxes
+// of the wallet are locked with the locker.
+func (p *Provider) Purge(locker Locker, theirDID string) (int, error) {
+	keys, unlock, err := locker.LockMailboxes(theirDID)
+	if err != nil {
+		return 0, err
+	}
+
+	defer unlock()
+
+	purged := 0
+
+	for _, key := range keys {
+		mb, err := p.mailbox(key)
+		if err != nil {
+			return purged, err
+		}
+
+		if len(mb.messages) == 0 {
+			continue
+		}
+
+		n := len(mb.messages)
+		mb.messages = nil
+
+		if err = p.save(key, mb); err != nil {
+			return purged, err
+		}
+
+		purged += n
+	}
+
+	atomic.AddUint64(&p.purged, uint64(purged))
+
+	if purged > 0 {
+		logger.Infof("mailboxes purged : did=%s messages=%d", theirDID, purged)
+	}
+
+	return purged, nil
+}
+
+// Expire drops the expired messages queued for the wallet, and returns their number. The mailboxes of the wallet are
+// locked with the locker.
+func (p *Provider) Expire(locker Locker, theirDID string) (int, error) {
+	keys, unlock, err := locker.LockMailboxes(theirDID)
+	if err != nil {
+		return 0, err
+	}
+
+	defer unlock()
+
+	expired := 0
+
+	for _, key := range keys {
+		mb, err := p.mailbox(key)
+		if err != nil {
+			return expired, err
+		}
+
+		n := mb.expire(p.config.TTL, time.Now())
+		if n == 0 {
+			continue
+		}
+
+		if err = p.save(key, mb); err != nil {
+			return expired, err
+		}
+
+		expired += n
+	}
+
+	atomic.AddUint64(&p.expired, uint64(expired))
+
+	return expired, nil
+}
+
+// Sweep drops the expired messages queued for the given wallets, whose mailboxes are locked with the locker.
+func (p *Provider) Sweep(locker Locker, recipients RecipientSource) error {
+	dids, err := recipients()
+	if err != nil {
+		return fmt.Errorf("get mailbox recipients : %w", err)
+	}
+
+	for _, did := range dids {
+		expired, err := p.Expire(locker, did)
+		if err != nil {
+			return err
+		}
+
+		if expired > 0 {
+			logger.Infof("expired messages dropped : did=%s messages=%d", did, expired)
+		}
+	}
+
+	return nil
+}
+
+// Start sweeps the mailboxes of the given wallets at the given interval, until Stop is called.
+func (p *Provider) Start(interval time.Duration, locker Locker, recipients RecipientSource) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := p.Sweep(locker, recipients); err != nil {
+					logger.Warnf("mailbox sweep : %s", err)
+				}
+			case <-p.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic sweep.
+func (p *Provider) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
+// mailbox returns the stored mailbox, an empty mailbox if not found.
+func (p *Provider) mailbox(key string) (*mailbox, error) {
+	s, err := p.Provider.OpenStore(p.name)
+	if err != nil {
+		return nil, fmt.Errorf("open mailbox store : %w", err)
+	}
+
+	return stored(s, key)
+}
+
+func (p *Provider) save(key string, mb *mailbox) error {
+	s, err := p.Provider.OpenStore(p.name)
+	if err != nil {
+		return fmt.Errorf("open mailbox store : %w", err)
+	}
+
+	value, err := mb.encode()
+	if err != nil {
+		return fmt.Errorf("encode mailbox %s : %w", key, err)
+	}
+
+	err = s.Put(key, value)
+	if err != nil {
+		return fmt.Errorf("save mailbox %s : %w", key, err)
+	}
+
+	return nil
+}
+
+// mailbox is a decoded mailbox, keeping the fields it doesn't use as is.
+type mailbox struct {
+	inbox    map[string]json.RawMessage
+	messages []map[string]json.RawMessage
+}
+
+// decode returns the mailbox, false if the value isn't a mailbox.
+func decode(value []byte) (*mailbox, bool) {
+	mb := &mailbox{inbox: map[string]json.RawMessage{}}
+
+	if json.Unmarshal(value, &mb.inbox) != nil || json.Unmarshal(mb.inbox[messagesField], &mb.messages) != nil {
+		return nil, false
+	}
+
+	return mb, true
+}
+
+// expire drops the messages queued for longer than the TTL, if set, and returns their number.
+func (mb *mailbox) expire(ttl time.Duration, now time.Time) int {
+	if ttl <= 0 {
+		return 0
+	}
+
+	kept := mb.messages[:0]
+
+	for _, msg := range mb.messages {
+		if added, ok := addedTime(msg); ok && !now.Before(added.Add(ttl)) {
+			continue
+		}
+
+		kept = append(kept, msg)
+	}
+
+	expired := len(mb.messages) - len(kept)
+	mb.messages = kept
+
+	return expired
+}
+
+// encode returns the mailbox with its message count and total size updated, as the Aries message pickup does.
+func (mb *mailbox) encode() ([]byte, error) {
+	if mb.messages == nil {
+		mb.messages = []map[string]json.RawMessage{}
+	}
+
+	messages, err := json.Marshal(mb.messages)
+	if err != nil {
+		return nil, fmt.Errorf("marshal messages : %w", err)
+	}
+
+	mb.inbox[messagesField] = messages
+	mb.inbox[countField] = json.RawMessage(fmt.Sprint(len(mb.messages)))
+	mb.inbox[sizeField] = json.RawMessage(fmt.Sprint(len(messages)))
+
+	return json.Marshal(mb.inbox)
+}
+
+// messageID returns the ID of the message, its raw value if it isn't a string.
+func messageID(msg map[string]json.RawMessage) string {
+	var id string
+
+	if len(msg[idField]) > 0 && json.Unmarshal(msg[idField], &id) != nil {
+		return string(msg[idField])
+	}
+
+	return id
+}
+
+// addedTime returns the time the message was queued, false if unknown.
+func addedTime(msg map[string]json.RawMessage) (time.Time, bool) {
+	var added time.Time
+
+	if len(msg[addedField]) == 0 || json.Unmarshal(msg[addedField], &added) != nil || added.IsZero() {
+		return time.Time{}, false
+	}
+
+	return added, true
+}
+
+// stored returns the mailbox stored in s, an empty mailbox if not found or not a mailbox.
+func stored(s storage.Store, key string) (*mailbox, error) {
+	value, err := s.Get(key)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return &mailbox{inbox: map[string]json.RawMessage{}}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get mailbox %s : %w", key, err)
+	}
+
+	mb, ok := decode(value)
+	if !ok {
+		return &mailbox{inbox: map[string]json.RawMessage{}}, nil
+	}
+
+	return mb, nil
+}
+
+// store bounds the mailboxes of the underlying store.
+type store struct {
+	storage.Store
+	provider *Provider
+}
+
+func (s *store) Put(key string, value []byte, tags ...storage.Tag) error {
+	bounded, err := s.bound(key, value)
+	if err != nil {
+		return err
+	}
+
+	return s.Store.Put(key, bounded, tags...)
+}
+
+func (s *store) Get(key string) ([]byte, error) {
+	value, err := s.Store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.unexpired(key, value)
+}
+
+func (s *store) GetBulk(keys ...string) ([][]byte, error) {
+	values, err := s.Store.GetBulk(keys...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+
+		values[i], err = s.unexpired(keys[i], value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+func (s *store) Batch(operations []storage.Operation) error {
+	ops := make([]storage.Operation, len(operations))
+
+	for i, op := range operations {
+		ops[i] = op
+
+		// a nil value deletes the key
+		if op.Value == nil {
+			continue
+		}
+
+		value, err := s.bound(op.Key, op.Value)
+		if err != nil {
+			return err
+		}
+
+		ops[i].Value = value
+	}
+
+	return s.Store.Batch(ops)
+}
+
+// unexpired returns the mailbox without its expired messages, so that they are never delivered. The value is returned
+// as is if it isn't a mailbox or no message expired.
+func (s *store) unexpired(key string, value []byte) ([]byte, error) {
+	mb, ok := decode(value)
+	if !ok {
+		return value, nil
+	}
+
+	if mb.expire(s.provider.config.TTL, time.Now()) == 0 {
+		return value, nil
+	}
+
+	encoded, err := mb.encode()
+	if err != nil {
+		return nil, fmt.Errorf("expire %s : %w", key, err)
+	}
+
+	return encoded, nil
+}
+
+// bound returns the mailbox without its expired messages, or an error if a new message exceeds a limit. The value is
+// returned as is if it isn't a mailbox, or no limit is configured.
+func (s *store) bound(key string, value []byte) ([]byte, error) {
+	config := s.provider.config
+
+	if !config.Enabled() {
+		return value, nil
+	}
+
+	mb, ok := decode(value)
+	if !ok {
+		return value, nil
+	}
+
+	prev, err := stored(s.Store, key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	known := make(map[string]bool, len(prev.messages))
+
+	for _, msg := range prev.messages {
+		known[string(msg[idField])] = true
+	}
+
+	atomic.AddUint64(&s.provider.expired, uint64(prev.expire(config.TTL, now)))
+	mb.expire(config.TTL, now)
+
+	queued := false
+
+	for _, msg := range mb.messages {
+		if known[string(msg[idField])] {
+			continue
+		}
+
+		queued = true
+
+		if config.MaxMessageSize > 0 && len(msg[msgField]) > config.MaxMessageSize {
+			atomic.AddUint64(&s.provider.oversized, 1)
+
+			return nil, fmt.Errorf("%w : %d bytes, max %d", ErrMessageTooLarge, len(msg[msgField]),
+				config.MaxMessageSize)
+		}
+	}
+
+	if queued && config.MaxDepth > 0 && len(mb.messages) > config.MaxDepth {
+		atomic.AddUint64(&s.provider.full, 1)
+
+		return nil, fmt.Errorf("%w : depth %d, max %d", ErrQueueFull, len(mb.messages), config.MaxDepth)
+	}
+
+	encoded, err := mb.encode()
+	if err != nil {
+		return nil, fmt.Errorf("bound %s : %w", key, err)
+	}
+
+	return encoded, nil
+}
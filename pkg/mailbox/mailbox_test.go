@@ -0,0 +1,402 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mailbox
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	storeName = "messagepickup"
+	did       = "did:example:1"
+)
+
+type message struct {
+	ID        string          `json:"id"`
+	AddedTime time.Time       `json:"added_time"`
+	Msg       json.RawMessage `json:"msg,omitempty"`
+}
+
+type inbox struct {
+	DID          string     `json:"DID"`
+	MessageCount int        `json:"message_count"`
+	TotalSize    int        `json:"total_size,omitempty"`
+	Messages     []*message `json:"messages"`
+}
+
+// pickupMailbox returns a mailbox as written by the Aries message pickup.
+func pickupMailbox(t *testing.T, msgs ...*message) []byte {
+	t.Helper()
+
+	mb := &inbox{DID: did, MessageCount: len(msgs), Messages: append([]*message{}, msgs...)}
+
+	value, err := json.Marshal(mb)
+	require.NoError(t, err)
+
+	return value
+}
+
+func msg(id string, age time.Duration, size int) *message {
+	payload := `"` + strings.Repeat("x", size-2) + `"`
+
+	return &message{ID: id, AddedTime: time.Now().Add(-age), Msg: json.RawMessage(payload)}
+}
+
+func read(t *testing.T, s storage.Store) *inbox {
+	t.Helper()
+
+	value, err := s.Get(did)
+	require.NoError(t, err)
+
+	mb := &inbox{}
+	require.NoError(t, json.Unmarshal(value, mb))
+
+	return mb
+}
+
+func ids(mb *inbox) []string {
+	ids := []string{}
+
+	for _, m := range mb.Messages {
+		ids = append(ids, m.ID)
+	}
+
+	return ids
+}
+
+// mailboxes locks the given mailboxes, whichever the wallet.
+type mailboxes []string
+
+func (m mailboxes) LockMailboxes(string) ([]string, func(), error) {
+	return m, func() {}, nil
+}
+
+type failingLocker struct{}
+
+func (failingLocker) LockMailboxes(string) ([]string, func(), error) {
+	return nil, nil, errors.New("lock timeout")
+}
+
+func TestConfig(t *testing.T) {
+	require.False(t, (*Config)(nil).Enabled())
+	require.False(t, (&Config{}).Enabled())
+	require.True(t, (&Config{MaxDepth: 1}).Enabled())
+	require.True(t, (&Config{MaxMessageSize: 1}).Enabled())
+	require.True(t, (&Config{TTL: time.Hour}).Enabled())
+}
+
+func TestProvider(t *testing.T) {
+	t.Run("max depth", func(t *testing.T) {
+		p := NewProvider(mem.NewProvider(), &Config{MaxDepth: 2}, storeName)
+
+		s, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put(did, pickupMailbox(t, msg("1", 0, 10), msg("2", 0, 10))))
+
+		err = s.Put(did, pickupMailbox(t, msg("1", 0, 10), msg("2", 0, 10), msg("3", 0, 10)))
+		require.ErrorIs(t, err, ErrQueueFull)
+		require.Equal(t, []string{"1", "2"}, ids(read(t, s)))
+
+		// the pickup writes the mailbox without the messages delivered
+		require.NoError(t, s.Put(did, pickupMailbox(t, msg("2", 0, 10))))
+		require.NoError(t, s.Put(did, pickupMailbox(t, msg("2", 0, 10), msg("3", 0, 10))))
+
+		mb := read(t, s)
+		require.Equal(t, []string{"2", "3"}, ids(mb))
+		require.Equal(t, 2, mb.MessageCount)
+		require.Equal(t, uint64(1), p.Stats().Full)
+	})
+
+	t.Run("max message size", func(t *testing.T) {
+		p := NewProvider(mem.NewProvider(), &Config{MaxMessageSize: 100}, storeName)
+
+		s, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put(did, pickupMailbox(t, msg("1", 0, 100))))
+
+		err = s.Put(did, pickupMailbox(t, msg("1", 0, 100), msg("2", 0, 101)))
+		require.ErrorIs(t, err, ErrMessageTooLarge)
+		require.Contains(t, err.Error(), "101 bytes, max 100")
+		require.Equal(t, uint64(1), p.Stats().Oversized)
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		p := NewProvider(mem.NewProvider(), &Config{TTL: time.Hour}, storeName)
+
+		s, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put(did, pickupMailbox(t, msg("1", 2*time.Hour, 10), msg("2", 0, 10))))
+
+		mb := read(t, s)
+		require.Equal(t, []string{"2"}, ids(mb))
+		require.Equal(t, 1, mb.MessageCount)
+
+		values, err := s.GetBulk(did, "did:example:2")
+		require.NoError(t, err)
+		require.Len(t, values, 2)
+		require.Nil(t, values[1])
+
+		expired, err := p.Expire(mailboxes{did}, did)
+		require.NoError(t, err)
+		require.Zero(t, expired)
+
+		raw, err := p.Provider.OpenStore(storeName)
+		require.NoError(t, err)
+		require.NoError(t, raw.Put(did, pickupMailbox(t, msg("1", 2*time.Hour, 10), msg("2", 0, 10))))
+
+		require.NoError(t, p.Sweep(mailboxes{did}, func() ([]string, error) {
+			return []string{did, "did:example:2"}, nil
+		}))
+
+		require.Equal(t, []string{"2"}, ids(read(t, raw)))
+		require.Equal(t, uint64(1), p.Stats().Expired)
+
+		require.NoError(t, raw.Put(did, pickupMailbox(t, msg("2", 2*time.Hour, 10))))
+		require.NoError(t, s.Batch([]storage.Operation{
+			{Key: did, Value: pickupMailbox(t, msg("2", 2*time.Hour, 10), msg("3", 0, 10))},
+			{Key: "did:example:2"},
+		}))
+
+		require.Equal(t, []string{"3"}, ids(read(t, raw)))
+		require.Equal(t, uint64(2), p.Stats().Expired)
+	})
+
+	t.Run("inspect and purge", func(t *testing.T) {
+		p := NewProvider(mem.NewProvider(), &Config{TTL: time.Hour}, storeName)
+
+		q, err := p.Inspect(did)
+		require.NoError(t, err)
+		require.Zero(t, q.Depth)
+		require.Empty(t, q.Messages)
+
+		s, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put(did, pickupMailbox(t, msg("1", 0, 10), msg("2", 0, 20), &message{ID: "3"})))
+
+		q, err = p.Inspect(did)
+		require.NoError(t, err)
+		require.Equal(t, did, q.DID)
+		require.Equal(t, 3, q.Depth)
+		require.Equal(t, 30, q.Size)
+		require.Equal(t, "1", q.Messages[0].ID)
+		require.Equal(t, 20, q.Messages[1].Size)
+		require.Equal(t, q.Messages[0].AddedTime.Add(time.Hour), *q.Messages[0].ExpiresAt)
+		require.Nil(t, q.Messages[2].ExpiresAt)
+
+		purged, err := p.Purge(mailboxes{did}, did)
+		require.NoError(t, err)
+		require.Equal(t, 3, purged)
+
+		mb := read(t, s)
+		require.Empty(t, mb.Messages)
+		require.Zero(t, mb.MessageCount)
+		require.Equal(t, did, mb.DID)
+
+		purged, err = p.Purge(mailboxes{did}, did)
+		require.NoError(t, err)
+		require.Zero(t, purged)
+		require.Equal(t, uint64(3), p.Stats().Purged)
+	})
+
+	t.Run("mailboxes of the wallet purged", func(t *testing.T) {
+		p := NewProvider(mem.NewProvider(), nil, storeName)
+
+		s, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put(did+"#key-1", pickupMailbox(t, msg("1", 0, 10), msg("2", 0, 10))))
+		require.NoError(t, s.Put(did+"#key-2", pickupMailbox(t, msg("3", 0, 10))))
+
+		purged, err := p.Purge(mailboxes{did + "#key-1", did + "#key-2", did + "#key-3"}, did)
+		require.NoError(t, err)
+		require.Equal(t, 3, purged)
+
+		for _, key := range []string{did + "#key-1", did + "#key-2"} {
+			q, err := p.Inspect(key)
+			require.NoError(t, err)
+			require.Zero(t, q.Depth)
+		}
+	})
+
+	t.Run("not a mailbox", func(t *testing.T) {
+		p := NewProvider(mem.NewProvider(), &Config{MaxDepth: 1, TTL: time.Hour}, storeName)
+
+		s, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Put(did, []byte("not a mailbox")))
+
+		value, err := s.Get(did)
+		require.NoError(t, err)
+		require.Equal(t, "not a mailbox", string(value))
+
+		require.NoError(t, s.Put(did, pickupMailbox(t, msg("1", 0, 10))))
+
+		q, err := p.Inspect("did:example:2")
+		require.NoError(t, err)
+		require.Zero(t, q.Depth)
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		p := NewProvider(mem.NewProvider(), nil, storeName)
+		require.False(t, p.Config().Enabled())
+
+		s, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+
+		value := pickupMailbox(t, msg("1", 2*time.Hour, 10))
+		require.NoError(t, s.Put(did, value))
+
+		stored, err := s.Get(did)
+		require.NoError(t, err)
+		require.Equal(t, value, stored)
+
+		other, err := p.OpenStore("other")
+		require.NoError(t, err)
+		require.NotEqual(t, s, other)
+	})
+}
+
+// failingProvider fails to save the mailbox of the failing DID.
+type failingProvider struct {
+	storage.Provider
+	failing string
+}
+
+func (p *failingProvider) OpenStore(name string) (storage.Store, error) {
+	s, err := p.Provider.OpenStore(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &failingStore{Store: s, failing: p.failing}, nil
+}
+
+type failingStore struct {
+	storage.Store
+	failing string
+}
+
+func (s *failingStore) Put(key string, value []byte, tags ...storage.Tag) error {
+	if key == s.failing {
+		return errors.New("put error")
+	}
+
+	return s.Store.Put(key, value, tags...)
+}
+
+func TestProviderErrors(t *testing.T) {
+	t.Run("open store error", func(t *testing.T) {
+		p := NewProvider(&mockstore.MockStoreProvider{FailNamespace: storeName}, &Config{TTL: time.Hour}, storeName)
+
+		_, err := p.OpenStore(storeName)
+		require.Error(t, err)
+
+		_, err = p.Inspect(did)
+		require.Contains(t, err.Error(), "open mailbox store")
+
+		_, err = p.Purge(mailboxes{did}, did)
+		require.Error(t, err)
+
+		_, err = p.Expire(mailboxes{did}, did)
+		require.Error(t, err)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		store := &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry)}
+		p := NewProvider(mockstore.NewCustomMockStoreProvider(store), &Config{TTL: time.Hour}, storeName)
+
+		raw, err := p.Provider.OpenStore(storeName)
+		require.NoError(t, err)
+
+		require.NoError(t, raw.Put(did, pickupMailbox(t, msg("1", 2*time.Hour, 10), msg("2", 0, 10))))
+
+		s, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+
+		store.ErrPut = errors.New("put error")
+
+		_, err = p.Purge(mailboxes{did}, did)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save mailbox")
+
+		_, err = p.Expire(mailboxes{did}, did)
+		require.Error(t, err)
+
+		store.ErrGet = errors.New("get error")
+
+		err = s.Put(did, pickupMailbox(t, msg("3", 0, 10)))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get mailbox")
+
+		err = s.Batch([]storage.Operation{{Key: did, Value: pickupMailbox(t, msg("3", 0, 10))}})
+		require.Error(t, err)
+
+		_, err = s.Get(did)
+		require.Error(t, err)
+
+		err = p.Sweep(mailboxes{did}, func() ([]string, error) {
+			return []string{did}, nil
+		})
+		require.Error(t, err)
+
+		err = p.Sweep(mailboxes{did}, func() ([]string, error) {
+			return nil, errors.New("recipients error")
+		})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get mailbox recipients")
+	})
+
+	t.Run("lock error", func(t *testing.T) {
+		p := NewProvider(mem.NewProvider(), &Config{TTL: time.Hour}, storeName)
+
+		_, err := p.Purge(failingLocker{}, did)
+		require.EqualError(t, err, "lock timeout")
+
+		_, err = p.Expire(failingLocker{}, did)
+		require.EqualError(t, err, "lock timeout")
+
+		err = p.Sweep(failingLocker{}, func() ([]string, error) {
+			return []string{did}, nil
+		})
+		require.EqualError(t, err, "lock timeout")
+	})
+}
+
+func TestStartStop(t *testing.T) {
+	raw := mem.NewProvider()
+	p := NewProvider(raw, &Config{TTL: time.Hour}, storeName)
+
+	s, err := raw.OpenStore(storeName)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Put(did, pickupMailbox(t, msg("1", 2*time.Hour, 10))))
+
+	p.Start(time.Millisecond, mailboxes{did}, func() ([]string, error) {
+		return []string{did}, nil
+	})
+	defer p.Stop()
+
+	require.Eventually(t, func() bool {
+		return p.Stats().Expired == 1
+	}, time.Second, time.Millisecond)
+
+	p.Stop()
+}
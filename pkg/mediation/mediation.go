@@ -0,0 +1,116 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mediation
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "mediationclient"
+	clientTag = "client"
+)
+
+var logger = log.New("hub-router/mediation")
+
+// Client is a wallet or agent registered for mediation with the router : the connection mediation was granted on, the
+// DID and label of the client, and the routing keys of the grant.
+type Client struct {
+	ConnectionID string    `json:"connectionID"`
+	TheirDID     string    `json:"theirDID"`
+	Label        string    `json:"label,omitempty"`
+	RoutingKeys  []string  `json:"routingKeys"`
+	Registered   time.Time `json:"registered"`
+}
+
+// Registry records the clients the router granted mediation to.
+type Registry struct {
+	store storage.Store
+}
+
+// New returns a new mediation client Registry.
+func New(p storage.Provider) (*Registry, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open mediation client store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{clientTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set mediation client store config : %w", err)
+	}
+
+	return &Registry{store: store}, nil
+}
+
+// Register registers the client of the connection, replacing its previous grant.
+func (r *Registry) Register(c *Client) error {
+	if c.Registered.IsZero() {
+		c.Registered = time.Now().UTC()
+	}
+
+	clientBytes, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshal mediation client : %w", err)
+	}
+
+	err = r.store.Put(c.ConnectionID, clientBytes, storage.Tag{Name: clientTag})
+	if err != nil {
+		return fmt.Errorf("save mediation client : %w", err)
+	}
+
+	return nil
+}
+
+// List returns the registered clients, most recently registered first.
+func (r *Registry) List() ([]*Client, error) {
+	iter, err := r.store.Query(clientTag)
+	if err != nil {
+		return nil, fmt.Errorf("query mediation clients : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var clients []*Client
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate mediation clients : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read mediation client : %w", err)
+		}
+
+		c := &Client{}
+
+		err = json.Unmarshal(val, c)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal mediation client : %w", err)
+		}
+
+		clients = append(clients, c)
+	}
+
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].Registered.After(clients[j].Registered)
+	})
+
+	return clients, nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package mediation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open mediation client store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set mediation client store config")
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("register and list", func(t *testing.T) {
+		r, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		clients, err := r.List()
+		require.NoError(t, err)
+		require.Empty(t, clients)
+
+		require.NoError(t, r.Register(&Client{
+			ConnectionID: "conn-1", TheirDID: "did:wallet1", RoutingKeys: []string{"key-1"},
+			Registered: time.Now().Add(-time.Hour),
+		}))
+		require.NoError(t, r.Register(&Client{ConnectionID: "conn-2", TheirDID: "did:wallet2"}))
+
+		clients, err = r.List()
+		require.NoError(t, err)
+		require.Len(t, clients, 2)
+		require.Equal(t, "conn-2", clients[0].ConnectionID)
+		require.False(t, clients[0].Registered.IsZero())
+		require.Equal(t, []string{"key-1"}, clients[1].RoutingKeys)
+
+		// a new grant replaces the previous one
+		require.NoError(t, r.Register(&Client{
+			ConnectionID: "conn-1", TheirDID: "did:wallet1", RoutingKeys: []string{"key-2"},
+		}))
+
+		clients, err = r.List()
+		require.NoError(t, err)
+		require.Len(t, clients, 2)
+		require.Equal(t, "conn-1", clients[0].ConnectionID)
+		require.Equal(t, []string{"key-2"}, clients[0].RoutingKeys)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		r, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}))
+		require.NoError(t, err)
+
+		err = r.Register(&Client{ConnectionID: "conn-1"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save mediation client")
+
+		_, err = r.List()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query mediation clients")
+	})
+
+	t.Run("iterator errors", func(t *testing.T) {
+		for _, store := range []*mockstore.MockStore{
+			{Store: make(map[string]mockstore.DBEntry), ErrNext: errors.New("next error")},
+			{Store: make(map[string]mockstore.DBEntry), ErrValue: errors.New("value error")},
+		} {
+			r, err := New(mockstore.NewCustomMockStoreProvider(store))
+			require.NoError(t, err)
+			require.NoError(t, r.Register(&Client{ConnectionID: "conn-1"}))
+
+			_, err = r.List()
+			require.Error(t, err)
+		}
+
+		p := mem.NewProvider()
+
+		r, err := New(p)
+		require.NoError(t, err)
+
+		store, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+		require.NoError(t, store.Put("conn-1", []byte("{"), storage.Tag{Name: clientTag}))
+
+		_, err = r.List()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal mediation client")
+	})
+}
@@ -0,0 +1,217 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package metering
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/trustbloc/hub-router/pkg/cloudevents"
+)
+
+const (
+	kafkaContentType    = "application/vnd.kafka.json.v2+json"
+	kafkaV3ContentType  = "application/json"
+	kafkaDefaultTimeout = 30 * time.Second
+	// recordEventType is the CloudEvents type name of the metering records.
+	recordEventType = "metering-record"
+)
+
+// HTTPClient posts the records to the Kafka REST proxy.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// KafkaSink publishes the records to a Kafka topic through a Kafka REST proxy (v2 API), keyed by record ID.
+type KafkaSink struct {
+	url      string
+	client   HTTPClient
+	timeout  time.Duration
+	ceMode   string
+	ceSource string
+}
+
+type kafkaRecords struct {
+	Records []*kafkaRecord `json:"records"`
+}
+
+type kafkaRecord struct {
+	Key   string      `json:"key"`
+	Value interface{} `json:"value"`
+}
+
+// kafkaV3Record is a record of the Kafka REST proxy v3 API, which supports the record headers.
+type kafkaV3Record struct {
+	Key     *kafkaV3Data     `json:"key"`
+	Value   *kafkaV3Data     `json:"value"`
+	Headers []*kafkaV3Header `json:"headers"`
+}
+
+type kafkaV3Data struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+type kafkaV3Header struct {
+	Name  string `json:"name"`
+	Value []byte `json:"value"`
+}
+
+// KafkaOption configures the KafkaSink.
+type KafkaOption func(k *KafkaSink)
+
+// WithKafkaCloudEvents publishes the records as CloudEvents 1.0 events of the given content mode, with the given
+// source. The binary mode sends the event attributes as record headers, which requires the REST proxy v3 API : the
+// proxy URL must then be the v3 cluster URL, eg: http://kafka-rest:8082/v3/clusters/{cluster_id}.
+func WithKafkaCloudEvents(mode, source string) KafkaOption {
+	return func(k *KafkaSink) {
+		k.ceMode = mode
+		k.ceSource = source
+	}
+}
+
+// NewKafkaSink returns a new KafkaSink publishing to the topic through the REST proxy at the given URL.
+func NewKafkaSink(proxyURL, topic string, client HTTPClient, opts ...KafkaOption) *KafkaSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	k := &KafkaSink{
+		url:     strings.TrimSuffix(proxyURL, "/") + "/topics/" + url.PathEscape(topic),
+		client:  client,
+		timeout: kafkaDefaultTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(k)
+	}
+
+	if k.ceMode == cloudevents.ModeBinary {
+		k.url += "/records"
+	}
+
+	return k
+}
+
+// Publish publishes the records to the topic.
+func (k *KafkaSink) Publish(records []*Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if k.ceMode == cloudevents.ModeBinary {
+		return k.publishBinary(records)
+	}
+
+	msg := &kafkaRecords{}
+
+	for _, r := range records {
+		value, err := k.value(r)
+		if err != nil {
+			return err
+		}
+
+		msg.Records = append(msg.Records, &kafkaRecord{Key: r.ID, Value: value})
+	}
+
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal kafka records : %w", err)
+	}
+
+	return k.post(msgBytes, kafkaContentType)
+}
+
+// value returns the record, or the CloudEvents event of the record in structured mode.
+func (k *KafkaSink) value(r *Record) (interface{}, error) {
+	if k.ceMode == "" {
+		return r, nil
+	}
+
+	return k.event(r)
+}
+
+func (k *KafkaSink) event(r *Record) (*cloudevents.Event, error) {
+	event, err := cloudevents.New(r.ID, k.ceSource, cloudevents.Type(recordEventType), r.PeriodEnd, r)
+	if err != nil {
+		return nil, fmt.Errorf("marshal kafka records : %w", err)
+	}
+
+	return event, nil
+}
+
+// publishBinary publishes the records one by one with the REST proxy v3 API, the event attributes in the headers.
+func (k *KafkaSink) publishBinary(records []*Record) error {
+	for _, r := range records {
+		event, err := k.event(r)
+		if err != nil {
+			return err
+		}
+
+		record := &kafkaV3Record{
+			Key:   &kafkaV3Data{Type: "STRING", Data: r.ID},
+			Value: &kafkaV3Data{Type: "JSON", Data: event.Data},
+			Headers: []*kafkaV3Header{
+				{Name: cloudevents.KafkaHeaderPrefix + "datacontenttype", Value: []byte(event.DataContentType)},
+			},
+		}
+
+		for name, value := range event.Headers(cloudevents.KafkaHeaderPrefix) {
+			record.Headers = append(record.Headers, &kafkaV3Header{Name: name, Value: []byte(value)})
+		}
+
+		sort.Slice(record.Headers, func(i, j int) bool { return record.Headers[i].Name < record.Headers[j].Name })
+
+		recordBytes, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("marshal kafka records : %w", err)
+		}
+
+		err = k.post(recordBytes, kafkaV3ContentType)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (k *KafkaSink) post(msgBytes []byte, contentType string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), k.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, k.url, bytes.NewReader(msgBytes))
+	if err != nil {
+		return fmt.Errorf("create kafka request : %w", err)
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post kafka records %s : %w", k.url, err)
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			logger.Warnf("failed to close kafka response body : %s", errClose)
+		}
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("post kafka records %s : unexpected status %d", k.url, resp.StatusCode)
+	}
+
+	return nil
+}
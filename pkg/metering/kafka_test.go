@@ -0,0 +1,154 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package metering
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/cloudevents"
+)
+
+func TestKafkaSink(t *testing.T) {
+	t.Run("publish", func(t *testing.T) {
+		var received struct {
+			Records []struct {
+				Key   string  `json:"key"`
+				Value *Record `json:"value"`
+			} `json:"records"`
+		}
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/topics/hub-router-metering", r.URL.Path)
+			require.Equal(t, kafkaContentType, r.Header.Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		}))
+		defer srv.Close()
+
+		sink := NewKafkaSink(srv.URL+"/", "hub-router-metering", nil)
+
+		require.NoError(t, sink.Publish(nil))
+		require.NoError(t, sink.Publish([]*Record{{ID: "tenant-1/forwards/2021-06-01T10:00:00Z", Quantity: 3,
+			Period: time.Now()}}))
+		require.Len(t, received.Records, 1)
+		require.Equal(t, "tenant-1/forwards/2021-06-01T10:00:00Z", received.Records[0].Key)
+		require.Equal(t, 3, received.Records[0].Value.Quantity)
+	})
+
+	t.Run("publish cloudevents structured", func(t *testing.T) {
+		var received struct {
+			Records []struct {
+				Key   string                     `json:"key"`
+				Value map[string]json.RawMessage `json:"value"`
+			} `json:"records"`
+		}
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/topics/hub-router-metering", r.URL.Path)
+			require.Equal(t, kafkaContentType, r.Header.Get("Content-Type"))
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		}))
+		defer srv.Close()
+
+		sink := NewKafkaSink(srv.URL, "hub-router-metering", nil,
+			WithKafkaCloudEvents(cloudevents.ModeStructured, "/hub-router"))
+
+		require.NoError(t, sink.Publish([]*Record{{ID: "record-1", Quantity: 3, PeriodEnd: time.Now()}}))
+		require.Len(t, received.Records, 1)
+		require.Equal(t, "record-1", received.Records[0].Key)
+		require.JSONEq(t, `"1.0"`, string(received.Records[0].Value["specversion"]))
+		require.JSONEq(t, `"record-1"`, string(received.Records[0].Value["id"]))
+		require.JSONEq(t, `"org.trustbloc.hub-router.metering-record"`, string(received.Records[0].Value["type"]))
+
+		record := &Record{}
+		require.NoError(t, json.Unmarshal(received.Records[0].Value["data"], record))
+		require.Equal(t, 3, record.Quantity)
+	})
+
+	t.Run("publish cloudevents binary", func(t *testing.T) {
+		received := make(chan *kafkaV3Record, 2)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/v3/clusters/cluster-1/topics/hub-router-metering/records", r.URL.Path)
+			require.Equal(t, kafkaV3ContentType, r.Header.Get("Content-Type"))
+
+			record := &kafkaV3Record{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(record))
+
+			received <- record
+		}))
+		defer srv.Close()
+
+		sink := NewKafkaSink(srv.URL+"/v3/clusters/cluster-1", "hub-router-metering", nil,
+			WithKafkaCloudEvents(cloudevents.ModeBinary, "/hub-router"))
+
+		require.NoError(t, sink.Publish([]*Record{{ID: "record-1", Quantity: 3}, {ID: "record-2", Quantity: 5}}))
+		require.Len(t, received, 2)
+
+		record := <-received
+		require.Equal(t, "STRING", record.Key.Type)
+		require.Equal(t, "record-1", record.Key.Data)
+		require.Equal(t, "JSON", record.Value.Type)
+		require.Equal(t, float64(3), record.Value.Data.(map[string]interface{})["quantity"])
+
+		headers := make(map[string]string)
+
+		for _, h := range record.Headers {
+			headers[h.Name] = string(h.Value)
+		}
+
+		require.Equal(t, "1.0", headers["ce_specversion"])
+		require.Equal(t, "record-1", headers["ce_id"])
+		require.Equal(t, "/hub-router", headers["ce_source"])
+		require.Equal(t, "application/json", headers["ce_datacontenttype"])
+	})
+
+	t.Run("publish cloudevents binary error", func(t *testing.T) {
+		err := NewKafkaSink("http://localhost", "topic", &mockHTTPClient{err: errors.New("post error")},
+			WithKafkaCloudEvents(cloudevents.ModeBinary, "/hub-router")).Publish([]*Record{{ID: "id"}})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "post error")
+	})
+
+	t.Run("unexpected status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		err := NewKafkaSink(srv.URL, "topic", nil).Publish([]*Record{{ID: "id"}})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unexpected status 500")
+	})
+
+	t.Run("post error", func(t *testing.T) {
+		err := NewKafkaSink("http://localhost", "topic", &mockHTTPClient{err: errors.New("post error")}).
+			Publish([]*Record{{ID: "id"}})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "post error")
+	})
+
+	t.Run("invalid url", func(t *testing.T) {
+		err := NewKafkaSink(":invalid", "topic", nil).Publish([]*Record{{ID: "id"}})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create kafka request")
+	})
+}
+
+type mockHTTPClient struct {
+	err error
+}
+
+func (c *mockHTTPClient) Do(*http.Request) (*http.Response, error) {
+	return nil, c.err
+}
@@ -0,0 +1,391 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package metering
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/stats"
+)
+
+const (
+	storeName = "metering"
+
+	// tag used to query the records; the value is the record period in unix seconds.
+	periodTag = "period"
+
+	// tag set on the closed periods not published to the sink yet.
+	unpublishedTag = "unpublished"
+
+	// tag set on the records, the value is the tenant ID : the records are stored in the tenant namespace if isolated.
+	tenantTag = "tenant"
+
+	recordPrefix = "record/"
+	closePrefix  = "close/"
+
+	// Period is the metering period.
+	Period = time.Hour
+
+	// Grace is the delay after the end of a period before it is closed automatically, for the late counters.
+	Grace = 5 * time.Minute
+)
+
+// ErrPeriodOpen is returned when closing a period that has not ended yet.
+var ErrPeriodOpen = errors.New("metering period not ended")
+
+var logger = log.New("hub-router/metering")
+
+// Record is the immutable usage of a tenant for a metric over a period. The ID is derived from the tenant, metric
+// and period, so that billing systems can reconcile the records and discard the duplicates.
+type Record struct {
+	ID        string    `json:"id"`
+	Tenant    string    `json:"tenant"`
+	Metric    string    `json:"metric"`
+	Quantity  int       `json:"quantity"`
+	Period    time.Time `json:"period"`
+	PeriodEnd time.Time `json:"periodEnd"`
+}
+
+// Close is the closing of a period : the records are created once, when the period is first closed.
+type Close struct {
+	Period    time.Time `json:"period"`
+	ClosedAt  time.Time `json:"closedAt"`
+	Count     int       `json:"count"`
+	Published bool      `json:"published"`
+}
+
+// Source returns the usage rollups of the tenants within [from, to).
+type Source func(from, to time.Time) ([]*stats.Rollup, error)
+
+// Sink receives the records of the closed periods.
+type Sink interface {
+	Publish(records []*Record) error
+}
+
+// Ledger records the metering records of the closed periods, and publishes them to the sink if any.
+type Ledger struct {
+	store    storage.Store
+	source   Source
+	sink     Sink
+	mutex    sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns a new metering Ledger, recording the usage returned by the source.
+func New(p storage.Provider, source Source, sink Sink) (*Ledger, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open metering store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{
+		TagNames: []string{periodTag, unpublishedTag, tenantTag},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("set metering store config : %w", err)
+	}
+
+	return &Ledger{store: store, source: source, sink: sink, stop: make(chan struct{})}, nil
+}
+
+// Close closes the period starting at the given time, truncated to the period. The period is closed once : closing
+// it again returns the records created at the first close, and retries their publication if it failed.
+func (l *Ledger) Close(period, now time.Time) (*Close, []*Record, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	period = period.UTC().Truncate(Period)
+	if period.Add(Period).After(now) {
+		return nil, nil, fmt.Errorf("%w : %s", ErrPeriodOpen, period.Format(time.RFC3339))
+	}
+
+	c, err := l.getClose(period)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var records []*Record
+
+	if c == nil {
+		c, records, err = l.close(period, now)
+	} else {
+		records, err = l.Records("", period, period.Add(Period))
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !c.Published {
+		l.publish(c, records)
+	}
+
+	return c, records, nil
+}
+
+// Records returns the records of the tenant within [from, to), ordered by period, tenant and metric. An empty
+// tenant ID returns the records of all the tenants, a zero time disables the bound.
+func (l *Ledger) Records(tenantID string, from, to time.Time) ([]*Record, error) {
+	iter, err := l.store.Query(periodTag)
+	if err != nil {
+		return nil, fmt.Errorf("query metering records : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	records := []*Record{}
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate metering records : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read metering record : %w", err)
+		}
+
+		r := &Record{}
+
+		err = json.Unmarshal(val, r)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal metering record : %w", err)
+		}
+
+		if (tenantID == "" || r.Tenant == tenantID) && (from.IsZero() || !r.Period.Before(from)) &&
+			(to.IsZero() || r.Period.Before(to)) {
+			records = append(records, r)
+		}
+	}
+
+	sortRecords(records)
+
+	return records, nil
+}
+
+// Start closes the ended periods, and retries the failed publications, periodically until Stop is called.
+func (l *Ledger) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				l.closeEnded(now.UTC())
+			case <-l.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic closing.
+func (l *Ledger) Stop() {
+	l.stopOnce.Do(func() {
+		close(l.stop)
+	})
+}
+
+// closeEnded closes the last period ended for longer than the grace delay, and retries the failed publications.
+func (l *Ledger) closeEnded(now time.Time) {
+	_, _, err := l.Close(now.Add(-Grace).Truncate(Period).Add(-Period), now)
+	if err != nil {
+		logger.Warnf("metering close : %s", err)
+	}
+
+	err = l.republish()
+	if err != nil {
+		logger.Warnf("metering publish : %s", err)
+	}
+}
+
+func (l *Ledger) close(period, now time.Time) (*Close, []*Record, error) {
+	rollups, err := l.source(period, period.Add(Period))
+	if err != nil {
+		return nil, nil, fmt.Errorf("get metering usage : %w", err)
+	}
+
+	records := []*Record{}
+
+	for _, r := range rollups {
+		if r.Tenant == "" || !r.Period.Equal(period) {
+			continue
+		}
+
+		for metric, quantity := range r.Counters {
+			records = append(records, &Record{
+				ID:        r.Tenant + "/" + metric + "/" + period.Format(time.RFC3339),
+				Tenant:    r.Tenant,
+				Metric:    metric,
+				Quantity:  quantity,
+				Period:    period,
+				PeriodEnd: period.Add(Period),
+			})
+		}
+	}
+
+	sortRecords(records)
+
+	for _, r := range records {
+		recordBytes, err := json.Marshal(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("marshal metering record : %w", err)
+		}
+
+		err = l.store.Put(recordPrefix+r.ID, recordBytes,
+			storage.Tag{Name: periodTag, Value: strconv.FormatInt(period.Unix(), 10)},
+			storage.Tag{Name: tenantTag, Value: r.Tenant})
+		if err != nil {
+			return nil, nil, fmt.Errorf("save metering record : %w", err)
+		}
+	}
+
+	c := &Close{Period: period, ClosedAt: now, Count: len(records), Published: l.sink == nil}
+
+	// the close is saved last : a period failing to close is closed again from scratch
+	err = l.saveClose(c)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	logger.Infof("metering period closed : period=%s records=%d", period.Format(time.RFC3339), len(records))
+
+	return c, records, nil
+}
+
+// publish publishes the records of the closed period to the sink; on failure, the publication is retried later.
+func (l *Ledger) publish(c *Close, records []*Record) {
+	err := l.sink.Publish(records)
+	if err != nil {
+		logger.Warnf("failed to publish metering records : period=%s : %s", c.Period.Format(time.RFC3339), err)
+
+		return
+	}
+
+	c.Published = true
+
+	err = l.saveClose(c)
+	if err != nil {
+		logger.Warnf("failed to save metering close : %s", err)
+	}
+}
+
+// republish retries the publication of the closed periods not published yet.
+func (l *Ledger) republish() error {
+	iter, err := l.store.Query(unpublishedTag)
+	if err != nil {
+		return fmt.Errorf("query unpublished metering periods : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var periods []time.Time
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("iterate unpublished metering periods : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return fmt.Errorf("read unpublished metering period : %w", err)
+		}
+
+		c := &Close{}
+
+		err = json.Unmarshal(val, c)
+		if err != nil {
+			return fmt.Errorf("unmarshal metering close : %w", err)
+		}
+
+		periods = append(periods, c.Period)
+	}
+
+	for _, period := range periods {
+		_, _, err = l.Close(period, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (l *Ledger) getClose(period time.Time) (*Close, error) {
+	closeBytes, err := l.store.Get(closePrefix + period.Format(time.RFC3339))
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get metering close : %w", err)
+	}
+
+	c := &Close{}
+
+	err = json.Unmarshal(closeBytes, c)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal metering close : %w", err)
+	}
+
+	return c, nil
+}
+
+func (l *Ledger) saveClose(c *Close) error {
+	closeBytes, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("marshal metering close : %w", err)
+	}
+
+	var tags []storage.Tag
+	if !c.Published {
+		tags = append(tags, storage.Tag{Name: unpublishedTag})
+	}
+
+	err = l.store.Put(closePrefix+c.Period.Format(time.RFC3339), closeBytes, tags...)
+	if err != nil {
+		return fmt.Errorf("save metering close : %w", err)
+	}
+
+	return nil
+}
+
+func sortRecords(records []*Record) {
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Period.Equal(records[j].Period) {
+			return records[i].Period.Before(records[j].Period)
+		}
+
+		if records[i].Tenant != records[j].Tenant {
+			return records[i].Tenant < records[j].Tenant
+		}
+
+		return records[i].Metric < records[j].Metric
+	})
+}
@@ -0,0 +1,232 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package metering
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+	"github.com/trustbloc/hub-router/pkg/stats"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, nil, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open metering store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p, nil, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set metering store config")
+	})
+}
+
+func TestLedger(t *testing.T) {
+	period := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+
+	t.Run("close once", func(t *testing.T) {
+		s, err := stats.New(mem.NewProvider(), 0)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Add("tenant-1", period.Add(time.Minute), stats.Forwards, 3))
+		require.NoError(t, s.Add("tenant-2", period.Add(time.Minute), stats.ConnectionsCreated, 1))
+		require.NoError(t, s.Add("tenant-1", period.Add(-time.Minute), stats.Forwards, 2))
+		require.NoError(t, s.Add("", period.Add(time.Minute), stats.Errors, 1))
+
+		sink := &mockSink{}
+
+		l, err := New(mem.NewProvider(), s.TenantRollups, sink)
+		require.NoError(t, err)
+
+		_, _, err = l.Close(period, period.Add(time.Minute))
+		require.ErrorIs(t, err, ErrPeriodOpen)
+
+		now := period.Add(Period + time.Minute)
+
+		c, records, err := l.Close(period.Add(time.Minute), now)
+		require.NoError(t, err)
+		require.Equal(t, period, c.Period)
+		require.Equal(t, now, c.ClosedAt)
+		require.True(t, c.Published)
+		require.Equal(t, 2, c.Count)
+		require.Len(t, records, 2)
+		require.Equal(t, "tenant-1/forwards/2021-06-01T10:00:00Z", records[0].ID)
+		require.Equal(t, 3, records[0].Quantity)
+		require.Equal(t, "tenant-2", records[1].Tenant)
+		require.Len(t, sink.published, 2)
+
+		// late counters are not metered once the period is closed
+		require.NoError(t, s.Add("tenant-1", period.Add(time.Minute), stats.Forwards, 1))
+
+		c2, records2, err := l.Close(period, now.Add(time.Hour))
+		require.NoError(t, err)
+		require.Equal(t, c, c2)
+		require.Equal(t, records, records2)
+		require.Len(t, sink.published, 2)
+
+		records, err = l.Records("tenant-1", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+
+		records, err = l.Records("", period.Add(Period), time.Time{})
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+
+	t.Run("publish retry", func(t *testing.T) {
+		s, err := stats.New(mem.NewProvider(), 0)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Add("tenant-1", period, stats.Forwards, 1))
+
+		sink := &mockSink{err: errors.New("sink error")}
+
+		l, err := New(mem.NewProvider(), s.TenantRollups, sink)
+		require.NoError(t, err)
+
+		c, _, err := l.Close(period, period.Add(2*Period))
+		require.NoError(t, err)
+		require.False(t, c.Published)
+
+		sink.err = nil
+
+		l.closeEnded(period.Add(Period + Grace))
+		require.Len(t, sink.published, 1)
+
+		c, _, err = l.Close(period, period.Add(2*Period))
+		require.NoError(t, err)
+		require.True(t, c.Published)
+	})
+
+	t.Run("no sink", func(t *testing.T) {
+		l, err := New(mem.NewProvider(), func(from, to time.Time) ([]*stats.Rollup, error) {
+			return nil, nil
+		}, nil)
+		require.NoError(t, err)
+
+		c, records, err := l.Close(period, period.Add(Period))
+		require.NoError(t, err)
+		require.True(t, c.Published)
+		require.Empty(t, records)
+	})
+
+	t.Run("source error", func(t *testing.T) {
+		l, err := New(mem.NewProvider(), func(from, to time.Time) ([]*stats.Rollup, error) {
+			return nil, errors.New("source error")
+		}, nil)
+		require.NoError(t, err)
+
+		_, _, err = l.Close(period, period.Add(Period))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get metering usage")
+
+		l.closeEnded(period.Add(2 * Period))
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		source := func(from, to time.Time) ([]*stats.Rollup, error) {
+			return []*stats.Rollup{{Period: from, Tenant: "tenant-1", Counters: map[string]int{stats.Forwards: 1}}}, nil
+		}
+
+		l, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}), source, nil)
+		require.NoError(t, err)
+
+		_, _, err = l.Close(period, period.Add(Period))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save metering record")
+
+		_, err = l.Records("", time.Time{}, time.Time{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query metering records")
+
+		require.Error(t, l.republish())
+
+		l, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), source, nil)
+		require.NoError(t, err)
+
+		_, _, err = l.Close(period, period.Add(Period))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get metering close")
+	})
+
+	t.Run("invalid data", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		l, err := New(p, nil, nil)
+		require.NoError(t, err)
+
+		require.NoError(t, l.store.Put(closePrefix+period.Format(time.RFC3339), []byte("invalid"),
+			storage.Tag{Name: unpublishedTag}))
+		require.NoError(t, l.store.Put(recordPrefix+"id", []byte("invalid"), storage.Tag{Name: periodTag}))
+
+		_, _, err = l.Close(period, period.Add(Period))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal metering close")
+
+		_, err = l.Records("", time.Time{}, time.Time{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal metering record")
+
+		require.Error(t, l.republish())
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		s, err := stats.New(mem.NewProvider(), 0)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Add("tenant-1", time.Now().Add(-Grace-Period), stats.Forwards, 1))
+
+		l, err := New(mem.NewProvider(), s.TenantRollups, nil)
+		require.NoError(t, err)
+
+		l.Start(time.Millisecond)
+		defer l.Stop()
+
+		require.Eventually(t, func() bool {
+			records, err := l.Records("", time.Time{}, time.Time{})
+
+			return err == nil && len(records) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+type mockSink struct {
+	published []*Record
+	err       error
+}
+
+func (s *mockSink) Publish(records []*Record) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	s.published = append(s.published, records...)
+
+	return nil
+}
@@ -0,0 +1,310 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType of the Prometheus text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric types.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// nolint:gochecknoglobals // read-only bucket bounds
+var (
+	// DurationBuckets are the upper bounds of the histogram buckets of the durations, in seconds.
+	DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	// SizeBuckets are the upper bounds of the histogram buckets of the message sizes, in bytes.
+	SizeBuckets = []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576}
+)
+
+// separator of the label values in the series keys.
+const separator = "\xff"
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry holds the metrics of the router, and writes them in the Prometheus text exposition format.
+type Registry struct {
+	mutex   sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry returns a new empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Counter registers a new counter with the given label names.
+func (r *Registry) Counter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: desc{name: name, help: help, labels: labels}, values: make(map[string]float64)}
+
+	r.register(c)
+
+	return c
+}
+
+// Histogram registers a new histogram with the given bucket upper bounds, sorted, and label names.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		desc: desc{name: name, help: help, labels: labels}, buckets: buckets, series: make(map[string]*histogram),
+	}
+
+	r.register(h)
+
+	return h
+}
+
+// GaugeFunc registers a new gauge collected when the metrics are written : collect returns the values keyed by the
+// value of the label.
+func (r *Registry) GaugeFunc(name, help, label string, collect func() map[string]float64) {
+	r.register(&gaugeFunc{desc: desc{name: name, help: help, labels: []string{label}}, collect: collect})
+}
+
+func (r *Registry) register(m metric) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteText writes the metrics in the Prometheus text exposition format, in the order they were registered.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mutex.Lock()
+	registered := append([]metric(nil), r.metrics...)
+	r.mutex.Unlock()
+
+	bw := bufio.NewWriter(w)
+
+	for _, m := range registered {
+		m.write(bw)
+	}
+
+	return bw.Flush()
+}
+
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d *desc) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, kind)
+}
+
+// key returns the key of the series with the given label values, the missing values empty.
+func (d *desc) key(values []string) string {
+	padded := make([]string, len(d.labels))
+	copy(padded, values)
+
+	return strings.Join(padded, separator)
+}
+
+// sample writes the sample of the series with the given key, and the bucket upper bound if not empty.
+func (d *desc) sample(w io.Writer, suffix, key, le string, value float64) {
+	pairs := make([]string, 0, len(d.labels)+1)
+
+	if len(d.labels) > 0 {
+		for i, v := range strings.Split(key, separator) {
+			pairs = append(pairs, fmt.Sprintf(`%s="%s"`, d.labels[i], escapeLabel(v)))
+		}
+	}
+
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf(`le="%s"`, le))
+	}
+
+	var labels string
+
+	if len(pairs) > 0 {
+		labels = "{" + strings.Join(pairs, ",") + "}"
+	}
+
+	fmt.Fprintf(w, "%s%s%s %s\n", d.name, suffix, labels, formatFloat(value))
+}
+
+// Counter is a monotonic counter.
+type Counter struct {
+	desc
+	mutex  sync.Mutex
+	values map[string]float64
+}
+
+// Inc increments the counter of the series with the given label values.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds the value, non-negative, to the counter of the series with the given label values.
+func (c *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		return
+	}
+
+	key := c.key(values)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.values[key] += v
+}
+
+// Value returns the counter of the series with the given label values.
+func (c *Counter) Value(values ...string) float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.values[c.key(values)]
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.header(w, TypeCounter)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, key := range sortedKeys(c.values) {
+		c.sample(w, "", key, "", c.values[key])
+	}
+}
+
+// Histogram counts the observations in buckets.
+type Histogram struct {
+	desc
+	buckets []float64
+	mutex   sync.Mutex
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// Observe records the value in the histogram of the series with the given label values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	key := h.key(values)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogram{counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+
+	s.count++
+	s.sum += v
+}
+
+// Count returns the number of observations of the series with the given label values.
+func (h *Histogram) Count(values ...string) uint64 {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if s, ok := h.series[h.key(values)]; ok {
+		return s.count
+	}
+
+	return 0
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.header(w, TypeHistogram)
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	keys := make([]string, 0, len(h.series))
+
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s := h.series[key]
+
+		for i, upper := range h.buckets {
+			h.sample(w, "_bucket", key, formatFloat(upper), float64(s.counts[i]))
+		}
+
+		h.sample(w, "_bucket", key, formatFloat(math.Inf(1)), float64(s.count))
+		h.sample(w, "_sum", key, "", s.sum)
+		h.sample(w, "_count", key, "", float64(s.count))
+	}
+}
+
+type gaugeFunc struct {
+	desc
+	collect func() map[string]float64
+}
+
+func (g *gaugeFunc) write(w io.Writer) {
+	g.header(w, TypeGauge)
+
+	values := g.collect()
+
+	for _, key := range sortedKeys(values) {
+		g.sample(w, "", key, "", values[key])
+	}
+}
+
+func sortedKeys(values map[string]float64) []string {
+	keys := make([]string, 0, len(values))
+
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func escapeHelp(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`).Replace(s)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	write := func(t *testing.T, r *Registry) string {
+		t.Helper()
+
+		buf := &bytes.Buffer{}
+		require.NoError(t, r.WriteText(buf))
+
+		return buf.String()
+	}
+
+	t.Run("counter", func(t *testing.T) {
+		r := NewRegistry()
+		c := r.Counter("hub_router_test_total", "Test counter.\nSecond line.", "tenant", "outcome")
+
+		c.Inc("acme", "granted")
+		c.Add(2, "acme", "granted")
+		c.Add(-1, "acme", "granted")
+		c.Inc(`a"b\c`)
+
+		require.Equal(t, float64(3), c.Value("acme", "granted"))
+		require.Equal(t, float64(1), c.Value(`a"b\c`, ""))
+		require.Equal(t, float64(0), c.Value("other"))
+
+		require.Equal(t, `# HELP hub_router_test_total Test counter.\nSecond line.
+# TYPE hub_router_test_total counter
+hub_router_test_total{tenant="a\"b\\c",outcome=""} 1
+hub_router_test_total{tenant="acme",outcome="granted"} 3
+`, write(t, r))
+	})
+
+	t.Run("counter without labels", func(t *testing.T) {
+		r := NewRegistry()
+		r.Counter("hub_router_test_total", "Test counter.").Add(1.5)
+
+		require.Equal(t, `# HELP hub_router_test_total Test counter.
+# TYPE hub_router_test_total counter
+hub_router_test_total 1.5
+`, write(t, r))
+	})
+
+	t.Run("histogram", func(t *testing.T) {
+		r := NewRegistry()
+		h := r.Histogram("hub_router_test_seconds", "Test histogram.", []float64{0.1, 1}, "type")
+
+		h.Observe(0.05, "a")
+		h.Observe(0.5, "a")
+		h.Observe(5, "a")
+
+		require.Equal(t, uint64(3), h.Count("a"))
+		require.Equal(t, uint64(0), h.Count("b"))
+
+		require.Equal(t, `# HELP hub_router_test_seconds Test histogram.
+# TYPE hub_router_test_seconds histogram
+hub_router_test_seconds_bucket{type="a",le="0.1"} 1
+hub_router_test_seconds_bucket{type="a",le="1"} 2
+hub_router_test_seconds_bucket{type="a",le="+Inf"} 3
+hub_router_test_seconds_sum{type="a"} 5.55
+hub_router_test_seconds_count{type="a"} 3
+`, write(t, r))
+	})
+
+	t.Run("gauge func", func(t *testing.T) {
+		r := NewRegistry()
+		r.GaugeFunc("hub_router_test_depth", "Test gauge.", "recipient", func() map[string]float64 {
+			return map[string]float64{"conn-2": 0, "conn-1": 4}
+		})
+
+		require.Equal(t, `# HELP hub_router_test_depth Test gauge.
+# TYPE hub_router_test_depth gauge
+hub_router_test_depth{recipient="conn-1"} 4
+hub_router_test_depth{recipient="conn-2"} 0
+`, write(t, r))
+	})
+
+	t.Run("registration order", func(t *testing.T) {
+		r := NewRegistry()
+		r.Counter("b_total", "B.")
+		r.Counter("a_total", "A.")
+
+		require.Equal(t, "# HELP b_total B.\n# TYPE b_total counter\n# HELP a_total A.\n# TYPE a_total counter\n",
+			write(t, r))
+	})
+
+	t.Run("format float", func(t *testing.T) {
+		require.Equal(t, "-Inf", formatFloat(math.Inf(-1)))
+		require.Equal(t, "1e+06", formatFloat(1e6))
+	})
+}
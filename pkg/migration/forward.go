@@ -0,0 +1,123 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package migration
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	"github.com/hyperledger/aries-framework-go/pkg/kms"
+)
+
+// Outbound delivers the forwards, eg: the Aries outbound dispatcher.
+type Outbound interface {
+	Forward(msg interface{}, des *service.Destination) error
+}
+
+// Mailboxes are the pickup mailboxes of the wallets, eg: the pickup queue.
+type Mailboxes interface {
+	// Drain hands the messages queued for the wallet to deliver, and removes the messages delivered.
+	Drain(theirDID string, deliver func(msg json.RawMessage) error) (int, error)
+}
+
+// Forwarder forwards the messages of the wallets handed over to their new mediator.
+type Forwarder struct {
+	outbound  Outbound
+	packager  transport.Packager
+	kms       kms.KeyManager
+	mailboxes Mailboxes
+}
+
+// NewForwarder returns a new Forwarder draining the given mailboxes, where the messages queued for the wallets are
+// stored; the packager and KMS pack the forwards for the routing keys of the new mediators.
+func NewForwarder(mailboxes Mailboxes, outbound Outbound, packager transport.Packager, km kms.KeyManager) *Forwarder {
+	return &Forwarder{outbound: outbound, packager: packager, kms: km, mailboxes: mailboxes}
+}
+
+// Forward posts the envelope, packed for the wallet, to the new mediator of the wallet.
+func (f *Forwarder) Forward(t *Target, envelope json.RawMessage) error {
+	msg := envelope
+
+	if len(t.RoutingKeys) > 0 {
+		var err error
+
+		msg, err = f.wrap(t, envelope)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := f.outbound.Forward(msg, &service.Destination{
+		ServiceEndpoint: t.Endpoint,
+		RecipientKeys:   []string{t.RecipientKey},
+	})
+	if err != nil {
+		return fmt.Errorf("forward to %s : %w", t.Endpoint, err)
+	}
+
+	return nil
+}
+
+// wrap wraps the envelope in a forward to the recipient key of the wallet, packed for the routing keys of the new
+// mediator with a single-use sender key, as the Aries outbound dispatcher does.
+func (f *Forwarder) wrap(t *Target, envelope json.RawMessage) (json.RawMessage, error) {
+	if f.packager == nil {
+		return nil, errors.New("no packager for the routing keys")
+	}
+
+	env := &model.Envelope{}
+
+	err := json.Unmarshal(envelope, env)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal envelope : %w", err)
+	}
+
+	forward, err := json.Marshal(&model.Forward{
+		Type: service.ForwardMsgType,
+		ID:   uuid.New().String(),
+		To:   t.RecipientKey,
+		Msg:  env,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("marshal forward : %w", err)
+	}
+
+	_, senderKey, err := f.kms.CreateAndExportPubKeyBytes(kms.ED25519Type)
+	if err != nil {
+		return nil, fmt.Errorf("create sender key : %w", err)
+	}
+
+	packed, err := f.packager.PackMessage(&transport.Envelope{
+		Message: forward,
+		FromKey: senderKey,
+		ToKeys:  t.RoutingKeys,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("pack forward : %w", err)
+	}
+
+	return packed, nil
+}
+
+// Drain forwards the messages queued for the wallet to its new mediator, and removes them from its mailboxes. It
+// returns the number of messages forwarded; the messages that fail to be forwarded are kept, and picked up by the
+// wallet as usual.
+func (f *Forwarder) Drain(h *Handover) (int, error) {
+	forwarded, err := f.mailboxes.Drain(h.WalletDID, func(msg json.RawMessage) error {
+		return f.Forward(&h.Target, msg)
+	})
+	if err != nil {
+		return forwarded, fmt.Errorf("drain mailboxes of %s : %w", h.WalletDID, err)
+	}
+
+	return forwarded, nil
+}
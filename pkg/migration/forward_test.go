@@ -0,0 +1,203 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package migration
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockdispatcher "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/dispatcher"
+	mockpackager "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/packager"
+	mockkms "github.com/hyperledger/aries-framework-go/pkg/mock/kms"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/pickup"
+)
+
+const envelope = `{"protected":"eyJ0eXAiOiJKV00vMS4wIn0","iv":"aXY","ciphertext":"Y2lwaGVy","tag":"dGFn"}`
+
+type recordingPackager struct {
+	mockpackager.Packager
+	packed *transport.Envelope
+}
+
+func (p *recordingPackager) PackMessage(e *transport.Envelope) ([]byte, error) {
+	p.packed = e
+
+	return p.Packager.PackMessage(e)
+}
+
+func newForwarder(t *testing.T, q *pickup.Queue, sent *[]string, sendErr error) *Forwarder {
+	t.Helper()
+
+	return NewForwarder(q, &mockdispatcher.MockOutbound{
+		ValidateForward: func(msg interface{}, des *service.Destination) error {
+			if sendErr != nil {
+				return sendErr
+			}
+
+			msgBytes, err := json.Marshal(msg)
+			require.NoError(t, err)
+
+			*sent = append(*sent, string(msgBytes))
+
+			return nil
+		},
+	}, &mockpackager.Packager{PackValue: []byte(`{"packed":true}`)},
+		&mockkms.KeyManager{CrAndExportPubKeyValue: []byte("sender-key")})
+}
+
+func newQueue(t *testing.T) *pickup.Queue {
+	t.Helper()
+
+	q, err := pickup.NewQueue(mem.NewProvider(), lock.NewLocal())
+	require.NoError(t, err)
+
+	return q
+}
+
+func TestForwarder(t *testing.T) {
+	t.Run("forward as is", func(t *testing.T) {
+		var sent []string
+
+		f := newForwarder(t, newQueue(t), &sent, nil)
+
+		require.NoError(t, f.Forward(&Target{Endpoint: "https://mediator.example.com"}, json.RawMessage(envelope)))
+		require.Equal(t, []string{envelope}, sent)
+	})
+
+	t.Run("forward wrapped for the routing keys", func(t *testing.T) {
+		var sent []string
+
+		f := newForwarder(t, newQueue(t), &sent, nil)
+
+		packager := &recordingPackager{Packager: mockpackager.Packager{PackValue: []byte(`{"packed":true}`)}}
+		f.packager = packager
+
+		target := &Target{
+			Endpoint: "https://mediator.example.com", RoutingKeys: []string{"routing-key"}, RecipientKey: "wallet-key",
+		}
+
+		require.NoError(t, f.Forward(target, json.RawMessage(envelope)))
+		require.Equal(t, []string{`{"packed":true}`}, sent)
+
+		require.Equal(t, []string{"routing-key"}, packager.packed.ToKeys)
+		require.Equal(t, []byte("sender-key"), packager.packed.FromKey)
+
+		forward := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(packager.packed.Message, &forward))
+		require.Equal(t, service.ForwardMsgType, forward["@type"])
+		require.Equal(t, "wallet-key", forward["to"])
+		require.NotNil(t, forward["msg"])
+	})
+
+	t.Run("forward errors", func(t *testing.T) {
+		var sent []string
+
+		target := &Target{
+			Endpoint: "https://mediator.example.com", RoutingKeys: []string{"routing-key"}, RecipientKey: "wallet-key",
+		}
+
+		f := newForwarder(t, newQueue(t), &sent, errors.New("send error"))
+
+		err := f.Forward(&Target{Endpoint: "https://mediator.example.com"}, json.RawMessage(envelope))
+		require.EqualError(t, err, "forward to https://mediator.example.com : send error")
+
+		err = f.Forward(target, json.RawMessage("not json"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal envelope")
+
+		f.kms = &mockkms.KeyManager{CrAndExportPubKeyErr: errors.New("kms error")}
+
+		err = f.Forward(target, json.RawMessage(envelope))
+		require.EqualError(t, err, "create sender key : kms error")
+
+		f.kms = &mockkms.KeyManager{}
+		f.packager = &mockpackager.Packager{PackErr: errors.New("pack error")}
+
+		err = f.Forward(target, json.RawMessage(envelope))
+		require.EqualError(t, err, "pack forward : pack error")
+
+		f.packager = nil
+
+		err = f.Forward(target, json.RawMessage(envelope))
+		require.EqualError(t, err, "no packager for the routing keys")
+	})
+}
+
+func TestDrain(t *testing.T) {
+	h := handover("did:1", time.Now().Add(time.Hour))
+
+	env := &model.Envelope{}
+	require.NoError(t, json.Unmarshal([]byte(envelope), env))
+
+	t.Run("queued messages forwarded", func(t *testing.T) {
+		q := newQueue(t)
+
+		require.NoError(t, q.Add("did:1", "key-1", env))
+		require.NoError(t, q.Add("did:1", "key-2", env))
+
+		var sent []string
+
+		f := newForwarder(t, q, &sent, nil)
+
+		forwarded, err := f.Drain(h)
+		require.NoError(t, err)
+		require.Equal(t, 2, forwarded)
+		require.Len(t, sent, 2)
+		require.JSONEq(t, envelope, sent[0])
+
+		depth, err := q.Depth("did:1")
+		require.NoError(t, err)
+		require.Zero(t, depth)
+
+		// nothing left to forward
+		forwarded, err = f.Drain(h)
+		require.NoError(t, err)
+		require.Zero(t, forwarded)
+
+		forwarded, err = f.Drain(handover("did:2", time.Now()))
+		require.NoError(t, err)
+		require.Zero(t, forwarded)
+	})
+
+	t.Run("failed forwards kept", func(t *testing.T) {
+		q := newQueue(t)
+
+		require.NoError(t, q.Add("did:1", "key-1", env))
+
+		var sent []string
+
+		forwarded, err := newForwarder(t, q, &sent, errors.New("send error")).Drain(h)
+		require.NoError(t, err)
+		require.Zero(t, forwarded)
+
+		depth, err := q.Depth("did:1")
+		require.NoError(t, err)
+		require.Equal(t, 1, depth)
+	})
+
+	t.Run("mailbox errors", func(t *testing.T) {
+		q, err := pickup.NewQueue(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), lock.NewLocal())
+		require.NoError(t, err)
+
+		_, err = NewForwarder(q, &mockdispatcher.MockOutbound{}, nil, &mockkms.KeyManager{}).Drain(h)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "drain mailboxes of did:1")
+	})
+}
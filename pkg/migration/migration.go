@@ -0,0 +1,374 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package migration hands the wallets moving to another mediator over : for a grace period, the messages queued for
+// the wallet, and those still forwarded to the router, are forwarded to the endpoint of the new mediator instead of
+// being queued.
+package migration
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+const (
+	// HandoverMsgType of the handover request, sent by the wallet to the router.
+	HandoverMsgType = "https://trustbloc.dev/mediator-handover/1.0/handover"
+	// HandoverAckMsgType of the reply to the handover request.
+	HandoverAckMsgType = "https://trustbloc.dev/mediator-handover/1.0/handover-ack"
+
+	storeName   = "migration"
+	handoverTag = "handover"
+	lockPrefix  = "handover-"
+
+	// AnyRevision applies the changes whatever the revision of the handover.
+	AnyRevision = -1
+)
+
+// Requesters of the handover.
+const (
+	// ViaMessage is the handover requested by the wallet, with a handover message.
+	ViaMessage = "message"
+	// ViaREST is the handover requested through the REST API, eg: by the backend of the wallet.
+	ViaREST = "rest"
+)
+
+var logger = log.New("hub-router/migration")
+
+var (
+	// ErrNotFound is returned when the wallet has no handover in progress.
+	ErrNotFound = errors.New("handover not found")
+	// ErrRevisionConflict is returned for the changes expecting another revision of the handover.
+	ErrRevisionConflict = errors.New("handover revision conflict")
+)
+
+// Target is the new mediator of the wallet : the messages are posted to its endpoint, wrapped in a forward to the
+// recipient key of the wallet packed for its routing keys, if any.
+type Target struct {
+	// Endpoint of the new mediator.
+	Endpoint string `json:"endpoint"`
+	// RoutingKeys of the new mediator, from its mediation grant.
+	RoutingKeys []string `json:"routingKeys,omitempty"`
+	// RecipientKey is the key of the wallet registered with the new mediator, the forwards are addressed to.
+	RecipientKey string `json:"recipientKey,omitempty"`
+}
+
+// Validate returns an error if the target isn't valid.
+func (t *Target) Validate() error {
+	u, err := url.Parse(t.Endpoint)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid endpoint : %s", t.Endpoint)
+	}
+
+	switch u.Scheme {
+	case "http", "https", "ws", "wss":
+	default:
+		return fmt.Errorf("unsupported endpoint scheme : %s", u.Scheme)
+	}
+
+	if len(t.RoutingKeys) > 0 && t.RecipientKey == "" {
+		return errors.New("recipient key is mandatory with routing keys")
+	}
+
+	return nil
+}
+
+// Handover of a wallet to its new mediator.
+type Handover struct {
+	Target
+	ConnectionID string    `json:"connectionID"`
+	WalletDID    string    `json:"walletDID"`
+	Via          string    `json:"via"`
+	RequestedAt  time.Time `json:"requestedAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	// Forwarded is the number of messages forwarded to the new mediator, when the handover was requested.
+	Forwarded int `json:"forwarded"`
+	// Revision is incremented on each change of the handover, from 1 when requested.
+	Revision int `json:"revision"`
+}
+
+// HandoverMsg is the handover request of the wallet, with its new mediator.
+type HandoverMsg struct {
+	ID           string   `json:"@id"`
+	Type         string   `json:"@type"`
+	Endpoint     string   `json:"endpoint"`
+	RoutingKeys  []string `json:"routingKeys,omitempty"`
+	RecipientKey string   `json:"recipientKey,omitempty"`
+}
+
+// Target returns the new mediator of the handover request.
+func (m *HandoverMsg) Target() *Target {
+	return &Target{Endpoint: m.Endpoint, RoutingKeys: m.RoutingKeys, RecipientKey: m.RecipientKey}
+}
+
+// HandoverAckMsg is the reply to the handover request : the end of the grace period, and the number of queued
+// messages forwarded to the new mediator.
+type HandoverAckMsg struct {
+	ID        string    `json:"@id"`
+	Type      string    `json:"@type"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	Forwarded int       `json:"forwarded"`
+}
+
+// Store persists the handovers in progress, by wallet DID.
+type Store struct {
+	store    storage.Store
+	locker   lock.Locker
+	now      func() time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns a new handover Store, the changes are serialized with the locker so that the revisions are checked
+// against the handovers stored.
+func New(p storage.Provider, locker lock.Locker) (*Store, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open migration store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{handoverTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set migration store config : %w", err)
+	}
+
+	return &Store{store: store, locker: locker, now: time.Now, stop: make(chan struct{})}, nil
+}
+
+// Save saves the handover of the wallet, replacing the handover in progress if any.
+func (s *Store) Save(h *Handover) error {
+	return s.SaveIf(h, AnyRevision)
+}
+
+// SaveIf is Save, applied if the handover in progress has the revision, or none is in progress with the revision 0,
+// or whatever its revision with AnyRevision : ErrRevisionConflict is returned otherwise. The revision of the handover
+// saved is set.
+func (s *Store) SaveIf(h *Handover, revision int) error {
+	unlock, err := s.locker.Lock(lockPrefix + h.WalletDID)
+	if err != nil {
+		return fmt.Errorf("lock handover : %w", err)
+	}
+
+	defer unlock()
+
+	current, err := s.current(h.WalletDID, revision)
+	if err != nil {
+		return err
+	}
+
+	saved := *h
+	saved.Revision = current + 1
+
+	handoverBytes, err := json.Marshal(&saved)
+	if err != nil {
+		return fmt.Errorf("marshal handover : %w", err)
+	}
+
+	err = s.store.Put(h.WalletDID, handoverBytes, storage.Tag{Name: handoverTag})
+	if err != nil {
+		return fmt.Errorf("save handover : %w", err)
+	}
+
+	h.Revision = saved.Revision
+
+	return nil
+}
+
+// current returns the revision of the handover of the wallet in progress, 0 if none, after checking it against the
+// revision expected.
+func (s *Store) current(walletDID string, revision int) (int, error) {
+	h, err := s.Get(walletDID)
+
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if revision != AnyRevision && revision != 0 {
+			return 0, fmt.Errorf("%w : no handover in progress", ErrRevisionConflict)
+		}
+
+		return 0, nil
+	case err != nil:
+		return 0, err
+	case revision != AnyRevision && h.Revision != revision:
+		return 0, fmt.Errorf("%w : current revision is %d", ErrRevisionConflict, h.Revision)
+	default:
+		return h.Revision, nil
+	}
+}
+
+// Get returns the handover of the wallet in progress, ErrNotFound if none or if its grace period is over.
+func (s *Store) Get(walletDID string) (*Handover, error) {
+	handoverBytes, err := s.store.Get(walletDID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get handover : %w", err)
+	}
+
+	h := &Handover{}
+
+	err = json.Unmarshal(handoverBytes, h)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal handover : %w", err)
+	}
+
+	if !s.now().Before(h.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+
+	return h, nil
+}
+
+// Cancel ends the handover of the wallet, ErrNotFound if none.
+func (s *Store) Cancel(walletDID string) error {
+	return s.CancelIf(walletDID, AnyRevision)
+}
+
+// CancelIf is Cancel, applied if the handover has the revision, or whatever its revision with AnyRevision :
+// ErrRevisionConflict is returned otherwise.
+func (s *Store) CancelIf(walletDID string, revision int) error {
+	unlock, err := s.locker.Lock(lockPrefix + walletDID)
+	if err != nil {
+		return fmt.Errorf("lock handover : %w", err)
+	}
+
+	defer unlock()
+
+	h, err := s.Get(walletDID)
+	if err != nil {
+		return err
+	}
+
+	if revision != AnyRevision && h.Revision != revision {
+		return fmt.Errorf("%w : current revision is %d", ErrRevisionConflict, h.Revision)
+	}
+
+	err = s.store.Delete(walletDID)
+	if err != nil {
+		return fmt.Errorf("delete handover : %w", err)
+	}
+
+	return nil
+}
+
+// Sweep deletes the handovers whose grace period is over.
+func (s *Store) Sweep(now time.Time) error {
+	iter, err := s.store.Query(handoverTag)
+	if err != nil {
+		return fmt.Errorf("query handovers : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var expired []string
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("iterate handovers : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		k, err := iter.Key()
+		if err != nil {
+			return fmt.Errorf("read handover key : %w", err)
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return fmt.Errorf("read handover : %w", err)
+		}
+
+		h := &Handover{}
+
+		// the handovers that can't be read are dropped
+		if err = json.Unmarshal(val, h); err != nil || !now.Before(h.ExpiresAt) {
+			expired = append(expired, k)
+		}
+	}
+
+	for _, k := range expired {
+		if err = s.end(k, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// end deletes the handover of the wallet if its grace period is still over, under the lock of the handover so that
+// a handover requested meanwhile isn't deleted.
+func (s *Store) end(walletDID string, now time.Time) error {
+	unlock, err := s.locker.Lock(lockPrefix + walletDID)
+	if err != nil {
+		return fmt.Errorf("lock handover : %w", err)
+	}
+
+	defer unlock()
+
+	handoverBytes, err := s.store.Get(walletDID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("get handover : %w", err)
+	}
+
+	h := &Handover{}
+
+	if err = json.Unmarshal(handoverBytes, h); err == nil && now.Before(h.ExpiresAt) {
+		return nil
+	}
+
+	if err = s.store.Delete(walletDID); err != nil {
+		return fmt.Errorf("delete handover : %w", err)
+	}
+
+	logger.Infof("handover ended : walletDID=%s", walletDID)
+
+	return nil
+}
+
+// Start sweeps the handovers periodically until Stop is called.
+func (s *Store) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := s.Sweep(now.UTC()); err != nil {
+					logger.Warnf("handover sweep : %s", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic sweep.
+func (s *Store) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
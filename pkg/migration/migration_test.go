@@ -0,0 +1,261 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package migration
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+func handover(walletDID string, expiresAt time.Time) *Handover {
+	return &Handover{
+		Target:       Target{Endpoint: "https://mediator.example.com"},
+		ConnectionID: "conn-1",
+		WalletDID:    walletDID,
+		Via:          ViaREST,
+		RequestedAt:  time.Now().UTC(),
+		ExpiresAt:    expiresAt,
+	}
+}
+
+func TestTarget(t *testing.T) {
+	for _, target := range []*Target{
+		{Endpoint: "https://mediator.example.com"},
+		{Endpoint: "wss://mediator.example.com/ws"},
+		{Endpoint: "https://mediator.example.com", RoutingKeys: []string{"key-1"}, RecipientKey: "key-2"},
+	} {
+		require.NoError(t, target.Validate())
+	}
+
+	for target, msg := range map[*Target]string{
+		{}:                               "invalid endpoint",
+		{Endpoint: "mediator"}:           "invalid endpoint",
+		{Endpoint: "ftp://mediator.com"}: "unsupported endpoint scheme",
+		{Endpoint: "https://mediator.example.com", RoutingKeys: []string{"key-1"}}: "recipient key is mandatory",
+	} {
+		err := target.Validate()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), msg)
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, lock.NewLocal())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open migration store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p, lock.NewLocal())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set migration store config")
+	})
+}
+
+func TestStore(t *testing.T) {
+	t.Run("handover for the grace period", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		_, err = s.Get("did:1")
+		require.True(t, errors.Is(err, ErrNotFound))
+
+		h := handover("did:1", time.Now().Add(time.Hour).UTC())
+		require.NoError(t, s.Save(h))
+
+		saved, err := s.Get("did:1")
+		require.NoError(t, err)
+		require.Equal(t, h.Endpoint, saved.Endpoint)
+		require.Equal(t, "conn-1", saved.ConnectionID)
+
+		later := time.Now().Add(2 * time.Hour)
+		s.now = func() time.Time { return later }
+
+		_, err = s.Get("did:1")
+		require.True(t, errors.Is(err, ErrNotFound))
+
+		require.True(t, errors.Is(s.Cancel("did:1"), ErrNotFound))
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		require.NoError(t, s.Save(handover("did:1", time.Now().Add(time.Hour))))
+		require.NoError(t, s.Cancel("did:1"))
+
+		_, err = s.Get("did:1")
+		require.True(t, errors.Is(err, ErrNotFound))
+	})
+
+	t.Run("sweep", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		s, err := New(p, lock.NewLocal())
+		require.NoError(t, err)
+
+		require.NoError(t, s.Save(handover("did:1", time.Now().Add(time.Hour))))
+		require.NoError(t, s.Save(handover("did:2", time.Now().Add(-time.Hour))))
+
+		store, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+		require.NoError(t, store.Put("invalid", []byte("{"), storage.Tag{Name: handoverTag}))
+
+		require.NoError(t, s.Sweep(time.Now()))
+
+		for _, k := range []string{"did:2", "invalid"} {
+			_, err = store.Get(k)
+			require.True(t, errors.Is(err, storage.ErrDataNotFound))
+		}
+
+		_, err = s.Get("did:1")
+		require.NoError(t, err)
+	})
+
+	t.Run("sweep in the background", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		require.NoError(t, s.Save(handover("did:1", time.Now())))
+
+		s.Start(time.Millisecond)
+		defer s.Stop()
+
+		require.Eventually(t, func() bool {
+			_, err = s.store.Get("did:1")
+
+			return err != nil
+		}, time.Second, time.Millisecond)
+
+		s.Stop()
+	})
+
+	t.Run("revisions", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		h := handover("did:1", time.Now().Add(time.Hour))
+		require.True(t, errors.Is(s.SaveIf(h, 1), ErrRevisionConflict))
+
+		require.NoError(t, s.SaveIf(h, 0))
+		require.Equal(t, 1, h.Revision)
+
+		require.True(t, errors.Is(s.SaveIf(h, 0), ErrRevisionConflict))
+
+		require.NoError(t, s.SaveIf(h, 1))
+		require.Equal(t, 2, h.Revision)
+
+		saved, err := s.Get("did:1")
+		require.NoError(t, err)
+		require.Equal(t, 2, saved.Revision)
+
+		require.True(t, errors.Is(s.CancelIf("did:1", 1), ErrRevisionConflict))
+		require.NoError(t, s.CancelIf("did:1", 2))
+		require.True(t, errors.Is(s.CancelIf("did:1", 2), ErrNotFound))
+	})
+
+	t.Run("handover requested while sweeping", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		require.NoError(t, s.Save(handover("did:1", time.Now().Add(time.Hour))))
+
+		// the expired handover read by the sweep was replaced
+		require.NoError(t, s.end("did:1", time.Now()))
+		require.NoError(t, s.end("did:2", time.Now()))
+
+		_, err = s.Get("did:1")
+		require.NoError(t, err)
+	})
+
+	t.Run("lock errors", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), &failingLocker{})
+		require.NoError(t, err)
+
+		for _, err = range []error{
+			s.Save(handover("did:1", time.Now())), s.Cancel("did:1"), s.end("did:1", time.Now()),
+		} {
+			require.Error(t, err)
+			require.Contains(t, err.Error(), "lock handover")
+		}
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		s, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+		}), lock.NewLocal())
+		require.NoError(t, err)
+
+		err = s.Save(handover("did:1", time.Now().Add(time.Hour)))
+		require.EqualError(t, err, "save handover : put error")
+
+		s, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:     make(map[string]mockstore.DBEntry),
+			ErrGet:    errors.New("get error"),
+			ErrPut:    errors.New("put error"),
+			ErrQuery:  errors.New("query error"),
+			ErrDelete: errors.New("delete error"),
+		}), lock.NewLocal())
+		require.NoError(t, err)
+
+		err = s.Save(handover("did:1", time.Now()))
+		require.EqualError(t, err, "get handover : get error")
+
+		err = s.end("did:1", time.Now())
+		require.EqualError(t, err, "get handover : get error")
+
+		_, err = s.Get("did:1")
+		require.EqualError(t, err, "get handover : get error")
+
+		err = s.Cancel("did:1")
+		require.EqualError(t, err, "get handover : get error")
+
+		err = s.Sweep(time.Now())
+		require.EqualError(t, err, "query handovers : query error")
+
+		s, err = New(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		require.NoError(t, s.store.Put("did:1", []byte("{")))
+
+		_, err = s.Get("did:1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal handover")
+
+		s, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:     map[string]mockstore.DBEntry{"did:1": {Value: []byte("{")}},
+			ErrDelete: errors.New("delete error"),
+		}), lock.NewLocal())
+		require.NoError(t, err)
+
+		err = s.end("did:1", time.Now())
+		require.EqualError(t, err, "delete handover : delete error")
+	})
+}
+
+type failingLocker struct{}
+
+func (l *failingLocker) Lock(string) (func(), error) {
+	return nil, errors.New("lock timeout")
+}
@@ -0,0 +1,179 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package ordering numbers the messages queued for each wallet, and delivers them in sequence : some credential
+// protocols break when their messages arrive out of order. The pickup queue keeps a counter per wallet, read,
+// incremented and written while it holds the lock of the wallet, so that the nodes of the router don't give two
+// messages of a wallet the same number; the messages of all the mailboxes of the wallet are merged by number when they
+// are picked up, whatever the time the nodes that queued them recorded.
+package ordering
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	seqField   = "seq"
+	addedField = "added_time"
+)
+
+// Stats of the sequenced messages.
+type Stats struct {
+	// Sequenced is the number of queued messages given a sequence number.
+	Sequenced uint64 `json:"sequenced"`
+	// Reordered is the number of batches delivered in sequence rather than in the order of the queued times of their
+	// messages, eg: queued by nodes whose clocks are skewed.
+	Reordered uint64 `json:"reordered"`
+}
+
+// Sequencer numbers the messages queued for the wallets, from the counter of their wallet, if enabled; when disabled,
+// the new messages aren't numbered, while the messages numbered are still delivered in sequence.
+type Sequencer struct {
+	enabled   bool
+	sequenced uint64
+	reordered uint64
+}
+
+// New returns a new Sequencer, numbering the new messages if enabled.
+func New(enabled bool) *Sequencer {
+	return &Sequencer{enabled: enabled}
+}
+
+// Enabled returns true if the new messages are numbered.
+func (s *Sequencer) Enabled() bool {
+	return s.enabled
+}
+
+// Stats returns the sequencing stats since the router started.
+func (s *Sequencer) Stats() *Stats {
+	return &Stats{
+		Sequenced: atomic.LoadUint64(&s.sequenced),
+		Reordered: atomic.LoadUint64(&s.reordered),
+	}
+}
+
+// Number gives the message the number following the counter of its wallet, if enabled, and returns the counter
+// incremented. The message is left as is if disabled.
+func (s *Sequencer) Number(msg map[string]json.RawMessage, counter uint64) uint64 {
+	if !s.enabled {
+		return counter
+	}
+
+	counter++
+	msg[seqField] = json.RawMessage(strconv.FormatUint(counter, 10))
+
+	atomic.AddUint64(&s.sequenced, 1)
+
+	return counter
+}
+
+// Renumber numbers the messages moved from another wallet from the counter of their new wallet, in delivery order,
+// and returns the counter incremented : the numbers given by the counter of their previous wallet are dropped.
+func (s *Sequencer) Renumber(msgs []map[string]json.RawMessage, counter uint64) uint64 {
+	Sort(msgs)
+
+	for _, msg := range msgs {
+		delete(msg, seqField)
+
+		counter = s.Number(msg, counter)
+	}
+
+	return counter
+}
+
+// Order sorts the messages of the mailboxes of a wallet in delivery order, see Sort, and records the batches whose
+// order differs from the order of the queued times of their messages.
+func (s *Sequencer) Order(msgs []map[string]json.RawMessage) {
+	if Sort(msgs) {
+		atomic.AddUint64(&s.reordered, 1)
+	}
+}
+
+// Sort sorts the messages in delivery order : by number, the messages not numbered first, eg: queued while the
+// sequencing was disabled, and by queued time for the messages with the same number. It returns true if the messages
+// sorted aren't in the order of their queued times.
+func Sort(msgs []map[string]json.RawMessage) bool {
+	seqs := make([]uint64, len(msgs))
+	added := make([]time.Time, len(msgs))
+
+	for i, msg := range msgs {
+		seqs[i], added[i] = Seq(msg), addedTime(msg)
+	}
+
+	sort.Stable(&messages{msgs: msgs, seqs: seqs, added: added})
+
+	for i := 1; i < len(msgs); i++ {
+		if added[i].Before(added[i-1]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Seq returns the number of the message, zero if it isn't numbered.
+func Seq(msg map[string]json.RawMessage) uint64 {
+	var seq uint64
+
+	if len(msg[seqField]) > 0 && json.Unmarshal(msg[seqField], &seq) != nil {
+		return 0
+	}
+
+	return seq
+}
+
+// Last returns the highest number of the messages, zero if none is numbered.
+func Last(msgs []map[string]json.RawMessage) uint64 {
+	var last uint64
+
+	for _, msg := range msgs {
+		if seq := Seq(msg); seq > last {
+			last = seq
+		}
+	}
+
+	return last
+}
+
+// addedTime returns the time the message was queued, zero if unknown.
+func addedTime(msg map[string]json.RawMessage) time.Time {
+	var added time.Time
+
+	if len(msg[addedField]) > 0 && json.Unmarshal(msg[addedField], &added) != nil {
+		return time.Time{}
+	}
+
+	return added
+}
+
+// messages sorts the messages with their numbers and queued times.
+type messages struct {
+	msgs  []map[string]json.RawMessage
+	seqs  []uint64
+	added []time.Time
+}
+
+func (m *messages) Len() int {
+	return len(m.msgs)
+}
+
+func (m *messages) Less(i, j int) bool {
+	if m.seqs[i] != m.seqs[j] {
+		return m.seqs[i] < m.seqs[j]
+	}
+
+	return m.added[i].Before(m.added[j])
+}
+
+func (m *messages) Swap(i, j int) {
+	m.msgs[i], m.msgs[j] = m.msgs[j], m.msgs[i]
+	m.seqs[i], m.seqs[j] = m.seqs[j], m.seqs[i]
+	m.added[i], m.added[j] = m.added[j], m.added[i]
+}
@@ -0,0 +1,128 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package ordering
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+var epoch = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC) // nolint:gochecknoglobals // test fixture
+
+// msg returns a queued message, numbered if seq is set, queued at the given offset of the epoch.
+func msg(t *testing.T, id string, seq uint64, offset time.Duration) map[string]json.RawMessage {
+	t.Helper()
+
+	added, err := epoch.Add(offset).MarshalJSON()
+	require.NoError(t, err)
+
+	m := map[string]json.RawMessage{"id": json.RawMessage(fmt.Sprintf("%q", id)), addedField: added}
+
+	if seq > 0 {
+		m[seqField] = json.RawMessage(fmt.Sprint(seq))
+	}
+
+	return m
+}
+
+func ids(t *testing.T, msgs []map[string]json.RawMessage) []string {
+	t.Helper()
+
+	ids := make([]string, len(msgs))
+
+	for i, m := range msgs {
+		require.NoError(t, json.Unmarshal(m["id"], &ids[i]))
+	}
+
+	return ids
+}
+
+func TestSequencer(t *testing.T) {
+	t.Run("messages numbered from the counter of the wallet", func(t *testing.T) {
+		s := New(true)
+		require.True(t, s.Enabled())
+
+		a, b := msg(t, "a", 0, 0), msg(t, "b", 0, 0)
+
+		counter := s.Number(a, 0)
+		counter = s.Number(b, counter)
+
+		require.Equal(t, uint64(2), counter)
+		require.Equal(t, uint64(1), Seq(a))
+		require.Equal(t, uint64(2), Seq(b))
+		require.Equal(t, uint64(2), Last([]map[string]json.RawMessage{b, a}))
+		require.Equal(t, &Stats{Sequenced: 2}, s.Stats())
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		s := New(false)
+		require.False(t, s.Enabled())
+
+		a := msg(t, "a", 0, 0)
+
+		require.Equal(t, uint64(3), s.Number(a, 3))
+		require.Zero(t, Seq(a))
+		require.Zero(t, s.Stats().Sequenced)
+	})
+
+	t.Run("messages with colliding queued times delivered in sequence", func(t *testing.T) {
+		s := New(true)
+
+		// queued in the same instant in two mailboxes of the wallet
+		msgs := []map[string]json.RawMessage{
+			msg(t, "c", 3, 0), msg(t, "a", 1, 0), msg(t, "d", 4, 0), msg(t, "b", 2, 0),
+		}
+
+		s.Order(msgs)
+		require.Equal(t, []string{"a", "b", "c", "d"}, ids(t, msgs))
+		require.Zero(t, s.Stats().Reordered)
+	})
+
+	t.Run("messages queued by skewed clocks delivered in sequence", func(t *testing.T) {
+		s := New(true)
+
+		// b was queued after a, by a node whose clock is behind
+		msgs := []map[string]json.RawMessage{msg(t, "b", 2, -time.Minute), msg(t, "a", 1, 0)}
+
+		s.Order(msgs)
+		require.Equal(t, []string{"a", "b"}, ids(t, msgs))
+		require.Equal(t, uint64(1), s.Stats().Reordered)
+	})
+
+	t.Run("messages not numbered delivered first, by queued time", func(t *testing.T) {
+		msgs := []map[string]json.RawMessage{
+			msg(t, "numbered", 1, -time.Hour), msg(t, "late", 0, time.Second), msg(t, "early", 0, 0),
+		}
+
+		require.True(t, Sort(msgs))
+		require.Equal(t, []string{"early", "late", "numbered"}, ids(t, msgs))
+	})
+
+	t.Run("messages renumbered for their new wallet", func(t *testing.T) {
+		msgs := []map[string]json.RawMessage{msg(t, "b", 2, 0), msg(t, "a", 1, 0)}
+
+		require.Equal(t, uint64(7), New(true).Renumber(msgs, 5))
+		require.Equal(t, []string{"a", "b"}, ids(t, msgs))
+		require.Equal(t, uint64(6), Seq(msgs[0]))
+		require.Equal(t, uint64(7), Seq(msgs[1]))
+
+		require.Equal(t, uint64(5), New(false).Renumber(msgs, 5))
+		require.Zero(t, Last(msgs))
+	})
+
+	t.Run("invalid fields", func(t *testing.T) {
+		m := map[string]json.RawMessage{seqField: json.RawMessage(`"one"`), addedField: json.RawMessage(`1`)}
+
+		require.Zero(t, Seq(m))
+		require.True(t, addedTime(m).IsZero())
+		require.Zero(t, Last(nil))
+	})
+}
@@ -0,0 +1,915 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pickup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/ordering"
+)
+
+const (
+	// IndexNamespace is the store of the recipient keys of the mailboxes of each wallet.
+	IndexNamespace = "pickup_index"
+
+	// keySeparator ends the wallet DID in the mailbox keys, so that they are routed to the region of the wallet.
+	keySeparator = "#"
+	lockPrefix   = "pickup-"
+	// migratedKey records in the index that the mailboxes of the Aries message pickup were migrated.
+	migratedKey = "migrated-" + messagepickup.Namespace
+
+	messagesField      = "messages"
+	countField         = "message_count"
+	sizeField          = "total_size"
+	didField           = "DID"
+	idField            = "id"
+	addedField         = "added_time"
+	msgField           = "msg"
+	lastAddedField     = "last_added_time"
+	lastDeliveredField = "last_delivered_time"
+	lastRemovedField   = "last_removed_time"
+
+	// deadLetterType is the type of the dead-letter entries of the queued messages that can't be decoded.
+	deadLetterType = "queued-message"
+	decisionDecode = "decode"
+)
+
+// Queue stores the messages queued for the wallets until they are picked up, in a mailbox per recipient key of each
+// wallet. The mailboxes use the format of the Aries message pickup, so that the wrappers of the mailbox store (limits,
+// deduplication, compression...) apply to them, and are keyed by the DID of the wallet then the recipient key. The
+// mailboxes of a wallet are written while its lock is held, and the messages delivered are removed once sent : the
+// messages of all the mailboxes of the wallet are delivered in sequence, numbered from a counter kept in the index of
+// the wallet.
+type Queue struct {
+	mailboxes   storage.Store
+	index       storage.Store
+	locker      lock.Locker
+	sequencer   *ordering.Sequencer
+	deadLetters DeadLetters
+}
+
+// DeadLetters keeps the queued messages that can't be delivered, eg: the dead-letter store of the router.
+type DeadLetters interface {
+	Put(e *deadletter.Entry) error
+}
+
+// Option configures the queue.
+type Option func(q *Queue)
+
+// WithSequencer numbers the messages queued with the sequencer, the messages aren't numbered by default.
+func WithSequencer(s *ordering.Sequencer) Option {
+	return func(q *Queue) {
+		q.sequencer = s
+	}
+}
+
+// WithDeadLetters moves the queued messages that can't be decoded to the dead letters, instead of failing the delivery
+// of the mailboxes of the wallet.
+func WithDeadLetters(d DeadLetters) Option {
+	return func(q *Queue) {
+		q.deadLetters = d
+	}
+}
+
+// NewQueue returns a new Queue storing the mailboxes in the given provider. The mailboxes are locked with the locker,
+// shared by the nodes of the router.
+func NewQueue(p storage.Provider, locker lock.Locker, opts ...Option) (*Queue, error) {
+	mailboxes, err := p.OpenStore(messagepickup.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("open mailbox store : %w", err)
+	}
+
+	index, err := p.OpenStore(IndexNamespace)
+	if err != nil {
+		return nil, fmt.Errorf("open mailbox index : %w", err)
+	}
+
+	q := &Queue{mailboxes: mailboxes, index: index, locker: locker, sequencer: ordering.New(false)}
+
+	for _, opt := range opts {
+		opt(q)
+	}
+
+	return q, nil
+}
+
+// MailboxKey returns the key of the mailbox of the recipient key of the wallet.
+func MailboxKey(theirDID, recipientKey string) string {
+	return theirDID + keySeparator + recipientKey
+}
+
+// Add queues the message for the recipient key of the wallet.
+func (q *Queue) Add(theirDID, recipientKey string, msg *model.Envelope) error {
+	unlock, err := q.lock(theirDID)
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	idx, err := q.walletIndex(theirDID)
+	if err != nil {
+		return err
+	}
+
+	counter, err := q.counter(theirDID, idx)
+	if err != nil {
+		return err
+	}
+
+	env, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal message : %w", err)
+	}
+
+	now := timestamp(time.Now())
+	queued := map[string]json.RawMessage{
+		idField: json.RawMessage(strconv.Quote(uuid.New().String())), addedField: now, msgField: env,
+	}
+
+	idx.add(recipientKey)
+	idx.NextSeq = q.sequencer.Number(queued, counter)
+
+	// the mailbox is indexed first, so that a mailbox written is always found
+	err = q.saveIndex(theirDID, idx)
+	if err != nil {
+		return err
+	}
+
+	mb, err := q.mailbox(theirDID, MailboxKey(theirDID, recipientKey))
+	if err != nil {
+		return err
+	}
+
+	mb.messages = append(mb.messages, queued)
+	mb.inbox[lastAddedField] = now
+
+	return q.save(mb)
+}
+
+// Keys returns the keys of the mailboxes of the wallet, none if no message was queued for it.
+func (q *Queue) Keys(theirDID string) ([]string, error) {
+	idx, err := q.walletIndex(theirDID)
+	if err != nil {
+		return nil, err
+	}
+
+	return idx.keys(theirDID), nil
+}
+
+// LockMailboxes locks the mailboxes of the wallet, eg: to purge them, it returns their keys and the function unlocking
+// them.
+func (q *Queue) LockMailboxes(theirDID string) ([]string, func(), error) {
+	unlock, err := q.lock(theirDID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys, err := q.Keys(theirDID)
+	if err != nil {
+		unlock()
+
+		return nil, nil, err
+	}
+
+	return keys, unlock, nil
+}
+
+// Status returns the number and size of the messages queued for the wallet, the time the oldest message waited, and
+// the last times its messages were added, delivered and removed.
+func (q *Queue) Status(theirDID string) (*messagepickup.Status, error) {
+	mbs, err := q.all(theirDID)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &messagepickup.Status{}
+
+	var first time.Time
+
+	for _, mb := range mbs {
+		for _, msg := range mb.messages {
+			if added := addedTime(msg); !added.IsZero() && (first.IsZero() || added.Before(first)) {
+				first = added
+			}
+		}
+
+		status.MessageCount += len(mb.messages)
+		status.TotalSize += mb.size()
+		status.LastAddedTime = latest(status.LastAddedTime, mb.time(lastAddedField))
+		status.LastDeliveredTime = latest(status.LastDeliveredTime, mb.time(lastDeliveredField))
+		status.LastRemovedTime = latest(status.LastRemovedTime, mb.time(lastRemovedField))
+	}
+
+	if !first.IsZero() {
+		status.DurationWaited = int(time.Since(first).Seconds())
+	}
+
+	return status, nil
+}
+
+// Depth returns the number of messages queued for the wallet.
+func (q *Queue) Depth(theirDID string) (int, error) {
+	status, err := q.Status(theirDID)
+	if err != nil {
+		return 0, err
+	}
+
+	return status.MessageCount, nil
+}
+
+// Deliver sends a batch of at most size messages queued for the wallet, the oldest first, and removes them from their
+// mailbox once sent : the messages are kept if send fails. The queued messages that can't be decoded are moved to the
+// dead letters before the batch is sent, rather than kept at the head of the mailbox; without dead letters, or if they
+// fail, nothing is sent and an error is returned. It returns the number of messages delivered.
+func (q *Queue) Deliver(theirDID string, size int, send func(msgs []*messagepickup.Message) error) (int, error) {
+	unlock, err := q.lock(theirDID)
+	if err != nil {
+		return 0, err
+	}
+
+	defer unlock()
+
+	mbs, err := q.all(theirDID)
+	if err != nil {
+		return 0, err
+	}
+
+	batch := q.oldest(mbs, size)
+
+	msgs := make([]*messagepickup.Message, 0, len(batch))
+	delivered := make(map[string]bool, len(batch))
+	deadLettered := map[string]bool{}
+
+	for _, m := range batch {
+		msg := &messagepickup.Message{}
+
+		if err = unmarshalMessage(m, msg); err != nil {
+			if err = q.deadLetter(theirDID, m, err); err != nil {
+				return 0, err
+			}
+
+			deadLettered[messageID(m)] = true
+
+			continue
+		}
+
+		delivered[messageID(m)] = true
+		msgs = append(msgs, msg)
+	}
+
+	// the dead-lettered messages are removed first, so that they aren't dead-lettered again if send fails
+	if err = q.remove(mbs, deadLettered, lastRemovedField); err != nil {
+		return 0, err
+	}
+
+	if err = send(msgs); err != nil {
+		return 0, err
+	}
+
+	if err = q.remove(mbs, delivered, lastDeliveredField, lastRemovedField); err != nil {
+		return 0, err
+	}
+
+	return len(msgs), nil
+}
+
+// deadLetter moves the queued message that can't be decoded to the dead letters.
+func (q *Queue) deadLetter(theirDID string, msg map[string]json.RawMessage, decodeErr error) error {
+	if q.deadLetters == nil {
+		return fmt.Errorf("queued message %s of %s : %w", messageID(msg), theirDID, decodeErr)
+	}
+
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal queued message : %w", err)
+	}
+
+	entry := &deadletter.Entry{MsgID: messageID(msg), MsgType: deadLetterType, Error: decodeErr.Error(), Message: raw}
+	entry.AddDecision(decisionDecode, fmt.Sprintf("queued for %s", theirDID))
+
+	if err = q.deadLetters.Put(entry); err != nil {
+		return fmt.Errorf("dead-letter queued message %s of %s : %w", messageID(msg), theirDID, err)
+	}
+
+	logger.Warnf("queued message %s of %s dead-lettered : %s", messageID(msg), theirDID, decodeErr)
+
+	return nil
+}
+
+// remove removes the given messages from the mailboxes, and sets the given times of the mailboxes updated.
+func (q *Queue) remove(mbs []*mailbox, ids map[string]bool, fields ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	now := timestamp(time.Now())
+
+	for _, mb := range mbs {
+		if !mb.remove(ids) {
+			continue
+		}
+
+		for _, field := range fields {
+			mb.inbox[field] = now
+		}
+
+		if err := q.save(mb); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Drain hands the messages queued for the wallet to deliver, eg: to forward them to the new mediator of the wallet,
+// and removes the messages delivered. The messages that fail to be delivered are kept. It returns the number of
+// messages delivered.
+func (q *Queue) Drain(theirDID string, deliver func(msg json.RawMessage) error) (int, error) {
+	unlock, err := q.lock(theirDID)
+	if err != nil {
+		return 0, err
+	}
+
+	defer unlock()
+
+	mbs, err := q.all(theirDID)
+	if err != nil {
+		return 0, err
+	}
+
+	drained := 0
+
+	for _, mb := range mbs {
+		delivered := map[string]bool{}
+
+		for _, msg := range mb.messages {
+			if len(msg[msgField]) == 0 {
+				continue
+			}
+
+			if err = deliver(msg[msgField]); err != nil {
+				logger.Warnf("failed to deliver a queued message of %s : %s", theirDID, err)
+
+				continue
+			}
+
+			delivered[messageID(msg)] = true
+		}
+
+		if !mb.remove(delivered) {
+			continue
+		}
+
+		mb.inbox[lastRemovedField] = timestamp(time.Now())
+
+		if err = q.save(mb); err != nil {
+			return drained, err
+		}
+
+		drained += len(delivered)
+	}
+
+	return drained, nil
+}
+
+// Move appends the messages queued for the previous DID of a wallet to the mailboxes of its new DID, eg: once the
+// wallet is recovered on a new device, and returns their number. The mailboxes of the previous DID are emptied.
+func (q *Queue) Move(previousDID, newDID string) (int, error) {
+	if previousDID == newDID {
+		return 0, nil
+	}
+
+	unlock, err := q.lockAll(previousDID, newDID)
+	if err != nil {
+		return 0, err
+	}
+
+	defer unlock()
+
+	previous, err := q.walletIndex(previousDID)
+	if err != nil {
+		return 0, err
+	}
+
+	mbs, err := q.read(previousDID, previous)
+	if err != nil {
+		return 0, err
+	}
+
+	moved, err := q.move(mbs, newDID)
+	if err != nil {
+		return 0, err
+	}
+
+	now := timestamp(time.Now())
+
+	for _, mb := range mbs {
+		if len(mb.messages) == 0 {
+			continue
+		}
+
+		mb.messages = nil
+		mb.inbox[lastRemovedField] = now
+
+		if err = q.save(mb); err != nil {
+			return 0, err
+		}
+	}
+
+	err = q.index.Delete(previousDID)
+	if err != nil && !errors.Is(err, storage.ErrDataNotFound) {
+		return moved, fmt.Errorf("delete mailbox index of %s : %w", previousDID, err)
+	}
+
+	logger.Infof("mailboxes moved : from=%s to=%s messages=%d", previousDID, newDID, moved)
+
+	return moved, nil
+}
+
+// move appends the messages of the mailboxes of the previous DID of a wallet to the mailboxes of the same recipient
+// keys of its new DID, numbered from the counter of the new DID. The mailboxes of the previous DID are left as is.
+func (q *Queue) move(previous []*mailbox, newDID string) (int, error) {
+	var msgs []map[string]json.RawMessage
+
+	for _, mb := range previous {
+		msgs = append(msgs, mb.messages...)
+	}
+
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	idx, err := q.walletIndex(newDID)
+	if err != nil {
+		return 0, err
+	}
+
+	counter, err := q.counter(newDID, idx)
+	if err != nil {
+		return 0, err
+	}
+
+	// the messages are maps, numbered in place
+	idx.NextSeq = q.sequencer.Renumber(msgs, counter)
+
+	for _, mb := range previous {
+		if len(mb.messages) > 0 {
+			idx.add(mb.recipientKey)
+		}
+	}
+
+	if err = q.saveIndex(newDID, idx); err != nil {
+		return 0, err
+	}
+
+	for _, mb := range previous {
+		if len(mb.messages) == 0 {
+			continue
+		}
+
+		next, err := q.mailbox(newDID, MailboxKey(newDID, mb.recipientKey))
+		if err != nil {
+			return 0, err
+		}
+
+		next.messages = append(next.messages, mb.messages...)
+
+		if err = q.save(next); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(msgs), nil
+}
+
+// Migrate moves the messages queued by the Aries message pickup before the upgrade to the mailboxes of the wallets,
+// and returns their number : the legacy mailboxes are read from the given store, keyed by the DIDs of the wallets,
+// and deleted once moved. The messages are queued in the mailbox keyed with the DID of the wallet, as the forwards
+// queued by the Aries mediator, after the messages queued since the upgrade. The migration is recorded once all the
+// wallets are migrated, so that it runs once; the wallets that fail to be migrated are migrated on the next call.
+func (q *Queue) Migrate(legacy storage.Store, dids []string) (int, error) {
+	_, err := q.index.Get(migratedKey)
+	if err == nil {
+		return 0, nil
+	}
+
+	if !errors.Is(err, storage.ErrDataNotFound) {
+		return 0, fmt.Errorf("get mailbox migration : %w", err)
+	}
+
+	migrated, failed := 0, 0
+
+	for _, did := range dids {
+		n, err := q.migrate(legacy, did)
+		if err != nil {
+			logger.Errorf("failed to migrate the mailbox of %s : %s", did, err)
+
+			failed++
+
+			continue
+		}
+
+		migrated += n
+	}
+
+	if failed > 0 {
+		return migrated, fmt.Errorf("migrate mailboxes : %d wallets failed", failed)
+	}
+
+	if err = q.index.Put(migratedKey, []byte(timestamp(time.Now()))); err != nil {
+		return migrated, fmt.Errorf("save mailbox migration : %w", err)
+	}
+
+	return migrated, nil
+}
+
+// migrate moves the messages of the legacy mailbox of the wallet to its mailboxes, and deletes the legacy mailbox.
+func (q *Queue) migrate(legacy storage.Store, theirDID string) (int, error) {
+	unlock, err := q.lock(theirDID)
+	if err != nil {
+		return 0, err
+	}
+
+	defer unlock()
+
+	value, err := legacy.Get(theirDID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return 0, nil
+	}
+
+	if err != nil {
+		return 0, fmt.Errorf("get legacy mailbox : %w", err)
+	}
+
+	mb, err := decode(theirDID, theirDID, value)
+	if err != nil {
+		return 0, err
+	}
+
+	mb.recipientKey = theirDID
+
+	moved, err := q.move([]*mailbox{mb}, theirDID)
+	if err != nil {
+		return 0, err
+	}
+
+	if err = legacy.Delete(theirDID); err != nil {
+		return moved, fmt.Errorf("delete legacy mailbox : %w", err)
+	}
+
+	logger.Infof("mailbox migrated : did=%s messages=%d", theirDID, moved)
+
+	return moved, nil
+}
+
+// walletIndex returns the index of the mailboxes of the wallet, empty if no message was queued for it.
+func (q *Queue) walletIndex(theirDID string) (*index, error) {
+	idx := &index{}
+
+	value, err := q.index.Get(theirDID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return idx, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get mailbox index of %s : %w", theirDID, err)
+	}
+
+	// the indexes written before the counters were kept per wallet are the recipient keys only
+	if len(value) > 0 && value[0] == '[' {
+		err = json.Unmarshal(value, &idx.RecipientKeys)
+	} else {
+		err = json.Unmarshal(value, idx)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal mailbox index of %s : %w", theirDID, err)
+	}
+
+	return idx, nil
+}
+
+func (q *Queue) saveIndex(theirDID string, idx *index) error {
+	value, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("marshal mailbox index : %w", err)
+	}
+
+	if err = q.index.Put(theirDID, value); err != nil {
+		return fmt.Errorf("save mailbox index of %s : %w", theirDID, err)
+	}
+
+	return nil
+}
+
+// counter returns the counter of the numbers of the messages of the wallet : the counter of its index, or the highest
+// number of its messages if the index has none, eg: the messages numbered before the counters were kept per wallet.
+func (q *Queue) counter(theirDID string, idx *index) (uint64, error) {
+	if idx.NextSeq > 0 || !q.sequencer.Enabled() {
+		return idx.NextSeq, nil
+	}
+
+	mbs, err := q.read(theirDID, idx)
+	if err != nil {
+		return 0, err
+	}
+
+	var last uint64
+
+	for _, mb := range mbs {
+		if seq := ordering.Last(mb.messages); seq > last {
+			last = seq
+		}
+	}
+
+	return last, nil
+}
+
+// all returns the mailboxes of the wallet.
+func (q *Queue) all(theirDID string) ([]*mailbox, error) {
+	idx, err := q.walletIndex(theirDID)
+	if err != nil {
+		return nil, err
+	}
+
+	return q.read(theirDID, idx)
+}
+
+// read returns the mailboxes of the index of the wallet.
+func (q *Queue) read(theirDID string, idx *index) ([]*mailbox, error) {
+	mbs := make([]*mailbox, len(idx.RecipientKeys))
+
+	for i, recipientKey := range idx.RecipientKeys {
+		mb, err := q.mailbox(theirDID, MailboxKey(theirDID, recipientKey))
+		if err != nil {
+			return nil, err
+		}
+
+		mb.recipientKey = recipientKey
+		mbs[i] = mb
+	}
+
+	return mbs, nil
+}
+
+// mailbox returns the stored mailbox, an empty mailbox if not found.
+func (q *Queue) mailbox(theirDID, key string) (*mailbox, error) {
+	value, err := q.mailboxes.Get(key)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return newMailbox(theirDID, key), nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get mailbox %s : %w", key, err)
+	}
+
+	return decode(theirDID, key, value)
+}
+
+// newMailbox returns an empty mailbox of the wallet.
+func newMailbox(theirDID, key string) *mailbox {
+	return &mailbox{key: key, inbox: map[string]json.RawMessage{didField: json.RawMessage(strconv.Quote(theirDID))}}
+}
+
+// decode decodes the stored mailbox, keeping the fields it doesn't use as is.
+func decode(theirDID, key string, value []byte) (*mailbox, error) {
+	mb := newMailbox(theirDID, key)
+
+	if json.Unmarshal(value, &mb.inbox) != nil || json.Unmarshal(mb.inbox[messagesField], &mb.messages) != nil {
+		return nil, fmt.Errorf("unmarshal mailbox %s", key)
+	}
+
+	return mb, nil
+}
+
+// save saves the mailbox with its message count and total size updated, as the Aries message pickup does.
+func (q *Queue) save(mb *mailbox) error {
+	if mb.messages == nil {
+		mb.messages = []map[string]json.RawMessage{}
+	}
+
+	messages, err := json.Marshal(mb.messages)
+	if err != nil {
+		return fmt.Errorf("marshal messages : %w", err)
+	}
+
+	mb.inbox[messagesField] = messages
+	mb.inbox[countField] = json.RawMessage(fmt.Sprint(len(mb.messages)))
+	mb.inbox[sizeField] = json.RawMessage(fmt.Sprint(len(messages)))
+
+	value, err := json.Marshal(mb.inbox)
+	if err != nil {
+		return fmt.Errorf("marshal mailbox : %w", err)
+	}
+
+	if err = q.mailboxes.Put(mb.key, value); err != nil {
+		return fmt.Errorf("save mailbox %s : %w", mb.key, err)
+	}
+
+	return nil
+}
+
+// lock locks the mailboxes of the wallet, it returns the function unlocking them.
+func (q *Queue) lock(theirDID string) (func(), error) {
+	unlock, err := q.locker.Lock(lockPrefix + theirDID)
+	if err != nil {
+		return nil, fmt.Errorf("lock mailboxes of %s : %w", theirDID, err)
+	}
+
+	return unlock, nil
+}
+
+// lockAll locks the mailboxes of the wallets, in the order of their DIDs so that two callers don't deadlock, it returns
+// the function unlocking them.
+func (q *Queue) lockAll(dids ...string) (func(), error) {
+	sort.Strings(dids)
+
+	unlocks := make([]func(), 0, len(dids))
+	unlockAll := func() {
+		for i := len(unlocks) - 1; i >= 0; i-- {
+			unlocks[i]()
+		}
+	}
+
+	for _, did := range dids {
+		unlock, err := q.lock(did)
+		if err != nil {
+			unlockAll()
+
+			return nil, err
+		}
+
+		unlocks = append(unlocks, unlock)
+	}
+
+	return unlockAll, nil
+}
+
+// index of the mailboxes of a wallet.
+type index struct {
+	// RecipientKeys are the recipient keys the messages of the wallet were queued for, a mailbox per key.
+	RecipientKeys []string `json:"recipientKeys"`
+	// NextSeq is the counter of the numbers of the messages queued for the wallet.
+	NextSeq uint64 `json:"nextSeq,omitempty"`
+}
+
+// add adds the recipient key to the index.
+func (idx *index) add(recipientKey string) {
+	for _, k := range idx.RecipientKeys {
+		if k == recipientKey {
+			return
+		}
+	}
+
+	idx.RecipientKeys = append(idx.RecipientKeys, recipientKey)
+}
+
+// keys returns the keys of the mailboxes of the wallet.
+func (idx *index) keys(theirDID string) []string {
+	keys := make([]string, len(idx.RecipientKeys))
+
+	for i, recipientKey := range idx.RecipientKeys {
+		keys[i] = MailboxKey(theirDID, recipientKey)
+	}
+
+	return keys
+}
+
+// mailbox is a decoded mailbox, keeping the fields it doesn't use as is.
+type mailbox struct {
+	key          string
+	recipientKey string
+	inbox        map[string]json.RawMessage
+	messages     []map[string]json.RawMessage
+}
+
+// remove removes the given messages, and returns true if any was removed.
+func (mb *mailbox) remove(ids map[string]bool) bool {
+	kept := make([]map[string]json.RawMessage, 0, len(mb.messages))
+
+	for _, msg := range mb.messages {
+		if !ids[messageID(msg)] {
+			kept = append(kept, msg)
+		}
+	}
+
+	removed := len(kept) < len(mb.messages)
+	mb.messages = kept
+
+	return removed
+}
+
+// size returns the size of the messages, as stored.
+func (mb *mailbox) size() int {
+	if len(mb.messages) == 0 {
+		return 0
+	}
+
+	size, err := strconv.Atoi(string(mb.inbox[sizeField]))
+	if err != nil {
+		return len(mb.inbox[messagesField])
+	}
+
+	return size
+}
+
+// time returns the time of the given field, zero if not set.
+func (mb *mailbox) time(field string) time.Time {
+	var t time.Time
+
+	if len(mb.inbox[field]) > 0 && json.Unmarshal(mb.inbox[field], &t) != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+// oldest returns at most size messages of the mailboxes, in sequence.
+func (q *Queue) oldest(mbs []*mailbox, size int) []map[string]json.RawMessage {
+	var msgs []map[string]json.RawMessage
+
+	for _, mb := range mbs {
+		msgs = append(msgs, mb.messages...)
+	}
+
+	q.sequencer.Order(msgs)
+
+	if size < 0 {
+		size = 0
+	}
+
+	if size < len(msgs) {
+		msgs = msgs[:size]
+	}
+
+	return msgs
+}
+
+func unmarshalMessage(msg map[string]json.RawMessage, m *messagepickup.Message) error {
+	raw, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshal queued message : %w", err)
+	}
+
+	if err = json.Unmarshal(raw, m); err != nil {
+		return fmt.Errorf("unmarshal queued message : %w", err)
+	}
+
+	return nil
+}
+
+// messageID returns the ID of the message, its raw value if it isn't a string.
+func messageID(msg map[string]json.RawMessage) string {
+	var id string
+
+	if len(msg[idField]) > 0 && json.Unmarshal(msg[idField], &id) != nil {
+		return string(msg[idField])
+	}
+
+	return id
+}
+
+// addedTime returns the time the message was queued, zero if unknown.
+func addedTime(msg map[string]json.RawMessage) time.Time {
+	var added time.Time
+
+	if len(msg[addedField]) > 0 && json.Unmarshal(msg[addedField], &added) != nil {
+		return time.Time{}
+	}
+
+	return added
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+
+	return a
+}
+
+func timestamp(t time.Time) json.RawMessage {
+	raw, err := t.MarshalJSON()
+	if err != nil {
+		return json.RawMessage(`""`)
+	}
+
+	return raw
+}
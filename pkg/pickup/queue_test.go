@@ -0,0 +1,543 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pickup
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/ordering"
+)
+
+func TestNewQueue(t *testing.T) {
+	p := mockstorage.NewMockProvider()
+	p.OpenStoreErr = errors.New("open error")
+
+	_, err := NewQueue(p, lock.NewLocal())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "open mailbox store")
+}
+
+// setAddedTimes sets the queued times of the messages of the mailbox.
+func setAddedTimes(t *testing.T, p storage.Provider, key string, added ...time.Time) {
+	t.Helper()
+
+	mailboxes, err := p.OpenStore(messagepickup.Namespace)
+	require.NoError(t, err)
+
+	value, err := mailboxes.Get(key)
+	require.NoError(t, err)
+
+	inbox := map[string]json.RawMessage{}
+	require.NoError(t, json.Unmarshal(value, &inbox))
+
+	var messages []map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(inbox["messages"], &messages))
+	require.Len(t, messages, len(added))
+
+	for i := range messages {
+		messages[i]["added_time"], err = added[i].MarshalJSON()
+		require.NoError(t, err)
+	}
+
+	inbox["messages"], err = json.Marshal(messages)
+	require.NoError(t, err)
+
+	value, err = json.Marshal(inbox)
+	require.NoError(t, err)
+	require.NoError(t, mailboxes.Put(key, value))
+}
+
+// putUndecodable queues a message whose envelope isn't an object, before a valid one.
+func putUndecodable(t *testing.T, q *Queue, p storage.Provider) {
+	t.Helper()
+
+	require.NoError(t, q.Add("did:1", "key-1", &model.Envelope{CipherText: "a"}))
+
+	mailboxes, err := p.OpenStore(messagepickup.Namespace)
+	require.NoError(t, err)
+
+	require.NoError(t, mailboxes.Put("did:1#key-1", []byte(`{"DID":"did:1","messages":[`+
+		`{"id":"bad","added_time":"2021-06-01T00:00:00Z","msg":"not an envelope"},`+
+		`{"id":"good","added_time":"2021-06-01T00:00:01Z","msg":{"ciphertext":"a"}}]}`)))
+}
+
+type failingDeadLetters struct{}
+
+func (failingDeadLetters) Put(*deadletter.Entry) error {
+	return errors.New("put error")
+}
+
+func TestQueue(t *testing.T) {
+	newQueue := func(t *testing.T) (*Queue, *mem.Provider) {
+		t.Helper()
+
+		p := mem.NewProvider()
+
+		q, err := NewQueue(p, lock.NewLocal())
+		require.NoError(t, err)
+
+		return q, p
+	}
+
+	t.Run("mailbox per recipient key", func(t *testing.T) {
+		q, p := newQueue(t)
+
+		require.NoError(t, q.Add("did:1", "key-1", &model.Envelope{CipherText: "a"}))
+		require.NoError(t, q.Add("did:1", "key-2", &model.Envelope{CipherText: "b"}))
+		require.NoError(t, q.Add("did:1", "key-1", &model.Envelope{CipherText: "c"}))
+
+		keys, err := q.Keys("did:1")
+		require.NoError(t, err)
+		require.Equal(t, []string{"did:1#key-1", "did:1#key-2"}, keys)
+
+		keys, err = q.Keys("did:2")
+		require.NoError(t, err)
+		require.Empty(t, keys)
+
+		status, err := q.Status("did:1")
+		require.NoError(t, err)
+		require.Equal(t, 3, status.MessageCount)
+		require.Positive(t, status.TotalSize)
+		require.False(t, status.LastAddedTime.IsZero())
+		require.True(t, status.LastDeliveredTime.IsZero())
+
+		// the mailboxes are stored in the format of the Aries message pickup
+		mailboxes, err := p.OpenStore(messagepickup.Namespace)
+		require.NoError(t, err)
+
+		value, err := mailboxes.Get("did:1#key-1")
+		require.NoError(t, err)
+
+		inbox := &struct {
+			DID          string                   `json:"DID"`
+			MessageCount int                      `json:"message_count"`
+			Messages     []*messagepickup.Message `json:"messages"`
+		}{}
+		require.NoError(t, json.Unmarshal(value, inbox))
+		require.Equal(t, "did:1", inbox.DID)
+		require.Equal(t, 2, inbox.MessageCount)
+		require.Equal(t, "c", inbox.Messages[1].Message.CipherText)
+	})
+
+	t.Run("mailboxes locked", func(t *testing.T) {
+		q, _ := newQueue(t)
+
+		require.NoError(t, q.Add("did:1", "key-1", &model.Envelope{CipherText: "a"}))
+
+		keys, unlock, err := q.LockMailboxes("did:1")
+		require.NoError(t, err)
+		require.Equal(t, []string{"did:1#key-1"}, keys)
+
+		added := make(chan error)
+
+		go func() {
+			added <- q.Add("did:1", "key-2", &model.Envelope{CipherText: "b"})
+		}()
+
+		select {
+		case <-added:
+			require.Fail(t, "message queued while the mailboxes are locked")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		unlock()
+		require.NoError(t, <-added)
+	})
+
+	t.Run("batch delivered oldest first and removed once sent", func(t *testing.T) {
+		q, _ := newQueue(t)
+
+		for _, m := range []struct{ key, text string }{{"key-1", "a"}, {"key-2", "b"}, {"key-1", "c"}} {
+			require.NoError(t, q.Add("did:1", m.key, &model.Envelope{CipherText: m.text}))
+		}
+
+		_, err := q.Deliver("did:1", 2, func([]*messagepickup.Message) error {
+			return errors.New("send error")
+		})
+		require.EqualError(t, err, "send error")
+
+		depth, err := q.Depth("did:1")
+		require.NoError(t, err)
+		require.Equal(t, 3, depth)
+
+		var sent []string
+
+		delivered, err := q.Deliver("did:1", 2, func(msgs []*messagepickup.Message) error {
+			for _, msg := range msgs {
+				sent = append(sent, msg.Message.CipherText)
+			}
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 2, delivered)
+		require.Equal(t, []string{"a", "b"}, sent)
+
+		status, err := q.Status("did:1")
+		require.NoError(t, err)
+		require.Equal(t, 1, status.MessageCount)
+		require.False(t, status.LastDeliveredTime.IsZero())
+
+		delivered, err = q.Deliver("did:1", 0, func(msgs []*messagepickup.Message) error {
+			require.Empty(t, msgs)
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Zero(t, delivered)
+	})
+
+	t.Run("messages with colliding queued times delivered in sequence", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		q, err := NewQueue(p, lock.NewLocal(), WithSequencer(ordering.New(true)))
+		require.NoError(t, err)
+
+		for _, m := range []struct{ key, text string }{{"key-2", "a"}, {"key-1", "b"}, {"key-2", "c"}, {"key-1", "d"}} {
+			require.NoError(t, q.Add("did:1", m.key, &model.Envelope{CipherText: m.text}))
+		}
+
+		// queued in the same instant, and c by a node whose clock is behind
+		now := time.Now()
+		setAddedTimes(t, p, "did:1#key-1", now, now)
+		setAddedTimes(t, p, "did:1#key-2", now, now.Add(-time.Minute))
+
+		var sent []string
+
+		delivered, err := q.Deliver("did:1", 10, func(msgs []*messagepickup.Message) error {
+			for _, msg := range msgs {
+				sent = append(sent, msg.Message.CipherText)
+			}
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 4, delivered)
+		require.Equal(t, []string{"a", "b", "c", "d"}, sent)
+	})
+
+	t.Run("counter of the wallet started from the numbered messages", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		// written before the counters were kept per wallet
+		index, err := p.OpenStore(IndexNamespace)
+		require.NoError(t, err)
+		require.NoError(t, index.Put("did:1", []byte(`["key-1"]`)))
+
+		mailboxes, err := p.OpenStore(messagepickup.Namespace)
+		require.NoError(t, err)
+		require.NoError(t, mailboxes.Put("did:1#key-1", []byte(`{"DID":"did:1","next_seq":4,`+
+			`"messages":[{"id":"a","seq":4}]}`)))
+
+		q, err := NewQueue(p, lock.NewLocal(), WithSequencer(ordering.New(true)))
+		require.NoError(t, err)
+
+		require.NoError(t, q.Add("did:1", "key-2", &model.Envelope{CipherText: "b"}))
+
+		value, err := index.Get("did:1")
+		require.NoError(t, err)
+		require.JSONEq(t, `{"recipientKeys":["key-1","key-2"],"nextSeq":5}`, string(value))
+
+		value, err = mailboxes.Get("did:1#key-2")
+		require.NoError(t, err)
+		require.Contains(t, string(value), `"seq":5`)
+	})
+
+	t.Run("fields of the messages kept", func(t *testing.T) {
+		q, p := newQueue(t)
+
+		require.NoError(t, q.Add("did:1", "key-1", &model.Envelope{CipherText: "a"}))
+		require.NoError(t, q.Add("did:1", "key-1", &model.Envelope{CipherText: "b"}))
+
+		mailboxes, err := p.OpenStore(messagepickup.Namespace)
+		require.NoError(t, err)
+
+		value, err := mailboxes.Get("did:1#key-1")
+		require.NoError(t, err)
+
+		inbox := map[string]json.RawMessage{}
+		require.NoError(t, json.Unmarshal(value, &inbox))
+
+		var messages []map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(inbox["messages"], &messages))
+
+		// eg: the sequence numbers of the ordered mailboxes
+		messages[0]["seq"], messages[1]["seq"] = json.RawMessage("1"), json.RawMessage("2")
+		inbox["messages"], err = json.Marshal(messages)
+		require.NoError(t, err)
+
+		value, err = json.Marshal(inbox)
+		require.NoError(t, err)
+		require.NoError(t, mailboxes.Put("did:1#key-1", value))
+
+		_, err = q.Deliver("did:1", 1, func([]*messagepickup.Message) error { return nil })
+		require.NoError(t, err)
+
+		value, err = mailboxes.Get("did:1#key-1")
+		require.NoError(t, err)
+		require.Contains(t, string(value), `"seq":2`)
+	})
+
+	t.Run("messages that can't be decoded dead-lettered before the batch", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		deadLetters, err := deadletter.New(p)
+		require.NoError(t, err)
+
+		q, err := NewQueue(p, lock.NewLocal(), WithDeadLetters(deadLetters))
+		require.NoError(t, err)
+
+		putUndecodable(t, q, p)
+
+		var sent []string
+
+		send := func(msgs []*messagepickup.Message) error {
+			for _, msg := range msgs {
+				sent = append(sent, msg.ID)
+			}
+
+			return nil
+		}
+
+		// the batch fails, the message that can't be decoded is dead-lettered once
+		_, err = q.Deliver("did:1", 2, func([]*messagepickup.Message) error { return errors.New("send error") })
+		require.EqualError(t, err, "send error")
+
+		delivered, err := q.Deliver("did:1", 2, send)
+		require.NoError(t, err)
+		require.Equal(t, 1, delivered)
+		require.Equal(t, []string{"good"}, sent)
+
+		depth, err := q.Depth("did:1")
+		require.NoError(t, err)
+		require.Zero(t, depth)
+
+		entries, err := deadLetters.List(deadletter.StatusDeadLettered)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, "bad", entries[0].MsgID)
+		require.Contains(t, entries[0].Error, "unmarshal queued message")
+		require.Contains(t, string(entries[0].Message), "not an envelope")
+	})
+
+	t.Run("messages that can't be decoded kept without dead letters", func(t *testing.T) {
+		q, p := newQueue(t)
+
+		putUndecodable(t, q, p)
+
+		delivered, err := q.Deliver("did:1", 2, func([]*messagepickup.Message) error {
+			require.Fail(t, "batch sent")
+
+			return nil
+		})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "queued message bad of did:1")
+		require.Zero(t, delivered)
+
+		depth, err := q.Depth("did:1")
+		require.NoError(t, err)
+		require.Equal(t, 2, depth)
+	})
+
+	t.Run("messages that can't be decoded kept if the dead letters fail", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		q, err := NewQueue(p, lock.NewLocal(), WithDeadLetters(failingDeadLetters{}))
+		require.NoError(t, err)
+
+		putUndecodable(t, q, p)
+
+		_, err = q.Deliver("did:1", 2, func([]*messagepickup.Message) error { return nil })
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "dead-letter queued message bad of did:1")
+
+		depth, err := q.Depth("did:1")
+		require.NoError(t, err)
+		require.Equal(t, 2, depth)
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		q, _ := newQueue(t)
+
+		require.NoError(t, q.Add("did:1", "key-1", &model.Envelope{CipherText: "a"}))
+		require.NoError(t, q.Add("did:1", "key-2", &model.Envelope{CipherText: "b"}))
+
+		drained, err := q.Drain("did:1", func(msg json.RawMessage) error {
+			env := &model.Envelope{}
+			require.NoError(t, json.Unmarshal(msg, env))
+
+			if env.CipherText == "b" {
+				return errors.New("forward error")
+			}
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, drained)
+
+		depth, err := q.Depth("did:1")
+		require.NoError(t, err)
+		require.Equal(t, 1, depth)
+	})
+
+	t.Run("move", func(t *testing.T) {
+		q, _ := newQueue(t)
+
+		require.NoError(t, q.Add("did:old", "key-1", &model.Envelope{CipherText: "a"}))
+		require.NoError(t, q.Add("did:old", "key-2", &model.Envelope{CipherText: "b"}))
+		require.NoError(t, q.Add("did:new", "key-1", &model.Envelope{CipherText: "c"}))
+
+		moved, err := q.Move("did:old", "did:old")
+		require.NoError(t, err)
+		require.Zero(t, moved)
+
+		moved, err = q.Move("did:old", "did:new")
+		require.NoError(t, err)
+		require.Equal(t, 2, moved)
+
+		depth, err := q.Depth("did:new")
+		require.NoError(t, err)
+		require.Equal(t, 3, depth)
+
+		keys, err := q.Keys("did:old")
+		require.NoError(t, err)
+		require.Empty(t, keys)
+
+		keys, err = q.Keys("did:new")
+		require.NoError(t, err)
+		require.Equal(t, []string{"did:new#key-1", "did:new#key-2"}, keys)
+	})
+
+	t.Run("moved messages numbered for the new did", func(t *testing.T) {
+		q, err := NewQueue(mem.NewProvider(), lock.NewLocal(), WithSequencer(ordering.New(true)))
+		require.NoError(t, err)
+
+		require.NoError(t, q.Add("did:new", "key-1", &model.Envelope{CipherText: "a"}))
+		require.NoError(t, q.Add("did:old", "key-2", &model.Envelope{CipherText: "b"}))
+		require.NoError(t, q.Add("did:old", "key-1", &model.Envelope{CipherText: "c"}))
+
+		moved, err := q.Move("did:old", "did:new")
+		require.NoError(t, err)
+		require.Equal(t, 2, moved)
+
+		require.NoError(t, q.Add("did:new", "key-2", &model.Envelope{CipherText: "d"}))
+
+		var sent []string
+
+		_, err = q.Deliver("did:new", 10, func(msgs []*messagepickup.Message) error {
+			for _, msg := range msgs {
+				sent = append(sent, msg.Message.CipherText)
+			}
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"a", "b", "c", "d"}, sent)
+	})
+
+	t.Run("mailboxes of the aries message pickup migrated once", func(t *testing.T) {
+		q, err := NewQueue(mem.NewProvider(), lock.NewLocal(), WithSequencer(ordering.New(true)))
+		require.NoError(t, err)
+
+		legacy, err := mem.NewProvider().OpenStore(messagepickup.Namespace)
+		require.NoError(t, err)
+
+		require.NoError(t, legacy.Put("did:1", []byte(`{"DID":"did:1","message_count":2,"messages":[`+
+			`{"id":"b","added_time":"2021-06-01T00:00:01Z","msg":{"ciphertext":"b"},"seq":2},`+
+			`{"id":"a","added_time":"2021-06-01T00:00:00Z","msg":{"ciphertext":"a"},"seq":1}]}`)))
+		require.NoError(t, legacy.Put("did:2", []byte(`{"messages":"not a list"}`)))
+
+		// queued since the upgrade
+		require.NoError(t, q.Add("did:1", "key-1", &model.Envelope{CipherText: "c"}))
+
+		migrated, err := q.Migrate(legacy, []string{"did:1", "did:2", "did:3"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "1 wallets failed")
+		require.Equal(t, 2, migrated)
+
+		_, err = legacy.Get("did:1")
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+
+		keys, err := q.Keys("did:1")
+		require.NoError(t, err)
+		require.Equal(t, []string{"did:1#key-1", "did:1#did:1"}, keys)
+
+		var sent []string
+
+		_, err = q.Deliver("did:1", 10, func(msgs []*messagepickup.Message) error {
+			for _, msg := range msgs {
+				sent = append(sent, msg.Message.CipherText)
+			}
+
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"c", "a", "b"}, sent)
+
+		// the failed wallet is migrated on the next call, then the migration is recorded
+		require.NoError(t, legacy.Put("did:2", []byte(`{"messages":[{"id":"d","msg":{"ciphertext":"d"}}]}`)))
+
+		migrated, err = q.Migrate(legacy, []string{"did:1", "did:2"})
+		require.NoError(t, err)
+		require.Equal(t, 1, migrated)
+
+		require.NoError(t, legacy.Put("did:3", []byte(`{"messages":[{"id":"e","msg":{"ciphertext":"e"}}]}`)))
+
+		migrated, err = q.Migrate(legacy, []string{"did:3"})
+		require.NoError(t, err)
+		require.Zero(t, migrated)
+	})
+
+	t.Run("invalid index", func(t *testing.T) {
+		q, p := newQueue(t)
+
+		index, err := p.OpenStore(IndexNamespace)
+		require.NoError(t, err)
+		require.NoError(t, index.Put("did:1", []byte(`{"recipientKeys":"key-1"}`)))
+
+		_, err = q.Keys("did:1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal mailbox index of did:1")
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		q, err := NewQueue(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store: make(map[string]mockstore.DBEntry), ErrGet: errors.New("get error"),
+		}), lock.NewLocal())
+		require.NoError(t, err)
+
+		_, err = q.Depth("did:1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get mailbox index")
+
+		require.Error(t, q.Add("did:1", "key-1", &model.Envelope{}))
+
+		_, _, err = q.LockMailboxes("did:1")
+		require.Error(t, err)
+
+		_, err = q.Migrate(nil, []string{"did:1"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get mailbox migration")
+
+		// the lock is released on error
+		_, err = q.Deliver("did:1", 1, func([]*messagepickup.Message) error { return nil })
+		require.Contains(t, err.Error(), "get mailbox index")
+	})
+}
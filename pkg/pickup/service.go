@@ -0,0 +1,251 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package pickup serves the message pickup protocol (Aries RFC 0212) in place of the Aries message pickup service :
+// the forwards the Aries mediator fails to deliver are queued in the storage of the router, in the mailboxes of each
+// wallet, and the wallets pick them up with status-request, batch-pickup and noop messages. A batch of messages is
+// removed from the mailboxes only once it is sent to the wallet.
+package pickup
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/dispatcher"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries/api"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/fingerprint"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/routes"
+)
+
+// DefaultBatchSize is the number of messages delivered in response to a noop.
+const DefaultBatchSize = 10
+
+var logger = log.New("hub-router/pickup")
+
+// Service handles the message pickup messages of the wallets. It is named as the Aries message pickup service, and
+// registered with the Aries agent before the default services : the Aries mediator and the inbound dispatcher resolve
+// it instead of the Aries one, the mediator routing the forwards and queueing those it fails to deliver with AddMessage.
+type Service struct {
+	queue    *Queue
+	outbound dispatcher.Outbound
+	routes   *routes.Store
+}
+
+// New returns a new Service queueing the messages in the given queue.
+func New(queue *Queue) *Service {
+	return &Service{queue: queue}
+}
+
+// Queue returns the queue of the messages picked up.
+func (s *Service) Queue() *Queue {
+	return s.queue
+}
+
+// Creator returns the creator of the service, registered with the Aries agent (aries.WithProtocols) : the service
+// sends its responses with the outbound dispatcher of the agent, and reads the routes of the Aries mediator from its
+// storage.
+func (s *Service) Creator() api.ProtocolSvcCreator {
+	return func(prv api.Provider) (dispatcher.ProtocolService, error) {
+		r, err := routes.Open(prv.StorageProvider())
+		if err != nil {
+			return nil, err
+		}
+
+		s.outbound = prv.OutboundDispatcher()
+		s.routes = r
+
+		return s, nil
+	}
+}
+
+// HandleInbound handles the message asynchronously, as the Aries services do.
+func (s *Service) HandleInbound(msg service.DIDCommMsg, ctx service.DIDCommContext) (string, error) {
+	go func() {
+		if err := s.handle(msg, ctx.MyDID(), ctx.TheirDID()); err != nil {
+			logger.Errorf("failed to handle %s : %s", msg.Type(), err)
+		}
+	}()
+
+	return msg.ID(), nil
+}
+
+// HandleOutbound adheres to dispatcher.ProtocolService, the router sends no pickup request.
+func (s *Service) HandleOutbound(_ service.DIDCommMsg, _, _ string) (string, error) {
+	return "", errors.New("not implemented")
+}
+
+// Accept returns true for the pickup requests of the wallets, the forwards are left to the Aries mediator.
+func (s *Service) Accept(msgType string) bool {
+	switch msgType {
+	case messagepickup.StatusRequestMsgType, messagepickup.BatchPickupMsgType, messagepickup.NoopMsgType:
+		return true
+	}
+
+	return false
+}
+
+// Name of the service, the name of the Aries message pickup service.
+func (s *Service) Name() string {
+	return messagepickup.MessagePickup
+}
+
+// AddMessage queues the message for the wallet : the Aries mediator queues the forwards it fails to deliver. The
+// mediator doesn't pass the recipient key of the forward, so it is read from the forwarded envelope : the message is
+// queued in the mailbox of its first recipient routed to the wallet. The envelopes whose recipients can't be read,
+// eg: the JWE envelopes whose recipients the mediator doesn't keep, are queued in the mailbox keyed with the DID of
+// the wallet.
+func (s *Service) AddMessage(message *model.Envelope, theirDID string) error {
+	return s.queue.Add(theirDID, s.recipientKey(message, theirDID), message)
+}
+
+// recipientKey returns the first recipient key of the envelope routed to the wallet, or the DID of the wallet.
+func (s *Service) recipientKey(message *model.Envelope, theirDID string) string {
+	if s.routes == nil {
+		return theirDID
+	}
+
+	for _, kid := range recipientKIDs(message) {
+		// the wallets register their keys either as is or as did:key
+		didKey, _ := fingerprint.CreateDIDKey(base58.Decode(kid))
+
+		for _, key := range []string{kid, didKey} {
+			did, err := s.routes.DID(key)
+			if err == nil && did == theirDID {
+				return key
+			}
+		}
+	}
+
+	logger.Debugf("no recipient of the forwarded envelope routed to %s, queued in the mailbox of the did", theirDID)
+
+	return theirDID
+}
+
+// recipientKIDs returns the recipient keys of the protected header of a legacy envelope (Aries RFC 0019), base58
+// encoded, none if the header can't be decoded.
+func recipientKIDs(message *model.Envelope) []string {
+	protected, err := base64.URLEncoding.DecodeString(message.Protected)
+	if err != nil {
+		protected, err = base64.RawURLEncoding.DecodeString(message.Protected)
+		if err != nil {
+			return nil
+		}
+	}
+
+	header := struct {
+		Recipients []struct {
+			Header struct {
+				KID string `json:"kid"`
+			} `json:"header"`
+		} `json:"recipients"`
+	}{}
+
+	if json.Unmarshal(protected, &header) != nil {
+		return nil
+	}
+
+	kids := make([]string, 0, len(header.Recipients))
+
+	for _, recipient := range header.Recipients {
+		if recipient.Header.KID != "" {
+			kids = append(kids, recipient.Header.KID)
+		}
+	}
+
+	return kids
+}
+
+func (s *Service) handle(msg service.DIDCommMsg, myDID, theirDID string) error {
+	switch msg.Type() {
+	case messagepickup.StatusRequestMsgType:
+		return s.handleStatusRequest(msg, myDID, theirDID)
+	case messagepickup.BatchPickupMsgType:
+		return s.handleBatchPickup(msg, myDID, theirDID)
+	case messagepickup.NoopMsgType:
+		return s.handleNoop(myDID, theirDID)
+	}
+
+	return fmt.Errorf("unsupported message type %s", msg.Type())
+}
+
+func (s *Service) handleStatusRequest(msg service.DIDCommMsg, myDID, theirDID string) error {
+	request := &messagepickup.StatusRequest{}
+
+	if err := msg.Decode(request); err != nil {
+		return fmt.Errorf("status request unmarshal : %w", err)
+	}
+
+	status, err := s.queue.Status(theirDID)
+	if err != nil {
+		return err
+	}
+
+	// the status takes the ID of the request, as the Aries message pickup does
+	status.Type = messagepickup.StatusMsgType
+	status.ID = msg.ID()
+	status.Thread = &decorator.Thread{ID: msg.ID()}
+
+	if request.Thread != nil {
+		status.Thread.PID = request.Thread.ID
+	}
+
+	return s.outbound.SendToDID(status, myDID, theirDID)
+}
+
+func (s *Service) handleBatchPickup(msg service.DIDCommMsg, myDID, theirDID string) error {
+	request := &messagepickup.BatchPickup{}
+
+	if err := msg.Decode(request); err != nil {
+		return fmt.Errorf("batch pickup unmarshal : %w", err)
+	}
+
+	// the batch takes the ID of the request, as the Aries message pickup does
+	delivered, err := s.queue.Deliver(theirDID, request.BatchSize, func(msgs []*messagepickup.Message) error {
+		return s.outbound.SendToDID(&messagepickup.Batch{
+			Type:     messagepickup.BatchMsgType,
+			ID:       msg.ID(),
+			Messages: msgs,
+			Thread:   &decorator.Thread{ID: msg.ID()},
+		}, myDID, theirDID)
+	})
+	if err != nil {
+		return fmt.Errorf("deliver batch : %w", err)
+	}
+
+	logger.Debugf("batch delivered : did=%s messages=%d", theirDID, delivered)
+
+	return nil
+}
+
+// handleNoop delivers the messages queued for the wallet, if any, eg: on the socket the wallet holds open.
+func (s *Service) handleNoop(myDID, theirDID string) error {
+	_, err := s.queue.Deliver(theirDID, DefaultBatchSize, func(msgs []*messagepickup.Message) error {
+		if len(msgs) == 0 {
+			return nil
+		}
+
+		return s.outbound.SendToDID(&messagepickup.Batch{
+			Type:     messagepickup.BatchMsgType,
+			ID:       uuid.New().String(),
+			Messages: msgs,
+		}, myDID, theirDID)
+	})
+	if err != nil {
+		return fmt.Errorf("deliver batch : %w", err)
+	}
+
+	return nil
+}
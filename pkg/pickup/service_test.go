@@ -0,0 +1,276 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package pickup
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/dispatcher"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries"
+	mockdispatcher "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/dispatcher"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/fingerprint"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/routes"
+)
+
+const (
+	myDID    = "did:example:router"
+	theirDID = "did:example:wallet"
+)
+
+func TestService(t *testing.T) {
+	t.Run("shadows the aries message pickup", func(t *testing.T) {
+		s, _ := newService(t)
+
+		require.Equal(t, messagepickup.MessagePickup, s.Name())
+		require.True(t, s.Accept(messagepickup.BatchPickupMsgType))
+		require.True(t, s.Accept(messagepickup.StatusRequestMsgType))
+		require.True(t, s.Accept(messagepickup.NoopMsgType))
+		require.False(t, s.Accept(service.ForwardMsgType))
+		require.False(t, s.Accept(messagepickup.BatchMsgType))
+
+		// the aries mediator resolves the service as the message pickup service
+		var _ messagepickup.ProtocolService = s
+
+		_, err := s.HandleOutbound(nil, myDID, theirDID)
+		require.Error(t, err)
+
+		// the aries mediator queues the forwards it fails to deliver for the wallet
+		require.NoError(t, s.AddMessage(&model.Envelope{CipherText: "a"}, theirDID))
+
+		keys, err := s.Queue().Keys(theirDID)
+		require.NoError(t, err)
+		require.Equal(t, []string{theirDID + "#" + theirDID}, keys)
+	})
+
+	t.Run("registered with the aries agent", func(t *testing.T) {
+		q, err := NewQueue(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		s := New(q)
+
+		framework, err := aries.New(aries.WithStoreProvider(mem.NewProvider()), aries.WithProtocols(s.Creator()))
+		require.NoError(t, err)
+
+		defer func() { require.NoError(t, framework.Close()) }()
+
+		ctx, err := framework.Context()
+		require.NoError(t, err)
+
+		// resolved by the aries mediator and the inbound dispatcher instead of the aries message pickup
+		svc, err := ctx.Service(messagepickup.MessagePickup)
+		require.NoError(t, err)
+		require.Same(t, s, svc)
+
+		// the forwards are handled by the aries mediator
+		svc, err = ctx.Service(mediator.Coordination)
+		require.NoError(t, err)
+		require.True(t, svc.(dispatcher.ProtocolService).Accept(service.ForwardMsgType))
+	})
+
+	t.Run("forward queued for its recipient key", func(t *testing.T) {
+		s, _ := newService(t)
+
+		p := mem.NewProvider()
+
+		var err error
+
+		s.routes, err = routes.Open(p)
+		require.NoError(t, err)
+		key4 := base58.Encode([]byte("public key 4"))
+		didKey4, _ := fingerprint.CreateDIDKey([]byte("public key 4"))
+
+		require.NoError(t, s.routes.Route("key-2", theirDID))
+		require.NoError(t, s.routes.Route("key-3", "did:example:other"))
+		require.NoError(t, s.routes.Route(didKey4, theirDID))
+
+		protected := func(kids ...string) string {
+			recipients := make([]map[string]interface{}, len(kids))
+			for i, kid := range kids {
+				recipients[i] = map[string]interface{}{"encrypted_key": "k", "header": map[string]string{"kid": kid}}
+			}
+
+			raw, mErr := json.Marshal(map[string]interface{}{"typ": "JWM/1.0", "recipients": recipients})
+			require.NoError(t, mErr)
+
+			return base64.URLEncoding.EncodeToString(raw)
+		}
+
+		// the first recipient routed to the wallet, the recipients routed elsewhere are skipped
+		require.NoError(t, s.AddMessage(&model.Envelope{Protected: protected("key-1", "key-3", "key-2")}, theirDID))
+		// the protected header unpadded
+		require.NoError(t, s.AddMessage(&model.Envelope{
+			Protected: strings.TrimRight(protected("key-2"), "="),
+		}, theirDID))
+		// the recipient registered as did:key
+		require.NoError(t, s.AddMessage(&model.Envelope{Protected: protected(key4)}, theirDID))
+		// no recipient routed to the wallet
+		require.NoError(t, s.AddMessage(&model.Envelope{Protected: protected("key-3")}, theirDID))
+		// the recipients can't be read
+		require.NoError(t, s.AddMessage(&model.Envelope{Protected: "not base64!"}, theirDID))
+
+		keys, err := s.Queue().Keys(theirDID)
+		require.NoError(t, err)
+		require.Equal(t, []string{theirDID + "#key-2", theirDID + "#" + didKey4, theirDID + "#" + theirDID}, keys)
+
+		status, err := s.Queue().Status(theirDID)
+		require.NoError(t, err)
+		require.Equal(t, 5, status.MessageCount)
+	})
+
+	t.Run("status request", func(t *testing.T) {
+		s, outbound := newService(t)
+
+		require.NoError(t, s.Queue().Add(theirDID, "key-1", &model.Envelope{CipherText: "a"}))
+
+		var status *messagepickup.Status
+
+		outbound.ValidateSendToDID = func(msg interface{}, _, _ string) error {
+			status = msg.(*messagepickup.Status)
+
+			return nil
+		}
+
+		request := didCommMsg(t, &messagepickup.StatusRequest{
+			Type: messagepickup.StatusRequestMsgType, ID: uuid.New().String(),
+			Thread: &decorator.Thread{ID: "thread-1"},
+		})
+
+		require.NoError(t, s.handle(request, myDID, theirDID))
+		require.Equal(t, messagepickup.StatusMsgType, status.Type)
+		require.Equal(t, request.ID(), status.ID)
+		require.Equal(t, "thread-1", status.Thread.PID)
+		require.Equal(t, 1, status.MessageCount)
+	})
+
+	t.Run("batch pickup", func(t *testing.T) {
+		s, outbound := newService(t)
+
+		require.NoError(t, s.Queue().Add(theirDID, "key-1", &model.Envelope{CipherText: "a"}))
+		require.NoError(t, s.Queue().Add(theirDID, "key-2", &model.Envelope{CipherText: "b"}))
+
+		request := didCommMsg(t, &messagepickup.BatchPickup{
+			Type: messagepickup.BatchPickupMsgType, ID: uuid.New().String(), BatchSize: 10,
+		})
+
+		// the batch isn't removed if it fails to be sent
+		outbound.SendErr = errors.New("send error")
+
+		err := s.handle(request, myDID, theirDID)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "send error")
+
+		depth, err := s.Queue().Depth(theirDID)
+		require.NoError(t, err)
+		require.Equal(t, 2, depth)
+
+		var batch *messagepickup.Batch
+
+		outbound.ValidateSendToDID = func(msg interface{}, _, _ string) error {
+			batch = msg.(*messagepickup.Batch)
+
+			return nil
+		}
+
+		require.NoError(t, s.handle(request, myDID, theirDID))
+		require.Equal(t, request.ID(), batch.ID)
+		require.Len(t, batch.Messages, 2)
+
+		depth, err = s.Queue().Depth(theirDID)
+		require.NoError(t, err)
+		require.Zero(t, depth)
+
+		// an empty batch answers the pickup of an empty queue
+		require.NoError(t, s.handle(request, myDID, theirDID))
+		require.Empty(t, batch.Messages)
+	})
+
+	t.Run("noop", func(t *testing.T) {
+		s, outbound := newService(t)
+
+		sent := 0
+
+		outbound.ValidateSendToDID = func(msg interface{}, _, _ string) error {
+			sent++
+
+			require.Len(t, msg.(*messagepickup.Batch).Messages, 1)
+
+			return nil
+		}
+
+		noop := didCommMsg(t, &messagepickup.Noop{Type: messagepickup.NoopMsgType, ID: uuid.New().String()})
+
+		require.NoError(t, s.handle(noop, myDID, theirDID))
+		require.Zero(t, sent)
+
+		require.NoError(t, s.Queue().Add(theirDID, "key-1", &model.Envelope{CipherText: "a"}))
+
+		require.NoError(t, s.handle(noop, myDID, theirDID))
+		require.Equal(t, 1, sent)
+	})
+
+	t.Run("handled asynchronously", func(t *testing.T) {
+		s, outbound := newService(t)
+
+		sent := make(chan interface{}, 1)
+
+		outbound.ValidateSendToDID = func(msg interface{}, _, _ string) error {
+			sent <- msg
+
+			return nil
+		}
+
+		request := didCommMsg(t, &messagepickup.StatusRequest{
+			Type: messagepickup.StatusRequestMsgType, ID: uuid.New().String(),
+		})
+
+		id, err := s.HandleInbound(request, service.NewDIDCommContext(myDID, theirDID, nil))
+		require.NoError(t, err)
+		require.Equal(t, request.ID(), id)
+		require.IsType(t, &messagepickup.Status{}, <-sent)
+	})
+}
+
+func newService(t *testing.T) (*Service, *mockdispatcher.MockOutbound) {
+	t.Helper()
+
+	q, err := NewQueue(mem.NewProvider(), lock.NewLocal())
+	require.NoError(t, err)
+
+	outbound := &mockdispatcher.MockOutbound{}
+
+	s := New(q)
+	s.outbound = outbound
+
+	return s, outbound
+}
+
+func didCommMsg(t *testing.T, msg interface{}) service.DIDCommMsgMap {
+	t.Helper()
+
+	raw, err := json.Marshal(msg)
+	require.NoError(t, err)
+
+	m, err := service.ParseDIDCommMsgMap(raw)
+	require.NoError(t, err)
+
+	return m
+}
@@ -0,0 +1,58 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package policy
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter counts the requests per key over fixed one minute windows.
+type Limiter struct {
+	mutex   sync.Mutex
+	windows map[string]*window
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+// NewLimiter returns a new Limiter.
+func NewLimiter() *Limiter {
+	return &Limiter{windows: make(map[string]*window)}
+}
+
+// Allow counts the request and returns true if the key made at most limit requests in the current minute. A zero
+// limit allows all the requests.
+func (l *Limiter) Allow(key string, limit int, now time.Time) bool {
+	if limit <= 0 {
+		return true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	start := now.Truncate(time.Minute)
+
+	w, ok := l.windows[key]
+	if !ok || !w.start.Equal(start) {
+		// the windows of the previous minutes are expired
+		for k, prev := range l.windows {
+			if !prev.start.Equal(start) {
+				delete(l.windows, k)
+			}
+		}
+
+		w = &window{start: start}
+		l.windows[key] = w
+	}
+
+	w.count++
+
+	return w.count <= limit
+}
@@ -0,0 +1,28 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiter(t *testing.T) {
+	l := NewLimiter()
+	now := time.Date(2021, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	require.True(t, l.Allow("key-1", 2, now))
+	require.True(t, l.Allow("key-1", 2, now.Add(time.Second)))
+	require.False(t, l.Allow("key-1", 2, now.Add(2*time.Second)))
+	require.True(t, l.Allow("key-2", 2, now))
+	require.True(t, l.Allow("key-1", 0, now))
+
+	require.True(t, l.Allow("key-1", 2, now.Add(time.Minute)))
+	require.Len(t, l.windows, 1)
+}
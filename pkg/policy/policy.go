@@ -0,0 +1,428 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+const (
+	storeName  = "policy"
+	currentKey = "current"
+	lockName   = "policy"
+
+	// AnyVersion applies the update whatever the current version.
+	AnyVersion = -1
+)
+
+var (
+	// ErrInvalid is returned when the policy document is not valid.
+	ErrInvalid = errors.New("invalid policy")
+	// ErrVersionConflict is returned when the policy document is not based on the current version.
+	ErrVersionConflict = errors.New("policy version conflict")
+	// ErrNotFound is returned when the policy version does not exist.
+	ErrNotFound = errors.New("policy version not found")
+)
+
+var logger = log.New("hub-router/policy")
+
+var (
+	didMethodPattern = regexp.MustCompile(`^[a-z0-9]+$`)
+	didPattern       = regexp.MustCompile(`^did:[a-z0-9]+:.+`)
+)
+
+// Document is the declarative router policy. The sections not set apply no restriction.
+type Document struct {
+	// Version is assigned by the router. When set in an update, it must be the current version.
+	Version    int         `json:"version,omitempty"`
+	UpdatedAt  *time.Time  `json:"updatedAt,omitempty"`
+	RateLimits *RateLimits `json:"rateLimits,omitempty"`
+	Allowlists *Allowlists `json:"allowlists,omitempty"`
+	Quotas     *Quotas     `json:"quotas,omitempty"`
+	AutoAccept *AutoAccept `json:"autoAccept,omitempty"`
+}
+
+// RateLimits are the maximum number of requests per minute, zero for no limit.
+type RateLimits struct {
+	// InvitationsPerMinute limits the invitations created by each tenant, and by the operator.
+	InvitationsPerMinute int `json:"invitationsPerMinute,omitempty"`
+	// ConnectionsPerMinute limits the connection requests from the wallets (DID exchange and create-conn-req).
+	ConnectionsPerMinute int `json:"connectionsPerMinute,omitempty"`
+}
+
+// Allowlists restrict the requests accepted by the router, empty lists allow all.
+type Allowlists struct {
+	// DIDMethods are the DID methods of the wallet DIDs accepted in the connection requests, eg: peer.
+	DIDMethods []string `json:"didMethods,omitempty"`
+	// MsgTypes are the DIDComm message types handled by the router message services.
+	MsgTypes []string `json:"msgTypes,omitempty"`
+	// MediationDIDs are the wallet DIDs granted mediation.
+	MediationDIDs []string `json:"mediationDIDs,omitempty"`
+}
+
+// Quotas are the maximum usage per tenant, zero for no quota.
+type Quotas struct {
+	// ConnectionsPerDay limits the connections created for each tenant over the last 24 hours.
+	ConnectionsPerDay int `json:"connectionsPerDay,omitempty"`
+	// MediationsPerTenant limits the wallets granted mediation for each tenant.
+	MediationsPerTenant int `json:"mediationsPerTenant,omitempty"`
+}
+
+// AutoAccept rules apply to the DID exchange and mediation requests, accepted by default.
+type AutoAccept struct {
+	// DIDExchange accepts the DID exchange requests, rejected if false.
+	DIDExchange *bool `json:"didExchange,omitempty"`
+	// Mediation grants the mediation requests, rejected if false.
+	Mediation *bool `json:"mediation,omitempty"`
+	// TenantsOnly accepts the requests on the connections of the tenants only.
+	TenantsOnly bool `json:"tenantsOnly,omitempty"`
+	// ManualMediation holds the mediation requests accepted by the other rules until the operator approves or denies
+	// them. The mediation auto-granted on create-conn-req is skipped.
+	ManualMediation bool `json:"manualMediation,omitempty"`
+}
+
+// Parse parses the policy document, rejecting the unknown fields.
+func Parse(data []byte) (*Document, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	doc := &Document{}
+
+	err := decoder.Decode(doc)
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalid, err)
+	}
+
+	return doc, nil
+}
+
+// Validate returns the problems found in the policy document, wrapped in ErrInvalid.
+func (d *Document) Validate() error {
+	var problems []string
+
+	if d.RateLimits != nil {
+		problems = append(problems, nonNegative("rateLimits.invitationsPerMinute", d.RateLimits.InvitationsPerMinute)...)
+		problems = append(problems, nonNegative("rateLimits.connectionsPerMinute", d.RateLimits.ConnectionsPerMinute)...)
+	}
+
+	if d.Quotas != nil {
+		problems = append(problems, nonNegative("quotas.connectionsPerDay", d.Quotas.ConnectionsPerDay)...)
+		problems = append(problems, nonNegative("quotas.mediationsPerTenant", d.Quotas.MediationsPerTenant)...)
+	}
+
+	if d.Allowlists != nil {
+		for i, method := range d.Allowlists.DIDMethods {
+			if !didMethodPattern.MatchString(method) {
+				problems = append(problems, fmt.Sprintf("allowlists.didMethods[%d] : invalid DID method %q", i, method))
+			}
+		}
+
+		for i, msgType := range d.Allowlists.MsgTypes {
+			if strings.TrimSpace(msgType) == "" {
+				problems = append(problems, fmt.Sprintf("allowlists.msgTypes[%d] : empty message type", i))
+			}
+		}
+
+		for i, didID := range d.Allowlists.MediationDIDs {
+			if !didPattern.MatchString(didID) {
+				problems = append(problems, fmt.Sprintf("allowlists.mediationDIDs[%d] : invalid DID %q", i, didID))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w : %s", ErrInvalid, strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// Equal returns true if the documents define the same policy, regardless of their version.
+func (d *Document) Equal(other *Document) bool {
+	a, b := *d, *other
+	a.Version, b.Version = 0, 0
+	a.UpdatedAt, b.UpdatedAt = nil, nil
+
+	return reflect.DeepEqual(a, b)
+}
+
+// AllowDIDMethod returns true if the DID method is allowed in the connection requests.
+func (d *Document) AllowDIDMethod(didID string) bool {
+	if d.Allowlists == nil || len(d.Allowlists.DIDMethods) == 0 {
+		return true
+	}
+
+	parts := strings.SplitN(didID, ":", 3) // nolint:gomnd // did:method:id
+
+	return len(parts) == 3 && contains(d.Allowlists.DIDMethods, parts[1])
+}
+
+// AllowMsgType returns true if the message type is allowed.
+func (d *Document) AllowMsgType(msgType string) bool {
+	return d.Allowlists == nil || len(d.Allowlists.MsgTypes) == 0 || contains(d.Allowlists.MsgTypes, msgType)
+}
+
+// AllowMediationDID returns true if the wallet DID is granted mediation.
+func (d *Document) AllowMediationDID(didID string) bool {
+	return d.Allowlists == nil || len(d.Allowlists.MediationDIDs) == 0 || contains(d.Allowlists.MediationDIDs, didID)
+}
+
+// AcceptDIDExchange returns true if the DID exchange requests for the tenant are accepted.
+func (d *Document) AcceptDIDExchange(tenantID string) bool {
+	return d.AutoAccept == nil || (isTrue(d.AutoAccept.DIDExchange) && (!d.AutoAccept.TenantsOnly || tenantID != ""))
+}
+
+// AcceptMediation returns true if the mediation requests for the tenant are granted.
+func (d *Document) AcceptMediation(tenantID string) bool {
+	return d.AutoAccept == nil || (isTrue(d.AutoAccept.Mediation) && (!d.AutoAccept.TenantsOnly || tenantID != ""))
+}
+
+// ManualMediation returns true if the mediation requests are held until approved or denied by the operator.
+func (d *Document) ManualMediation() bool {
+	return d.AutoAccept != nil && d.AutoAccept.ManualMediation
+}
+
+// DefaultRefreshInterval is the time after which the cached policy document is reloaded from the storage, so that the
+// updates made by the other nodes sharing the storage are applied.
+const DefaultRefreshInterval = 5 * time.Second
+
+// Store persists the versions of the policy document. The current version is cached, and reloaded once the refresh
+// interval elapsed; the updates are checked against the current version stored, while its lock is held.
+type Store struct {
+	store   storage.Store
+	locker  lock.Locker
+	refresh time.Duration
+	now     func() time.Time
+	// reloadMutex serializes the reloads of the current version.
+	reloadMutex sync.Mutex
+	mutex       sync.RWMutex
+	current     *Document
+	loaded      time.Time
+}
+
+// Option configures the Store.
+type Option func(s *Store)
+
+// WithRefreshInterval sets the time after which the current version is reloaded, DefaultRefreshInterval by default.
+func WithRefreshInterval(interval time.Duration) Option {
+	return func(s *Store) {
+		s.refresh = interval
+	}
+}
+
+// New returns a new policy Store, loading the current version. The locker serializes the updates by the nodes sharing
+// the storage.
+func New(p storage.Provider, locker lock.Locker, opts ...Option) (*Store, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open policy store : %w", err)
+	}
+
+	s := &Store{store: store, locker: locker, refresh: DefaultRefreshInterval, now: time.Now}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if _, err = s.Latest(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Current returns the current policy document, empty if none was set. It is reloaded from the storage once the
+// refresh interval elapsed, the cached version is kept if the storage fails.
+func (s *Store) Current() *Document {
+	if s.stale() {
+		s.reload()
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.current
+}
+
+// Latest returns the current policy document read from the storage, empty if none was set.
+func (s *Store) Latest() (*Document, error) {
+	current, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache(current)
+
+	return current, nil
+}
+
+func (s *Store) reload() {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	// reloaded while waiting for the mutex
+	if !s.stale() {
+		return
+	}
+
+	if _, err := s.Latest(); err != nil {
+		logger.Warnf("failed to reload the policy : %s", err)
+
+		s.mutex.Lock()
+		s.loaded = s.now()
+		s.mutex.Unlock()
+	}
+}
+
+func (s *Store) stale() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return s.now().Sub(s.loaded) > s.refresh
+}
+
+func (s *Store) cache(current *Document) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.current, s.loaded = current, s.now()
+}
+
+// read returns the current version stored, empty if none was set.
+func (s *Store) read() (*Document, error) {
+	current, err := s.get(currentKey)
+	if errors.Is(err, ErrNotFound) {
+		return &Document{}, nil
+	}
+
+	return current, err
+}
+
+// Get returns the given version of the policy document.
+func (s *Store) Get(version int) (*Document, error) {
+	return s.get(strconv.Itoa(version))
+}
+
+// Put validates the document and saves it as the new version, unless it is equal to the current version. The
+// document is only validated with dryRun. It returns the resulting document, and true if it is a new version.
+func (s *Store) Put(doc *Document, dryRun bool) (*Document, bool, error) {
+	return s.PutIf(doc, dryRun, AnyVersion)
+}
+
+// PutIf is Put, applied if the current version is the given version, or whatever the current version with
+// AnyVersion : ErrVersionConflict is returned otherwise. The version is checked against the current version stored.
+func (s *Store) PutIf(doc *Document, dryRun bool, version int) (*Document, bool, error) {
+	err := doc.Validate()
+	if err != nil {
+		return nil, false, err
+	}
+
+	unlock, err := s.locker.Lock(lockName)
+	if err != nil {
+		return nil, false, fmt.Errorf("lock policy : %w", err)
+	}
+
+	defer unlock()
+
+	current, err := s.Latest()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if version != AnyVersion && version != current.Version {
+		return nil, false, fmt.Errorf("%w : current version is %d", ErrVersionConflict, current.Version)
+	}
+
+	if doc.Version != 0 && doc.Version != current.Version {
+		return nil, false, fmt.Errorf("%w : current version is %d", ErrVersionConflict, current.Version)
+	}
+
+	if doc.Equal(current) {
+		return current, false, nil
+	}
+
+	next := *doc
+	now := time.Now().UTC()
+	next.Version = current.Version + 1
+	next.UpdatedAt = &now
+
+	if dryRun {
+		return &next, true, nil
+	}
+
+	docBytes, err := json.Marshal(&next)
+	if err != nil {
+		return nil, false, fmt.Errorf("marshal policy : %w", err)
+	}
+
+	for _, key := range []string{strconv.Itoa(next.Version), currentKey} {
+		err = s.store.Put(key, docBytes)
+		if err != nil {
+			return nil, false, fmt.Errorf("save policy : %w", err)
+		}
+	}
+
+	s.cache(&next)
+
+	return &next, true, nil
+}
+
+func (s *Store) get(key string) (*Document, error) {
+	docBytes, err := s.store.Get(key)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get policy : %w", err)
+	}
+
+	doc := &Document{}
+
+	err = json.Unmarshal(docBytes, doc)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal policy : %w", err)
+	}
+
+	return doc, nil
+}
+
+func nonNegative(field string, val int) []string {
+	if val < 0 {
+		return []string{fmt.Sprintf("%s : must not be negative", field)}
+	}
+
+	return nil
+}
+
+func contains(values []string, val string) bool {
+	for _, v := range values {
+		if v == val {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isTrue(b *bool) bool {
+	return b == nil || *b
+}
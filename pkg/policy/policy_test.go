@@ -0,0 +1,301 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package policy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+type failingLocker struct{}
+
+func (l *failingLocker) Lock(string) (func(), error) {
+	return nil, errors.New("lock timeout")
+}
+
+func TestParse(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		doc, err := Parse([]byte(`{"rateLimits":{"invitationsPerMinute":10},"allowlists":{"didMethods":["peer"]}}`))
+		require.NoError(t, err)
+		require.Equal(t, 10, doc.RateLimits.InvitationsPerMinute)
+		require.Equal(t, []string{"peer"}, doc.Allowlists.DIDMethods)
+	})
+
+	t.Run("unknown field", func(t *testing.T) {
+		_, err := Parse([]byte(`{"rateLimit":{}}`))
+		require.ErrorIs(t, err, ErrInvalid)
+		require.Contains(t, err.Error(), "unknown field")
+	})
+}
+
+func TestValidate(t *testing.T) {
+	doc := &Document{
+		RateLimits: &RateLimits{InvitationsPerMinute: -1, ConnectionsPerMinute: -2},
+		Quotas:     &Quotas{ConnectionsPerDay: -3, MediationsPerTenant: -4},
+		Allowlists: &Allowlists{
+			DIDMethods: []string{"peer", "did:key"}, MsgTypes: []string{" "}, MediationDIDs: []string{"wallet-1"},
+		},
+	}
+
+	err := doc.Validate()
+	require.ErrorIs(t, err, ErrInvalid)
+	require.Contains(t, err.Error(), "rateLimits.invitationsPerMinute : must not be negative")
+	require.Contains(t, err.Error(), "rateLimits.connectionsPerMinute : must not be negative")
+	require.Contains(t, err.Error(), "quotas.connectionsPerDay : must not be negative")
+	require.Contains(t, err.Error(), `allowlists.didMethods[1] : invalid DID method "did:key"`)
+	require.Contains(t, err.Error(), "allowlists.msgTypes[0] : empty message type")
+	require.Contains(t, err.Error(), "quotas.mediationsPerTenant : must not be negative")
+	require.Contains(t, err.Error(), `allowlists.mediationDIDs[0] : invalid DID "wallet-1"`)
+
+	require.NoError(t, (&Document{}).Validate())
+}
+
+func TestRules(t *testing.T) {
+	no := false
+
+	t.Run("default", func(t *testing.T) {
+		doc := &Document{}
+		require.True(t, doc.AllowDIDMethod("did:example:123"))
+		require.True(t, doc.AllowMsgType("https://didcomm.org/test/1.0/msg"))
+		require.True(t, doc.AcceptDIDExchange(""))
+		require.True(t, doc.AcceptMediation(""))
+		require.True(t, doc.AllowMediationDID("did:peer:123"))
+		require.False(t, doc.ManualMediation())
+	})
+
+	t.Run("allowlists", func(t *testing.T) {
+		doc := &Document{Allowlists: &Allowlists{DIDMethods: []string{"peer"}, MsgTypes: []string{"type-1"}}}
+		require.True(t, doc.AllowDIDMethod("did:peer:123"))
+		require.False(t, doc.AllowDIDMethod("did:example:123"))
+		require.False(t, doc.AllowDIDMethod("invalid"))
+		require.True(t, doc.AllowMsgType("type-1"))
+		require.False(t, doc.AllowMsgType("type-2"))
+
+		doc = &Document{Allowlists: &Allowlists{MediationDIDs: []string{"did:peer:123"}}}
+		require.True(t, doc.AllowMediationDID("did:peer:123"))
+		require.False(t, doc.AllowMediationDID("did:peer:456"))
+	})
+
+	t.Run("auto accept", func(t *testing.T) {
+		doc := &Document{AutoAccept: &AutoAccept{Mediation: &no, TenantsOnly: true}}
+		require.True(t, doc.AcceptDIDExchange("tenant-1"))
+		require.False(t, doc.AcceptDIDExchange(""))
+		require.False(t, doc.AcceptMediation("tenant-1"))
+
+		doc = &Document{AutoAccept: &AutoAccept{ManualMediation: true}}
+		require.True(t, doc.AcceptMediation(""))
+		require.True(t, doc.ManualMediation())
+	})
+}
+
+func TestStore(t *testing.T) {
+	t.Run("versions", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		s, err := New(p, lock.NewLocal())
+		require.NoError(t, err)
+		require.Equal(t, 0, s.Current().Version)
+
+		doc := &Document{RateLimits: &RateLimits{InvitationsPerMinute: 10}}
+
+		result, changed, err := s.Put(doc, true)
+		require.NoError(t, err)
+		require.True(t, changed)
+		require.Equal(t, 1, result.Version)
+		require.Equal(t, 0, s.Current().Version)
+
+		result, changed, err = s.Put(doc, false)
+		require.NoError(t, err)
+		require.True(t, changed)
+		require.Equal(t, 1, result.Version)
+		require.NotNil(t, result.UpdatedAt)
+
+		result, changed, err = s.Put(&Document{Version: 1, RateLimits: &RateLimits{InvitationsPerMinute: 10}}, false)
+		require.NoError(t, err)
+		require.False(t, changed)
+		require.Equal(t, 1, result.Version)
+
+		_, _, err = s.Put(&Document{}, false)
+		require.NoError(t, err)
+
+		_, _, err = s.Put(&Document{Version: 1}, false)
+		require.ErrorIs(t, err, ErrVersionConflict)
+
+		_, _, err = s.Put(&Document{Quotas: &Quotas{ConnectionsPerDay: -1}}, false)
+		require.ErrorIs(t, err, ErrInvalid)
+
+		s, err = New(p, lock.NewLocal())
+		require.NoError(t, err)
+		require.Equal(t, 2, s.Current().Version)
+
+		v1, err := s.Get(1)
+		require.NoError(t, err)
+		require.Equal(t, 10, v1.RateLimits.InvitationsPerMinute)
+
+		_, err = s.Get(3)
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("conditional update", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		doc := &Document{RateLimits: &RateLimits{InvitationsPerMinute: 10}}
+
+		_, _, err = s.PutIf(doc, false, 1)
+		require.ErrorIs(t, err, ErrVersionConflict)
+
+		result, changed, err := s.PutIf(doc, false, 0)
+		require.NoError(t, err)
+		require.True(t, changed)
+		require.Equal(t, 1, result.Version)
+
+		_, _, err = s.PutIf(&Document{}, false, 0)
+		require.ErrorIs(t, err, ErrVersionConflict)
+		require.Contains(t, err.Error(), "current version is 1")
+
+		result, _, err = s.PutIf(&Document{}, false, 1)
+		require.NoError(t, err)
+		require.Equal(t, 2, result.Version)
+	})
+
+	t.Run("replicas", func(t *testing.T) {
+		p := mem.NewProvider()
+		locker := lock.NewLocal()
+
+		replica1, err := New(p, locker)
+		require.NoError(t, err)
+
+		replica2, err := New(p, locker)
+		require.NoError(t, err)
+
+		_, _, err = replica1.PutIf(&Document{RateLimits: &RateLimits{InvitationsPerMinute: 10}}, false, 0)
+		require.NoError(t, err)
+
+		// the version is checked against the current version stored, not the version cached by the replica
+		_, _, err = replica2.PutIf(&Document{}, false, 0)
+		require.ErrorIs(t, err, ErrVersionConflict)
+		require.Contains(t, err.Error(), "current version is 1")
+
+		// the update made by the other replica is applied once the cache is refreshed
+		_, _, err = replica2.PutIf(&Document{Quotas: &Quotas{ConnectionsPerDay: 1}}, false, 1)
+		require.NoError(t, err)
+		require.Equal(t, 1, replica1.Current().Version)
+
+		latest, err := replica1.Latest()
+		require.NoError(t, err)
+		require.Equal(t, 2, latest.Version)
+
+		_, _, err = replica2.PutIf(&Document{Quotas: &Quotas{ConnectionsPerDay: 2}}, false, 2)
+		require.NoError(t, err)
+		require.Equal(t, 2, replica1.Current().Version)
+
+		refreshed := time.Now().Add(DefaultRefreshInterval + time.Second)
+		replica1.now = func() time.Time { return refreshed }
+
+		require.Equal(t, 3, replica1.Current().Version)
+		require.Equal(t, 2, replica1.Current().Quotas.ConnectionsPerDay)
+	})
+
+	t.Run("concurrent updates", func(t *testing.T) {
+		p := mem.NewProvider()
+		locker := lock.NewLocal()
+
+		const updates = 10
+
+		errs := make(chan error, updates)
+
+		for i := 0; i < updates; i++ {
+			s, err := New(p, locker, WithRefreshInterval(time.Minute))
+			require.NoError(t, err)
+
+			doc := &Document{RateLimits: &RateLimits{InvitationsPerMinute: i + 1}}
+
+			go func() {
+				_, _, putErr := s.PutIf(doc, false, 0)
+				errs <- putErr
+			}()
+		}
+
+		applied := 0
+
+		for i := 0; i < updates; i++ {
+			if err := <-errs; err == nil {
+				applied++
+			} else {
+				require.ErrorIs(t, err, ErrVersionConflict)
+			}
+		}
+
+		require.Equal(t, 1, applied)
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, lock.NewLocal())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open policy store")
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		_, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), lock.NewLocal())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get policy")
+
+		s, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+		}), lock.NewLocal())
+		require.NoError(t, err)
+
+		_, _, err = s.Put(&Document{Quotas: &Quotas{ConnectionsPerDay: 1}}, false)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save policy")
+
+		store := &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry)}
+
+		s, err = New(mockstore.NewCustomMockStoreProvider(store), lock.NewLocal())
+		require.NoError(t, err)
+
+		store.ErrGet = errors.New("get error")
+
+		_, _, err = s.Put(&Document{Quotas: &Quotas{ConnectionsPerDay: 1}}, false)
+		require.EqualError(t, err, "get policy : get error")
+
+		// the cached version is kept if the storage fails
+		refreshed := time.Now().Add(DefaultRefreshInterval + time.Second)
+		s.now = func() time.Time { return refreshed }
+
+		require.Equal(t, 0, s.Current().Version)
+		require.Equal(t, refreshed, s.loaded)
+
+		s.locker = &failingLocker{}
+
+		_, _, err = s.Put(&Document{Quotas: &Quotas{ConnectionsPerDay: 1}}, false)
+		require.EqualError(t, err, "lock policy : lock timeout")
+
+		s, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store: map[string]mockstore.DBEntry{currentKey: {Value: []byte("{")}},
+		}), lock.NewLocal())
+		require.Nil(t, s)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal policy")
+	})
+}
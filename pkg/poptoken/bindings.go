@@ -0,0 +1,65 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package poptoken
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+const storeName = "poptoken"
+
+// Bindings binds the invitations and connections to the subject of the token they were created with.
+type Bindings struct {
+	store storage.Store
+}
+
+// NewBindings returns new Bindings.
+func NewBindings(p storage.Provider) (*Bindings, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open token binding store : %w", err)
+	}
+
+	return &Bindings{store: store}, nil
+}
+
+// Bind binds the IDs to the subject.
+func (b *Bindings) Bind(subject string, ids ...string) error {
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+
+		err := b.store.Put(id, []byte(subject))
+		if err != nil {
+			return fmt.Errorf("save token binding : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Subject returns the subject the ID is bound to, empty if not bound.
+func (b *Bindings) Subject(id string) (string, error) {
+	if id == "" {
+		return "", nil
+	}
+
+	subject, err := b.store.Get(id)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("get token binding : %w", err)
+	}
+
+	return string(subject), nil
+}
@@ -0,0 +1,56 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package poptoken
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindings(t *testing.T) {
+	b, err := NewBindings(mem.NewProvider())
+	require.NoError(t, err)
+
+	require.NoError(t, b.Bind("user-1", "invitation-1", "", "conn-1"))
+
+	for _, id := range []string{"invitation-1", "conn-1"} {
+		subject, err := b.Subject(id)
+		require.NoError(t, err)
+		require.Equal(t, "user-1", subject)
+	}
+
+	for _, id := range []string{"", "conn-2"} {
+		subject, err := b.Subject(id)
+		require.NoError(t, err)
+		require.Empty(t, subject)
+	}
+
+	t.Run("store errors", func(t *testing.T) {
+		_, err := NewBindings(&mockstore.MockStoreProvider{FailNamespace: storeName})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open token binding store")
+
+		p := mockstore.NewMockStoreProvider()
+		p.Store.ErrPut = errors.New("put error")
+		p.Store.ErrGet = errors.New("get error")
+
+		b, err := NewBindings(p)
+		require.NoError(t, err)
+
+		err = b.Bind("user-1", "conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save token binding")
+
+		_, err = b.Subject("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get token binding")
+	})
+}
@@ -0,0 +1,423 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package poptoken verifies the short-lived signed tokens (compact JWS) issued by the wallet backends to the
+// authenticated app users, and binds the invitations and connections to the subject of the tokens.
+package poptoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/doc/jose"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/jwt"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/signature/verifier"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+const (
+	// DefaultMaxTTL is the maximum lifetime of the tokens, if not configured.
+	DefaultMaxTTL = 5 * time.Minute
+	// leeway tolerates the clock skew between the wallet backend and the router.
+	leeway = 30 * time.Second
+
+	algHS256 = "HS256"
+	algRS256 = "RS256"
+	algES256 = "ES256"
+	algEdDSA = "EdDSA"
+
+	usedStoreName = "tokenuse"
+	usedTag       = "used"
+	lockPrefix    = "poptoken-"
+)
+
+// ErrInvalidToken is returned for the tokens failing the verification.
+var ErrInvalidToken = errors.New("invalid token")
+
+var logger = log.New("hub-router/poptoken")
+
+// Config of the tokens.
+type Config struct {
+	// Secret verifies the HS256 tokens.
+	Secret []byte
+	// PublicKey verifies the RS256, ES256 or EdDSA tokens.
+	PublicKey crypto.PublicKey
+	// Issuer is the expected iss claim, not checked if empty.
+	Issuer string
+	// Audience is the expected aud claim, not checked if empty.
+	Audience string
+	// MaxTTL is the maximum lifetime of the tokens, from now to their expiry, DefaultMaxTTL if zero.
+	MaxTTL time.Duration
+}
+
+// Enabled returns true if a token verification key is configured.
+func (c *Config) Enabled() bool {
+	return c != nil && (len(c.Secret) > 0 || c.PublicKey != nil)
+}
+
+// ParsePublicKey parses the PEM encoded public key (PKIX) verifying the tokens.
+func ParsePublicKey(pemBytes []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key : %w", err)
+	}
+
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// Claims of the tokens.
+type Claims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti"`
+}
+
+// audience is either a string or an array of strings.
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*a = audience{s}
+
+		return nil
+	}
+
+	var l []string
+	if err := json.Unmarshal(data, &l); err != nil {
+		return fmt.Errorf("unmarshal aud : %w", err)
+	}
+
+	*a = l
+
+	return nil
+}
+
+func (a audience) contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Verifier verifies the tokens. The tokens are single use : their ID (jti) is recorded until they expire, in the
+// given storage shared by the nodes, so that a token is not used once per node.
+type Verifier struct {
+	config   Config
+	verifier *jose.CompositeAlgSigVerifier
+	store    storage.Store
+	locker   lock.Locker
+	now      func() time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// used records the use of a token ID until the token expires.
+type used struct {
+	Expiry time.Time `json:"expiry"`
+}
+
+// NewVerifier returns a new token Verifier, recording the token IDs in the storage under the locks.
+func NewVerifier(config *Config, p storage.Provider, locker lock.Locker) (*Verifier, error) {
+	store, err := p.OpenStore(usedStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open token use store : %w", err)
+	}
+
+	err = p.SetStoreConfig(usedStoreName, storage.StoreConfiguration{TagNames: []string{usedTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set token use store config : %w", err)
+	}
+
+	v := &Verifier{
+		config:   *config,
+		verifier: newSignatureVerifier(config),
+		store:    store,
+		locker:   locker,
+		now:      time.Now,
+		stop:     make(chan struct{}),
+	}
+
+	if v.config.MaxTTL <= 0 {
+		v.config.MaxTTL = DefaultMaxTTL
+	}
+
+	return v, nil
+}
+
+// Verify returns the claims of the token, or an error wrapping ErrInvalidToken if its signature, lifetime, issuer or
+// audience is invalid, or if it was already used. The token is used once verified : the caller releases it if the
+// request it carries fails.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	// the signature is verified over the header and payload as sent : the JWS parser passes the header re-serialized,
+	// which differs from the header of the wallet backends serializing it in another order
+	signed := token
+	if i := strings.LastIndex(token, "."); i >= 0 {
+		signed = token[:i]
+	}
+
+	t, err := jwt.Parse(token, jwt.WithSignatureVerifier(jose.SignatureVerifierFunc(
+		func(h jose.Headers, payload, _, sig []byte) error {
+			return v.verifier.Verify(h, payload, []byte(signed), sig)
+		})))
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidToken, err)
+	}
+
+	claims := &Claims{}
+
+	if err = t.DecodeClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w : claims : %s", ErrInvalidToken, err)
+	}
+
+	if err = v.verifyClaims(claims); err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidToken, err)
+	}
+
+	return claims, nil
+}
+
+// newSignatureVerifier returns the JWS verifier of the configured key, for the algorithm of the key type only : the
+// tokens with another alg, none included, are rejected.
+func newSignatureVerifier(config *Config) *jose.CompositeAlgSigVerifier {
+	alg, verify := algHS256, func(msg, sig []byte) error {
+		return verifyHS256(config.Secret, msg, sig)
+	}
+
+	switch key := config.PublicKey.(type) {
+	case *rsa.PublicKey:
+		alg, verify = algRS256, func(msg, sig []byte) error {
+			return jwt.VerifyRS256(&verifier.PublicKey{Value: x509.MarshalPKCS1PublicKey(key)}, msg, sig)
+		}
+	case *ecdsa.PublicKey:
+		alg, verify = algES256, func(msg, sig []byte) error {
+			return verifier.NewECDSAES256SignatureVerifier().Verify(
+				&verifier.PublicKey{Value: elliptic.Marshal(key.Curve, key.X, key.Y)}, msg, sig)
+		}
+	case ed25519.PublicKey:
+		alg, verify = algEdDSA, func(msg, sig []byte) error {
+			return jwt.VerifyEdDSA(&verifier.PublicKey{Value: key}, msg, sig)
+		}
+	}
+
+	return jose.NewCompositeAlgSigVerifier(jose.AlgSignatureVerifier{
+		Alg: alg,
+		Verifier: jose.SignatureVerifierFunc(func(_ jose.Headers, _, signed, sig []byte) error {
+			return verify(signed, sig)
+		}),
+	})
+}
+
+// verifyHS256 verifies the HMAC of the HS256 tokens, not supported by the Aries JWT verifiers.
+func verifyHS256(secret, signed, sig []byte) error {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(signed) // nolint:errcheck,gosec // never returns an error
+
+	if !hmac.Equal(mac.Sum(nil), sig) {
+		return errors.New("hmac signature verification failed")
+	}
+
+	return nil
+}
+
+func (v *Verifier) verifyClaims(c *Claims) error {
+	switch {
+	case c.Subject == "":
+		return errors.New("missing sub")
+	case c.ID == "":
+		return errors.New("missing jti")
+	case v.config.Issuer != "" && subtle.ConstantTimeCompare([]byte(c.Issuer), []byte(v.config.Issuer)) != 1:
+		return fmt.Errorf("unexpected iss %q", c.Issuer)
+	case v.config.Audience != "" && !c.Audience.contains(v.config.Audience):
+		return errors.New("unexpected aud")
+	}
+
+	if err := v.verifyLifetime(c); err != nil {
+		return err
+	}
+
+	return v.use(c.ID, time.Unix(c.ExpiresAt, 0).Add(leeway))
+}
+
+func (v *Verifier) verifyLifetime(c *Claims) error {
+	now := v.now()
+	expiry := time.Unix(c.ExpiresAt, 0)
+
+	switch {
+	case c.ExpiresAt == 0:
+		return errors.New("missing exp")
+	case now.After(expiry.Add(leeway)):
+		return errors.New("expired")
+	case expiry.Sub(now) > v.config.MaxTTL+leeway:
+		return fmt.Errorf("lifetime exceeds %s", v.config.MaxTTL)
+	case c.NotBefore != 0 && now.Add(leeway).Before(time.Unix(c.NotBefore, 0)):
+		return errors.New("not valid yet")
+	}
+
+	return nil
+}
+
+// use records the token ID until the token expires, it returns an error if the token was already used.
+func (v *Verifier) use(id string, expiry time.Time) error {
+	unlock, err := v.locker.Lock(lockPrefix + id)
+	if err != nil {
+		return fmt.Errorf("lock token use : %w", err)
+	}
+
+	defer unlock()
+
+	usedBytes, err := v.store.Get(id)
+
+	switch {
+	case errors.Is(err, storage.ErrDataNotFound):
+	case err != nil:
+		return fmt.Errorf("get token use : %w", err)
+	default:
+		u := &used{}
+
+		// the uses that can't be read are overwritten
+		if json.Unmarshal(usedBytes, u) == nil && v.now().Before(u.Expiry) {
+			return errors.New("already used")
+		}
+	}
+
+	usedBytes, err = json.Marshal(&used{Expiry: expiry.UTC()})
+	if err != nil {
+		return fmt.Errorf("marshal token use : %w", err)
+	}
+
+	if err = v.store.Put(id, usedBytes, storage.Tag{Name: usedTag}); err != nil {
+		return fmt.Errorf("save token use : %w", err)
+	}
+
+	return nil
+}
+
+// Release forgets the use of the token with the claims, so that it can be used again, eg: after the request it
+// carried failed.
+func (v *Verifier) Release(c *Claims) error {
+	unlock, err := v.locker.Lock(lockPrefix + c.ID)
+	if err != nil {
+		return fmt.Errorf("lock token use : %w", err)
+	}
+
+	defer unlock()
+
+	if err = v.store.Delete(c.ID); err != nil {
+		return fmt.Errorf("delete token use : %w", err)
+	}
+
+	return nil
+}
+
+// Sweep deletes the uses of the tokens expired.
+func (v *Verifier) Sweep(now time.Time) error {
+	iter, err := v.store.Query(usedTag)
+	if err != nil {
+		return fmt.Errorf("query token uses : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var expired []string
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("iterate token uses : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		k, err := iter.Key()
+		if err != nil {
+			return fmt.Errorf("read token use key : %w", err)
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return fmt.Errorf("read token use : %w", err)
+		}
+
+		u := &used{}
+
+		// the uses that can't be read are dropped
+		if err = json.Unmarshal(val, u); err != nil || !now.Before(u.Expiry) {
+			expired = append(expired, k)
+		}
+	}
+
+	for _, k := range expired {
+		if err = v.store.Delete(k); err != nil {
+			return fmt.Errorf("delete token use : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Start sweeps the expired token uses periodically until Stop is called.
+func (v *Verifier) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := v.Sweep(now.UTC()); err != nil {
+					logger.Warnf("token use sweep : %s", err)
+				}
+			case <-v.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic sweep.
+func (v *Verifier) Stop() {
+	v.stopOnce.Do(func() {
+		close(v.stop)
+	})
+}
@@ -0,0 +1,459 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package poptoken
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+const es256KeySize = 32
+
+func sign(t *testing.T, alg string, key interface{}, claims interface{}) string {
+	t.Helper()
+
+	return signHeader(t, `{"alg":"`+alg+`"}`, key, claims)
+}
+
+// signHeader signs the claims with the header as given, eg: serialized in another order.
+func signHeader(t *testing.T, h string, key interface{}, claims interface{}) string {
+	t.Helper()
+
+	c, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signed := base64.RawURLEncoding.EncodeToString([]byte(h)) + "." + base64.RawURLEncoding.EncodeToString(c)
+	digest := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+
+		sig = make([]byte, 2*es256KeySize)
+		r.FillBytes(sig[:es256KeySize])
+		s.FillBytes(sig[es256KeySize:])
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, []byte(signed))
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+type failingLocker struct{}
+
+func (l *failingLocker) Lock(string) (func(), error) {
+	return nil, errors.New("lock timeout")
+}
+
+func newVerifier(t *testing.T, config *Config) *Verifier {
+	t.Helper()
+
+	v, err := NewVerifier(config, mem.NewProvider(), lock.NewLocal())
+	require.NoError(t, err)
+
+	return v
+}
+
+func claims(jti string) *Claims {
+	return &Claims{Subject: "user-1", ExpiresAt: time.Now().Add(time.Minute).Unix(), ID: jti}
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("secret")
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		alg    string
+		key    interface{}
+		config *Config
+	}{
+		{algHS256, secret, &Config{Secret: secret}},
+		{algRS256, rsaKey, &Config{PublicKey: &rsaKey.PublicKey}},
+		{algES256, ecKey, &Config{PublicKey: &ecKey.PublicKey}},
+		{algEdDSA, edKey, &Config{PublicKey: edPub}},
+	} {
+		tc := tc
+
+		t.Run(tc.alg, func(t *testing.T) {
+			v := newVerifier(t, tc.config)
+			require.True(t, tc.config.Enabled())
+
+			c, err := v.Verify(sign(t, tc.alg, tc.key, claims("1")))
+			require.NoError(t, err)
+			require.Equal(t, "user-1", c.Subject)
+
+			// header serialized in another order
+			_, err = v.Verify(signHeader(t, `{ "typ": "JWT", "alg": "`+tc.alg+`" }`, tc.key, claims("2")))
+			require.NoError(t, err)
+
+			// unsigned
+			token := sign(t, "none", tc.key, claims("3"))
+			_, err = v.Verify(token[:strings.LastIndex(token, ".")+1])
+			require.ErrorIs(t, err, ErrInvalidToken)
+			require.Contains(t, err.Error(), "no verifier found for none algorithm")
+
+			// tampered
+			token = sign(t, tc.alg, tc.key, claims("4"))
+			_, err = v.Verify(token[:len(token)-4] + "AAAA")
+			require.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
+
+func TestVerifyAlgorithm(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	t.Run("HS256 signed with the RSA public key", func(t *testing.T) {
+		v := newVerifier(t, &Config{PublicKey: &rsaKey.PublicKey})
+
+		_, err := v.Verify(sign(t, algHS256, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}),
+			claims("1")))
+		require.ErrorIs(t, err, ErrInvalidToken)
+		require.Contains(t, err.Error(), "no verifier found for HS256 algorithm")
+	})
+
+	t.Run("alg of another key type", func(t *testing.T) {
+		v := newVerifier(t, &Config{PublicKey: edPub})
+
+		_, err := v.Verify(sign(t, algRS256, rsaKey, claims("1")))
+		require.ErrorIs(t, err, ErrInvalidToken)
+		require.Contains(t, err.Error(), "no verifier found for RS256 algorithm")
+
+		// signed with the key of the verifier, but with another alg
+		_, err = v.Verify(signHeader(t, `{"alg":"ES256"}`, edKey, claims("2")))
+		require.ErrorIs(t, err, ErrInvalidToken)
+		require.Contains(t, err.Error(), "no verifier found for ES256 algorithm")
+	})
+
+	t.Run("malformed header", func(t *testing.T) {
+		v := newVerifier(t, &Config{Secret: []byte("secret")})
+
+		for h, msg := range map[string]string{
+			`{"typ":"JWT"}`:               "alg JWS header is not defined",
+			`{"alg":"HS256"`:              "unmarshal JSON headers",
+			`["alg","HS256"]`:             "unmarshal JSON headers",
+			`{"alg":"HS256","typ":"JWS"}`: "typ is not JWT",
+		} {
+			_, err := v.Verify(signHeader(t, h, []byte("secret"), claims(h)))
+			require.ErrorIs(t, err, ErrInvalidToken, h)
+			require.Contains(t, err.Error(), msg, h)
+		}
+
+		_, err := v.Verify("!." + strings.SplitN(sign(t, algHS256, []byte("secret"), claims("1")), ".", 2)[1])
+		require.ErrorIs(t, err, ErrInvalidToken)
+		require.Contains(t, err.Error(), "decode base64 header")
+	})
+}
+
+func TestVerifyClaims(t *testing.T) {
+	secret := []byte("secret")
+
+	v := newVerifier(t, &Config{Secret: secret, Issuer: "wallet-backend", Audience: "hub-router"})
+	require.Equal(t, DefaultMaxTTL, v.config.MaxTTL)
+
+	valid := func(jti string) map[string]interface{} {
+		return map[string]interface{}{
+			"sub": "user-1", "iss": "wallet-backend", "aud": []string{"other", "hub-router"},
+			"exp": time.Now().Add(time.Minute).Unix(), "jti": jti,
+		}
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		c, err := v.Verify(sign(t, algHS256, secret, valid("1")))
+		require.NoError(t, err)
+		require.Equal(t, "user-1", c.Subject)
+
+		c2 := valid("2")
+		c2["aud"] = "hub-router"
+
+		_, err = v.Verify(sign(t, algHS256, secret, c2))
+		require.NoError(t, err)
+	})
+
+	t.Run("single use", func(t *testing.T) {
+		token := sign(t, algHS256, secret, valid("3"))
+
+		_, err := v.Verify(token)
+		require.NoError(t, err)
+
+		_, err = v.Verify(token)
+		require.ErrorIs(t, err, ErrInvalidToken)
+		require.Contains(t, err.Error(), "already used")
+
+		// usable again once expired
+		v.now = func() time.Time { return time.Now().Add(time.Hour) }
+		require.NoError(t, v.use("3", time.Now().Add(2*time.Hour)))
+
+		v.now = time.Now
+	})
+
+	for name, tc := range map[string]struct {
+		claim string
+		value interface{}
+		err   string
+	}{
+		"missing sub":     {"sub", "", "missing sub"},
+		"missing jti":     {"jti", "", "missing jti"},
+		"missing exp":     {"exp", 0, "missing exp"},
+		"expired":         {"exp", time.Now().Add(-time.Minute).Unix(), "expired"},
+		"long lived":      {"exp", time.Now().Add(time.Hour).Unix(), "lifetime exceeds 5m0s"},
+		"not valid yet":   {"nbf", time.Now().Add(time.Minute).Unix(), "not valid yet"},
+		"another issuer":  {"iss", "other", "unexpected iss"},
+		"another aud":     {"aud", "other", "unexpected aud"},
+		"invalid aud":     {"aud", 1, "unmarshal aud"},
+		"invalid subject": {"sub", 1, "claims"},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			c := valid(name)
+			c[tc.claim] = tc.value
+
+			_, err := v.Verify(sign(t, algHS256, secret, c))
+			require.ErrorIs(t, err, ErrInvalidToken)
+			require.Contains(t, err.Error(), tc.err)
+		})
+	}
+
+	t.Run("malformed", func(t *testing.T) {
+		for _, token := range []string{"", "a.b", "!.b.c", "e30.b.!", "bm90IGpzb24.b.c"} {
+			_, err := v.Verify(token)
+			require.ErrorIs(t, err, ErrInvalidToken)
+		}
+	})
+}
+
+func TestParsePublicKey(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&ecKey.PublicKey)
+	require.NoError(t, err)
+
+	key, err := ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	require.NoError(t, err)
+	require.Equal(t, &ecKey.PublicKey, key)
+
+	_, err = ParsePublicKey([]byte("invalid"))
+	require.EqualError(t, err, "no PEM block found")
+
+	_, err = ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: []byte("invalid")}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "parse public key")
+
+	var c *Config
+	require.False(t, c.Enabled())
+}
+
+func TestNewVerifier(t *testing.T) {
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := NewVerifier(&Config{Secret: []byte("secret")}, p, lock.NewLocal())
+		require.EqualError(t, err, "open token use store : open error")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := NewVerifier(&Config{Secret: []byte("secret")}, p, lock.NewLocal())
+		require.EqualError(t, err, "set token use store config : config error")
+	})
+}
+
+func TestVerifierUses(t *testing.T) {
+	secret := []byte("secret")
+	config := &Config{Secret: secret}
+
+	t.Run("shared by the verifiers", func(t *testing.T) {
+		p := mem.NewProvider()
+		locker := lock.NewLocal()
+
+		verifiers := make([]*Verifier, 4)
+
+		for i := range verifiers {
+			v, err := NewVerifier(config, p, locker)
+			require.NoError(t, err)
+
+			verifiers[i] = v
+		}
+
+		token := sign(t, algHS256, secret, claims("1"))
+
+		var (
+			wg     sync.WaitGroup
+			mutex  sync.Mutex
+			passed int
+		)
+
+		for _, v := range verifiers {
+			wg.Add(1)
+
+			go func(v *Verifier) {
+				defer wg.Done()
+
+				if _, err := v.Verify(token); err == nil {
+					mutex.Lock()
+					passed++
+					mutex.Unlock()
+				}
+			}(v)
+		}
+
+		wg.Wait()
+		require.Equal(t, 1, passed)
+	})
+
+	t.Run("released", func(t *testing.T) {
+		v := newVerifier(t, config)
+		token := sign(t, algHS256, secret, claims("1"))
+
+		c, err := v.Verify(token)
+		require.NoError(t, err)
+
+		require.NoError(t, v.Release(c))
+
+		_, err = v.Verify(token)
+		require.NoError(t, err)
+
+		_, err = v.Verify(token)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "already used")
+	})
+
+	t.Run("sweep", func(t *testing.T) {
+		v := newVerifier(t, config)
+
+		_, err := v.Verify(sign(t, algHS256, secret, claims("1")))
+		require.NoError(t, err)
+		require.NoError(t, v.store.Put("invalid", []byte("{"), storage.Tag{Name: usedTag}))
+
+		require.NoError(t, v.Sweep(time.Now()))
+
+		_, err = v.store.Get("invalid")
+		require.Error(t, err)
+
+		_, err = v.store.Get("1")
+		require.NoError(t, err)
+
+		require.NoError(t, v.Sweep(time.Now().Add(time.Hour)))
+
+		_, err = v.store.Get("1")
+		require.Error(t, err)
+	})
+
+	t.Run("sweep in the background", func(t *testing.T) {
+		v := newVerifier(t, config)
+
+		require.NoError(t, v.use("1", time.Now()))
+
+		v.Start(time.Millisecond)
+		defer v.Stop()
+
+		require.Eventually(t, func() bool {
+			_, err := v.store.Get("1")
+
+			return err != nil
+		}, time.Second, time.Millisecond)
+
+		v.Stop()
+	})
+
+	t.Run("unreadable use overwritten", func(t *testing.T) {
+		v := newVerifier(t, config)
+
+		require.NoError(t, v.store.Put("1", []byte("{")))
+
+		_, err := v.Verify(sign(t, algHS256, secret, claims("1")))
+		require.NoError(t, err)
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		v, err := NewVerifier(config, mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:     make(map[string]mockstore.DBEntry),
+			ErrGet:    errors.New("get error"),
+			ErrQuery:  errors.New("query error"),
+			ErrDelete: errors.New("delete error"),
+		}), lock.NewLocal())
+		require.NoError(t, err)
+
+		require.EqualError(t, v.use("1", time.Now()), "get token use : get error")
+		require.EqualError(t, v.Release(claims("1")), "delete token use : delete error")
+		require.EqualError(t, v.Sweep(time.Now()), "query token uses : query error")
+
+		v, err = NewVerifier(config, mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+		}), lock.NewLocal())
+		require.NoError(t, err)
+
+		_, err = v.Verify(sign(t, algHS256, secret, claims("1")))
+		require.ErrorIs(t, err, ErrInvalidToken)
+		require.Contains(t, err.Error(), "save token use : put error")
+	})
+
+	t.Run("lock errors", func(t *testing.T) {
+		v, err := NewVerifier(config, mem.NewProvider(), &failingLocker{})
+		require.NoError(t, err)
+
+		_, err = v.Verify(sign(t, algHS256, secret, claims("1")))
+		require.ErrorIs(t, err, ErrInvalidToken)
+		require.Contains(t, err.Error(), "lock token use : lock timeout")
+
+		require.EqualError(t, v.Release(claims("1")), "lock token use : lock timeout")
+	})
+}
@@ -0,0 +1,281 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package preparse
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+)
+
+// ErrInvalidMessage is returned when the message isn't a JSON object.
+var ErrInvalidMessage = errors.New("invalid message")
+
+// nolint:gochecknoglobals // immutable keys
+var (
+	idKey          = []byte("@id")
+	typeKey        = []byte("@type")
+	toKey          = []byte("to")
+	forwardMsgType = []byte(service.ForwardMsgType)
+)
+
+// Header is the routing header of a plaintext DIDComm message. The values are slices of the parsed message, unless
+// they hold JSON escape sequences : they mustn't be modified.
+type Header struct {
+	ID   []byte
+	Type []byte
+	// To is the recipient key (or DID) of a forward message.
+	To []byte
+}
+
+// IsForward returns true if the message is a forward message.
+func (h *Header) IsForward() bool {
+	return bytes.Equal(h.Type, forwardMsgType)
+}
+
+// Parse extracts the id, type and recipient of the message without unmarshalling it : the nested values (eg: the
+// forwarded envelope) are skipped without allocation. The keys are matched exactly, and the values that aren't
+// strings are ignored. The message is only validated as far as needed to find the header.
+func Parse(msg []byte) (*Header, error) {
+	s := &scanner{data: msg}
+	h := &Header{}
+
+	if err := s.expect('{'); err != nil {
+		return nil, err
+	}
+
+	if s.peek() == '}' {
+		return h, nil
+	}
+
+	for {
+		key, err := s.string()
+		if err != nil {
+			return nil, err
+		}
+
+		if err = s.expect(':'); err != nil {
+			return nil, err
+		}
+
+		if err = s.header(h, key); err != nil {
+			return nil, err
+		}
+
+		end, err := s.endOfObject()
+		if err != nil {
+			return nil, err
+		}
+
+		if end {
+			return h, nil
+		}
+	}
+}
+
+type scanner struct {
+	data []byte
+	pos  int
+}
+
+// header reads the value of the key into the header if it is a header key, or skips it.
+func (s *scanner) header(h *Header, key []byte) error {
+	var field *[]byte
+
+	switch {
+	case bytes.Equal(key, idKey):
+		field = &h.ID
+	case bytes.Equal(key, typeKey):
+		field = &h.Type
+	case bytes.Equal(key, toKey):
+		field = &h.To
+	}
+
+	if field == nil || s.peek() != '"' {
+		return s.skipValue()
+	}
+
+	value, err := s.string()
+	if err != nil {
+		return err
+	}
+
+	*field = value
+
+	return nil
+}
+
+// endOfObject reads the separator after a member, returning true at the end of the object.
+func (s *scanner) endOfObject() (bool, error) {
+	switch s.peek() {
+	case ',':
+		s.pos++
+
+		return false, nil
+	case '}':
+		s.pos++
+
+		return true, nil
+	default:
+		return false, fmt.Errorf("%w : unexpected character at %d", ErrInvalidMessage, s.pos)
+	}
+}
+
+func (s *scanner) skipSpace() {
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case ' ', '\t', '\n', '\r':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next non-space character, 0 at the end of the data.
+func (s *scanner) peek() byte {
+	s.skipSpace()
+
+	if s.pos >= len(s.data) {
+		return 0
+	}
+
+	return s.data[s.pos]
+}
+
+func (s *scanner) expect(c byte) error {
+	if s.peek() != c {
+		return fmt.Errorf("%w : expected '%c' at %d", ErrInvalidMessage, c, s.pos)
+	}
+
+	s.pos++
+
+	return nil
+}
+
+// string reads a string value, unescaped if needed.
+func (s *scanner) string() ([]byte, error) {
+	if err := s.expect('"'); err != nil {
+		return nil, err
+	}
+
+	start := s.pos
+
+	if err := s.skipString(); err != nil {
+		return nil, err
+	}
+
+	value := s.data[start : s.pos-1]
+	if bytes.IndexByte(value, '\\') < 0 {
+		return value, nil
+	}
+
+	return unescape(s.data[start-1 : s.pos])
+}
+
+// skipValue skips a value of any type, the nested objects and arrays included.
+func (s *scanner) skipValue() error {
+	switch s.peek() {
+	case '"':
+		_, err := s.string()
+
+		return err
+	case '{', '[':
+		return s.skipNested()
+	case 0:
+		return fmt.Errorf("%w : unexpected end", ErrInvalidMessage)
+	}
+
+	// number, boolean or null
+	start := s.pos
+
+	for s.pos < len(s.data) && !isDelimiter(s.data[s.pos]) {
+		s.pos++
+	}
+
+	if s.pos == start {
+		return fmt.Errorf("%w : missing value at %d", ErrInvalidMessage, s.pos)
+	}
+
+	return nil
+}
+
+// skipNested skips an object or array, the strings are skipped as a whole so that their brackets aren't counted.
+func (s *scanner) skipNested() error {
+	depth := 0
+
+	for s.pos < len(s.data) {
+		switch s.data[s.pos] {
+		case '"':
+			s.pos++
+
+			if err := s.skipString(); err != nil {
+				return err
+			}
+
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+		}
+
+		s.pos++
+
+		if depth == 0 {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w : unexpected end", ErrInvalidMessage)
+}
+
+// skipString skips the rest of a string, after its opening quote, without unescaping it : the closing quote is the
+// first one not escaped by an odd number of backslashes.
+func (s *scanner) skipString() error {
+	start := s.pos
+
+	for {
+		i := bytes.IndexByte(s.data[s.pos:], '"')
+		if i < 0 {
+			return fmt.Errorf("%w : unterminated string", ErrInvalidMessage)
+		}
+
+		s.pos += i + 1
+
+		backslashes := 0
+		for j := s.pos - 2; j >= start && s.data[j] == '\\'; j-- {
+			backslashes++
+		}
+
+		if backslashes%2 == 0 {
+			return nil
+		}
+	}
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case ',', '}', ']', ' ', '\t', '\n', '\r':
+		return true
+	default:
+		return false
+	}
+}
+
+func unescape(quoted []byte) ([]byte, error) {
+	var value string
+
+	if err := json.Unmarshal(quoted, &value); err != nil {
+		return nil, fmt.Errorf("%w : %s", ErrInvalidMessage, err)
+	}
+
+	return []byte(value), nil
+}
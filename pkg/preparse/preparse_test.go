@@ -0,0 +1,111 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package preparse
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/stretchr/testify/require"
+)
+
+// forward is a forward message with a 4KB envelope, the size of a credential offer.
+// nolint:gochecknoglobals // test data
+var forward = []byte(`{"@type":"https://didcomm.org/routing/1.0/forward",` +
+	`"@id":"8d2f4b6a-0c1e-4f3a-9b5d-7e6f8a9b0c1d","to":"HvpYUMRvUgRaTyd48HnW8jU9JvPymFqUW4pUfT9DPkyb",` +
+	`"msg":{"protected":"eyJlbmMiOiJ4Y2hhY2hhMjBwb2x5MTMwNV9pZXRmIn0",` +
+	`"recipients":[{"encrypted_key":"a2V5","header":{"kid":"HvpYUMRvUgRaTyd48HnW8jU9JvPymFqUW4pUfT9DPkyb"}}],` +
+	`"iv":"aXY","ciphertext":"` + strings.Repeat("Y2lwaGVydGV4dA", 300) + `","tag":"dGFn"}}`)
+
+func TestParse(t *testing.T) {
+	t.Run("same header as unmarshal", func(t *testing.T) {
+		for _, msg := range []string{
+			string(forward),
+			`{"@type":"https://didcomm.org/routing/1.0/forward","to":"did:example:next","msg":{"a":["}",{"b":"]"}]}}`,
+			` { "msg" : [1, 2.5e3, true, null, "\"{"] , "to" : "key" , "@id" : "1" , "@type" : "t" } `,
+			`{"@type":"https:\/\/didcomm.org\/routing\/1.0\/forward","to":"kéy","@id":"\"1\""}`,
+			`{"to":"key-1","to":"key-2","n":-1}`,
+			`{"to":1,"@id":{"nested":"1"},"@type":null}`,
+			`{}`,
+		} {
+			h, err := Parse([]byte(msg))
+			require.NoError(t, err, msg)
+
+			expected := &model.Forward{}
+			if err := json.Unmarshal([]byte(msg), &struct {
+				ID   *string `json:"@id"`
+				Type *string `json:"@type"`
+				To   *string `json:"to"`
+			}{&expected.ID, &expected.Type, &expected.To}); err != nil {
+				expected = &model.Forward{}
+			}
+
+			require.Equal(t, expected.ID, string(h.ID), msg)
+			require.Equal(t, expected.Type, string(h.Type), msg)
+			require.Equal(t, expected.To, string(h.To), msg)
+		}
+	})
+
+	t.Run("forward", func(t *testing.T) {
+		h, err := Parse(forward)
+		require.NoError(t, err)
+		require.True(t, h.IsForward())
+		require.Equal(t, "HvpYUMRvUgRaTyd48HnW8jU9JvPymFqUW4pUfT9DPkyb", string(h.To))
+
+		h, err = Parse([]byte(`{"@type":"https://didcomm.org/trust_ping/1.0/ping"}`))
+		require.NoError(t, err)
+		require.False(t, h.IsForward())
+	})
+
+	t.Run("invalid message", func(t *testing.T) {
+		for _, msg := range []string{
+			``,
+			`[]`,
+			`{`,
+			`{"to"}`,
+			`{"to":"key"`,
+			`{"to":"key";}`,
+			`{"to":"key`,
+			`{"to":"key\`,
+			`{"to":"\uZZZZ"}`,
+			`{"msg":{"a":1}`,
+			`{"msg":"abc}`,
+			`{"msg":{"a":"b\"}}`,
+			`{"msg":}`,
+			`{to:"key"}`,
+		} {
+			_, err := Parse([]byte(msg))
+			require.ErrorIs(t, err, ErrInvalidMessage, msg)
+		}
+	})
+}
+
+func BenchmarkParse(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(forward)))
+
+	for i := 0; i < b.N; i++ {
+		h, err := Parse(forward)
+		if err != nil || !h.IsForward() {
+			b.Fatal("forward not parsed")
+		}
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(forward)))
+
+	for i := 0; i < b.N; i++ {
+		f := &model.Forward{}
+		if err := json.Unmarshal(forward, f); err != nil || f.Type == "" {
+			b.Fatal("forward not unmarshalled")
+		}
+	}
+}
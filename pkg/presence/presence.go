@@ -0,0 +1,252 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package presence
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "presence"
+	statusTag = "status"
+
+	// DefaultTimeout is the inactivity period after which a wallet is considered offline.
+	DefaultTimeout = 5 * time.Minute
+)
+
+// Presence statuses.
+const (
+	Online  = "online"
+	Offline = "offline"
+)
+
+// Activity sources.
+const (
+	SourceMediation   = "mediation"
+	SourceDIDExchange = "didexchange"
+	SourcePickup      = "pickup"
+	SourceWebSocket   = "websocket"
+	SourceReturnRoute = "return-route"
+)
+
+// ErrNotFound is returned when no activity was recorded for the connection.
+var ErrNotFound = errors.New("presence not found")
+
+var logger = log.New("hub-router/presence")
+
+// Record is the presence of a mediated wallet.
+type Record struct {
+	ConnectionID string    `json:"connectionID"`
+	Status       string    `json:"status"`
+	LastSeen     time.Time `json:"lastSeen"`
+	Source       string    `json:"source"`
+}
+
+// Tracker records wallet activity and detects presence changes.
+type Tracker struct {
+	store    storage.Store
+	timeout  time.Duration
+	onChange func(*Record)
+	mutex    sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns a new presence Tracker. onChange is called whenever a wallet goes online or offline.
+func New(p storage.Provider, timeout time.Duration, onChange func(*Record)) (*Tracker, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open presence store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{statusTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set presence store config : %w", err)
+	}
+
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	if onChange == nil {
+		onChange = func(*Record) {}
+	}
+
+	return &Tracker{store: store, timeout: timeout, onChange: onChange, stop: make(chan struct{})}, nil
+}
+
+// Seen records activity from the wallet on the given connection.
+func (t *Tracker) Seen(connectionID, source string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	r, err := t.Get(connectionID)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	changed := r == nil || r.Status != Online
+
+	r = &Record{ConnectionID: connectionID, Status: Online, LastSeen: time.Now().UTC(), Source: source}
+
+	err = t.save(r)
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		t.onChange(r)
+	}
+
+	return nil
+}
+
+// Get returns the presence of the wallet on the given connection.
+func (t *Tracker) Get(connectionID string) (*Record, error) {
+	recordBytes, err := t.store.Get(connectionID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get presence : %w", err)
+	}
+
+	r := &Record{}
+
+	err = json.Unmarshal(recordBytes, r)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal presence : %w", err)
+	}
+
+	return r, nil
+}
+
+// List returns the presence of the wallets with the given status (all wallets if empty), most recently seen first.
+func (t *Tracker) List(status string) ([]*Record, error) {
+	expression := statusTag
+	if status != "" {
+		expression = fmt.Sprintf("%s:%s", statusTag, status)
+	}
+
+	iter, err := t.store.Query(expression)
+	if err != nil {
+		return nil, fmt.Errorf("query presence : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var records []*Record
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate presence : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read presence : %w", err)
+		}
+
+		r := &Record{}
+
+		err = json.Unmarshal(val, r)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal presence : %w", err)
+		}
+
+		records = append(records, r)
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].LastSeen.After(records[j].LastSeen)
+	})
+
+	return records, nil
+}
+
+// Sweep marks the online wallets without activity within the timeout as offline.
+func (t *Tracker) Sweep(now time.Time) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	records, err := t.List(Online)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range records {
+		if now.Sub(r.LastSeen) < t.timeout {
+			continue
+		}
+
+		r.Status = Offline
+
+		err = t.save(r)
+		if err != nil {
+			return err
+		}
+
+		t.onChange(r)
+	}
+
+	return nil
+}
+
+// Start sweeps the presence records periodically until Stop is called.
+func (t *Tracker) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := t.Sweep(now.UTC()); err != nil {
+					logger.Warnf("presence sweep : %s", err)
+				}
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic sweep.
+func (t *Tracker) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+	})
+}
+
+func (t *Tracker) save(r *Record) error {
+	recordBytes, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal presence : %w", err)
+	}
+
+	err = t.store.Put(r.ConnectionID, recordBytes, storage.Tag{Name: statusTag, Value: r.Status})
+	if err != nil {
+		return fmt.Errorf("save presence : %w", err)
+	}
+
+	return nil
+}
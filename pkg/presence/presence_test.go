@@ -0,0 +1,143 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package presence
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		tr, err := New(mem.NewProvider(), 0, nil)
+		require.NoError(t, err)
+		require.Equal(t, DefaultTimeout, tr.timeout)
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, time.Minute, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open presence store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p, time.Minute, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set presence store config")
+	})
+}
+
+func TestTracker(t *testing.T) {
+	t.Run("online and offline changes", func(t *testing.T) {
+		var changes []Record
+
+		tr, err := New(mem.NewProvider(), time.Minute, func(r *Record) {
+			changes = append(changes, *r)
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, tr.Seen("conn-1", SourceMediation))
+		require.NoError(t, tr.Seen("conn-1", SourcePickup))
+		require.NoError(t, tr.Seen("conn-2", SourceDIDExchange))
+		require.Len(t, changes, 2)
+		require.Equal(t, Online, changes[0].Status)
+
+		r, err := tr.Get("conn-1")
+		require.NoError(t, err)
+		require.Equal(t, SourcePickup, r.Source)
+
+		require.NoError(t, tr.Sweep(time.Now()))
+		require.Len(t, changes, 2)
+
+		require.NoError(t, tr.Sweep(time.Now().Add(2*time.Minute)))
+		require.Len(t, changes, 4)
+		require.Equal(t, Offline, changes[3].Status)
+
+		records, err := tr.List(Offline)
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+
+		require.NoError(t, tr.Seen("conn-1", SourceWebSocket))
+		require.Len(t, changes, 5)
+		require.Equal(t, Online, changes[4].Status)
+
+		records, err = tr.List("")
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		require.Equal(t, "conn-1", records[0].ConnectionID)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		tr, err := New(mem.NewProvider(), time.Minute, nil)
+		require.NoError(t, err)
+
+		_, err = tr.Get("conn-1")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		tr, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}), time.Minute, nil)
+		require.NoError(t, err)
+
+		err = tr.Seen("conn-1", SourceMediation)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save presence")
+
+		err = tr.Sweep(time.Now())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query presence")
+
+		tr, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), time.Minute, nil)
+		require.NoError(t, err)
+
+		err = tr.Seen("conn-1", SourceMediation)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get presence")
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		changes := make(chan *Record, 2)
+
+		tr, err := New(mem.NewProvider(), time.Millisecond, func(r *Record) {
+			changes <- r
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, tr.Seen("conn-1", SourceMediation))
+		require.Equal(t, Online, (<-changes).Status)
+
+		tr.Start(time.Millisecond)
+		defer tr.Stop()
+
+		select {
+		case r := <-changes:
+			require.Equal(t, Offline, r.Status)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+	})
+}
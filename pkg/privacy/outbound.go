@@ -0,0 +1,94 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package privacy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+)
+
+// Outbound wraps an outbound transport to pad the envelopes to their bucket size, and to release them in randomized
+// batches. Send blocks until the message is released, so that the sender still gets the result of the delivery.
+type Outbound struct {
+	transport.OutboundTransport
+	config  Config
+	mutex   sync.Mutex
+	pending []*pendingSend
+}
+
+type pendingSend struct {
+	data        []byte
+	destination *service.Destination
+	result      chan sendResult
+}
+
+type sendResult struct {
+	resp string
+	err  error
+}
+
+// NewOutbound returns a new Outbound wrapping the given transport.
+func NewOutbound(ot transport.OutboundTransport, config *Config) *Outbound {
+	o := &Outbound{OutboundTransport: ot}
+
+	if config != nil {
+		o.config = *config
+	}
+
+	return o
+}
+
+// Send pads the data and sends it through the wrapped transport with the next batch.
+func (t *Outbound) Send(data []byte, destination *service.Destination) (string, error) {
+	data = Pad(data, t.config.PadSize)
+
+	if t.config.BatchWindow <= 0 {
+		return t.OutboundTransport.Send(data, destination)
+	}
+
+	p := &pendingSend{data: data, destination: destination, result: make(chan sendResult, 1)}
+
+	t.mutex.Lock()
+
+	t.pending = append(t.pending, p)
+	if len(t.pending) == 1 {
+		// the batch is released after a random delay, so that the release time doesn't reveal the first message time
+		time.AfterFunc(time.Duration(1+randInt(int64(t.config.BatchWindow))), t.release)
+	}
+
+	t.mutex.Unlock()
+
+	r := <-p.result
+
+	return r.resp, r.err
+}
+
+// release sends the pending messages in random order.
+func (t *Outbound) release() {
+	t.mutex.Lock()
+	batch := t.pending
+	t.pending = nil
+	t.mutex.Unlock()
+
+	for i := len(batch) - 1; i > 0; i-- {
+		j := randInt(int64(i + 1))
+		batch[i], batch[j] = batch[j], batch[i]
+	}
+
+	logger.Debugf("releasing batch of %d messages", len(batch))
+
+	for _, p := range batch {
+		go func(p *pendingSend) {
+			resp, err := t.OutboundTransport.Send(p.data, p.destination)
+
+			p.result <- sendResult{resp: resp, err: err}
+		}(p)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package privacy
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbound(t *testing.T) {
+	t.Run("envelopes are padded", func(t *testing.T) {
+		ot := &mockOutbound{}
+
+		o := NewOutbound(ot, &Config{PadSize: 64})
+
+		_, err := o.Send([]byte(`{"ciphertext":"data"}`), &service.Destination{})
+		require.NoError(t, err)
+		require.Len(t, ot.sent, 1)
+		require.Len(t, ot.sent[0], 64)
+	})
+
+	t.Run("messages are released in batches", func(t *testing.T) {
+		ot := &mockOutbound{}
+
+		o := NewOutbound(ot, &Config{BatchWindow: 50 * time.Millisecond})
+
+		var wg sync.WaitGroup
+
+		start := time.Now()
+
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				_, err := o.Send([]byte("data"), &service.Destination{})
+				require.NoError(t, err)
+			}()
+		}
+
+		wg.Wait()
+		require.Len(t, ot.sent, 5)
+		require.Less(t, time.Since(start), 5*time.Second)
+	})
+
+	t.Run("send error", func(t *testing.T) {
+		o := NewOutbound(&mockOutbound{err: errors.New("send error")}, &Config{BatchWindow: time.Millisecond})
+
+		_, err := o.Send([]byte("data"), &service.Destination{})
+		require.EqualError(t, err, "send error")
+
+		o = NewOutbound(&mockOutbound{err: errors.New("send error")}, nil)
+
+		_, err = o.Send([]byte("data"), &service.Destination{})
+		require.EqualError(t, err, "send error")
+	})
+}
+
+type mockOutbound struct {
+	transport.OutboundTransport
+	err   error
+	mutex sync.Mutex
+	sent  [][]byte
+}
+
+func (m *mockOutbound) Send(data []byte, _ *service.Destination) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.sent = append(m.sent, data)
+
+	return "", m.err
+}
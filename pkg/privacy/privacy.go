@@ -0,0 +1,81 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package privacy
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"time"
+
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+var logger = log.New("hub-router/privacy")
+
+// Config of the privacy mode, which reduces what an observer of the router traffic can learn from the size and
+// timing of the messages.
+type Config struct {
+	// PadSize is the smallest padding bucket, in bytes : the envelopes are padded to the next power of two multiple
+	// of PadSize. Zero disables the padding.
+	PadSize int
+	// BatchWindow is the maximum time an outbound message is held before being released, in a batch shuffled with
+	// the other messages held meanwhile. Zero disables the batch release.
+	BatchWindow time.Duration
+}
+
+// Enabled returns true if padding or batch release is configured.
+func (c *Config) Enabled() bool {
+	return c != nil && (c.PadSize > 0 || c.BatchWindow > 0)
+}
+
+// Bucket returns the padded size of an envelope of the given size.
+func Bucket(size, padSize int) int {
+	if padSize <= 0 {
+		return size
+	}
+
+	bucket := padSize
+
+	for bucket < size {
+		bucket *= 2
+	}
+
+	return bucket
+}
+
+// Pad pads the JSON envelope to its bucket size with trailing whitespace, which JSON parsers ignore. Data that is not
+// a JSON object is returned as is.
+func Pad(data []byte, padSize int) []byte {
+	bucket := Bucket(len(data), padSize)
+	if bucket == len(data) || !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return data
+	}
+
+	padded := make([]byte, bucket)
+	copy(padded, data)
+
+	for i := len(data); i < bucket; i++ {
+		padded[i] = ' '
+	}
+
+	return padded
+}
+
+// randInt returns a random number in [0, n).
+func randInt(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+
+	r, err := rand.Int(rand.Reader, big.NewInt(n))
+	if err != nil {
+		return 0
+	}
+
+	return r.Int64()
+}
@@ -0,0 +1,50 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package privacy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	var c *Config
+
+	require.False(t, c.Enabled())
+	require.False(t, (&Config{}).Enabled())
+	require.True(t, (&Config{PadSize: 1024}).Enabled())
+	require.True(t, (&Config{BatchWindow: time.Second}).Enabled())
+}
+
+func TestBucket(t *testing.T) {
+	require.Equal(t, 10, Bucket(10, 0))
+	require.Equal(t, 1024, Bucket(10, 1024))
+	require.Equal(t, 1024, Bucket(1024, 1024))
+	require.Equal(t, 2048, Bucket(1025, 1024))
+	require.Equal(t, 8192, Bucket(5000, 1024))
+}
+
+func TestPad(t *testing.T) {
+	t.Run("json envelope is padded", func(t *testing.T) {
+		data := []byte(`{"protected":"eyJ0eXAiOiJKV00vMS4wIn0","ciphertext":"data"}`)
+
+		padded := Pad(data, 128)
+		require.Len(t, padded, 128)
+
+		envelope := map[string]string{}
+		require.NoError(t, json.Unmarshal(padded, &envelope))
+		require.Equal(t, "data", envelope["ciphertext"])
+	})
+
+	t.Run("other data is not padded", func(t *testing.T) {
+		require.Equal(t, []byte("data"), Pad([]byte("data"), 128))
+		require.Equal(t, []byte("{}"), Pad([]byte("{}"), 0))
+	})
+}
@@ -0,0 +1,156 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package problemreport persists the problem reports received from the wallets and adapters, linked to the thread and
+// connection they were reported on, so that the integration failures reported by the peers are visible.
+package problemreport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "problemreport"
+
+	// ConnectionIDTag tags the reports by connection ID.
+	ConnectionIDTag = "connectionID"
+	// ThreadIDTag tags the reports by the ID of the thread they were reported on.
+	ThreadIDTag = "threadID"
+)
+
+var logger = log.New("hub-router/problemreport")
+
+// Record of a problem report received from a peer.
+type Record struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+	// ConnectionID the report was received on, empty if the connection isn't known.
+	ConnectionID string `json:"connectionID,omitempty"`
+	// ThreadID is the thread the problem is reported on (~thread.thid), ParentThreadID its parent thread.
+	ThreadID       string `json:"threadID,omitempty"`
+	ParentThreadID string `json:"parentThreadID,omitempty"`
+	MsgID          string `json:"msgID"`
+	// Code and Description are the problem code and its english description.
+	Code        string `json:"code,omitempty"`
+	Description string `json:"description,omitempty"`
+	Explain     string `json:"explain,omitempty"`
+	Impact      string `json:"impact,omitempty"`
+	Where       string `json:"where,omitempty"`
+	WhoRetries  string `json:"whoRetries,omitempty"`
+	FixHint     string `json:"fixHint,omitempty"`
+}
+
+// Store persists and queries the problem reports.
+type Store struct {
+	store storage.Store
+}
+
+// New returns a new problem report Store backed by the given storage provider.
+func New(p storage.Provider) (*Store, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open problem report store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{ConnectionIDTag, ThreadIDTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set problem report store config : %w", err)
+	}
+
+	return &Store{store: store}, nil
+}
+
+// Save persists the report, setting the ID and Time if they are empty.
+func (s *Store) Save(r *Record) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+
+	if r.Time.IsZero() {
+		r.Time = time.Now().UTC()
+	}
+
+	recordBytes, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal problem report : %w", err)
+	}
+
+	var tags []storage.Tag
+
+	if r.ConnectionID != "" {
+		tags = append(tags, storage.Tag{Name: ConnectionIDTag, Value: r.ConnectionID})
+	}
+
+	if r.ThreadID != "" {
+		tags = append(tags, storage.Tag{Name: ThreadIDTag, Value: r.ThreadID})
+	}
+
+	err = s.store.Put(r.ID, recordBytes, tags...)
+	if err != nil {
+		return fmt.Errorf("save problem report : %w", err)
+	}
+
+	return nil
+}
+
+// Find returns the reports with the given tag value, most recent first. A positive limit caps the number of reports.
+func (s *Store) Find(tagName, value string, limit int) ([]*Record, error) {
+	if value == "" {
+		return nil, errors.New("problem report query value is mandatory")
+	}
+
+	iter, err := s.store.Query(fmt.Sprintf("%s:%s", tagName, value))
+	if err != nil {
+		return nil, fmt.Errorf("query problem reports : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	records := []*Record{}
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate problem reports : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read problem report : %w", err)
+		}
+
+		r := &Record{}
+
+		err = json.Unmarshal(val, r)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal problem report : %w", err)
+		}
+
+		records = append(records, r)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Time.After(records[j].Time)
+	})
+
+	if limit > 0 && len(records) > limit {
+		records = records[:limit]
+	}
+
+	return records, nil
+}
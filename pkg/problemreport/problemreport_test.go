@@ -0,0 +1,119 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package problemreport
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open problem report store")
+	})
+
+	t.Run("store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set problem report store config")
+	})
+}
+
+func TestStore(t *testing.T) {
+	t.Run("save and find", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		now := time.Now().UTC()
+
+		require.NoError(t, s.Save(&Record{
+			ConnectionID: "conn-1", ThreadID: "thid-1", MsgID: "msg-1", Code: "invalid-message", Time: now,
+		}))
+		require.NoError(t, s.Save(&Record{ConnectionID: "conn-1", ThreadID: "thid-2", MsgID: "msg-2"}))
+		require.NoError(t, s.Save(&Record{ThreadID: "thid-1", MsgID: "msg-3", Time: now.Add(-time.Minute)}))
+
+		records, err := s.Find(ConnectionIDTag, "conn-1", 0)
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		require.Equal(t, "msg-2", records[0].MsgID)
+		require.NotEmpty(t, records[0].ID)
+		require.False(t, records[0].Time.IsZero())
+
+		records, err = s.Find(ConnectionIDTag, "conn-1", 1)
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		require.Equal(t, "msg-2", records[0].MsgID)
+
+		records, err = s.Find(ThreadIDTag, "thid-1", 0)
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		require.Equal(t, "msg-1", records[0].MsgID)
+		require.Equal(t, "invalid-message", records[0].Code)
+
+		records, err = s.Find(ConnectionIDTag, "conn-2", 0)
+		require.NoError(t, err)
+		require.Empty(t, records)
+	})
+
+	t.Run("missing query value", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		_, err = s.Find(ConnectionIDTag, "", 0)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "problem report query value is mandatory")
+	})
+
+	t.Run("save error", func(t *testing.T) {
+		s := &Store{store: &mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+		}}
+
+		err := s.Save(&Record{ThreadID: "thid"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save problem report")
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		s := &Store{store: &mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrQuery: errors.New("query error"),
+		}}
+
+		_, err := s.Find(ThreadIDTag, "thid", 0)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query problem reports")
+	})
+
+	t.Run("invalid record", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		require.NoError(t, s.store.Put("id", []byte("invalid"), storage.Tag{Name: ThreadIDTag, Value: "thid"}))
+
+		_, err = s.Find(ThreadIDTag, "thid", 0)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal problem report")
+	})
+}
@@ -0,0 +1,116 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	socks5Scheme = "socks5"
+	onionSuffix  = ".onion"
+)
+
+// ErrNoOnionProxy is returned for the .onion endpoints when no SOCKS5 proxy is configured for them : they can only be
+// reached through Tor, and resolving them outside of it would leak the destination.
+var ErrNoOnionProxy = errors.New("no SOCKS5 proxy configured for onion endpoint")
+
+// Rule routes the outbound deliveries to the matching hosts through a SOCKS5 proxy. The host is either a host name,
+// or a domain suffix starting with "*." (eg: *.onion).
+type Rule struct {
+	Host  string
+	Proxy *url.URL
+}
+
+// Config of the SOCKS5 proxies used for the outbound DIDComm deliveries.
+type Config struct {
+	// Default is the proxy used for the destinations matching no rule; nil to connect directly.
+	Default *url.URL
+	Rules   []*Rule
+}
+
+// Enabled returns true if a proxy is configured.
+func (c *Config) Enabled() bool {
+	return c != nil && (c.Default != nil || len(c.Rules) > 0)
+}
+
+// ParseURL parses a SOCKS5 proxy URL, eg: socks5://127.0.0.1:9050.
+func ParseURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse proxy url : %w", err)
+	}
+
+	if u.Scheme != socks5Scheme || u.Host == "" {
+		return nil, fmt.Errorf("unsupported proxy url %s, expected socks5://host:port", rawURL)
+	}
+
+	return u, nil
+}
+
+// ParseRule parses a rule in the host=proxy-url format, eg: *.onion=socks5://127.0.0.1:9050.
+func ParseRule(rule string) (*Rule, error) {
+	parts := strings.SplitN(rule, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return nil, fmt.Errorf("invalid proxy rule %s, expected host=socks5://host:port", rule)
+	}
+
+	u, err := ParseURL(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rule{Host: strings.ToLower(parts[0]), Proxy: u}, nil
+}
+
+// Proxy returns the proxy to use for the request; it can be set as the Proxy of an http.Transport. The requests to
+// the destinations without SOCKS5 proxy use the proxy set in the environment, if any.
+func (c *Config) Proxy(req *http.Request) (*url.URL, error) {
+	proxy, err := c.ProxyFor(req.URL.Hostname())
+	if err != nil || proxy != nil {
+		return proxy, err
+	}
+
+	return http.ProxyFromEnvironment(req)
+}
+
+// ProxyFor returns the proxy to use for the given host, nil to connect directly.
+func (c *Config) ProxyFor(host string) (*url.URL, error) {
+	host = strings.ToLower(host)
+
+	var proxy *url.URL
+
+	if c != nil {
+		proxy = c.Default
+
+		for _, r := range c.Rules {
+			if r.matches(host) {
+				proxy = r.Proxy
+
+				break
+			}
+		}
+	}
+
+	if proxy == nil && strings.HasSuffix(host, onionSuffix) {
+		return nil, fmt.Errorf("%w : %s", ErrNoOnionProxy, host)
+	}
+
+	return proxy, nil
+}
+
+func (r *Rule) matches(host string) bool {
+	if strings.HasPrefix(r.Host, "*.") {
+		return strings.HasSuffix(host, r.Host[1:])
+	}
+
+	return host == r.Host
+}
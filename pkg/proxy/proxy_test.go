@@ -0,0 +1,111 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("url", func(t *testing.T) {
+		u, err := ParseURL("socks5://127.0.0.1:9050")
+		require.NoError(t, err)
+		require.Equal(t, "127.0.0.1:9050", u.Host)
+
+		_, err = ParseURL("http://127.0.0.1:8080")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unsupported proxy url")
+
+		_, err = ParseURL("socks5://")
+		require.Error(t, err)
+
+		_, err = ParseURL(":invalid")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "parse proxy url")
+	})
+
+	t.Run("rule", func(t *testing.T) {
+		r, err := ParseRule("*.Onion=socks5://127.0.0.1:9050")
+		require.NoError(t, err)
+		require.Equal(t, "*.onion", r.Host)
+		require.Equal(t, "127.0.0.1:9050", r.Proxy.Host)
+
+		_, err = ParseRule("socks5://127.0.0.1:9050")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid proxy rule")
+
+		_, err = ParseRule("example.com=http://127.0.0.1:8080")
+		require.Error(t, err)
+	})
+}
+
+func TestConfig(t *testing.T) {
+	tor, err := ParseURL("socks5://127.0.0.1:9050")
+	require.NoError(t, err)
+
+	corporate, err := ParseURL("socks5://proxy.example.com:1080")
+	require.NoError(t, err)
+
+	t.Run("enabled", func(t *testing.T) {
+		var c *Config
+
+		require.False(t, c.Enabled())
+		require.False(t, (&Config{}).Enabled())
+		require.True(t, (&Config{Default: tor}).Enabled())
+		require.True(t, (&Config{Rules: []*Rule{{Host: "*.onion", Proxy: tor}}}).Enabled())
+	})
+
+	t.Run("proxy per destination", func(t *testing.T) {
+		c := &Config{Default: corporate, Rules: []*Rule{
+			{Host: "*.onion", Proxy: tor},
+			{Host: "wallet.example.com", Proxy: tor},
+		}}
+
+		for host, expected := range map[string]string{
+			"abcdefghijklmnop.onion": tor.Host,
+			"wallet.example.com":     tor.Host,
+			"other.example.com":      corporate.Host,
+		} {
+			u, err := c.ProxyFor(host)
+			require.NoError(t, err)
+			require.Equal(t, expected, u.Host)
+		}
+
+		u, err := c.Proxy(httptest.NewRequest(http.MethodPost, "http://abcdefghijklmnop.onion:8080/", nil))
+		require.NoError(t, err)
+		require.Equal(t, tor.Host, u.Host)
+	})
+
+	t.Run("direct connection", func(t *testing.T) {
+		c := &Config{Rules: []*Rule{{Host: "*.onion", Proxy: tor}}}
+
+		u, err := c.ProxyFor("wallet.example.com")
+		require.NoError(t, err)
+		require.Nil(t, u)
+
+		var nilConfig *Config
+
+		u, err = nilConfig.ProxyFor("wallet.example.com")
+		require.NoError(t, err)
+		require.Nil(t, u)
+
+		u, err = c.Proxy(httptest.NewRequest(http.MethodPost, "http://wallet.example.com/", nil))
+		require.NoError(t, err)
+		require.Nil(t, u)
+	})
+
+	t.Run("onion endpoint without proxy", func(t *testing.T) {
+		var c *Config
+
+		_, err := c.ProxyFor("abcdefghijklmnop.onion")
+		require.ErrorIs(t, err, ErrNoOnionProxy)
+	})
+}
@@ -0,0 +1,196 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	"github.com/trustbloc/edge-core/pkg/log"
+	"nhooyr.io/websocket"
+)
+
+// pingInterval keeps the connections dialed with the return route open.
+const pingInterval = 30 * time.Second
+
+var logger = log.New("hub-router/proxy")
+
+// WSOutbound wraps the Aries WebSocket outbound transport, which dials with the default HTTP client, to dial the
+// destinations with the given HTTP transport, eg: through the proxies. The messages to the wallets holding a
+// connection open on the inbound transport are still sent on it by the wrapped transport.
+type WSOutbound struct {
+	transport.OutboundTransport
+	client *http.Client
+	prov   transport.Provider
+	mutex  sync.RWMutex
+	conns  map[string]*websocket.Conn
+}
+
+// NewWSOutbound returns a new WSOutbound wrapping the Aries WebSocket outbound transport, dialing with the given HTTP
+// transport.
+func NewWSOutbound(ot transport.OutboundTransport, rt http.RoundTripper) *WSOutbound {
+	return &WSOutbound{
+		OutboundTransport: ot,
+		client:            &http.Client{Transport: rt},
+		conns:             make(map[string]*websocket.Conn),
+	}
+}
+
+// Start starts the wrapped transport.
+func (t *WSOutbound) Start(prov transport.Provider) error {
+	t.prov = prov
+
+	return t.OutboundTransport.Start(prov)
+}
+
+// AcceptRecipient returns true if a connection is open for one of the keys.
+func (t *WSOutbound) AcceptRecipient(keys []string) bool {
+	return t.OutboundTransport.AcceptRecipient(keys) || t.fetch(keys) != nil
+}
+
+// Send sends the data on the connection open for the destination keys, else on a connection dialed with the HTTP
+// transport; the connection is kept open to receive the responses if the destination sets the return route.
+func (t *WSOutbound) Send(data []byte, destination *service.Destination) (string, error) {
+	keys := destination.RecipientKeys
+	if len(destination.RoutingKeys) != 0 {
+		keys = destination.RoutingKeys
+	}
+
+	if t.OutboundTransport.AcceptRecipient(keys) {
+		return t.OutboundTransport.Send(data, destination)
+	}
+
+	conn := t.fetch(keys)
+	if conn == nil {
+		var err error
+
+		conn, err = t.dial(destination)
+		if err != nil {
+			return "", err
+		}
+
+		if destination.TransportReturnRoute != decorator.TransportReturnRouteAll {
+			defer closeConn(conn)
+		}
+	}
+
+	if err := conn.Write(context.Background(), websocket.MessageText, data); err != nil {
+		return "", fmt.Errorf("websocket write message : %w", err)
+	}
+
+	return "", nil
+}
+
+func (t *WSOutbound) dial(destination *service.Destination) (*websocket.Conn, error) {
+	opts := &websocket.DialOptions{HTTPClient: t.client}
+
+	conn, _, err := websocket.Dial(context.Background(), destination.ServiceEndpoint, opts) // nolint:bodyclose // no body
+	if err != nil {
+		return nil, fmt.Errorf("websocket client : %w", err)
+	}
+
+	if destination.TransportReturnRoute == decorator.TransportReturnRouteAll {
+		t.mutex.Lock()
+
+		for _, k := range destination.RecipientKeys {
+			t.conns[k] = conn
+		}
+
+		t.mutex.Unlock()
+
+		go t.listen(conn, destination.RecipientKeys)
+	}
+
+	return conn, nil
+}
+
+func (t *WSOutbound) fetch(keys []string) *websocket.Conn {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+
+	for _, k := range keys {
+		if conn, ok := t.conns[k]; ok {
+			return conn
+		}
+	}
+
+	return nil
+}
+
+// listen hands the messages received on the connection to the agent, until the connection is closed.
+func (t *WSOutbound) listen(conn *websocket.Conn, keys []string) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	defer func() {
+		cancel()
+
+		t.mutex.Lock()
+
+		for _, k := range keys {
+			if t.conns[k] == conn {
+				delete(t.conns, k)
+			}
+		}
+
+		t.mutex.Unlock()
+
+		closeConn(conn)
+	}()
+
+	go keepAlive(ctx, conn)
+
+	for {
+		_, message, err := conn.Read(ctx)
+		if err != nil {
+			if websocket.CloseStatus(err) != websocket.StatusNormalClosure {
+				logger.Warnf("websocket read message : %s", err)
+			}
+
+			return
+		}
+
+		envelope, err := t.prov.Packager().UnpackMessage(message)
+		if err != nil {
+			logger.Warnf("failed to unpack message : %s", err)
+
+			continue
+		}
+
+		if err = t.prov.InboundMessageHandler()(envelope); err != nil {
+			logger.Warnf("incoming message processing failed : %s", err)
+		}
+	}
+}
+
+func keepAlive(ctx context.Context, conn *websocket.Conn) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := conn.Ping(ctx); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func closeConn(conn *websocket.Conn) {
+	err := conn.Close(websocket.StatusNormalClosure, "closing the connection")
+	if err != nil && websocket.CloseStatus(err) != websocket.StatusNormalClosure {
+		logger.Warnf("failed to close websocket connection : %s", err)
+	}
+}
@@ -0,0 +1,233 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockpackager "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/packager"
+	"github.com/stretchr/testify/require"
+	"nhooyr.io/websocket"
+)
+
+func TestWSOutbound(t *testing.T) {
+	t.Run("dialed with the transport", func(t *testing.T) {
+		received := make(chan []byte, 1)
+		srv := newWSServer(t, received, nil)
+		rt := &countingTransport{}
+
+		ot := NewWSOutbound(&mockOutbound{}, rt)
+		require.NoError(t, ot.Start(&mockProvider{}))
+
+		_, err := ot.Send([]byte("msg"), &service.Destination{ServiceEndpoint: srv, RecipientKeys: []string{"key-1"}})
+		require.NoError(t, err)
+		require.Equal(t, []byte("msg"), <-received)
+		require.Equal(t, 1, rt.count())
+
+		// not kept open without the return route
+		require.False(t, ot.AcceptRecipient([]string{"key-1"}))
+	})
+
+	t.Run("kept open with the return route", func(t *testing.T) {
+		received := make(chan []byte, 2)
+		reply := make(chan []byte)
+		srv := newWSServer(t, received, reply)
+		rt := &countingTransport{}
+		handled := make(chan *transport.Envelope, 1)
+		envelope := &transport.Envelope{Message: []byte("response")}
+
+		ot := NewWSOutbound(&mockOutbound{}, rt)
+		require.NoError(t, ot.Start(&mockProvider{
+			packager: &mockpackager.Packager{UnpackValue: envelope},
+			handler: func(e *transport.Envelope) error {
+				handled <- e
+
+				return errors.New("handler error")
+			},
+		}))
+
+		destination := &service.Destination{
+			ServiceEndpoint: srv, RecipientKeys: []string{"key-1"},
+			TransportReturnRoute: decorator.TransportReturnRouteAll,
+		}
+
+		_, err := ot.Send([]byte("msg-1"), destination)
+		require.NoError(t, err)
+		require.True(t, ot.AcceptRecipient([]string{"key-1"}))
+
+		_, err = ot.Send([]byte("msg-2"), destination)
+		require.NoError(t, err)
+		require.Equal(t, []byte("msg-1"), <-received)
+		require.Equal(t, []byte("msg-2"), <-received)
+		require.Equal(t, 1, rt.count())
+
+		reply <- []byte("response")
+		require.Equal(t, envelope, <-handled)
+
+		// forgotten once closed by the destination
+		close(reply)
+
+		require.Eventually(t, func() bool {
+			return !ot.AcceptRecipient([]string{"key-1"})
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("unpack error", func(t *testing.T) {
+		reply := make(chan []byte)
+		srv := newWSServer(t, make(chan []byte, 1), reply)
+
+		ot := NewWSOutbound(&mockOutbound{}, &countingTransport{})
+		require.NoError(t, ot.Start(&mockProvider{packager: &mockpackager.Packager{UnpackErr: errors.New("unpack")}}))
+
+		_, err := ot.Send([]byte("msg"), &service.Destination{
+			ServiceEndpoint: srv, RecipientKeys: []string{"key-1"},
+			TransportReturnRoute: decorator.TransportReturnRouteAll,
+		})
+		require.NoError(t, err)
+
+		reply <- []byte("response")
+		close(reply)
+
+		require.Eventually(t, func() bool {
+			return !ot.AcceptRecipient([]string{"key-1"})
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("sent on the connection of the wrapped transport", func(t *testing.T) {
+		wrapped := &mockOutbound{accept: true}
+		rt := &countingTransport{}
+
+		ot := NewWSOutbound(wrapped, rt)
+		require.NoError(t, ot.Start(&mockProvider{}))
+		require.True(t, ot.AcceptRecipient([]string{"key-1"}))
+
+		_, err := ot.Send([]byte("msg"), &service.Destination{
+			ServiceEndpoint: "ws://unreachable", RecipientKeys: []string{"key-1"}, RoutingKeys: []string{"key-2"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, wrapped.sent)
+		require.Equal(t, 0, rt.count())
+	})
+
+	t.Run("dial error", func(t *testing.T) {
+		ot := NewWSOutbound(&mockOutbound{}, &countingTransport{err: errors.New("proxy error")})
+		require.NoError(t, ot.Start(&mockProvider{}))
+
+		_, err := ot.Send([]byte("msg"), &service.Destination{ServiceEndpoint: "ws://example.com"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "proxy error")
+	})
+}
+
+// newWSServer returns the endpoint of a WebSocket server, sending the messages received to the received channel, and
+// the messages of the reply channel to the client until it is closed.
+func newWSServer(t *testing.T, received, reply chan []byte) string {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+
+		go func() {
+			for {
+				_, msg, err := conn.Read(context.Background())
+				if err != nil {
+					return
+				}
+
+				received <- msg
+			}
+		}()
+
+		if reply == nil {
+			return
+		}
+
+		for msg := range reply {
+			if err = conn.Write(context.Background(), websocket.MessageText, msg); err != nil {
+				return
+			}
+		}
+
+		conn.Close(websocket.StatusNormalClosure, "") // nolint:errcheck,gosec // test server
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+type countingTransport struct {
+	mutex sync.Mutex
+	trips int
+	err   error
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mutex.Lock()
+	c.trips++
+	c.mutex.Unlock()
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func (c *countingTransport) count() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.trips
+}
+
+type mockOutbound struct {
+	transport.OutboundTransport
+	accept bool
+	sent   int
+}
+
+func (m *mockOutbound) Start(transport.Provider) error {
+	return nil
+}
+
+func (m *mockOutbound) AcceptRecipient([]string) bool {
+	return m.accept
+}
+
+func (m *mockOutbound) Send([]byte, *service.Destination) (string, error) {
+	m.sent++
+
+	return "", nil
+}
+
+type mockProvider struct {
+	transport.Provider
+	packager transport.Packager
+	handler  transport.InboundMessageHandler
+}
+
+func (m *mockProvider) Packager() transport.Packager {
+	return m.packager
+}
+
+func (m *mockProvider) InboundMessageHandler() transport.InboundMessageHandler {
+	return m.handler
+}
@@ -0,0 +1,259 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package qrcode
+
+const (
+	numMasks = 8
+
+	// eclMedium is the format bits of the medium error correction level.
+	eclMedium = 0
+
+	formatGenerator  = 0x537
+	formatMask       = 0x5412
+	versionGenerator = 0x1f25
+)
+
+// drawFunctionPatterns draws the finder, timing and alignment patterns, and the version information; the format
+// information is reserved, and drawn with the mask.
+func (c *Code) drawFunctionPatterns() {
+	for i := 0; i < c.size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	c.drawFinderPattern(3, 3)
+	c.drawFinderPattern(c.size-4, 3)
+	c.drawFinderPattern(3, c.size-4)
+
+	positions := alignmentPositions(c.version)
+	last := len(positions) - 1
+
+	for i, x := range positions {
+		for j, y := range positions {
+			// the alignment patterns don't overlap the finder patterns
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+
+			c.drawAlignmentPattern(x, y)
+		}
+	}
+
+	c.drawFormatBits(0)
+	c.drawVersion()
+}
+
+// drawFinderPattern draws the finder pattern centered on the module, with its separator.
+func (c *Code) drawFinderPattern(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+
+			if xx >= 0 && xx < c.size && yy >= 0 && yy < c.size {
+				dist := max(abs(dx), abs(dy))
+				c.setFunction(xx, yy, dist != 2 && dist != 4)
+			}
+		}
+	}
+}
+
+func (c *Code) drawAlignmentPattern(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// drawFormatBits draws the two copies of the format information of the mask, and the dark module.
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatBits(mask)
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(bits, i))
+	}
+
+	c.setFunction(8, 7, bit(bits, 6))
+	c.setFunction(8, 8, bit(bits, 7))
+	c.setFunction(7, 8, bit(bits, 8))
+
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(bits, i))
+	}
+
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.size-1-i, 8, bit(bits, i))
+	}
+
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.size-15+i, bit(bits, i))
+	}
+
+	c.setFunction(8, c.size-8, true)
+}
+
+// drawVersion draws the two copies of the version information, from version 7.
+func (c *Code) drawVersion() {
+	if c.version < 7 {
+		return
+	}
+
+	bits := versionBits(c.version)
+
+	for i := 0; i < 18; i++ {
+		a, b := c.size-11+i%3, i/3
+
+		c.setFunction(a, b, bit(bits, i))
+		c.setFunction(b, a, bit(bits, i))
+	}
+}
+
+// drawCodewords draws the codewords in the zigzag order, in the modules not reserved by the function patterns.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+
+	for right := c.size - 1; right >= 1; right -= 2 {
+		// the vertical timing pattern is skipped
+		if right == 6 {
+			right = 5
+		}
+
+		upward := (right+1)&2 == 0
+
+		for vert := 0; vert < c.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if upward {
+					y = c.size - 1 - vert
+				}
+
+				if !c.function[y][x] && i < len(data)*8 {
+					c.modules[y][x] = data[i/8]>>(7-i%8)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyBestMask applies the mask with the lowest penalty, and draws its format information.
+func (c *Code) applyBestMask() {
+	best, minPenalty := 0, -1
+
+	for mask := 0; mask < numMasks; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+
+		if penalty := c.penalty(); minPenalty < 0 || penalty < minPenalty {
+			best, minPenalty = mask, penalty
+		}
+
+		// the mask is its own inverse
+		c.applyMask(mask)
+	}
+
+	c.applyMask(best)
+	c.drawFormatBits(best)
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if !c.function[y][x] && masked(mask, x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+func masked(mask, x, y int) bool {
+	switch mask {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+// alignmentPositions returns the coordinates of the centers of the alignment patterns, on both axes.
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+
+	numAlign := version/7 + 2
+	step := (version*8 + numAlign*3 + 5) / (numAlign*4 - 4) * 2
+	result := make([]int, numAlign)
+	result[0] = 6
+
+	for i, pos := numAlign-1, version*4+17-7; i >= 1; i, pos = i-1, pos-step {
+		result[i] = pos
+	}
+
+	return result
+}
+
+// formatBits returns the format information of the mask : the error correction level and the mask, their BCH code,
+// masked.
+func formatBits(mask int) int {
+	data := eclMedium<<3 | mask
+	rem := data
+
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * formatGenerator)
+	}
+
+	return (data<<10 | rem) ^ formatMask
+}
+
+// versionBits returns the version information : the version and its BCH code.
+func versionBits(version int) int {
+	rem := version
+
+	for i := 0; i < 12; i++ {
+		rem = (rem << 1) ^ ((rem >> 11) * versionGenerator)
+	}
+
+	return version<<12 | rem
+}
+
+func bit(x, i int) bool {
+	return (x>>i)&1 != 0
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
+
+func max(x, y int) int {
+	if x > y {
+		return x
+	}
+
+	return y
+}
@@ -0,0 +1,101 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package qrcode
+
+const (
+	penaltyRun     = 3
+	penaltyBlock   = 3
+	penaltyFinder  = 40
+	penaltyBalance = 10
+
+	minRun = 5
+)
+
+// finderLike is the 1:1:3:1:1 finder pattern followed by four light modules, the penalty applies in both directions.
+// nolint:gochecknoglobals // read-only lookup table
+var finderLike = []bool{true, false, true, true, true, false, true, false, false, false, false}
+
+// penalty returns the penalty score of the masked symbol : the long runs and the blocks of the same color, the patterns
+// alike the finder patterns, and the imbalance of the dark and light modules. The mask with the lowest penalty is the
+// easiest to scan.
+func (c *Code) penalty() int {
+	result := 0
+
+	for i := 0; i < c.size; i++ {
+		row := make([]bool, c.size)
+		col := make([]bool, c.size)
+
+		for j := 0; j < c.size; j++ {
+			row[j], col[j] = c.modules[i][j], c.modules[j][i]
+		}
+
+		result += linePenalty(row) + linePenalty(col)
+	}
+
+	dark := 0
+
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+
+			if x > 0 && y > 0 && c.modules[y][x] == c.modules[y][x-1] && c.modules[y][x] == c.modules[y-1][x] &&
+				c.modules[y][x] == c.modules[y-1][x-1] {
+				result += penaltyBlock
+			}
+		}
+	}
+
+	total := c.size * c.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+
+	return result + k*penaltyBalance
+}
+
+// linePenalty returns the penalty of the runs and of the finder-like patterns of a row or a column.
+func linePenalty(line []bool) int {
+	result := 0
+	run := 1
+
+	for i := 1; i <= len(line); i++ {
+		if i < len(line) && line[i] == line[i-1] {
+			run++
+
+			continue
+		}
+
+		if run >= minRun {
+			result += penaltyRun + run - minRun
+		}
+
+		run = 1
+	}
+
+	for i := 0; i+len(finderLike) <= len(line); i++ {
+		if matches(line[i:], finderLike, false) || matches(line[i:], finderLike, true) {
+			result += penaltyFinder
+		}
+	}
+
+	return result
+}
+
+// matches returns true if the line starts with the pattern, or with the reversed pattern.
+func matches(line, pattern []bool, reversed bool) bool {
+	for i, p := range pattern {
+		if reversed {
+			p = pattern[len(pattern)-1-i]
+		}
+
+		if line[i] != p {
+			return false
+		}
+	}
+
+	return true
+}
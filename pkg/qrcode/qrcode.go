@@ -0,0 +1,251 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package qrcode encodes data in QR codes (ISO/IEC 18004), to scan the invitations of the router with the wallets :
+// byte mode, medium error correction level, the smallest version that fits the data.
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+const (
+	minVersion = 1
+	maxVersion = 40
+
+	// quietZone is the width of the light border around the symbol, in modules.
+	quietZone = 4
+
+	// DefaultScale is the size of the modules in the PNG images, in pixels.
+	DefaultScale = 8
+)
+
+// ErrTooLong is returned when the data doesn't fit in the largest QR code.
+var ErrTooLong = errors.New("data too long for a qr code")
+
+// Error correction codewords per block, and number of blocks, of the medium error correction level per version.
+// nolint:gochecknoglobals // read-only lookup tables
+var (
+	eccCodewordsPerBlock = [maxVersion + 1]int{
+		-1, 10, 16, 26, 18, 24, 16, 18, 22, 22, 26, 30, 22, 22, 24, 24, 28, 28, 26, 26, 26,
+		26, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28, 28,
+	}
+	eccBlocks = [maxVersion + 1]int{
+		-1, 1, 1, 1, 2, 2, 4, 4, 4, 5, 5, 5, 8, 9, 9, 10, 10, 11, 13, 14, 16,
+		17, 17, 18, 20, 21, 23, 25, 26, 28, 29, 31, 33, 35, 37, 38, 40, 43, 45, 47, 49,
+	}
+)
+
+// Code is a QR code.
+type Code struct {
+	version  int
+	size     int
+	modules  [][]bool
+	function [][]bool
+}
+
+// Encode encodes the data in the smallest QR code that fits it.
+func Encode(data []byte) (*Code, error) {
+	version := minVersion
+
+	for ; ; version++ {
+		if version > maxVersion {
+			return nil, ErrTooLong
+		}
+
+		if 4+countBits(version)+len(data)*8 <= dataCodewords(version)*8 {
+			break
+		}
+	}
+
+	c := newCode(version)
+	c.drawFunctionPatterns()
+	c.drawCodewords(addECCAndInterleave(version, encodeData(version, data)))
+	c.applyBestMask()
+
+	return c, nil
+}
+
+// Size returns the number of modules per side of the QR code.
+func (c *Code) Size() int {
+	return c.size
+}
+
+// Dark returns true if the module at column x and row y is dark.
+func (c *Code) Dark(x, y int) bool {
+	return c.modules[y][x]
+}
+
+// PNG returns the PNG image of the QR code with its quiet zone, scale pixels per module.
+func (c *Code) PNG(scale int) ([]byte, error) {
+	if scale <= 0 {
+		scale = DefaultScale
+	}
+
+	side := (c.size + 2*quietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, side, side))
+
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			mx, my := x/scale-quietZone, y/scale-quietZone
+
+			if mx >= 0 && my >= 0 && mx < c.size && my < c.size && c.modules[my][mx] {
+				img.SetGray(x, y, color.Gray{Y: 0})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: 0xff})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func newCode(version int) *Code {
+	size := version*4 + 17
+
+	c := &Code{version: version, size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+
+	for i := range c.modules {
+		c.modules[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+
+	return c
+}
+
+// countBits returns the number of bits of the character count of the byte mode.
+func countBits(version int) int {
+	if version < 10 {
+		return 8
+	}
+
+	return 16
+}
+
+// rawDataModules returns the number of modules available for the codewords, once the function patterns drawn.
+func rawDataModules(version int) int {
+	result := (16*version+128)*version + 64
+
+	if version >= 2 {
+		numAlign := version/7 + 2
+		result -= (25*numAlign-10)*numAlign - 55
+
+		if version >= 7 {
+			result -= 36
+		}
+	}
+
+	return result
+}
+
+// dataCodewords returns the number of data codewords of the version.
+func dataCodewords(version int) int {
+	return rawDataModules(version)/8 - eccCodewordsPerBlock[version]*eccBlocks[version]
+}
+
+// encodeData returns the data codewords : the byte mode segment, the terminator and the pad codewords.
+func encodeData(version int, data []byte) []byte {
+	bb := &bitBuffer{}
+	bb.append(0x4, 4)
+	bb.append(len(data), countBits(version))
+
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	capacity := dataCodewords(version) * 8
+
+	terminator := capacity - len(bb.bits)
+	if terminator > 4 {
+		terminator = 4
+	}
+
+	bb.append(0, terminator)
+	bb.append(0, (8-len(bb.bits)%8)%8)
+
+	for pad := 0xec; len(bb.bits) < capacity; pad ^= 0xec ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	return bb.bytes()
+}
+
+// addECCAndInterleave splits the data codewords in blocks, adds the error correction codewords of each block, and
+// interleaves the blocks.
+func addECCAndInterleave(version int, data []byte) []byte {
+	numBlocks := eccBlocks[version]
+	blockECCLen := eccCodewordsPerBlock[version]
+	rawCodewords := rawDataModules(version) / 8
+	numShortBlocks := numBlocks - rawCodewords%numBlocks
+	shortBlockLen := rawCodewords / numBlocks
+
+	divisor := reedSolomonDivisor(blockECCLen)
+	blocks := make([][]byte, numBlocks)
+
+	for i, k := 0, 0; i < numBlocks; i++ {
+		datLen := shortBlockLen - blockECCLen
+		if i >= numShortBlocks {
+			datLen++
+		}
+
+		dat := data[k : k+datLen]
+		k += datLen
+
+		block := make([]byte, 0, shortBlockLen+1)
+		block = append(block, dat...)
+
+		// the short blocks are padded to interleave the data codewords, the padding is skipped
+		if i < numShortBlocks {
+			block = append(block, 0)
+		}
+
+		blocks[i] = append(block, reedSolomonRemainder(dat, divisor)...)
+	}
+
+	result := make([]byte, 0, rawCodewords)
+
+	for i := 0; i <= shortBlockLen; i++ {
+		for j, block := range blocks {
+			if i != shortBlockLen-blockECCLen || j >= numShortBlocks {
+				result = append(result, block[i])
+			}
+		}
+	}
+
+	return result
+}
+
+type bitBuffer struct {
+	bits []bool
+}
+
+func (b *bitBuffer) append(val, n int) {
+	for i := n - 1; i >= 0; i-- {
+		b.bits = append(b.bits, (val>>i)&1 != 0)
+	}
+}
+
+func (b *bitBuffer) bytes() []byte {
+	result := make([]byte, len(b.bits)/8)
+
+	for i, bit := range b.bits {
+		if bit {
+			result[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,278 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncode(t *testing.T) {
+	t.Run("smallest version", func(t *testing.T) {
+		for n, version := range map[int]int{0: 1, 14: 1, 15: 2, 26: 2, 27: 3, 213: 10, 214: 11, 2331: 40} {
+			c, err := Encode(bytes.Repeat([]byte("a"), n))
+			require.NoError(t, err)
+			require.Equal(t, version, c.version)
+			require.Equal(t, version*4+17, c.Size())
+		}
+
+		_, err := Encode(bytes.Repeat([]byte("a"), 2332))
+		require.Equal(t, ErrTooLong, err)
+	})
+
+	t.Run("decoded", func(t *testing.T) {
+		for _, data := range []string{
+			"", "hello", "didcomm://invite?oob=" + strings.Repeat("eyJAaWQiOiJpbnYtMSJ9", 30),
+			strings.Repeat("0123456789", 100),
+		} {
+			c, err := Encode([]byte(data))
+			require.NoError(t, err)
+			require.Equal(t, data, string(decode(t, c)))
+		}
+	})
+
+	t.Run("function patterns", func(t *testing.T) {
+		c, err := Encode([]byte(strings.Repeat("x", 150)))
+		require.NoError(t, err)
+		require.Equal(t, 8, c.version)
+
+		// finder patterns: dark border, light ring, dark center, light separator
+		for _, corner := range [][2]int{{0, 0}, {c.size - 7, 0}, {0, c.size - 7}} {
+			x, y := corner[0], corner[1]
+			require.True(t, c.Dark(x, y))
+			require.False(t, c.Dark(x+1, y+1))
+			require.True(t, c.Dark(x+3, y+3))
+		}
+
+		require.False(t, c.Dark(7, 7))
+		require.True(t, c.Dark(8, c.size-8))
+
+		for i := 8; i < c.size-8; i++ {
+			require.Equal(t, i%2 == 0, c.Dark(i, 6))
+			require.Equal(t, i%2 == 0, c.Dark(6, i))
+		}
+
+		// alignment pattern centers
+		require.Equal(t, []int{6, 24, 42}, alignmentPositions(8))
+		require.True(t, c.Dark(24, 24))
+		require.False(t, c.Dark(25, 24))
+		require.True(t, c.Dark(26, 24))
+	})
+}
+
+func TestPNG(t *testing.T) {
+	c, err := Encode([]byte("hello"))
+	require.NoError(t, err)
+
+	for scale, side := range map[int]int{0: (21 + 8) * DefaultScale, 2: (21 + 8) * 2} {
+		data, err := c.PNG(scale)
+		require.NoError(t, err)
+
+		img, err := png.Decode(bytes.NewReader(data))
+		require.NoError(t, err)
+		require.Equal(t, side, img.Bounds().Dx())
+
+		s := side / (21 + 8)
+		r, _, _, _ := img.At(0, 0).RGBA()
+		require.Equal(t, uint32(0xffff), r)
+
+		r, _, _, _ = img.At(4*s, 4*s).RGBA()
+		require.Zero(t, r)
+	}
+}
+
+func TestCodewords(t *testing.T) {
+	t.Run("reed solomon", func(t *testing.T) {
+		// HELLO WORLD, version 1-M, from the ISO/IEC 18004 annex
+		data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+		require.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23},
+			reedSolomonRemainder(data, reedSolomonDivisor(10)))
+	})
+
+	t.Run("capacity", func(t *testing.T) {
+		require.Equal(t, 16, dataCodewords(1))
+		require.Equal(t, 28, dataCodewords(2))
+		require.Equal(t, 216, dataCodewords(10))
+		require.Equal(t, 2334, dataCodewords(40))
+	})
+
+	t.Run("format and version", func(t *testing.T) {
+		for mask, bits := range []string{
+			"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+			"100010111111001", "100000011001110", "100111110010111", "100101010100000",
+		} {
+			require.Equal(t, bits, toBinary(formatBits(mask), 15))
+		}
+
+		require.Equal(t, 0x07c94, versionBits(7))
+		require.Equal(t, 0x28c69, versionBits(40))
+	})
+}
+
+// decode reads back the data of the QR code : its mask from the format information, the codewords in the zigzag
+// order, the data codewords from the blocks once their error correction checked.
+func decode(t *testing.T, c *Code) []byte {
+	t.Helper()
+
+	var format int
+
+	for i := 0; i < 15; i++ {
+		x, y := c.size-1-i, 8
+		if i >= 8 {
+			x, y = 8, c.size-15+i
+		}
+
+		if c.Dark(x, y) {
+			format |= 1 << i
+		}
+	}
+
+	mask := -1
+
+	for m := 0; m < numMasks; m++ {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+
+	require.NotEqual(t, -1, mask)
+
+	reader := newCode(c.version)
+	reader.drawFunctionPatterns()
+
+	for y := range c.modules {
+		copy(reader.modules[y], c.modules[y])
+	}
+
+	reader.applyMask(mask)
+
+	codewords := make([]byte, rawDataModules(c.version)/8)
+	i := 0
+
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+
+		for vert := 0; vert < c.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x, y := right-j, vert
+				if (right+1)&2 == 0 {
+					y = c.size - 1 - vert
+				}
+
+				if !reader.function[y][x] && i < len(codewords)*8 {
+					if reader.modules[y][x] {
+						codewords[i/8] |= 1 << (7 - i%8)
+					}
+
+					i++
+				}
+			}
+		}
+	}
+
+	return parseData(t, deinterleave(t, c.version, codewords))
+}
+
+func deinterleave(t *testing.T, version int, codewords []byte) []byte {
+	t.Helper()
+
+	numBlocks := eccBlocks[version]
+	eccLen := eccCodewordsPerBlock[version]
+	numShort := numBlocks - len(codewords)%numBlocks
+	shortLen := len(codewords) / numBlocks
+
+	blocks := make([][]byte, numBlocks)
+	k := 0
+
+	for i := 0; i <= shortLen; i++ {
+		for j := range blocks {
+			// the short blocks are padded in the interleaving
+			if i == shortLen-eccLen && j < numShort {
+				continue
+			}
+
+			blocks[j] = append(blocks[j], codewords[k])
+			k++
+		}
+	}
+
+	var data []byte
+
+	for _, block := range blocks {
+		dat, ecc := block[:len(block)-eccLen], block[len(block)-eccLen:]
+		require.Equal(t, ecc, reedSolomonRemainder(dat, reedSolomonDivisor(eccLen)))
+
+		data = append(data, dat...)
+	}
+
+	return data
+}
+
+func parseData(t *testing.T, data []byte) []byte {
+	t.Helper()
+
+	bits := &bitBuffer{}
+
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+
+	read := func(pos, n int) int {
+		v := 0
+		for _, b := range bits.bits[pos : pos+n] {
+			v <<= 1
+
+			if b {
+				v |= 1
+			}
+		}
+
+		return v
+	}
+
+	require.Equal(t, 0x4, read(0, 4))
+
+	n := countBits(versionOf(len(data)))
+	length := read(4, n)
+	result := make([]byte, length)
+
+	for i := range result {
+		result[i] = byte(read(4+n+i*8, 8))
+	}
+
+	return result
+}
+
+func versionOf(dataLen int) int {
+	for v := minVersion; v <= maxVersion; v++ {
+		if dataCodewords(v) == dataLen {
+			return v
+		}
+	}
+
+	return 0
+}
+
+func toBinary(v, n int) string {
+	var sb strings.Builder
+
+	for i := n - 1; i >= 0; i-- {
+		if bit(v, i) {
+			sb.WriteByte('1')
+		} else {
+			sb.WriteByte('0')
+		}
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,278 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package queue
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+// Alert scopes.
+const (
+	ScopeRecipient = "recipient"
+	ScopeGlobal    = "global"
+)
+
+// Alert states.
+const (
+	StateHigh    = "high"
+	StateCleared = "cleared"
+)
+
+var logger = log.New("hub-router/queue")
+
+// Mailboxes are the pickup mailboxes of the wallets, eg: the pickup queue.
+type Mailboxes interface {
+	// Depth returns the number of messages queued for the wallet.
+	Depth(theirDID string) (int, error)
+}
+
+// RecipientSource returns the DIDs of the wallets the router queues messages for, keyed by connection ID.
+type RecipientSource func() (map[string]string, error)
+
+// Config holds the queue depth high-watermarks; a zero watermark disables the corresponding alerts.
+type Config struct {
+	RecipientWatermark int
+	GlobalWatermark    int
+	// LoadShedding rejects new connections while the global queue depth is above its watermark.
+	LoadShedding bool
+}
+
+// Enabled returns true if a watermark is configured.
+func (c *Config) Enabled() bool {
+	return c != nil && (c.RecipientWatermark > 0 || c.GlobalWatermark > 0)
+}
+
+// Alert is raised when the queue depth crosses a watermark, and when it goes back below.
+type Alert struct {
+	Time         time.Time `json:"time"`
+	Scope        string    `json:"scope"`
+	State        string    `json:"state"`
+	ConnectionID string    `json:"connectionID,omitempty"`
+	Depth        int       `json:"depth"`
+	Watermark    int       `json:"watermark"`
+}
+
+// Monitor checks the depth of the router message queues (the pickup mailboxes) against the watermarks.
+type Monitor struct {
+	mailboxes  Mailboxes
+	recipients RecipientSource
+	config     *Config
+	onAlert    func(*Alert)
+	mutex      sync.Mutex
+	high       map[string]bool
+	shedding   bool
+	queued     map[string]time.Time
+	onDrain    func(connID string, queued time.Duration)
+	paused     bool
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// New returns a new queue Monitor reading the depths of the given pickup mailboxes.
+func New(mailboxes Mailboxes, recipients RecipientSource, config *Config, onAlert func(*Alert)) *Monitor {
+	if onAlert == nil {
+		onAlert = func(*Alert) {}
+	}
+
+	return &Monitor{
+		mailboxes:  mailboxes,
+		recipients: recipients,
+		config:     config,
+		onAlert:    onAlert,
+		high:       make(map[string]bool),
+		queued:     make(map[string]time.Time),
+		onDrain:    func(string, time.Duration) {},
+		stop:       make(chan struct{}),
+	}
+}
+
+// OnDrain sets the function called when the queue of a recipient is drained, with the time it stayed non-empty.
+func (m *Monitor) OnDrain(onDrain func(connID string, queued time.Duration)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.onDrain = onDrain
+}
+
+// Check reads the queue depths and raises an alert for each watermark crossed since the previous check.
+func (m *Monitor) Check() error {
+	recipients, err := m.recipients()
+	if err != nil {
+		return fmt.Errorf("get queue recipients : %w", err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	total := 0
+
+	for connID, theirDID := range recipients {
+		depth, err := m.depth(theirDID)
+		if err != nil {
+			return err
+		}
+
+		total += depth
+
+		m.drain(connID, depth)
+		m.watermark(ScopeRecipient, connID, depth, m.config.RecipientWatermark)
+	}
+
+	high := m.watermark(ScopeGlobal, "", total, m.config.GlobalWatermark)
+
+	m.shedding = m.config.LoadShedding && high
+
+	return nil
+}
+
+// Depths returns the queue depth of each recipient, keyed by connection ID, without checking the watermarks.
+func (m *Monitor) Depths() (map[string]int, error) {
+	recipients, err := m.recipients()
+	if err != nil {
+		return nil, fmt.Errorf("get queue recipients : %w", err)
+	}
+
+	depths := make(map[string]int, len(recipients))
+
+	for connID, theirDID := range recipients {
+		depths[connID], err = m.depth(theirDID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return depths, nil
+}
+
+// Shedding returns true if new connections must be rejected, the global queue depth being above its watermark.
+func (m *Monitor) Shedding() bool {
+	if m == nil {
+		return false
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.shedding
+}
+
+// Start checks the queue depths periodically until Stop is called.
+func (m *Monitor) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if m.isPaused() {
+					continue
+				}
+
+				if err := m.Check(); err != nil {
+					logger.Warnf("queue depth check : %s", err)
+				}
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Pause suspends the periodic checks, eg: on a standby node, so that the alerts are raised by the active node only.
+func (m *Monitor) Pause() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.paused = true
+}
+
+// Resume resumes the periodic checks, and checks the queue depths right away.
+func (m *Monitor) Resume() error {
+	m.mutex.Lock()
+	m.paused = false
+	m.mutex.Unlock()
+
+	return m.Check()
+}
+
+func (m *Monitor) isPaused() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.paused
+}
+
+// Stop stops the periodic checks.
+func (m *Monitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+}
+
+func (m *Monitor) depth(theirDID string) (int, error) {
+	depth, err := m.mailboxes.Depth(theirDID)
+	if err != nil {
+		return 0, fmt.Errorf("get mailbox : %w", err)
+	}
+
+	return depth, nil
+}
+
+// drain tracks since when the queue of the recipient is non-empty, and reports the queueing time once drained.
+func (m *Monitor) drain(connID string, depth int) {
+	since, queued := m.queued[connID]
+
+	switch {
+	case depth > 0 && !queued:
+		m.queued[connID] = time.Now()
+	case depth == 0 && queued:
+		delete(m.queued, connID)
+
+		m.onDrain(connID, time.Since(since))
+	}
+}
+
+// watermark raises an alert if the depth crossed the watermark, and returns true if the depth is above it.
+func (m *Monitor) watermark(scope, connID string, depth, watermark int) bool {
+	if watermark <= 0 {
+		return false
+	}
+
+	key := scope + ":" + connID
+	high := depth >= watermark
+
+	if high == m.high[key] {
+		return high
+	}
+
+	alert := &Alert{
+		Time: time.Now().UTC(), Scope: scope, State: StateCleared, ConnectionID: connID, Depth: depth, Watermark: watermark,
+	}
+
+	if high {
+		alert.State = StateHigh
+		m.high[key] = true
+
+		logger.Warnf("queue depth above watermark : scope=%s connectionID=%s depth=%d watermark=%d",
+			scope, connID, depth, watermark)
+	} else {
+		delete(m.high, key)
+
+		logger.Infof("queue depth back below watermark : scope=%s connectionID=%s depth=%d watermark=%d",
+			scope, connID, depth, watermark)
+	}
+
+	m.onAlert(alert)
+
+	return high
+}
@@ -0,0 +1,221 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package queue
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	var c *Config
+
+	require.False(t, c.Enabled())
+	require.False(t, (&Config{LoadShedding: true}).Enabled())
+	require.True(t, (&Config{GlobalWatermark: 1}).Enabled())
+}
+
+func TestMonitor(t *testing.T) {
+	t.Run("watermark alerts and load shedding", func(t *testing.T) {
+		p := &mockMailboxes{}
+
+		var alerts []*Alert
+
+		m := New(p, func() (map[string]string, error) {
+			return map[string]string{"conn-1": "did:1", "conn-2": "did:2", "conn-3": "did:3"}, nil
+		}, &Config{RecipientWatermark: 5, GlobalWatermark: 8, LoadShedding: true}, func(a *Alert) {
+			alerts = append(alerts, a)
+		})
+
+		require.NoError(t, m.Check())
+		require.Empty(t, alerts)
+		require.False(t, m.Shedding())
+
+		putInbox(t, p, "did:1", 6)
+		putInbox(t, p, "did:2", 3)
+
+		require.NoError(t, m.Check())
+		require.Len(t, alerts, 2)
+		require.Equal(t, &Alert{
+			Time: alerts[0].Time, Scope: ScopeRecipient, State: StateHigh, ConnectionID: "conn-1", Depth: 6, Watermark: 5,
+		}, alerts[0])
+		require.Equal(t, ScopeGlobal, alerts[1].Scope)
+		require.Equal(t, 9, alerts[1].Depth)
+		require.True(t, m.Shedding())
+
+		require.NoError(t, m.Check())
+		require.Len(t, alerts, 2)
+
+		putInbox(t, p, "did:1", 1)
+
+		require.NoError(t, m.Check())
+		require.Len(t, alerts, 4)
+		require.Equal(t, StateCleared, alerts[2].State)
+		require.Equal(t, StateCleared, alerts[3].State)
+		require.False(t, m.Shedding())
+	})
+
+	t.Run("no load shedding", func(t *testing.T) {
+		p := &mockMailboxes{}
+
+		m := New(p, func() (map[string]string, error) {
+			return map[string]string{"conn-1": "did:1"}, nil
+		}, &Config{GlobalWatermark: 1}, nil)
+
+		putInbox(t, p, "did:1", 1)
+
+		require.NoError(t, m.Check())
+		require.False(t, m.Shedding())
+
+		var nilMonitor *Monitor
+
+		require.False(t, nilMonitor.Shedding())
+	})
+
+	t.Run("drain", func(t *testing.T) {
+		p := &mockMailboxes{}
+
+		m := New(p, func() (map[string]string, error) {
+			return map[string]string{"conn-1": "did:1"}, nil
+		}, &Config{}, nil)
+
+		drained := make(map[string]time.Duration)
+
+		m.OnDrain(func(connID string, queued time.Duration) {
+			drained[connID] = queued
+		})
+
+		putInbox(t, p, "did:1", 2)
+		require.NoError(t, m.Check())
+
+		time.Sleep(10 * time.Millisecond)
+
+		putInbox(t, p, "did:1", 1)
+		require.NoError(t, m.Check())
+		require.Empty(t, drained)
+
+		putInbox(t, p, "did:1", 0)
+		require.NoError(t, m.Check())
+		require.Len(t, drained, 1)
+		require.GreaterOrEqual(t, drained["conn-1"], 10*time.Millisecond)
+
+		require.NoError(t, m.Check())
+		require.Len(t, drained, 1)
+	})
+
+	t.Run("depths", func(t *testing.T) {
+		p := &mockMailboxes{}
+
+		m := New(p, func() (map[string]string, error) {
+			return map[string]string{"conn-1": "did:1", "conn-2": "did:2"}, nil
+		}, &Config{RecipientWatermark: 1}, func(*Alert) {
+			require.Fail(t, "unexpected alert")
+		})
+
+		putInbox(t, p, "did:1", 4)
+
+		depths, err := m.Depths()
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{"conn-1": 4, "conn-2": 0}, depths)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		m := New(&mockMailboxes{}, func() (map[string]string, error) {
+			return nil, errors.New("recipients error")
+		}, &Config{GlobalWatermark: 1}, nil)
+
+		err := m.Check()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "recipients error")
+
+		_, err = m.Depths()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "recipients error")
+
+		m = New(&mockMailboxes{err: errors.New("get error")}, func() (map[string]string, error) {
+			return map[string]string{"conn-1": "did:1"}, nil
+		}, &Config{GlobalWatermark: 1}, nil)
+
+		err = m.Check()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get mailbox")
+
+		_, err = m.Depths()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get mailbox")
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		p := &mockMailboxes{}
+		alerts := make(chan *Alert, 1)
+
+		m := New(p, func() (map[string]string, error) {
+			return map[string]string{"conn-1": "did:1"}, nil
+		}, &Config{RecipientWatermark: 1}, func(a *Alert) {
+			alerts <- a
+		})
+
+		putInbox(t, p, "did:1", 1)
+
+		m.Start(time.Millisecond)
+		defer m.Stop()
+
+		select {
+		case a := <-alerts:
+			require.Equal(t, StateHigh, a.State)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+	})
+}
+
+func TestMonitorPause(t *testing.T) {
+	p := &mockMailboxes{}
+	alerts := make(chan *Alert, 1)
+
+	m := New(p, func() (map[string]string, error) {
+		return map[string]string{"conn-1": "did:1"}, nil
+	}, &Config{RecipientWatermark: 1}, func(a *Alert) {
+		alerts <- a
+	})
+
+	putInbox(t, p, "did:1", 1)
+
+	m.Pause()
+	m.Start(time.Millisecond)
+	defer m.Stop()
+
+	time.Sleep(20 * time.Millisecond)
+	require.Empty(t, alerts)
+
+	require.NoError(t, m.Resume())
+
+	a := <-alerts
+	require.Equal(t, StateHigh, a.State)
+}
+
+func putInbox(t *testing.T, p *mockMailboxes, theirDID string, count int) {
+	t.Helper()
+
+	if p.depths == nil {
+		p.depths = map[string]int{}
+	}
+
+	p.depths[theirDID] = count
+}
+
+type mockMailboxes struct {
+	depths map[string]int
+	err    error
+}
+
+func (m *mockMailboxes) Depth(theirDID string) (int, error) {
+	return m.depths[theirDID], m.err
+}
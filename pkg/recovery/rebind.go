@@ -0,0 +1,100 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package recovery
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/trustbloc/hub-router/pkg/routes"
+)
+
+// Transfer is the result of the rebinding of a wallet.
+type Transfer struct {
+	Routes   int `json:"routes"`
+	Messages int `json:"messages"`
+}
+
+// Mailboxes moves the messages queued for a DID to the mailbox of another one, while both mailboxes are locked.
+type Mailboxes interface {
+	Move(previousDID, newDID string) (int, error)
+}
+
+// Rebinder rebinds the routes and the mailbox of the wallets to another DID.
+type Rebinder struct {
+	routes    *routes.Store
+	mailboxes Mailboxes
+}
+
+// NewRebinder returns a new Rebinder. p is the storage provider of the Aries agent, where the recipient keys routed by
+// the mediator are stored, and mailboxes the pickup mailboxes of the wallets.
+func NewRebinder(p storage.Provider, mailboxes Mailboxes) (*Rebinder, error) {
+	r, err := routes.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Rebinder{routes: r, mailboxes: mailboxes}, nil
+}
+
+// Routed returns the recipient keys, among the given ones, routed to the DID.
+func (r *Rebinder) Routed(did string, recipientKeys []string) ([]string, error) {
+	var routed []string
+
+	for _, key := range recipientKeys {
+		routeDID, err := r.routes.DID(key)
+		if errors.Is(err, storage.ErrDataNotFound) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("get route : %w", err)
+		}
+
+		if routeDID == did {
+			routed = append(routed, key)
+		}
+	}
+
+	return routed, nil
+}
+
+// Rebind routes the recipient keys to the new DID, and moves the messages queued for the previous DID to the mailbox
+// of the new one. The routes are rebound first, so that the new forwards are queued for the new DID, and routed back
+// to the previous DID if the messages fail to be moved.
+func (r *Rebinder) Rebind(previousDID, newDID string, recipientKeys []string) (*Transfer, error) {
+	rebound, err := r.route(recipientKeys, newDID)
+	if err == nil {
+		var moved int
+
+		moved, err = r.mailboxes.Move(previousDID, newDID)
+		if err == nil {
+			return &Transfer{Routes: len(recipientKeys), Messages: moved}, nil
+		}
+
+		err = fmt.Errorf("move mailbox : %w", err)
+	}
+
+	if _, rollbackErr := r.route(recipientKeys[:rebound], previousDID); rollbackErr != nil {
+		return nil, fmt.Errorf("%w, and the routes failed to be restored : %s", err, rollbackErr.Error())
+	}
+
+	return nil, err
+}
+
+// route routes the recipient keys to the DID, it returns the number of keys routed.
+func (r *Rebinder) route(recipientKeys []string, did string) (int, error) {
+	for i, key := range recipientKeys {
+		if err := r.routes.Route(key, did); err != nil {
+			return i, fmt.Errorf("save route : %w", err)
+		}
+	}
+
+	return len(recipientKeys), nil
+}
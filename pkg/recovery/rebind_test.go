@@ -0,0 +1,198 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package recovery
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/pickup"
+	"github.com/trustbloc/hub-router/pkg/routes"
+)
+
+type inbox struct {
+	DID          string            `json:"DID"`
+	MessageCount int               `json:"message_count"`
+	Messages     []json.RawMessage `json:"messages"`
+}
+
+func newStores(t *testing.T) (*mem.Provider, *routes.Store, storage.Store) {
+	t.Helper()
+
+	p := mem.NewProvider()
+
+	r, err := routes.Open(p)
+	require.NoError(t, err)
+
+	for key, did := range map[string]string{"key-1": "did:previous", "key-2": "did:previous", "key-3": "did:other"} {
+		require.NoError(t, r.Route(key, did))
+	}
+
+	mailboxes, err := p.OpenStore(messagepickup.Namespace)
+	require.NoError(t, err)
+	require.NoError(t, mailboxes.Put(pickup.MailboxKey("did:previous", "key-1"), []byte(`{"DID":"did:previous",`+
+		`"message_count":2,"messages":[{"id":"a"},{"id":"b"}]}`)))
+
+	index, err := p.OpenStore(pickup.IndexNamespace)
+	require.NoError(t, err)
+	require.NoError(t, index.Put("did:previous", []byte(`["key-1"]`)))
+
+	return p, r, mailboxes
+}
+
+func getInbox(t *testing.T, mailboxes storage.Store, did string) *inbox {
+	t.Helper()
+
+	inboxBytes, err := mailboxes.Get(did)
+	require.NoError(t, err)
+
+	in := &inbox{}
+	require.NoError(t, json.Unmarshal(inboxBytes, in))
+
+	return in
+}
+
+func newRebinder(t *testing.T, p storage.Provider) *Rebinder {
+	t.Helper()
+
+	q, err := pickup.NewQueue(p, lock.NewLocal())
+	require.NoError(t, err)
+
+	r, err := NewRebinder(p, q)
+	require.NoError(t, err)
+
+	return r
+}
+
+func requireRoutes(t *testing.T, r *routes.Store, expected map[string]string) {
+	t.Helper()
+
+	for key, did := range expected {
+		routeDID, err := r.DID(key)
+		require.NoError(t, err)
+		require.Equal(t, did, routeDID)
+	}
+}
+
+func TestNewRebinder(t *testing.T) {
+	_, err := NewRebinder(&mockstore.MockStoreProvider{
+		Store: &mockstore.MockStore{Store: map[string]mockstore.DBEntry{}}, FailNamespace: mediator.Coordination,
+	}, nil)
+	require.Error(t, err)
+}
+
+func TestRebinder(t *testing.T) {
+	t.Run("routes and mailbox rebound to the new did", func(t *testing.T) {
+		p, routes, mailboxes := newStores(t)
+
+		r := newRebinder(t, p)
+
+		routed, err := r.Routed("did:previous", []string{"key-1", "key-2", "key-3", "key-4"})
+		require.NoError(t, err)
+		require.Equal(t, []string{"key-1", "key-2"}, routed)
+
+		transfer, err := r.Rebind("did:previous", "did:new", routed)
+		require.NoError(t, err)
+		require.Equal(t, &Transfer{Routes: 2, Messages: 2}, transfer)
+
+		requireRoutes(t, routes, map[string]string{"key-1": "did:new", "key-2": "did:new", "key-3": "did:other"})
+
+		in := getInbox(t, mailboxes, pickup.MailboxKey("did:new", "key-1"))
+		require.Equal(t, "did:new", in.DID)
+		require.Equal(t, 2, in.MessageCount)
+		require.Len(t, in.Messages, 2)
+		require.Empty(t, getInbox(t, mailboxes, pickup.MailboxKey("did:previous", "key-1")).Messages)
+
+		// nothing left to move
+		transfer, err = r.Rebind("did:previous", "did:new", nil)
+		require.NoError(t, err)
+		require.Equal(t, &Transfer{}, transfer)
+	})
+
+	t.Run("queued messages appended to the mailbox of the new did", func(t *testing.T) {
+		p, _, mailboxes := newStores(t)
+
+		require.NoError(t, mailboxes.Put(pickup.MailboxKey("did:new", "key-1"), []byte(`{"DID":"did:new",`+
+			`"message_count":1,"messages":[{"id":"c"}],"total_size":10}`)))
+
+		transfer, err := newRebinder(t, p).Rebind("did:previous", "did:new", []string{"key-1"})
+		require.NoError(t, err)
+		require.Equal(t, 2, transfer.Messages)
+
+		in := getInbox(t, mailboxes, pickup.MailboxKey("did:new", "key-1"))
+		require.Equal(t, 3, in.MessageCount)
+		require.Equal(t, []json.RawMessage{
+			json.RawMessage(`{"id":"c"}`), json.RawMessage(`{"id":"a"}`), json.RawMessage(`{"id":"b"}`),
+		}, in.Messages)
+	})
+
+	t.Run("routes restored if the mailbox fails to be moved", func(t *testing.T) {
+		p, routes, mailboxes := newStores(t)
+
+		require.NoError(t, mailboxes.Put(pickup.MailboxKey("did:new", "key-1"), []byte(`{"messages":"none"}`)))
+
+		_, err := newRebinder(t, p).Rebind("did:previous", "did:new", []string{"key-1", "key-2"})
+		require.EqualError(t, err, "move mailbox : unmarshal mailbox did:new#key-1")
+
+		requireRoutes(t, routes, map[string]string{"key-1": "did:previous", "key-2": "did:previous"})
+		require.Len(t, getInbox(t, mailboxes, pickup.MailboxKey("did:previous", "key-1")).Messages, 2)
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		store := &mockstore.MockStore{Store: map[string]mockstore.DBEntry{}}
+
+		r, err := NewRebinder(mockstore.NewCustomMockStoreProvider(store), &failingMailboxes{})
+		require.NoError(t, err)
+		require.NoError(t, r.routes.Route("key-1", "did:previous"))
+
+		store.ErrPut = errors.New("put error")
+
+		_, err = r.Rebind("did:previous", "did:new", []string{"key-1"})
+		require.EqualError(t, err, "save route : put error")
+
+		store.ErrPut = nil
+
+		_, err = r.Rebind("did:previous", "did:new", []string{"key-1"})
+		require.EqualError(t, err, "move mailbox : move error")
+
+		route, err := r.routes.DID("key-1")
+		require.NoError(t, err)
+		require.Equal(t, "did:previous", route)
+
+		r.mailboxes = &failingMailboxes{onMove: func() { store.ErrPut = errors.New("put error") }}
+
+		_, err = r.Rebind("did:previous", "did:new", []string{"key-1"})
+		require.EqualError(t, err, "move mailbox : move error, and the routes failed to be restored : "+
+			"save route : put error")
+
+		store.ErrGet = errors.New("get error")
+
+		_, err = r.Routed("did:previous", []string{"key-1"})
+		require.EqualError(t, err, "get route : get error")
+	})
+}
+
+type failingMailboxes struct {
+	onMove func()
+}
+
+func (m *failingMailboxes) Move(string, string) (int, error) {
+	if m.onMove != nil {
+		m.onMove()
+	}
+
+	return 0, errors.New("move error")
+}
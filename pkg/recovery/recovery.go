@@ -0,0 +1,142 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package recovery transfers the mediation of a wallet recovered on a new device to the connection of the new device :
+// the recipient keys of the wallet routed by the router, and the messages queued for it, are rebound to the DID of the
+// new connection, given proof of control of the keys of the wallet or a recovery token issued for its connection.
+package recovery
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/fingerprint"
+)
+
+// Msg types.
+const (
+	// TransferMsgType requests the transfer of the mediation of the previous DID of the wallet to the connection the
+	// message is received on.
+	TransferMsgType = "https://trustbloc.dev/mediator-recovery/1.0/transfer"
+	// TransferAckMsgType acknowledges the transfer.
+	TransferAckMsgType = "https://trustbloc.dev/mediator-recovery/1.0/transfer-ack"
+)
+
+const (
+	// ProofKeySignature proves the control of a recipient key of the previous DID of the wallet, with the signature of
+	// the transfer challenge.
+	ProofKeySignature = "key-signature"
+
+	// MaxProofAge is the maximum age of the key signature proofs.
+	MaxProofAge = 5 * time.Minute
+	// leeway tolerates the clock skew between the wallet and the router.
+	leeway = 30 * time.Second
+
+	didKeyPrefix = "did:key:"
+)
+
+// ErrInvalidProof is returned for the proofs failing the verification.
+var ErrInvalidProof = errors.New("invalid proof")
+
+// Proof of control of the keys of the previous DID of the wallet.
+type Proof struct {
+	Type string `json:"type"`
+	// Key is the recipient key of the previous DID signing the challenge, base58 or did:key encoded.
+	Key string `json:"key,omitempty"`
+	// Created is the time the challenge was signed at.
+	Created time.Time `json:"created,omitempty"`
+	// Signature is the base64url encoded Ed25519 signature of the challenge.
+	Signature string `json:"signature,omitempty"`
+	// Token is the recovery token issued for the connection of the previous DID.
+	Token string `json:"token,omitempty"`
+}
+
+// TransferMsg is the transfer request sent by the wallet on the connection of its new device.
+type TransferMsg struct {
+	ID          string `json:"@id,omitempty"`
+	Type        string `json:"@type,omitempty"`
+	PreviousDID string `json:"previousDID,omitempty"`
+	Proof       *Proof `json:"proof,omitempty"`
+}
+
+// TransferAckMsg acknowledges the transfer, with the number of recipient keys and queued messages rebound.
+type TransferAckMsg struct {
+	ID       string `json:"@id,omitempty"`
+	Type     string `json:"@type,omitempty"`
+	Routes   int    `json:"routes"`
+	Messages int    `json:"messages"`
+}
+
+// Challenge returns the challenge signed by the key signature proofs : the previous and new DIDs of the wallet and
+// the creation time of the proof, in UTC and RFC3339 format, separated by dots.
+func Challenge(previousDID, newDID string, created time.Time) []byte {
+	return []byte(strings.Join([]string{previousDID, newDID, created.UTC().Format(time.RFC3339Nano)}, "."))
+}
+
+// VerifyKeySignature verifies the key signature proof of the transfer from the previous DID to the new one : the key
+// must be one of the recipient keys of the previous DID, and the proof created less than MaxProofAge ago.
+func VerifyKeySignature(p *Proof, recipientKeys []string, previousDID, newDID string, now time.Time) error {
+	if p == nil || p.Type != ProofKeySignature {
+		return fmt.Errorf("%w : unsupported proof type", ErrInvalidProof)
+	}
+
+	if !contains(recipientKeys, p.Key) {
+		return fmt.Errorf("%w : not a recipient key of %s", ErrInvalidProof, previousDID)
+	}
+
+	if p.Created.Before(now.Add(-MaxProofAge)) || p.Created.After(now.Add(leeway)) {
+		return fmt.Errorf("%w : proof expired", ErrInvalidProof)
+	}
+
+	pubKey, err := publicKey(p.Key)
+	if err != nil {
+		return fmt.Errorf("%w : %s", ErrInvalidProof, err)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(p.Signature)
+	if err != nil || !ed25519.Verify(pubKey, Challenge(previousDID, newDID, p.Created), sig) {
+		return fmt.Errorf("%w : signature verification failed", ErrInvalidProof)
+	}
+
+	return nil
+}
+
+func publicKey(key string) (ed25519.PublicKey, error) {
+	var (
+		pubKey []byte
+		err    error
+	)
+
+	if strings.HasPrefix(key, didKeyPrefix) {
+		pubKey, err = fingerprint.PubKeyFromDIDKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("parse did:key : %w", err)
+		}
+	} else {
+		pubKey = base58.Decode(key)
+	}
+
+	if len(pubKey) != ed25519.PublicKeySize {
+		return nil, errors.New("not an ed25519 key")
+	}
+
+	return pubKey, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value && value != "" {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package recovery
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/fingerprint"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChallenge(t *testing.T) {
+	created := time.Date(2021, 6, 1, 12, 0, 0, 0, time.FixedZone("EST", -5*60*60))
+
+	require.Equal(t, "did:1.did:2.2021-06-01T17:00:00Z", string(Challenge("did:1", "did:2", created)))
+}
+
+func TestVerifyKeySignature(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	key := base58.Encode(pubKey)
+	didKey, _ := fingerprint.CreateDIDKey(pubKey)
+	now := time.Now()
+
+	sign := func(key string, created time.Time) *Proof {
+		return &Proof{
+			Type: ProofKeySignature, Key: key, Created: created,
+			Signature: base64.RawURLEncoding.EncodeToString(
+				ed25519.Sign(privKey, Challenge("did:previous", "did:new", created))),
+		}
+	}
+
+	t.Run("valid proofs", func(t *testing.T) {
+		require.NoError(t, VerifyKeySignature(sign(key, now), []string{"key-1", key}, "did:previous", "did:new", now))
+		require.NoError(t, VerifyKeySignature(sign(didKey, now.Add(-time.Minute)), []string{didKey},
+			"did:previous", "did:new", now))
+	})
+
+	t.Run("invalid proofs", func(t *testing.T) {
+		invalidSig := sign(key, now)
+		invalidSig.Signature = "%"
+
+		otherTime := sign(key, now.Add(time.Second))
+		otherTime.Created = now
+
+		for _, tc := range []struct {
+			proof *Proof
+			msg   string
+		}{
+			{proof: nil, msg: "unsupported proof type"},
+			{proof: &Proof{Type: "recovery-token"}, msg: "unsupported proof type"},
+			{proof: sign("key-2", now), msg: "not a recipient key of did:previous"},
+			{proof: sign(key, now.Add(-time.Hour)), msg: "proof expired"},
+			{proof: sign(key, now.Add(time.Hour)), msg: "proof expired"},
+			{proof: sign("key-1", now), msg: "not an ed25519 key"},
+			{proof: sign("did:key:z", now), msg: "parse did:key"},
+			{proof: invalidSig, msg: "signature verification failed"},
+			{proof: otherTime, msg: "signature verification failed"},
+		} {
+			err := VerifyKeySignature(tc.proof, []string{"key-1", key, "did:key:z"}, "did:previous", "did:new", now)
+			require.True(t, errors.Is(err, ErrInvalidProof))
+			require.Contains(t, err.Error(), tc.msg)
+		}
+
+		err := VerifyKeySignature(sign(key, now), []string{key}, "did:previous", "did:other", now)
+		require.EqualError(t, err, "invalid proof : signature verification failed")
+	})
+}
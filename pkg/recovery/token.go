@@ -0,0 +1,324 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package recovery
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/jose"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/jwt"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+const (
+	// ProofRecoveryToken proves the transfer with a recovery token issued for the connection of the previous DID.
+	ProofRecoveryToken = "recovery-token"
+
+	// DefaultTokenTTL is the lifetime of the recovery tokens, if not configured.
+	DefaultTokenTTL = 30 * 24 * time.Hour
+
+	storeName      = "recovery"
+	secretKey      = "token-secret"
+	tokenKeyPrefix = "token-"
+	lockPrefix     = "recovery-"
+	secretSize     = 32
+	algHS256       = "HS256"
+	typeJWT        = "JWT"
+)
+
+var (
+	// ErrTokenNotFound is returned if the connection has no recovery token.
+	ErrTokenNotFound = errors.New("recovery token not found")
+
+	errSignature = errors.New("signature mismatch")
+)
+
+// Token is a recovery token issued for a connection.
+type Token struct {
+	Token        string    `json:"token"`
+	ConnectionID string    `json:"connectionID"`
+	IssuedAt     time.Time `json:"issuedAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// claims of the recovery tokens.
+type claims struct {
+	ID        string `json:"jti"`
+	Subject   string `json:"sub"`
+	DID       string `json:"did"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Tokens issues the recovery tokens of the connections : compact JWS signed with HS256. A connection has a single
+// token, issuing a new one revokes the previous one, and a token is consumed by the transfer it proves.
+type Tokens struct {
+	store    storage.Store
+	locker   lock.Locker
+	secret   hs256
+	verifier *jose.CompositeAlgSigVerifier
+	ttl      time.Duration
+	now      func() time.Time
+}
+
+// NewTokens returns new Tokens, issued for the ttl, or DefaultTokenTTL if zero, and signed with the secret. If the
+// secret is not set, a secret is generated and shared by the router instances through the storage : the first instance
+// creates it while its lock is held, and the others read it.
+func NewTokens(p storage.Provider, locker lock.Locker, secret []byte, ttl time.Duration) (*Tokens, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open recovery store : %w", err)
+	}
+
+	t := &Tokens{store: store, locker: locker, secret: secret, ttl: ttl, now: time.Now}
+
+	if len(t.secret) == 0 {
+		t.secret, err = t.sharedSecret()
+		if err != nil {
+			return nil, fmt.Errorf("recovery token secret : %w", err)
+		}
+	}
+
+	if t.ttl <= 0 {
+		t.ttl = DefaultTokenTTL
+	}
+
+	t.verifier = jose.NewCompositeAlgSigVerifier(jose.AlgSignatureVerifier{Alg: algHS256, Verifier: t.secret})
+
+	return t, nil
+}
+
+// sharedSecret returns the secret stored, generated and stored if not found.
+func (t *Tokens) sharedSecret() ([]byte, error) {
+	unlock, err := t.lock(secretKey)
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+
+	secret, err := t.store.Get(secretKey)
+	if !errors.Is(err, storage.ErrDataNotFound) {
+		return secret, err
+	}
+
+	secret = make([]byte, secretSize)
+
+	if _, err = rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	if err = t.store.Put(secretKey, secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// Issue issues a recovery token for the connection with the wallet DID, revoking the previous one if any.
+func (t *Tokens) Issue(connID, walletDID string) (*Token, error) {
+	now := t.now().UTC().Truncate(time.Second)
+
+	var token string
+
+	c := &claims{
+		ID: uuid.New().String(), Subject: connID, DID: walletDID,
+		IssuedAt: now.Unix(), ExpiresAt: now.Add(t.ttl).Unix(),
+	}
+
+	jws, err := jwt.NewSigned(c, jose.Headers{jose.HeaderType: typeJWT}, t.secret)
+	if err == nil {
+		token, err = jws.Serialize(false)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("sign recovery token : %w", err)
+	}
+
+	unlock, err := t.lock(tokenKeyPrefix + connID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+
+	if err = t.store.Put(tokenKeyPrefix+connID, []byte(c.ID)); err != nil {
+		return nil, fmt.Errorf("save recovery token : %w", err)
+	}
+
+	return &Token{
+		Token:        token,
+		ConnectionID: connID,
+		IssuedAt:     now,
+		ExpiresAt:    now.Add(t.ttl),
+	}, nil
+}
+
+// Verify verifies the recovery token presented for the wallet DID, and returns the connection it was issued for.
+func (t *Tokens) Verify(token, walletDID string) (string, error) {
+	c, err := t.verify(token, walletDID)
+	if err != nil {
+		return "", err
+	}
+
+	if err = t.issued(c); err != nil {
+		return "", err
+	}
+
+	return c.Subject, nil
+}
+
+// Consume verifies the recovery token presented for the wallet DID, and revokes it : of the transfers presenting the
+// same token, a single one consumes it. It returns the connection the token was issued for.
+func (t *Tokens) Consume(token, walletDID string) (string, error) {
+	c, err := t.verify(token, walletDID)
+	if err != nil {
+		return "", err
+	}
+
+	unlock, err := t.lock(tokenKeyPrefix + c.Subject)
+	if err != nil {
+		return "", err
+	}
+
+	defer unlock()
+
+	if err = t.issued(c); err != nil {
+		return "", err
+	}
+
+	if err = t.store.Delete(tokenKeyPrefix + c.Subject); err != nil {
+		return "", fmt.Errorf("consume recovery token : %w", err)
+	}
+
+	return c.Subject, nil
+}
+
+// verify verifies the signature, the subject and the expiry of the token, and returns its claims.
+func (t *Tokens) verify(token, walletDID string) (*claims, error) {
+	c, err := t.parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.DID != walletDID {
+		return nil, fmt.Errorf("%w : token not issued for %s", ErrInvalidProof, walletDID)
+	}
+
+	if !t.now().Before(time.Unix(c.ExpiresAt, 0)) {
+		return nil, fmt.Errorf("%w : token expired", ErrInvalidProof)
+	}
+
+	return c, nil
+}
+
+// issued returns an error if the token isn't the token issued last for its connection, the only one valid.
+func (t *Tokens) issued(c *claims) error {
+	id, err := t.store.Get(tokenKeyPrefix + c.Subject)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return fmt.Errorf("%w : token revoked", ErrInvalidProof)
+	}
+
+	if err != nil {
+		return fmt.Errorf("get recovery token : %w", err)
+	}
+
+	if string(id) != c.ID {
+		return fmt.Errorf("%w : token revoked", ErrInvalidProof)
+	}
+
+	return nil
+}
+
+// parse verifies the signature of the token, and returns its claims.
+func (t *Tokens) parse(token string) (*claims, error) {
+	jws, err := jwt.Parse(token, jwt.WithSignatureVerifier(t.verifier))
+	if errors.Is(err, errSignature) {
+		return nil, fmt.Errorf("%w : token signature verification failed", ErrInvalidProof)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w : malformed token", ErrInvalidProof)
+	}
+
+	c := &claims{}
+
+	if err = jws.DecodeClaims(c); err != nil {
+		return nil, fmt.Errorf("%w : malformed token claims", ErrInvalidProof)
+	}
+
+	return c, nil
+}
+
+// Revoke revokes the recovery token of the connection.
+func (t *Tokens) Revoke(connID string) error {
+	unlock, err := t.lock(tokenKeyPrefix + connID)
+	if err != nil {
+		return err
+	}
+
+	defer unlock()
+
+	_, err = t.store.Get(tokenKeyPrefix + connID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return ErrTokenNotFound
+	}
+
+	if err == nil {
+		err = t.store.Delete(tokenKeyPrefix + connID)
+	}
+
+	if err != nil {
+		return fmt.Errorf("revoke recovery token : %w", err)
+	}
+
+	return nil
+}
+
+// lock locks the record of the key, it returns the function unlocking it.
+func (t *Tokens) lock(key string) (func(), error) {
+	unlock, err := t.locker.Lock(lockPrefix + key)
+	if err != nil {
+		return nil, fmt.Errorf("lock recovery token : %w", err)
+	}
+
+	return unlock, nil
+}
+
+// hs256 signs and verifies the tokens with HMAC SHA-256 keyed with the secret.
+type hs256 []byte
+
+// Sign signs the data.
+func (s hs256) Sign(data []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, s)
+	mac.Write(data) // nolint:errcheck,gosec // hash writes never fail
+
+	return mac.Sum(nil), nil
+}
+
+// Headers returns the algorithm header of the tokens.
+func (s hs256) Headers() jose.Headers {
+	return jose.Headers{jose.HeaderAlgorithm: algHS256}
+}
+
+// Verify verifies the signature of the signing input.
+func (s hs256) Verify(_ jose.Headers, _, signingInput, sig []byte) error {
+	expected, err := s.Sign(signingInput)
+	if err != nil || !hmac.Equal(sig, expected) {
+		return errSignature
+	}
+
+	return nil
+}
@@ -0,0 +1,307 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package recovery
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/jwt"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+func TestNewTokens(t *testing.T) {
+	t.Run("secret shared through the storage", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		tokens, err := NewTokens(p, lock.NewLocal(), nil, 0)
+		require.NoError(t, err)
+		require.Equal(t, DefaultTokenTTL, tokens.ttl)
+		require.Len(t, tokens.secret, secretSize)
+
+		other, err := NewTokens(p, lock.NewLocal(), nil, time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, tokens.secret, other.secret)
+
+		token, err := tokens.Issue("conn-1", "did:1")
+		require.NoError(t, err)
+
+		connID, err := other.Verify(token.Token, "did:1")
+		require.NoError(t, err)
+		require.Equal(t, "conn-1", connID)
+	})
+
+	t.Run("secret created once", func(t *testing.T) {
+		p := mem.NewProvider()
+		locker := lock.NewLocal()
+
+		var wg sync.WaitGroup
+
+		tokens := make([]*Tokens, 10)
+
+		for i := range tokens {
+			wg.Add(1)
+
+			go func(i int) {
+				defer wg.Done()
+
+				var err error
+
+				tokens[i], err = NewTokens(p, locker, nil, 0)
+				require.NoError(t, err)
+			}(i)
+		}
+
+		wg.Wait()
+
+		for _, other := range tokens[1:] {
+			require.Equal(t, tokens[0].secret, other.secret)
+		}
+	})
+
+	t.Run("configured secret", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		tokens, err := NewTokens(p, lock.NewLocal(), []byte("secret"), 0)
+		require.NoError(t, err)
+		require.Equal(t, hs256("secret"), tokens.secret)
+
+		store, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+
+		_, err = store.Get(secretKey)
+		require.Error(t, err)
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		_, err := NewTokens(&mockstore.MockStoreProvider{FailNamespace: storeName}, lock.NewLocal(), nil, 0)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open recovery store")
+
+		p := mockstore.NewMockStoreProvider()
+		p.Store.ErrPut = errors.New("put error")
+
+		_, err = NewTokens(p, lock.NewLocal(), nil, 0)
+		require.EqualError(t, err, "recovery token secret : put error")
+
+		p.Store.ErrGet = errors.New("get error")
+
+		_, err = NewTokens(p, lock.NewLocal(), nil, 0)
+		require.EqualError(t, err, "recovery token secret : get error")
+
+		_, err = NewTokens(mem.NewProvider(), &failingLocker{}, nil, 0)
+		require.EqualError(t, err, "recovery token secret : lock recovery token : lock timeout")
+	})
+}
+
+func TestTokens(t *testing.T) {
+	newTokens := func(t *testing.T) *Tokens {
+		t.Helper()
+
+		tokens, err := NewTokens(mem.NewProvider(), lock.NewLocal(), nil, time.Hour)
+		require.NoError(t, err)
+
+		return tokens
+	}
+
+	t.Run("token issued for the connection", func(t *testing.T) {
+		tokens := newTokens(t)
+
+		token, err := tokens.Issue("conn-1", "did:1")
+		require.NoError(t, err)
+		require.Equal(t, "conn-1", token.ConnectionID)
+		require.Equal(t, time.Hour, token.ExpiresAt.Sub(token.IssuedAt))
+		require.True(t, jwt.IsJWS(token.Token))
+
+		connID, err := tokens.Verify(token.Token, "did:1")
+		require.NoError(t, err)
+		require.Equal(t, "conn-1", connID)
+
+		require.NoError(t, tokens.Revoke("conn-1"))
+		require.True(t, errors.Is(tokens.Revoke("conn-1"), ErrTokenNotFound))
+
+		_, err = tokens.Verify(token.Token, "did:1")
+		require.EqualError(t, err, "invalid proof : token revoked")
+	})
+
+	t.Run("token consumed once", func(t *testing.T) {
+		tokens := newTokens(t)
+
+		token, err := tokens.Issue("conn-1", "did:1")
+		require.NoError(t, err)
+
+		_, err = tokens.Consume(token.Token, "did:2")
+		require.EqualError(t, err, "invalid proof : token not issued for did:2")
+
+		var (
+			wg       sync.WaitGroup
+			mutex    sync.Mutex
+			consumed int
+		)
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				connID, err := tokens.Consume(token.Token, "did:1")
+				if err != nil {
+					require.EqualError(t, err, "invalid proof : token revoked")
+
+					return
+				}
+
+				require.Equal(t, "conn-1", connID)
+
+				mutex.Lock()
+				consumed++
+				mutex.Unlock()
+			}()
+		}
+
+		wg.Wait()
+
+		require.Equal(t, 1, consumed)
+		require.True(t, errors.Is(tokens.Revoke("conn-1"), ErrTokenNotFound))
+	})
+
+	t.Run("new token revokes the previous one", func(t *testing.T) {
+		tokens := newTokens(t)
+
+		previous, err := tokens.Issue("conn-1", "did:1")
+		require.NoError(t, err)
+
+		token, err := tokens.Issue("conn-1", "did:1")
+		require.NoError(t, err)
+
+		_, err = tokens.Verify(previous.Token, "did:1")
+		require.EqualError(t, err, "invalid proof : token revoked")
+
+		_, err = tokens.Verify(token.Token, "did:1")
+		require.NoError(t, err)
+	})
+
+	t.Run("invalid tokens", func(t *testing.T) {
+		tokens := newTokens(t)
+
+		token, err := tokens.Issue("conn-1", "did:1")
+		require.NoError(t, err)
+
+		parts := strings.Split(token.Token, ".")
+
+		other := newTokens(t)
+
+		forged, err := other.Issue("conn-1", "did:1")
+		require.NoError(t, err)
+
+		unsigned := parts[0] + ".e30"
+		badClaims := parts[0] + ".eyJleHAiOiJ4In0" // {"exp":"x"}
+		badClaims += "." + sign(t, tokens, badClaims)
+
+		for token, msg := range map[string]string{
+			"":              "malformed token",
+			"a.b":           "malformed token",
+			forged.Token:    "token signature verification failed",
+			unsigned + ".%": "malformed token",
+			badClaims:       "malformed token claims",
+			unsigned + "." + sign(t, tokens, unsigned): "token not issued for did:1",
+		} {
+			_, err = tokens.Verify(token, "did:1")
+			require.True(t, errors.Is(err, ErrInvalidProof))
+			require.Contains(t, err.Error(), msg)
+		}
+
+		_, err = tokens.Verify(token.Token, "did:2")
+		require.EqualError(t, err, "invalid proof : token not issued for did:2")
+
+		tokens.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+		_, err = tokens.Verify(token.Token, "did:1")
+		require.EqualError(t, err, "invalid proof : token expired")
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		p := mockstore.NewMockStoreProvider()
+
+		tokens, err := NewTokens(p, lock.NewLocal(), nil, time.Hour)
+		require.NoError(t, err)
+
+		token, err := tokens.Issue("conn-1", "did:1")
+		require.NoError(t, err)
+
+		p.Store.ErrPut = errors.New("put error")
+		p.Store.ErrGet = errors.New("get error")
+		p.Store.ErrDelete = errors.New("delete error")
+
+		_, err = tokens.Issue("conn-1", "did:1")
+		require.EqualError(t, err, "save recovery token : put error")
+
+		_, err = tokens.Verify(token.Token, "did:1")
+		require.EqualError(t, err, "get recovery token : get error")
+
+		_, err = tokens.Consume(token.Token, "did:1")
+		require.EqualError(t, err, "get recovery token : get error")
+
+		err = tokens.Revoke("conn-1")
+		require.EqualError(t, err, "revoke recovery token : get error")
+
+		p.Store.ErrGet = nil
+
+		err = tokens.Revoke("conn-1")
+		require.EqualError(t, err, "revoke recovery token : delete error")
+
+		p.Store.ErrPut = nil
+
+		token, err = tokens.Issue("conn-1", "did:1")
+		require.NoError(t, err)
+
+		_, err = tokens.Consume(token.Token, "did:1")
+		require.EqualError(t, err, "consume recovery token : delete error")
+	})
+
+	t.Run("lock errors", func(t *testing.T) {
+		tokens := newTokens(t)
+
+		token, err := tokens.Issue("conn-1", "did:1")
+		require.NoError(t, err)
+
+		tokens.locker = &failingLocker{}
+
+		_, err = tokens.Issue("conn-1", "did:1")
+		require.EqualError(t, err, "lock recovery token : lock timeout")
+
+		_, err = tokens.Consume(token.Token, "did:1")
+		require.EqualError(t, err, "lock recovery token : lock timeout")
+
+		require.EqualError(t, tokens.Revoke("conn-1"), "lock recovery token : lock timeout")
+	})
+}
+
+type failingLocker struct{}
+
+func (l *failingLocker) Lock(string) (func(), error) {
+	return nil, errors.New("lock timeout")
+}
+
+// sign returns the encoded signature of the signing input with the secret of the tokens.
+func sign(t *testing.T, tokens *Tokens, signingInput string) string {
+	t.Helper()
+
+	sig, err := tokens.secret.Sign([]byte(signingInput))
+	require.NoError(t, err)
+
+	return base64.RawURLEncoding.EncodeToString(sig)
+}
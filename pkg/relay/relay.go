@@ -0,0 +1,100 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/dispatcher"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	vdrapi "github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/preparse"
+	"github.com/trustbloc/hub-router/pkg/routes"
+)
+
+var logger = log.New("hub-router/relay")
+
+// nolint:gochecknoglobals // immutable prefix
+var didPrefix = []byte("did:")
+
+// Relay forwards the nested forward messages addressed to another mediator, so that the router can be one hop of a
+// chain of mediators : the router unwraps its layer, and sends the inner envelope (packed for the next mediator) to
+// the endpoint of the next hop DID.
+type Relay struct {
+	routes   *routes.Store
+	vdr      vdrapi.Registry
+	outbound dispatcher.Outbound
+}
+
+// New returns a new Relay. p is the storage provider of the Aries agent, where the mediator registers the routes.
+func New(p storage.Provider, vdr vdrapi.Registry, outbound dispatcher.Outbound) (*Relay, error) {
+	r, err := routes.Open(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Relay{routes: r, vdr: vdr, outbound: outbound}, nil
+}
+
+// HandleEnvelope relays the envelope if it is a forward message addressed to another mediator, or hands it on to next.
+func (r *Relay) HandleEnvelope(envelope *transport.Envelope, header *preparse.Header,
+	next transport.InboundMessageHandler) error {
+	relayed, err := r.RelayEnvelope(envelope, header)
+	if relayed || err != nil {
+		return err
+	}
+
+	return next(envelope)
+}
+
+// RelayEnvelope forwards the envelope with the header to the next hop if it is a forward message addressed to a DID
+// the router has no route for. It returns false if the envelope is to be handled by the Aries mediator : any other
+// message, or a forward to a wallet mediated by the router.
+func (r *Relay) RelayEnvelope(envelope *transport.Envelope, header *preparse.Header) (bool, error) {
+	if !header.IsForward() || !bytes.HasPrefix(header.To, didPrefix) {
+		return false, nil
+	}
+
+	_, err := r.routes.DID(string(header.To))
+	if err == nil {
+		return false, nil
+	}
+
+	if !errors.Is(err, storage.ErrDataNotFound) {
+		return false, fmt.Errorf("get route : %w", err)
+	}
+
+	// only the relayed forwards are unmarshalled
+	forward := &model.Forward{}
+
+	err = json.Unmarshal(envelope.Message, forward)
+	if err != nil || forward.To != string(header.To) {
+		return false, nil // nolint:nilerr // handled by the Aries mediator
+	}
+
+	dest, err := service.GetDestination(forward.To, r.vdr)
+	if err != nil {
+		return true, fmt.Errorf("resolve next hop %s : %w", forward.To, err)
+	}
+
+	err = r.outbound.Forward(forward.Msg, dest)
+	if err != nil {
+		return true, fmt.Errorf("forward to next hop %s : %w", forward.To, err)
+	}
+
+	logger.Debugf("forward relayed : id=%s nextHop=%s endpoint=%s", forward.ID, forward.To, dest.ServiceEndpoint)
+
+	return true, nil
+}
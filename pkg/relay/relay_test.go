@@ -0,0 +1,193 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockdispatcher "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/dispatcher"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	mockvdr "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+	"github.com/trustbloc/hub-router/pkg/preparse"
+	"github.com/trustbloc/hub-router/pkg/routes"
+)
+
+const nextHop = "did:example:mediator"
+
+func TestNew(t *testing.T) {
+	p := mockstorage.NewMockProvider()
+	p.OpenStoreErr = errors.New("open error")
+
+	_, err := New(p, nil, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "open route store")
+}
+
+func TestRelayEnvelope(t *testing.T) {
+	t.Run("nested forward is relayed to the next hop", func(t *testing.T) {
+		var forwarded *service.Destination
+
+		r, err := New(mem.NewProvider(), &mockvdr.MockVDRegistry{ResolveValue: mockdiddoc.GetMockDIDDoc(t)},
+			&mockdispatcher.MockOutbound{ValidateForward: func(msg interface{}, des *service.Destination) error {
+				env, ok := msg.(*model.Envelope)
+				require.True(t, ok)
+				require.Equal(t, "inner", env.CipherText)
+
+				forwarded = des
+
+				return nil
+			}})
+		require.NoError(t, err)
+
+		relayed, err := relayEnvelope(t, r, forwardEnvelope(t, nextHop))
+		require.NoError(t, err)
+		require.True(t, relayed)
+		require.Equal(t, "https://localhost:8090", forwarded.ServiceEndpoint)
+	})
+
+	t.Run("forward to a mediated wallet is not relayed", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		mediated, err := routes.Open(p)
+		require.NoError(t, err)
+		require.NoError(t, mediated.Route(nextHop, "did:example:wallet"))
+
+		r, err := New(p, &mockvdr.MockVDRegistry{}, &mockdispatcher.MockOutbound{})
+		require.NoError(t, err)
+
+		relayed, err := relayEnvelope(t, r, forwardEnvelope(t, nextHop))
+		require.NoError(t, err)
+		require.False(t, relayed)
+	})
+
+	t.Run("other messages are not relayed", func(t *testing.T) {
+		r, err := New(mem.NewProvider(), &mockvdr.MockVDRegistry{}, &mockdispatcher.MockOutbound{})
+		require.NoError(t, err)
+
+		relayed, err := relayEnvelope(t, r, &transport.Envelope{Message: []byte(`{"@type":"other"}`)})
+		require.NoError(t, err)
+		require.False(t, relayed)
+
+		relayed, err = r.RelayEnvelope(&transport.Envelope{Message: []byte("invalid")}, &preparse.Header{})
+		require.NoError(t, err)
+		require.False(t, relayed)
+
+		relayed, err = relayEnvelope(t, r, forwardEnvelope(t, "6Gs8Ls9nH3uRz1BXc2mVq4yT7kWf5jPe8aDx0LoN2Ui"))
+		require.NoError(t, err)
+		require.False(t, relayed)
+
+		// the nested envelope is only validated once the forward is to be relayed
+		relayed, err = relayEnvelope(t, r, &transport.Envelope{Message: []byte(
+			`{"@type":"https://didcomm.org/routing/1.0/forward","to":"did:example:next","msg":{"protected":tru}}`,
+		)})
+		require.NoError(t, err)
+		require.False(t, relayed)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		r, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), &mockvdr.MockVDRegistry{}, &mockdispatcher.MockOutbound{})
+		require.NoError(t, err)
+
+		_, err = relayEnvelope(t, r, forwardEnvelope(t, nextHop))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get route")
+
+		r, err = New(mem.NewProvider(), &mockvdr.MockVDRegistry{ResolveErr: errors.New("resolve error")},
+			&mockdispatcher.MockOutbound{})
+		require.NoError(t, err)
+
+		relayed, err := relayEnvelope(t, r, forwardEnvelope(t, nextHop))
+		require.True(t, relayed)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "resolve next hop")
+
+		r, err = New(mem.NewProvider(), &mockvdr.MockVDRegistry{ResolveValue: mockdiddoc.GetMockDIDDoc(t)},
+			&mockdispatcher.MockOutbound{ValidateForward: func(interface{}, *service.Destination) error {
+				return errors.New("forward error")
+			}})
+		require.NoError(t, err)
+
+		_, err = relayEnvelope(t, r, forwardEnvelope(t, nextHop))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "forward to next hop")
+	})
+}
+
+func TestHandleEnvelope(t *testing.T) {
+	var handled []*transport.Envelope
+
+	next := func(envelope *transport.Envelope) error {
+		handled = append(handled, envelope)
+
+		return nil
+	}
+
+	r, err := New(mem.NewProvider(), &mockvdr.MockVDRegistry{ResolveValue: mockdiddoc.GetMockDIDDoc(t)},
+		&mockdispatcher.MockOutbound{})
+	require.NoError(t, err)
+
+	require.NoError(t, handleEnvelope(t, r, &transport.Envelope{Message: []byte(`{"@type":"other"}`)}, next))
+	require.Len(t, handled, 1)
+
+	// the relayed forwards aren't handed on
+	require.NoError(t, handleEnvelope(t, r, forwardEnvelope(t, nextHop), next))
+	require.Len(t, handled, 1)
+
+	r.vdr = &mockvdr.MockVDRegistry{ResolveErr: errors.New("resolve error")}
+
+	err = handleEnvelope(t, r, forwardEnvelope(t, nextHop), next)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "resolve next hop")
+	require.Len(t, handled, 1)
+}
+
+// relayEnvelope relays the envelope with the header of its message, as the inbound chains do.
+func relayEnvelope(t *testing.T, r *Relay, envelope *transport.Envelope) (bool, error) {
+	t.Helper()
+
+	return r.RelayEnvelope(envelope, parseHeader(t, envelope))
+}
+
+// handleEnvelope handles the envelope with the header of its message, as the inbound chains do.
+func handleEnvelope(t *testing.T, r *Relay, envelope *transport.Envelope, next transport.InboundMessageHandler) error {
+	t.Helper()
+
+	return r.HandleEnvelope(envelope, parseHeader(t, envelope), next)
+}
+
+func parseHeader(t *testing.T, envelope *transport.Envelope) *preparse.Header {
+	t.Helper()
+
+	header, err := preparse.Parse(envelope.Message)
+	require.NoError(t, err)
+
+	return header
+}
+
+func forwardEnvelope(t *testing.T, to string) *transport.Envelope {
+	t.Helper()
+
+	msg, err := json.Marshal(&model.Forward{
+		Type: service.ForwardMsgType, ID: "id", To: to, Msg: &model.Envelope{CipherText: "inner"},
+	})
+	require.NoError(t, err)
+
+	return &transport.Envelope{Message: msg}
+}
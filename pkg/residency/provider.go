@@ -0,0 +1,280 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package residency
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// keySeparator ends the wallet DID in the keys of the routed stores, eg: the chunks of a mailbox.
+const keySeparator = "#"
+
+// Provider is a storage provider routing the records of the given stores (eg: the pickup mailboxes, keyed by the DID
+// of the wallet) to the storage of the region of the wallet. The records of the wallets not pinned to a region, and
+// the other stores, are stored in the default provider. The records stored before the wallet was pinned are still
+// read from the default provider, and moved to the region when updated.
+type Provider struct {
+	storage.Provider
+	router  *Router
+	stores  map[string]bool
+	mutex   sync.Mutex
+	configs map[string]storage.StoreConfiguration
+}
+
+// NewProvider returns a new Provider routing the records of the given stores.
+func (r *Router) NewProvider(p storage.Provider, stores ...string) *Provider {
+	rp := &Provider{
+		Provider: p, router: r, stores: make(map[string]bool, len(stores)),
+		configs: make(map[string]storage.StoreConfiguration),
+	}
+
+	for _, name := range stores {
+		rp.stores[name] = true
+	}
+
+	return rp
+}
+
+// OpenStore opens the store, routed to the regions if it is one of the routed stores.
+func (p *Provider) OpenStore(name string) (storage.Store, error) {
+	s, err := p.Provider.OpenStore(name)
+	if err != nil || !p.stores[name] {
+		return s, err
+	}
+
+	return &store{Store: s, name: name, provider: p}, nil
+}
+
+// SetStoreConfig sets the store config in the default provider, and in the regions once their store is opened.
+func (p *Provider) SetStoreConfig(name string, config storage.StoreConfiguration) error {
+	p.mutex.Lock()
+	p.configs[name] = config
+	p.mutex.Unlock()
+
+	return p.Provider.SetStoreConfig(name, config)
+}
+
+func (p *Provider) regionStore(region, name string) (storage.Store, error) {
+	rp := p.router.config.Regions[region]
+
+	s, err := rp.OpenStore(name)
+	if err != nil {
+		return nil, fmt.Errorf("open store %s in region %s : %w", name, region, err)
+	}
+
+	p.mutex.Lock()
+	config, ok := p.configs[name]
+	p.mutex.Unlock()
+
+	if ok {
+		if err = rp.SetStoreConfig(name, config); err != nil {
+			return nil, fmt.Errorf("set store %s config in region %s : %w", name, region, err)
+		}
+	}
+
+	return s, nil
+}
+
+// store routes the records to the store of the region of their wallet.
+type store struct {
+	storage.Store
+	name     string
+	provider *Provider
+}
+
+// region returns the store of the region of the wallet of the key, nil if the wallet isn't pinned.
+func (s *store) region(key string) (storage.Store, error) {
+	region := s.provider.router.Region(strings.SplitN(key, keySeparator, 2)[0]) // nolint:gomnd // did#suffix
+	if region == "" {
+		return nil, nil
+	}
+
+	return s.provider.regionStore(region, s.name)
+}
+
+func (s *store) Put(key string, value []byte, tags ...storage.Tag) error {
+	return s.Batch([]storage.Operation{{Key: key, Value: value, Tags: tags}})
+}
+
+func (s *store) Get(key string) ([]byte, error) {
+	rs, err := s.region(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs != nil {
+		value, err := rs.Get(key)
+		if !errors.Is(err, storage.ErrDataNotFound) {
+			return value, err
+		}
+	}
+
+	return s.Store.Get(key)
+}
+
+func (s *store) GetTags(key string) ([]storage.Tag, error) {
+	rs, err := s.region(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs != nil {
+		tags, err := rs.GetTags(key)
+		if !errors.Is(err, storage.ErrDataNotFound) {
+			return tags, err
+		}
+	}
+
+	return s.Store.GetTags(key)
+}
+
+func (s *store) GetBulk(keys ...string) ([][]byte, error) {
+	values := make([][]byte, len(keys))
+
+	for i, key := range keys {
+		value, err := s.Get(key)
+		if err != nil && !errors.Is(err, storage.ErrDataNotFound) {
+			return nil, err
+		}
+
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// Query queries the default store, then the stores of the regions.
+func (s *store) Query(expression string, options ...storage.QueryOption) (storage.Iterator, error) {
+	iters := []storage.Iterator{}
+
+	for _, region := range append([]string{""}, s.provider.router.Regions()...) {
+		st := s.Store
+
+		if region != "" {
+			var err error
+
+			st, err = s.provider.regionStore(region, s.name)
+			if err != nil {
+				closeAll(iters)
+
+				return nil, err
+			}
+		}
+
+		iter, err := st.Query(expression, options...)
+		if err != nil {
+			closeAll(iters)
+
+			return nil, err
+		}
+
+		iters = append(iters, iter)
+	}
+
+	return &multiIterator{iters: iters}, nil
+}
+
+func (s *store) Delete(key string) error {
+	return s.Batch([]storage.Operation{{Key: key}})
+}
+
+// Batch runs the operations of the keys of each region in a batch of the store of the region, the records of the
+// pinned wallets are deleted from the default store.
+func (s *store) Batch(operations []storage.Operation) error {
+	var local []storage.Operation
+
+	regional := make(map[storage.Store][]storage.Operation)
+
+	for _, op := range operations {
+		rs, err := s.region(op.Key)
+		if err != nil {
+			return err
+		}
+
+		if rs == nil {
+			local = append(local, op)
+
+			continue
+		}
+
+		regional[rs] = append(regional[rs], op)
+		local = append(local, storage.Operation{Key: op.Key})
+	}
+
+	for rs, ops := range regional {
+		if err := rs.Batch(ops); err != nil {
+			return err
+		}
+	}
+
+	if len(local) == 0 {
+		return nil
+	}
+
+	return s.Store.Batch(local)
+}
+
+// multiIterator iterates over the iterators in turn.
+type multiIterator struct {
+	iters []storage.Iterator
+}
+
+func (m *multiIterator) Next() (bool, error) {
+	for len(m.iters) > 0 {
+		ok, err := m.iters[0].Next()
+		if err != nil || ok {
+			return ok, err
+		}
+
+		storage.Close(m.iters[0], logger)
+		m.iters = m.iters[1:]
+	}
+
+	return false, nil
+}
+
+func (m *multiIterator) Key() (string, error) {
+	if len(m.iters) == 0 {
+		return "", errors.New("iterator exhausted")
+	}
+
+	return m.iters[0].Key()
+}
+
+func (m *multiIterator) Value() ([]byte, error) {
+	if len(m.iters) == 0 {
+		return nil, errors.New("iterator exhausted")
+	}
+
+	return m.iters[0].Value()
+}
+
+func (m *multiIterator) Tags() ([]storage.Tag, error) {
+	if len(m.iters) == 0 {
+		return nil, errors.New("iterator exhausted")
+	}
+
+	return m.iters[0].Tags()
+}
+
+func (m *multiIterator) Close() error {
+	closeAll(m.iters)
+	m.iters = nil
+
+	return nil
+}
+
+func closeAll(iters []storage.Iterator) {
+	for _, it := range iters {
+		storage.Close(it, logger)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package residency
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+func TestProvider(t *testing.T) {
+	const (
+		wallet1 = "did:peer:wallet1"
+		wallet2 = "did:peer:wallet2"
+	)
+
+	config := newConfig()
+	shared := mem.NewProvider()
+
+	r, err := New(shared, lock.NewLocal(), config)
+	require.NoError(t, err)
+	require.NoError(t, r.Pin("eu", wallet1))
+
+	p := r.NewProvider(shared, "mailbox")
+
+	other, err := p.OpenStore("other")
+	require.NoError(t, err)
+	require.NoError(t, other.Put(wallet1, []byte("other")))
+
+	store, err := p.OpenStore("mailbox")
+	require.NoError(t, err)
+	require.NoError(t, p.SetStoreConfig("mailbox", storage.StoreConfiguration{TagNames: []string{"queued"}}))
+
+	require.NoError(t, store.Put(wallet1, []byte("eu"), storage.Tag{Name: "queued"}))
+	require.NoError(t, store.Put(wallet1+"#chunk-1", []byte("eu chunk"), storage.Tag{Name: "queued"}))
+	require.NoError(t, store.Put(wallet2, []byte("default"), storage.Tag{Name: "queued"}))
+
+	euStore, err := config.Regions["eu"].OpenStore("mailbox")
+	require.NoError(t, err)
+
+	value, err := euStore.Get(wallet1 + "#chunk-1")
+	require.NoError(t, err)
+	require.Equal(t, "eu chunk", string(value))
+
+	euConfig, err := config.Regions["eu"].GetStoreConfig("mailbox")
+	require.NoError(t, err)
+	require.Equal(t, []string{"queued"}, euConfig.TagNames)
+
+	sharedStore, err := shared.OpenStore("mailbox")
+	require.NoError(t, err)
+
+	_, err = sharedStore.Get(wallet1)
+	require.True(t, errors.Is(err, storage.ErrDataNotFound))
+
+	value, err = store.Get(wallet1)
+	require.NoError(t, err)
+	require.Equal(t, "eu", string(value))
+
+	tags, err := store.GetTags(wallet1)
+	require.NoError(t, err)
+	require.Equal(t, []storage.Tag{{Name: "queued"}}, tags)
+
+	values, err := store.GetBulk(wallet1, wallet2, "did:peer:unknown")
+	require.NoError(t, err)
+	require.Equal(t, [][]byte{[]byte("eu"), []byte("default"), nil}, values)
+
+	require.Equal(t, []string{wallet1, wallet1 + "#chunk-1", wallet2}, queryKeys(t, store, "queued"))
+
+	t.Run("wallet pinned after its messages were queued", func(t *testing.T) {
+		require.NoError(t, r.Pin("us", wallet2))
+
+		value, err := store.Get(wallet2)
+		require.NoError(t, err)
+		require.Equal(t, "default", string(value))
+
+		tags, err := store.GetTags(wallet2)
+		require.NoError(t, err)
+		require.Len(t, tags, 1)
+
+		require.NoError(t, store.Put(wallet2, []byte("us")))
+
+		_, err = sharedStore.Get(wallet2)
+		require.True(t, errors.Is(err, storage.ErrDataNotFound))
+
+		value, err = store.Get(wallet2)
+		require.NoError(t, err)
+		require.Equal(t, "us", string(value))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, store.Delete(wallet1))
+		require.NoError(t, store.Delete(wallet1+"#chunk-1"))
+
+		_, err := store.Get(wallet1)
+		require.True(t, errors.Is(err, storage.ErrDataNotFound))
+	})
+}
+
+func queryKeys(t *testing.T, store storage.Store, expression string) []string {
+	t.Helper()
+
+	iter, err := store.Query(expression)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, iter.Close())
+	}()
+
+	var keys []string
+
+	for {
+		ok, err := iter.Next()
+		require.NoError(t, err)
+
+		if !ok {
+			break
+		}
+
+		key, err := iter.Key()
+		require.NoError(t, err)
+
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
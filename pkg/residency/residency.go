@@ -0,0 +1,303 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package residency pins the tenants and wallets to a storage region, and routes the messages queued for the wallets
+// to the datasource of their region, for the data localization requirements.
+package residency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+const (
+	storeName  = "residency"
+	pinTag     = "pin"
+	didPrefix  = "did:"
+	lockPrefix = "residency-"
+
+	// AnyRevision applies the changes whatever the revision of the pin.
+	AnyRevision = -1
+)
+
+var (
+	// ErrUnknownRegion is returned when pinning to a region that isn't configured.
+	ErrUnknownRegion = errors.New("unknown region")
+	// ErrRevisionConflict is returned for the changes expecting another revision of the pin.
+	ErrRevisionConflict = errors.New("pin revision conflict")
+)
+
+var logger = log.New("hub-router/residency")
+
+// Config of the regions.
+type Config struct {
+	// Regions are the storage providers of the regions, keyed by region.
+	Regions map[string]storage.Provider
+	// Tenants are the regions the tenants are pinned to, keyed by tenant ID : the wallets of a tenant are pinned to
+	// its region when they connect.
+	Tenants map[string]string
+}
+
+// Enabled returns true if regions are configured.
+func (c *Config) Enabled() bool {
+	return c != nil && len(c.Regions) > 0
+}
+
+// Pin of a wallet to a region.
+type Pin struct {
+	Region string `json:"region"`
+	// Revision is incremented on each change of the pin, from 1 when pinned.
+	Revision int `json:"revision"`
+}
+
+// Router resolves the region of the wallets, from their DID. The wallets not pinned to a region are stored in the
+// default storage.
+type Router struct {
+	config Config
+	store  storage.Store
+	locker lock.Locker
+	mutex  sync.RWMutex
+	pins   map[string]*Pin
+}
+
+// New returns a new Router, loading the pins of the wallets persisted in the given storage provider. The changes of
+// the pins are serialized with the locker, so that their revisions are checked against the pins stored.
+func New(p storage.Provider, locker lock.Locker, config *Config) (*Router, error) {
+	for tenantID, region := range config.Tenants {
+		if _, ok := config.Regions[region]; !ok {
+			return nil, fmt.Errorf("tenant %s : %w %s", tenantID, ErrUnknownRegion, region)
+		}
+	}
+
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open residency store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{pinTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set residency store config : %w", err)
+	}
+
+	r := &Router{config: *config, store: store, locker: locker, pins: make(map[string]*Pin)}
+
+	iter, err := store.Query(pinTag)
+	if err != nil {
+		return nil, fmt.Errorf("query residency pins : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate residency pins : %w", err)
+		}
+
+		if !ok {
+			return r, nil
+		}
+
+		did, err := iter.Key()
+		if err != nil {
+			return nil, fmt.Errorf("read residency pin : %w", err)
+		}
+
+		pinBytes, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read residency pin : %w", err)
+		}
+
+		r.pins[did] = decodePin(pinBytes)
+	}
+}
+
+// Regions returns the configured regions, sorted.
+func (r *Router) Regions() []string {
+	regions := make([]string, 0, len(r.config.Regions))
+
+	for region := range r.config.Regions {
+		regions = append(regions, region)
+	}
+
+	sort.Strings(regions)
+
+	return regions
+}
+
+// TenantRegion returns the region the tenant is pinned to, empty if not pinned.
+func (r *Router) TenantRegion(tenantID string) string {
+	return r.config.Tenants[tenantID]
+}
+
+// Region returns the region the wallet DID is pinned to, empty if not pinned.
+func (r *Router) Region(did string) string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if pin, ok := r.pins[did]; ok {
+		return pin.Region
+	}
+
+	return ""
+}
+
+// PinOf returns the pin of the wallet DID, nil if not pinned.
+func (r *Router) PinOf(did string) *Pin {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	if pin, ok := r.pins[did]; ok {
+		copied := *pin
+
+		return &copied
+	}
+
+	return nil
+}
+
+// Lookup returns the pin of the wallet DID stored, nil if not pinned.
+func (r *Router) Lookup(did string) (*Pin, error) {
+	pinBytes, err := r.store.Get(did)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get residency pin : %w", err)
+	}
+
+	return decodePin(pinBytes), nil
+}
+
+// decodePin returns the pin stored, the pins stored before the revisions are their region.
+func decodePin(pinBytes []byte) *Pin {
+	pin := &Pin{}
+
+	if err := json.Unmarshal(pinBytes, pin); err != nil {
+		return &Pin{Region: string(pinBytes), Revision: 1}
+	}
+
+	return pin
+}
+
+// Assign pins the DIDs of the wallets of the tenant to the region of the tenant, unless already pinned. The IDs that
+// aren't DIDs (eg: connection IDs) are ignored.
+func (r *Router) Assign(tenantID string, ids ...string) error {
+	region := r.TenantRegion(tenantID)
+	if region == "" {
+		return nil
+	}
+
+	var dids []string
+
+	for _, id := range ids {
+		if strings.HasPrefix(id, didPrefix) && r.Region(id) == "" {
+			dids = append(dids, id)
+		}
+	}
+
+	return r.Pin(region, dids...)
+}
+
+// Pin pins the wallet DIDs to the region, the messages queued for the wallets are moved to the region as they are
+// updated. An empty region unpins the wallets.
+func (r *Router) Pin(region string, dids ...string) error {
+	for _, did := range dids {
+		if _, err := r.PinIf(region, did, AnyRevision); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PinIf pins the wallet DID to the region, if its pin has the revision, or it isn't pinned with the revision 0, or
+// whatever its revision with AnyRevision : ErrRevisionConflict is returned otherwise. The revision is checked against
+// the pin stored. It returns the pin saved, nil if unpinned.
+func (r *Router) PinIf(region, did string, revision int) (*Pin, error) {
+	if _, ok := r.config.Regions[region]; !ok && region != "" {
+		return nil, fmt.Errorf("%w %s", ErrUnknownRegion, region)
+	}
+
+	unlock, err := r.locker.Lock(lockPrefix + did)
+	if err != nil {
+		return nil, fmt.Errorf("lock residency pin : %w", err)
+	}
+
+	defer unlock()
+
+	current, err := r.Lookup(did)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkRevision(current, revision); err != nil {
+		return nil, err
+	}
+
+	var pin *Pin
+
+	if region == "" {
+		err = r.store.Delete(did)
+	} else {
+		pin = &Pin{Region: region, Revision: 1}
+		if current != nil {
+			pin.Revision = current.Revision + 1
+		}
+
+		err = r.save(did, pin)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("save residency pin : %w", err)
+	}
+
+	r.mutex.Lock()
+	if pin == nil {
+		delete(r.pins, did)
+	} else {
+		r.pins[did] = pin
+	}
+	r.mutex.Unlock()
+
+	logger.Infof("wallet %s pinned to region [%s]", did, region)
+
+	return pin, nil
+}
+
+func (r *Router) save(did string, pin *Pin) error {
+	pinBytes, err := json.Marshal(pin)
+	if err != nil {
+		return err
+	}
+
+	return r.store.Put(did, pinBytes, storage.Tag{Name: pinTag})
+}
+
+func checkRevision(pin *Pin, revision int) error {
+	switch {
+	case revision == AnyRevision:
+		return nil
+	case pin == nil && revision == 0:
+		return nil
+	case pin == nil:
+		return fmt.Errorf("%w : wallet not pinned", ErrRevisionConflict)
+	case pin.Revision != revision:
+		return fmt.Errorf("%w : current revision is %d", ErrRevisionConflict, pin.Revision)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,166 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package residency
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+func newConfig() *Config {
+	return &Config{
+		Regions: map[string]storage.Provider{"eu": mem.NewProvider(), "us": mem.NewProvider()},
+		Tenants: map[string]string{"acme": "eu"},
+	}
+}
+
+func TestConfig(t *testing.T) {
+	require.False(t, (*Config)(nil).Enabled())
+	require.False(t, (&Config{}).Enabled())
+	require.True(t, newConfig().Enabled())
+}
+
+func TestRouter(t *testing.T) {
+	t.Run("pins", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		r, err := New(p, lock.NewLocal(), newConfig())
+		require.NoError(t, err)
+		require.Equal(t, []string{"eu", "us"}, r.Regions())
+		require.Equal(t, "eu", r.TenantRegion("acme"))
+		require.Empty(t, r.TenantRegion("beta"))
+
+		require.NoError(t, r.Assign("acme", "conn-1", "did:peer:wallet1"))
+		require.NoError(t, r.Assign("beta", "did:peer:wallet2"))
+		require.Equal(t, "eu", r.Region("did:peer:wallet1"))
+		require.Empty(t, r.Region("did:peer:wallet2"))
+		require.Empty(t, r.Region("conn-1"))
+
+		require.NoError(t, r.Pin("us", "did:peer:wallet2"))
+		require.NoError(t, r.Pin("us", "did:peer:wallet1"))
+		require.NoError(t, r.Assign("acme", "did:peer:wallet1"))
+		require.Equal(t, "us", r.Region("did:peer:wallet1"))
+
+		err = r.Pin("apac", "did:peer:wallet1")
+		require.True(t, errors.Is(err, ErrUnknownRegion))
+
+		require.NoError(t, r.Pin("", "did:peer:wallet1"))
+		require.Empty(t, r.Region("did:peer:wallet1"))
+
+		reloaded, err := New(p, lock.NewLocal(), newConfig())
+		require.NoError(t, err)
+		require.Equal(t, "us", reloaded.Region("did:peer:wallet2"))
+		require.Empty(t, reloaded.Region("did:peer:wallet1"))
+	})
+
+	t.Run("revisions", func(t *testing.T) {
+		r, err := New(mem.NewProvider(), lock.NewLocal(), newConfig())
+		require.NoError(t, err)
+
+		_, err = r.PinIf("eu", "did:peer:wallet1", 1)
+		require.True(t, errors.Is(err, ErrRevisionConflict))
+
+		pin, err := r.PinIf("eu", "did:peer:wallet1", 0)
+		require.NoError(t, err)
+		require.Equal(t, &Pin{Region: "eu", Revision: 1}, pin)
+
+		_, err = r.PinIf("us", "did:peer:wallet1", 0)
+		require.True(t, errors.Is(err, ErrRevisionConflict))
+		require.Equal(t, "eu", r.Region("did:peer:wallet1"))
+
+		pin, err = r.PinIf("us", "did:peer:wallet1", 1)
+		require.NoError(t, err)
+		require.Equal(t, &Pin{Region: "us", Revision: 2}, pin)
+		require.Equal(t, pin, r.PinOf("did:peer:wallet1"))
+
+		pin, err = r.PinIf("", "did:peer:wallet1", 2)
+		require.NoError(t, err)
+		require.Nil(t, pin)
+		require.Nil(t, r.PinOf("did:peer:wallet1"))
+
+		pin, err = r.Lookup("did:peer:wallet1")
+		require.NoError(t, err)
+		require.Nil(t, pin)
+	})
+
+	t.Run("pinned before the revisions", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		store, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+		require.NoError(t, store.Put("did:peer:wallet1", []byte("eu"), storage.Tag{Name: pinTag}))
+
+		r, err := New(p, lock.NewLocal(), newConfig())
+		require.NoError(t, err)
+		require.Equal(t, &Pin{Region: "eu", Revision: 1}, r.PinOf("did:peer:wallet1"))
+
+		pin, err := r.PinIf("us", "did:peer:wallet1", 1)
+		require.NoError(t, err)
+		require.Equal(t, 2, pin.Revision)
+	})
+
+	t.Run("lock error", func(t *testing.T) {
+		r, err := New(mem.NewProvider(), &failingLocker{}, newConfig())
+		require.NoError(t, err)
+
+		err = r.Pin("eu", "did:peer:wallet1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "lock residency pin")
+	})
+
+	t.Run("tenant pinned to an unknown region", func(t *testing.T) {
+		config := newConfig()
+		config.Tenants["beta"] = "apac"
+
+		_, err := New(mem.NewProvider(), lock.NewLocal(), config)
+		require.True(t, errors.Is(err, ErrUnknownRegion))
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		_, err := New(&mockstorage.MockProvider{OpenStoreErr: errors.New("open error")}, lock.NewLocal(),
+			newConfig())
+		require.EqualError(t, err, "open residency store : open error")
+
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err = New(p, lock.NewLocal(), newConfig())
+		require.EqualError(t, err, "set residency store config : config error")
+
+		r, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), lock.NewLocal(), newConfig())
+		require.NoError(t, err)
+
+		_, err = r.PinIf("eu", "did:peer:wallet1", 0)
+		require.EqualError(t, err, "get residency pin : get error")
+
+		r, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+		}), lock.NewLocal(), newConfig())
+		require.NoError(t, err)
+
+		_, err = r.PinIf("eu", "did:peer:wallet1", 0)
+		require.EqualError(t, err, "save residency pin : put error")
+	})
+}
+
+type failingLocker struct{}
+
+func (l *failingLocker) Lock(string) (func(), error) {
+	return nil, errors.New("lock timeout")
+}
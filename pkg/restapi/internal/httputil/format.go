@@ -0,0 +1,124 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package httputil
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+// Media types of the responses.
+const (
+	MediaTypeJSON            = "application/json"
+	MediaTypeDIDCommEnvelope = "application/didcomm-envelope"
+	MediaTypePNG             = "image/png"
+
+	anyMediaType = "*/*"
+)
+
+// Negotiate returns the media type of the response : the offer the request accepts with the highest quality in its
+// Accept header, the first one on a tie, or the first offer if the request has no Accept header. It returns false if
+// the request accepts none of the offers.
+func Negotiate(req *http.Request, offers ...string) (string, bool) {
+	accept := req.Header.Values("Accept")
+	if len(accept) == 0 {
+		return offers[0], true
+	}
+
+	ranges := parseAccept(strings.Join(accept, ","))
+
+	best, bestQuality := "", 0.0
+
+	for _, offer := range offers {
+		if q := quality(ranges, offer); q > bestQuality {
+			best, bestQuality = offer, q
+		}
+	}
+
+	return best, best != ""
+}
+
+// WriteNotAcceptable writes the not acceptable response, with the media types supported by the endpoint.
+func WriteNotAcceptable(rw http.ResponseWriter, endpoint string, logger log.Logger, offers ...string) {
+	WriteErrorResponseWithLog(rw, http.StatusNotAcceptable,
+		fmt.Sprintf("not acceptable, supported media types : %s", strings.Join(offers, ", ")), endpoint, logger)
+}
+
+// WriteContent writes the content of the response, with its media type.
+func WriteContent(rw http.ResponseWriter, mediaType string, content []byte, endpoint string, logger log.Logger) {
+	rw.Header().Set("Content-Type", mediaType)
+
+	if _, err := rw.Write(content); err != nil {
+		logger.Errorf("endpoint=[%s] failed to write response : %s", endpoint, err.Error())
+
+		return
+	}
+
+	logger.Infof("endpoint=[%s] msg=[%s]", endpoint, "success")
+}
+
+type mediaRange struct {
+	mediaType string
+	quality   float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	var ranges []mediaRange
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, mediaRange{mediaType: mediaType, quality: q})
+	}
+
+	return ranges
+}
+
+// quality returns the quality of the offer : the quality of the most specific media range matching it, zero if none.
+func quality(ranges []mediaRange, offer string) float64 {
+	q, specificity := 0.0, -1
+
+	for _, r := range ranges {
+		s := matchSpecificity(r.mediaType, offer)
+		if s > specificity {
+			q, specificity = r.quality, s
+		}
+	}
+
+	return q
+}
+
+// matchSpecificity returns how specific the media range matching the media type is (2 for type/subtype, 1 for type/*,
+// 0 for */*), -1 if it doesn't match.
+func matchSpecificity(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case mediaRange == anyMediaType:
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	default:
+		return -1
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package httputil
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{MediaTypeJSON, MediaTypeDIDCommEnvelope, MediaTypePNG}
+
+	for accept, expected := range map[string]string{
+		"":                                 MediaTypeJSON,
+		"*/*":                              MediaTypeJSON,
+		"image/png":                        MediaTypePNG,
+		"image/*":                          MediaTypePNG,
+		"application/didcomm-envelope":     MediaTypeDIDCommEnvelope,
+		"image/png;q=0.5, application/*":   MediaTypeJSON,
+		"image/png, */*;q=0.1":             MediaTypePNG,
+		"application/json;q=0, */*":        MediaTypeDIDCommEnvelope,
+		"text/html, image/png;q=0.9, bad/": MediaTypePNG,
+		"image/png;q=high, */*;q=0.2":      MediaTypeJSON,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		mediaType, ok := Negotiate(req, offers...)
+		require.True(t, ok, accept)
+		require.Equal(t, expected, mediaType, accept)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/html, image/*;q=0")
+
+	_, ok := Negotiate(req, offers...)
+	require.False(t, ok)
+
+	rw := httptest.NewRecorder()
+	WriteNotAcceptable(rw, "/", log.New("test"), offers...)
+	require.Equal(t, http.StatusNotAcceptable, rw.Code)
+	require.Contains(t, rw.Body.String(), "supported media types : application/json, application/didcomm-envelope")
+}
+
+func TestWriteContent(t *testing.T) {
+	rw := httptest.NewRecorder()
+	WriteContent(rw, MediaTypePNG, []byte("png"), "/", log.New("test"))
+	require.Equal(t, MediaTypePNG, rw.Header().Get("Content-Type"))
+	require.Equal(t, "png", rw.Body.String())
+
+	WriteContent(&failingWriter{ResponseWriter: httptest.NewRecorder()}, MediaTypePNG, []byte("png"), "/",
+		log.New("test"))
+}
+
+type failingWriter struct {
+	http.ResponseWriter
+}
+
+func (w *failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write error")
+}
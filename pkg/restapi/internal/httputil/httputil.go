@@ -17,17 +17,26 @@ import (
 // ErrorResponse to send error message in the response.
 type ErrorResponse struct {
 	Message string `json:"errMessage,omitempty"`
+	// Explain is the human-readable explanation of the error status, in the language of the request.
+	Explain string `json:"explain,omitempty"`
 }
 
-// WriteErrorResponseWithLog write error response along with adding a error log.
+// Localizer localizes the human-readable fields of the error responses, in the language of the request.
+type Localizer interface {
+	// Explain returns the explanation of the error status, and its locale.
+	Explain(status int) (text, locale string)
+}
+
+// WriteErrorResponseWithLog write error response along with adding a error log. The response is localized if the
+// response writer is, or wraps, a Localizer.
 func WriteErrorResponseWithLog(rw http.ResponseWriter, status int, msg, endpoint string, logger log.Logger) {
 	logger.Errorf("endpoint=[%s] status=[%d] errMsg=[%s]", endpoint, status, msg)
 
+	resp := ErrorResponse{Message: msg, Explain: Explain(rw, status)}
+
 	rw.WriteHeader(status)
 
-	err := json.NewEncoder(rw).Encode(ErrorResponse{
-		Message: msg,
-	})
+	err := json.NewEncoder(rw).Encode(resp)
 
 	if err != nil {
 		logger.Errorf("Unable to send error message, %s", err)
@@ -43,3 +52,33 @@ func WriteResponseWithLog(rw io.Writer, v interface{}, endpoint string, logger l
 		logger.Errorf("Unable to send error response, %s", err)
 	}
 }
+
+// Explain returns the explanation of the error status in the language of the request, and sets the Content-Language
+// of the response. It returns an empty explanation if the response writer isn't localized.
+func Explain(rw http.ResponseWriter, status int) string {
+	l, ok := localizer(rw)
+	if !ok {
+		return ""
+	}
+
+	text, locale := l.Explain(status)
+	rw.Header().Set("Content-Language", locale)
+
+	return text
+}
+
+// localizer returns the Localizer of the response writer, unwrapping the response writers wrapping it.
+func localizer(rw http.ResponseWriter) (Localizer, bool) {
+	for {
+		if l, ok := rw.(Localizer); ok {
+			return l, true
+		}
+
+		u, ok := rw.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil, false
+		}
+
+		rw = u.Unwrap()
+	}
+}
@@ -0,0 +1,89 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+
+	"github.com/trustbloc/hub-router/pkg/backpressure"
+	"github.com/trustbloc/hub-router/pkg/preparse"
+)
+
+// AdmitEnvelope rejects the envelopes received by a standby node, and the messages not compliant with the Aries RFCs
+// in strict compliance mode. It pauses the forward messages addressed to the wallets of a suspended tenant, rejecting
+// them so that the senders retry them once the tenant is active again. It is bound to the first stage of the inbound
+// chains : the nested forwards are admitted before being relayed to another mediator.
+func (o *Operation) AdmitEnvelope(envelope *transport.Envelope, header *preparse.Header) error {
+	if err := o.failover.admit(); err != nil {
+		return err
+	}
+
+	if err := o.compliance.check(envelope); err != nil {
+		return err
+	}
+
+	return o.admitTenantForward(header)
+}
+
+// gateForward redirects the forwards addressed to the wallets handed over to another mediator, suppresses the
+// duplicate forwards, then admits the envelope through the backpressure gate.
+func (o *Operation) gateForward(envelope *transport.Envelope, header *preparse.Header) error {
+	if err := o.handover.redirect(envelope, header); err != nil {
+		return err
+	}
+
+	if err := o.suppressDuplicate(header); err != nil {
+		return err
+	}
+
+	return o.backpressure.admitEnvelope(envelope, header)
+}
+
+// gateEnvelope hands the envelope on once through the gate, and records it once handled; the suppressed envelopes are
+// acknowledged without being handed on.
+func (o *Operation) gateEnvelope(envelope *transport.Envelope, header *preparse.Header,
+	next transport.InboundMessageHandler) error {
+	err := o.gateForward(envelope, header)
+	if errors.Is(err, backpressure.ErrSuppressed) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = next(envelope)
+	if err != nil {
+		return err
+	}
+
+	o.EnvelopeHandled(envelope, header)
+
+	return nil
+}
+
+func (o *Operation) admitTenantForward(forward *preparse.Header) error {
+	if !o.tenancy.registry.AnySuspended() || !forward.IsForward() {
+		return nil
+	}
+
+	theirDID, err := o.routes.DID(string(forward.To))
+	if err != nil {
+		return nil // nolint:nilerr // not routed by the router : handled by the Aries mediator
+	}
+
+	if err = o.tenancy.checkActive(o.tenantOf(string(theirDID))); err != nil {
+		logger.Warnf("forward paused : msgID=%s : %s", forward.ID, err)
+
+		return fmt.Errorf("forward paused : %w", err)
+	}
+
+	return nil
+}
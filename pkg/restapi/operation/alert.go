@@ -0,0 +1,97 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/trustbloc/hub-router/pkg/alert"
+	"github.com/trustbloc/hub-router/pkg/digest"
+	"github.com/trustbloc/hub-router/pkg/queue"
+)
+
+// storageProbeInterval is the interval the storage is probed at, for the storage failure incidents and alerts.
+const storageProbeInterval = 30 * time.Second
+
+// initAlerts initializes the critical alerts, if alert channels are configured, and the storage probes.
+func (o *Operation) initAlerts(config *Config) error {
+	o.reporting.alerts = alert.New(config.Reporting.AlertChannels...)
+
+	var err error
+
+	o.reporting.storageMonitor, err = alert.NewStorageMonitor(map[string]storage.Provider{
+		"router": config.Storage.Persistent,
+		"aries":  config.Aries.StorageProvider(),
+	}, o.reporting.raise)
+	if err != nil {
+		return fmt.Errorf("storage monitor: %w", err)
+	}
+
+	return nil
+}
+
+// sendAlert sends the alert to the alert channels without blocking the caller.
+func (h *reportingHandler) sendAlert(a *alert.Alert) {
+	if h.alerts == nil {
+		return
+	}
+
+	go func() {
+		if err := h.alerts.Send(a); err != nil {
+			logger.Warnf("failed to send alert : %s", err)
+		}
+	}()
+}
+
+// queueWatermarkAlert returns the alert of the queue watermark crossed, resolved once the queue is back below.
+func queueWatermarkAlert(a *queue.Alert) *alert.Alert {
+	name, subject := "router queue", "router"
+	if a.Scope == queue.ScopeRecipient {
+		name, subject = "queue of connection "+a.ConnectionID, a.ConnectionID
+	}
+
+	summary := fmt.Sprintf("%s depth %d above watermark %d", name, a.Depth, a.Watermark)
+	if a.State == queue.StateCleared {
+		summary = fmt.Sprintf("%s depth %d back below watermark %d", name, a.Depth, a.Watermark)
+	}
+
+	return &alert.Alert{
+		Kind:     alert.QueueWatermark,
+		Subject:  subject,
+		Resolved: a.State == queue.StateCleared,
+		Time:     a.Time,
+		Summary:  summary,
+		Detail:   a,
+	}
+}
+
+// sloBreachSink sends an SLO breach alert for the digests whose delivery SLO is not met.
+type sloBreachSink struct {
+	send func(*alert.Alert)
+}
+
+// Deliver sends the SLO breach alert of the digest, if any. The alert is sent asynchronously, so that its failures
+// don't redeliver the digest to the other sinks.
+func (s *sloBreachSink) Deliver(d *digest.Digest) error {
+	if d.Delivery == nil || d.Delivery.Met {
+		return nil
+	}
+
+	s.send(&alert.Alert{
+		Kind:    alert.SLOBreach,
+		Subject: d.Schedule,
+		Time:    d.GeneratedAt,
+		Summary: fmt.Sprintf("%s delivery success rate %.2f%% below objective %.2f%% from %s to %s", d.Schedule,
+			100*d.Delivery.SuccessRate, 100*d.Delivery.Objective, d.From.Format(time.RFC3339), d.To.Format(time.RFC3339)),
+		Detail: d.Delivery,
+	})
+
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/alert"
+	"github.com/trustbloc/hub-router/pkg/digest"
+	"github.com/trustbloc/hub-router/pkg/queue"
+)
+
+type mockAlertChannel struct {
+	mutex  sync.Mutex
+	alerts []*alert.Alert
+}
+
+func (m *mockAlertChannel) Send(a *alert.Alert) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.alerts = append(m.alerts, a)
+
+	return nil
+}
+
+func (m *mockAlertChannel) received(n int) func() bool {
+	return func() bool {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+
+		return len(m.alerts) == n
+	}
+}
+
+func TestAlerts(t *testing.T) {
+	t.Run("alerts not configured", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+		require.Nil(t, o.reporting.alerts)
+		require.NotNil(t, o.reporting.storageMonitor)
+
+		o.reporting.sendAlert(&alert.Alert{Kind: alert.StorageFailure})
+	})
+
+	t.Run("queue watermark alerts", func(t *testing.T) {
+		channel := &mockAlertChannel{}
+
+		cfg := config()
+		cfg.Reporting.AlertChannels = []alert.Channel{channel}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+		require.NotNil(t, o.reporting.storageMonitor)
+
+		now := time.Now().UTC()
+
+		o.queueAlert(&queue.Alert{
+			Time: now, Scope: queue.ScopeRecipient, State: queue.StateHigh, ConnectionID: "conn-1", Depth: 120,
+			Watermark: 100,
+		})
+		require.Eventually(t, channel.received(1), 5*time.Second, 10*time.Millisecond)
+
+		o.queueAlert(&queue.Alert{Time: now, Scope: queue.ScopeGlobal, State: queue.StateCleared, Depth: 10,
+			Watermark: 1000})
+		require.Eventually(t, channel.received(2), 5*time.Second, 10*time.Millisecond)
+
+		channel.mutex.Lock()
+		defer channel.mutex.Unlock()
+
+		alerts := map[bool]*alert.Alert{channel.alerts[0].Resolved: channel.alerts[0]}
+		alerts[channel.alerts[1].Resolved] = channel.alerts[1]
+
+		require.Equal(t, alert.QueueWatermark, alerts[false].Kind)
+		require.Equal(t, now, alerts[false].Time)
+		require.Equal(t, "conn-1", alerts[false].Subject)
+		require.Equal(t, "queue of connection conn-1 depth 120 above watermark 100", alerts[false].Summary)
+		require.Equal(t, "router", alerts[true].Subject)
+		require.Equal(t, "router queue depth 10 back below watermark 1000", alerts[true].Summary)
+	})
+
+	t.Run("slo breach alerts", func(t *testing.T) {
+		channel := &mockAlertChannel{}
+
+		cfg := config()
+		cfg.Reporting.AlertChannels = []alert.Channel{channel}
+		cfg.Reporting.Digests = &digest.Config{Schedules: []string{digest.Daily}}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		from, to, err := digest.Period(digest.Daily, time.Now())
+		require.NoError(t, err)
+
+		sink := &sloBreachSink{send: o.reporting.sendAlert}
+
+		require.NoError(t, sink.Deliver(&digest.Digest{Delivery: digest.NewDelivery(10, 0, 0.99)}))
+		require.NoError(t, sink.Deliver(&digest.Digest{
+			Schedule: digest.Daily, From: from, To: to, Delivery: digest.NewDelivery(90, 10, 0.99),
+		}))
+		require.Eventually(t, channel.received(1), 5*time.Second, 10*time.Millisecond)
+
+		channel.mutex.Lock()
+		defer channel.mutex.Unlock()
+
+		require.Equal(t, alert.SLOBreach, channel.alerts[0].Kind)
+		require.Equal(t, digest.Daily, channel.alerts[0].Subject)
+		require.Equal(t, "daily delivery success rate 90.00% below objective 99.00% from "+
+			from.Format(time.RFC3339)+" to "+to.Format(time.RFC3339), channel.alerts[0].Summary)
+	})
+}
@@ -0,0 +1,205 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+
+	"github.com/trustbloc/hub-router/pkg/alert"
+	"github.com/trustbloc/hub-router/pkg/anomaly"
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/digest"
+	"github.com/trustbloc/hub-router/pkg/incident"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// API endpoints.
+const (
+	anomaliesPath = "/reports/anomalies"
+)
+
+// anomalyAnalysisInterval is the interval the activity of the hours over is analyzed at.
+const anomalyAnalysisInterval = 5 * time.Minute
+
+// AnomaliesResp model.
+type AnomaliesResp struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Summary is the number of anomalies per kind.
+	Summary map[string]int    `json:"summary"`
+	Reports []*anomaly.Report `json:"reports"`
+}
+
+// reportingHandler records the incidents, and detects the anomalies, sends the alerts and delivers the digests, if
+// configured : its anomaly detector, alerter and digest scheduler are nil if not.
+type reportingHandler struct {
+	anomalies      *anomaly.Detector
+	digests        *digest.Scheduler
+	alerts         *alert.Alerter
+	storageMonitor *alert.StorageMonitor
+	incidents      *incident.Timeline
+}
+
+// initReporting initializes the incident timeline, and the anomaly detection, the alerts and the digests, if
+// configured.
+func (o *Operation) initReporting(config *Config) error {
+	o.reporting = &reportingHandler{}
+
+	if err := o.initIncidents(config); err != nil {
+		return err
+	}
+
+	if config.Reporting.Anomalies != nil {
+		var err error
+
+		o.reporting.anomalies, err = anomaly.New(config.Storage.Persistent, config.Reporting.Anomalies, o.walletConnection)
+		if err != nil {
+			return fmt.Errorf("anomaly detector: %w", err)
+		}
+	}
+
+	if err := o.initAlerts(config); err != nil {
+		return err
+	}
+
+	return o.initDigests(config)
+}
+
+func (h *reportingHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(anomaliesPath, http.MethodGet, h.getAnomalies),
+		support.NewHTTPHandler(incidentsPath, http.MethodGet, h.getIncidents),
+	}
+}
+
+// start starts the incident checks, the storage probes, and the anomaly analysis and the digest deliveries, if
+// configured.
+func (h *reportingHandler) start() {
+	h.incidents.Start(incidentCheckInterval)
+	h.storageMonitor.Start(storageProbeInterval)
+
+	if h.anomalies != nil {
+		h.anomalies.Start(anomalyAnalysisInterval)
+	}
+
+	if h.digests != nil {
+		h.digests.Start(digestInterval)
+	}
+}
+
+// getAnomalies returns the anomaly reports of the hours within the 'from' and 'to' RFC3339 query params, the last 24
+// hours by default.
+func (h *reportingHandler) getAnomalies(rw http.ResponseWriter, req *http.Request) {
+	if h.anomalies == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "anomaly detection not enabled", anomaliesPath,
+			logger)
+
+		return
+	}
+
+	from, to, err := getTimeRange(req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), anomaliesPath, logger)
+
+		return
+	}
+
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+
+	if from.IsZero() {
+		from = to.Add(-day)
+	}
+
+	reports, err := h.anomalies.Reports(from, to)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get anomaly reports - err=%s", err.Error()), anomaliesPath, logger)
+
+		return
+	}
+
+	summary := map[string]int{}
+
+	for _, r := range reports {
+		for _, a := range r.Anomalies {
+			summary[a.Kind]++
+		}
+	}
+
+	httputil.WriteResponseWithLog(rw, &AnomaliesResp{From: from, To: to, Summary: summary, Reports: reports},
+		anomaliesPath, logger)
+}
+
+// observeEnvelope records the forward routed to the wallet with the DID, empty if the envelope isn't a forward routed
+// by the router, and the keys of the envelope, for the anomaly detection.
+func (h *reportingHandler) observeEnvelope(envelope *transport.Envelope, theirDID string) {
+	if h.anomalies == nil {
+		return
+	}
+
+	if theirDID != "" {
+		h.anomalies.Forward(theirDID)
+	}
+
+	if len(envelope.FromKey) == 0 || len(envelope.ToKey) == 0 {
+		return
+	}
+
+	err := h.anomalies.SenderKey(base58.Encode(envelope.ToKey), base58.Encode(envelope.FromKey))
+	if err != nil {
+		logger.Warnf("failed to record the sender key : %s", err)
+	}
+}
+
+// recordFailure records the failure of a message of the connection, for the anomaly detection.
+func (h *reportingHandler) recordFailure(connID string) {
+	if h.anomalies != nil && connID != "" {
+		h.anomalies.Failure(connID)
+	}
+}
+
+// msgConnectionID returns the connection the inbound message is received on, empty if not found.
+func (o *Operation) msgConnectionID(msg service.DIDCommMsg) string {
+	inbound, ok := msg.(*aries.InboundMsg)
+	if !ok {
+		return ""
+	}
+
+	connID, err := o.connections.GetConnectionIDByDIDs(inbound.MyDID, inbound.TheirDID)
+	if err != nil {
+		return ""
+	}
+
+	return connID
+}
+
+// walletConnection returns the connection with the wallet DID, empty if not found.
+func (o *Operation) walletConnection(walletDID string) string {
+	records, err := o.connections.QueryConnectionRecords()
+	if err != nil {
+		logger.Warnf("failed to query the connections : %s", err)
+
+		return ""
+	}
+
+	for _, r := range records {
+		if r.TheirDID == walletDID {
+			return r.ConnectionID
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,144 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/anomaly"
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/routes"
+)
+
+func TestAnomalies(t *testing.T) {
+	newOperation := func(t *testing.T) *Operation {
+		t.Helper()
+
+		ariesStorage := mem.NewProvider()
+
+		mediated, err := routes.Open(ariesStorage)
+		require.NoError(t, err)
+		require.NoError(t, mediated.Route("key-1", "did:wallet"))
+
+		cfg := config()
+		cfg.Aries.(*mockprovider.Provider).StorageProviderValue = ariesStorage
+		cfg.Reporting.Anomalies = &anomaly.Config{}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-1", State: connection.StateNameCompleted, ThreadID: "thid-1",
+			MyDID: "did:router", TheirDID: "did:wallet", Namespace: connection.MyNSPrefix,
+		}))
+
+		return o
+	}
+
+	getAnomalies := func(o *Operation, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.reporting.getAnomalies(w, httptest.NewRequest(http.MethodGet, anomaliesPath+query, nil))
+
+		return w
+	}
+
+	t.Run("anomaly detection not enabled", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.EnvelopeHandled(withHeader(&transport.Envelope{
+			Message: []byte(`{}`), FromKey: []byte("from"), ToKey: []byte("to"),
+		}))
+		o.reporting.recordFailure("conn-1")
+
+		require.Equal(t, http.StatusNotFound, getAnomalies(o, "").Code)
+	})
+
+	t.Run("anomaly reports", func(t *testing.T) {
+		o := newOperation(t)
+
+		o.EnvelopeHandled(withHeader(&transport.Envelope{
+			Message: []byte(`{"@id":"msg-1","@type":"https://didcomm.org/routing/1.0/forward","to":"key-1","msg":{}}`),
+			FromKey: []byte("sender-key"),
+			ToKey:   []byte("recipient-key"),
+		}))
+		o.reporting.recordFailure("conn-1")
+
+		w := getAnomalies(o, "?from=2021-03-01T10:00:00Z&to=2021-03-02T10:00:00Z")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &AnomaliesResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, "2021-03-01T10:00:00Z", resp.From.Format(time.RFC3339))
+		require.Empty(t, resp.Reports)
+		require.Empty(t, resp.Summary)
+
+		w = getAnomalies(o, "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp = &AnomaliesResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, day, resp.To.Sub(resp.From))
+	})
+
+	t.Run("invalid time range", func(t *testing.T) {
+		o := newOperation(t)
+
+		require.Equal(t, http.StatusBadRequest, getAnomalies(o, "?from=yesterday").Code)
+		require.Equal(t, http.StatusBadRequest,
+			getAnomalies(o, "?from=2021-03-02T10:00:00Z&to=2021-03-01T10:00:00Z").Code)
+	})
+
+	t.Run("storage error", func(t *testing.T) {
+		o := newOperation(t)
+
+		var err error
+
+		o.reporting.anomalies, err = anomaly.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}), nil, nil)
+		require.NoError(t, err)
+
+		o.EnvelopeHandled(withHeader(&transport.Envelope{
+			Message: []byte(`{}`), FromKey: []byte("from"), ToKey: []byte("to"),
+		}))
+
+		require.Equal(t, http.StatusInternalServerError, getAnomalies(o, "").Code)
+	})
+
+	t.Run("connections of the anomalies", func(t *testing.T) {
+		o := newOperation(t)
+
+		require.Equal(t, "conn-1", o.walletConnection("did:wallet"))
+		require.Empty(t, o.walletConnection("did:other"))
+
+		require.Equal(t, "conn-1", o.msgConnectionID(&aries.InboundMsg{
+			DIDCommMsg: service.DIDCommMsgMap{}, MyDID: "did:router", TheirDID: "did:wallet",
+		}))
+		require.Empty(t, o.msgConnectionID(&aries.InboundMsg{
+			DIDCommMsg: service.DIDCommMsgMap{}, MyDID: "did:router", TheirDID: "did:other",
+		}))
+		require.Empty(t, o.msgConnectionID(service.DIDCommMsgMap{}))
+	})
+}
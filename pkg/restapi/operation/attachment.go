@@ -0,0 +1,174 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/trustbloc/hub-router/pkg/attachment"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+// API endpoints.
+const (
+	attachmentsPath       = "/attachments"
+	attachmentPath        = attachmentsPath + "/{id}"
+	attachmentContentPath = attachmentPath + "/content"
+)
+
+// attachmentSweepInterval is the interval the expired attachments are deleted at.
+const attachmentSweepInterval = time.Minute
+
+// attachmentHandler serves the attachments forwarded by URL instead of inline. Its store is nil if the attachments
+// aren't enabled.
+type attachmentHandler struct {
+	store *attachment.Store
+}
+
+func (o *Operation) initAttachments(config *Config) error {
+	o.attachments = &attachmentHandler{}
+
+	if !config.Attachments.Enabled() {
+		return nil
+	}
+
+	var err error
+
+	o.attachments.store, err = attachment.New(config.Storage.Persistent, config.Attachments)
+	if err != nil {
+		return fmt.Errorf("attachment store: %w", err)
+	}
+
+	return nil
+}
+
+func (h *attachmentHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(attachmentsPath, http.MethodPost, h.uploadAttachment),
+		support.NewHTTPHandler(attachmentPath, http.MethodDelete, h.deleteAttachment),
+		support.NewHTTPHandler(attachmentContentPath, http.MethodGet, h.getAttachmentContent),
+	}
+}
+
+// start sweeps the expired attachments periodically, if enabled.
+func (h *attachmentHandler) start() {
+	if h.store != nil {
+		h.store.Start(attachmentSweepInterval)
+	}
+}
+
+// uploadAttachment stores the request body as an attachment, and returns the URL the wallet fetches it from, to be
+// forwarded instead of the blob.
+func (h *attachmentHandler) uploadAttachment(rw http.ResponseWriter, req *http.Request) {
+	if h.store == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "attachments not enabled", attachmentsPath, logger)
+
+		return
+	}
+
+	var ttl time.Duration
+
+	if v := req.URL.Query().Get("ttl"); v != "" {
+		var err error
+
+		ttl, err = time.ParseDuration(v)
+		if err != nil || ttl <= 0 {
+			httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, fmt.Sprintf("invalid ttl : %s", v),
+				attachmentsPath, logger)
+
+			return
+		}
+	}
+
+	contentType := req.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	a, err := h.store.Put(tenant.FromContext(req.Context()), contentType, req.Body, ttl)
+	if errors.Is(err, attachment.ErrTooLarge) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusRequestEntityTooLarge, err.Error(), attachmentsPath, logger)
+
+		return
+	}
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to store attachment - err=%s", err.Error()), attachmentsPath, logger)
+
+		return
+	}
+
+	rw.WriteHeader(http.StatusCreated)
+	httputil.WriteResponseWithLog(rw, a, attachmentsPath, logger)
+}
+
+// getAttachmentContent returns the blob of the attachment to the holder of its URL, without API key.
+func (h *attachmentHandler) getAttachmentContent(rw http.ResponseWriter, req *http.Request) {
+	if h.store == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "attachments not enabled", attachmentContentPath,
+			logger)
+
+		return
+	}
+
+	a, blob, err := h.store.Get(mux.Vars(req)["id"], req.URL.Query().Get("token"))
+	if err != nil {
+		writeAttachmentError(rw, err, attachmentContentPath)
+
+		return
+	}
+
+	// the blob is served from the router origin : it must never be rendered by the browser
+	rw.Header().Set("Content-Type", a.ContentType)
+	rw.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+	rw.Header().Set("Content-Disposition", "attachment")
+	rw.Header().Set("X-Content-Type-Options", "nosniff")
+	rw.Header().Set("Cache-Control", "private, no-store")
+	rw.Header().Set("Digest", "sha-256="+a.Digest)
+
+	if _, err = rw.Write(blob); err != nil {
+		logger.Errorf("endpoint=[%s] failed to write attachment %s : %s", attachmentContentPath, a.ID, err.Error())
+	}
+}
+
+// deleteAttachment deletes the attachment before it expires, eg: once the wallet confirmed its receipt.
+func (h *attachmentHandler) deleteAttachment(rw http.ResponseWriter, req *http.Request) {
+	if h.store == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "attachments not enabled", attachmentPath, logger)
+
+		return
+	}
+
+	err := h.store.Delete(mux.Vars(req)["id"], tenant.FromContext(req.Context()))
+	if err != nil {
+		writeAttachmentError(rw, err, attachmentPath)
+
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func writeAttachmentError(rw http.ResponseWriter, err error, endpoint string) {
+	if errors.Is(err, attachment.ErrNotFound) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, err.Error(), endpoint, logger)
+
+		return
+	}
+
+	httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+		fmt.Sprintf("failed to get attachment - err=%s", err.Error()), endpoint, logger)
+}
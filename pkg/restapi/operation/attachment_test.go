@@ -0,0 +1,136 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/attachment"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+func TestAttachments(t *testing.T) {
+	newRouter := func(t *testing.T, attachments *attachment.Config) http.Handler {
+		t.Helper()
+
+		cfg := config()
+		cfg.Tenancy.APIKeys = tenant.NewKeys("operator-key", map[string]string{
+			"tenant-1": "tenant-key-1", "tenant-2": "tenant-key-2",
+		})
+		cfg.Attachments = attachments
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		router := mux.NewRouter()
+
+		for _, h := range o.GetRESTHandlers() {
+			router.HandleFunc(h.Path(), h.Handle()).Methods(h.Method())
+		}
+
+		router.Use(o.Authenticate)
+
+		return router
+	}
+
+	serve := func(router http.Handler, method, target, auth string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, body)
+		if auth != "" {
+			req.Header.Set("Authorization", "Bearer "+auth)
+		}
+
+		req.Header.Set("Content-Type", "image/png")
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("upload, fetch and delete", func(t *testing.T) {
+		router := newRouter(t, &attachment.Config{BaseURL: "https://router.example.com", TTL: time.Hour})
+
+		w := serve(router, http.MethodPost, attachmentsPath+"?ttl=10m", "tenant-key-1", strings.NewReader("image"))
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		a := &attachment.Attachment{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), a))
+		require.Equal(t, "tenant-1", a.Tenant)
+		require.Equal(t, int64(5), a.Size)
+		require.Equal(t, 10*time.Minute, a.Expires.Sub(a.Created))
+
+		u, err := url.Parse(a.URL)
+		require.NoError(t, err)
+
+		// the wallet fetches the content without API key
+		w = serve(router, http.MethodGet, u.RequestURI(), "", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "image", w.Body.String())
+		require.Equal(t, "image/png", w.Header().Get("Content-Type"))
+		require.Equal(t, "5", w.Header().Get("Content-Length"))
+		require.Equal(t, "attachment", w.Header().Get("Content-Disposition"))
+		require.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+		require.Equal(t, "sha-256="+a.Digest, w.Header().Get("Digest"))
+
+		w = serve(router, http.MethodGet, u.Path+"?token=invalid", "", nil)
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		w = serve(router, http.MethodDelete, attachmentsPath+"/"+a.ID, "", nil)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = serve(router, http.MethodDelete, attachmentsPath+"/"+a.ID, "tenant-key-2", nil)
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		w = serve(router, http.MethodDelete, attachmentsPath+"/"+a.ID, "tenant-key-1", nil)
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		w = serve(router, http.MethodGet, u.RequestURI(), "", nil)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("invalid uploads", func(t *testing.T) {
+		router := newRouter(t, &attachment.Config{BaseURL: "https://router.example.com", MaxSize: 4})
+
+		w := serve(router, http.MethodPost, attachmentsPath, "", strings.NewReader("image"))
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+
+		for _, ttl := range []string{"soon", "-1m"} {
+			w = serve(router, http.MethodPost, attachmentsPath+"?ttl="+ttl, "operator-key", strings.NewReader("img"))
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			require.Contains(t, w.Body.String(), "invalid ttl")
+		}
+
+		w = serve(router, http.MethodPost, attachmentsPath, "operator-key", strings.NewReader("image"))
+		require.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+
+	t.Run("attachments not enabled", func(t *testing.T) {
+		router := newRouter(t, nil)
+
+		for _, method := range []string{http.MethodPost, http.MethodDelete, http.MethodGet} {
+			target := map[string]string{
+				http.MethodPost:   attachmentsPath,
+				http.MethodDelete: attachmentsPath + "/id",
+				http.MethodGet:    attachmentsPath + "/id/content",
+			}[method]
+
+			w := serve(router, method, target, "operator-key", nil)
+			require.Equal(t, http.StatusNotFound, w.Code)
+			require.Contains(t, w.Body.String(), "attachments not enabled")
+		}
+	})
+}
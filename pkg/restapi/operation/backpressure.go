@@ -0,0 +1,151 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	vdrapi "github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
+	didstore "github.com/hyperledger/aries-framework-go/pkg/store/did"
+
+	"github.com/trustbloc/hub-router/pkg/backpressure"
+	"github.com/trustbloc/hub-router/pkg/l10n"
+	"github.com/trustbloc/hub-router/pkg/preparse"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+const deliveryStatusTopic = "delivery-status"
+
+// backpressureHandler admits the forwards through the backpressure gate, and signals the backpressure to their senders
+// and to the webhooks. Its gate is nil if the backpressure isn't enabled.
+type backpressureHandler struct {
+	gate           *backpressure.Gate
+	didConnections didstore.ConnectionStore
+	vdr            vdrapi.Registry
+	messenger      service.Messenger
+	catalog        *l10n.Catalog
+	webhook        *webhook.Notifier
+	// recipients returns the DIDs of the wallets seen by the router, keyed by connection ID.
+	recipients func() (map[string]string, error)
+}
+
+func (o *Operation) initBackpressure(config *Config) error {
+	o.backpressure = &backpressureHandler{
+		vdr: o.vdriRegistry, messenger: o.messenger, catalog: o.catalog, webhook: o.webhook,
+		recipients: o.queueRecipients,
+	}
+
+	if !config.Admission.Backpressure.Enabled() {
+		return nil
+	}
+
+	var err error
+
+	o.didConnections, err = didstore.NewConnectionStore(config.Aries)
+	if err != nil {
+		return fmt.Errorf("did connection store: %w", err)
+	}
+
+	o.backpressure.didConnections = o.didConnections
+
+	o.backpressure.gate, err = backpressure.New(config.Aries.StorageProvider(), o.pickup,
+		config.Admission.Backpressure, o.backpressure.signal)
+	if err != nil {
+		return fmt.Errorf("backpressure gate: %w", err)
+	}
+
+	return nil
+}
+
+// admitEnvelope admits the forward through the backpressure gate, if enabled.
+func (h *backpressureHandler) admitEnvelope(envelope *transport.Envelope, header *preparse.Header) error {
+	if h.gate == nil {
+		return nil
+	}
+
+	return h.gate.AdmitEnvelope(envelope, header)
+}
+
+// signal reports the backpressure signal to the sender of the forward, and notifies it to the webhooks, without
+// blocking the inbound transport.
+func (h *backpressureHandler) signal(s *backpressure.Signal) {
+	go func() {
+		if err := h.report(s); err != nil {
+			logger.Debugf("backpressure problem report not sent : msgID=%s : %s", s.MsgID, err)
+		}
+
+		s.ConnectionID = h.recipientConnectionOf(s.RecipientDID)
+
+		if err := h.webhook.Notify(deliveryStatusTopic, s); err != nil {
+			logger.Warnf("failed to notify delivery status : %s", err)
+		}
+	}()
+}
+
+// report sends a problem report with the retry hint to the sender of the forward, if it is a DIDComm connection of the
+// router; the anonymous senders only get the webhook notifications.
+func (h *backpressureHandler) report(s *backpressure.Signal) error {
+	if s.SenderKey == "" {
+		return errors.New("anonymous sender")
+	}
+
+	theirDID, err := h.didConnections.GetDID(s.SenderKey)
+	if err != nil {
+		return fmt.Errorf("get sender DID : %w", err)
+	}
+
+	dest, err := service.GetDestination(theirDID, h.vdr)
+	if err != nil {
+		return fmt.Errorf("get sender destination : %w", err)
+	}
+
+	code := problemQueueNearFull
+	if s.Rejected {
+		code = problemQueueFull
+	}
+
+	en, _ := h.catalog.Text("", code)
+
+	report := &ProblemReport{
+		ID:          uuid.New().String(),
+		Type:        problemReportMsgType,
+		Description: &ProblemDescription{Code: code, En: en},
+		Explain:     en,
+		Thread:      &decorator.Thread{PID: s.MsgID},
+		RetryAfter:  s.RetryAfter,
+	}
+
+	err = h.messenger.SendToDestination(service.NewDIDCommMsgMap(report), s.RouterKey, dest)
+	if err != nil {
+		return fmt.Errorf("send problem report : %w", err)
+	}
+
+	return nil
+}
+
+// recipientConnectionOf returns the connection of the wallet with the given DID, empty if not found.
+func (h *backpressureHandler) recipientConnectionOf(theirDID string) string {
+	recipients, err := h.recipients()
+	if err != nil {
+		logger.Debugf("backpressure recipient connection not found : %s", err)
+
+		return ""
+	}
+
+	for connID, did := range recipients {
+		if did == theirDID {
+			return connID
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,192 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	mockvdri "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/backpressure"
+	"github.com/trustbloc/hub-router/pkg/inbound"
+	"github.com/trustbloc/hub-router/pkg/internal/mock/didexchange"
+	"github.com/trustbloc/hub-router/pkg/internal/mock/messenger"
+	"github.com/trustbloc/hub-router/pkg/presence"
+	"github.com/trustbloc/hub-router/pkg/routes"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestBackpressure(t *testing.T) {
+	forward := &transport.Envelope{
+		Message: []byte(`{"@id":"msg-1","@type":"https://didcomm.org/routing/1.0/forward","to":"key-1","msg":{}}`),
+		FromKey: []byte("adapter-key"),
+		ToKey:   []byte("router-key"),
+	}
+
+	t.Run("problem report and delivery status", func(t *testing.T) {
+		statuses := make(chan *backpressure.Signal, 1)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			msg := &struct {
+				Topic   string               `json:"topic"`
+				Message *backpressure.Signal `json:"message"`
+			}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(msg))
+
+			if msg.Topic == deliveryStatusTopic {
+				statuses <- msg.Message
+			}
+		}))
+		defer srv.Close()
+
+		ariesStorage := mem.NewProvider()
+
+		mediated, err := routes.Open(ariesStorage)
+		require.NoError(t, err)
+		require.NoError(t, mediated.Route("key-1", "did:wallet"))
+
+		cfg := config()
+		cfg.Aries.(*mockprovider.Provider).StorageProviderValue = ariesStorage
+		cfg.Aries.(*mockprovider.Provider).VDRegistryValue = &mockvdri.MockVDRegistry{
+			ResolveValue: mockdiddoc.GetMockDIDDoc(t),
+		}
+		cfg.Webhook = webhook.New([]string{srv.URL}, nil)
+		cfg.Admission.Backpressure = &backpressure.Config{RecipientCap: 10, RetryAfter: 30 * time.Second}
+		cfg.Transports.Inbound = []*inbound.Middleware{inbound.NewMiddleware(nil, inbound.Gate)}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		queueMessages(t, o.pickup, "did:wallet", "key-1", 10)
+
+		o.didExchange = &didexchange.MockClient{TheirDID: "did:wallet"}
+		require.NoError(t, o.presence.Seen("conn-1", presence.SourceMediation))
+		require.NoError(t, o.didConnections.SaveDID("did:adapter", base58.Encode(forward.FromKey)))
+
+		reports := make(chan *ProblemReport, 1)
+
+		o.backpressure.messenger = &messenger.MockMessenger{
+			SendToDestinationFunc: func(msg service.DIDCommMsgMap, sender string, dest *service.Destination) error {
+				require.Equal(t, base58.Encode(forward.ToKey), sender)
+				require.NotEmpty(t, dest.ServiceEndpoint)
+
+				report := &ProblemReport{}
+				require.NoError(t, msg.Decode(report))
+
+				reports <- report
+
+				return nil
+			},
+		}
+
+		err = o.backpressure.admitEnvelope(withHeader(forward))
+		require.ErrorIs(t, err, backpressure.ErrQueueFull)
+
+		select {
+		case report := <-reports:
+			require.Equal(t, problemReportMsgType, report.Type)
+			require.Equal(t, problemQueueFull, report.Description.Code)
+			require.Equal(t, "msg-1", report.Thread.PID)
+			require.Equal(t, 30, report.RetryAfter)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+
+		select {
+		case s := <-statuses:
+			require.Equal(t, backpressure.StateFull, s.State)
+			require.Equal(t, "conn-1", s.ConnectionID)
+			require.Equal(t, "msg-1", s.MsgID)
+			require.True(t, s.Rejected)
+			require.Equal(t, 30, s.RetryAfter)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+	})
+
+	t.Run("problem report not sent", func(t *testing.T) {
+		cfg := config()
+		cfg.Admission.Backpressure = &backpressure.Config{RecipientCap: 10}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		s := &backpressure.Signal{State: backpressure.StateNearCap, MsgID: "msg-1", RetryAfter: 60}
+
+		err = o.backpressure.report(s)
+		require.EqualError(t, err, "anonymous sender")
+
+		s.SenderKey = "unknown"
+
+		err = o.backpressure.report(s)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get sender DID")
+
+		require.NoError(t, o.didConnections.SaveDID("did:adapter", "sender"))
+
+		s.SenderKey = "sender"
+		o.backpressure.vdr = &mockvdri.MockVDRegistry{ResolveErr: errors.New("resolve error")}
+
+		err = o.backpressure.report(s)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get sender destination")
+
+		o.backpressure.vdr = &mockvdri.MockVDRegistry{ResolveValue: mockdiddoc.GetMockDIDDoc(t)}
+		o.backpressure.messenger = &messenger.MockMessenger{
+			SendToDestinationFunc: func(msg service.DIDCommMsgMap, _ string, _ *service.Destination) error {
+				report := &ProblemReport{}
+				require.NoError(t, msg.Decode(report))
+				require.Equal(t, problemQueueNearFull, report.Description.Code)
+
+				return errors.New("send error")
+			},
+		}
+
+		err = o.backpressure.report(s)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "send problem report")
+
+		o.didExchange = &didexchange.MockClient{TheirDID: "did:wallet"}
+		require.Empty(t, o.backpressure.recipientConnectionOf("did:other"))
+	})
+
+	t.Run("init errors", func(t *testing.T) {
+		cfg := config()
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		cfg.Admission.Backpressure = &backpressure.Config{RecipientCap: 10}
+		cfg.Aries.(*mockprovider.Provider).StorageProviderValue = &mockstore.MockStoreProvider{
+			FailNamespace: mediator.Coordination,
+		}
+
+		err = o.initBackpressure(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "backpressure gate")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+		require.Nil(t, o.backpressure.gate)
+	})
+}
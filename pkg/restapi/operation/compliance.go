@@ -0,0 +1,191 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	didstore "github.com/hyperledger/aries-framework-go/pkg/store/did"
+
+	"github.com/trustbloc/hub-router/pkg/compliance"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// API endpoints.
+const (
+	complianceReportsPath = "/compliance/reports"
+	complianceReportPath  = complianceReportsPath + "/{id}"
+)
+
+// ComplianceReportsResp model.
+type ComplianceReportsResp struct {
+	Mode    string               `json:"mode"`
+	Reports []*compliance.Report `json:"reports"`
+}
+
+// complianceHandler validates the inbound messages against the Aries RFCs, and serves the compliance reports of their
+// senders. Its validator is nil if the compliance mode is off.
+type complianceHandler struct {
+	validator *compliance.Validator
+	reports   *compliance.Reports
+	mode      string
+	// senderConnection returns the connection of the sender key, empty if not a connection of the router.
+	senderConnection func(senderKey string) string
+}
+
+// initCompliance initializes the validation of the inbound messages against the Aries RFCs, unless the compliance mode
+// is off.
+func (o *Operation) initCompliance(config *Config) error {
+	o.compliance = &complianceHandler{senderConnection: o.senderConnection}
+
+	if config.Admission.ComplianceMode == "" || config.Admission.ComplianceMode == compliance.ModeOff {
+		return nil
+	}
+
+	var err error
+
+	o.compliance.mode = config.Admission.ComplianceMode
+
+	o.compliance.validator, err = compliance.NewValidator()
+	if err != nil {
+		return fmt.Errorf("compliance validator: %w", err)
+	}
+
+	o.compliance.reports, err = compliance.NewReports(config.Storage.Persistent)
+	if err != nil {
+		return fmt.Errorf("compliance reports: %w", err)
+	}
+
+	if o.didConnections == nil {
+		o.didConnections, err = didstore.NewConnectionStore(config.Aries)
+		if err != nil {
+			return fmt.Errorf("did connection store: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (h *complianceHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(complianceReportsPath, http.MethodGet, h.getComplianceReports),
+		support.NewHTTPHandler(complianceReportPath, http.MethodGet, h.getComplianceReport),
+		support.NewHTTPHandler(complianceReportPath, http.MethodDelete, h.deleteComplianceReport),
+	}
+}
+
+// check validates the message of the envelope against the Aries RFCs, and records the result in the compliance report
+// of its sender. The non-compliant messages are rejected in strict mode, with an error wrapping
+// compliance.ErrNonCompliant.
+func (h *complianceHandler) check(envelope *transport.Envelope) error {
+	if h.validator == nil {
+		return nil
+	}
+
+	result := h.validator.Validate(envelope.Message)
+	rejected := !result.Compliant() && h.mode == compliance.ModeStrict
+
+	peer, connID := compliance.AnonymousPeer, ""
+
+	if len(envelope.FromKey) > 0 {
+		peer = base58.Encode(envelope.FromKey)
+		connID = h.senderConnection(peer)
+	}
+
+	if _, err := h.reports.Record(peer, connID, result, rejected); err != nil {
+		logger.Warnf("failed to record the compliance report : %s", err)
+	}
+
+	if result.Compliant() {
+		return nil
+	}
+
+	logger.Warnf("non-compliant message : peer=%s msgType=%s msgID=%s rejected=%t : %s", peer, result.MsgType,
+		result.MsgID, rejected, strings.Join(result.Errors, "; "))
+
+	if !rejected {
+		return nil
+	}
+
+	return fmt.Errorf("%w : %s", compliance.ErrNonCompliant, strings.Join(result.Errors, "; "))
+}
+
+func (h *complianceHandler) getComplianceReports(rw http.ResponseWriter, _ *http.Request) {
+	if !h.enabled(rw, complianceReportsPath) {
+		return
+	}
+
+	reports, err := h.reports.List()
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get compliance reports - err=%s", err.Error()), complianceReportsPath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, &ComplianceReportsResp{Mode: h.mode, Reports: reports},
+		complianceReportsPath, logger)
+}
+
+func (h *complianceHandler) getComplianceReport(rw http.ResponseWriter, req *http.Request) {
+	if !h.enabled(rw, complianceReportPath) {
+		return
+	}
+
+	report, err := h.reports.Get(mux.Vars(req)["id"])
+	if err != nil {
+		writeComplianceError(rw, err)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, report, complianceReportPath, logger)
+}
+
+// deleteComplianceReport resets the compliance report of the peer, eg: between the test rounds of a plugfest.
+func (h *complianceHandler) deleteComplianceReport(rw http.ResponseWriter, req *http.Request) {
+	if !h.enabled(rw, complianceReportPath) {
+		return
+	}
+
+	if err := h.reports.Delete(mux.Vars(req)["id"]); err != nil {
+		writeComplianceError(rw, err)
+
+		return
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// complianceEnabled writes the error response if the compliance mode is off.
+func (h *complianceHandler) enabled(rw http.ResponseWriter, path string) bool {
+	if h.validator == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "compliance mode not enabled", path, logger)
+
+		return false
+	}
+
+	return true
+}
+
+func writeComplianceError(rw http.ResponseWriter, err error) {
+	if errors.Is(err, compliance.ErrNotFound) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, err.Error(), complianceReportPath, logger)
+
+		return
+	}
+
+	httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+		fmt.Sprintf("failed to access compliance report - err=%s", err.Error()), complianceReportPath, logger)
+}
@@ -0,0 +1,165 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/compliance"
+)
+
+func TestCompliance(t *testing.T) {
+	compliant := &transport.Envelope{
+		Message: []byte(`{"@id":"1","@type":"https://didcomm.org/trust_ping/1.0/ping"}`),
+		FromKey: []byte("wallet-key"),
+	}
+
+	nonCompliant := &transport.Envelope{
+		Message: []byte(`{"@id":"2","@type":"https://didcomm.org/routing/1.0/forward","msg":{}}`),
+		FromKey: []byte("wallet-key"),
+	}
+
+	peer := base58.Encode(compliant.FromKey)
+
+	newOperation := func(t *testing.T, mode string) *Operation {
+		t.Helper()
+
+		cfg := config()
+		cfg.Admission.ComplianceMode = mode
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		return o
+	}
+
+	getReport := func(o *Operation, id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.compliance.getComplianceReport(w, mux.SetURLVars(
+			httptest.NewRequest(http.MethodGet, complianceReportsPath+"/"+id, nil), map[string]string{"id": id}))
+
+		return w
+	}
+
+	t.Run("report mode", func(t *testing.T) {
+		o := newOperation(t, compliance.ModeReport)
+
+		require.NoError(t, o.AdmitEnvelope(withHeader(compliant)))
+		require.NoError(t, o.AdmitEnvelope(withHeader(nonCompliant)))
+		require.NoError(t, o.AdmitEnvelope(withHeader(&transport.Envelope{Message: []byte(`{"@id":"3"}`)})))
+
+		w := httptest.NewRecorder()
+		o.compliance.getComplianceReports(w, httptest.NewRequest(http.MethodGet, complianceReportsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		resp := &ComplianceReportsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, compliance.ModeReport, resp.Mode)
+		require.Len(t, resp.Reports, 2)
+
+		w = getReport(o, peer)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		report := &compliance.Report{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), report))
+		require.False(t, report.Compliant)
+		require.Equal(t, 2, report.Checked)
+		require.Equal(t, 1, report.NonCompliant)
+		require.Zero(t, report.Rejected)
+		require.Len(t, report.Violations, 1)
+		require.Equal(t, "Aries RFC 0094: Cross-Domain Messaging", report.Violations[0].RFC)
+
+		w = getReport(o, compliance.AnonymousPeer)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = httptest.NewRecorder()
+		o.compliance.deleteComplianceReport(w, mux.SetURLVars(
+			httptest.NewRequest(http.MethodDelete, complianceReportsPath+"/"+peer, nil), map[string]string{"id": peer}))
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		require.Equal(t, http.StatusNotFound, getReport(o, peer).Code)
+
+		w = httptest.NewRecorder()
+		o.compliance.deleteComplianceReport(w, mux.SetURLVars(
+			httptest.NewRequest(http.MethodDelete, complianceReportsPath+"/"+peer, nil), map[string]string{"id": peer}))
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("strict mode", func(t *testing.T) {
+		o := newOperation(t, compliance.ModeStrict)
+
+		require.NoError(t, o.AdmitEnvelope(withHeader(compliant)))
+
+		err := o.AdmitEnvelope(withHeader(nonCompliant))
+		require.ErrorIs(t, err, compliance.ErrNonCompliant)
+		require.Contains(t, err.Error(), "to is required")
+
+		report, err := o.compliance.reports.Get(peer)
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Rejected)
+		require.True(t, report.Violations[0].Rejected)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		for _, mode := range []string{"", compliance.ModeOff} {
+			o := newOperation(t, mode)
+			require.Nil(t, o.compliance.validator)
+			require.NoError(t, o.AdmitEnvelope(withHeader(nonCompliant)))
+
+			w := httptest.NewRecorder()
+			o.compliance.getComplianceReports(w, httptest.NewRequest(http.MethodGet, complianceReportsPath, nil))
+			require.Equal(t, http.StatusNotFound, w.Code)
+			require.Contains(t, w.Body.String(), "compliance mode not enabled")
+
+			require.Equal(t, http.StatusNotFound, getReport(o, peer).Code)
+
+			w = httptest.NewRecorder()
+			o.compliance.deleteComplianceReport(w, httptest.NewRequest(http.MethodDelete, complianceReportsPath+"/"+peer, nil))
+			require.Equal(t, http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		cfg := config()
+		cfg.Admission.ComplianceMode = compliance.ModeReport
+		p := mockstore.NewMockStoreProvider()
+		p.FailNamespace = "compliance"
+		cfg.Storage.Persistent = p
+
+		_, err := New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "compliance reports")
+
+		o := newOperation(t, compliance.ModeStrict)
+
+		o.compliance.reports, err = compliance.NewReports(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrGet:   errors.New("get error"),
+			ErrQuery: errors.New("query error"),
+		}))
+		require.NoError(t, err)
+
+		require.NoError(t, o.AdmitEnvelope(withHeader(compliant)))
+		require.ErrorIs(t, o.AdmitEnvelope(withHeader(nonCompliant)), compliance.ErrNonCompliant)
+
+		w := httptest.NewRecorder()
+		o.compliance.getComplianceReports(w, httptest.NewRequest(http.MethodGet, complianceReportsPath, nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+
+		require.Equal(t, http.StatusInternalServerError, getReport(o, peer).Code)
+	})
+}
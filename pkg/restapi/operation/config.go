@@ -0,0 +1,184 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+
+	"github.com/trustbloc/hub-router/pkg/alert"
+	"github.com/trustbloc/hub-router/pkg/anomaly"
+	"github.com/trustbloc/hub-router/pkg/backpressure"
+	"github.com/trustbloc/hub-router/pkg/backup"
+	"github.com/trustbloc/hub-router/pkg/compression"
+	"github.com/trustbloc/hub-router/pkg/connpool"
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/dedup"
+	"github.com/trustbloc/hub-router/pkg/digest"
+	"github.com/trustbloc/hub-router/pkg/inbound"
+	"github.com/trustbloc/hub-router/pkg/incident"
+	"github.com/trustbloc/hub-router/pkg/limits"
+	"github.com/trustbloc/hub-router/pkg/mailbox"
+	"github.com/trustbloc/hub-router/pkg/metering"
+	"github.com/trustbloc/hub-router/pkg/ordering"
+	"github.com/trustbloc/hub-router/pkg/pickup"
+	"github.com/trustbloc/hub-router/pkg/poptoken"
+	"github.com/trustbloc/hub-router/pkg/queue"
+	"github.com/trustbloc/hub-router/pkg/residency"
+	"github.com/trustbloc/hub-router/pkg/slowconsumer"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+	"github.com/trustbloc/hub-router/pkg/terms"
+	"github.com/trustbloc/hub-router/pkg/upstream"
+)
+
+// TransportConfig holds the transports of the Aries agent the operation hooks into.
+type TransportConfig struct {
+	// WSOutbound is the WebSocket outbound transport of the Aries agent, timed to detect the slow consumers.
+	WSOutbound *slowconsumer.Outbound
+	// OutboundPool pools the outbound HTTP connections of the Aries agent, its stats are returned by the diagnostics.
+	OutboundPool *connpool.Transport
+	// Inbound are the chains of the Aries inbound transports : the operation handles their stages admitting and
+	// verifying the envelopes, observing the sockets held open by the wallets, relaying the nested forwards and gating
+	// the forwards. The messages queued for the wallets holding a socket open are delivered on the socket once it is
+	// open.
+	Inbound []*inbound.Middleware
+	// Packager of the Aries agent, packs the forwards to the wallets handed over for the routing keys of their new
+	// mediator.
+	Packager transport.Packager
+}
+
+// AdmissionConfig holds the settings of the stages of the Inbound transports admitting the envelopes.
+type AdmissionConfig struct {
+	// KeyPinning pins the sender keys per connection, the envelopes are verified by the Inbound transports.
+	KeyPinning bool
+	// MultiHopForward relays the nested forward messages addressed to another mediator, through the Inbound transports.
+	MultiHopForward bool
+	// ComplianceMode validates the inbound messages against the Aries RFCs, reporting the non-compliant peers
+	// (compliance.ModeReport) or also rejecting their messages (compliance.ModeStrict); off if empty.
+	ComplianceMode string
+	// Backpressure rejects the forwards addressed to a full queue through the Inbound transports, and signals the
+	// senders to pause.
+	Backpressure *backpressure.Config
+	// SuppressionWindow is the time the forwards delivered to the wallets are tracked for, in the transient storage,
+	// to suppress their duplicates through the Inbound transports. The duplicates aren't suppressed if zero.
+	SuppressionWindow time.Duration
+	// HandoverGracePeriod is the time the messages of the wallets handed over to another mediator are forwarded to it,
+	// instead of being queued, through the Inbound transports. The wallets can't be handed over if zero.
+	HandoverGracePeriod time.Duration
+	// Limits caps the DID exchanges in progress, and the pickups handled concurrently by the Pickups limit of the
+	// inbound transports.
+	Limits  *limits.Config
+	Pickups *limits.Pickups
+}
+
+// QueueConfig holds the pickup mailboxes, and the thresholds the queues are watched against.
+type QueueConfig struct {
+	// Pickup is the queue of the messages picked up by the wallets, served by the pickup.Service registered with the
+	// Aries agent; a queue is opened in the persistent storage if nil.
+	Pickup        *pickup.Queue
+	Watermarks    *queue.Config
+	SlowConsumers *slowconsumer.Config
+	// Compression compresses the pickup mailboxes, its compression ratio is returned by the diagnostics.
+	Compression *compression.Provider
+	// Dedup stores the duplicate payloads of the pickup mailboxes once, its stats are returned by the diagnostics.
+	Dedup *dedup.Provider
+	// Ordering delivers the messages of the pickup mailboxes in sequence, its stats are returned by the diagnostics.
+	Ordering *ordering.Sequencer
+	// Mailboxes bounds the pickup mailboxes, whose queues are inspected and purged through the REST API.
+	Mailboxes *mailbox.Provider
+}
+
+// MediationConfig holds the settings of the mediation granted to the wallets.
+type MediationConfig struct {
+	AutoGrant       bool
+	PresenceTimeout time.Duration
+	// KeyReusePolicy is the policy applied when a router key is reused across connections (keyusage.PolicyAudit if
+	// empty).
+	KeyReusePolicy string
+	// GrantTransfer lets the wallets recovered on a new device transfer the mediation of their previous DID to the
+	// connection of the new device, with a DIDComm message proving the control of the keys of the previous DID.
+	GrantTransfer bool
+	// RecoveryTokenTTL is the lifetime of the recovery tokens issued to the wallet backends for the grant transfers,
+	// recovery.DefaultTokenTTL if zero.
+	RecoveryTokenTTL time.Duration
+	// RecoveryTokenSecret signs the recovery tokens; a secret is generated and stored, shared by the router instances,
+	// if not set.
+	RecoveryTokenSecret []byte
+	// AdvertisedEndpoint is the externally reachable URL of the router, advertised in its DID docs and mediation grants
+	// instead of the endpoint of its inbound transports, eg: behind a load balancer or a NAT gateway.
+	AdvertisedEndpoint string
+	// Endpoints are the endpoints advertised, in order of priority, in the invitations and the router DID docs, eg: the
+	// external WebSocket endpoint first for the wallets honoring the first service only. The first endpoint is advertised
+	// in the mediation grants, unless AdvertisedEndpoint is set. The default service of the agent is advertised if nil.
+	Endpoints []string
+	// Upstream is the mediator the router requests mediation from, when it runs behind NAT without a public endpoint :
+	// its routing is advertised in the router DID docs and mediation grants. The router is reached directly if nil.
+	Upstream *upstream.Config
+}
+
+// InvitationConfig holds the settings of the invitations issued by the router.
+type InvitationConfig struct {
+	// Tokens requires a token issued by the wallet backend to create the invitations, and binds the connections to
+	// the subject of the token.
+	Tokens *poptoken.Config
+	// Terms are attached to the invitations and sent with the mediation grants.
+	Terms *terms.Terms
+	// Branding of the invitations issued under the API key of each tenant, keyed by tenant ID.
+	Branding map[string]*tenant.Branding
+	// Label of the invitations issued without tenant branding, defaults to hub-router.
+	Label string
+}
+
+// TenancyConfig holds the settings of the tenants sharing the router.
+type TenancyConfig struct {
+	// APIKeys authenticate the REST API requests, the tenant keys are scoped to the wallets of their tenant. The REST
+	// API is open if nil.
+	APIKeys *tenant.Keys
+	// Residency pins the wallets to the region of their tenant, or to the region set with the API.
+	Residency *residency.Router
+	// Metering closes the hourly metering periods, recording the usage of the tenants, and publishes the records to
+	// the MeteringSink if any.
+	Metering     bool
+	MeteringSink metering.Sink
+	// UserAgentTenants are the tenants opted in the capture of the user agent and app version reported by their
+	// wallets at registration, all of them with useragent.AllTenants. Nothing is captured if empty.
+	UserAgentTenants []string
+}
+
+// ReportingConfig holds the settings of the reports of the router activity : stats, history, anomalies, digests,
+// alerts, incidents and exports.
+type ReportingConfig struct {
+	StatsRetention time.Duration
+	// HistoryLimit is the number of changes kept in the history of each connection, history.DefaultLimit if zero.
+	HistoryLimit int
+	// Anomalies analyzes the routed traffic hourly, and reports the unusual routing patterns. The traffic isn't
+	// analyzed if nil.
+	Anomalies *anomaly.Config
+	// Digests delivers the digests of the router activity to the webhooks, and to the DigestSinks, on schedule.
+	Digests     *digest.Config
+	DigestSinks []digest.Sink
+	// AlertChannels are sent the critical alerts : the queue watermarks crossed, the storage failures, and the delivery
+	// SLO breaches of the digests.
+	AlertChannels []alert.Channel
+	// Incidents is the config of the incident timeline of the health checks, the alerts and the error bursts, the
+	// defaults apply if nil.
+	Incidents *incident.Config
+	// BackupEncrypter encrypts the exports (audit, stats and metering) with the public keys of the operators.
+	BackupEncrypter *backup.Encrypter
+}
+
+// DeadLetterConfig holds the settings of the messages failed.
+type DeadLetterConfig struct {
+	// Retention deletes the dead-letter entries not updated for the given period, kept forever if zero.
+	Retention time.Duration
+	// Archiver archives the dead-letter entries once dead-lettered, and before they expire.
+	Archiver deadletter.Archiver
+	// TransientRetryAfter is the time the peers are advised to wait before retrying the messages failed on transient
+	// errors, retryadvice.DefaultRetryAfter if zero.
+	TransientRetryAfter time.Duration
+}
@@ -0,0 +1,259 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	didexdsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/didexchange"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/peer"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/mediation"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// API endpoints.
+const (
+	connectionResolvePath = connectionsPath + "/resolve"
+)
+
+const (
+	maxConnectionSize        = 64 * 1024
+	maxResolveConnectionSize = 4096
+)
+
+var errInvalidConnectionReq = errors.New("invalid connection request")
+
+// ConnectionReq model : the DID doc of the peer to connect to, or its public DID.
+type ConnectionReq struct {
+	DIDDoc json.RawMessage `json:"didDoc,omitempty"`
+	DID    string          `json:"did,omitempty"`
+	// Label of the agent, optional.
+	Label string `json:"label,omitempty"`
+	// UserAgent and AppVersion reported by the wallet to its backend, optional : captured for the tenants opted in.
+	UserAgent  string `json:"userAgent,omitempty"`
+	AppVersion string `json:"appVersion,omitempty"`
+}
+
+// ConnectionResp model : the router DID doc of the connection and its routing, as returned to create-conn-req.
+type ConnectionResp struct {
+	ConnectionID     string          `json:"connectionID"`
+	DIDDoc           json.RawMessage `json:"didDoc"`
+	ServiceEndpoint  string          `json:"serviceEndpoint,omitempty"`
+	RoutingKeys      []string        `json:"routingKeys,omitempty"`
+	MediationGranted bool            `json:"mediationGranted,omitempty"`
+}
+
+// ResolveConnectionReq model : the public DID of the service to connect to, eg: did:web or did:orb.
+type ResolveConnectionReq struct {
+	DID string `json:"did"`
+	// Label of the service, optional.
+	Label string `json:"label,omitempty"`
+}
+
+// ResolveConnectionResp model : the connection of the DID exchange in progress.
+type ResolveConnectionResp struct {
+	ConnectionID string `json:"connectionID"`
+	DID          string `json:"did"`
+}
+
+// MediationClientsResp model.
+type MediationClientsResp struct {
+	Connections []*MediationClient `json:"connections"`
+}
+
+// MediationClient model : the wallet or agent registered for mediation, and when it was last seen on its connection.
+type MediationClient struct {
+	*mediation.Client
+	LastSeen *time.Time `json:"lastSeen,omitempty"`
+}
+
+// getConnections returns the wallets and agents registered for mediation with the router, most recently registered
+// first.
+func (o *Operation) getConnections(rw http.ResponseWriter, _ *http.Request) {
+	clients, err := o.mediationClients.List()
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to list connections - err=%s", err.Error()), connectionsPath, logger)
+
+		return
+	}
+
+	resp := &MediationClientsResp{Connections: []*MediationClient{}}
+
+	for _, c := range clients {
+		client := &MediationClient{Client: c}
+
+		if r, err := o.presence.Get(c.ConnectionID); err == nil {
+			client.LastSeen = &r.LastSeen
+		}
+
+		resp.Connections = append(resp.Connections, client)
+	}
+
+	httputil.WriteResponseWithLog(rw, resp, connectionsPath, logger)
+}
+
+// postConnection creates the connection with the DID doc or public DID of the request directly, without the DIDComm
+// bootstrap : the REST analogue of create-conn-req, for the backend adapters co-located with the router.
+func (o *Operation) postConnection(rw http.ResponseWriter, req *http.Request) {
+	corrID := correlationID(rw, req)
+
+	if err := o.shedLoad(); err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusServiceUnavailable, err.Error(), connectionsPath, logger)
+
+		return
+	}
+
+	didDoc, connReq, err := o.connectionDIDDoc(rw, req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), connectionsPath, logger)
+
+		return
+	}
+
+	data, err := o.establishConnection("", didDoc, connReq.Label, &correlation.Record{CorrelationID: corrID},
+		requestActor(req))
+	if err != nil {
+		writeConnectionError(rw, err, connectionsPath)
+
+		return
+	}
+
+	o.captureUserAgent("", data.ConnectionID, connReq.UserAgent, connReq.AppVersion)
+
+	rw.WriteHeader(http.StatusCreated)
+
+	httputil.WriteResponseWithLog(rw, &ConnectionResp{
+		ConnectionID:     data.ConnectionID,
+		DIDDoc:           data.DIDDoc,
+		ServiceEndpoint:  data.ServiceEndpoint,
+		RoutingKeys:      data.RoutingKeys,
+		MediationGranted: data.MediationGranted,
+	}, connectionsPath, logger)
+}
+
+// connectionDIDDoc returns the connection request and its DID doc, resolving its public DID if no DID doc is given.
+func (o *Operation) connectionDIDDoc(rw http.ResponseWriter, req *http.Request) (*did.Doc, *ConnectionReq, error) {
+	connReq := &ConnectionReq{}
+
+	err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxConnectionSize)).Decode(connReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w : %s", errInvalidConnectionReq, err)
+	}
+
+	switch {
+	case len(connReq.DIDDoc) > 0 && connReq.DID != "", len(connReq.DIDDoc) == 0 && connReq.DID == "":
+		return nil, nil, fmt.Errorf("%w : expected either didDoc or did", errInvalidConnectionReq)
+	case len(connReq.DIDDoc) > 0:
+		didDoc, err := did.ParseDocument(connReq.DIDDoc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w : parse did doc : %s", errInvalidConnectionReq, err)
+		}
+
+		return didDoc, connReq, nil
+	}
+
+	docResolution, err := o.vdriRegistry.Resolve(connReq.DID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w : resolve did : %s", errInvalidConnectionReq, err)
+	}
+
+	return docResolution.DIDDocument, connReq, nil
+}
+
+// postResolveConnection resolves the public DID of the request and starts the DID exchange with it through an implicit
+// invitation, for the services publishing a resolvable DID instead of out-of-band invitations. The exchange completes
+// asynchronously : the connection event is published once it is completed.
+func (o *Operation) postResolveConnection(rw http.ResponseWriter, req *http.Request) {
+	corrID := correlationID(rw, req)
+
+	if err := o.shedLoad(); err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusServiceUnavailable, err.Error(), connectionResolvePath, logger)
+
+		return
+	}
+
+	resolveReq, err := o.resolveConnectionReq(rw, req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), connectionResolvePath, logger)
+
+		return
+	}
+
+	if err = o.checkConnPolicy("", resolveReq.DID); err != nil {
+		writeConnectionError(rw, err, connectionResolvePath)
+
+		return
+	}
+
+	connID, err := o.didExchange.CreateImplicitInvitation(resolveReq.Label, resolveReq.DID)
+	if err != nil {
+		writeConnectionError(rw, fmt.Errorf("implicit invitation : %w", err), connectionResolvePath)
+
+		return
+	}
+
+	o.recordAudit(&audit.Entry{
+		Type: audit.DIDExchangeAction, ConnectionID: connID, MsgType: didexdsvc.RequestMsgType,
+		Detail: fmt.Sprintf("implicit invitation did=%s", resolveReq.DID), Actor: requestActor(req),
+	})
+	o.correlate(&correlation.Record{ConnectionID: connID, CorrelationID: corrID, MsgType: didexdsvc.RequestMsgType})
+
+	rw.WriteHeader(http.StatusAccepted)
+
+	httputil.WriteResponseWithLog(rw, &ResolveConnectionResp{ConnectionID: connID, DID: resolveReq.DID},
+		connectionResolvePath, logger)
+}
+
+// resolveConnectionReq returns the resolve request, once its public DID is resolved to a DID doc with a DIDComm
+// service.
+func (o *Operation) resolveConnectionReq(rw http.ResponseWriter, req *http.Request) (*ResolveConnectionReq, error) {
+	resolveReq := &ResolveConnectionReq{}
+
+	err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxResolveConnectionSize)).Decode(resolveReq)
+	if err != nil {
+		return nil, fmt.Errorf("%w : %s", errInvalidConnectionReq, err)
+	}
+
+	if !strings.HasPrefix(resolveReq.DID, "did:") || strings.HasPrefix(resolveReq.DID, "did:"+peer.DIDMethod+":") {
+		return nil, fmt.Errorf("%w : not a public did : %s", errInvalidConnectionReq, resolveReq.DID)
+	}
+
+	docResolution, err := o.vdriRegistry.Resolve(resolveReq.DID)
+	if err != nil {
+		return nil, fmt.Errorf("%w : resolve did : %s", errInvalidConnectionReq, err)
+	}
+
+	if _, err = service.CreateDestination(docResolution.DIDDocument); err != nil {
+		return nil, fmt.Errorf("%w : no didcomm service : %s", errInvalidConnectionReq, err)
+	}
+
+	return resolveReq, nil
+}
+
+// writeConnectionError writes the response of the connection rejected by the policy, or failed to be created.
+func writeConnectionError(rw http.ResponseWriter, err error, path string) {
+	switch {
+	case errors.Is(err, errRateLimited):
+		httputil.WriteErrorResponseWithLog(rw, http.StatusTooManyRequests, err.Error(), path, logger)
+	case problemCode(err) == problemPolicyRejected:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusForbidden, err.Error(), path, logger)
+	default:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to create connection - err=%s", err.Error()), path, logger)
+	}
+}
@@ -0,0 +1,327 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	mediatordsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+	mockroute "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/protocol/mediator"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	mockvdri "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/history"
+	"github.com/trustbloc/hub-router/pkg/internal/mock/didexchange"
+	"github.com/trustbloc/hub-router/pkg/mediation"
+	"github.com/trustbloc/hub-router/pkg/policy"
+	"github.com/trustbloc/hub-router/pkg/presence"
+)
+
+func TestPostConnection(t *testing.T) {
+	postConnection := func(o *Operation, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.postConnection(w, httptest.NewRequest(http.MethodPost, connectionsPath, strings.NewReader(body)))
+
+		return w
+	}
+
+	didDocReq := func(t *testing.T) string {
+		t.Helper()
+
+		didDocBytes, err := mockdiddoc.GetMockDIDDoc(t).JSONBytes()
+		require.NoError(t, err)
+
+		return `{"didDoc":` + string(didDocBytes) + `}`
+	}
+
+	t.Run("with a did doc", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := postConnection(o, didDocReq(t))
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.NotEmpty(t, w.Header().Get(correlationIDHeader))
+
+		resp := &ConnectionResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.NotEmpty(t, resp.ConnectionID)
+		require.NotEmpty(t, resp.DIDDoc)
+		require.Equal(t, o.endpoint, resp.ServiceEndpoint)
+		require.Len(t, resp.RoutingKeys, 1)
+
+		changes, err := o.history.Get(resp.ConnectionID)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		require.Equal(t, audit.ConnectionCreated, changes[0].Type)
+		require.Equal(t, history.ActorOperator, changes[0].Actor)
+	})
+
+	t.Run("with a public did", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.vdriRegistry = &mockvdri.MockVDRegistry{ResolveValue: mockdiddoc.GetMockDIDDoc(t)}
+
+		w := postConnection(o, `{"did":"did:example:adapter"}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		o.vdriRegistry = &mockvdri.MockVDRegistry{ResolveErr: errors.New("resolve error")}
+
+		w = postConnection(o, `{"did":"did:example:adapter"}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "resolve did")
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		for body, expected := range map[string]string{
+			`{`:  "invalid connection request",
+			`{}`: "expected either didDoc or did",
+			`{"didDoc":{},"did":"did:example:adapter"}`: "expected either didDoc or did",
+			`{"didDoc":"invalid"}`:                      "parse did doc",
+		} {
+			w := postConnection(o, body)
+			require.Equal(t, http.StatusBadRequest, w.Code, body)
+			require.Contains(t, w.Body.String(), expected, body)
+		}
+	})
+
+	t.Run("rejected by policy", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		_, _, err = o.policies.store.Put(&policy.Document{Allowlists: &policy.Allowlists{DIDMethods: []string{"key"}}}, false)
+		require.NoError(t, err)
+
+		w := postConnection(o, didDocReq(t))
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Contains(t, w.Body.String(), "did method not allowed")
+
+		_, _, err = o.policies.store.Put(&policy.Document{RateLimits: &policy.RateLimits{ConnectionsPerMinute: 1}}, false)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusCreated, postConnection(o, didDocReq(t)).Code)
+		require.Equal(t, http.StatusTooManyRequests, postConnection(o, didDocReq(t)).Code)
+	})
+
+	t.Run("create connection error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.didExchange = &didexchange.MockClient{CreateConnErr: errors.New("create error")}
+
+		w := postConnection(o, didDocReq(t))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "failed to create connection")
+	})
+}
+
+func TestPostResolveConnection(t *testing.T) {
+	postResolveConnection := func(o *Operation, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.postResolveConnection(w, httptest.NewRequest(http.MethodPost, connectionResolvePath, strings.NewReader(body)))
+
+		return w
+	}
+
+	newOperation := func(t *testing.T) *Operation {
+		t.Helper()
+
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.didExchange = &didexchange.MockClient{}
+		o.vdriRegistry = &mockvdri.MockVDRegistry{ResolveValue: mockdiddoc.GetMockDIDDoc(t)}
+
+		return o
+	}
+
+	t.Run("implicit invitation", func(t *testing.T) {
+		o := newOperation(t)
+
+		w := postResolveConnection(o, `{"did":"did:web:service.example.com","label":"service"}`)
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+
+		resp := &ResolveConnectionResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.NotEmpty(t, resp.ConnectionID)
+		require.Equal(t, "did:web:service.example.com", resp.DID)
+
+		changes, err := o.history.Get(resp.ConnectionID)
+		require.NoError(t, err)
+		require.Len(t, changes, 1)
+		require.Equal(t, audit.DIDExchangeAction, changes[0].Type)
+		require.Equal(t, "implicit invitation did=did:web:service.example.com", changes[0].Detail)
+		require.Equal(t, history.ActorOperator, changes[0].Actor)
+
+		records, err := o.correlations.Find(correlation.CorrelationIDTag, w.Header().Get(correlationIDHeader))
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		require.Equal(t, resp.ConnectionID, records[0].ConnectionID)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		o := newOperation(t)
+
+		for body, expected := range map[string]string{
+			`{`:                            "invalid connection request",
+			`{"did":"service"}`:            "not a public did",
+			`{"did":"did:peer:1zQmZkgBY"}`: "not a public did",
+		} {
+			w := postResolveConnection(o, body)
+			require.Equal(t, http.StatusBadRequest, w.Code, body)
+			require.Contains(t, w.Body.String(), expected, body)
+		}
+
+		o.vdriRegistry = &mockvdri.MockVDRegistry{ResolveErr: errors.New("resolve error")}
+
+		w := postResolveConnection(o, `{"did":"did:web:service.example.com"}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "resolve did")
+
+		o.vdriRegistry = &mockvdri.MockVDRegistry{ResolveValue: &did.Doc{ID: "did:web:service.example.com"}}
+
+		w = postResolveConnection(o, `{"did":"did:web:service.example.com"}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "no didcomm service")
+	})
+
+	t.Run("rejected by policy", func(t *testing.T) {
+		o := newOperation(t)
+
+		_, _, err := o.policies.store.Put(&policy.Document{
+			Allowlists: &policy.Allowlists{DIDMethods: []string{"orb"}},
+		}, false)
+		require.NoError(t, err)
+
+		w := postResolveConnection(o, `{"did":"did:web:service.example.com"}`)
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Contains(t, w.Body.String(), "did method not allowed")
+	})
+
+	t.Run("implicit invitation error", func(t *testing.T) {
+		o := newOperation(t)
+		o.didExchange = &didexchange.MockClient{ImplicitInvErr: errors.New("invitation error")}
+
+		w := postResolveConnection(o, `{"did":"did:web:service.example.com"}`)
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "implicit invitation")
+	})
+}
+
+func TestGetConnections(t *testing.T) {
+	getConnections := func(t *testing.T, o *Operation) *MediationClientsResp {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		o.getConnections(w, httptest.NewRequest(http.MethodGet, connectionsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		resp := &MediationClientsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+
+		return resp
+	}
+
+	t.Run("mediation clients", func(t *testing.T) {
+		cfg := config()
+		cfg.Mediation.AutoGrant = true
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.routeSvc = &mockroute.MockMediatorSvc{}
+
+		require.Empty(t, getConnections(t, o).Connections)
+
+		didDoc := mockdiddoc.GetMockDIDDoc(t)
+
+		didDocBytes, err := didDoc.JSONBytes()
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.postConnection(w, httptest.NewRequest(http.MethodPost, connectionsPath,
+			strings.NewReader(`{"didDoc":`+string(didDocBytes)+`,"label":"Acme Agent"}`)))
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		created := &ConnectionResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), created))
+		require.True(t, created.MediationGranted)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-2", State: connection.StateNameCompleted, ThreadID: "thid-2",
+			MyDID: "did:router", TheirDID: "did:wallet2", TheirLabel: "Bob's Wallet", Namespace: connection.MyNSPrefix,
+		}))
+
+		o.registerMediationClient("conn-2", "", "",
+			grantRoutingKeys(mediatordsvc.Options{RoutingKeys: []string{"key-2"}}))
+		o.registerMediationClient("conn-3", "", "", nil)
+		o.registerMediationClient("", "did:wallet4", "", nil)
+		o.seen("conn-2", presence.SourceMediation)
+
+		resp := getConnections(t, o)
+		require.Len(t, resp.Connections, 2)
+
+		require.Equal(t, "conn-2", resp.Connections[0].ConnectionID)
+		require.Equal(t, "did:wallet2", resp.Connections[0].TheirDID)
+		require.Equal(t, "Bob's Wallet", resp.Connections[0].Label)
+		require.Equal(t, []string{"key-2"}, resp.Connections[0].RoutingKeys)
+		require.NotNil(t, resp.Connections[0].LastSeen)
+
+		require.Equal(t, created.ConnectionID, resp.Connections[1].ConnectionID)
+		require.Equal(t, didDoc.ID, resp.Connections[1].TheirDID)
+		require.Equal(t, "Acme Agent", resp.Connections[1].Label)
+		require.Equal(t, created.RoutingKeys, resp.Connections[1].RoutingKeys)
+		require.False(t, resp.Connections[1].Registered.IsZero())
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.mediationClients, err = mediation.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}))
+		require.NoError(t, err)
+
+		// the connection is created anyway
+		o.registerMediationClient("conn-1", "did:wallet1", "", nil)
+
+		w := httptest.NewRecorder()
+		o.getConnections(w, httptest.NewRequest(http.MethodGet, connectionsPath, nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "failed to list connections")
+	})
+
+	t.Run("init error", func(t *testing.T) {
+		cfg := config()
+		persistent := mockstore.NewMockStoreProvider()
+		persistent.FailNamespace = "mediationclient"
+		cfg.Storage.Persistent = persistent
+
+		_, err := New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "mediation client registry")
+	})
+}
@@ -0,0 +1,209 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+// API endpoints.
+const (
+	connectionWebhookPath = connectionsPath + "/{id}/webhook"
+)
+
+const maxConnectionWebhookSize = 4096
+
+// ConnectionWebhookReq model : the URL the notifications about the connection are posted to.
+type ConnectionWebhookReq struct {
+	URL string `json:"url"`
+}
+
+// ConnectionWebhookResp model.
+type ConnectionWebhookResp struct {
+	ConnectionID string `json:"connectionID"`
+	URL          string `json:"url"`
+	Revision     int    `json:"revision"`
+}
+
+// putConnectionWebhook registers the webhook of the connection, receiving the notifications about the connection
+// only, eg: the adapter that created it. It replaces the previous webhook of the connection.
+func (o *Operation) putConnectionWebhook(rw http.ResponseWriter, req *http.Request) {
+	webhooks, connID, ok := o.connectionWebhooks(rw, req)
+	if !ok {
+		return
+	}
+
+	webhookReq := &ConnectionWebhookReq{}
+
+	err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxConnectionWebhookSize)).Decode(webhookReq)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, fmt.Sprintf("invalid request : %s", err),
+			connectionWebhookPath, logger)
+
+		return
+	}
+
+	revision, ok := webhookPrecondition(rw, req, webhooks, connID)
+	if !ok {
+		return
+	}
+
+	registration, err := webhooks.RegisterIf(connID, webhookReq.URL, revision)
+
+	switch {
+	case errors.Is(err, webhook.ErrInvalidURL):
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), connectionWebhookPath, logger)
+
+		return
+	case errors.Is(err, webhook.ErrRevisionConflict):
+		writePreconditionFailed(rw, err, connectionWebhookPath)
+
+		return
+	case err != nil:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to register webhook - err=%s", err.Error()), connectionWebhookPath, logger)
+
+		return
+	}
+
+	o.recordAudit(&audit.Entry{
+		Type: audit.WebhookChanged, ConnectionID: connID, Detail: fmt.Sprintf("url=%s", webhookReq.URL),
+		Actor: requestActor(req),
+	})
+
+	setETag(rw, registration.Revision)
+	httputil.WriteResponseWithLog(rw, &ConnectionWebhookResp{
+		ConnectionID: connID, URL: registration.URL, Revision: registration.Revision,
+	}, connectionWebhookPath, logger)
+}
+
+// getConnectionWebhook returns the webhook of the connection, 404 if none is registered.
+func (o *Operation) getConnectionWebhook(rw http.ResponseWriter, req *http.Request) {
+	webhooks, connID, ok := o.connectionWebhooks(rw, req)
+	if !ok {
+		return
+	}
+
+	registration, err := webhooks.Lookup(connID)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get webhook - err=%s", err.Error()), connectionWebhookPath, logger)
+
+		return
+	}
+
+	if registration == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound,
+			fmt.Sprintf("no webhook registered for connection : %s", connID), connectionWebhookPath, logger)
+
+		return
+	}
+
+	setETag(rw, registration.Revision)
+	httputil.WriteResponseWithLog(rw, &ConnectionWebhookResp{
+		ConnectionID: connID, URL: registration.URL, Revision: registration.Revision,
+	}, connectionWebhookPath, logger)
+}
+
+// deleteConnectionWebhook removes the webhook of the connection : the notifications about the connection are only
+// posted to the webhook URLs again.
+func (o *Operation) deleteConnectionWebhook(rw http.ResponseWriter, req *http.Request) {
+	webhooks, connID, ok := o.connectionWebhooks(rw, req)
+	if !ok {
+		return
+	}
+
+	revision, ok := webhookPrecondition(rw, req, webhooks, connID)
+	if !ok {
+		return
+	}
+
+	err := webhooks.RemoveIf(connID, revision)
+
+	switch {
+	case errors.Is(err, webhook.ErrRevisionConflict):
+		writePreconditionFailed(rw, err, connectionWebhookPath)
+
+		return
+	case err != nil:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to remove webhook - err=%s", err.Error()), connectionWebhookPath, logger)
+
+		return
+	}
+
+	o.recordAudit(&audit.Entry{
+		Type: audit.WebhookChanged, ConnectionID: connID, Detail: "removed", Actor: requestActor(req),
+	})
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// connectionWebhooks returns the connection webhooks and the connection of the request, or writes the error response
+// if the connection webhooks aren't enabled or the connection isn't found.
+func (o *Operation) connectionWebhooks(rw http.ResponseWriter, req *http.Request) (*webhook.ConnectionWebhooks,
+	string, bool) {
+	webhooks := o.webhook.ConnectionWebhooks()
+	if webhooks == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "connection webhooks not enabled",
+			connectionWebhookPath, logger)
+
+		return nil, "", false
+	}
+
+	connID := mux.Vars(req)["id"]
+
+	_, err := o.connections.GetConnectionRecord(connID)
+	if err != nil || !o.visible(tenant.FromContext(req.Context()), connID) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, fmt.Sprintf("connection not found : %s", connID),
+			connectionWebhookPath, logger)
+
+		return nil, "", false
+	}
+
+	return webhooks, connID, true
+}
+
+// webhookPrecondition returns the revision of the connection webhook the change of the request is conditional on,
+// anyRevision if not conditional, or writes the error response.
+func webhookPrecondition(rw http.ResponseWriter, req *http.Request, webhooks *webhook.ConnectionWebhooks,
+	connID string) (int, bool) {
+	p, err := ifMatch(req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), connectionWebhookPath, logger)
+
+		return 0, false
+	}
+
+	if p == nil {
+		return anyRevision, true
+	}
+
+	registration, err := webhooks.Lookup(connID)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get webhook - err=%s", err.Error()), connectionWebhookPath, logger)
+
+		return 0, false
+	}
+
+	if registration == nil {
+		return p.revision(0), true
+	}
+
+	return p.revision(registration.Revision), true
+}
@@ -0,0 +1,236 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/history"
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestConnectionWebhook(t *testing.T) {
+	newOperation := func(t *testing.T, webhooks *webhook.ConnectionWebhooks) *Operation {
+		t.Helper()
+
+		cfg := config()
+		cfg.Webhook = webhook.New(nil, nil, webhook.WithConnectionWebhooks(webhooks, nil))
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-1", State: connection.StateNameCompleted, ThreadID: "thid-1",
+			MyDID: "did:router", TheirDID: "did:wallet1", Namespace: connection.MyNSPrefix,
+		}))
+
+		return o
+	}
+
+	webhookReq := func(method, connID, body string) *http.Request {
+		return mux.SetURLVars(httptest.NewRequest(method, connectionsPath+"/"+connID+"/webhook",
+			strings.NewReader(body)), map[string]string{"id": connID})
+	}
+
+	serve := func(o *Operation, handler http.HandlerFunc, req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		return w
+	}
+
+	t.Run("register, get and remove", func(t *testing.T) {
+		webhooks, err := webhook.NewConnectionWebhooks(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		o := newOperation(t, webhooks)
+
+		w := serve(o, o.getConnectionWebhook, webhookReq(http.MethodGet, "conn-1", ""))
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Contains(t, w.Body.String(), "no webhook registered")
+
+		w = serve(o, o.putConnectionWebhook,
+			webhookReq(http.MethodPut, "conn-1", `{"url":"https://adapter.example.com/events"}`))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		url, err := webhooks.Get("conn-1")
+		require.NoError(t, err)
+		require.Equal(t, "https://adapter.example.com/events", url)
+
+		w = serve(o, o.getConnectionWebhook, webhookReq(http.MethodGet, "conn-1", ""))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &ConnectionWebhookResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, &ConnectionWebhookResp{
+			ConnectionID: "conn-1", URL: "https://adapter.example.com/events", Revision: 1,
+		}, resp)
+		require.Equal(t, `"1"`, w.Header().Get(etagHeader))
+
+		w = serve(o, o.deleteConnectionWebhook, webhookReq(http.MethodDelete, "conn-1", ""))
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		url, err = webhooks.Get("conn-1")
+		require.NoError(t, err)
+		require.Empty(t, url)
+
+		changes, err := o.history.Get("conn-1")
+		require.NoError(t, err)
+		require.Len(t, changes, 2)
+		require.Equal(t, audit.WebhookChanged, changes[0].Type)
+		require.Equal(t, history.ActorOperator, changes[0].Actor)
+		require.Equal(t, "url=https://adapter.example.com/events", changes[0].Detail)
+		require.Equal(t, "removed", changes[1].Detail)
+	})
+
+	t.Run("if-match revisions", func(t *testing.T) {
+		webhooks, err := webhook.NewConnectionWebhooks(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		o := newOperation(t, webhooks)
+
+		conditional := func(method, body, etag string) *httptest.ResponseRecorder {
+			req := webhookReq(method, "conn-1", body)
+			req.Header.Set(ifMatchHeader, etag)
+
+			if method == http.MethodPut {
+				return serve(o, o.putConnectionWebhook, req)
+			}
+
+			return serve(o, o.deleteConnectionWebhook, req)
+		}
+
+		w := conditional(http.MethodPut, `{"url":"https://adapter.example.com/events"}`, "*")
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = conditional(http.MethodPut, `{"url":"https://adapter.example.com/events"}`, `"0"`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Equal(t, `"1"`, w.Header().Get(etagHeader))
+
+		w = conditional(http.MethodPut, `{"url":"http://adapter:8080/events"}`, `"1"`)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, `"2"`, w.Header().Get(etagHeader))
+
+		// stale revision : the change of the other admin tool is not clobbered
+		w = conditional(http.MethodPut, `{"url":"https://adapter.example.com/events"}`, `"1"`)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+		require.Contains(t, w.Body.String(), "precondition failed")
+
+		w = conditional(http.MethodDelete, "", `W/"2"`)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = conditional(http.MethodDelete, "", "2")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = conditional(http.MethodDelete, "", `"2"`)
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		url, err := webhooks.Get("conn-1")
+		require.NoError(t, err)
+		require.Empty(t, url)
+	})
+
+	t.Run("tenant connections", func(t *testing.T) {
+		webhooks, err := webhook.NewConnectionWebhooks(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		o := newOperation(t, webhooks)
+
+		req := webhookReq(http.MethodPut, "conn-1", `{"url":"https://adapter.example.com/events"}`)
+		req = req.WithContext(tenant.WithTenant(req.Context(), "acme"))
+
+		require.Equal(t, http.StatusNotFound, serve(o, o.putConnectionWebhook, req).Code)
+
+		o.assignTenant("acme", "conn-1")
+
+		req = webhookReq(http.MethodPut, "conn-1", `{"url":"https://adapter.example.com/events"}`)
+		req = req.WithContext(tenant.WithTenant(req.Context(), "acme"))
+
+		require.Equal(t, http.StatusOK, serve(o, o.putConnectionWebhook, req).Code)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		webhooks, err := webhook.NewConnectionWebhooks(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		o := newOperation(t, webhooks)
+
+		w := serve(o, o.putConnectionWebhook, webhookReq(http.MethodPut, "conn-1", `{`))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "invalid request")
+
+		w = serve(o, o.putConnectionWebhook, webhookReq(http.MethodPut, "conn-1", `{"url":"adapter/events"}`))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "invalid webhook URL")
+
+		w = serve(o, o.putConnectionWebhook,
+			webhookReq(http.MethodPut, "conn-2", `{"url":"https://adapter.example.com/events"}`))
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Contains(t, w.Body.String(), "connection not found")
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		for _, handler := range []http.HandlerFunc{
+			o.putConnectionWebhook, o.getConnectionWebhook, o.deleteConnectionWebhook,
+		} {
+			w := serve(o, handler, webhookReq(http.MethodGet, "conn-1", ""))
+			require.Equal(t, http.StatusNotFound, w.Code)
+			require.Contains(t, w.Body.String(), "connection webhooks not enabled")
+		}
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		webhooks, err := webhook.NewConnectionWebhooks(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:     make(map[string]mockstore.DBEntry),
+			ErrPut:    errors.New("put error"),
+			ErrGet:    errors.New("get error"),
+			ErrDelete: errors.New("delete error"),
+		}), lock.NewLocal())
+		require.NoError(t, err)
+
+		o := newOperation(t, webhooks)
+
+		w := serve(o, o.putConnectionWebhook,
+			webhookReq(http.MethodPut, "conn-1", `{"url":"https://adapter.example.com/events"}`))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "failed to register webhook")
+
+		w = serve(o, o.getConnectionWebhook, webhookReq(http.MethodGet, "conn-1", ""))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+
+		w = serve(o, o.deleteConnectionWebhook, webhookReq(http.MethodDelete, "conn-1", ""))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+
+		for _, handler := range []http.HandlerFunc{o.putConnectionWebhook, o.deleteConnectionWebhook} {
+			req := webhookReq(http.MethodPut, "conn-1", `{"url":"https://adapter.example.com/events"}`)
+			req.Header.Set(ifMatchHeader, `"1"`)
+
+			w = serve(o, handler, req)
+			require.Equal(t, http.StatusInternalServerError, w.Code)
+			require.Contains(t, w.Body.String(), "failed to get webhook")
+		}
+	})
+}
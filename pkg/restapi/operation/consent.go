@@ -0,0 +1,179 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/history"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+	"github.com/trustbloc/hub-router/pkg/terms"
+)
+
+// API endpoints.
+const (
+	connectionsPath        = "/connections"
+	connectionConsentsPath = connectionsPath + "/{id}/consents"
+)
+
+// maxConsentSize is the maximum size of a consent request, in bytes.
+const maxConsentSize = 1024
+
+// ConsentReq model: the terms version acknowledged by the wallet, through its backend.
+type ConsentReq struct {
+	Version string `json:"version"`
+}
+
+// ConsentsResp model.
+type ConsentsResp struct {
+	Consents []*terms.Consent `json:"consents"`
+}
+
+// handleAccept records the consent of the wallet acknowledging the terms with an accept message. No reply is sent.
+func (h *termsHandler) handleAccept(msg service.DIDCommMsg) (service.DIDCommMsgMap, error) {
+	accept := &terms.AcceptMsg{}
+
+	if err := msg.Decode(accept); err != nil {
+		return nil, withProblem(problemInvalidMsg, fmt.Errorf("decode terms accept : %w", err))
+	}
+
+	if accept.Version == "" {
+		return nil, withProblem(problemInvalidMsg, errors.New("terms version is mandatory"))
+	}
+
+	var myDID string
+
+	if inbound, ok := msg.(*aries.InboundMsg); ok {
+		myDID = inbound.MyDID
+	}
+
+	connID, err := h.records.ConnectionOf(myDID)
+	if err != nil {
+		return nil, withProblem(problemInvalidMsg, fmt.Errorf("terms accept connection : %w", err))
+	}
+
+	err = h.accept(connID, &terms.Consent{Version: accept.Version, Via: terms.ViaMessage, MsgID: accept.ID},
+		history.ActorWallet)
+	if err != nil {
+		return nil, withProblem(problemInternal, err)
+	}
+
+	return nil, nil
+}
+
+// accept records the consent of the connection, with the terms version last presented to it.
+func (h *termsHandler) accept(connectionID string, c *terms.Consent, actor string) error {
+	c.AcceptedAt = time.Now().UTC()
+
+	if r := h.presented(connectionID); r != nil {
+		c.PresentedVersion = r.Version
+	}
+
+	err := h.records.Accept(connectionID, c)
+	if err != nil {
+		return fmt.Errorf("record consent : %w", err)
+	}
+
+	h.recordAudit(&audit.Entry{
+		Type: audit.TermsAccepted, ConnectionID: connectionID, MsgType: terms.AcceptMsgType,
+		Detail: fmt.Sprintf("version=%s via=%s", c.Version, c.Via), Actor: actor,
+	})
+
+	return nil
+}
+
+// link links the router DID of the connection to the connection, to record the accept messages received on it.
+func (h *termsHandler) link(myDID, connectionID string) {
+	if h.terms == nil || myDID == "" {
+		return
+	}
+
+	if err := h.records.Link(myDID, connectionID); err != nil {
+		logger.Warnf("failed to link the terms of connection id=[%s] : %s", connectionID, err)
+	}
+}
+
+// postConsent records the acknowledgment of the terms by the wallet of the connection, reported by its backend.
+func (h *termsHandler) postConsent(rw http.ResponseWriter, req *http.Request) {
+	connID, ok := h.consentConnection(rw, req)
+	if !ok {
+		return
+	}
+
+	consentReq := &ConsentReq{}
+
+	err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxConsentSize)).Decode(consentReq)
+	if err != nil || consentReq.Version == "" {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, "invalid consent : terms version is mandatory",
+			connectionConsentsPath, logger)
+
+		return
+	}
+
+	c := &terms.Consent{Version: consentReq.Version, Via: terms.ViaREST}
+
+	if err = h.accept(connID, c, requestActor(req)); err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to record consent - err=%s", err.Error()), connectionConsentsPath, logger)
+
+		return
+	}
+
+	rw.WriteHeader(http.StatusCreated)
+	httputil.WriteResponseWithLog(rw, c, connectionConsentsPath, logger)
+}
+
+// getConsents returns the consents of the connection, oldest first.
+func (h *termsHandler) getConsents(rw http.ResponseWriter, req *http.Request) {
+	connID, ok := h.consentConnection(rw, req)
+	if !ok {
+		return
+	}
+
+	consents, err := h.records.Consents(connID)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get consents - err=%s", err.Error()), connectionConsentsPath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, &ConsentsResp{Consents: consents}, connectionConsentsPath, logger)
+}
+
+// consentConnection returns the connection of the consents request, or writes the error response if the terms aren't
+// configured or the connection isn't found.
+func (h *termsHandler) consentConnection(rw http.ResponseWriter, req *http.Request) (string, bool) {
+	if h.terms == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "terms not configured", connectionConsentsPath,
+			logger)
+
+		return "", false
+	}
+
+	connID := mux.Vars(req)["id"]
+
+	_, err := h.didExchange.GetConnection(connID)
+	if err != nil || !h.visible(tenant.FromContext(req.Context()), connID) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, fmt.Sprintf("connection not found : %s", connID),
+			connectionConsentsPath, logger)
+
+		return "", false
+	}
+
+	return connID, true
+}
@@ -0,0 +1,168 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/internal/mock/didexchange"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+	"github.com/trustbloc/hub-router/pkg/terms"
+)
+
+func TestConsents(t *testing.T) {
+	newOperation := func(t *testing.T) *Operation {
+		t.Helper()
+
+		cfg := config()
+		cfg.Invitations.Terms = &terms.Terms{URL: "https://example.com/terms", Version: "2021-06"}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.terms.didExchange = &didexchange.MockClient{}
+
+		return o
+	}
+
+	consentsReq := func(method, connID, body string) *http.Request {
+		return mux.SetURLVars(httptest.NewRequest(method, connectionsPath+"/"+connID+"/consents",
+			strings.NewReader(body)), map[string]string{"id": connID})
+	}
+
+	getConsents := func(t *testing.T, o *Operation, connID string) []*terms.Consent {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		o.terms.getConsents(w, consentsReq(http.MethodGet, connID, ""))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &ConsentsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+
+		return resp.Consents
+	}
+
+	t.Run("terms accepted with a message and through the REST API", func(t *testing.T) {
+		o := newOperation(t)
+
+		require.Empty(t, getConsents(t, o, "conn-1"))
+
+		o.terms.present("invitation-1", terms.ViaInvitation)
+		o.terms.inherit("invitation-1", "conn-1", "did:router")
+
+		reply, err := o.terms.handleAccept(&aries.InboundMsg{
+			DIDCommMsg: service.NewDIDCommMsgMap(&terms.AcceptMsg{
+				ID: "msg-1", Type: terms.AcceptMsgType, Version: "2021-06",
+			}),
+			MyDID: "did:router",
+		})
+		require.NoError(t, err)
+		require.Nil(t, reply)
+
+		w := httptest.NewRecorder()
+		o.terms.postConsent(w, consentsReq(http.MethodPost, "conn-1", `{"version":"2021-09"}`))
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		consents := getConsents(t, o, "conn-1")
+		require.Len(t, consents, 2)
+		require.Equal(t, "2021-06", consents[0].Version)
+		require.Equal(t, terms.ViaMessage, consents[0].Via)
+		require.Equal(t, "2021-06", consents[0].PresentedVersion)
+		require.Equal(t, "msg-1", consents[0].MsgID)
+		require.False(t, consents[0].AcceptedAt.IsZero())
+		require.Equal(t, "2021-09", consents[1].Version)
+		require.Equal(t, terms.ViaREST, consents[1].Via)
+
+		entries, err := o.auditLog.Query(time.Time{}, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, audit.TermsAccepted, entries[0].Type)
+		require.Equal(t, "conn-1", entries[0].ConnectionID)
+	})
+
+	t.Run("invalid accept message", func(t *testing.T) {
+		o := newOperation(t)
+
+		_, err := o.terms.handleAccept(service.DIDCommMsgMap{"@type": terms.AcceptMsgType, "version": 1})
+		require.Error(t, err)
+		require.Equal(t, problemInvalidMsg, problemCode(err))
+
+		_, err = o.terms.handleAccept(service.NewDIDCommMsgMap(&terms.AcceptMsg{Type: terms.AcceptMsgType}))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "terms version is mandatory")
+
+		_, err = o.terms.handleAccept(&aries.InboundMsg{
+			DIDCommMsg: service.NewDIDCommMsgMap(&terms.AcceptMsg{Type: terms.AcceptMsgType, Version: "2021-06"}),
+			MyDID:      "did:unknown",
+		})
+		require.Error(t, err)
+		require.ErrorIs(t, err, terms.ErrConnectionNotFound)
+	})
+
+	t.Run("invalid consent request", func(t *testing.T) {
+		o := newOperation(t)
+
+		for _, body := range []string{"invalid", `{}`} {
+			w := httptest.NewRecorder()
+			o.terms.postConsent(w, consentsReq(http.MethodPost, "conn-1", body))
+			require.Equal(t, http.StatusBadRequest, w.Code)
+		}
+	})
+
+	t.Run("connection not found", func(t *testing.T) {
+		o := newOperation(t)
+		o.terms.didExchange = &didexchange.MockClient{GetConnectionErr: errors.New("not found")}
+
+		w := httptest.NewRecorder()
+		o.terms.getConsents(w, consentsReq(http.MethodGet, "conn-1", ""))
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		o.terms.didExchange = &didexchange.MockClient{}
+		o.assignTenant("tenant-1", "conn-1")
+
+		req := consentsReq(http.MethodGet, "conn-1", "")
+
+		w = httptest.NewRecorder()
+		o.terms.getConsents(w, req.WithContext(tenant.WithTenant(req.Context(), "tenant-2")))
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		w = httptest.NewRecorder()
+		o.terms.getConsents(w, req.WithContext(tenant.WithTenant(req.Context(), "tenant-1")))
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("terms not configured", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		_, ok := o.msgService(terms.AcceptMsgType)
+		require.False(t, ok)
+
+		w := httptest.NewRecorder()
+		o.terms.getConsents(w, consentsReq(http.MethodGet, "conn-1", ""))
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		w = httptest.NewRecorder()
+		o.terms.postConsent(w, consentsReq(http.MethodPost, "conn-1", `{"version":"2021-06"}`))
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		o.terms.link("did:router", "conn-1")
+	})
+}
@@ -0,0 +1,103 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// API endpoints.
+const (
+	correlationsPath = "/correlations"
+)
+
+// correlationIDHeader is the REST header carrying the caller's correlation ID.
+const correlationIDHeader = "X-Correlation-ID"
+
+// CorrelationsResp model.
+type CorrelationsResp struct {
+	Records []*correlation.Record `json:"records"`
+}
+
+func (o *Operation) getCorrelations(rw http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+
+	var (
+		records []*correlation.Record
+		err     error
+	)
+
+	switch {
+	case query.Get("threadID") != "":
+		records, err = o.correlations.Thread(query.Get("threadID"))
+	case query.Get("connectionID") != "":
+		records, err = o.correlations.Find(correlation.ConnectionIDTag, query.Get("connectionID"))
+	case query.Get("correlationID") != "":
+		records, err = o.correlations.Find(correlation.CorrelationIDTag, query.Get("correlationID"))
+	default:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest,
+			"one of threadID, connectionID or correlationID query params is mandatory", correlationsPath, logger)
+
+		return
+	}
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get correlation records - err=%s", err.Error()), correlationsPath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, &CorrelationsResp{Records: records}, correlationsPath, logger)
+}
+
+// correlationID returns the caller's correlation ID (or a new one) and echoes it in the response.
+func correlationID(rw http.ResponseWriter, req *http.Request) string {
+	var id string
+
+	if req != nil {
+		id = req.Header.Get(correlationIDHeader)
+	}
+
+	if id == "" {
+		id = uuid.New().String()
+	}
+
+	rw.Header().Set(correlationIDHeader, id)
+
+	return id
+}
+
+func (o *Operation) correlate(r *correlation.Record) {
+	err := o.correlations.Save(r)
+	if err != nil {
+		logger.Warnf("failed to save correlation record thid=[%s] : %s", r.ThreadID, err.Error())
+	}
+}
+
+// msgCorrelation returns the correlation record for the DIDComm message.
+func msgCorrelation(msg service.DIDCommMsg) *correlation.Record {
+	thID, err := msg.ThreadID()
+	if err != nil && !errors.Is(err, service.ErrThreadIDNotFound) {
+		logger.Debugf("get thread id for msg id=[%s] : %s", msg.ID(), err.Error())
+	}
+
+	return &correlation.Record{
+		ThreadID:       thID,
+		ParentThreadID: msg.ParentThreadID(),
+		MsgID:          msg.ID(),
+		MsgType:        msg.Type(),
+	}
+}
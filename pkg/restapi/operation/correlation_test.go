@@ -0,0 +1,111 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/correlation"
+)
+
+func TestGetCorrelations(t *testing.T) {
+	t.Run("invitation correlation", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, invitationPath, nil)
+		req.Header.Set(correlationIDHeader, "corr-1")
+
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "corr-1", w.Header().Get(correlationIDHeader))
+
+		var inv *DIDCommInvitationResp
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &inv))
+
+		o.correlate(&correlation.Record{ThreadID: "thid-1", ParentThreadID: inv.Invitation.ID, ConnectionID: "conn-1"})
+
+		for _, query := range []string{"?correlationID=corr-1", "?threadID=" + inv.Invitation.ID} {
+			w = httptest.NewRecorder()
+			o.getCorrelations(w, httptest.NewRequest(http.MethodGet, correlationsPath+query, nil))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			resp := &CorrelationsResp{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+			require.NotEmpty(t, resp.Records)
+			require.Equal(t, inv.Invitation.ID, resp.Records[0].ThreadID)
+			require.Equal(t, "corr-1", resp.Records[0].CorrelationID)
+		}
+
+		w = httptest.NewRecorder()
+		o.getCorrelations(w, httptest.NewRequest(http.MethodGet, correlationsPath+"?connectionID=conn-1", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &CorrelationsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Len(t, resp.Records, 1)
+		require.Equal(t, inv.Invitation.ID, resp.Records[0].ParentThreadID)
+	})
+
+	t.Run("generated correlation id", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, httptest.NewRequest(http.MethodGet, invitationPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NotEmpty(t, w.Header().Get(correlationIDHeader))
+	})
+
+	t.Run("missing query", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.getCorrelations(w, httptest.NewRequest(http.MethodGet, correlationsPath, nil))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("query error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.correlations, err = correlation.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrQuery: errors.New("query error"),
+		}))
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.getCorrelations(w, httptest.NewRequest(http.MethodGet, correlationsPath+"?threadID=thid", nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "failed to get correlation records")
+	})
+}
+
+func TestMsgCorrelation(t *testing.T) {
+	msg := service.NewDIDCommMsgMap(struct {
+		ID     string            `json:"@id"`
+		Type   string            `json:"@type"`
+		Thread map[string]string `json:"~thread"`
+	}{ID: "msg-1", Type: createConnReq, Thread: map[string]string{"thid": "thid-1", "pthid": "pthid-1"}})
+
+	r := msgCorrelation(msg)
+	require.Equal(t, "thid-1", r.ThreadID)
+	require.Equal(t, "pthid-1", r.ParentThreadID)
+	require.Equal(t, "msg-1", r.MsgID)
+	require.Equal(t, createConnReq, r.MsgType)
+}
@@ -0,0 +1,218 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// API endpoints.
+const (
+	deadLettersPath      = "/deadletters"
+	deadLetterPath       = deadLettersPath + "/{id}"
+	deadLetterReplayPath = deadLetterPath + "/replay"
+)
+
+// deadLetterSweepInterval is the interval the dead-letter entries are archived and expired at.
+const deadLetterSweepInterval = time.Minute
+
+// Processing steps recorded as dead-letter decisions.
+const (
+	decisionRoute   = "route"
+	decisionProcess = "process"
+	decisionReplay  = "replay"
+	decisionReply   = "reply"
+)
+
+// DeadLettersResp model.
+type DeadLettersResp struct {
+	Entries []*deadletter.Entry `json:"entries"`
+}
+
+// deadLetterHandler serves the messages dead-lettered, and replays them on the request of the operator.
+type deadLetterHandler struct {
+	store *deadletter.Store
+	// mutex serializes the replays, by the operator or by the node taking over.
+	mutex sync.Mutex
+	// replay processes the message of the entry again, and sends the reply.
+	replay func(msg service.DIDCommMsgMap, entry *deadletter.Entry)
+}
+
+func (o *Operation) initDeadLetters(config *Config) error {
+	o.deadLetters = &deadLetterHandler{replay: o.replay}
+
+	var err error
+
+	o.deadLetters.store, err = deadletter.New(config.Storage.Persistent,
+		deadletter.WithRetention(config.DeadLetters.Retention), deadletter.WithArchiver(config.DeadLetters.Archiver))
+	if err != nil {
+		return fmt.Errorf("dead-letter store: %w", err)
+	}
+
+	return nil
+}
+
+func (h *deadLetterHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(deadLettersPath, http.MethodGet, h.getDeadLetters),
+		support.NewHTTPHandler(deadLetterPath, http.MethodGet, h.getDeadLetter),
+		support.NewHTTPHandler(deadLetterReplayPath, http.MethodPost, h.replayDeadLetter),
+	}
+}
+
+// start starts the sweeps of the entries past their retention, if set.
+func (h *deadLetterHandler) start() {
+	if h.store.Enabled() {
+		h.store.Start(deadLetterSweepInterval)
+	}
+}
+
+func (h *deadLetterHandler) getDeadLetters(rw http.ResponseWriter, req *http.Request) {
+	entries, err := h.store.List(req.URL.Query().Get("status"))
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to list dead-letter entries - err=%s", err.Error()), deadLettersPath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, &DeadLettersResp{Entries: entries}, deadLettersPath, logger)
+}
+
+func (h *deadLetterHandler) getDeadLetter(rw http.ResponseWriter, req *http.Request) {
+	entry, ok := h.deadLetterEntry(rw, req, deadLetterPath)
+	if !ok {
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, entry, deadLetterPath, logger)
+}
+
+// replayDeadLetter re-injects the dead-lettered message into the processing pipeline. Replays are serialized and
+// resolved entries can't be replayed again.
+func (h *deadLetterHandler) replayDeadLetter(rw http.ResponseWriter, req *http.Request) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	entry, ok := h.deadLetterEntry(rw, req, deadLetterReplayPath)
+	if !ok {
+		return
+	}
+
+	if entry.Status == deadletter.StatusResolved {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusConflict,
+			fmt.Sprintf("dead-letter entry %s is already resolved", entry.ID), deadLetterReplayPath, logger)
+
+		return
+	}
+
+	msg, err := service.ParseDIDCommMsgMap(entry.Message)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to parse dead-lettered message - err=%s", err.Error()), deadLetterReplayPath, logger)
+
+		return
+	}
+
+	entry.Replays++
+	entry.AddDecision(decisionReplay, fmt.Sprintf("attempt %d", entry.Replays))
+
+	h.replay(msg, entry)
+
+	err = h.store.Put(entry)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to save dead-letter entry - err=%s", err.Error()), deadLetterReplayPath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, entry, deadLetterReplayPath, logger)
+}
+
+func (o *Operation) replay(msg service.DIDCommMsgMap, entry *deadletter.Entry) {
+	msgMap, err := o.processMsg(msg, entry)
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	replyErr := o.messenger.ReplyTo(msg.ID(), msgMap) // nolint:staticcheck //issue#47
+	if replyErr != nil {
+		entry.AddDecision(decisionReply, replyErr.Error())
+
+		if err == nil {
+			entry.Error = fmt.Sprintf("send reply : %s", replyErr.Error())
+		}
+
+		return
+	}
+
+	entry.AddDecision(decisionReply, "sent")
+
+	if err == nil {
+		entry.Status = deadletter.StatusResolved
+		entry.Error = ""
+	}
+}
+
+func (h *deadLetterHandler) deadLetterEntry(rw http.ResponseWriter, req *http.Request,
+	endpoint string) (*deadletter.Entry, bool) {
+	entry, err := h.store.Get(mux.Vars(req)["id"])
+	if errors.Is(err, deadletter.ErrNotFound) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, err.Error(), endpoint, logger)
+
+		return nil, false
+	}
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get dead-letter entry - err=%s", err.Error()), endpoint, logger)
+
+		return nil, false
+	}
+
+	return entry, true
+}
+
+// deadLetter persists the failed message with the decisions applied to it.
+func (o *Operation) deadLetter(msg service.DIDCommMsg, entry *deadletter.Entry, err error) {
+	msgBytes, mErr := json.Marshal(msg)
+	if mErr != nil {
+		logger.Warnf("failed to marshal dead-lettered message : %s", mErr)
+
+		return
+	}
+
+	corr := msgCorrelation(msg)
+
+	entry.MsgID = msg.ID()
+	entry.MsgType = msg.Type()
+	entry.ThreadID = corr.ThreadID
+	entry.Error = err.Error()
+	entry.Problem = problemCode(err)
+	entry.Message = msgBytes
+
+	if status := o.failover.status(); status != nil {
+		entry.NodeID, entry.Token = status.NodeID, status.Token
+	}
+
+	if pErr := o.deadLetters.store.Put(entry); pErr != nil {
+		logger.Warnf("failed to save dead-letter entry : %s", pErr)
+	}
+}
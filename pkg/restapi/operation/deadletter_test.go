@@ -0,0 +1,245 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/internal/mock/messenger"
+)
+
+func TestDeadLetters(t *testing.T) {
+	t.Run("failed message is dead-lettered", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		entry := deadLetterMsg(t, o, service.NewDIDCommMsgMap(&DIDCommMsg{
+			ID: uuid.New().String(), Type: "unsupported-message-type",
+		}))
+
+		w := httptest.NewRecorder()
+		o.deadLetters.getDeadLetters(w, httptest.NewRequest(http.MethodGet, deadLettersPath+"?status=dead-lettered", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &DeadLettersResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Len(t, resp.Entries, 1)
+		require.Equal(t, entry.ID, resp.Entries[0].ID)
+
+		w = httptest.NewRecorder()
+		o.deadLetters.getDeadLetter(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, deadLettersPath+"/"+entry.ID, nil),
+			map[string]string{"id": entry.ID}))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		result := &deadletter.Entry{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), result))
+		require.Equal(t, "unsupported-message-type", result.MsgType)
+		require.Contains(t, result.Error, "unsupported message service type")
+		require.Len(t, result.Decisions, 2)
+		require.Equal(t, "unsupported", result.Decisions[0].Outcome)
+		require.Equal(t, problemUnsupportedMsgType, result.Decisions[1].Outcome)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.deadLetters.getDeadLetter(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, deadLettersPath+"/invalid", nil),
+			map[string]string{"id": "invalid"}))
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.deadLetters.store, err = deadletter.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrGet:   errors.New("get error"),
+			ErrQuery: errors.New("query error"),
+		}))
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.deadLetters.getDeadLetters(w, httptest.NewRequest(http.MethodGet, deadLettersPath, nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "query error")
+
+		w = httptest.NewRecorder()
+		o.deadLetters.getDeadLetter(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, deadLettersPath+"/id", nil),
+			map[string]string{"id": "id"}))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "get error")
+	})
+}
+
+func TestReplayDeadLetter(t *testing.T) {
+	t.Run("replay resolves the entry", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		didDocBytes, err := mockdiddoc.GetMockDIDDoc(t).JSONBytes()
+		require.NoError(t, err)
+
+		msg := receivedMsg(t, CreateConnReq{
+			ID:   uuid.New().String(),
+			Type: createConnReq,
+			Data: &CreateConnReqData{DIDDoc: didDocBytes},
+		})
+
+		entry := &deadletter.Entry{}
+		o.deadLetter(msg, entry, errors.New("transient error"))
+
+		var replyTo string
+
+		o.messenger = &messenger.MockMessenger{
+			ReplyToFunc: func(msgID string, msg service.DIDCommMsgMap) error {
+				replyTo = msgID
+
+				return nil
+			},
+		}
+
+		w := replay(o, entry.ID)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, msg.ID(), replyTo)
+
+		result := &deadletter.Entry{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), result))
+		require.Equal(t, deadletter.StatusResolved, result.Status)
+		require.Equal(t, 1, result.Replays)
+		require.Empty(t, result.Error)
+		require.Equal(t, "sent", result.Decisions[len(result.Decisions)-1].Outcome)
+
+		w = replay(o, entry.ID)
+		require.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("replay fails again", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.messenger = &messenger.MockMessenger{}
+
+		entry := deadLetterMsg(t, o, service.NewDIDCommMsgMap(&DIDCommMsg{
+			ID: uuid.New().String(), Type: "unsupported-message-type",
+		}))
+
+		w := replay(o, entry.ID)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		result := &deadletter.Entry{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), result))
+		require.Equal(t, deadletter.StatusDeadLettered, result.Status)
+		require.Equal(t, 1, result.Replays)
+		require.Contains(t, result.Error, "unsupported message service type")
+	})
+
+	t.Run("reply error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		entry := &deadletter.Entry{}
+		o.deadLetter(receivedMsg(t, CreateConnReq{
+			ID: uuid.New().String(), Type: createConnReq, Data: &CreateConnReqData{},
+		}), entry, errors.New("transient error"))
+
+		o.messenger = &messenger.MockMessenger{
+			ReplyToFunc: func(msgID string, msg service.DIDCommMsgMap) error {
+				return errors.New("reply error")
+			},
+		}
+
+		w := replay(o, entry.ID)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		result := &deadletter.Entry{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), result))
+		require.Equal(t, deadletter.StatusDeadLettered, result.Status)
+		require.Equal(t, "reply error", result.Decisions[len(result.Decisions)-1].Outcome)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := replay(o, "invalid")
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("invalid message", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		entry := &deadletter.Entry{Message: []byte(`"invalid"`)}
+		require.NoError(t, o.deadLetters.store.Put(entry))
+
+		w := replay(o, entry.ID)
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "failed to parse dead-lettered message")
+	})
+
+	t.Run("save error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.messenger = &messenger.MockMessenger{}
+
+		entry := deadLetterMsg(t, o, service.NewDIDCommMsgMap(&DIDCommMsg{
+			ID: uuid.New().String(), Type: "unsupported-message-type",
+		}))
+
+		entryBytes, err := json.Marshal(entry)
+		require.NoError(t, err)
+
+		o.deadLetters.store, err = deadletter.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  map[string]mockstore.DBEntry{entry.ID: {Value: entryBytes}},
+			ErrPut: errors.New("put error"),
+		}))
+		require.NoError(t, err)
+
+		w := replay(o, entry.ID)
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "put error")
+	})
+}
+
+func deadLetterMsg(t *testing.T, o *Operation, msg service.DIDCommMsgMap) *deadletter.Entry {
+	t.Helper()
+
+	entry := &deadletter.Entry{}
+
+	_, err := o.processMsg(msg, entry)
+	require.Error(t, err)
+
+	o.deadLetter(msg, entry, err)
+	require.NotEmpty(t, entry.ID)
+
+	return entry
+}
+
+func replay(o *Operation, id string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+
+	o.deadLetters.replayDeadLetter(w, mux.SetURLVars(
+		httptest.NewRequest(http.MethodPost, deadLettersPath+"/"+id+"/replay", nil), map[string]string{"id": id}))
+
+	return w
+}
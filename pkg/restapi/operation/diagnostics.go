@@ -0,0 +1,141 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"net/http"
+	"runtime"
+	"time"
+
+	"github.com/trustbloc/hub-router/pkg/compression"
+	"github.com/trustbloc/hub-router/pkg/connpool"
+	"github.com/trustbloc/hub-router/pkg/dedup"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/kmscache"
+	"github.com/trustbloc/hub-router/pkg/mailbox"
+	"github.com/trustbloc/hub-router/pkg/ordering"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/retryadvice"
+	"github.com/trustbloc/hub-router/pkg/suppression"
+)
+
+// API endpoints.
+const (
+	diagnosticsPath = "/diagnostics"
+)
+
+// DiagnosticsResp model.
+type DiagnosticsResp struct {
+	Time        time.Time `json:"time"`
+	Goroutines  int       `json:"goroutines"`
+	HeapAlloc   uint64    `json:"heapAlloc"`
+	HeapObjects uint64    `json:"heapObjects"`
+	NumGC       uint32    `json:"numGC"`
+	MaxProcs    int       `json:"maxProcs"`
+	// QueueCompression is the compression ratio of the queued messages, if they are compressed.
+	QueueCompression *compression.Stats `json:"queueCompression,omitempty"`
+	// QueueDedup is the number of duplicate queued payloads stored once, if they are deduplicated.
+	QueueDedup *dedup.Stats `json:"queueDedup,omitempty"`
+	// QueueOrdering is the number of queued messages numbered, and of mailboxes reordered, if they are sequenced.
+	QueueOrdering *ordering.Stats `json:"queueOrdering,omitempty"`
+	// QueueLimits is the number of queued messages rejected, expired and purged, if the mailboxes are bounded.
+	QueueLimits *mailbox.Stats `json:"queueLimits,omitempty"`
+	// OutboundPool is the reuse of the pooled outbound connections, with the busiest destinations.
+	OutboundPool *connpool.Stats `json:"outboundPool,omitempty"`
+	// KMS is the number of KMS round trips and their latency, and the key lookups served from the cache.
+	KMS *kmscache.Stats `json:"kms,omitempty"`
+	// Limits are the memory limit, and the handshakes and pickups in progress, if the router is limited.
+	Limits *LimitsResp `json:"limits,omitempty"`
+	// Suppression is the number of duplicate forwards suppressed, if they are.
+	Suppression *suppression.Stats `json:"suppression,omitempty"`
+	// Retries are the retries advised to the peers on transient errors, and whether they retried.
+	Retries *retryadvice.Stats `json:"retries"`
+}
+
+// diagnosticsHandler serves the runtime counters, and the stats of the queue storage and of the outbound pool, nil if
+// not enabled.
+type diagnosticsHandler struct {
+	queueCompression *compression.Provider
+	queueDedup       *dedup.Provider
+	queueOrdering    *ordering.Sequencer
+	outboundPool     *connpool.Transport
+	// components sets the stats of the router components : the limits, retries, suppression, mailboxes and KMS.
+	components func(resp *DiagnosticsResp)
+}
+
+func (o *Operation) initDiagnostics(config *Config) {
+	o.diagnostics = &diagnosticsHandler{
+		queueCompression: config.Queues.Compression,
+		queueDedup:       config.Queues.Dedup,
+		queueOrdering:    config.Queues.Ordering,
+		outboundPool:     config.Transports.OutboundPool,
+		components:       o.componentDiagnostics,
+	}
+}
+
+func (h *diagnosticsHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(diagnosticsPath, http.MethodGet, h.getDiagnostics),
+	}
+}
+
+// getDiagnostics returns the runtime counters used to detect leaks; gc=true forces a garbage collection first so
+// that consecutive heap samples are comparable.
+func (h *diagnosticsHandler) getDiagnostics(rw http.ResponseWriter, req *http.Request) {
+	if req.URL.Query().Get("gc") == "true" {
+		runtime.GC()
+	}
+
+	var stats runtime.MemStats
+
+	runtime.ReadMemStats(&stats)
+
+	resp := &DiagnosticsResp{
+		Time:        time.Now().UTC(),
+		Goroutines:  runtime.NumGoroutine(),
+		HeapAlloc:   stats.HeapAlloc,
+		HeapObjects: stats.HeapObjects,
+		NumGC:       stats.NumGC,
+		MaxProcs:    runtime.GOMAXPROCS(0),
+	}
+
+	h.components(resp)
+
+	if h.queueCompression != nil {
+		resp.QueueCompression = h.queueCompression.Stats()
+	}
+
+	if h.queueDedup != nil && h.queueDedup.Enabled() {
+		resp.QueueDedup = h.queueDedup.Stats()
+	}
+
+	if h.queueOrdering != nil && h.queueOrdering.Enabled() {
+		resp.QueueOrdering = h.queueOrdering.Stats()
+	}
+
+	if h.outboundPool != nil {
+		resp.OutboundPool = h.outboundPool.Stats()
+	}
+
+	httputil.WriteResponseWithLog(rw, resp, diagnosticsPath, logger)
+}
+
+// componentDiagnostics sets the limits in use, the retries advised, and the stats of the mailboxes, and of the
+// suppression and the KMS cache, if enabled.
+func (o *Operation) componentDiagnostics(resp *DiagnosticsResp) {
+	resp.Limits = o.limits.stats()
+	resp.Retries = o.retries.Stats()
+	resp.QueueLimits = o.mailbox.stats()
+
+	if o.suppression != nil {
+		resp.Suppression = o.suppression.Stats()
+	}
+
+	if km, ok := o.keyManager.(*kmscache.KeyManager); ok {
+		resp.KMS = km.Stats()
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	mockkms "github.com/hyperledger/aries-framework-go/pkg/mock/kms"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/compression"
+	"github.com/trustbloc/hub-router/pkg/connpool"
+	"github.com/trustbloc/hub-router/pkg/dedup"
+	"github.com/trustbloc/hub-router/pkg/kmscache"
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/ordering"
+	"github.com/trustbloc/hub-router/pkg/pickup"
+)
+
+func TestGetDiagnostics(t *testing.T) {
+	o, err := New(config())
+	require.NoError(t, err)
+
+	for _, target := range []string{diagnosticsPath, diagnosticsPath + "?gc=true"} {
+		w := httptest.NewRecorder()
+		o.diagnostics.getDiagnostics(w, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &DiagnosticsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Positive(t, resp.Goroutines)
+		require.Positive(t, resp.HeapObjects)
+		require.Nil(t, resp.QueueCompression)
+		require.Nil(t, resp.QueueDedup)
+		require.Nil(t, resp.QueueOrdering)
+		require.Nil(t, resp.Suppression)
+		require.Nil(t, resp.OutboundPool)
+		require.Nil(t, resp.KMS)
+	}
+
+	t.Run("queue compression", func(t *testing.T) {
+		codec, err := compression.New(compression.Zstd)
+		require.NoError(t, err)
+
+		cp, err := compression.NewProvider(mem.NewProvider(), codec, messagepickup.Namespace)
+		require.NoError(t, err)
+
+		s, err := cp.OpenStore(messagepickup.Namespace)
+		require.NoError(t, err)
+		require.NoError(t, s.Put("did:example:1", []byte(`{"message_count":0,"messages":[],"padding":"`+
+			strings.Repeat("a", 1024)+`"}`)))
+
+		cfg := config()
+		cfg.Queues.Compression = cp
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.diagnostics.getDiagnostics(w, httptest.NewRequest(http.MethodGet, diagnosticsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &DiagnosticsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, compression.Zstd, resp.QueueCompression.Algorithm)
+		require.Equal(t, uint64(1), resp.QueueCompression.Writes)
+		require.Greater(t, resp.QueueCompression.Ratio, 1.0)
+	})
+
+	t.Run("queue deduplication", func(t *testing.T) {
+		dp := dedup.NewProvider(mem.NewProvider(), true, messagepickup.Namespace)
+
+		s, err := dp.OpenStore(messagepickup.Namespace)
+		require.NoError(t, err)
+
+		msg := `{"id":"%s","msg":{"message":"` + strings.Repeat("a", 1024) + `"}}`
+		require.NoError(t, s.Put("did:example:1", []byte(`{"message_count":2,"messages":[`+
+			fmt.Sprintf(msg, "1")+`,`+fmt.Sprintf(msg, "2")+`]}`)))
+
+		cfg := config()
+		cfg.Queues.Dedup = dp
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.diagnostics.getDiagnostics(w, httptest.NewRequest(http.MethodGet, diagnosticsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &DiagnosticsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, uint64(1), resp.QueueDedup.Deduplicated)
+		require.Equal(t, uint64(1), resp.QueueDedup.Duplicates)
+		require.Positive(t, resp.QueueDedup.SavedBytes)
+	})
+
+	t.Run("queue ordering", func(t *testing.T) {
+		op := ordering.New(true)
+
+		q, err := pickup.NewQueue(mem.NewProvider(), lock.NewLocal(), pickup.WithSequencer(op))
+		require.NoError(t, err)
+		require.NoError(t, q.Add("did:example:1", "key-1", &model.Envelope{}))
+		require.NoError(t, q.Add("did:example:1", "key-1", &model.Envelope{}))
+
+		cfg := config()
+		cfg.Queues.Ordering = op
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.diagnostics.getDiagnostics(w, httptest.NewRequest(http.MethodGet, diagnosticsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &DiagnosticsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, uint64(2), resp.QueueOrdering.Sequenced)
+		require.Zero(t, resp.QueueOrdering.Reordered)
+	})
+
+	t.Run("outbound pool", func(t *testing.T) {
+		cfg := config()
+		cfg.Transports.OutboundPool = connpool.NewTransport(&http.Transport{}, &connpool.Config{MaxIdlePerHost: 8})
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.diagnostics.getDiagnostics(w, httptest.NewRequest(http.MethodGet, diagnosticsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &DiagnosticsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, 8, resp.OutboundPool.MaxIdlePerHost)
+		require.Empty(t, resp.OutboundPool.Destinations)
+	})
+
+	t.Run("kms", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.keyManager = kmscache.New(&mockkms.KeyManager{}, &kmscache.Config{Size: 16})
+
+		_, err = o.keyManager.Get("key")
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.diagnostics.getDiagnostics(w, httptest.NewRequest(http.MethodGet, diagnosticsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &DiagnosticsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, uint64(1), resp.KMS.RoundTrips)
+		require.Equal(t, 16, resp.KMS.Capacity)
+	})
+}
@@ -0,0 +1,119 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	didstore "github.com/hyperledger/aries-framework-go/pkg/store/did"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/digest"
+	"github.com/trustbloc/hub-router/pkg/stats"
+)
+
+// digestInterval is the interval the digests of the ended periods are delivered at.
+const digestInterval = 5 * time.Minute
+
+// initDigests initializes the digests delivered to the webhooks, and to the digest sinks, if scheduled.
+func (o *Operation) initDigests(config *Config) error {
+	if !config.Reporting.Digests.Enabled() {
+		return nil
+	}
+
+	sinks := config.Reporting.DigestSinks
+	if config.Webhook != nil {
+		sinks = append([]digest.Sink{digest.NewWebhookSink(config.Webhook)}, sinks...)
+	}
+
+	sinks = append(sinks, &sloBreachSink{send: o.reporting.raise})
+
+	var err error
+
+	o.didConnections, err = didstore.NewConnectionStore(config.Aries)
+	if err != nil {
+		return fmt.Errorf("did connection store: %w", err)
+	}
+
+	o.reporting.digests, err = digest.New(config.Storage.Persistent, config.Reporting.Digests, o.buildDigest, sinks...)
+	if err != nil {
+		return fmt.Errorf("digest scheduler: %w", err)
+	}
+
+	return nil
+}
+
+// buildDigest returns the digest of the router activity within [from, to) : the connections added and the failures
+// counted in the stats, the mediation grants ended recorded in the audit log, and the forwards tallied by the digest
+// scheduler.
+func (o *Operation) buildDigest(from, to time.Time) (*digest.Digest, error) {
+	rollups, err := o.stats.History("", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &digest.Digest{}
+	failures := 0
+
+	for _, r := range rollups {
+		d.ConnectionsAdded += r.Counters[stats.ConnectionsCreated]
+		failures += r.Counters[stats.Errors]
+	}
+
+	entries, err := o.auditLog.Query(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if e.Type == audit.WalletHandover || e.Type == audit.GrantTransfer {
+			d.GrantsRevoked++
+		}
+	}
+
+	forwards, senders, err := o.reporting.digests.Forwards(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range senders {
+		s.ConnectionID = o.senderConnection(s.Key)
+	}
+
+	d.Delivery = digest.NewDelivery(forwards, failures, o.reporting.digests.Objective())
+	d.TopSenders = senders
+
+	return d, nil
+}
+
+// tallyForward tallies the forward routed by the router for the digests, with the key of its sender.
+func (h *reportingHandler) tallyForward(envelope *transport.Envelope) {
+	if h.digests == nil {
+		return
+	}
+
+	var senderKey string
+
+	if len(envelope.FromKey) > 0 {
+		senderKey = base58.Encode(envelope.FromKey)
+	}
+
+	h.digests.Forward(senderKey)
+}
+
+// senderConnection returns the connection of the router with the sender key, empty if not found.
+func (o *Operation) senderConnection(senderKey string) string {
+	theirDID, err := o.didConnections.GetDID(senderKey)
+	if err != nil {
+		return ""
+	}
+
+	return o.walletConnection(theirDID)
+}
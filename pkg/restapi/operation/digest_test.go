@@ -0,0 +1,154 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	didstore "github.com/hyperledger/aries-framework-go/pkg/store/did"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/digest"
+	"github.com/trustbloc/hub-router/pkg/routes"
+	"github.com/trustbloc/hub-router/pkg/stats"
+)
+
+func failingQuery() *mockstore.MockStoreProvider {
+	return mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+		Store:    make(map[string]mockstore.DBEntry),
+		ErrQuery: errors.New("query error"),
+	})
+}
+
+type mockDigestSink struct {
+	digests []*digest.Digest
+}
+
+func (m *mockDigestSink) Deliver(d *digest.Digest) error {
+	m.digests = append(m.digests, d)
+
+	return nil
+}
+
+func TestDigests(t *testing.T) {
+	t.Run("digest built and delivered", func(t *testing.T) {
+		ariesStorage := mem.NewProvider()
+
+		mediated, err := routes.Open(ariesStorage)
+		require.NoError(t, err)
+		require.NoError(t, mediated.Route("key-1", "did:wallet"))
+
+		sink := &mockDigestSink{}
+
+		cfg := config()
+		cfg.Aries.(*mockprovider.Provider).StorageProviderValue = ariesStorage
+		cfg.Reporting.Digests = &digest.Config{Schedules: []string{digest.Daily}, Objective: 0.9}
+		cfg.Reporting.DigestSinks = []digest.Sink{sink}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-1", State: connection.StateNameCompleted, ThreadID: "thid-1",
+			MyDID: "did:router", TheirDID: "did:issuer", Namespace: connection.MyNSPrefix,
+		}))
+
+		didConnections, err := didstore.NewConnectionStore(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, didConnections.SaveDID("did:issuer", base58.Encode([]byte("issuer-key"))))
+
+		from, to, err := digest.Period(digest.Daily, time.Now())
+		require.NoError(t, err)
+
+		require.NoError(t, o.stats.Add("", from.Add(time.Hour), stats.ConnectionsCreated, 3))
+		require.NoError(t, o.stats.Add("", from.Add(2*time.Hour), stats.Errors, 2))
+		require.NoError(t, o.stats.Add("", to, stats.ConnectionsCreated, 5))
+
+		for _, e := range []*audit.Entry{
+			{Type: audit.WalletHandover, Time: from.Add(time.Hour)},
+			{Type: audit.GrantTransfer, Time: from.Add(2 * time.Hour)},
+			{Type: audit.ConnectionCreated, Time: from.Add(2 * time.Hour)},
+			{Type: audit.GrantTransfer, Time: to.Add(time.Hour)},
+		} {
+			require.NoError(t, o.auditLog.Record(e))
+		}
+
+		// forwards routed by the router, the current hour is tallied in memory until it's over
+		o.EnvelopeHandled(withHeader(&transport.Envelope{
+			Message: []byte(`{"@id":"msg-1","@type":"https://didcomm.org/routing/1.0/forward","to":"key-1","msg":{}}`),
+			FromKey: []byte("issuer-key"),
+		}))
+		o.EnvelopeHandled(withHeader(&transport.Envelope{
+			Message: []byte(`{"@type":"https://didcomm.org/trust_ping/1.0/ping"}`),
+		}))
+
+		require.NoError(t, o.reporting.digests.Run())
+		require.Len(t, sink.digests, 1)
+
+		d := sink.digests[0]
+		require.Equal(t, digest.Daily, d.Schedule)
+		require.Equal(t, from, d.From)
+		require.Equal(t, 3, d.ConnectionsAdded)
+		require.Equal(t, 2, d.GrantsRevoked)
+		require.Equal(t, &digest.Delivery{Failures: 2, Objective: 0.9}, d.Delivery)
+		require.Empty(t, d.TopSenders)
+
+		require.Equal(t, "conn-1", o.senderConnection(base58.Encode([]byte("issuer-key"))))
+		require.Empty(t, o.senderConnection("unknown-key"))
+	})
+
+	t.Run("digests not scheduled", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+		require.Nil(t, o.reporting.digests)
+
+		o.reporting.tallyForward(&transport.Envelope{})
+	})
+
+	t.Run("unsupported schedule", func(t *testing.T) {
+		cfg := config()
+		cfg.Reporting.Digests = &digest.Config{Schedules: []string{"monthly"}}
+
+		_, err := New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "digest scheduler")
+	})
+
+	t.Run("build errors", func(t *testing.T) {
+		cfg := config()
+		cfg.Reporting.Digests = &digest.Config{Schedules: []string{digest.Weekly}}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.stats, err = stats.New(failingQuery(), 0)
+		require.NoError(t, err)
+
+		_, err = o.buildDigest(time.Time{}, time.Now())
+		require.Error(t, err)
+
+		o.stats, err = stats.New(mem.NewProvider(), 0)
+		require.NoError(t, err)
+
+		o.auditLog, err = audit.New(failingQuery())
+		require.NoError(t, err)
+
+		_, err = o.buildDigest(time.Time{}, time.Now())
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,74 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+	"github.com/hyperledger/aries-framework-go/pkg/kms"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/fingerprint"
+)
+
+// createInvitation creates the out-of-band invitation of the tenant, with a service per advertised endpoint if
+// configured, the default service of the agent otherwise.
+func (o *Operation) createInvitation(tenantID string, params *invitationParams) (*outofband.Invitation, error) {
+	services, err := o.invitationServices()
+	if err != nil {
+		return nil, err
+	}
+
+	return o.oob.CreateInvitation(services, o.invitationOptions(tenantID, params)...)
+}
+
+// invitationServices returns the services of the invitations, one per advertised endpoint in order of priority, with
+// the same new recipient key. It returns nil if the endpoints advertised aren't configured.
+func (o *Operation) invitationServices() ([]interface{}, error) {
+	if len(o.endpoints) == 0 {
+		return nil, nil
+	}
+
+	_, pubKeyBytes, err := o.keyManager.CreateAndExportPubKeyBytes(kms.ED25519Type)
+	if err != nil {
+		return nil, fmt.Errorf("kms failed to create invitation key: %w", err)
+	}
+
+	recipientKey, _ := fingerprint.CreateDIDKey(pubKeyBytes)
+
+	services := make([]interface{}, len(o.endpoints))
+
+	for i, endpoint := range o.endpoints {
+		services[i] = &did.Service{
+			ID:              uuid.New().String(),
+			Type:            didCommServiceType,
+			Priority:        uint(i),
+			RecipientKeys:   []string{recipientKey},
+			ServiceEndpoint: endpoint,
+		}
+	}
+
+	return services, nil
+}
+
+// routerServices returns the services of the router DID docs, one per advertised endpoint in order of priority, with
+// the routing keys : the endpoint of the upstream mediator only, once granted mediation by it.
+func (o *Operation) routerServices(endpoint string, routingKeys []string) []did.Service {
+	endpoints := o.endpoints
+	if endpoint != o.endpoint || len(endpoints) == 0 {
+		endpoints = []string{endpoint}
+	}
+
+	services := make([]did.Service, len(endpoints))
+
+	for i, e := range endpoints {
+		services[i] = did.Service{ServiceEndpoint: e, RoutingKeys: routingKeys, Priority: uint(i)}
+	}
+
+	return services
+}
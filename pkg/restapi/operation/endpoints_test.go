@@ -0,0 +1,114 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"testing"
+
+	mediatordsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+	vdrapi "github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockkms "github.com/hyperledger/aries-framework-go/pkg/mock/kms"
+	mockvdri "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/history"
+)
+
+func TestEndpoints(t *testing.T) {
+	endpoints := []string{"wss://router.example.com/ws", "https://router.example.com"}
+
+	newOperation := func(t *testing.T, endpoints []string) *Operation {
+		t.Helper()
+
+		cfg := config()
+		cfg.Mediation.Endpoints = endpoints
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		return o
+	}
+
+	t.Run("invitation services", func(t *testing.T) {
+		services, err := newOperation(t, nil).invitationServices()
+		require.NoError(t, err)
+		require.Nil(t, services)
+
+		o := newOperation(t, endpoints)
+
+		services, err = o.invitationServices()
+		require.NoError(t, err)
+		require.Len(t, services, 2)
+
+		for i, svc := range services {
+			s, ok := svc.(*did.Service)
+			require.True(t, ok)
+			require.Equal(t, endpoints[i], s.ServiceEndpoint)
+			require.Equal(t, uint(i), s.Priority)
+			require.Equal(t, didCommServiceType, s.Type)
+			require.Len(t, s.RecipientKeys, 1)
+		}
+
+		first, ok := services[0].(*did.Service)
+		require.True(t, ok)
+
+		second, ok := services[1].(*did.Service)
+		require.True(t, ok)
+		require.Equal(t, first.RecipientKeys, second.RecipientKeys)
+
+		o.keyManager = &mockkms.KeyManager{CrAndExportPubKeyErr: errors.New("kms error")}
+
+		_, err = o.createInvitation("", &invitationParams{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "kms failed to create invitation key: kms error")
+	})
+
+	t.Run("router did doc and grant", func(t *testing.T) {
+		o := newOperation(t, endpoints)
+		require.Equal(t, endpoints[0], o.endpoint)
+
+		var routerDoc *did.Doc
+
+		o.vdriRegistry = &mockvdri.MockVDRegistry{
+			CreateFunc: func(method string, doc *did.Doc, _ ...vdrapi.DIDMethodOption) (*did.DocResolution, error) {
+				routerDoc = doc
+
+				return &did.DocResolution{DIDDocument: mockdiddoc.GetMockDIDDoc(t)}, nil
+			},
+		}
+
+		data, err := o.establishConnection("", mockdiddoc.GetMockDIDDoc(t), "", &correlation.Record{},
+			history.ActorOperator)
+		require.NoError(t, err)
+		require.Equal(t, endpoints[0], data.ServiceEndpoint)
+
+		require.Len(t, routerDoc.Service, 2)
+		require.Equal(t, endpoints[0], routerDoc.Service[0].ServiceEndpoint)
+		require.Equal(t, endpoints[1], routerDoc.Service[1].ServiceEndpoint)
+		require.Equal(t, uint(1), routerDoc.Service[1].Priority)
+
+		args, err := o.mediationGrantOptions("conn-1")
+		require.NoError(t, err)
+
+		opts, ok := args.(mediatordsvc.Options)
+		require.True(t, ok)
+		require.Equal(t, endpoints[0], opts.ServiceEndpoint)
+	})
+
+	t.Run("upstream endpoint only", func(t *testing.T) {
+		o := newOperation(t, endpoints)
+
+		services := o.routerServices("https://mediator.example.com", []string{"did:key:upstream"})
+		require.Equal(t, []did.Service{{
+			ServiceEndpoint: "https://mediator.example.com", RoutingKeys: []string{"did:key:upstream"},
+		}}, services)
+	})
+}
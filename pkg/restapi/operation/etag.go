@@ -0,0 +1,125 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+const (
+	etagHeader    = "ETag"
+	ifMatchHeader = "If-Match"
+
+	// anyRevision is the revision of the unconditional changes, the AnyRevision (AnyVersion) of the record stores.
+	anyRevision = -1
+	// noRevision is the revision of the changes whose precondition no revision matches, eg: a weak ETag : the record
+	// stores reject them as conflicting with any revision.
+	noRevision = -2
+
+	anyETag        = "*"
+	weakETagPrefix = "W/"
+)
+
+// errInvalidIfMatch is returned for an If-Match header that isn't a list of ETags, or *.
+var errInvalidIfMatch = errors.New("invalid If-Match : expected * or the ETags of the record, eg: \"3\"")
+
+// precondition is the If-Match header of a request, the changes are applied to the matching revisions of the record
+// only, so that the concurrent admin tools don't clobber each other's changes.
+type precondition struct {
+	// exists matches any revision of the record, If-Match: *.
+	exists bool
+	// revisions are the revisions of the strong ETags, the weak ETags never match (RFC 7232 strong comparison).
+	revisions []int
+}
+
+// revisionETag returns the ETag of the revision of a record.
+func revisionETag(revision int) string {
+	return strconv.Quote(strconv.Itoa(revision))
+}
+
+// setETag sets the ETag of the revision of the record returned, before the response is written.
+func setETag(rw http.ResponseWriter, revision int) {
+	rw.Header().Set(etagHeader, revisionETag(revision))
+}
+
+// ifMatch returns the precondition of the If-Match header of the request, nil without the header.
+func ifMatch(req *http.Request) (*precondition, error) {
+	header := strings.TrimSpace(req.Header.Get(ifMatchHeader))
+	if header == "" {
+		return nil, nil
+	}
+
+	if header == anyETag {
+		return &precondition{exists: true}, nil
+	}
+
+	p := &precondition{}
+
+	for _, etag := range strings.Split(header, ",") {
+		etag = strings.TrimSpace(etag)
+		weak := strings.HasPrefix(etag, weakETagPrefix)
+		etag = strings.TrimPrefix(etag, weakETagPrefix)
+
+		value, err := strconv.Unquote(etag)
+		if err != nil || !strings.HasPrefix(etag, `"`) {
+			return nil, errInvalidIfMatch
+		}
+
+		revision, err := strconv.Atoi(value)
+		if err != nil || revision < 0 {
+			return nil, errInvalidIfMatch
+		}
+
+		if !weak {
+			p.revisions = append(p.revisions, revision)
+		}
+	}
+
+	return p, nil
+}
+
+// revision returns the revision the change is conditional on, given the current revision of the record, 0 if it
+// doesn't exist : anyRevision without precondition, the current revision if it matches, noRevision if none matches.
+// The record stores check the revision against the stored record, so that a record changed meanwhile is rejected.
+func (p *precondition) revision(current int) int {
+	if p == nil {
+		return anyRevision
+	}
+
+	if p.exists {
+		if current > 0 {
+			return current
+		}
+
+		return noRevision
+	}
+
+	for _, revision := range p.revisions {
+		if revision == current {
+			return revision
+		}
+	}
+
+	if len(p.revisions) > 0 {
+		// conflicts with the current revision, reported by the store
+		return p.revisions[0]
+	}
+
+	return noRevision
+}
+
+// writePreconditionFailed writes the 412 of the change expecting another revision of the record.
+func writePreconditionFailed(rw http.ResponseWriter, err error, endpoint string) {
+	httputil.WriteErrorResponseWithLog(rw, http.StatusPreconditionFailed,
+		fmt.Sprintf("precondition failed : %s", err), endpoint, logger)
+}
@@ -0,0 +1,72 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIfMatch(t *testing.T) {
+	revision := func(t *testing.T, header string, current int) int {
+		t.Helper()
+
+		req := httptest.NewRequest(http.MethodPost, tenantsPath, nil)
+		if header != "" {
+			req.Header.Set(ifMatchHeader, header)
+		}
+
+		p, err := ifMatch(req)
+		require.NoError(t, err)
+
+		return p.revision(current)
+	}
+
+	t.Run("revision", func(t *testing.T) {
+		require.Equal(t, 3, revision(t, `"3"`, 3))
+		require.Equal(t, 3, revision(t, `"3"`, 4))
+		require.Equal(t, 0, revision(t, revisionETag(0), 0))
+	})
+
+	t.Run("no header", func(t *testing.T) {
+		require.Equal(t, anyRevision, revision(t, "", 3))
+	})
+
+	t.Run("any revision", func(t *testing.T) {
+		require.Equal(t, 3, revision(t, "*", 3))
+		require.Equal(t, noRevision, revision(t, "*", 0))
+	})
+
+	t.Run("list", func(t *testing.T) {
+		require.Equal(t, 2, revision(t, `"1", "2"`, 2))
+		require.Equal(t, 1, revision(t, `"1","2"`, 3))
+	})
+
+	t.Run("weak ETags never match", func(t *testing.T) {
+		require.Equal(t, noRevision, revision(t, `W/"3"`, 3))
+		require.Equal(t, 4, revision(t, `W/"3", "4"`, 3))
+	})
+
+	t.Run("invalid header", func(t *testing.T) {
+		for _, header := range []string{"3", `"abc"`, `"-1"`, "**", `W/3`, "`3`", `"1",`, `W/"3", *`} {
+			req := httptest.NewRequest(http.MethodPost, tenantsPath, nil)
+			req.Header.Set(ifMatchHeader, header)
+
+			_, err := ifMatch(req)
+			require.ErrorIs(t, err, errInvalidIfMatch, header)
+		}
+	})
+}
+
+func TestSetETag(t *testing.T) {
+	w := httptest.NewRecorder()
+	setETag(w, 7)
+	require.Equal(t, `"7"`, w.Header().Get(etagHeader))
+}
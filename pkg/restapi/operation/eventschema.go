@@ -0,0 +1,65 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+// API endpoints.
+const (
+	eventSchemasPath = "/events/schemas"
+	eventSchemaPath  = eventSchemasPath + "/{version}"
+)
+
+// schemaMediaType is the media type of the event schemas, served as JSON to the clients that don't accept it.
+const schemaMediaType = "application/schema+json"
+
+// EventSchemasResp lists the schema versions of the webhook messages.
+type EventSchemasResp struct {
+	Versions []string `json:"versions"`
+	Current  string   `json:"current"`
+}
+
+func (o *Operation) getEventSchemas(rw http.ResponseWriter, _ *http.Request) {
+	httputil.WriteResponseWithLog(rw, &EventSchemasResp{
+		Versions: webhook.SchemaVersions(),
+		Current:  webhook.CurrentSchemaVersion,
+	}, eventSchemasPath, logger)
+}
+
+func (o *Operation) getEventSchema(rw http.ResponseWriter, req *http.Request) {
+	mediaType, ok := httputil.Negotiate(req, schemaMediaType, httputil.MediaTypeJSON)
+	if !ok {
+		httputil.WriteNotAcceptable(rw, eventSchemaPath, logger, schemaMediaType, httputil.MediaTypeJSON)
+
+		return
+	}
+
+	schema, err := webhook.Schema(mux.Vars(req)["version"])
+	if errors.Is(err, webhook.ErrUnknownSchemaVersion) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, err.Error(), eventSchemaPath, logger)
+
+		return
+	}
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to read event schema - err=%s", err.Error()), eventSchemaPath, logger)
+
+		return
+	}
+
+	httputil.WriteContent(rw, mediaType, schema, eventSchemaPath, logger)
+}
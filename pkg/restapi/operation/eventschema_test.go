@@ -0,0 +1,84 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestEventSchemas(t *testing.T) {
+	t.Run("list versions", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.getEventSchemas(w, httptest.NewRequest(http.MethodGet, eventSchemasPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &EventSchemasResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, []string{webhook.SchemaVersion1, webhook.SchemaVersion2}, resp.Versions)
+		require.Equal(t, webhook.CurrentSchemaVersion, resp.Current)
+	})
+
+	t.Run("get schema", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.getEventSchema(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, eventSchemasPath+"/1", nil),
+			map[string]string{"version": "1"}))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "application/schema+json", w.Header().Get("Content-Type"))
+
+		schema, err := webhook.Schema(webhook.SchemaVersion1)
+		require.NoError(t, err)
+		require.Equal(t, schema, w.Body.Bytes())
+	})
+
+	t.Run("negotiated media type", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		for accept, status := range map[string]int{"application/json": http.StatusOK, "text/html": http.StatusNotAcceptable} {
+			req := httptest.NewRequest(http.MethodGet, eventSchemasPath+"/1", nil)
+			req.Header.Set("Accept", accept)
+
+			w := httptest.NewRecorder()
+			o.getEventSchema(w, mux.SetURLVars(req, map[string]string{"version": "1"}))
+			require.Equal(t, status, w.Code)
+
+			if status == http.StatusOK {
+				require.Equal(t, accept, w.Header().Get("Content-Type"))
+			}
+		}
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.getEventSchema(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, eventSchemasPath+"/0", nil),
+			map[string]string{"version": "0"}))
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Contains(t, w.Body.String(), "unknown schema version")
+	})
+
+	t.Run("public access", func(t *testing.T) {
+		require.Equal(t, accessPublic, endpointAccess(eventSchemasPath))
+		require.Equal(t, accessPublic, endpointAccess(eventSchemaPath))
+	})
+}
@@ -0,0 +1,417 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/backup"
+	"github.com/trustbloc/hub-router/pkg/exportjob"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+// API endpoints.
+const (
+	auditExportPath = "/audit/export"
+	statsExportPath = "/stats/export"
+	exportJobPath   = "/export/jobs/{id}"
+)
+
+// Export constants.
+const (
+	csvFormat      = "csv"
+	csvContentType = "text/csv"
+
+	// exportJobSweepInterval is the interval the expired export jobs are deleted at.
+	exportJobSweepInterval = 10 * time.Minute
+)
+
+// ExportJobResp model.
+type ExportJobResp struct {
+	JobID  string `json:"jobID"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// exportFunc writes the csv records of the tenant, header included, within [from, to).
+type exportFunc func(w *csv.Writer, tenantID string, from, to time.Time) error
+
+// writeFunc writes the csv records of an export, header included.
+type writeFunc func(w *csv.Writer) error
+
+// exportHandler exports the audit entries, and their hourly stats, as csv files encrypted with the backup keys if
+// configured : streamed in the response, or exported by a job whose file is read once done.
+type exportHandler struct {
+	auditLog  *audit.Log
+	jobs      *exportjob.Store
+	encrypter *backup.Encrypter
+}
+
+func (o *Operation) initExport(config *Config) error {
+	jobs, err := exportjob.New(config.Storage.Transient, 0, 0)
+	if err != nil {
+		return fmt.Errorf("export jobs : %w", err)
+	}
+
+	o.export = &exportHandler{auditLog: o.auditLog, jobs: jobs, encrypter: config.Reporting.BackupEncrypter}
+
+	return nil
+}
+
+func (h *exportHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(auditExportPath, http.MethodGet, h.exportAudit),
+		support.NewHTTPHandler(statsExportPath, http.MethodGet, h.exportStats),
+		support.NewHTTPHandler(exportJobPath, http.MethodGet, h.getExportJob),
+	}
+}
+
+func (h *exportHandler) exportAudit(rw http.ResponseWriter, req *http.Request) {
+	h.export(rw, req, auditExportPath, "audit.csv", h.auditRecords)
+}
+
+func (h *exportHandler) exportStats(rw http.ResponseWriter, req *http.Request) {
+	h.export(rw, req, statsExportPath, "stats.csv", h.statsRecords)
+}
+
+func (h *exportHandler) export(rw http.ResponseWriter, req *http.Request, endpoint, fileName string, fn exportFunc) {
+	from, to, err := getTimeRange(req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), endpoint, logger)
+
+		return
+	}
+
+	if format := req.URL.Query().Get("format"); format != "" && format != csvFormat {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest,
+			fmt.Sprintf("unsupported export format : %s", format), endpoint, logger)
+
+		return
+	}
+
+	tenantID := tenant.FromContext(req.Context())
+
+	write := func(w *csv.Writer) error {
+		return fn(w, tenantID, from, to)
+	}
+
+	if req.URL.Query().Get("async") == "true" {
+		h.startExportJob(rw, endpoint, tenantID, fileName, write)
+
+		return
+	}
+
+	h.stream(rw, endpoint, fileName, write)
+}
+
+// stream writes the export file in the response as its records are written.
+func (h *exportHandler) stream(rw http.ResponseWriter, endpoint, fileName string, write writeFunc) {
+	w := &exportWriter{ResponseWriter: rw, fileName: h.fileName(fileName)}
+
+	err := h.writeExport(w, write)
+	if err == nil {
+		w.start()
+		logger.Infof("endpoint=[%s] msg=[%s]", endpoint, "success")
+
+		return
+	}
+
+	if !w.started {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to export - err=%s", err.Error()), endpoint, logger)
+
+		return
+	}
+
+	// the status is sent with the first rows : the connection is aborted, so that the client doesn't take the rows
+	// sent for the whole export
+	logger.Errorf("endpoint=[%s] export aborted : %s", endpoint, err)
+
+	panic(http.ErrAbortHandler)
+}
+
+func (h *exportHandler) startExportJob(rw http.ResponseWriter, endpoint, tenantID, fileName string, write writeFunc) {
+	job, err := h.jobs.Run(tenantID, func() ([]byte, string, error) {
+		var buf bytes.Buffer
+
+		if exportErr := h.writeExport(&buf, write); exportErr != nil {
+			return nil, "", exportErr
+		}
+
+		return buf.Bytes(), h.fileName(fileName), nil
+	})
+
+	if errors.Is(err, exportjob.ErrBusy) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusTooManyRequests, err.Error(), endpoint, logger)
+
+		return
+	}
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to start export job - err=%s", err.Error()), endpoint, logger)
+
+		return
+	}
+
+	rw.WriteHeader(http.StatusAccepted)
+	httputil.WriteResponseWithLog(rw, &ExportJobResp{JobID: job.ID, Status: job.Status}, endpoint, logger)
+}
+
+func (h *exportHandler) getExportJob(rw http.ResponseWriter, req *http.Request) {
+	job, err := h.jobs.Get(mux.Vars(req)["id"])
+	if errors.Is(err, exportjob.ErrNotFound) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "export job not found", exportJobPath, logger)
+
+		return
+	}
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get export job - err=%s", err.Error()), exportJobPath, logger)
+
+		return
+	}
+
+	// the jobs of the other tenants are not disclosed
+	if tenantID := tenant.FromContext(req.Context()); tenantID != "" && tenantID != job.Tenant {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "export job not found", exportJobPath, logger)
+
+		return
+	}
+
+	if job.Status != exportjob.StatusDone {
+		httputil.WriteResponseWithLog(rw, &ExportJobResp{JobID: job.ID, Status: job.Status, Error: job.Error},
+			exportJobPath, logger)
+
+		return
+	}
+
+	rw.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", job.FileName))
+
+	httputil.WriteContent(rw, exportContentType(job.FileName), job.Data, exportJobPath, logger)
+}
+
+// writeExport writes the csv file of the records to out, encrypted with the backup keys if configured.
+func (h *exportHandler) writeExport(out io.Writer, write writeFunc) error {
+	var encrypted io.WriteCloser
+
+	if h.encrypter != nil {
+		var err error
+
+		encrypted, err = h.encrypter.Writer(out)
+		if err != nil {
+			return fmt.Errorf("encrypt export : %w", err)
+		}
+
+		out = encrypted
+	}
+
+	w := csv.NewWriter(out)
+
+	if err := write(w); err != nil {
+		return err
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write csv : %w", err)
+	}
+
+	if encrypted != nil {
+		if err := encrypted.Close(); err != nil {
+			return fmt.Errorf("encrypt export : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// fileName returns the name of the export file, with the extension of the backups if encrypted.
+func (h *exportHandler) fileName(name string) string {
+	if h.encrypter != nil {
+		return name + backup.Extension
+	}
+
+	return name
+}
+
+// auditRecords writes the audit entries as they are read, a day at a time.
+func (h *exportHandler) auditRecords(w *csv.Writer, tenantID string, from, to time.Time) error {
+	if err := w.Write([]string{"id", "time", "type", "connectionID", "msgType", "detail", "threadID"}); err != nil {
+		return fmt.Errorf("write csv : %w", err)
+	}
+
+	return h.auditLog.Each(tenantID, from, to, func(e *audit.Entry) error {
+		err := w.Write([]string{
+			e.ID, e.Time.Format(time.RFC3339), e.Type, e.ConnectionID, e.MsgType, e.Detail, e.ThreadID,
+		})
+		if err != nil {
+			return fmt.Errorf("write csv : %w", err)
+		}
+
+		return nil
+	})
+}
+
+// statsRecords writes the hourly count of each audit entry type, restricted to the entries of the tenant if any.
+func (h *exportHandler) statsRecords(w *csv.Writer, tenantID string, from, to time.Time) error {
+	type bucket struct {
+		period time.Time
+		kind   string
+	}
+
+	counts := make(map[bucket]int)
+
+	err := h.auditLog.Each(tenantID, from, to, func(e *audit.Entry) error {
+		counts[bucket{period: e.Time.UTC().Truncate(time.Hour), kind: e.Type}]++
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	buckets := make([]bucket, 0, len(counts))
+	for b := range counts {
+		buckets = append(buckets, b)
+	}
+
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].period.Equal(buckets[j].period) {
+			return buckets[i].kind < buckets[j].kind
+		}
+
+		return buckets[i].period.Before(buckets[j].period)
+	})
+
+	if err = w.Write([]string{"period", "type", "count"}); err != nil {
+		return fmt.Errorf("write csv : %w", err)
+	}
+
+	for _, b := range buckets {
+		if err = w.Write([]string{b.period.Format(time.RFC3339), b.kind, strconv.Itoa(counts[b])}); err != nil {
+			return fmt.Errorf("write csv : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// exportWriter streams the export file in the response : the headers are sent with the first bytes written, so that
+// an export failing before can still be answered with an error.
+type exportWriter struct {
+	http.ResponseWriter
+	fileName string
+	started  bool
+}
+
+func (w *exportWriter) Write(b []byte) (int, error) {
+	w.start()
+
+	return w.ResponseWriter.Write(b)
+}
+
+// start sends the headers of the export file, once.
+func (w *exportWriter) start() {
+	if w.started {
+		return
+	}
+
+	w.started = true
+
+	w.Header().Set("Content-Type", exportContentType(w.fileName))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", w.fileName))
+	w.WriteHeader(http.StatusOK)
+}
+
+// exportContentType returns the content type of the export file, csv or encrypted.
+func exportContentType(fileName string) string {
+	if strings.HasSuffix(fileName, backup.Extension) {
+		return backup.ContentType
+	}
+
+	return csvContentType
+}
+
+// ProtocolUsage returns the number of audited DIDComm messages per message type within [from, to).
+func (o *Operation) ProtocolUsage(from, to time.Time) (map[string]int, error) {
+	entries, err := o.auditLog.Query(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]int)
+
+	for _, e := range entries {
+		if e.MsgType != "" {
+			usage[e.MsgType]++
+		}
+	}
+
+	return usage, nil
+}
+
+func (o *Operation) recordAudit(e *audit.Entry) {
+	if e.Tenant == "" {
+		e.Tenant = o.tenantOf(e.ConnectionID)
+	}
+
+	err := o.auditLog.Record(e)
+	if err != nil {
+		logger.Warnf("failed to record audit entry type=[%s] : %s", e.Type, err.Error())
+	}
+
+	o.recordHistory(e)
+}
+
+func getTimeRange(req *http.Request) (from, to time.Time, err error) {
+	from, err = parseTimeParam(req, "from")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	to, err = parseTimeParam(req, "to")
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return time.Time{}, time.Time{}, errors.New("invalid time range : 'from' must be before 'to'")
+	}
+
+	return from, to, nil
+}
+
+func parseTimeParam(req *http.Request, name string) (time.Time, error) {
+	val := req.URL.Query().Get(name)
+	if val == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid '%s' time, expected RFC3339 : %w", name, err)
+	}
+
+	return t, nil
+}
@@ -0,0 +1,400 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/backup"
+	"github.com/trustbloc/hub-router/pkg/exportjob"
+)
+
+func TestExportAudit(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.recordAudit(&audit.Entry{Type: audit.InvitationCreated, Detail: "inv-1"})
+		o.recordAudit(&audit.Entry{Type: audit.ConnectionCreated, ConnectionID: "conn-1"})
+
+		w := httptest.NewRecorder()
+		o.export.exportAudit(w, httptest.NewRequest(http.MethodGet, auditExportPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, csvContentType, w.Header().Get("Content-Type"))
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		require.Equal(t, "type", records[0][2])
+		require.Equal(t, audit.InvitationCreated, records[1][2])
+		require.Equal(t, "conn-1", records[2][3])
+	})
+
+	t.Run("time range filter", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.recordAudit(&audit.Entry{Type: audit.InvitationCreated, Time: time.Now().Add(-48 * time.Hour)})
+		o.recordAudit(&audit.Entry{Type: audit.ConnectionCreated})
+
+		from := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+		w := httptest.NewRecorder()
+		o.export.exportAudit(w, httptest.NewRequest(http.MethodGet, auditExportPath+"?from="+from, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		require.Equal(t, audit.ConnectionCreated, records[1][2])
+	})
+
+	t.Run("invalid time range", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.export.exportAudit(w, httptest.NewRequest(http.MethodGet, auditExportPath+"?from=invalid", nil))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "invalid 'from' time")
+
+		w = httptest.NewRecorder()
+		o.export.exportAudit(w, httptest.NewRequest(http.MethodGet, auditExportPath+"?to=invalid", nil))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "invalid 'to' time")
+
+		w = httptest.NewRecorder()
+		o.export.exportAudit(w, httptest.NewRequest(http.MethodGet,
+			auditExportPath+"?from=2021-02-01T00:00:00Z&to=2021-01-01T00:00:00Z", nil))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "invalid time range")
+	})
+
+	t.Run("unsupported format", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.export.exportAudit(w, httptest.NewRequest(http.MethodGet, auditExportPath+"?format=parquet", nil))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "unsupported export format : parquet")
+	})
+
+	t.Run("audit query error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.export.auditLog = auditLogWithErr(t, errors.New("query error"))
+
+		w := httptest.NewRecorder()
+		o.export.exportAudit(w, httptest.NewRequest(http.MethodGet, auditExportPath, nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "failed to export")
+	})
+}
+
+func TestExportStats(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		base := time.Date(2021, 6, 1, 10, 30, 0, 0, time.UTC)
+
+		o.recordAudit(&audit.Entry{Type: audit.ConnectionCreated, Time: base})
+		o.recordAudit(&audit.Entry{Type: audit.ConnectionCreated, Time: base.Add(time.Minute)})
+		o.recordAudit(&audit.Entry{Type: audit.InvitationCreated, Time: base})
+		o.recordAudit(&audit.Entry{Type: audit.InvitationCreated, Time: base.Add(-2 * time.Hour)})
+
+		w := httptest.NewRecorder()
+		o.export.exportStats(w, httptest.NewRequest(http.MethodGet, statsExportPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		require.Equal(t, [][]string{
+			{"period", "type", "count"},
+			{"2021-06-01T08:00:00Z", audit.InvitationCreated, "1"},
+			{"2021-06-01T10:00:00Z", audit.ConnectionCreated, "2"},
+			{"2021-06-01T10:00:00Z", audit.InvitationCreated, "1"},
+		}, records)
+	})
+
+	t.Run("audit query error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.export.auditLog = auditLogWithErr(t, errors.New("query error"))
+
+		w := httptest.NewRecorder()
+		o.export.exportStats(w, httptest.NewRequest(http.MethodGet, statsExportPath, nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
+
+func TestProtocolUsage(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.recordAudit(&audit.Entry{Type: audit.InvitationCreated})
+		o.recordAudit(&audit.Entry{Type: audit.ConnectionCreated, MsgType: createConnReq})
+		o.recordAudit(&audit.Entry{Type: audit.MessageFailed, MsgType: createConnReq})
+
+		usage, err := o.ProtocolUsage(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]int{createConnReq: 2}, usage)
+	})
+
+	t.Run("audit query error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.auditLog = auditLogWithErr(t, errors.New("query error"))
+
+		_, err = o.ProtocolUsage(time.Time{}, time.Time{})
+		require.Error(t, err)
+	})
+}
+
+func TestExportJob(t *testing.T) {
+	t.Run("async export", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.recordAudit(&audit.Entry{Type: audit.InvitationCreated})
+
+		w := httptest.NewRecorder()
+		o.export.exportAudit(w, httptest.NewRequest(http.MethodGet, auditExportPath+"?async=true", nil))
+		require.Equal(t, http.StatusAccepted, w.Code)
+
+		job := &ExportJobResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), job))
+		require.NotEmpty(t, job.JobID)
+		require.Equal(t, exportjob.StatusPending, job.Status)
+
+		require.Eventually(t, func() bool {
+			w = httptest.NewRecorder()
+			o.export.getExportJob(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, exportJobPath, nil),
+				map[string]string{"id": job.JobID}))
+
+			return w.Header().Get("Content-Type") == csvContentType
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.Equal(t, http.StatusOK, w.Code)
+		require.True(t, strings.HasPrefix(w.Body.String(), "id,time,type"))
+	})
+
+	t.Run("async export failure", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.export.auditLog = auditLogWithErr(t, errors.New("query error"))
+
+		w := httptest.NewRecorder()
+		o.export.exportStats(w, httptest.NewRequest(http.MethodGet, statsExportPath+"?async=true", nil))
+		require.Equal(t, http.StatusAccepted, w.Code)
+
+		job := &ExportJobResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), job))
+
+		require.Eventually(t, func() bool {
+			w = httptest.NewRecorder()
+			o.export.getExportJob(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, exportJobPath, nil),
+				map[string]string{"id": job.JobID}))
+
+			result := &ExportJobResp{}
+
+			return json.Unmarshal(w.Body.Bytes(), result) == nil && result.Status == exportjob.StatusFailed
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.Contains(t, w.Body.String(), "query error")
+	})
+
+	t.Run("save job error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.export.jobs = jobsWithErr(t, &mockstore.MockStore{
+			Store: make(map[string]mockstore.DBEntry), ErrPut: errors.New("put error"),
+		})
+
+		w := httptest.NewRecorder()
+		o.export.exportAudit(w, httptest.NewRequest(http.MethodGet, auditExportPath+"?async=true", nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "failed to start export job")
+	})
+
+	t.Run("too many jobs running", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		release := make(chan struct{})
+		defer close(release)
+
+		for i := 0; i < exportjob.DefaultMaxRunning; i++ {
+			_, err = o.export.jobs.Run("", func() ([]byte, string, error) {
+				<-release
+
+				return nil, "", nil
+			})
+			require.NoError(t, err)
+		}
+
+		w := httptest.NewRecorder()
+		o.export.exportAudit(w, httptest.NewRequest(http.MethodGet, auditExportPath+"?async=true", nil))
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+		require.Contains(t, w.Body.String(), exportjob.ErrBusy.Error())
+	})
+
+	t.Run("job not found", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.export.getExportJob(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, exportJobPath, nil),
+			map[string]string{"id": "invalid"}))
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("get job error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.export.jobs = jobsWithErr(t, &mockstore.MockStore{
+			Store: make(map[string]mockstore.DBEntry), ErrGet: errors.New("get error"),
+		})
+
+		w := httptest.NewRecorder()
+		o.export.getExportJob(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, exportJobPath, nil),
+			map[string]string{"id": "id"}))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "failed to get export job")
+	})
+}
+
+func TestExportStream(t *testing.T) {
+	o, err := New(config())
+	require.NoError(t, err)
+
+	row := []string{strings.Repeat("x", 1024)}
+
+	t.Run("rows streamed as written", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		o.export.stream(w, auditExportPath, "audit.csv", func(cw *csv.Writer) error {
+			for i := 0; i < 10; i++ {
+				require.NoError(t, cw.Write(row))
+			}
+
+			// the rows beyond the buffer of the csv writer are already sent
+			require.Equal(t, http.StatusOK, w.Code)
+			require.NotZero(t, w.Body.Len())
+
+			return nil
+		})
+
+		require.Equal(t, `attachment; filename="audit.csv"`, w.Header().Get("Content-Disposition"))
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 10)
+	})
+
+	t.Run("export aborted once started", func(t *testing.T) {
+		w := httptest.NewRecorder()
+
+		require.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			o.export.stream(w, auditExportPath, "audit.csv", func(cw *csv.Writer) error {
+				for i := 0; i < 10; i++ {
+					require.NoError(t, cw.Write(row))
+				}
+
+				return errors.New("query error")
+			})
+		})
+	})
+}
+
+func jobsWithErr(t *testing.T, store *mockstore.MockStore) *exportjob.Store {
+	t.Helper()
+
+	jobs, err := exportjob.New(mockstore.NewCustomMockStoreProvider(store), 0, 0)
+	require.NoError(t, err)
+
+	return jobs
+}
+
+func auditLogWithErr(t *testing.T, err error) *audit.Log {
+	t.Helper()
+
+	l, e := audit.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+		Store:    make(map[string]mockstore.DBEntry),
+		ErrQuery: err,
+	}))
+	require.NoError(t, e)
+
+	return l
+}
+
+func TestEncryptedExport(t *testing.T) {
+	encrypter, err := backup.NewEncrypter("age1xmwwc06ly3ee5rytxm9mflaz2u56jjj36s0mypdrwsvlul66mv4q47ryef")
+	require.NoError(t, err)
+
+	config := config()
+	config.Reporting.BackupEncrypter = encrypter
+
+	o, err := New(config)
+	require.NoError(t, err)
+
+	o.recordAudit(&audit.Entry{Type: audit.InvitationCreated, Detail: "inv-1"})
+
+	t.Run("export", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		o.export.exportAudit(w, httptest.NewRequest(http.MethodGet, auditExportPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, backup.ContentType, w.Header().Get("Content-Type"))
+		require.Equal(t, `attachment; filename="audit.csv.age"`, w.Header().Get("Content-Disposition"))
+		require.True(t, strings.HasPrefix(w.Body.String(), "age-encryption.org/v1\n"))
+		require.NotContains(t, w.Body.String(), "inv-1")
+	})
+
+	t.Run("async export", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		o.export.exportStats(w, httptest.NewRequest(http.MethodGet, statsExportPath+"?async=true", nil))
+		require.Equal(t, http.StatusAccepted, w.Code)
+
+		job := &ExportJobResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), job))
+
+		require.Eventually(t, func() bool {
+			w = httptest.NewRecorder()
+			o.export.getExportJob(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, exportJobPath, nil),
+				map[string]string{"id": job.JobID}))
+
+			return w.Header().Get("Content-Type") == backup.ContentType
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.Equal(t, `attachment; filename="stats.csv.age"`, w.Header().Get("Content-Disposition"))
+
+		// the job data is encrypted at rest
+		done, err := o.export.jobs.Get(job.JobID)
+		require.NoError(t, err)
+		require.NotContains(t, string(done.Data), audit.InvitationCreated)
+	})
+}
@@ -0,0 +1,246 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/ha"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// API endpoints.
+const (
+	failoverPath        = "/failover"
+	failoverPromotePath = failoverPath + "/promote"
+)
+
+const (
+	haTopic = "ha"
+
+	decisionFailover = "failover"
+)
+
+// PromoteResp model.
+type PromoteResp struct {
+	*ha.Status
+}
+
+// failoverHandler serves the promotion of the node of an active/standby deployment, and fences the REST API and the
+// DIDComm messages of the standby node. Its node is nil if the router isn't part of an active/standby deployment.
+type failoverHandler struct {
+	node *ha.Node
+}
+
+func (o *Operation) initFailover(config *Config) error {
+	o.failover = &failoverHandler{}
+
+	if !config.HA.Enabled() {
+		return nil
+	}
+
+	node, err := ha.New(config.Storage.Persistent, o.locker, config.HA, o.roleChanged)
+	if err != nil {
+		return fmt.Errorf("ha node: %w", err)
+	}
+
+	o.failover.node = node
+
+	return nil
+}
+
+func (h *failoverHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(failoverPromotePath, http.MethodPost, h.promote),
+	}
+}
+
+// startFailover renews or reads the lease periodically, the queue checks are paused on a standby node.
+func (o *Operation) startFailover() {
+	if o.failover.node == nil {
+		return
+	}
+
+	if !o.failover.node.Active() && o.queue != nil {
+		o.queue.Pause()
+	}
+
+	o.failover.node.Start()
+}
+
+// promote makes the node active : the fencing token is transferred from the active node, the outbox is replayed and
+// the queues are resumed before the response is written.
+func (h *failoverHandler) promote(rw http.ResponseWriter, _ *http.Request) {
+	if h.node == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "active/standby not enabled", failoverPromotePath,
+			logger)
+
+		return
+	}
+
+	status, err := h.node.Promote()
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusConflict,
+			fmt.Sprintf("failed to promote the node - err=%s", err.Error()), failoverPromotePath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, &PromoteResp{Status: status}, failoverPromotePath, logger)
+}
+
+// Fence is the REST API middleware rejecting the requests changing the state of the router (all but GET and HEAD)
+// with 503 on a standby node : the standby nodes serve the health check, the read APIs and the promotion only.
+func (o *Operation) Fence(next http.Handler) http.Handler {
+	return o.failover.fence(next)
+}
+
+func (h *failoverHandler) fence(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if h.node == nil || req.Method == http.MethodGet || req.Method == http.MethodHead ||
+			req.URL.Path == failoverPromotePath {
+			next.ServeHTTP(rw, req)
+
+			return
+		}
+
+		if err := h.node.Admit(); err != nil {
+			httputil.WriteErrorResponseWithLog(rw, http.StatusServiceUnavailable, err.Error(), req.URL.Path, logger)
+
+			return
+		}
+
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// admit returns an error wrapping ha.ErrStandby if the node is a standby node.
+func (h *failoverHandler) admit() error {
+	if h.node == nil {
+		return nil
+	}
+
+	return h.node.Admit()
+}
+
+// status returns the status of the node, nil if not part of an active/standby deployment.
+func (h *failoverHandler) status() *ha.Status {
+	if h.node == nil {
+		return nil
+	}
+
+	return h.node.Status()
+}
+
+// role returns the role of the node, empty if not part of an active/standby deployment.
+func (h *failoverHandler) role() string {
+	if h.node == nil {
+		return ""
+	}
+
+	return h.node.Status().Role
+}
+
+func (o *Operation) roleChanged(status *ha.Status, previous string) {
+	logger.Infof("node id=[%s] role=[%s] previous=[%s] token=[%d]", status.NodeID, status.Role, previous, status.Token)
+
+	e := &events.RoleEvent{
+		Time: time.Now().UTC(), NodeID: status.NodeID, Role: status.Role, Previous: previous, Token: status.Token,
+	}
+
+	o.events.Publish(e)
+	o.recordAudit(&audit.Entry{
+		Type: audit.RoleChanged, Detail: fmt.Sprintf("node=%s role=%s token=%d", status.NodeID, status.Role, status.Token),
+	})
+
+	go func() {
+		if err := o.webhook.Notify(haTopic, e); err != nil {
+			logger.Warnf("failed to notify role change : %s", err)
+		}
+	}()
+
+	// the node started active has nothing to resume
+	if o.failover.node == nil {
+		return
+	}
+
+	if status.Role == ha.RoleActive {
+		o.resume(status.Previous)
+	} else if o.queue != nil {
+		o.queue.Pause()
+	}
+}
+
+// resume replays the outbox of the previous active node, and resumes the queue checks.
+func (o *Operation) resume(previous *ha.Lease) {
+	replayed, err := o.replayOutbox(previous)
+	if err != nil {
+		logger.Warnf("failed to replay the outbox : %s", err)
+	}
+
+	logger.Infof("outbox replayed : %d messages", replayed)
+
+	if o.queue != nil {
+		if err = o.queue.Resume(); err != nil {
+			logger.Warnf("queue depth check : %s", err)
+		}
+	}
+}
+
+// replayOutbox replays the messages in flight on the previous active node when it was lost : the messages it
+// dead-lettered with the fencing token taken over, failed on a transient error and never replayed. The messages failed
+// on a permanent error, eg: rejected by the policy, aren't replayed. It returns the number of messages replayed.
+func (o *Operation) replayOutbox(previous *ha.Lease) (int, error) {
+	if previous == nil {
+		return 0, nil
+	}
+
+	o.deadLetters.mutex.Lock()
+	defer o.deadLetters.mutex.Unlock()
+
+	entries, err := o.deadLetters.store.List(deadletter.StatusDeadLettered)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+
+	for _, entry := range entries {
+		if entry.Replays > 0 || entry.NodeID != previous.NodeID || entry.Token != previous.Token ||
+			!retryable(entry.Problem) {
+			continue
+		}
+
+		msg, err := service.ParseDIDCommMsgMap(entry.Message)
+		if err != nil {
+			logger.Warnf("failed to parse dead-lettered message %s : %s", entry.ID, err)
+
+			continue
+		}
+
+		entry.Replays++
+		entry.AddDecision(decisionReplay, decisionFailover)
+
+		o.replay(msg, entry)
+
+		if err = o.deadLetters.store.Put(entry); err != nil {
+			return replayed, err
+		}
+
+		replayed++
+	}
+
+	return replayed, nil
+}
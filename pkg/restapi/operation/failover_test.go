@@ -0,0 +1,321 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockdispatcher "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/dispatcher"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockvdri "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/ha"
+	"github.com/trustbloc/hub-router/pkg/inbound"
+	"github.com/trustbloc/hub-router/pkg/internal/mock/messenger"
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/retryadvice"
+)
+
+func TestFailover(t *testing.T) {
+	health := func(o *Operation) *healthCheckResp {
+		w := httptest.NewRecorder()
+		o.healthCheckHandler(w, httptest.NewRequest(http.MethodGet, healthCheckPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &healthCheckResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+
+		return resp
+	}
+
+	fence := func(o *Operation, method string) int {
+		w := httptest.NewRecorder()
+		o.Fence(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+			rw.WriteHeader(http.StatusNoContent)
+		})).ServeHTTP(w, httptest.NewRequest(method, tenantsPath, nil))
+
+		return w.Code
+	}
+
+	t.Run("single node", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		require.Empty(t, health(o).Role)
+		require.Equal(t, http.StatusNoContent, fence(o, http.MethodPost))
+		require.NoError(t, o.AdmitEnvelope(withHeader(&transport.Envelope{Message: []byte("{}")})))
+	})
+
+	t.Run("standby node", func(t *testing.T) {
+		cfg := config()
+		cfg.HA = &ha.Config{Mode: ha.ModeStandby, NodeID: "node-2"}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		defer o.failover.node.Stop()
+
+		require.Equal(t, ha.RoleStandby, health(o).Role)
+		require.Equal(t, http.StatusNoContent, fence(o, http.MethodGet))
+		require.Equal(t, http.StatusServiceUnavailable, fence(o, http.MethodPost))
+
+		err = o.AdmitEnvelope(withHeader(&transport.Envelope{Message: []byte("{}")}))
+		require.True(t, errors.Is(err, ha.ErrStandby))
+	})
+
+	t.Run("standby node refuses relayed forward", func(t *testing.T) {
+		relay := func(t *testing.T, cfg *Config) (int, error) {
+			t.Helper()
+
+			relayed := 0
+
+			cfg.Admission.MultiHopForward = true
+			cfg.Aries.(*mockprovider.Provider).VDRegistryValue = &mockvdri.MockVDRegistry{
+				ResolveValue: mockdiddoc.GetMockDIDDoc(t),
+			}
+			cfg.Aries.(*mockprovider.Provider).OutboundDispatcherValue = &mockdispatcher.MockOutbound{
+				ValidateForward: func(interface{}, *service.Destination) error {
+					relayed++
+
+					return nil
+				},
+			}
+
+			it := &mockInbound{}
+			m := inbound.NewMiddleware(it, inbound.Admit, inbound.Verify, inbound.Relay, inbound.Gate)
+			cfg.Transports.Inbound = []*inbound.Middleware{m}
+
+			o, err := New(cfg)
+			require.NoError(t, err)
+
+			if o.failover.node != nil {
+				defer o.failover.node.Stop()
+			}
+
+			require.NoError(t, m.Start(&mockTransportProvider{}))
+
+			msg, err := json.Marshal(&model.Forward{
+				Type: service.ForwardMsgType, ID: uuid.New().String(), To: "did:example:mediator",
+				Msg: &model.Envelope{CipherText: "inner"},
+			})
+			require.NoError(t, err)
+
+			err = it.handler(&transport.Envelope{Message: msg})
+
+			return relayed, err
+		}
+
+		relayed, err := relay(t, config())
+		require.NoError(t, err)
+		require.Equal(t, 1, relayed)
+
+		cfg := config()
+		cfg.HA = &ha.Config{Mode: ha.ModeStandby, NodeID: "node-2"}
+
+		relayed, err = relay(t, cfg)
+		require.True(t, errors.Is(err, ha.ErrStandby))
+		require.Zero(t, relayed)
+	})
+
+	t.Run("active node fenced", func(t *testing.T) {
+		cfg := config()
+		cfg.Events = events.NewBus()
+		cfg.HA = &ha.Config{Mode: ha.ModeActive, NodeID: "node-1", RenewInterval: 10 * time.Millisecond}
+
+		sub := cfg.Events.Subscribe(10, events.TopicHA)
+		defer sub.Unsubscribe()
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		defer o.failover.node.Stop()
+
+		e, ok := (<-sub.C).(*events.RoleEvent)
+		require.True(t, ok)
+		require.Equal(t, ha.RoleActive, e.Role)
+		require.Equal(t, ha.RoleStandby, e.Previous)
+		require.Equal(t, uint64(1), e.Token)
+
+		require.Equal(t, ha.RoleActive, health(o).Role)
+		require.Equal(t, http.StatusNoContent, fence(o, http.MethodPost))
+		require.NoError(t, o.AdmitEnvelope(withHeader(&transport.Envelope{Message: []byte("{}")})))
+
+		// node-2 admits the messages once the lease taken from node-1 expired
+		node2, err := ha.New(cfg.Storage.Persistent, o.locker,
+			&ha.Config{Mode: ha.ModeStandby, NodeID: "node-2", RenewInterval: 10 * time.Millisecond}, nil)
+		require.NoError(t, err)
+
+		_, err = node2.Promote()
+		require.NoError(t, err)
+		require.NoError(t, o.failover.node.Renew())
+
+		e, ok = (<-sub.C).(*events.RoleEvent)
+		require.True(t, ok)
+		require.Equal(t, ha.RoleStandby, e.Role)
+		require.Equal(t, ha.RoleActive, e.Previous)
+
+		require.Equal(t, http.StatusServiceUnavailable, fence(o, http.MethodPost))
+		require.True(t, errors.Is(o.AdmitEnvelope(withHeader(&transport.Envelope{Message: []byte("{}")})), ha.ErrStandby))
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		cfg := config()
+		cfg.HA = &ha.Config{Mode: "primary"}
+
+		_, err := New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "ha node")
+	})
+}
+
+func TestPromote(t *testing.T) {
+	promote := func(o *Operation) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.Fence(http.HandlerFunc(o.failover.promote)).ServeHTTP(w,
+			httptest.NewRequest(http.MethodPost, failoverPromotePath, nil))
+
+		return w
+	}
+
+	t.Run("standby node promoted", func(t *testing.T) {
+		cfg := config()
+		cfg.Events = events.NewBus()
+		cfg.Storage.Locks = lock.NewLocal()
+
+		// the node promoted waits for the lease of the active node to expire
+		active, err := ha.New(cfg.Storage.Persistent, cfg.Storage.Locks,
+			&ha.Config{Mode: ha.ModeActive, NodeID: "node-1", RenewInterval: 10 * time.Millisecond}, nil)
+		require.NoError(t, err)
+
+		cfg.HA = &ha.Config{Mode: ha.ModeStandby, NodeID: "node-2", RenewInterval: 10 * time.Millisecond}
+
+		sub := cfg.Events.Subscribe(10, events.TopicHA)
+		defer sub.Unsubscribe()
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		defer o.failover.node.Stop()
+
+		didDocBytes, err := mockdiddoc.GetMockDIDDoc(t).JSONBytes()
+		require.NoError(t, err)
+
+		// dead-lettered by the active node
+		deadLetter := func(entry *deadletter.Entry, msg interface{}, err error) *deadletter.Entry {
+			o.deadLetter(receivedMsg(t, msg), entry, err)
+
+			entry.NodeID, entry.Token = "node-1", 1
+			require.NoError(t, o.deadLetters.store.Put(entry))
+
+			return entry
+		}
+
+		createConn := CreateConnReq{
+			ID: uuid.New().String(), Type: createConnReq, Data: &CreateConnReqData{DIDDoc: didDocBytes},
+		}
+
+		outbox := deadLetter(&deadletter.Entry{}, createConn, fmt.Errorf("leader lost : %w", retryadvice.ErrTransient))
+
+		replayed := deadLetter(&deadletter.Entry{Replays: 1},
+			&DIDCommMsg{ID: uuid.New().String(), Type: "unsupported"}, retryadvice.ErrTransient)
+
+		rejected := deadLetter(&deadletter.Entry{}, createConn,
+			withProblem(problemPolicyRejected, errors.New("did method not allowed")))
+
+		previousToken := deadLetter(&deadletter.Entry{}, createConn, retryadvice.ErrTransient)
+		previousToken.Token = 0
+		require.NoError(t, o.deadLetters.store.Put(previousToken))
+
+		o.messenger = &messenger.MockMessenger{}
+
+		w := promote(o)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &PromoteResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, ha.RoleActive, resp.Role)
+		require.Equal(t, uint64(2), resp.Token)
+
+		e, ok := (<-sub.C).(*events.RoleEvent)
+		require.True(t, ok)
+		require.Equal(t, ha.RoleActive, e.Role)
+
+		entry, err := o.deadLetters.store.Get(outbox.ID)
+		require.NoError(t, err)
+		require.Equal(t, deadletter.StatusResolved, entry.Status)
+		require.Equal(t, decisionReplay, entry.Decisions[0].Step)
+		require.Equal(t, decisionFailover, entry.Decisions[0].Outcome)
+
+		entry, err = o.deadLetters.store.Get(replayed.ID)
+		require.NoError(t, err)
+		require.Equal(t, deadletter.StatusDeadLettered, entry.Status)
+		require.Equal(t, 1, entry.Replays)
+
+		// the messages failed on a permanent error, or dead-lettered before the token was taken, aren't replayed
+		for _, id := range []string{rejected.ID, previousToken.ID} {
+			entry, err = o.deadLetters.store.Get(id)
+			require.NoError(t, err)
+			require.Equal(t, deadletter.StatusDeadLettered, entry.Status)
+			require.Zero(t, entry.Replays)
+		}
+
+		entry, err = o.deadLetters.store.Get(rejected.ID)
+		require.NoError(t, err)
+		require.Equal(t, problemPolicyRejected, entry.Problem)
+
+		require.NoError(t, active.Renew())
+		require.False(t, active.Active())
+
+		w = promote(o)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, uint64(2), resp.Token)
+	})
+
+	t.Run("not an active/standby deployment", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := promote(o)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+type mockInbound struct {
+	transport.InboundTransport
+	handler transport.InboundMessageHandler
+}
+
+func (m *mockInbound) Start(prov transport.Provider) error {
+	m.handler = prov.InboundMessageHandler()
+
+	return nil
+}
+
+type mockTransportProvider struct {
+	transport.Provider
+}
+
+func (m *mockTransportProvider) InboundMessageHandler() transport.InboundMessageHandler {
+	return func(*transport.Envelope) error {
+		return nil
+	}
+}
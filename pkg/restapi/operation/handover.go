@@ -0,0 +1,359 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/backpressure"
+	"github.com/trustbloc/hub-router/pkg/history"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/migration"
+	"github.com/trustbloc/hub-router/pkg/preparse"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+// API endpoints.
+const (
+	walletHandoverPath = walletPath + "/handover"
+)
+
+const (
+	maxHandoverSize       = 4096
+	handoverSweepInterval = 10 * time.Minute
+)
+
+// handoverHandler hands the wallets over to their new mediator, on the request of the wallets or of their backend, and
+// redirects the forwards addressed to them during the grace period. Its store is nil if the handovers aren't enabled.
+type handoverHandler struct {
+	store       *migration.Store
+	forwarder   *migration.Forwarder
+	gracePeriod time.Duration
+	connections *connection.Lookup
+	// visible returns true if the connection is visible to the tenant.
+	visible     func(tenantID, connID string) bool
+	recordAudit func(*audit.Entry)
+	// forwardRecipient returns the connection and the DID of the wallet the forward with the header is addressed to.
+	forwardRecipient func(header *preparse.Header) (string, string, bool)
+}
+
+// initHandovers initializes the handovers of the wallets to another mediator, if a grace period is set.
+func (o *Operation) initHandovers(config *Config) error {
+	o.handover = &handoverHandler{
+		gracePeriod: config.Admission.HandoverGracePeriod, connections: o.connections, visible: o.visible,
+		recordAudit: o.recordAudit, forwardRecipient: o.forwardRecipient,
+	}
+
+	if config.Admission.HandoverGracePeriod <= 0 {
+		return nil
+	}
+
+	var err error
+
+	o.handover.store, err = migration.New(config.Storage.Persistent, o.locker)
+	if err != nil {
+		return fmt.Errorf("handover store: %w", err)
+	}
+
+	o.handover.forwarder = migration.NewForwarder(o.pickup, config.Aries.OutboundDispatcher(),
+		config.Transports.Packager, config.Aries.KMS())
+
+	return nil
+}
+
+func (h *handoverHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(walletHandoverPath, http.MethodPost, h.postHandover),
+		support.NewHTTPHandler(walletHandoverPath, http.MethodGet, h.getHandover),
+		support.NewHTTPHandler(walletHandoverPath, http.MethodDelete, h.deleteHandover),
+	}
+}
+
+// handleHandover hands the wallet over to its new mediator, on the request of the wallet : the reply acknowledges the
+// handover with the end of its grace period.
+func (h *handoverHandler) handleHandover(msg service.DIDCommMsg) (service.DIDCommMsgMap, error) {
+	handoverMsg := &migration.HandoverMsg{}
+
+	if err := msg.Decode(handoverMsg); err != nil {
+		return nil, withProblem(problemInvalidMsg, fmt.Errorf("decode handover : %w", err))
+	}
+
+	target := handoverMsg.Target()
+
+	if err := target.Validate(); err != nil {
+		return nil, withProblem(problemInvalidMsg, err)
+	}
+
+	inbound, ok := msg.(*aries.InboundMsg)
+	if !ok || inbound.TheirDID == "" {
+		return nil, withProblem(problemInvalidMsg, errors.New("handover connection not found"))
+	}
+
+	connID, err := h.connections.GetConnectionIDByDIDs(inbound.MyDID, inbound.TheirDID)
+	if err != nil {
+		return nil, withProblem(problemInvalidMsg, fmt.Errorf("handover connection : %w", err))
+	}
+
+	handover, err := h.start(connID, inbound.TheirDID, target, migration.ViaMessage, history.ActorWallet,
+		migration.AnyRevision)
+	if err != nil {
+		return nil, withProblem(problemInternal, err)
+	}
+
+	return service.NewDIDCommMsgMap(&migration.HandoverAckMsg{
+		ID:        uuid.New().String(),
+		Type:      migration.HandoverAckMsgType,
+		ExpiresAt: handover.ExpiresAt,
+		Forwarded: handover.Forwarded,
+	}), nil
+}
+
+// start saves the handover of the wallet for the grace period, if the handover in progress has the revision,
+// and forwards the messages queued for the wallet to its new mediator.
+func (h *handoverHandler) start(connID, walletDID string, t *migration.Target, via, actor string, revision int) (
+	*migration.Handover, error) {
+	now := time.Now().UTC()
+
+	handover := &migration.Handover{
+		Target: *t, ConnectionID: connID, WalletDID: walletDID, Via: via, RequestedAt: now,
+		ExpiresAt: now.Add(h.gracePeriod),
+	}
+
+	// the new forwards are redirected before the queued messages are drained
+	err := h.store.SaveIf(handover, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	handover.Forwarded, err = h.forwarder.Drain(handover)
+	if err != nil {
+		logger.Warnf("failed to forward the messages queued for connection id=[%s] : %s", connID, err)
+	}
+
+	// the handover cancelled or replaced meanwhile is kept
+	err = h.store.SaveIf(handover, handover.Revision)
+	if errors.Is(err, migration.ErrRevisionConflict) {
+		logger.Warnf("handover of connection id=[%s] changed while forwarding the queued messages : %s", connID, err)
+	} else if err != nil {
+		return nil, err
+	}
+
+	h.recordAudit(&audit.Entry{
+		Type: audit.WalletHandover, ConnectionID: connID, MsgType: migration.HandoverMsgType,
+		Detail: fmt.Sprintf("endpoint=%s via=%s forwarded=%d", handover.Endpoint, via, handover.Forwarded),
+		Actor:  actor,
+	})
+
+	return handover, nil
+}
+
+// postHandover hands the wallet of the connection over to its new mediator, on the request of its backend.
+func (h *handoverHandler) postHandover(rw http.ResponseWriter, req *http.Request) {
+	connID, walletDID, ok := h.wallet(rw, req)
+	if !ok {
+		return
+	}
+
+	target := &migration.Target{}
+
+	err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxHandoverSize)).Decode(target)
+	if err == nil {
+		err = target.Validate()
+	}
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, fmt.Sprintf("invalid handover : %s", err),
+			walletHandoverPath, logger)
+
+		return
+	}
+
+	revision, ok := h.precondition(rw, req, walletDID)
+	if !ok {
+		return
+	}
+
+	handover, err := h.start(connID, walletDID, target, migration.ViaREST, requestActor(req), revision)
+
+	switch {
+	case errors.Is(err, migration.ErrRevisionConflict):
+		writePreconditionFailed(rw, err, walletHandoverPath)
+
+		return
+	case err != nil:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to hand the wallet over - err=%s", err.Error()), walletHandoverPath, logger)
+
+		return
+	}
+
+	setETag(rw, handover.Revision)
+	rw.WriteHeader(http.StatusCreated)
+	httputil.WriteResponseWithLog(rw, handover, walletHandoverPath, logger)
+}
+
+// getHandover returns the handover of the wallet of the connection in progress.
+func (h *handoverHandler) getHandover(rw http.ResponseWriter, req *http.Request) {
+	_, walletDID, ok := h.wallet(rw, req)
+	if !ok {
+		return
+	}
+
+	handover, err := h.store.Get(walletDID)
+	if h.storeError(rw, err) {
+		return
+	}
+
+	setETag(rw, handover.Revision)
+	httputil.WriteResponseWithLog(rw, handover, walletHandoverPath, logger)
+}
+
+// deleteHandover ends the handover of the wallet of the connection before its grace period is over : the messages
+// are queued for the wallet again.
+func (h *handoverHandler) deleteHandover(rw http.ResponseWriter, req *http.Request) {
+	connID, walletDID, ok := h.wallet(rw, req)
+	if !ok {
+		return
+	}
+
+	revision, ok := h.precondition(rw, req, walletDID)
+	if !ok {
+		return
+	}
+
+	if h.storeError(rw, h.store.CancelIf(walletDID, revision)) {
+		return
+	}
+
+	h.recordAudit(&audit.Entry{Type: audit.HandoverCancelled, ConnectionID: connID, Actor: requestActor(req)})
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+// wallet returns the connection of the handover request and the DID of its wallet, or writes the error
+// response if the handovers aren't enabled or the connection isn't found.
+func (h *handoverHandler) wallet(rw http.ResponseWriter, req *http.Request) (string, string, bool) {
+	if h.store == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "wallet handover not enabled", walletHandoverPath,
+			logger)
+
+		return "", "", false
+	}
+
+	connID := mux.Vars(req)["id"]
+
+	conn, err := h.connections.GetConnectionRecord(connID)
+	if err != nil || !h.visible(tenant.FromContext(req.Context()), connID) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, fmt.Sprintf("connection not found : %s", connID),
+			walletHandoverPath, logger)
+
+		return "", "", false
+	}
+
+	return connID, conn.TheirDID, true
+}
+
+// precondition returns the revision of the handover of the wallet the change of the request is conditional
+// on, anyRevision if not conditional, or writes the error response.
+func (h *handoverHandler) precondition(rw http.ResponseWriter, req *http.Request, walletDID string) (int, bool) {
+	p, err := ifMatch(req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), walletHandoverPath, logger)
+
+		return 0, false
+	}
+
+	if p == nil {
+		return anyRevision, true
+	}
+
+	handover, err := h.store.Get(walletDID)
+	if errors.Is(err, migration.ErrNotFound) {
+		return p.revision(0), true
+	}
+
+	if h.storeError(rw, err) {
+		return 0, false
+	}
+
+	return p.revision(handover.Revision), true
+}
+
+// storeError writes the error response of the handover store, it returns false if there is no error.
+func (h *handoverHandler) storeError(rw http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, migration.ErrNotFound):
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, err.Error(), walletHandoverPath, logger)
+	case errors.Is(err, migration.ErrRevisionConflict):
+		writePreconditionFailed(rw, err, walletHandoverPath)
+	default:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get handover - err=%s", err.Error()), walletHandoverPath, logger)
+	}
+
+	return true
+}
+
+// redirect returns an error wrapping backpressure.ErrSuppressed if the envelope is a forward addressed to a
+// wallet handed over to another mediator : the forwarded message is posted to the new mediator instead of being
+// queued. The forward is admitted, and queued, if it fails to be posted.
+func (h *handoverHandler) redirect(envelope *transport.Envelope, header *preparse.Header) error {
+	if h.store == nil {
+		return nil
+	}
+
+	_, walletDID, ok := h.forwardRecipient(header)
+	if !ok {
+		return nil
+	}
+
+	handover, err := h.store.Get(walletDID)
+	if err != nil {
+		if !errors.Is(err, migration.ErrNotFound) {
+			logger.Warnf("failed to get the handover of the wallet : %s", err)
+		}
+
+		return nil
+	}
+
+	forward := &model.Forward{}
+
+	if err = json.Unmarshal(envelope.Message, forward); err != nil || forward.Msg == nil {
+		return nil // nolint:nilerr // queued for the wallet
+	}
+
+	msg, err := json.Marshal(forward.Msg)
+	if err == nil {
+		err = h.forwarder.Forward(&handover.Target, msg)
+	}
+
+	if err != nil {
+		logger.Warnf("failed to forward to the new mediator of the wallet, queued : %s", err)
+
+		return nil
+	}
+
+	logger.Debugf("forward redirected to the new mediator : id=%s endpoint=%s", forward.ID, handover.Endpoint)
+
+	return fmt.Errorf("%w : forward %s redirected to %s", backpressure.ErrSuppressed, forward.ID, handover.Endpoint)
+}
@@ -0,0 +1,337 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockdispatcher "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/dispatcher"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/backpressure"
+	"github.com/trustbloc/hub-router/pkg/migration"
+	"github.com/trustbloc/hub-router/pkg/routes"
+)
+
+type handoverOutbound struct {
+	mockdispatcher.MockOutbound
+	mutex     sync.Mutex
+	sent      []*service.Destination
+	err       error
+	onForward func()
+}
+
+func (o *handoverOutbound) Forward(_ interface{}, des *service.Destination) error {
+	if o.onForward != nil {
+		o.onForward()
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.err != nil {
+		return o.err
+	}
+
+	o.sent = append(o.sent, des)
+
+	return nil
+}
+
+func (o *handoverOutbound) forwarded() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	return len(o.sent)
+}
+
+func TestHandover(t *testing.T) {
+	forward := &transport.Envelope{
+		Message: []byte(`{"@id":"msg-1","@type":"https://didcomm.org/routing/1.0/forward","to":"key-1","msg":{}}`),
+	}
+
+	handoverReq := func(o *Operation, method, connID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := mux.SetURLVars(httptest.NewRequest(method, walletsPath+"/"+connID+"/handover",
+			strings.NewReader(body)), map[string]string{"id": connID})
+
+		switch method {
+		case http.MethodPost:
+			o.handover.postHandover(w, req)
+		case http.MethodGet:
+			o.handover.getHandover(w, req)
+		default:
+			o.handover.deleteHandover(w, req)
+		}
+
+		return w
+	}
+
+	newOperation := func(t *testing.T, outbound *handoverOutbound) *Operation {
+		t.Helper()
+
+		ariesStorage := mem.NewProvider()
+
+		mediated, err := routes.Open(ariesStorage)
+		require.NoError(t, err)
+		require.NoError(t, mediated.Route("key-1", "did:wallet"))
+
+		cfg := config()
+		cfg.Aries.(*mockprovider.Provider).StorageProviderValue = ariesStorage
+		cfg.Aries.(*mockprovider.Provider).OutboundDispatcherValue = outbound
+		cfg.Admission.HandoverGracePeriod = time.Hour
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		queueMessages(t, o.pickup, "did:wallet", "key-1", 1)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-1", State: connection.StateNameCompleted, ThreadID: "thid-1",
+			MyDID: "did:router", TheirDID: "did:wallet", Namespace: connection.MyNSPrefix,
+		}))
+
+		return o
+	}
+
+	t.Run("handover not enabled", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		_, ok := o.msgService(migration.HandoverMsgType)
+		require.False(t, ok)
+
+		require.Equal(t, http.StatusNotFound,
+			handoverReq(o, http.MethodPost, "conn-1", `{"endpoint":"https://mediator.example.com"}`).Code)
+		require.NoError(t, o.gateForward(withHeader(forward)))
+	})
+
+	t.Run("handover requested through the API", func(t *testing.T) {
+		outbound := &handoverOutbound{}
+		o := newOperation(t, outbound)
+
+		require.NoError(t, o.gateForward(withHeader(forward)))
+		require.Equal(t, http.StatusNotFound, handoverReq(o, http.MethodGet, "conn-1", "").Code)
+
+		w := handoverReq(o, http.MethodPost, "conn-1", `{"endpoint":"https://mediator.example.com"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		h := &migration.Handover{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), h))
+		require.Equal(t, "https://mediator.example.com", h.Endpoint)
+		require.Equal(t, "did:wallet", h.WalletDID)
+		require.Equal(t, migration.ViaREST, h.Via)
+		require.Equal(t, 1, h.Forwarded)
+		require.WithinDuration(t, time.Now().Add(time.Hour), h.ExpiresAt, time.Minute)
+
+		// the forwards to the wallet are redirected to the new mediator
+		err := o.gateForward(withHeader(forward))
+		require.True(t, errors.Is(err, backpressure.ErrSuppressed))
+		require.Equal(t, 2, outbound.forwarded())
+		require.Equal(t, "https://mediator.example.com", outbound.sent[1].ServiceEndpoint)
+
+		w = handoverReq(o, http.MethodGet, "conn-1", "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		entries, err := o.auditLog.Query(time.Time{}, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, audit.WalletHandover, entries[0].Type)
+		require.Equal(t, "conn-1", entries[0].ConnectionID)
+
+		require.Equal(t, http.StatusNoContent, handoverReq(o, http.MethodDelete, "conn-1", "").Code)
+		require.Equal(t, http.StatusNotFound, handoverReq(o, http.MethodDelete, "conn-1", "").Code)
+		require.NoError(t, o.gateForward(withHeader(forward)))
+	})
+
+	t.Run("forwards queued if they fail to be redirected", func(t *testing.T) {
+		outbound := &handoverOutbound{err: errors.New("send error")}
+		o := newOperation(t, outbound)
+
+		w := handoverReq(o, http.MethodPost, "conn-1", `{"endpoint":"https://mediator.example.com"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		h := &migration.Handover{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), h))
+		require.Zero(t, h.Forwarded)
+
+		require.NoError(t, o.gateForward(withHeader(forward)))
+		require.NoError(t, o.gateForward(withHeader(&transport.Envelope{
+			Message: []byte(`{"@id":"msg-2","@type":"https://didcomm.org/routing/1.0/forward","to":"key-1"}`),
+		})))
+	})
+
+	t.Run("handover requested by the wallet", func(t *testing.T) {
+		outbound := &handoverOutbound{}
+		o := newOperation(t, outbound)
+
+		_, ok := o.msgService(migration.HandoverMsgType)
+		require.True(t, ok)
+
+		reply, err := o.handover.handleHandover(&aries.InboundMsg{
+			DIDCommMsg: service.NewDIDCommMsgMap(&migration.HandoverMsg{
+				ID: "handover-1", Type: migration.HandoverMsgType,
+				Endpoint: "wss://mediator.example.com",
+			}),
+			MyDID: "did:router", TheirDID: "did:wallet",
+		})
+		require.NoError(t, err)
+
+		ack := &migration.HandoverAckMsg{}
+		require.NoError(t, reply.Decode(ack))
+		require.Equal(t, migration.HandoverAckMsgType, ack.Type)
+		require.Equal(t, 1, ack.Forwarded)
+
+		h, err := o.handover.store.Get("did:wallet")
+		require.NoError(t, err)
+		require.Equal(t, migration.ViaMessage, h.Via)
+		require.Equal(t, "conn-1", h.ConnectionID)
+	})
+
+	t.Run("invalid handover requests", func(t *testing.T) {
+		o := newOperation(t, &handoverOutbound{})
+
+		for _, body := range []string{`{`, `{"endpoint":"mediator"}`} {
+			require.Equal(t, http.StatusBadRequest, handoverReq(o, http.MethodPost, "conn-1", body).Code)
+		}
+
+		require.Equal(t, http.StatusNotFound,
+			handoverReq(o, http.MethodPost, "conn-2", `{"endpoint":"https://mediator.example.com"}`).Code)
+
+		for _, msg := range []service.DIDCommMsg{
+			service.DIDCommMsgMap{"@type": migration.HandoverMsgType, "endpoint": 1},
+			service.NewDIDCommMsgMap(&migration.HandoverMsg{Type: migration.HandoverMsgType}),
+			service.NewDIDCommMsgMap(&migration.HandoverMsg{
+				Type: migration.HandoverMsgType, Endpoint: "https://mediator.example.com",
+			}),
+			&aries.InboundMsg{
+				DIDCommMsg: service.NewDIDCommMsgMap(&migration.HandoverMsg{
+					Type: migration.HandoverMsgType, Endpoint: "https://mediator.example.com",
+				}),
+				MyDID: "did:router", TheirDID: "did:unknown",
+			},
+		} {
+			_, err := o.handover.handleHandover(msg)
+			require.Error(t, err)
+			require.Equal(t, problemInvalidMsg, problemCode(err))
+		}
+	})
+
+	t.Run("if-match revisions", func(t *testing.T) {
+		o := newOperation(t, &handoverOutbound{})
+
+		conditional := func(method, etag string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := mux.SetURLVars(httptest.NewRequest(method, walletsPath+"/conn-1/handover",
+				strings.NewReader(`{"endpoint":"https://mediator.example.com"}`)), map[string]string{"id": "conn-1"})
+			req.Header.Set(ifMatchHeader, etag)
+
+			if method == http.MethodPost {
+				o.handover.postHandover(w, req)
+			} else {
+				o.handover.deleteHandover(w, req)
+			}
+
+			return w
+		}
+
+		w := conditional(http.MethodPost, "*")
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = conditional(http.MethodPost, `"0"`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.Equal(t, `"2"`, w.Header().Get(etagHeader))
+
+		w = handoverReq(o, http.MethodGet, "conn-1", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, `"2"`, w.Header().Get(etagHeader))
+		require.Contains(t, w.Body.String(), `"revision":2`)
+
+		// stale revision : the change of the other admin tool is not clobbered
+		w = conditional(http.MethodPost, `"1"`)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+		require.Contains(t, w.Body.String(), "precondition failed")
+
+		w = conditional(http.MethodDelete, `W/"2"`)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = conditional(http.MethodDelete, `W/2`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = conditional(http.MethodDelete, "*")
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		require.Equal(t, http.StatusNotFound, handoverReq(o, http.MethodGet, "conn-1", "").Code)
+	})
+
+	t.Run("handover cancelled while forwarding the queued messages", func(t *testing.T) {
+		outbound := &handoverOutbound{}
+		o := newOperation(t, outbound)
+
+		outbound.onForward = func() {
+			require.NoError(t, o.handover.store.Cancel("did:wallet"))
+		}
+
+		w := handoverReq(o, http.MethodPost, "conn-1", `{"endpoint":"https://mediator.example.com"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		require.Equal(t, http.StatusNotFound, handoverReq(o, http.MethodGet, "conn-1", "").Code)
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		o := newOperation(t, &handoverOutbound{})
+
+		var err error
+
+		o.handover.store, err = migration.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+			ErrPut: errors.New("put error"),
+		}), o.locker)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusInternalServerError,
+			handoverReq(o, http.MethodPost, "conn-1", `{"endpoint":"https://mediator.example.com"}`).Code)
+		require.Equal(t, http.StatusInternalServerError, handoverReq(o, http.MethodGet, "conn-1", "").Code)
+
+		w := httptest.NewRecorder()
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodDelete, walletsPath+"/conn-1/handover", nil),
+			map[string]string{"id": "conn-1"})
+		req.Header.Set(ifMatchHeader, `"1"`)
+		o.handover.deleteHandover(w, req)
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+
+		_, err = o.handover.handleHandover(&aries.InboundMsg{
+			DIDCommMsg: service.NewDIDCommMsgMap(&migration.HandoverMsg{
+				Type: migration.HandoverMsgType, Endpoint: "https://mediator.example.com",
+			}),
+			MyDID: "did:router", TheirDID: "did:wallet",
+		})
+		require.Error(t, err)
+		require.Equal(t, problemInternal, problemCode(err))
+
+		require.NoError(t, o.gateForward(withHeader(forward)))
+	})
+}
@@ -0,0 +1,96 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/history"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+// API endpoints.
+const (
+	connectionHistoryPath = connectionsPath + "/{id}/history"
+)
+
+// HistoryResp model.
+type HistoryResp struct {
+	ConnectionID string            `json:"connectionID"`
+	Changes      []*history.Change `json:"changes"`
+}
+
+// recordChange returns true for the audit entries changing the connection or mediation record.
+func recordChange(entryType string) bool {
+	switch entryType {
+	case audit.ConnectionCreated, audit.DIDExchangeAction, audit.MediationAction, audit.TermsAccepted,
+		audit.WalletPinned, audit.WalletHandover, audit.HandoverCancelled, audit.GrantTransfer, audit.RecoveryToken,
+		audit.WebhookChanged:
+		return true
+	default:
+		return false
+	}
+}
+
+// recordHistory appends the audit entry changing a connection or mediation record to the history of the connection.
+func (o *Operation) recordHistory(e *audit.Entry) {
+	if e.ConnectionID == "" || !recordChange(e.Type) {
+		return
+	}
+
+	err := o.history.Record(e.ConnectionID, &history.Change{Time: e.Time, Actor: e.Actor, Type: e.Type, Detail: e.Detail})
+	if err != nil {
+		logger.Warnf("failed to record the history of connection id=[%s] : %s", e.ConnectionID, err)
+	}
+}
+
+// requestActor returns the actor of the changes requested : the tenant of the API key, or the operator.
+func requestActor(req *http.Request) string {
+	if tenantID := tenant.FromContext(req.Context()); tenantID != "" {
+		return history.TenantActor(tenantID)
+	}
+
+	return history.ActorOperator
+}
+
+// getHistory returns the latest changes of the connection and of its mediation, oldest first. The history of the
+// connections removed since is still returned.
+func (o *Operation) getHistory(rw http.ResponseWriter, req *http.Request) {
+	connID := mux.Vars(req)["id"]
+
+	if !o.visible(tenant.FromContext(req.Context()), connID) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, fmt.Sprintf("connection not found : %s", connID),
+			connectionHistoryPath, logger)
+
+		return
+	}
+
+	changes, err := o.history.Get(connID)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get history - err=%s", err.Error()), connectionHistoryPath, logger)
+
+		return
+	}
+
+	if len(changes) == 0 {
+		if _, err = o.connections.GetConnectionRecord(connID); err != nil {
+			httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound,
+				fmt.Sprintf("connection not found : %s", connID), connectionHistoryPath, logger)
+
+			return
+		}
+	}
+
+	httputil.WriteResponseWithLog(rw, &HistoryResp{ConnectionID: connID, Changes: changes}, connectionHistoryPath,
+		logger)
+}
@@ -0,0 +1,176 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/history"
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/residency"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+func TestHistory(t *testing.T) {
+	newOperation := func(t *testing.T) *Operation {
+		t.Helper()
+
+		cfg := config()
+
+		router, err := residency.New(mem.NewProvider(), lock.NewLocal(), &residency.Config{
+			Regions: map[string]storage.Provider{"eu": mem.NewProvider(), "us": mem.NewProvider()},
+		})
+		require.NoError(t, err)
+
+		cfg.Tenancy.Residency = router
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-1", State: connection.StateNameCompleted, ThreadID: "thid-1",
+			MyDID: "did:router", TheirDID: "did:wallet1", Namespace: connection.MyNSPrefix,
+		}))
+
+		return o
+	}
+
+	withTenant := func(req *http.Request, tenantID string) *http.Request {
+		return req.WithContext(tenant.WithTenant(req.Context(), tenantID))
+	}
+
+	connReq := func(method, path, connID, body string) *http.Request {
+		return mux.SetURLVars(httptest.NewRequest(method, connectionsPath+"/"+connID+path, strings.NewReader(body)),
+			map[string]string{"id": connID})
+	}
+
+	getHistory := func(o *Operation, req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.getHistory(w, req)
+
+		return w
+	}
+
+	historyResp := func(t *testing.T, w *httptest.ResponseRecorder) *HistoryResp {
+		t.Helper()
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		resp := &HistoryResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+
+		return resp
+	}
+
+	t.Run("changes of the connection", func(t *testing.T) {
+		o := newOperation(t)
+
+		resp := historyResp(t, getHistory(o, connReq(http.MethodGet, "/history", "conn-1", "")))
+		require.Equal(t, "conn-1", resp.ConnectionID)
+		require.Empty(t, resp.Changes)
+
+		o.recordAudit(&audit.Entry{Type: audit.ConnectionCreated, ConnectionID: "conn-1", Actor: history.ActorWallet})
+		o.recordAudit(&audit.Entry{Type: audit.KeyMismatch, ConnectionID: "conn-1"})
+		o.recordAudit(&audit.Entry{Type: audit.MediationAction, ConnectionID: "conn-1", Detail: "auto-granted"})
+
+		w := httptest.NewRecorder()
+		o.residency.putWalletRegion(w, connReq(http.MethodPut, "/region", "conn-1", `{"region":"eu"}`))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		o.assignTenant("acme", "conn-1")
+
+		w = httptest.NewRecorder()
+		o.residency.putWalletRegion(w, withTenant(connReq(http.MethodPut, "/region", "conn-1", `{"region":"us"}`), "acme"))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp = historyResp(t, getHistory(o, withTenant(connReq(http.MethodGet, "/history", "conn-1", ""), "acme")))
+		require.Len(t, resp.Changes, 4)
+
+		for i, expected := range []*history.Change{
+			{Actor: history.ActorWallet, Type: audit.ConnectionCreated},
+			{Actor: history.ActorRouter, Type: audit.MediationAction, Detail: "auto-granted"},
+			{Actor: history.ActorOperator, Type: audit.WalletPinned, Detail: "region=eu"},
+			{Actor: history.TenantActor("acme"), Type: audit.WalletPinned, Detail: "region=us"},
+		} {
+			require.Equal(t, expected.Actor, resp.Changes[i].Actor)
+			require.Equal(t, expected.Type, resp.Changes[i].Type)
+			require.Equal(t, expected.Detail, resp.Changes[i].Detail)
+			require.False(t, resp.Changes[i].Time.IsZero())
+		}
+
+		entries, err := o.auditLog.QueryTenant("acme", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, history.TenantActor("acme"), entries[0].Actor)
+	})
+
+	t.Run("history of a removed connection", func(t *testing.T) {
+		o := newOperation(t)
+
+		o.recordAudit(&audit.Entry{Type: audit.RecoveryToken, ConnectionID: "conn-2", Actor: history.ActorOperator})
+
+		resp := historyResp(t, getHistory(o, connReq(http.MethodGet, "/history", "conn-2", "")))
+		require.Len(t, resp.Changes, 1)
+	})
+
+	t.Run("connection not found", func(t *testing.T) {
+		o := newOperation(t)
+
+		w := getHistory(o, connReq(http.MethodGet, "/history", "conn-2", ""))
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		// the connections of the other tenants aren't visible
+		w = getHistory(o, withTenant(connReq(http.MethodGet, "/history", "conn-1", ""), "acme"))
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		o := newOperation(t)
+
+		var err error
+
+		o.history, err = history.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), 0)
+		require.NoError(t, err)
+
+		// the change is audited anyway
+		o.recordAudit(&audit.Entry{Type: audit.ConnectionCreated, ConnectionID: "conn-1"})
+
+		w := getHistory(o, connReq(http.MethodGet, "/history", "conn-1", ""))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "failed to get history")
+	})
+
+	t.Run("init error", func(t *testing.T) {
+		cfg := config()
+		persistent := mockstore.NewMockStoreProvider()
+		persistent.FailNamespace = "history"
+		cfg.Storage.Persistent = persistent
+
+		_, err := New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "history log")
+	})
+}
@@ -0,0 +1,194 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/trustbloc/hub-router/pkg/idempotency"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	// maxFingerprintSize is the size of the beginning of the request bodies fingerprinted, in bytes : the larger
+	// bodies, eg: the attachments, are streamed to the handlers.
+	maxFingerprintSize = 1024 * 1024
+	// maxRecordedResponseSize is the size of the largest response recorded, in bytes.
+	maxRecordedResponseSize = 64 * 1024
+	// idempotencySweepInterval is the interval the expired results are deleted at.
+	idempotencySweepInterval = 10 * time.Minute
+)
+
+// responseRecorder records the response written to the client.
+type responseRecorder struct {
+	http.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	truncated bool
+}
+
+// Unwrap returns the response writer the response is written to.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	if r.body.Len()+len(b) > maxRecordedResponseSize {
+		r.truncated = true
+	} else {
+		r.body.Write(b)
+	}
+
+	return r.ResponseWriter.Write(b)
+}
+
+// Idempotency is the REST API middleware executing once the POST and DELETE requests with an Idempotency-Key header :
+// the requests retried with the same key get the recorded result, with the Idempotent-Replayed header, until the key
+// expires. The keys are scoped to the tenant and the endpoint; the server errors aren't recorded, so that the requests
+// are executed again when retried.
+func (o *Operation) Idempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		key := req.Header.Get(idempotencyKeyHeader)
+
+		if key == "" || o.idempotency == nil || routeAccess(req) == accessPublic ||
+			(req.Method != http.MethodPost && req.Method != http.MethodDelete) {
+			next.ServeHTTP(rw, req)
+
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLength {
+			httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest,
+				fmt.Sprintf("invalid %s : longer than %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength),
+				req.URL.Path, logger)
+
+			return
+		}
+
+		key = tenant.FromContext(req.Context()) + "|" + req.Method + " " + req.URL.Path + "|" + key
+
+		fingerprint, err := fingerprintRequest(req)
+		if err != nil {
+			httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest,
+				fmt.Sprintf("failed to read request - err=%s", err.Error()), req.URL.Path, logger)
+
+			return
+		}
+
+		if o.replayResult(rw, req, key, fingerprint) {
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: rw}
+
+		next.ServeHTTP(recorder, req)
+
+		o.recordResult(key, fingerprint, recorder)
+	})
+}
+
+// replayResult writes the result recorded for the key, or the error if the key can't be used, and returns true; it
+// returns false if the request is to be executed.
+func (o *Operation) replayResult(rw http.ResponseWriter, req *http.Request, key, fingerprint string) bool {
+	r, err := o.idempotency.Begin(key, fingerprint)
+
+	switch {
+	case errors.Is(err, idempotency.ErrMismatch):
+		httputil.WriteErrorResponseWithLog(rw, http.StatusUnprocessableEntity, err.Error(), req.URL.Path, logger)
+	case errors.Is(err, idempotency.ErrInProgress):
+		httputil.WriteErrorResponseWithLog(rw, http.StatusConflict, err.Error(), req.URL.Path, logger)
+	case err != nil:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get idempotency result - err=%s", err.Error()), req.URL.Path, logger)
+	case r == nil:
+		return false
+	default:
+		logger.Infof("endpoint=[%s] replaying the result of %s=[%s]", req.URL.Path, idempotencyKeyHeader,
+			req.Header.Get(idempotencyKeyHeader))
+
+		if r.ContentType != "" {
+			rw.Header().Set("Content-Type", r.ContentType)
+		}
+
+		rw.Header().Set(idempotentReplayedHeader, "true")
+		rw.WriteHeader(r.Status)
+
+		if _, err = rw.Write(r.Body); err != nil {
+			logger.Errorf("Unable to send idempotency result, %s", err)
+		}
+	}
+
+	return true
+}
+
+// recordResult records the response of the request, or abandons the request if the response can't be replayed.
+func (o *Operation) recordResult(key, fingerprint string, recorder *responseRecorder) {
+	var err error
+
+	if recorder.status >= http.StatusInternalServerError || recorder.truncated {
+		err = o.idempotency.Abandon(key)
+	} else {
+		err = o.idempotency.Complete(key, &idempotency.Result{
+			Fingerprint: fingerprint,
+			Status:      recorder.status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+	}
+
+	if err != nil {
+		logger.Warnf("failed to record idempotency result : %s", err)
+	}
+}
+
+// fingerprintRequest returns the fingerprint of the method, URL, length and body of the request; the beginning of the
+// body read is restored for the handler.
+func fingerprintRequest(req *http.Request) (string, error) {
+	h := sha256.New()
+
+	for _, s := range []string{req.Method, req.URL.RequestURI(), strconv.FormatInt(req.ContentLength, 10)} {
+		h.Write([]byte(s + "\n")) // nolint:errcheck,gosec // never returns an error
+	}
+
+	if req.Body != nil {
+		prefix, err := ioutil.ReadAll(io.LimitReader(req.Body, maxFingerprintSize))
+		if err != nil {
+			return "", err
+		}
+
+		h.Write(prefix) // nolint:errcheck,gosec // never returns an error
+
+		req.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(prefix), req.Body))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+func TestIdempotency(t *testing.T) {
+	o, err := New(config())
+	require.NoError(t, err)
+
+	executed := map[string]int{}
+	status := http.StatusCreated
+
+	router := mux.NewRouter()
+
+	for _, h := range o.GetRESTHandlers() {
+		router.HandleFunc(h.Path(), func(rw http.ResponseWriter, req *http.Request) {
+			body, readErr := ioutil.ReadAll(req.Body)
+			require.NoError(t, readErr)
+
+			executed[req.Method+" "+req.URL.Path]++
+
+			rw.Header().Set("Content-Type", "application/json")
+			rw.WriteHeader(status)
+
+			_, writeErr := fmt.Fprintf(rw, `{"body":%q,"count":%d}`, body, executed[req.Method+" "+req.URL.Path])
+			require.NoError(t, writeErr)
+		}).Methods(h.Method())
+	}
+
+	router.Use(o.Idempotency)
+
+	serve := func(method, path, key, body string, tenantID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(idempotencyKeyHeader, key)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req.WithContext(tenant.WithTenant(req.Context(), tenantID)))
+
+		return w
+	}
+
+	t.Run("executed once", func(t *testing.T) {
+		w := serve(http.MethodPost, tenantsPath, "key-1", `{"id":"t1"}`, "")
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.Empty(t, w.Header().Get(idempotentReplayedHeader))
+
+		replayed := serve(http.MethodPost, tenantsPath, "key-1", `{"id":"t1"}`, "")
+		require.Equal(t, http.StatusCreated, replayed.Code)
+		require.Equal(t, "true", replayed.Header().Get(idempotentReplayedHeader))
+		require.Equal(t, "application/json", replayed.Header().Get("Content-Type"))
+		require.Equal(t, w.Body.String(), replayed.Body.String())
+		require.Equal(t, 1, executed["POST /tenants"])
+
+		w = serve(http.MethodDelete, "/tenants/t1", "key-1", "", "")
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.Empty(t, w.Header().Get(idempotentReplayedHeader))
+
+		serve(http.MethodDelete, "/tenants/t1", "key-1", "", "")
+		require.Equal(t, 1, executed["DELETE /tenants/t1"])
+	})
+
+	t.Run("key scoped to the tenant", func(t *testing.T) {
+		serve(http.MethodPost, "/connections/c1/consents", "key-2", `{"version":"v1"}`, "tenant-1")
+		serve(http.MethodPost, "/connections/c1/consents", "key-2", `{"version":"v1"}`, "tenant-2")
+		serve(http.MethodPost, "/connections/c1/consents", "key-2", `{"version":"v1"}`, "tenant-1")
+
+		require.Equal(t, 2, executed["POST /connections/c1/consents"])
+	})
+
+	t.Run("key reused with a different request", func(t *testing.T) {
+		serve(http.MethodPost, tenantsPath, "key-3", `{"id":"t3"}`, "")
+
+		w := serve(http.MethodPost, tenantsPath, "key-3", `{"id":"t4"}`, "")
+		require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		require.Contains(t, w.Body.String(), "idempotency key reused with a different request")
+	})
+
+	t.Run("request in progress", func(t *testing.T) {
+		fingerprint, err := fingerprintRequest(httptest.NewRequest(http.MethodPost, tenantsPath,
+			strings.NewReader(`{"id":"t5"}`)))
+		require.NoError(t, err)
+
+		_, err = o.idempotency.Begin("|POST /tenants|key-5", fingerprint)
+		require.NoError(t, err)
+
+		w := serve(http.MethodPost, tenantsPath, "key-5", `{"id":"t5"}`, "")
+		require.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("server errors not recorded", func(t *testing.T) {
+		status = http.StatusInternalServerError
+		defer func() { status = http.StatusCreated }()
+
+		serve(http.MethodPost, "/failover/promote", "key-6", "", "")
+		serve(http.MethodPost, "/failover/promote", "key-6", "", "")
+
+		require.Equal(t, 2, executed["POST /failover/promote"])
+	})
+
+	t.Run("not applied", func(t *testing.T) {
+		serve(http.MethodPost, "/metering/periods/2021-06/close", "", "", "")
+		serve(http.MethodPost, "/metering/periods/2021-06/close", "", "", "")
+		serve(http.MethodGet, tenantsPath, "key-7", "", "")
+		serve(http.MethodGet, tenantsPath, "key-7", "", "")
+
+		require.Equal(t, 2, executed["POST /metering/periods/2021-06/close"])
+		require.Equal(t, 2, executed["GET /tenants"])
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		w := serve(http.MethodPost, tenantsPath, strings.Repeat("k", maxIdempotencyKeyLength+1), `{}`, "")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("large body streamed", func(t *testing.T) {
+		body := strings.Repeat("a", maxFingerprintSize+10)
+
+		w := serve(http.MethodPost, attachmentsPath, "key-8", body, "")
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.Contains(t, w.Body.String(), body)
+
+		// the response too large to be recorded
+		w = serve(http.MethodPost, attachmentsPath, "key-8", body, "")
+		require.Empty(t, w.Header().Get(idempotentReplayedHeader))
+		require.Equal(t, 2, executed["POST /attachments"])
+
+	})
+}
@@ -0,0 +1,111 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/trustbloc/hub-router/pkg/alert"
+	"github.com/trustbloc/hub-router/pkg/incident"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// API endpoints.
+const (
+	incidentsPath = "/incidents"
+)
+
+// incidentCheckInterval is the interval the error bursts are checked, and the incidents pruned at.
+const incidentCheckInterval = 10 * time.Second
+
+// IncidentsResp model.
+type IncidentsResp struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// Ongoing is the number of incidents not resolved yet.
+	Ongoing   int                  `json:"ongoing"`
+	Incidents []*incident.Incident `json:"incidents"`
+}
+
+// initIncidents initializes the incident timeline.
+func (o *Operation) initIncidents(config *Config) error {
+	var err error
+
+	o.reporting.incidents, err = incident.New(config.Storage.Persistent, config.Reporting.Incidents)
+	if err != nil {
+		return fmt.Errorf("incident timeline: %w", err)
+	}
+
+	return nil
+}
+
+// raise records the alert in the incident timeline, and sends it to the alert channels.
+func (h *reportingHandler) raise(a *alert.Alert) {
+	var err error
+
+	switch {
+	case a.Kind == alert.SLOBreach:
+		err = h.incidents.Record(a.Kind, a.Subject, a.Summary, a.Time)
+	case a.Resolved:
+		err = h.incidents.Resolve(a.Kind, a.Subject, a.Summary, a.Time)
+	default:
+		err = h.incidents.Raise(a.Kind, a.Subject, a.Summary, a.Time)
+	}
+
+	if err != nil {
+		logger.Warnf("failed to record %s incident : %s", a.Kind, err)
+	}
+
+	h.sendAlert(a)
+}
+
+// recordError records an internal error of the router, for the error burst incidents.
+func (h *reportingHandler) recordError() {
+	if err := h.incidents.Error(); err != nil {
+		logger.Warnf("failed to record error burst incident : %s", err)
+	}
+}
+
+// getIncidents returns the incidents ongoing within the 'from' and 'to' RFC3339 query params, the last 7 days by
+// default.
+func (h *reportingHandler) getIncidents(rw http.ResponseWriter, req *http.Request) {
+	from, to, err := getTimeRange(req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), incidentsPath, logger)
+
+		return
+	}
+
+	if to.IsZero() {
+		to = time.Now().UTC()
+	}
+
+	if from.IsZero() {
+		from = to.Add(-7 * day)
+	}
+
+	incidents, err := h.incidents.Incidents(from, to)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get incidents - err=%s", err.Error()), incidentsPath, logger)
+
+		return
+	}
+
+	ongoing := 0
+
+	for _, i := range incidents {
+		if i.Ongoing() {
+			ongoing++
+		}
+	}
+
+	httputil.WriteResponseWithLog(rw, &IncidentsResp{From: from, To: to, Ongoing: ongoing, Incidents: incidents},
+		incidentsPath, logger)
+}
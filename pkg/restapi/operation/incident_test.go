@@ -0,0 +1,116 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/alert"
+	"github.com/trustbloc/hub-router/pkg/digest"
+	"github.com/trustbloc/hub-router/pkg/incident"
+	"github.com/trustbloc/hub-router/pkg/stats"
+)
+
+func TestIncidents(t *testing.T) {
+	getIncidents := func(o *Operation, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.reporting.getIncidents(w, httptest.NewRequest(http.MethodGet, incidentsPath+query, nil))
+
+		return w
+	}
+
+	t.Run("incident timeline", func(t *testing.T) {
+		channel := &mockAlertChannel{}
+
+		cfg := config()
+		cfg.Reporting.AlertChannels = []alert.Channel{channel}
+		cfg.Reporting.Incidents = &incident.Config{BurstThreshold: 2}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		now := time.Now().UTC().Add(-time.Hour)
+
+		o.reporting.raise(&alert.Alert{Kind: alert.StorageFailure, Subject: "router", Time: now, Summary: "failing"})
+		o.reporting.raise(&alert.Alert{
+			Kind: alert.StorageFailure, Subject: "router", Resolved: true, Time: now.Add(time.Minute),
+			Summary: "recovered",
+		})
+		require.NoError(t, (&sloBreachSink{send: o.reporting.raise}).Deliver(&digest.Digest{
+			Schedule: digest.Daily, GeneratedAt: now.Add(2 * time.Minute), Delivery: digest.NewDelivery(90, 10, 0.99),
+		}))
+		o.countStat("", stats.Errors)
+		o.countStat("", stats.Errors)
+		require.Eventually(t, channel.received(3), 5*time.Second, 10*time.Millisecond)
+
+		w := getIncidents(o, "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &IncidentsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, 7*day, resp.To.Sub(resp.From))
+		require.Equal(t, 1, resp.Ongoing)
+		require.Len(t, resp.Incidents, 3)
+		require.Equal(t, alert.StorageFailure, resp.Incidents[0].Kind)
+		require.Len(t, resp.Incidents[0].Events, 2)
+		require.Equal(t, alert.SLOBreach, resp.Incidents[1].Kind)
+		require.Equal(t, incident.ErrorBurst, resp.Incidents[2].Kind)
+		require.True(t, resp.Incidents[2].Ongoing())
+
+		w = getIncidents(o, "?from=2021-03-01T10:00:00Z&to=2021-03-02T10:00:00Z")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp = &IncidentsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Empty(t, resp.Incidents)
+	})
+
+	t.Run("invalid time range", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusBadRequest, getIncidents(o, "?to=tomorrow").Code)
+		require.Equal(t, http.StatusBadRequest,
+			getIncidents(o, "?from=2021-03-02T10:00:00Z&to=2021-03-01T10:00:00Z").Code)
+	})
+
+	t.Run("storage error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.reporting.incidents, err = incident.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}), &incident.Config{BurstThreshold: 1})
+		require.NoError(t, err)
+
+		o.reporting.raise(&alert.Alert{Kind: alert.StorageFailure, Subject: "router", Time: time.Now()})
+		o.reporting.recordError()
+
+		require.Equal(t, http.StatusInternalServerError, getIncidents(o, "").Code)
+	})
+
+	t.Run("incident timeline error", func(t *testing.T) {
+		cfg := config()
+		p := mockstore.NewMockStoreProvider()
+		p.FailNamespace = "incident"
+		cfg.Storage.Persistent = p
+
+		_, err := New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "incident timeline")
+	})
+}
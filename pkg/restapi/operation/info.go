@@ -0,0 +1,64 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+const infoConfigPath = "/info/config"
+
+// Sources of the configuration settings.
+const (
+	SourceFlag    = "flag"
+	SourceEnv     = "env"
+	SourceDefault = "default"
+	SourceUnset   = "unset"
+)
+
+// ConfigSetting is a setting of the effective configuration : its value, and where it was resolved from.
+type ConfigSetting struct {
+	Name  string      `json:"name"`
+	Value interface{} `json:"value,omitempty"`
+	// Source is flag, env, default or unset.
+	Source string `json:"source"`
+	// Masked is true if the value, or the credentials within it, are masked.
+	Masked bool `json:"masked,omitempty"`
+}
+
+// ConfigInfo is the effective configuration the router was started with, the secrets masked.
+type ConfigInfo struct {
+	Version  string           `json:"version"`
+	Started  time.Time        `json:"started"`
+	Settings []*ConfigSetting `json:"settings"`
+}
+
+// infoHandler serves the effective configuration, nil if not available.
+type infoHandler struct {
+	config *ConfigInfo
+}
+
+func (h *infoHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(infoConfigPath, http.MethodGet, h.getConfigInfo),
+	}
+}
+
+func (h *infoHandler) getConfigInfo(rw http.ResponseWriter, _ *http.Request) {
+	if h.config == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "configuration info not available",
+			infoConfigPath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, h.config, infoConfigPath, logger)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigInfo(t *testing.T) {
+	getConfigInfo := func(o *Operation) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.info.getConfigInfo(w, httptest.NewRequest(http.MethodGet, infoConfigPath, nil))
+
+		return w
+	}
+
+	t.Run("effective configuration", func(t *testing.T) {
+		cfg := config()
+		cfg.ConfigInfo = &ConfigInfo{
+			Version: "v1.0.0",
+			Started: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+			Settings: []*ConfigSetting{
+				{Name: "host-url", Value: "0.0.0.0:8080", Source: SourceFlag},
+				{Name: "operator-api-key", Value: "****", Source: SourceEnv, Masked: true},
+				{Name: "stats-retention", Value: "720h", Source: SourceDefault},
+				{Name: "webhook-url", Source: SourceUnset},
+			},
+		}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		w := getConfigInfo(o)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		info := &ConfigInfo{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), info))
+		require.Equal(t, cfg.ConfigInfo, info)
+		require.Equal(t, accessOperator, endpointAccess(infoConfigPath))
+	})
+
+	t.Run("not available", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := getConfigInfo(o)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,73 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+
+	"github.com/trustbloc/hub-router/pkg/qrcode"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// invitationURLPrefix is the prefix of the invitation URLs encoded in the QR codes, the invitation is base64url encoded
+// in the oob query parameter.
+const invitationURLPrefix = "didcomm://invite?oob="
+
+// invitationFormats are the media types of the invitations, by order of preference.
+// nolint:gochecknoglobals // read-only lookup table
+var invitationFormats = []string{
+	httputil.MediaTypeJSON, httputil.MediaTypeDIDCommEnvelope, httputil.MediaTypePNG,
+}
+
+// writeInvitation writes the invitation in the media type negotiated : the invitation response in JSON, the bare
+// out-of-band message, or the QR code of its URL.
+func writeInvitation(rw http.ResponseWriter, mediaType string, resp *DIDCommInvitationResp) {
+	var (
+		content []byte
+		err     error
+	)
+
+	switch mediaType {
+	case httputil.MediaTypeDIDCommEnvelope:
+		content, err = json.Marshal(resp.Invitation.Invitation)
+	case httputil.MediaTypePNG:
+		content, err = invitationQRCode(resp.Invitation.Invitation)
+	default:
+		httputil.WriteResponseWithLog(rw, resp, invitationPath, logger)
+
+		return
+	}
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to write router invitation - err=%s", err.Error()), invitationPath, logger)
+
+		return
+	}
+
+	httputil.WriteContent(rw, mediaType, content, invitationPath, logger)
+}
+
+// invitationQRCode returns the PNG image of the QR code of the invitation URL.
+func invitationQRCode(invitation *outofband.Invitation) ([]byte, error) {
+	invitationBytes, err := json.Marshal(invitation)
+	if err != nil {
+		return nil, fmt.Errorf("marshal invitation : %w", err)
+	}
+
+	code, err := qrcode.Encode([]byte(invitationURLPrefix + base64.RawURLEncoding.EncodeToString(invitationBytes)))
+	if err != nil {
+		return nil, fmt.Errorf("encode invitation qr code : %w", err)
+	}
+
+	return code.PNG(qrcode.DefaultScale)
+}
@@ -0,0 +1,84 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+func TestInvitationFormats(t *testing.T) {
+	generate := func(t *testing.T, accept string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		o, err := New(config())
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, invitationPath, nil)
+		req.Header.Set("Accept", accept)
+
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, req)
+
+		return w
+	}
+
+	t.Run("didcomm envelope", func(t *testing.T) {
+		w := generate(t, httputil.MediaTypeDIDCommEnvelope)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, httputil.MediaTypeDIDCommEnvelope, w.Header().Get("Content-Type"))
+
+		invitation := &outofband.Invitation{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), invitation))
+		require.NotEmpty(t, invitation.ID)
+		require.Equal(t, "https://didcomm.org/out-of-band/1.0/invitation", invitation.Type)
+	})
+
+	t.Run("qr code", func(t *testing.T) {
+		w := generate(t, "image/*")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, httputil.MediaTypePNG, w.Header().Get("Content-Type"))
+
+		img, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+		require.NoError(t, err)
+		require.NotZero(t, img.Bounds().Dx())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		w := generate(t, "application/*")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &DIDCommInvitationResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.NotEmpty(t, resp.Invitation.ID)
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		w := generate(t, "text/html")
+		require.Equal(t, http.StatusNotAcceptable, w.Code)
+		require.Contains(t, w.Body.String(), "application/json, application/didcomm-envelope, image/png")
+	})
+
+	t.Run("invitation too long", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		writeInvitation(w, httputil.MediaTypePNG, &DIDCommInvitationResp{Invitation: &Invitation{
+			Invitation: &outofband.Invitation{Label: strings.Repeat("a", 3000)},
+		}})
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "encode invitation qr code")
+	})
+}
@@ -0,0 +1,63 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	didexdsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/didexchange"
+)
+
+// maxInvitationParamLength is the max length of the label, goal and goal code set with the invitation request.
+const maxInvitationParamLength = 256
+
+// supportedHandshakeProtocols are the handshake protocols the router completes the connections with.
+// nolint:gochecknoglobals // read-only lookup table
+var supportedHandshakeProtocols = map[string]bool{didexdsvc.PIURI: true}
+
+// invitationParams are the query params of the invitation request, overriding the branding of the invitation and
+// constraining its handshake protocols.
+type invitationParams struct {
+	label     string
+	goal      string
+	goalCode  string
+	protocols []string
+}
+
+// parseInvitationParams returns the query params of the invitation request : the label, goal and goal_code, and the
+// handshake_protocols (repeatable, or comma separated).
+func parseInvitationParams(req *http.Request) (*invitationParams, error) {
+	query := req.URL.Query()
+
+	params := &invitationParams{
+		label:    query.Get("label"),
+		goal:     query.Get("goal"),
+		goalCode: query.Get("goal_code"),
+	}
+
+	for name, val := range map[string]string{"label": params.label, "goal": params.goal, "goal_code": params.goalCode} {
+		if len(val) > maxInvitationParamLength {
+			return nil, fmt.Errorf("invalid '%s' : longer than %d characters", name, maxInvitationParamLength)
+		}
+	}
+
+	for _, val := range query["handshake_protocols"] {
+		for _, protocol := range strings.Split(val, ",") {
+			protocol = strings.TrimSpace(protocol)
+
+			if !supportedHandshakeProtocols[protocol] {
+				return nil, fmt.Errorf("invalid 'handshake_protocols' : unsupported handshake protocol %s", protocol)
+			}
+
+			params.protocols = append(params.protocols, protocol)
+		}
+	}
+
+	return params, nil
+}
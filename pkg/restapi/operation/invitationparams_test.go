@@ -0,0 +1,85 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+func TestInvitationParams(t *testing.T) {
+	cfg := config()
+	cfg.Invitations.Label = "Example Router"
+	cfg.Invitations.Branding = map[string]*tenant.Branding{"tenant-1": {Label: "Acme", Goal: "Connect your Acme wallet"}}
+
+	o, err := New(cfg)
+	require.NoError(t, err)
+
+	generate := func(tenantID string, query url.Values) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, withTenant(httptest.NewRequest(http.MethodGet,
+			invitationPath+"?"+query.Encode(), nil), tenantID))
+
+		return w
+	}
+
+	invitation := func(t *testing.T, tenantID string, query url.Values) *Invitation {
+		t.Helper()
+
+		w := generate(tenantID, query)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		resp := &DIDCommInvitationResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+
+		return resp.Invitation
+	}
+
+	t.Run("router label", func(t *testing.T) {
+		inv := invitation(t, "", nil)
+		require.Equal(t, "Example Router", inv.Label)
+		require.Empty(t, inv.Goal)
+		require.Equal(t, []string{"https://didcomm.org/didexchange/1.0"}, inv.Protocols)
+	})
+
+	t.Run("query params", func(t *testing.T) {
+		inv := invitation(t, "tenant-1", url.Values{
+			"label":               {"Acme Support"},
+			"goal_code":           {"aries.rel.build"},
+			"handshake_protocols": {"https://didcomm.org/didexchange/1.0"},
+		})
+		require.Equal(t, "Acme Support", inv.Label)
+		require.Equal(t, "Connect your Acme wallet", inv.Goal)
+		require.Equal(t, "aries.rel.build", inv.GoalCode)
+		require.Equal(t, []string{"https://didcomm.org/didexchange/1.0"}, inv.Protocols)
+
+		inv = invitation(t, "", url.Values{"goal": {"Connect"}})
+		require.Equal(t, "Example Router", inv.Label)
+		require.Equal(t, "Connect", inv.Goal)
+		require.Empty(t, inv.GoalCode)
+	})
+
+	t.Run("invalid query params", func(t *testing.T) {
+		for _, query := range []url.Values{
+			{"label": {strings.Repeat("a", maxInvitationParamLength+1)}},
+			{"goal_code": {strings.Repeat("a", maxInvitationParamLength+1)}},
+			{"handshake_protocols": {"https://didcomm.org/didexchange/1.0,https://didcomm.org/connections/1.0"}},
+		} {
+			w := generate("", query)
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			require.Contains(t, w.Body.String(), "invalid '")
+		}
+	})
+}
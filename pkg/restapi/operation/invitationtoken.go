@@ -0,0 +1,135 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/trustbloc/hub-router/pkg/poptoken"
+)
+
+const (
+	// invitationTokenHeader carries the token of the invitation requests, the Authorization header carries the API key.
+	invitationTokenHeader = "X-Invitation-Token"
+	tokenSweepInterval    = time.Minute
+)
+
+// invitationTokenHandler verifies the tokens of the invitation requests, and binds the invitations and connections to
+// the subject of their token. Its verifier and bindings are nil if the tokens aren't required.
+type invitationTokenHandler struct {
+	tokens   *poptoken.Verifier
+	subjects *poptoken.Bindings
+}
+
+func (o *Operation) initInvitationTokens(config *Config) error {
+	o.invitationTokens = &invitationTokenHandler{}
+
+	if !config.Invitations.Tokens.Enabled() {
+		return nil
+	}
+
+	var err error
+
+	o.invitationTokens.subjects, err = poptoken.NewBindings(config.Storage.Persistent)
+	if err != nil {
+		return fmt.Errorf("token bindings: %w", err)
+	}
+
+	// the uses are shared by the nodes, so that a token is not used once per node
+	o.invitationTokens.tokens, err = poptoken.NewVerifier(config.Invitations.Tokens, config.Storage.Transient, o.locker)
+	if err != nil {
+		return fmt.Errorf("token verifier: %w", err)
+	}
+
+	return nil
+}
+
+// start starts the sweeps of the expired token uses, if the tokens are required.
+func (h *invitationTokenHandler) start() {
+	if h.tokens != nil {
+		h.tokens.Start(tokenSweepInterval)
+	}
+}
+
+// verify returns the claims of the invitation request token, nil if the tokens aren't required.
+func (h *invitationTokenHandler) verify(req *http.Request) (*poptoken.Claims, error) {
+	if h.tokens == nil {
+		return nil, nil
+	}
+
+	token := req.Header.Get(invitationTokenHeader)
+	if token == "" {
+		return nil, fmt.Errorf("missing %s header", invitationTokenHeader)
+	}
+
+	return h.tokens.Verify(token)
+}
+
+// release releases the token of the invitation request that failed, so that it can be retried.
+func (h *invitationTokenHandler) release(claims *poptoken.Claims) {
+	if claims == nil {
+		return
+	}
+
+	if err := h.tokens.Release(claims); err != nil {
+		logger.Warnf("failed to release invitation token jti=[%s] : %s", claims.ID, err)
+	}
+}
+
+// tokenSubject returns the subject of the token claims, empty if the tokens aren't required.
+func tokenSubject(claims *poptoken.Claims) string {
+	if claims == nil {
+		return ""
+	}
+
+	return claims.Subject
+}
+
+// bind binds the invitation or connection to the subject of the token it was created with.
+func (h *invitationTokenHandler) bind(subject, id string) {
+	if h.subjects == nil {
+		return
+	}
+
+	if err := h.subjects.Bind(subject, id); err != nil {
+		logger.Warnf("failed to bind token subject to id=[%s] : %s", id, err)
+	}
+}
+
+// subjectOf returns the subject of the token the invitation or connection was created with, empty if not bound.
+func (h *invitationTokenHandler) subjectOf(id string) string {
+	if h.subjects == nil {
+		return ""
+	}
+
+	subject, err := h.subjects.Subject(id)
+	if err != nil {
+		logger.Warnf("failed to get token subject of id=[%s] : %s", id, err)
+	}
+
+	return subject
+}
+
+// admit binds the connection to the subject of the invitation the DID exchange request responds to. With the tokens
+// required, the requests responding to an invitation not created with a token are rejected.
+func (h *invitationTokenHandler) admit(invitationID, connectionID string) error {
+	if h.subjects == nil {
+		return nil
+	}
+
+	subject := h.subjectOf(invitationID)
+	if subject == "" {
+		return withProblem(problemPolicyRejected, errors.New("invitation not bound to a token subject"))
+	}
+
+	h.bind(subject, connectionID)
+
+	return nil
+}
@@ -0,0 +1,160 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockoutofband "github.com/trustbloc/hub-router/pkg/internal/mock/outofband"
+	"github.com/trustbloc/hub-router/pkg/poptoken"
+	"github.com/trustbloc/hub-router/pkg/presence"
+)
+
+func invitationToken(secret []byte, subject, jti string) string {
+	claims := fmt.Sprintf(`{"sub":%q,"exp":%d,"jti":%q}`, subject, time.Now().Add(time.Minute).Unix(), jti)
+	signed := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(claims))
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestInvitationTokens(t *testing.T) {
+	secret := []byte("secret")
+
+	t.Run("invitation bound to the token subject", func(t *testing.T) {
+		cfg := config()
+		cfg.Invitations.Tokens = &poptoken.Config{Secret: secret}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, invitationPath, nil)
+		req.Header.Set(invitationTokenHeader, invitationToken(secret, "user-1", "1"))
+
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &DIDCommInvitationResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, "user-1", o.invitationTokens.subjectOf(resp.Invitation.ID))
+
+		// the token is single use
+		w = httptest.NewRecorder()
+		o.generateInvitation(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Contains(t, w.Body.String(), "already used")
+
+		require.NoError(t, o.invitationTokens.admit(resp.Invitation.ID, "conn-1"))
+		require.Equal(t, "user-1", o.invitationTokens.subjectOf("conn-1"))
+
+		require.NoError(t, o.presence.Seen("conn-1", presence.SourceMediation))
+
+		record, err := o.presence.Get("conn-1")
+		require.NoError(t, err)
+
+		wallet, err := o.wallet(record)
+		require.NoError(t, err)
+		require.Equal(t, "user-1", wallet.Subject)
+
+		err = o.invitationTokens.admit("unknown-invitation", "conn-2")
+		require.Error(t, err)
+		require.Equal(t, problemPolicyRejected, problemCode(err))
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		cfg := config()
+		cfg.Invitations.Tokens = &poptoken.Config{Secret: secret}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, httptest.NewRequest(http.MethodGet, invitationPath, nil))
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Contains(t, w.Body.String(), "missing "+invitationTokenHeader)
+
+		req := httptest.NewRequest(http.MethodGet, invitationPath, nil)
+		req.Header.Set(invitationTokenHeader, invitationToken([]byte("other"), "user-1", "1"))
+
+		w = httptest.NewRecorder()
+		o.generateInvitation(w, req)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Contains(t, w.Body.String(), "invalid token")
+	})
+
+	t.Run("token released if the invitation fails", func(t *testing.T) {
+		cfg := config()
+		cfg.Invitations.Tokens = &poptoken.Config{Secret: secret}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		oob := o.oob
+		o.oob = &mockoutofband.MockClient{CreateInvitationErr: errors.New("invitation error")}
+
+		req := httptest.NewRequest(http.MethodGet, invitationPath, nil)
+		req.Header.Set(invitationTokenHeader, invitationToken(secret, "user-1", "1"))
+
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, req)
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+
+		// retried with the same token
+		o.oob = oob
+
+		w = httptest.NewRecorder()
+		o.generateInvitation(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("tokens not required", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.invitationTokens.bind("user-1", "invitation-1")
+		require.Empty(t, o.invitationTokens.subjectOf("invitation-1"))
+		require.NoError(t, o.invitationTokens.admit("invitation-1", "conn-1"))
+	})
+
+	t.Run("init error", func(t *testing.T) {
+		cfg := config()
+		cfg.Invitations.Tokens = &poptoken.Config{Secret: secret}
+		p := mockstore.NewMockStoreProvider()
+		p.FailNamespace = "poptoken"
+		cfg.Storage.Persistent = p
+
+		_, err := New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "token bindings")
+
+		cfg = config()
+		cfg.Invitations.Tokens = &poptoken.Config{Secret: secret}
+		p = mockstore.NewMockStoreProvider()
+		p.FailNamespace = "tokenuse"
+		cfg.Storage.Transient = p
+
+		_, err = New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "token verifier")
+	})
+}
@@ -0,0 +1,59 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/keypin"
+	"github.com/trustbloc/hub-router/pkg/preparse"
+	"github.com/trustbloc/hub-router/pkg/stats"
+)
+
+const securityTopic = "security"
+
+// VerifyEnvelope rejects the inbound envelopes sent with another key than the one pinned for the connection.
+func (o *Operation) VerifyEnvelope(envelope *transport.Envelope, _ *preparse.Header) error {
+	err := o.keyPins.Verify(envelope.ToKey, envelope.FromKey, envelope.Message)
+	if err == nil {
+		return nil
+	}
+
+	mismatch := &keypin.MismatchError{}
+	if !errors.As(err, &mismatch) {
+		logger.Errorf("failed to verify pinned key : %s", err)
+
+		return err
+	}
+
+	logger.Warnf("envelope rejected : %s", err)
+
+	o.securityEvent(&events.SecurityEvent{
+		Time: time.Now().UTC(), Kind: events.SecurityKeyMismatch, RecipientKey: mismatch.RecipientKey, Detail: err.Error(),
+	})
+
+	return err
+}
+
+// securityEvent records the security event in the audit trail, publishes it and notifies it to the webhooks.
+func (o *Operation) securityEvent(e *events.SecurityEvent) {
+	o.recordAudit(&audit.Entry{Type: e.Kind, ConnectionID: e.ConnectionID, Detail: e.Detail})
+	o.countStat(o.tenantOf(e.ConnectionID), stats.Errors)
+	o.reporting.recordFailure(e.ConnectionID)
+	o.events.Publish(e)
+
+	go func() {
+		if err := o.webhook.Notify(securityTopic, e); err != nil {
+			logger.Warnf("failed to notify security event : %s", err)
+		}
+	}()
+}
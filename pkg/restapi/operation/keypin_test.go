@@ -0,0 +1,92 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/inbound"
+	"github.com/trustbloc/hub-router/pkg/keypin"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestVerifyEnvelope(t *testing.T) {
+	t.Run("key mismatch is rejected", func(t *testing.T) {
+		notified := make(chan *webhook.Message, 1)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			msg := &webhook.Message{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(msg))
+
+			notified <- msg
+		}))
+		defer srv.Close()
+
+		cfg := config()
+		cfg.Webhook = webhook.New([]string{srv.URL}, nil)
+		cfg.Admission.KeyPinning = true
+		cfg.Transports.Inbound = []*inbound.Middleware{inbound.NewMiddleware(nil, inbound.Verify)}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		sub := o.Events().Subscribe(1, events.TopicSecurity)
+		defer sub.Unsubscribe()
+
+		require.NoError(t, o.VerifyEnvelope(&transport.Envelope{ToKey: []byte("router-key"), FromKey: []byte("key-1")}, nil))
+		require.NoError(t, o.VerifyEnvelope(&transport.Envelope{ToKey: []byte("router-key"), FromKey: []byte("key-1")}, nil))
+
+		err = o.VerifyEnvelope(&transport.Envelope{ToKey: []byte("router-key"), FromKey: []byte("key-2")}, nil)
+		require.ErrorIs(t, err, keypin.ErrKeyMismatch)
+
+		e, ok := (<-sub.C).(*events.SecurityEvent)
+		require.True(t, ok)
+		require.Equal(t, events.SecurityKeyMismatch, e.Kind)
+		require.Equal(t, keypin.Thumbprint([]byte("router-key")), e.RecipientKey)
+
+		select {
+		case msg := <-notified:
+			require.Equal(t, securityTopic, msg.Topic)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+
+		entries, err := o.auditLog.Query(time.Time{}, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, audit.KeyMismatch, entries[0].Type)
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		cfg := config()
+		cfg.Admission.KeyPinning = true
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.keyPins, err = keypin.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}))
+		require.NoError(t, err)
+
+		err = o.VerifyEnvelope(&transport.Envelope{ToKey: []byte("router-key"), FromKey: []byte("key-1")}, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get error")
+	})
+}
@@ -0,0 +1,123 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	mediatordsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/kms"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/fingerprint"
+
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/keyusage"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// API endpoints.
+const (
+	keyReusePath = "/audit/key-reuse"
+)
+
+// keyUsageHandler records the keys used by the connections, to detect and report the keys reused across them.
+type keyUsageHandler struct {
+	registry *keyusage.Registry
+	// policy is audit or reject : the keys reused are rejected, or only reported.
+	policy        string
+	securityEvent func(*events.SecurityEvent)
+}
+
+func (h *keyUsageHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(keyReusePath, http.MethodGet, h.getKeyReuseReport),
+	}
+}
+
+func (h *keyUsageHandler) getKeyReuseReport(rw http.ResponseWriter, _ *http.Request) {
+	report, err := h.registry.Report()
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get key reuse report - err=%s", err.Error()), keyReusePath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, report, keyReusePath, logger)
+}
+
+// recordKeyUsage records the router keys used for the connection. A key reused across connections raises a security
+// event, and fails the connection with the reject policy.
+func (h *keyUsageHandler) record(connID, role string, keys ...[]byte) error {
+	err := h.registry.Record(connID, role, keys...)
+	if err == nil {
+		return nil
+	}
+
+	if !errors.Is(err, keyusage.ErrKeyReused) {
+		logger.Errorf("failed to record key usage : connectionID=%s : %s", connID, err)
+
+		return nil
+	}
+
+	h.securityEvent(&events.SecurityEvent{
+		Time: time.Now().UTC(), Kind: events.SecurityKeyReuse, ConnectionID: connID, Detail: err.Error(),
+	})
+
+	if h.policy == keyusage.PolicyReject {
+		return err
+	}
+
+	return nil
+}
+
+// recordDIDKeyUsage records the keys of the router DID once the DID exchange completed. The connection is already
+// established, so the key reuse is only reported.
+func (o *Operation) recordDIDKeyUsage(connID, myDID string) {
+	docResolution, err := o.vdriRegistry.Resolve(myDID)
+	if err != nil {
+		logger.Debugf("router did not resolved : connectionID=%s : %s", connID, err)
+
+		return
+	}
+
+	var keys [][]byte
+
+	for _, vm := range docResolution.DIDDocument.VerificationMethod {
+		keys = append(keys, vm.Value)
+	}
+
+	err = o.keyUsage.record(connID, keyusage.RoleRouterDID, keys...)
+	if err != nil {
+		logger.Warnf("router did key reused : connectionID=%s : %s", connID, err)
+	}
+}
+
+// mediationGrantOptions creates the routing key granted to the wallet, a new one per mediation.
+func (o *Operation) mediationGrantOptions(connID string) (interface{}, error) {
+	_, pubKeyBytes, err := o.keyManager.CreateAndExportPubKeyBytes(kms.ED25519Type)
+	if err != nil {
+		return nil, fmt.Errorf("kms failed to create routing key: %w", err)
+	}
+
+	err = o.keyUsage.record(connID, keyusage.RoleRoutingKey, pubKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	routingKey, _ := fingerprint.CreateDIDKey(pubKeyBytes)
+
+	endpoint, upstreamKeys, err := o.upstreamRouting(routingKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return mediatordsvc.Options{ServiceEndpoint: endpoint, RoutingKeys: append([]string{routingKey}, upstreamKeys...)}, nil
+}
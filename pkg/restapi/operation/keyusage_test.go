@@ -0,0 +1,138 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockkms "github.com/hyperledger/aries-framework-go/pkg/mock/kms"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/keyusage"
+)
+
+func TestKeyUsage(t *testing.T) {
+	t.Run("distinct routing keys per mediation", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.keyManager = &mockkms.KeyManager{CrAndExportPubKeyValue: []byte("routing-key-1")}
+
+		_, err = o.mediationGrantOptions("conn-1")
+		require.NoError(t, err)
+
+		o.keyManager = &mockkms.KeyManager{CrAndExportPubKeyValue: []byte("routing-key-2")}
+
+		_, err = o.mediationGrantOptions("conn-2")
+		require.NoError(t, err)
+
+		report := keyReuseReport(t, o)
+		require.Equal(t, 2, report.Keys)
+		require.Equal(t, 2, report.Connections)
+		require.Empty(t, report.Reused)
+	})
+
+	t.Run("key reuse is audited", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		sub := o.Events().Subscribe(1, events.TopicSecurity)
+		defer sub.Unsubscribe()
+
+		o.keyManager = &mockkms.KeyManager{CrAndExportPubKeyValue: []byte("routing-key")}
+
+		_, err = o.mediationGrantOptions("conn-1")
+		require.NoError(t, err)
+
+		_, err = o.mediationGrantOptions("conn-2")
+		require.NoError(t, err)
+
+		e, ok := (<-sub.C).(*events.SecurityEvent)
+		require.True(t, ok)
+		require.Equal(t, events.SecurityKeyReuse, e.Kind)
+		require.Equal(t, "conn-2", e.ConnectionID)
+
+		report := keyReuseReport(t, o)
+		require.Len(t, report.Reused, 1)
+		require.Equal(t, keyusage.RoleRoutingKey, report.Reused[0].Role)
+		require.Equal(t, []string{"conn-1", "conn-2"}, report.Reused[0].ConnectionIDs)
+
+		entries, err := o.auditLog.Query(time.Time{}, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, audit.KeyReuse, entries[0].Type)
+	})
+
+	t.Run("key reuse is rejected", func(t *testing.T) {
+		cfg := config()
+		cfg.Mediation.KeyReusePolicy = keyusage.PolicyReject
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		require.NoError(t, o.keyUsage.record("conn-1", keyusage.RoleRouterDID, []byte("router-key")))
+
+		err = o.keyUsage.record("conn-2", keyusage.RoleRouterDID, []byte("router-key"))
+		require.ErrorIs(t, err, keyusage.ErrKeyReused)
+
+		o.keyManager = &mockkms.KeyManager{CrAndExportPubKeyValue: []byte("router-key")}
+
+		_, err = o.mediationGrantOptions("conn-3")
+		require.ErrorIs(t, err, keyusage.ErrKeyReused)
+	})
+
+	t.Run("kms error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.keyManager = &mockkms.KeyManager{CrAndExportPubKeyErr: errors.New("kms error")}
+
+		_, err = o.mediationGrantOptions("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "kms failed to create routing key")
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.keyUsage.registry, err = keyusage.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrGet:   errors.New("get error"),
+			ErrQuery: errors.New("query error"),
+		}))
+		require.NoError(t, err)
+
+		require.NoError(t, o.keyUsage.record("conn-1", keyusage.RoleRouterDID, []byte("router-key")))
+
+		w := httptest.NewRecorder()
+		o.keyUsage.getKeyReuseReport(w, httptest.NewRequest(http.MethodGet, keyReusePath, nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "query error")
+	})
+}
+
+func keyReuseReport(t *testing.T, o *Operation) *keyusage.Report {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	o.keyUsage.getKeyReuseReport(w, httptest.NewRequest(http.MethodGet, keyReusePath, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	report := &keyusage.Report{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), report))
+
+	return report
+}
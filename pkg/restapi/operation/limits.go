@@ -0,0 +1,88 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"github.com/hyperledger/aries-framework-go/pkg/client/didexchange"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	didexdsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/didexchange"
+
+	"github.com/trustbloc/hub-router/pkg/limits"
+)
+
+// LimitsResp model.
+type LimitsResp struct {
+	// MemoryLimit is the soft limit of the heap, in bytes.
+	MemoryLimit uint64        `json:"memoryLimit,omitempty"`
+	Handshakes  *limits.Stats `json:"handshakes,omitempty"`
+	Pickups     *limits.Stats `json:"pickups,omitempty"`
+}
+
+// limitsHandler holds the DID exchanges and the pickups within the limits of the router. Its config is nil if the
+// router isn't limited.
+type limitsHandler struct {
+	config     *limits.Config
+	handshakes *limits.Handshakes
+	pickups    *limits.Pickups
+}
+
+func (o *Operation) initLimits(config *Config) {
+	o.limits = &limitsHandler{}
+
+	if config.Admission.Limits == nil {
+		return
+	}
+
+	o.limits.config = config.Admission.Limits
+	o.limits.handshakes = limits.NewHandshakes(config.Admission.Limits)
+	o.limits.pickups = config.Admission.Pickups
+}
+
+// openHandshake counts the DID exchange of the connection, from the invitation it responds to, towards the handshake
+// limits, waiting for a slot if the handshakes are queued.
+func (h *limitsHandler) openHandshake(connectionID, invitationID string) error {
+	if h.handshakes == nil {
+		return nil
+	}
+
+	if err := h.handshakes.Open(connectionID, invitationID); err != nil {
+		return withProblem(problemOverloaded, err)
+	}
+
+	return nil
+}
+
+// closeHandshake releases the DID exchange of the connection once completed, or abandoned.
+func (h *limitsHandler) closeHandshake(msg service.StateMsg) {
+	if h.handshakes == nil || msg.Type != service.PostState ||
+		(msg.StateID != didexdsvc.StateIDCompleted && msg.StateID != didexdsvc.StateIDAbandoned) {
+		return
+	}
+
+	if event, ok := msg.Properties.(didexchange.Event); ok {
+		h.handshakes.Close(event.ConnectionID())
+	}
+}
+
+// stats returns the limits in use, nil if the router isn't limited.
+func (h *limitsHandler) stats() *LimitsResp {
+	if h.config == nil {
+		return nil
+	}
+
+	resp := &LimitsResp{MemoryLimit: h.config.MemoryLimit}
+
+	if h.handshakes != nil {
+		resp.Handshakes = h.handshakes.Stats()
+	}
+
+	if h.pickups != nil {
+		resp.Pickups = h.pickups.Stats()
+	}
+
+	return resp
+}
@@ -0,0 +1,129 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	didexdsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/didexchange"
+	mediatordsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/limits"
+)
+
+func TestLimits(t *testing.T) {
+	t.Run("handshakes", func(t *testing.T) {
+		cfg := config()
+		cfg.Admission.Limits = &limits.Config{MaxHandshakes: 1}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		require.NoError(t, o.limits.openHandshake("conn-1", "inv-1"))
+
+		err = o.limits.openHandshake("conn-2", "inv-1")
+		require.ErrorIs(t, err, limits.ErrLimitReached)
+		require.Equal(t, problemOverloaded, problemCode(err))
+
+		// the pre states and the other states don't release the handshake
+		for _, msg := range []service.StateMsg{
+			{Type: service.PreState, StateID: didexdsvc.StateIDCompleted, Properties: &didexchangeEvent{connID: "conn-1"}},
+			{Type: service.PostState, StateID: "responded", Properties: &didexchangeEvent{connID: "conn-1"}},
+		} {
+			o.limits.closeHandshake(msg)
+			require.Equal(t, 1, o.limits.handshakes.Stats().Open)
+		}
+
+		o.limits.closeHandshake(service.StateMsg{
+			Type: service.PostState, StateID: didexdsvc.StateIDAbandoned, Properties: &didexchangeEvent{connID: "conn-1"},
+		})
+		require.NoError(t, o.limits.openHandshake("conn-2", "inv-1"))
+	})
+
+	t.Run("queued handshakes don't hold up the other actions", func(t *testing.T) {
+		cfg := config()
+		cfg.Admission.Limits = &limits.Config{MaxHandshakes: 1, HandshakeQueue: 1, HandshakeQueueTimeout: time.Minute}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		require.NoError(t, o.limits.openHandshake("conn-1", "inv-1"))
+
+		actionCh := make(chan service.DIDCommAction)
+		defer close(actionCh)
+
+		go o.didCommActionListener(actionCh)
+
+		handshake := make(chan struct{})
+		mediation := make(chan struct{})
+
+		actionCh <- service.DIDCommAction{
+			Message:  service.DIDCommMsgMap{"@type": didexdsvc.RequestMsgType},
+			Continue: func(interface{}) { close(handshake) },
+		}
+
+		actionCh <- service.DIDCommAction{
+			Message:  service.DIDCommMsgMap{"@type": mediatordsvc.RequestMsgType},
+			Continue: func(interface{}) { close(mediation) },
+		}
+
+		select {
+		case <-mediation:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+
+		require.Equal(t, 1, o.limits.handshakes.Stats().Queued)
+
+		o.limits.closeHandshake(service.StateMsg{
+			Type: service.PostState, StateID: didexdsvc.StateIDCompleted, Properties: &didexchangeEvent{connID: "conn-1"},
+		})
+
+		select {
+		case <-handshake:
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+	})
+
+	t.Run("diagnostics", func(t *testing.T) {
+		pickups := limits.NewPickups(10)
+
+		cfg := config()
+		cfg.Admission.Limits = &limits.Config{MaxHandshakes: 5, MaxPickups: 10, MemoryLimit: 1 << 30}
+		cfg.Admission.Pickups = pickups
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.diagnostics.getDiagnostics(w, httptest.NewRequest(http.MethodGet, diagnosticsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &DiagnosticsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Positive(t, resp.MaxProcs)
+		require.Equal(t, uint64(1<<30), resp.Limits.MemoryLimit)
+		require.Equal(t, &limits.Stats{Max: 5}, resp.Limits.Handshakes)
+		require.Equal(t, &limits.Stats{Max: 10}, resp.Limits.Pickups)
+	})
+
+	t.Run("not limited", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		require.NoError(t, o.limits.openHandshake("conn-1", "inv-1"))
+		o.limits.closeHandshake(service.StateMsg{})
+		require.Nil(t, o.limits.stats())
+	})
+}
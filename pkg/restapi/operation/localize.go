@@ -0,0 +1,49 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/trustbloc/hub-router/pkg/l10n"
+)
+
+// localizedWriter localizes the error responses in the locale negotiated with the Accept-Language header.
+type localizedWriter struct {
+	http.ResponseWriter
+	catalog *l10n.Catalog
+	locale  string
+}
+
+// Explain returns the explanation of the error status from the l10n catalog, keyed by http-<status>.
+func (w *localizedWriter) Explain(status int) (string, string) {
+	return w.catalog.Text(w.locale, "http-"+strconv.Itoa(status))
+}
+
+// Unwrap returns the response writer the response is written to.
+func (w *localizedWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Localize is the REST API middleware localizing the error responses of the requests with an Accept-Language header :
+// the errors are explained in the closest language of the catalog, English by default, and the Content-Language
+// header is set to it.
+func (o *Operation) Localize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		acceptLanguage := req.Header.Get("Accept-Language")
+		if acceptLanguage == "" {
+			next.ServeHTTP(rw, req)
+
+			return
+		}
+
+		next.ServeHTTP(&localizedWriter{
+			ResponseWriter: rw, catalog: o.catalog, locale: o.catalog.Negotiate(acceptLanguage),
+		}, req)
+	})
+}
@@ -0,0 +1,93 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+func TestLocalize(t *testing.T) {
+	cfg := config()
+	cfg.Tenancy.APIKeys = tenant.NewKeys("operator-key", nil)
+
+	o, err := New(cfg)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+
+	for _, h := range o.GetRESTHandlers() {
+		router.HandleFunc(h.Path(), h.Handle()).Methods(h.Method())
+	}
+
+	router.Use(o.Localize, o.Authenticate, o.ValidateRequest)
+
+	serve := func(method, path, auth, acceptLanguage, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", auth)
+
+		if acceptLanguage != "" {
+			req.Header.Set("Accept-Language", acceptLanguage)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("error response", func(t *testing.T) {
+		for acceptLanguage, expected := range map[string][2]string{
+			"fr-CA,fr;q=0.9": {"fr", "La requête n'est pas authentifiée."},
+			"ja":             {"en", "The request is not authenticated."},
+		} {
+			w := serve(http.MethodGet, statsHistoryPath, "", acceptLanguage, "")
+			require.Equal(t, http.StatusUnauthorized, w.Code)
+			require.Equal(t, expected[0], w.Header().Get("Content-Language"))
+
+			resp := &httputil.ErrorResponse{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+			require.Equal(t, "invalid API key", resp.Message)
+			require.Equal(t, expected[1], resp.Explain)
+		}
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		w := serve(http.MethodPost, tenantsPath, "Bearer operator-key", "de", "{")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Equal(t, "de", w.Header().Get("Content-Language"))
+
+		resp := &InvalidRequestResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, "Die Anfrage ist ungültig.", resp.Explain)
+	})
+
+	t.Run("not localized", func(t *testing.T) {
+		w := serve(http.MethodGet, statsHistoryPath, "", "", "")
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+		require.Empty(t, w.Header().Get("Content-Language"))
+		require.NotContains(t, w.Body.String(), "explain")
+	})
+
+	t.Run("wrapped response writer", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		rw := &responseRecorder{ResponseWriter: &localizedWriter{ResponseWriter: w, catalog: o.catalog, locale: "es"}}
+
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "connection not found", "/", logger)
+		require.Equal(t, "es", w.Header().Get("Content-Language"))
+		require.Contains(t, w.Body.String(), "No se encontró el recurso.")
+	})
+}
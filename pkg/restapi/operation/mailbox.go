@@ -0,0 +1,208 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/mailbox"
+	"github.com/trustbloc/hub-router/pkg/pickup"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+// API endpoints.
+const (
+	queuePath = "/queues/{id}"
+)
+
+const mailboxSweepInterval = time.Minute
+
+// QueueLimits model.
+type QueueLimits struct {
+	MaxDepth       int    `json:"maxDepth,omitempty"`
+	MaxMessageSize int    `json:"maxMessageSize,omitempty"`
+	TTL            string `json:"ttl,omitempty"`
+}
+
+// QueueResp model.
+type QueueResp struct {
+	ConnectionID string `json:"connectionID"`
+	*mailbox.Queue
+	Limits *QueueLimits `json:"limits,omitempty"`
+}
+
+// PurgeQueueResp model.
+type PurgeQueueResp struct {
+	ConnectionID string `json:"connectionID"`
+	Purged       int    `json:"purged"`
+}
+
+// mailboxHandler serves the queues of the wallets, in the mailboxes of their recipient keys, and sweeps their expired
+// messages. Its mailboxes are nil if the queue store isn't configured.
+type mailboxHandler struct {
+	mailboxes   *mailbox.Provider
+	pickup      *pickup.Queue
+	didExchange aries.DIDExchange
+	// visible returns true if the connection is visible to the tenant.
+	visible func(tenantID, connID string) bool
+	// recipients returns the DIDs of the wallets seen by the router, keyed by connection ID.
+	recipients func() (map[string]string, error)
+}
+
+func (o *Operation) initMailbox(config *Config) {
+	o.mailbox = &mailboxHandler{
+		mailboxes: config.Queues.Mailboxes, pickup: o.pickup, didExchange: o.didExchange, visible: o.visible,
+		recipients: o.queueRecipients,
+	}
+}
+
+func (h *mailboxHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(queuePath, http.MethodGet, h.getQueue),
+		support.NewHTTPHandler(queuePath, http.MethodDelete, h.purgeQueue),
+	}
+}
+
+// start drops the expired messages of the wallets seen by the router periodically, if a TTL is set. The mailboxes of
+// a wallet are swept while the pickup queue holds its lock.
+func (h *mailboxHandler) start() {
+	if h.mailboxes == nil || h.mailboxes.Config().TTL <= 0 {
+		return
+	}
+
+	h.mailboxes.Start(mailboxSweepInterval, h.pickup, h.recipientDIDs)
+}
+
+// stats returns the number of queued messages rejected, expired and purged, nil if the mailboxes aren't bounded.
+func (h *mailboxHandler) stats() *mailbox.Stats {
+	if h.mailboxes == nil || !h.mailboxes.Config().Enabled() {
+		return nil
+	}
+
+	return h.mailboxes.Stats()
+}
+
+// recipientDIDs returns the DIDs of the wallets seen by the router.
+func (h *mailboxHandler) recipientDIDs() ([]string, error) {
+	recipients, err := h.recipients()
+	if err != nil {
+		return nil, err
+	}
+
+	dids := make([]string, 0, len(recipients))
+
+	for _, theirDID := range recipients {
+		dids = append(dids, theirDID)
+	}
+
+	return dids, nil
+}
+
+// inspectQueue returns the messages queued for the wallet, in the mailboxes of its recipient keys.
+func (h *mailboxHandler) inspectQueue(theirDID string) (*mailbox.Queue, error) {
+	keys, err := h.pickup.Keys(theirDID)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &mailbox.Queue{DID: theirDID, Messages: []*mailbox.Message{}}
+
+	for _, key := range keys {
+		mb, e := h.mailboxes.Inspect(key)
+		if e != nil {
+			return nil, e
+		}
+
+		q.Depth += mb.Depth
+		q.Size += mb.Size
+		q.Messages = append(q.Messages, mb.Messages...)
+	}
+
+	return q, nil
+}
+
+func (h *mailboxHandler) getQueue(rw http.ResponseWriter, req *http.Request) {
+	connID, theirDID, ok := h.queueRecipient(rw, req)
+	if !ok {
+		return
+	}
+
+	q, err := h.inspectQueue(theirDID)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to inspect queue - err=%s", err.Error()), queuePath, logger)
+
+		return
+	}
+
+	resp := &QueueResp{ConnectionID: connID, Queue: q}
+
+	if c := h.mailboxes.Config(); c.Enabled() {
+		resp.Limits = &QueueLimits{MaxDepth: c.MaxDepth, MaxMessageSize: c.MaxMessageSize}
+
+		if c.TTL > 0 {
+			resp.Limits.TTL = c.TTL.String()
+		}
+	}
+
+	httputil.WriteResponseWithLog(rw, resp, queuePath, logger)
+}
+
+// purgeQueue drops the messages queued for the wallet, eg: once it is lost, so that they don't hold up the storage
+// until they expire.
+func (h *mailboxHandler) purgeQueue(rw http.ResponseWriter, req *http.Request) {
+	connID, theirDID, ok := h.queueRecipient(rw, req)
+	if !ok {
+		return
+	}
+
+	purged, err := h.purgeMailboxes(theirDID)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to purge queue - err=%s", err.Error()), queuePath, logger)
+
+		return
+	}
+
+	logger.Infof("queue purged : connectionID=%s messages=%d", connID, purged)
+
+	httputil.WriteResponseWithLog(rw, &PurgeQueueResp{ConnectionID: connID, Purged: purged}, queuePath, logger)
+}
+
+// purgeMailboxes drops the messages queued for the wallet, in the mailboxes of its recipient keys, while the pickup
+// queue holds the lock of the wallet.
+func (h *mailboxHandler) purgeMailboxes(theirDID string) (int, error) {
+	return h.mailboxes.Purge(h.pickup, theirDID)
+}
+
+// queueRecipient returns the connection ID of the request and the DID of its wallet, or writes the error response.
+func (h *mailboxHandler) queueRecipient(rw http.ResponseWriter, req *http.Request) (string, string, bool) {
+	if h.mailboxes == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "queue store not configured", queuePath, logger)
+
+		return "", "", false
+	}
+
+	connID := mux.Vars(req)["id"]
+
+	conn, err := h.didExchange.GetConnection(connID)
+	if err != nil || !h.visible(tenant.FromContext(req.Context()), connID) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, fmt.Sprintf("connection not found : %s", connID),
+			queuePath, logger)
+
+		return "", "", false
+	}
+
+	return connID, conn.TheirDID, true
+}
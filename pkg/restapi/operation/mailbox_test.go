@@ -0,0 +1,160 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/internal/mock/didexchange"
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/mailbox"
+	"github.com/trustbloc/hub-router/pkg/pickup"
+	"github.com/trustbloc/hub-router/pkg/presence"
+)
+
+func TestQueues(t *testing.T) {
+	newOperation := func(t *testing.T, limits *mailbox.Config) *Operation {
+		t.Helper()
+
+		cfg := config()
+		cfg.Aries.(*mockprovider.Provider).StorageProviderValue = mem.NewProvider()
+		cfg.Queues.Mailboxes = mailbox.NewProvider(cfg.Storage.Persistent, limits, messagepickup.Namespace)
+
+		q, err := pickup.NewQueue(cfg.Queues.Mailboxes, lock.NewLocal())
+		require.NoError(t, err)
+
+		cfg.Queues.Pickup = q
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-1", State: connection.StateNameCompleted, ThreadID: "thid-1",
+			MyDID: "did:router", TheirDID: "did:wallet", Namespace: connection.MyNSPrefix,
+		}))
+
+		// a mailbox per recipient key
+		queueMessages(t, q, "did:wallet", "key-1", 1)
+		queueMessages(t, q, "did:wallet", "key-2", 1)
+
+		return o
+	}
+
+	request := func(o *Operation, method, connID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := mux.SetURLVars(httptest.NewRequest(method, "/queues/"+connID, nil), map[string]string{"id": connID})
+
+		if method == http.MethodDelete {
+			o.mailbox.purgeQueue(w, req)
+		} else {
+			o.mailbox.getQueue(w, req)
+		}
+
+		return w
+	}
+
+	t.Run("inspect and purge", func(t *testing.T) {
+		o := newOperation(t, &mailbox.Config{MaxDepth: 10, TTL: time.Hour})
+
+		w := request(o, http.MethodGet, "conn-1")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		q := &QueueResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), q))
+		require.Equal(t, "conn-1", q.ConnectionID)
+		require.Equal(t, "did:wallet", q.DID)
+		require.Equal(t, 2, q.Depth)
+		require.Len(t, q.Messages, 2)
+		require.NotEmpty(t, q.Messages[0].ID)
+		require.NotNil(t, q.Messages[0].ExpiresAt)
+		require.Equal(t, &QueueLimits{MaxDepth: 10, TTL: "1h0m0s"}, q.Limits)
+
+		w = request(o, http.MethodDelete, "conn-1")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		purged := &PurgeQueueResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), purged))
+		require.Equal(t, 2, purged.Purged)
+
+		w = request(o, http.MethodGet, "conn-1")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		q = &QueueResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), q))
+		require.Zero(t, q.Depth)
+
+		w = httptest.NewRecorder()
+		o.diagnostics.getDiagnostics(w, httptest.NewRequest(http.MethodGet, diagnosticsPath, nil))
+		require.Contains(t, w.Body.String(), `"queueLimits":{"full":0,"oversized":0,"expired":0,"purged":2}`)
+	})
+
+	t.Run("unlimited", func(t *testing.T) {
+		o := newOperation(t, nil)
+
+		w := request(o, http.MethodGet, "conn-1")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NotContains(t, w.Body.String(), "limits")
+	})
+
+	t.Run("connection not found", func(t *testing.T) {
+		o := newOperation(t, nil)
+
+		require.Equal(t, http.StatusNotFound, request(o, http.MethodGet, "conn-2").Code)
+		require.Equal(t, http.StatusNotFound, request(o, http.MethodDelete, "conn-2").Code)
+	})
+
+	t.Run("queue store not configured", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := request(o, http.MethodGet, "conn-1")
+		require.Equal(t, http.StatusNotFound, w.Code)
+		require.Contains(t, w.Body.String(), "queue store not configured")
+	})
+
+	t.Run("mailbox recipients", func(t *testing.T) {
+		o := newOperation(t, &mailbox.Config{TTL: time.Hour})
+		o.mailbox.start()
+		defer o.mailbox.mailboxes.Stop()
+
+		dids, err := o.mailbox.recipientDIDs()
+		require.NoError(t, err)
+		require.Empty(t, dids)
+
+		o.didExchange = &didexchange.MockClient{TheirDID: "did:wallet"}
+		o.seen("conn-1", presence.SourceMediation)
+
+		dids, err = o.mailbox.recipientDIDs()
+		require.NoError(t, err)
+		require.Equal(t, []string{"did:wallet"}, dids)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		o := newOperation(t, nil)
+
+		store := &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry), ErrGet: errors.New("get error")}
+		o.mailbox.mailboxes = mailbox.NewProvider(mockstore.NewCustomMockStoreProvider(store), nil, messagepickup.Namespace)
+
+		require.Equal(t, http.StatusInternalServerError, request(o, http.MethodGet, "conn-1").Code)
+		require.Equal(t, http.StatusInternalServerError, request(o, http.MethodDelete, "conn-1").Code)
+	})
+}
@@ -0,0 +1,134 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	mediatordsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/mediation"
+	"github.com/trustbloc/hub-router/pkg/presence"
+)
+
+const keylistUpdateAdd = "add"
+
+// autoGrantMediation grants mediation on the connection created through create-conn-req or the REST API, registering
+// the recipient keys of the wallet's DID doc with the router as if the wallet had requested mediation and updated its
+// keylist, unless the policy rejects the wallet or requires the approval of the operator. Returns false if mediation
+// isn't auto-granted or if the grant failed; the wallet can then request it explicitly.
+func (o *Operation) autoGrantMediation(corr *correlation.Record, connID, myDID string, theirDoc *did.Doc) bool {
+	if o.routeSvc == nil {
+		return false
+	}
+
+	entry := &audit.Entry{
+		Type: audit.MediationAction, ConnectionID: connID, ThreadID: corr.ThreadID,
+		MsgType: mediatordsvc.KeylistUpdateMsgType, Detail: "auto-granted",
+	}
+
+	err := o.tenancy.checkActive(o.tenantOf(connID))
+	if err == nil {
+		err = o.checkMediationGrant(connID, theirDoc.ID)
+	}
+
+	if err == nil && o.policies.store.Current().ManualMediation() {
+		err = errMediationPending
+	}
+
+	if err == nil {
+		err = o.registerRecipientKeys(myDID, theirDoc)
+	}
+
+	o.metrics.countMediation(err)
+
+	if err != nil {
+		logger.Warnf("auto-grant mediation connectionID=[%s] : %s", connID, err)
+
+		entry.Type = audit.ActionRejected
+		entry.Detail = fmt.Sprintf("auto-grant mediation : %s", err)
+		o.recordAudit(entry)
+
+		return false
+	}
+
+	o.recordAudit(entry)
+	o.seen(connID, presence.SourceMediation)
+	o.events.Publish(&events.MediationEvent{
+		Time: time.Now().UTC(), ConnectionID: connID, ThreadID: corr.ThreadID, MsgType: mediatordsvc.KeylistUpdateMsgType,
+	})
+
+	return true
+}
+
+// registerMediationClient registers the client mediation was granted to on the connection, with the routing keys of the
+// grant. The DID and label of the client are looked up from the connection if the DID isn't given.
+func (o *Operation) registerMediationClient(connID, theirDID, label string, routingKeys []string) {
+	if connID == "" {
+		return
+	}
+
+	if theirDID == "" {
+		record, err := o.connections.GetConnectionRecord(connID)
+		if err != nil {
+			logger.Warnf("failed to get mediation client connectionID=[%s] : %s", connID, err)
+
+			return
+		}
+
+		theirDID, label = record.TheirDID, record.TheirLabel
+	}
+
+	err := o.mediationClients.Register(&mediation.Client{
+		ConnectionID: connID, TheirDID: theirDID, Label: label, RoutingKeys: routingKeys,
+	})
+	if err != nil {
+		logger.Warnf("failed to register mediation client connectionID=[%s] : %s", connID, err)
+	}
+}
+
+// grantRoutingKeys returns the routing keys of the mediation grant options.
+func grantRoutingKeys(args interface{}) []string {
+	if opts, ok := args.(mediatordsvc.Options); ok {
+		return opts.RoutingKeys
+	}
+
+	return nil
+}
+
+func (o *Operation) registerRecipientKeys(myDID string, theirDoc *did.Doc) error {
+	var updates []mediatordsvc.Update
+
+	for _, svc := range theirDoc.Service {
+		for _, key := range svc.RecipientKeys {
+			updates = append(updates, mediatordsvc.Update{RecipientKey: key, Action: keylistUpdateAdd})
+		}
+	}
+
+	if len(updates) == 0 {
+		return errors.New("no recipient keys in did doc")
+	}
+
+	_, err := o.routeSvc.HandleInbound(service.NewDIDCommMsgMap(&mediatordsvc.KeylistUpdate{
+		ID:      uuid.New().String(),
+		Type:    mediatordsvc.KeylistUpdateMsgType,
+		Updates: updates,
+	}), service.NewDIDCommContext(myDID, theirDoc.ID, nil))
+	if err != nil {
+		return fmt.Errorf("register recipient keys : %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	mediatordsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+	mockroute "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/protocol/mediator"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/events"
+)
+
+func TestAutoGrantMediation(t *testing.T) {
+	t.Run("create-conn-req grants mediation", func(t *testing.T) {
+		cfg := config()
+		cfg.Mediation.AutoGrant = true
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		updates := make(chan *mediatordsvc.KeylistUpdate, 1)
+
+		o.routeSvc = &mockroute.MockMediatorSvc{
+			HandleFunc: func(msg service.DIDCommMsg) (string, error) {
+				update := &mediatordsvc.KeylistUpdate{}
+				require.NoError(t, msg.Decode(update))
+
+				updates <- update
+
+				return msg.ID(), nil
+			},
+		}
+
+		sub := o.Events().Subscribe(10, events.TopicMediation)
+		defer sub.Unsubscribe()
+
+		signingKey := base58.Encode(make([]byte, 32))
+
+		resp, err := o.handleCreateConnReq(receivedMsg(t, CreateConnReq{
+			ID:   uuid.New().String(),
+			Type: createConnReq,
+			Data: &CreateConnReqData{SigningKey: signingKey, ServiceEndpoint: "http://wallet.example.com"},
+		}))
+		require.NoError(t, err)
+
+		pMsg := &CreateConnResp{}
+		require.NoError(t, resp.Decode(pMsg))
+		require.True(t, pMsg.Data.MediationGranted)
+
+		update := <-updates
+		require.Equal(t, mediatordsvc.KeylistUpdateMsgType, update.Type)
+		require.Equal(t, []mediatordsvc.Update{{RecipientKey: signingKey, Action: keylistUpdateAdd}}, update.Updates)
+
+		select {
+		case e := <-sub.C:
+			require.Equal(t, pMsg.Data.ConnectionID, e.(*events.MediationEvent).ConnectionID)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+
+		entries, err := o.auditLog.Query(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, audit.MediationAction, entries[len(entries)-1].Type)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		require.False(t, o.autoGrantMediation(&correlation.Record{}, "conn-1", "did:peer:1", &did.Doc{}))
+	})
+
+	t.Run("no recipient keys", func(t *testing.T) {
+		cfg := config()
+		cfg.Mediation.AutoGrant = true
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		require.False(t, o.autoGrantMediation(&correlation.Record{}, "conn-1", "did:peer:1", &did.Doc{}))
+
+		entries, err := o.auditLog.Query(time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, audit.ActionRejected, entries[len(entries)-1].Type)
+		require.Contains(t, entries[len(entries)-1].Detail, "no recipient keys in did doc")
+	})
+
+	t.Run("register keys error", func(t *testing.T) {
+		cfg := config()
+		cfg.Mediation.AutoGrant = true
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.routeSvc = &mockroute.MockMediatorSvc{
+			HandleFunc: func(service.DIDCommMsg) (string, error) {
+				return "", errors.New("handle error")
+			},
+		}
+
+		require.False(t, o.autoGrantMediation(&correlation.Record{}, "conn-1", "did:peer:1", &did.Doc{
+			Service: []did.Service{{RecipientKeys: []string{"key-1"}}},
+		}))
+	})
+}
@@ -0,0 +1,258 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// API endpoints.
+const (
+	mediationRequestsPath       = "/mediation-requests"
+	mediationRequestApprovePath = mediationRequestsPath + "/{id}/approve"
+	mediationRequestDenyPath    = mediationRequestsPath + "/{id}/deny"
+)
+
+var (
+	errMediationPending  = errors.New("mediation pending approval")
+	errMediationDenied   = errors.New("mediation denied by the operator")
+	errMediationReplaced = errors.New("mediation request replaced by a new request")
+	errMediationNotFound = errors.New("mediation request not found")
+	errMediationConflict = errors.New("mediation request revision conflict")
+)
+
+// MediationRequest model: the mediation request held until the operator approves or denies it, keyed by the
+// connection it was received on.
+type MediationRequest struct {
+	ConnectionID string    `json:"connectionID"`
+	ThreadID     string    `json:"threadID"`
+	TheirDID     string    `json:"theirDID,omitempty"`
+	Label        string    `json:"label,omitempty"`
+	Tenant       string    `json:"tenant,omitempty"`
+	Received     time.Time `json:"received"`
+	// Revision identifies the request among the requests held since the router started, the request replacing the
+	// request of a connection has another revision.
+	Revision int `json:"revision"`
+}
+
+// MediationRequestsResp model.
+type MediationRequestsResp struct {
+	Requests []*MediationRequest `json:"requests"`
+}
+
+type pendingMediation struct {
+	request *MediationRequest
+	action  service.DIDCommAction
+	corr    *correlation.Record
+	entry   *audit.Entry
+}
+
+// mediationApprovals holds the mediation requests pending approval, in memory : the requests held when the router
+// stops are lost, the wallets request mediation again.
+type mediationApprovals struct {
+	mutex    sync.Mutex
+	pending  map[string]*pendingMediation
+	revision int
+}
+
+// hold holds the mediation request, and returns the request of the connection it replaces if any.
+func (a *mediationApprovals) hold(p *pendingMediation) *pendingMediation {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.pending == nil {
+		a.pending = make(map[string]*pendingMediation)
+	}
+
+	a.revision++
+	p.request.Revision = a.revision
+
+	replaced := a.pending[p.request.ConnectionID]
+	a.pending[p.request.ConnectionID] = p
+
+	return replaced
+}
+
+// take removes the mediation request of the connection if it matches the precondition, and returns it.
+func (a *mediationApprovals) take(connID string, precondition *precondition) (*pendingMediation, error) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	p, ok := a.pending[connID]
+
+	current := 0
+	if ok {
+		current = p.request.Revision
+	}
+
+	if revision := precondition.revision(current); revision != anyRevision && revision != current {
+		return nil, fmt.Errorf("%w : current revision is %d", errMediationConflict, current)
+	}
+
+	if !ok {
+		return nil, errMediationNotFound
+	}
+
+	delete(a.pending, connID)
+
+	return p, nil
+}
+
+// list returns the mediation requests pending approval, oldest first.
+func (a *mediationApprovals) list() []*MediationRequest {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	requests := []*MediationRequest{}
+
+	for _, p := range a.pending {
+		requests = append(requests, p.request)
+	}
+
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].Received.Before(requests[j].Received)
+	})
+
+	return requests
+}
+
+// admitMediationRequest applies the mediation policy to the mediation request received on the connection, and returns
+// the grant options. The requests accepted are held if the policy requires the approval of the operator.
+func (o *Operation) admitMediationRequest(connID string) (interface{}, error) {
+	err := o.checkMediationRequest(connID, "")
+	if err != nil {
+		return nil, err
+	}
+
+	if o.policies.store.Current().ManualMediation() {
+		return nil, errMediationPending
+	}
+
+	return o.mediationGrantOptions(connID)
+}
+
+// checkMediationRequest applies the auto-accept rules, the allowlist and the quota to the mediation request of the
+// wallet of the connection.
+func (o *Operation) checkMediationRequest(connID, theirDID string) error {
+	err := o.checkMediationPolicy(o.tenantOf(connID))
+	if err != nil {
+		return err
+	}
+
+	return o.checkMediationGrant(connID, theirDID)
+}
+
+// holdMediation holds the mediation request until the operator approves or denies it, replacing the request held for
+// the connection if any.
+func (o *Operation) holdMediation(msg service.DIDCommAction, corr *correlation.Record, entry *audit.Entry) {
+	r := &MediationRequest{
+		ConnectionID: corr.ConnectionID, ThreadID: corr.ThreadID, Tenant: o.tenantOf(corr.ConnectionID),
+		Received: time.Now().UTC(),
+	}
+
+	if record, err := o.connections.GetConnectionRecord(corr.ConnectionID); err == nil {
+		r.TheirDID, r.Label = record.TheirDID, record.TheirLabel
+	}
+
+	replaced := o.mediationApprovals.hold(&pendingMediation{request: r, action: msg, corr: corr, entry: entry})
+	if replaced != nil {
+		o.completeAction(replaced.action, replaced.corr, replaced.entry, nil, errMediationReplaced)
+	}
+
+	logger.Infof("msgType=[%s] id=[%s] msg=[%s]", msg.Message.Type(), msg.Message.ID(), errMediationPending)
+
+	o.recordAudit(&audit.Entry{
+		Type: audit.MediationPending, MsgType: entry.MsgType, ThreadID: corr.ThreadID, ConnectionID: corr.ConnectionID,
+		Detail: msg.Message.ID(), Actor: entry.Actor,
+	})
+	o.correlate(corr)
+}
+
+func (o *Operation) getMediationRequests(rw http.ResponseWriter, _ *http.Request) {
+	httputil.WriteResponseWithLog(rw, &MediationRequestsResp{Requests: o.mediationApprovals.list()},
+		mediationRequestsPath, logger)
+}
+
+// approveMediationRequest grants the mediation request held for the connection, if it still complies with the
+// policy : it is denied otherwise.
+func (o *Operation) approveMediationRequest(rw http.ResponseWriter, req *http.Request) {
+	p, ok := o.takeMediationRequest(rw, req, mediationRequestApprovePath)
+	if !ok {
+		return
+	}
+
+	var args interface{}
+
+	err := o.checkMediationRequest(p.request.ConnectionID, p.request.TheirDID)
+	if err == nil {
+		args, err = o.mediationGrantOptions(p.request.ConnectionID)
+	}
+
+	p.entry.Actor = requestActor(req)
+	o.completeAction(p.action, p.corr, p.entry, args, err)
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusConflict,
+			fmt.Sprintf("mediation request denied - err=%s", err.Error()), mediationRequestApprovePath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, p.request, mediationRequestApprovePath, logger)
+}
+
+// denyMediationRequest rejects the mediation request held for the connection.
+func (o *Operation) denyMediationRequest(rw http.ResponseWriter, req *http.Request) {
+	p, ok := o.takeMediationRequest(rw, req, mediationRequestDenyPath)
+	if !ok {
+		return
+	}
+
+	p.entry.Actor = requestActor(req)
+	o.completeAction(p.action, p.corr, p.entry, nil, errMediationDenied)
+
+	httputil.WriteResponseWithLog(rw, p.request, mediationRequestDenyPath, logger)
+}
+
+// takeMediationRequest takes the mediation request of the request if it matches its If-Match header, or writes the
+// error response.
+func (o *Operation) takeMediationRequest(rw http.ResponseWriter, req *http.Request, path string) (*pendingMediation,
+	bool) {
+	precondition, err := ifMatch(req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), path, logger)
+
+		return nil, false
+	}
+
+	p, err := o.mediationApprovals.take(mux.Vars(req)["id"], precondition)
+
+	switch {
+	case errors.Is(err, errMediationConflict):
+		writePreconditionFailed(rw, err, path)
+
+		return nil, false
+	case err != nil:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, err.Error(), path, logger)
+
+		return nil, false
+	}
+
+	return p, true
+}
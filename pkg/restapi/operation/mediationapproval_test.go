@@ -0,0 +1,265 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	mediatordsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	mockroute "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/protocol/mediator"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/mediation"
+	"github.com/trustbloc/hub-router/pkg/policy"
+)
+
+func TestMediationApproval(t *testing.T) {
+	type outcome struct {
+		args interface{}
+		err  error
+	}
+
+	newOperation := func(t *testing.T, doc *policy.Document) *Operation {
+		t.Helper()
+
+		cfg := config()
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		_, _, err = o.policies.store.Put(doc, false)
+		require.NoError(t, err)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+
+		for _, r := range []*connection.Record{
+			{ConnectionID: "conn-1", TheirDID: "did:peer:wallet1", TheirLabel: "Alice's Wallet"},
+			{ConnectionID: "conn-2", TheirDID: "did:peer:wallet2"},
+		} {
+			r.State, r.ThreadID, r.MyDID, r.Namespace = connection.StateNameCompleted, "thid-"+r.ConnectionID,
+				"did:router", connection.MyNSPrefix
+			require.NoError(t, recorder.SaveConnectionRecord(r))
+		}
+
+		return o
+	}
+
+	request := func(o *Operation, connID string) chan outcome {
+		done := make(chan outcome, 1)
+
+		o.handleAction(service.DIDCommAction{
+			Message:    service.DIDCommMsgMap{"@type": mediatordsvc.RequestMsgType, "@id": "req-" + connID},
+			Properties: actionProperties{"connectionID": connID},
+			Continue:   func(args interface{}) { done <- outcome{args: args} },
+			Stop:       func(err error) { done <- outcome{err: err} },
+		})
+
+		return done
+	}
+
+	decide := func(o *Operation, path, connID string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler(w, mux.SetURLVars(httptest.NewRequest(http.MethodPost, path, nil), map[string]string{"id": connID}))
+
+		return w
+	}
+
+	pending := func(t *testing.T, o *Operation) []*MediationRequest {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		o.getMediationRequests(w, httptest.NewRequest(http.MethodGet, mediationRequestsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &MediationRequestsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+
+		return resp.Requests
+	}
+
+	t.Run("manual approval", func(t *testing.T) {
+		o := newOperation(t, &policy.Document{AutoAccept: &policy.AutoAccept{ManualMediation: true}})
+		require.Empty(t, pending(t, o))
+
+		first := request(o, "conn-1")
+		second := request(o, "conn-2")
+		require.Empty(t, first)
+
+		requests := pending(t, o)
+		require.Len(t, requests, 2)
+		require.Equal(t, "conn-1", requests[0].ConnectionID)
+		require.Equal(t, "did:peer:wallet1", requests[0].TheirDID)
+		require.Equal(t, "Alice's Wallet", requests[0].Label)
+		require.Equal(t, "req-conn-1", requests[0].ThreadID)
+
+		w := decide(o, mediationRequestApprovePath, "conn-1", o.approveMediationRequest)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		result := <-first
+		require.NoError(t, result.err)
+		require.Len(t, grantRoutingKeys(result.args), 1)
+
+		w = decide(o, mediationRequestDenyPath, "conn-2", o.denyMediationRequest)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.ErrorIs(t, (<-second).err, errMediationDenied)
+
+		require.Empty(t, pending(t, o))
+
+		w = decide(o, mediationRequestDenyPath, "conn-2", o.denyMediationRequest)
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		w = decide(o, mediationRequestApprovePath, "conn-2", o.approveMediationRequest)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("replaced request", func(t *testing.T) {
+		o := newOperation(t, &policy.Document{AutoAccept: &policy.AutoAccept{ManualMediation: true}})
+
+		first := request(o, "conn-1")
+		request(o, "conn-1")
+
+		require.ErrorIs(t, (<-first).err, errMediationReplaced)
+		require.Len(t, pending(t, o), 1)
+	})
+
+	t.Run("if-match revisions", func(t *testing.T) {
+		o := newOperation(t, &policy.Document{AutoAccept: &policy.AutoAccept{ManualMediation: true}})
+
+		conditional := func(path, etag string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, path, nil), map[string]string{"id": "conn-1"})
+			req.Header.Set(ifMatchHeader, etag)
+			handler(w, req)
+
+			return w
+		}
+
+		first := request(o, "conn-1")
+		stale := pending(t, o)[0].Revision
+
+		// the request approved by the other admin tool is replaced by a new request of the wallet
+		second := request(o, "conn-1")
+		require.ErrorIs(t, (<-first).err, errMediationReplaced)
+
+		current := pending(t, o)[0].Revision
+		require.NotEqual(t, stale, current)
+
+		w := conditional(mediationRequestApprovePath, revisionETag(stale), o.approveMediationRequest)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+		require.Contains(t, w.Body.String(), "precondition failed")
+
+		w = conditional(mediationRequestDenyPath, "W/"+revisionETag(current), o.denyMediationRequest)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = conditional(mediationRequestDenyPath, "W/", o.denyMediationRequest)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		require.Len(t, pending(t, o), 1)
+
+		w = conditional(mediationRequestDenyPath, revisionETag(current), o.denyMediationRequest)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.ErrorIs(t, (<-second).err, errMediationDenied)
+
+		w = conditional(mediationRequestApprovePath, "*", o.approveMediationRequest)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("approval against the policy", func(t *testing.T) {
+		o := newOperation(t, &policy.Document{AutoAccept: &policy.AutoAccept{ManualMediation: true}})
+
+		done := request(o, "conn-1")
+
+		_, _, err := o.policies.store.Put(&policy.Document{
+			AutoAccept: &policy.AutoAccept{ManualMediation: true},
+			Allowlists: &policy.Allowlists{MediationDIDs: []string{"did:peer:wallet2"}},
+		}, false)
+		require.NoError(t, err)
+
+		w := decide(o, mediationRequestApprovePath, "conn-1", o.approveMediationRequest)
+		require.Equal(t, http.StatusConflict, w.Code)
+		require.Contains(t, w.Body.String(), "mediation not allowed : did:peer:wallet1")
+		require.Error(t, (<-done).err)
+	})
+
+	t.Run("mediation allowlist", func(t *testing.T) {
+		o := newOperation(t, &policy.Document{
+			Allowlists: &policy.Allowlists{MediationDIDs: []string{"did:peer:wallet2"}},
+		})
+
+		result := <-request(o, "conn-1")
+		require.Error(t, result.err)
+		require.Contains(t, result.err.Error(), "mediation not allowed : did:peer:wallet1")
+
+		require.NoError(t, (<-request(o, "conn-2")).err)
+
+		result = <-request(o, "conn-3")
+		require.Error(t, result.err)
+		require.Contains(t, result.err.Error(), "get connection")
+	})
+
+	t.Run("auto-grant skipped", func(t *testing.T) {
+		cfg := config()
+		cfg.Mediation.AutoGrant = true
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.routeSvc = &mockroute.MockMediatorSvc{}
+
+		_, _, err = o.policies.store.Put(&policy.Document{AutoAccept: &policy.AutoAccept{ManualMediation: true}}, false)
+		require.NoError(t, err)
+
+		didDoc := mockdiddoc.GetMockDIDDoc(t)
+		require.False(t, o.autoGrantMediation(&correlation.Record{}, "conn-1", "did:router", didDoc))
+
+		_, _, err = o.policies.store.Put(&policy.Document{}, false)
+		require.NoError(t, err)
+		require.True(t, o.autoGrantMediation(&correlation.Record{}, "conn-1", "did:router", didDoc))
+	})
+
+	t.Run("mediation quota", func(t *testing.T) {
+		o := newOperation(t, &policy.Document{Quotas: &policy.Quotas{MediationsPerTenant: 1}})
+
+		o.assignTenant("tenant-1", "conn-1", "conn-2")
+
+		require.NoError(t, (<-request(o, "conn-1")).err)
+		require.NoError(t, (<-request(o, "conn-1")).err)
+
+		result := <-request(o, "conn-2")
+		require.Error(t, result.err)
+		require.Contains(t, result.err.Error(), "mediation quota exceeded : 1 wallets per tenant")
+
+		var err error
+
+		o.mediationClients, err = mediation.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrQuery: errors.New("query error"),
+		}))
+		require.NoError(t, err)
+
+		result = <-request(o, "conn-2")
+		require.Error(t, result.err)
+		require.Contains(t, result.err.Error(), "mediation quota")
+	})
+}
+
+type actionProperties map[string]interface{}
+
+func (p actionProperties) All() map[string]interface{} {
+	return p
+}
@@ -0,0 +1,158 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/metering"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// API endpoints.
+const (
+	meteringRecordsPath = "/metering/records"
+	meteringClosePath   = "/metering/periods/{period}/close"
+)
+
+const meteringCloseInterval = time.Minute
+
+// MeteringRecordsResp model.
+type MeteringRecordsResp struct {
+	Records []*metering.Record `json:"records"`
+}
+
+// MeteringCloseResp model.
+type MeteringCloseResp struct {
+	*metering.Close
+	Records []*metering.Record `json:"records"`
+}
+
+// meteringHandler serves the billing records of the tenants, and closes the metering periods. Its ledger is nil if
+// the metering isn't enabled.
+type meteringHandler struct {
+	ledger *metering.Ledger
+	// stream writes the export file of the records in the response.
+	stream func(rw http.ResponseWriter, endpoint, fileName string, write writeFunc)
+}
+
+func (o *Operation) initMetering(config *Config) error {
+	o.metering = &meteringHandler{stream: o.export.stream}
+
+	if !config.Tenancy.Metering {
+		return nil
+	}
+
+	var err error
+
+	o.metering.ledger, err = metering.New(config.Storage.Persistent, o.stats.TenantRollups, config.Tenancy.MeteringSink)
+	if err != nil {
+		return fmt.Errorf("metering ledger: %w", err)
+	}
+
+	return nil
+}
+
+func (h *meteringHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(meteringRecordsPath, http.MethodGet, h.getMeteringRecords),
+		support.NewHTTPHandler(meteringClosePath, http.MethodPost, h.closeMeteringPeriod),
+	}
+}
+
+// start closes the metering periods ended periodically.
+func (h *meteringHandler) start() {
+	if h.ledger != nil {
+		h.ledger.Start(meteringCloseInterval)
+	}
+}
+
+func (h *meteringHandler) getMeteringRecords(rw http.ResponseWriter, req *http.Request) {
+	if h.ledger == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "metering not enabled", meteringRecordsPath, logger)
+
+		return
+	}
+
+	from, to, err := getTimeRange(req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), meteringRecordsPath, logger)
+
+		return
+	}
+
+	records, err := h.ledger.Records(req.URL.Query().Get("tenant"), from, to)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get metering records - err=%s", err.Error()), meteringRecordsPath, logger)
+
+		return
+	}
+
+	if req.URL.Query().Get("format") != csvFormat {
+		httputil.WriteResponseWithLog(rw, &MeteringRecordsResp{Records: records}, meteringRecordsPath, logger)
+
+		return
+	}
+
+	h.stream(rw, meteringRecordsPath, "metering.csv", func(w *csv.Writer) error {
+		return w.WriteAll(meteringRecords(records))
+	})
+}
+
+func (h *meteringHandler) closeMeteringPeriod(rw http.ResponseWriter, req *http.Request) {
+	if h.ledger == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "metering not enabled", meteringClosePath, logger)
+
+		return
+	}
+
+	period, err := time.Parse(time.RFC3339, mux.Vars(req)["period"])
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest,
+			fmt.Sprintf("invalid period, expected RFC3339 : %s", err.Error()), meteringClosePath, logger)
+
+		return
+	}
+
+	c, records, err := h.ledger.Close(period, time.Now().UTC())
+	if errors.Is(err, metering.ErrPeriodOpen) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusConflict, err.Error(), meteringClosePath, logger)
+
+		return
+	}
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to close metering period - err=%s", err.Error()), meteringClosePath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, &MeteringCloseResp{Close: c, Records: records}, meteringClosePath, logger)
+}
+
+func meteringRecords(records []*metering.Record) [][]string {
+	rows := [][]string{{"id", "tenant", "metric", "quantity", "period", "periodEnd"}}
+
+	for _, r := range records {
+		rows = append(rows, []string{
+			r.ID, r.Tenant, r.Metric, strconv.Itoa(r.Quantity),
+			r.Period.Format(time.RFC3339), r.PeriodEnd.Format(time.RFC3339),
+		})
+	}
+
+	return rows
+}
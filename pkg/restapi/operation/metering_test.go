@@ -0,0 +1,125 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/metering"
+	"github.com/trustbloc/hub-router/pkg/stats"
+)
+
+func TestMetering(t *testing.T) {
+	period := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+
+	closePeriod := func(o *Operation, val string) *httptest.ResponseRecorder {
+		req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/metering/periods/"+val+"/close", nil),
+			map[string]string{"period": val})
+
+		w := httptest.NewRecorder()
+		o.metering.closeMeteringPeriod(w, req)
+
+		return w
+	}
+
+	t.Run("close and export", func(t *testing.T) {
+		cfg := config()
+		cfg.Tenancy.Metering = true
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		require.NoError(t, o.stats.Add("tenant-1", period, stats.Forwards, 3))
+		require.NoError(t, o.stats.Add("tenant-2", period, stats.Forwards, 1))
+
+		for i := 0; i < 2; i++ {
+			w := closePeriod(o, period.Format(time.RFC3339))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			resp := &MeteringCloseResp{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+			require.Equal(t, 2, resp.Count)
+			require.Len(t, resp.Records, 2)
+		}
+
+		w := httptest.NewRecorder()
+		o.metering.getMeteringRecords(w, httptest.NewRequest(http.MethodGet, meteringRecordsPath+"?tenant=tenant-1", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &MeteringRecordsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Len(t, resp.Records, 1)
+		require.Equal(t, 3, resp.Records[0].Quantity)
+
+		w = httptest.NewRecorder()
+		o.metering.getMeteringRecords(w, httptest.NewRequest(http.MethodGet, meteringRecordsPath+"?format=csv", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, csvContentType, w.Header().Get("Content-Type"))
+		require.Contains(t, w.Body.String(), "tenant-2,forwards,1,")
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		cfg := config()
+		cfg.Tenancy.Metering = true
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		w := closePeriod(o, "invalid")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = closePeriod(o, time.Now().UTC().Format(time.RFC3339))
+		require.Equal(t, http.StatusConflict, w.Code)
+		require.Contains(t, w.Body.String(), metering.ErrPeriodOpen.Error())
+
+		w = httptest.NewRecorder()
+		o.metering.getMeteringRecords(w, httptest.NewRequest(http.MethodGet, meteringRecordsPath+"?from=invalid", nil))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.metering.ledger, err = metering.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrGet:   errors.New("get error"),
+			ErrQuery: errors.New("query error"),
+		}), o.stats.TenantRollups, nil)
+		require.NoError(t, err)
+
+		w := closePeriod(o, period.Format(time.RFC3339))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "get error")
+
+		w = httptest.NewRecorder()
+		o.metering.getMeteringRecords(w, httptest.NewRequest(http.MethodGet, meteringRecordsPath, nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "query error")
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := closePeriod(o, period.Format(time.RFC3339))
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		w = httptest.NewRecorder()
+		o.metering.getMeteringRecords(w, httptest.NewRequest(http.MethodGet, meteringRecordsPath, nil))
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
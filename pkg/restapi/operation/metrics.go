@@ -0,0 +1,117 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+
+	"github.com/trustbloc/hub-router/pkg/metrics"
+	"github.com/trustbloc/hub-router/pkg/queue"
+)
+
+// API endpoints.
+const (
+	metricsPath = "/metrics"
+)
+
+// Outcomes of the mediation requests.
+const (
+	mediationGranted = "granted"
+	mediationDenied  = "denied"
+)
+
+// routerMetrics are the metrics of the router exposed to Prometheus.
+type routerMetrics struct {
+	registry    *metrics.Registry
+	invitations *metrics.Counter
+	mediations  *metrics.Counter
+	forwards    *metrics.Counter
+	forwardSize *metrics.Histogram
+	actions     *metrics.Histogram
+	mailboxes   *queue.Monitor
+}
+
+// initMetrics initializes the metrics, the queue depths read from the pickup mailboxes on each scrape, through the
+// queue monitor if any.
+func (o *Operation) initMetrics() {
+	r := metrics.NewRegistry()
+
+	o.metrics = &routerMetrics{
+		registry: r,
+		invitations: r.Counter("hub_router_invitations_created_total",
+			"Invitations created, by tenant.", "tenant"),
+		mediations: r.Counter("hub_router_mediation_requests_total",
+			"Mediation requests granted or denied.", "outcome"),
+		forwards: r.Counter("hub_router_forwarded_messages_total",
+			"Forward messages routed to the wallets."),
+		forwardSize: r.Histogram("hub_router_forwarded_message_size_bytes",
+			"Size of the forward messages routed to the wallets, in bytes.", metrics.SizeBuckets),
+		actions: r.Histogram("hub_router_didcomm_action_duration_seconds",
+			"Processing latency of the DIDComm actions, by message type.", metrics.DurationBuckets, "type"),
+	}
+
+	o.metrics.mailboxes = o.queue
+
+	if o.metrics.mailboxes == nil {
+		o.metrics.mailboxes = queue.New(o.pickup, o.queueRecipients, &queue.Config{}, nil)
+	}
+
+	r.GaugeFunc("hub_router_queue_depth", "Messages queued for each wallet, by connection.", "recipient",
+		o.queueDepths)
+}
+
+// queueDepths returns the queue depth of each wallet, none if the depths can't be read.
+func (o *Operation) queueDepths() map[string]float64 {
+	depths, err := o.metrics.mailboxes.Depths()
+	if err != nil {
+		logger.Warnf("metrics : failed to read the queue depths : %s", err)
+
+		return nil
+	}
+
+	values := make(map[string]float64, len(depths))
+
+	for connID, depth := range depths {
+		values[connID] = float64(depth)
+	}
+
+	return values
+}
+
+// countMediation counts the mediation request granted, or denied with the error; the requests held for the approval of
+// the operator are counted once decided.
+func (m *routerMetrics) countMediation(err error) {
+	switch {
+	case err == nil:
+		m.mediations.Inc(mediationGranted)
+	case !errors.Is(err, errMediationPending):
+		m.mediations.Inc(mediationDenied)
+	}
+}
+
+// observeForward counts the forward routed to a wallet, and its size.
+func (m *routerMetrics) observeForward(envelope *transport.Envelope) {
+	m.forwards.Inc()
+	m.forwardSize.Observe(float64(len(envelope.Message)))
+}
+
+// observeAction records the processing latency of the DIDComm action of the message type, started at the given time.
+func (m *routerMetrics) observeAction(msgType string, started time.Time) {
+	m.actions.Observe(time.Since(started).Seconds(), msgType)
+}
+
+func (o *Operation) getMetrics(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("Content-Type", metrics.ContentType)
+
+	if err := o.metrics.registry.WriteText(rw); err != nil {
+		logger.Warnf("failed to write the metrics : %s", err)
+	}
+}
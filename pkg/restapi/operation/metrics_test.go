@@ -0,0 +1,129 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	mediatordsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/internal/mock/didexchange"
+	"github.com/trustbloc/hub-router/pkg/metrics"
+	"github.com/trustbloc/hub-router/pkg/policy"
+	"github.com/trustbloc/hub-router/pkg/presence"
+	"github.com/trustbloc/hub-router/pkg/queue"
+	"github.com/trustbloc/hub-router/pkg/routes"
+)
+
+func TestMetrics(t *testing.T) {
+	scrape := func(t *testing.T, o *Operation) string {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		o.getMetrics(w, httptest.NewRequest(http.MethodGet, metricsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, metrics.ContentType, w.Header().Get("Content-Type"))
+
+		return w.Body.String()
+	}
+
+	t.Run("instrumentation", func(t *testing.T) {
+		ariesStorage := mem.NewProvider()
+
+		mediated, err := routes.Open(ariesStorage)
+		require.NoError(t, err)
+		require.NoError(t, mediated.Route("key-1", "did:wallet"))
+
+		cfg := config()
+		cfg.Aries.(*mockprovider.Provider).StorageProviderValue = ariesStorage
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.didExchange = &didexchange.MockClient{TheirDID: "did:wallet"}
+		o.seen("conn-1", presence.SourceMediation)
+
+		queueMessages(t, o.pickup, "did:wallet", "key-1", 2)
+		queueMessages(t, o.pickup, "did:wallet", "key-2", 1)
+
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, httptest.NewRequest(http.MethodGet, invitationPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		o.EnvelopeHandled(withHeader(&transport.Envelope{
+			Message: []byte(`{"@id":"msg-1","@type":"https://didcomm.org/routing/1.0/forward","to":"key-1","msg":{}}`),
+		}))
+		o.EnvelopeHandled(withHeader(&transport.Envelope{
+			Message: []byte(`{"@type":"https://didcomm.org/trust_ping/1.0/ping"}`),
+		}))
+
+		disabled := false
+
+		_, _, err = o.policies.store.Put(&policy.Document{AutoAccept: &policy.AutoAccept{Mediation: &disabled}}, false)
+		require.NoError(t, err)
+
+		done := make(chan error, 1)
+
+		o.handleAction(service.DIDCommAction{
+			Message:    service.DIDCommMsgMap{"@type": mediatordsvc.RequestMsgType, "@id": "req-1"},
+			Properties: actionProperties{"connectionID": "conn-1"},
+			Continue:   func(interface{}) { done <- nil },
+			Stop:       func(err error) { done <- err },
+		})
+		require.Error(t, <-done)
+
+		body := scrape(t, o)
+		require.Contains(t, body, `hub_router_invitations_created_total{tenant=""} 1`)
+		require.Contains(t, body, `hub_router_mediation_requests_total{outcome="denied"} 1`)
+		require.Contains(t, body, "hub_router_forwarded_messages_total 1\n")
+		require.Contains(t, body, `hub_router_forwarded_message_size_bytes_bucket{le="256"} 1`)
+		require.Contains(t, body, `hub_router_didcomm_action_duration_seconds_count{type="`+
+			mediatordsvc.RequestMsgType+`"} 1`)
+		require.Contains(t, body, `hub_router_queue_depth{recipient="conn-1"} 3`)
+	})
+
+	t.Run("mediation outcomes", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.metrics.countMediation(nil)
+		o.metrics.countMediation(errMediationPending)
+		o.metrics.countMediation(errMediationDenied)
+
+		require.Equal(t, float64(1), o.metrics.mediations.Value(mediationGranted))
+		require.Equal(t, float64(1), o.metrics.mediations.Value(mediationDenied))
+	})
+
+	t.Run("queue depth errors", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.metrics.mailboxes = queue.New(o.pickup, func() (map[string]string, error) {
+			return nil, errors.New("recipients error")
+		}, &queue.Config{}, nil)
+
+		require.Nil(t, o.queueDepths())
+		require.Contains(t, scrape(t, o), "# TYPE hub_router_queue_depth gauge\n")
+	})
+
+	t.Run("queue monitor", func(t *testing.T) {
+		cfg := config()
+		cfg.Queues.Watermarks = &queue.Config{GlobalWatermark: 10}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+		require.Same(t, o.queue, o.metrics.mailboxes)
+	})
+}
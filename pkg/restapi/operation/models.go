@@ -11,16 +11,25 @@ import (
 	"time"
 
 	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
 )
 
 type healthCheckResp struct {
 	Status      string    `json:"status"`
 	CurrentTime time.Time `json:"currentTime"`
+	// Role of the node in an active/standby deployment.
+	Role string `json:"role,omitempty"`
 }
 
 // DIDCommInvitationResp model.
 type DIDCommInvitationResp struct {
-	Invitation *outofband.Invitation `json:"invitation"`
+	Invitation *Invitation `json:"invitation"`
+}
+
+// Invitation model : the out-of-band invitation, with the image of the tenant branding if any.
+type Invitation struct {
+	*outofband.Invitation
+	ImageURL string `json:"imageUrl,omitempty"`
 }
 
 // CreateConnReq model.
@@ -31,9 +40,18 @@ type CreateConnReq struct {
 	Data    *CreateConnReqData `json:"data"`
 }
 
-// CreateConnReqData model for data in CreateConnReq.
+// CreateConnReqData model for data in CreateConnReq. The sender either provides its DID doc, or just its base58
+// encoded keys and service endpoint in which case the router constructs the peer DID doc.
 type CreateConnReqData struct {
-	DIDDoc json.RawMessage `json:"didDoc"`
+	DIDDoc          json.RawMessage `json:"didDoc,omitempty"`
+	SigningKey      string          `json:"signingKey,omitempty"`
+	AgreementKey    string          `json:"agreementKey,omitempty"`
+	ServiceEndpoint string          `json:"serviceEndpoint,omitempty"`
+	// Label of the wallet, optional.
+	Label string `json:"label,omitempty"`
+	// UserAgent and AppVersion of the wallet, optional : captured for the tenants opted in.
+	UserAgent  string `json:"userAgent,omitempty"`
+	AppVersion string `json:"appVersion,omitempty"`
 }
 
 // CreateConnResp model.
@@ -46,8 +64,42 @@ type CreateConnResp struct {
 
 // CreateConnRespData model for error data in CreateConnResp.
 type CreateConnRespData struct {
-	ErrorMsg string          `json:"errorMsg"`
-	DIDDoc   json.RawMessage `json:"didDoc"`
+	ErrorMsg         string          `json:"errorMsg"`
+	ProblemReport    *ProblemReport  `json:"problemReport,omitempty"`
+	DIDDoc           json.RawMessage `json:"didDoc"`
+	ConnectionID     string          `json:"connectionID,omitempty"`
+	ServiceEndpoint  string          `json:"serviceEndpoint,omitempty"`
+	RoutingKeys      []string        `json:"routingKeys,omitempty"`
+	MediationGranted bool            `json:"mediationGranted,omitempty"`
+}
+
+// ProblemReport model, refer https://github.com/hyperledger/aries-rfcs/tree/main/features/0035-report-problem.
+type ProblemReport struct {
+	ID          string              `json:"@id,omitempty"`
+	Type        string              `json:"@type"`
+	Description *ProblemDescription `json:"description"`
+	Explain     string              `json:"explain,omitempty"`
+	Items       []map[string]string `json:"problem_items,omitempty"`
+	L10n        *L10n               `json:"~l10n,omitempty"`
+	Thread      *decorator.Thread   `json:"~thread,omitempty"`
+	// RetryAfter is the number of seconds the sender is advised to wait before sending again.
+	RetryAfter int `json:"retry_after,omitempty"`
+	// Impact, Where, WhoRetries and FixHint are only read from the problem reports received from the peers.
+	Impact     string `json:"impact,omitempty"`
+	Where      string `json:"where,omitempty"`
+	WhoRetries string `json:"who_retries,omitempty"`
+	FixHint    string `json:"fix_hint,omitempty"`
+}
+
+// ProblemDescription model for the description in ProblemReport.
+type ProblemDescription struct {
+	Code string `json:"code"`
+	En   string `json:"en,omitempty"`
+}
+
+// L10n localization decorator, refer https://github.com/hyperledger/aries-rfcs/tree/main/features/0043-l10n.
+type L10n struct {
+	Locale string `json:"locale"`
 }
 
 // DIDCommMsg model.
@@ -0,0 +1,118 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/migration"
+	"github.com/trustbloc/hub-router/pkg/recovery"
+	"github.com/trustbloc/hub-router/pkg/terms"
+)
+
+// MsgHandler handles an inbound DIDComm message and returns the reply. No reply is sent if the reply is nil.
+// If an error is returned, a problem report is sent back instead; a *ProblemError sets the problem code.
+type MsgHandler func(msg service.DIDCommMsg) (service.DIDCommMsgMap, error)
+
+// MsgService is a DIDComm message service handled by the router's message listener.
+type MsgService struct {
+	Name    string
+	MsgType string
+	Handler MsgHandler
+}
+
+// RegisterMsgService registers an additional DIDComm message service. Its messages go through the same pipeline
+// as the router's own messages (audit, correlation, dead-lettering and replay).
+func (o *Operation) RegisterMsgService(svc *MsgService) error {
+	if svc == nil || svc.Name == "" || svc.MsgType == "" || svc.Handler == nil {
+		return errors.New("message service name, type and handler are mandatory")
+	}
+
+	o.msgSvcsMutex.Lock()
+	defer o.msgSvcsMutex.Unlock()
+
+	if _, ok := o.msgSvcs[svc.MsgType]; ok {
+		return fmt.Errorf("message service for %s already registered", svc.MsgType)
+	}
+
+	err := o.msgRegistrar.Register(aries.NewMsgSvc(svc.Name, svc.MsgType, o.msgCh))
+	if err != nil {
+		return fmt.Errorf("register message service : %w", err)
+	}
+
+	o.msgSvcs[svc.MsgType] = svc
+
+	return nil
+}
+
+// registerMsgServices registers the router's message services followed by the configured ones.
+func (o *Operation) registerMsgServices(svcs []*MsgService) error {
+	routerSvcs := []*MsgService{
+		{Name: "create-connection", MsgType: createConnReq, Handler: o.handleCreateConnReq},
+		{Name: "problem-report", MsgType: problemReportMsgType, Handler: o.handleProblemReport},
+	}
+
+	if o.terms.terms != nil {
+		routerSvcs = append(routerSvcs, &MsgService{
+			Name: "mediator-terms", MsgType: terms.AcceptMsgType, Handler: o.terms.handleAccept,
+		})
+	}
+
+	if o.handover.store != nil {
+		routerSvcs = append(routerSvcs, &MsgService{
+			Name: "mediator-handover", MsgType: migration.HandoverMsgType, Handler: o.handover.handleHandover,
+		})
+	}
+
+	if o.recovery.rebinder != nil {
+		routerSvcs = append(routerSvcs, &MsgService{
+			Name: "mediator-recovery", MsgType: recovery.TransferMsgType, Handler: o.recovery.handleTransfer,
+		})
+	}
+
+	svcs = append(routerSvcs, svcs...)
+
+	for _, svc := range svcs {
+		if err := o.RegisterMsgService(svc); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *Operation) msgService(msgType string) (*MsgService, bool) {
+	o.msgSvcsMutex.RLock()
+	defer o.msgSvcsMutex.RUnlock()
+
+	svc, ok := o.msgSvcs[msgType]
+
+	return svc, ok
+}
+
+// errorReply returns the reply for the failed message. Custom message service failures are reported with a problem
+// report; for backward compatibility, the other failures are reported in a create-conn-resp.
+func (o *Operation) errorReply(msg service.DIDCommMsg, err error) service.DIDCommMsgMap {
+	report := o.newProblemReport(msg, err)
+
+	if svc, ok := o.msgService(msg.Type()); ok && svc.MsgType != createConnReq {
+		report.ID = uuid.New().String()
+
+		return service.NewDIDCommMsgMap(report)
+	}
+
+	return service.NewDIDCommMsgMap(&CreateConnResp{
+		ID:   uuid.New().String(),
+		Type: createConnResp,
+		Data: &CreateConnRespData{ErrorMsg: err.Error(), ProblemReport: report},
+	})
+}
@@ -0,0 +1,134 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/internal/mock/messenger"
+)
+
+const (
+	pingMsgType  = "https://example.com/ping/1.0/ping"
+	pongMsgType  = "https://example.com/ping/1.0/pong"
+	eventMsgType = "https://example.com/event/1.0/event"
+)
+
+func TestRegisterMsgService(t *testing.T) {
+	t.Run("custom message service", func(t *testing.T) {
+		cfg := config()
+		cfg.MsgServices = []*MsgService{{
+			Name:    "ping",
+			MsgType: pingMsgType,
+			Handler: func(msg service.DIDCommMsg) (service.DIDCommMsgMap, error) {
+				return service.NewDIDCommMsgMap(&DIDCommMsg{ID: uuid.New().String(), Type: pongMsgType}), nil
+			},
+		}}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		replies := make(chan service.DIDCommMsgMap, 1)
+
+		o.messenger = &messenger.MockMessenger{
+			ReplyToFunc: func(msgID string, msg service.DIDCommMsgMap) error {
+				replies <- msg
+
+				return nil
+			},
+		}
+
+		msgCh := make(chan service.DIDCommMsg, 1)
+		go o.didCommMsgListener(msgCh)
+
+		msgCh <- service.NewDIDCommMsgMap(&DIDCommMsg{ID: uuid.New().String(), Type: pingMsgType})
+
+		select {
+		case reply := <-replies:
+			require.Equal(t, pongMsgType, reply.Type())
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+	})
+
+	t.Run("handler error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		require.NoError(t, o.RegisterMsgService(&MsgService{
+			Name:    "ping",
+			MsgType: pingMsgType,
+			Handler: func(msg service.DIDCommMsg) (service.DIDCommMsgMap, error) {
+				return nil, &ProblemError{Code: "ping-failed", Err: errors.New("ping error")}
+			},
+		}))
+
+		entry := &deadletter.Entry{}
+
+		reply, err := o.processMsg(service.NewDIDCommMsgMap(&DIDCommMsg{ID: uuid.New().String(), Type: pingMsgType}), entry)
+		require.EqualError(t, err, "ping error")
+		require.Equal(t, problemReportMsgType, reply.Type())
+		require.NotEmpty(t, reply.ID())
+
+		report := &ProblemReport{}
+		require.NoError(t, reply.Decode(report))
+		require.Equal(t, "ping-failed", report.Description.Code)
+		require.Equal(t, "ping", entry.Decisions[0].Outcome)
+	})
+
+	t.Run("no reply", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		require.NoError(t, o.RegisterMsgService(&MsgService{
+			Name:    "event",
+			MsgType: eventMsgType,
+			Handler: func(msg service.DIDCommMsg) (service.DIDCommMsgMap, error) {
+				return nil, nil
+			},
+		}))
+
+		reply, err := o.processMsg(service.NewDIDCommMsgMap(&DIDCommMsg{Type: eventMsgType}), &deadletter.Entry{})
+		require.NoError(t, err)
+		require.Nil(t, reply)
+	})
+
+	t.Run("registration errors", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		err = o.RegisterMsgService(&MsgService{Name: "ping"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "mandatory")
+
+		err = o.RegisterMsgService(&MsgService{Name: "ping", MsgType: createConnReq, Handler: o.handleCreateConnReq})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "already registered")
+
+		err = o.RegisterMsgService(&MsgService{
+			Name: "create-connection", MsgType: pingMsgType, Handler: o.handleCreateConnReq,
+		})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "register message service")
+	})
+
+	t.Run("config error", func(t *testing.T) {
+		cfg := config()
+		cfg.MsgServices = []*MsgService{{Name: "invalid"}}
+
+		_, err := New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "message service client")
+	})
+}
@@ -7,14 +7,17 @@ SPDX-License-Identifier: Apache-2.0
 package operation
 
 import (
+	"crypto/ed25519"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
+	"github.com/btcsuite/btcutil/base58"
 	"github.com/google/uuid"
 	"github.com/hyperledger/aries-framework-go/pkg/client/didexchange"
-	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
 	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
 	"github.com/hyperledger/aries-framework-go/pkg/didcomm/messaging/msghandler"
 	didexdsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/didexchange"
@@ -22,13 +25,47 @@ import (
 	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
 	vdrapi "github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
 	"github.com/hyperledger/aries-framework-go/pkg/kms"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	didstore "github.com/hyperledger/aries-framework-go/pkg/store/did"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/fingerprint"
 	"github.com/hyperledger/aries-framework-go/pkg/vdr/peer"
 	"github.com/hyperledger/aries-framework-go/spi/storage"
 	"github.com/trustbloc/edge-core/pkg/log"
 
 	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/attachment"
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/ha"
+	"github.com/trustbloc/hub-router/pkg/history"
+	"github.com/trustbloc/hub-router/pkg/idempotency"
+	"github.com/trustbloc/hub-router/pkg/inbound"
 	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/keypin"
+	"github.com/trustbloc/hub-router/pkg/keyusage"
+	"github.com/trustbloc/hub-router/pkg/l10n"
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/mediation"
+	"github.com/trustbloc/hub-router/pkg/pickup"
+	"github.com/trustbloc/hub-router/pkg/presence"
+	"github.com/trustbloc/hub-router/pkg/problemreport"
+	"github.com/trustbloc/hub-router/pkg/queue"
+	"github.com/trustbloc/hub-router/pkg/relay"
 	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/retryadvice"
+	"github.com/trustbloc/hub-router/pkg/routes"
+	"github.com/trustbloc/hub-router/pkg/slowconsumer"
+	"github.com/trustbloc/hub-router/pkg/socket"
+	"github.com/trustbloc/hub-router/pkg/stats"
+	"github.com/trustbloc/hub-router/pkg/supervisor"
+	"github.com/trustbloc/hub-router/pkg/suppression"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+	"github.com/trustbloc/hub-router/pkg/terms"
+	"github.com/trustbloc/hub-router/pkg/upstream"
+	"github.com/trustbloc/hub-router/pkg/useragent"
+	"github.com/trustbloc/hub-router/pkg/webhook"
 )
 
 // API endpoints.
@@ -46,6 +83,14 @@ const (
 	didExStateComp    = msgTypeBaseURI + "/didexchange/1.0/state-complete"
 )
 
+// DID doc constants.
+const (
+	ed25519VerificationKey2018 = "Ed25519VerificationKey2018"
+	x25519KeyAgreementKey2019  = "X25519KeyAgreementKey2019"
+	didCommServiceType         = "did-communication"
+	curve25519KeySize          = 32
+)
+
 var logger = log.New("hub-router/operations")
 
 // Handler http handler for each controller API endpoint.
@@ -55,23 +100,58 @@ type Handler interface {
 	Handle() http.HandlerFunc
 }
 
+// feature of the router holding its own state, and serving its own REST API.
+type feature interface {
+	handlers() []Handler
+}
+
 // Storage config.
 type Storage struct {
 	Persistent storage.Provider
 	Transient  storage.Provider
+	// Isolation is set if the data of the tenants is isolated in their storage namespace, it is also the Persistent
+	// provider.
+	Isolation *tenant.IsolatedProvider
+	// Locks serializes the writes of the records shared by the nodes of the router, eg: the HA lease. The locks are
+	// local to the process if nil.
+	Locks lock.Locker
 }
 
-// Config holds configuration.
+// Config holds configuration : the Aries agent and the router storage, and the settings of each feature, grouped.
 type Config struct {
 	Aries          aries.Ctx
 	AriesMessenger service.Messenger
 	MsgRegistrar   *msghandler.Registrar
 	Storage        *Storage
+	Webhook        *webhook.Notifier
+	Events         *events.Bus
+	MsgServices    []*MsgService
+	Transports     TransportConfig
+	Admission      AdmissionConfig
+	Queues         QueueConfig
+	Mediation      MediationConfig
+	Invitations    InvitationConfig
+	Tenancy        TenancyConfig
+	Reporting      ReportingConfig
+	DeadLetters    DeadLetterConfig
+	// IdempotencyKeyTTL is the time the results of the requests with an Idempotency-Key header are recorded for, in
+	// the transient storage, idempotency.DefaultTTL if zero.
+	IdempotencyKeyTTL time.Duration
+	// Attachments stores the blobs uploaded by the adapters, fetched by the wallets from an expiring URL.
+	Attachments *attachment.Config
+	// HA makes the router a node of an active/standby deployment : the DIDComm messages are only processed by the
+	// active node, holding the fencing token.
+	HA *ha.Config
+	// Supervisor tracks the permanent failures of the listeners, reported by the readiness.
+	Supervisor *supervisor.Supervisor
+	// ConfigInfo is the effective configuration the router was started with, returned to the operator.
+	ConfigInfo *ConfigInfo
 }
 
 // Operation implements hub-router operations.
 type Operation struct {
 	storage      *Storage
+	locker       lock.Locker
 	oob          aries.OutOfBand
 	didExchange  aries.DIDExchange
 	mediator     aries.Mediator
@@ -79,6 +159,67 @@ type Operation struct {
 	vdriRegistry vdrapi.Registry
 	keyManager   kms.KeyManager
 	endpoint     string
+	endpoints    []string
+	auditLog     *audit.Log
+	history      *history.Log
+	export       *exportHandler
+	catalog      *l10n.Catalog
+	correlations *correlation.Store
+	deadLetters  *deadLetterHandler
+	webhook      *webhook.Notifier
+	presence     *presence.Tracker
+	events       *events.Bus
+	msgRegistrar *msghandler.Registrar
+	msgCh        chan service.DIDCommMsg
+	msgSvcs      map[string]*MsgService
+	msgSvcsMutex sync.RWMutex
+	routeSvc     aries.RouteService
+	stats        *stats.Store
+	queue        *queue.Monitor
+
+	slowConsumers    *slowconsumer.Detector
+	recipientConns   sync.Map
+	sockets          *socketRegistry
+	keyPins          *keypin.Store
+	keyUsage         *keyUsageHandler
+	mediationClients *mediation.Registry
+	relay            *relay.Relay
+	tenants          *tenant.Store
+	tenancy          *tenancyHandler
+	routes           *routes.Store
+	metering         *meteringHandler
+	policies         *policyHandler
+
+	mailbox             *mailboxHandler
+	pickup              *pickup.Queue
+	attachments         *attachmentHandler
+	backpressure        *backpressureHandler
+	didConnections      didstore.ConnectionStore
+	limits              *limitsHandler
+	invitationTokens    *invitationTokenHandler
+	brandings           map[string]*tenant.Branding
+	label               string
+	mediationApprovals  mediationApprovals
+	userAgents          *useragent.Store
+	terms               *termsHandler
+	createConnReqSchema *msgSchema
+	requestSchemas      map[string]*msgSchema
+	idempotency         *idempotency.Store
+	connections         *connection.Lookup
+	problemReports      *problemreport.Store
+	retries             *retryadvice.Tracker
+	residency           *residencyHandler
+	failover            *failoverHandler
+	suppression         *suppression.Window
+	handover            *handoverHandler
+	recovery            *recoveryHandler
+	reporting           *reportingHandler
+	supervisor          *supervisor.Supervisor
+	info                *infoHandler
+	diagnostics         *diagnosticsHandler
+	upstream            *upstream.Mediator
+	metrics             *routerMetrics
+	compliance          *complianceHandler
 }
 
 // New returns a new Operation.
@@ -108,36 +249,494 @@ func New(config *Config) (*Operation, error) {
 		mediator:     mediatorClient,
 		messenger:    config.AriesMessenger,
 		vdriRegistry: config.Aries.VDRegistry(),
-		endpoint:     config.Aries.RouterEndpoint(),
+		endpoint:     routerEndpoint(config),
+		endpoints:    config.Mediation.Endpoints,
 		keyManager:   config.Aries.KMS(),
+		webhook:      config.Webhook,
+		events:       config.Events,
+		msgRegistrar: config.MsgRegistrar,
+		msgCh:        make(chan service.DIDCommMsg, 1),
+		msgSvcs:      make(map[string]*MsgService),
+
+		pickup:     config.Queues.Pickup,
+		supervisor: config.Supervisor,
+		info:       &infoHandler{config: config.ConfigInfo},
 	}
 
-	msgCh := make(chan service.DIDCommMsg, 1)
+	if o.events == nil {
+		o.events = events.NewBus()
+	}
 
-	msgSvc := aries.NewMsgSvc("create-connection", createConnReq, msgCh)
+	o.locker = config.Storage.Locks
+	if o.locker == nil {
+		o.locker = lock.NewLocal()
+	}
 
-	err = config.MsgRegistrar.Register(msgSvc)
+	if o.supervisor == nil {
+		o.supervisor = supervisor.New(0)
+	}
+
+	err = o.initComponents(config)
 	if err != nil {
-		return nil, fmt.Errorf("message service client: %w", err)
+		return nil, err
 	}
 
-	go o.didCommActionListener(actionCh)
+	err = o.registerMsgServices(config.MsgServices)
+	if err != nil {
+		return nil, fmt.Errorf("message service client: %w", err)
+	}
 
-	go o.didCommMsgListener(msgCh)
+	o.startListeners(actionCh, stateMsgCh)
 
-	go o.stateMsgHandler(stateMsgCh)
+	o.startMonitoring(config)
 
 	return o, nil
 }
 
-// GetRESTHandlers get all controller API handler available for this service.
+// routerEndpoint returns the endpoint advertised by the router : the advertised endpoint if configured, else the first
+// endpoint advertised, else the endpoint of its inbound transports.
+func routerEndpoint(config *Config) string {
+	if config.Mediation.AdvertisedEndpoint != "" {
+		return config.Mediation.AdvertisedEndpoint
+	}
+
+	if len(config.Mediation.Endpoints) > 0 {
+		return config.Mediation.Endpoints[0]
+	}
+
+	return config.Aries.RouterEndpoint()
+}
+
+// startMonitoring starts the periodic checks, and hooks the operation to the Aries transports.
+func (o *Operation) startMonitoring(config *Config) {
+	o.presence.Start(presenceSweepInterval)
+
+	o.stats.Start(statsPruneInterval)
+
+	o.deadLetters.start()
+
+	if o.queue != nil {
+		o.queue.Start(queueCheckInterval)
+	}
+
+	o.mailbox.start()
+
+	o.startFailover()
+
+	o.startSweeps()
+
+	if o.sockets != nil {
+		o.sockets.start(socketSweepInterval)
+	}
+
+	if o.sockets != nil || o.slowConsumers != nil && config.Transports.WSOutbound != nil {
+		go o.indexRecipients()
+	}
+
+	if o.slowConsumers != nil && config.Transports.WSOutbound != nil {
+		config.Transports.WSOutbound.SetObserver(o)
+	}
+
+	o.reporting.start()
+
+	if o.upstream != nil {
+		o.upstream.Start(upstreamRetryInterval)
+	}
+
+	o.hookInboundTransports(config)
+}
+
+// startSweeps starts the periodic sweeps of the expired records.
+func (o *Operation) startSweeps() {
+	if o.suppression != nil {
+		o.suppression.Start(suppressionSweepInterval)
+	}
+
+	o.idempotency.Start(idempotencySweepInterval)
+	o.export.jobs.Start(exportJobSweepInterval)
+
+	o.invitationTokens.start()
+
+	if o.handover.store != nil {
+		o.handover.store.Start(handoverSweepInterval)
+	}
+
+	o.metering.start()
+
+	o.attachments.start()
+}
+
+// hookInboundTransports binds the stages of the Aries inbound transports handled by the operation : the envelopes
+// are admitted, verified, the sockets observed, the nested forwards relayed, and the envelopes handed on unless
+// redirected, duplicate or addressed to a full queue.
+func (o *Operation) hookInboundTransports(config *Config) {
+	for _, m := range config.Transports.Inbound {
+		m.Handle(inbound.Admit, inbound.Check(o.AdmitEnvelope))
+
+		if o.keyPins != nil {
+			m.Handle(inbound.Verify, inbound.Check(o.VerifyEnvelope))
+		}
+
+		if o.sockets != nil {
+			m.Handle(inbound.ObserveSocket, socket.NewHandler(o))
+		}
+
+		if o.relay != nil {
+			m.Handle(inbound.Relay, o.relay.HandleEnvelope)
+		}
+
+		m.Handle(inbound.Gate, o.gateEnvelope)
+	}
+}
+
+func (o *Operation) initComponents(config *Config) error {
+	s := config.Storage
+
+	var err error
+
+	o.auditLog, err = audit.New(s.Persistent)
+	if err != nil {
+		return fmt.Errorf("audit log: %w", err)
+	}
+
+	o.history, err = history.New(s.Persistent, config.Reporting.HistoryLimit)
+	if err != nil {
+		return fmt.Errorf("history log: %w", err)
+	}
+
+	if err = o.initExport(config); err != nil {
+		return err
+	}
+
+	o.correlations, err = correlation.New(s.Persistent)
+	if err != nil {
+		return fmt.Errorf("correlation store: %w", err)
+	}
+
+	if err = o.initDeadLetters(config); err != nil {
+		return err
+	}
+
+	o.presence, err = presence.New(s.Persistent, config.Mediation.PresenceTimeout, o.presenceChanged)
+	if err != nil {
+		return fmt.Errorf("presence tracker: %w", err)
+	}
+
+	o.stats, err = stats.New(s.Persistent, config.Reporting.StatsRetention)
+	if err != nil {
+		return fmt.Errorf("stats store: %w", err)
+	}
+
+	err = o.initRegistries(s)
+	if err != nil {
+		return err
+	}
+
+	o.initMailbox(config)
+
+	o.initDiagnostics(config)
+
+	err = o.initTenants(config)
+	if err != nil {
+		return err
+	}
+
+	o.events.Register(o.countEvent, events.TopicConnection, events.TopicForward)
+
+	err = o.initMessaging(config)
+	if err != nil {
+		return err
+	}
+
+	return o.initOptionalComponents(config)
+}
+
+// initRegistries initializes the registries of the router keys and of the mediation clients.
+func (o *Operation) initRegistries(s *Storage) error {
+	var err error
+
+	o.keyUsage = &keyUsageHandler{securityEvent: o.securityEvent}
+
+	o.keyUsage.registry, err = keyusage.New(s.Persistent)
+	if err != nil {
+		return fmt.Errorf("key usage registry: %w", err)
+	}
+
+	o.mediationClients, err = mediation.New(s.Persistent)
+	if err != nil {
+		return fmt.Errorf("mediation client registry: %w", err)
+	}
+
+	if o.pickup == nil {
+		o.pickup, err = pickup.NewQueue(s.Persistent, o.locker, pickup.WithDeadLetters(o.deadLetters.store))
+		if err != nil {
+			return fmt.Errorf("pickup queue: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// initMessaging initializes the components handling the inbound messages, the problem reports and the reporting.
+func (o *Operation) initMessaging(config *Config) error {
+	var err error
+
+	o.catalog, err = l10n.NewCatalog()
+	if err != nil {
+		return fmt.Errorf("l10n catalog: %w", err)
+	}
+
+	o.createConnReqSchema, err = newMsgSchema("create-conn-req.json", maxCreateConnReqSize)
+	if err != nil {
+		return fmt.Errorf("create-conn-req schema: %w", err)
+	}
+
+	if err = o.initRequestSchemas(); err != nil {
+		return fmt.Errorf("request schemas: %w", err)
+	}
+
+	o.idempotency, err = idempotency.New(config.Storage.Transient, config.IdempotencyKeyTTL)
+	if err != nil {
+		return fmt.Errorf("idempotency store: %w", err)
+	}
+
+	o.connections, err = connection.NewLookup(config.Aries)
+	if err != nil {
+		return fmt.Errorf("connection lookup: %w", err)
+	}
+
+	o.initResidency(config)
+
+	o.problemReports, err = problemreport.New(config.Storage.Persistent)
+	if err != nil {
+		return fmt.Errorf("problem report store: %w", err)
+	}
+
+	o.retries = retryadvice.New(config.DeadLetters.TransientRetryAfter)
+
+	err = o.initReporting(config)
+	if err != nil {
+		return err
+	}
+
+	err = o.initFailover(config)
+	if err != nil {
+		return err
+	}
+
+	return o.initUpstream(config)
+}
+
+// initInboundHooks initializes the components hooked to the inbound transports.
+func (o *Operation) initInboundHooks(config *Config) error {
+	err := o.initCompliance(config)
+	if err != nil {
+		return err
+	}
+
+	if config.Admission.KeyPinning {
+		o.keyPins, err = keypin.New(config.Storage.Persistent)
+		if err != nil {
+			return fmt.Errorf("key pin store: %w", err)
+		}
+	}
+
+	if config.Admission.MultiHopForward {
+		o.relay, err = relay.New(config.Aries.StorageProvider(), o.vdriRegistry, config.Aries.OutboundDispatcher())
+		if err != nil {
+			return fmt.Errorf("forward relay: %w", err)
+		}
+	}
+
+	if config.Admission.SuppressionWindow > 0 {
+		o.suppression, err = suppression.New(config.Storage.Transient, config.Admission.SuppressionWindow)
+		if err != nil {
+			return fmt.Errorf("suppression window: %w", err)
+		}
+	}
+
+	if err = o.initGrantTransfer(config); err != nil {
+		return err
+	}
+
+	return o.initHandovers(config)
+}
+
+// initInvitations initializes the invitation tokens and the terms attached to the invitations, and the capture of the
+// user agents of the wallets registering.
+func (o *Operation) initInvitations(config *Config) error {
+	err := o.initInvitationTokens(config)
+	if err != nil {
+		return err
+	}
+
+	err = o.initUserAgents(config)
+	if err != nil {
+		return err
+	}
+
+	o.brandings = config.Invitations.Branding
+
+	o.label = config.Invitations.Label
+	if o.label == "" {
+		o.label = defaultLabel
+	}
+
+	return o.initTerms(config)
+}
+
+// initOptionalComponents initializes the components enabled by the configuration.
+func (o *Operation) initOptionalComponents(config *Config) error {
+	var err error
+
+	if config.Mediation.AutoGrant {
+		o.routeSvc, err = aries.GetRouteService(config.Aries)
+		if err != nil {
+			return fmt.Errorf("route service: %w", err)
+		}
+	}
+
+	o.initLimits(config)
+
+	if err = o.initPolicies(config); err != nil {
+		return err
+	}
+
+	o.keyUsage.policy = config.Mediation.KeyReusePolicy
+	if o.keyUsage.policy == "" {
+		o.keyUsage.policy = keyusage.PolicyAudit
+	}
+
+	err = o.initInboundHooks(config)
+	if err != nil {
+		return err
+	}
+
+	err = o.initMetering(config)
+	if err != nil {
+		return err
+	}
+
+	if config.Queues.SlowConsumers.Enabled() {
+		o.slowConsumers, err = slowconsumer.New(config.Storage.Persistent, config.Queues.SlowConsumers, o.slowConsumerChanged)
+		if err != nil {
+			return fmt.Errorf("slow consumer detector: %w", err)
+		}
+	}
+
+	err = o.initAttachments(config)
+	if err != nil {
+		return err
+	}
+
+	err = o.initBackpressure(config)
+	if err != nil {
+		return err
+	}
+
+	o.initSockets(config)
+
+	err = o.initInvitations(config)
+	if err != nil {
+		return err
+	}
+
+	o.initQueueMonitor(config)
+
+	return nil
+}
+
+// initQueueMonitor initializes the queue monitor, which also measures the pickup latency of the slow consumers, then
+// the metrics reading the queue depths.
+func (o *Operation) initQueueMonitor(config *Config) {
+	watermarks := config.Queues.Watermarks
+	latency := config.Queues.SlowConsumers.Enabled() && config.Queues.SlowConsumers.PickupThreshold > 0
+
+	if !watermarks.Enabled() && !latency {
+		o.initMetrics()
+
+		return
+	}
+
+	if watermarks == nil {
+		watermarks = &queue.Config{}
+	}
+
+	o.queue = queue.New(o.pickup, o.queueRecipients, watermarks, o.queueAlert)
+
+	if latency {
+		o.queue.OnDrain(o.pickupDrained)
+	}
+
+	o.initMetrics()
+}
+
+// Events returns the event bus, to subscribe to the hub-router events in-process.
+func (o *Operation) Events() *events.Bus {
+	return o.events
+}
+
+// GetRESTHandlers get all controller API handler available for this service : the handlers of the router, then the
+// handlers of each feature.
 func (o *Operation) GetRESTHandlers() []Handler {
-	return []Handler{
+	handlers := []Handler{
 		// healthcheck
 		support.NewHTTPHandler(healthCheckPath, http.MethodGet, o.healthCheckHandler),
+		support.NewHTTPHandler(readinessPath, http.MethodGet, o.getReadiness),
 
 		// router
 		support.NewHTTPHandler(invitationPath, http.MethodGet, o.generateInvitation),
+
+		// wallets
+		support.NewHTTPHandler(walletsPath, http.MethodGet, o.getWallets),
+		support.NewHTTPHandler(walletPath, http.MethodGet, o.getWallet),
+
+		// connections
+		support.NewHTTPHandler(connectionsPath, http.MethodGet, o.getConnections),
+		support.NewHTTPHandler(connectionsPath, http.MethodPost, o.postConnection),
+		support.NewHTTPHandler(connectionResolvePath, http.MethodPost, o.postResolveConnection),
+
+		// consents
+
+		// history
+		support.NewHTTPHandler(connectionHistoryPath, http.MethodGet, o.getHistory),
+
+		// connection webhooks
+		support.NewHTTPHandler(connectionWebhookPath, http.MethodPut, o.putConnectionWebhook),
+		support.NewHTTPHandler(connectionWebhookPath, http.MethodGet, o.getConnectionWebhook),
+		support.NewHTTPHandler(connectionWebhookPath, http.MethodDelete, o.deleteConnectionWebhook),
+
+		// export
+		support.NewHTTPHandler(statsHistoryPath, http.MethodGet, o.getStatsHistory),
+		support.NewHTTPHandler(metricsPath, http.MethodGet, o.getMetrics),
+
+		// policies
+		support.NewHTTPHandler(mediationRequestsPath, http.MethodGet, o.getMediationRequests),
+		support.NewHTTPHandler(mediationRequestApprovePath, http.MethodPost, o.approveMediationRequest),
+		support.NewHTTPHandler(mediationRequestDenyPath, http.MethodPost, o.denyMediationRequest),
+
+		// tenants
+
+		// events
+		support.NewHTTPHandler(eventSchemasPath, http.MethodGet, o.getEventSchemas),
+		support.NewHTTPHandler(eventSchemaPath, http.MethodGet, o.getEventSchema),
+
+		// debug
+		support.NewHTTPHandler(correlationsPath, http.MethodGet, o.getCorrelations),
+	}
+
+	for _, f := range o.features() {
+		handlers = append(handlers, f.handlers()...)
+	}
+
+	return handlers
+}
+
+// features returns the features serving their own REST API.
+func (o *Operation) features() []feature {
+	return []feature{
+		o.failover, o.mailbox, o.residency, o.metering, o.compliance, o.handover, o.recovery, o.export,
+		o.attachments, o.reporting, o.diagnostics, o.info, o.terms, o.keyUsage, o.deadLetters, o.tenancy, o.policies,
 	}
 }
 
@@ -145,74 +744,217 @@ func (o *Operation) healthCheckHandler(rw http.ResponseWriter, _ *http.Request)
 	resp := &healthCheckResp{
 		Status:      "success",
 		CurrentTime: time.Now(),
+		Role:        o.failover.role(),
 	}
 
 	httputil.WriteResponseWithLog(rw, resp, healthCheckPath, logger)
 }
 
-func (o *Operation) generateInvitation(rw http.ResponseWriter, _ *http.Request) {
-	// TODO configure hub-router label
-	invitation, err := o.oob.CreateInvitation(nil, outofband.WithLabel("hub-router"))
+func (o *Operation) generateInvitation(rw http.ResponseWriter, req *http.Request) {
+	corrID := correlationID(rw, req)
+
+	mediaType, ok := httputil.Negotiate(req, invitationFormats...)
+	if !ok {
+		httputil.WriteNotAcceptable(rw, invitationPath, logger, invitationFormats...)
+
+		return
+	}
+
+	tenantID := tenant.FromContext(req.Context())
+
+	if status, err := o.checkInvitationAllowed(tenantID); err != nil {
+		httputil.WriteErrorResponseWithLog(rw, status, err.Error(), invitationPath, logger)
+
+		return
+	}
+
+	params, err := parseInvitationParams(req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), invitationPath, logger)
+
+		return
+	}
+
+	claims, err := o.invitationTokens.verify(req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusUnauthorized, err.Error(), invitationPath, logger)
+
+		return
+	}
+
+	invitation, err := o.createInvitation(tenantID, params)
 	if err != nil {
+		o.invitationTokens.release(claims)
 		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
 			fmt.Sprintf("failed to create router invitation - err=%s", err.Error()), invitationPath, logger)
 
 		return
 	}
 
-	httputil.WriteResponseWithLog(rw, &DIDCommInvitationResp{
-		Invitation: invitation,
-	}, invitationPath, logger)
+	o.metrics.invitations.Inc(tenantID)
+	o.assignTenant(tenantID, invitation.ID)
+	o.invitationTokens.bind(tokenSubject(claims), invitation.ID)
+	o.terms.present(invitation.ID, terms.ViaInvitation)
+
+	o.recordAudit(&audit.Entry{
+		Type: audit.InvitationCreated, ThreadID: invitation.ID, Detail: invitation.ID, Tenant: tenantID,
+	})
+	o.correlate(&correlation.Record{ThreadID: invitation.ID, CorrelationID: corrID, MsgType: invitation.Type})
+
+	writeInvitation(rw, mediaType, &DIDCommInvitationResp{
+		Invitation: &Invitation{Invitation: invitation, ImageURL: o.branding(tenantID).ImageURL},
+	})
+}
+
+// checkInvitationAllowed returns the error status if the router sheds the load, the tenant is suspended or exceeded its
+// invitation rate limit.
+func (o *Operation) checkInvitationAllowed(tenantID string) (int, error) {
+	if err := o.shedLoad(); err != nil {
+		return http.StatusServiceUnavailable, err
+	}
+
+	if err := o.tenancy.checkActive(tenantID); err != nil {
+		return http.StatusForbidden, err
+	}
+
+	if err := o.policies.checkInvitationPolicy(tenantID); err != nil {
+		return http.StatusTooManyRequests, err
+	}
+
+	return 0, nil
 }
 
 func (o *Operation) didCommActionListener(ch <-chan service.DIDCommAction) {
 	for msg := range ch {
-		var err error
-
-		var args interface{}
+		// the queued handshakes wait for a slot without holding up the other actions
+		if o.limits.handshakes.Queueing() && msg.Message.Type() == didexdsvc.RequestMsgType {
+			go o.handleAction(msg)
 
-		switch msg.Message.Type() {
-		case didexdsvc.RequestMsgType:
-			args = nil
-		case mediatordsvc.RequestMsgType:
-			args = nil
-		default:
-			err = fmt.Errorf("unsupported message type : %s", msg.Message.Type())
+			continue
 		}
 
-		if err != nil {
-			logger.Errorf("msgType=[%s] id=[%s] errMsg=[%s]", msg.Message.Type(), msg.Message.ID(), err.Error())
+		o.handleAction(msg)
+	}
+}
+
+func (o *Operation) handleAction(msg service.DIDCommAction) {
+	defer o.metrics.observeAction(msg.Message.Type(), time.Now())
+
+	var err error
+
+	var args interface{}
+
+	corr := msgCorrelation(msg.Message)
+	corr.ConnectionID = actionConnectionID(msg)
+
+	entry := &audit.Entry{
+		MsgType: msg.Message.Type(), ThreadID: corr.ThreadID, ConnectionID: corr.ConnectionID,
+		Detail: msg.Message.ID(), Actor: history.ActorWallet,
+	}
+
+	switch msg.Message.Type() {
+	case didexdsvc.RequestMsgType:
+		args = nil
+		entry.Type = audit.DIDExchangeAction
+
+		err = o.admitDIDExchangeRequest(msg.Message, corr.ConnectionID)
+	case mediatordsvc.RequestMsgType:
+		entry.Type = audit.MediationAction
+
+		args, err = o.admitMediationRequest(corr.ConnectionID)
 
-			msg.Stop(fmt.Errorf("handle %s : %w", msg.Message.Type(), err))
-		} else {
-			logger.Infof("msgType=[%s] id=[%s] msg=[%s]", msg.Message.Type(), msg.Message.ID(), "success")
+		o.seen(corr.ConnectionID, presence.SourceMediation)
+		o.events.Publish(&events.MediationEvent{
+			Time: time.Now().UTC(), ConnectionID: corr.ConnectionID, ThreadID: corr.ThreadID, MsgType: msg.Message.Type(),
+		})
 
-			msg.Continue(args)
+		if errors.Is(err, errMediationPending) {
+			o.holdMediation(msg, corr, entry)
+
+			return
 		}
+	default:
+		err = fmt.Errorf("unsupported message type : %s", msg.Message.Type())
 	}
+
+	o.completeAction(msg, corr, entry, args, err)
 }
 
-func (o *Operation) didCommMsgListener(ch <-chan service.DIDCommMsg) {
-	for msg := range ch {
-		var err error
+// completeAction continues the action with the args, or stops it with the error, and records it.
+func (o *Operation) completeAction(msg service.DIDCommAction, corr *correlation.Record, entry *audit.Entry,
+	args interface{}, err error) {
+	if err != nil {
+		logger.Errorf("msgType=[%s] id=[%s] errMsg=[%s]", msg.Message.Type(), msg.Message.ID(), err.Error())
 
-		var msgMap service.DIDCommMsgMap
+		msg.Stop(fmt.Errorf("handle %s : %w", msg.Message.Type(), err))
 
-		switch msg.Type() {
-		case createConnReq:
-			msgMap, err = o.handleCreateConnReq(msg)
-		default:
-			err = fmt.Errorf("unsupported message service type : %s", msg.Type())
+		entry.Type = audit.ActionRejected
+		entry.Detail = err.Error()
+
+		o.countStat(o.tenantOf(corr.ConnectionID), stats.Errors)
+		o.reporting.recordFailure(corr.ConnectionID)
+	} else {
+		logger.Infof("msgType=[%s] id=[%s] msg=[%s]", msg.Message.Type(), msg.Message.ID(), "success")
+
+		msg.Continue(args)
+
+		if msg.Message.Type() == mediatordsvc.RequestMsgType {
+			o.terms.sendGrant(corr.ConnectionID, corr.ThreadID)
+			o.registerMediationClient(corr.ConnectionID, "", "", grantRoutingKeys(args))
 		}
+	}
+
+	if msg.Message.Type() == mediatordsvc.RequestMsgType {
+		o.metrics.countMediation(err)
+	}
 
+	o.recordAudit(entry)
+	o.correlate(corr)
+}
+
+// admitDIDExchangeRequest checks the DID exchange request against the policy of the tenant, the token subject of the
+// invitation, and the handshake limits.
+func (o *Operation) admitDIDExchangeRequest(msg service.DIDCommMsg, connectionID string) error {
+	// the request is a response to the invitation, created for the tenant
+	tenantID := o.tenantOf(msg.ParentThreadID())
+	o.assignTenant(tenantID, connectionID)
+
+	err := o.checkDIDExchangePolicy(msg, tenantID)
+	if err != nil {
+		return err
+	}
+
+	err = o.invitationTokens.admit(msg.ParentThreadID(), connectionID)
+	if err != nil {
+		return err
+	}
+
+	return o.limits.openHandshake(connectionID, msg.ParentThreadID())
+}
+
+func actionConnectionID(msg service.DIDCommAction) string {
+	if msg.Properties == nil {
+		return ""
+	}
+
+	connID, _ := msg.Properties.All()["connectionID"].(string) // nolint:errcheck // empty if not a string
+
+	return connID
+}
+
+func (o *Operation) didCommMsgListener(ch <-chan service.DIDCommMsg) {
+	for msg := range ch {
+		entry := &deadletter.Entry{}
+
+		msgMap, err := o.processMsg(msg, entry)
 		if err != nil {
-			msgMap = service.NewDIDCommMsgMap(&CreateConnResp{
-				ID:   uuid.New().String(),
-				Type: createConnResp,
-				Data: &CreateConnRespData{ErrorMsg: err.Error()},
-			})
+			o.deadLetter(msg, entry, err)
+			o.countStat(o.msgTenant(msg), stats.Errors)
+			o.reporting.recordFailure(o.msgConnectionID(msg))
+		}
 
-			logger.Errorf("msgType=[%s] id=[%s] errMsg=[%s]", msg.Type(), msg.ID(), err.Error())
+		if msgMap == nil {
+			continue
 		}
 
 		err = o.messenger.ReplyTo(msg.ID(), msgMap) // nolint:staticcheck //issue#47
@@ -226,38 +968,161 @@ func (o *Operation) didCommMsgListener(ch <-chan service.DIDCommMsg) {
 	}
 }
 
+// processMsg handles the message and returns the reply, recording the decisions applied in the dead-letter entry.
+// On failure, the reply carries the error and the problem report.
+func (o *Operation) processMsg(msg service.DIDCommMsg, entry *deadletter.Entry) (service.DIDCommMsgMap, error) {
+	var err error
+
+	var msgMap service.DIDCommMsgMap
+
+	svc, ok := o.msgService(msg.Type())
+
+	switch {
+	case !o.policies.store.Current().AllowMsgType(msg.Type()):
+		entry.AddDecision(decisionRoute, "policy")
+
+		err = withProblem(problemPolicyRejected, fmt.Errorf("message type not allowed by policy : %s", msg.Type()))
+	case ok:
+		entry.AddDecision(decisionRoute, svc.Name)
+
+		msgMap, err = svc.Handler(msg)
+	default:
+		entry.AddDecision(decisionRoute, "unsupported")
+
+		err = withProblem(problemUnsupportedMsgType,
+			fmt.Errorf("unsupported message service type : %s", msg.Type()))
+	}
+
+	o.retries.Observe(msgPeer(msg), msg.Type(), err != nil && retryable(problemCode(err)))
+
+	if err == nil {
+		entry.AddDecision(decisionProcess, "success")
+
+		return msgMap, nil
+	}
+
+	entry.AddDecision(decisionProcess, problemCode(err))
+
+	logger.Errorf("msgType=[%s] id=[%s] errMsg=[%s]", msg.Type(), msg.ID(), err.Error())
+
+	corr := msgCorrelation(msg)
+
+	o.recordAudit(&audit.Entry{
+		Type: audit.MessageFailed, MsgType: msg.Type(), ThreadID: corr.ThreadID, Detail: err.Error(),
+	})
+	o.correlate(corr)
+
+	return o.errorReply(msg, err), err
+}
+
 func (o *Operation) handleCreateConnReq(msg service.DIDCommMsg) (service.DIDCommMsgMap, error) {
-	pMsg := CreateConnReq{}
+	didDoc, reqData, err := o.parseCreateConnReq(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	tenantID := o.msgTenant(msg)
 
-	err := msg.Decode(&pMsg)
+	data, err := o.establishConnection(tenantID, didDoc, reqData.Label, msgCorrelation(msg), history.ActorWallet)
 	if err != nil {
-		return nil, fmt.Errorf("parse didcomm message : %w", err)
+		return nil, err
 	}
 
-	// get the peerDID from the request
-	if pMsg.Data == nil || pMsg.Data.DIDDoc == nil || len(pMsg.Data.DIDDoc) == 0 {
-		return nil, errors.New("did document mandatory")
+	o.captureUserAgent(tenantID, data.ConnectionID, reqData.UserAgent, reqData.AppVersion)
+
+	// send router did doc
+	return service.NewDIDCommMsgMap(&CreateConnResp{
+		ID:   uuid.New().String(),
+		Type: createConnResp,
+		Data: data,
+	}), nil
+}
+
+// establishConnection creates the connection of the tenant with the DID doc and label, with a new router DID, and
+// auto-grants mediation on the connection if enabled : on the request of the wallet (create-conn-req), or of a backend
+// adapter through the REST API.
+func (o *Operation) establishConnection(tenantID string, didDoc *did.Doc, label string, corr *correlation.Record,
+	actor string) (*CreateConnRespData, error) {
+	err := o.checkConnPolicy(tenantID, didDoc.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	didDoc, err := did.ParseDocument(pMsg.Data.DIDDoc)
+	router, err := o.createRouterDID()
 	if err != nil {
-		return nil, fmt.Errorf("parse did doc : %w", err)
+		return nil, err
 	}
 
+	routerDoc, pubKeyBytes := router.doc, router.pubKeyBytes
+
+	// create connection
+	connID, err := o.didExchange.CreateConnection(routerDoc.ID, didDoc, didexchange.WithTheirLabel(label))
+	if err != nil {
+		return nil, fmt.Errorf("create connection : %w", err)
+	}
+
+	o.assignTenant(tenantID, connID, routerDoc.ID, didDoc.ID)
+	o.connectionCreated(corr, connID, didDoc, label, actor)
+
+	err = o.keyUsage.record(connID, keyusage.RoleRouterDID, pubKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	mediationGranted := o.autoGrantMediation(corr, connID, routerDoc.ID, didDoc)
+	if mediationGranted {
+		o.registerMediationClient(connID, didDoc.ID, label, []string{base58.Encode(pubKeyBytes)})
+	}
+
+	newDocBytes, err := routerDoc.JSONBytes()
+	if err != nil {
+		return nil, fmt.Errorf("marshal did doc : %w", err)
+	}
+
+	logger.Debugf("created PEER DID: %s", newDocBytes)
+
+	return &CreateConnRespData{
+		DIDDoc:           newDocBytes,
+		ConnectionID:     connID,
+		ServiceEndpoint:  router.service.ServiceEndpoint,
+		RoutingKeys:      append([]string{base58.Encode(pubKeyBytes)}, router.service.RoutingKeys...),
+		MediationGranted: mediationGranted,
+	}, nil
+}
+
+// routerDID is the peer DID of the router for a connection, its key, and the DIDComm service it advertises first.
+type routerDID struct {
+	doc         *did.Doc
+	pubKeyBytes []byte
+	service     *did.Service
+}
+
+// createRouterDID creates a new peer DID, with a new key, for the connection : the router DIDs and keys are not
+// shared across connections, to limit their correlation.
+func (o *Operation) createRouterDID() (*routerDID, error) {
 	// TODO - key type should be configurable
 	keyID, pubKeyBytes, err := o.keyManager.CreateAndExportPubKeyBytes(kms.ED25519Type)
 	if err != nil {
-		return nil, fmt.Errorf("kms failed to create key: %w", err)
+		return nil, withProblem(problemTransient, fmt.Errorf("kms failed to create key: %w", err))
+	}
+
+	recipientKey, _ := fingerprint.CreateDIDKey(pubKeyBytes)
+
+	endpoint, routingKeys, err := o.upstreamRouting(recipientKey)
+	if err != nil {
+		return nil, err
 	}
 
+	services := o.routerServices(endpoint, routingKeys)
+
 	// create peer DID
 	docResolution, err := o.vdriRegistry.Create(
 		peer.DIDMethod,
 		&did.Doc{
-			Service: []did.Service{{ServiceEndpoint: o.endpoint}},
+			Service: services,
 			VerificationMethod: []did.VerificationMethod{*did.NewVerificationMethodFromBytes(
 				"#"+keyID,
-				"Ed25519VerificationKey2018",
+				ed25519VerificationKey2018,
 				"",
 				pubKeyBytes,
 			)},
@@ -267,31 +1132,116 @@ func (o *Operation) handleCreateConnReq(msg service.DIDCommMsg) (service.DIDComm
 		return nil, fmt.Errorf("create new peer did : %w", err)
 	}
 
-	// create connection
-	_, err = o.didExchange.CreateConnection(docResolution.DIDDocument.ID, didDoc)
+	return &routerDID{doc: docResolution.DIDDocument, pubKeyBytes: pubKeyBytes, service: &services[0]}, nil
+}
+
+// parseCreateConnReq validates the create-conn-req, unless the router sheds load, and returns the sender DID doc and
+// the request data.
+func (o *Operation) parseCreateConnReq(msg service.DIDCommMsg) (*did.Doc, *CreateConnReqData, error) {
+	err := o.shedLoad()
 	if err != nil {
-		return nil, fmt.Errorf("create connection : %w", err)
+		return nil, nil, err
 	}
 
-	newDocBytes, err := docResolution.DIDDocument.JSONBytes()
+	// validate the request as it was received, the schema requires either the DID doc or the keys of the wallet
+	msgBytes, err := json.Marshal(msg)
 	if err != nil {
-		return nil, fmt.Errorf("marshal did doc : %w", err)
+		return nil, nil, withProblem(problemInvalidMsg, fmt.Errorf("marshal didcomm message : %w", err))
 	}
 
-	logger.Debugf("created PEER DID: %s", newDocBytes)
+	err = o.createConnReqSchema.validate(msg.Type(), msgBytes)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// send router did doc
-	return service.NewDIDCommMsgMap(&CreateConnResp{
-		ID:   uuid.New().String(),
-		Type: createConnResp,
-		Data: &CreateConnRespData{DIDDoc: newDocBytes},
-	}), nil
+	pMsg := CreateConnReq{}
+
+	err = msg.Decode(&pMsg)
+	if err != nil {
+		return nil, nil, withProblem(problemInvalidMsg, fmt.Errorf("parse didcomm message : %w", err))
+	}
+
+	didDoc, err := connDIDDoc(pMsg.Data)
+	if err != nil {
+		return nil, nil, withProblem(problemInvalidDIDDoc, err)
+	}
+
+	return didDoc, pMsg.Data, nil
+}
+
+// connDIDDoc returns the DID doc sent in the request, or the peer DID doc built from the keys sent in the request.
+func connDIDDoc(data *CreateConnReqData) (*did.Doc, error) {
+	if len(data.DIDDoc) > 0 {
+		didDoc, err := did.ParseDocument(data.DIDDoc)
+		if err != nil {
+			return nil, fmt.Errorf("parse did doc : %w", err)
+		}
+
+		return didDoc, nil
+	}
+
+	signingKey := base58.Decode(data.SigningKey)
+	if len(signingKey) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid signing key : expected %d bytes", ed25519.PublicKeySize)
+	}
+
+	signingVM := did.NewVerificationMethodFromBytes("#key-1", ed25519VerificationKey2018, "", signingKey)
+
+	vms := []did.VerificationMethod{*signingVM}
+	opts := []did.DocOption{
+		did.WithAuthentication([]did.Verification{*did.NewReferencedVerification(signingVM, did.Authentication)}),
+		did.WithService([]did.Service{{
+			ID:              "#didcomm",
+			Type:            didCommServiceType,
+			ServiceEndpoint: data.ServiceEndpoint,
+			RecipientKeys:   []string{data.SigningKey},
+		}}),
+	}
+
+	if data.AgreementKey != "" {
+		agreementKey := base58.Decode(data.AgreementKey)
+		if len(agreementKey) != curve25519KeySize {
+			return nil, fmt.Errorf("invalid agreement key : expected %d bytes", curve25519KeySize)
+		}
+
+		agreementVM := did.NewVerificationMethodFromBytes("#key-2", x25519KeyAgreementKey2019, "", agreementKey)
+
+		vms = append(vms, *agreementVM)
+		opts = append(opts, did.WithKeyAgreement(
+			[]did.Verification{*did.NewEmbeddedVerification(agreementVM, did.KeyAgreement)}))
+	}
+
+	didDoc, err := peer.NewDoc(vms, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("create peer did doc : %w", err)
+	}
+
+	return didDoc, nil
+}
+
+func (o *Operation) connectionCreated(corr *correlation.Record, connID string, didDoc *did.Doc, label, actor string) {
+	o.indexRecipient(connID, didDoc)
+
+	corr.ConnectionID = connID
+
+	o.recordAudit(&audit.Entry{
+		Type: audit.ConnectionCreated, ConnectionID: connID, ThreadID: corr.ThreadID, MsgType: corr.MsgType,
+		Actor: actor,
+	})
+	o.correlate(corr)
+
+	o.events.Publish(&events.ConnectionEvent{
+		Time: time.Now().UTC(), State: events.ConnectionCreated, ConnectionID: connID, ThreadID: corr.ThreadID,
+		Label: label,
+	})
 }
 
 func (o *Operation) stateMsgHandler(stateMsgCh chan service.StateMsg) {
 	for msg := range stateMsgCh {
 		switch msg.ProtocolName {
 		case didexdsvc.DIDExchange:
+			o.limits.closeHandshake(msg)
+
 			err := o.hanlDIDExStateMsg(msg)
 			if err != nil {
 				logger.Errorf("failed to handle did exchange state message : %s", err.Error())
@@ -324,13 +1274,32 @@ func (o *Operation) hanlDIDExStateMsg(msg service.StateMsg) error {
 		return fmt.Errorf("get connection for id=%s : %w", event.ConnectionID(), err)
 	}
 
+	msgID := uuid.New().String()
+
 	err = o.messenger.Send(service.NewDIDCommMsgMap(&DIDCommMsg{
-		ID:   uuid.New().String(),
+		ID:   msgID,
 		Type: didExStateComp,
 	}), conn.MyDID, conn.TheirDID)
 	if err != nil {
 		return fmt.Errorf("send didex state complete msg : %w", err)
 	}
 
+	o.assignTenant(o.tenantOf(conn.InvitationID), conn.ConnectionID, conn.MyDID, conn.TheirDID)
+	o.terms.inherit(conn.InvitationID, conn.ConnectionID, conn.MyDID)
+	o.seen(conn.ConnectionID, presence.SourceDIDExchange)
+	o.indexConnection(conn.ConnectionID, conn.TheirDID)
+	o.recordDIDKeyUsage(conn.ConnectionID, conn.MyDID)
+	o.events.Publish(&events.ConnectionEvent{
+		Time: time.Now().UTC(), State: events.ConnectionCompleted, ConnectionID: conn.ConnectionID,
+		ThreadID: conn.ThreadID, MyDID: conn.MyDID, TheirDID: conn.TheirDID, Label: conn.TheirLabel,
+	})
+
+	o.correlate(&correlation.Record{
+		ThreadID:     conn.ThreadID,
+		ConnectionID: conn.ConnectionID,
+		MsgID:        msgID,
+		MsgType:      didExStateComp,
+	})
+
 	return nil
 }
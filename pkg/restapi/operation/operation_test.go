@@ -7,6 +7,7 @@ SPDX-License-Identifier: Apache-2.0
 package operation
 
 import (
+	"crypto/ed25519"
 	"encoding/json"
 	"errors"
 	"net/http"
@@ -14,6 +15,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/btcsuite/btcutil/base58"
 	"github.com/google/uuid"
 	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
 	didexdsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/didexchange"
@@ -29,6 +31,10 @@ import (
 	mockvdri "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
 	"github.com/stretchr/testify/require"
 
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/history"
+	"github.com/trustbloc/hub-router/pkg/inbound"
 	"github.com/trustbloc/hub-router/pkg/internal/mock/didexchange"
 	"github.com/trustbloc/hub-router/pkg/internal/mock/messenger"
 	mockoutofband "github.com/trustbloc/hub-router/pkg/internal/mock/outofband"
@@ -39,7 +45,79 @@ func TestNew(t *testing.T) {
 		o, err := New(config())
 		require.NoError(t, err)
 
-		require.Len(t, o.GetRESTHandlers(), 2)
+		require.Len(t, o.GetRESTHandlers(), 55)
+	})
+
+	t.Run("with advertised endpoint", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+		require.Equal(t, "endpoint", o.endpoint)
+
+		config := config()
+		config.Mediation.AdvertisedEndpoint = "https://router.example.com"
+
+		o, err = New(config)
+		require.NoError(t, err)
+		require.Equal(t, "https://router.example.com", o.endpoint)
+
+		args, err := o.mediationGrantOptions("conn-1")
+		require.NoError(t, err)
+
+		opts, ok := args.(mediatordsvc.Options)
+		require.True(t, ok)
+		require.Equal(t, "https://router.example.com", opts.ServiceEndpoint)
+	})
+
+	t.Run("with multi-hop forward", func(t *testing.T) {
+		config := config()
+		config.Admission.MultiHopForward = true
+		config.Transports.Inbound = []*inbound.Middleware{inbound.NewMiddleware(nil, inbound.Relay)}
+
+		o, err := New(config)
+		require.NoError(t, err)
+		require.NotNil(t, o.relay)
+	})
+
+	t.Run("with dead-letter retention", func(t *testing.T) {
+		config := config()
+		config.DeadLetters.Retention = time.Hour
+
+		o, err := New(config)
+		require.NoError(t, err)
+		require.True(t, o.deadLetters.store.Enabled())
+
+		o.deadLetters.store.Stop()
+	})
+
+	t.Run("audit log error", func(t *testing.T) {
+		config := config()
+		config.Storage.Persistent = &mockstore.MockStoreProvider{ErrOpenStoreHandle: errors.New("open error")}
+
+		o, err := New(config)
+		require.Nil(t, o)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "audit log")
+	})
+
+	t.Run("pickup queue error", func(t *testing.T) {
+		config := config()
+		persistent := mockstore.NewMockStoreProvider()
+		persistent.FailNamespace = messagepickup.Namespace
+		config.Storage.Persistent = persistent
+
+		_, err := New(config)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "pickup queue")
+	})
+
+	t.Run("export job store error", func(t *testing.T) {
+		config := config()
+		config.Storage.Transient = &mockstore.MockStoreProvider{ErrOpenStoreHandle: errors.New("open error")}
+
+		o, err := New(config)
+		require.Nil(t, o)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open export job store")
 	})
 
 	t.Run("aries store error", func(t *testing.T) {
@@ -102,7 +180,7 @@ func TestGenerateInvitationHandler(t *testing.T) {
 		require.NoError(t, err)
 
 		w := httptest.NewRecorder()
-		o.generateInvitation(w, nil)
+		o.generateInvitation(w, httptest.NewRequest(http.MethodGet, invitationPath, nil))
 		require.Equal(t, http.StatusOK, w.Code)
 
 		var result *DIDCommInvitationResp
@@ -121,7 +199,7 @@ func TestGenerateInvitationHandler(t *testing.T) {
 		o.oob = &mockoutofband.MockClient{CreateInvitationErr: errors.New("invitation error")}
 
 		w := httptest.NewRecorder()
-		o.generateInvitation(w, nil)
+		o.generateInvitation(w, httptest.NewRequest(http.MethodGet, invitationPath, nil))
 		require.Equal(t, http.StatusInternalServerError, w.Code)
 		require.Contains(t, w.Body.String(), "failed to create router invitation")
 	})
@@ -163,7 +241,10 @@ func TestDIDCommListener(t *testing.T) {
 				Type string `json:"@type,omitempty"`
 			}{Type: mediatordsvc.RequestMsgType}),
 			Continue: func(args interface{}) {
-				require.Nil(t, args)
+				opts, ok := args.(mediatordsvc.Options)
+				require.True(t, ok)
+				require.Len(t, opts.RoutingKeys, 1)
+				require.Contains(t, opts.RoutingKeys[0], "did:key:")
 
 				done <- struct{}{}
 			},
@@ -344,6 +425,7 @@ func TestDIDCommMsgListener(t *testing.T) {
 
 				require.Contains(t, pMsg.Data.ErrorMsg, "unsupported message service type : unsupported-message-type")
 				require.Empty(t, pMsg.Data.DIDDoc)
+				require.Equal(t, problemUnsupportedMsgType, pMsg.Data.ProblemReport.Description.Code)
 
 				done <- struct{}{}
 
@@ -416,7 +498,7 @@ func TestDIDCommMsgListener(t *testing.T) {
 		didDocBytes, err := mockdiddoc.GetMockDIDDoc(t).JSONBytes()
 		require.NoError(t, err)
 
-		msgCh <- service.NewDIDCommMsgMap(CreateConnReq{
+		msgCh <- receivedMsg(t, CreateConnReq{
 			ID:   uuid.New().String(),
 			Type: createConnReq,
 			Data: &CreateConnReqData{
@@ -437,25 +519,27 @@ func TestCreateConnectionReqHanlder(t *testing.T) {
 		c, err := New(config())
 		require.NoError(t, err)
 
-		msg := service.NewDIDCommMsgMap(CreateConnReq{
+		msg := receivedMsg(t, CreateConnReq{
 			ID:   uuid.New().String(),
 			Type: createConnReq,
 			Data: &CreateConnReqData{},
 		})
 
 		_, err = c.handleCreateConnReq(msg)
-		require.Contains(t, err.Error(), "did document mandatory")
+		require.Error(t, err)
+		require.Equal(t, problemInvalidMsg, problemCode(err))
+		require.Contains(t, err.Error(), "didDoc is required")
 	})
 
 	t.Run("invalid did doc error", func(t *testing.T) {
 		c, err := New(config())
 		require.NoError(t, err)
 
-		msg := service.NewDIDCommMsgMap(CreateConnReq{
+		msg := receivedMsg(t, CreateConnReq{
 			ID:   uuid.New().String(),
 			Type: createConnReq,
 			Data: &CreateConnReqData{
-				DIDDoc: []byte("invalid-diddoc"),
+				DIDDoc: json.RawMessage(`{"@context":"https://www.w3.org/ns/did/v1","id":"did:peer:123","service":[1]}`),
 			},
 		})
 
@@ -474,7 +558,7 @@ func TestCreateConnectionReqHanlder(t *testing.T) {
 		didDocBytes, err := mockdiddoc.GetMockDIDDoc(t).JSONBytes()
 		require.NoError(t, err)
 
-		msg := service.NewDIDCommMsgMap(CreateConnReq{
+		msg := receivedMsg(t, CreateConnReq{
 			ID:   uuid.New().String(),
 			Type: createConnReq,
 			Data: &CreateConnReqData{
@@ -497,7 +581,7 @@ func TestCreateConnectionReqHanlder(t *testing.T) {
 		didDocBytes, err := mockdiddoc.GetMockDIDDoc(t).JSONBytes()
 		require.NoError(t, err)
 
-		msg := service.NewDIDCommMsgMap(CreateConnReq{
+		msg := receivedMsg(t, CreateConnReq{
 			ID:   uuid.New().String(),
 			Type: createConnReq,
 			Data: &CreateConnReqData{
@@ -510,6 +594,65 @@ func TestCreateConnectionReqHanlder(t *testing.T) {
 		require.Contains(t, err.Error(), "create connection")
 	})
 
+	t.Run("key-only request", func(t *testing.T) {
+		c, err := New(config())
+		require.NoError(t, err)
+
+		msg := receivedMsg(t, CreateConnReq{
+			ID:   uuid.New().String(),
+			Type: createConnReq,
+			Data: &CreateConnReqData{
+				SigningKey:      base58.Encode(make([]byte, ed25519.PublicKeySize)),
+				AgreementKey:    base58.Encode(make([]byte, curve25519KeySize)),
+				ServiceEndpoint: "http://wallet.example.com",
+			},
+		})
+
+		resp, err := c.handleCreateConnReq(msg)
+		require.NoError(t, err)
+		require.Equal(t, createConnResp, resp.Type())
+
+		pMsg := &CreateConnResp{}
+		require.NoError(t, resp.Decode(pMsg))
+		require.NotEmpty(t, pMsg.Data.ConnectionID)
+		require.Equal(t, c.endpoint, pMsg.Data.ServiceEndpoint)
+		require.Len(t, pMsg.Data.RoutingKeys, 1)
+	})
+
+	t.Run("key-only request without service endpoint", func(t *testing.T) {
+		c, err := New(config())
+		require.NoError(t, err)
+
+		msg := receivedMsg(t, CreateConnReq{
+			ID:   uuid.New().String(),
+			Type: createConnReq,
+			Data: &CreateConnReqData{SigningKey: base58.Encode(make([]byte, ed25519.PublicKeySize))},
+		})
+
+		_, err = c.handleCreateConnReq(msg)
+		require.Error(t, err)
+		require.Equal(t, problemInvalidMsg, problemCode(err))
+	})
+
+	t.Run("key-only request with invalid key", func(t *testing.T) {
+		c, err := New(config())
+		require.NoError(t, err)
+
+		msg := receivedMsg(t, CreateConnReq{
+			ID:   uuid.New().String(),
+			Type: createConnReq,
+			Data: &CreateConnReqData{
+				SigningKey:      base58.Encode(make([]byte, 33)),
+				ServiceEndpoint: "http://wallet.example.com",
+			},
+		})
+
+		_, err = c.handleCreateConnReq(msg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid signing key")
+		require.Equal(t, problemInvalidDIDDoc, problemCode(err))
+	})
+
 	t.Run("error if cannot create key", func(t *testing.T) {
 		expected := errors.New("test")
 
@@ -521,7 +664,7 @@ func TestCreateConnectionReqHanlder(t *testing.T) {
 		didDocBytes, err := mockdiddoc.GetMockDIDDoc(t).JSONBytes()
 		require.NoError(t, err)
 
-		msg := service.NewDIDCommMsgMap(CreateConnReq{
+		msg := receivedMsg(t, CreateConnReq{
 			ID:   uuid.New().String(),
 			Type: createConnReq,
 			Data: &CreateConnReqData{
@@ -533,3 +676,73 @@ func TestCreateConnectionReqHanlder(t *testing.T) {
 		require.ErrorIs(t, err, expected)
 	})
 }
+
+func TestConnDIDDoc(t *testing.T) {
+	t.Run("peer did doc from keys", func(t *testing.T) {
+		signingKey := base58.Encode(make([]byte, ed25519.PublicKeySize))
+
+		didDoc, err := connDIDDoc(&CreateConnReqData{
+			SigningKey:      signingKey,
+			AgreementKey:    base58.Encode(make([]byte, curve25519KeySize)),
+			ServiceEndpoint: "http://wallet.example.com",
+		})
+		require.NoError(t, err)
+		require.Contains(t, didDoc.ID, "did:peer:")
+		require.Len(t, didDoc.VerificationMethod, 2)
+		require.Len(t, didDoc.Authentication, 1)
+		require.Len(t, didDoc.KeyAgreement, 1)
+		require.Equal(t, "http://wallet.example.com", didDoc.Service[0].ServiceEndpoint)
+		require.Equal(t, []string{signingKey}, didDoc.Service[0].RecipientKeys)
+	})
+
+	t.Run("invalid agreement key", func(t *testing.T) {
+		_, err := connDIDDoc(&CreateConnReqData{
+			SigningKey:      base58.Encode(make([]byte, ed25519.PublicKeySize)),
+			AgreementKey:    base58.Encode(make([]byte, 16)),
+			ServiceEndpoint: "http://wallet.example.com",
+		})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid agreement key")
+	})
+}
+
+func TestEvents(t *testing.T) {
+	bus := events.NewBus()
+
+	cfg := config()
+	cfg.Events = bus
+
+	o, err := New(cfg)
+	require.NoError(t, err)
+	require.Equal(t, bus, o.Events())
+
+	sub := bus.Subscribe(10)
+	defer sub.Unsubscribe()
+
+	actionCh := make(chan service.DIDCommAction, 1)
+	go o.didCommActionListener(actionCh)
+
+	actionCh <- service.DIDCommAction{
+		Message: service.NewDIDCommMsgMap(struct {
+			Type string `json:"@type,omitempty"`
+		}{Type: mediatordsvc.RequestMsgType}),
+		Continue: func(interface{}) {},
+	}
+
+	select {
+	case e := <-sub.C:
+		require.Equal(t, events.TopicMediation, e.Topic())
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "tests are not validated due to timeout")
+	}
+
+	o.connectionCreated(msgCorrelation(service.NewDIDCommMsgMap(&DIDCommMsg{ID: "msg-1", Type: createConnReq})),
+		"conn-1", nil, "Alice's Wallet", history.ActorWallet)
+
+	e, ok := (<-sub.C).(*events.ConnectionEvent)
+	require.True(t, ok)
+	require.Equal(t, events.ConnectionCreated, e.State)
+	require.Equal(t, "conn-1", e.ConnectionID)
+	require.Equal(t, "msg-1", e.ThreadID)
+	require.Equal(t, "Alice's Wallet", e.Label)
+}
@@ -0,0 +1,317 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	didexdsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/didexchange"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/policy"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/stats"
+)
+
+// API endpoints.
+const (
+	policiesPath = "/policies"
+)
+
+const maxPolicySize = 64 * 1024
+
+var errRateLimited = errors.New("rate limit exceeded")
+
+// PolicyResp model.
+type PolicyResp struct {
+	Policy *policy.Document `json:"policy"`
+	// Changed is true if the document is a new version, false if it is equal to the current version.
+	Changed bool `json:"changed"`
+	DryRun  bool `json:"dryRun,omitempty"`
+}
+
+// policyHandler serves the versions of the policy document, and rate limits the invitations and connections.
+type policyHandler struct {
+	store       *policy.Store
+	limiter     *policy.Limiter
+	recordAudit func(*audit.Entry)
+}
+
+func (o *Operation) initPolicies(config *Config) error {
+	o.policies = &policyHandler{limiter: policy.NewLimiter(), recordAudit: o.recordAudit}
+
+	var err error
+
+	o.policies.store, err = policy.New(config.Storage.Persistent, o.locker)
+	if err != nil {
+		return fmt.Errorf("policy store: %w", err)
+	}
+
+	return nil
+}
+
+func (h *policyHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(policiesPath, http.MethodGet, h.getPolicy),
+		support.NewHTTPHandler(policiesPath, http.MethodPut, h.putPolicy),
+	}
+}
+
+func (h *policyHandler) getPolicy(rw http.ResponseWriter, req *http.Request) {
+	val := req.URL.Query().Get("version")
+	if val == "" {
+		current, err := h.store.Latest()
+		if err != nil {
+			httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+				fmt.Sprintf("failed to get policy - err=%s", err.Error()), policiesPath, logger)
+
+			return
+		}
+
+		setETag(rw, current.Version)
+		httputil.WriteResponseWithLog(rw, &PolicyResp{Policy: current}, policiesPath, logger)
+
+		return
+	}
+
+	version, err := strconv.Atoi(val)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest,
+			fmt.Sprintf("invalid version : %s", val), policiesPath, logger)
+
+		return
+	}
+
+	doc, err := h.store.Get(version)
+	if errors.Is(err, policy.ErrNotFound) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, err.Error(), policiesPath, logger)
+
+		return
+	}
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get policy - err=%s", err.Error()), policiesPath, logger)
+
+		return
+	}
+
+	setETag(rw, doc.Version)
+	httputil.WriteResponseWithLog(rw, &PolicyResp{Policy: doc}, policiesPath, logger)
+}
+
+func (h *policyHandler) putPolicy(rw http.ResponseWriter, req *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(rw, req.Body, maxPolicySize))
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest,
+			fmt.Sprintf("failed to read policy - err=%s", err.Error()), policiesPath, logger)
+
+		return
+	}
+
+	version, ok := h.precondition(rw, req)
+	if !ok {
+		return
+	}
+
+	dryRun := req.URL.Query().Get("dryRun") == "true"
+
+	var changed bool
+
+	doc, err := policy.Parse(body)
+	if err == nil {
+		doc, changed, err = h.store.PutIf(doc, dryRun, version)
+	}
+
+	if err != nil {
+		writePolicyError(rw, err, version != anyRevision)
+
+		return
+	}
+
+	if changed && !dryRun {
+		h.recordAudit(&audit.Entry{Type: audit.PolicyUpdated, Detail: strconv.Itoa(doc.Version)})
+	}
+
+	if !dryRun {
+		setETag(rw, doc.Version)
+	}
+
+	httputil.WriteResponseWithLog(rw, &PolicyResp{Policy: doc, Changed: changed, DryRun: dryRun}, policiesPath, logger)
+}
+
+// checkInvitationPolicy applies the invitation rate limit of the tenant, or of the operator if empty.
+func (h *policyHandler) checkInvitationPolicy(tenantID string) error {
+	limits := h.store.Current().RateLimits
+	if limits != nil && !h.limiter.Allow("invitation/"+tenantID, limits.InvitationsPerMinute, time.Now()) {
+		return fmt.Errorf("%w : invitations per minute", errRateLimited)
+	}
+
+	return nil
+}
+
+// checkConnPolicy applies the connection rate limit, the DID method allowlist and the connection quota to the
+// connection requested for the tenant by the wallet DID.
+func (o *Operation) checkConnPolicy(tenantID, didID string) error {
+	doc := o.policies.store.Current()
+
+	if doc.RateLimits != nil &&
+		!o.policies.limiter.Allow("connection/"+tenantID, doc.RateLimits.ConnectionsPerMinute, time.Now()) {
+		return withProblem(problemPolicyRejected, fmt.Errorf("%w : connections per minute", errRateLimited))
+	}
+
+	if !doc.AllowDIDMethod(didID) {
+		return withProblem(problemPolicyRejected, fmt.Errorf("did method not allowed : %s", didID))
+	}
+
+	if tenantID == "" || doc.Quotas == nil || doc.Quotas.ConnectionsPerDay == 0 {
+		return nil
+	}
+
+	rollups, err := o.stats.History(tenantID, time.Now().Add(-day), time.Time{})
+	if err != nil {
+		return fmt.Errorf("connection quota : %w", err)
+	}
+
+	var count int
+
+	for _, r := range rollups {
+		count += r.Counters[stats.ConnectionsCreated]
+	}
+
+	if count >= doc.Quotas.ConnectionsPerDay {
+		return withProblem(problemPolicyRejected,
+			fmt.Errorf("connection quota exceeded : %d connections per day", doc.Quotas.ConnectionsPerDay))
+	}
+
+	return nil
+}
+
+// checkDIDExchangePolicy applies the auto-accept rules and the connection policy to the DID exchange request.
+func (o *Operation) checkDIDExchangePolicy(msg service.DIDCommMsg, tenantID string) error {
+	if !o.policies.store.Current().AcceptDIDExchange(tenantID) {
+		return errors.New("did exchange not accepted by policy")
+	}
+
+	request := didexdsvc.Request{}
+
+	err := msg.Decode(&request)
+	if err != nil {
+		return fmt.Errorf("parse did exchange request : %w", err)
+	}
+
+	didID := request.DID
+	if didID == "" && request.Connection != nil {
+		didID = request.Connection.DID
+	}
+
+	return o.checkConnPolicy(tenantID, didID)
+}
+
+// checkMediationPolicy rejects the mediation requests of the suspended tenants, and applies the auto-accept rules.
+func (o *Operation) checkMediationPolicy(tenantID string) error {
+	if err := o.tenancy.checkActive(tenantID); err != nil {
+		return err
+	}
+
+	if !o.policies.store.Current().AcceptMediation(tenantID) {
+		return errors.New("mediation not granted by policy")
+	}
+
+	return nil
+}
+
+// checkMediationGrant applies the mediation DID allowlist and the mediation quota of its tenant to the wallet of the
+// connection. The DID of the wallet is looked up from the connection if not given.
+func (o *Operation) checkMediationGrant(connID, theirDID string) error {
+	doc := o.policies.store.Current()
+
+	if doc.Allowlists != nil && len(doc.Allowlists.MediationDIDs) > 0 && theirDID == "" {
+		record, err := o.connections.GetConnectionRecord(connID)
+		if err != nil {
+			return fmt.Errorf("get connection : %w", err)
+		}
+
+		theirDID = record.TheirDID
+	}
+
+	if !doc.AllowMediationDID(theirDID) {
+		return fmt.Errorf("mediation not allowed : %s", theirDID)
+	}
+
+	tenantID := o.tenantOf(connID)
+	if tenantID == "" || doc.Quotas == nil || doc.Quotas.MediationsPerTenant == 0 {
+		return nil
+	}
+
+	clients, err := o.mediationClients.List()
+	if err != nil {
+		return fmt.Errorf("mediation quota : %w", err)
+	}
+
+	var count int
+
+	for _, c := range clients {
+		if c.ConnectionID != connID && o.tenantOf(c.ConnectionID) == tenantID {
+			count++
+		}
+	}
+
+	if count >= doc.Quotas.MediationsPerTenant {
+		return fmt.Errorf("mediation quota exceeded : %d wallets per tenant", doc.Quotas.MediationsPerTenant)
+	}
+
+	return nil
+}
+
+// precondition returns the version of the policy the update of the request is conditional on, anyRevision if
+// not conditional, or writes the error response.
+func (h *policyHandler) precondition(rw http.ResponseWriter, req *http.Request) (int, bool) {
+	p, err := ifMatch(req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), policiesPath, logger)
+
+		return 0, false
+	}
+
+	if p == nil {
+		return anyRevision, true
+	}
+
+	current, err := h.store.Latest()
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get policy - err=%s", err.Error()), policiesPath, logger)
+
+		return 0, false
+	}
+
+	return p.revision(current.Version), true
+}
+
+// writePolicyError writes the error of a policy update : the version conflicts are preconditions failed if the update
+// is conditional (If-Match), and conflicts with the version of the document otherwise.
+func writePolicyError(rw http.ResponseWriter, err error, conditional bool) {
+	switch {
+	case errors.Is(err, policy.ErrInvalid):
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), policiesPath, logger)
+	case errors.Is(err, policy.ErrVersionConflict) && conditional:
+		writePreconditionFailed(rw, err, policiesPath)
+	case errors.Is(err, policy.ErrVersionConflict):
+		httputil.WriteErrorResponseWithLog(rw, http.StatusConflict, err.Error(), policiesPath, logger)
+	default:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to update policy - err=%s", err.Error()), policiesPath, logger)
+	}
+}
@@ -0,0 +1,300 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	didexdsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/didexchange"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/policy"
+	"github.com/trustbloc/hub-router/pkg/stats"
+)
+
+func TestPolicyAPI(t *testing.T) {
+	put := func(o *Operation, query, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.policies.putPolicy(w, httptest.NewRequest(http.MethodPut, policiesPath+query, strings.NewReader(body)))
+
+		return w
+	}
+
+	get := func(o *Operation, query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.policies.getPolicy(w, httptest.NewRequest(http.MethodGet, policiesPath+query, nil))
+
+		return w
+	}
+
+	policyResp := func(t *testing.T, w *httptest.ResponseRecorder) *PolicyResp {
+		t.Helper()
+
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		resp := &PolicyResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+
+		return resp
+	}
+
+	t.Run("versions and dry run", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		doc := `{"rateLimits":{"invitationsPerMinute":10}}`
+
+		resp := policyResp(t, put(o, "?dryRun=true", doc))
+		require.True(t, resp.DryRun)
+		require.True(t, resp.Changed)
+		require.Equal(t, 1, resp.Policy.Version)
+		require.Equal(t, 0, o.policies.store.Current().Version)
+
+		resp = policyResp(t, put(o, "", doc))
+		require.True(t, resp.Changed)
+		require.Equal(t, 1, resp.Policy.Version)
+
+		resp = policyResp(t, put(o, "", doc))
+		require.False(t, resp.Changed)
+		require.Equal(t, 1, resp.Policy.Version)
+
+		resp = policyResp(t, put(o, "", `{"version":1,"quotas":{"connectionsPerDay":5}}`))
+		require.True(t, resp.Changed)
+		require.Equal(t, 2, resp.Policy.Version)
+
+		resp = policyResp(t, get(o, ""))
+		require.Equal(t, 2, resp.Policy.Version)
+		require.Equal(t, 5, resp.Policy.Quotas.ConnectionsPerDay)
+
+		resp = policyResp(t, get(o, "?version=1"))
+		require.Equal(t, 10, resp.Policy.RateLimits.InvitationsPerMinute)
+
+		entries, err := o.auditLog.Query(time.Time{}, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, audit.PolicyUpdated, entries[0].Type)
+	})
+
+	t.Run("if-match versions", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		putIf := func(etag, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodPut, policiesPath, strings.NewReader(body))
+			req.Header.Set(ifMatchHeader, etag)
+
+			w := httptest.NewRecorder()
+			o.policies.putPolicy(w, req)
+
+			return w
+		}
+
+		w := get(o, "")
+		require.Equal(t, `"0"`, w.Header().Get(etagHeader))
+
+		w = putIf(`"0"`, `{"rateLimits":{"invitationsPerMinute":10}}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Equal(t, `"1"`, w.Header().Get(etagHeader))
+
+		// stale version : the change of the other admin tool is not clobbered
+		w = putIf(`"0"`, `{"quotas":{"connectionsPerDay":5}}`)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+		require.Contains(t, w.Body.String(), "current version is 1")
+		require.Equal(t, 10, o.policies.store.Current().RateLimits.InvitationsPerMinute)
+
+		w = putIf(`"x"`, `{}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "invalid If-Match")
+
+		w = put(o, "?dryRun=true", `{"quotas":{"connectionsPerDay":5}}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Header().Get(etagHeader))
+
+		w = get(o, "?version=1")
+		require.Equal(t, `"1"`, w.Header().Get(etagHeader))
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := put(o, "", `{"rateLimits":{"invitationsPerMinute":-1},"allowlists":{"didMethods":["did:peer"]}}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "rateLimits.invitationsPerMinute : must not be negative")
+		require.Contains(t, w.Body.String(), "invalid DID method")
+
+		w = put(o, "?dryRun=true", `{"unknown":true}`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "unknown field")
+
+		w = put(o, "", `{"version":3}`)
+		require.Equal(t, http.StatusConflict, w.Code)
+
+		w = put(o, "", strings.Repeat(" ", maxPolicySize+1))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "failed to read policy")
+
+		w = get(o, "?version=abc")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = get(o, "?version=1")
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.policies.store, err = policy.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+		}), o.locker)
+		require.NoError(t, err)
+
+		w := put(o, "", `{"quotas":{"connectionsPerDay":5}}`)
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "put error")
+
+		store := &mockstore.MockStore{Store: map[string]mockstore.DBEntry{"1": {Value: []byte("{")}}}
+
+		o.policies.store, err = policy.New(mockstore.NewCustomMockStoreProvider(store), o.locker)
+		require.NoError(t, err)
+
+		w = get(o, "?version=1")
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+
+		store.ErrGet = errors.New("get error")
+
+		w = get(o, "")
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "failed to get policy")
+	})
+}
+
+func TestPolicyEnforcement(t *testing.T) {
+	setPolicy := func(t *testing.T, o *Operation, doc *policy.Document) {
+		t.Helper()
+
+		_, _, err := o.policies.store.Put(doc, false)
+		require.NoError(t, err)
+	}
+
+	t.Run("invitation rate limit", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		setPolicy(t, o, &policy.Document{RateLimits: &policy.RateLimits{InvitationsPerMinute: 1}})
+
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, httptest.NewRequest(http.MethodGet, invitationPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		w = httptest.NewRecorder()
+		o.generateInvitation(w, httptest.NewRequest(http.MethodGet, invitationPath, nil))
+		require.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+
+	t.Run("did method allowlist", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		setPolicy(t, o, &policy.Document{Allowlists: &policy.Allowlists{DIDMethods: []string{"key"}}})
+
+		didDocBytes, err := mockdiddoc.GetMockDIDDoc(t).JSONBytes()
+		require.NoError(t, err)
+
+		_, err = o.handleCreateConnReq(receivedMsg(t, CreateConnReq{
+			ID: uuid.New().String(), Type: createConnReq, Data: &CreateConnReqData{DIDDoc: didDocBytes},
+		}))
+		require.Error(t, err)
+		require.Equal(t, problemPolicyRejected, problemCode(err))
+		require.Contains(t, err.Error(), "did method not allowed")
+	})
+
+	t.Run("connection rate limit and quota", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		setPolicy(t, o, &policy.Document{RateLimits: &policy.RateLimits{ConnectionsPerMinute: 1}})
+		require.NoError(t, o.checkConnPolicy("tenant-1", "did:peer:1"))
+		require.ErrorIs(t, o.checkConnPolicy("tenant-1", "did:peer:2"), errRateLimited)
+		require.NoError(t, o.checkConnPolicy("tenant-2", "did:peer:3"))
+
+		setPolicy(t, o, &policy.Document{Quotas: &policy.Quotas{ConnectionsPerDay: 1}})
+		require.NoError(t, o.checkConnPolicy("tenant-1", "did:peer:1"))
+		require.NoError(t, o.stats.Incr("tenant-1", stats.ConnectionsCreated))
+
+		err = o.checkConnPolicy("tenant-1", "did:peer:1")
+		require.Equal(t, problemPolicyRejected, problemCode(err))
+		require.Contains(t, err.Error(), "connection quota exceeded")
+		require.NoError(t, o.checkConnPolicy("", "did:peer:1"))
+
+		o.stats, err = stats.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrQuery: errors.New("query error"),
+		}), 0)
+		require.NoError(t, err)
+
+		err = o.checkConnPolicy("tenant-1", "did:peer:1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "connection quota")
+	})
+
+	t.Run("message type allowlist", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		setPolicy(t, o, &policy.Document{Allowlists: &policy.Allowlists{MsgTypes: []string{"other-type"}}})
+
+		entry := &deadletter.Entry{}
+
+		_, err = o.processMsg(receivedMsg(t, CreateConnReq{
+			ID: uuid.New().String(), Type: createConnReq, Data: &CreateConnReqData{},
+		}), entry)
+		require.Error(t, err)
+		require.Equal(t, problemPolicyRejected, problemCode(err))
+		require.Equal(t, "policy", entry.Decisions[0].Outcome)
+	})
+
+	t.Run("auto accept", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		no := false
+
+		setPolicy(t, o, &policy.Document{AutoAccept: &policy.AutoAccept{Mediation: &no, TenantsOnly: true}})
+		require.Error(t, o.checkMediationPolicy("tenant-1"))
+
+		request := service.NewDIDCommMsgMap(&didexdsvc.Request{
+			Type: didexdsvc.RequestMsgType, Connection: &didexdsvc.Connection{DID: "did:peer:1"},
+		})
+
+		err = o.checkDIDExchangePolicy(request, "")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "did exchange not accepted by policy")
+		require.NoError(t, o.checkDIDExchangePolicy(request, "tenant-1"))
+
+		setPolicy(t, o, &policy.Document{Allowlists: &policy.Allowlists{DIDMethods: []string{"key"}}})
+		require.Error(t, o.checkDIDExchangePolicy(request, "tenant-1"))
+
+		err = o.checkDIDExchangePolicy(service.DIDCommMsgMap{"@type": didexdsvc.RequestMsgType, "connection": "x"}, "")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "parse did exchange request")
+	})
+}
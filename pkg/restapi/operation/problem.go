@@ -0,0 +1,127 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/retryadvice"
+)
+
+// Problem codes, these are also the keys of the l10n catalog.
+const (
+	problemUnsupportedMsgType = "unsupported-message-type"
+	problemInvalidMsg         = "invalid-message"
+	problemInvalidDIDDoc      = "invalid-did-doc"
+	problemInternal           = "internal-error"
+	problemOverloaded         = "router-overloaded"
+	problemPolicyRejected     = "policy-rejected"
+	problemQueueNearFull      = "recipient-queue-near-full"
+	problemQueueFull          = "recipient-queue-full"
+	problemTransient          = "transient-error"
+
+	problemReportMsgType = "https://didcomm.org/report-problem/1.0/problem-report"
+)
+
+// ProblemError associates an error with the problem code, and optionally the problem items, reported to the sender.
+type ProblemError struct {
+	Code  string
+	Err   error
+	Items []map[string]string
+}
+
+func (p *ProblemError) Error() string {
+	return p.Err.Error()
+}
+
+func (p *ProblemError) Unwrap() error {
+	return p.Err
+}
+
+func withProblem(code string, err error) error {
+	return &ProblemError{Code: code, Err: err}
+}
+
+func problemItems(err error) []map[string]string {
+	var p *ProblemError
+
+	if errors.As(err, &p) {
+		return p.Items
+	}
+
+	return nil
+}
+
+// problemCode returns the problem code of the error : the errors without code are internal errors, or transient errors
+// if they are timeouts.
+func problemCode(err error) string {
+	var p *ProblemError
+
+	if errors.As(err, &p) {
+		return p.Code
+	}
+
+	if retryadvice.IsTransient(err) {
+		return problemTransient
+	}
+
+	return problemInternal
+}
+
+// retryable returns true if the peers are advised to retry the messages failed with the problem code.
+func retryable(code string) bool {
+	return code == problemTransient || code == problemOverloaded
+}
+
+// msgPeer returns the DID of the peer that sent the message, empty if unknown.
+func msgPeer(msg service.DIDCommMsg) string {
+	if inbound, ok := msg.(*aries.InboundMsg); ok {
+		return inbound.TheirDID
+	}
+
+	return ""
+}
+
+// newProblemReport builds the problem report for the error with the explanation in the peer's advertised locale. The
+// peers are advised when to retry the messages failed on transient errors.
+func (o *Operation) newProblemReport(msg service.DIDCommMsg, err error) *ProblemReport {
+	code := problemCode(err)
+
+	en, _ := o.catalog.Text("", code)
+	explain, locale := o.catalog.Text(peerLocale(msg), code)
+
+	report := &ProblemReport{
+		Type:        problemReportMsgType,
+		Description: &ProblemDescription{Code: code, En: en},
+		Explain:     explain,
+		Items:       problemItems(err),
+		L10n:        &L10n{Locale: locale},
+	}
+
+	if retryable(code) {
+		report.RetryAfter = int(o.retries.RetryAfter() / time.Second)
+	}
+
+	return report
+}
+
+// peerLocale returns the locale from the message's ~l10n decorator, if any.
+func peerLocale(msg service.DIDCommMsg) string {
+	decorated := struct {
+		L10n *L10n `json:"~l10n"`
+	}{}
+
+	if err := msg.Decode(&decorated); err != nil || decorated.L10n == nil {
+		return ""
+	}
+
+	return decorated.L10n.Locale
+}
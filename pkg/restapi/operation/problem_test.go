@@ -0,0 +1,114 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockkms "github.com/hyperledger/aries-framework-go/pkg/mock/kms"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/deadletter"
+	"github.com/trustbloc/hub-router/pkg/retryadvice"
+)
+
+func TestProblemCode(t *testing.T) {
+	require.Equal(t, problemInternal, problemCode(errors.New("error")))
+	require.Equal(t, problemInvalidDIDDoc, problemCode(withProblem(problemInvalidDIDDoc, errors.New("error"))))
+	require.Equal(t, problemInvalidDIDDoc,
+		problemCode(fmt.Errorf("wrapped : %w", withProblem(problemInvalidDIDDoc, errors.New("error")))))
+
+	require.Equal(t, problemTransient, problemCode(fmt.Errorf("storage : %w", context.DeadlineExceeded)))
+	require.Equal(t, problemTransient, problemCode(fmt.Errorf("kms : %w", retryadvice.ErrTransient)))
+
+	cause := errors.New("cause")
+	require.ErrorIs(t, withProblem(problemInvalidMsg, cause), cause)
+	require.Equal(t, "cause", withProblem(problemInvalidMsg, cause).Error())
+}
+
+func TestNewProblemReport(t *testing.T) {
+	o, err := New(config())
+	require.NoError(t, err)
+
+	t.Run("peer locale", func(t *testing.T) {
+		msg := service.NewDIDCommMsgMap(struct {
+			ID   string `json:"@id"`
+			Type string `json:"@type"`
+			L10n *L10n  `json:"~l10n"`
+		}{ID: uuid.New().String(), Type: createConnReq, L10n: &L10n{Locale: "fr-CA"}})
+
+		report := o.newProblemReport(msg, withProblem(problemInvalidDIDDoc, errors.New("parse did doc")))
+		require.Equal(t, problemReportMsgType, report.Type)
+		require.Equal(t, problemInvalidDIDDoc, report.Description.Code)
+		require.Equal(t, "The DID document is not valid.", report.Description.En)
+		require.Equal(t, "Le document DID n'est pas valide.", report.Explain)
+		require.Equal(t, "fr", report.L10n.Locale)
+	})
+
+	t.Run("no peer locale", func(t *testing.T) {
+		msg := receivedMsg(t, CreateConnReq{
+			ID: uuid.New().String(), Type: createConnReq, Data: &CreateConnReqData{},
+		})
+
+		report := o.newProblemReport(msg, errors.New("kms error"))
+		require.Equal(t, problemInternal, report.Description.Code)
+		require.Equal(t, report.Description.En, report.Explain)
+		require.Equal(t, "en", report.L10n.Locale)
+		require.Zero(t, report.RetryAfter)
+	})
+}
+
+func TestRetryAdvice(t *testing.T) {
+	cfg := config()
+	cfg.DeadLetters.TransientRetryAfter = 10 * time.Second
+
+	o, err := New(cfg)
+	require.NoError(t, err)
+
+	didDocBytes, err := mockdiddoc.GetMockDIDDoc(t).JSONBytes()
+	require.NoError(t, err)
+
+	msg := &aries.InboundMsg{
+		DIDCommMsg: receivedMsg(t, CreateConnReq{
+			ID:   uuid.New().String(),
+			Type: createConnReq,
+			Data: &CreateConnReqData{DIDDoc: json.RawMessage(didDocBytes)},
+		}),
+		TheirDID: "did:adapter",
+	}
+
+	o.keyManager = &mockkms.KeyManager{CrAndExportPubKeyErr: errors.New("kms unavailable")}
+
+	reply, err := o.processMsg(msg, &deadletter.Entry{})
+	require.Error(t, err)
+
+	resp := &CreateConnResp{}
+	require.NoError(t, reply.Decode(resp))
+	require.Equal(t, problemTransient, resp.Data.ProblemReport.Description.Code)
+	require.Equal(t, 10, resp.Data.ProblemReport.RetryAfter)
+
+	o.keyManager = &mockkms.KeyManager{}
+
+	_, err = o.processMsg(msg, &deadletter.Entry{})
+	require.NoError(t, err)
+
+	stats := o.retries.Stats()
+	require.Equal(t, uint64(1), stats.Advised)
+	require.Equal(t, uint64(1), stats.Retried)
+	require.Equal(t, uint64(1), stats.Early)
+	require.Equal(t, uint64(1), stats.Recovered)
+	require.Zero(t, stats.Pending)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/problemreport"
+)
+
+const (
+	problemReportTopic = "problem-report"
+	// walletProblemReports is the number of problem reports returned with the wallet, most recent first.
+	walletProblemReports = 10
+)
+
+// handleProblemReport persists the problem report received from a wallet or adapter, linked to its thread and
+// connection, and notifies it to the webhooks. No reply is ever sent, not even on failure : replying to a problem
+// report with a problem report would loop.
+func (o *Operation) handleProblemReport(msg service.DIDCommMsg) (service.DIDCommMsgMap, error) {
+	report := &ProblemReport{}
+
+	if err := msg.Decode(report); err != nil {
+		logger.Warnf("failed to decode problem report id=[%s] : %s", msg.ID(), err)
+
+		return nil, nil
+	}
+
+	corr := msgCorrelation(msg)
+
+	r := &problemreport.Record{
+		ConnectionID: o.msgConnection(msg, corr.ThreadID), ThreadID: corr.ThreadID,
+		ParentThreadID: corr.ParentThreadID, MsgID: msg.ID(), Explain: report.Explain, Impact: report.Impact,
+		Where: report.Where, WhoRetries: report.WhoRetries, FixHint: report.FixHint,
+	}
+
+	if report.Description != nil {
+		r.Code, r.Description = report.Description.Code, report.Description.En
+	}
+
+	logger.Warnf("problem report received : connectionID=%s thid=%s code=%s", r.ConnectionID, r.ThreadID, r.Code)
+
+	if err := o.problemReports.Save(r); err != nil {
+		logger.Warnf("failed to save problem report id=[%s] : %s", msg.ID(), err)
+	}
+
+	corr.ConnectionID = r.ConnectionID
+	o.correlate(corr)
+
+	go func() {
+		if err := o.webhook.Notify(problemReportTopic, r); err != nil {
+			logger.Warnf("failed to notify problem report : %s", err)
+		}
+	}()
+
+	return nil, nil
+}
+
+// msgConnection returns the connection the message was received on, from its DIDs or, if unknown, from the
+// correlation of its thread. It returns an empty ID if the connection isn't found.
+func (o *Operation) msgConnection(msg service.DIDCommMsg, threadID string) string {
+	if inbound, ok := msg.(*aries.InboundMsg); ok && inbound.MyDID != "" && inbound.TheirDID != "" {
+		connID, err := o.connections.GetConnectionIDByDIDs(inbound.MyDID, inbound.TheirDID)
+		if err == nil {
+			return connID
+		}
+
+		logger.Debugf("connection not found for msg id=[%s] : %s", msg.ID(), err)
+	}
+
+	if threadID == "" {
+		return ""
+	}
+
+	records, err := o.correlations.Find(correlation.ThreadIDTag, threadID)
+	if err != nil {
+		logger.Debugf("thread correlations not found for msg id=[%s] : %s", msg.ID(), err)
+
+		return ""
+	}
+
+	for _, r := range records {
+		if r.ConnectionID != "" {
+			return r.ConnectionID
+		}
+	}
+
+	return ""
+}
+
+// connectionProblems returns the problem reports last received on the connection.
+func (o *Operation) connectionProblems(connectionID string) ([]*problemreport.Record, error) {
+	records, err := o.problemReports.Find(problemreport.ConnectionIDTag, connectionID, walletProblemReports)
+	if err != nil {
+		return nil, fmt.Errorf("get problem reports : %w", err)
+	}
+
+	return records, nil
+}
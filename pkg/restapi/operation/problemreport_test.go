@@ -0,0 +1,161 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/problemreport"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestProblemReports(t *testing.T) {
+	report := func(thid string) service.DIDCommMsgMap {
+		return service.NewDIDCommMsgMap(&ProblemReport{
+			ID:          "report-1",
+			Type:        problemReportMsgType,
+			Description: &ProblemDescription{Code: "message-parse-failure", En: "the message can't be parsed"},
+			Explain:     "unexpected field",
+			Thread:      &decorator.Thread{ID: thid},
+			Impact:      "thread",
+			WhoRetries:  "you",
+		})
+	}
+
+	t.Run("problem report received on a connection", func(t *testing.T) {
+		notified := make(chan *problemreport.Record, 1)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			msg := &struct {
+				Topic   string                `json:"topic"`
+				Message *problemreport.Record `json:"message"`
+			}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(msg))
+			require.Equal(t, problemReportTopic, msg.Topic)
+
+			notified <- msg.Message
+		}))
+		defer srv.Close()
+
+		cfg := config()
+		cfg.Webhook = webhook.New([]string{srv.URL}, nil)
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-1", State: connection.StateNameCompleted, ThreadID: "thid-0",
+			MyDID: "did:router", TheirDID: "did:wallet", Namespace: connection.MyNSPrefix,
+		}))
+
+		reply, err := o.handleProblemReport(&aries.InboundMsg{
+			DIDCommMsg: report("thid-1"), MyDID: "did:router", TheirDID: "did:wallet",
+		})
+		require.NoError(t, err)
+		require.Nil(t, reply)
+
+		select {
+		case r := <-notified:
+			require.Equal(t, "conn-1", r.ConnectionID)
+			require.Equal(t, "thid-1", r.ThreadID)
+			require.Equal(t, "message-parse-failure", r.Code)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+
+		records, err := o.correlations.Find(correlation.ThreadIDTag, "thid-1")
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+		require.Equal(t, "conn-1", records[0].ConnectionID)
+
+		require.NoError(t, o.presence.Seen("conn-1", "mediation"))
+
+		w := httptest.NewRecorder()
+		o.getWallet(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, walletsPath+"/conn-1", nil),
+			map[string]string{"id": "conn-1"}))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		wallet := &Wallet{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), wallet))
+		require.Len(t, wallet.Problems, 1)
+		require.Equal(t, "report-1", wallet.Problems[0].MsgID)
+		require.Equal(t, "the message can't be parsed", wallet.Problems[0].Description)
+		require.Equal(t, "unexpected field", wallet.Problems[0].Explain)
+		require.Equal(t, "thread", wallet.Problems[0].Impact)
+		require.Equal(t, "you", wallet.Problems[0].WhoRetries)
+	})
+
+	t.Run("connection from the thread correlation", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		require.NoError(t, o.correlations.Save(&correlation.Record{ThreadID: "thid-1"}))
+		require.NoError(t, o.correlations.Save(&correlation.Record{ThreadID: "thid-1", ConnectionID: "conn-1"}))
+
+		_, err = o.handleProblemReport(&aries.InboundMsg{
+			DIDCommMsg: report("thid-1"), MyDID: "did:router", TheirDID: "did:unknown",
+		})
+		require.NoError(t, err)
+
+		records, err := o.connectionProblems("conn-1")
+		require.NoError(t, err)
+		require.Len(t, records, 1)
+
+		require.Empty(t, o.msgConnection(report("thid-2"), "thid-2"))
+		require.Empty(t, o.msgConnection(report(""), ""))
+	})
+
+	t.Run("problem report not recorded", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		reply, err := o.handleProblemReport(service.DIDCommMsgMap{"@type": problemReportMsgType, "description": 1})
+		require.NoError(t, err)
+		require.Nil(t, reply)
+
+		o.problemReports, err = problemreport.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}))
+		require.NoError(t, err)
+
+		_, err = o.handleProblemReport(report("thid-1"))
+		require.NoError(t, err)
+
+		_, err = o.connectionProblems("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get problem reports")
+	})
+
+	t.Run("init error", func(t *testing.T) {
+		cfg := config()
+		cfg.Storage.Persistent = &mockstore.MockStoreProvider{
+			Store: &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry)}, FailNamespace: "problemreport",
+		}
+
+		_, err := New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "problem report store")
+	})
+}
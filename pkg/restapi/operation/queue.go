@@ -0,0 +1,66 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"time"
+
+	"github.com/trustbloc/hub-router/pkg/queue"
+)
+
+const (
+	queueTopic         = "queue"
+	queueCheckInterval = 30 * time.Second
+)
+
+// errOverloaded is returned for new connections while the router sheds load.
+var errOverloaded = errors.New("router overloaded : message queues above watermark")
+
+// queueRecipients returns the DIDs of the wallets seen by the router, keyed by connection ID.
+func (o *Operation) queueRecipients() (map[string]string, error) {
+	records, err := o.presence.List("")
+	if err != nil {
+		return nil, err
+	}
+
+	recipients := make(map[string]string, len(records))
+
+	for _, r := range records {
+		conn, err := o.didExchange.GetConnection(r.ConnectionID)
+		if err != nil {
+			logger.Debugf("queue recipient connection not found : connectionID=%s : %s", r.ConnectionID, err)
+
+			continue
+		}
+
+		recipients[r.ConnectionID] = conn.TheirDID
+	}
+
+	return recipients, nil
+}
+
+// queueAlert notifies the queue watermark alert to the webhooks, and sends it to the alert channels, without blocking
+// the queue checks.
+func (o *Operation) queueAlert(a *queue.Alert) {
+	o.reporting.raise(queueWatermarkAlert(a))
+
+	go func() {
+		if err := o.webhook.Notify(queueTopic, a); err != nil {
+			logger.Warnf("failed to notify queue alert : %s", err)
+		}
+	}()
+}
+
+// shedLoad returns an error if new connections must be rejected.
+func (o *Operation) shedLoad() error {
+	if o.queue.Shedding() {
+		return withProblem(problemOverloaded, errOverloaded)
+	}
+
+	return nil
+}
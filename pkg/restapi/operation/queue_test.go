@@ -0,0 +1,101 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/internal/mock/didexchange"
+	"github.com/trustbloc/hub-router/pkg/presence"
+	"github.com/trustbloc/hub-router/pkg/queue"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestQueueWatermarks(t *testing.T) {
+	t.Run("alert and load shedding", func(t *testing.T) {
+		alerts := make(chan *queue.Alert, 1)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			msg := &struct {
+				Topic   string       `json:"topic"`
+				Message *queue.Alert `json:"message"`
+			}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(msg))
+
+			if msg.Topic == queueTopic {
+				alerts <- msg.Message
+			}
+		}))
+		defer srv.Close()
+
+		cfg := config()
+		cfg.Webhook = webhook.New([]string{srv.URL}, nil)
+		cfg.Queues.Watermarks = &queue.Config{GlobalWatermark: 2, LoadShedding: true}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.didExchange = &didexchange.MockClient{TheirDID: "did:wallet"}
+
+		queueMessages(t, o.pickup, "did:wallet", "key-1", 2)
+		queueMessages(t, o.pickup, "did:wallet", "key-2", 1)
+
+		require.NoError(t, o.presence.Seen("conn-1", presence.SourceMediation))
+		require.NoError(t, o.queue.Check())
+
+		select {
+		case a := <-alerts:
+			require.Equal(t, queue.ScopeGlobal, a.Scope)
+			require.Equal(t, queue.StateHigh, a.State)
+			require.Equal(t, 3, a.Depth)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, httptest.NewRequest(http.MethodGet, invitationPath, nil))
+		require.Equal(t, http.StatusServiceUnavailable, w.Code)
+		require.Contains(t, w.Body.String(), "router overloaded")
+
+		_, err = o.handleCreateConnReq(receivedMsg(t, CreateConnReq{
+			ID: uuid.New().String(), Type: createConnReq, Data: &CreateConnReqData{},
+		}))
+		require.Error(t, err)
+		require.Equal(t, problemOverloaded, problemCode(err))
+	})
+
+	t.Run("connection not found", func(t *testing.T) {
+		cfg := config()
+		cfg.Queues.Watermarks = &queue.Config{RecipientWatermark: 1}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.didExchange = &didexchange.MockClient{GetConnectionErr: errors.New("not found")}
+
+		require.NoError(t, o.presence.Seen("conn-1", presence.SourceMediation))
+
+		recipients, err := o.queueRecipients()
+		require.NoError(t, err)
+		require.Empty(t, recipients)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+		require.Nil(t, o.queue)
+		require.NoError(t, o.shedLoad())
+	})
+}
@@ -0,0 +1,70 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/supervisor"
+)
+
+const readinessPath = "/readiness"
+
+// Readiness statuses.
+const (
+	readinessReady   = "ready"
+	readinessFailing = "failing"
+)
+
+// errChannelClosed is the failure of the DIDComm listeners, once the Aries framework closed their channel.
+var errChannelClosed = errors.New("channel closed")
+
+// ReadinessResp model : the router is failing once one of its listeners, or supervised goroutines, failed
+// permanently.
+type ReadinessResp struct {
+	Status   string                `json:"status"`
+	Failures []*supervisor.Failure `json:"failures,omitempty"`
+}
+
+// startListeners starts the DIDComm listeners, supervised : the router stops handling the DIDComm messages if they
+// return.
+func (o *Operation) startListeners(actionCh <-chan service.DIDCommAction, stateMsgCh chan service.StateMsg) {
+	o.supervisor.Go("didcomm-action-listener", func() error {
+		o.didCommActionListener(actionCh)
+
+		return errChannelClosed
+	})
+
+	o.supervisor.Go("didcomm-message-listener", func() error {
+		o.didCommMsgListener(o.msgCh)
+
+		return errChannelClosed
+	})
+
+	o.supervisor.Go("didcomm-state-listener", func() error {
+		o.stateMsgHandler(stateMsgCh)
+
+		return errChannelClosed
+	})
+}
+
+func (o *Operation) getReadiness(rw http.ResponseWriter, _ *http.Request) {
+	resp := &ReadinessResp{Status: readinessReady}
+
+	if !o.supervisor.Ready() {
+		resp.Status = readinessFailing
+		resp.Failures = o.supervisor.Failures()
+
+		rw.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	httputil.WriteResponseWithLog(rw, resp, readinessPath, logger)
+}
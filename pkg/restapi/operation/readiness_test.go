@@ -0,0 +1,73 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/supervisor"
+)
+
+func TestReadiness(t *testing.T) {
+	getReadiness := func(o *Operation) (int, *ReadinessResp) {
+		w := httptest.NewRecorder()
+		o.getReadiness(w, httptest.NewRequest(http.MethodGet, readinessPath, nil))
+
+		resp := &ReadinessResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+
+		return w.Code, resp
+	}
+
+	t.Run("ready", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		code, resp := getReadiness(o)
+		require.Equal(t, http.StatusOK, code)
+		require.Equal(t, readinessReady, resp.Status)
+		require.Empty(t, resp.Failures)
+		require.Equal(t, accessPublic, endpointAccess(readinessPath))
+	})
+
+	t.Run("listener failed", func(t *testing.T) {
+		cfg := config()
+		cfg.Supervisor = supervisor.New(0)
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		close(o.msgCh)
+
+		require.Eventually(t, func() bool { return !cfg.Supervisor.Ready() }, time.Second, time.Millisecond)
+
+		code, resp := getReadiness(o)
+		require.Equal(t, http.StatusServiceUnavailable, code)
+		require.Equal(t, readinessFailing, resp.Status)
+		require.Len(t, resp.Failures, 1)
+		require.Equal(t, "didcomm-message-listener", resp.Failures[0].Component)
+		require.Equal(t, errChannelClosed.Error(), resp.Failures[0].Error)
+	})
+
+	t.Run("component failed", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.supervisor.Fail("didcomm-http", errors.New("connection refused"))
+
+		code, resp := getReadiness(o)
+		require.Equal(t, http.StatusServiceUnavailable, code)
+		require.Equal(t, "didcomm-http", resp.Failures[0].Component)
+	})
+}
@@ -0,0 +1,186 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/residency"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// API endpoints.
+const (
+	walletRegionPath = walletPath + "/region"
+)
+
+const maxWalletRegionSize = 1024
+
+// WalletRegionReq model : pins the wallet to the region, an empty region unpins it.
+type WalletRegionReq struct {
+	Region string `json:"region"`
+}
+
+// WalletRegionResp model : the revision of the pin is 0 once unpinned.
+type WalletRegionResp struct {
+	ConnectionID string `json:"connectionID"`
+	Region       string `json:"region,omitempty"`
+	Revision     int    `json:"revision"`
+}
+
+// residencyHandler serves the pins of the wallets to the regions of the data residency, and pins the wallets of the
+// tenants to their region. Its router is nil if the data residency isn't enabled.
+type residencyHandler struct {
+	router      *residency.Router
+	connections *connection.Lookup
+	recordAudit func(*audit.Entry)
+}
+
+func (o *Operation) initResidency(config *Config) {
+	o.residency = &residencyHandler{
+		router: config.Tenancy.Residency, connections: o.connections, recordAudit: o.recordAudit,
+	}
+}
+
+func (h *residencyHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(walletRegionPath, http.MethodPut, h.putWalletRegion),
+	}
+}
+
+// putWalletRegion pins the wallet of the connection to the region : the messages queued for the wallet are stored in
+// the datasource of the region.
+func (h *residencyHandler) putWalletRegion(rw http.ResponseWriter, req *http.Request) {
+	if h.router == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "data residency not enabled", walletRegionPath,
+			logger)
+
+		return
+	}
+
+	connID := mux.Vars(req)["id"]
+
+	regionReq := &WalletRegionReq{}
+
+	err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxWalletRegionSize)).Decode(regionReq)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, fmt.Sprintf("invalid request : %s", err),
+			walletRegionPath, logger)
+
+		return
+	}
+
+	conn, err := h.connections.GetConnectionRecord(connID)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, fmt.Sprintf("connection not found : %s", err),
+			walletRegionPath, logger)
+
+		return
+	}
+
+	revision, ok := h.pinPrecondition(rw, req, conn.TheirDID)
+	if !ok {
+		return
+	}
+
+	pin, err := h.router.PinIf(regionReq.Region, conn.TheirDID, revision)
+	if walletRegionError(rw, err) {
+		return
+	}
+
+	h.recordAudit(&audit.Entry{
+		Type: audit.WalletPinned, ConnectionID: connID, Detail: fmt.Sprintf("region=%s", regionReq.Region),
+		Actor: requestActor(req),
+	})
+
+	resp := &WalletRegionResp{ConnectionID: connID, Region: regionReq.Region}
+	if pin != nil {
+		resp.Revision = pin.Revision
+	}
+
+	setETag(rw, resp.Revision)
+	httputil.WriteResponseWithLog(rw, resp, walletRegionPath, logger)
+}
+
+// walletRegionError writes the error response of the pin, it returns false if there is no error.
+func walletRegionError(rw http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, residency.ErrUnknownRegion):
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), walletRegionPath, logger)
+	case errors.Is(err, residency.ErrRevisionConflict):
+		writePreconditionFailed(rw, err, walletRegionPath)
+	default:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to pin wallet - err=%s", err.Error()), walletRegionPath, logger)
+	}
+
+	return true
+}
+
+// pinPrecondition returns the revision of the pin of the wallet the change of the request is conditional on,
+// anyRevision if not conditional, or writes the error response.
+func (h *residencyHandler) pinPrecondition(rw http.ResponseWriter, req *http.Request, walletDID string) (int, bool) {
+	p, err := ifMatch(req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), walletRegionPath, logger)
+
+		return 0, false
+	}
+
+	if p == nil {
+		return anyRevision, true
+	}
+
+	pin, err := h.router.Lookup(walletDID)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get wallet pin - err=%s", err.Error()), walletRegionPath, logger)
+
+		return 0, false
+	}
+
+	if pin == nil {
+		return p.revision(0), true
+	}
+
+	return p.revision(pin.Revision), true
+}
+
+// walletPin returns the pin of the wallet of the connection to its region, nil if not pinned.
+func (h *residencyHandler) walletPin(connID string) *residency.Pin {
+	if h.router == nil {
+		return nil
+	}
+
+	conn, err := h.connections.GetConnectionRecord(connID)
+	if err != nil {
+		return nil
+	}
+
+	return h.router.PinOf(conn.TheirDID)
+}
+
+// assign pins the wallets of the invitations, connections or DIDs assigned to the tenant to its region.
+func (h *residencyHandler) assign(tenantID string, ids ...string) {
+	if h.router == nil {
+		return
+	}
+
+	if err := h.router.Assign(tenantID, ids...); err != nil {
+		logger.Warnf("failed to pin the wallets of tenant=[%s] to its region : %s", tenantID, err)
+	}
+}
@@ -0,0 +1,155 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/residency"
+)
+
+func TestWalletRegion(t *testing.T) {
+	putRegion := func(o *Operation, connID, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.residency.putWalletRegion(w, mux.SetURLVars(httptest.NewRequest(http.MethodPut, walletsPath+"/"+connID+"/region",
+			strings.NewReader(body)), map[string]string{"id": connID}))
+
+		return w
+	}
+
+	region := func(o *Operation, connID string) string {
+		if pin := o.residency.walletPin(connID); pin != nil {
+			return pin.Region
+		}
+
+		return ""
+	}
+
+	t.Run("data residency not enabled", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusNotFound, putRegion(o, "conn-1", `{"region":"eu"}`).Code)
+		require.Empty(t, region(o, "conn-1"))
+	})
+
+	t.Run("wallets pinned to a region", func(t *testing.T) {
+		cfg := config()
+
+		router, err := residency.New(mem.NewProvider(), lock.NewLocal(), &residency.Config{
+			Regions: map[string]storage.Provider{"eu": mem.NewProvider(), "us": mem.NewProvider()},
+			Tenants: map[string]string{"acme": "eu"},
+		})
+		require.NoError(t, err)
+
+		cfg.Tenancy.Residency = router
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-1", State: connection.StateNameCompleted, ThreadID: "thid-1",
+			MyDID: "did:router", TheirDID: "did:wallet1", Namespace: connection.MyNSPrefix,
+		}))
+
+		o.assignTenant("acme", "conn-1", "did:wallet1")
+		require.Equal(t, "eu", region(o, "conn-1"))
+
+		w := putRegion(o, "conn-1", `{"region":"us"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &WalletRegionResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, &WalletRegionResp{ConnectionID: "conn-1", Region: "us", Revision: 2}, resp)
+		require.Equal(t, `"2"`, w.Header().Get(etagHeader))
+		require.Equal(t, "us", router.Region("did:wallet1"))
+		require.Equal(t, "us", region(o, "conn-1"))
+
+		require.Equal(t, http.StatusOK, putRegion(o, "conn-1", `{}`).Code)
+		require.Empty(t, region(o, "conn-1"))
+
+		require.Equal(t, http.StatusBadRequest, putRegion(o, "conn-1", `{"region":"apac"}`).Code)
+		require.Equal(t, http.StatusBadRequest, putRegion(o, "conn-1", `{`).Code)
+		require.Equal(t, http.StatusNotFound, putRegion(o, "conn-2", `{"region":"eu"}`).Code)
+		require.Empty(t, region(o, "conn-2"))
+	})
+
+	t.Run("if-match revisions", func(t *testing.T) {
+		cfg := config()
+
+		router, err := residency.New(mem.NewProvider(), lock.NewLocal(), &residency.Config{
+			Regions: map[string]storage.Provider{"eu": mem.NewProvider(), "us": mem.NewProvider()},
+		})
+		require.NoError(t, err)
+
+		cfg.Tenancy.Residency = router
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-1", State: connection.StateNameCompleted, ThreadID: "thid-1",
+			MyDID: "did:router", TheirDID: "did:wallet1", Namespace: connection.MyNSPrefix,
+		}))
+
+		conditional := func(body, etag string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodPut, walletsPath+"/conn-1/region",
+				strings.NewReader(body)), map[string]string{"id": "conn-1"})
+			req.Header.Set(ifMatchHeader, etag)
+
+			o.residency.putWalletRegion(w, req)
+
+			return w
+		}
+
+		w := conditional(`{"region":"eu"}`, "*")
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = conditional(`{"region":"eu"}`, `"0"`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Equal(t, `"1"`, w.Header().Get(etagHeader))
+
+		// stale revision : the change of the other admin tool is not clobbered
+		w = conditional(`{"region":"us"}`, `"0"`)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+		require.Equal(t, "eu", region(o, "conn-1"))
+
+		w = conditional(`{"region":"us"}`, `W/"1"`)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = conditional(`{"region":"us"}`, `1`)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = conditional(`{"region":"us"}`, `"1"`)
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, "us", region(o, "conn-1"))
+
+		pin := o.residency.walletPin("conn-1")
+		require.Equal(t, &residency.Pin{Region: "us", Revision: 2}, pin)
+
+		w = conditional(`{}`, "*")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, `"0"`, w.Header().Get(etagHeader))
+		require.Nil(t, o.residency.walletPin("conn-1"))
+	})
+}
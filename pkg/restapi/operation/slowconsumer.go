@@ -0,0 +1,126 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+
+	"github.com/trustbloc/hub-router/pkg/slowconsumer"
+)
+
+const slowConsumerTopic = "slow-consumer"
+
+// ObserveWrite records the latency of a WebSocket write to the wallet with the given recipient keys.
+func (o *Operation) ObserveWrite(recipientKeys []string, latency time.Duration) {
+	connID, ok := o.recipientConnection(recipientKeys)
+	if !ok {
+		return
+	}
+
+	o.observeLatency(connID, slowconsumer.KindWebSocketWrite, latency)
+}
+
+// Degraded returns true if the wallet with the given recipient keys is a slow consumer to degrade.
+func (o *Operation) Degraded(recipientKeys []string) bool {
+	if !o.slowConsumers.Degrade() {
+		return false
+	}
+
+	connID, ok := o.recipientConnection(recipientKeys)
+
+	return ok && o.slowConsumers.IsSlow(connID)
+}
+
+// pickupDrained records the time the messages queued for the wallet waited to be picked up.
+func (o *Operation) pickupDrained(connID string, queued time.Duration) {
+	o.observeLatency(connID, slowconsumer.KindPickup, queued)
+}
+
+func (o *Operation) observeLatency(connID, kind string, latency time.Duration) {
+	if err := o.slowConsumers.Observe(connID, kind, latency); err != nil {
+		logger.Warnf("failed to record delivery latency : %s", err)
+	}
+}
+
+// indexRecipient maps the recipient keys of the wallet DID doc to the connection, to attribute the writes and the
+// sockets.
+func (o *Operation) indexRecipient(connID string, didDoc *did.Doc) {
+	if !o.indexesRecipients() || didDoc == nil {
+		return
+	}
+
+	dest, err := service.CreateDestination(didDoc)
+	if err != nil {
+		logger.Debugf("wallet recipient not indexed : connectionID=%s : %s", connID, err)
+
+		return
+	}
+
+	for _, key := range dest.RecipientKeys {
+		o.recipientConns.Store(key, connID)
+	}
+}
+
+// indexRecipients indexes the recipient keys of the wallets seen by the router before a restart.
+func (o *Operation) indexRecipients() {
+	recipients, err := o.queueRecipients()
+	if err != nil {
+		logger.Warnf("failed to index wallet recipients : %s", err)
+
+		return
+	}
+
+	for connID, theirDID := range recipients {
+		o.indexConnection(connID, theirDID)
+	}
+}
+
+// indexConnection maps the recipient keys of the wallet DID to the connection.
+func (o *Operation) indexConnection(connID, theirDID string) {
+	if !o.indexesRecipients() {
+		return
+	}
+
+	docResolution, err := o.vdriRegistry.Resolve(theirDID)
+	if err != nil {
+		logger.Debugf("wallet recipient not resolved : connectionID=%s : %s", connID, err)
+
+		return
+	}
+
+	o.indexRecipient(connID, docResolution.DIDDocument)
+}
+
+// indexesRecipients returns true if the recipient keys of the wallets are indexed, to detect the slow consumers or to
+// register the sockets.
+func (o *Operation) indexesRecipients() bool {
+	return o.slowConsumers != nil || o.sockets != nil
+}
+
+func (o *Operation) recipientConnection(recipientKeys []string) (string, bool) {
+	for _, key := range recipientKeys {
+		if val, ok := o.recipientConns.Load(key); ok {
+			connID, ok := val.(string)
+
+			return connID, ok
+		}
+	}
+
+	return "", false
+}
+
+// slowConsumerChanged notifies the slow consumer change to the webhooks without blocking the deliveries.
+func (o *Operation) slowConsumerChanged(s *slowconsumer.Status) {
+	go func() {
+		if err := o.webhook.Notify(slowConsumerTopic, s); err != nil {
+			logger.Warnf("failed to notify slow consumer change : %s", err)
+		}
+	}()
+}
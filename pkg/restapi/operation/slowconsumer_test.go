@@ -0,0 +1,196 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	mockvdri "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/history"
+	"github.com/trustbloc/hub-router/pkg/internal/mock/didexchange"
+	"github.com/trustbloc/hub-router/pkg/presence"
+	"github.com/trustbloc/hub-router/pkg/slowconsumer"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestSlowConsumers(t *testing.T) {
+	t.Run("slow websocket writes", func(t *testing.T) {
+		changes := make(chan *slowconsumer.Status, 1)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			msg := &struct {
+				Topic   string               `json:"topic"`
+				Message *slowconsumer.Status `json:"message"`
+			}{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(msg))
+
+			if msg.Topic == slowConsumerTopic {
+				changes <- msg.Message
+			}
+		}))
+		defer srv.Close()
+
+		wsOutbound := slowconsumer.NewOutbound(nil, 1)
+
+		cfg := config()
+		cfg.Webhook = webhook.New([]string{srv.URL}, nil)
+		cfg.Queues.SlowConsumers = &slowconsumer.Config{WriteThreshold: time.Second, Window: 1, Degrade: true}
+		cfg.Transports.WSOutbound = wsOutbound
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+		require.Nil(t, o.queue)
+
+		didDoc := mockdiddoc.GetMockDIDDoc(t)
+
+		dest, err := service.CreateDestination(didDoc)
+		require.NoError(t, err)
+
+		o.ObserveWrite(dest.RecipientKeys, time.Hour)
+		require.False(t, o.Degraded(dest.RecipientKeys))
+
+		o.connectionCreated(&correlation.Record{MsgID: "msg-1", MsgType: createConnReq}, "conn-1", didDoc, "",
+			history.ActorWallet)
+		require.NoError(t, o.presence.Seen("conn-1", presence.SourceWebSocket))
+		require.NoError(t, o.presence.Seen("conn-2", presence.SourceWebSocket))
+
+		o.ObserveWrite(dest.RecipientKeys, time.Hour)
+		require.True(t, o.Degraded(dest.RecipientKeys))
+
+		select {
+		case s := <-changes:
+			require.Equal(t, "conn-1", s.ConnectionID)
+			require.True(t, s.Slow)
+			require.Equal(t, slowconsumer.KindWebSocketWrite, s.Kind)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+
+		w := httptest.NewRecorder()
+		o.getWallets(w, httptest.NewRequest(http.MethodGet, walletsPath+"?slow=true", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &WalletsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Len(t, resp.Wallets, 1)
+		require.Equal(t, "conn-1", resp.Wallets[0].ConnectionID)
+		require.True(t, resp.Wallets[0].SlowConsumer.Slow)
+
+		w = httptest.NewRecorder()
+		o.getWallet(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, walletsPath+"/conn-2", nil),
+			map[string]string{"id": "conn-2"}))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		wallet := &Wallet{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), wallet))
+		require.Equal(t, presence.Online, wallet.Status)
+		require.Nil(t, wallet.SlowConsumer)
+	})
+
+	t.Run("slow pickup", func(t *testing.T) {
+		cfg := config()
+		cfg.Queues.SlowConsumers = &slowconsumer.Config{PickupThreshold: time.Nanosecond, Window: 1}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+		require.False(t, o.Degraded([]string{"key"}))
+
+		o.didExchange = &didexchange.MockClient{TheirDID: "did:wallet"}
+
+		queueMessages(t, o.pickup, "did:wallet", "key-1", 3)
+
+		require.NoError(t, o.presence.Seen("conn-1", presence.SourcePickup))
+		require.NoError(t, o.queue.Check())
+
+		_, err = o.pickup.Deliver("did:wallet", 3, func([]*messagepickup.Message) error { return nil })
+		require.NoError(t, err)
+		require.NoError(t, o.queue.Check())
+
+		s, err := o.slowConsumers.Get("conn-1")
+		require.NoError(t, err)
+		require.True(t, s.Slow)
+		require.Equal(t, slowconsumer.KindPickup, s.Kind)
+	})
+
+	t.Run("index connections", func(t *testing.T) {
+		didDoc := mockdiddoc.GetMockDIDDoc(t)
+
+		cfg := config()
+		cfg.Aries.(*mockprovider.Provider).VDRegistryValue = &mockvdri.MockVDRegistry{ResolveValue: didDoc}
+		cfg.Queues.SlowConsumers = &slowconsumer.Config{WriteThreshold: time.Second}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.didExchange = &didexchange.MockClient{TheirDID: didDoc.ID}
+
+		require.NoError(t, o.presence.Seen("conn-1", presence.SourceMediation))
+
+		o.indexRecipients()
+
+		dest, err := service.CreateDestination(didDoc)
+		require.NoError(t, err)
+
+		connID, ok := o.recipientConnection(dest.RecipientKeys)
+		require.True(t, ok)
+		require.Equal(t, "conn-1", connID)
+
+		o.vdriRegistry = &mockvdri.MockVDRegistry{ResolveErr: errors.New("resolve error")}
+		o.indexConnection("conn-2", "did:invalid")
+		o.indexRecipient("conn-2", nil)
+
+		o.presence, err = presence.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrQuery: errors.New("query error"),
+		}), time.Minute, nil)
+		require.NoError(t, err)
+
+		o.indexRecipients()
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		cfg := config()
+		cfg.Queues.SlowConsumers = &slowconsumer.Config{WriteThreshold: time.Second}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		require.NoError(t, o.presence.Seen("conn-1", presence.SourceMediation))
+
+		o.slowConsumers, err = slowconsumer.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), cfg.Queues.SlowConsumers, nil)
+		require.NoError(t, err)
+
+		o.pickupDrained("conn-1", time.Hour)
+
+		w := httptest.NewRecorder()
+		o.getWallets(w, httptest.NewRequest(http.MethodGet, walletsPath, nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "get error")
+
+		w = httptest.NewRecorder()
+		o.getWallet(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, walletsPath+"/conn-1", nil),
+			map[string]string{"id": "conn-1"}))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+}
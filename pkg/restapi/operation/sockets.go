@@ -0,0 +1,217 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+
+	"github.com/trustbloc/hub-router/pkg/inbound"
+	"github.com/trustbloc/hub-router/pkg/migration"
+	"github.com/trustbloc/hub-router/pkg/presence"
+)
+
+const socketSweepInterval = 30 * time.Second
+
+// LiveSocket model: the WebSocket held open to the router by the wallet on the connection, the messages forwarded to
+// the wallet are delivered on it as soon as they arrive instead of being queued for pickup.
+type LiveSocket struct {
+	ConnectionID string    `json:"connectionID"`
+	RecipientKey string    `json:"recipientKey"`
+	OpenedAt     time.Time `json:"openedAt"`
+	LastSeen     time.Time `json:"lastSeen"`
+	// Delivered is the number of queued messages delivered on the socket when it was opened.
+	Delivered int `json:"delivered"`
+}
+
+// socketRegistry tracks the live sockets per connection. The sockets closed by the wallets are swept once the
+// WebSocket outbound transport doesn't hold them anymore.
+type socketRegistry struct {
+	mutex     sync.RWMutex
+	sockets   map[string]*LiveSocket
+	outbound  transport.OutboundTransport
+	forwarder *migration.Forwarder
+}
+
+// open registers the socket of the wallet on the connection, and returns true if it wasn't registered yet.
+func (r *socketRegistry) open(connID, recipientKey string, now time.Time) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if s, ok := r.sockets[connID]; ok && s.RecipientKey == recipientKey {
+		s.LastSeen = now
+
+		return false
+	}
+
+	r.sockets[connID] = &LiveSocket{ConnectionID: connID, RecipientKey: recipientKey, OpenedAt: now, LastSeen: now}
+
+	return true
+}
+
+// delivered records the queued messages delivered on the socket of the connection.
+func (r *socketRegistry) delivered(connID string, count int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if s, ok := r.sockets[connID]; ok {
+		s.Delivered += count
+	}
+}
+
+// get returns the live socket of the connection, nil if none.
+func (r *socketRegistry) get(connID string) *LiveSocket {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	s, ok := r.sockets[connID]
+	if !ok {
+		return nil
+	}
+
+	live := *s
+
+	return &live
+}
+
+// sweep removes the sockets not held by the WebSocket transport anymore; the transport pings them.
+func (r *socketRegistry) sweep() {
+	r.mutex.RLock()
+
+	sockets := make([]*LiveSocket, 0, len(r.sockets))
+
+	for _, s := range r.sockets {
+		sockets = append(sockets, s)
+	}
+
+	r.mutex.RUnlock()
+
+	for _, s := range sockets {
+		if r.outbound.AcceptRecipient([]string{s.RecipientKey}) {
+			continue
+		}
+
+		r.mutex.Lock()
+
+		// the socket may have been reopened meanwhile
+		if r.sockets[s.ConnectionID] == s {
+			delete(r.sockets, s.ConnectionID)
+		}
+
+		r.mutex.Unlock()
+	}
+}
+
+func (r *socketRegistry) start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		for range ticker.C {
+			r.sweep()
+		}
+	}()
+}
+
+// socketOutbound delivers the queued messages on the sockets of the wallets, through the WebSocket transport.
+type socketOutbound struct {
+	transport.OutboundTransport
+}
+
+func (s *socketOutbound) Forward(msg interface{}, des *service.Destination) error {
+	data, ok := msg.(json.RawMessage)
+	if !ok {
+		return errors.New("unexpected queued message")
+	}
+
+	_, err := s.Send(data, des)
+
+	return err
+}
+
+// initSockets initializes the registry of the sockets held open by the wallets, if the WebSocket transports are
+// hooked.
+func (o *Operation) initSockets(config *Config) {
+	if config.Transports.WSOutbound == nil || !observesSockets(config.Transports.Inbound) {
+		return
+	}
+
+	forwarder := migration.NewForwarder(o.pickup, &socketOutbound{OutboundTransport: config.Transports.WSOutbound}, nil,
+		config.Aries.KMS())
+
+	o.sockets = &socketRegistry{
+		sockets: map[string]*LiveSocket{}, outbound: config.Transports.WSOutbound, forwarder: forwarder,
+	}
+}
+
+// observesSockets returns true if an inbound transport observes the sockets held open by the wallets.
+func observesSockets(transports []*inbound.Middleware) bool {
+	for _, m := range transports {
+		if m.Has(inbound.ObserveSocket) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ObserveSocket registers the socket held open by the wallet with the given sender key, and delivers the messages
+// queued for the wallet on the socket once opened.
+func (o *Operation) ObserveSocket(senderKey string) {
+	connID, ok := o.recipientConnection([]string{senderKey})
+	if !ok {
+		return
+	}
+
+	o.seen(connID, presence.SourceWebSocket)
+
+	if o.sockets.open(connID, senderKey, time.Now().UTC()) {
+		o.deliverQueued(connID, senderKey)
+	}
+}
+
+// deliverQueued delivers the messages queued for the wallet on its socket, before the message that opened the socket
+// is handled : a pickup on the socket doesn't deliver them twice. The messages that fail to be delivered are kept,
+// and picked up by the wallet as usual.
+func (o *Operation) deliverQueued(connID, recipientKey string) {
+	conn, err := o.didExchange.GetConnection(connID)
+	if err != nil {
+		logger.Warnf("failed to deliver the messages queued for connection id=[%s] : %s", connID, err)
+
+		return
+	}
+
+	delivered, err := o.sockets.forwarder.Drain(&migration.Handover{
+		Target:       migration.Target{RecipientKey: recipientKey},
+		ConnectionID: connID,
+		WalletDID:    conn.TheirDID,
+	})
+	if err != nil {
+		logger.Warnf("failed to deliver the messages queued for connection id=[%s] : %s", connID, err)
+
+		return
+	}
+
+	if delivered > 0 {
+		o.sockets.delivered(connID, delivered)
+
+		logger.Infof("delivered %d queued messages on the socket of connection id=[%s]", delivered, connID)
+	}
+}
+
+// liveSocket returns the live socket of the wallet on the connection, nil if none.
+func (o *Operation) liveSocket(connID string) *LiveSocket {
+	if o.sockets == nil {
+		return nil
+	}
+
+	return o.sockets.get(connID)
+}
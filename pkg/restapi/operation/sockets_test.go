@@ -0,0 +1,178 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/inbound"
+	"github.com/trustbloc/hub-router/pkg/internal/mock/didexchange"
+	"github.com/trustbloc/hub-router/pkg/presence"
+	"github.com/trustbloc/hub-router/pkg/slowconsumer"
+)
+
+func TestSockets(t *testing.T) {
+	newOperation := func(t *testing.T, ws *mockWSOutbound) (*Operation, storage.Store, string) {
+		t.Helper()
+
+		cfg := config()
+		cfg.Transports.WSOutbound = slowconsumer.NewOutbound(ws, 1)
+		cfg.Transports.Inbound = []*inbound.Middleware{inbound.NewMiddleware(nil, inbound.ObserveSocket)}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+		require.NotNil(t, o.sockets)
+
+		queueMessages(t, o.pickup, "did:wallet", "key-1", 2)
+
+		mailbox, err := cfg.Storage.Persistent.OpenStore(messagepickup.Namespace)
+		require.NoError(t, err)
+
+		o.didExchange = &didexchange.MockClient{TheirDID: "did:wallet"}
+
+		didDoc := mockdiddoc.GetMockDIDDoc(t)
+		o.indexRecipient("conn-1", didDoc)
+
+		dest, err := service.CreateDestination(didDoc)
+		require.NoError(t, err)
+
+		return o, mailbox, dest.RecipientKeys[0]
+	}
+
+	getWallets := func(t *testing.T, o *Operation) []*Wallet {
+		t.Helper()
+
+		w := httptest.NewRecorder()
+		o.getWallets(w, httptest.NewRequest(http.MethodGet, walletsPath+"?live=true", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &WalletsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+
+		return resp.Wallets
+	}
+
+	t.Run("queued messages delivered on open", func(t *testing.T) {
+		ws := &mockWSOutbound{accept: true}
+
+		o, _, key := newOperation(t, ws)
+
+		o.ObserveSocket("did:key:unknown")
+		require.Nil(t, o.liveSocket("conn-1"))
+
+		o.ObserveSocket(key)
+		require.Len(t, ws.sent, 2)
+		require.Equal(t, []string{key}, ws.destinations[0].RecipientKeys)
+
+		depth, err := o.pickup.Depth("did:wallet")
+		require.NoError(t, err)
+		require.Zero(t, depth)
+
+		s := o.liveSocket("conn-1")
+		require.Equal(t, key, s.RecipientKey)
+		require.Equal(t, 2, s.Delivered)
+
+		record, err := o.presence.Get("conn-1")
+		require.NoError(t, err)
+		require.Equal(t, presence.SourceWebSocket, record.Source)
+
+		o.ObserveSocket(key)
+		require.Len(t, ws.sent, 2)
+		require.Equal(t, s.OpenedAt, o.liveSocket("conn-1").OpenedAt)
+
+		wallets := getWallets(t, o)
+		require.Len(t, wallets, 1)
+		require.Equal(t, key, wallets[0].Socket.RecipientKey)
+
+		ws.accept = false
+		o.sockets.sweep()
+		require.Nil(t, o.liveSocket("conn-1"))
+		require.Empty(t, getWallets(t, o))
+	})
+
+	t.Run("delivery failure", func(t *testing.T) {
+		o, _, key := newOperation(t, &mockWSOutbound{sendErr: errors.New("send error")})
+
+		o.ObserveSocket(key)
+		require.Zero(t, o.liveSocket("conn-1").Delivered)
+
+		depth, err := o.pickup.Depth("did:wallet")
+		require.NoError(t, err)
+		require.Equal(t, 2, depth)
+
+		o.sockets.delivered("conn-2", 1)
+		require.Nil(t, o.liveSocket("conn-2"))
+	})
+
+	t.Run("connection not found", func(t *testing.T) {
+		ws := &mockWSOutbound{}
+
+		o, _, key := newOperation(t, ws)
+		o.didExchange = &didexchange.MockClient{GetConnectionErr: errors.New("not found")}
+
+		o.ObserveSocket(key)
+		require.NotNil(t, o.liveSocket("conn-1"))
+		require.Empty(t, ws.sent)
+	})
+
+	t.Run("mailbox error", func(t *testing.T) {
+		ws := &mockWSOutbound{}
+
+		o, mailbox, key := newOperation(t, ws)
+		require.NoError(t, mailbox.Put("did:wallet#key-1", []byte(`{`)))
+
+		o.ObserveSocket(key)
+		require.NotNil(t, o.liveSocket("conn-1"))
+		require.Empty(t, ws.sent)
+	})
+
+	t.Run("unexpected queued message", func(t *testing.T) {
+		err := (&socketOutbound{}).Forward(struct{}{}, &service.Destination{})
+		require.EqualError(t, err, "unexpected queued message")
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+		require.Nil(t, o.sockets)
+		require.Nil(t, o.liveSocket("conn-1"))
+	})
+}
+
+type mockWSOutbound struct {
+	transport.OutboundTransport
+	accept       bool
+	sendErr      error
+	sent         [][]byte
+	destinations []*service.Destination
+}
+
+func (m *mockWSOutbound) Send(data []byte, destination *service.Destination) (string, error) {
+	if m.sendErr != nil {
+		return "", m.sendErr
+	}
+
+	m.sent = append(m.sent, data)
+	m.destinations = append(m.destinations, destination)
+
+	return "", nil
+}
+
+func (m *mockWSOutbound) AcceptRecipient([]string) bool {
+	return m.accept
+}
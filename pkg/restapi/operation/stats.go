@@ -0,0 +1,113 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/stats"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+// API endpoints.
+const (
+	statsHistoryPath = "/stats/history"
+)
+
+const (
+	statsPruneInterval = time.Hour
+	defaultStatsRange  = 24 * time.Hour
+	day                = 24 * time.Hour
+)
+
+// StatsHistoryResp model.
+type StatsHistoryResp struct {
+	From    time.Time       `json:"from"`
+	To      time.Time       `json:"to"`
+	Rollups []*stats.Rollup `json:"rollups"`
+}
+
+func (o *Operation) getStatsHistory(rw http.ResponseWriter, req *http.Request) {
+	period := defaultStatsRange
+
+	if val := req.URL.Query().Get("range"); val != "" {
+		var err error
+
+		period, err = parseRange(val)
+		if err != nil {
+			httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), statsHistoryPath, logger)
+
+			return
+		}
+	}
+
+	to := time.Now().UTC()
+	from := to.Add(-period)
+
+	rollups, err := o.stats.History(tenant.FromContext(req.Context()), from, time.Time{})
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get stats history - err=%s", err.Error()), statsHistoryPath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, &StatsHistoryResp{From: from, To: to, Rollups: rollups}, statsHistoryPath, logger)
+}
+
+// countEvent updates the stats rollups with the connection and forward events.
+func (o *Operation) countEvent(e events.Event) {
+	switch event := e.(type) {
+	case *events.ConnectionEvent:
+		if event.State == events.ConnectionCreated {
+			o.countStat(o.tenantOf(event.ConnectionID), stats.ConnectionsCreated)
+		}
+	case *events.ForwardEvent:
+		o.countStat(o.tenantOf(event.ConnectionID), stats.Forwards)
+	}
+}
+
+// countStat increments the counter in the global rollup, and in the rollup of the tenant if any. The errors are
+// recorded for the error burst incidents.
+func (o *Operation) countStat(tenantID, counter string) {
+	if err := o.stats.Incr(tenantID, counter); err != nil {
+		logger.Warnf("failed to update stats counter=[%s] : %s", counter, err)
+	}
+
+	if counter == stats.Errors {
+		o.reporting.recordError()
+	}
+}
+
+// parseRange parses the stats range, either a number of days (eg: 7d) or a duration (eg: 12h).
+func parseRange(val string) (time.Duration, error) {
+	var (
+		period time.Duration
+		err    error
+	)
+
+	if strings.HasSuffix(val, "d") {
+		var days int
+
+		days, err = strconv.Atoi(strings.TrimSuffix(val, "d"))
+		period = time.Duration(days) * day
+	} else {
+		period, err = time.ParseDuration(val)
+	}
+
+	if err != nil || period <= 0 {
+		return 0, fmt.Errorf("invalid range '%s', expected a number of days (eg: 7d) or a duration (eg: 12h)", val)
+	}
+
+	return period, nil
+}
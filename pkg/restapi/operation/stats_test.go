@@ -0,0 +1,94 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/stats"
+)
+
+func TestGetStatsHistory(t *testing.T) {
+	t.Run("counters", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.events.Publish(&events.ConnectionEvent{State: events.ConnectionCreated})
+		o.events.Publish(&events.ConnectionEvent{State: events.ConnectionCompleted})
+		o.events.Publish(&events.ForwardEvent{})
+		o.events.Publish(&events.ForwardEvent{})
+		o.countStat("", stats.Errors)
+
+		require.NoError(t, o.stats.Add("", time.Now().Add(-72*time.Hour), stats.Forwards, 1))
+
+		resp := statsHistory(t, o, "")
+		require.Len(t, resp.Rollups, 1)
+		require.Equal(t, map[string]int{
+			stats.ConnectionsCreated: 1, stats.Forwards: 2, stats.Errors: 1,
+		}, resp.Rollups[0].Counters)
+		require.Equal(t, 24*time.Hour, resp.To.Sub(resp.From))
+
+		resp = statsHistory(t, o, "?range=7d")
+		require.Len(t, resp.Rollups, 2)
+
+		resp = statsHistory(t, o, "?range=1h")
+		require.Len(t, resp.Rollups, 1)
+	})
+
+	t.Run("invalid range", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		for _, val := range []string{"7", "xd", "-1d", "0h"} {
+			w := httptest.NewRecorder()
+			o.getStatsHistory(w, httptest.NewRequest(http.MethodGet, statsHistoryPath+"?range="+val, nil))
+			require.Equal(t, http.StatusBadRequest, w.Code)
+			require.Contains(t, w.Body.String(), "invalid range")
+		}
+	})
+
+	t.Run("store error", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.stats, err = stats.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}), 0)
+		require.NoError(t, err)
+
+		o.countStat("", stats.Errors)
+
+		w := httptest.NewRecorder()
+		o.getStatsHistory(w, httptest.NewRequest(http.MethodGet, statsHistoryPath, nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "query error")
+	})
+}
+
+func statsHistory(t *testing.T, o *Operation, query string) *StatsHistoryResp {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	o.getStatsHistory(w, httptest.NewRequest(http.MethodGet, statsHistoryPath+query, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	resp := &StatsHistoryResp{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+
+	return resp
+}
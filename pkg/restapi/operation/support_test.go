@@ -7,9 +7,13 @@ SPDX-License-Identifier: Apache-2.0
 package operation
 
 import (
+	"encoding/json"
 	"fmt"
+	"testing"
 
 	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
 	"github.com/hyperledger/aries-framework-go/pkg/didcomm/messaging/msghandler"
 	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/didexchange"
 	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
@@ -21,9 +25,11 @@ import (
 	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
 	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
 	mockvdri "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
+	"github.com/stretchr/testify/require"
 
 	"github.com/trustbloc/hub-router/pkg/aries"
 	mockoutofband "github.com/trustbloc/hub-router/pkg/internal/mock/outofband"
+	"github.com/trustbloc/hub-router/pkg/pickup"
 )
 
 func getAriesCtx() aries.Ctx {
@@ -53,6 +59,29 @@ func config() *Config {
 	}
 }
 
+// queueMessages queues the messages for the recipient key of the wallet, as the pickup service does.
+func queueMessages(t *testing.T, q *pickup.Queue, theirDID, recipientKey string, count int) {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		require.NoError(t, q.Add(theirDID, recipientKey, &model.Envelope{CipherText: fmt.Sprintf("msg-%d", i)}))
+	}
+}
+
+// receivedMsg returns the message as the router receives it, parsed from its JSON : unlike
+// service.NewDIDCommMsgMap, it keeps the raw JSON fields (e.g. the DID doc of a create-conn-req) JSON objects.
+func receivedMsg(t *testing.T, v interface{}) service.DIDCommMsgMap {
+	t.Helper()
+
+	msgBytes, err := json.Marshal(v)
+	require.NoError(t, err)
+
+	msg, err := service.ParseDIDCommMsgMap(msgBytes)
+	require.NoError(t, err)
+
+	return msg
+}
+
 type didexchangeEvent struct {
 	connID    string
 	invID     string
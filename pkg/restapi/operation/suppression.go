@@ -0,0 +1,86 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+
+	"github.com/trustbloc/hub-router/pkg/backpressure"
+	"github.com/trustbloc/hub-router/pkg/preparse"
+)
+
+// suppressionSweepInterval is the interval the delivered messages older than the suppression window are deleted at.
+const suppressionSweepInterval = time.Minute
+
+// suppressDuplicate returns an error wrapping backpressure.ErrSuppressed if the envelope is a forward already
+// delivered to the wallet within the suppression window : the duplicate is acknowledged to the sender, and dropped.
+func (o *Operation) suppressDuplicate(header *preparse.Header) error {
+	if o.suppression == nil {
+		return nil
+	}
+
+	msgID, theirDID, ok := o.forwardRecipient(header)
+	if !ok || msgID == "" {
+		return nil
+	}
+
+	duplicate, err := o.suppression.Duplicate(theirDID, msgID)
+	if err != nil {
+		logger.Warnf("failed to check the delivered messages : %s", err)
+
+		return nil
+	}
+
+	if !duplicate {
+		return nil
+	}
+
+	logger.Infof("duplicate forward suppressed : msgID=%s", msgID)
+
+	return fmt.Errorf("%w : duplicate forward %s", backpressure.ErrSuppressed, msgID)
+}
+
+// EnvelopeHandled records the forward delivered to the wallet, so that its duplicates are suppressed, and observes the
+// envelope for the metrics, the anomaly detection and the digests.
+func (o *Operation) EnvelopeHandled(envelope *transport.Envelope, header *preparse.Header) {
+	msgID, theirDID, ok := o.forwardRecipient(header)
+
+	o.reporting.observeEnvelope(envelope, theirDID)
+
+	if !ok {
+		return
+	}
+
+	o.metrics.observeForward(envelope)
+	o.reporting.tallyForward(envelope)
+
+	if o.suppression == nil || msgID == "" {
+		return
+	}
+
+	if err := o.suppression.Delivered(theirDID, msgID); err != nil {
+		logger.Warnf("failed to record the delivered message : %s", err)
+	}
+}
+
+// forwardRecipient returns the ID of the forward message, empty if none, and the DID of the wallet it is addressed
+// to, false if the envelope isn't a forward routed by the router.
+func (o *Operation) forwardRecipient(forward *preparse.Header) (string, string, bool) {
+	if !forward.IsForward() {
+		return "", "", false
+	}
+
+	theirDID, err := o.routes.DID(string(forward.To))
+	if err != nil {
+		return "", "", false
+	}
+
+	return string(forward.ID), string(theirDID), true
+}
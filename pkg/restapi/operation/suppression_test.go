@@ -0,0 +1,145 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/backpressure"
+	"github.com/trustbloc/hub-router/pkg/preparse"
+	"github.com/trustbloc/hub-router/pkg/routes"
+	"github.com/trustbloc/hub-router/pkg/suppression"
+)
+
+func TestSuppression(t *testing.T) {
+	forward := &transport.Envelope{
+		Message: []byte(`{"@id":"msg-1","@type":"https://didcomm.org/routing/1.0/forward","to":"key-1","msg":{}}`),
+	}
+
+	newOperation := func(t *testing.T) *Operation {
+		t.Helper()
+
+		ariesStorage := mem.NewProvider()
+
+		mediated, err := routes.Open(ariesStorage)
+		require.NoError(t, err)
+		require.NoError(t, mediated.Route("key-1", "did:wallet"))
+
+		cfg := config()
+		cfg.Aries.(*mockprovider.Provider).StorageProviderValue = ariesStorage
+		cfg.Admission.SuppressionWindow = time.Minute
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		return o
+	}
+
+	t.Run("duplicate forward suppressed", func(t *testing.T) {
+		o := newOperation(t)
+
+		require.NoError(t, o.gateForward(withHeader(forward)))
+
+		o.EnvelopeHandled(withHeader(forward))
+
+		err := o.gateForward(withHeader(forward))
+		require.True(t, errors.Is(err, backpressure.ErrSuppressed))
+
+		// not a forward, or not routed by the router
+		for _, msg := range []string{
+			`{"@id":"msg-1","@type":"https://didcomm.org/trust_ping/1.0/ping"}`,
+			`{"@id":"msg-1","@type":"https://didcomm.org/routing/1.0/forward","to":"key-2","msg":{}}`,
+		} {
+			envelope := &transport.Envelope{Message: []byte(msg)}
+
+			o.EnvelopeHandled(withHeader(envelope))
+			require.NoError(t, o.gateForward(withHeader(envelope)))
+		}
+
+		w := httptest.NewRecorder()
+		o.diagnostics.getDiagnostics(w, httptest.NewRequest(http.MethodGet, diagnosticsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &DiagnosticsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, &suppression.Stats{Window: 60, Suppressed: 1}, resp.Suppression)
+	})
+
+	t.Run("gate", func(t *testing.T) {
+		o := newOperation(t)
+
+		handled := 0
+		handlerErr := errors.New("handler error")
+
+		_, header := withHeader(forward)
+
+		// a forward failed is not recorded
+		err := o.gateEnvelope(forward, header, func(*transport.Envelope) error {
+			return handlerErr
+		})
+		require.ErrorIs(t, err, handlerErr)
+
+		next := func(*transport.Envelope) error {
+			handled++
+
+			return nil
+		}
+
+		require.NoError(t, o.gateEnvelope(forward, header, next))
+		require.Equal(t, 1, handled)
+
+		// the duplicate is acknowledged without being handed on
+		require.NoError(t, o.gateEnvelope(forward, header, next))
+		require.Equal(t, 1, handled)
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		o := newOperation(t)
+
+		var err error
+
+		o.suppression, err = suppression.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+			ErrPut: errors.New("put error"),
+		}), time.Minute)
+		require.NoError(t, err)
+
+		o.EnvelopeHandled(withHeader(forward))
+		require.NoError(t, o.gateForward(withHeader(forward)))
+	})
+
+	t.Run("not suppressed", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.EnvelopeHandled(withHeader(forward))
+		require.NoError(t, o.gateForward(withHeader(forward)))
+		require.NoError(t, o.gateForward(withHeader(forward)))
+	})
+}
+
+// withHeader returns the envelope with the header of its message, as the inbound chains hand them to their stages.
+func withHeader(envelope *transport.Envelope) (*transport.Envelope, *preparse.Header) {
+	header, err := preparse.Parse(envelope.Message)
+	if err != nil {
+		return envelope, &preparse.Header{}
+	}
+
+	return envelope, header
+}
@@ -0,0 +1,137 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+const bearerPrefix = "Bearer "
+
+// defaultLabel of the invitations issued without tenant branding, unless the router label is configured.
+const defaultLabel = "hub-router"
+
+// Access levels of the REST endpoints.
+const (
+	accessOperator = iota
+	accessTenant
+	accessPublic
+)
+
+// Authenticate is the REST API middleware checking the API key of the requests (Authorization: Bearer <key>), and
+// scoping their context to the tenant of the key. Without API keys configured, all the requests are from the
+// operator.
+func (o *Operation) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		access := routeAccess(req)
+
+		if !o.tenancy.apiKeys.Enabled() || access == accessPublic {
+			next.ServeHTTP(rw, req)
+
+			return
+		}
+
+		auth := req.Header.Get("Authorization")
+
+		tenantID, ok := o.tenancy.resolveAPIKey(strings.TrimPrefix(auth, bearerPrefix))
+		if !ok || !strings.HasPrefix(auth, bearerPrefix) {
+			httputil.WriteErrorResponseWithLog(rw, http.StatusUnauthorized, "invalid API key", req.URL.Path, logger)
+
+			return
+		}
+
+		if tenantID != "" && access == accessOperator {
+			httputil.WriteErrorResponseWithLog(rw, http.StatusForbidden, "operator API key required", req.URL.Path,
+				logger)
+
+			return
+		}
+
+		next.ServeHTTP(rw, req.WithContext(tenant.WithTenant(req.Context(), tenantID)))
+	})
+}
+
+// tenantOf returns the tenant the invitation, connection or DID is assigned to, empty for the operator.
+func (o *Operation) tenantOf(id string) string {
+	tenantID, err := o.tenants.Get(id)
+	if err != nil {
+		logger.Warnf("failed to get tenant of id=[%s] : %s", id, err)
+	}
+
+	return tenantID
+}
+
+// assignTenant assigns the invitations, connections or DIDs to the tenant.
+func (o *Operation) assignTenant(tenantID string, ids ...string) {
+	if err := o.tenants.Assign(tenantID, ids...); err != nil {
+		logger.Warnf("failed to assign tenant=[%s] : %s", tenantID, err)
+	}
+
+	o.residency.assign(tenantID, ids...)
+}
+
+// msgTenant returns the tenant of the connection the message was received on.
+func (o *Operation) msgTenant(msg service.DIDCommMsg) string {
+	inbound, ok := msg.(*aries.InboundMsg)
+	if !ok {
+		return ""
+	}
+
+	return o.tenantOf(inbound.MyDID)
+}
+
+// visible returns true if the connection is visible to the tenant, all the connections are visible to the operator.
+func (o *Operation) visible(tenantID, connID string) bool {
+	return tenantID == "" || o.tenantOf(connID) == tenantID
+}
+
+// branding returns the invitation branding of the tenant, the router branding if the tenant has none.
+func (o *Operation) branding(tenantID string) *tenant.Branding {
+	if b, ok := o.brandings[tenantID]; ok {
+		return b
+	}
+
+	return &tenant.Branding{Label: o.label}
+}
+
+func routeAccess(req *http.Request) int {
+	route := mux.CurrentRoute(req)
+	if route == nil {
+		return accessOperator
+	}
+
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return accessOperator
+	}
+
+	return endpointAccess(path)
+}
+
+// endpointAccess returns the access level of the endpoint : the tenants access the invitation, wallets, consents,
+// history, connection webhook and stats endpoints, scoped to their wallets, and upload the attachments. The health
+// check, the readiness and the event schemas are public, as are the attachment contents, authorized by the token of
+// their URL. The other endpoints are restricted to the operator.
+func endpointAccess(path string) int {
+	switch path {
+	case healthCheckPath, readinessPath, eventSchemasPath, eventSchemaPath, attachmentContentPath:
+		return accessPublic
+	case invitationPath, walletsPath, walletPath, statsHistoryPath, statsExportPath, exportJobPath, attachmentsPath,
+		attachmentPath, connectionConsentsPath, connectionHistoryPath, connectionWebhookPath:
+		return accessTenant
+	default:
+		return accessOperator
+	}
+}
@@ -0,0 +1,324 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	didexdsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/didexchange"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/internal/mock/didexchange"
+	"github.com/trustbloc/hub-router/pkg/internal/mock/messenger"
+	mockoutofband "github.com/trustbloc/hub-router/pkg/internal/mock/outofband"
+	"github.com/trustbloc/hub-router/pkg/presence"
+	"github.com/trustbloc/hub-router/pkg/stats"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+func TestAuthenticate(t *testing.T) {
+	cfg := config()
+	cfg.Tenancy.APIKeys = tenant.NewKeys("operator-key", map[string]string{"tenant-1": "tenant-key"})
+
+	o, err := New(cfg)
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+
+	for _, h := range o.GetRESTHandlers() {
+		router.HandleFunc(h.Path(), h.Handle()).Methods(h.Method())
+	}
+
+	router.HandleFunc("/tenant", func(rw http.ResponseWriter, req *http.Request) {
+		_, err := rw.Write([]byte(tenant.FromContext(req.Context())))
+		require.NoError(t, err)
+	})
+
+	router.Use(o.Authenticate)
+
+	serve := func(path, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		return w
+	}
+
+	t.Run("public endpoint", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, serve(healthCheckPath, "").Code)
+	})
+
+	t.Run("invalid api key", func(t *testing.T) {
+		for _, auth := range []string{"", "Bearer invalid", "operator-key", "Bearer "} {
+			w := serve(statsHistoryPath, auth)
+			require.Equal(t, http.StatusUnauthorized, w.Code)
+			require.Contains(t, w.Body.String(), "invalid API key")
+		}
+	})
+
+	t.Run("tenant endpoint", func(t *testing.T) {
+		require.Equal(t, http.StatusOK, serve(statsHistoryPath, "Bearer tenant-key").Code)
+		require.Equal(t, http.StatusOK, serve(statsHistoryPath, "Bearer operator-key").Code)
+	})
+
+	t.Run("api key issued by the router", func(t *testing.T) {
+		_, apiKey, _, err := o.tenancy.registry.Put("tenant-2", tenant.StateActive, true)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, serve(statsHistoryPath, "Bearer "+apiKey).Code)
+		require.Equal(t, http.StatusForbidden, serve(tenantsPath, "Bearer "+apiKey).Code)
+	})
+
+	t.Run("operator endpoint", func(t *testing.T) {
+		w := serve("/tenant", "Bearer tenant-key")
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Contains(t, w.Body.String(), "operator API key required")
+
+		w = serve("/tenant", "Bearer operator-key")
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Empty(t, w.Body.String())
+	})
+
+	t.Run("no api keys", func(t *testing.T) {
+		o.tenancy.apiKeys = nil
+		defer func() { o.tenancy.apiKeys = cfg.Tenancy.APIKeys }()
+
+		require.Equal(t, http.StatusOK, serve(diagnosticsPath, "").Code)
+	})
+
+	t.Run("no route", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		o.Authenticate(http.NotFoundHandler()).ServeHTTP(w,
+			withTenantKey(httptest.NewRequest(http.MethodGet, "/", nil), "tenant-key"))
+		require.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
+
+func TestTenantAttribution(t *testing.T) {
+	o, err := New(config())
+	require.NoError(t, err)
+
+	o.oob = &mockoutofband.MockClient{InvitationID: "inv-1"}
+	o.didExchange = &didexchange.MockClient{MyDID: "did:router", InvitationID: "inv-1"}
+	o.messenger = &messenger.MockMessenger{}
+
+	w := httptest.NewRecorder()
+	o.generateInvitation(w, withTenant(httptest.NewRequest(http.MethodGet, invitationPath, nil), "tenant-1"))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "tenant-1", o.tenantOf("inv-1"))
+
+	err = o.hanlDIDExStateMsg(service.StateMsg{
+		Type:         service.PostState,
+		ProtocolName: didexdsvc.DIDExchange,
+		StateID:      didexdsvc.StateIDCompleted,
+		Properties:   &didexchangeEvent{connID: "conn-1"},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "tenant-1", o.tenantOf("conn-1"))
+	require.Equal(t, "tenant-1", o.tenantOf("did:router"))
+
+	didDocBytes, err := mockdiddoc.GetMockDIDDoc(t).JSONBytes()
+	require.NoError(t, err)
+
+	resp, err := o.handleCreateConnReq(&aries.InboundMsg{
+		DIDCommMsg: receivedMsg(t, CreateConnReq{
+			ID:   "msg-1",
+			Type: createConnReq,
+			Data: &CreateConnReqData{DIDDoc: json.RawMessage(didDocBytes)},
+		}),
+		MyDID: "did:router",
+	})
+	require.NoError(t, err)
+
+	connResp := &CreateConnResp{}
+	require.NoError(t, resp.Decode(connResp))
+	require.Equal(t, "tenant-1", o.tenantOf(connResp.Data.ConnectionID))
+
+	require.Eventually(t, func() bool {
+		rollups, err := o.stats.History("tenant-1", time.Time{}, time.Time{})
+
+		return err == nil && len(rollups) == 1 && rollups[0].Counters[stats.ConnectionsCreated] == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	entries, err := o.auditLog.QueryTenant("tenant-1", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.Equal(t, audit.InvitationCreated, entries[0].Type)
+	require.Equal(t, audit.ConnectionCreated, entries[1].Type)
+
+	require.Empty(t, o.msgTenant(service.DIDCommMsgMap{"@type": createConnReq}))
+}
+
+func TestTenantScope(t *testing.T) {
+	t.Run("stats history", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.countStat("tenant-1", stats.Forwards)
+		o.countStat("tenant-2", stats.Forwards)
+
+		resp := statsHistory(t, o, "")
+		require.Len(t, resp.Rollups, 1)
+		require.Equal(t, 2, resp.Rollups[0].Counters[stats.Forwards])
+
+		w := httptest.NewRecorder()
+		o.getStatsHistory(w, withTenant(httptest.NewRequest(http.MethodGet, statsHistoryPath, nil), "tenant-1"))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp = &StatsHistoryResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Len(t, resp.Rollups, 1)
+		require.Equal(t, 1, resp.Rollups[0].Counters[stats.Forwards])
+		require.Equal(t, "tenant-1", resp.Rollups[0].Tenant)
+	})
+
+	t.Run("stats export", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.assignTenant("tenant-1", "conn-1")
+		o.recordAudit(&audit.Entry{Type: audit.ConnectionCreated, ConnectionID: "conn-1"})
+		o.recordAudit(&audit.Entry{Type: audit.ConnectionCreated, ConnectionID: "conn-2"})
+
+		w := httptest.NewRecorder()
+		o.export.exportStats(w, withTenant(httptest.NewRequest(http.MethodGet, statsExportPath, nil), "tenant-1"))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), audit.ConnectionCreated+",1")
+
+		w = httptest.NewRecorder()
+		o.export.exportStats(w, httptest.NewRequest(http.MethodGet, statsExportPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Contains(t, w.Body.String(), audit.ConnectionCreated+",2")
+	})
+
+	t.Run("export job", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.export.exportStats(w, withTenant(httptest.NewRequest(http.MethodGet, statsExportPath+"?async=true", nil),
+			"tenant-1"))
+		require.Equal(t, http.StatusAccepted, w.Code)
+
+		job := &ExportJobResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), job))
+
+		getJob := func(tenantID string) int {
+			req := mux.SetURLVars(httptest.NewRequest(http.MethodGet, "/export/jobs/"+job.JobID, nil),
+				map[string]string{"id": job.JobID})
+
+			w := httptest.NewRecorder()
+			o.export.getExportJob(w, withTenant(req, tenantID))
+
+			return w.Code
+		}
+
+		require.Equal(t, http.StatusOK, getJob("tenant-1"))
+		require.Equal(t, http.StatusOK, getJob(""))
+		require.Equal(t, http.StatusNotFound, getJob("tenant-2"))
+	})
+
+	t.Run("wallets", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.assignTenant("tenant-1", "conn-1")
+		o.seen("conn-1", presence.SourceMediation)
+		o.seen("conn-2", presence.SourceMediation)
+
+		w := httptest.NewRecorder()
+		o.getWallets(w, withTenant(httptest.NewRequest(http.MethodGet, walletsPath, nil), "tenant-1"))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &WalletsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Len(t, resp.Wallets, 1)
+		require.Equal(t, "conn-1", resp.Wallets[0].ConnectionID)
+
+		w = httptest.NewRecorder()
+		o.getWallet(w, withTenant(mux.SetURLVars(httptest.NewRequest(http.MethodGet, walletsPath+"/conn-2", nil),
+			map[string]string{"id": "conn-2"}), "tenant-1"))
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.tenants, err = tenant.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+			ErrGet: errors.New("get error"),
+		}))
+		require.NoError(t, err)
+
+		o.assignTenant("tenant-1", "conn-1")
+		require.Empty(t, o.tenantOf("conn-1"))
+	})
+}
+
+func TestTenantBranding(t *testing.T) {
+	cfg := config()
+	cfg.Invitations.Branding = map[string]*tenant.Branding{
+		"tenant-1": {Label: "Acme", Goal: "Connect your Acme wallet", ImageURL: "https://acme.example.com/logo.png"},
+	}
+
+	o, err := New(cfg)
+	require.NoError(t, err)
+
+	invitation := func(tenantID string) *Invitation {
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, withTenant(httptest.NewRequest(http.MethodGet, invitationPath, nil), tenantID))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &DIDCommInvitationResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+
+		return resp.Invitation
+	}
+
+	t.Run("tenant branding", func(t *testing.T) {
+		inv := invitation("tenant-1")
+		require.Equal(t, "Acme", inv.Label)
+		require.Equal(t, "Connect your Acme wallet", inv.Goal)
+		require.Equal(t, "https://acme.example.com/logo.png", inv.ImageURL)
+	})
+
+	t.Run("router branding", func(t *testing.T) {
+		for _, tenantID := range []string{"", "tenant-2"} {
+			inv := invitation(tenantID)
+			require.Equal(t, defaultLabel, inv.Label)
+			require.Empty(t, inv.Goal)
+			require.Empty(t, inv.ImageURL)
+		}
+	})
+}
+
+func withTenant(req *http.Request, tenantID string) *http.Request {
+	return req.WithContext(tenant.WithTenant(req.Context(), tenantID))
+}
+
+func withTenantKey(req *http.Request, key string) *http.Request {
+	req.Header.Set("Authorization", bearerPrefix+key)
+
+	return req
+}
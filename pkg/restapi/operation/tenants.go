@@ -0,0 +1,387 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/routes"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+// API endpoints.
+const (
+	tenantsPath = "/tenants"
+	tenantPath  = tenantsPath + "/{id}"
+)
+
+const (
+	tenantTopic   = "tenant"
+	maxTenantSize = 4 * 1024
+)
+
+// TenantReq model : creates the tenant, or changes its state.
+type TenantReq struct {
+	ID string `json:"id"`
+	// State is active or suspended, active if empty.
+	State string `json:"state,omitempty"`
+}
+
+// TenantResp model.
+type TenantResp struct {
+	ID    string `json:"id"`
+	State string `json:"state"`
+	// Configured is true if the API key of the tenant is set at startup (--tenant-api-key).
+	Configured bool       `json:"configured"`
+	Created    *time.Time `json:"created,omitempty"`
+	Updated    *time.Time `json:"updated,omitempty"`
+	// Namespace is the storage namespace of the tenant, if the tenant storage is isolated.
+	Namespace string `json:"namespace,omitempty"`
+	// APIKey is the API key issued to the tenant, only returned once when the tenant is created.
+	APIKey string `json:"apiKey,omitempty"`
+	// Revision of the registered tenant, also returned as its ETag.
+	Revision int `json:"revision,omitempty"`
+}
+
+// TenantsResp model.
+type TenantsResp struct {
+	Tenants []*TenantResp `json:"tenants"`
+}
+
+// tenancyHandler serves the registry of the tenants, and resolves the API keys of the tenants, configured at startup or
+// issued by the router. Its isolation is nil if the tenant storage isn't isolated.
+type tenancyHandler struct {
+	registry  *tenant.Registry
+	apiKeys   *tenant.Keys
+	isolation *tenant.IsolatedProvider
+	// changed publishes the state change of the tenant.
+	changed func(tenantID, state, previous string)
+}
+
+func (o *Operation) initTenants(config *Config) error {
+	var err error
+
+	o.tenants, err = tenant.New(config.Storage.Persistent)
+	if err != nil {
+		return fmt.Errorf("tenant store: %w", err)
+	}
+
+	o.tenancy = &tenancyHandler{
+		apiKeys: config.Tenancy.APIKeys, isolation: config.Storage.Isolation, changed: o.tenantChanged,
+	}
+
+	o.tenancy.registry, err = tenant.NewRegistry(config.Storage.Persistent, o.locker)
+	if err != nil {
+		return fmt.Errorf("tenant registry: %w", err)
+	}
+
+	o.routes, err = routes.Open(config.Aries.StorageProvider())
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (h *tenancyHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(tenantsPath, http.MethodPost, h.postTenant),
+		support.NewHTTPHandler(tenantsPath, http.MethodGet, h.getTenants),
+		support.NewHTTPHandler(tenantPath, http.MethodGet, h.getTenant),
+		support.NewHTTPHandler(tenantPath, http.MethodDelete, h.deleteTenant),
+	}
+}
+
+// postTenant creates the tenant, issuing its API key unless configured at startup, or changes its state.
+func (h *tenancyHandler) postTenant(rw http.ResponseWriter, req *http.Request) {
+	tenantReq, ok := decodeTenantReq(rw, req)
+	if !ok {
+		return
+	}
+
+	revision, ok := h.precondition(rw, req, tenantReq.ID, tenantsPath)
+	if !ok {
+		return
+	}
+
+	previous := h.state(tenantReq.ID)
+
+	t, apiKey, created, err := h.registry.PutIf(tenantReq.ID, tenantReq.State,
+		!h.apiKeys.Configured(tenantReq.ID), revision)
+
+	switch {
+	case errors.Is(err, tenant.ErrInvalidState):
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), tenantsPath, logger)
+
+		return
+	case errors.Is(err, tenant.ErrRevisionConflict):
+		writePreconditionFailed(rw, err, tenantsPath)
+
+		return
+	case err != nil:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to save tenant - err=%s", err.Error()), tenantsPath, logger)
+
+		return
+	}
+
+	if created {
+		previous = ""
+	}
+
+	if previous != t.State {
+		h.changed(t.ID, t.State, previous)
+	}
+
+	resp := h.tenantResp(t)
+	resp.APIKey = apiKey
+
+	setETag(rw, t.Revision)
+
+	if created {
+		rw.WriteHeader(http.StatusCreated)
+	}
+
+	httputil.WriteResponseWithLog(rw, resp, tenantsPath, logger)
+}
+
+// decodeTenantReq returns the tenant request.
+func decodeTenantReq(rw http.ResponseWriter, req *http.Request) (*TenantReq, bool) {
+	tenantReq := &TenantReq{}
+
+	err := json.NewDecoder(http.MaxBytesReader(rw, req.Body, maxTenantSize)).Decode(tenantReq)
+	if err != nil || tenantReq.ID == "" {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, "invalid tenant : id is mandatory", tenantsPath,
+			logger)
+
+		return nil, false
+	}
+
+	if tenantReq.State == "" {
+		tenantReq.State = tenant.StateActive
+	}
+
+	return tenantReq, true
+}
+
+// precondition returns the revision of the tenant the change of the request is conditional on, anyRevision if
+// not conditional, or writes the error response.
+func (h *tenancyHandler) precondition(rw http.ResponseWriter, req *http.Request, tenantID, endpoint string) (int,
+	bool) {
+	p, err := ifMatch(req)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest, err.Error(), endpoint, logger)
+
+		return 0, false
+	}
+
+	if p == nil {
+		return anyRevision, true
+	}
+
+	t, err := h.registry.Get(tenantID)
+
+	switch {
+	case errors.Is(err, tenant.ErrNotFound):
+		return p.revision(0), true
+	case err != nil:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get tenant - err=%s", err.Error()), endpoint, logger)
+
+		return 0, false
+	}
+
+	return p.revision(t.Revision), true
+}
+
+// getTenants returns the registered tenants, and the tenants configured at startup.
+func (h *tenancyHandler) getTenants(rw http.ResponseWriter, _ *http.Request) {
+	tenants, err := h.registry.List()
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to list tenants - err=%s", err.Error()), tenantsPath, logger)
+
+		return
+	}
+
+	resp := &TenantsResp{Tenants: []*TenantResp{}}
+	registered := make(map[string]bool, len(tenants))
+
+	for _, t := range tenants {
+		resp.Tenants = append(resp.Tenants, h.tenantResp(t))
+		registered[t.ID] = true
+	}
+
+	for _, tenantID := range h.apiKeys.Tenants() {
+		if !registered[tenantID] {
+			resp.Tenants = append(resp.Tenants, h.configuredTenantResp(tenantID))
+		}
+	}
+
+	httputil.WriteResponseWithLog(rw, resp, tenantsPath, logger)
+}
+
+func (h *tenancyHandler) getTenant(rw http.ResponseWriter, req *http.Request) {
+	tenantID := mux.Vars(req)["id"]
+
+	t, err := h.registry.Get(tenantID)
+	if err == nil {
+		setETag(rw, t.Revision)
+		httputil.WriteResponseWithLog(rw, h.tenantResp(t), tenantPath, logger)
+
+		return
+	}
+
+	if !errors.Is(err, tenant.ErrNotFound) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get tenant - err=%s", err.Error()), tenantPath, logger)
+
+		return
+	}
+
+	if !h.apiKeys.Configured(tenantID) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, err.Error(), tenantPath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, h.configuredTenantResp(tenantID), tenantPath, logger)
+}
+
+// deleteTenant deletes the tenant, revoking its API key. The tenants configured at startup can't be deleted, as
+// their API key can't be revoked : they are suspended instead. With purge=true, the storage namespace of the tenant
+// is deleted too.
+func (h *tenancyHandler) deleteTenant(rw http.ResponseWriter, req *http.Request) {
+	tenantID := mux.Vars(req)["id"]
+	purge := req.URL.Query().Get("purge") == "true"
+
+	if purge && h.isolation == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusBadRequest,
+			"purge requires the tenant storage isolation (--tenant-storage-isolation)", tenantPath, logger)
+
+		return
+	}
+
+	if h.apiKeys.Configured(tenantID) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusConflict,
+			fmt.Sprintf("tenant %s is configured at startup, suspend it instead", tenantID), tenantPath, logger)
+
+		return
+	}
+
+	revision, ok := h.precondition(rw, req, tenantID, tenantPath)
+	if !ok {
+		return
+	}
+
+	t, err := h.registry.DeleteIf(tenantID, revision)
+
+	switch {
+	case errors.Is(err, tenant.ErrNotFound):
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, err.Error(), tenantPath, logger)
+
+		return
+	case errors.Is(err, tenant.ErrRevisionConflict):
+		writePreconditionFailed(rw, err, tenantPath)
+
+		return
+	case err != nil:
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to delete tenant - err=%s", err.Error()), tenantPath, logger)
+
+		return
+	}
+
+	h.changed(t.ID, events.TenantDeleted, t.State)
+
+	if purge {
+		if err = h.isolation.Purge(t.ID); err != nil {
+			httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+				fmt.Sprintf("failed to purge tenant storage - err=%s", err.Error()), tenantPath, logger)
+
+			return
+		}
+	}
+
+	rw.WriteHeader(http.StatusNoContent)
+}
+
+func (h *tenancyHandler) tenantResp(t *tenant.Tenant) *TenantResp {
+	created, updated := t.Created, t.Updated
+
+	return &TenantResp{
+		ID: t.ID, State: t.State, Configured: h.apiKeys.Configured(t.ID), Created: &created, Updated: &updated,
+		Namespace: h.namespace(t.ID), Revision: t.Revision,
+	}
+}
+
+func (h *tenancyHandler) configuredTenantResp(tenantID string) *TenantResp {
+	return &TenantResp{ID: tenantID, State: tenant.StateActive, Configured: true, Namespace: h.namespace(tenantID)}
+}
+
+// namespace returns the storage namespace of the tenant, empty if the tenant storage isn't isolated.
+func (h *tenancyHandler) namespace(tenantID string) string {
+	if h.isolation == nil {
+		return ""
+	}
+
+	return tenant.Namespace(tenantID)
+}
+
+// state returns the state of the tenant, active if not registered.
+func (h *tenancyHandler) state(tenantID string) string {
+	if h.registry.Suspended(tenantID) {
+		return tenant.StateSuspended
+	}
+
+	return tenant.StateActive
+}
+
+// tenantChanged publishes the state change of the tenant, audits it and notifies it to the webhooks.
+func (o *Operation) tenantChanged(tenantID, state, previous string) {
+	logger.Infof("tenant id=[%s] state=[%s] previous=[%s]", tenantID, state, previous)
+
+	e := &events.TenantEvent{Time: time.Now().UTC(), TenantID: tenantID, State: state, Previous: previous}
+
+	o.events.Publish(e)
+	o.recordAudit(&audit.Entry{Type: audit.TenantUpdated, Detail: state, Tenant: tenantID})
+
+	go func() {
+		if err := o.webhook.Notify(tenantTopic, e); err != nil {
+			logger.Warnf("failed to notify tenant state : %s", err)
+		}
+	}()
+}
+
+// checkActive returns an error wrapping tenant.ErrSuspended if the tenant is suspended.
+func (h *tenancyHandler) checkActive(tenantID string) error {
+	if h.registry.Suspended(tenantID) {
+		return fmt.Errorf("%w : %s", tenant.ErrSuspended, tenantID)
+	}
+
+	return nil
+}
+
+// resolveAPIKey returns the tenant of the API key, configured at startup or issued by the router, empty for the
+// operator key. It returns false for an unknown key.
+func (h *tenancyHandler) resolveAPIKey(apiKey string) (string, bool) {
+	if tenantID, ok := h.apiKeys.Resolve(apiKey); ok {
+		return tenantID, true
+	}
+
+	return h.registry.Resolve(apiKey)
+}
@@ -0,0 +1,459 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+	mockroute "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/protocol/mediator"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/backpressure"
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/events"
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+	"github.com/trustbloc/hub-router/pkg/routes"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+func TestTenants(t *testing.T) {
+	newOperation := func(t *testing.T) *Operation {
+		t.Helper()
+
+		cfg := config()
+		cfg.Tenancy.APIKeys = tenant.NewKeys("operator-key", map[string]string{"tenant-1": "tenant-key"})
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		return o
+	}
+
+	postTenant := func(o *Operation, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.tenancy.postTenant(w, httptest.NewRequest(http.MethodPost, tenantsPath, strings.NewReader(body)))
+
+		return w
+	}
+
+	tenantReq := func(method, id string) *http.Request {
+		return mux.SetURLVars(httptest.NewRequest(method, tenantsPath+"/"+id, nil), map[string]string{"id": id})
+	}
+
+	t.Run("create, suspend, resume and delete", func(t *testing.T) {
+		o := newOperation(t)
+
+		sub := o.Events().Subscribe(10, events.TopicTenant)
+		defer sub.Unsubscribe()
+
+		w := postTenant(o, `{"id":"acme"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		created := &TenantResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), created))
+		require.Equal(t, "acme", created.ID)
+		require.Equal(t, tenant.StateActive, created.State)
+		require.False(t, created.Configured)
+		require.NotEmpty(t, created.APIKey)
+
+		tenantID, ok := o.tenancy.resolveAPIKey(created.APIKey)
+		require.True(t, ok)
+		require.Equal(t, "acme", tenantID)
+
+		w = postTenant(o, `{"id":"acme","state":"suspended"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		suspended := &TenantResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), suspended))
+		require.Equal(t, tenant.StateSuspended, suspended.State)
+		require.Empty(t, suspended.APIKey)
+
+		// unchanged state : no event
+		require.Equal(t, http.StatusOK, postTenant(o, `{"id":"acme","state":"suspended"}`).Code)
+		require.Equal(t, http.StatusOK, postTenant(o, `{"id":"acme","state":"active"}`).Code)
+
+		w = httptest.NewRecorder()
+		o.tenancy.deleteTenant(w, tenantReq(http.MethodDelete, "acme"))
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		_, ok = o.tenancy.resolveAPIKey(created.APIKey)
+		require.False(t, ok)
+
+		for _, expected := range []events.TenantEvent{
+			{TenantID: "acme", State: tenant.StateActive},
+			{TenantID: "acme", State: tenant.StateSuspended, Previous: tenant.StateActive},
+			{TenantID: "acme", State: tenant.StateActive, Previous: tenant.StateSuspended},
+			{TenantID: "acme", State: events.TenantDeleted, Previous: tenant.StateActive},
+		} {
+			select {
+			case e := <-sub.C:
+				te, ok := e.(*events.TenantEvent)
+				require.True(t, ok)
+				require.Equal(t, expected.TenantID, te.TenantID)
+				require.Equal(t, expected.State, te.State)
+				require.Equal(t, expected.Previous, te.Previous)
+			case <-time.After(time.Second):
+				require.Fail(t, "tenant event not published")
+			}
+		}
+
+		require.Empty(t, sub.C)
+
+		entries, err := o.auditLog.QueryTenant("acme", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, entries, 4)
+		require.Equal(t, audit.TenantUpdated, entries[0].Type)
+	})
+
+	t.Run("if-match revisions", func(t *testing.T) {
+		o := newOperation(t)
+
+		w := postTenant(o, `{"id":"acme"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		require.Equal(t, `"1"`, w.Header().Get(etagHeader))
+
+		w = httptest.NewRecorder()
+		o.tenancy.getTenant(w, tenantReq(http.MethodGet, "acme"))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.Equal(t, `"1"`, w.Header().Get(etagHeader))
+		require.Contains(t, w.Body.String(), `"revision":1`)
+
+		conditional := func(method, body, etag string) *httptest.ResponseRecorder {
+			req := tenantReq(method, "acme")
+			if body != "" {
+				req = httptest.NewRequest(method, tenantsPath, strings.NewReader(body))
+			}
+
+			req.Header.Set(ifMatchHeader, etag)
+
+			w := httptest.NewRecorder()
+			if method == http.MethodPost {
+				o.tenancy.postTenant(w, req)
+			} else {
+				o.tenancy.deleteTenant(w, req)
+			}
+
+			return w
+		}
+
+		w = conditional(http.MethodPost, `{"id":"acme","state":"suspended"}`, `"1"`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.Equal(t, `"2"`, w.Header().Get(etagHeader))
+
+		// stale revision : the change of the other admin tool is not clobbered
+		w = conditional(http.MethodPost, `{"id":"acme","state":"active"}`, `"1"`)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+		require.Contains(t, w.Body.String(), "precondition failed")
+		require.Equal(t, tenant.StateSuspended, o.tenancy.state("acme"))
+
+		w = conditional(http.MethodPost, `{"id":"acme"}`, "2")
+		require.Equal(t, http.StatusBadRequest, w.Code)
+		require.Contains(t, w.Body.String(), "invalid If-Match")
+
+		w = conditional(http.MethodDelete, "", `"1"`)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		// the weak ETags never match
+		w = conditional(http.MethodDelete, "", `W/"2"`)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = conditional(http.MethodDelete, "", `"1", "2"`)
+		require.Equal(t, http.StatusNoContent, w.Code)
+
+		// the tenant doesn't exist : only created without If-Match, or with the revision 0
+		w = conditional(http.MethodPost, `{"id":"acme"}`, `"1"`)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = conditional(http.MethodPost, `{"id":"acme"}`, "*")
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = conditional(http.MethodPost, `{"id":"acme"}`, `"0"`)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		w = conditional(http.MethodPost, `{"id":"acme"}`, `"0"`)
+		require.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		w = conditional(http.MethodDelete, "", "*")
+		require.Equal(t, http.StatusNoContent, w.Code)
+	})
+
+	t.Run("configured tenant", func(t *testing.T) {
+		o := newOperation(t)
+
+		w := postTenant(o, `{"id":"tenant-1","state":"suspended"}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		resp := &TenantResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.True(t, resp.Configured)
+		require.Empty(t, resp.APIKey)
+
+		w = httptest.NewRecorder()
+		o.tenancy.deleteTenant(w, tenantReq(http.MethodDelete, "tenant-1"))
+		require.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("get tenants", func(t *testing.T) {
+		o := newOperation(t)
+
+		require.Equal(t, http.StatusCreated, postTenant(o, `{"id":"acme","state":"suspended"}`).Code)
+
+		w := httptest.NewRecorder()
+		o.tenancy.getTenants(w, httptest.NewRequest(http.MethodGet, tenantsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &TenantsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Len(t, resp.Tenants, 2)
+		require.Equal(t, "acme", resp.Tenants[0].ID)
+		require.Equal(t, tenant.StateSuspended, resp.Tenants[0].State)
+		require.NotNil(t, resp.Tenants[0].Created)
+		require.Equal(t, "tenant-1", resp.Tenants[1].ID)
+		require.True(t, resp.Tenants[1].Configured)
+		require.Nil(t, resp.Tenants[1].Created)
+
+		for id, state := range map[string]string{"acme": tenant.StateSuspended, "tenant-1": tenant.StateActive} {
+			w = httptest.NewRecorder()
+			o.tenancy.getTenant(w, tenantReq(http.MethodGet, id))
+			require.Equal(t, http.StatusOK, w.Code)
+
+			got := &TenantResp{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), got))
+			require.Equal(t, id, got.ID)
+			require.Equal(t, state, got.State)
+		}
+
+		w = httptest.NewRecorder()
+		o.tenancy.getTenant(w, tenantReq(http.MethodGet, "unknown"))
+		require.Equal(t, http.StatusNotFound, w.Code)
+
+		w = httptest.NewRecorder()
+		o.tenancy.deleteTenant(w, tenantReq(http.MethodDelete, "unknown"))
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		o := newOperation(t)
+
+		for _, body := range []string{`{`, `{"state":"active"}`, `{"id":"acme","state":"closed"}`} {
+			require.Equal(t, http.StatusBadRequest, postTenant(o, body).Code)
+		}
+	})
+
+	t.Run("isolated storage", func(t *testing.T) {
+		cfg := config()
+		tenants := make(map[string]storage.Provider)
+
+		isolation, err := tenant.NewIsolatedProvider(cfg.Storage.Persistent,
+			func(tenantID, _ string) (storage.Provider, error) {
+				tenants[tenantID] = mem.NewProvider()
+
+				return tenants[tenantID], nil
+			})
+		require.NoError(t, err)
+
+		cfg.Storage.Persistent = isolation
+		cfg.Storage.Isolation = isolation
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusCreated, postTenant(o, `{"id":"acme"}`).Code)
+		require.Equal(t, []string{"acme"}, isolation.Tenants())
+		require.NotNil(t, tenants["acme"])
+
+		w := httptest.NewRecorder()
+		o.tenancy.getTenant(w, tenantReq(http.MethodGet, "acme"))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &TenantResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, "acme", resp.Namespace)
+
+		w = httptest.NewRecorder()
+		o.tenancy.deleteTenant(w, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, tenantsPath+"/acme?purge=true", nil),
+			map[string]string{"id": "acme"}))
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Empty(t, isolation.Tenants())
+
+		entries, err := o.auditLog.QueryTenant("acme", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Empty(t, entries)
+	})
+
+	t.Run("purge without isolated storage", func(t *testing.T) {
+		o := newOperation(t)
+
+		require.Equal(t, http.StatusCreated, postTenant(o, `{"id":"acme"}`).Code)
+
+		w := httptest.NewRecorder()
+		o.tenancy.deleteTenant(w, mux.SetURLVars(httptest.NewRequest(http.MethodDelete, tenantsPath+"/acme?purge=true", nil),
+			map[string]string{"id": "acme"}))
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = httptest.NewRecorder()
+		o.tenancy.getTenant(w, tenantReq(http.MethodGet, "acme"))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NotContains(t, w.Body.String(), "namespace")
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		s := &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry)}
+
+		cfg := config()
+		cfg.Storage.Persistent = mockstore.NewCustomMockStoreProvider(s)
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusCreated, postTenant(o, `{"id":"acme"}`).Code)
+
+		s.ErrPut = errors.New("put error")
+		s.ErrDelete = errors.New("delete error")
+
+		require.Equal(t, http.StatusInternalServerError, postTenant(o, `{"id":"acme","state":"suspended"}`).Code)
+
+		w := httptest.NewRecorder()
+		o.tenancy.deleteTenant(w, tenantReq(http.MethodDelete, "acme"))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+
+		s.ErrGet = errors.New("get error")
+		s.ErrQuery = errors.New("query error")
+
+		w = httptest.NewRecorder()
+		o.tenancy.getTenant(w, tenantReq(http.MethodGet, "acme"))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "failed to get tenant")
+
+		w = httptest.NewRecorder()
+		o.tenancy.getTenants(w, httptest.NewRequest(http.MethodGet, tenantsPath, nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "failed to list tenants")
+	})
+
+	t.Run("init errors", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		cfg := config()
+		cfg.Storage.Persistent = p
+
+		_, err := New(cfg)
+		require.Error(t, err)
+
+		cfg = config()
+		cfg.Aries.(*mockprovider.Provider).StorageProviderValue = &mockstore.MockStoreProvider{
+			FailNamespace: mediator.Coordination,
+		}
+
+		_, err = New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open route store")
+	})
+}
+
+func TestTenantSuspension(t *testing.T) {
+	forward := &transport.Envelope{
+		Message: []byte(`{"@id":"msg-1","@type":"https://didcomm.org/routing/1.0/forward","to":"key-1","msg":{}}`),
+	}
+
+	ariesStorage := mem.NewProvider()
+
+	newOperation := func(t *testing.T) *Operation {
+		t.Helper()
+
+		mediated, err := routes.Open(ariesStorage)
+		require.NoError(t, err)
+		require.NoError(t, mediated.Route("key-1", "did:wallet"))
+
+		cfg := config()
+		cfg.Aries.(*mockprovider.Provider).StorageProviderValue = ariesStorage
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.assignTenant("tenant-1", "conn-1", "did:wallet")
+
+		_, _, _, err = o.tenancy.registry.Put("tenant-1", tenant.StateSuspended, false)
+		require.NoError(t, err)
+
+		return o
+	}
+
+	t.Run("invitations rejected", func(t *testing.T) {
+		o := newOperation(t)
+
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, withTenant(httptest.NewRequest(http.MethodGet, invitationPath, nil), "tenant-1"))
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Contains(t, w.Body.String(), tenant.ErrSuspended.Error())
+
+		w = httptest.NewRecorder()
+		o.generateInvitation(w, withTenant(httptest.NewRequest(http.MethodGet, invitationPath, nil), "tenant-2"))
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("mediations rejected", func(t *testing.T) {
+		o := newOperation(t)
+
+		require.ErrorIs(t, o.checkMediationPolicy("tenant-1"), tenant.ErrSuspended)
+		require.NoError(t, o.checkMediationPolicy("tenant-2"))
+
+		o.routeSvc = &mockroute.MockMediatorSvc{}
+
+		require.False(t, o.autoGrantMediation(&correlation.Record{}, "conn-1", "did:peer:1", &did.Doc{
+			Service: []did.Service{{RecipientKeys: []string{"key-1"}}},
+		}))
+	})
+
+	t.Run("forwards paused", func(t *testing.T) {
+		o := newOperation(t)
+
+		err := o.AdmitEnvelope(withHeader(forward))
+		require.ErrorIs(t, err, tenant.ErrSuspended)
+
+		// not a forward, or not routed by the router
+		require.NoError(t, o.AdmitEnvelope(withHeader(&transport.Envelope{Message: []byte(`{"@type":"other"}`)})))
+		require.NoError(t, o.AdmitEnvelope(withHeader(&transport.Envelope{
+			Message: []byte(`{"@id":"msg-2","@type":"https://didcomm.org/routing/1.0/forward","to":"key-2","msg":{}}`),
+		})))
+
+		_, _, _, err = o.tenancy.registry.Put("tenant-1", tenant.StateActive, false)
+		require.NoError(t, err)
+
+		require.NoError(t, o.AdmitEnvelope(withHeader(forward)))
+	})
+
+	t.Run("backpressure gate", func(t *testing.T) {
+		o := newOperation(t)
+
+		_, _, _, err := o.tenancy.registry.Put("tenant-1", tenant.StateActive, false)
+		require.NoError(t, err)
+
+		queueMessages(t, o.pickup, "did:wallet", "key-1", 10)
+
+		o.backpressure.gate, err = backpressure.New(ariesStorage, o.pickup, &backpressure.Config{RecipientCap: 10}, nil)
+		require.NoError(t, err)
+
+		require.ErrorIs(t, o.gateForward(withHeader(forward)), backpressure.ErrQueueFull)
+	})
+}
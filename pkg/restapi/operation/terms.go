@@ -0,0 +1,182 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/terms"
+)
+
+// termsHandler presents the terms to the wallets, with the invitations and the mediation grants, and records their
+// consents. Its terms are nil if not configured.
+type termsHandler struct {
+	terms       *terms.Terms
+	records     *terms.Store
+	didExchange aries.DIDExchange
+	messenger   service.Messenger
+	// visible returns true if the connection is visible to the tenant.
+	visible     func(tenantID, connID string) bool
+	recordAudit func(*audit.Entry)
+}
+
+func (o *Operation) initTerms(config *Config) error {
+	o.terms = &termsHandler{
+		didExchange: o.didExchange, messenger: o.messenger, visible: o.visible, recordAudit: o.recordAudit,
+	}
+
+	if !config.Invitations.Terms.Enabled() {
+		return nil
+	}
+
+	var err error
+
+	o.terms.records, err = terms.New(config.Storage.Persistent)
+	if err != nil {
+		return fmt.Errorf("terms store: %w", err)
+	}
+
+	o.terms.terms = config.Invitations.Terms
+
+	return nil
+}
+
+func (h *termsHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(connectionConsentsPath, http.MethodGet, h.getConsents),
+		support.NewHTTPHandler(connectionConsentsPath, http.MethodPost, h.postConsent),
+	}
+}
+
+// invitationOptions returns the options of the router invitations, branded for the tenant unless overridden by the
+// request params, with the terms attached if configured.
+func (o *Operation) invitationOptions(tenantID string, params *invitationParams) []outofband.MessageOption {
+	b := o.branding(tenantID)
+
+	label, goal := b.Label, b.Goal
+
+	if params.label != "" {
+		label = params.label
+	}
+
+	if params.goal != "" {
+		goal = params.goal
+	}
+
+	opts := []outofband.MessageOption{outofband.WithLabel(label)}
+
+	if goal != "" || params.goalCode != "" {
+		opts = append(opts, outofband.WithGoal(goal, params.goalCode))
+	}
+
+	if len(params.protocols) > 0 {
+		opts = append(opts, outofband.WithHandshakeProtocols(params.protocols...))
+	}
+
+	if o.terms.terms != nil {
+		opts = append(opts, outofband.WithAttachments(o.terms.terms.Attachment()))
+	}
+
+	return opts
+}
+
+// present records the terms version presented to the invitation or connection.
+func (h *termsHandler) present(id, via string) {
+	if h.terms == nil {
+		return
+	}
+
+	err := h.records.Present(id, &terms.Record{
+		Version: h.terms.Version, Via: via, PresentedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		logger.Warnf("failed to record the terms presented to id=[%s] : %s", id, err)
+	}
+}
+
+// inherit records the terms version presented in the invitation to the connection created from it.
+func (h *termsHandler) inherit(invitationID, connectionID, myDID string) {
+	h.link(myDID, connectionID)
+
+	if h.terms == nil || invitationID == "" {
+		return
+	}
+
+	r, err := h.records.Get(invitationID)
+	if err == nil {
+		err = h.records.Present(connectionID, r)
+	}
+
+	if err != nil && !errors.Is(err, terms.ErrNotFound) {
+		logger.Warnf("failed to record the terms presented to connection id=[%s] : %s", connectionID, err)
+	}
+}
+
+// presented returns the terms version last presented to the connection, nil if never presented.
+func (h *termsHandler) presented(connectionID string) *terms.Record {
+	if h.terms == nil {
+		return nil
+	}
+
+	r, err := h.records.Get(connectionID)
+	if err != nil && !errors.Is(err, terms.ErrNotFound) {
+		logger.Warnf("failed to get the terms presented to connection id=[%s] : %s", connectionID, err)
+	}
+
+	return r
+}
+
+// sendGrant sends the terms with the mediation grant, threaded to the mediation request : the Aries mediator
+// grant message can't carry them.
+func (h *termsHandler) sendGrant(connectionID, threadID string) {
+	if h.terms == nil {
+		return
+	}
+
+	err := h.send(connectionID, threadID)
+	if err != nil {
+		logger.Warnf("failed to send the terms to connection id=[%s] : %s", connectionID, err)
+
+		return
+	}
+
+	h.present(connectionID, terms.ViaMediationGrant)
+}
+
+func (h *termsHandler) send(connectionID, threadID string) error {
+	conn, err := h.didExchange.GetConnection(connectionID)
+	if err != nil {
+		return fmt.Errorf("get connection : %w", err)
+	}
+
+	h.link(conn.MyDID, connectionID)
+
+	err = h.messenger.Send(service.NewDIDCommMsgMap(&terms.Msg{
+		ID:         uuid.New().String(),
+		Type:       terms.MsgType,
+		Thread:     &decorator.Thread{ID: threadID},
+		URL:        h.terms.URL,
+		PrivacyURL: h.terms.PrivacyURL,
+		Version:    h.terms.Version,
+	}), conn.MyDID, conn.TheirDID)
+	if err != nil {
+		return fmt.Errorf("send terms : %w", err)
+	}
+
+	return nil
+}
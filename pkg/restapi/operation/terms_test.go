@@ -0,0 +1,136 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/internal/mock/didexchange"
+	"github.com/trustbloc/hub-router/pkg/internal/mock/messenger"
+	"github.com/trustbloc/hub-router/pkg/presence"
+	"github.com/trustbloc/hub-router/pkg/terms"
+)
+
+func TestTerms(t *testing.T) {
+	mediatorTerms := &terms.Terms{
+		URL: "https://example.com/terms", PrivacyURL: "https://example.com/privacy", Version: "2021-06",
+	}
+
+	t.Run("terms presented in the invitation and with the mediation grant", func(t *testing.T) {
+		cfg := config()
+		cfg.Invitations.Terms = mediatorTerms
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.generateInvitation(w, httptest.NewRequest(http.MethodGet, invitationPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &struct {
+			Invitation struct {
+				ID       string `json:"@id"`
+				Requests []struct {
+					ID   string `json:"@id"`
+					Data struct {
+						JSON *terms.Terms `json:"json"`
+					} `json:"data"`
+				} `json:"request~attach"`
+			} `json:"invitation"`
+		}{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Len(t, resp.Invitation.Requests, 1)
+		require.Equal(t, terms.AttachmentID, resp.Invitation.Requests[0].ID)
+		require.Equal(t, mediatorTerms, resp.Invitation.Requests[0].Data.JSON)
+
+		o.terms.inherit(resp.Invitation.ID, "conn-1", "did:router")
+
+		r := o.terms.presented("conn-1")
+		require.Equal(t, "2021-06", r.Version)
+		require.Equal(t, terms.ViaInvitation, r.Via)
+
+		sent := make(chan *terms.Msg, 1)
+
+		o.terms.didExchange = &didexchange.MockClient{MyDID: "did:router", TheirDID: "did:wallet"}
+		o.terms.messenger = &messenger.MockMessenger{
+			SendFunc: func(msg service.DIDCommMsgMap, myDID, theirDID string) error {
+				require.Equal(t, "did:router", myDID)
+				require.Equal(t, "did:wallet", theirDID)
+
+				m := &terms.Msg{}
+				require.NoError(t, msg.Decode(m))
+
+				sent <- m
+
+				return nil
+			},
+		}
+
+		o.terms.sendGrant("conn-1", "mediation-request-1")
+
+		m := <-sent
+		require.Equal(t, terms.MsgType, m.Type)
+		require.Equal(t, "mediation-request-1", m.Thread.ID)
+		require.Equal(t, mediatorTerms, &terms.Terms{URL: m.URL, PrivacyURL: m.PrivacyURL, Version: m.Version})
+
+		require.Equal(t, terms.ViaMediationGrant, o.terms.presented("conn-1").Via)
+
+		require.NoError(t, o.presence.Seen("conn-1", presence.SourceMediation))
+
+		record, err := o.presence.Get("conn-1")
+		require.NoError(t, err)
+
+		wallet, err := o.wallet(record)
+		require.NoError(t, err)
+		require.Equal(t, "2021-06", wallet.Terms.Version)
+	})
+
+	t.Run("terms not sent", func(t *testing.T) {
+		cfg := config()
+		cfg.Invitations.Terms = mediatorTerms
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.terms.didExchange = &didexchange.MockClient{GetConnectionErr: errors.New("get error")}
+		o.terms.sendGrant("conn-1", "mediation-request-1")
+		require.Nil(t, o.terms.presented("conn-1"))
+
+		o.terms.didExchange = &didexchange.MockClient{}
+		o.terms.messenger = &messenger.MockMessenger{
+			SendFunc: func(service.DIDCommMsgMap, string, string) error {
+				return errors.New("send error")
+			},
+		}
+		o.terms.sendGrant("conn-1", "mediation-request-1")
+		require.Nil(t, o.terms.presented("conn-1"))
+
+		// the invitation wasn't presented the terms
+		o.terms.inherit("invitation-1", "conn-1", "")
+		o.terms.inherit("", "conn-1", "")
+		require.Nil(t, o.terms.presented("conn-1"))
+	})
+
+	t.Run("terms not configured", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		require.Len(t, o.invitationOptions("", &invitationParams{}), 1)
+
+		o.terms.present("invitation-1", terms.ViaInvitation)
+		o.terms.inherit("invitation-1", "conn-1", "")
+		o.terms.sendGrant("conn-1", "mediation-request-1")
+		require.Nil(t, o.terms.presented("conn-1"))
+	})
+}
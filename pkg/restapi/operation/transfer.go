@@ -0,0 +1,250 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	vdrapi "github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/history"
+	"github.com/trustbloc/hub-router/pkg/internal/common/support"
+	"github.com/trustbloc/hub-router/pkg/recovery"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+// API endpoints.
+const (
+	connectionRecoveryTokenPath = connectionsPath + "/{id}/recovery-token"
+)
+
+// recoveryHandler transfers the mediation of the wallets recovered on a new device to their new connection, and issues
+// the recovery tokens proving the control of their previous DID. Its rebinder is nil if the grant transfer isn't
+// enabled.
+type recoveryHandler struct {
+	rebinder    *recovery.Rebinder
+	tokens      *recovery.Tokens
+	connections *connection.Lookup
+	vdr         vdrapi.Registry
+	// tenantOf returns the tenant of the invitation, connection or DID.
+	tenantOf          func(id string) string
+	checkTenantActive func(tenantID string) error
+	// visible returns true if the connection is visible to the tenant.
+	visible     func(tenantID, connID string) bool
+	recordAudit func(*audit.Entry)
+}
+
+// initGrantTransfer initializes the grant transfers of the wallets recovered on a new device, if enabled.
+func (o *Operation) initGrantTransfer(config *Config) error {
+	o.recovery = &recoveryHandler{
+		connections: o.connections, vdr: o.vdriRegistry, tenantOf: o.tenantOf, checkTenantActive: o.tenancy.checkActive,
+		visible: o.visible, recordAudit: o.recordAudit,
+	}
+
+	if !config.Mediation.GrantTransfer {
+		return nil
+	}
+
+	var err error
+
+	o.recovery.rebinder, err = recovery.NewRebinder(config.Aries.StorageProvider(), o.pickup)
+	if err != nil {
+		return fmt.Errorf("grant transfer: %w", err)
+	}
+
+	o.recovery.tokens, err = recovery.NewTokens(config.Storage.Persistent, o.locker,
+		config.Mediation.RecoveryTokenSecret, config.Mediation.RecoveryTokenTTL)
+	if err != nil {
+		return fmt.Errorf("recovery tokens: %w", err)
+	}
+
+	return nil
+}
+
+func (h *recoveryHandler) handlers() []Handler {
+	return []Handler{
+		support.NewHTTPHandler(connectionRecoveryTokenPath, http.MethodPost, h.postRecoveryToken),
+	}
+}
+
+// handleTransfer transfers the mediation of the previous DID of the wallet, recovered on a new device, to the
+// connection the request is received on : the recipient keys of the previous DID routed by the router and the messages
+// queued for it are rebound to the DID of the new connection.
+func (h *recoveryHandler) handleTransfer(msg service.DIDCommMsg) (service.DIDCommMsgMap, error) {
+	transfer := &recovery.TransferMsg{}
+
+	if err := msg.Decode(transfer); err != nil {
+		return nil, withProblem(problemInvalidMsg, fmt.Errorf("decode transfer : %w", err))
+	}
+
+	inbound, ok := msg.(*aries.InboundMsg)
+	if !ok || inbound.TheirDID == "" {
+		return nil, withProblem(problemInvalidMsg, errors.New("transfer connection not found"))
+	}
+
+	connID, err := h.connections.GetConnectionIDByDIDs(inbound.MyDID, inbound.TheirDID)
+	if err != nil {
+		return nil, withProblem(problemInvalidMsg, fmt.Errorf("transfer connection : %w", err))
+	}
+
+	routed, tokenConnID, err := h.transferKeys(transfer, inbound.TheirDID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the recovery tokens are single use : the token is consumed before the routes are rebound, so that of the
+	// transfers presenting it, a single one is carried out
+	if tokenConnID != "" {
+		if _, err = h.tokens.Consume(transfer.Proof.Token, transfer.PreviousDID); err != nil {
+			return nil, proofError(err)
+		}
+	}
+
+	result, err := h.rebinder.Rebind(transfer.PreviousDID, inbound.TheirDID, routed)
+	if err != nil {
+		return nil, withProblem(problemInternal, err)
+	}
+
+	h.recordAudit(&audit.Entry{
+		Type: audit.GrantTransfer, ConnectionID: connID, ThreadID: msgCorrelation(msg).ThreadID, Actor: history.ActorWallet,
+		MsgType: recovery.TransferMsgType,
+		Detail: fmt.Sprintf("previous=%s proof=%s routes=%d messages=%d", transfer.PreviousDID,
+			transfer.Proof.Type, result.Routes, result.Messages),
+	})
+
+	return service.NewDIDCommMsgMap(&recovery.TransferAckMsg{
+		ID:       uuid.New().String(),
+		Type:     recovery.TransferAckMsgType,
+		Routes:   result.Routes,
+		Messages: result.Messages,
+	}), nil
+}
+
+// transferKeys verifies the proof of the transfer request, and returns the recipient keys of the previous DID of the
+// wallet routed by the router, and the connection of the recovery token if the proof is a token. The previous and new
+// DIDs must belong to the same tenant.
+func (h *recoveryHandler) transferKeys(transfer *recovery.TransferMsg, newDID string) ([]string, string, error) {
+	if transfer.PreviousDID == "" || transfer.PreviousDID == newDID {
+		return nil, "", withProblem(problemInvalidMsg, errors.New("invalid previous did"))
+	}
+
+	tenantID := h.tenantOf(newDID)
+	if h.tenantOf(transfer.PreviousDID) != tenantID {
+		return nil, "", withProblem(problemInvalidMsg, fmt.Errorf("previous did not found : %s", transfer.PreviousDID))
+	}
+
+	if err := h.checkTenantActive(tenantID); err != nil {
+		return nil, "", withProblem(problemInvalidMsg, err)
+	}
+
+	docResolution, err := h.vdr.Resolve(transfer.PreviousDID)
+	if err != nil {
+		return nil, "", withProblem(problemInvalidMsg, fmt.Errorf("resolve previous did : %w", err))
+	}
+
+	var recipientKeys []string
+
+	for _, svc := range docResolution.DIDDocument.Service {
+		recipientKeys = append(recipientKeys, svc.RecipientKeys...)
+	}
+
+	tokenConnID, err := h.verifyTransferProof(transfer, recipientKeys, newDID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	routed, err := h.rebinder.Routed(transfer.PreviousDID, recipientKeys)
+	if err != nil {
+		return nil, "", withProblem(problemInternal, err)
+	}
+
+	if len(routed) == 0 {
+		return nil, "", withProblem(problemInvalidMsg, fmt.Errorf("no mediation granted to %s", transfer.PreviousDID))
+	}
+
+	return routed, tokenConnID, nil
+}
+
+// verifyTransferProof verifies the proof of control of the previous DID of the wallet : the signature of a recipient
+// key of the DID, or the recovery token issued for its connection. It returns the connection of the token, empty for
+// a key signature.
+func (h *recoveryHandler) verifyTransferProof(transfer *recovery.TransferMsg, recipientKeys []string,
+	newDID string) (string, error) {
+	var (
+		tokenConnID string
+		err         error
+	)
+
+	if transfer.Proof != nil && transfer.Proof.Type == recovery.ProofRecoveryToken {
+		tokenConnID, err = h.tokens.Verify(transfer.Proof.Token, transfer.PreviousDID)
+	} else {
+		err = recovery.VerifyKeySignature(transfer.Proof, recipientKeys, transfer.PreviousDID, newDID, time.Now())
+	}
+
+	if err != nil {
+		return "", proofError(err)
+	}
+
+	return tokenConnID, nil
+}
+
+// proofError returns the problem of the transfer proof error.
+func proofError(err error) error {
+	if errors.Is(err, recovery.ErrInvalidProof) {
+		return withProblem(problemInvalidMsg, err)
+	}
+
+	return withProblem(problemInternal, err)
+}
+
+// postRecoveryToken issues a recovery token for the connection, stored by the backend of the wallet : the wallet
+// recovered on a new device proves with it the control of the DID of the connection, to transfer its mediation. A
+// new token revokes the previous one of the connection.
+func (h *recoveryHandler) postRecoveryToken(rw http.ResponseWriter, req *http.Request) {
+	if h.tokens == nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, "grant transfer not enabled",
+			connectionRecoveryTokenPath, logger)
+
+		return
+	}
+
+	connID := mux.Vars(req)["id"]
+
+	conn, err := h.connections.GetConnectionRecord(connID)
+	if err != nil || !h.visible(tenant.FromContext(req.Context()), connID) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, fmt.Sprintf("connection not found : %s", connID),
+			connectionRecoveryTokenPath, logger)
+
+		return
+	}
+
+	token, err := h.tokens.Issue(connID, conn.TheirDID)
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to issue recovery token - err=%s", err.Error()), connectionRecoveryTokenPath, logger)
+
+		return
+	}
+
+	h.recordAudit(&audit.Entry{
+		Type: audit.RecoveryToken, ConnectionID: connID, Detail: fmt.Sprintf("expiresAt=%s", token.ExpiresAt),
+		Actor: requestActor(req),
+	})
+
+	rw.WriteHeader(http.StatusCreated)
+	httputil.WriteResponseWithLog(rw, token, connectionRecoveryTokenPath, logger)
+}
@@ -0,0 +1,350 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcutil/base58"
+	"github.com/gorilla/mux"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	mockvdri "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/aries"
+	"github.com/trustbloc/hub-router/pkg/audit"
+	"github.com/trustbloc/hub-router/pkg/lock"
+	"github.com/trustbloc/hub-router/pkg/pickup"
+	"github.com/trustbloc/hub-router/pkg/recovery"
+	"github.com/trustbloc/hub-router/pkg/routes"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+func TestTransfer(t *testing.T) {
+	pubKey, privKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	key := base58.Encode(pubKey)
+
+	proof := func(newDID string) *recovery.Proof {
+		created := time.Now()
+
+		return &recovery.Proof{
+			Type: recovery.ProofKeySignature, Key: key, Created: created,
+			Signature: base64.RawURLEncoding.EncodeToString(
+				ed25519.Sign(privKey, recovery.Challenge("did:previous", newDID, created))),
+		}
+	}
+
+	transferMsg := func(previousDID string, p *recovery.Proof) *aries.InboundMsg {
+		return &aries.InboundMsg{
+			DIDCommMsg: service.NewDIDCommMsgMap(&recovery.TransferMsg{
+				ID: "transfer-1", Type: recovery.TransferMsgType, PreviousDID: previousDID, Proof: p,
+			}),
+			MyDID: "did:router", TheirDID: "did:new",
+		}
+	}
+
+	recoveryTokenReq := func(o *Operation, connID string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		o.recovery.postRecoveryToken(w, mux.SetURLVars(httptest.NewRequest(http.MethodPost,
+			connectionsPath+"/"+connID+"/recovery-token", nil), map[string]string{"id": connID}))
+
+		return w
+	}
+
+	newOperation := func(t *testing.T) (*Operation, *mem.Provider) {
+		t.Helper()
+
+		ariesStorage := mem.NewProvider()
+
+		mediated, err := routes.Open(ariesStorage)
+		require.NoError(t, err)
+		require.NoError(t, mediated.Route(key, "did:previous"))
+
+		cfg := config()
+		cfg.Aries.(*mockprovider.Provider).StorageProviderValue = ariesStorage
+		cfg.Aries.(*mockprovider.Provider).VDRegistryValue = &mockvdri.MockVDRegistry{
+			ResolveValue: &did.Doc{ID: "did:previous", Service: []did.Service{{RecipientKeys: []string{key}}}},
+		}
+		cfg.Mediation.GrantTransfer = true
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		queueMessages(t, o.pickup, "did:previous", key, 1)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-2", State: connection.StateNameCompleted, ThreadID: "thid-2",
+			MyDID: "did:router", TheirDID: "did:new", Namespace: connection.MyNSPrefix,
+		}))
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-1", State: connection.StateNameCompleted, ThreadID: "thid-1",
+			MyDID: "did:router-1", TheirDID: "did:previous", Namespace: connection.MyNSPrefix,
+		}))
+
+		return o, ariesStorage
+	}
+
+	t.Run("grant transfer not enabled", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		_, ok := o.msgService(recovery.TransferMsgType)
+		require.False(t, ok)
+
+		require.Equal(t, http.StatusNotFound, recoveryTokenReq(o, "conn-1").Code)
+	})
+
+	t.Run("mediation transferred to the new device", func(t *testing.T) {
+		o, ariesStorage := newOperation(t)
+
+		_, ok := o.msgService(recovery.TransferMsgType)
+		require.True(t, ok)
+
+		reply, err := o.recovery.handleTransfer(transferMsg("did:previous", proof("did:new")))
+		require.NoError(t, err)
+
+		ack := &recovery.TransferAckMsg{}
+		require.NoError(t, reply.Decode(ack))
+		require.Equal(t, recovery.TransferAckMsgType, ack.Type)
+		require.Equal(t, 1, ack.Routes)
+		require.Equal(t, 1, ack.Messages)
+
+		mediated, err := routes.Open(ariesStorage)
+		require.NoError(t, err)
+
+		theirDID, err := mediated.DID(key)
+		require.NoError(t, err)
+		require.Equal(t, "did:new", theirDID)
+
+		keys, err := o.pickup.Keys("did:new")
+		require.NoError(t, err)
+		require.Equal(t, []string{pickup.MailboxKey("did:new", key)}, keys)
+
+		entries, err := o.auditLog.Query(time.Time{}, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		require.Equal(t, audit.GrantTransfer, entries[0].Type)
+		require.Equal(t, "conn-2", entries[0].ConnectionID)
+
+		// the mediation of the previous did is already transferred
+		_, err = o.recovery.handleTransfer(transferMsg("did:previous", proof("did:new")))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "no mediation granted to did:previous")
+	})
+
+	t.Run("mediation transferred with a recovery token", func(t *testing.T) {
+		o, _ := newOperation(t)
+
+		w := recoveryTokenReq(o, "conn-1")
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		token := &recovery.Token{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), token))
+		require.Equal(t, "conn-1", token.ConnectionID)
+		require.NotEmpty(t, token.Token)
+
+		p := &recovery.Proof{Type: recovery.ProofRecoveryToken, Token: token.Token}
+
+		reply, err := o.recovery.handleTransfer(transferMsg("did:previous", p))
+		require.NoError(t, err)
+
+		ack := &recovery.TransferAckMsg{}
+		require.NoError(t, reply.Decode(ack))
+		require.Equal(t, 1, ack.Routes)
+
+		entries, err := o.auditLog.Query(time.Time{}, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, entries, 2)
+		require.Equal(t, audit.RecoveryToken, entries[0].Type)
+		require.Equal(t, audit.GrantTransfer, entries[1].Type)
+		require.Contains(t, entries[1].Detail, "proof=recovery-token")
+
+		// the token is single use
+		_, err = o.recovery.handleTransfer(transferMsg("did:previous", p))
+		require.Error(t, err)
+		require.Equal(t, problemInvalidMsg, problemCode(err))
+		require.Contains(t, err.Error(), "token revoked")
+	})
+
+	t.Run("recovery token errors", func(t *testing.T) {
+		o, _ := newOperation(t)
+
+		require.Equal(t, http.StatusNotFound, recoveryTokenReq(o, "conn-3").Code)
+
+		p := mockstore.NewMockStoreProvider()
+
+		var err error
+
+		o.recovery.tokens, err = recovery.NewTokens(p, lock.NewLocal(), nil, time.Hour)
+		require.NoError(t, err)
+
+		w := recoveryTokenReq(o, "conn-1")
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		token := &recovery.Token{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), token))
+
+		p.Store.ErrPut = errors.New("put error")
+		p.Store.ErrGet = errors.New("get error")
+
+		require.Equal(t, http.StatusInternalServerError, recoveryTokenReq(o, "conn-1").Code)
+
+		_, err = o.recovery.handleTransfer(transferMsg("did:previous",
+			&recovery.Proof{Type: recovery.ProofRecoveryToken, Token: token.Token}))
+		require.Error(t, err)
+		require.Equal(t, problemInternal, problemCode(err))
+
+		// the transfer fails, and the routes aren't rebound, if the token fails to be consumed
+		p.Store.ErrGet = nil
+		p.Store.ErrDelete = errors.New("delete error")
+
+		_, err = o.recovery.handleTransfer(transferMsg("did:previous",
+			&recovery.Proof{Type: recovery.ProofRecoveryToken, Token: token.Token}))
+		require.Error(t, err)
+		require.Equal(t, problemInternal, problemCode(err))
+		require.Contains(t, err.Error(), "consume recovery token")
+
+		routed, err := o.recovery.rebinder.Routed("did:previous", []string{key})
+		require.NoError(t, err)
+		require.Equal(t, []string{key}, routed)
+	})
+
+	t.Run("recovery token presented by concurrent transfers", func(t *testing.T) {
+		o, _ := newOperation(t)
+
+		w := recoveryTokenReq(o, "conn-1")
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		token := &recovery.Token{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), token))
+
+		p := &recovery.Proof{Type: recovery.ProofRecoveryToken, Token: token.Token}
+
+		var (
+			wg          sync.WaitGroup
+			transferred int32
+		)
+
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				if _, err := o.recovery.handleTransfer(transferMsg("did:previous", p)); err == nil {
+					atomic.AddInt32(&transferred, 1)
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		require.Equal(t, int32(1), transferred)
+	})
+
+	t.Run("invalid transfer requests", func(t *testing.T) {
+		o, _ := newOperation(t)
+
+		o.assignTenant("tenant-1", "did:other")
+
+		for _, msg := range []service.DIDCommMsg{
+			service.DIDCommMsgMap{"@type": recovery.TransferMsgType, "previousDID": 1},
+			service.NewDIDCommMsgMap(&recovery.TransferMsg{Type: recovery.TransferMsgType, PreviousDID: "did:previous"}),
+			&aries.InboundMsg{
+				DIDCommMsg: service.NewDIDCommMsgMap(&recovery.TransferMsg{Type: recovery.TransferMsgType}),
+				MyDID:      "did:router", TheirDID: "did:unknown",
+			},
+			transferMsg("", proof("did:new")),
+			transferMsg("did:new", proof("did:new")),
+			transferMsg("did:other", proof("did:new")),
+			transferMsg("did:previous", nil),
+			transferMsg("did:previous", proof("did:attacker")),
+		} {
+			_, err := o.recovery.handleTransfer(msg)
+			require.Error(t, err)
+			require.Equal(t, problemInvalidMsg, problemCode(err))
+		}
+
+		o.recovery.vdr = &mockvdri.MockVDRegistry{ResolveErr: errors.New("resolve error")}
+
+		_, err := o.recovery.handleTransfer(transferMsg("did:previous", proof("did:new")))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "resolve previous did")
+	})
+
+	t.Run("tenant suspended", func(t *testing.T) {
+		o, _ := newOperation(t)
+
+		_, _, _, err := o.tenancy.registry.Put("tenant-1", tenant.StateSuspended, false)
+		require.NoError(t, err)
+
+		o.assignTenant("tenant-1", "did:previous", "did:new")
+
+		_, err = o.recovery.handleTransfer(transferMsg("did:previous", proof("did:new")))
+		require.True(t, errors.Is(err, tenant.ErrSuspended))
+		require.Equal(t, problemInvalidMsg, problemCode(err))
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		o, _ := newOperation(t)
+
+		rebinder := func(p storage.Provider) (*recovery.Rebinder, error) {
+			q, err := pickup.NewQueue(p, lock.NewLocal())
+			if err != nil {
+				return nil, err
+			}
+
+			return recovery.NewRebinder(p, q)
+		}
+
+		store := &mockstore.MockStore{Store: map[string]mockstore.DBEntry{}}
+		p := mockstore.NewCustomMockStoreProvider(store)
+
+		mediated, err := routes.Open(p)
+		require.NoError(t, err)
+		require.NoError(t, mediated.Route(key, "did:previous"))
+
+		store.ErrPut = errors.New("put error")
+
+		o.recovery.rebinder, err = rebinder(p)
+		require.NoError(t, err)
+
+		_, err = o.recovery.handleTransfer(transferMsg("did:previous", proof("did:new")))
+		require.Error(t, err)
+		require.Equal(t, problemInternal, problemCode(err))
+
+		o.recovery.rebinder, err = rebinder(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  map[string]mockstore.DBEntry{},
+			ErrGet: errors.New("get error"),
+		}))
+		require.NoError(t, err)
+
+		_, err = o.recovery.handleTransfer(transferMsg("did:previous", proof("did:new")))
+		require.Error(t, err)
+		require.Equal(t, problemInternal, problemCode(err))
+	})
+}
@@ -0,0 +1,60 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"time"
+
+	mediatordsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+
+	"github.com/trustbloc/hub-router/pkg/upstream"
+)
+
+const upstreamRetryInterval = 30 * time.Second
+
+// initUpstream initializes the upstream mediator, if enabled : the router connects to it in the background, and
+// advertises its own endpoint until granted mediation.
+func (o *Operation) initUpstream(config *Config) error {
+	if config.Mediation.Upstream == nil {
+		return nil
+	}
+
+	// the route service is looked up by the mediator client already
+	svc, _ := config.Aries.Service(mediatordsvc.Coordination)
+
+	coordinator, ok := svc.(upstream.Coordinator)
+	if !ok {
+		return errors.New("upstream mediator: route service is not a route coordinator")
+	}
+
+	o.upstream = upstream.New(config.Mediation.Upstream, o.oob, o.connections, coordinator)
+
+	return nil
+}
+
+// upstreamRouting registers the router keys with the upstream mediator and returns its endpoint and routing keys, to
+// be advertised after the router keys. It returns the endpoint of the router and no routing keys if the upstream
+// mediator isn't enabled, or the router isn't granted mediation yet.
+func (o *Operation) upstreamRouting(keys ...string) (string, []string, error) {
+	if o.upstream == nil {
+		return o.endpoint, nil, nil
+	}
+
+	endpoint, routingKeys, ok := o.upstream.Routing()
+	if !ok {
+		logger.Warnf("upstream mediator not connected : advertising the router endpoint")
+
+		return o.endpoint, nil, nil
+	}
+
+	if err := o.upstream.AddKeys(keys...); err != nil {
+		return "", nil, withProblem(problemTransient, err)
+	}
+
+	return endpoint, routingKeys, nil
+}
@@ -0,0 +1,126 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	mediatordsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/doc/did"
+	vdrapi "github.com/hyperledger/aries-framework-go/pkg/framework/aries/api/vdr"
+	mockroute "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/protocol/mediator"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockvdri "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/correlation"
+	"github.com/trustbloc/hub-router/pkg/history"
+	"github.com/trustbloc/hub-router/pkg/upstream"
+)
+
+func TestUpstream(t *testing.T) {
+	newOperation := func(t *testing.T, coordinator *mockroute.MockMediatorSvc) *Operation {
+		t.Helper()
+
+		cfg := config()
+		cfg.Aries.(*mockprovider.Provider).ServiceMap[mediatordsvc.Coordination] = coordinator
+		cfg.Mediation.Upstream = &upstream.Config{Invitation: &outofband.Invitation{}}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.upstream.Stop()
+
+		return o
+	}
+
+	connected := func() *mockroute.MockMediatorSvc {
+		return &mockroute.MockMediatorSvc{
+			Connections: []string{"upstream-conn"}, RouterEndpoint: "https://mediator.example.com",
+			RoutingKeys: []string{"did:key:upstream"},
+		}
+	}
+
+	t.Run("upstream routing advertised", func(t *testing.T) {
+		coordinator := connected()
+
+		var added []string
+
+		coordinator.AddKeyFunc = func(key string) error {
+			added = append(added, key)
+
+			return nil
+		}
+
+		o := newOperation(t, coordinator)
+		require.NoError(t, o.upstream.Connect())
+
+		args, err := o.mediationGrantOptions("conn-1")
+		require.NoError(t, err)
+
+		opts, ok := args.(mediatordsvc.Options)
+		require.True(t, ok)
+		require.Equal(t, "https://mediator.example.com", opts.ServiceEndpoint)
+		require.Len(t, opts.RoutingKeys, 2)
+		require.Equal(t, "did:key:upstream", opts.RoutingKeys[1])
+		require.Equal(t, []string{opts.RoutingKeys[0]}, added)
+
+		var routerDoc *did.Doc
+
+		o.vdriRegistry = &mockvdri.MockVDRegistry{
+			CreateFunc: func(method string, doc *did.Doc, _ ...vdrapi.DIDMethodOption) (*did.DocResolution, error) {
+				routerDoc = doc
+
+				return &did.DocResolution{DIDDocument: mockdiddoc.GetMockDIDDoc(t)}, nil
+			},
+		}
+
+		data, err := o.establishConnection("", mockdiddoc.GetMockDIDDoc(t), "", &correlation.Record{},
+			history.ActorOperator)
+		require.NoError(t, err)
+		require.Equal(t, "https://mediator.example.com", data.ServiceEndpoint)
+		require.Len(t, data.RoutingKeys, 2)
+		require.Equal(t, "did:key:upstream", data.RoutingKeys[1])
+
+		require.Equal(t, "https://mediator.example.com", routerDoc.Service[0].ServiceEndpoint)
+		require.Equal(t, []string{"did:key:upstream"}, routerDoc.Service[0].RoutingKeys)
+		require.Len(t, added, 2)
+	})
+
+	t.Run("not connected", func(t *testing.T) {
+		o := newOperation(t, &mockroute.MockMediatorSvc{GetConnectionsErr: errors.New("get error")})
+
+		args, err := o.mediationGrantOptions("conn-1")
+		require.NoError(t, err)
+
+		opts, ok := args.(mediatordsvc.Options)
+		require.True(t, ok)
+		require.Equal(t, o.endpoint, opts.ServiceEndpoint)
+		require.Len(t, opts.RoutingKeys, 1)
+	})
+
+	t.Run("add key error", func(t *testing.T) {
+		coordinator := connected()
+		coordinator.AddKeyErr = errors.New("add error")
+
+		o := newOperation(t, coordinator)
+		require.NoError(t, o.upstream.Connect())
+
+		_, err := o.mediationGrantOptions("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "add upstream key : add error")
+		require.Equal(t, problemTransient, problemCode(err))
+
+		_, err = o.establishConnection("", mockdiddoc.GetMockDIDDoc(t), "", &correlation.Record{},
+			history.ActorOperator)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "add upstream key : add error")
+	})
+}
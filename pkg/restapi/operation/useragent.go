@@ -0,0 +1,60 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/trustbloc/hub-router/pkg/useragent"
+)
+
+// initUserAgents initializes the capture of the user agents, if tenants opted in.
+func (o *Operation) initUserAgents(config *Config) error {
+	if len(config.Tenancy.UserAgentTenants) == 0 {
+		return nil
+	}
+
+	var err error
+
+	o.userAgents, err = useragent.New(config.Storage.Persistent, config.Tenancy.UserAgentTenants)
+	if err != nil {
+		return fmt.Errorf("user agent store: %w", err)
+	}
+
+	return nil
+}
+
+// captureUserAgent records the user agent and app version the wallet reported at registration, if its tenant opted in.
+func (o *Operation) captureUserAgent(tenantID, connID, userAgent, appVersion string) {
+	if o.userAgents == nil {
+		return
+	}
+
+	_, err := o.userAgents.Capture(tenantID, connID, &useragent.Agent{
+		UserAgent: userAgent, AppVersion: appVersion, CapturedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		logger.Warnf("failed to capture the user agent of connection id=[%s] : %s", connID, err)
+	}
+}
+
+// walletAgent returns the user agent captured for the connection, nil if none.
+func (o *Operation) walletAgent(connID string) *useragent.Agent {
+	if o.userAgents == nil {
+		return nil
+	}
+
+	a, err := o.userAgents.Get(connID)
+	if err != nil {
+		logger.Debugf("no user agent for connection id=[%s] : %s", connID, err)
+
+		return nil
+	}
+
+	return a
+}
@@ -0,0 +1,115 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/presence"
+	"github.com/trustbloc/hub-router/pkg/useragent"
+)
+
+func TestUserAgent(t *testing.T) {
+	postConnection := func(t *testing.T, o *Operation, body string) string {
+		t.Helper()
+
+		didDocBytes, err := mockdiddoc.GetMockDIDDoc(t).JSONBytes()
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.postConnection(w, httptest.NewRequest(http.MethodPost, connectionsPath,
+			strings.NewReader(`{"didDoc":`+string(didDocBytes)+body+`}`)))
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		resp := &ConnectionResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+
+		return resp.ConnectionID
+	}
+
+	getWallet := func(t *testing.T, o *Operation, connID string) *Wallet {
+		t.Helper()
+
+		o.seen(connID, presence.SourceMediation)
+
+		w := httptest.NewRecorder()
+		o.getWallet(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, walletsPath+"/"+connID, nil),
+			map[string]string{"id": connID}))
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		wallet := &Wallet{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), wallet))
+
+		return wallet
+	}
+
+	const reported = `,"userAgent":"okhttp/4.9.0","appVersion":"2.3.1"`
+
+	t.Run("opted in", func(t *testing.T) {
+		cfg := config()
+		cfg.Tenancy.UserAgentTenants = []string{useragent.AllTenants}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		wallet := getWallet(t, o, postConnection(t, o, reported))
+		require.NotNil(t, wallet.Agent)
+		require.Equal(t, "okhttp/4.9.0", wallet.Agent.UserAgent)
+		require.Equal(t, "2.3.1", wallet.Agent.AppVersion)
+		require.False(t, wallet.Agent.CapturedAt.IsZero())
+
+		require.Nil(t, getWallet(t, o, postConnection(t, o, "")).Agent)
+	})
+
+	t.Run("not opted in", func(t *testing.T) {
+		cfg := config()
+		cfg.Tenancy.UserAgentTenants = []string{"acme"}
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+		require.Nil(t, getWallet(t, o, postConnection(t, o, reported)).Agent)
+
+		o, err = New(config())
+		require.NoError(t, err)
+		require.Nil(t, o.userAgents)
+		require.Nil(t, getWallet(t, o, postConnection(t, o, reported)).Agent)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		cfg := config()
+		cfg.Tenancy.UserAgentTenants = []string{useragent.AllTenants}
+		p := mockstore.NewMockStoreProvider()
+		p.FailNamespace = "useragent"
+		cfg.Storage.Persistent = p
+
+		_, err := New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "user agent store")
+
+		p = mockstore.NewMockStoreProvider()
+		p.Store.ErrPut = errors.New("put error")
+
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.userAgents, err = useragent.New(p, []string{useragent.AllTenants})
+		require.NoError(t, err)
+
+		o.captureUserAgent("", "conn-1", "okhttp/4.9.0", "")
+		require.Nil(t, o.walletAgent("conn-1"))
+	})
+}
@@ -0,0 +1,324 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+)
+
+// maxCreateConnReqSize is the maximum size of a create-conn-req message, in bytes.
+const maxCreateConnReqSize = 64 * 1024
+
+//go:embed schema/*.json
+var schemaFS embed.FS
+
+// msgSchema validates DIDComm messages against a JSON schema.
+type msgSchema struct {
+	schema  *gojsonschema.Schema
+	maxSize int
+}
+
+func newMsgSchema(file string, maxSize int) (*msgSchema, error) {
+	schemaBytes, err := schemaFS.ReadFile("schema/" + file)
+	if err != nil {
+		return nil, fmt.Errorf("read schema %s : %w", file, err)
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schemaBytes))
+	if err != nil {
+		return nil, fmt.Errorf("load schema %s : %w", file, err)
+	}
+
+	return &msgSchema{schema: schema, maxSize: maxSize}, nil
+}
+
+// validate returns a problem error listing each schema violation as a problem item.
+func (s *msgSchema) validate(msgType string, msgBytes []byte) error {
+	if len(msgBytes) > s.maxSize {
+		return withProblem(problemInvalidMsg,
+			fmt.Errorf("invalid %s : message size %d exceeds %d bytes", msgType, len(msgBytes), s.maxSize))
+	}
+
+	result, err := s.schema.Validate(gojsonschema.NewBytesLoader(msgBytes))
+	if err != nil {
+		return withProblem(problemInvalidMsg, fmt.Errorf("validate didcomm message : %w", err))
+	}
+
+	if result.Valid() {
+		return nil
+	}
+
+	var (
+		details []string
+		items   []map[string]string
+	)
+
+	for _, e := range result.Errors() {
+		details = append(details, e.String())
+		items = append(items, map[string]string{e.Field(): e.Description()})
+	}
+
+	return &ProblemError{
+		Code:  problemInvalidMsg,
+		Err:   fmt.Errorf("invalid %s : %s", msgType, strings.Join(details, "; ")),
+		Items: items,
+	}
+}
+
+// InvalidRequestResp model : the request body not valid against the schema of the endpoint.
+type InvalidRequestResp struct {
+	Message string `json:"errMessage"`
+	// Explain is the human-readable explanation of the error, in the language of the request.
+	Explain string `json:"explain,omitempty"`
+	// Errors are the invalid fields, the empty pointer referring to the whole body.
+	Errors []*FieldError `json:"errors"`
+}
+
+// FieldError model.
+type FieldError struct {
+	// Pointer is the JSON pointer (RFC 6901) to the invalid field, eg: /routingKeys/0.
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// openAPIDoc is the part of the OpenAPI document describing the request bodies of the admin API endpoints.
+type openAPIDoc struct {
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components json.RawMessage                         `json:"components"`
+}
+
+type openAPIOperation struct {
+	RequestBody *openAPIRequestBody `json:"requestBody"`
+}
+
+type openAPIRequestBody struct {
+	Ref     string `json:"$ref"`
+	Content map[string]struct {
+		Schema map[string]interface{} `json:"schema"`
+	} `json:"content"`
+}
+
+// requestBodyMaxSizes returns the maximum size of the request body of each operation of the OpenAPI document, by
+// method and path template.
+func requestBodyMaxSizes() map[string]int {
+	return map[string]int{
+		http.MethodPut + " " + walletRegionPath:        maxWalletRegionSize,
+		http.MethodPost + " " + walletHandoverPath:     maxHandoverSize,
+		http.MethodPost + " " + connectionsPath:        maxConnectionSize,
+		http.MethodPost + " " + connectionResolvePath:  maxResolveConnectionSize,
+		http.MethodPost + " " + connectionConsentsPath: maxConsentSize,
+		http.MethodPut + " " + connectionWebhookPath:   maxConnectionWebhookSize,
+		http.MethodPut + " " + policiesPath:            maxPolicySize,
+		http.MethodPost + " " + tenantsPath:            maxTenantSize,
+	}
+}
+
+func (o *Operation) initRequestSchemas() error {
+	schemas, err := requestSchemas(openAPIDocument, requestBodyMaxSizes())
+	if err != nil {
+		return err
+	}
+
+	o.requestSchemas = schemas
+
+	return nil
+}
+
+// requestSchemas returns the schemas of the request bodies of the OpenAPI document, by method and path template :
+// each operation with a request body must have a maximum size, and each maximum size an operation.
+func requestSchemas(docBytes []byte, maxSizes map[string]int) (map[string]*msgSchema, error) {
+	doc := &openAPIDoc{}
+
+	err := json.Unmarshal(docBytes, doc)
+	if err != nil {
+		return nil, fmt.Errorf("parse openapi document : %w", err)
+	}
+
+	schemas := map[string]*msgSchema{}
+
+	for path, operations := range doc.Paths {
+		for method, op := range operations {
+			if op.RequestBody == nil {
+				continue
+			}
+
+			key := strings.ToUpper(method) + " " + path
+
+			maxSize, ok := maxSizes[key]
+			if !ok {
+				return nil, fmt.Errorf("openapi document : no maximum size of the %s request body", key)
+			}
+
+			schemas[key], err = doc.requestSchema(op.RequestBody, maxSize)
+			if err != nil {
+				return nil, fmt.Errorf("openapi document : %s request body : %w", key, err)
+			}
+		}
+	}
+
+	for key := range maxSizes {
+		if _, ok := schemas[key]; !ok {
+			return nil, fmt.Errorf("openapi document : no %s request body", key)
+		}
+	}
+
+	return schemas, nil
+}
+
+// requestSchema compiles the JSON schema of the request body, along with the components of the document for its
+// references to resolve.
+func (d *openAPIDoc) requestSchema(body *openAPIRequestBody, maxSize int) (*msgSchema, error) {
+	if body.Ref != "" {
+		var components struct {
+			RequestBodies map[string]*openAPIRequestBody `json:"requestBodies"`
+		}
+
+		if err := json.Unmarshal(d.Components, &components); err != nil {
+			return nil, fmt.Errorf("parse components : %w", err)
+		}
+
+		ref, ok := components.RequestBodies[strings.TrimPrefix(body.Ref, "#/components/requestBodies/")]
+		if !ok {
+			return nil, fmt.Errorf("%s not found", body.Ref)
+		}
+
+		body = ref
+	}
+
+	media, ok := body.Content["application/json"]
+	if !ok || media.Schema == nil {
+		return nil, errors.New("no application/json schema")
+	}
+
+	root := map[string]interface{}{"components": d.Components}
+
+	for k, v := range media.Schema {
+		root[k] = v
+	}
+
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(root))
+	if err != nil {
+		return nil, fmt.Errorf("load schema : %w", err)
+	}
+
+	return &msgSchema{schema: schema, maxSize: maxSize}, nil
+}
+
+// ValidateRequest is the REST API middleware validating the request bodies of the admin API endpoints against their
+// schema : the invalid requests are rejected with 400, listing the invalid fields by their JSON pointer.
+func (o *Operation) ValidateRequest(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		s, ok := o.requestSchemas[routeKey(req)]
+		if !ok {
+			next.ServeHTTP(rw, req)
+
+			return
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(req.Body, int64(s.maxSize)+1))
+		if err != nil {
+			writeInvalidRequest(rw, req.URL.Path, []*FieldError{{Message: fmt.Sprintf("read request body : %s", err)}})
+
+			return
+		}
+
+		if fieldErrs := s.validateRequest(body); len(fieldErrs) > 0 {
+			writeInvalidRequest(rw, req.URL.Path, fieldErrs)
+
+			return
+		}
+
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// validateRequest returns the invalid fields of the request body.
+func (s *msgSchema) validateRequest(body []byte) []*FieldError {
+	if len(body) > s.maxSize {
+		return []*FieldError{{Message: fmt.Sprintf("request body exceeds %d bytes", s.maxSize)}}
+	}
+
+	result, err := s.schema.Validate(gojsonschema.NewBytesLoader(body))
+	if err != nil {
+		return []*FieldError{{Message: fmt.Sprintf("malformed JSON : %s", err)}}
+	}
+
+	var fieldErrs []*FieldError
+
+	for _, e := range result.Errors() {
+		fieldErrs = append(fieldErrs, &FieldError{Pointer: jsonPointer(e), Message: e.Description()})
+	}
+
+	return fieldErrs
+}
+
+// jsonPointer returns the JSON pointer to the field of the schema violation : the missing or unexpected property
+// itself rather than its parent.
+func jsonPointer(e gojsonschema.ResultError) string {
+	pointer := strings.TrimPrefix(e.Context().String("/"), gojsonschema.STRING_ROOT_SCHEMA_PROPERTY)
+
+	if property, ok := e.Details()["property"].(string); ok {
+		pointer += "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(property)
+	}
+
+	return pointer
+}
+
+func writeInvalidRequest(rw http.ResponseWriter, path string, fieldErrs []*FieldError) {
+	details := make([]string, len(fieldErrs))
+
+	for i, e := range fieldErrs {
+		details[i] = strings.TrimSpace(e.Pointer + " " + e.Message)
+	}
+
+	logger.Errorf("endpoint=[%s] status=[%d] errMsg=[invalid request : %s]", path, http.StatusBadRequest,
+		strings.Join(details, "; "))
+
+	resp := &InvalidRequestResp{
+		Message: "invalid request", Explain: httputil.Explain(rw, http.StatusBadRequest), Errors: fieldErrs,
+	}
+
+	rw.WriteHeader(http.StatusBadRequest)
+
+	err := json.NewEncoder(rw).Encode(resp)
+	if err != nil {
+		logger.Errorf("Unable to send error message, %s", err)
+	}
+}
+
+// routeKey returns the method and path template of the route of the request.
+func routeKey(req *http.Request) string {
+	route := mux.CurrentRoute(req)
+	if route == nil {
+		return ""
+	}
+
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+
+	return req.Method + " " + path
+}
@@ -0,0 +1,274 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateConnReqValidation(t *testing.T) {
+	const validDIDDoc = `{"@context":["https://www.w3.org/ns/did/v1"],"id":"did:peer:123"}`
+
+	t.Run("valid", func(t *testing.T) {
+		s, err := newMsgSchema("create-conn-req.json", maxCreateConnReqSize)
+		require.NoError(t, err)
+
+		require.NoError(t, s.validate(createConnReq, createConnReqBytes(t, "", validDIDDoc)))
+	})
+
+	t.Run("invalid messages", func(t *testing.T) {
+		s, err := newMsgSchema("create-conn-req.json", maxCreateConnReqSize)
+		require.NoError(t, err)
+
+		tests := []struct {
+			name   string
+			msgID  string
+			didDoc string
+			field  string
+		}{
+			{
+				name:   "id too long",
+				msgID:  strings.Repeat("a", 129),
+				didDoc: validDIDDoc,
+				field:  "@id",
+			},
+			{
+				name:   "unknown context",
+				didDoc: `{"@context":["https://example.com/context"],"id":"did:peer:123"}`,
+				field:  "data.didDoc.@context",
+			},
+			{
+				name:   "invalid did",
+				didDoc: `{"@context":"https://w3id.org/did/v1","id":"peer:123"}`,
+				field:  "data.didDoc.id",
+			},
+			{
+				name:   "not an object",
+				didDoc: `"did:peer:123"`,
+				field:  "data.didDoc",
+			},
+		}
+
+		for _, tc := range tests {
+			err = s.validate(createConnReq, createConnReqBytes(t, tc.msgID, tc.didDoc))
+			require.Error(t, err, tc.name)
+			require.Contains(t, err.Error(), "invalid "+createConnReq, tc.name)
+			require.Equal(t, problemInvalidMsg, problemCode(err), tc.name)
+
+			items := problemItems(err)
+			require.NotEmpty(t, items, tc.name)
+			require.Contains(t, items[0], tc.field, tc.name)
+		}
+	})
+
+	t.Run("message too large", func(t *testing.T) {
+		s, err := newMsgSchema("create-conn-req.json", 64)
+		require.NoError(t, err)
+
+		err = s.validate(createConnReq, createConnReqBytes(t, "", validDIDDoc))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "exceeds 64 bytes")
+		require.Equal(t, problemInvalidMsg, problemCode(err))
+	})
+
+	t.Run("invalid json", func(t *testing.T) {
+		s, err := newMsgSchema("create-conn-req.json", maxCreateConnReqSize)
+		require.NoError(t, err)
+
+		err = s.validate(createConnReq, []byte("{"))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "validate didcomm message")
+	})
+
+	t.Run("schema not found", func(t *testing.T) {
+		_, err := newMsgSchema("invalid.json", maxCreateConnReqSize)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "read schema invalid.json")
+	})
+
+	t.Run("problem items are reported", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		msg := receivedMsg(t, CreateConnReq{
+			ID:   uuid.New().String(),
+			Type: createConnReq,
+			Data: &CreateConnReqData{DIDDoc: json.RawMessage(`{"id":"did:peer:123"}`)},
+		})
+
+		_, err = o.handleCreateConnReq(msg)
+		require.Error(t, err)
+
+		report := o.newProblemReport(msg, err)
+		require.Equal(t, problemInvalidMsg, report.Description.Code)
+		require.Equal(t, []map[string]string{{"data.didDoc": "@context is required"}}, report.Items)
+	})
+}
+
+func createConnReqBytes(t *testing.T, msgID, didDoc string) []byte {
+	t.Helper()
+
+	if msgID == "" {
+		msgID = uuid.New().String()
+	}
+
+	msgBytes, err := json.Marshal(CreateConnReq{
+		ID:   msgID,
+		Type: createConnReq,
+		Data: &CreateConnReqData{DIDDoc: json.RawMessage(didDoc)},
+	})
+	require.NoError(t, err)
+
+	return msgBytes
+}
+
+func TestValidateRequest(t *testing.T) {
+	o, err := New(config())
+	require.NoError(t, err)
+
+	router := mux.NewRouter()
+
+	for _, h := range o.GetRESTHandlers() {
+		router.HandleFunc(h.Path(), func(rw http.ResponseWriter, req *http.Request) {
+			body, readErr := ioutil.ReadAll(req.Body)
+			require.NoError(t, readErr)
+
+			_, writeErr := rw.Write(body)
+			require.NoError(t, writeErr)
+		}).Methods(h.Method())
+	}
+
+	router.Use(o.ValidateRequest)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+
+		return w
+	}
+
+	invalid := func(t *testing.T, w *httptest.ResponseRecorder) []*FieldError {
+		t.Helper()
+
+		require.Equal(t, http.StatusBadRequest, w.Code)
+
+		resp := &InvalidRequestResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Equal(t, "invalid request", resp.Message)
+		require.NotEmpty(t, resp.Errors)
+
+		return resp.Errors
+	}
+
+	t.Run("valid requests", func(t *testing.T) {
+		for _, r := range []struct{ method, path, body string }{
+			{http.MethodPost, tenantsPath, `{"id":"tenant-1","state":"suspended"}`},
+			{http.MethodPost, "/connections/123/consents", `{"version":"v1"}`},
+			{http.MethodPost, connectionsPath, `{"did":"did:example:adapter"}`},
+			{http.MethodPost, connectionResolvePath, `{"did":"did:web:service.example.com","label":"service"}`},
+			{http.MethodPost, "/wallets/123/handover", `{"endpoint":"wss://mediator.example.com/ws",` +
+				`"routingKeys":["key1"],"recipientKey":"key2"}`},
+			{http.MethodPut, "/wallets/123/region", `{"region":""}`},
+			{http.MethodPut, policiesPath, `{"version":1,"rateLimits":{"invitationsPerMinute":10},` +
+				`"allowlists":{"didMethods":["peer"]},"autoAccept":{"mediation":null}}`},
+			{http.MethodGet, tenantsPath, ""},
+		} {
+			w := serve(r.method, r.path, r.body)
+			require.Equal(t, http.StatusOK, w.Code, r.path)
+			require.Equal(t, r.body, w.Body.String())
+		}
+	})
+
+	t.Run("invalid fields", func(t *testing.T) {
+		for _, r := range []struct{ method, path, body, pointer string }{
+			{http.MethodPost, tenantsPath, `{"state":"active"}`, "/id"},
+			{http.MethodPost, tenantsPath, `{"id":"tenant-1","state":"deleted"}`, "/state"},
+			{http.MethodPost, "/connections/123/consents", `{"version":""}`, "/version"},
+			{http.MethodPost, connectionsPath, `{"did":"adapter"}`, "/did"},
+			{http.MethodPost, connectionResolvePath, `{"label":"service"}`, "/did"},
+			{http.MethodPost, "/wallets/123/handover", `{"endpoint":"ftp://mediator"}`, "/endpoint"},
+			{http.MethodPost, "/wallets/123/handover", `{"endpoint":"https://mediator","routingKeys":[1]}`,
+				"/routingKeys/0"},
+			{http.MethodPut, "/wallets/123/region", `{"region":10}`, "/region"},
+			{http.MethodPut, policiesPath, `{"rateLimits":{"invitationsPerMinute":-1}}`,
+				"/rateLimits/invitationsPerMinute"},
+			{http.MethodPut, policiesPath, `{"quota":{}}`, "/quota"},
+			{http.MethodPut, policiesPath, `{"allowlists":{"didMethods":["Peer"]}}`, "/allowlists/didMethods/0"},
+			{http.MethodPost, tenantsPath, `[]`, ""},
+		} {
+			errs := invalid(t, serve(r.method, r.path, r.body))
+			require.Equal(t, r.pointer, errs[0].Pointer, r.body)
+			require.NotEmpty(t, errs[0].Message)
+		}
+	})
+
+	t.Run("malformed body", func(t *testing.T) {
+		errs := invalid(t, serve(http.MethodPost, tenantsPath, `{"id":`))
+		require.Equal(t, "", errs[0].Pointer)
+		require.Contains(t, errs[0].Message, "malformed JSON")
+
+		errs = invalid(t, serve(http.MethodPost, tenantsPath, `{"id":"`+strings.Repeat("a", maxTenantSize)+`"}`))
+		require.Equal(t, "", errs[0].Pointer)
+		require.Contains(t, errs[0].Message, "request body exceeds")
+	})
+
+	t.Run("json pointer escaping", func(t *testing.T) {
+		errs := invalid(t, serve(http.MethodPut, policiesPath, `{"a/b~c":true}`))
+		require.Equal(t, "/a~1b~0c", errs[0].Pointer)
+	})
+}
+
+func TestRequestSchemas(t *testing.T) {
+	doc := func(requestBody string) []byte {
+		return []byte(`{"paths":{"/tenants":{"get":{},"post":{"requestBody":` + requestBody + `}}},` +
+			`"components":{"requestBodies":{"TenantReq":{"content":{"application/json":{"schema":` +
+			`{"$ref":"#/components/schemas/TenantReq"}}}}},"schemas":{"TenantReq":{"required":["id"]}}}}`)
+	}
+
+	maxSizes := map[string]int{http.MethodPost + " " + tenantsPath: maxTenantSize}
+
+	t.Run("every request body of the document", func(t *testing.T) {
+		schemas, err := requestSchemas(openAPIDocument, requestBodyMaxSizes())
+		require.NoError(t, err)
+		require.Len(t, schemas, len(requestBodyMaxSizes()))
+	})
+
+	t.Run("request body reference", func(t *testing.T) {
+		schemas, err := requestSchemas(doc(`{"$ref":"#/components/requestBodies/TenantReq"}`), maxSizes)
+		require.NoError(t, err)
+		require.Len(t, schemas, 1)
+		require.Len(t, schemas[http.MethodPost+" "+tenantsPath].validateRequest([]byte(`{}`)), 1)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			doc      []byte
+			maxSizes map[string]int
+			err      string
+		}{
+			{[]byte(`[]`), maxSizes, "parse openapi document"},
+			{doc(`{"$ref":"#/components/requestBodies/TenantReq"}`), nil, "no maximum size of the POST /tenants"},
+			{doc(`{"$ref":"#/components/requestBodies/Tenant"}`), maxSizes, "#/components/requestBodies/Tenant not found"},
+			{doc(`{"content":{}}`), maxSizes, "no application/json schema"},
+			{doc(`{"content":{"application/json":{"schema":{"type":1}}}}`), maxSizes, "load schema"},
+			{[]byte(`{"paths":{}}`), maxSizes, "no POST /tenants request body"},
+		} {
+			_, err := requestSchemas(tc.doc, tc.maxSizes)
+			require.Error(t, err)
+			require.Contains(t, err.Error(), tc.err)
+		}
+	})
+}
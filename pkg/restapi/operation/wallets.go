@@ -0,0 +1,200 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/presence"
+	"github.com/trustbloc/hub-router/pkg/problemreport"
+	"github.com/trustbloc/hub-router/pkg/restapi/internal/httputil"
+	"github.com/trustbloc/hub-router/pkg/slowconsumer"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+	"github.com/trustbloc/hub-router/pkg/terms"
+	"github.com/trustbloc/hub-router/pkg/useragent"
+)
+
+// API endpoints.
+const (
+	walletsPath = "/wallets"
+	walletPath  = walletsPath + "/{id}"
+)
+
+const (
+	presenceTopic         = "presence"
+	presenceSweepInterval = 30 * time.Second
+)
+
+// WalletsResp model.
+type WalletsResp struct {
+	Wallets []*Wallet `json:"wallets"`
+}
+
+// Wallet model: the presence of the wallet, its label if it sent one, its slow consumer status if the detection is
+// enabled, the app user
+// (token subject) its connection is bound to if the invitations require a token, and the terms version last presented
+// to it if the terms are configured. The wallet detail carries the problem reports last received on its connection.
+// The region, and the revision of the pin, are set if the wallet is pinned to a storage region, the socket if the
+// wallet holds a WebSocket open, the agent if the wallet reported its user agent at registration and its tenant opted
+// in the capture.
+type Wallet struct {
+	*presence.Record
+	Label          string                  `json:"label,omitempty"`
+	SlowConsumer   *slowconsumer.Status    `json:"slowConsumer,omitempty"`
+	Subject        string                  `json:"subject,omitempty"`
+	Terms          *terms.Record           `json:"terms,omitempty"`
+	Problems       []*problemreport.Record `json:"problems,omitempty"`
+	Region         string                  `json:"region,omitempty"`
+	RegionRevision int                     `json:"regionRevision,omitempty"`
+	Socket         *LiveSocket             `json:"socket,omitempty"`
+	Agent          *useragent.Agent        `json:"agent,omitempty"`
+}
+
+func (o *Operation) getWallets(rw http.ResponseWriter, req *http.Request) {
+	records, err := o.presence.List(req.URL.Query().Get("status"))
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to list wallets - err=%s", err.Error()), walletsPath, logger)
+
+		return
+	}
+
+	slowOnly := req.URL.Query().Get("slow") == "true"
+	liveOnly := req.URL.Query().Get("live") == "true"
+	tenantID := tenant.FromContext(req.Context())
+
+	wallets := []*Wallet{}
+
+	for _, r := range records {
+		if !o.visible(tenantID, r.ConnectionID) {
+			continue
+		}
+
+		w, err := o.wallet(r)
+		if err != nil {
+			httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+				fmt.Sprintf("failed to list wallets - err=%s", err.Error()), walletsPath, logger)
+
+			return
+		}
+
+		if slowOnly && (w.SlowConsumer == nil || !w.SlowConsumer.Slow) || liveOnly && w.Socket == nil {
+			continue
+		}
+
+		wallets = append(wallets, w)
+	}
+
+	httputil.WriteResponseWithLog(rw, &WalletsResp{Wallets: wallets}, walletsPath, logger)
+}
+
+func (o *Operation) getWallet(rw http.ResponseWriter, req *http.Request) {
+	record, err := o.presence.Get(mux.Vars(req)["id"])
+	if err == nil && !o.visible(tenant.FromContext(req.Context()), record.ConnectionID) {
+		err = presence.ErrNotFound
+	}
+
+	if errors.Is(err, presence.ErrNotFound) {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusNotFound, err.Error(), walletPath, logger)
+
+		return
+	}
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get wallet - err=%s", err.Error()), walletPath, logger)
+
+		return
+	}
+
+	w, err := o.wallet(record)
+	if err == nil {
+		w.Problems, err = o.connectionProblems(record.ConnectionID)
+	}
+
+	if err != nil {
+		httputil.WriteErrorResponseWithLog(rw, http.StatusInternalServerError,
+			fmt.Sprintf("failed to get wallet - err=%s", err.Error()), walletPath, logger)
+
+		return
+	}
+
+	httputil.WriteResponseWithLog(rw, w, walletPath, logger)
+}
+
+// wallet adds the label, the token subject, the terms, the region, the live socket, the user agent and the slow
+// consumer status to the presence of the wallet.
+func (o *Operation) wallet(r *presence.Record) (*Wallet, error) {
+	w := &Wallet{
+		Record: r, Label: o.connectionLabel(r.ConnectionID), Subject: o.invitationTokens.subjectOf(r.ConnectionID),
+		Terms: o.terms.presented(r.ConnectionID), Socket: o.liveSocket(r.ConnectionID),
+		Agent: o.walletAgent(r.ConnectionID),
+	}
+
+	if pin := o.residency.walletPin(r.ConnectionID); pin != nil {
+		w.Region, w.RegionRevision = pin.Region, pin.Revision
+	}
+
+	if o.slowConsumers == nil {
+		return w, nil
+	}
+
+	s, err := o.slowConsumers.Get(r.ConnectionID)
+	if errors.Is(err, slowconsumer.ErrNotFound) {
+		return w, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	w.SlowConsumer = s
+
+	return w, nil
+}
+
+// seen records activity from the wallet on the connection.
+func (o *Operation) seen(connectionID, source string) {
+	if connectionID == "" {
+		return
+	}
+
+	if err := o.presence.Seen(connectionID, source); err != nil {
+		logger.Warnf("failed to record wallet presence : %s", err)
+	}
+}
+
+// presenceChanged notifies the presence change to the webhooks without blocking the message listeners.
+func (o *Operation) presenceChanged(r *presence.Record) {
+	o.events.Publish(&events.PresenceEvent{
+		Time: r.LastSeen, ConnectionID: r.ConnectionID, Status: r.Status, Source: r.Source,
+	})
+
+	go func() {
+		if err := o.webhook.Notify(presenceTopic, r); err != nil {
+			logger.Warnf("failed to notify presence change : %s", err)
+		}
+	}()
+}
+
+// connectionLabel returns the label the wallet sent with the DID exchange or create-conn-req, empty if none.
+func (o *Operation) connectionLabel(connID string) string {
+	record, err := o.connections.GetConnectionRecord(connID)
+	if err != nil {
+		logger.Debugf("failed to get the label of connection id=[%s] : %s", connID, err)
+
+		return ""
+	}
+
+	return record.TheirLabel
+}
@@ -0,0 +1,140 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package operation
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/presence"
+	"github.com/trustbloc/hub-router/pkg/webhook"
+)
+
+func TestWallets(t *testing.T) {
+	t.Run("presence tracking", func(t *testing.T) {
+		msgs := make(chan *webhook.Message, 1)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			msg := &webhook.Message{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(msg))
+
+			msgs <- msg
+		}))
+		defer srv.Close()
+
+		cfg := config()
+		cfg.Webhook = webhook.New([]string{srv.URL}, nil)
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		o.seen("", presence.SourceMediation)
+		o.seen("conn-1", presence.SourceMediation)
+
+		select {
+		case msg := <-msgs:
+			require.Equal(t, presenceTopic, msg.Topic)
+			require.Equal(t, presence.Online, msg.Message.(map[string]interface{})["status"])
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+
+		w := httptest.NewRecorder()
+		o.getWallets(w, httptest.NewRequest(http.MethodGet, walletsPath+"?status=online", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &WalletsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Len(t, resp.Wallets, 1)
+		require.Equal(t, "conn-1", resp.Wallets[0].ConnectionID)
+
+		w = httptest.NewRecorder()
+		o.getWallet(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, walletsPath+"/conn-1", nil),
+			map[string]string{"id": "conn-1"}))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		record := &presence.Record{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), record))
+		require.Equal(t, presence.SourceMediation, record.Source)
+	})
+
+	t.Run("wallet label", func(t *testing.T) {
+		cfg := config()
+
+		o, err := New(cfg)
+		require.NoError(t, err)
+
+		recorder, err := connection.NewRecorder(cfg.Aries)
+		require.NoError(t, err)
+		require.NoError(t, recorder.SaveConnectionRecord(&connection.Record{
+			ConnectionID: "conn-1", State: connection.StateNameCompleted, ThreadID: "thid-1",
+			MyDID: "did:router", TheirDID: "did:wallet1", TheirLabel: "Alice's Wallet", Namespace: connection.MyNSPrefix,
+		}))
+
+		o.seen("conn-1", presence.SourceMediation)
+		o.seen("conn-2", presence.SourceMediation)
+
+		w := httptest.NewRecorder()
+		o.getWallets(w, httptest.NewRequest(http.MethodGet, walletsPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+
+		resp := &WalletsResp{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+		require.Len(t, resp.Wallets, 2)
+
+		labels := map[string]string{}
+		for _, wallet := range resp.Wallets {
+			labels[wallet.ConnectionID] = wallet.Label
+		}
+
+		require.Equal(t, map[string]string{"conn-1": "Alice's Wallet", "conn-2": ""}, labels)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		w := httptest.NewRecorder()
+		o.getWallet(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, walletsPath+"/invalid", nil),
+			map[string]string{"id": "invalid"}))
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		o, err := New(config())
+		require.NoError(t, err)
+
+		o.presence, err = presence.New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrGet:   errors.New("get error"),
+			ErrQuery: errors.New("query error"),
+		}), time.Minute, nil)
+		require.NoError(t, err)
+
+		o.seen("conn-1", presence.SourceMediation)
+
+		w := httptest.NewRecorder()
+		o.getWallets(w, httptest.NewRequest(http.MethodGet, walletsPath, nil))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "query error")
+
+		w = httptest.NewRecorder()
+		o.getWallet(w, mux.SetURLVars(httptest.NewRequest(http.MethodGet, walletsPath+"/conn-1", nil),
+			map[string]string{"id": "conn-1"}))
+		require.Equal(t, http.StatusInternalServerError, w.Code)
+		require.Contains(t, w.Body.String(), "get error")
+	})
+}
@@ -0,0 +1,159 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package retryadvice tracks the retries advised to the peers on transient router errors, and whether the peers
+// actually retried, and after how long.
+package retryadvice
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRetryAfter is the time the peers are advised to wait before retrying, if not configured.
+	DefaultRetryAfter = 5 * time.Second
+	// abandonWindow is the time after which an advised retry that didn't happen is counted as abandoned.
+	abandonWindow = 10 * time.Minute
+)
+
+// ErrTransient marks the errors that are expected to go away on retry, eg: a storage or KMS hiccup.
+var ErrTransient = errors.New("transient error")
+
+// IsTransient returns true if the error is marked transient, or is a timeout.
+func IsTransient(err error) bool {
+	if errors.Is(err, ErrTransient) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+type advice struct {
+	time time.Time
+}
+
+// Stats of the advised retries.
+type Stats struct {
+	// RetryAfter is the number of seconds the peers are advised to wait.
+	RetryAfter int `json:"retryAfter"`
+	// Advised is the number of retries advised.
+	Advised uint64 `json:"advised"`
+	// Retried is the number of advised retries that happened, Early those sent before the advised time.
+	Retried uint64 `json:"retried"`
+	Early   uint64 `json:"early"`
+	// Recovered is the number of advised retries that succeeded.
+	Recovered uint64 `json:"recovered"`
+	// Abandoned is the number of advised retries that didn't happen within 10 minutes.
+	Abandoned uint64 `json:"abandoned"`
+	// Pending is the number of advised retries still expected.
+	Pending int `json:"pending"`
+	// AvgRetryDelay is the average time, in milliseconds, the peers waited before retrying.
+	AvgRetryDelay int64 `json:"avgRetryDelay"`
+}
+
+// Tracker tracks the retries advised to the peers, per peer and message type : the next message of the same type
+// from the peer is the retry.
+type Tracker struct {
+	retryAfter time.Duration
+	mutex      sync.Mutex
+	pending    map[string]advice
+	stats      Stats
+	totalDelay time.Duration
+	now        func() time.Time
+}
+
+// New returns a new Tracker advising the peers to retry after the given time, DefaultRetryAfter if zero.
+func New(retryAfter time.Duration) *Tracker {
+	if retryAfter <= 0 {
+		retryAfter = DefaultRetryAfter
+	}
+
+	return &Tracker{
+		retryAfter: retryAfter, pending: make(map[string]advice), now: time.Now,
+		stats: Stats{RetryAfter: int(retryAfter / time.Second)},
+	}
+}
+
+// RetryAfter returns the time the peers are advised to wait before retrying.
+func (t *Tracker) RetryAfter() time.Duration {
+	return t.retryAfter
+}
+
+// Observe records the message of the given type processed for the peer : it is counted as the retry of the retry
+// advised to the peer, if any, and a new retry is tracked if one is advised for it. Messages from unknown peers are
+// not tracked.
+func (t *Tracker) Observe(peer, msgType string, advised bool) {
+	if peer == "" {
+		return
+	}
+
+	key := peer + " " + msgType
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	now := t.now()
+
+	t.expire(now)
+
+	if a, ok := t.pending[key]; ok {
+		delete(t.pending, key)
+
+		delay := now.Sub(a.time)
+
+		t.stats.Retried++
+		t.totalDelay += delay
+
+		if delay < t.retryAfter {
+			t.stats.Early++
+		}
+
+		if !advised {
+			t.stats.Recovered++
+		}
+	}
+
+	if advised {
+		t.pending[key] = advice{time: now}
+		t.stats.Advised++
+	}
+}
+
+// Stats returns the stats of the advised retries.
+func (t *Tracker) Stats() *Stats {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.expire(t.now())
+
+	s := t.stats
+	s.Pending = len(t.pending)
+
+	if s.Retried > 0 {
+		s.AvgRetryDelay = (t.totalDelay / time.Duration(s.Retried)).Milliseconds()
+	}
+
+	return &s
+}
+
+// expire counts the advised retries that didn't happen within the abandon window.
+func (t *Tracker) expire(now time.Time) {
+	for key, a := range t.pending {
+		if now.Sub(a.time) >= abandonWindow {
+			delete(t.pending, key)
+
+			t.stats.Abandoned++
+		}
+	}
+}
@@ -0,0 +1,71 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package retryadvice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTransient(t *testing.T) {
+	require.True(t, IsTransient(fmt.Errorf("kms : %w", ErrTransient)))
+	require.True(t, IsTransient(fmt.Errorf("storage : %w", context.DeadlineExceeded)))
+	require.True(t, IsTransient(fmt.Errorf("read : %w", os.ErrDeadlineExceeded)))
+	require.True(t, IsTransient(&net.DNSError{IsTimeout: true}))
+	require.False(t, IsTransient(&net.DNSError{}))
+	require.False(t, IsTransient(errors.New("invalid message")))
+	require.False(t, IsTransient(nil))
+}
+
+func TestTracker(t *testing.T) {
+	t.Run("retries", func(t *testing.T) {
+		now := time.Now()
+
+		tr := New(0)
+		tr.now = func() time.Time { return now }
+		require.Equal(t, DefaultRetryAfter, tr.RetryAfter())
+
+		tr.Observe("did:adapter", "create-conn-req", true)
+		tr.Observe("did:other", "create-conn-req", true)
+		tr.Observe("did:adapter", "create-conn-req", false)
+
+		now = now.Add(10 * time.Second)
+
+		// the retry of did:other fails again
+		tr.Observe("did:other", "create-conn-req", true)
+
+		now = now.Add(10 * time.Second)
+
+		tr.Observe("did:other", "create-conn-req", false)
+
+		require.Equal(t, &Stats{
+			RetryAfter: 5, Advised: 3, Retried: 3, Early: 1, Recovered: 2, AvgRetryDelay: 6666,
+		}, tr.Stats())
+	})
+
+	t.Run("abandoned", func(t *testing.T) {
+		now := time.Now()
+
+		tr := New(time.Minute)
+		tr.now = func() time.Time { return now }
+
+		tr.Observe("did:adapter", "create-conn-req", true)
+		tr.Observe("", "create-conn-req", true)
+		require.Equal(t, 1, tr.Stats().Pending)
+
+		now = now.Add(abandonWindow)
+
+		require.Equal(t, &Stats{RetryAfter: 60, Advised: 1, Abandoned: 1}, tr.Stats())
+	})
+}
@@ -0,0 +1,52 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package routes reads and writes the routes of the Aries mediator : the DID of the connection each recipient key is
+// routed to. The mediator keeps them in its own store, with a key format it doesn't export, so the router accesses
+// them through this package only : its tests run against the mediator of the Aries version the router depends on, so
+// that a change of the format fails there rather than in each feature reading the routes.
+package routes
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// keyPrefix is the prefix of the recipient keys in the store of the Aries mediator.
+const keyPrefix = "route-"
+
+// Store of the routes of the Aries mediator.
+type Store struct {
+	store storage.Store
+}
+
+// Open opens the routes in the given storage provider of the Aries agent.
+func Open(p storage.Provider) (*Store, error) {
+	s, err := p.OpenStore(mediator.Coordination)
+	if err != nil {
+		return nil, fmt.Errorf("open route store : %w", err)
+	}
+
+	return &Store{store: s}, nil
+}
+
+// DID returns the DID of the connection the recipient key is routed to, an error wrapping storage.ErrDataNotFound if
+// the recipient key isn't routed by the router.
+func (s *Store) DID(recipientKey string) (string, error) {
+	did, err := s.store.Get(keyPrefix + recipientKey)
+	if err != nil {
+		return "", err
+	}
+
+	return string(did), nil
+}
+
+// Route routes the recipient key to the DID, eg: once the wallet is recovered on a new connection.
+func (s *Store) Route(recipientKey, did string) error {
+	return s.store.Put(keyPrefix+recipientKey, []byte(did))
+}
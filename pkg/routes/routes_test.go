@@ -0,0 +1,148 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/model"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/messagepickup"
+	mockdispatcher "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/dispatcher"
+	mockdiddoc "github.com/hyperledger/aries-framework-go/pkg/mock/diddoc"
+	mockkms "github.com/hyperledger/aries-framework-go/pkg/mock/kms"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockvdr "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+const (
+	myDID    = "did:example:router"
+	theirDID = "did:example:wallet"
+	timeout  = 5 * time.Second
+)
+
+// pickup is the message pickup service the Aries mediator queues the forwards it fails to deliver with.
+type pickup struct{}
+
+func (p *pickup) AddMessage(*model.Envelope, string) error {
+	return nil
+}
+
+// newMediator returns the Aries mediator, and the outbound dispatcher it sends its responses and forwards with.
+func newMediator(t *testing.T, p storage.Provider) (*mediator.Service, *mockdispatcher.MockOutbound) {
+	t.Helper()
+
+	outbound := &mockdispatcher.MockOutbound{}
+
+	svc, err := mediator.New(&mockprovider.Provider{
+		StorageProviderValue:              p,
+		ProtocolStateStorageProviderValue: mem.NewProvider(),
+		OutboundDispatcherValue:           outbound,
+		KMSValue:                          &mockkms.KeyManager{},
+		VDRegistryValue:                   &mockvdr.MockVDRegistry{ResolveValue: mockdiddoc.GetMockDIDDoc(t)},
+		ServiceMap:                        map[string]interface{}{messagepickup.MessagePickup: &pickup{}},
+	})
+	require.NoError(t, err)
+
+	return svc, outbound
+}
+
+func didCommMsg(t *testing.T, msg interface{}) service.DIDCommMsgMap {
+	t.Helper()
+
+	raw, err := json.Marshal(msg)
+	require.NoError(t, err)
+
+	m, err := service.ParseDIDCommMsgMap(raw)
+	require.NoError(t, err)
+
+	return m
+}
+
+func TestStore(t *testing.T) {
+	t.Run("routes registered by the aries mediator read", func(t *testing.T) {
+		p := mem.NewProvider()
+		svc, outbound := newMediator(t, p)
+
+		responded := make(chan struct{})
+
+		outbound.ValidateSendToDID = func(interface{}, string, string) error {
+			close(responded)
+
+			return nil
+		}
+
+		_, err := svc.HandleInbound(didCommMsg(t, &mediator.KeylistUpdate{
+			Type: mediator.KeylistUpdateMsgType, ID: uuid.New().String(),
+			Updates: []mediator.Update{{RecipientKey: "key-1", Action: "add"}},
+		}), service.NewDIDCommContext(myDID, theirDID, nil))
+		require.NoError(t, err)
+
+		select {
+		case <-responded:
+		case <-time.After(timeout):
+			require.Fail(t, "keylist update not handled")
+		}
+
+		routes, err := Open(p)
+		require.NoError(t, err)
+
+		did, err := routes.DID("key-1")
+		require.NoError(t, err)
+		require.Equal(t, theirDID, did)
+
+		_, err = routes.DID("key-2")
+		require.ErrorIs(t, err, storage.ErrDataNotFound)
+	})
+
+	t.Run("routes written used by the aries mediator", func(t *testing.T) {
+		p := mem.NewProvider()
+		svc, outbound := newMediator(t, p)
+
+		routes, err := Open(p)
+		require.NoError(t, err)
+		require.NoError(t, routes.Route("key-1", theirDID))
+
+		forwarded := make(chan struct{})
+
+		outbound.ValidateForward = func(interface{}, *service.Destination) error {
+			close(forwarded)
+
+			return nil
+		}
+
+		_, err = svc.HandleInbound(didCommMsg(t, &model.Forward{
+			Type: service.ForwardMsgType, ID: uuid.New().String(), To: "key-1", Msg: &model.Envelope{CipherText: "a"},
+		}), service.NewDIDCommContext(myDID, "", nil))
+		require.NoError(t, err)
+
+		select {
+		case <-forwarded:
+		case <-time.After(timeout):
+			require.Fail(t, "forward not routed")
+		}
+	})
+
+	t.Run("route store not opened", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := Open(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open route store")
+	})
+}
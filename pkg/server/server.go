@@ -0,0 +1,110 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/restapi/operation"
+)
+
+// Config holds the hub-router configuration: the Aries context, the message registrar used by the Aries agent,
+// the storage providers and the optional webhook/events settings.
+type Config = operation.Config
+
+// The settings of each feature, grouped in the Config.
+type (
+	TransportConfig  = operation.TransportConfig
+	AdmissionConfig  = operation.AdmissionConfig
+	QueueConfig      = operation.QueueConfig
+	MediationConfig  = operation.MediationConfig
+	InvitationConfig = operation.InvitationConfig
+	TenancyConfig    = operation.TenancyConfig
+	ReportingConfig  = operation.ReportingConfig
+	DeadLetterConfig = operation.DeadLetterConfig
+)
+
+// Server runs hub-router as a component of the calling Go process. The caller owns the Aries agent, the storage
+// and the listener.
+type Server struct {
+	operation  *operation.Operation
+	router     *mux.Router
+	httpServer *http.Server
+}
+
+// New returns a new hub-router Server.
+func New(config *Config) (*Server, error) {
+	if config == nil || config.Aries == nil || config.MsgRegistrar == nil || config.Storage == nil {
+		return nil, errors.New("aries context, message registrar and storage are mandatory")
+	}
+
+	o, err := operation.New(config)
+	if err != nil {
+		return nil, fmt.Errorf("create hub-router operations : %w", err)
+	}
+
+	router := mux.NewRouter()
+
+	for _, h := range o.GetRESTHandlers() {
+		router.HandleFunc(h.Path(), h.Handle()).Methods(h.Method())
+	}
+
+	router.Use(o.Localize, o.Authenticate, o.Fence, o.ValidateRequest, o.Idempotency)
+
+	return &Server{
+		operation:  o,
+		router:     router,
+		httpServer: &http.Server{Handler: router},
+	}, nil
+}
+
+// Handler returns the REST API handler, to be mounted on the caller's router or wrapped with its middleware.
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+// Operation returns the hub-router operations.
+func (s *Server) Operation() *operation.Operation {
+	return s.operation
+}
+
+// Events returns the event bus, to subscribe to the hub-router events in-process.
+func (s *Server) Events() *events.Bus {
+	return s.operation.Events()
+}
+
+// Serve serves the REST API on the given listener until Shutdown is called.
+func (s *Server) Serve(l net.Listener) error {
+	err := s.httpServer.Serve(l)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// ServeTLS serves the REST API over TLS on the given listener until Shutdown is called.
+func (s *Server) ServeTLS(l net.Listener, certFile, keyFile string) error {
+	err := s.httpServer.ServeTLS(l, certFile, keyFile)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown gracefully stops serving the REST API.
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.httpServer.Shutdown(ctx)
+}
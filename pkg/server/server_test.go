@@ -0,0 +1,158 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/messaging/msghandler"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/didexchange"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	outofbandsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/outofband"
+	mockcrypto "github.com/hyperledger/aries-framework-go/pkg/mock/crypto"
+	mocksvc "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/protocol/didexchange"
+	mockroute "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/protocol/mediator"
+	mockkms "github.com/hyperledger/aries-framework-go/pkg/mock/kms"
+	mockprovider "github.com/hyperledger/aries-framework-go/pkg/mock/provider"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	mockvdri "github.com/hyperledger/aries-framework-go/pkg/mock/vdr"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/events"
+	mockoutofband "github.com/trustbloc/hub-router/pkg/internal/mock/outofband"
+	"github.com/trustbloc/hub-router/pkg/restapi/operation"
+	"github.com/trustbloc/hub-router/pkg/tenant"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		bus := events.NewBus()
+
+		cfg := config()
+		cfg.Events = bus
+
+		s, err := New(cfg)
+		require.NoError(t, err)
+		require.NotNil(t, s.Operation())
+		require.Equal(t, bus, s.Events())
+
+		w := httptest.NewRecorder()
+		s.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthcheck", nil))
+		require.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("api keys", func(t *testing.T) {
+		cfg := config()
+		cfg.Tenancy.APIKeys = tenant.NewKeys("operator-key", map[string]string{"tenant-1": "tenant-key"})
+
+		s, err := New(cfg)
+		require.NoError(t, err)
+
+		for path, status := range map[string]map[string]int{
+			"/healthcheck":   {"": http.StatusOK},
+			"/stats/history": {"": http.StatusUnauthorized, "tenant-key": http.StatusOK, "operator-key": http.StatusOK},
+			"/diagnostics":   {"tenant-key": http.StatusForbidden, "operator-key": http.StatusOK},
+		} {
+			for key, code := range status {
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				if key != "" {
+					req.Header.Set("Authorization", "Bearer "+key)
+				}
+
+				w := httptest.NewRecorder()
+				s.Handler().ServeHTTP(w, req)
+				require.Equal(t, code, w.Code, path+" "+key)
+			}
+		}
+	})
+
+	t.Run("missing config", func(t *testing.T) {
+		_, err := New(nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "mandatory")
+
+		_, err = New(&Config{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "mandatory")
+	})
+
+	t.Run("operation error", func(t *testing.T) {
+		cfg := config()
+		cfg.Aries = &mockprovider.Provider{
+			ProtocolStateStorageProviderValue: mockstore.NewMockStoreProvider(),
+			StorageProviderValue:              mockstore.NewMockStoreProvider(),
+		}
+
+		_, err := New(cfg)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create hub-router operations")
+	})
+}
+
+func TestServe(t *testing.T) {
+	t.Run("serve and shutdown", func(t *testing.T) {
+		s, err := New(config())
+		require.NoError(t, err)
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		errCh := make(chan error, 1)
+
+		go func() {
+			errCh <- s.Serve(l)
+		}()
+
+		resp, err := http.Get(fmt.Sprintf("http://%s/healthcheck", l.Addr())) // nolint:noctx // test
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NoError(t, resp.Body.Close())
+
+		require.NoError(t, s.Shutdown(context.Background()))
+		require.NoError(t, <-errCh)
+	})
+
+	t.Run("serve tls error", func(t *testing.T) {
+		s, err := New(config())
+		require.NoError(t, err)
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		err = s.ServeTLS(l, "invalid-cert", "invalid-key")
+		require.Error(t, err)
+	})
+}
+
+func config() *Config {
+	return &Config{
+		Aries: &mockprovider.Provider{
+			ProtocolStateStorageProviderValue: mockstore.NewMockStoreProvider(),
+			StorageProviderValue:              mockstore.NewMockStoreProvider(),
+			ServiceMap: map[string]interface{}{
+				outofbandsvc.Name:       &mockoutofband.MockService{},
+				didexchange.DIDExchange: &mocksvc.MockDIDExchangeSvc{},
+				mediator.Coordination:   &mockroute.MockMediatorSvc{},
+			},
+			KMSValue:             &mockkms.KeyManager{},
+			CryptoValue:          &mockcrypto.Crypto{},
+			ServiceEndpointValue: "endpoint",
+			VDRegistryValue:      &mockvdri.MockVDRegistry{},
+		},
+		MsgRegistrar: msghandler.NewRegistrar(),
+		Storage: &operation.Storage{
+			Persistent: mem.NewProvider(),
+			Transient:  mem.NewProvider(),
+		},
+	}
+}
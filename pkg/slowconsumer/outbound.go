@@ -0,0 +1,78 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package slowconsumer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+)
+
+// DefaultSlowLanes is the number of concurrent writes to the slow consumers when they are degraded.
+const DefaultSlowLanes = 1
+
+// WriteObserver is notified of the writes made by the Outbound transport.
+type WriteObserver interface {
+	// ObserveWrite records the latency of a successful write to the given recipient keys.
+	ObserveWrite(recipientKeys []string, latency time.Duration)
+	// Degraded returns true if the writes to the given recipient keys must go through the slow lanes.
+	Degraded(recipientKeys []string) bool
+}
+
+// Outbound wraps an outbound transport to time its writes, and to limit the concurrency of the writes to the
+// degraded slow consumers so that they do not hold up the deliveries to the other wallets.
+type Outbound struct {
+	transport.OutboundTransport
+	lanes    chan struct{}
+	mutex    sync.RWMutex
+	observer WriteObserver
+}
+
+// NewOutbound returns a new Outbound wrapping the given transport, with the given number of slow lanes.
+func NewOutbound(ot transport.OutboundTransport, slowLanes int) *Outbound {
+	if slowLanes <= 0 {
+		slowLanes = DefaultSlowLanes
+	}
+
+	return &Outbound{OutboundTransport: ot, lanes: make(chan struct{}, slowLanes)}
+}
+
+// SetObserver sets the observer of the writes; the transport is created before the observer.
+func (t *Outbound) SetObserver(observer WriteObserver) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.observer = observer
+}
+
+// Send sends the data through the wrapped transport.
+func (t *Outbound) Send(data []byte, destination *service.Destination) (string, error) {
+	t.mutex.RLock()
+	observer := t.observer
+	t.mutex.RUnlock()
+
+	if observer == nil {
+		return t.OutboundTransport.Send(data, destination)
+	}
+
+	if observer.Degraded(destination.RecipientKeys) {
+		t.lanes <- struct{}{}
+
+		defer func() { <-t.lanes }()
+	}
+
+	start := time.Now()
+
+	resp, err := t.OutboundTransport.Send(data, destination)
+	if err == nil {
+		observer.ObserveWrite(destination.RecipientKeys, time.Since(start))
+	}
+
+	return resp, err
+}
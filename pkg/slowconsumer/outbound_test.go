@@ -0,0 +1,116 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package slowconsumer
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutbound(t *testing.T) {
+	t.Run("writes are timed", func(t *testing.T) {
+		ot := &mockOutbound{delay: time.Millisecond}
+		obs := &mockObserver{}
+
+		o := NewOutbound(ot, 0)
+		require.Equal(t, DefaultSlowLanes, cap(o.lanes))
+
+		_, err := o.Send([]byte("data"), &service.Destination{RecipientKeys: []string{"key-1"}})
+		require.NoError(t, err)
+		require.Empty(t, obs.latencies)
+
+		o.SetObserver(obs)
+
+		_, err = o.Send([]byte("data"), &service.Destination{RecipientKeys: []string{"key-1"}})
+		require.NoError(t, err)
+		require.Len(t, obs.latencies, 1)
+		require.GreaterOrEqual(t, obs.latencies["key-1"], time.Millisecond)
+
+		ot.err = errors.New("send error")
+
+		_, err = o.Send([]byte("data"), &service.Destination{RecipientKeys: []string{"key-2"}})
+		require.Error(t, err)
+		require.Len(t, obs.latencies, 1)
+	})
+
+	t.Run("degraded writes go through the slow lanes", func(t *testing.T) {
+		ot := &mockOutbound{delay: 10 * time.Millisecond}
+
+		o := NewOutbound(ot, 1)
+		o.SetObserver(&mockObserver{degraded: true})
+
+		var wg sync.WaitGroup
+
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				_, err := o.Send([]byte("data"), &service.Destination{RecipientKeys: []string{"key-1"}})
+				require.NoError(t, err)
+			}()
+		}
+
+		wg.Wait()
+		require.Equal(t, 1, ot.maxConcurrent)
+	})
+}
+
+type mockOutbound struct {
+	transport.OutboundTransport
+	delay         time.Duration
+	err           error
+	mutex         sync.Mutex
+	concurrent    int
+	maxConcurrent int
+}
+
+func (m *mockOutbound) Send([]byte, *service.Destination) (string, error) {
+	m.mutex.Lock()
+	m.concurrent++
+
+	if m.concurrent > m.maxConcurrent {
+		m.maxConcurrent = m.concurrent
+	}
+	m.mutex.Unlock()
+
+	time.Sleep(m.delay)
+
+	m.mutex.Lock()
+	m.concurrent--
+	m.mutex.Unlock()
+
+	return "", m.err
+}
+
+type mockObserver struct {
+	degraded  bool
+	mutex     sync.Mutex
+	latencies map[string]time.Duration
+}
+
+func (m *mockObserver) ObserveWrite(recipientKeys []string, latency time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.latencies == nil {
+		m.latencies = make(map[string]time.Duration)
+	}
+
+	m.latencies[recipientKeys[0]] = latency
+}
+
+func (m *mockObserver) Degraded([]string) bool {
+	return m.degraded
+}
@@ -0,0 +1,211 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package slowconsumer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "slowconsumer"
+	slowTag   = "slow"
+
+	// DefaultWindow is the number of consecutive slow deliveries after which a wallet is flagged as slow consumer.
+	DefaultWindow = 5
+)
+
+// Latency kinds.
+const (
+	KindPickup         = "pickup"
+	KindWebSocketWrite = "websocket-write"
+)
+
+// ErrNotFound is returned when no latency was observed for the connection.
+var ErrNotFound = errors.New("slow consumer status not found")
+
+var logger = log.New("hub-router/slowconsumer")
+
+// Config holds the latency thresholds; a zero threshold disables the detection for the corresponding kind.
+type Config struct {
+	PickupThreshold time.Duration
+	WriteThreshold  time.Duration
+	// Window is the number of consecutive deliveries above the threshold after which a wallet is flagged.
+	Window int
+	// Degrade throttles the WebSocket writes to the slow consumers.
+	Degrade bool
+}
+
+// Enabled returns true if a threshold is configured.
+func (c *Config) Enabled() bool {
+	return c != nil && (c.PickupThreshold > 0 || c.WriteThreshold > 0)
+}
+
+// Status is the delivery latency status of a wallet.
+type Status struct {
+	ConnectionID string        `json:"connectionID"`
+	Slow         bool          `json:"slow"`
+	Kind         string        `json:"kind"`
+	Latency      time.Duration `json:"latency"`
+	Exceeded     int           `json:"exceeded"`
+	Since        time.Time     `json:"since"`
+}
+
+// Detector flags the wallets whose delivery latency consistently exceeds the thresholds.
+type Detector struct {
+	store    storage.Store
+	config   *Config
+	onChange func(*Status)
+	mutex    sync.Mutex
+}
+
+// New returns a new Detector. onChange is called whenever a wallet is flagged, or no longer flagged, as slow consumer.
+func New(p storage.Provider, config *Config, onChange func(*Status)) (*Detector, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open slow consumer store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{slowTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set slow consumer store config : %w", err)
+	}
+
+	if config.Window <= 0 {
+		config.Window = DefaultWindow
+	}
+
+	if onChange == nil {
+		onChange = func(*Status) {}
+	}
+
+	return &Detector{store: store, config: config, onChange: onChange}, nil
+}
+
+// Observe records the latency of a delivery to the wallet on the given connection.
+func (d *Detector) Observe(connectionID, kind string, latency time.Duration) error {
+	threshold := d.threshold(kind)
+	if threshold <= 0 {
+		return nil
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	s, err := d.Get(connectionID)
+	if errors.Is(err, ErrNotFound) {
+		s, err = &Status{ConnectionID: connectionID}, nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	wasSlow := s.Slow
+
+	s.Kind = kind
+	s.Latency = latency
+
+	if latency > threshold {
+		s.Exceeded++
+	} else {
+		s.Exceeded = 0
+	}
+
+	s.Slow = s.Exceeded >= d.config.Window
+
+	if s.Slow && !wasSlow {
+		s.Since = time.Now().UTC()
+	}
+
+	if !s.Slow {
+		s.Since = time.Time{}
+	}
+
+	err = d.save(s)
+	if err != nil {
+		return err
+	}
+
+	if s.Slow != wasSlow {
+		logger.Infof("slow consumer change : connectionID=%s slow=%t kind=%s latency=%s",
+			connectionID, s.Slow, kind, latency)
+
+		d.onChange(s)
+	}
+
+	return nil
+}
+
+// Get returns the latency status of the wallet on the given connection.
+func (d *Detector) Get(connectionID string) (*Status, error) {
+	statusBytes, err := d.store.Get(connectionID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get slow consumer status : %w", err)
+	}
+
+	s := &Status{}
+
+	err = json.Unmarshal(statusBytes, s)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal slow consumer status : %w", err)
+	}
+
+	return s, nil
+}
+
+// IsSlow returns true if the wallet on the given connection is flagged as slow consumer.
+func (d *Detector) IsSlow(connectionID string) bool {
+	if d == nil {
+		return false
+	}
+
+	s, err := d.Get(connectionID)
+
+	return err == nil && s.Slow
+}
+
+// Degrade returns true if the writes to the slow consumers must be throttled.
+func (d *Detector) Degrade() bool {
+	return d != nil && d.config.Degrade
+}
+
+func (d *Detector) threshold(kind string) time.Duration {
+	switch kind {
+	case KindPickup:
+		return d.config.PickupThreshold
+	case KindWebSocketWrite:
+		return d.config.WriteThreshold
+	default:
+		return 0
+	}
+}
+
+func (d *Detector) save(s *Status) error {
+	statusBytes, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("marshal slow consumer status : %w", err)
+	}
+
+	err = d.store.Put(s.ConnectionID, statusBytes, storage.Tag{Name: slowTag, Value: strconv.FormatBool(s.Slow)})
+	if err != nil {
+		return fmt.Errorf("save slow consumer status : %w", err)
+	}
+
+	return nil
+}
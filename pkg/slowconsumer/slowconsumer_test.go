@@ -0,0 +1,144 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package slowconsumer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestConfig(t *testing.T) {
+	var c *Config
+
+	require.False(t, c.Enabled())
+	require.False(t, (&Config{Window: 1, Degrade: true}).Enabled())
+	require.True(t, (&Config{WriteThreshold: time.Second}).Enabled())
+}
+
+func TestNew(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		d, err := New(mem.NewProvider(), &Config{PickupThreshold: time.Minute}, nil)
+		require.NoError(t, err)
+		require.Equal(t, DefaultWindow, d.config.Window)
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, &Config{}, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open slow consumer store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p, &Config{}, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set slow consumer store config")
+	})
+}
+
+func TestDetector(t *testing.T) {
+	t.Run("slow consumer flagged and cleared", func(t *testing.T) {
+		var changes []Status
+
+		d, err := New(mem.NewProvider(), &Config{
+			PickupThreshold: time.Minute, WriteThreshold: time.Second, Window: 2, Degrade: true,
+		}, func(s *Status) {
+			changes = append(changes, *s)
+		})
+		require.NoError(t, err)
+		require.True(t, d.Degrade())
+
+		require.NoError(t, d.Observe("conn-1", KindWebSocketWrite, 2*time.Second))
+		require.False(t, d.IsSlow("conn-1"))
+
+		require.NoError(t, d.Observe("conn-1", KindWebSocketWrite, time.Millisecond))
+		require.NoError(t, d.Observe("conn-1", KindPickup, 2*time.Minute))
+		require.False(t, d.IsSlow("conn-1"))
+		require.Empty(t, changes)
+
+		require.NoError(t, d.Observe("conn-1", KindWebSocketWrite, 3*time.Second))
+		require.True(t, d.IsSlow("conn-1"))
+		require.Len(t, changes, 1)
+		require.True(t, changes[0].Slow)
+		require.Equal(t, KindWebSocketWrite, changes[0].Kind)
+		require.False(t, changes[0].Since.IsZero())
+
+		require.NoError(t, d.Observe("conn-1", KindPickup, 3*time.Minute))
+		require.Len(t, changes, 1)
+
+		s, err := d.Get("conn-1")
+		require.NoError(t, err)
+		require.Equal(t, 3, s.Exceeded)
+		require.Equal(t, 3*time.Minute, s.Latency)
+
+		require.NoError(t, d.Observe("conn-1", KindPickup, time.Second))
+		require.False(t, d.IsSlow("conn-1"))
+		require.Len(t, changes, 2)
+		require.False(t, changes[1].Slow)
+		require.True(t, changes[1].Since.IsZero())
+	})
+
+	t.Run("disabled kind", func(t *testing.T) {
+		d, err := New(mem.NewProvider(), &Config{PickupThreshold: time.Minute, Window: 1}, nil)
+		require.NoError(t, err)
+		require.False(t, d.Degrade())
+
+		require.NoError(t, d.Observe("conn-1", KindWebSocketWrite, time.Hour))
+		require.NoError(t, d.Observe("conn-1", "unknown", time.Hour))
+
+		_, err = d.Get("conn-1")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		var nilDetector *Detector
+
+		require.False(t, nilDetector.IsSlow("conn-1"))
+		require.False(t, nilDetector.Degrade())
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		d, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+		}), &Config{PickupThreshold: time.Minute}, nil)
+		require.NoError(t, err)
+
+		err = d.Observe("conn-1", KindPickup, time.Hour)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save slow consumer status")
+
+		d, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), &Config{PickupThreshold: time.Minute}, nil)
+		require.NoError(t, err)
+
+		err = d.Observe("conn-1", KindPickup, time.Hour)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get slow consumer status")
+
+		d, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store: map[string]mockstore.DBEntry{"conn-1": {Value: []byte("invalid")}},
+		}), &Config{PickupThreshold: time.Minute}, nil)
+		require.NoError(t, err)
+
+		_, err = d.Get("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal slow consumer status")
+	})
+}
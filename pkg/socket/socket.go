@@ -0,0 +1,53 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package socket observes the wallets holding a WebSocket open to the router : a wallet that asks for the return
+// route of all its messages keeps the socket in the pool of the Aries WebSocket transport, and receives the messages
+// forwarded to it on the socket as soon as they arrive, instead of picking them up.
+package socket
+
+import (
+	"encoding/json"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/fingerprint"
+
+	"github.com/trustbloc/hub-router/pkg/inbound"
+	"github.com/trustbloc/hub-router/pkg/preparse"
+)
+
+// Observer is notified of the wallets holding a socket open to the router.
+type Observer interface {
+	// ObserveSocket records that the wallet with the given sender key (did:key) holds its socket open.
+	ObserveSocket(senderKey string)
+}
+
+// NewHandler returns the handler of the WebSocket inbound envelopes, notifying the observer of the envelopes asking
+// for the return route of all the messages.
+func NewHandler(observer Observer) inbound.Handler {
+	return func(envelope *transport.Envelope, _ *preparse.Header, next transport.InboundMessageHandler) error {
+		if len(envelope.FromKey) > 0 && returnRouteAll(envelope.Message) {
+			senderKey, _ := fingerprint.CreateDIDKey(envelope.FromKey)
+
+			observer.ObserveSocket(senderKey)
+		}
+
+		return next(envelope)
+	}
+}
+
+// returnRouteAll returns true if the message asks for the return route of all the messages, as the Aries WebSocket
+// transport does to keep the socket in its pool.
+func returnRouteAll(msg []byte) bool {
+	trans := &decorator.Transport{}
+
+	if err := json.Unmarshal(msg, trans); err != nil {
+		return false
+	}
+
+	return trans.ReturnRoute != nil && trans.ReturnRoute.Value == decorator.TransportReturnRouteAll
+}
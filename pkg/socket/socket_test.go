@@ -0,0 +1,60 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package socket
+
+import (
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/transport"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/fingerprint"
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/preparse"
+)
+
+func TestHandler(t *testing.T) {
+	var handled []*transport.Envelope
+
+	next := func(envelope *transport.Envelope) error {
+		handled = append(handled, envelope)
+
+		return nil
+	}
+
+	o := &mockObserver{}
+	handler := NewHandler(o)
+
+	returnRoute := []byte(`{"@type":"https://didcomm.org/messagepickup/1.0/status-request",` +
+		`"~transport":{"~return_route":"all"}}`)
+	fromKey := []byte("sender-key-bytes-of-32-bytes-len")
+
+	for _, envelope := range []*transport.Envelope{
+		{Message: returnRoute},
+		{Message: []byte(`{"~transport":{"~return_route":"thread"}}`), FromKey: fromKey},
+		{Message: []byte(`{}`), FromKey: fromKey},
+		{Message: []byte(`{`), FromKey: fromKey},
+	} {
+		require.NoError(t, handler(envelope, &preparse.Header{}, next))
+	}
+
+	require.Len(t, handled, 4)
+	require.Empty(t, o.keys)
+
+	require.NoError(t, handler(&transport.Envelope{Message: returnRoute, FromKey: fromKey}, &preparse.Header{}, next))
+	require.Len(t, handled, 5)
+
+	senderKey, _ := fingerprint.CreateDIDKey(fromKey)
+	require.Equal(t, []string{senderKey}, o.keys)
+}
+
+type mockObserver struct {
+	keys []string
+}
+
+func (m *mockObserver) ObserveSocket(senderKey string) {
+	m.keys = append(m.keys, senderKey)
+}
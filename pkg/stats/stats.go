@@ -0,0 +1,269 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package stats
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName = "stats"
+
+	// tag used to query all the rollups; the value is the rollup period in unix seconds.
+	periodTag = "period"
+
+	// tag used to query the rollups of a tenant; the value is the tenant ID.
+	tenantTag = "tenant"
+
+	// DefaultRetention is the period the hourly rollups are kept for.
+	DefaultRetention = 30 * 24 * time.Hour
+)
+
+// Counters.
+const (
+	ConnectionsCreated = "connections-created"
+	Forwards           = "forwards"
+	Errors             = "errors"
+)
+
+var logger = log.New("hub-router/stats")
+
+// Rollup holds the counters of an hour, global or restricted to the wallets of a tenant.
+type Rollup struct {
+	Period   time.Time      `json:"period"`
+	Tenant   string         `json:"tenant,omitempty"`
+	Counters map[string]int `json:"counters"`
+}
+
+// Store persists hourly rollups of the hub-router counters. The counters are aggregated globally, and per tenant for
+// the wallets of the tenants.
+type Store struct {
+	store     storage.Store
+	retention time.Duration
+	mutex     sync.Mutex
+	stop      chan struct{}
+	stopOnce  sync.Once
+}
+
+// New returns a new stats Store keeping the rollups for the given retention period.
+func New(p storage.Provider, retention time.Duration) (*Store, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open stats store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{periodTag, tenantTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set stats store config : %w", err)
+	}
+
+	if retention <= 0 {
+		retention = DefaultRetention
+	}
+
+	return &Store{store: store, retention: retention, stop: make(chan struct{})}, nil
+}
+
+// Incr increments the counter in the rollups of the current hour.
+func (s *Store) Incr(tenantID, counter string) error {
+	return s.Add(tenantID, time.Now(), counter, 1)
+}
+
+// Add adds n to the counter in the global rollup of the hour of t, and in the rollup of the tenant if not empty.
+func (s *Store) Add(tenantID string, t time.Time, counter string, n int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	period := t.UTC().Truncate(time.Hour)
+
+	err := s.add("", period, counter, n)
+	if err != nil || tenantID == "" {
+		return err
+	}
+
+	return s.add(tenantID, period, counter, n)
+}
+
+// History returns the rollups of the hours within [from, to), ordered by period. A zero value disables the bound.
+// The rollups are restricted to the tenant, global if the tenant ID is empty.
+func (s *Store) History(tenantID string, from, to time.Time) ([]*Rollup, error) {
+	if tenantID == "" {
+		return s.query(periodTag, from, to)
+	}
+
+	return s.query(tenantTag+":"+tenantID, from, to)
+}
+
+// TenantRollups returns the rollups of all the tenants within [from, to), ordered by period.
+func (s *Store) TenantRollups(from, to time.Time) ([]*Rollup, error) {
+	return s.query(tenantTag, from, to)
+}
+
+// Prune deletes the rollups older than the retention period.
+func (s *Store) Prune(now time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var expired []*Rollup
+
+	for _, expression := range []string{periodTag, tenantTag} {
+		rollups, err := s.query(expression, time.Time{}, now.Add(-s.retention))
+		if err != nil {
+			return err
+		}
+
+		expired = append(expired, rollups...)
+	}
+
+	for _, r := range expired {
+		err := s.store.Delete(key(r.Tenant, r.Period))
+		if err != nil {
+			return fmt.Errorf("delete stats rollup : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Start prunes the expired rollups periodically until Stop is called.
+func (s *Store) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := s.Prune(now.UTC()); err != nil {
+					logger.Warnf("stats prune : %s", err)
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic pruning.
+func (s *Store) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+func (s *Store) add(tenantID string, period time.Time, counter string, n int) error {
+	r, err := s.get(tenantID, period)
+	if err != nil {
+		return err
+	}
+
+	r.Counters[counter] += n
+
+	rollupBytes, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal stats rollup : %w", err)
+	}
+
+	tag := storage.Tag{Name: periodTag, Value: strconv.FormatInt(period.Unix(), 10)}
+	if tenantID != "" {
+		tag = storage.Tag{Name: tenantTag, Value: tenantID}
+	}
+
+	err = s.store.Put(key(tenantID, period), rollupBytes, tag)
+	if err != nil {
+		return fmt.Errorf("save stats rollup : %w", err)
+	}
+
+	return nil
+}
+
+func (s *Store) query(expression string, from, to time.Time) ([]*Rollup, error) {
+	iter, err := s.store.Query(expression)
+	if err != nil {
+		return nil, fmt.Errorf("query stats rollups : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	rollups := []*Rollup{}
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate stats rollups : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read stats rollup : %w", err)
+		}
+
+		r := &Rollup{}
+
+		err = json.Unmarshal(val, r)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal stats rollup : %w", err)
+		}
+
+		if (from.IsZero() || !r.Period.Before(from.UTC().Truncate(time.Hour))) && (to.IsZero() || r.Period.Before(to)) {
+			rollups = append(rollups, r)
+		}
+	}
+
+	sort.Slice(rollups, func(i, j int) bool {
+		return rollups[i].Period.Before(rollups[j].Period)
+	})
+
+	return rollups, nil
+}
+
+func (s *Store) get(tenantID string, period time.Time) (*Rollup, error) {
+	rollupBytes, err := s.store.Get(key(tenantID, period))
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return &Rollup{Period: period, Tenant: tenantID, Counters: make(map[string]int)}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get stats rollup : %w", err)
+	}
+
+	r := &Rollup{}
+
+	err = json.Unmarshal(rollupBytes, r)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal stats rollup : %w", err)
+	}
+
+	if r.Counters == nil {
+		r.Counters = make(map[string]int)
+	}
+
+	return r, nil
+}
+
+// key returns the key of the rollup : the period for the global rollups, prefixed with the tenant ID for the tenants.
+func key(tenantID string, period time.Time) string {
+	if tenantID == "" {
+		return period.Format(time.RFC3339)
+	}
+
+	return tenantID + "/" + period.Format(time.RFC3339)
+}
@@ -0,0 +1,163 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package stats
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), 0)
+		require.NoError(t, err)
+		require.Equal(t, DefaultRetention, s.retention)
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, time.Hour)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open stats store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p, time.Hour)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set stats store config")
+	})
+}
+
+func TestStore(t *testing.T) {
+	t.Run("rollups and retention", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), 24*time.Hour)
+		require.NoError(t, err)
+
+		now := time.Now().UTC().Truncate(time.Hour)
+
+		require.NoError(t, s.Incr("", ConnectionsCreated))
+		require.NoError(t, s.Incr("", ConnectionsCreated))
+		require.NoError(t, s.Add("", now.Add(-2*time.Hour+time.Minute), Forwards, 3))
+		require.NoError(t, s.Add("", now.Add(-48*time.Hour), Errors, 1))
+
+		rollups, err := s.History("", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, rollups, 3)
+		require.Equal(t, 1, rollups[0].Counters[Errors])
+		require.Equal(t, 3, rollups[1].Counters[Forwards])
+		require.Equal(t, now.Add(-2*time.Hour), rollups[1].Period)
+		require.Equal(t, 2, rollups[2].Counters[ConnectionsCreated])
+
+		rollups, err = s.History("", now.Add(-90*time.Minute), time.Time{})
+		require.NoError(t, err)
+		require.Len(t, rollups, 2)
+
+		require.NoError(t, s.Prune(time.Now()))
+
+		rollups, err = s.History("", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, rollups, 2)
+	})
+
+	t.Run("tenant rollups", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), 24*time.Hour)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Incr("tenant-1", ConnectionsCreated))
+		require.NoError(t, s.Incr("tenant-2", ConnectionsCreated))
+		require.NoError(t, s.Incr("", ConnectionsCreated))
+		require.NoError(t, s.Add("tenant-1", time.Now().Add(-48*time.Hour), Forwards, 2))
+
+		rollups, err := s.History("", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, rollups, 2)
+		require.Equal(t, 3, rollups[1].Counters[ConnectionsCreated])
+		require.Empty(t, rollups[1].Tenant)
+
+		rollups, err = s.History("tenant-1", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, rollups, 2)
+		require.Equal(t, 2, rollups[0].Counters[Forwards])
+		require.Equal(t, 1, rollups[1].Counters[ConnectionsCreated])
+		require.Equal(t, "tenant-1", rollups[1].Tenant)
+
+		rollups, err = s.History("tenant-3", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Empty(t, rollups)
+
+		rollups, err = s.TenantRollups(time.Now().Add(-time.Hour), time.Time{})
+		require.NoError(t, err)
+		require.Len(t, rollups, 2)
+
+		require.NoError(t, s.Prune(time.Now()))
+
+		rollups, err = s.History("tenant-1", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, rollups, 1)
+
+		rollups, err = s.History("", time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, rollups, 1)
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		s, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}), time.Hour)
+		require.NoError(t, err)
+
+		err = s.Incr("", Forwards)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save stats rollup")
+
+		_, err = s.History("", time.Time{}, time.Time{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query stats rollups")
+
+		require.Error(t, s.Prune(time.Now()))
+
+		s, err = New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), time.Hour)
+		require.NoError(t, err)
+
+		err = s.Incr("", Forwards)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get stats rollup")
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), time.Hour)
+		require.NoError(t, err)
+
+		require.NoError(t, s.Add("", time.Now().Add(-2*time.Hour), Errors, 1))
+
+		s.Start(time.Millisecond)
+		defer s.Stop()
+
+		require.Eventually(t, func() bool {
+			rollups, err := s.History("", time.Time{}, time.Time{})
+
+			return err == nil && len(rollups) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
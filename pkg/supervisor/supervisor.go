@@ -0,0 +1,175 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package supervisor tracks the permanent failures of the listeners and of the long-running goroutines of the router,
+// so that a half-broken instance reports its readiness as failing, or exits, instead of limping along : the
+// orchestration then restarts it.
+package supervisor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	// DefaultCheckThreshold is the number of consecutive failed checks after which a watched component failed.
+	DefaultCheckThreshold = 3
+	// DefaultCheckInterval is the interval the watched components are checked at.
+	DefaultCheckInterval = 10 * time.Second
+)
+
+// ErrStopped is the failure of a supervised goroutine returning without error.
+var ErrStopped = errors.New("stopped")
+
+var logger = log.New("hub-router/supervisor")
+
+// Failure of a component.
+type Failure struct {
+	Component string    `json:"component"`
+	Error     string    `json:"error"`
+	Time      time.Time `json:"time"`
+}
+
+// Supervisor tracks the components failed permanently.
+type Supervisor struct {
+	mutex    sync.RWMutex
+	failures []*Failure
+	exitCode int
+	stop     chan struct{}
+	stopOnce sync.Once
+	exit     func(int)
+	now      func() time.Time
+}
+
+// New returns a new Supervisor. Once a component failed, the process exits with the exit code, unless it is zero.
+func New(exitCode int) *Supervisor {
+	return &Supervisor{
+		exitCode: exitCode,
+		stop:     make(chan struct{}),
+		exit:     os.Exit,
+		now:      time.Now,
+	}
+}
+
+// Go runs the function in a goroutine : the function returning, or panicking, before Stop is called is a permanent
+// failure of the component.
+func (s *Supervisor) Go(component string, fn func() error) {
+	go func() {
+		err := ErrStopped
+
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic : %v", r)
+			}
+
+			if !s.stopped() {
+				s.Fail(component, err)
+			}
+		}()
+
+		if fnErr := fn(); fnErr != nil {
+			err = fnErr
+		}
+	}()
+}
+
+// Watch checks the component at the interval until Stop is called : the component failed permanently once the check
+// failed threshold times in a row.
+func (s *Supervisor) Watch(component string, check func() error, interval time.Duration, threshold int) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		failed := 0
+
+		for {
+			select {
+			case <-ticker.C:
+				err := check()
+				if err == nil {
+					failed = 0
+
+					continue
+				}
+
+				failed++
+
+				logger.Warnf("%s check failed (%d/%d) : %s", component, failed, threshold, err)
+
+				if failed >= threshold {
+					s.Fail(component, err)
+
+					return
+				}
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+}
+
+// DialCheck returns a check of the listener at the address, eg: host:port, accepting the TCP connections.
+func DialCheck(address string, timeout time.Duration) func() error {
+	return func() error {
+		conn, err := net.DialTimeout("tcp", address, timeout)
+		if err != nil {
+			return fmt.Errorf("dial %s : %w", address, err)
+		}
+
+		return conn.Close()
+	}
+}
+
+// Fail records the permanent failure of the component, and exits if the exit code is set.
+func (s *Supervisor) Fail(component string, err error) {
+	s.mutex.Lock()
+	s.failures = append(s.failures, &Failure{Component: component, Error: err.Error(), Time: s.now()})
+	s.mutex.Unlock()
+
+	logger.Errorf("%s failed permanently : %s", component, err)
+
+	if s.exitCode != 0 {
+		logger.Errorf("exiting with code %d", s.exitCode)
+
+		s.exit(s.exitCode)
+	}
+}
+
+// Failures returns the permanent failures, in the order they occurred.
+func (s *Supervisor) Failures() []*Failure {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return append([]*Failure{}, s.failures...)
+}
+
+// Ready returns false once a component failed.
+func (s *Supervisor) Ready() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return len(s.failures) == 0
+}
+
+// Stop stops the checks : the components stopped from then on are not failures.
+func (s *Supervisor) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+func (s *Supervisor) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
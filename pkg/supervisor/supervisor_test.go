@@ -0,0 +1,144 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package supervisor
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newSupervisor(exitCode int) (*Supervisor, chan int) {
+	exits := make(chan int, 1)
+
+	s := New(exitCode)
+	s.exit = func(code int) { exits <- code }
+	s.now = func() time.Time { return time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	return s, exits
+}
+
+func waitFailures(t *testing.T, s *Supervisor, n int) []*Failure {
+	t.Helper()
+
+	require.Eventually(t, func() bool { return len(s.Failures()) == n }, time.Second, time.Millisecond)
+
+	return s.Failures()
+}
+
+func TestSupervisor(t *testing.T) {
+	t.Run("goroutine failed", func(t *testing.T) {
+		s, exits := newSupervisor(0)
+		require.True(t, s.Ready())
+
+		s.Go("listener", func() error { return errors.New("channel closed") })
+		s.Go("stopped", func() error { return nil })
+		s.Go("panicked", func() error { panic("boom") })
+
+		failures := waitFailures(t, s, 3)
+		require.False(t, s.Ready())
+
+		errs := map[string]string{}
+		for _, f := range failures {
+			errs[f.Component] = f.Error
+			require.Equal(t, s.now(), f.Time)
+		}
+
+		require.Equal(t, map[string]string{
+			"listener": "channel closed", "stopped": ErrStopped.Error(), "panicked": "panic : boom",
+		}, errs)
+		require.Empty(t, exits)
+	})
+
+	t.Run("exit code", func(t *testing.T) {
+		s, exits := newSupervisor(3)
+
+		s.Go("listener", func() error { return errors.New("channel closed") })
+
+		require.Equal(t, 3, <-exits)
+		require.Len(t, s.Failures(), 1)
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		s, _ := newSupervisor(0)
+
+		done := make(chan struct{})
+
+		s.Go("listener", func() error {
+			<-done
+
+			return nil
+		})
+
+		s.Stop()
+		s.Stop()
+		close(done)
+
+		time.Sleep(10 * time.Millisecond)
+		require.True(t, s.Ready())
+	})
+}
+
+func TestWatch(t *testing.T) {
+	t.Run("threshold", func(t *testing.T) {
+		s, _ := newSupervisor(0)
+		checks := 0
+
+		s.Watch("didcomm-http", func() error {
+			checks++
+
+			// recovers once, then fails for good
+			if checks == 2 {
+				return nil
+			}
+
+			return errors.New("connection refused")
+		}, time.Millisecond, 2)
+
+		failures := waitFailures(t, s, 1)
+		require.Equal(t, "didcomm-http", failures[0].Component)
+		require.Equal(t, "connection refused", failures[0].Error)
+		require.Equal(t, 4, checks)
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		s, _ := newSupervisor(0)
+
+		s.Watch("didcomm-http", func() error { return errors.New("connection refused") }, time.Hour, 1)
+		s.Stop()
+
+		require.True(t, s.Ready())
+	})
+}
+
+func TestDialCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		for {
+			conn, acceptErr := l.Accept()
+			if acceptErr != nil {
+				return
+			}
+
+			require.NoError(t, conn.Close())
+		}
+	}()
+
+	check := DialCheck(l.Addr().String(), time.Second)
+	require.NoError(t, check())
+
+	require.NoError(t, l.Close())
+
+	err = check()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "dial "+l.Addr().String())
+}
@@ -0,0 +1,197 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package suppression tracks the IDs of the messages recently delivered to each wallet, and suppresses the duplicates
+// delivered again within the window, eg: forwarded again by a sender retrying, or replayed after a failover, so that
+// the wallets see each message at most once.
+package suppression
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	storeName    = "suppression"
+	deliveredTag = "delivered"
+)
+
+var logger = log.New("hub-router/suppression")
+
+// Stats of the suppression window.
+type Stats struct {
+	// Window is the time, in seconds, the delivered message IDs are tracked for.
+	Window int `json:"window"`
+	// Suppressed is the number of duplicate messages suppressed since the router started.
+	Suppressed uint64 `json:"suppressed"`
+}
+
+// record of a delivered message.
+type record struct {
+	Recipient string    `json:"recipient"`
+	MsgID     string    `json:"msgID"`
+	Delivered time.Time `json:"delivered"`
+}
+
+// Window tracks the messages delivered to the wallets within the window, in the given (transient) storage : the
+// storage is shared by the nodes, so that the duplicates replayed after a failover are suppressed too.
+type Window struct {
+	store      storage.Store
+	window     time.Duration
+	suppressed uint64
+	now        func() time.Time
+	stop       chan struct{}
+	stopOnce   sync.Once
+}
+
+// New returns a new suppression Window.
+func New(p storage.Provider, window time.Duration) (*Window, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open suppression store : %w", err)
+	}
+
+	err = p.SetStoreConfig(storeName, storage.StoreConfiguration{TagNames: []string{deliveredTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set suppression store config : %w", err)
+	}
+
+	return &Window{store: store, window: window, now: time.Now, stop: make(chan struct{})}, nil
+}
+
+// Duplicate returns true if the message was delivered to the recipient within the window; the duplicate is counted
+// as suppressed.
+func (w *Window) Duplicate(recipient, msgID string) (bool, error) {
+	recordBytes, err := w.store.Get(key(recipient, msgID))
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, fmt.Errorf("get delivered message : %w", err)
+	}
+
+	r := &record{}
+
+	err = json.Unmarshal(recordBytes, r)
+	if err != nil {
+		return false, fmt.Errorf("unmarshal delivered message : %w", err)
+	}
+
+	if w.now().Sub(r.Delivered) >= w.window {
+		return false, nil
+	}
+
+	atomic.AddUint64(&w.suppressed, 1)
+
+	return true, nil
+}
+
+// Delivered records the message delivered to the recipient.
+func (w *Window) Delivered(recipient, msgID string) error {
+	recordBytes, err := json.Marshal(&record{Recipient: recipient, MsgID: msgID, Delivered: w.now().UTC()})
+	if err != nil {
+		return fmt.Errorf("marshal delivered message : %w", err)
+	}
+
+	err = w.store.Put(key(recipient, msgID), recordBytes, storage.Tag{Name: deliveredTag})
+	if err != nil {
+		return fmt.Errorf("save delivered message : %w", err)
+	}
+
+	return nil
+}
+
+// Stats returns the window and the number of duplicates suppressed.
+func (w *Window) Stats() *Stats {
+	return &Stats{Window: int(w.window / time.Second), Suppressed: atomic.LoadUint64(&w.suppressed)}
+}
+
+// Sweep deletes the messages delivered before the window.
+func (w *Window) Sweep(now time.Time) error {
+	iter, err := w.store.Query(deliveredTag)
+	if err != nil {
+		return fmt.Errorf("query delivered messages : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var expired []string
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return fmt.Errorf("iterate delivered messages : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		k, err := iter.Key()
+		if err != nil {
+			return fmt.Errorf("read delivered message key : %w", err)
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return fmt.Errorf("read delivered message : %w", err)
+		}
+
+		r := &record{}
+
+		// the records that can't be read are dropped
+		if err = json.Unmarshal(val, r); err != nil || now.Sub(r.Delivered) >= w.window {
+			expired = append(expired, k)
+		}
+	}
+
+	for _, k := range expired {
+		if err = w.store.Delete(k); err != nil {
+			return fmt.Errorf("delete delivered message : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Start sweeps the delivered messages periodically until Stop is called.
+func (w *Window) Start(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+
+	go func() {
+		defer ticker.Stop()
+
+		for {
+			select {
+			case now := <-ticker.C:
+				if err := w.Sweep(now.UTC()); err != nil {
+					logger.Warnf("suppression sweep : %s", err)
+				}
+			case <-w.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic sweep.
+func (w *Window) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+}
+
+func key(recipient, msgID string) string {
+	return recipient + "|" + msgID
+}
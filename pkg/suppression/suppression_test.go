@@ -0,0 +1,156 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package suppression
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p, time.Minute)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open suppression store")
+	})
+
+	t.Run("set store config error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := New(p, time.Minute)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set suppression store config")
+	})
+}
+
+func TestWindow(t *testing.T) {
+	t.Run("duplicates suppressed within the window", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		w, err := New(p, time.Minute)
+		require.NoError(t, err)
+
+		duplicate, err := w.Duplicate("did:1", "msg-1")
+		require.NoError(t, err)
+		require.False(t, duplicate)
+
+		require.NoError(t, w.Delivered("did:1", "msg-1"))
+
+		duplicate, err = w.Duplicate("did:1", "msg-1")
+		require.NoError(t, err)
+		require.True(t, duplicate)
+
+		duplicate, err = w.Duplicate("did:2", "msg-1")
+		require.NoError(t, err)
+		require.False(t, duplicate)
+
+		// another node sharing the storage
+		other, err := New(p, time.Minute)
+		require.NoError(t, err)
+
+		duplicate, err = other.Duplicate("did:1", "msg-1")
+		require.NoError(t, err)
+		require.True(t, duplicate)
+
+		later := time.Now().Add(time.Minute)
+		w.now = func() time.Time { return later }
+
+		duplicate, err = w.Duplicate("did:1", "msg-1")
+		require.NoError(t, err)
+		require.False(t, duplicate)
+
+		require.Equal(t, &Stats{Window: 60, Suppressed: 1}, w.Stats())
+	})
+
+	t.Run("sweep", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		w, err := New(p, time.Minute)
+		require.NoError(t, err)
+
+		require.NoError(t, w.Delivered("did:1", "msg-1"))
+		require.NoError(t, w.Delivered("did:1", "msg-2"))
+
+		store, err := p.OpenStore(storeName)
+		require.NoError(t, err)
+		require.NoError(t, store.Put("invalid", []byte("{"), storage.Tag{Name: deliveredTag}))
+
+		require.NoError(t, w.Sweep(time.Now()))
+
+		_, err = store.Get("invalid")
+		require.Error(t, err)
+
+		duplicate, err := w.Duplicate("did:1", "msg-2")
+		require.NoError(t, err)
+		require.True(t, duplicate)
+
+		require.NoError(t, w.Sweep(time.Now().Add(time.Minute)))
+
+		_, err = store.Get(key("did:1", "msg-2"))
+		require.Error(t, err)
+	})
+
+	t.Run("sweep in the background", func(t *testing.T) {
+		w, err := New(mem.NewProvider(), time.Nanosecond)
+		require.NoError(t, err)
+
+		require.NoError(t, w.Delivered("did:1", "msg-1"))
+
+		w.Start(time.Millisecond)
+		defer w.Stop()
+
+		require.Eventually(t, func() bool {
+			_, err = w.store.Get(key("did:1", "msg-1"))
+
+			return err != nil
+		}, time.Second, time.Millisecond)
+
+		w.Stop()
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		w, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrGet:   errors.New("get error"),
+			ErrPut:   errors.New("put error"),
+			ErrQuery: errors.New("query error"),
+		}), time.Minute)
+		require.NoError(t, err)
+
+		_, err = w.Duplicate("did:1", "msg-1")
+		require.EqualError(t, err, "get delivered message : get error")
+
+		err = w.Delivered("did:1", "msg-1")
+		require.EqualError(t, err, "save delivered message : put error")
+
+		err = w.Sweep(time.Now())
+		require.EqualError(t, err, "query delivered messages : query error")
+
+		p := mem.NewProvider()
+
+		w, err = New(p, time.Minute)
+		require.NoError(t, err)
+
+		require.NoError(t, w.store.Put(key("did:1", "msg-1"), []byte("{")))
+
+		_, err = w.Duplicate("did:1", "msg-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal delivered message")
+	})
+}
@@ -0,0 +1,132 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package telemetry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+var logger = log.New("hub-router/telemetry")
+
+// UsageSource returns the aggregate protocol usage counts, keyed by DIDComm message type, within [from, to).
+type UsageSource func(from, to time.Time) (map[string]int, error)
+
+// Report is the anonymous usage report sent to the telemetry endpoint. It only contains aggregate,
+// non-identifying counters: no DIDs, keys, connection IDs or endpoints.
+type Report struct {
+	Version         string         `json:"version"`
+	StorageBackends []string       `json:"storageBackends"`
+	ProtocolUsage   map[string]int `json:"protocolUsage"`
+	PeriodStart     time.Time      `json:"periodStart"`
+	PeriodEnd       time.Time      `json:"periodEnd"`
+}
+
+// Config holds the telemetry configuration.
+type Config struct {
+	Endpoint        string
+	Interval        time.Duration
+	Version         string
+	StorageBackends []string
+	Usage           UsageSource
+	HTTPClient      *http.Client
+}
+
+// Reporter periodically posts usage reports to the configured endpoint.
+type Reporter struct {
+	config   *Config
+	lastSent time.Time
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// New returns a new Reporter.
+func New(config *Config) *Reporter {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{}
+	}
+
+	return &Reporter{
+		config:   config,
+		lastSent: time.Now().UTC(),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start sends a report on every interval until Stop is called.
+func (r *Reporter) Start() {
+	logger.Infof("anonymous usage telemetry enabled : endpoint=%s interval=%s", r.config.Endpoint, r.config.Interval)
+
+	go func() {
+		ticker := time.NewTicker(r.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.Send(); err != nil {
+					logger.Warnf("failed to send usage telemetry : %s", err.Error())
+				}
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the reporter.
+func (r *Reporter) Stop() {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+}
+
+// Send sends the usage report for the period since the last successful report.
+func (r *Reporter) Send() error {
+	now := time.Now().UTC()
+
+	usage, err := r.config.Usage(r.lastSent, now)
+	if err != nil {
+		return fmt.Errorf("get protocol usage : %w", err)
+	}
+
+	reportBytes, err := json.Marshal(&Report{
+		Version:         r.config.Version,
+		StorageBackends: r.config.StorageBackends,
+		ProtocolUsage:   usage,
+		PeriodStart:     r.lastSent,
+		PeriodEnd:       now,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal usage report : %w", err)
+	}
+
+	resp, err := r.config.HTTPClient.Post(r.config.Endpoint, "application/json", bytes.NewBuffer(reportBytes))
+	if err != nil {
+		return fmt.Errorf("post usage report : %w", err)
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			logger.Warnf("failed to close response body : %s", errClose.Error())
+		}
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("post usage report : unexpected status %d", resp.StatusCode)
+	}
+
+	r.lastSent = now
+
+	return nil
+}
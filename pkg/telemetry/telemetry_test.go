@@ -0,0 +1,108 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReporter(t *testing.T) {
+	t.Run("send report", func(t *testing.T) {
+		reports := make(chan *Report, 1)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			report := &Report{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(report))
+
+			reports <- report
+		}))
+		defer srv.Close()
+
+		r := New(&Config{
+			Endpoint:        srv.URL,
+			Interval:        time.Millisecond,
+			Version:         "0.1.7",
+			StorageBackends: []string{"mysql"},
+			Usage: func(from, to time.Time) (map[string]int, error) {
+				return map[string]int{"https://didcomm.org/coordinatemediation/1.0/mediate-request": 2}, nil
+			},
+		})
+
+		r.Start()
+		defer r.Stop()
+
+		select {
+		case report := <-reports:
+			require.Equal(t, "0.1.7", report.Version)
+			require.Equal(t, []string{"mysql"}, report.StorageBackends)
+			require.Equal(t, 2, report.ProtocolUsage["https://didcomm.org/coordinatemediation/1.0/mediate-request"])
+			require.True(t, report.PeriodStart.Before(report.PeriodEnd))
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "tests are not validated due to timeout")
+		}
+	})
+
+	t.Run("usage error", func(t *testing.T) {
+		r := New(&Config{
+			Usage: func(from, to time.Time) (map[string]int, error) {
+				return nil, errors.New("usage error")
+			},
+		})
+
+		err := r.Send()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get protocol usage")
+	})
+
+	t.Run("post error", func(t *testing.T) {
+		r := New(&Config{
+			Endpoint: "http://invalid.example.com:-1",
+			Usage: func(from, to time.Time) (map[string]int, error) {
+				return nil, nil
+			},
+		})
+
+		err := r.Send()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "post usage report")
+	})
+
+	t.Run("unexpected status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		r := New(&Config{
+			Endpoint: srv.URL,
+			Usage: func(from, to time.Time) (map[string]int, error) {
+				return nil, nil
+			},
+		})
+
+		lastSent := r.lastSent
+
+		err := r.Send()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unexpected status 500")
+		require.Equal(t, lastSent, r.lastSent)
+	})
+
+	t.Run("stop is idempotent", func(t *testing.T) {
+		r := New(&Config{Interval: time.Hour})
+		r.Start()
+		r.Stop()
+		r.Stop()
+	})
+}
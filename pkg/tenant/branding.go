@@ -0,0 +1,49 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tenant
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// Branding of the invitations issued under the API key of a tenant.
+type Branding struct {
+	// Label of the invitations, displayed by the wallets.
+	Label string `json:"label"`
+	// Goal is the goal text of the invitations, optional.
+	Goal string `json:"goal,omitempty"`
+	// ImageURL is the URL of the image displayed with the invitations, optional.
+	ImageURL string `json:"imageUrl,omitempty"`
+}
+
+// ParseBranding parses the branding of the tenants, a JSON object keyed by tenant ID.
+func ParseBranding(data []byte) (map[string]*Branding, error) {
+	branding := make(map[string]*Branding)
+
+	err := json.Unmarshal(data, &branding)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal branding : %w", err)
+	}
+
+	for tenantID, b := range branding {
+		if tenantID == "" || b == nil || b.Label == "" {
+			return nil, fmt.Errorf("label of tenant %q is mandatory", tenantID)
+		}
+
+		if b.ImageURL == "" {
+			continue
+		}
+
+		if u, err := url.ParseRequestURI(b.ImageURL); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid image URL of tenant %q : %s", tenantID, b.ImageURL)
+		}
+	}
+
+	return branding, nil
+}
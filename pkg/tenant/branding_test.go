@@ -0,0 +1,43 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tenant
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBranding(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		branding, err := ParseBranding([]byte(`{
+			"tenant-1":{"label":"Acme","goal":"Connect your Acme wallet","imageUrl":"https://acme.example.com/logo.png"},
+			"tenant-2":{"label":"Globex"}
+		}`))
+		require.NoError(t, err)
+		require.Equal(t, map[string]*Branding{
+			"tenant-1": {
+				Label: "Acme", Goal: "Connect your Acme wallet", ImageURL: "https://acme.example.com/logo.png",
+			},
+			"tenant-2": {Label: "Globex"},
+		}, branding)
+	})
+
+	t.Run("invalid branding", func(t *testing.T) {
+		for data, msg := range map[string]string{
+			`[]`:                    "unmarshal branding",
+			`{"tenant-1":{}}`:       "label of tenant \"tenant-1\" is mandatory",
+			`{"tenant-1":null}`:     "label of tenant \"tenant-1\" is mandatory",
+			`{"":{"label":"Acme"}}`: "label of tenant \"\" is mandatory",
+			`{"tenant-1":{"label":"Acme","imageUrl":"logo.png"}}`: "invalid image URL of tenant \"tenant-1\"",
+		} {
+			_, err := ParseBranding([]byte(data))
+			require.Error(t, err)
+			require.Contains(t, err.Error(), msg)
+		}
+	})
+}
@@ -0,0 +1,562 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tenant
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+const (
+	// TagName is the storage tag carrying the tenant of a record : with the isolation enabled, the records tagged with
+	// a tenant are stored in the namespace of the tenant.
+	TagName = "tenant"
+
+	namespaceStoreName = "tenantnamespace"
+	namespaceStoreTag  = "store"
+	namespaceSeparator = "|"
+	namespaceMaxLength = 32
+)
+
+var namespacePattern = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+// OpenFunc opens the storage provider of the namespace of the tenant.
+type OpenFunc func(tenantID, namespace string) (storage.Provider, error)
+
+// Namespace returns the storage namespace of the tenant : its ID if it is a safe database name, else a hash of its ID.
+func Namespace(tenantID string) string {
+	if len(tenantID) <= namespaceMaxLength && namespacePattern.MatchString(tenantID) {
+		return tenantID
+	}
+
+	h := sha256.Sum256([]byte(tenantID))
+
+	return "x" + hex.EncodeToString(h[:8])
+}
+
+// IsolatedProvider stores the records tagged with a tenant (TagName) in the namespace of the tenant, a separate
+// storage provider, so that the data of each tenant can be backed up, exported or deleted independently. The other
+// records are stored in the shared provider. The reads look up the shared provider, then the namespaces holding
+// records of the store.
+type IsolatedProvider struct {
+	shared     storage.Provider
+	open       OpenFunc
+	index      storage.Store
+	mutex      sync.RWMutex
+	providers  map[string]storage.Provider
+	namespaces map[string]map[string]bool
+	configs    map[string]storage.StoreConfiguration
+}
+
+// NewIsolatedProvider returns a new IsolatedProvider, loading the namespaces holding records.
+func NewIsolatedProvider(shared storage.Provider, open OpenFunc) (*IsolatedProvider, error) {
+	index, err := shared.OpenStore(namespaceStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open tenant namespace index : %w", err)
+	}
+
+	err = shared.SetStoreConfig(namespaceStoreName,
+		storage.StoreConfiguration{TagNames: []string{namespaceStoreTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set tenant namespace index config : %w", err)
+	}
+
+	p := &IsolatedProvider{
+		shared: shared, open: open, index: index, providers: make(map[string]storage.Provider),
+		namespaces: make(map[string]map[string]bool), configs: make(map[string]storage.StoreConfiguration),
+	}
+
+	iter, err := index.Query(namespaceStoreTag)
+	if err != nil {
+		return nil, fmt.Errorf("query tenant namespaces : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate tenant namespaces : %w", err)
+		}
+
+		if !ok {
+			return p, nil
+		}
+
+		key, err := iter.Key()
+		if err != nil {
+			return nil, fmt.Errorf("read tenant namespace : %w", err)
+		}
+
+		parts := strings.SplitN(key, namespaceSeparator, 2) // nolint:gomnd // store|tenant
+		if len(parts) == 2 {                                // nolint:gomnd // store|tenant
+			p.addNamespace(parts[0], parts[1])
+		}
+	}
+}
+
+func (p *IsolatedProvider) addNamespace(name, tenantID string) {
+	if p.namespaces[name] == nil {
+		p.namespaces[name] = make(map[string]bool)
+	}
+
+	p.namespaces[name][tenantID] = true
+}
+
+// OpenStore opens the store in the shared provider; the store of each namespace is opened on use.
+func (p *IsolatedProvider) OpenStore(name string) (storage.Store, error) {
+	store, err := p.shared.OpenStore(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &isolatedStore{Store: store, name: name, provider: p}, nil
+}
+
+// SetStoreConfig sets the store config in the shared provider, and in the namespaces once their store is opened.
+func (p *IsolatedProvider) SetStoreConfig(name string, config storage.StoreConfiguration) error {
+	p.mutex.Lock()
+	p.configs[name] = config
+	p.mutex.Unlock()
+
+	return p.shared.SetStoreConfig(name, config)
+}
+
+// GetStoreConfig returns the store config of the shared provider.
+func (p *IsolatedProvider) GetStoreConfig(name string) (storage.StoreConfiguration, error) {
+	return p.shared.GetStoreConfig(name)
+}
+
+// GetOpenStores returns the stores open in the shared provider.
+func (p *IsolatedProvider) GetOpenStores() []storage.Store {
+	return p.shared.GetOpenStores()
+}
+
+// Close closes the namespaces and the shared provider.
+func (p *IsolatedProvider) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for tenantID, tp := range p.providers {
+		if err := tp.Close(); err != nil {
+			logger.Warnf("failed to close the storage of tenant %s : %s", tenantID, err)
+		}
+	}
+
+	return p.shared.Close()
+}
+
+// Tenants returns the tenants whose namespace holds records, sorted.
+func (p *IsolatedProvider) Tenants() []string {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	set := make(map[string]bool)
+
+	for _, tenants := range p.namespaces {
+		for tenantID := range tenants {
+			set[tenantID] = true
+		}
+	}
+
+	return sortedKeys(set)
+}
+
+// Purge deletes the records of the namespace of the tenant.
+func (p *IsolatedProvider) Purge(tenantID string) error {
+	p.mutex.RLock()
+
+	var names []string
+
+	for name, tenants := range p.namespaces {
+		if tenants[tenantID] {
+			names = append(names, name)
+		}
+	}
+
+	p.mutex.RUnlock()
+
+	for _, name := range names {
+		store, err := p.tenantStore(tenantID, name, false)
+		if err != nil {
+			return err
+		}
+
+		err = purgeStore(store)
+		if err != nil {
+			return fmt.Errorf("purge store %s of tenant %s : %w", name, tenantID, err)
+		}
+
+		err = p.index.Delete(name + namespaceSeparator + tenantID)
+		if err != nil {
+			return fmt.Errorf("delete tenant namespace : %w", err)
+		}
+
+		p.mutex.Lock()
+		delete(p.namespaces[name], tenantID)
+		p.mutex.Unlock()
+	}
+
+	return nil
+}
+
+func purgeStore(store storage.Store) error {
+	iter, err := store.Query(TagName)
+	if err != nil {
+		return fmt.Errorf("query records : %w", err)
+	}
+
+	var keys []string
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			storage.Close(iter, logger)
+
+			return fmt.Errorf("iterate records : %w", err)
+		}
+
+		if !ok {
+			break
+		}
+
+		key, err := iter.Key()
+		if err != nil {
+			storage.Close(iter, logger)
+
+			return fmt.Errorf("read record : %w", err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	storage.Close(iter, logger)
+
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			return fmt.Errorf("delete record : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// tenantStore returns the store of the namespace of the tenant. With register, the namespace is indexed as holding
+// records of the store.
+func (p *IsolatedProvider) tenantStore(tenantID, name string, register bool) (storage.Store, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	tp, ok := p.providers[tenantID]
+	if !ok {
+		var err error
+
+		tp, err = p.open(tenantID, Namespace(tenantID))
+		if err != nil {
+			return nil, fmt.Errorf("open storage of tenant %s : %w", tenantID, err)
+		}
+
+		p.providers[tenantID] = tp
+	}
+
+	store, err := tp.OpenStore(name)
+	if err != nil {
+		return nil, fmt.Errorf("open store %s of tenant %s : %w", name, tenantID, err)
+	}
+
+	if config, ok := p.configs[name]; ok {
+		if err = tp.SetStoreConfig(name, config); err != nil {
+			return nil, fmt.Errorf("set store %s config of tenant %s : %w", name, tenantID, err)
+		}
+	}
+
+	if register && !p.namespaces[name][tenantID] {
+		err = p.index.Put(name+namespaceSeparator+tenantID, []byte(tenantID), storage.Tag{Name: namespaceStoreTag})
+		if err != nil {
+			return nil, fmt.Errorf("index tenant namespace : %w", err)
+		}
+
+		p.addNamespace(name, tenantID)
+	}
+
+	return store, nil
+}
+
+// tenantStores returns the stores of the namespaces holding records of the store, the given tenant only if set.
+func (p *IsolatedProvider) tenantStores(name, tenantID string) ([]storage.Store, error) {
+	p.mutex.RLock()
+
+	tenants := make(map[string]bool)
+
+	for t := range p.namespaces[name] {
+		if tenantID == "" || t == tenantID {
+			tenants[t] = true
+		}
+	}
+
+	p.mutex.RUnlock()
+
+	var stores []storage.Store
+
+	for _, t := range sortedKeys(tenants) {
+		store, err := p.tenantStore(t, name, false)
+		if err != nil {
+			return nil, err
+		}
+
+		stores = append(stores, store)
+	}
+
+	return stores, nil
+}
+
+type isolatedStore struct {
+	storage.Store
+	name     string
+	provider *IsolatedProvider
+}
+
+func (s *isolatedStore) Put(key string, value []byte, tags ...storage.Tag) error {
+	tenantID := tagTenant(tags)
+	if tenantID == "" {
+		return s.Store.Put(key, value, tags...)
+	}
+
+	store, err := s.provider.tenantStore(tenantID, s.name, true)
+	if err != nil {
+		return err
+	}
+
+	err = store.Put(key, value, tags...)
+	if err != nil {
+		return err
+	}
+
+	// the record may have been stored in the shared provider before the isolation was enabled
+	err = s.Store.Delete(key)
+	if err != nil && !errors.Is(err, storage.ErrDataNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *isolatedStore) Get(key string) ([]byte, error) {
+	var value []byte
+
+	err := s.lookup(key, func(store storage.Store) error {
+		var err error
+		value, err = store.Get(key)
+
+		return err
+	})
+
+	return value, err
+}
+
+func (s *isolatedStore) GetTags(key string) ([]storage.Tag, error) {
+	var tags []storage.Tag
+
+	err := s.lookup(key, func(store storage.Store) error {
+		var err error
+		tags, err = store.GetTags(key)
+
+		return err
+	})
+
+	return tags, err
+}
+
+// lookup calls get with the shared store, then with the stores of the namespaces, until the key is found.
+func (s *isolatedStore) lookup(key string, get func(storage.Store) error) error {
+	err := get(s.Store)
+	if !errors.Is(err, storage.ErrDataNotFound) {
+		return err
+	}
+
+	stores, err := s.provider.tenantStores(s.name, "")
+	if err != nil {
+		return err
+	}
+
+	for _, store := range stores {
+		err = get(store)
+		if !errors.Is(err, storage.ErrDataNotFound) {
+			return err
+		}
+	}
+
+	return fmt.Errorf("get %s : %w", key, storage.ErrDataNotFound)
+}
+
+func (s *isolatedStore) GetBulk(keys ...string) ([][]byte, error) {
+	values := make([][]byte, len(keys))
+
+	for i, key := range keys {
+		value, err := s.Get(key)
+		if err != nil && !errors.Is(err, storage.ErrDataNotFound) {
+			return nil, err
+		}
+
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+// Query queries the shared store and the stores of the namespaces, only the namespace of the tenant for a
+// tenant:<id> expression.
+func (s *isolatedStore) Query(expression string, options ...storage.QueryOption) (storage.Iterator, error) {
+	var tenantID string
+
+	if strings.HasPrefix(expression, TagName+":") {
+		tenantID = strings.TrimPrefix(expression, TagName+":")
+	}
+
+	stores, err := s.provider.tenantStores(s.name, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	iters := make([]storage.Iterator, 0, len(stores)+1)
+
+	for _, store := range append([]storage.Store{s.Store}, stores...) {
+		iter, err := store.Query(expression, options...)
+		if err != nil {
+			for _, it := range iters {
+				storage.Close(it, logger)
+			}
+
+			return nil, err
+		}
+
+		iters = append(iters, iter)
+	}
+
+	return &multiIterator{iters: iters}, nil
+}
+
+func (s *isolatedStore) Delete(key string) error {
+	err := s.Store.Delete(key)
+	if err != nil {
+		return err
+	}
+
+	stores, err := s.provider.tenantStores(s.name, "")
+	if err != nil {
+		return err
+	}
+
+	for _, store := range stores {
+		if err = store.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *isolatedStore) Batch(operations []storage.Operation) error {
+	for _, op := range operations {
+		var err error
+
+		if op.Value == nil {
+			err = s.Delete(op.Key)
+		} else {
+			err = s.Put(op.Key, op.Value, op.Tags...)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// multiIterator iterates over the iterators in turn.
+type multiIterator struct {
+	iters []storage.Iterator
+}
+
+func (m *multiIterator) Next() (bool, error) {
+	for len(m.iters) > 0 {
+		ok, err := m.iters[0].Next()
+		if err != nil || ok {
+			return ok, err
+		}
+
+		storage.Close(m.iters[0], logger)
+		m.iters = m.iters[1:]
+	}
+
+	return false, nil
+}
+
+func (m *multiIterator) Key() (string, error) {
+	if len(m.iters) == 0 {
+		return "", errors.New("iterator exhausted")
+	}
+
+	return m.iters[0].Key()
+}
+
+func (m *multiIterator) Value() ([]byte, error) {
+	if len(m.iters) == 0 {
+		return nil, errors.New("iterator exhausted")
+	}
+
+	return m.iters[0].Value()
+}
+
+func (m *multiIterator) Tags() ([]storage.Tag, error) {
+	if len(m.iters) == 0 {
+		return nil, errors.New("iterator exhausted")
+	}
+
+	return m.iters[0].Tags()
+}
+
+func (m *multiIterator) Close() error {
+	for _, it := range m.iters {
+		storage.Close(it, logger)
+	}
+
+	m.iters = nil
+
+	return nil
+}
+
+func tagTenant(tags []storage.Tag) string {
+	for _, t := range tags {
+		if t.Name == TagName {
+			return t.Value
+		}
+	}
+
+	return ""
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+
+	for k := range set {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,211 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tenant
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestNamespace(t *testing.T) {
+	require.Equal(t, "acme", Namespace("acme"))
+	require.Equal(t, "tenant_1-a", Namespace("tenant_1-a"))
+
+	ns := Namespace("Acme Corp")
+	require.Len(t, ns, 17)
+	require.Equal(t, "x", ns[:1])
+	require.Equal(t, ns, Namespace("Acme Corp"))
+	require.NotEqual(t, ns, Namespace("acme corp"))
+	require.NotEqual(t, "a123456789012345678901234567890123", Namespace("a123456789012345678901234567890123"))
+}
+
+func TestIsolatedProvider(t *testing.T) {
+	newProvider := func(t *testing.T) (*IsolatedProvider, storage.Provider, map[string]storage.Provider) {
+		t.Helper()
+
+		shared := mem.NewProvider()
+		tenants := make(map[string]storage.Provider)
+
+		p, err := NewIsolatedProvider(shared, func(tenantID, namespace string) (storage.Provider, error) {
+			require.Equal(t, Namespace(tenantID), namespace)
+
+			if tenants[tenantID] == nil {
+				tenants[tenantID] = mem.NewProvider()
+			}
+
+			return tenants[tenantID], nil
+		})
+		require.NoError(t, err)
+
+		return p, shared, tenants
+	}
+
+	t.Run("records stored in the tenant namespace", func(t *testing.T) {
+		p, shared, tenants := newProvider(t)
+
+		store, err := p.OpenStore("audit")
+		require.NoError(t, err)
+		require.NoError(t, p.SetStoreConfig("audit", storage.StoreConfiguration{TagNames: []string{"time", TagName}}))
+
+		require.NoError(t, store.Put("k1", []byte("shared"), storage.Tag{Name: "time"}))
+		require.NoError(t, store.Put("k2", []byte("acme"), storage.Tag{Name: "time"},
+			storage.Tag{Name: TagName, Value: "acme"}))
+		require.NoError(t, store.Put("k3", []byte("beta"), storage.Tag{Name: "time"},
+			storage.Tag{Name: TagName, Value: "beta"}))
+		require.Equal(t, []string{"acme", "beta"}, p.Tenants())
+
+		sharedStore, err := shared.OpenStore("audit")
+		require.NoError(t, err)
+
+		_, err = sharedStore.Get("k2")
+		require.True(t, errors.Is(err, storage.ErrDataNotFound))
+
+		acmeStore, err := tenants["acme"].OpenStore("audit")
+		require.NoError(t, err)
+
+		value, err := acmeStore.Get("k2")
+		require.NoError(t, err)
+		require.Equal(t, "acme", string(value))
+
+		config, err := tenants["acme"].GetStoreConfig("audit")
+		require.NoError(t, err)
+		require.Equal(t, []string{"time", TagName}, config.TagNames)
+
+		value, err = store.Get("k3")
+		require.NoError(t, err)
+		require.Equal(t, "beta", string(value))
+
+		tags, err := store.GetTags("k3")
+		require.NoError(t, err)
+		require.Len(t, tags, 2)
+
+		_, err = store.Get("k4")
+		require.True(t, errors.Is(err, storage.ErrDataNotFound))
+
+		values, err := store.GetBulk("k1", "k2", "k4")
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{[]byte("shared"), []byte("acme"), nil}, values)
+
+		require.Equal(t, []string{"k1", "k2", "k3"}, queryKeys(t, store, "time"))
+		require.Equal(t, []string{"k2"}, queryKeys(t, store, TagName+":acme"))
+		require.Equal(t, []string{"k2", "k3"}, queryKeys(t, store, TagName))
+
+		require.NoError(t, store.Batch([]storage.Operation{
+			{Key: "k2"}, {Key: "k5", Value: []byte("acme"), Tags: []storage.Tag{{Name: TagName, Value: "acme"}}},
+		}))
+		require.Equal(t, []string{"k5"}, queryKeys(t, store, TagName+":acme"))
+
+		require.NoError(t, p.Close())
+	})
+
+	t.Run("record moved from the shared storage", func(t *testing.T) {
+		p, shared, _ := newProvider(t)
+
+		sharedStore, err := shared.OpenStore("stats")
+		require.NoError(t, err)
+		require.NoError(t, sharedStore.Put("k1", []byte("old"), storage.Tag{Name: TagName, Value: "acme"}))
+
+		store, err := p.OpenStore("stats")
+		require.NoError(t, err)
+		require.NoError(t, store.Put("k1", []byte("new"), storage.Tag{Name: TagName, Value: "acme"}))
+
+		value, err := store.Get("k1")
+		require.NoError(t, err)
+		require.Equal(t, "new", string(value))
+	})
+
+	t.Run("purge and reload", func(t *testing.T) {
+		p, shared, tenants := newProvider(t)
+
+		store, err := p.OpenStore("stats")
+		require.NoError(t, err)
+		require.NoError(t, store.Put("k1", []byte("acme"), storage.Tag{Name: TagName, Value: "acme"}))
+		require.NoError(t, store.Put("k2", []byte("beta"), storage.Tag{Name: TagName, Value: "beta"}))
+
+		reloaded, err := NewIsolatedProvider(shared, func(tenantID, _ string) (storage.Provider, error) {
+			return tenants[tenantID], nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"acme", "beta"}, reloaded.Tenants())
+
+		store, err = reloaded.OpenStore("stats")
+		require.NoError(t, err)
+
+		require.NoError(t, reloaded.Purge("acme"))
+		require.NoError(t, reloaded.Purge("unknown"))
+		require.Equal(t, []string{"beta"}, reloaded.Tenants())
+		require.Equal(t, []string{"k2"}, queryKeys(t, store, TagName))
+
+		_, err = store.Get("k1")
+		require.True(t, errors.Is(err, storage.ErrDataNotFound))
+	})
+
+	t.Run("error opening the tenant storage", func(t *testing.T) {
+		p, err := NewIsolatedProvider(mem.NewProvider(), func(string, string) (storage.Provider, error) {
+			return nil, errors.New("open error")
+		})
+		require.NoError(t, err)
+
+		store, err := p.OpenStore("stats")
+		require.NoError(t, err)
+
+		err = store.Put("k1", []byte("acme"), storage.Tag{Name: TagName, Value: "acme"})
+		require.EqualError(t, err, "open storage of tenant acme : open error")
+		require.Empty(t, p.Tenants())
+	})
+
+	t.Run("error opening the namespace index", func(t *testing.T) {
+		_, err := NewIsolatedProvider(&mockstorage.MockProvider{OpenStoreErr: errors.New("open error")}, nil)
+		require.EqualError(t, err, "open tenant namespace index : open error")
+	})
+
+	t.Run("error setting the namespace index config", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err := NewIsolatedProvider(p, nil)
+		require.EqualError(t, err, "set tenant namespace index config : config error")
+	})
+}
+
+func queryKeys(t *testing.T, store storage.Store, expression string) []string {
+	t.Helper()
+
+	iter, err := store.Query(expression)
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, iter.Close())
+	}()
+
+	var keys []string
+
+	for {
+		ok, err := iter.Next()
+		require.NoError(t, err)
+
+		if !ok {
+			break
+		}
+
+		key, err := iter.Key()
+		require.NoError(t, err)
+
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
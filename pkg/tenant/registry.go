@@ -0,0 +1,460 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tenant
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+// States of the tenants.
+const (
+	// StateActive is the state of the tenants served normally.
+	StateActive = "active"
+	// StateSuspended is the state of the tenants whose wallets get no new mediation, and no forward.
+	StateSuspended = "suspended"
+)
+
+const (
+	registryStoreName = "tenants"
+	registryTag       = "tenant"
+	lockPrefix        = "tenant-"
+	apiKeySize        = 32
+
+	// AnyRevision applies the changes whatever the revision of the tenant.
+	AnyRevision = -1
+)
+
+var (
+	// ErrNotFound is returned when the tenant is not registered.
+	ErrNotFound = errors.New("tenant not found")
+	// ErrInvalidState is returned for an unknown tenant state.
+	ErrInvalidState = errors.New("invalid tenant state")
+	// ErrSuspended is returned for the requests of a suspended tenant.
+	ErrSuspended = errors.New("tenant suspended")
+	// ErrRevisionConflict is returned for the changes expecting another revision of the tenant.
+	ErrRevisionConflict = errors.New("tenant revision conflict")
+)
+
+var logger = log.New("hub-router/tenant")
+
+// Tenant registered with the router.
+type Tenant struct {
+	ID      string    `json:"id"`
+	State   string    `json:"state"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+	// KeyHash is the SHA-256 of the API key issued by the router, empty if the tenant has a key configured at startup.
+	KeyHash string `json:"keyHash,omitempty"`
+	// Revision is incremented on each change of the tenant, from 1 when registered.
+	Revision int `json:"revision"`
+}
+
+// DefaultRefreshInterval is the time after which the cached tenants are reloaded from the storage, so that the changes
+// made by the other nodes sharing the storage are seen.
+const DefaultRefreshInterval = 5 * time.Second
+
+// Registry persists the tenants and their state. The tenants are cached, so that the state checks and the API key
+// resolution don't hit the storage, and reloaded once the refresh interval elapsed. The changes are checked against the
+// tenant stored, while its lock is held, and the API reads go through to the storage.
+type Registry struct {
+	store   storage.Store
+	locker  lock.Locker
+	refresh time.Duration
+	now     func() time.Time
+	// reloadMutex serializes the reloads of the cache.
+	reloadMutex sync.Mutex
+	mutex       sync.RWMutex
+	tenants     map[string]*Tenant
+	keys        map[string]string
+	loaded      time.Time
+}
+
+// RegistryOption configures the Registry.
+type RegistryOption func(r *Registry)
+
+// WithRefreshInterval sets the time after which the cached tenants are reloaded, DefaultRefreshInterval by default.
+func WithRefreshInterval(interval time.Duration) RegistryOption {
+	return func(r *Registry) {
+		r.refresh = interval
+	}
+}
+
+// NewRegistry returns a new tenant Registry, loading the registered tenants. The locker serializes the changes of a
+// tenant by the nodes sharing the storage.
+func NewRegistry(p storage.Provider, locker lock.Locker, opts ...RegistryOption) (*Registry, error) {
+	store, err := p.OpenStore(registryStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open tenant registry : %w", err)
+	}
+
+	err = p.SetStoreConfig(registryStoreName, storage.StoreConfiguration{TagNames: []string{registryTag}})
+	if err != nil {
+		return nil, fmt.Errorf("set tenant registry config : %w", err)
+	}
+
+	r := &Registry{store: store, locker: locker, refresh: DefaultRefreshInterval, now: time.Now}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	err = r.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// reload replaces the cached tenants with the tenants stored.
+func (r *Registry) reload() error {
+	now := r.now()
+
+	tenants, err := r.query()
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.tenants, r.keys, r.loaded = make(map[string]*Tenant), make(map[string]string), now
+
+	for _, t := range tenants {
+		r.cache(t)
+	}
+
+	return nil
+}
+
+// fresh reloads the cached tenants once the refresh interval elapsed. The cache is kept if the storage fails, until
+// the next refresh.
+func (r *Registry) fresh() {
+	if !r.stale() {
+		return
+	}
+
+	r.reloadMutex.Lock()
+	defer r.reloadMutex.Unlock()
+
+	// reloaded while waiting for the mutex
+	if !r.stale() {
+		return
+	}
+
+	if err := r.reload(); err != nil {
+		logger.Warnf("failed to reload the tenants : %s", err)
+
+		r.mutex.Lock()
+		r.loaded = r.now()
+		r.mutex.Unlock()
+	}
+}
+
+func (r *Registry) stale() bool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.now().Sub(r.loaded) > r.refresh
+}
+
+func (r *Registry) query() ([]*Tenant, error) {
+	iter, err := r.store.Query(registryTag)
+	if err != nil {
+		return nil, fmt.Errorf("query tenants : %w", err)
+	}
+
+	defer storage.Close(iter, logger)
+
+	var tenants []*Tenant
+
+	for {
+		ok, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("iterate tenants : %w", err)
+		}
+
+		if !ok {
+			return tenants, nil
+		}
+
+		val, err := iter.Value()
+		if err != nil {
+			return nil, fmt.Errorf("read tenant : %w", err)
+		}
+
+		t := &Tenant{}
+
+		err = json.Unmarshal(val, t)
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal tenant : %w", err)
+		}
+
+		tenants = append(tenants, t)
+	}
+}
+
+// Put registers the tenant in the given state, or changes the state of the registered tenant. If issueKey is true, an
+// API key is issued to the new tenant, returned once. It returns true if the tenant is new.
+func (r *Registry) Put(id, state string, issueKey bool) (*Tenant, string, bool, error) {
+	return r.PutIf(id, state, issueKey, AnyRevision)
+}
+
+// PutIf is Put, applied if the tenant is registered with the revision, or not registered with the revision 0, or
+// whatever its revision with AnyRevision : ErrRevisionConflict is returned otherwise. The revision is checked against
+// the tenant stored.
+func (r *Registry) PutIf(id, state string, issueKey bool, revision int) (*Tenant, string, bool, error) {
+	if state != StateActive && state != StateSuspended {
+		return nil, "", false, fmt.Errorf("%w : %q", ErrInvalidState, state)
+	}
+
+	unlock, err := r.locker.Lock(lockPrefix + id)
+	if err != nil {
+		return nil, "", false, fmt.Errorf("lock tenant : %w", err)
+	}
+
+	defer unlock()
+
+	t, err := r.read(id)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, "", false, err
+	}
+
+	if err = checkRevision(t, revision); err != nil {
+		return nil, "", false, err
+	}
+
+	now := time.Now().UTC()
+
+	if t != nil {
+		updated := *t
+		updated.State = state
+		updated.Updated = now
+		updated.Revision++
+
+		return &updated, "", false, r.save(&updated)
+	}
+
+	t = &Tenant{ID: id, State: state, Created: now, Updated: now, Revision: 1}
+
+	var apiKey string
+
+	if issueKey {
+		keyBytes := make([]byte, apiKeySize)
+
+		if _, err := rand.Read(keyBytes); err != nil {
+			return nil, "", false, fmt.Errorf("generate api key : %w", err)
+		}
+
+		apiKey = base64.RawURLEncoding.EncodeToString(keyBytes)
+		t.KeyHash = hashKey(apiKey)
+	}
+
+	return t, apiKey, true, r.save(t)
+}
+
+func checkRevision(t *Tenant, revision int) error {
+	switch {
+	case revision == AnyRevision:
+		return nil
+	case t == nil && revision == 0:
+		return nil
+	case t == nil:
+		return fmt.Errorf("%w : tenant not registered", ErrRevisionConflict)
+	case t.Revision != revision:
+		return fmt.Errorf("%w : current revision is %d", ErrRevisionConflict, t.Revision)
+	default:
+		return nil
+	}
+}
+
+// read returns the tenant stored, ErrNotFound if not registered.
+func (r *Registry) read(id string) (*Tenant, error) {
+	tenantBytes, err := r.store.Get(id)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get tenant : %w", err)
+	}
+
+	t := &Tenant{}
+
+	if err = json.Unmarshal(tenantBytes, t); err != nil {
+		return nil, fmt.Errorf("unmarshal tenant : %w", err)
+	}
+
+	return t, nil
+}
+
+func (r *Registry) save(t *Tenant) error {
+	tenantBytes, err := json.Marshal(t)
+	if err != nil {
+		return fmt.Errorf("marshal tenant : %w", err)
+	}
+
+	err = r.store.Put(t.ID, tenantBytes, storage.Tag{Name: registryTag})
+	if err != nil {
+		return fmt.Errorf("save tenant : %w", err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.cache(t)
+
+	return nil
+}
+
+func (r *Registry) cache(t *Tenant) {
+	r.tenants[t.ID] = t
+
+	if t.KeyHash != "" {
+		r.keys[t.KeyHash] = t.ID
+	}
+}
+
+func (r *Registry) uncache(id string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if t, ok := r.tenants[id]; ok {
+		delete(r.keys, t.KeyHash)
+		delete(r.tenants, id)
+	}
+}
+
+// Get returns the registered tenant, read from the storage, ErrNotFound if not registered.
+func (r *Registry) Get(id string) (*Tenant, error) {
+	t, err := r.read(id)
+	if errors.Is(err, ErrNotFound) {
+		r.uncache(id)
+	}
+
+	return t, err
+}
+
+// List returns the registered tenants, read from the storage, ordered by ID.
+func (r *Registry) List() ([]*Tenant, error) {
+	tenants, err := r.query()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].ID < tenants[j].ID
+	})
+
+	return tenants, nil
+}
+
+// Delete unregisters the tenant, revoking the API key issued by the router. It returns ErrNotFound if not registered.
+func (r *Registry) Delete(id string) (*Tenant, error) {
+	return r.DeleteIf(id, AnyRevision)
+}
+
+// DeleteIf is Delete, applied if the tenant has the revision, or whatever its revision with AnyRevision :
+// ErrRevisionConflict is returned otherwise. The revision is checked against the tenant stored.
+func (r *Registry) DeleteIf(id string, revision int) (*Tenant, error) {
+	unlock, err := r.locker.Lock(lockPrefix + id)
+	if err != nil {
+		return nil, fmt.Errorf("lock tenant : %w", err)
+	}
+
+	defer unlock()
+
+	t, err := r.read(id)
+	if errors.Is(err, ErrNotFound) {
+		r.uncache(id)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkRevision(t, revision); err != nil {
+		return nil, err
+	}
+
+	err = r.store.Delete(id)
+	if err != nil {
+		return nil, fmt.Errorf("delete tenant : %w", err)
+	}
+
+	r.uncache(id)
+
+	return t, nil
+}
+
+// Resolve returns the tenant the API key was issued to by the router. It returns false for an unknown key.
+func (r *Registry) Resolve(apiKey string) (string, bool) {
+	if apiKey == "" {
+		return "", false
+	}
+
+	r.fresh()
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	tenantID, ok := r.keys[hashKey(apiKey)]
+
+	return tenantID, ok
+}
+
+// Suspended returns true if the tenant is suspended. The tenants not registered are active.
+func (r *Registry) Suspended(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	r.fresh()
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	t, ok := r.tenants[id]
+
+	return ok && t.State == StateSuspended
+}
+
+// AnySuspended returns true if a tenant is suspended.
+func (r *Registry) AnySuspended() bool {
+	r.fresh()
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, t := range r.tenants {
+		if t.State == StateSuspended {
+			return true
+		}
+	}
+
+	return false
+}
+
+func hashKey(apiKey string) string {
+	h := sha256.Sum256([]byte(apiKey))
+
+	return hex.EncodeToString(h[:])
+}
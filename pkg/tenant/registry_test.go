@@ -0,0 +1,327 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tenant
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+type failingLocker struct{}
+
+func (l *failingLocker) Lock(string) (func(), error) {
+	return nil, errors.New("lock timeout")
+}
+
+func TestRegistry(t *testing.T) {
+	t.Run("lifecycle", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		r, err := NewRegistry(p, lock.NewLocal())
+		require.NoError(t, err)
+		require.False(t, r.AnySuspended())
+
+		tenants, err := r.List()
+		require.NoError(t, err)
+		require.Empty(t, tenants)
+
+		acme, apiKey, created, err := r.Put("acme", StateActive, true)
+		require.NoError(t, err)
+		require.True(t, created)
+		require.NotEmpty(t, apiKey)
+		require.Equal(t, StateActive, acme.State)
+
+		tenantID, ok := r.Resolve(apiKey)
+		require.True(t, ok)
+		require.Equal(t, "acme", tenantID)
+
+		_, ok = r.Resolve("invalid")
+		require.False(t, ok)
+
+		_, ok = r.Resolve("")
+		require.False(t, ok)
+
+		_, apiKey2, created, err := r.Put("tenant-1", StateSuspended, false)
+		require.NoError(t, err)
+		require.True(t, created)
+		require.Empty(t, apiKey2)
+		require.True(t, r.Suspended("tenant-1"))
+		require.False(t, r.Suspended("acme"))
+		require.False(t, r.Suspended(""))
+		require.True(t, r.AnySuspended())
+
+		updated, noKey, created, err := r.Put("acme", StateSuspended, true)
+		require.NoError(t, err)
+		require.False(t, created)
+		require.Empty(t, noKey)
+		require.Equal(t, acme.Created, updated.Created)
+		require.True(t, r.Suspended("acme"))
+
+		// reloaded from the storage
+		r, err = NewRegistry(p, lock.NewLocal())
+		require.NoError(t, err)
+
+		tenants, err = r.List()
+		require.NoError(t, err)
+		require.Len(t, tenants, 2)
+		require.Equal(t, "acme", tenants[0].ID)
+		require.Equal(t, StateSuspended, tenants[0].State)
+		require.Equal(t, "tenant-1", tenants[1].ID)
+
+		tenantID, ok = r.Resolve(apiKey)
+		require.True(t, ok)
+		require.Equal(t, "acme", tenantID)
+
+		deleted, err := r.Delete("acme")
+		require.NoError(t, err)
+		require.Equal(t, "acme", deleted.ID)
+
+		_, ok = r.Resolve(apiKey)
+		require.False(t, ok)
+
+		_, err = r.Get("acme")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		_, err = r.Delete("acme")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		got, err := r.Get("tenant-1")
+		require.NoError(t, err)
+		require.Equal(t, StateSuspended, got.State)
+	})
+
+	t.Run("revisions", func(t *testing.T) {
+		r, err := NewRegistry(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		_, _, _, err = r.PutIf("acme", StateActive, false, 1)
+		require.True(t, errors.Is(err, ErrRevisionConflict))
+		require.Contains(t, err.Error(), "tenant not registered")
+
+		acme, _, _, err := r.PutIf("acme", StateActive, false, 0)
+		require.NoError(t, err)
+		require.Equal(t, 1, acme.Revision)
+
+		_, _, _, err = r.PutIf("acme", StateActive, false, 0)
+		require.True(t, errors.Is(err, ErrRevisionConflict))
+
+		acme, _, _, err = r.PutIf("acme", StateSuspended, false, 1)
+		require.NoError(t, err)
+		require.Equal(t, 2, acme.Revision)
+
+		_, _, _, err = r.PutIf("acme", StateActive, false, 1)
+		require.True(t, errors.Is(err, ErrRevisionConflict))
+		require.Contains(t, err.Error(), "current revision is 2")
+
+		_, err = r.DeleteIf("acme", 1)
+		require.True(t, errors.Is(err, ErrRevisionConflict))
+
+		_, err = r.DeleteIf("acme", 2)
+		require.NoError(t, err)
+
+		_, err = r.DeleteIf("acme", 2)
+		require.True(t, errors.Is(err, ErrNotFound))
+	})
+
+	t.Run("replicas", func(t *testing.T) {
+		p := mem.NewProvider()
+		locker := lock.NewLocal()
+
+		replica1, err := NewRegistry(p, locker)
+		require.NoError(t, err)
+
+		replica2, err := NewRegistry(p, locker, WithRefreshInterval(time.Minute))
+		require.NoError(t, err)
+
+		_, apiKey, _, err := replica1.Put("acme", StateActive, true)
+		require.NoError(t, err)
+
+		// the revision is checked against the tenant stored, not the tenant cached by the replica
+		acme, _, _, err := replica2.PutIf("acme", StateSuspended, false, 1)
+		require.NoError(t, err)
+		require.Equal(t, 2, acme.Revision)
+
+		_, _, _, err = replica1.PutIf("acme", StateActive, false, 1)
+		require.True(t, errors.Is(err, ErrRevisionConflict))
+		require.Contains(t, err.Error(), "current revision is 2")
+
+		got, err := replica1.Get("acme")
+		require.NoError(t, err)
+		require.Equal(t, StateSuspended, got.State)
+
+		// the state checks see the changes of the other replica once the cache is refreshed
+		require.False(t, replica1.Suspended("tenant-1"))
+
+		_, _, _, err = replica2.Put("tenant-1", StateSuspended, false)
+		require.NoError(t, err)
+		require.False(t, replica1.Suspended("tenant-1"))
+
+		refreshed := time.Now().Add(DefaultRefreshInterval + time.Second)
+		replica1.now = func() time.Time { return refreshed }
+
+		require.True(t, replica1.Suspended("tenant-1"))
+		require.True(t, replica1.AnySuspended())
+
+		_, err = replica2.Delete("acme")
+		require.NoError(t, err)
+
+		tenantID, ok := replica1.Resolve(apiKey)
+		require.True(t, ok)
+		require.Equal(t, "acme", tenantID)
+
+		refreshed = refreshed.Add(DefaultRefreshInterval + time.Second)
+
+		_, ok = replica1.Resolve(apiKey)
+		require.False(t, ok)
+
+		_, err = replica1.DeleteIf("acme", 2)
+		require.True(t, errors.Is(err, ErrNotFound))
+	})
+
+	t.Run("concurrent changes", func(t *testing.T) {
+		p := mem.NewProvider()
+		locker := lock.NewLocal()
+
+		replica1, err := NewRegistry(p, locker)
+		require.NoError(t, err)
+
+		replica2, err := NewRegistry(p, locker)
+		require.NoError(t, err)
+
+		_, _, _, err = replica1.Put("acme", StateActive, false)
+		require.NoError(t, err)
+
+		const changes = 10
+
+		errs := make(chan error, changes)
+
+		for i := 0; i < changes; i++ {
+			r := replica1
+			if i%2 == 1 {
+				r = replica2
+			}
+
+			go func() {
+				_, _, _, putErr := r.PutIf("acme", StateSuspended, false, 1)
+				errs <- putErr
+			}()
+		}
+
+		applied := 0
+
+		for i := 0; i < changes; i++ {
+			if err := <-errs; err == nil {
+				applied++
+			} else {
+				require.True(t, errors.Is(err, ErrRevisionConflict))
+			}
+		}
+
+		require.Equal(t, 1, applied)
+	})
+
+	t.Run("invalid state", func(t *testing.T) {
+		r, err := NewRegistry(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		_, _, _, err = r.Put("acme", "closed", false)
+		require.ErrorIs(t, err, ErrInvalidState)
+	})
+
+	t.Run("storage errors", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := NewRegistry(p, lock.NewLocal())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open tenant registry")
+
+		p = mockstorage.NewMockProvider()
+		p.SetStoreConfigErr = errors.New("config error")
+
+		_, err = NewRegistry(p, lock.NewLocal())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "set tenant registry config")
+
+		_, err = NewRegistry(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:    make(map[string]mockstore.DBEntry),
+			ErrQuery: errors.New("query error"),
+		}), lock.NewLocal())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "query tenants")
+
+		s := &mockstore.MockStore{Store: make(map[string]mockstore.DBEntry)}
+
+		r, err := NewRegistry(mockstore.NewCustomMockStoreProvider(s), lock.NewLocal())
+		require.NoError(t, err)
+
+		_, _, _, err = r.Put("acme", StateActive, false)
+		require.NoError(t, err)
+
+		s.ErrPut = errors.New("put error")
+
+		_, _, _, err = r.Put("tenant-1", StateActive, false)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save tenant")
+
+		s.ErrDelete = errors.New("delete error")
+
+		_, err = r.Delete("acme")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "delete tenant")
+
+		s.ErrGet = errors.New("get error")
+
+		_, err = r.Get("acme")
+		require.EqualError(t, err, "get tenant : get error")
+
+		_, _, _, err = r.Put("acme", StateSuspended, false)
+		require.EqualError(t, err, "get tenant : get error")
+
+		_, err = r.Delete("acme")
+		require.EqualError(t, err, "get tenant : get error")
+
+		// the cache is kept until the next refresh if the storage fails
+		s.ErrQuery = errors.New("query error")
+
+		_, err = r.List()
+		require.EqualError(t, err, "query tenants : query error")
+
+		refreshed := time.Now().Add(DefaultRefreshInterval + time.Second)
+		r.now = func() time.Time { return refreshed }
+
+		require.False(t, r.Suspended("acme"))
+		require.Equal(t, refreshed, r.loaded)
+
+		r.locker = &failingLocker{}
+
+		_, _, _, err = r.Put("acme", StateSuspended, false)
+		require.EqualError(t, err, "lock tenant : lock timeout")
+
+		_, err = r.Delete("acme")
+		require.EqualError(t, err, "lock tenant : lock timeout")
+
+		mp := mem.NewProvider()
+
+		store, err := mp.OpenStore(registryStoreName)
+		require.NoError(t, err)
+		require.NoError(t, store.Put("acme", []byte("{"), storage.Tag{Name: registryTag}))
+
+		_, err = NewRegistry(mp, lock.NewLocal())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal tenant")
+	})
+}
@@ -0,0 +1,150 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tenant
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+const storeName = "tenant"
+
+type contextKey struct{}
+
+// Keys holds the REST API keys : the operator key, with access to the global numbers, and the tenant keys, scoped
+// to the wallets of their tenant.
+type Keys struct {
+	operator string
+	tenants  map[string]string
+}
+
+// NewKeys returns the REST API keys, the tenant keys are indexed by tenant ID.
+func NewKeys(operatorKey string, tenantKeys map[string]string) *Keys {
+	return &Keys{operator: operatorKey, tenants: tenantKeys}
+}
+
+// Enabled returns true if an API key is configured.
+func (k *Keys) Enabled() bool {
+	return k != nil && (k.operator != "" || len(k.tenants) > 0)
+}
+
+// Resolve returns the tenant ID of the API key, empty for the operator key. It returns false for an unknown key.
+func (k *Keys) Resolve(apiKey string) (string, bool) {
+	if apiKey == "" {
+		return "", false
+	}
+
+	if k.operator != "" && subtle.ConstantTimeCompare([]byte(apiKey), []byte(k.operator)) == 1 {
+		return "", true
+	}
+
+	for tenantID, key := range k.tenants {
+		if subtle.ConstantTimeCompare([]byte(apiKey), []byte(key)) == 1 {
+			return tenantID, true
+		}
+	}
+
+	return "", false
+}
+
+// Configured returns true if the tenant has an API key.
+func (k *Keys) Configured(tenantID string) bool {
+	if k == nil {
+		return false
+	}
+
+	_, ok := k.tenants[tenantID]
+
+	return ok
+}
+
+// Tenants returns the IDs of the tenants with an API key, sorted.
+func (k *Keys) Tenants() []string {
+	if k == nil {
+		return nil
+	}
+
+	tenantIDs := make([]string, 0, len(k.tenants))
+
+	for tenantID := range k.tenants {
+		tenantIDs = append(tenantIDs, tenantID)
+	}
+
+	sort.Strings(tenantIDs)
+
+	return tenantIDs
+}
+
+// WithTenant returns a copy of the context scoped to the tenant.
+func WithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, contextKey{}, tenantID)
+}
+
+// FromContext returns the tenant the context is scoped to, empty for the operator.
+func FromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(contextKey{}).(string) // nolint:errcheck // empty for the operator
+
+	return tenantID
+}
+
+// Store assigns the invitations, connections and DIDs to the tenant they were created for.
+type Store struct {
+	store storage.Store
+}
+
+// New returns a new tenant Store.
+func New(p storage.Provider) (*Store, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open tenant store : %w", err)
+	}
+
+	return &Store{store: store}, nil
+}
+
+// Assign assigns the IDs to the tenant. Nothing is assigned to the operator.
+func (s *Store) Assign(tenantID string, ids ...string) error {
+	if tenantID == "" {
+		return nil
+	}
+
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+
+		err := s.store.Put(id, []byte(tenantID))
+		if err != nil {
+			return fmt.Errorf("save tenant assignment : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Get returns the tenant the ID is assigned to, empty if not assigned.
+func (s *Store) Get(id string) (string, error) {
+	if id == "" {
+		return "", nil
+	}
+
+	tenantBytes, err := s.store.Get(id)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return "", nil
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("get tenant assignment : %w", err)
+	}
+
+	return string(tenantBytes), nil
+}
@@ -0,0 +1,103 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package tenant
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+)
+
+func TestKeys(t *testing.T) {
+	t.Run("resolve", func(t *testing.T) {
+		keys := NewKeys("operator-key", map[string]string{"tenant-1": "key-1", "tenant-2": "key-2"})
+		require.True(t, keys.Enabled())
+
+		tenantID, ok := keys.Resolve("operator-key")
+		require.True(t, ok)
+		require.Empty(t, tenantID)
+
+		tenantID, ok = keys.Resolve("key-2")
+		require.True(t, ok)
+		require.Equal(t, "tenant-2", tenantID)
+
+		_, ok = keys.Resolve("invalid")
+		require.False(t, ok)
+
+		_, ok = keys.Resolve("")
+		require.False(t, ok)
+
+		require.Equal(t, []string{"tenant-1", "tenant-2"}, keys.Tenants())
+		require.True(t, keys.Configured("tenant-1"))
+		require.False(t, keys.Configured("tenant-3"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		var keys *Keys
+
+		require.False(t, keys.Enabled())
+		require.False(t, keys.Configured("tenant-1"))
+		require.Empty(t, keys.Tenants())
+		require.False(t, NewKeys("", nil).Enabled())
+
+		_, ok := NewKeys("", map[string]string{"tenant-1": "key-1"}).Resolve("")
+		require.False(t, ok)
+	})
+}
+
+func TestContext(t *testing.T) {
+	require.Empty(t, FromContext(context.Background()))
+	require.Equal(t, "tenant-1", FromContext(WithTenant(context.Background(), "tenant-1")))
+}
+
+func TestStore(t *testing.T) {
+	t.Run("assign and get", func(t *testing.T) {
+		s, err := New(mem.NewProvider())
+		require.NoError(t, err)
+
+		require.NoError(t, s.Assign("tenant-1", "conn-1", "", "did:peer:1"))
+		require.NoError(t, s.Assign("", "conn-2"))
+
+		for id, tenantID := range map[string]string{"conn-1": "tenant-1", "did:peer:1": "tenant-1", "conn-2": "", "": ""} {
+			got, err := s.Get(id)
+			require.NoError(t, err)
+			require.Equal(t, tenantID, got)
+		}
+	})
+
+	t.Run("open store error", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := New(p)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open tenant store")
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		s, err := New(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrPut: errors.New("put error"),
+			ErrGet: errors.New("get error"),
+		}))
+		require.NoError(t, err)
+
+		err = s.Assign("tenant-1", "conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save tenant assignment")
+
+		_, err = s.Get("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get tenant assignment")
+	})
+}
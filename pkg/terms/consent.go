@@ -0,0 +1,125 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package terms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+// AcceptMsgType of the message acknowledging the terms, sent by the wallets on the connection.
+const AcceptMsgType = "https://trustbloc.dev/mediator-terms/1.0/accept"
+
+// Acknowledgments of the terms.
+const (
+	// ViaMessage is the acknowledgment of the terms with an accept message from the wallet.
+	ViaMessage = "message"
+	// ViaREST is the acknowledgment of the terms through the REST API, by the wallet backend.
+	ViaREST = "rest"
+)
+
+const (
+	consentKeyPrefix = "consents_"
+	didKeyPrefix     = "did_"
+)
+
+// ErrConnectionNotFound is returned when no connection is linked to the router DID.
+var ErrConnectionNotFound = errors.New("connection not linked")
+
+// AcceptMsg is the accept message, threaded to the terms message if it answers it.
+type AcceptMsg struct {
+	ID      string            `json:"@id"`
+	Type    string            `json:"@type"`
+	Thread  *decorator.Thread `json:"~thread,omitempty"`
+	Version string            `json:"version"`
+}
+
+// Consent is the acknowledgment of a terms version by the wallet of a connection.
+type Consent struct {
+	// Version of the terms accepted.
+	Version string `json:"version"`
+	// Via is the acknowledgment channel : ViaMessage or ViaREST.
+	Via        string    `json:"via"`
+	AcceptedAt time.Time `json:"acceptedAt"`
+	// PresentedVersion is the terms version last presented to the connection at the time of the acknowledgment.
+	PresentedVersion string `json:"presentedVersion,omitempty"`
+	// MsgID of the accept message, if acknowledged with a message.
+	MsgID string `json:"msgID,omitempty"`
+}
+
+// Accept appends the consent to the consents of the connection.
+func (s *Store) Accept(connectionID string, c *Consent) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	consents, err := s.Consents(connectionID)
+	if err != nil {
+		return err
+	}
+
+	consentsBytes, err := json.Marshal(append(consents, c))
+	if err != nil {
+		return fmt.Errorf("marshal consents : %w", err)
+	}
+
+	err = s.store.Put(consentKeyPrefix+connectionID, consentsBytes)
+	if err != nil {
+		return fmt.Errorf("save consents : %w", err)
+	}
+
+	return nil
+}
+
+// Consents returns the consents of the connection, oldest first.
+func (s *Store) Consents(connectionID string) ([]*Consent, error) {
+	consentsBytes, err := s.store.Get(consentKeyPrefix + connectionID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return []*Consent{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get consents : %w", err)
+	}
+
+	var consents []*Consent
+
+	err = json.Unmarshal(consentsBytes, &consents)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal consents : %w", err)
+	}
+
+	return consents, nil
+}
+
+// Link links the router DID of the connection to the connection, to record the consents received on it.
+func (s *Store) Link(myDID, connectionID string) error {
+	err := s.store.Put(didKeyPrefix+myDID, []byte(connectionID))
+	if err != nil {
+		return fmt.Errorf("save connection link : %w", err)
+	}
+
+	return nil
+}
+
+// ConnectionOf returns the connection linked to the router DID, ErrConnectionNotFound if not linked.
+func (s *Store) ConnectionOf(myDID string) (string, error) {
+	connID, err := s.store.Get(didKeyPrefix + myDID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return "", ErrConnectionNotFound
+	}
+
+	if err != nil {
+		return "", fmt.Errorf("get connection link : %w", err)
+	}
+
+	return string(connID), nil
+}
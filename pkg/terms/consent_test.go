@@ -0,0 +1,97 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package terms
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsents(t *testing.T) {
+	s, err := New(mem.NewProvider())
+	require.NoError(t, err)
+
+	consents, err := s.Consents("conn-1")
+	require.NoError(t, err)
+	require.Empty(t, consents)
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	first := &Consent{Version: "2021-06", Via: ViaMessage, AcceptedAt: now, PresentedVersion: "2021-06", MsgID: "msg-1"}
+	second := &Consent{Version: "2021-09", Via: ViaREST, AcceptedAt: now.Add(time.Hour)}
+
+	require.NoError(t, s.Accept("conn-1", first))
+	require.NoError(t, s.Accept("conn-1", second))
+
+	consents, err = s.Consents("conn-1")
+	require.NoError(t, err)
+	require.Equal(t, []*Consent{first, second}, consents)
+
+	consents, err = s.Consents("conn-2")
+	require.NoError(t, err)
+	require.Empty(t, consents)
+
+	t.Run("store errors", func(t *testing.T) {
+		p := mockstore.NewMockStoreProvider()
+		p.Store.ErrPut = errors.New("put error")
+
+		s, err := New(p)
+		require.NoError(t, err)
+
+		err = s.Accept("conn-1", first)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save consents")
+
+		p.Store.Store[consentKeyPrefix+"conn-1"] = mockstore.DBEntry{Value: []byte("invalid")}
+
+		err = s.Accept("conn-1", first)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal consents")
+
+		p.Store.ErrGet = errors.New("get error")
+
+		_, err = s.Consents("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get consents")
+	})
+}
+
+func TestLink(t *testing.T) {
+	s, err := New(mem.NewProvider())
+	require.NoError(t, err)
+
+	_, err = s.ConnectionOf("did:router")
+	require.ErrorIs(t, err, ErrConnectionNotFound)
+
+	require.NoError(t, s.Link("did:router", "conn-1"))
+
+	connID, err := s.ConnectionOf("did:router")
+	require.NoError(t, err)
+	require.Equal(t, "conn-1", connID)
+
+	t.Run("store errors", func(t *testing.T) {
+		p := mockstore.NewMockStoreProvider()
+		p.Store.ErrPut = errors.New("put error")
+		p.Store.ErrGet = errors.New("get error")
+
+		s, err := New(p)
+		require.NoError(t, err)
+
+		err = s.Link("did:router", "conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save connection link")
+
+		_, err = s.ConnectionOf("did:router")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get connection link")
+	})
+}
@@ -0,0 +1,134 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package terms references the terms of service and privacy documents of the mediator in the invitations and
+// mediation grants, tracks the version presented to each connection, and records the consents of the wallets.
+package terms
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+const (
+	// MsgType of the terms message, sent on the connection with the mediation grant.
+	MsgType = "https://trustbloc.dev/mediator-terms/1.0/terms"
+	// AttachmentID of the terms in the invitations.
+	AttachmentID = "mediator-terms"
+
+	storeName = "terms"
+)
+
+// Presentations of the terms.
+const (
+	// ViaInvitation is the presentation of the terms in the invitation the connection was created from.
+	ViaInvitation = "invitation"
+	// ViaMediationGrant is the presentation of the terms with the mediation grant.
+	ViaMediationGrant = "mediation-grant"
+)
+
+// ErrNotFound is returned when the terms were never presented to the connection.
+var ErrNotFound = errors.New("terms not presented")
+
+// Terms references the terms documents of the mediator.
+type Terms struct {
+	// URL of the terms of service.
+	URL string `json:"url"`
+	// PrivacyURL is the URL of the privacy policy, if any.
+	PrivacyURL string `json:"privacyURL,omitempty"`
+	// Version of the terms, the wallets record the version they accepted.
+	Version string `json:"version"`
+}
+
+// Enabled returns true if the terms are configured.
+func (t *Terms) Enabled() bool {
+	return t != nil && t.URL != ""
+}
+
+// Attachment returns the terms as an invitation attachment.
+func (t *Terms) Attachment() *decorator.Attachment {
+	return &decorator.Attachment{
+		ID:          AttachmentID,
+		Description: "mediator terms of service",
+		MimeType:    "application/json",
+		Data:        decorator.AttachmentData{JSON: t},
+	}
+}
+
+// Msg is the terms message, threaded to the mediation request.
+type Msg struct {
+	ID         string            `json:"@id"`
+	Type       string            `json:"@type"`
+	Thread     *decorator.Thread `json:"~thread,omitempty"`
+	URL        string            `json:"url"`
+	PrivacyURL string            `json:"privacyURL,omitempty"`
+	Version    string            `json:"version"`
+}
+
+// Record of the terms version presented to an invitation or connection.
+type Record struct {
+	Version     string    `json:"version"`
+	Via         string    `json:"via"`
+	PresentedAt time.Time `json:"presentedAt"`
+}
+
+// Store tracks the terms version presented to the invitations and connections, and the consents of the connections.
+type Store struct {
+	store storage.Store
+	mutex sync.Mutex
+}
+
+// New returns a new terms Store.
+func New(p storage.Provider) (*Store, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open terms store : %w", err)
+	}
+
+	return &Store{store: store}, nil
+}
+
+// Present records the terms version presented to the invitation or connection.
+func (s *Store) Present(id string, r *Record) error {
+	recordBytes, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshal terms record : %w", err)
+	}
+
+	err = s.store.Put(id, recordBytes)
+	if err != nil {
+		return fmt.Errorf("save terms record : %w", err)
+	}
+
+	return nil
+}
+
+// Get returns the terms version last presented to the invitation or connection, ErrNotFound if never presented.
+func (s *Store) Get(id string) (*Record, error) {
+	recordBytes, err := s.store.Get(id)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get terms record : %w", err)
+	}
+
+	r := &Record{}
+
+	err = json.Unmarshal(recordBytes, r)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal terms record : %w", err)
+	}
+
+	return r, nil
+}
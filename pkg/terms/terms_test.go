@@ -0,0 +1,73 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package terms
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTerms(t *testing.T) {
+	var disabled *Terms
+	require.False(t, disabled.Enabled())
+	require.False(t, (&Terms{Version: "1"}).Enabled())
+
+	terms := &Terms{URL: "https://example.com/terms", Version: "2021-06"}
+	require.True(t, terms.Enabled())
+
+	a := terms.Attachment()
+	require.Equal(t, AttachmentID, a.ID)
+	require.Equal(t, terms, a.Data.JSON)
+}
+
+func TestStore(t *testing.T) {
+	s, err := New(mem.NewProvider())
+	require.NoError(t, err)
+
+	_, err = s.Get("conn-1")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	r := &Record{Version: "2021-06", Via: ViaInvitation, PresentedAt: time.Now().UTC().Truncate(time.Second)}
+	require.NoError(t, s.Present("conn-1", r))
+
+	got, err := s.Get("conn-1")
+	require.NoError(t, err)
+	require.Equal(t, r, got)
+
+	t.Run("store errors", func(t *testing.T) {
+		_, err := New(&mockstore.MockStoreProvider{FailNamespace: storeName})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open terms store")
+
+		p := mockstore.NewMockStoreProvider()
+		p.Store.ErrPut = errors.New("put error")
+		p.Store.ErrGet = errors.New("get error")
+
+		s, err := New(p)
+		require.NoError(t, err)
+
+		err = s.Present("conn-1", r)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save terms record")
+
+		_, err = s.Get("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get terms record")
+
+		p.Store.ErrGet = nil
+		p.Store.Store["conn-2"] = mockstore.DBEntry{Value: []byte("invalid")}
+
+		_, err = s.Get("conn-2")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal terms record")
+	})
+}
@@ -0,0 +1,234 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package upstream
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	// DefaultTimeout of the connection with the upstream mediator and of its mediation grant.
+	DefaultTimeout = 30 * time.Second
+
+	// DefaultLabel of the router in the connection with the upstream mediator.
+	DefaultLabel = "hub-router"
+
+	pollInterval = 100 * time.Millisecond
+)
+
+// ErrNotConnected is returned when the router isn't granted mediation by the upstream mediator yet.
+var ErrNotConnected = errors.New("upstream mediator not connected")
+
+var logger = log.New("hub-router/upstream")
+
+// OutOfBand client accepting the invitation of the upstream mediator.
+type OutOfBand interface {
+	AcceptInvitation(i *outofband.Invitation, myLabel string, opts ...outofband.MessageOption) (string, error)
+}
+
+// Connections looks up the connection with the upstream mediator.
+type Connections interface {
+	GetConnectionRecord(connectionID string) (*connection.Record, error)
+}
+
+// Coordinator is the route coordination service of the router, as the client of the upstream mediator.
+type Coordinator interface {
+	Register(connectionID string, options ...mediator.ClientOption) error
+	GetConnections() ([]string, error)
+	Config(connID string) (*mediator.Config, error)
+	AddKey(connID, recKey string) error
+}
+
+// Config of the upstream mediator.
+type Config struct {
+	// Invitation is the out-of-band invitation of the upstream mediator.
+	Invitation *outofband.Invitation
+	// Label of the router in the connection, DefaultLabel if empty.
+	Label string
+	// Timeout of the connection and of the mediation grant, DefaultTimeout if not set.
+	Timeout time.Duration
+}
+
+// Mediator is the upstream mediator the router requests mediation from, when it runs behind NAT without a public
+// endpoint : the router advertises the endpoint and the routing keys of the upstream mediator, after its own routing
+// keys, in the DID docs and mediation grants it issues, and registers its keys with the upstream mediator.
+type Mediator struct {
+	config      *Config
+	oob         OutOfBand
+	connections Connections
+	coordinator Coordinator
+
+	mutex    sync.RWMutex
+	connID   string
+	routing  *mediator.Config
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// New returns the upstream mediator, not connected until Connect succeeds.
+func New(config *Config, oob OutOfBand, connections Connections, coordinator Coordinator) *Mediator {
+	c := *config
+
+	if c.Label == "" {
+		c.Label = DefaultLabel
+	}
+
+	if c.Timeout <= 0 {
+		c.Timeout = DefaultTimeout
+	}
+
+	return &Mediator{
+		config: &c, oob: oob, connections: connections, coordinator: coordinator, stop: make(chan struct{}),
+	}
+}
+
+// Connect connects to the upstream mediator and requests mediation, unless the router is already granted mediation
+// on a previous connection.
+func (m *Mediator) Connect() error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.routing != nil {
+		return nil
+	}
+
+	connIDs, err := m.coordinator.GetConnections()
+	if err != nil {
+		return fmt.Errorf("get upstream connections : %w", err)
+	}
+
+	if len(connIDs) == 0 {
+		if err = m.requestMediation(); err != nil {
+			return err
+		}
+	} else {
+		m.connID = connIDs[0]
+	}
+
+	routing, err := m.coordinator.Config(m.connID)
+	if err != nil {
+		return fmt.Errorf("get upstream routing : %w", err)
+	}
+
+	m.routing = routing
+
+	logger.Infof("granted mediation by the upstream mediator : endpoint=%s", routing.Endpoint())
+
+	return nil
+}
+
+// requestMediation accepts the invitation of the upstream mediator, once, and requests mediation on the connection
+// once completed.
+func (m *Mediator) requestMediation() error {
+	if m.connID == "" {
+		connID, err := m.oob.AcceptInvitation(m.config.Invitation, m.config.Label)
+		if err != nil {
+			return fmt.Errorf("accept upstream invitation : %w", err)
+		}
+
+		m.connID = connID
+	}
+
+	if err := m.waitConnection(); err != nil {
+		return err
+	}
+
+	err := m.coordinator.Register(m.connID, func(opts *mediator.ClientOptions) {
+		opts.Timeout = m.config.Timeout
+	})
+	if err != nil {
+		return fmt.Errorf("request upstream mediation : %w", err)
+	}
+
+	return nil
+}
+
+func (m *Mediator) waitConnection() error {
+	deadline := time.Now().Add(m.config.Timeout)
+
+	for {
+		record, err := m.connections.GetConnectionRecord(m.connID)
+		if err == nil && record.State == connection.StateNameCompleted {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("upstream connection not completed : connectionID=%s", m.connID)
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+// Routing returns the endpoint and the routing keys of the upstream mediator, false if not connected yet.
+func (m *Mediator) Routing() (string, []string, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.routing == nil {
+		return "", nil, false
+	}
+
+	return m.routing.Endpoint(), m.routing.Keys(), true
+}
+
+// AddKeys registers the keys of the router with the upstream mediator, for the messages forwarded to them to be routed
+// to the router.
+func (m *Mediator) AddKeys(keys ...string) error {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	if m.routing == nil {
+		return ErrNotConnected
+	}
+
+	for _, key := range keys {
+		if err := m.coordinator.AddKey(m.connID, key); err != nil {
+			return fmt.Errorf("add upstream key : %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Start connects to the upstream mediator, retrying at the interval until connected or Stop is called.
+func (m *Mediator) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			err := m.Connect()
+			if err == nil {
+				return
+			}
+
+			logger.Warnf("upstream mediator : %s", err)
+
+			select {
+			case <-ticker.C:
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the connection retries.
+func (m *Mediator) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stop)
+	})
+}
@@ -0,0 +1,202 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package upstream
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	mockroute "github.com/hyperledger/aries-framework-go/pkg/mock/didcomm/protocol/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/store/connection"
+	"github.com/stretchr/testify/require"
+
+	mockoutofband "github.com/trustbloc/hub-router/pkg/internal/mock/outofband"
+)
+
+type mockConnections struct {
+	records map[string]*connection.Record
+}
+
+func (c *mockConnections) GetConnectionRecord(connectionID string) (*connection.Record, error) {
+	record, ok := c.records[connectionID]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+
+	return record, nil
+}
+
+func completed(connID string) *mockConnections {
+	return &mockConnections{records: map[string]*connection.Record{
+		connID: {ConnectionID: connID, State: connection.StateNameCompleted},
+	}}
+}
+
+func TestNew(t *testing.T) {
+	m := New(&Config{}, nil, nil, nil)
+	require.Equal(t, DefaultLabel, m.config.Label)
+	require.Equal(t, DefaultTimeout, m.config.Timeout)
+
+	m = New(&Config{Label: "router", Timeout: time.Second}, nil, nil, nil)
+	require.Equal(t, "router", m.config.Label)
+	require.Equal(t, time.Second, m.config.Timeout)
+}
+
+func TestConnect(t *testing.T) {
+	t.Run("request mediation", func(t *testing.T) {
+		var timeout time.Duration
+
+		coordinator := &mockroute.MockMediatorSvc{
+			RouterEndpoint: "https://mediator.example.com",
+			RoutingKeys:    []string{"did:key:upstream"},
+			RegisterFunc: func(connectionID string, options ...mediator.ClientOption) error {
+				require.Equal(t, "conn-1", connectionID)
+
+				opts := &mediator.ClientOptions{}
+				for _, option := range options {
+					option(opts)
+				}
+
+				timeout = opts.Timeout
+
+				return nil
+			},
+		}
+
+		m := New(&Config{Invitation: &outofband.Invitation{}, Timeout: time.Second},
+			&mockoutofband.MockClient{ConnectionID: "conn-1"}, completed("conn-1"), coordinator)
+
+		_, _, ok := m.Routing()
+		require.False(t, ok)
+		require.ErrorIs(t, m.AddKeys("did:key:router"), ErrNotConnected)
+
+		require.NoError(t, m.Connect())
+		require.Equal(t, time.Second, timeout)
+
+		endpoint, keys, ok := m.Routing()
+		require.True(t, ok)
+		require.Equal(t, "https://mediator.example.com", endpoint)
+		require.Equal(t, []string{"did:key:upstream"}, keys)
+
+		var added []string
+
+		coordinator.AddKeyFunc = func(key string) error {
+			added = append(added, key)
+
+			return nil
+		}
+
+		require.NoError(t, m.AddKeys("did:key:router1", "did:key:router2"))
+		require.Equal(t, []string{"did:key:router1", "did:key:router2"}, added)
+
+		// connected once
+		coordinator.GetConnectionsErr = errors.New("get error")
+		require.NoError(t, m.Connect())
+	})
+
+	t.Run("already registered", func(t *testing.T) {
+		m := New(&Config{}, &mockoutofband.MockClient{AcceptInvitationErr: errors.New("accept error")}, nil,
+			&mockroute.MockMediatorSvc{
+				Connections: []string{"conn-2"}, RouterEndpoint: "https://mediator.example.com",
+				RoutingKeys: []string{"did:key:upstream"},
+			})
+
+		require.NoError(t, m.Connect())
+		require.Equal(t, "conn-2", m.connID)
+	})
+
+	t.Run("connection not completed", func(t *testing.T) {
+		oob := &mockoutofband.MockClient{ConnectionID: "conn-1"}
+		connections := &mockConnections{records: map[string]*connection.Record{
+			"conn-1": {ConnectionID: "conn-1", State: "requested"},
+		}}
+
+		m := New(&Config{Timeout: time.Millisecond}, oob, connections, &mockroute.MockMediatorSvc{})
+
+		err := m.Connect()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "upstream connection not completed : connectionID=conn-1")
+
+		// the invitation is accepted once
+		oob.AcceptInvitationErr = errors.New("accept error")
+		connections.records["conn-1"].State = connection.StateNameCompleted
+
+		err = m.Connect()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get upstream routing")
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		m := New(&Config{}, nil, nil, &mockroute.MockMediatorSvc{GetConnectionsErr: errors.New("get error")})
+
+		err := m.Connect()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get upstream connections : get error")
+
+		m = New(&Config{}, &mockoutofband.MockClient{AcceptInvitationErr: errors.New("accept error")}, nil,
+			&mockroute.MockMediatorSvc{})
+
+		err = m.Connect()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "accept upstream invitation : accept error")
+
+		m = New(&Config{}, &mockoutofband.MockClient{ConnectionID: "conn-1"}, completed("conn-1"),
+			&mockroute.MockMediatorSvc{RegisterFunc: func(string, ...mediator.ClientOption) error {
+				return errors.New("register error")
+			}})
+
+		err = m.Connect()
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "request upstream mediation : register error")
+
+		coordinator := &mockroute.MockMediatorSvc{
+			Connections: []string{"conn-1"}, RouterEndpoint: "https://mediator.example.com",
+			RoutingKeys: []string{"did:key:upstream"},
+		}
+
+		m = New(&Config{}, nil, nil, coordinator)
+		require.NoError(t, m.Connect())
+
+		coordinator.AddKeyErr = errors.New("add error")
+
+		err = m.AddKeys("did:key:router")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "add upstream key : add error")
+	})
+}
+
+func TestStart(t *testing.T) {
+	t.Run("connects in the background", func(t *testing.T) {
+		m := New(&Config{}, nil, nil, &mockroute.MockMediatorSvc{
+			Connections: []string{"conn-1"}, RouterEndpoint: "https://mediator.example.com",
+			RoutingKeys: []string{"did:key:upstream"},
+		})
+
+		m.Start(time.Millisecond)
+		defer m.Stop()
+
+		require.Eventually(t, func() bool {
+			_, _, ok := m.Routing()
+
+			return ok
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		m := New(&Config{}, nil, nil, &mockroute.MockMediatorSvc{GetConnectionsErr: errors.New("get error")})
+
+		m.Start(time.Millisecond)
+		m.Stop()
+		m.Stop()
+
+		_, _, ok := m.Routing()
+		require.False(t, ok)
+	})
+}
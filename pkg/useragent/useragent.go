@@ -0,0 +1,102 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+// Package useragent records the user agent and app version the wallets report when they register, for the tenants
+// opted in : it helps debugging the interop issues of specific wallet versions.
+package useragent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+)
+
+const storeName = "useragent"
+
+// AllTenants opts all the tenants in, and the wallets registered without tenant.
+const AllTenants = "*"
+
+// ErrNotFound is returned when no user agent was captured for the connection.
+var ErrNotFound = errors.New("user agent not captured")
+
+// Agent is the user agent and app version reported by the wallet at registration.
+type Agent struct {
+	UserAgent  string    `json:"userAgent,omitempty"`
+	AppVersion string    `json:"appVersion,omitempty"`
+	CapturedAt time.Time `json:"capturedAt"`
+}
+
+// Store persists the user agents of the wallets of the tenants opted in, keyed by connection ID.
+type Store struct {
+	store   storage.Store
+	tenants map[string]bool
+}
+
+// New returns a new Store capturing the user agents of the wallets of the given tenants, of all of them with
+// AllTenants.
+func New(p storage.Provider, tenants []string) (*Store, error) {
+	store, err := p.OpenStore(storeName)
+	if err != nil {
+		return nil, fmt.Errorf("open user agent store : %w", err)
+	}
+
+	s := &Store{store: store, tenants: make(map[string]bool)}
+
+	for _, t := range tenants {
+		s.tenants[t] = true
+	}
+
+	return s, nil
+}
+
+// OptedIn returns true if the user agents of the wallets of the tenant are captured.
+func (s *Store) OptedIn(tenantID string) bool {
+	return s.tenants[AllTenants] || tenantID != "" && s.tenants[tenantID]
+}
+
+// Capture records the user agent and app version of the wallet registered on the connection, if its tenant opted in
+// and the wallet reported any. It returns true if recorded.
+func (s *Store) Capture(tenantID, connID string, a *Agent) (bool, error) {
+	if !s.OptedIn(tenantID) || a == nil || a.UserAgent == "" && a.AppVersion == "" {
+		return false, nil
+	}
+
+	agentBytes, err := json.Marshal(a)
+	if err != nil {
+		return false, fmt.Errorf("marshal user agent : %w", err)
+	}
+
+	err = s.store.Put(connID, agentBytes)
+	if err != nil {
+		return false, fmt.Errorf("save user agent : %w", err)
+	}
+
+	return true, nil
+}
+
+// Get returns the user agent captured for the connection, ErrNotFound if none.
+func (s *Store) Get(connID string) (*Agent, error) {
+	agentBytes, err := s.store.Get(connID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get user agent : %w", err)
+	}
+
+	a := &Agent{}
+
+	err = json.Unmarshal(agentBytes, a)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal user agent : %w", err)
+	}
+
+	return a, nil
+}
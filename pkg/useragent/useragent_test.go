@@ -0,0 +1,90 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package useragent
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	a := &Agent{UserAgent: "okhttp/4.9.0", AppVersion: "2.3.1", CapturedAt: time.Now().UTC().Truncate(time.Second)}
+
+	t.Run("opted in tenants", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), []string{"acme"})
+		require.NoError(t, err)
+		require.True(t, s.OptedIn("acme"))
+		require.False(t, s.OptedIn("globex"))
+		require.False(t, s.OptedIn(""))
+
+		ok, err := s.Capture("globex", "conn-1", a)
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		_, err = s.Get("conn-1")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		ok, err = s.Capture("acme", "conn-1", &Agent{})
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		ok, err = s.Capture("acme", "conn-1", a)
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		got, err := s.Get("conn-1")
+		require.NoError(t, err)
+		require.Equal(t, a, got)
+	})
+
+	t.Run("all tenants", func(t *testing.T) {
+		s, err := New(mem.NewProvider(), []string{AllTenants})
+		require.NoError(t, err)
+		require.True(t, s.OptedIn(""))
+		require.True(t, s.OptedIn("globex"))
+
+		s, err = New(mem.NewProvider(), nil)
+		require.NoError(t, err)
+		require.False(t, s.OptedIn(""))
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		_, err := New(&mockstore.MockStoreProvider{FailNamespace: storeName}, nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open user agent store")
+
+		p := mockstore.NewMockStoreProvider()
+		p.Store.ErrPut = errors.New("put error")
+		p.Store.ErrGet = errors.New("get error")
+
+		s, err := New(p, []string{AllTenants})
+		require.NoError(t, err)
+
+		_, err = s.Capture("", "conn-1", a)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save user agent")
+
+		_, err = s.Get("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get user agent")
+
+		p = mockstore.NewMockStoreProvider()
+		p.Store.Store["conn-1"] = mockstore.DBEntry{Value: []byte("{")}
+
+		s, err = New(p, nil)
+		require.NoError(t, err)
+
+		_, err = s.Get("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unmarshal user agent")
+	})
+}
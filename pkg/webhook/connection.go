@@ -0,0 +1,200 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/hyperledger/aries-framework-go/spi/storage"
+
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+const (
+	connectionWebhookStoreName  = "connwebhook"
+	connectionWebhookLockPrefix = "connwebhook-"
+
+	// AnyRevision applies the changes whatever the revision of the connection webhook.
+	AnyRevision = -1
+)
+
+var (
+	// ErrInvalidURL is returned when the URL of a connection webhook isn't an absolute HTTP(S) URL.
+	ErrInvalidURL = errors.New("invalid webhook URL : expected an absolute http or https URL")
+	// ErrRevisionConflict is returned for the changes expecting another revision of the connection webhook.
+	ErrRevisionConflict = errors.New("connection webhook revision conflict")
+)
+
+// ConnectionWebhooks registers the webhooks specific to a connection, eg: of the adapter that created it, receiving
+// the notifications about the connection only, in addition to the webhook URLs.
+type ConnectionWebhooks struct {
+	store  storage.Store
+	locker lock.Locker
+}
+
+// Registration is the webhook registered for a connection.
+type Registration struct {
+	URL string `json:"url"`
+	// Revision is incremented on each change of the webhook, from 1 when registered.
+	Revision int `json:"revision"`
+}
+
+// WithConnectionWebhooks also posts the notifications about a connection to the webhook registered for it, with the
+// given client : the connection webhooks are registered through the REST API, so the client must not carry the
+// credentials of the webhook URLs.
+func WithConnectionWebhooks(w *ConnectionWebhooks, client HTTPClient) Option {
+	return func(n *Notifier) {
+		if client == nil {
+			client = http.DefaultClient
+		}
+
+		n.connWebhooks = w
+		n.connClient = client
+	}
+}
+
+// NewConnectionWebhooks returns the connection webhooks, registered in the given (persistent) storage. The changes are
+// serialized with the locker, so that the revisions are checked against the webhooks stored.
+func NewConnectionWebhooks(p storage.Provider, locker lock.Locker) (*ConnectionWebhooks, error) {
+	store, err := p.OpenStore(connectionWebhookStoreName)
+	if err != nil {
+		return nil, fmt.Errorf("open connection webhook store : %w", err)
+	}
+
+	return &ConnectionWebhooks{store: store, locker: locker}, nil
+}
+
+// Register registers the webhook of the connection, replacing its previous webhook.
+func (w *ConnectionWebhooks) Register(connectionID, webhookURL string) error {
+	_, err := w.RegisterIf(connectionID, webhookURL, AnyRevision)
+
+	return err
+}
+
+// RegisterIf is Register, applied if the webhook of the connection has the revision, or none is registered with the
+// revision 0, or whatever its revision with AnyRevision : ErrRevisionConflict is returned otherwise.
+func (w *ConnectionWebhooks) RegisterIf(connectionID, webhookURL string, revision int) (*Registration, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, ErrInvalidURL
+	}
+
+	unlock, err := w.locker.Lock(connectionWebhookLockPrefix + connectionID)
+	if err != nil {
+		return nil, fmt.Errorf("lock connection webhook : %w", err)
+	}
+
+	defer unlock()
+
+	current, err := w.Lookup(connectionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = checkRevision(current, revision); err != nil {
+		return nil, err
+	}
+
+	r := &Registration{URL: webhookURL, Revision: 1}
+	if current != nil {
+		r.Revision = current.Revision + 1
+	}
+
+	registrationBytes, err := json.Marshal(r)
+	if err != nil {
+		return nil, fmt.Errorf("marshal connection webhook : %w", err)
+	}
+
+	if err = w.store.Put(connectionID, registrationBytes); err != nil {
+		return nil, fmt.Errorf("save connection webhook : %w", err)
+	}
+
+	return r, nil
+}
+
+// Get returns the URL of the webhook of the connection, empty if none is registered.
+func (w *ConnectionWebhooks) Get(connectionID string) (string, error) {
+	r, err := w.Lookup(connectionID)
+	if err != nil || r == nil {
+		return "", err
+	}
+
+	return r.URL, nil
+}
+
+// Lookup returns the webhook registered for the connection, nil if none is registered.
+func (w *ConnectionWebhooks) Lookup(connectionID string) (*Registration, error) {
+	registrationBytes, err := w.store.Get(connectionID)
+	if errors.Is(err, storage.ErrDataNotFound) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("get connection webhook : %w", err)
+	}
+
+	r := &Registration{}
+
+	// the webhooks registered before the revisions are stored as their URL
+	if err = json.Unmarshal(registrationBytes, r); err != nil {
+		return &Registration{URL: string(registrationBytes), Revision: 1}, nil // nolint:nilerr // URL only
+	}
+
+	return r, nil
+}
+
+// Remove removes the webhook of the connection, if any.
+func (w *ConnectionWebhooks) Remove(connectionID string) error {
+	return w.RemoveIf(connectionID, AnyRevision)
+}
+
+// RemoveIf is Remove, applied if the webhook of the connection has the revision, or whatever its revision with
+// AnyRevision : ErrRevisionConflict is returned otherwise.
+func (w *ConnectionWebhooks) RemoveIf(connectionID string, revision int) error {
+	unlock, err := w.locker.Lock(connectionWebhookLockPrefix + connectionID)
+	if err != nil {
+		return fmt.Errorf("lock connection webhook : %w", err)
+	}
+
+	defer unlock()
+
+	if revision != AnyRevision {
+		current, err := w.Lookup(connectionID)
+		if err != nil {
+			return err
+		}
+
+		if err = checkRevision(current, revision); err != nil {
+			return err
+		}
+	}
+
+	if err := w.store.Delete(connectionID); err != nil && !errors.Is(err, storage.ErrDataNotFound) {
+		return fmt.Errorf("remove connection webhook : %w", err)
+	}
+
+	return nil
+}
+
+func checkRevision(r *Registration, revision int) error {
+	switch {
+	case revision == AnyRevision:
+		return nil
+	case r == nil && revision == 0:
+		return nil
+	case r == nil:
+		return fmt.Errorf("%w : no webhook registered", ErrRevisionConflict)
+	case r.Revision != revision:
+		return fmt.Errorf("%w : current revision is %d", ErrRevisionConflict, r.Revision)
+	default:
+		return nil
+	}
+}
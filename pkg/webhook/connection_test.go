@@ -0,0 +1,236 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	mockstore "github.com/hyperledger/aries-framework-go/pkg/mock/storage"
+	"github.com/stretchr/testify/require"
+
+	mockstorage "github.com/trustbloc/hub-router/pkg/internal/mock/storage"
+	"github.com/trustbloc/hub-router/pkg/lock"
+)
+
+func TestConnectionWebhooks(t *testing.T) {
+	t.Run("register, get and remove", func(t *testing.T) {
+		w, err := NewConnectionWebhooks(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		url, err := w.Get("conn-1")
+		require.NoError(t, err)
+		require.Empty(t, url)
+
+		require.NoError(t, w.Register("conn-1", "https://adapter.example.com/events"))
+		require.NoError(t, w.Register("conn-1", "http://adapter:8080/events"))
+
+		url, err = w.Get("conn-1")
+		require.NoError(t, err)
+		require.Equal(t, "http://adapter:8080/events", url)
+
+		require.NoError(t, w.Remove("conn-1"))
+		require.NoError(t, w.Remove("conn-1"))
+
+		url, err = w.Get("conn-1")
+		require.NoError(t, err)
+		require.Empty(t, url)
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		w, err := NewConnectionWebhooks(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		for _, url := range []string{"", "adapter/events", "ftp://adapter/events", "https://", "http://[::1"} {
+			require.ErrorIs(t, w.Register("conn-1", url), ErrInvalidURL, url)
+		}
+	})
+
+	t.Run("store errors", func(t *testing.T) {
+		p := mockstorage.NewMockProvider()
+		p.OpenStoreErr = errors.New("open error")
+
+		_, err := NewConnectionWebhooks(p, lock.NewLocal())
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "open connection webhook store")
+
+		w, err := NewConnectionWebhooks(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:     make(map[string]mockstore.DBEntry),
+			ErrPut:    errors.New("put error"),
+			ErrDelete: errors.New("delete error"),
+		}), lock.NewLocal())
+		require.NoError(t, err)
+
+		err = w.Register("conn-1", "https://adapter.example.com/events")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "save connection webhook")
+
+		err = w.Remove("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "remove connection webhook")
+
+		w, err = NewConnectionWebhooks(mockstore.NewCustomMockStoreProvider(&mockstore.MockStore{
+			Store:  make(map[string]mockstore.DBEntry),
+			ErrGet: errors.New("get error"),
+		}), lock.NewLocal())
+		require.NoError(t, err)
+
+		_, err = w.Get("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get connection webhook")
+
+		_, err = w.RegisterIf("conn-1", "https://adapter.example.com/events", 1)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get connection webhook")
+
+		err = w.RemoveIf("conn-1", 1)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "get connection webhook")
+	})
+
+	t.Run("revisions", func(t *testing.T) {
+		w, err := NewConnectionWebhooks(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+
+		_, err = w.RegisterIf("conn-1", "https://adapter.example.com/events", 1)
+		require.ErrorIs(t, err, ErrRevisionConflict)
+
+		r, err := w.RegisterIf("conn-1", "https://adapter.example.com/events", 0)
+		require.NoError(t, err)
+		require.Equal(t, 1, r.Revision)
+
+		_, err = w.RegisterIf("conn-1", "http://adapter:8080/events", 0)
+		require.ErrorIs(t, err, ErrRevisionConflict)
+
+		r, err = w.RegisterIf("conn-1", "http://adapter:8080/events", 1)
+		require.NoError(t, err)
+		require.Equal(t, &Registration{URL: "http://adapter:8080/events", Revision: 2}, r)
+
+		require.ErrorIs(t, w.RemoveIf("conn-1", 1), ErrRevisionConflict)
+		require.NoError(t, w.RemoveIf("conn-1", 2))
+		require.ErrorIs(t, w.RemoveIf("conn-1", 2), ErrRevisionConflict)
+
+		r, err = w.Lookup("conn-1")
+		require.NoError(t, err)
+		require.Nil(t, r)
+	})
+
+	t.Run("registered before the revisions", func(t *testing.T) {
+		p := mem.NewProvider()
+
+		store, err := p.OpenStore(connectionWebhookStoreName)
+		require.NoError(t, err)
+		require.NoError(t, store.Put("conn-1", []byte("https://adapter.example.com/events")))
+
+		w, err := NewConnectionWebhooks(p, lock.NewLocal())
+		require.NoError(t, err)
+
+		r, err := w.Lookup("conn-1")
+		require.NoError(t, err)
+		require.Equal(t, &Registration{URL: "https://adapter.example.com/events", Revision: 1}, r)
+
+		r, err = w.RegisterIf("conn-1", "http://adapter:8080/events", 1)
+		require.NoError(t, err)
+		require.Equal(t, 2, r.Revision)
+	})
+
+	t.Run("lock error", func(t *testing.T) {
+		w, err := NewConnectionWebhooks(mem.NewProvider(), &failingLocker{})
+		require.NoError(t, err)
+
+		err = w.Register("conn-1", "https://adapter.example.com/events")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "lock connection webhook")
+
+		err = w.Remove("conn-1")
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "lock connection webhook")
+	})
+}
+
+type failingLocker struct{}
+
+func (l *failingLocker) Lock(string) (func(), error) {
+	return nil, errors.New("lock timeout")
+}
+
+func TestNotifierConnectionWebhooks(t *testing.T) {
+	newServer := func(t *testing.T) (*httptest.Server, chan *Message) {
+		t.Helper()
+
+		msgs := make(chan *Message, 10)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Empty(t, r.Header.Get("Authorization"))
+
+			msg := &Message{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(msg))
+
+			msgs <- msg
+		}))
+
+		return srv, msgs
+	}
+
+	t.Run("notifications about the connection only", func(t *testing.T) {
+		srv, msgs := newServer(t)
+		defer srv.Close()
+
+		webhooks, err := NewConnectionWebhooks(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+		require.NoError(t, webhooks.Register("conn-1", srv.URL))
+
+		n := New(nil, nil, WithConnectionWebhooks(webhooks, nil))
+		require.Equal(t, webhooks, n.ConnectionWebhooks())
+
+		require.NoError(t, n.Notify("presence", map[string]string{"connectionID": "conn-1", "status": "online"}))
+		require.NoError(t, n.Notify("presence", map[string]string{"connectionID": "conn-2", "status": "online"}))
+		require.NoError(t, n.Notify("tenant", map[string]string{"tenantID": "acme"}))
+
+		require.Len(t, msgs, 1)
+
+		msg := <-msgs
+		require.Equal(t, "presence", msg.Topic)
+		require.Equal(t, map[string]interface{}{"connectionID": "conn-1", "status": "online"}, msg.Message)
+	})
+
+	t.Run("in addition to the webhook URLs", func(t *testing.T) {
+		srv, msgs := newServer(t)
+		defer srv.Close()
+
+		webhooks, err := NewConnectionWebhooks(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+		require.NoError(t, webhooks.Register("conn-1", srv.URL))
+
+		n := New([]string{srv.URL}, nil, WithConnectionWebhooks(webhooks, http.DefaultClient))
+
+		require.NoError(t, n.Notify("presence", map[string]string{"connectionID": "conn-1"}))
+		require.Len(t, msgs, 2)
+	})
+
+	t.Run("post error", func(t *testing.T) {
+		webhooks, err := NewConnectionWebhooks(mem.NewProvider(), lock.NewLocal())
+		require.NoError(t, err)
+		require.NoError(t, webhooks.Register("conn-1", "http://localhost:1/events"))
+
+		n := New(nil, nil, WithConnectionWebhooks(webhooks, nil))
+
+		err = n.Notify("presence", map[string]string{"connectionID": "conn-1"})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "post webhook http://localhost:1/events")
+	})
+
+	t.Run("not enabled", func(t *testing.T) {
+		var n *Notifier
+		require.Nil(t, n.ConnectionWebhooks())
+		require.Nil(t, New(nil, nil).ConnectionWebhooks())
+	})
+}
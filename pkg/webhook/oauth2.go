@@ -0,0 +1,164 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	formContentType = "application/x-www-form-urlencoded"
+	// expiryDelta renews the access token before it expires, so that it isn't rejected by the webhook in transit.
+	expiryDelta = 10 * time.Second
+)
+
+// ClientCredentials configures the OAuth2 client credentials grant (RFC 6749 section 4.4) used to get the access
+// token sent to the webhooks.
+type ClientCredentials struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// OAuth2Transport adds the bearer access token acquired with the client credentials to the webhook requests. The token
+// is cached until it expires, or until a webhook rejects it.
+type OAuth2Transport struct {
+	config ClientCredentials
+	base   http.RoundTripper
+	mutex  sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewOAuth2Transport returns a new OAuth2Transport sending the requests, and the token requests, with the base
+// transport (eg: configured with a client certificate).
+func NewOAuth2Transport(config *ClientCredentials, base http.RoundTripper) *OAuth2Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	return &OAuth2Transport{config: *config, base: base}
+}
+
+// RoundTrip sends the request with the access token.
+func (t *OAuth2Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.accessToken(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		// the token may have been revoked : the next notification gets a new one
+		t.invalidate(token)
+	}
+
+	return resp, nil
+}
+
+func (t *OAuth2Transport) accessToken(ctx context.Context) (string, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.token != "" && (t.expiry.IsZero() || time.Now().Before(t.expiry)) {
+		return t.token, nil
+	}
+
+	resp, err := t.requestToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	t.token = resp.AccessToken
+	t.expiry = time.Time{}
+
+	if resp.ExpiresIn > 0 {
+		t.expiry = time.Now().Add(time.Duration(resp.ExpiresIn)*time.Second - expiryDelta)
+	}
+
+	logger.Debugf("webhook access token acquired : expiresIn=%ds", resp.ExpiresIn)
+
+	return t.token, nil
+}
+
+func (t *OAuth2Transport) invalidate(token string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.token == token {
+		t.token = ""
+	}
+}
+
+func (t *OAuth2Transport) requestToken(ctx context.Context) (*tokenResponse, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(t.config.Scopes) > 0 {
+		form.Set("scope", strings.Join(t.config.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.config.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("create token request : %w", err)
+	}
+
+	req.Header.Set("Content-Type", formContentType)
+	req.SetBasicAuth(url.QueryEscape(t.config.ClientID), url.QueryEscape(t.config.ClientSecret))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("request token %s : %w", t.config.TokenURL, err)
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			logger.Warnf("failed to close token response body : %s", errClose)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request token %s : unexpected status %d", t.config.TokenURL, resp.StatusCode)
+	}
+
+	token := &tokenResponse{}
+
+	err = json.NewDecoder(resp.Body).Decode(token)
+	if err != nil {
+		return nil, fmt.Errorf("decode token response : %w", err)
+	}
+
+	if token.AccessToken == "" {
+		return nil, errors.New("decode token response : missing access_token")
+	}
+
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return nil, fmt.Errorf("decode token response : unsupported token type %s", token.TokenType)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,123 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuth2Transport(t *testing.T) {
+	t.Run("notify with access token", func(t *testing.T) {
+		var tokens int32
+
+		tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			clientID, secret, ok := r.BasicAuth()
+			require.True(t, ok)
+			require.Equal(t, "router", clientID)
+			require.Equal(t, "secret", secret)
+			require.Equal(t, formContentType, r.Header.Get("Content-Type"))
+			require.NoError(t, r.ParseForm())
+			require.Equal(t, "client_credentials", r.Form.Get("grant_type"))
+			require.Equal(t, "events:write events:read", r.Form.Get("scope"))
+
+			n := atomic.AddInt32(&tokens, 1)
+
+			require.NoError(t, json.NewEncoder(w).Encode(&tokenResponse{
+				AccessToken: fmt.Sprintf("token-%d", n), TokenType: "Bearer", ExpiresIn: 3600,
+			}))
+		}))
+		defer tokenSrv.Close()
+
+		var posts int32
+
+		auths := make(chan string, 3)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auths <- r.Header.Get("Authorization")
+
+			if atomic.AddInt32(&posts, 1) == 2 {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+		}))
+		defer srv.Close()
+
+		n := New([]string{srv.URL}, &http.Client{Transport: NewOAuth2Transport(&ClientCredentials{
+			TokenURL:     tokenSrv.URL,
+			ClientID:     "router",
+			ClientSecret: "secret",
+			Scopes:       []string{"events:write", "events:read"},
+		}, nil)})
+
+		require.NoError(t, n.Notify("presence", nil))
+		require.Equal(t, "Bearer token-1", <-auths)
+
+		err := n.Notify("presence", nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unexpected status 401")
+		require.Equal(t, "Bearer token-1", <-auths)
+
+		require.NoError(t, n.Notify("presence", nil))
+		require.Equal(t, "Bearer token-2", <-auths)
+		require.Equal(t, int32(2), atomic.LoadInt32(&tokens))
+	})
+
+	t.Run("token errors", func(t *testing.T) {
+		for _, tc := range []struct {
+			name   string
+			status int
+			body   string
+			err    string
+		}{
+			{name: "unexpected status", status: http.StatusUnauthorized, err: "unexpected status 401"},
+			{name: "invalid response", status: http.StatusOK, body: "{", err: "decode token response"},
+			{name: "missing token", status: http.StatusOK, body: "{}", err: "missing access_token"},
+			{
+				name: "unsupported type", status: http.StatusOK, body: `{"access_token":"t","token_type":"mac"}`,
+				err: "unsupported token type mac",
+			},
+		} {
+			tc := tc
+
+			t.Run(tc.name, func(t *testing.T) {
+				tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tc.status)
+					_, _ = w.Write([]byte(tc.body)) // nolint:errcheck // test server
+				}))
+				defer tokenSrv.Close()
+
+				n := New([]string{"http://localhost"}, &http.Client{
+					Transport: NewOAuth2Transport(&ClientCredentials{TokenURL: tokenSrv.URL}, nil),
+				})
+
+				err := n.Notify("presence", nil)
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tc.err)
+			})
+		}
+	})
+
+	t.Run("token request errors", func(t *testing.T) {
+		tr := NewOAuth2Transport(&ClientCredentials{TokenURL: "http://invalid.example.com:-1"}, nil)
+
+		_, err := tr.RoundTrip(httptest.NewRequest(http.MethodPost, "http://localhost", nil))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create token request")
+
+		tr = NewOAuth2Transport(&ClientCredentials{TokenURL: "http://127.0.0.1:1"}, nil)
+
+		_, err = tr.RoundTrip(httptest.NewRequest(http.MethodPost, "http://localhost", nil))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "request token")
+	})
+}
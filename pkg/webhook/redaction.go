@@ -0,0 +1,181 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// Redaction levels of the DIDs and keys of the notifications.
+const (
+	// RedactionRaw sends the identifiers as is.
+	RedactionRaw = "raw"
+	// RedactionHashed replaces the identifiers with their hash.
+	RedactionHashed = "hashed"
+	// RedactionOmitted removes the identifiers, and replaces the DIDs within the texts with RedactedText.
+	RedactionOmitted = "omitted"
+)
+
+// RedactedText replaces the DIDs omitted within the texts of the notifications, eg: the detail of an event.
+const RedactedText = "[redacted]"
+
+var (
+	didPattern     = regexp.MustCompile(`did:[a-z0-9]+:[A-Za-z0-9._:%\-]+(#[A-Za-z0-9._\-]+)?`)
+	fullDIDPattern = regexp.MustCompile(`^` + didPattern.String() + `$`)
+)
+
+// keyFields are the fields of the messages holding the keys (base58 encoded), or lists of keys.
+// nolint:gochecknoglobals // read-only lookup table
+var keyFields = map[string]bool{
+	"key":           true,
+	"keys":          true,
+	"recipientKey":  true,
+	"recipientKeys": true,
+	"senderKey":     true,
+	"senderKeys":    true,
+	"routingKeys":   true,
+}
+
+// Redaction of the DIDs and keys of the notifications, for the consumers that must not receive correlatable
+// identifiers. The DIDs are redacted wherever they appear in the messages, the keys in the key fields only. The hashed
+// identifiers are the base64url encoded HMAC-SHA256 of the identifier keyed with the salt : they can still be counted
+// and grouped, but not correlated with the identifiers known to other systems without the salt.
+type Redaction struct {
+	DIDs string
+	Keys string
+	Salt string
+}
+
+// ValidRedaction returns true if the redaction level is supported.
+func ValidRedaction(level string) bool {
+	return level == RedactionRaw || level == RedactionHashed || level == RedactionOmitted
+}
+
+// WithRedaction redacts the DIDs and keys of the notifications posted to the webhook URLs and published to the sinks.
+func WithRedaction(r *Redaction) Option {
+	return func(n *Notifier) {
+		n.redaction = r
+	}
+}
+
+// WithSinkRedaction redacts the DIDs and keys of the notifications published to the sinks, instead of the redaction
+// of the webhook URLs.
+func WithSinkRedaction(r *Redaction) Option {
+	return func(n *Notifier) {
+		n.sinkRedaction = r
+	}
+}
+
+// Apply returns the message with the DIDs and keys redacted, as generic JSON. The message is returned as is if there
+// is nothing to redact.
+func (r *Redaction) Apply(msg interface{}) (interface{}, error) {
+	if r.raw() {
+		return msg, nil
+	}
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal message : %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	var v interface{}
+
+	if err = decoder.Decode(&v); err != nil {
+		return nil, fmt.Errorf("unmarshal message : %w", err)
+	}
+
+	redacted, _ := r.redact(v, false)
+
+	return redacted, nil
+}
+
+func (r *Redaction) raw() bool {
+	return r == nil || (r.DIDs == "" || r.DIDs == RedactionRaw) && (r.Keys == "" || r.Keys == RedactionRaw)
+}
+
+// redact returns the value redacted, and false if it is omitted. The values of the key fields are redacted as keys.
+func (r *Redaction) redact(v interface{}, keyField bool) (interface{}, bool) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, field := range val {
+			redacted, keep := r.redact(field, keyFields[k])
+			if !keep {
+				delete(val, k)
+
+				continue
+			}
+
+			val[k] = redacted
+		}
+
+		return val, true
+	case []interface{}:
+		items := make([]interface{}, 0, len(val))
+
+		for _, item := range val {
+			if redacted, keep := r.redact(item, keyField); keep {
+				items = append(items, redacted)
+			}
+		}
+
+		return items, true
+	case string:
+		return r.redactString(val, keyField)
+	default:
+		return v, true
+	}
+}
+
+func (r *Redaction) redactString(s string, keyField bool) (interface{}, bool) {
+	if keyField {
+		return r.identifier(s, r.Keys)
+	}
+
+	if fullDIDPattern.MatchString(s) {
+		return r.identifier(s, r.DIDs)
+	}
+
+	if r.DIDs == "" || r.DIDs == RedactionRaw {
+		return s, true
+	}
+
+	return didPattern.ReplaceAllStringFunc(s, func(did string) string {
+		if r.DIDs == RedactionHashed {
+			return r.hash(did)
+		}
+
+		return RedactedText
+	}), true
+}
+
+// identifier returns the identifier redacted at the level, and false if it is omitted.
+func (r *Redaction) identifier(s, level string) (string, bool) {
+	switch level {
+	case RedactionHashed:
+		return r.hash(s), true
+	case RedactionOmitted:
+		return "", false
+	default:
+		return s, true
+	}
+}
+
+func (r *Redaction) hash(s string) string {
+	mac := hmac.New(sha256.New, []byte(r.Salt))
+	mac.Write([]byte(s)) // nolint:errcheck,gosec // hash writes never fail
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
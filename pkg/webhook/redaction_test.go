@@ -0,0 +1,173 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type redactionMsg struct {
+	ConnectionID  string   `json:"connectionID"`
+	TheirDID      string   `json:"theirDID"`
+	RecipientKey  string   `json:"recipientKey"`
+	RoutingKeys   []string `json:"routingKeys"`
+	Detail        string   `json:"detail"`
+	Depth         int64    `json:"depth"`
+	Senders       []*redactionSender
+	UnrelatedKeys []string `json:"unrelated"`
+}
+
+type redactionSender struct {
+	Key      string `json:"key"`
+	Forwards int    `json:"forwards"`
+}
+
+func newRedactionMsg() *redactionMsg {
+	return &redactionMsg{
+		ConnectionID:  "conn-1",
+		TheirDID:      "did:peer:1zQmZkgqsP9BaqdTWkU8y6mD7E4dYLU2jkDmmSMYVn2ZSRrt",
+		RecipientKey:  "5Kgs5vPFQuUcVq5eECMb3fHQNtkNcGdiJf4LWxLxZfX6",
+		RoutingKeys:   []string{"8HH5gYEeNc3z7PYXmd54d4x6qAfCNrqQqEB3nS7Zfu7K"},
+		Detail:        "envelope from did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK#key-1, rejected",
+		Depth:         9007199254740993,
+		Senders:       []*redactionSender{{Key: "8HH5gYEeNc3z7PYXmd54d4x6qAfCNrqQqEB3nS7Zfu7K", Forwards: 3}},
+		UnrelatedKeys: []string{"did:example:123"},
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	t.Run("raw", func(t *testing.T) {
+		msg := newRedactionMsg()
+
+		for _, r := range []*Redaction{nil, {}, {DIDs: RedactionRaw, Keys: RedactionRaw}} {
+			redacted, err := r.Apply(msg)
+			require.NoError(t, err)
+			require.Equal(t, msg, redacted)
+		}
+	})
+
+	t.Run("hashed", func(t *testing.T) {
+		r := &Redaction{DIDs: RedactionHashed, Keys: RedactionHashed, Salt: "salt"}
+
+		redacted, err := r.Apply(newRedactionMsg())
+		require.NoError(t, err)
+
+		b, err := json.Marshal(redacted)
+		require.NoError(t, err)
+
+		msg := &redactionMsg{}
+		require.NoError(t, json.Unmarshal(b, msg))
+
+		didHash := r.hash("did:peer:1zQmZkgqsP9BaqdTWkU8y6mD7E4dYLU2jkDmmSMYVn2ZSRrt")
+		keyHash := r.hash("8HH5gYEeNc3z7PYXmd54d4x6qAfCNrqQqEB3nS7Zfu7K")
+
+		require.Len(t, didHash, 43)
+		require.NotEqual(t, didHash, (&Redaction{}).hash("did:peer:1zQmZkgqsP9BaqdTWkU8y6mD7E4dYLU2jkDmmSMYVn2ZSRrt"))
+		require.Equal(t, "conn-1", msg.ConnectionID)
+		require.Equal(t, didHash, msg.TheirDID)
+		require.Equal(t, r.hash("5Kgs5vPFQuUcVq5eECMb3fHQNtkNcGdiJf4LWxLxZfX6"), msg.RecipientKey)
+		require.Equal(t, []string{keyHash}, msg.RoutingKeys)
+		require.Equal(t, keyHash, msg.Senders[0].Key)
+		require.Equal(t, 3, msg.Senders[0].Forwards)
+		require.Equal(t, "envelope from "+r.hash("did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK#key-1")+
+			", rejected", msg.Detail)
+		require.Equal(t, int64(9007199254740993), msg.Depth)
+		require.Equal(t, []string{r.hash("did:example:123")}, msg.UnrelatedKeys)
+	})
+
+	t.Run("omitted", func(t *testing.T) {
+		r := &Redaction{DIDs: RedactionOmitted, Keys: RedactionOmitted}
+
+		redacted, err := r.Apply(newRedactionMsg())
+		require.NoError(t, err)
+
+		b, err := json.Marshal(redacted)
+		require.NoError(t, err)
+
+		msg := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(b, &msg))
+
+		require.Equal(t, "conn-1", msg["connectionID"])
+		require.NotContains(t, msg, "theirDID")
+		require.NotContains(t, msg, "recipientKey")
+		require.Empty(t, msg["routingKeys"])
+		require.Equal(t, []interface{}{map[string]interface{}{"forwards": float64(3)}}, msg["Senders"])
+		require.Equal(t, "envelope from "+RedactedText+", rejected", msg["detail"])
+		require.Empty(t, msg["unrelated"])
+	})
+
+	t.Run("keys only", func(t *testing.T) {
+		r := &Redaction{Keys: RedactionOmitted}
+
+		redacted, err := r.Apply(newRedactionMsg())
+		require.NoError(t, err)
+
+		m, ok := redacted.(map[string]interface{})
+		require.True(t, ok)
+		require.Equal(t, "did:peer:1zQmZkgqsP9BaqdTWkU8y6mD7E4dYLU2jkDmmSMYVn2ZSRrt", m["theirDID"])
+		require.Equal(t, newRedactionMsg().Detail, m["detail"])
+		require.NotContains(t, m, "recipientKey")
+	})
+
+	t.Run("marshal error", func(t *testing.T) {
+		_, err := (&Redaction{DIDs: RedactionHashed}).Apply(make(chan int))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "marshal message")
+
+		err = New([]string{"http://localhost"}, nil, WithRedaction(&Redaction{DIDs: RedactionHashed})).
+			Notify("presence", make(chan int))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "redact webhook message")
+	})
+
+	t.Run("valid levels", func(t *testing.T) {
+		require.True(t, ValidRedaction(RedactionRaw))
+		require.True(t, ValidRedaction(RedactionHashed))
+		require.True(t, ValidRedaction(RedactionOmitted))
+		require.False(t, ValidRedaction("masked"))
+	})
+}
+
+func TestNotifierRedaction(t *testing.T) {
+	msgs := make(chan *Message, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := &Message{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(msg))
+
+		msgs <- msg
+	}))
+	defer srv.Close()
+
+	sink := &mockSink{}
+
+	n := New([]string{srv.URL}, nil, WithSinks(sink),
+		WithRedaction(&Redaction{DIDs: RedactionHashed, Keys: RedactionRaw}),
+		WithSinkRedaction(&Redaction{DIDs: RedactionOmitted, Keys: RedactionOmitted}))
+
+	require.NoError(t, n.Notify("security", map[string]string{
+		"connectionID": "conn-1", "theirDID": "did:example:123", "recipientKey": "key-1",
+	}))
+
+	msg := <-msgs
+	require.Equal(t, map[string]interface{}{
+		"connectionID": "conn-1", "theirDID": (&Redaction{}).hash("did:example:123"), "recipientKey": "key-1",
+	}, msg.Message)
+
+	require.Len(t, sink.notifications, 1)
+	require.Equal(t, msg.ID, sink.notifications[0].ID)
+	require.Equal(t, "conn-1", sink.notifications[0].ConnectionID)
+
+	sinkMsg := &Message{}
+	require.NoError(t, json.Unmarshal(sink.notifications[0].Body, sinkMsg))
+	require.Equal(t, map[string]interface{}{"connectionID": "conn-1"}, sinkMsg.Message)
+}
@@ -0,0 +1,45 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+)
+
+// Schema versions of the webhook messages.
+const (
+	// SchemaVersion1 is the original message : id, topic and message.
+	SchemaVersion1 = "1"
+	// SchemaVersion2 adds the schemaVersion and the time of the notification to the message.
+	SchemaVersion2 = "2"
+	// CurrentSchemaVersion is the schema version of the messages, unless the compatibility mode is configured.
+	CurrentSchemaVersion = SchemaVersion2
+)
+
+// ErrUnknownSchemaVersion is returned for an unsupported schema version.
+var ErrUnknownSchemaVersion = errors.New("unknown schema version")
+
+//go:embed schema/*.json
+var schemaFS embed.FS
+
+// SchemaVersions returns the supported schema versions, oldest first.
+func SchemaVersions() []string {
+	return []string{SchemaVersion1, SchemaVersion2}
+}
+
+// Schema returns the JSON schema of the webhook messages of the given version.
+func Schema(version string) ([]byte, error) {
+	for _, v := range SchemaVersions() {
+		if v == version {
+			return schemaFS.ReadFile("schema/v" + version + ".json")
+		}
+	}
+
+	return nil, fmt.Errorf("%w : %s", ErrUnknownSchemaVersion, version)
+}
@@ -0,0 +1,118 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/xeipuuv/gojsonschema"
+
+	"github.com/trustbloc/hub-router/pkg/backpressure"
+	"github.com/trustbloc/hub-router/pkg/events"
+	"github.com/trustbloc/hub-router/pkg/presence"
+	"github.com/trustbloc/hub-router/pkg/queue"
+	"github.com/trustbloc/hub-router/pkg/slowconsumer"
+)
+
+func TestSchema(t *testing.T) {
+	now := time.Now().UTC()
+
+	msgs := map[string]interface{}{
+		"presence": &presence.Record{ConnectionID: "conn-1", Status: presence.Online, LastSeen: now, Source: "pickup"},
+		"queue": &queue.Alert{
+			Time: now, Scope: queue.ScopeRecipient, State: queue.StateHigh, ConnectionID: "conn-1", Depth: 10, Watermark: 5,
+		},
+		"slow-consumer": &slowconsumer.Status{
+			ConnectionID: "conn-1", Slow: true, Kind: "pickup", Latency: time.Second, Exceeded: 3, Since: now,
+		},
+		"security": &events.SecurityEvent{Time: now, Kind: events.SecurityKeyMismatch, Detail: "key mismatch"},
+		"delivery-status": &backpressure.Signal{
+			Time: now, State: backpressure.StateFull, ConnectionID: "conn-1", MsgID: "msg-1", Depth: 10, Cap: 10,
+			Rejected: true, RetryAfter: 60,
+		},
+		"unknown": map[string]string{"any": "payload"},
+	}
+
+	for _, version := range SchemaVersions() {
+		version := version
+
+		t.Run("version "+version, func(t *testing.T) {
+			schemaBytes, err := Schema(version)
+			require.NoError(t, err)
+
+			schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(schemaBytes))
+			require.NoError(t, err)
+
+			n := New(nil, nil, WithSchemaVersion(version))
+
+			for topic, msg := range msgs {
+				msgBytes, err := json.Marshal(n.message("id", topic, msg))
+				require.NoError(t, err)
+
+				result, err := schema.Validate(gojsonschema.NewBytesLoader(msgBytes))
+				require.NoError(t, err)
+				require.True(t, result.Valid(), "%s : %v", topic, result.Errors())
+			}
+
+			msgBytes, err := json.Marshal(n.message("id", "presence", map[string]string{"status": "away"}))
+			require.NoError(t, err)
+
+			result, err := schema.Validate(gojsonschema.NewBytesLoader(msgBytes))
+			require.NoError(t, err)
+			require.False(t, result.Valid())
+		})
+	}
+
+	t.Run("previous version does not validate the current messages", func(t *testing.T) {
+		schemaBytes, err := Schema(SchemaVersion2)
+		require.NoError(t, err)
+
+		n := New(nil, nil, WithSchemaVersion(SchemaVersion1))
+
+		msgBytes, err := json.Marshal(n.message("id", "queue", msgs["queue"]))
+		require.NoError(t, err)
+
+		result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schemaBytes), gojsonschema.NewBytesLoader(msgBytes))
+		require.NoError(t, err)
+		require.False(t, result.Valid())
+	})
+
+	t.Run("unknown version", func(t *testing.T) {
+		_, err := Schema("0")
+		require.ErrorIs(t, err, ErrUnknownSchemaVersion)
+	})
+}
+
+func TestSchemaVersion(t *testing.T) {
+	msgs := make(chan map[string]interface{}, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		msg := make(map[string]interface{})
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+
+		msgs <- msg
+	}))
+	defer srv.Close()
+
+	require.NoError(t, New([]string{srv.URL}, nil).Notify("presence", nil))
+
+	msg := <-msgs
+	require.Equal(t, CurrentSchemaVersion, msg["schemaVersion"])
+	require.NotEmpty(t, msg["time"])
+
+	require.NoError(t, New([]string{srv.URL}, nil, WithSchemaVersion(SchemaVersion1)).Notify("presence", nil))
+
+	msg = <-msgs
+	require.NotContains(t, msg, "schemaVersion")
+	require.NotContains(t, msg, "time")
+	require.Len(t, msg, 3)
+}
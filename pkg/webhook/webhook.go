@@ -0,0 +1,300 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/pkg/cloudevents"
+)
+
+const (
+	contentType    = "application/json"
+	defaultTimeout = 10 * time.Second
+)
+
+var logger = log.New("hub-router/webhook")
+
+// HTTPClient posts the webhook notifications.
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Message is the webhook notification payload. SchemaVersion and Time are not set with the schema version 1.
+type Message struct {
+	SchemaVersion string      `json:"schemaVersion,omitempty"`
+	ID            string      `json:"id"`
+	Topic         string      `json:"topic"`
+	Time          *time.Time  `json:"time,omitempty"`
+	Message       interface{} `json:"message"`
+}
+
+// Notification is an encoded notification, as posted to the webhooks.
+type Notification struct {
+	ID    string
+	Topic string
+	// ConnectionID is the connection the notification is about, if any, eg: to order the notifications per connection.
+	ConnectionID string
+	Body         []byte
+	// Headers of the webhook request : the content type, and the event attributes in CloudEvents binary mode.
+	Headers map[string]string
+}
+
+// Sink publishes the notifications to another destination than the webhook URLs, eg: a cloud messaging service.
+type Sink interface {
+	Publish(n *Notification) error
+}
+
+// Notifier posts event notifications to the configured webhook URLs and sinks.
+type Notifier struct {
+	urls          []string
+	sinks         []Sink
+	client        HTTPClient
+	timeout       time.Duration
+	schemaVersion string
+	ceMode        string
+	ceSource      string
+	redaction     *Redaction
+	sinkRedaction *Redaction
+	connWebhooks  *ConnectionWebhooks
+	connClient    HTTPClient
+}
+
+// Option configures the Notifier.
+type Option func(n *Notifier)
+
+// WithSchemaVersion sets the schema version of the messages, eg: the previous version for the consumers not
+// supporting the current one yet. Defaults to CurrentSchemaVersion if empty.
+func WithSchemaVersion(version string) Option {
+	return func(n *Notifier) {
+		if version != "" {
+			n.schemaVersion = version
+		}
+	}
+}
+
+// WithCloudEvents sends the notifications as CloudEvents 1.0 events of the given content mode (structured or binary),
+// with the given source. The event type is the topic prefixed with the hub-router namespace, and the event data is the
+// message of the topic.
+func WithCloudEvents(mode, source string) Option {
+	return func(n *Notifier) {
+		n.ceMode = mode
+		n.ceSource = source
+	}
+}
+
+// WithSinks publishes the notifications to the sinks, in addition to the webhook URLs.
+func WithSinks(sinks ...Sink) Option {
+	return func(n *Notifier) {
+		n.sinks = append(n.sinks, sinks...)
+	}
+}
+
+// New returns a new Notifier posting to the given URLs. Notify is a no-op if no URL or sink is configured.
+func New(urls []string, client HTTPClient, opts ...Option) *Notifier {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	n := &Notifier{urls: urls, client: client, timeout: defaultTimeout, schemaVersion: CurrentSchemaVersion}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	return n
+}
+
+// Notify posts the message with the given topic to all the webhook URLs and sinks, and to the webhook of the
+// connection the message is about, if registered.
+func (n *Notifier) Notify(topic string, msg interface{}) error {
+	if n == nil || len(n.urls) == 0 && len(n.sinks) == 0 && n.connWebhooks == nil {
+		return nil
+	}
+
+	id := uuid.New().String()
+
+	notification, err := n.encode(id, topic, msg, n.redaction)
+	if err != nil {
+		return err
+	}
+
+	var errs []string
+
+	for _, url := range n.urls {
+		err = n.post(n.client, url, notification)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if err = n.postConnectionWebhook(notification); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	if len(n.sinks) > 0 && n.sinkRedaction != nil {
+		notification, err = n.encode(id, topic, msg, n.sinkRedaction)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, sink := range n.sinks {
+		err = sink.Publish(notification)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("notify webhooks : %s", strings.Join(errs, "; "))
+	}
+
+	logger.Debugf("webhook notification sent : topic=%s", topic)
+
+	return nil
+}
+
+// encode returns the notification : the versioned message, or the CloudEvents event, with the message redacted.
+func (n *Notifier) encode(id, topic string, msg interface{}, redaction *Redaction) (*Notification, error) {
+	notification := &Notification{ID: id, Topic: topic, ConnectionID: connectionID(msg)}
+
+	msg, err := redaction.Apply(msg)
+	if err != nil {
+		return nil, fmt.Errorf("redact webhook message : %w", err)
+	}
+
+	if n.ceMode == "" {
+		msgBytes, marshalErr := json.Marshal(n.message(notification.ID, topic, msg))
+		if marshalErr != nil {
+			return nil, fmt.Errorf("marshal webhook message : %w", marshalErr)
+		}
+
+		notification.Body = msgBytes
+		notification.Headers = map[string]string{"Content-Type": contentType}
+
+		return notification, nil
+	}
+
+	event, err := cloudevents.New(notification.ID, n.ceSource, cloudevents.Type(topic), time.Now(), msg)
+	if err != nil {
+		return nil, fmt.Errorf("marshal webhook message : %w", err)
+	}
+
+	if n.ceMode == cloudevents.ModeBinary {
+		notification.Body = event.Data
+		notification.Headers = event.Headers(cloudevents.HTTPHeaderPrefix)
+		notification.Headers["Content-Type"] = event.DataContentType
+
+		return notification, nil
+	}
+
+	notification.Body, err = json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("marshal webhook message : %w", err)
+	}
+
+	notification.Headers = map[string]string{"Content-Type": cloudevents.ContentType}
+
+	return notification, nil
+}
+
+// connectionID returns the connectionID field of the message, if any.
+func connectionID(msg interface{}) string {
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return ""
+	}
+
+	conn := &struct {
+		ConnectionID string `json:"connectionID"`
+	}{}
+
+	err = json.Unmarshal(msgBytes, conn)
+	if err != nil {
+		return ""
+	}
+
+	return conn.ConnectionID
+}
+
+func (n *Notifier) message(id, topic string, msg interface{}) *Message {
+	m := &Message{ID: id, Topic: topic, Message: msg}
+
+	if n.schemaVersion != SchemaVersion1 {
+		now := time.Now().UTC()
+
+		m.SchemaVersion = n.schemaVersion
+		m.Time = &now
+	}
+
+	return m
+}
+
+// ConnectionWebhooks returns the webhooks registered for the connections, nil if not enabled.
+func (n *Notifier) ConnectionWebhooks() *ConnectionWebhooks {
+	if n == nil {
+		return nil
+	}
+
+	return n.connWebhooks
+}
+
+// postConnectionWebhook posts the notification about a connection to the webhook registered for the connection, if
+// any.
+func (n *Notifier) postConnectionWebhook(notification *Notification) error {
+	if n.connWebhooks == nil || notification.ConnectionID == "" {
+		return nil
+	}
+
+	url, err := n.connWebhooks.Get(notification.ConnectionID)
+	if err != nil || url == "" {
+		return err
+	}
+
+	return n.post(n.connClient, url, notification)
+}
+
+func (n *Notifier) post(client HTTPClient, url string, notification *Notification) error {
+	ctx, cancel := context.WithTimeout(context.Background(), n.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(notification.Body))
+	if err != nil {
+		return fmt.Errorf("create webhook request : %w", err)
+	}
+
+	for k, v := range notification.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("post webhook %s : %w", url, err)
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			logger.Warnf("failed to close webhook response body : %s", errClose)
+		}
+	}()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("post webhook %s : unexpected status %d", url, resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/trustbloc/hub-router/pkg/cloudevents"
+)
+
+func TestNotifier(t *testing.T) {
+	t.Run("notify", func(t *testing.T) {
+		msgs := make(chan *Message, 2)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, contentType, r.Header.Get("Content-Type"))
+
+			msg := &Message{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(msg))
+
+			msgs <- msg
+		}))
+		defer srv.Close()
+
+		n := New([]string{srv.URL, srv.URL}, nil)
+
+		require.NoError(t, n.Notify("presence", map[string]string{"status": "online"}))
+		require.Len(t, msgs, 2)
+
+		msg := <-msgs
+		require.NotEmpty(t, msg.ID)
+		require.Equal(t, "presence", msg.Topic)
+		require.Equal(t, map[string]interface{}{"status": "online"}, msg.Message)
+	})
+
+	t.Run("cloudevents", func(t *testing.T) {
+		reqs := make(chan *http.Request, 1)
+		bodies := make(chan map[string]interface{}, 1)
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := make(map[string]interface{})
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+
+			reqs <- r
+			bodies <- body
+		}))
+		defer srv.Close()
+
+		n := New([]string{srv.URL}, nil, WithCloudEvents(cloudevents.ModeStructured, "/hub-router"))
+		require.NoError(t, n.Notify("presence", map[string]string{"status": "online"}))
+
+		r, body := <-reqs, <-bodies
+		require.Equal(t, cloudevents.ContentType, r.Header.Get("Content-Type"))
+		require.Equal(t, "1.0", body["specversion"])
+		require.Equal(t, "/hub-router", body["source"])
+		require.Equal(t, "org.trustbloc.hub-router.presence", body["type"])
+		require.Equal(t, map[string]interface{}{"status": "online"}, body["data"])
+
+		n = New([]string{srv.URL}, nil, WithCloudEvents(cloudevents.ModeBinary, "/hub-router"))
+		require.NoError(t, n.Notify("presence", map[string]string{"status": "online"}))
+
+		r, body = <-reqs, <-bodies
+		require.Equal(t, contentType, r.Header.Get("Content-Type"))
+		require.Equal(t, "1.0", r.Header.Get("ce-specversion"))
+		require.NotEmpty(t, r.Header.Get("ce-id"))
+		require.NotEmpty(t, r.Header.Get("ce-time"))
+		require.Equal(t, "org.trustbloc.hub-router.presence", r.Header.Get("ce-type"))
+		require.Equal(t, map[string]interface{}{"status": "online"}, body)
+
+		err := n.Notify("presence", make(chan int))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "marshal webhook message")
+	})
+
+	t.Run("sinks", func(t *testing.T) {
+		sink := &mockSink{}
+
+		n := New(nil, nil, WithSinks(sink))
+
+		require.NoError(t, n.Notify("presence", map[string]string{"connectionID": "conn-1"}))
+		require.Len(t, sink.notifications, 1)
+		require.NotEmpty(t, sink.notifications[0].ID)
+		require.Equal(t, "presence", sink.notifications[0].Topic)
+		require.Equal(t, "conn-1", sink.notifications[0].ConnectionID)
+		require.Equal(t, contentType, sink.notifications[0].Headers["Content-Type"])
+
+		msg := &Message{}
+		require.NoError(t, json.Unmarshal(sink.notifications[0].Body, msg))
+		require.Equal(t, sink.notifications[0].ID, msg.ID)
+
+		require.NoError(t, n.Notify("queue", []string{"no connection"}))
+		require.Empty(t, sink.notifications[1].ConnectionID)
+
+		sink.err = errors.New("sink error")
+
+		err := n.Notify("presence", nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "sink error")
+	})
+
+	t.Run("no webhook", func(t *testing.T) {
+		var n *Notifier
+
+		require.NoError(t, n.Notify("presence", nil))
+		require.NoError(t, New(nil, nil).Notify("presence", nil))
+	})
+
+	t.Run("marshal error", func(t *testing.T) {
+		err := New([]string{"http://localhost"}, nil).Notify("presence", make(chan int))
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "marshal webhook message")
+	})
+
+	t.Run("post error", func(t *testing.T) {
+		err := New([]string{"http://invalid.example.com:-1"}, nil).Notify("presence", nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "create webhook request")
+	})
+
+	t.Run("unexpected status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		err := New([]string{srv.URL}, nil).Notify("presence", nil)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unexpected status 500")
+	})
+}
+
+type mockSink struct {
+	notifications []*Notification
+	err           error
+}
+
+func (s *mockSink) Publish(n *Notification) error {
+	s.notifications = append(s.notifications, n)
+
+	return s.err
+}
@@ -0,0 +1,28 @@
+#!/bin/bash
+#
+# Copyright SecureKey Technologies Inc. All Rights Reserved.
+#
+# SPDX-License-Identifier: Apache-2.0
+#
+set -e
+
+echo "Running $0"
+
+PLATFORMS=${PLATFORMS:-"linux/amd64 linux/arm64 windows/amd64"}
+
+pwd=`pwd`
+
+# vet compiles the packages and their tests for each release platform, so that the platform specific code paths
+# (eg: the cgroup limits, which Windows doesn't have) are checked without the target hardware
+for platform in $PLATFORMS; do
+    echo "Checking $platform"
+
+    export GOOS=${platform%/*}
+    export GOARCH=${platform#*/}
+
+    go vet github.com/trustbloc/hub-router/...
+
+    cd cmd/hub-router
+    go vet github.com/trustbloc/hub-router/cmd/hub-router/...
+    cd "$pwd"
+done
@@ -32,3 +32,10 @@ PKGS=`go list github.com/trustbloc/hub-router/cmd/hub-router/... 2> /dev/null |
 go test $PKGS -count=1 -race -coverprofile=profile.out -covermode=atomic -timeout=10m
 amend_coverage_file
 cd "$pwd"
+
+# Running wallet simulator unit tests
+cd test/tools/walletsim
+PKGS=`go list github.com/trustbloc/hub-router/test/tools/walletsim/... 2> /dev/null`
+go test $PKGS -count=1 -race -coverprofile=profile.out -covermode=atomic -timeout=10m
+amend_coverage_file
+cd "$pwd"
@@ -54,7 +54,7 @@ func TestMain(m *testing.M) {
 func runBDDTests(tags, format string) int { // nolint:gocognit // done this way across all our projects
 	return godog.RunWithOptions("godogs", func(s *godog.Suite) {
 		var composition []*dockerutil.Composition
-		var composeFiles = []string{"./fixtures/hub-router", "./fixtures/integration"}
+		var composeFiles = bddctx.ComposeFiles(os.Getenv("BDD_PROFILE"))
 		s.BeforeSuite(func() {
 			if os.Getenv("DISABLE_COMPOSITION") != "true" {
 				// Need a unique name, but docker does not allow '-' in names
@@ -122,7 +122,7 @@ func generateUUID() string {
 }
 
 func FeatureContext(s *godog.Suite) {
-	bddContext, err := bddctx.NewBDDContext("fixtures/keys/tls/ec-cacert.pem")
+	bddContext, err := bddctx.NewBDDContext("fixtures/keys/tls/ec-cacert.pem", os.Getenv("BDD_PROFILE"))
 	if err != nil {
 		panic(fmt.Sprintf("Error returned from NewBDDContext: %s", err))
 	}
@@ -0,0 +1,24 @@
+#
+# Copyright SecureKey Technologies Inc. All Rights Reserved.
+#
+# SPDX-License-Identifier: Apache-2.0
+#
+
+# Runs against the cluster and couchdb profiles : BDD_PROFILE=cluster TAGS=cluster, BDD_PROFILE=couchdb TAGS=cluster
+@cluster
+Feature: Hub Router Cluster
+
+  Scenario: Router replicas share the storage
+    When Wallet gets DIDComm invitation from hub-router replica 2
+    Then Wallet connects with Router
+    And  Wallet registers with the Router for mediation
+    Then the mediated wallets are the same on every hub-router replica
+
+  Scenario: Router replicas agree on the revisions of the shared records
+    When Wallet gets DIDComm invitation from hub-router replica 1
+    Then Wallet connects with Router
+    And  Wallet registers with the Router for mediation
+    When the webhook of the wallet connection is registered on hub-router replica 1
+    Then hub-router replica 2 returns the webhook with the same ETag
+    When the webhook is changed on hub-router replica 2 with that ETag
+    Then the webhook change on hub-router replica 1 with the previous ETag is rejected
@@ -17,3 +17,15 @@ Feature: Hub Router Integration
     Then Wallet sends establish connection request for adapter
     And  Wallet passes the details of router to adapter
     And  Adapter registers with the Router for mediation
+
+  Scenario: Forward messages between Wallet and Adapter through Router
+    When Wallet gets DIDComm invitation from hub-router
+    Then Wallet connects with Router
+    And  Wallet registers with the Router for mediation
+    Then Wallet gets invitation from Adapter
+    And  Wallet connects with Adapter
+    Then Wallet sends establish connection request for adapter
+    And  Wallet passes the details of router to adapter
+    And  Adapter registers with the Router for mediation
+    Then Wallet sends a message to Adapter through the Router
+    And  Adapter replies to Wallet through the Router
@@ -12,19 +12,58 @@ import (
 	tlsutils "github.com/trustbloc/edge-core/pkg/utils/tls"
 )
 
+// BDD profiles, the profile selects the docker-compose fixtures the scenarios run against.
+const (
+	// ProfileDefault runs a single hub-router backed by MySQL.
+	ProfileDefault = "default"
+	// ProfileCluster runs two hub-router replicas sharing the same MySQL storage.
+	ProfileCluster = "cluster"
+	// ProfileCouchDB runs two hub-router replicas sharing the same CouchDB storage.
+	ProfileCouchDB = "couchdb"
+)
+
 // BDDContext is a global context shared between different test suites in bddtests.
 type BDDContext struct {
-	TLSConfig *tls.Config
+	TLSConfig     *tls.Config
+	Profile       string
+	HubRouterURLs []string
 }
 
 // NewBDDContext create new BDDContext.
-func NewBDDContext(caCertPath string) (*BDDContext, error) {
+func NewBDDContext(caCertPath, profile string) (*BDDContext, error) {
 	rootCAs, err := tlsutils.GetCertPool(false, []string{caCertPath})
 	if err != nil {
 		return nil, err
 	}
 
+	if profile == "" {
+		profile = ProfileDefault
+	}
+
 	return &BDDContext{
-		TLSConfig: &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12},
+		TLSConfig:     &tls.Config{RootCAs: rootCAs, MinVersion: tls.VersionTLS12},
+		Profile:       profile,
+		HubRouterURLs: HubRouterURLs(profile),
 	}, nil
 }
+
+// ComposeFiles returns the docker-compose fixtures of the profile.
+func ComposeFiles(profile string) []string {
+	switch profile {
+	case ProfileCluster:
+		return []string{"./fixtures/cluster/hub-router", "./fixtures/integration"}
+	case ProfileCouchDB:
+		return []string{"./fixtures/couchdb/hub-router", "./fixtures/integration"}
+	}
+
+	return []string{"./fixtures/hub-router", "./fixtures/integration"}
+}
+
+// HubRouterURLs returns the REST API URLs of the hub-router replicas of the profile.
+func HubRouterURLs(profile string) []string {
+	if profile == ProfileCluster || profile == ProfileCouchDB {
+		return []string{"https://localhost:10200", "https://localhost:10300"}
+	}
+
+	return []string{"https://localhost:10200"}
+}
@@ -0,0 +1,190 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package router
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/cucumber/godog"
+	didexcmd "github.com/hyperledger/aries-framework-go/pkg/controller/command/didexchange"
+
+	"github.com/trustbloc/hub-router/pkg/restapi/operation"
+	"github.com/trustbloc/hub-router/test/bdd/pkg/bddutil"
+)
+
+const (
+	connectionWebhookPathFmt = "/connections/%s/webhook"
+	clusterWebhookURL        = "http://webhook.example.com/connections/events"
+)
+
+// registerClusterSteps registers the steps checking that the replicas of the cluster profile agree on the records
+// they share.
+func (e *Steps) registerClusterSteps(s *godog.Suite) {
+	s.Step(`^the webhook of the wallet connection is registered on hub-router replica (\d+)$`, e.registerWebhook)
+	s.Step(`^hub-router replica (\d+) returns the webhook with the same ETag$`, e.sameWebhookETag)
+	s.Step(`^the webhook is changed on hub-router replica (\d+) with that ETag$`, e.changeWebhook)
+	s.Step(`^the webhook change on hub-router replica (\d+) with the previous ETag is rejected$`, e.staleWebhookChange)
+}
+
+// replicaURL returns the REST API URL of the replica, numbered from 1.
+func (e *Steps) replicaURL(replica int) (string, error) {
+	if replica < 1 || replica > len(e.bddContext.HubRouterURLs) {
+		return "", fmt.Errorf("no hub-router replica %d in the %s profile", replica, e.bddContext.Profile)
+	}
+
+	return e.bddContext.HubRouterURLs[replica-1], nil
+}
+
+// routerConnIDOf returns the ID of the connection of the wallet on the router side, registered for mediation.
+func (e *Steps) routerConnIDOf(routerURL string) (string, error) {
+	var conn didexcmd.QueryConnectionResponse
+
+	err := bddutil.SendHTTPReq(http.MethodGet, walletAPIURL+fmt.Sprintf(connectionsByIDPathFmt, e.walletRouterConnID),
+		nil, &conn, e.bddContext.TLSConfig)
+	if err != nil {
+		return "", fmt.Errorf("get wallet connection : %w", err)
+	}
+
+	var clients operation.MediationClientsResp
+
+	err = bddutil.SendHTTPReq(http.MethodGet, routerURL+"/connections", nil, &clients, e.bddContext.TLSConfig)
+	if err != nil {
+		return "", fmt.Errorf("get mediation clients from %s : %w", routerURL, err)
+	}
+
+	for _, c := range clients.Connections {
+		if c.TheirDID == conn.Result.MyDID {
+			return c.ConnectionID, nil
+		}
+	}
+
+	return "", fmt.Errorf("wallet DID %s not registered on %s", conn.Result.MyDID, routerURL)
+}
+
+func (e *Steps) registerWebhook(replica int) error {
+	routerURL, err := e.replicaURL(replica)
+	if err != nil {
+		return err
+	}
+
+	e.routerConnID, err = e.routerConnIDOf(routerURL)
+	if err != nil {
+		return err
+	}
+
+	e.webhookETag, err = e.putWebhook(routerURL, "", http.StatusOK)
+
+	return err
+}
+
+func (e *Steps) sameWebhookETag(replica int) error {
+	routerURL, err := e.replicaURL(replica)
+	if err != nil {
+		return err
+	}
+
+	resp, respBytes, err := e.webhookRequest(http.MethodGet, routerURL, "", nil)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return bddutil.ExpectedStatusCodeError(http.StatusOK, resp.StatusCode, respBytes)
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != e.webhookETag {
+		return fmt.Errorf("webhook ETag on %s : expected=%s actual=%s", routerURL, e.webhookETag, etag)
+	}
+
+	return nil
+}
+
+func (e *Steps) changeWebhook(replica int) error {
+	routerURL, err := e.replicaURL(replica)
+	if err != nil {
+		return err
+	}
+
+	e.previousWebhookETag = e.webhookETag
+
+	e.webhookETag, err = e.putWebhook(routerURL, e.webhookETag, http.StatusOK)
+	if err != nil {
+		return err
+	}
+
+	if e.webhookETag == e.previousWebhookETag {
+		return fmt.Errorf("webhook ETag on %s not changed : %s", routerURL, e.webhookETag)
+	}
+
+	return nil
+}
+
+func (e *Steps) staleWebhookChange(replica int) error {
+	routerURL, err := e.replicaURL(replica)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.putWebhook(routerURL, e.previousWebhookETag, http.StatusPreconditionFailed)
+
+	return err
+}
+
+// putWebhook registers the webhook of the connection, with the If-Match header if the ETag is set, and returns the
+// ETag of the response.
+func (e *Steps) putWebhook(routerURL, etag string, expectedStatus int) (string, error) {
+	reqBytes, err := json.Marshal(&operation.ConnectionWebhookReq{URL: clusterWebhookURL})
+	if err != nil {
+		return "", fmt.Errorf("marshal webhook request : %w", err)
+	}
+
+	resp, respBytes, err := e.webhookRequest(http.MethodPut, routerURL, etag, reqBytes)
+	if err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != expectedStatus {
+		return "", bddutil.ExpectedStatusCodeError(expectedStatus, resp.StatusCode, respBytes)
+	}
+
+	return resp.Header.Get("ETag"), nil
+}
+
+func (e *Steps) webhookRequest(method, routerURL, etag string, body []byte) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(context.Background(), method,
+		routerURL+fmt.Sprintf(connectionWebhookPathFmt, e.routerConnID), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("create webhook request : %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if etag != "" {
+		req.Header.Set("If-Match", etag)
+	}
+
+	httpClient := &http.Client{Transport: &http.Transport{TLSClientConfig: e.bddContext.TLSConfig}}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s webhook on %s : %w", method, routerURL, err)
+	}
+
+	defer bddutil.CloseResponseBody(resp.Body)
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read webhook response : %w", err)
+	}
+
+	return resp, respBytes, nil
+}
@@ -14,6 +14,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
@@ -38,10 +40,11 @@ var logger = log.New("hub-router/routersteps")
 
 const (
 	// base urls.
-	hubRouterURL     = "https://localhost:10200"
-	walletAPIURL     = "https://localhost:10210"
-	adapterAPIURL    = "https://localhost:10220"
-	walletWebhookURL = "http://localhost:10211"
+	hubRouterURL      = "https://localhost:10200"
+	walletAPIURL      = "https://localhost:10210"
+	adapterAPIURL     = "https://localhost:10220"
+	walletWebhookURL  = "http://localhost:10211"
+	adapterWebhookURL = "http://localhost:10222"
 
 	// connection paths.
 	createInvitationPath   = "/outofband/create-invitation"
@@ -55,6 +58,10 @@ const (
 	registerMsgService    = msgServiceOperationID + "/register-service"
 	unregisterMsgService  = msgServiceOperationID + "/unregister-service"
 	sendNewMsg            = msgServiceOperationID + "/send"
+	sendReplyMsg          = msgServiceOperationID + "/reply"
+
+	// message forwarded between the wallet and the adapter through the router.
+	forwardMsgType = "https://trustbloc.dev/bdd/1.0/forward-test"
 
 	// vdr paths.
 	vdrOperationID = "/vdr"
@@ -77,6 +84,10 @@ type Steps struct {
 	adapterRouterConnID  string
 	adapterDID           string
 	routerDIDDoc         *did.Doc
+	forwardedMsgID       string
+	routerConnID         string
+	webhookETag          string
+	previousWebhookETag  string
 }
 
 // NewSteps returns new agent from client SDK.
@@ -89,6 +100,8 @@ func NewSteps(ctx *context.BDDContext) *Steps {
 // RegisterSteps registers agent steps.
 func (e *Steps) RegisterSteps(s *godog.Suite) {
 	s.Step(`^Wallet gets DIDComm invitation from hub-router$`, e.invitation)
+	s.Step(`^Wallet gets DIDComm invitation from hub-router replica (\d+)$`, e.replicaInvitation)
+	s.Step(`^the mediated wallets are the same on every hub-router replica$`, e.sameWalletsOnReplicas)
 	s.Step(`^Wallet connects with Router$`, e.connectWithRouter)
 	s.Step(`^Wallet registers with the Router for mediation$`, e.mediationRegistration)
 	s.Step(`^Wallet gets invitation from Adapter$`, e.adapterInvitation)
@@ -96,11 +109,28 @@ func (e *Steps) RegisterSteps(s *godog.Suite) {
 	s.Step(`^Wallet sends establish connection request for adapter$`, e.establishConnReq)
 	s.Step(`^Wallet passes the details of router to adapter$`, e.adapterEstablishConn)
 	s.Step(`^Adapter registers with the Router for mediation$`, e.routeRegistration)
+	s.Step(`^Wallet sends a message to Adapter through the Router$`, e.walletSendsToAdapter)
+	s.Step(`^Adapter replies to Wallet through the Router$`, e.adapterRepliesToWallet)
+
+	e.registerClusterSteps(s)
 }
 
 func (e *Steps) invitation() error {
+	return e.routerInvitation(hubRouterURL)
+}
+
+func (e *Steps) replicaInvitation(replica int) error {
+	routerURL, err := e.replicaURL(replica)
+	if err != nil {
+		return err
+	}
+
+	return e.routerInvitation(routerURL)
+}
+
+func (e *Steps) routerInvitation(routerURL string) error {
 	resp, err := bddutil.HTTPDo(http.MethodGet, //nolint:bodyclose // false positive as body is closed in util function
-		hubRouterURL+"/didcomm/invitation", "", "", nil, e.bddContext.TLSConfig)
+		routerURL+"/didcomm/invitation", "", "", nil, e.bddContext.TLSConfig)
 	if err != nil {
 		return err
 	}
@@ -128,7 +158,7 @@ func (e *Steps) invitation() error {
 			"https://didcomm.org/out-of-band/1.0/invitation", result.Invitation.Type)
 	}
 
-	e.routerInvitationStr = result.Invitation
+	e.routerInvitationStr = result.Invitation.Invitation
 
 	return nil
 }
@@ -344,6 +374,149 @@ func (e *Steps) routeRegistration() error {
 	return nil
 }
 
+func (e *Steps) walletSendsToAdapter() error {
+	msgSvcName := uuid.New().String()
+
+	err := e.registerMsgServices(adapterAPIURL, msgSvcName, forwardMsgType)
+	if err != nil {
+		return err
+	}
+
+	msgID := uuid.New().String()
+
+	rawBytes, err := json.Marshal(&operation.DIDCommMsg{ID: msgID, Type: forwardMsgType})
+	if err != nil {
+		return fmt.Errorf("failed to get raw message bytes:  %w", err)
+	}
+
+	reqBytes, err := json.Marshal(&messaging.SendNewMessageArgs{
+		ConnectionID: e.walletAdapterConnID,
+		MessageBody:  rawBytes,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = bddutil.SendHTTPReq(http.MethodPost, walletAPIURL+sendNewMsg, reqBytes, nil, e.bddContext.TLSConfig)
+	if err != nil {
+		return fmt.Errorf("failed to send message : %w", err)
+	}
+
+	msg, err := e.pullMsgFromWebhookURL(adapterWebhookURL, msgSvcName)
+	if err != nil {
+		return fmt.Errorf("adapter failed to receive the message : %w", err)
+	}
+
+	receivedID, err := webhookMsgID(msg)
+	if err != nil {
+		return err
+	}
+
+	if receivedID != msgID {
+		return fmt.Errorf("unexpected message received by adapter : expected=%s actual=%s", msgID, receivedID)
+	}
+
+	e.forwardedMsgID = msgID
+
+	return nil
+}
+
+func (e *Steps) adapterRepliesToWallet() error {
+	msgSvcName := uuid.New().String()
+
+	err := e.registerMsgServices(walletAPIURL, msgSvcName, forwardMsgType)
+	if err != nil {
+		return err
+	}
+
+	replyID := uuid.New().String()
+
+	rawBytes, err := json.Marshal(&operation.DIDCommMsg{ID: replyID, Type: forwardMsgType})
+	if err != nil {
+		return fmt.Errorf("failed to get raw message bytes:  %w", err)
+	}
+
+	reqBytes, err := json.Marshal(&messaging.SendReplyMessageArgs{
+		MessageID:   e.forwardedMsgID,
+		MessageBody: rawBytes,
+	})
+	if err != nil {
+		return err
+	}
+
+	// the wallet is reachable only through the router, the reply is forwarded by the router
+	err = bddutil.SendHTTPReq(http.MethodPost, adapterAPIURL+sendReplyMsg, reqBytes, nil, e.bddContext.TLSConfig)
+	if err != nil {
+		return fmt.Errorf("failed to send reply : %w", err)
+	}
+
+	msg, err := e.pullMsgFromWebhookURL(walletWebhookURL, msgSvcName)
+	if err != nil {
+		return fmt.Errorf("wallet failed to receive the reply : %w", err)
+	}
+
+	receivedID, err := webhookMsgID(msg)
+	if err != nil {
+		return err
+	}
+
+	if receivedID != replyID {
+		return fmt.Errorf("unexpected reply received by wallet : expected=%s actual=%s", replyID, receivedID)
+	}
+
+	return nil
+}
+
+func webhookMsgID(webhookMsg *service.DIDCommMsgMap) (string, error) {
+	var message struct {
+		Message operation.DIDCommMsg `json:"message"`
+	}
+
+	err := webhookMsg.Decode(&message)
+	if err != nil {
+		return "", fmt.Errorf("failed to read message: %w", err)
+	}
+
+	return message.Message.ID, nil
+}
+
+func (e *Steps) sameWalletsOnReplicas() error {
+	var expected []string
+
+	for i, routerURL := range e.bddContext.HubRouterURLs {
+		var resp operation.WalletsResp
+
+		err := bddutil.SendHTTPReq(http.MethodGet, routerURL+"/wallets", nil, &resp, e.bddContext.TLSConfig)
+		if err != nil {
+			return fmt.Errorf("get wallets from %s : %w", routerURL, err)
+		}
+
+		var connIDs []string
+
+		for _, w := range resp.Wallets {
+			connIDs = append(connIDs, w.ConnectionID)
+		}
+
+		sort.Strings(connIDs)
+
+		if i == 0 {
+			if len(connIDs) == 0 {
+				return fmt.Errorf("no mediated wallets on %s", routerURL)
+			}
+
+			expected = connIDs
+
+			continue
+		}
+
+		if strings.Join(connIDs, ",") != strings.Join(expected, ",") {
+			return fmt.Errorf("mediated wallets on %s : expected=%v actual=%v", routerURL, expected, connIDs)
+		}
+	}
+
+	return nil
+}
+
 func (e *Steps) validateConnection(connID, state string) error {
 	const (
 		sleep      = 1 * time.Second
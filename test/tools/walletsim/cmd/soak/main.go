@@ -0,0 +1,52 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/test/tools/walletsim"
+	"github.com/trustbloc/hub-router/test/tools/walletsim/soak"
+)
+
+var logger = log.New("hub-router/soak-cli")
+
+func main() {
+	config := &soak.Config{}
+
+	flag.StringVar(&config.RouterURL, "router-url", "https://localhost:10200", "hub-router REST API URL")
+	caCert := flag.String("ca-cert", "", "CA certificate (PEM) used to reach the router, system pool if empty")
+	flag.IntVar(&config.Iterations, "iterations", 1000, "number of connect/mediate/forward/disconnect loops")
+	flag.IntVar(&config.SampleEvery, "sample-every", 50, "number of loops between two diagnostics samples")
+	flag.IntVar(&config.LeakWindow, "leak-window", 10, "number of consecutive growing samples reported as a leak")
+	flag.DurationVar(&config.Timeout, "timeout", 30*time.Second, "timeout waiting for the router")
+
+	flag.Parse()
+
+	var err error
+
+	config.TLSConfig, err = walletsim.LoadTLSConfig(*caCert)
+	if err != nil {
+		logger.Errorf("soak : %s", err)
+		os.Exit(1)
+	}
+
+	samples, err := soak.Run(config)
+
+	for _, s := range samples {
+		fmt.Printf("%d,%d,%d\n", s.Iteration, s.Goroutines, s.HeapObjects)
+	}
+
+	if err != nil {
+		logger.Errorf("soak : %s", err)
+		os.Exit(1)
+	}
+}
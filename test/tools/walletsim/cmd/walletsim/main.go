@@ -0,0 +1,92 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/test/tools/walletsim"
+)
+
+var logger = log.New("hub-router/walletsim-cli")
+
+func main() {
+	routerURL := flag.String("router-url", "https://localhost:10200", "hub-router REST API URL")
+	label := flag.String("label", "walletsim", "wallet label used when connecting to the router")
+	caCert := flag.String("ca-cert", "", "CA certificate (PEM) used to reach the router, system pool if empty")
+	keys := flag.Int("keys", 1, "number of recipient keys to register with the router")
+	pickup := flag.Int("pickup", 0, "batch size to pick up the queued messages with, no pickup if 0")
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout waiting for the router")
+
+	flag.Parse()
+
+	if err := run(*routerURL, *label, *caCert, *keys, *pickup, *timeout); err != nil {
+		logger.Errorf("walletsim : %s", err)
+		os.Exit(1)
+	}
+}
+
+func run(routerURL, label, caCert string, keys, pickup int, timeout time.Duration) error {
+	tlsConfig, err := walletsim.LoadTLSConfig(caCert)
+	if err != nil {
+		return err
+	}
+
+	w, err := walletsim.New(walletsim.WithLabel(label), walletsim.WithTLSConfig(tlsConfig),
+		walletsim.WithTimeout(timeout))
+	if err != nil {
+		return err
+	}
+
+	defer w.Close()
+
+	inv, err := w.FetchInvitation(routerURL)
+	if err != nil {
+		return err
+	}
+
+	connID, err := w.Connect(inv)
+	if err != nil {
+		return err
+	}
+
+	err = w.RegisterMediation(connID)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < keys; i++ {
+		key, err := w.NewKey()
+		if err != nil {
+			return err
+		}
+
+		err = w.AddKeys(connID, key)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(key)
+	}
+
+	if pickup > 0 {
+		count, err := w.Pickup(connID, pickup)
+		if err != nil {
+			return err
+		}
+
+		logger.Infof("picked up %d messages", count)
+	}
+
+	logger.Infof("wallet mediated by the router : connectionID=%s", connID)
+
+	return nil
+}
@@ -0,0 +1,18 @@
+// Copyright SecureKey Technologies Inc. All Rights Reserved.
+//
+// SPDX-License-Identifier: Apache-2.0
+
+module github.com/trustbloc/hub-router/test/tools/walletsim
+
+go 1.15
+
+require (
+	github.com/cenkalti/backoff/v4 v4.1.0 // indirect
+	github.com/google/uuid v1.2.0
+	github.com/hyperledger/aries-framework-go v0.1.7-0.20210526123422-eec182deab9a
+	github.com/hyperledger/aries-framework-go/component/storageutil v0.0.0-20210520055214-ae429bb89bf7
+	github.com/stretchr/testify v1.7.0
+	github.com/trustbloc/edge-core v0.1.7-0.20210527163745-994ae929f957
+	golang.org/x/sys v0.0.0-20210420072515-93ed5bcd2bfe // indirect
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+)
@@ -0,0 +1,233 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package soak
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/trustbloc/edge-core/pkg/log"
+
+	"github.com/trustbloc/hub-router/test/tools/walletsim"
+)
+
+const (
+	diagnosticsPath = "/diagnostics?gc=true"
+	soakMsgType     = "https://trustbloc.dev/soak/1.0/message"
+
+	defaultIterations  = 1000
+	defaultSampleEvery = 50
+	defaultLeakWindow  = 10
+	defaultTimeout     = 30 * time.Second
+)
+
+var logger = log.New("hub-router/soak")
+
+// Config holds the soak test configuration.
+type Config struct {
+	// RouterURL is the hub-router REST API URL.
+	RouterURL string
+	TLSConfig *tls.Config
+	// Iterations is the number of connect/mediate/forward/disconnect loops.
+	Iterations int
+	// SampleEvery is the number of loops between two diagnostics samples.
+	SampleEvery int
+	// LeakWindow is the number of consecutive growing samples reported as a leak.
+	LeakWindow int
+	Timeout    time.Duration
+}
+
+// Sample is a snapshot of the router runtime counters.
+type Sample struct {
+	Iteration   int    `json:"iteration"`
+	Goroutines  int    `json:"goroutines"`
+	HeapObjects uint64 `json:"heapObjects"`
+}
+
+// Run cycles the connect/mediate/forward/disconnect loops against the router while sampling its diagnostics
+// endpoint, and fails as soon as the goroutine or heap counts grow monotonically over the leak window.
+func Run(config *Config) ([]*Sample, error) {
+	setDefaults(config)
+
+	var samples []*Sample
+
+	for i := 1; i <= config.Iterations; i++ {
+		err := loop(config)
+		if err != nil {
+			return samples, fmt.Errorf("iteration %d : %w", i, err)
+		}
+
+		if i%config.SampleEvery != 0 {
+			continue
+		}
+
+		sample, err := Diagnostics(config.RouterURL, config.TLSConfig, config.Timeout)
+		if err != nil {
+			return samples, err
+		}
+
+		sample.Iteration = i
+		samples = append(samples, sample)
+
+		logger.Infof("iteration %d : goroutines=%d heapObjects=%d", i, sample.Goroutines, sample.HeapObjects)
+
+		err = DetectLeak(samples, config.LeakWindow)
+		if err != nil {
+			return samples, err
+		}
+	}
+
+	return samples, nil
+}
+
+// DetectLeak returns an error if the goroutine or heap object counts grew at every one of the last window samples.
+func DetectLeak(samples []*Sample, window int) error {
+	if window < 2 || len(samples) < window {
+		return nil
+	}
+
+	last := samples[len(samples)-window:]
+
+	goroutines, heap := true, true
+
+	for i := 1; i < len(last); i++ {
+		goroutines = goroutines && last[i].Goroutines > last[i-1].Goroutines
+		heap = heap && last[i].HeapObjects > last[i-1].HeapObjects
+	}
+
+	first, latest := last[0], last[len(last)-1]
+
+	if goroutines {
+		return fmt.Errorf("goroutine leak : %d goroutines at iteration %d, %d at iteration %d",
+			first.Goroutines, first.Iteration, latest.Goroutines, latest.Iteration)
+	}
+
+	if heap {
+		return fmt.Errorf("heap leak : %d heap objects at iteration %d, %d at iteration %d",
+			first.HeapObjects, first.Iteration, latest.HeapObjects, latest.Iteration)
+	}
+
+	return nil
+}
+
+// Diagnostics samples the router diagnostics endpoint, after a forced garbage collection.
+func Diagnostics(routerURL string, tlsConfig *tls.Config, timeout time.Duration) (*Sample, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, routerURL+diagnosticsPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create diagnostics request : %w", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get diagnostics : %w", err)
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			logger.Warnf("failed to close diagnostics response body : %s", errClose)
+		}
+	}()
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read diagnostics response : %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get diagnostics : unexpected status %d : %s", resp.StatusCode, respBytes)
+	}
+
+	sample := &Sample{}
+
+	err = json.Unmarshal(respBytes, sample)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal diagnostics : %w", err)
+	}
+
+	return sample, nil
+}
+
+// loop connects a new wallet to the router, registers it for mediation, forwards a message to it through the router,
+// picks the message up and disconnects.
+func loop(config *Config) error {
+	w, err := walletsim.New(walletsim.WithTLSConfig(config.TLSConfig), walletsim.WithTimeout(config.Timeout))
+	if err != nil {
+		return err
+	}
+
+	defer w.Close()
+
+	inv, err := w.FetchInvitation(config.RouterURL)
+	if err != nil {
+		return err
+	}
+
+	connID, err := w.Connect(inv)
+	if err != nil {
+		return err
+	}
+
+	err = w.RegisterMediation(connID)
+	if err != nil {
+		return err
+	}
+
+	key, err := w.NewKey()
+	if err != nil {
+		return err
+	}
+
+	err = w.AddKeys(connID, key)
+	if err != nil {
+		return err
+	}
+
+	err = w.Forward(connID, key, key, service.NewDIDCommMsgMap(map[string]interface{}{
+		"@id":   uuid.New().String(),
+		"@type": soakMsgType,
+	}))
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Pickup(connID, 1)
+	if err != nil {
+		return err
+	}
+
+	return w.Disconnect(connID)
+}
+
+func setDefaults(config *Config) {
+	if config.Iterations <= 0 {
+		config.Iterations = defaultIterations
+	}
+
+	if config.SampleEvery <= 0 {
+		config.SampleEvery = defaultSampleEvery
+	}
+
+	if config.LeakWindow <= 0 {
+		config.LeakWindow = defaultLeakWindow
+	}
+
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package soak
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectLeak(t *testing.T) {
+	t.Run("no leak", func(t *testing.T) {
+		samples := []*Sample{
+			{Iteration: 1, Goroutines: 50, HeapObjects: 1000},
+			{Iteration: 2, Goroutines: 52, HeapObjects: 1200},
+			{Iteration: 3, Goroutines: 51, HeapObjects: 1100},
+			{Iteration: 4, Goroutines: 53, HeapObjects: 1300},
+		}
+
+		require.NoError(t, DetectLeak(samples, 3))
+		require.NoError(t, DetectLeak(samples[:2], 3))
+		require.NoError(t, DetectLeak(samples, 1))
+	})
+
+	t.Run("goroutine leak", func(t *testing.T) {
+		err := DetectLeak([]*Sample{
+			{Iteration: 1, Goroutines: 50, HeapObjects: 1000},
+			{Iteration: 2, Goroutines: 52, HeapObjects: 900},
+			{Iteration: 3, Goroutines: 54, HeapObjects: 1000},
+		}, 3)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "goroutine leak : 50 goroutines at iteration 1, 54 at iteration 3")
+	})
+
+	t.Run("heap leak", func(t *testing.T) {
+		err := DetectLeak([]*Sample{
+			{Iteration: 1, Goroutines: 50, HeapObjects: 1000},
+			{Iteration: 2, Goroutines: 50, HeapObjects: 1100},
+			{Iteration: 3, Goroutines: 50, HeapObjects: 1200},
+		}, 3)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "heap leak")
+	})
+}
+
+func TestDiagnostics(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "true", r.URL.Query().Get("gc"))
+
+			_, err := rw.Write([]byte(`{"goroutines":57,"heapObjects":41210}`))
+			require.NoError(t, err)
+		}))
+		defer srv.Close()
+
+		sample, err := Diagnostics(srv.URL, nil, time.Second)
+		require.NoError(t, err)
+		require.Equal(t, 57, sample.Goroutines)
+		require.Equal(t, uint64(41210), sample.HeapObjects)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(http.StatusNotFound)
+		}))
+		defer srv.Close()
+
+		_, err := Diagnostics(srv.URL, nil, time.Second)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unexpected status 404")
+
+		_, err = Diagnostics("http://localhost:-1", nil, time.Second)
+		require.Error(t, err)
+	})
+}
+
+func TestRun(t *testing.T) {
+	_, err := Run(&Config{RouterURL: "http://localhost:-1", Iterations: 1, Timeout: time.Second})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "iteration 1")
+}
@@ -0,0 +1,372 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package walletsim
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/component/storageutil/mem"
+	"github.com/hyperledger/aries-framework-go/pkg/client/didexchange"
+	"github.com/hyperledger/aries-framework-go/pkg/client/mediator"
+	"github.com/hyperledger/aries-framework-go/pkg/client/messagepickup"
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/decorator"
+	mediatorsvc "github.com/hyperledger/aries-framework-go/pkg/didcomm/protocol/mediator"
+	arieshttp "github.com/hyperledger/aries-framework-go/pkg/didcomm/transport/http"
+	ariesws "github.com/hyperledger/aries-framework-go/pkg/didcomm/transport/ws"
+	"github.com/hyperledger/aries-framework-go/pkg/framework/aries"
+	ariesctx "github.com/hyperledger/aries-framework-go/pkg/framework/context"
+	"github.com/hyperledger/aries-framework-go/pkg/kms"
+	"github.com/hyperledger/aries-framework-go/pkg/vdr/fingerprint"
+	"github.com/trustbloc/edge-core/pkg/log"
+)
+
+const (
+	defaultLabel   = "walletsim"
+	defaultTimeout = 30 * time.Second
+	pollInterval   = 500 * time.Millisecond
+
+	invitationPath  = "/didcomm/invitation"
+	completedState  = "completed"
+	defaultPickupSz = 10
+)
+
+var logger = log.New("hub-router/walletsim")
+
+// keyRegistrar registers recipient keys with the router (keylist update).
+type keyRegistrar interface {
+	AddKey(connID, recKey string) error
+}
+
+type options struct {
+	label     string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+}
+
+// Option configures the simulated wallet.
+type Option func(*options)
+
+// WithLabel sets the label the wallet uses when connecting, defaults to "walletsim".
+func WithLabel(label string) Option {
+	return func(o *options) {
+		o.label = label
+	}
+}
+
+// WithTLSConfig sets the TLS config used to reach the router.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(o *options) {
+		o.tlsConfig = tlsConfig
+	}
+}
+
+// WithTimeout sets how long the wallet waits for the router, defaults to 30s.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// Wallet simulates a mobile wallet (no inbound transport, return route "all") using the hub-router as its mediator.
+type Wallet struct {
+	framework *aries.Aries
+	ctx       *ariesctx.Provider
+	oob       *outofband.Client
+	didex     *didexchange.Client
+	mediator  *mediator.Client
+	pickup    *messagepickup.Client
+	keys      keyRegistrar
+	label     string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+}
+
+// New returns a new simulated wallet with in-memory storage.
+func New(opts ...Option) (*Wallet, error) {
+	o := &options{label: defaultLabel, timeout: defaultTimeout}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	outboundHTTP, err := arieshttp.NewOutbound(arieshttp.WithOutboundTLSConfig(o.tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("create outbound http transport : %w", err)
+	}
+
+	framework, err := aries.New(
+		aries.WithStoreProvider(mem.NewProvider()),
+		aries.WithProtocolStateStoreProvider(mem.NewProvider()),
+		aries.WithOutboundTransports(outboundHTTP, ariesws.NewOutbound()),
+		aries.WithTransportReturnRoute(decorator.TransportReturnRouteAll),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create aries framework : %w", err)
+	}
+
+	w := &Wallet{framework: framework, label: o.label, tlsConfig: o.tlsConfig, timeout: o.timeout}
+
+	err = w.initClients()
+	if err != nil {
+		w.Close()
+
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func (w *Wallet) initClients() error {
+	ctx, err := w.framework.Context()
+	if err != nil {
+		return fmt.Errorf("get aries context : %w", err)
+	}
+
+	w.ctx = ctx
+
+	w.oob, err = outofband.New(ctx)
+	if err != nil {
+		return fmt.Errorf("create out-of-band client : %w", err)
+	}
+
+	w.didex, err = didexchange.New(ctx)
+	if err != nil {
+		return fmt.Errorf("create didexchange client : %w", err)
+	}
+
+	w.mediator, err = mediator.New(ctx, mediator.WithTimeout(w.timeout))
+	if err != nil {
+		return fmt.Errorf("create mediator client : %w", err)
+	}
+
+	w.pickup, err = messagepickup.New(ctx)
+	if err != nil {
+		return fmt.Errorf("create message pickup client : %w", err)
+	}
+
+	svc, err := ctx.Service(mediatorsvc.Coordination)
+	if err != nil {
+		return fmt.Errorf("lookup route service : %w", err)
+	}
+
+	var ok bool
+
+	w.keys, ok = svc.(keyRegistrar)
+	if !ok {
+		return errors.New("cast service to route service failed")
+	}
+
+	// the wallet accepts everything
+	actions := make(chan service.DIDCommAction)
+
+	err = w.didex.RegisterActionEvent(actions)
+	if err != nil {
+		return fmt.Errorf("register didexchange action event : %w", err)
+	}
+
+	go service.AutoExecuteActionEvent(actions)
+
+	return nil
+}
+
+// FetchInvitation gets the out-of-band invitation of the router at the given REST API URL.
+func (w *Wallet) FetchInvitation(routerURL string) (*outofband.Invitation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, routerURL+invitationPath, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create invitation request : %w", err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: w.tlsConfig}}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get invitation : %w", err)
+	}
+
+	defer func() {
+		if errClose := resp.Body.Close(); errClose != nil {
+			logger.Warnf("failed to close invitation response body : %s", errClose)
+		}
+	}()
+
+	respBytes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read invitation response : %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("get invitation : unexpected status %d : %s", resp.StatusCode, respBytes)
+	}
+
+	result := &struct {
+		Invitation *outofband.Invitation `json:"invitation"`
+	}{}
+
+	err = json.Unmarshal(respBytes, result)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal invitation : %w", err)
+	}
+
+	if result.Invitation == nil {
+		return nil, errors.New("no invitation in the router response")
+	}
+
+	return result.Invitation, nil
+}
+
+// Connect accepts the out-of-band invitation and waits until the connection is completed.
+func (w *Wallet) Connect(invitation *outofband.Invitation) (string, error) {
+	connID, err := w.oob.AcceptInvitation(invitation, w.label)
+	if err != nil {
+		return "", fmt.Errorf("accept invitation : %w", err)
+	}
+
+	deadline := time.Now().Add(w.timeout)
+
+	for {
+		conn, err := w.didex.GetConnection(connID)
+		if err == nil && conn.State == completedState {
+			logger.Infof("connection completed : connectionID=%s", connID)
+
+			return connID, nil
+		}
+
+		if time.Now().After(deadline) {
+			return "", fmt.Errorf("connection %s not completed after %s", connID, w.timeout)
+		}
+
+		time.Sleep(pollInterval)
+	}
+}
+
+// RegisterMediation requests mediation from the router on the connection.
+func (w *Wallet) RegisterMediation(connID string) error {
+	err := w.mediator.Register(connID)
+	if err != nil {
+		return fmt.Errorf("register mediation : %w", err)
+	}
+
+	return nil
+}
+
+// NewKey creates a new ED25519 recipient key, as a did:key.
+func (w *Wallet) NewKey() (string, error) {
+	_, pubKeyBytes, err := w.ctx.KMS().CreateAndExportPubKeyBytes(kms.ED25519Type)
+	if err != nil {
+		return "", fmt.Errorf("create key : %w", err)
+	}
+
+	didKey, _ := fingerprint.CreateDIDKey(pubKeyBytes)
+
+	return didKey, nil
+}
+
+// AddKeys registers the recipient keys with the router (keylist update) on the mediated connection.
+func (w *Wallet) AddKeys(connID string, keys ...string) error {
+	for _, key := range keys {
+		err := w.keys.AddKey(connID, key)
+		if err != nil {
+			return fmt.Errorf("add key %s : %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// Forward sends the message to the recipient key through the router mediating the connection, the router queues the
+// message until the recipient picks it up.
+func (w *Wallet) Forward(connID, senderKey, recipientKey string, msg service.DIDCommMsgMap) error {
+	config, err := w.mediator.GetConfig(connID)
+	if err != nil {
+		return fmt.Errorf("get router config : %w", err)
+	}
+
+	err = w.ctx.Messenger().SendToDestination(msg, senderKey, &service.Destination{
+		RecipientKeys:   []string{recipientKey},
+		ServiceEndpoint: config.Endpoint(),
+		RoutingKeys:     config.Keys(),
+	})
+	if err != nil {
+		return fmt.Errorf("forward message : %w", err)
+	}
+
+	return nil
+}
+
+// Pickup picks up the messages queued by the router on the connection, returns the number of messages received.
+func (w *Wallet) Pickup(connID string, batchSize int) (int, error) {
+	if batchSize <= 0 {
+		batchSize = defaultPickupSz
+	}
+
+	status, err := w.pickup.StatusRequest(connID)
+	if err != nil {
+		return 0, fmt.Errorf("pickup status : %w", err)
+	}
+
+	if status.MessageCount == 0 {
+		return 0, nil
+	}
+
+	count, err := w.pickup.BatchPickup(connID, batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("batch pickup : %w", err)
+	}
+
+	return count, nil
+}
+
+// Disconnect unregisters the wallet from the router mediating the connection.
+func (w *Wallet) Disconnect(connID string) error {
+	err := w.mediator.Unregister(connID)
+	if err != nil {
+		return fmt.Errorf("unregister mediation : %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the wallet.
+func (w *Wallet) Close() {
+	if err := w.framework.Close(); err != nil {
+		logger.Warnf("failed to close aries framework : %s", err)
+	}
+}
+
+// LoadTLSConfig returns the TLS config trusting the system certificates and the given CA certificate (PEM), if any.
+func LoadTLSConfig(caCert string) (*tls.Config, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	if caCert != "" {
+		pem, err := ioutil.ReadFile(caCert) //nolint:gosec // file path from the command line
+		if err != nil {
+			return nil, fmt.Errorf("read ca cert : %w", err)
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", caCert)
+		}
+	}
+
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
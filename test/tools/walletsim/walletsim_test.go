@@ -0,0 +1,128 @@
+/*
+Copyright SecureKey Technologies Inc. All Rights Reserved.
+
+SPDX-License-Identifier: Apache-2.0
+*/
+
+package walletsim
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/aries-framework-go/pkg/client/outofband"
+	"github.com/hyperledger/aries-framework-go/pkg/didcomm/common/service"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	w, err := New(WithLabel("wallet"), WithTimeout(time.Second))
+	require.NoError(t, err)
+
+	defer w.Close()
+
+	require.Equal(t, "wallet", w.label)
+	require.Equal(t, time.Second, w.timeout)
+}
+
+func TestFetchInvitation(t *testing.T) {
+	w, err := New()
+	require.NoError(t, err)
+
+	defer w.Close()
+
+	t.Run("success", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			require.Equal(t, invitationPath, r.URL.Path)
+
+			_, err := rw.Write([]byte(`{"invitation":{"@id":"inv-1","label":"hub-router"}}`))
+			require.NoError(t, err)
+		}))
+		defer srv.Close()
+
+		inv, err := w.FetchInvitation(srv.URL)
+		require.NoError(t, err)
+		require.Equal(t, "inv-1", inv.ID)
+	})
+
+	t.Run("unexpected status", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer srv.Close()
+
+		_, err := w.FetchInvitation(srv.URL)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unexpected status 500")
+	})
+
+	t.Run("invalid response", func(t *testing.T) {
+		for _, body := range []string{"invalid", "{}"} {
+			body := body
+
+			srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				_, err := rw.Write([]byte(body))
+				require.NoError(t, err)
+			}))
+
+			_, err := w.FetchInvitation(srv.URL)
+			require.Error(t, err)
+			require.True(t, strings.Contains(err.Error(), "unmarshal invitation") ||
+				strings.Contains(err.Error(), "no invitation"))
+
+			srv.Close()
+		}
+	})
+
+	t.Run("router unreachable", func(t *testing.T) {
+		_, err := w.FetchInvitation("http://localhost:-1")
+		require.Error(t, err)
+	})
+}
+
+func TestWallet(t *testing.T) {
+	w, err := New(WithTimeout(time.Second))
+	require.NoError(t, err)
+
+	defer w.Close()
+
+	t.Run("new key", func(t *testing.T) {
+		key, err := w.NewKey()
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(key, "did:key:"))
+	})
+
+	t.Run("connect error", func(t *testing.T) {
+		_, err := w.Connect(&outofband.Invitation{})
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "accept invitation")
+	})
+
+	t.Run("unknown connection", func(t *testing.T) {
+		require.Error(t, w.RegisterMediation("invalid"))
+		require.Error(t, w.AddKeys("invalid", "key"))
+
+		_, err := w.Pickup("invalid", 0)
+		require.Error(t, err)
+
+		require.Error(t, w.Forward("invalid", "sender", "recipient", service.DIDCommMsgMap{}))
+		require.Error(t, w.Disconnect("invalid"))
+	})
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	tlsConfig, err := LoadTLSConfig("")
+	require.NoError(t, err)
+	require.NotNil(t, tlsConfig.RootCAs)
+
+	_, err = LoadTLSConfig("invalid.pem")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "read ca cert")
+
+	_, err = LoadTLSConfig("go.mod")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "no certificate found")
+}